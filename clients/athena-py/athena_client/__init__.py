@@ -0,0 +1,7 @@
+"""Python client for the ATHENA API, generated from its OpenAPI spec."""
+
+from .client import SDK_VERSION, AthenaApiError, AthenaClient
+
+__version__ = SDK_VERSION
+
+__all__ = ["AthenaApiError", "AthenaClient", "SDK_VERSION", "__version__"]
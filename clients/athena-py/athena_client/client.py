@@ -0,0 +1,804 @@
+# Code generated by sdkgen from the OpenAPI spec. DO NOT EDIT.
+"""Client for the ATHENA API."""
+
+import json
+import urllib.error
+import urllib.parse
+import urllib.request
+from typing import Any, Dict, List, Optional, TypedDict
+
+SDK_VERSION = "1.12.1"
+
+
+class _APIErrorBodyRequired(TypedDict):
+    error: str
+
+
+class APIErrorBody(_APIErrorBodyRequired, total=False):
+    details: str
+
+
+class _ApprovalRequired(TypedDict):
+    approver: str
+    decided_at: str
+    decision: str
+
+
+class Approval(_ApprovalRequired, total=False):
+    reason: str
+
+
+class _ApprovalRequestRequired(TypedDict):
+    approver: str
+
+
+class ApprovalRequest(_ApprovalRequestRequired, total=False):
+    reason: str
+
+
+class _BootConfirmationRequired(TypedDict):
+    release_id: str
+
+
+class BootConfirmation(_BootConfirmationRequired, total=False):
+    slot: str
+
+
+class _CertificateRequestRequired(TypedDict):
+    csr: str
+
+
+class CertificateRequest(_CertificateRequestRequired, total=False):
+    name: str
+
+
+class _ClaimTokenRequired(TypedDict):
+    board_type: str
+    created_at: str
+    expires_at: str
+    status: str
+    template_id: str
+    template_version: str
+    token_id: str
+
+
+class ClaimToken(_ClaimTokenRequired, total=False):
+    claimed_at: Optional[str]
+    description: str
+    device_id: str
+    labels: Dict[str, str]
+    ota_channel: str
+    parameters: Dict[str, Any]
+    parent_id: str
+    token: str
+
+
+class ClaimTokenList(TypedDict):
+    tokens: List[ClaimToken]
+    total: int
+
+
+class _ClaimTokenRequestRequired(TypedDict):
+    board_type: str
+    template_id: str
+    template_version: str
+
+
+class ClaimTokenRequest(_ClaimTokenRequestRequired, total=False):
+    description: str
+    labels: Dict[str, str]
+    ota_channel: str
+    parameters: Dict[str, Any]
+    parent_id: str
+    ttl_seconds: int
+
+
+class _CommandRequired(TypedDict):
+    command_id: str
+    created_at: str
+    device_id: str
+    expires_at: str
+    status: str
+    type: str
+
+
+class Command(_CommandRequired, total=False):
+    completed_at: Optional[str]
+    delivered_at: Optional[str]
+    error: str
+    payload: Dict[str, Any]
+    result: Dict[str, Any]
+
+
+class _CommandAckRequired(TypedDict):
+    status: str
+
+
+class CommandAck(_CommandAckRequired, total=False):
+    error: str
+    result: Dict[str, Any]
+
+
+class CommandList(TypedDict):
+    commands: List[Command]
+    total: int
+
+
+class _CommandRequestRequired(TypedDict):
+    type: str
+
+
+class CommandRequest(_CommandRequestRequired, total=False):
+    payload: Dict[str, Any]
+    ttl_seconds: int
+
+
+class _CredentialRequired(TypedDict):
+    expires_at: str
+    issued_at: str
+    type: str
+    version: int
+
+
+class Credential(_CredentialRequired, total=False):
+    fingerprint: str
+    revoked_at: Optional[str]
+
+
+class _CurrentCheckRequired(TypedDict):
+    component: str
+    max_current: float
+    pin: str
+    required_current: float
+    safe: bool
+
+
+class CurrentCheck(_CurrentCheckRequired, total=False):
+    message: str
+
+
+class _DeploymentRequired(TypedDict):
+    bytes_served: int
+    created_at: str
+    deployment_id: str
+    failure_count: int
+    failure_threshold: int
+    release_id: str
+    rollout_percentage: int
+    status: str
+    strategy: str
+    success_count: int
+    target_devices: List[str]
+    updated_at: str
+
+
+class Deployment(_DeploymentRequired, total=False):
+    annotations: Dict[str, str]
+    approval: Approval
+    name: str
+
+
+class _DeploymentConfigRequired(TypedDict):
+    failure_threshold: int
+    rollout_percentage: int
+    strategy: str
+    target_devices: List[str]
+
+
+class DeploymentConfig(_DeploymentConfigRequired, total=False):
+    annotations: Dict[str, str]
+    data_budget_bytes: int
+    end_at: Optional[str]
+    maintenance_windows: List[str]
+    name: str
+    promotion_success_percentage: int
+    promotion_tiers: List[int]
+    start_at: Optional[str]
+
+
+class DeploymentList(TypedDict):
+    deployments: List[Deployment]
+    total: int
+
+
+class DeploymentRequest(TypedDict):
+    config: DeploymentConfig
+    release_id: str
+
+
+class _DeploymentStatusRequired(TypedDict):
+    bytes_served: int
+    cancelled_count: int
+    completed_count: int
+    crash_count: int
+    crashed_devices: int
+    created_at: str
+    deployment_id: str
+    downloading_count: int
+    failed_count: int
+    installing_count: int
+    pending_count: int
+    progress_percentage: int
+    release_id: str
+    rollout_percentage: int
+    status: str
+    strategy: str
+    total_devices: int
+    updated_at: str
+
+
+class DeploymentStatus(_DeploymentStatusRequired, total=False):
+    failure_codes: Dict[str, int]
+
+
+class _IssuedCredentialRequired(TypedDict):
+    expires_at: str
+    fingerprint: str
+    name: str
+    type: str
+    version: int
+
+
+class IssuedCredential(_IssuedCredentialRequired, total=False):
+    ca_certificate: str
+    certificate: str
+    private_key: str
+    token: str
+
+
+class _DeviceRequired(TypedDict):
+    board_type: str
+    created_at: str
+    device_id: str
+    firmware_hash: str
+    last_seen: str
+    ota_channel: str
+    parameters: Dict[str, Any]
+    status: str
+    template_id: str
+    template_version: str
+    updated_at: str
+
+
+class Device(_DeviceRequired, total=False):
+    issued_credential: IssuedCredential
+    labels: Dict[str, str]
+    name: str
+    ota_enrollment: str
+    parent_id: str
+    reported: Dict[str, Any]
+
+
+class _DeviceClaimRequired(TypedDict):
+    firmware_hash: str
+    token: str
+
+
+class DeviceClaim(_DeviceClaimRequired, total=False):
+    board_type: str
+    device_id: str
+    name: str
+
+
+class _DeviceEventRequired(TypedDict):
+    device_id: str
+    event_id: str
+    message: str
+    source: str
+    timestamp: str
+    type: str
+
+
+class DeviceEvent(_DeviceEventRequired, total=False):
+    data: Dict[str, Any]
+
+
+class _DeviceEventListRequired(TypedDict):
+    count: int
+    device_id: str
+    events: List[DeviceEvent]
+
+
+class DeviceEventList(_DeviceEventListRequired, total=False):
+    next_cursor: str
+
+
+class _DeviceGroupRequired(TypedDict):
+    created_at: str
+    devices: List[str]
+    name: str
+    updated_at: str
+
+
+class DeviceGroup(_DeviceGroupRequired, total=False):
+    description: str
+    labels: Dict[str, str]
+
+
+class DeviceGroupList(TypedDict):
+    groups: List[DeviceGroup]
+    total: int
+
+
+class DeviceGroupMembers(TypedDict):
+    device_ids: List[str]
+
+
+class DeviceList(TypedDict):
+    devices: List[Device]
+    limit: int
+    offset: int
+    total: int
+
+
+class _MetricPointRequired(TypedDict):
+    metric_name: str
+    metric_value: Any
+    timestamp: str
+
+
+class MetricPoint(_MetricPointRequired, total=False):
+    tags: Dict[str, str]
+
+
+class DeviceMetrics(TypedDict):
+    count: int
+    device_id: str
+    metrics: List[MetricPoint]
+
+
+class _DeviceRegistrationRequired(TypedDict):
+    board_type: str
+    firmware_hash: str
+    template_id: str
+    template_version: str
+
+
+class DeviceRegistration(_DeviceRegistrationRequired, total=False):
+    device_id: str
+    labels: Dict[str, str]
+    name: str
+    ota_channel: str
+    ota_enrollment: str
+    parameters: Dict[str, Any]
+    parent_id: str
+
+
+class LoginRequest(TypedDict):
+    password: str
+    username: str
+
+
+class User(TypedDict):
+    id: str
+    roles: List[str]
+    username: str
+
+
+class LoginResponse(TypedDict):
+    expires_at: str
+    token: str
+    user: User
+
+
+class _PinCompatibilityCheckRequired(TypedDict):
+    compatible: bool
+    component: str
+    pin: str
+    pin_type: str
+    required: str
+
+
+class PinCompatibilityCheck(_PinCompatibilityCheckRequired, total=False):
+    message: str
+
+
+class _PromotedResourceRequired(TypedDict):
+    id: str
+    kind: str
+    promoted: bool
+
+
+class PromotedResource(_PromotedResourceRequired, total=False):
+    version: str
+
+
+class Promotion(TypedDict):
+    from: str
+    resources: List[PromotedResource]
+    to: str
+
+
+class PromotionRequest(TypedDict):
+    from: str
+    to: str
+
+
+class _ReleaseSlotsRequired(TypedDict):
+    target_slot: str
+
+
+class ReleaseSlots(_ReleaseSlotsRequired, total=False):
+    boot_flags: List[str]
+    rollback_slot: str
+
+
+class _ReleaseRequired(TypedDict):
+    binary_hash: str
+    binary_size: int
+    channel: str
+    created_at: str
+    created_by: str
+    release_id: str
+    release_notes: str
+    signature: str
+    template_id: str
+    version: str
+
+
+class Release(_ReleaseRequired, total=False):
+    annotations: Dict[str, str]
+    environments: List[str]
+    name: str
+    signing_key_id: str
+    slots: ReleaseSlots
+    template_version: str
+
+
+class ReleaseList(TypedDict):
+    releases: List[Release]
+
+
+class _WiringComponentRequired(TypedDict):
+    id: str
+    name: str
+    type: str
+
+
+class WiringComponent(_WiringComponentRequired, total=False):
+    metadata: Dict[str, Any]
+
+
+class _WiringConnectionRequired(TypedDict):
+    from_component: str
+    from_pin: str
+    to_component: str
+    to_pin: str
+
+
+class WiringConnection(_WiringConnectionRequired, total=False):
+    wire_color: str
+
+
+class _WiringDiagramRequired(TypedDict):
+    components: List[WiringComponent]
+    connections: List[WiringConnection]
+
+
+class WiringDiagram(_WiringDiagramRequired, total=False):
+    metadata: Dict[str, Any]
+
+
+class SafetyCheckRequest(TypedDict):
+    board: str
+    wiring_diagram: WiringDiagram
+
+
+class _VoltageCheckRequired(TypedDict):
+    compatible: bool
+    component: str
+    required_voltage: str
+    supplied_voltage: str
+
+
+class VoltageCheck(_VoltageCheckRequired, total=False):
+    message: str
+
+
+class _SafetyReportRequired(TypedDict):
+    current_checks: List[CurrentCheck]
+    pin_compatibility: List[PinCompatibilityCheck]
+    valid: bool
+    voltage_checks: List[VoltageCheck]
+
+
+class SafetyReport(_SafetyReportRequired, total=False):
+    errors: List[str]
+    warnings: List[str]
+
+
+class _TwinDocumentRequired(TypedDict):
+    state: Dict[str, Any]
+    version: int
+
+
+class TwinDocument(_TwinDocumentRequired, total=False):
+    updated_at: Optional[str]
+
+
+class Twin(TypedDict):
+    desired: TwinDocument
+    device_id: str
+    reported: TwinDocument
+
+
+class TwinDelta(TypedDict):
+    desired_version: int
+    device_id: str
+    reported_version: int
+    state: Dict[str, Any]
+
+
+class _TwinPatchRequired(TypedDict):
+    state: Dict[str, Any]
+
+
+class TwinPatch(_TwinPatchRequired, total=False):
+    version: Optional[int]
+
+
+class _UpdateStatusReportRequired(TypedDict):
+    progress: int
+    release_id: str
+    status: str
+
+
+class UpdateStatusReport(_UpdateStatusReportRequired, total=False):
+    downloaded_bytes: int
+    error_code: str
+    error_message: str
+    error_type: str
+
+
+class _WebhookRequired(TypedDict):
+    created_at: str
+    enabled: bool
+    updated_at: str
+    url: str
+    webhook_id: str
+
+
+class Webhook(_WebhookRequired, total=False):
+    created_by: str
+    description: str
+    events: List[str]
+    secret: str
+
+
+class WebhookList(TypedDict):
+    count: int
+    webhooks: List[Webhook]
+
+
+class _WebhookRequestRequired(TypedDict):
+    url: str
+
+
+class WebhookRequest(_WebhookRequestRequired, total=False):
+    description: str
+    enabled: Optional[bool]
+    events: List[str]
+    secret: str
+
+
+class AthenaApiError(Exception):
+    """Raised for responses with a non-2xx status."""
+
+    def __init__(self, status: int, message: str, details: Optional[str] = None) -> None:
+        super().__init__(f"{status}: {message}" + (f": {details}" if details else ""))
+        self.status = status
+        self.message = message
+        self.details = details
+
+
+class AthenaClient:
+    """Calls the ATHENA API through the gateway."""
+
+    def __init__(self, base_url: str = "http://localhost:8000", token: Optional[str] = None, timeout: float = 30.0) -> None:
+        self.base_url = base_url.rstrip("/")
+        self.token = token
+        self.timeout = timeout
+
+    def login(self, body: LoginRequest) -> LoginResponse:
+        """Exchange a username and password for a token."""
+        return self._request("POST", "/api/v1/auth/login", body)
+
+    def list_device_groups(self) -> DeviceGroupList:
+        """List device groups."""
+        return self._request("GET", "/api/v1/device-groups")
+
+    def create_device_group(self, body: DeviceGroup) -> DeviceGroup:
+        """Create a device group of registered devices (operators only); 409 if the name is taken."""
+        return self._request("POST", "/api/v1/device-groups", body)
+
+    def delete_device_group(self, name: str) -> None:
+        """Remove a device group (operators only)."""
+        self._request("DELETE", f"/api/v1/device-groups/{_quote(name)}")
+
+    def get_device_group(self, name: str) -> DeviceGroup:
+        """Get a device group."""
+        return self._request("GET", f"/api/v1/device-groups/{_quote(name)}")
+
+    def add_device_group_members(self, name: str, body: DeviceGroupMembers) -> DeviceGroup:
+        """Add registered devices to a device group (operators only)."""
+        return self._request("POST", f"/api/v1/device-groups/{_quote(name)}/members", body)
+
+    def remove_device_group_member(self, name: str, device_id: str) -> DeviceGroup:
+        """Remove a device from a device group's members (operators only)."""
+        return self._request("DELETE", f"/api/v1/device-groups/{_quote(name)}/members/{_quote(device_id)}")
+
+    def list_devices(self, *, status: Optional[str] = None, board_type: Optional[str] = None, template_id: Optional[str] = None, ota_channel: Optional[str] = None, parent_id: Optional[str] = None, selector: Optional[str] = None, group: Optional[str] = None, limit: Optional[str] = None, offset: Optional[str] = None) -> DeviceList:
+        """List devices."""
+        return self._request("GET", "/api/v1/devices", None, {"status": status, "board_type": board_type, "template_id": template_id, "ota_channel": ota_channel, "parent_id": parent_id, "selector": selector, "group": group, "limit": limit, "offset": offset})
+
+    def register_device(self, body: DeviceRegistration) -> Device:
+        """Register a provisioned device."""
+        return self._request("POST", "/api/v1/devices", body)
+
+    def claim_device(self, body: DeviceClaim) -> Device:
+        """Register a device with a claim token; 401 for invalid or expired tokens, 409 for used ones."""
+        return self._request("POST", "/api/v1/devices/claim", body)
+
+    def list_claim_tokens(self, *, status: Optional[str] = None) -> ClaimTokenList:
+        """List claim tokens, newest first (operators only)."""
+        return self._request("GET", "/api/v1/devices/claim-tokens", None, {"status": status})
+
+    def create_claim_token(self, body: ClaimTokenRequest) -> ClaimToken:
+        """Create a single-use claim token a pre-provisioned device registers itself with (operators only)."""
+        return self._request("POST", "/api/v1/devices/claim-tokens", body)
+
+    def revoke_claim_token(self, token_id: str) -> None:
+        """Revoke a claim token (operators only)."""
+        self._request("DELETE", f"/api/v1/devices/claim-tokens/{_quote(token_id)}")
+
+    def get_claim_token(self, token_id: str) -> ClaimToken:
+        """Get a claim token (operators only)."""
+        return self._request("GET", f"/api/v1/devices/claim-tokens/{_quote(token_id)}")
+
+    def get_device(self, id: str) -> Device:
+        """Get a device."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}")
+
+    def sign_certificate(self, id: str, body: CertificateRequest) -> IssuedCredential:
+        """Sign a device's certificate signing request (operators only)."""
+        return self._request("POST", f"/api/v1/devices/{_quote(id)}/certificates", body)
+
+    def list_commands(self, id: str, *, status: Optional[str] = None, limit: Optional[str] = None) -> CommandList:
+        """List the commands sent to a device, newest first."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/commands", None, {"status": status, "limit": limit})
+
+    def send_command(self, id: str, body: CommandRequest) -> Command:
+        """Send a command to a device (operators only)."""
+        return self._request("POST", f"/api/v1/devices/{_quote(id)}/commands", body)
+
+    def poll_commands(self, id: str) -> CommandList:
+        """Get the commands waiting for a device and mark them delivered."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/commands/pending")
+
+    def get_command(self, id: str, cmd_id: str) -> Command:
+        """Get a command sent to a device."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/commands/{_quote(cmd_id)}")
+
+    def ack_command(self, id: str, cmd_id: str, body: CommandAck) -> Command:
+        """Report that a device ran a command; 409 if it already completed or expired."""
+        return self._request("POST", f"/api/v1/devices/{_quote(id)}/commands/{_quote(cmd_id)}/ack", body)
+
+    def revoke_credential(self, id: str, name: str) -> Credential:
+        """Revoke a device credential (operators only)."""
+        return self._request("POST", f"/api/v1/devices/{_quote(id)}/credentials/{_quote(name)}/revoke")
+
+    def list_device_events(self, id: str, *, type: Optional[str] = None, since: Optional[str] = None, until: Optional[str] = None, limit: Optional[str] = None, cursor: Optional[str] = None) -> DeviceEventList:
+        """List a device's lifecycle events, newest first, filtered by comma separated types and an RFC 3339 time range."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/events", None, {"type": type, "since": since, "until": until, "limit": limit, "cursor": cursor})
+
+    def get_twin(self, id: str) -> Twin:
+        """Get the desired and reported state of a device."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/twin")
+
+    def get_twin_delta(self, id: str) -> TwinDelta:
+        """Get the desired state a device has not reported yet."""
+        return self._request("GET", f"/api/v1/devices/{_quote(id)}/twin/delta")
+
+    def update_desired_state(self, id: str, body: TwinPatch) -> Twin:
+        """Patch the desired state of a device (operators only); 409 if version is stale."""
+        return self._request("PATCH", f"/api/v1/devices/{_quote(id)}/twin/desired", body)
+
+    def report_twin_state(self, id: str, body: TwinPatch) -> Twin:
+        """Patch the reported state of a device, authorized by the device's own token; 409 if version is stale."""
+        return self._request("POST", f"/api/v1/devices/{_quote(id)}/twin/reported", body)
+
+    def check_safety(self, body: SafetyCheckRequest) -> SafetyReport:
+        """Check the electrical safety of a wiring diagram on a board, e.g. after editing a generated plan."""
+        return self._request("POST", "/api/v1/nlp/safety-check", body)
+
+    def list_deployments(self, *, release_id: Optional[str] = None) -> DeploymentList:
+        """List the deployments of a release, or the active deployments."""
+        return self._request("GET", "/api/v1/ota/deployments", None, {"release_id": release_id})
+
+    def create_deployment(self, body: DeploymentRequest) -> Deployment:
+        """Deploy a release to devices."""
+        return self._request("POST", "/api/v1/ota/deployments", body)
+
+    def get_deployment(self, deployment_id: str) -> Deployment:
+        """Get a deployment."""
+        return self._request("GET", f"/api/v1/ota/deployments/{_quote(deployment_id)}")
+
+    def approve_deployment(self, deployment_id: str, body: ApprovalRequest) -> Deployment:
+        """Approve a deployment pending approval (administrators only)."""
+        return self._request("POST", f"/api/v1/ota/deployments/{_quote(deployment_id)}/approve", body)
+
+    def cancel_deployment(self, deployment_id: str) -> Deployment:
+        """Cancel a deployment."""
+        return self._request("POST", f"/api/v1/ota/deployments/{_quote(deployment_id)}/cancel")
+
+    def reject_deployment(self, deployment_id: str, body: ApprovalRequest) -> Deployment:
+        """Reject a deployment pending approval (administrators only)."""
+        return self._request("POST", f"/api/v1/ota/deployments/{_quote(deployment_id)}/reject", body)
+
+    def get_deployment_status(self, deployment_id: str) -> DeploymentStatus:
+        """Get a deployment's progress, with failures counted by error code."""
+        return self._request("GET", f"/api/v1/ota/deployments/{_quote(deployment_id)}/status")
+
+    def report_update_status(self, device_id: str, body: UpdateStatusReport) -> None:
+        """Report the status of a device's update, with an error code if it failed."""
+        self._request("POST", f"/api/v1/ota/devices/{_quote(device_id)}/updates/status", body)
+
+    def list_releases(self, *, template_id: Optional[str] = None, channel: Optional[str] = None, environment: Optional[str] = None) -> ReleaseList:
+        """List firmware releases."""
+        return self._request("GET", "/api/v1/ota/releases", None, {"template_id": template_id, "channel": channel, "environment": environment})
+
+    def get_release(self, release_id: str) -> Release:
+        """Get a firmware release."""
+        return self._request("GET", f"/api/v1/ota/releases/{_quote(release_id)}")
+
+    def promote_release(self, release_id: str, body: PromotionRequest) -> Promotion:
+        """Promote a release and its template version to the next environment."""
+        return self._request("POST", f"/api/v1/ota/releases/{_quote(release_id)}/promote", body)
+
+    def confirm_boot(self, device_id: str, body: BootConfirmation) -> None:
+        """Confirm a device booted the release it was updated to, after flashing it."""
+        self._request("POST", f"/api/v1/ota/updates/{_quote(device_id)}/confirm-boot", body)
+
+    def list_webhooks(self) -> WebhookList:
+        """List the webhooks receiving OTA events."""
+        return self._request("GET", "/api/v1/ota/webhooks")
+
+    def create_webhook(self, body: WebhookRequest) -> Webhook:
+        """Register a webhook; its secret is only returned here (administrators only)."""
+        return self._request("POST", "/api/v1/ota/webhooks", body)
+
+    def delete_webhook(self, webhook_id: str) -> None:
+        """Remove a webhook (administrators only)."""
+        self._request("DELETE", f"/api/v1/ota/webhooks/{_quote(webhook_id)}")
+
+    def get_metrics(self, device_id: str, *, start: Optional[str] = None, end: Optional[str] = None) -> DeviceMetrics:
+        """Get a device's metrics, by default of the last 24 hours."""
+        return self._request("GET", f"/api/v1/telemetry/metrics/{_quote(device_id)}", None, {"start": start, "end": end})
+
+    def _request(self, method: str, path: str, body: Any = None, query: Optional[Dict[str, Optional[str]]] = None) -> Any:
+        url = self.base_url + path
+        params = {key: value for key, value in (query or {}).items() if value}
+        if params:
+            url += "?" + urllib.parse.urlencode(params)
+        headers = {"Accept": "application/json"}
+        data = None
+        if body is not None:
+            headers["Content-Type"] = "application/json"
+            data = json.dumps(body).encode("utf-8")
+        if self.token:
+            headers["Authorization"] = "Bearer " + self.token
+        request = urllib.request.Request(url, data=data, headers=headers, method=method)
+        try:
+            with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                payload = response.read()
+        except urllib.error.HTTPError as err:
+            try:
+                error = json.loads(err.read() or b"{}")
+            except ValueError:
+                error = {}
+            raise AthenaApiError(err.code, error.get("error") or err.reason, error.get("details")) from None
+        return json.loads(payload) if payload else None
+
+
+def _quote(value: str) -> str:
+    return urllib.parse.quote(value, safe="")
@@ -0,0 +1,734 @@
+// Code generated by sdkgen from the OpenAPI spec. DO NOT EDIT.
+
+export const SDK_VERSION = "1.12.1";
+
+export interface APIErrorBody {
+  details?: string;
+  error: string;
+}
+
+export interface Approval {
+  approver: string;
+  decided_at: string;
+  decision: string;
+  reason?: string;
+}
+
+export interface ApprovalRequest {
+  approver: string;
+  reason?: string;
+}
+
+export interface BootConfirmation {
+  release_id: string;
+  slot?: string;
+}
+
+export interface CertificateRequest {
+  csr: string;
+  name?: string;
+}
+
+export interface ClaimToken {
+  board_type: string;
+  claimed_at?: string | null;
+  created_at: string;
+  description?: string;
+  device_id?: string;
+  expires_at: string;
+  labels?: Record<string, string>;
+  ota_channel?: string;
+  parameters?: Record<string, unknown>;
+  parent_id?: string;
+  status: string;
+  template_id: string;
+  template_version: string;
+  token?: string;
+  token_id: string;
+}
+
+export interface ClaimTokenList {
+  tokens: ClaimToken[];
+  total: number;
+}
+
+export interface ClaimTokenRequest {
+  board_type: string;
+  description?: string;
+  labels?: Record<string, string>;
+  ota_channel?: string;
+  parameters?: Record<string, unknown>;
+  parent_id?: string;
+  template_id: string;
+  template_version: string;
+  ttl_seconds?: number;
+}
+
+export interface Command {
+  command_id: string;
+  completed_at?: string | null;
+  created_at: string;
+  delivered_at?: string | null;
+  device_id: string;
+  error?: string;
+  expires_at: string;
+  payload?: Record<string, unknown>;
+  result?: Record<string, unknown>;
+  status: string;
+  type: string;
+}
+
+export interface CommandAck {
+  error?: string;
+  result?: Record<string, unknown>;
+  status: string;
+}
+
+export interface CommandList {
+  commands: Command[];
+  total: number;
+}
+
+export interface CommandRequest {
+  payload?: Record<string, unknown>;
+  ttl_seconds?: number;
+  type: string;
+}
+
+export interface Credential {
+  expires_at: string;
+  fingerprint?: string;
+  issued_at: string;
+  revoked_at?: string | null;
+  type: string;
+  version: number;
+}
+
+export interface CurrentCheck {
+  component: string;
+  max_current: number;
+  message?: string;
+  pin: string;
+  required_current: number;
+  safe: boolean;
+}
+
+export interface Deployment {
+  annotations?: Record<string, string>;
+  approval?: Approval;
+  bytes_served: number;
+  created_at: string;
+  deployment_id: string;
+  failure_count: number;
+  failure_threshold: number;
+  name?: string;
+  release_id: string;
+  rollout_percentage: number;
+  status: string;
+  strategy: string;
+  success_count: number;
+  target_devices: string[];
+  updated_at: string;
+}
+
+export interface DeploymentConfig {
+  annotations?: Record<string, string>;
+  data_budget_bytes?: number;
+  end_at?: string | null;
+  failure_threshold: number;
+  maintenance_windows?: string[];
+  name?: string;
+  promotion_success_percentage?: number;
+  promotion_tiers?: number[];
+  rollout_percentage: number;
+  start_at?: string | null;
+  strategy: string;
+  target_devices: string[];
+}
+
+export interface DeploymentList {
+  deployments: Deployment[];
+  total: number;
+}
+
+export interface DeploymentRequest {
+  config: DeploymentConfig;
+  release_id: string;
+}
+
+export interface DeploymentStatus {
+  bytes_served: number;
+  cancelled_count: number;
+  completed_count: number;
+  crash_count: number;
+  crashed_devices: number;
+  created_at: string;
+  deployment_id: string;
+  downloading_count: number;
+  failed_count: number;
+  failure_codes?: Record<string, number>;
+  installing_count: number;
+  pending_count: number;
+  progress_percentage: number;
+  release_id: string;
+  rollout_percentage: number;
+  status: string;
+  strategy: string;
+  total_devices: number;
+  updated_at: string;
+}
+
+export interface Device {
+  board_type: string;
+  created_at: string;
+  device_id: string;
+  firmware_hash: string;
+  issued_credential?: IssuedCredential;
+  labels?: Record<string, string>;
+  last_seen: string;
+  name?: string;
+  ota_channel: string;
+  ota_enrollment?: string;
+  parameters: Record<string, unknown>;
+  parent_id?: string;
+  reported?: Record<string, unknown>;
+  status: string;
+  template_id: string;
+  template_version: string;
+  updated_at: string;
+}
+
+export interface DeviceClaim {
+  board_type?: string;
+  device_id?: string;
+  firmware_hash: string;
+  name?: string;
+  token: string;
+}
+
+export interface DeviceEvent {
+  data?: Record<string, unknown>;
+  device_id: string;
+  event_id: string;
+  message: string;
+  source: string;
+  timestamp: string;
+  type: string;
+}
+
+export interface DeviceEventList {
+  count: number;
+  device_id: string;
+  events: DeviceEvent[];
+  next_cursor?: string;
+}
+
+export interface DeviceGroup {
+  created_at: string;
+  description?: string;
+  devices: string[];
+  labels?: Record<string, string>;
+  name: string;
+  updated_at: string;
+}
+
+export interface DeviceGroupList {
+  groups: DeviceGroup[];
+  total: number;
+}
+
+export interface DeviceGroupMembers {
+  device_ids: string[];
+}
+
+export interface DeviceList {
+  devices: Device[];
+  limit: number;
+  offset: number;
+  total: number;
+}
+
+export interface DeviceMetrics {
+  count: number;
+  device_id: string;
+  metrics: MetricPoint[];
+}
+
+export interface DeviceRegistration {
+  board_type: string;
+  device_id?: string;
+  firmware_hash: string;
+  labels?: Record<string, string>;
+  name?: string;
+  ota_channel?: string;
+  ota_enrollment?: string;
+  parameters?: Record<string, unknown>;
+  parent_id?: string;
+  template_id: string;
+  template_version: string;
+}
+
+export interface IssuedCredential {
+  ca_certificate?: string;
+  certificate?: string;
+  expires_at: string;
+  fingerprint: string;
+  name: string;
+  private_key?: string;
+  token?: string;
+  type: string;
+  version: number;
+}
+
+export interface LoginRequest {
+  password: string;
+  username: string;
+}
+
+export interface LoginResponse {
+  expires_at: string;
+  token: string;
+  user: User;
+}
+
+export interface MetricPoint {
+  metric_name: string;
+  metric_value: unknown;
+  tags?: Record<string, string>;
+  timestamp: string;
+}
+
+export interface PinCompatibilityCheck {
+  compatible: boolean;
+  component: string;
+  message?: string;
+  pin: string;
+  pin_type: string;
+  required: string;
+}
+
+export interface PromotedResource {
+  id: string;
+  kind: string;
+  promoted: boolean;
+  version?: string;
+}
+
+export interface Promotion {
+  from: string;
+  resources: PromotedResource[];
+  to: string;
+}
+
+export interface PromotionRequest {
+  from: string;
+  to: string;
+}
+
+export interface Release {
+  annotations?: Record<string, string>;
+  binary_hash: string;
+  binary_size: number;
+  channel: string;
+  created_at: string;
+  created_by: string;
+  environments?: string[];
+  name?: string;
+  release_id: string;
+  release_notes: string;
+  signature: string;
+  signing_key_id?: string;
+  slots?: ReleaseSlots;
+  template_id: string;
+  template_version?: string;
+  version: string;
+}
+
+export interface ReleaseList {
+  releases: Release[];
+}
+
+export interface ReleaseSlots {
+  boot_flags?: string[];
+  rollback_slot?: string;
+  target_slot: string;
+}
+
+export interface SafetyCheckRequest {
+  board: string;
+  wiring_diagram: WiringDiagram;
+}
+
+export interface SafetyReport {
+  current_checks: CurrentCheck[];
+  errors?: string[];
+  pin_compatibility: PinCompatibilityCheck[];
+  valid: boolean;
+  voltage_checks: VoltageCheck[];
+  warnings?: string[];
+}
+
+export interface Twin {
+  desired: TwinDocument;
+  device_id: string;
+  reported: TwinDocument;
+}
+
+export interface TwinDelta {
+  desired_version: number;
+  device_id: string;
+  reported_version: number;
+  state: Record<string, unknown>;
+}
+
+export interface TwinDocument {
+  state: Record<string, unknown>;
+  updated_at?: string | null;
+  version: number;
+}
+
+export interface TwinPatch {
+  state: Record<string, unknown>;
+  version?: number | null;
+}
+
+export interface UpdateStatusReport {
+  downloaded_bytes?: number;
+  error_code?: string;
+  error_message?: string;
+  error_type?: string;
+  progress: number;
+  release_id: string;
+  status: string;
+}
+
+export interface User {
+  id: string;
+  roles: string[];
+  username: string;
+}
+
+export interface VoltageCheck {
+  compatible: boolean;
+  component: string;
+  message?: string;
+  required_voltage: string;
+  supplied_voltage: string;
+}
+
+export interface Webhook {
+  created_at: string;
+  created_by?: string;
+  description?: string;
+  enabled: boolean;
+  events?: string[];
+  secret?: string;
+  updated_at: string;
+  url: string;
+  webhook_id: string;
+}
+
+export interface WebhookList {
+  count: number;
+  webhooks: Webhook[];
+}
+
+export interface WebhookRequest {
+  description?: string;
+  enabled?: boolean | null;
+  events?: string[];
+  secret?: string;
+  url: string;
+}
+
+export interface WiringComponent {
+  id: string;
+  metadata?: Record<string, unknown>;
+  name: string;
+  type: string;
+}
+
+export interface WiringConnection {
+  from_component: string;
+  from_pin: string;
+  to_component: string;
+  to_pin: string;
+  wire_color?: string;
+}
+
+export interface WiringDiagram {
+  components: WiringComponent[];
+  connections: WiringConnection[];
+  metadata?: Record<string, unknown>;
+}
+
+export class AthenaApiError extends Error {
+  constructor(public readonly status: number, message: string, public readonly details?: string) {
+    super(message);
+    this.name = "AthenaApiError";
+  }
+}
+
+export interface AthenaClientOptions {
+  baseUrl?: string;
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+export class AthenaClient {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+  token?: string;
+
+  constructor(options: AthenaClientOptions = {}) {
+    this.baseUrl = (options.baseUrl ?? "http://localhost:8000").replace(/\/+$/, "");
+    this.token = options.token;
+    this.fetchImpl = options.fetch ?? fetch;
+  }
+
+  /** Exchange a username and password for a token */
+  login(body: LoginRequest): Promise<LoginResponse> {
+    return this.request("POST", "/api/v1/auth/login", body);
+  }
+
+  /** List device groups */
+  listDeviceGroups(): Promise<DeviceGroupList> {
+    return this.request("GET", "/api/v1/device-groups");
+  }
+
+  /** Create a device group of registered devices (operators only); 409 if the name is taken */
+  createDeviceGroup(body: DeviceGroup): Promise<DeviceGroup> {
+    return this.request("POST", "/api/v1/device-groups", body);
+  }
+
+  /** Remove a device group (operators only) */
+  deleteDeviceGroup(name: string): Promise<void> {
+    return this.request("DELETE", `/api/v1/device-groups/${encodeURIComponent(name)}`);
+  }
+
+  /** Get a device group */
+  getDeviceGroup(name: string): Promise<DeviceGroup> {
+    return this.request("GET", `/api/v1/device-groups/${encodeURIComponent(name)}`);
+  }
+
+  /** Add registered devices to a device group (operators only) */
+  addDeviceGroupMembers(name: string, body: DeviceGroupMembers): Promise<DeviceGroup> {
+    return this.request("POST", `/api/v1/device-groups/${encodeURIComponent(name)}/members`, body);
+  }
+
+  /** Remove a device from a device group's members (operators only) */
+  removeDeviceGroupMember(name: string, deviceId: string): Promise<DeviceGroup> {
+    return this.request("DELETE", `/api/v1/device-groups/${encodeURIComponent(name)}/members/${encodeURIComponent(deviceId)}`);
+  }
+
+  /** List devices */
+  listDevices(query: { status?: string; board_type?: string; template_id?: string; ota_channel?: string; parent_id?: string; selector?: string; group?: string; limit?: string; offset?: string } = {}): Promise<DeviceList> {
+    return this.request("GET", "/api/v1/devices", undefined, query);
+  }
+
+  /** Register a provisioned device */
+  registerDevice(body: DeviceRegistration): Promise<Device> {
+    return this.request("POST", "/api/v1/devices", body);
+  }
+
+  /** Register a device with a claim token; 401 for invalid or expired tokens, 409 for used ones */
+  claimDevice(body: DeviceClaim): Promise<Device> {
+    return this.request("POST", "/api/v1/devices/claim", body);
+  }
+
+  /** List claim tokens, newest first (operators only) */
+  listClaimTokens(query: { status?: string } = {}): Promise<ClaimTokenList> {
+    return this.request("GET", "/api/v1/devices/claim-tokens", undefined, query);
+  }
+
+  /** Create a single-use claim token a pre-provisioned device registers itself with (operators only) */
+  createClaimToken(body: ClaimTokenRequest): Promise<ClaimToken> {
+    return this.request("POST", "/api/v1/devices/claim-tokens", body);
+  }
+
+  /** Revoke a claim token (operators only) */
+  revokeClaimToken(tokenId: string): Promise<void> {
+    return this.request("DELETE", `/api/v1/devices/claim-tokens/${encodeURIComponent(tokenId)}`);
+  }
+
+  /** Get a claim token (operators only) */
+  getClaimToken(tokenId: string): Promise<ClaimToken> {
+    return this.request("GET", `/api/v1/devices/claim-tokens/${encodeURIComponent(tokenId)}`);
+  }
+
+  /** Get a device */
+  getDevice(id: string): Promise<Device> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}`);
+  }
+
+  /** Sign a device's certificate signing request (operators only) */
+  signCertificate(id: string, body: CertificateRequest): Promise<IssuedCredential> {
+    return this.request("POST", `/api/v1/devices/${encodeURIComponent(id)}/certificates`, body);
+  }
+
+  /** List the commands sent to a device, newest first */
+  listCommands(id: string, query: { status?: string; limit?: string } = {}): Promise<CommandList> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/commands`, undefined, query);
+  }
+
+  /** Send a command to a device (operators only) */
+  sendCommand(id: string, body: CommandRequest): Promise<Command> {
+    return this.request("POST", `/api/v1/devices/${encodeURIComponent(id)}/commands`, body);
+  }
+
+  /** Get the commands waiting for a device and mark them delivered */
+  pollCommands(id: string): Promise<CommandList> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/commands/pending`);
+  }
+
+  /** Get a command sent to a device */
+  getCommand(id: string, cmdId: string): Promise<Command> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/commands/${encodeURIComponent(cmdId)}`);
+  }
+
+  /** Report that a device ran a command; 409 if it already completed or expired */
+  ackCommand(id: string, cmdId: string, body: CommandAck): Promise<Command> {
+    return this.request("POST", `/api/v1/devices/${encodeURIComponent(id)}/commands/${encodeURIComponent(cmdId)}/ack`, body);
+  }
+
+  /** Revoke a device credential (operators only) */
+  revokeCredential(id: string, name: string): Promise<Credential> {
+    return this.request("POST", `/api/v1/devices/${encodeURIComponent(id)}/credentials/${encodeURIComponent(name)}/revoke`);
+  }
+
+  /** List a device's lifecycle events, newest first, filtered by comma separated types and an RFC 3339 time range */
+  listDeviceEvents(id: string, query: { type?: string; since?: string; until?: string; limit?: string; cursor?: string } = {}): Promise<DeviceEventList> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/events`, undefined, query);
+  }
+
+  /** Get the desired and reported state of a device */
+  getTwin(id: string): Promise<Twin> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/twin`);
+  }
+
+  /** Get the desired state a device has not reported yet */
+  getTwinDelta(id: string): Promise<TwinDelta> {
+    return this.request("GET", `/api/v1/devices/${encodeURIComponent(id)}/twin/delta`);
+  }
+
+  /** Patch the desired state of a device (operators only); 409 if version is stale */
+  updateDesiredState(id: string, body: TwinPatch): Promise<Twin> {
+    return this.request("PATCH", `/api/v1/devices/${encodeURIComponent(id)}/twin/desired`, body);
+  }
+
+  /** Patch the reported state of a device, authorized by the device's own token; 409 if version is stale */
+  reportTwinState(id: string, body: TwinPatch): Promise<Twin> {
+    return this.request("POST", `/api/v1/devices/${encodeURIComponent(id)}/twin/reported`, body);
+  }
+
+  /** Check the electrical safety of a wiring diagram on a board, e.g. after editing a generated plan */
+  checkSafety(body: SafetyCheckRequest): Promise<SafetyReport> {
+    return this.request("POST", "/api/v1/nlp/safety-check", body);
+  }
+
+  /** List the deployments of a release, or the active deployments */
+  listDeployments(query: { release_id?: string } = {}): Promise<DeploymentList> {
+    return this.request("GET", "/api/v1/ota/deployments", undefined, query);
+  }
+
+  /** Deploy a release to devices */
+  createDeployment(body: DeploymentRequest): Promise<Deployment> {
+    return this.request("POST", "/api/v1/ota/deployments", body);
+  }
+
+  /** Get a deployment */
+  getDeployment(deploymentId: string): Promise<Deployment> {
+    return this.request("GET", `/api/v1/ota/deployments/${encodeURIComponent(deploymentId)}`);
+  }
+
+  /** Approve a deployment pending approval (administrators only) */
+  approveDeployment(deploymentId: string, body: ApprovalRequest): Promise<Deployment> {
+    return this.request("POST", `/api/v1/ota/deployments/${encodeURIComponent(deploymentId)}/approve`, body);
+  }
+
+  /** Cancel a deployment */
+  cancelDeployment(deploymentId: string): Promise<Deployment> {
+    return this.request("POST", `/api/v1/ota/deployments/${encodeURIComponent(deploymentId)}/cancel`);
+  }
+
+  /** Reject a deployment pending approval (administrators only) */
+  rejectDeployment(deploymentId: string, body: ApprovalRequest): Promise<Deployment> {
+    return this.request("POST", `/api/v1/ota/deployments/${encodeURIComponent(deploymentId)}/reject`, body);
+  }
+
+  /** Get a deployment's progress, with failures counted by error code */
+  getDeploymentStatus(deploymentId: string): Promise<DeploymentStatus> {
+    return this.request("GET", `/api/v1/ota/deployments/${encodeURIComponent(deploymentId)}/status`);
+  }
+
+  /** Report the status of a device's update, with an error code if it failed */
+  reportUpdateStatus(deviceId: string, body: UpdateStatusReport): Promise<void> {
+    return this.request("POST", `/api/v1/ota/devices/${encodeURIComponent(deviceId)}/updates/status`, body);
+  }
+
+  /** List firmware releases */
+  listReleases(query: { template_id?: string; channel?: string; environment?: string } = {}): Promise<ReleaseList> {
+    return this.request("GET", "/api/v1/ota/releases", undefined, query);
+  }
+
+  /** Get a firmware release */
+  getRelease(releaseId: string): Promise<Release> {
+    return this.request("GET", `/api/v1/ota/releases/${encodeURIComponent(releaseId)}`);
+  }
+
+  /** Promote a release and its template version to the next environment */
+  promoteRelease(releaseId: string, body: PromotionRequest): Promise<Promotion> {
+    return this.request("POST", `/api/v1/ota/releases/${encodeURIComponent(releaseId)}/promote`, body);
+  }
+
+  /** Confirm a device booted the release it was updated to, after flashing it */
+  confirmBoot(deviceId: string, body: BootConfirmation): Promise<void> {
+    return this.request("POST", `/api/v1/ota/updates/${encodeURIComponent(deviceId)}/confirm-boot`, body);
+  }
+
+  /** List the webhooks receiving OTA events */
+  listWebhooks(): Promise<WebhookList> {
+    return this.request("GET", "/api/v1/ota/webhooks");
+  }
+
+  /** Register a webhook; its secret is only returned here (administrators only) */
+  createWebhook(body: WebhookRequest): Promise<Webhook> {
+    return this.request("POST", "/api/v1/ota/webhooks", body);
+  }
+
+  /** Remove a webhook (administrators only) */
+  deleteWebhook(webhookId: string): Promise<void> {
+    return this.request("DELETE", `/api/v1/ota/webhooks/${encodeURIComponent(webhookId)}`);
+  }
+
+  /** Get a device's metrics, by default of the last 24 hours */
+  getMetrics(deviceId: string, query: { start?: string; end?: string } = {}): Promise<DeviceMetrics> {
+    return this.request("GET", `/api/v1/telemetry/metrics/${encodeURIComponent(deviceId)}`, undefined, query);
+  }
+
+  private async request<T>(method: string, path: string, body?: unknown, query?: Record<string, string | undefined>): Promise<T> {
+    const url = new URL(this.baseUrl + path);
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== "") {
+        url.searchParams.set(key, value);
+      }
+    }
+    const headers: Record<string, string> = { Accept: "application/json" };
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    if (this.token) {
+      headers["Authorization"] = `Bearer ${this.token}`;
+    }
+    const response = await this.fetchImpl(url.toString(), {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const payload = await response.json().catch(() => undefined);
+    if (!response.ok) {
+      throw new AthenaApiError(response.status, payload?.error ?? response.statusText, payload?.details);
+    }
+    return payload as T;
+  }
+}
@@ -0,0 +1,230 @@
+// Code generated by editorgen. DO NOT EDIT.
+
+export const EDITOR_API_VERSION = "v1";
+
+export interface EditorSourceFile {
+  path: string;
+  section?: string;
+  content: string;
+}
+
+export interface EditorLibrary {
+  name: string;
+  version: string;
+  url?: string;
+}
+
+export interface EditorTemplateSource {
+  template_id: string;
+  version: string;
+  name: string;
+  files: EditorSourceFile[];
+  schema?: Record<string, unknown>;
+  parameters?: Record<string, unknown>;
+  libraries?: EditorLibrary[];
+  blocks?: string[];
+  generated: boolean;
+}
+
+export interface EditorForkRequest {
+  id: string;
+  name?: string;
+  version?: string;
+  description?: string;
+  files: EditorSourceFile[];
+  parameters?: Record<string, unknown>;
+}
+
+export interface EditorTemplate {
+  id: string;
+  name: string;
+  version: string;
+  category: string;
+  description: string;
+  boards_supported: string[];
+  parameters: Record<string, unknown>;
+  libraries: EditorLibrary[];
+  forked_from?: string;
+}
+
+export interface EditorPreviewRequest {
+  version?: string;
+  parameters: Record<string, unknown>;
+  snippets?: string;
+  previous_code?: string;
+}
+
+export interface EditorPreviewResult {
+  template_id: string;
+  version: string;
+  parameters: Record<string, unknown>;
+  rendered_code: string;
+  blocks?: string[];
+  diff?: string;
+  changed: boolean;
+}
+
+export interface EditorCompileRequest {
+  template_id: string;
+  template_version?: string;
+  template_code: string;
+  parameters: Record<string, unknown>;
+  schema?: Record<string, unknown>;
+  board: string;
+  libraries?: EditorLibrary[];
+  overrides?: Record<string, string>;
+  snippets?: string;
+}
+
+export interface EditorBinarySize {
+  program_size: number;
+  data_size: number;
+  max_program: number;
+  max_data: number;
+}
+
+export interface EditorDiagnostic {
+  file: string;
+  line: number;
+  column: number;
+  message: string;
+  type?: string;
+}
+
+export interface EditorCompileResult {
+  success: boolean;
+  artifact_id?: string;
+  duration: string;
+  cache_hit?: boolean;
+  binary_hash?: string;
+  size?: EditorBinarySize;
+  errors?: EditorDiagnostic[];
+  warnings?: EditorDiagnostic[];
+}
+
+export interface EditorFlashRequest {
+  port: string;
+  board: string;
+  artifact_id: string;
+  verify_flash: boolean;
+  health_check: boolean;
+}
+
+export interface EditorFlashError {
+  type: string;
+  message: string;
+  code?: number;
+}
+
+export interface EditorFlashResult {
+  success: boolean;
+  port?: string;
+  board?: string;
+  duration: string;
+  verify_result?: Record<string, unknown>;
+  health_check?: Record<string, unknown>;
+  errors?: EditorFlashError[];
+}
+
+export interface EditorPortList {
+  ports: string[];
+  count: number;
+}
+
+export interface EditorEndpointInfo {
+  name: string;
+  method: string;
+  path: string;
+}
+
+export interface EditorAPIInfo {
+  api_version: string;
+  base_path: string;
+  endpoints: EditorEndpointInfo[];
+}
+
+export class EditorApiError extends Error {
+  constructor(public readonly status: number, message: string) {
+    super(message);
+    this.name = "EditorApiError";
+  }
+}
+
+export interface EditorClientOptions {
+  baseUrl?: string;
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+export class EditorClient {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+  token?: string;
+
+  constructor(options: EditorClientOptions = {}) {
+    this.baseUrl = (options.baseUrl ?? "http://localhost:8080").replace(/\/+$/, "");
+    this.token = options.token;
+    this.fetchImpl = options.fetch ?? fetch;
+  }
+
+  /** Discover the API version and endpoints */
+  info(): Promise<EditorAPIInfo> {
+    return this.request("GET", "/info");
+  }
+
+  /** Open a template in the editor */
+  getTemplateSource(id: string, query: { version?: string } = {}): Promise<EditorTemplateSource> {
+    return this.request("GET", `/templates/${encodeURIComponent(id)}/source`, undefined, query);
+  }
+
+  /** Push local edits back as a fork of the template */
+  forkTemplate(id: string, body: EditorForkRequest, query: { version?: string } = {}): Promise<EditorTemplate> {
+    return this.request("POST", `/templates/${encodeURIComponent(id)}/fork`, body, query);
+  }
+
+  /** Render a template with parameters resolved */
+  previewTemplate(id: string, body: EditorPreviewRequest): Promise<EditorPreviewResult> {
+    return this.request("POST", `/templates/${encodeURIComponent(id)}/preview`, body);
+  }
+
+  /** Compile the current buffer through the provisioning service */
+  compile(body: EditorCompileRequest): Promise<EditorCompileResult> {
+    return this.request("POST", "/compile", body);
+  }
+
+  /** Flash a compiled artifact to a device */
+  flash(body: EditorFlashRequest): Promise<EditorFlashResult> {
+    return this.request("POST", "/flash", body);
+  }
+
+  /** List serial ports available for flashing */
+  listPorts(): Promise<EditorPortList> {
+    return this.request("GET", "/ports");
+  }
+
+  private async request<T>(method: string, path: string, body?: unknown, query?: Record<string, string | undefined>): Promise<T> {
+    const url = new URL(this.baseUrl + "/api/editor/v1" + path);
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== "") {
+        url.searchParams.set(key, value);
+      }
+    }
+    const headers: Record<string, string> = {};
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    if (this.token) {
+      headers["Authorization"] = `Bearer ${this.token}`;
+    }
+    const response = await this.fetchImpl(url.toString(), {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const payload = await response.json().catch(() => undefined);
+    if (!response.ok) {
+      throw new EditorApiError(response.status, payload?.error ?? response.statusText);
+    }
+    return payload as T;
+  }
+}
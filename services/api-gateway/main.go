@@ -9,9 +9,13 @@ import (
 	"syscall"
 	"time"
 
+	"cloud.google.com/go/datastore"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/declarative"
 	"github.com/athena/platform-lib/pkg/gateway"
+	"github.com/athena/platform-lib/pkg/loadtest"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,7 +28,20 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
+
+	// Set Datastore emulator host if configured
+	if cfg.DatastoreHost != "" {
+		os.Setenv("DATASTORE_EMULATOR_HOST", cfg.DatastoreHost)
+	}
+
+	// Initialize Datastore client
+	datastoreClient, err := datastore.NewClient(context.Background(), cfg.DatastoreProject)
+	if err != nil {
+		logger.Error("Failed to create Datastore client", "error", err)
+		os.Exit(1)
+	}
+	defer datastoreClient.Close()
 
 	// Initialize gateway
 	gw, err := gateway.NewGateway(cfg, logger)
@@ -32,6 +49,10 @@ func main() {
 		logger.Error("Failed to initialize API gateway", "error", err)
 		os.Exit(1)
 	}
+	gw.SetServiceAccountStore(gateway.NewDatastoreServiceAccountStore(datastoreClient))
+	gw.SetUsageStore(metering.NewDatastoreUsageStore(datastoreClient))
+	gw.SetApplyStateStore(declarative.NewDatastoreStateStore(datastoreClient))
+	gw.SetBenchmarkStore(loadtest.NewDatastoreResultStore(datastoreClient))
 
 	// Setup HTTP server
 	router := gin.New()
@@ -47,7 +68,7 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("API Gateway starting on %s", cfg.HTTPPort)
+		logger.Info("API Gateway starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("Failed to start server", "error", err)
 			os.Exit(1)
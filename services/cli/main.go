@@ -17,12 +17,12 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Create and execute CLI
 	rootCmd := cli.NewRootCommand(cfg, logger)
 	if err := rootCmd.Execute(); err != nil {
-		logger.Error(err)
+		logger.Error("Command failed", "error", err)
 		os.Exit(1)
 	}
 }
@@ -10,9 +10,17 @@ import (
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/cache"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
 	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
+	"github.com/athena/platform-lib/pkg/metrics"
+	"github.com/athena/platform-lib/pkg/telemetry"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/athena/platform-lib/pkg/topics"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,29 +32,89 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize Datastore client
 	ctx := context.Background()
 	datastoreClient, err := datastore.NewClient(ctx, cfg.DatastoreProject)
 	if err != nil {
-		logger.Fatalf("Failed to create Datastore client: %v", err)
+		logger.Fatal("Failed to create Datastore client", "error", err)
 	}
 	defer datastoreClient.Close()
 
-	// Initialize repository
-	repository := device.NewDatastoreRepository(datastoreClient)
+	// Datastore calls are timed and counted per repository method
+	serviceMetrics := metrics.NewMetrics(cfg.ServiceName, *logger)
+
+	// Initialize repository. Device records are read through a cache
+	// shared with the services on the OTA and telemetry hot paths.
+	repository := cache.NewDeviceRepository(
+		metrics.NewDeviceRepository(device.NewDatastoreRepository(datastoreClient), serviceMetrics),
+		cache.NewStoreFromConfig(cfg, logger), cfg.Cache.DeviceTTL)
 
 	// Initialize service
 	service, err := device.NewService(cfg, logger, repository)
 	if err != nil {
-		logger.Fatalf("Failed to initialize device service: %v", err)
+		logger.Fatal("Failed to initialize device service", "error", err)
+	}
+
+	// Devices registered without an OTA channel or enrollment policy take
+	// those of their template, and offline notifications go to the
+	// template's owner
+	service.SetTemplateDirectory(metrics.NewTemplateRepository(template.NewDatastoreRepository(datastoreClient), serviceMetrics))
+	service.SetTwinStore(device.NewDatastoreTwinStore(datastoreClient))
+	service.SetGroupStore(device.NewDatastoreGroupStore(datastoreClient))
+	service.SetCommandStore(device.NewDatastoreCommandStore(datastoreClient))
+	service.SetClaimTokenStore(device.NewDatastoreClaimTokenStore(datastoreClient))
+	service.SetEventStore(device.NewDatastoreEventStore(datastoreClient))
+	service.SetAvailabilityStore(device.NewDatastoreAvailabilityStore(datastoreClient))
+	service.SetDebugHistoryStore(device.NewDatastoreDebugHistoryStore(datastoreClient))
+
+	// Commands are pushed over MQTT when a broker is configured; devices
+	// without a connection poll for them
+	var mqttClient *telemetry.MQTTClient
+	if cfg.MQTT.Enabled {
+		namespace, err := topics.New(cfg.MQTT.TopicTemplate, cfg.MQTT.Tenant)
+		if err != nil {
+			logger.Fatal("Invalid MQTT topic configuration", "error", err)
+		}
+		mqttClient, err = telemetry.NewMQTTClient(&telemetry.MQTTConfig{
+			BrokerURL:      cfg.MQTT.BrokerURL,
+			ClientID:       "device-service",
+			Username:       cfg.MQTT.Username,
+			Password:       cfg.MQTT.Password,
+			QoS:            1,
+			CleanSession:   true,
+			ConnectTimeout: 10 * time.Second,
+			KeepAlive:      60 * time.Second,
+			Topics:         namespace,
+		}, nil, logger)
+		if err != nil {
+			logger.Fatal("Failed to create MQTT client", "error", err)
+		}
+		if err := mqttClient.Connect(); err != nil {
+			logger.Fatal("Failed to connect MQTT client", "error", err)
+		}
+		service.SetCommandDispatcher(device.NewMQTTCommandDispatcher(mqttClient, namespace))
 	}
 
+	// Registered devices are metered as device-months per project
+	usage := metering.NewRecorderFromConfig(cfg, logger, repository)
+	usage.MeterDevices(repository)
+	usage.Start()
+
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
 
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
+	// Operational tasks, run by administrators through the API gateway
+	tasks := admin.Setup(router, cfg, logger, device.AdminTasks(repository)...)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+
 	// Register routes
 	device.RegisterRoutes(router, service)
 
@@ -57,9 +125,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("Device service starting on %s", cfg.HTTPPort)
+		logger.Info("Device service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
@@ -70,18 +138,25 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Cancel running admin tasks
+	tasks.Stop()
+
 	// Graceful shutdown
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	// Shutdown the service first
 	if err := service.Shutdown(); err != nil {
-		logger.Errorf("Failed to shutdown service gracefully: %v", err)
+		logger.Error("Failed to shutdown service gracefully", "error", err)
+	}
+	usage.Stop()
+	if mqttClient != nil {
+		mqttClient.Disconnect()
 	}
 
 	// Then shutdown the HTTP server
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal("Server forced to shutdown", "error", err)
 	}
 
 	logger.Info("Server exited")
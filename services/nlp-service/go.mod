@@ -5,6 +5,7 @@ go 1.24.0
 toolchain go1.24.2
 
 require (
+	cloud.google.com/go/datastore v1.15.0
 	github.com/athena/platform-lib v0.0.0
 	github.com/gin-gonic/gin v1.11.0
 )
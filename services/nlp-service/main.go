@@ -9,9 +9,12 @@ import (
 	"syscall"
 	"time"
 
+	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
 	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/athena/platform-lib/pkg/nlp"
+	"github.com/athena/platform-lib/pkg/template"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,7 +26,7 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize service
 	nlpConfig := &nlp.ServiceConfig{
@@ -34,17 +37,28 @@ func main() {
 		LLMMaxTokens:   1000,
 		LLMTimeout:     30,
 	}
-	_, err = nlp.NewService(nlpConfig)
+	service, err := nlp.NewService(nlpConfig)
 	if err != nil {
-		logger.Fatalf("Failed to initialize NLP service: %v", err)
+		logger.Fatal("Failed to initialize NLP service", "error", err)
 	}
 
+	// Plans select from the template catalogue and its locale packs
+	datastoreClient, err := datastore.NewClient(context.Background(), cfg.DatastoreProject)
+	if err != nil {
+		logger.Fatal("Failed to create Datastore client", "error", err)
+	}
+	defer datastoreClient.Close()
+	service.SetTemplateDirectory(template.NewDatastoreRepository(datastoreClient))
+
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
 
-	// Register routes - for now just basic health check
-	// TODO: Implement NLP routes
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
+	// Register routes
+	nlp.RegisterRoutes(router, service)
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy", "service": "nlp-service"})
 	})
@@ -56,9 +70,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("NLP service starting on %s", cfg.HTTPPort)
+		logger.Info("NLP service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
@@ -74,7 +88,7 @@ func main() {
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal("Server forced to shutdown", "error", err)
 	}
 
 	logger.Info("Server exited")
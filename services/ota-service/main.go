@@ -9,9 +9,19 @@ import (
 	"syscall"
 	"time"
 
+	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/cache"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/debugcapture"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/environments"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
+	"github.com/athena/platform-lib/pkg/metrics"
 	"github.com/athena/platform-lib/pkg/ota"
+	"github.com/athena/platform-lib/pkg/template"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,25 +34,117 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
-	// Initialize service - simplified for now
-	// TODO: Implement proper service with all dependencies
-	_, err = ota.NewService(cfg, logger, nil, nil, nil, nil)
+	// Initialize Datastore client
+	ctx := context.Background()
+	datastoreClient, err := datastore.NewClient(ctx, cfg.DatastoreProject)
+	if err != nil {
+		logger.Error("Failed to create Datastore client", "error", err)
+		os.Exit(1)
+	}
+	defer datastoreClient.Close()
+
+	// Datastore calls are timed and counted per repository method
+	serviceMetrics := metrics.NewMetrics(cfg.ServiceName, *logger)
+
+	// Initialize repositories. Device records, used to target deployments
+	// and check device tokens, are read through the shared device cache.
+	repository := metrics.NewOTARepository(ota.NewDatastoreRepository(datastoreClient), serviceMetrics)
+	devices := cache.NewDeviceRepository(
+		metrics.NewDeviceRepository(device.NewDatastoreRepository(datastoreClient), serviceMetrics),
+		cache.NewStoreFromConfig(cfg, logger), cfg.Cache.DeviceTTL)
+
+	// Firmware is signed with the configured key pair and stored under
+	// ota.storage_path. Keys rotated through the API, and the retired keys
+	// older releases verify against, are kept in Datastore.
+	signer, err := ota.NewSignerFromConfig(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to load firmware signing key", "error", err)
+		os.Exit(1)
+	}
+	signingKeys := ota.NewDatastoreSigningKeyStore(datastoreClient)
+	if err := signer.Restore(ctx, signingKeys); err != nil {
+		logger.Error("Failed to restore firmware signing keys", "error", err)
+		os.Exit(1)
+	}
+	storage, err := ota.NewLocalStorageBackend(cfg.OTA.StoragePath)
+	if err != nil {
+		logger.Error("Failed to initialize firmware storage", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize service
+	service, err := ota.NewService(cfg, logger, repository, devices, signer, storage)
 	if err != nil {
 		logger.Error("Failed to initialize OTA service", "error", err)
 		os.Exit(1)
 	}
 
+	service.SetSigningKeyStore(signingKeys)
+
+	// Deployments can target the device groups managed by the device service
+	service.SetDeviceGroups(device.NewDatastoreGroupStore(datastoreClient))
+
+	// Completed updates are added to the device timelines kept by the
+	// device service
+	service.SetDeviceEvents(device.NewEventRecorder(device.NewDatastoreEventStore(datastoreClient), cfg.ServiceName, logger))
+
+	// Releases are promoted through environments with their template versions
+	templates := metrics.NewTemplateRepository(template.NewDatastoreRepository(datastoreClient), serviceMetrics)
+	service.SetTemplatePromoter(template.NewPromoter(templates, environments.NewPipelineFromConfig(cfg)))
+
+	// Firmware downloads are metered per project
+	usage := metering.NewRecorderFromConfig(cfg, logger.Component("metering"), devices)
+	service.SetUsageRecorder(usage)
+	usage.Start()
+
+	// Start and pause scheduled deployments on their maintenance windows
+	scheduler := ota.NewDeploymentScheduler(service, logger.Component("scheduler"))
+	scheduler.Start(cfg.DeploymentSchedule.CheckInterval)
+
+	// Expand canary and staged deployments as their devices update
+	promoter := ota.NewDeploymentPromoter(service, logger.Component("promoter"))
+	promoter.Start(cfg.Promotion.CheckInterval)
+
+	// Time out and requeue updates of devices that went quiet mid-update
+	reaper := ota.NewStaleUpdateReaper(service, logger.Component("reaper"))
+	reaper.Start(cfg.StaleUpdates.CheckInterval)
+
+	// Pause or roll back deployments whose devices' telemetry regresses
+	healthMonitor := ota.NewDeploymentHealthMonitor(service, logger.Component("health"))
+	healthMonitor.Start(cfg.DeploymentHealth.CheckInterval)
+
+	// Delete releases, and their binaries, that retention rules let go
+	sweeper := ota.NewReleaseSweeper(service, logger.Component("retention"))
+	sweeper.Start(cfg.Retention.SweepInterval)
+
+	// Deploy releases to the fleets subscribed by policies, which are kept
+	// in Datastore with their audit trail
+	policies := ota.NewPolicyEngine(service, ota.NewDatastorePolicyStore(datastoreClient), logger.Component("policies"))
+	if cfg.OTA.PolicyEvaluationInterval > 0 {
+		policies.Start(cfg.OTA.PolicyEvaluationInterval)
+	}
+
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
 
-	// Register routes - basic health check for now
-	// TODO: Implement OTA routes
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "healthy", "service": "ota-service"})
-	})
+	// Per-device request and response capture, started from the device service
+	debugcapture.Setup(router, cfg, logger.Component("debugcapture"))
+
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
+	// Operational tasks, run by administrators through the API gateway
+	tasks := admin.Setup(router, cfg, logger, service.AdminTasks()...)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+
+	// Register routes
+	ota.RegisterRoutes(router, service)
+	ota.RegisterPolicyRoutes(router, policies)
 
 	server := &http.Server{
 		Addr:    cfg.HTTPPort,
@@ -51,7 +153,7 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("OTA service starting on %s", cfg.HTTPPort)
+		logger.Info("OTA service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error("Failed to start server", "error", err)
 			os.Exit(1)
@@ -65,11 +167,29 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Cancel running admin tasks
+	tasks.Stop()
+
+	// Stop the deployment scheduler, promoter, stale update reaper, health
+	// monitor, release sweeper and policy engine
+	scheduler.Stop()
+	promoter.Stop()
+	reaper.Stop()
+	healthMonitor.Stop()
+	sweeper.Stop()
+	policies.Stop()
+
+	// Report buffered usage
+	usage.Stop()
+
+	// Give up on webhook deliveries still being retried
+	service.StopWebhooks()
+
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
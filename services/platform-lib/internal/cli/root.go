@@ -239,7 +239,7 @@ func newProvisionCompileCommand(cfg *config.Config, logger *logger.Logger) *cobr
 				"parameters": map[string]string{"last_artifact_id": resp.ArtifactID},
 			}
 			if err := pm.UpdateCurrentProfile(updates); err != nil {
-				logger.Warnf("Failed to save artifact ID to profile: %v", err)
+				logger.Warn("Failed to save artifact ID to profile", "error", err)
 			}
 
 			return nil
@@ -27,6 +27,8 @@ type Device struct {
 	FirmwareHash    string                 `json:"firmware_hash"`
 	LastSeen        time.Time              `json:"last_seen"`
 	OTAChannel      string                 `json:"ota_channel"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	Reported        map[string]interface{} `json:"reported,omitempty"`
 	CreatedAt       time.Time              `json:"created_at"`
 	UpdatedAt       time.Time              `json:"updated_at"`
 }
@@ -43,6 +45,8 @@ type DeviceEntity struct {
 	FirmwareHash    string    `datastore:"firmware_hash"`
 	LastSeen        time.Time `datastore:"last_seen"`
 	OTAChannel      string    `datastore:"ota_channel"`
+	LabelsJSON      string    `datastore:"labels_json,noindex"`
+	ReportedJSON    string    `datastore:"reported_json,noindex"`
 	CreatedAt       time.Time `datastore:"created_at"`
 	UpdatedAt       time.Time `datastore:"updated_at"`
 }
@@ -69,6 +73,7 @@ type DeviceRegistrationRequest struct {
 	SecretsRef      string                 `json:"secrets_ref,omitempty"`
 	FirmwareHash    string                 `json:"firmware_hash" binding:"required"`
 	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
 }
 
 // DeviceStatusUpdate represents a device status update
@@ -108,6 +113,20 @@ func (d *Device) ToEntity() (*DeviceEntity, error) {
 		return nil, err
 	}
 
+	var labelsJSON []byte
+	if len(d.Labels) > 0 {
+		if labelsJSON, err = json.Marshal(d.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	var reportedJSON []byte
+	if len(d.Reported) > 0 {
+		if reportedJSON, err = json.Marshal(d.Reported); err != nil {
+			return nil, err
+		}
+	}
+
 	return &DeviceEntity{
 		DeviceID:        d.DeviceID,
 		BoardType:       d.BoardType,
@@ -119,6 +138,8 @@ func (d *Device) ToEntity() (*DeviceEntity, error) {
 		FirmwareHash:    d.FirmwareHash,
 		LastSeen:        d.LastSeen,
 		OTAChannel:      d.OTAChannel,
+		LabelsJSON:      string(labelsJSON),
+		ReportedJSON:    string(reportedJSON),
 		CreatedAt:       d.CreatedAt,
 		UpdatedAt:       d.UpdatedAt,
 	}, nil
@@ -133,6 +154,20 @@ func (de *DeviceEntity) FromEntity() (*Device, error) {
 		}
 	}
 
+	var labels map[string]string
+	if de.LabelsJSON != "" {
+		if err := json.Unmarshal([]byte(de.LabelsJSON), &labels); err != nil {
+			return nil, err
+		}
+	}
+
+	var reported map[string]interface{}
+	if de.ReportedJSON != "" {
+		if err := json.Unmarshal([]byte(de.ReportedJSON), &reported); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Device{
 		DeviceID:        de.DeviceID,
 		BoardType:       de.BoardType,
@@ -144,6 +179,8 @@ func (de *DeviceEntity) FromEntity() (*Device, error) {
 		FirmwareHash:    de.FirmwareHash,
 		LastSeen:        de.LastSeen,
 		OTAChannel:      de.OTAChannel,
+		Labels:          labels,
+		Reported:        reported,
 		CreatedAt:       de.CreatedAt,
 		UpdatedAt:       de.UpdatedAt,
 	}, nil
@@ -154,6 +191,16 @@ func (d *Device) IsOnline(timeout time.Duration) bool {
 	return time.Since(d.LastSeen) <= timeout && d.Status == DeviceStatusOnline
 }
 
+// MatchesLabels reports whether the device carries every label in selector
+func (d *Device) MatchesLabels(selector map[string]string) bool {
+	for key, value := range selector {
+		if current, ok := d.Labels[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
 // ToRegistrationRequest converts a Device to a DeviceRegistrationRequest
 func (d *Device) ToRegistrationRequest() *DeviceRegistrationRequest {
 	return &DeviceRegistrationRequest{
@@ -165,6 +212,7 @@ func (d *Device) ToRegistrationRequest() *DeviceRegistrationRequest {
 		SecretsRef:      d.SecretsRef,
 		FirmwareHash:    d.FirmwareHash,
 		OTAChannel:      d.OTAChannel,
+		Labels:          d.Labels,
 	}
 }
 
@@ -188,6 +236,7 @@ func FromRegistrationRequest(req *DeviceRegistrationRequest) *Device {
 		FirmwareHash:    req.FirmwareHash,
 		LastSeen:        now,
 		OTAChannel:      otaChannel,
+		Labels:          req.Labels,
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
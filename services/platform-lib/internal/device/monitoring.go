@@ -74,8 +74,7 @@ func (m *MonitoringService) Start(ctx context.Context) error {
 	}
 
 	m.isRunning = true
-	m.logger.Infof("Starting device monitoring service with offline timeout: %v, check interval: %v",
-		m.offlineTimeout, m.checkInterval)
+	m.logger.Info("Starting device monitoring service", "offline_timeout", m.offlineTimeout, "check_interval", m.checkInterval)
 
 	// Start the monitoring goroutine
 	m.wg.Add(1)
@@ -141,7 +140,7 @@ func (m *MonitoringService) checkDeviceStatus(ctx context.Context) {
 	cutoffTime := time.Now().Add(-m.offlineTimeout)
 	staleDevices, err := m.repository.GetDevicesLastSeenBefore(ctx, cutoffTime)
 	if err != nil {
-		m.logger.Errorf("Failed to get stale devices: %v", err)
+		m.logger.Error("Failed to get stale devices", "error", err)
 		return
 	}
 
@@ -152,16 +151,16 @@ func (m *MonitoringService) checkDeviceStatus(ctx context.Context) {
 		if device.Status == DeviceStatusOnline {
 			err := m.repository.UpdateDeviceStatus(ctx, device.DeviceID, DeviceStatusOffline, device.LastSeen)
 			if err != nil {
-				m.logger.Errorf("Failed to mark device %s as offline: %v", device.DeviceID, err)
+				m.logger.Error("Failed to mark device as offline", "device_id", device.DeviceID, "error", err)
 				continue
 			}
 			offlineCount++
-			m.logger.Infof("Device %s marked as offline (last seen: %v)", device.DeviceID, device.LastSeen)
+			m.logger.Info("Device marked as offline", "device_id", device.DeviceID, "last_seen", device.LastSeen)
 		}
 	}
 
 	if offlineCount > 0 {
-		m.logger.Infof("Marked %d devices as offline", offlineCount)
+		m.logger.Info("Marked devices as offline", "devices", offlineCount)
 	}
 
 	// Log health summary
@@ -172,12 +171,12 @@ func (m *MonitoringService) checkDeviceStatus(ctx context.Context) {
 func (m *MonitoringService) logHealthSummary(ctx context.Context) {
 	health, err := m.repository.GetDeviceHealthStatus(ctx)
 	if err != nil {
-		m.logger.Errorf("Failed to get device health status: %v", err)
+		m.logger.Error("Failed to get device health status", "error", err)
 		return
 	}
 
-	m.logger.Debugf("Device health summary - Total: %d, Online: %d, Offline: %d, Error: %d",
-		health.TotalDevices, health.OnlineDevices, health.OfflineDevices, health.ErrorDevices)
+	m.logger.Debug("Device health summary", "total", health.TotalDevices, "online", health.OnlineDevices,
+		"offline", health.OfflineDevices, "error", health.ErrorDevices)
 }
 
 // ProcessHeartbeat processes a device heartbeat and updates status
@@ -207,7 +206,7 @@ func (m *MonitoringService) ProcessHeartbeat(ctx context.Context, heartbeat *Dev
 		return fmt.Errorf("failed to update device status from heartbeat: %w", err)
 	}
 
-	m.logger.Debugf("Processed heartbeat from device %s with status %s", heartbeat.DeviceID, heartbeat.Status)
+	m.logger.Debug("Processed heartbeat", "device_id", heartbeat.DeviceID, "status", heartbeat.Status)
 	return nil
 }
 
@@ -261,7 +260,7 @@ func (m *MonitoringService) SetOfflineTimeout(timeout time.Duration) {
 	defer m.mu.Unlock()
 
 	m.offlineTimeout = timeout
-	m.logger.Infof("Updated offline timeout to %v", timeout)
+	m.logger.Info("Updated offline timeout", "offline_timeout", timeout)
 }
 
 // SetCheckInterval updates the check interval configuration
@@ -270,7 +269,7 @@ func (m *MonitoringService) SetCheckInterval(interval time.Duration) {
 	defer m.mu.Unlock()
 
 	m.checkInterval = interval
-	m.logger.Infof("Updated check interval to %v", interval)
+	m.logger.Info("Updated check interval", "check_interval", interval)
 }
 
 // GetConfiguration returns the current monitoring configuration
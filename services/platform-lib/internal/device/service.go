@@ -86,7 +86,7 @@ func (s *Service) healthCheck(c *gin.Context) {
 func (s *Service) registerDevice(c *gin.Context) {
 	var req DeviceRegistrationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		s.logger.Errorf("Invalid device registration request: %v", err)
+		s.logger.Error("Invalid device registration request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -100,7 +100,7 @@ func (s *Service) registerDevice(c *gin.Context) {
 	// Register device
 	ctx := context.Background()
 	if err := s.repository.RegisterDevice(ctx, device); err != nil {
-		s.logger.Errorf("Failed to register device %s: %v", req.DeviceID, err)
+		s.logger.Error("Failed to register device", "device_id", req.DeviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to register device",
 			"details": err.Error(),
@@ -108,7 +108,7 @@ func (s *Service) registerDevice(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s registered successfully", device.DeviceID)
+	s.logger.Info("Device registered successfully", "device_id", device.DeviceID)
 	c.JSON(http.StatusCreated, device)
 }
 
@@ -149,7 +149,7 @@ func (s *Service) listDevices(c *gin.Context) {
 	// Get devices
 	devices, err := s.repository.ListDevices(ctx, filters)
 	if err != nil {
-		s.logger.Errorf("Failed to list devices: %v", err)
+		s.logger.Error("Failed to list devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to list devices",
 			"details": err.Error(),
@@ -160,7 +160,7 @@ func (s *Service) listDevices(c *gin.Context) {
 	// Get total count
 	total, err := s.repository.GetDeviceCount(ctx, filters)
 	if err != nil {
-		s.logger.Errorf("Failed to get device count: %v", err)
+		s.logger.Error("Failed to get device count", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device count",
 			"details": err.Error(),
@@ -196,7 +196,7 @@ func (s *Service) getDevice(c *gin.Context) {
 	ctx := context.Background()
 	device, err := s.repository.GetDevice(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "Device not found",
 			"details": err.Error(),
@@ -218,7 +218,7 @@ func (s *Service) updateDevice(c *gin.Context) {
 
 	var device Device
 	if err := c.ShouldBindJSON(&device); err != nil {
-		s.logger.Errorf("Invalid device update request: %v", err)
+		s.logger.Error("Invalid device update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -231,7 +231,7 @@ func (s *Service) updateDevice(c *gin.Context) {
 
 	ctx := context.Background()
 	if err := s.repository.UpdateDevice(ctx, &device); err != nil {
-		s.logger.Errorf("Failed to update device %s: %v", deviceID, err)
+		s.logger.Error("Failed to update device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update device",
 			"details": err.Error(),
@@ -239,7 +239,7 @@ func (s *Service) updateDevice(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s updated successfully", deviceID)
+	s.logger.Info("Device updated successfully", "device_id", deviceID)
 	c.JSON(http.StatusOK, device)
 }
 
@@ -254,7 +254,7 @@ func (s *Service) deleteDevice(c *gin.Context) {
 
 	ctx := context.Background()
 	if err := s.repository.DeleteDevice(ctx, deviceID); err != nil {
-		s.logger.Errorf("Failed to delete device %s: %v", deviceID, err)
+		s.logger.Error("Failed to delete device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to delete device",
 			"details": err.Error(),
@@ -262,7 +262,7 @@ func (s *Service) deleteDevice(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s deleted successfully", deviceID)
+	s.logger.Info("Device deleted successfully", "device_id", deviceID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Device deleted successfully",
 	})
@@ -279,7 +279,7 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 
 	var statusUpdate DeviceStatusUpdate
 	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
-		s.logger.Errorf("Invalid status update request: %v", err)
+		s.logger.Error("Invalid status update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -295,7 +295,7 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 
 	ctx := context.Background()
 	if err := s.repository.UpdateDeviceStatus(ctx, deviceID, statusUpdate.Status, lastSeen); err != nil {
-		s.logger.Errorf("Failed to update device status for %s: %v", deviceID, err)
+		s.logger.Error("Failed to update device status", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update device status",
 			"details": err.Error(),
@@ -303,7 +303,7 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s status updated to %s", deviceID, statusUpdate.Status)
+	s.logger.Info("Device status updated", "device_id", deviceID, "status", statusUpdate.Status)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Device status updated successfully",
 	})
@@ -320,7 +320,7 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 
 	var heartbeat DeviceHeartbeat
 	if err := c.ShouldBindJSON(&heartbeat); err != nil {
-		s.logger.Errorf("Invalid heartbeat request: %v", err)
+		s.logger.Error("Invalid heartbeat request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -334,7 +334,7 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 	// Use monitoring service to process heartbeat
 	ctx := context.Background()
 	if err := s.monitoring.ProcessHeartbeat(ctx, &heartbeat); err != nil {
-		s.logger.Errorf("Failed to process heartbeat for device %s: %v", deviceID, err)
+		s.logger.Error("Failed to process heartbeat", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to process heartbeat",
 			"details": err.Error(),
@@ -342,7 +342,7 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 		return
 	}
 
-	s.logger.Debugf("Heartbeat received from device %s", deviceID)
+	s.logger.Debug("Heartbeat received", "device_id", deviceID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Heartbeat processed successfully",
 	})
@@ -352,7 +352,7 @@ func (s *Service) getDeviceHealth(c *gin.Context) {
 	ctx := context.Background()
 	health, err := s.repository.GetDeviceHealthStatus(ctx)
 	if err != nil {
-		s.logger.Errorf("Failed to get device health status: %v", err)
+		s.logger.Error("Failed to get device health status", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device health status",
 			"details": err.Error(),
@@ -375,7 +375,7 @@ func (s *Service) getDevicesByStatus(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByStatus(ctx, status)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by status %s: %v", status, err)
+		s.logger.Error("Failed to get devices by status", "status", status, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by status",
 			"details": err.Error(),
@@ -411,7 +411,7 @@ func (s *Service) getOfflineDevices(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetOfflineDevices(ctx, timeout)
 	if err != nil {
-		s.logger.Errorf("Failed to get offline devices: %v", err)
+		s.logger.Error("Failed to get offline devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get offline devices",
 			"details": err.Error(),
@@ -463,7 +463,7 @@ func (s *Service) searchDevices(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.SearchDevices(ctx, query, filters)
 	if err != nil {
-		s.logger.Errorf("Failed to search devices: %v", err)
+		s.logger.Error("Failed to search devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to search devices",
 			"details": err.Error(),
@@ -496,7 +496,7 @@ func (s *Service) getDevicesByTemplate(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByTemplate(ctx, templateID)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by template %s: %v", templateID, err)
+		s.logger.Error("Failed to get devices by template", "template_id", templateID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by template",
 			"details": err.Error(),
@@ -529,7 +529,7 @@ func (s *Service) getDevicesByOTAChannel(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByOTAChannel(ctx, channel)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by OTA channel %s: %v", channel, err)
+		s.logger.Error("Failed to get devices by OTA channel", "channel", channel, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by OTA channel",
 			"details": err.Error(),
@@ -562,7 +562,7 @@ func (s *Service) getDeviceUptime(c *gin.Context) {
 	ctx := context.Background()
 	uptime, err := s.monitoring.GetDeviceUptime(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device uptime for %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device uptime", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device uptime",
 			"details": err.Error(),
@@ -589,7 +589,7 @@ func (s *Service) getDeviceLastSeen(c *gin.Context) {
 	ctx := context.Background()
 	lastSeenDuration, err := s.monitoring.GetDeviceLastSeenDuration(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device last seen duration for %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device last seen duration", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device last seen duration",
 			"details": err.Error(),
@@ -599,7 +599,7 @@ func (s *Service) getDeviceLastSeen(c *gin.Context) {
 
 	isOnline, err := s.monitoring.CheckDeviceOnlineStatus(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to check device online status for %s: %v", deviceID, err)
+		s.logger.Error("Failed to check device online status", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to check device online status",
 			"details": err.Error(),
@@ -632,7 +632,7 @@ func (s *Service) updateMonitoringConfig(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&configUpdate); err != nil {
-		s.logger.Errorf("Invalid monitoring config update request: %v", err)
+		s.logger.Error("Invalid monitoring config update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -668,7 +668,7 @@ func (s *Service) updateMonitoringConfig(c *gin.Context) {
 
 	// Return updated configuration
 	config := s.monitoring.GetConfiguration()
-	s.logger.Infof("Monitoring configuration updated")
+	s.logger.Info("Monitoring configuration updated")
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Monitoring configuration updated successfully",
@@ -683,7 +683,7 @@ func (s *Service) Shutdown() error {
 	s.logger.Info("Shutting down device service...")
 
 	if err := s.monitoring.Stop(); err != nil {
-		s.logger.Errorf("Failed to stop monitoring service: %v", err)
+		s.logger.Error("Failed to stop monitoring service", "error", err)
 		return err
 	}
 
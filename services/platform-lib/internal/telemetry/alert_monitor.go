@@ -70,7 +70,7 @@ func (am *AlertMonitor) LoadThresholds(deviceIDs []string) error {
 		cancel()
 
 		if err != nil {
-			am.logger.Error(fmt.Sprintf("Failed to load thresholds for device %s: %v", deviceID, err))
+			am.logger.Error("Failed to load thresholds", "device_id", deviceID, "error", err)
 			continue
 		}
 
@@ -105,7 +105,7 @@ func (am *AlertMonitor) AddThreshold(deviceID string, thresholdID string, thresh
 	}
 
 	am.thresholds[deviceID] = append(am.thresholds[deviceID], config)
-	am.logger.Info(fmt.Sprintf("Added threshold %s for device %s", thresholdID, deviceID))
+	am.logger.Info("Added threshold", "threshold_id", thresholdID, "device_id", deviceID)
 }
 
 // RemoveThreshold removes a threshold from monitoring
@@ -117,7 +117,7 @@ func (am *AlertMonitor) RemoveThreshold(deviceID string, thresholdID string) {
 	for i, config := range thresholds {
 		if config.ThresholdID == thresholdID {
 			am.thresholds[deviceID] = append(thresholds[:i], thresholds[i+1:]...)
-			am.logger.Info(fmt.Sprintf("Removed threshold %s for device %s", thresholdID, deviceID))
+			am.logger.Info("Removed threshold", "threshold_id", thresholdID, "device_id", deviceID)
 			break
 		}
 	}
@@ -170,7 +170,7 @@ func (am *AlertMonitor) checkDeviceThresholds(deviceID string) {
 		}
 
 		if err := am.checkThreshold(config); err != nil {
-			am.logger.Error(fmt.Sprintf("Error checking threshold %s: %v", config.ThresholdID, err))
+			am.logger.Error("Error checking threshold", "threshold_id", config.ThresholdID, "error", err)
 		}
 	}
 }
@@ -232,7 +232,7 @@ func (am *AlertMonitor) checkThreshold(config *ThresholdConfig) error {
 		config.LastAlert = time.Now()
 		am.mu.Unlock()
 
-		am.logger.Warn(fmt.Sprintf("Alert triggered for device %s: %s", config.DeviceID, alert.Message))
+		am.logger.Warn("Alert triggered", "device_id", config.DeviceID, "message", alert.Message)
 	}
 
 	// Update last check time
@@ -3,7 +3,6 @@ package telemetry
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -65,7 +64,7 @@ func (an *AlertNotifier) SendAlert(alert *Alert) {
 		case ChannelLog:
 			an.sendLog(alert)
 		default:
-			an.logger.Warn(fmt.Sprintf("Unknown notification channel: %s", channel.Channel))
+			an.logger.Warn("Unknown notification channel", "channel", channel.Channel)
 		}
 	}
 }
@@ -91,13 +90,13 @@ func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to marshal webhook payload: %v", err))
+		an.logger.Error("Failed to marshal webhook payload", "error", err)
 		return
 	}
 
 	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to create webhook request: %v", err))
+		an.logger.Error("Failed to create webhook request", "error", err)
 		return
 	}
 
@@ -114,15 +113,15 @@ func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface
 
 	resp, err := an.client.Do(req)
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to send webhook: %v", err))
+		an.logger.Error("Failed to send webhook", "error", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		an.logger.Info(fmt.Sprintf("Webhook notification sent for alert %s", alert.AlertID))
+		an.logger.Info("Webhook notification sent", "alert_id", alert.AlertID)
 	} else {
-		an.logger.Error(fmt.Sprintf("Webhook returned status %d for alert %s", resp.StatusCode, alert.AlertID))
+		an.logger.Error("Webhook returned error status", "status", resp.StatusCode, "alert_id", alert.AlertID)
 	}
 }
 
@@ -137,23 +136,22 @@ func (an *AlertNotifier) sendEmail(alert *Alert, settings map[string]interface{}
 		return
 	}
 
-	an.logger.Info(fmt.Sprintf("Email notification would be sent to %s for alert %s", to, alert.AlertID))
-	an.logger.Info(fmt.Sprintf("Subject: [%s] Alert for device %s", alert.Severity, alert.DeviceID))
-	an.logger.Info(fmt.Sprintf("Body: %s", alert.Message))
+	an.logger.Info("Email notification would be sent", "to", to, "alert_id", alert.AlertID,
+		"severity", alert.Severity, "device_id", alert.DeviceID, "message", alert.Message)
 }
 
 // sendLog logs the alert
 func (an *AlertNotifier) sendLog(alert *Alert) {
-	logMessage := fmt.Sprintf("[ALERT] Device: %s, Metric: %s, Severity: %s, Message: %s",
-		alert.DeviceID, alert.MetricName, alert.Severity, alert.Message)
+	fields := []interface{}{"alert_id", alert.AlertID, "device_id", alert.DeviceID, "metric", alert.MetricName,
+		"severity", alert.Severity, "message", alert.Message}
 
 	switch alert.Severity {
 	case "critical":
-		an.logger.Error(logMessage)
+		an.logger.Error("Alert", fields...)
 	case "warning":
-		an.logger.Warn(logMessage)
+		an.logger.Warn("Alert", fields...)
 	default:
-		an.logger.Info(logMessage)
+		an.logger.Info("Alert", fields...)
 	}
 }
 
@@ -163,7 +161,7 @@ func (an *AlertNotifier) AddChannel(config NotificationConfig) {
 	defer an.mu.Unlock()
 
 	an.channels = append(an.channels, config)
-	an.logger.Info(fmt.Sprintf("Added notification channel: %s", config.Channel))
+	an.logger.Info("Added notification channel", "channel", config.Channel)
 }
 
 // RemoveChannel removes a notification channel
@@ -174,7 +172,7 @@ func (an *AlertNotifier) RemoveChannel(channel NotificationChannel) {
 	for i, config := range an.channels {
 		if config.Channel == channel {
 			an.channels = append(an.channels[:i], an.channels[i+1:]...)
-			an.logger.Info(fmt.Sprintf("Removed notification channel: %s", channel))
+			an.logger.Info("Removed notification channel", "channel", channel)
 			break
 		}
 	}
@@ -188,7 +186,7 @@ func (an *AlertNotifier) UpdateChannel(channel NotificationChannel, settings map
 	for i, config := range an.channels {
 		if config.Channel == channel {
 			an.channels[i].Settings = settings
-			an.logger.Info(fmt.Sprintf("Updated notification channel: %s", channel))
+			an.logger.Info("Updated notification channel", "channel", channel)
 			break
 		}
 	}
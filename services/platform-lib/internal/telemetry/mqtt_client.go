@@ -98,7 +98,7 @@ func (c *MQTTClient) Subscribe(topic string, qos byte, handler MessageHandler) e
 		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
 	}
 
-	c.logger.Info(fmt.Sprintf("Subscribed to topic: %s", topic))
+	c.logger.Info("Subscribed to topic", "topic", topic)
 	return nil
 }
 
@@ -113,7 +113,7 @@ func (c *MQTTClient) Unsubscribe(topic string) error {
 		return fmt.Errorf("failed to unsubscribe from topic %s: %w", topic, token.Error())
 	}
 
-	c.logger.Info(fmt.Sprintf("Unsubscribed from topic: %s", topic))
+	c.logger.Info("Unsubscribed from topic", "topic", topic)
 	return nil
 }
 
@@ -124,18 +124,18 @@ func (c *MQTTClient) handleMessage(topic string, payload []byte) {
 	c.mu.RUnlock()
 
 	if !exists {
-		c.logger.Warn(fmt.Sprintf("No handler for topic: %s", topic))
+		c.logger.Warn("No handler for topic", "topic", topic)
 		return
 	}
 
 	if err := handler(topic, payload); err != nil {
-		c.logger.Error(fmt.Sprintf("Error handling message from topic %s: %v", topic, err))
+		c.logger.Error("Error handling message", "topic", topic, "error", err)
 	}
 }
 
 // onConnectionLost is called when connection to broker is lost
 func (c *MQTTClient) onConnectionLost(client mqtt.Client, err error) {
-	c.logger.Warn(fmt.Sprintf("MQTT connection lost: %v", err))
+	c.logger.Warn("MQTT connection lost", "error", err)
 }
 
 // onConnect is called when connection to broker is established
@@ -168,7 +168,7 @@ func (c *MQTTClient) SubscribeToDeviceTelemetry() error {
 			return fmt.Errorf("failed to store telemetry data: %w", err)
 		}
 
-		c.logger.Debug(fmt.Sprintf("Stored telemetry for device %s", data.DeviceID))
+		c.logger.Debug("Stored telemetry", "device_id", data.DeviceID)
 		return nil
 	}
 
@@ -191,7 +191,7 @@ func (c *MQTTClient) SubscribeToDeviceHeartbeats() error {
 			return fmt.Errorf("failed to unmarshal heartbeat: %w", err)
 		}
 
-		c.logger.Debug(fmt.Sprintf("Received heartbeat from device %s", heartbeat.DeviceID))
+		c.logger.Debug("Received heartbeat", "device_id", heartbeat.DeviceID)
 		return nil
 	}
 
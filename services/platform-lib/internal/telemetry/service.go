@@ -233,7 +233,7 @@ func (s *Service) ingestTelemetryHandler(c *gin.Context) {
 	}
 
 	if err := s.IngestTelemetry(deviceID, &data); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to ingest telemetry: %v", err))
+		s.logger.Error("Failed to ingest telemetry", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store telemetry data"})
 		return
 	}
@@ -263,7 +263,7 @@ func (s *Service) getMetricsHandler(c *gin.Context) {
 	timeRange := TimeRange{Start: start, End: end}
 	metrics, err := s.GetDeviceMetrics(deviceID, timeRange)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to get metrics: %v", err))
+		s.logger.Error("Failed to get metrics", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve metrics"})
 		return
 	}
@@ -300,7 +300,7 @@ func (s *Service) getMetricByNameHandler(c *gin.Context) {
 	timeRange := TimeRange{Start: start, End: end}
 	metrics, err := s.repository.GetDeviceMetricsByName(ctx, deviceID, metricName, timeRange)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to get metric: %v", err))
+		s.logger.Error("Failed to get metric", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve metric"})
 		return
 	}
@@ -327,7 +327,7 @@ func (s *Service) createThresholdHandler(c *gin.Context) {
 
 	thresholdID, err := s.repository.CreateThreshold(ctx, deviceID, &threshold)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to create threshold: %v", err))
+		s.logger.Error("Failed to create threshold", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create threshold"})
 		return
 	}
@@ -346,7 +346,7 @@ func (s *Service) listThresholdsHandler(c *gin.Context) {
 
 	thresholds, err := s.repository.ListThresholds(ctx, deviceID)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to list thresholds: %v", err))
+		s.logger.Error("Failed to list thresholds", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve thresholds"})
 		return
 	}
@@ -367,7 +367,7 @@ func (s *Service) listAlertsHandler(c *gin.Context) {
 
 	alerts, err := s.repository.ListAlerts(ctx, deviceID, status)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to list alerts: %v", err))
+		s.logger.Error("Failed to list alerts", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
 		return
 	}
@@ -386,7 +386,7 @@ func (s *Service) acknowledgeAlertHandler(c *gin.Context) {
 	defer cancel()
 
 	if err := s.repository.AcknowledgeAlert(ctx, alertID); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to acknowledge alert: %v", err))
+		s.logger.Error("Failed to acknowledge alert", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
 		return
 	}
@@ -401,7 +401,7 @@ func (s *Service) resolveAlertHandler(c *gin.Context) {
 	defer cancel()
 
 	if err := s.repository.ResolveAlert(ctx, alertID); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to resolve alert: %v", err))
+		s.logger.Error("Failed to resolve alert", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve alert"})
 		return
 	}
@@ -421,7 +421,7 @@ func (s *Service) aggregateMetricsHandler(c *gin.Context) {
 
 	results, err := s.repository.AggregateMetrics(ctx, &query)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to aggregate metrics: %v", err))
+		s.logger.Error("Failed to aggregate metrics", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate metrics"})
 		return
 	}
@@ -468,7 +468,7 @@ func (s *Service) exportDataHandler(c *gin.Context) {
 	}
 
 	if err := s.exporter.Export(ctx, &request, c.Writer); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to export data: %v", err))
+		s.logger.Error("Failed to export data", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
 		return
 	}
@@ -502,7 +502,7 @@ func (s *Service) exportAggregatedHandler(c *gin.Context) {
 	}
 
 	if err := s.exporter.ExportAggregated(ctx, &request.Query, request.Format, c.Writer); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to export aggregated data: %v", err))
+		s.logger.Error("Failed to export aggregated data", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export aggregated data"})
 		return
 	}
@@ -45,7 +45,7 @@ func (sm *StreamManager) HandleWebSocket(c *gin.Context) {
 
 	conn, err := sm.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to upgrade WebSocket connection: %v", err))
+		sm.logger.Error("Failed to upgrade WebSocket connection", "error", err)
 		return
 	}
 	defer conn.Close()
@@ -54,11 +54,11 @@ func (sm *StreamManager) HandleWebSocket(c *gin.Context) {
 	sm.registerClient(deviceID, conn)
 	defer sm.unregisterClient(deviceID, conn)
 
-	sm.logger.Info(fmt.Sprintf("WebSocket client connected for device %s", deviceID))
+	sm.logger.Info("WebSocket client connected", "device_id", deviceID)
 
 	// Send initial historical data
 	if err := sm.sendHistoricalData(conn, deviceID); err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to send historical data: %v", err))
+		sm.logger.Error("Failed to send historical data", "error", err)
 	}
 
 	// Keep connection alive and handle incoming messages
@@ -66,13 +66,13 @@ func (sm *StreamManager) HandleWebSocket(c *gin.Context) {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				sm.logger.Error(fmt.Sprintf("WebSocket error: %v", err))
+				sm.logger.Error("WebSocket error", "error", err)
 			}
 			break
 		}
 	}
 
-	sm.logger.Info(fmt.Sprintf("WebSocket client disconnected for device %s", deviceID))
+	sm.logger.Info("WebSocket client disconnected", "device_id", deviceID)
 }
 
 // registerClient registers a WebSocket connection for a device
@@ -111,7 +111,7 @@ func (sm *StreamManager) BroadcastTelemetry(deviceID string, data *TelemetryData
 
 	message, err := json.Marshal(data)
 	if err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to marshal telemetry data: %v", err))
+		sm.logger.Error("Failed to marshal telemetry data", "error", err)
 		return
 	}
 
@@ -120,7 +120,7 @@ func (sm *StreamManager) BroadcastTelemetry(deviceID string, data *TelemetryData
 
 	for conn := range clients {
 		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			sm.logger.Error(fmt.Sprintf("Failed to send message to WebSocket client: %v", err))
+			sm.logger.Error("Failed to send message to WebSocket client", "error", err)
 			conn.Close()
 			delete(clients, conn)
 		}
@@ -0,0 +1,406 @@
+// Package admin runs operational tasks such as index rebuilds, garbage
+// collection and data migrations inside platform services. Each service
+// registers its tasks with a Runner, which runs them in the background and
+// keeps a short history of jobs. The API gateway forwards the admin API to
+// services with a signed request; services reject requests it did not sign.
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxJobs bounds the job history a runner keeps
+	maxJobs = 100
+
+	// redacted replaces the value of secret task parameters in job history
+	redacted = "[redacted]"
+)
+
+var (
+	ErrTaskNotFound  = errors.New("task not found")
+	ErrJobNotFound   = errors.New("job not found")
+	ErrInvalidParams = errors.New("invalid task parameters")
+)
+
+// Params are the string parameters a task is started with
+type Params map[string]string
+
+// Duration returns the named parameter as a duration, or def when it is not
+// set
+func (p Params) Duration(name string, def time.Duration) (time.Duration, error) {
+	value, ok := p[name]
+	if !ok || value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %s must be a positive duration such as 24h", ErrInvalidParams, name)
+	}
+	return d, nil
+}
+
+// Bool returns the named parameter as a boolean, false when it is not set
+func (p Params) Bool(name string) (bool, error) {
+	value, ok := p[name]
+	if !ok || value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false", ErrInvalidParams, name)
+	}
+	return b, nil
+}
+
+// Result is what a task reports when it finishes, e.g. the number of
+// entities it touched
+type Result map[string]interface{}
+
+// Task is an operational task a service can run on request
+type Task struct {
+	Name        string
+	Description string
+	// Secret lists parameters, such as key material, that are not kept in
+	// the job history
+	Secret []string
+	Run    func(ctx context.Context, params Params) (Result, error)
+}
+
+// TaskInfo describes a task in the admin API
+type TaskInfo struct {
+	Service     string `json:"service"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// JobStatus is the state of a job
+type JobStatus string
+
+const (
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job is one run of a task
+type Job struct {
+	ID          string     `json:"id"`
+	Service     string     `json:"service"`
+	Task        string     `json:"task"`
+	Params      Params     `json:"params,omitempty"`
+	RequestedBy string     `json:"requested_by,omitempty"`
+	Status      JobStatus  `json:"status"`
+	Result      Result     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// StartRequest starts a task through the admin API
+type StartRequest struct {
+	Params      Params `json:"params,omitempty"`
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// LogLevelRequest changes a log level of the service through the admin
+// API. An empty component sets the service level; an empty level removes a
+// component's own level.
+type LogLevelRequest struct {
+	Component   string `json:"component,omitempty"`
+	Level       string `json:"level"`
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// Runner runs a service's admin tasks in the background
+type Runner struct {
+	service string
+	logger  *logger.Logger
+	now     func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks map[string]*Task
+	order []string
+	jobs  []*Job
+}
+
+// NewRunner creates a runner with no tasks
+func NewRunner(service string, log *logger.Logger) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		service: service,
+		logger:  log.Component("admin"),
+		now:     time.Now,
+		ctx:     ctx,
+		cancel:  cancel,
+		tasks:   make(map[string]*Task),
+	}
+}
+
+// Setup serves the admin API of a service under /admin with tasks
+// registered, accepting only requests signed with the JWT secret
+func Setup(router *gin.Engine, cfg *config.Config, log *logger.Logger, tasks ...Task) *Runner {
+	runner := NewRunner(cfg.ServiceName, log)
+	runner.Register(tasks...)
+	runner.RegisterRoutes(router.Group("/admin", RequireSignature([]byte(cfg.JWTSecret))))
+	return runner
+}
+
+// Register adds tasks, replacing any registered under the same name
+func (r *Runner) Register(tasks ...Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range tasks {
+		task := tasks[i]
+		if _, exists := r.tasks[task.Name]; !exists {
+			r.order = append(r.order, task.Name)
+		}
+		r.tasks[task.Name] = &task
+	}
+}
+
+// Tasks lists the registered tasks in registration order
+func (r *Runner) Tasks() []TaskInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]TaskInfo, 0, len(r.order))
+	for _, name := range r.order {
+		infos = append(infos, TaskInfo{Service: r.service, Name: name, Description: r.tasks[name].Description})
+	}
+	return infos
+}
+
+// Start runs a task in the background and returns its job
+func (r *Runner) Start(name string, params Params, requestedBy string) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+	}
+
+	job := &Job{
+		ID:          newJobID(),
+		Service:     r.service,
+		Task:        name,
+		Params:      redact(params, task.Secret),
+		RequestedBy: requestedBy,
+		Status:      JobStatusRunning,
+		StartedAt:   r.now(),
+	}
+	r.jobs = append(r.jobs, job)
+	r.trim()
+
+	r.logger.Info("Admin task started", "task", name, "requested_by", requestedBy, "job_id", job.ID)
+	r.wg.Add(1)
+	go r.run(task, job, params)
+	return job.snapshot(), nil
+}
+
+func (r *Runner) run(task *Task, job *Job, params Params) {
+	defer r.wg.Done()
+
+	var result Result
+	var err error
+	func() {
+		// A task bug fails its job rather than the service
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("task panicked: %v", recovered)
+			}
+		}()
+		if params == nil {
+			params = Params{}
+		}
+		result, err = task.Run(r.ctx, params)
+	}()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	finished := r.now()
+	job.FinishedAt = &finished
+	job.Result = result
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		r.logger.Warn("Admin task failed", "task", job.Task, "job_id", job.ID, "error", err)
+		return
+	}
+	job.Status = JobStatusSucceeded
+	r.logger.Info("Admin task finished", "task", job.Task, "job_id", job.ID, "duration", finished.Sub(job.StartedAt).Round(time.Millisecond))
+}
+
+// trim drops the oldest finished jobs beyond the history limit
+func (r *Runner) trim() {
+	for excess := len(r.jobs) - maxJobs; excess > 0; excess-- {
+		for i, job := range r.jobs {
+			if job.Status != JobStatusRunning {
+				r.jobs = append(r.jobs[:i], r.jobs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Jobs lists jobs, newest first
+func (r *Runner) Jobs() []*Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := make([]*Job, 0, len(r.jobs))
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, r.jobs[i].snapshot())
+	}
+	return jobs
+}
+
+// Job returns a job by ID
+func (r *Runner) Job(id string) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ID == id {
+			return job.snapshot(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// Wait blocks until every running job has finished
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Stop cancels running jobs and waits for them to return
+func (r *Runner) Stop() {
+	if r == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// RegisterRoutes registers the admin API. The caller is responsible for
+// authentication.
+func (r *Runner) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/tasks", r.listTasksHandler)
+	router.POST("/tasks/:name", r.startTaskHandler)
+	router.GET("/jobs", r.listJobsHandler)
+	router.GET("/jobs/:id", r.getJobHandler)
+	router.GET("/log-levels", r.getLogLevelsHandler)
+	router.PUT("/log-levels", r.setLogLevelHandler)
+}
+
+func (r *Runner) listTasksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"service": r.service, "tasks": r.Tasks()})
+}
+
+func (r *Runner) startTaskHandler(c *gin.Context) {
+	var req StartRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	job, err := r.Start(c.Param("name"), req.Params, req.RequestedBy)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (r *Runner) listJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"service": r.service, "jobs": r.Jobs()})
+}
+
+func (r *Runner) getJobHandler(c *gin.Context) {
+	job, err := r.Job(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (r *Runner) getLogLevelsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"service": r.service, "log_levels": r.logger.Levels()})
+}
+
+func (r *Runner) setLogLevelHandler(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := r.logger.SetComponentLevel(req.Component, req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r.logger.Info("Log level changed", "log_component", req.Component, "level", req.Level, "requested_by", req.RequestedBy)
+	c.JSON(http.StatusOK, gin.H{"service": r.service, "log_levels": r.logger.Levels()})
+}
+
+func (j *Job) snapshot() *Job {
+	copied := *j
+	if j.FinishedAt != nil {
+		finished := *j.FinishedAt
+		copied.FinishedAt = &finished
+	}
+	return &copied
+}
+
+func redact(params Params, secret []string) Params {
+	if len(params) == 0 {
+		return nil
+	}
+	recorded := make(Params, len(params))
+	for name, value := range params {
+		recorded[name] = value
+	}
+	for _, name := range secret {
+		if _, ok := recorded[name]; ok {
+			recorded[name] = redacted
+		}
+	}
+	return recorded
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SortJobs orders jobs from several services newest first
+func SortJobs(jobs []*Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+}
@@ -0,0 +1,251 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunner_RunsTasksAsJobs(t *testing.T) {
+	runner := NewRunner("device-service", logger.New("info", "test"))
+	runner.Register(
+		Task{
+			Name: "reindex",
+			Run: func(ctx context.Context, params Params) (Result, error) {
+				return Result{"reindexed": 3}, nil
+			},
+		},
+		Task{
+			Name:   "rotate",
+			Secret: []string{"private_key"},
+			Run: func(ctx context.Context, params Params) (Result, error) {
+				return nil, errors.New("key mismatch")
+			},
+		},
+		Task{
+			Name: "broken",
+			Run: func(ctx context.Context, params Params) (Result, error) {
+				var m map[string]int
+				m["x"] = 1
+				return nil, nil
+			},
+		},
+	)
+
+	_, err := runner.Start("vacuum", nil, "alice")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	reindex, err := runner.Start("reindex", nil, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusRunning, reindex.Status)
+	rotate, err := runner.Start("rotate", Params{"private_key": "-----BEGIN", "resign": "true"}, "alice")
+	require.NoError(t, err)
+	broken, err := runner.Start("broken", nil, "alice")
+	require.NoError(t, err)
+	runner.Wait()
+
+	job, err := runner.Job(reindex.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusSucceeded, job.Status)
+	assert.Equal(t, Result{"reindexed": 3}, job.Result)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, "alice", job.RequestedBy)
+
+	job, err = runner.Job(rotate.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, job.Status)
+	assert.Equal(t, "key mismatch", job.Error)
+	assert.Equal(t, Params{"private_key": redacted, "resign": "true"}, job.Params)
+
+	// A panicking task fails its job instead of the service
+	job, err = runner.Job(broken.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, job.Status)
+	assert.Contains(t, job.Error, "task panicked")
+
+	jobs := runner.Jobs()
+	require.Len(t, jobs, 3)
+	assert.Equal(t, broken.ID, jobs[0].ID)
+	assert.Equal(t, []TaskInfo{
+		{Service: "device-service", Name: "reindex"},
+		{Service: "device-service", Name: "rotate"},
+		{Service: "device-service", Name: "broken"},
+	}, runner.Tasks())
+}
+
+func TestRunner_StopCancelsJobs(t *testing.T) {
+	runner := NewRunner("telemetry-service", logger.New("info", "test"))
+	runner.Register(Task{
+		Name: "gc",
+		Run: func(ctx context.Context, params Params) (Result, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	job, err := runner.Start("gc", nil, "")
+	require.NoError(t, err)
+	runner.Stop()
+
+	job, err = runner.Job(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, job.Status)
+	assert.Equal(t, context.Canceled.Error(), job.Error)
+}
+
+func TestParams(t *testing.T) {
+	params := Params{"older_than": "48h", "resign": "yes", "bad": "soon"}
+
+	d, err := params.Duration("older_than", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 48*time.Hour, d)
+	d, err = params.Duration("missing", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+	_, err = params.Duration("bad", time.Hour)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = params.Bool("resign")
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	b, err := Params{"resign": "true"}.Bool("resign")
+	require.NoError(t, err)
+	assert.True(t, b)
+}
+
+func TestMigrateTask(t *testing.T) {
+	var ran []string
+	task := MigrateTask(
+		Migration{ID: "0001-a", Up: func(ctx context.Context) (int, error) {
+			ran = append(ran, "0001-a")
+			return 2, nil
+		}},
+		Migration{ID: "0002-b", Up: func(ctx context.Context) (int, error) {
+			ran = append(ran, "0002-b")
+			return 0, errors.New("boom")
+		}},
+		Migration{ID: "0003-c", Up: func(ctx context.Context) (int, error) {
+			ran = append(ran, "0003-c")
+			return 1, nil
+		}},
+	)
+
+	result, err := task.Run(context.Background(), Params{})
+	assert.ErrorContains(t, err, "migration 0002-b failed: boom")
+	assert.Equal(t, []string{"0001-a", "0002-b"}, ran)
+	assert.Equal(t, map[string]int{"0001-a": 2}, result["migrations"])
+}
+
+func TestSetup_RequiresSignedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := "test-secret"
+	router := gin.New()
+	runner := Setup(router, &config.Config{ServiceName: "template-service", JWTSecret: secret}, logger.New("info", "test"), Task{
+		Name: "reindex",
+		Run: func(ctx context.Context, params Params) (Result, error) {
+			return Result{"reindexed": len(params)}, nil
+		},
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(method, path, body string, sign func(*http.Request, []byte)) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if sign != nil {
+			sign(req, []byte(body))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	signNow := func(req *http.Request, body []byte) { SignRequest(req, []byte(secret), body, time.Now()) }
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/tasks", "", nil).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/tasks", "", func(req *http.Request, body []byte) {
+		SignRequest(req, []byte("other-secret"), body, time.Now())
+	}).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/admin/tasks", "", func(req *http.Request, body []byte) {
+		SignRequest(req, []byte(secret), body, time.Now().Add(-time.Hour))
+	}).StatusCode)
+	// The signature covers the body
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/tasks/reindex", `{"params":{"a":"1"}}`, func(req *http.Request, body []byte) {
+		SignRequest(req, []byte(secret), []byte(`{}`), time.Now())
+	}).StatusCode)
+
+	resp := send(http.MethodGet, "/admin/tasks", "", signNow)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tasks struct {
+		Tasks []TaskInfo `json:"tasks"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+	assert.Equal(t, []TaskInfo{{Service: "template-service", Name: "reindex"}}, tasks.Tasks)
+
+	resp = send(http.MethodPost, "/admin/tasks/reindex", `{"params":{"a":"1"},"requested_by":"alice"}`, signNow)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var job Job
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	runner.Wait()
+
+	resp = send(http.MethodGet, "/admin/jobs/"+job.ID, "", signNow)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	assert.Equal(t, JobStatusSucceeded, job.Status)
+	assert.Equal(t, "alice", job.RequestedBy)
+	assert.EqualValues(t, 1, job.Result["reindexed"])
+
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/admin/tasks/vacuum", "", signNow).StatusCode)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/admin/jobs/missing", "", signNow).StatusCode)
+}
+
+func TestRunner_LogLevels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := logger.New("info", "test")
+	log.Component("scheduler")
+	runner := NewRunner("ota-service", log)
+	router := gin.New()
+	runner.RegisterRoutes(router.Group("/admin"))
+
+	send := func(method, body string) (int, logger.Levels) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/admin/log-levels", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var resp struct {
+			Service   string        `json:"service"`
+			LogLevels logger.Levels `json:"log_levels"`
+		}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "ota-service", resp.Service)
+		}
+		return w.Code, resp.LogLevels
+	}
+
+	code, levels := send(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "info", levels.Level)
+	assert.Equal(t, []string{"admin", "scheduler"}, levels.Components)
+	assert.Empty(t, levels.Overrides)
+
+	code, levels = send(http.MethodPut, `{"component":"scheduler","level":"debug"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"scheduler": "debug"}, levels.Overrides)
+	assert.True(t, log.Component("scheduler").IsLevelEnabled(logrus.DebugLevel))
+	assert.False(t, log.IsLevelEnabled(logrus.DebugLevel))
+
+	code, _ = send(http.MethodPut, `{"component":"scheduler","level":"loud"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
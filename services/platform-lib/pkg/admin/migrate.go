@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+)
+
+// Migration is a data migration. Migrations are not tracked, so Up must be
+// idempotent: it finds the entities still needing the change, changes them
+// and returns how many it changed.
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context) (int, error)
+}
+
+// MigrateTask returns a "migrate" task running migrations in order. It
+// stops at the first failing migration; the result reports how many
+// entities each completed migration changed.
+func MigrateTask(migrations ...Migration) Task {
+	return Task{
+		Name:        "migrate",
+		Description: "Apply pending data migrations",
+		Run: func(ctx context.Context, params Params) (Result, error) {
+			changed := make(map[string]int, len(migrations))
+			result := Result{"migrations": changed}
+			for _, migration := range migrations {
+				n, err := migration.Up(ctx)
+				if err != nil {
+					return result, fmt.Errorf("migration %s failed: %w", migration.ID, err)
+				}
+				changed[migration.ID] = n
+			}
+			return result, nil
+		},
+	}
+}
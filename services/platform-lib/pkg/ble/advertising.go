@@ -0,0 +1,160 @@
+package ble
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HCI LE advertising report event
+const (
+	hciEventLEMeta           = 0x3E
+	hciSubeventAdvertising   = 0x02
+	hciAdvertisingScanResult = 0x04
+
+	adIncomplete128 = 0x06
+	adComplete128   = 0x07
+	adShortName     = 0x08
+	adCompleteName  = 0x09
+)
+
+// advertisement is one advertising or scan response report
+type advertisement struct {
+	address string
+	random  bool
+	scanRsp bool
+	name    string
+	uuids   []UUID
+	rssi    int
+}
+
+// parseAdvertisingReports parses the reports of an LE Meta advertising
+// report event, without the HCI packet type and event header
+func parseAdvertisingReports(event []byte) ([]advertisement, error) {
+	if len(event) < 2 || event[0] != hciSubeventAdvertising {
+		return nil, nil
+	}
+	count := int(event[1])
+	rest := event[2:]
+
+	reports := make([]advertisement, 0, count)
+	for i := 0; i < count; i++ {
+		// Event type, address type, address, data length
+		if len(rest) < 9 {
+			return nil, fmt.Errorf("short advertising report")
+		}
+		report := advertisement{
+			scanRsp: rest[0] == hciAdvertisingScanResult,
+			random:  rest[1] == 0x01,
+			address: formatAddress(rest[2:8]),
+		}
+		length := int(rest[8])
+		if len(rest) < 9+length+1 {
+			return nil, fmt.Errorf("short advertising report")
+		}
+		parseAdvertisingData(rest[9:9+length], &report)
+		report.rssi = int(int8(rest[9+length]))
+		reports = append(reports, report)
+		rest = rest[9+length+1:]
+	}
+	return reports, nil
+}
+
+// parseAdvertisingData reads the names and 128-bit service UUIDs from AD
+// structures, ignoring malformed trailing data
+func parseAdvertisingData(data []byte, report *advertisement) {
+	for len(data) > 0 {
+		length := int(data[0])
+		if length == 0 || len(data) < 1+length {
+			return
+		}
+		kind, value := data[1], data[2:1+length]
+		switch kind {
+		case adIncomplete128, adComplete128:
+			for ; len(value) >= 16; value = value[16:] {
+				if uuid, ok := uuidFromWire(value[:16]); ok {
+					report.uuids = append(report.uuids, uuid)
+				}
+			}
+		case adShortName:
+			if report.name == "" {
+				report.name = string(value)
+			}
+		case adCompleteName:
+			report.name = string(value)
+		}
+		data = data[1+length:]
+	}
+}
+
+// formatAddress formats a little-endian device address for display
+func formatAddress(raw []byte) string {
+	parts := make([]string, len(raw))
+	for i := range raw {
+		parts[len(raw)-1-i] = fmt.Sprintf("%02X", raw[i])
+	}
+	return strings.Join(parts, ":")
+}
+
+// parseAddress parses a display address, keeping display byte order
+func parseAddress(address string) ([6]byte, error) {
+	var raw [6]byte
+	parts := strings.Split(address, ":")
+	if len(parts) != len(raw) {
+		return raw, fmt.Errorf("invalid Bluetooth address %q", address)
+	}
+	for i, part := range parts {
+		var b byte
+		if _, err := fmt.Sscanf(part, "%02X", &b); err != nil || len(part) != 2 {
+			return raw, fmt.Errorf("invalid Bluetooth address %q", address)
+		}
+		raw[i] = b
+	}
+	return raw, nil
+}
+
+// scanResults merges advertising and scan response reports by address and
+// keeps the devices advertising a service
+type scanResults struct {
+	service UUID
+	seen    map[string]*advertisement
+}
+
+func newScanResults(service UUID) *scanResults {
+	return &scanResults{service: service, seen: make(map[string]*advertisement)}
+}
+
+func (r *scanResults) add(report advertisement) {
+	merged, ok := r.seen[report.address]
+	if !ok {
+		merged = &advertisement{address: report.address, random: report.random}
+		r.seen[report.address] = merged
+	}
+	if report.name != "" {
+		merged.name = report.name
+	}
+	merged.uuids = append(merged.uuids, report.uuids...)
+	if !report.scanRsp || merged.rssi == 0 {
+		merged.rssi = report.rssi
+	}
+}
+
+// peripherals lists matching devices, strongest signal first
+func (r *scanResults) peripherals() []Peripheral {
+	var found []Peripheral
+	for _, adv := range r.seen {
+		for _, uuid := range adv.uuids {
+			if uuid == r.service {
+				found = append(found, Peripheral{Address: adv.address, Random: adv.random, Name: adv.name, RSSI: adv.rssi})
+				break
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].RSSI != found[j].RSSI {
+			return found[i].RSSI > found[j].RSSI
+		}
+		return found[i].Address < found[j].Address
+	})
+	return found
+}
@@ -0,0 +1,71 @@
+package ble
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// advertisingReport builds one report of an LE advertising report event
+func advertisingReport(eventType, addrType byte, addr [6]byte, data []byte, rssi int8) []byte {
+	report := []byte{eventType, addrType}
+	report = append(report, addr[:]...)
+	report = append(report, byte(len(data)))
+	report = append(report, data...)
+	return append(report, byte(rssi))
+}
+
+func adStructure(kind byte, value []byte) []byte {
+	return append([]byte{byte(len(value) + 1), kind}, value...)
+}
+
+func TestParseAdvertisingReports(t *testing.T) {
+	addr := [6]byte{0xCC, 0xBB, 0xAA, 0x28, 0x6F, 0x24}
+	advData := append(adStructure(0x01, []byte{0x06}), adStructure(adComplete128, ServiceUUID.wire())...)
+	scanRsp := adStructure(adCompleteName, []byte("athena-greenhouse-01"))
+
+	event := []byte{hciSubeventAdvertising, 2}
+	event = append(event, advertisingReport(0x00, 0x00, addr, advData, -58)...)
+	event = append(event, advertisingReport(hciAdvertisingScanResult, 0x00, addr, scanRsp, -60)...)
+
+	reports, err := parseAdvertisingReports(event)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "24:6F:28:AA:BB:CC", reports[0].address)
+	assert.Equal(t, []UUID{ServiceUUID}, reports[0].uuids)
+	assert.Equal(t, -58, reports[0].rssi)
+	assert.True(t, reports[1].scanRsp)
+	assert.Equal(t, "athena-greenhouse-01", reports[1].name)
+
+	_, err = parseAdvertisingReports(event[:len(event)-3])
+	assert.Error(t, err)
+
+	reports, err = parseAdvertisingReports([]byte{0x01, 0x00})
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+}
+
+func TestScanResults(t *testing.T) {
+	results := newScanResults(ServiceUUID)
+	results.add(advertisement{address: "24:6F:28:AA:BB:CC", uuids: []UUID{ServiceUUID}, rssi: -70})
+	results.add(advertisement{address: "24:6F:28:AA:BB:CC", scanRsp: true, name: "athena-a", rssi: -72})
+	results.add(advertisement{address: "C4:4F:33:11:22:33", random: true, uuids: []UUID{ServiceUUID}, rssi: -50})
+	results.add(advertisement{address: "00:11:22:33:44:55", name: "headphones", rssi: -40})
+
+	assert.Equal(t, []Peripheral{
+		{Address: "C4:4F:33:11:22:33", Random: true, RSSI: -50},
+		{Address: "24:6F:28:AA:BB:CC", Name: "athena-a", RSSI: -70},
+	}, results.peripherals())
+}
+
+func TestParseAddress(t *testing.T) {
+	addr, err := parseAddress("24:6f:28:AA:BB:CC")
+	require.NoError(t, err)
+	assert.Equal(t, [6]byte{0x24, 0x6F, 0x28, 0xAA, 0xBB, 0xCC}, addr)
+
+	for _, bad := range []string{"", "24:6F:28:AA:BB", "24:6F:28:AA:BB:GG", "246F:28:AA:BB:CC:DD"} {
+		_, err := parseAddress(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,288 @@
+package ble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ATT opcodes used by the GATT client
+const (
+	attOpError            = 0x01
+	attOpMTURequest       = 0x02
+	attOpMTUResponse      = 0x03
+	attOpFindByTypeReq    = 0x06
+	attOpFindByTypeRsp    = 0x07
+	attOpReadByTypeReq    = 0x08
+	attOpReadByTypeRsp    = 0x09
+	attOpReadRequest      = 0x0A
+	attOpReadResponse     = 0x0B
+	attOpReadBlobRequest  = 0x0C
+	attOpReadBlobResponse = 0x0D
+	attOpWriteRequest     = 0x12
+	attOpWriteResponse    = 0x13
+	attOpPrepareWriteReq  = 0x16
+	attOpPrepareWriteRsp  = 0x17
+	attOpExecuteWriteReq  = 0x18
+	attOpExecuteWriteRsp  = 0x19
+	attOpNotification     = 0x1B
+	attOpIndication       = 0x1D
+	attOpConfirmation     = 0x1E
+)
+
+const (
+	attErrRequestNotSupported = 0x06
+	attErrAttributeNotFound   = 0x0A
+	attErrAttributeNotLong    = 0x0B
+
+	gattPrimaryService = 0x2800
+	gattCharacteristic = 0x2803
+
+	attDefaultMTU = 23
+	// attClientMTU is the MTU offered to devices; ESP32 accepts up to 517
+	attClientMTU = 247
+)
+
+// ATTError is an error response from a device
+type ATTError struct {
+	Opcode byte
+	Handle uint16
+	Code   byte
+}
+
+func (e *ATTError) Error() string {
+	switch e.Code {
+	case 0x05, 0x0F:
+		return fmt.Sprintf("ATT error 0x%02x on handle 0x%04x: link not encrypted (pairing failed?)", e.Code, e.Handle)
+	case 0x03:
+		return fmt.Sprintf("ATT error 0x%02x on handle 0x%04x: write not permitted", e.Code, e.Handle)
+	}
+	return fmt.Sprintf("ATT error 0x%02x on handle 0x%04x (request 0x%02x)", e.Code, e.Handle, e.Opcode)
+}
+
+// pduConn carries one ATT PDU per Read and Write, like an L2CAP
+// SOCK_SEQPACKET socket on the ATT channel
+type pduConn interface {
+	io.ReadWriteCloser
+}
+
+// deadliner is implemented by connections that support read deadlines
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// gattClient is a GATT client for one service over an ATT bearer
+type gattClient struct {
+	conn    pduConn
+	mtu     int
+	handles map[UUID]uint16
+	buf     []byte
+}
+
+// newGATTClient exchanges the MTU and discovers the characteristics of a
+// primary service
+func newGATTClient(ctx context.Context, conn pduConn, service UUID) (*gattClient, error) {
+	c := &gattClient{
+		conn:    conn,
+		mtu:     attDefaultMTU,
+		handles: make(map[UUID]uint16),
+		buf:     make([]byte, 1024),
+	}
+
+	rsp, err := c.request(ctx, []byte{attOpMTURequest, byte(attClientMTU), byte(attClientMTU >> 8)}, attOpMTUResponse)
+	var attErr *ATTError
+	switch {
+	case errors.As(err, &attErr) && attErr.Code == attErrRequestNotSupported:
+	case err != nil:
+		return nil, fmt.Errorf("MTU exchange failed: %w", err)
+	case len(rsp) >= 3:
+		if server := int(binary.LittleEndian.Uint16(rsp[1:3])); server < attClientMTU {
+			c.mtu = max(server, attDefaultMTU)
+		} else {
+			c.mtu = attClientMTU
+		}
+	}
+
+	start, end, err := c.findService(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.discoverCharacteristics(ctx, start, end); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// findService finds the handle range of a primary service by UUID
+func (c *gattClient) findService(ctx context.Context, service UUID) (uint16, uint16, error) {
+	req := []byte{attOpFindByTypeReq, 0x01, 0x00, 0xFF, 0xFF, byte(gattPrimaryService & 0xFF), byte(gattPrimaryService >> 8)}
+	req = append(req, service.wire()...)
+
+	rsp, err := c.request(ctx, req, attOpFindByTypeRsp)
+	var attErr *ATTError
+	if errors.As(err, &attErr) && attErr.Code == attErrAttributeNotFound {
+		return 0, 0, fmt.Errorf("device does not offer the provisioning service %s", service)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("service discovery failed: %w", err)
+	}
+	if len(rsp) < 5 {
+		return 0, 0, fmt.Errorf("short service discovery response")
+	}
+	return binary.LittleEndian.Uint16(rsp[1:3]), binary.LittleEndian.Uint16(rsp[3:5]), nil
+}
+
+// discoverCharacteristics records the value handle of every characteristic
+// in a handle range
+func (c *gattClient) discoverCharacteristics(ctx context.Context, start, end uint16) error {
+	for start <= end {
+		req := []byte{attOpReadByTypeReq, byte(start), byte(start >> 8), byte(end), byte(end >> 8),
+			byte(gattCharacteristic & 0xFF), byte(gattCharacteristic >> 8)}
+		rsp, err := c.request(ctx, req, attOpReadByTypeRsp)
+		var attErr *ATTError
+		if errors.As(err, &attErr) && attErr.Code == attErrAttributeNotFound {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("characteristic discovery failed: %w", err)
+		}
+		if len(rsp) < 2 || rsp[1] < 7 {
+			return fmt.Errorf("malformed characteristic discovery response")
+		}
+
+		size := int(rsp[1])
+		last := start
+		for entry := rsp[2:]; len(entry) >= size; entry = entry[size:] {
+			// Declaration handle, properties, value handle, UUID
+			last = binary.LittleEndian.Uint16(entry[0:2])
+			if uuid, ok := uuidFromWire(entry[5:size]); ok {
+				c.handles[uuid] = binary.LittleEndian.Uint16(entry[3:5])
+			}
+		}
+		if last == 0xFFFF || last < start {
+			return nil
+		}
+		start = last + 1
+	}
+	return nil
+}
+
+func (c *gattClient) handle(characteristic UUID) (uint16, error) {
+	handle, ok := c.handles[characteristic]
+	if !ok {
+		return 0, fmt.Errorf("device has no characteristic %s", characteristic)
+	}
+	return handle, nil
+}
+
+// Read reads a characteristic, following up with blob reads for values
+// longer than one PDU
+func (c *gattClient) Read(ctx context.Context, characteristic UUID) ([]byte, error) {
+	handle, err := c.handle(characteristic)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := c.request(ctx, []byte{attOpReadRequest, byte(handle), byte(handle >> 8)}, attOpReadResponse)
+	if err != nil {
+		return nil, err
+	}
+	value := append([]byte(nil), rsp[1:]...)
+
+	for chunk := len(rsp) - 1; chunk == c.mtu-1; {
+		offset := len(value)
+		rsp, err := c.request(ctx, []byte{attOpReadBlobRequest, byte(handle), byte(handle >> 8), byte(offset), byte(offset >> 8)}, attOpReadBlobResponse)
+		var attErr *ATTError
+		if errors.As(err, &attErr) && attErr.Code == attErrAttributeNotLong {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, rsp[1:]...)
+		chunk = len(rsp) - 1
+	}
+	return value, nil
+}
+
+// Write writes a characteristic with a write request, or with prepared
+// writes when the value does not fit in one PDU
+func (c *gattClient) Write(ctx context.Context, characteristic UUID, value []byte) error {
+	handle, err := c.handle(characteristic)
+	if err != nil {
+		return err
+	}
+
+	if len(value) <= c.mtu-3 {
+		req := append([]byte{attOpWriteRequest, byte(handle), byte(handle >> 8)}, value...)
+		_, err := c.request(ctx, req, attOpWriteResponse)
+		return err
+	}
+
+	chunk := c.mtu - 5
+	for offset := 0; offset < len(value); offset += chunk {
+		part := value[offset:min(offset+chunk, len(value))]
+		req := append([]byte{attOpPrepareWriteReq, byte(handle), byte(handle >> 8), byte(offset), byte(offset >> 8)}, part...)
+		if _, err := c.request(ctx, req, attOpPrepareWriteRsp); err != nil {
+			// Cancel the queued writes
+			_, _ = c.request(ctx, []byte{attOpExecuteWriteReq, 0x00}, attOpExecuteWriteRsp)
+			return err
+		}
+	}
+	_, err = c.request(ctx, []byte{attOpExecuteWriteReq, 0x01}, attOpExecuteWriteRsp)
+	return err
+}
+
+func (c *gattClient) Close() error {
+	return c.conn.Close()
+}
+
+// request sends a request PDU and waits for its response, skipping
+// notifications and confirming indications that arrive in between
+func (c *gattClient) request(ctx context.Context, req []byte, response byte) ([]byte, error) {
+	if d, ok := c.conn.(deadliner); ok {
+		deadline, hasDeadline := ctx.Deadline()
+		if !hasDeadline {
+			deadline = time.Now().Add(30 * time.Second)
+		}
+		if err := d.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("ATT write failed: %w", err)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := c.conn.Read(c.buf)
+		if err != nil {
+			return nil, fmt.Errorf("ATT read failed: %w", err)
+		}
+		if n == 0 {
+			continue
+		}
+		pdu := c.buf[:n]
+
+		switch pdu[0] {
+		case response:
+			return append([]byte(nil), pdu...), nil
+		case attOpError:
+			if len(pdu) < 5 {
+				return nil, fmt.Errorf("short ATT error response")
+			}
+			return nil, &ATTError{Opcode: pdu[1], Handle: binary.LittleEndian.Uint16(pdu[2:4]), Code: pdu[4]}
+		case attOpNotification:
+		case attOpIndication:
+			if _, err := c.conn.Write([]byte{attOpConfirmation}); err != nil {
+				return nil, fmt.Errorf("ATT write failed: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected ATT opcode 0x%02x waiting for 0x%02x", pdu[0], response)
+		}
+	}
+}
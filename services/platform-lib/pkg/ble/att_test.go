@@ -0,0 +1,228 @@
+package ble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeATT is an in-memory ATT server exposing one primary service. Each
+// Write is handled immediately and its responses are returned by Read.
+type fakeATT struct {
+	mtu       int
+	service   UUID
+	chars     []UUID
+	values    map[uint16][]byte
+	prepared  []byte
+	target    uint16
+	pending   [][]byte
+	requests  []byte
+	interject [][]byte
+	closed    bool
+}
+
+func newFakeATT(service UUID, chars ...UUID) *fakeATT {
+	return &fakeATT{mtu: 64, service: service, chars: chars, values: make(map[uint16][]byte)}
+}
+
+// Service at handle 1, characteristic i declared at 2+2i with its value
+// at 3+2i
+func (f *fakeATT) valueHandle(char UUID) uint16 {
+	for i, c := range f.chars {
+		if c == char {
+			return uint16(3 + 2*i)
+		}
+	}
+	return 0
+}
+
+func (f *fakeATT) endHandle() uint16 {
+	return uint16(1 + 2*len(f.chars))
+}
+
+func attErrorPDU(opcode byte, handle uint16, code byte) []byte {
+	return []byte{attOpError, opcode, byte(handle), byte(handle >> 8), code}
+}
+
+func (f *fakeATT) Write(p []byte) (int, error) {
+	req := append([]byte(nil), p...)
+	if req[0] == attOpConfirmation {
+		return len(p), nil
+	}
+	f.requests = append(f.requests, req[0])
+	f.pending = append(f.pending, f.interject...)
+	f.interject = nil
+
+	handle := func() uint16 { return binary.LittleEndian.Uint16(req[1:3]) }
+	switch req[0] {
+	case attOpMTURequest:
+		f.pending = append(f.pending, []byte{attOpMTUResponse, byte(f.mtu), byte(f.mtu >> 8)})
+	case attOpFindByTypeReq:
+		if uuid, _ := uuidFromWire(req[7:]); uuid != f.service {
+			f.pending = append(f.pending, attErrorPDU(req[0], 1, attErrAttributeNotFound))
+			break
+		}
+		end := f.endHandle()
+		f.pending = append(f.pending, []byte{attOpFindByTypeRsp, 0x01, 0x00, byte(end), byte(end >> 8)})
+	case attOpReadByTypeReq:
+		// One characteristic per response to exercise paging
+		start := handle()
+		for i, c := range f.chars {
+			decl := uint16(2 + 2*i)
+			if decl < start {
+				continue
+			}
+			rsp := []byte{attOpReadByTypeRsp, 21, byte(decl), byte(decl >> 8), 0x0A, byte(decl + 1), byte((decl + 1) >> 8)}
+			f.pending = append(f.pending, append(rsp, c.wire()...))
+			return len(p), nil
+		}
+		f.pending = append(f.pending, attErrorPDU(req[0], start, attErrAttributeNotFound))
+	case attOpReadRequest:
+		value := f.values[handle()]
+		f.pending = append(f.pending, append([]byte{attOpReadResponse}, value[:min(len(value), f.mtu-1)]...))
+	case attOpReadBlobRequest:
+		value := f.values[handle()]
+		offset := int(binary.LittleEndian.Uint16(req[3:5]))
+		if offset >= len(value) {
+			f.pending = append(f.pending, attErrorPDU(req[0], handle(), attErrAttributeNotLong))
+			break
+		}
+		value = value[offset:]
+		f.pending = append(f.pending, append([]byte{attOpReadBlobResponse}, value[:min(len(value), f.mtu-1)]...))
+	case attOpWriteRequest:
+		if len(req) > f.mtu {
+			f.pending = append(f.pending, attErrorPDU(req[0], handle(), 0x0D))
+			break
+		}
+		f.values[handle()] = req[3:]
+		f.pending = append(f.pending, []byte{attOpWriteResponse})
+	case attOpPrepareWriteReq:
+		f.prepared = append(f.prepared, req[5:]...)
+		f.target = handle()
+		f.pending = append(f.pending, append([]byte{attOpPrepareWriteRsp}, req[1:]...))
+	case attOpExecuteWriteReq:
+		if req[1] == 0x01 {
+			f.values[f.target] = f.prepared
+		}
+		f.prepared = nil
+		f.pending = append(f.pending, []byte{attOpExecuteWriteRsp})
+	default:
+		f.pending = append(f.pending, attErrorPDU(req[0], 0, attErrRequestNotSupported))
+	}
+	return len(p), nil
+}
+
+func (f *fakeATT) Read(p []byte) (int, error) {
+	if len(f.pending) == 0 {
+		return 0, errors.New("no pending PDU")
+	}
+	pdu := f.pending[0]
+	f.pending = f.pending[1:]
+	return copy(p, pdu), nil
+}
+
+func (f *fakeATT) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestGATTClientDiscovery(t *testing.T) {
+	server := newFakeATT(ServiceUUID, InfoUUID, SSIDUUID, StatusUUID)
+
+	client, err := newGATTClient(context.Background(), server, ServiceUUID)
+	require.NoError(t, err)
+	assert.Equal(t, 64, client.mtu)
+	assert.Equal(t, map[UUID]uint16{InfoUUID: 3, SSIDUUID: 5, StatusUUID: 7}, client.handles)
+
+	_, err = client.Read(context.Background(), TokenUUID)
+	assert.ErrorContains(t, err, "no characteristic")
+
+	require.NoError(t, client.Close())
+	assert.True(t, server.closed)
+}
+
+func TestGATTClientServiceMissing(t *testing.T) {
+	server := newFakeATT(MustParseUUID("0000180f-0000-1000-8000-00805f9b34fb"), InfoUUID)
+
+	_, err := newGATTClient(context.Background(), server, ServiceUUID)
+	assert.ErrorContains(t, err, "does not offer the provisioning service")
+}
+
+func TestGATTClientLongValues(t *testing.T) {
+	server := newFakeATT(ServiceUUID, InfoUUID, TokenUUID)
+	client, err := newGATTClient(context.Background(), server, ServiceUUID)
+	require.NoError(t, err)
+
+	long := make([]byte, 150)
+	for i := range long {
+		long[i] = byte(i)
+	}
+
+	// Values longer than MTU-3 go through prepared writes
+	require.NoError(t, client.Write(context.Background(), TokenUUID, long))
+	assert.Equal(t, long, server.values[server.valueHandle(TokenUUID)])
+	assert.Contains(t, server.requests, byte(attOpPrepareWriteReq))
+
+	// Values filling a read response are continued with blob reads
+	server.values[server.valueHandle(InfoUUID)] = long
+	value, err := client.Read(context.Background(), InfoUUID)
+	require.NoError(t, err)
+	assert.Equal(t, long, value)
+
+	// A value of exactly MTU-1 ends with an attribute-not-long error
+	server.values[server.valueHandle(InfoUUID)] = long[:63]
+	value, err = client.Read(context.Background(), InfoUUID)
+	require.NoError(t, err)
+	assert.Equal(t, long[:63], value)
+}
+
+func TestGATTClientSkipsServerInitiatedPDUs(t *testing.T) {
+	server := newFakeATT(ServiceUUID, StatusUUID)
+	client, err := newGATTClient(context.Background(), server, ServiceUUID)
+	require.NoError(t, err)
+
+	handle := server.valueHandle(StatusUUID)
+	server.values[handle] = []byte{byte(StatusReady)}
+	server.interject = [][]byte{
+		{attOpNotification, byte(handle), byte(handle >> 8), byte(StatusConnecting)},
+		{attOpIndication, byte(handle), byte(handle >> 8), byte(StatusConnecting)},
+	}
+
+	value, err := client.Read(context.Background(), StatusUUID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{byte(StatusReady)}, value)
+}
+
+func TestGATTClientErrorResponse(t *testing.T) {
+	server := newFakeATT(ServiceUUID, ProofUUID)
+	server.mtu = 23
+	client, err := newGATTClient(context.Background(), server, ServiceUUID)
+	require.NoError(t, err)
+
+	handle := server.valueHandle(ProofUUID)
+	server.interject = [][]byte{attErrorPDU(attOpWriteRequest, handle, 0x05)}
+
+	err = client.Write(context.Background(), ProofUUID, []byte("1234"))
+	var attErr *ATTError
+	require.ErrorAs(t, err, &attErr)
+	assert.Equal(t, byte(0x05), attErr.Code)
+	assert.Contains(t, err.Error(), "not encrypted")
+}
+
+func TestUUIDWireRoundTrip(t *testing.T) {
+	uuid, ok := uuidFromWire(ServiceUUID.wire())
+	require.True(t, ok)
+	assert.Equal(t, ServiceUUID, uuid)
+	assert.Equal(t, "a7e40001-5c2b-4b8e-9f3a-6d1c0e8b2a10", uuid.String())
+
+	short, ok := uuidFromWire([]byte{0x03, 0x28})
+	require.True(t, ok)
+	assert.Equal(t, "00002803-0000-1000-8000-00805f9b34fb", short.String())
+
+	_, err := ParseUUID("not-a-uuid")
+	assert.Error(t, err)
+}
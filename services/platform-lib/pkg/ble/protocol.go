@@ -0,0 +1,166 @@
+// Package ble provisions devices over Bluetooth Low Energy. Firmware built
+// with the esp32-ble-provisioning template exposes a GATT config service;
+// Provision unlocks it with the device's provisioning code, writes Wi-Fi
+// credentials and the device token, and waits for the device to join Wi-Fi.
+package ble
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion is the config service version this package speaks
+const ProtocolVersion = 1
+
+// UUID is a 128-bit Bluetooth UUID in display (big-endian) byte order
+type UUID [16]byte
+
+// ParseUUID parses a UUID such as "a7e40001-5c2b-4b8e-9f3a-6d1c0e8b2a10"
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	raw, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(raw) != len(u) {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], raw)
+	return u, nil
+}
+
+// MustParseUUID parses a UUID and panics if it is invalid
+func MustParseUUID(s string) UUID {
+	u, err := ParseUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// wire returns the UUID in the little-endian order used on air
+func (u UUID) wire() []byte {
+	b := make([]byte, len(u))
+	for i := range u {
+		b[i] = u[len(u)-1-i]
+	}
+	return b
+}
+
+// bluetoothBase is the base UUID that 16-bit UUIDs are shortened from
+var bluetoothBase = MustParseUUID("00000000-0000-1000-8000-00805f9b34fb")
+
+// uuidFromWire decodes a 16-bit or 128-bit UUID in on-air byte order
+func uuidFromWire(b []byte) (UUID, bool) {
+	var u UUID
+	switch len(b) {
+	case 2:
+		u = bluetoothBase
+		u[2], u[3] = b[1], b[0]
+	case 16:
+		for i := range u {
+			u[i] = b[len(b)-1-i]
+		}
+	default:
+		return u, false
+	}
+	return u, true
+}
+
+// Config service and characteristics. Info is a JSON DeviceInfo; Proof,
+// SSID, Passphrase and Token are written as UTF-8 strings; Control takes
+// a single command byte and Status reads as a single Status byte.
+var (
+	ServiceUUID    = MustParseUUID("a7e40001-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	InfoUUID       = MustParseUUID("a7e40002-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	ProofUUID      = MustParseUUID("a7e40003-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	SSIDUUID       = MustParseUUID("a7e40004-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	PassphraseUUID = MustParseUUID("a7e40005-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	TokenUUID      = MustParseUUID("a7e40006-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	ControlUUID    = MustParseUUID("a7e40007-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+	StatusUUID     = MustParseUUID("a7e40008-5c2b-4b8e-9f3a-6d1c0e8b2a10")
+)
+
+// controlApply asks the device to store the credentials and join Wi-Fi
+const controlApply byte = 0x01
+
+// Status is the provisioning state a device reports
+type Status byte
+
+const (
+	// StatusLocked waits for the provisioning code
+	StatusLocked Status = iota
+	// StatusReady accepts credentials
+	StatusReady
+	// StatusConnecting is joining Wi-Fi with the written credentials
+	StatusConnecting
+	// StatusConnected has joined Wi-Fi and stored the credentials
+	StatusConnected
+	// StatusWiFiFailed could not join Wi-Fi; credentials may be rewritten
+	StatusWiFiFailed
+	// StatusRejected was given the wrong provisioning code
+	StatusRejected
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusLocked:
+		return "locked"
+	case StatusReady:
+		return "ready"
+	case StatusConnecting:
+		return "connecting"
+	case StatusConnected:
+		return "connected"
+	case StatusWiFiFailed:
+		return "wifi_failed"
+	case StatusRejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(s))
+}
+
+var (
+	// ErrUnsupported is returned where no Bluetooth transport is available
+	ErrUnsupported = errors.New("BLE provisioning is not supported on this platform")
+	// ErrRejected is returned when the device refuses the provisioning code
+	ErrRejected = errors.New("device rejected the provisioning code")
+	// ErrWiFiFailed is returned when the device cannot join the network
+	ErrWiFiFailed = errors.New("device could not join Wi-Fi")
+)
+
+// DeviceInfo is what a device reports about itself before provisioning
+type DeviceInfo struct {
+	Protocol     int    `json:"protocol"`
+	DeviceID     string `json:"device_id"`
+	FirmwareHash string `json:"firmware_hash"`
+	MAC          string `json:"mac"`
+}
+
+// Peripheral is a device found advertising the config service
+type Peripheral struct {
+	Address string `json:"address"`
+	// Random is set for devices using a random rather than public address
+	Random bool   `json:"random,omitempty"`
+	Name   string `json:"name,omitempty"`
+	RSSI   int    `json:"rssi"`
+}
+
+// Conn is a connection to a device's config service
+type Conn interface {
+	Read(ctx context.Context, characteristic UUID) ([]byte, error)
+	Write(ctx context.Context, characteristic UUID, value []byte) error
+	Close() error
+}
+
+// Transport finds devices advertising the config service and connects to
+// them. Scan runs until its context is done.
+type Transport interface {
+	Scan(ctx context.Context) ([]Peripheral, error)
+	Connect(ctx context.Context, peripheral Peripheral) (Conn, error)
+}
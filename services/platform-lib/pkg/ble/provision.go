@@ -0,0 +1,143 @@
+package ble
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// statusPollInterval is how often Provision reads the status while the
+// device joins Wi-Fi
+const statusPollInterval = 500 * time.Millisecond
+
+// Credentials are written to a device during provisioning. Proof is the
+// provisioning code the firmware was built with, if any.
+type Credentials struct {
+	SSID       string
+	Passphrase string
+	Token      string
+	Proof      string
+}
+
+// Validate checks the credentials fit what Wi-Fi and the firmware accept
+func (c *Credentials) Validate() error {
+	if len(c.SSID) == 0 || len(c.SSID) > 32 {
+		return fmt.Errorf("SSID must be 1-32 bytes")
+	}
+	if c.Passphrase != "" && (len(c.Passphrase) < 8 || len(c.Passphrase) > 63) {
+		return fmt.Errorf("WPA passphrase must be 8-63 characters")
+	}
+	if len(c.Token) == 0 || len(c.Token) > 128 {
+		return fmt.Errorf("device token must be 1-128 bytes")
+	}
+	if len(c.Proof) > 64 {
+		return fmt.Errorf("provisioning code must be at most 64 bytes")
+	}
+	for _, value := range []string{c.SSID, c.Passphrase, c.Token, c.Proof} {
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("credentials must be valid UTF-8")
+		}
+	}
+	return nil
+}
+
+// ReadInfo reads what a device reports about itself
+func ReadInfo(ctx context.Context, conn Conn) (*DeviceInfo, error) {
+	raw, err := conn.Read(ctx, InfoUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device info: %w", err)
+	}
+	var info DeviceInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("invalid device info: %w", err)
+	}
+	if info.Protocol != ProtocolVersion {
+		return nil, fmt.Errorf("device speaks provisioning protocol %d, this CLI speaks %d", info.Protocol, ProtocolVersion)
+	}
+	if info.DeviceID == "" {
+		return nil, fmt.Errorf("device did not report its device ID")
+	}
+	return &info, nil
+}
+
+// ReadStatus reads a device's provisioning state
+func ReadStatus(ctx context.Context, conn Conn) (Status, error) {
+	raw, err := conn.Read(ctx, StatusUUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read provisioning status: %w", err)
+	}
+	if len(raw) == 0 {
+		return 0, fmt.Errorf("empty provisioning status")
+	}
+	return Status(raw[0]), nil
+}
+
+// Provision writes credentials to a connected device and waits until it
+// has joined Wi-Fi. The device keeps the config service open after a
+// failure, so Provision can be retried on the same connection.
+func Provision(ctx context.Context, conn Conn, creds Credentials) (*DeviceInfo, error) {
+	if err := creds.Validate(); err != nil {
+		return nil, err
+	}
+
+	info, err := ReadInfo(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Firmware built without a code unlocks on any proof
+	if err := conn.Write(ctx, ProofUUID, []byte(creds.Proof)); err != nil {
+		return nil, fmt.Errorf("failed to write provisioning code: %w", err)
+	}
+	status, err := ReadStatus(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if status == StatusRejected || status == StatusLocked {
+		return nil, ErrRejected
+	}
+
+	writes := []struct {
+		characteristic UUID
+		name           string
+		value          string
+	}{
+		{SSIDUUID, "SSID", creds.SSID},
+		{PassphraseUUID, "passphrase", creds.Passphrase},
+		{TokenUUID, "device token", creds.Token},
+	}
+	for _, w := range writes {
+		if err := conn.Write(ctx, w.characteristic, []byte(w.value)); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", w.name, err)
+		}
+	}
+	if err := conn.Write(ctx, ControlUUID, []byte{controlApply}); err != nil {
+		return nil, fmt.Errorf("failed to apply credentials: %w", err)
+	}
+
+	// The device reports ready until it picks up the apply command
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := ReadStatus(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case StatusConnected:
+			return info, nil
+		case StatusWiFiFailed:
+			return nil, fmt.Errorf("%w %q", ErrWiFiFailed, creds.SSID)
+		case StatusRejected, StatusLocked:
+			return nil, ErrRejected
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("device did not join Wi-Fi (last status %s): %w", status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ble
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevice mimics the firmware's config service state machine
+type fakeDevice struct {
+	info    DeviceInfo
+	code    string
+	status  Status
+	outcome Status
+	written map[UUID]string
+}
+
+func newFakeDevice(code string) *fakeDevice {
+	return &fakeDevice{
+		info:    DeviceInfo{Protocol: ProtocolVersion, DeviceID: "greenhouse-01", FirmwareHash: "9b1c", MAC: "24:6F:28:AA:BB:CC"},
+		code:    code,
+		outcome: StatusConnected,
+		written: make(map[UUID]string),
+	}
+}
+
+func (d *fakeDevice) Read(ctx context.Context, characteristic UUID) ([]byte, error) {
+	switch characteristic {
+	case InfoUUID:
+		return json.Marshal(d.info)
+	case StatusUUID:
+		status := d.status
+		if status == StatusConnecting {
+			d.status = d.outcome
+		}
+		return []byte{byte(status)}, nil
+	}
+	return nil, &ATTError{Opcode: attOpReadRequest, Code: 0x02}
+}
+
+func (d *fakeDevice) Write(ctx context.Context, characteristic UUID, value []byte) error {
+	switch characteristic {
+	case ProofUUID:
+		if d.code == "" || string(value) == d.code {
+			d.status = StatusReady
+		} else {
+			d.status = StatusRejected
+		}
+		return nil
+	case ControlUUID:
+		if d.status != StatusReady || len(value) != 1 || value[0] != controlApply {
+			return &ATTError{Opcode: attOpWriteRequest, Code: 0x03}
+		}
+		d.status = StatusConnecting
+		return nil
+	}
+	if d.status != StatusReady {
+		return &ATTError{Opcode: attOpWriteRequest, Code: 0x03}
+	}
+	d.written[characteristic] = string(value)
+	return nil
+}
+
+func (d *fakeDevice) Close() error {
+	return nil
+}
+
+func testCredentials() Credentials {
+	return Credentials{SSID: "greenhouse", Passphrase: "correct horse", Token: "tok_abc123", Proof: "4711"}
+}
+
+func TestProvision(t *testing.T) {
+	device := newFakeDevice("4711")
+
+	info, err := Provision(context.Background(), device, testCredentials())
+	require.NoError(t, err)
+	assert.Equal(t, "greenhouse-01", info.DeviceID)
+	assert.Equal(t, "9b1c", info.FirmwareHash)
+	assert.Equal(t, map[UUID]string{
+		SSIDUUID:       "greenhouse",
+		PassphraseUUID: "correct horse",
+		TokenUUID:      "tok_abc123",
+	}, device.written)
+}
+
+func TestProvisionWithoutCode(t *testing.T) {
+	device := newFakeDevice("")
+	creds := testCredentials()
+	creds.Proof = ""
+
+	_, err := Provision(context.Background(), device, creds)
+	require.NoError(t, err)
+}
+
+func TestProvisionRejected(t *testing.T) {
+	device := newFakeDevice("0000")
+
+	_, err := Provision(context.Background(), device, testCredentials())
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.Empty(t, device.written)
+}
+
+func TestProvisionWiFiFailed(t *testing.T) {
+	device := newFakeDevice("4711")
+	device.outcome = StatusWiFiFailed
+
+	_, err := Provision(context.Background(), device, testCredentials())
+	assert.ErrorIs(t, err, ErrWiFiFailed)
+	assert.Contains(t, err.Error(), `"greenhouse"`)
+}
+
+func TestProvisionTimeout(t *testing.T) {
+	device := newFakeDevice("4711")
+	device.outcome = StatusConnecting
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := Provision(ctx, device, testCredentials())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "last status connecting")
+}
+
+func TestProvisionProtocolMismatch(t *testing.T) {
+	device := newFakeDevice("4711")
+	device.info.Protocol = ProtocolVersion + 1
+
+	_, err := Provision(context.Background(), device, testCredentials())
+	assert.ErrorContains(t, err, "provisioning protocol")
+}
+
+func TestCredentialsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Credentials)
+		err    string
+	}{
+		{"valid", func(c *Credentials) {}, ""},
+		{"open network", func(c *Credentials) { c.Passphrase = "" }, ""},
+		{"missing SSID", func(c *Credentials) { c.SSID = "" }, "SSID"},
+		{"long SSID", func(c *Credentials) { c.SSID = strings.Repeat("s", 33) }, "SSID"},
+		{"short passphrase", func(c *Credentials) { c.Passphrase = "short" }, "passphrase"},
+		{"missing token", func(c *Credentials) { c.Token = "" }, "token"},
+		{"long code", func(c *Credentials) { c.Proof = strings.Repeat("1", 65) }, "provisioning code"},
+		{"invalid UTF-8", func(c *Credentials) { c.SSID = "\xff" }, "UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := testCredentials()
+			tt.modify(&creds)
+			err := creds.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+//go:build linux
+
+package ble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Socket options missing from x/sys
+const (
+	hciFilter        = 2
+	btSecurity       = 4
+	btSecurityMedium = 2
+)
+
+// HCI packets and commands used for scanning
+const (
+	hciCommandPacket = 0x01
+	hciEventPacket   = 0x04
+
+	hciEventCommandComplete = 0x0E
+	hciEventCommandStatus   = 0x0F
+
+	hciReadBDAddr        = 0x1009
+	hciLESetScanParams   = 0x200B
+	hciLESetScanEnable   = 0x200C
+	hciStatusDisallowed  = 0x0C
+	hciCommandTimeout    = 2 * time.Second
+	hciReadPollInterval  = 200 * time.Millisecond
+	attCID               = 4
+	scanIntervalSlots    = 0x0060
+	scanWindowSlots      = 0x0030
+	defaultConnectWindow = 20 * time.Second
+)
+
+// hciTransport talks to a local adapter through BlueZ kernel sockets.
+// Scanning needs CAP_NET_RAW; connecting does not.
+type hciTransport struct {
+	adapter int
+}
+
+// NewTransport returns a transport for the local adapter hciN
+func NewTransport(adapter int) Transport {
+	return &hciTransport{adapter: adapter}
+}
+
+// Scan actively scans until ctx is done and returns the devices that
+// advertised the config service
+func (t *hciTransport) Scan(ctx context.Context) ([]Peripheral, error) {
+	fd, err := t.openHCI()
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	// Scanning may already be off, or on by bluetoothd; neither is an error
+	_ = t.command(fd, hciLESetScanEnable, []byte{0x00, 0x00}, hciStatusDisallowed)
+	params := []byte{0x01, 0, 0, 0, 0, 0x00, 0x00}
+	binary.LittleEndian.PutUint16(params[1:3], scanIntervalSlots)
+	binary.LittleEndian.PutUint16(params[3:5], scanWindowSlots)
+	if err := t.command(fd, hciLESetScanParams, params); err != nil {
+		return nil, fmt.Errorf("failed to set scan parameters: %w", err)
+	}
+	if err := t.command(fd, hciLESetScanEnable, []byte{0x01, 0x00}); err != nil {
+		return nil, fmt.Errorf("failed to start scanning: %w", err)
+	}
+	defer func() { _ = t.command(fd, hciLESetScanEnable, []byte{0x00, 0x00}, hciStatusDisallowed) }()
+
+	results := newScanResults(ServiceUUID)
+	buf := make([]byte, 512)
+	for ctx.Err() == nil {
+		n, err := unix.Read(fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("HCI read failed: %w", err)
+		}
+		if n < 3 || buf[0] != hciEventPacket || buf[1] != hciEventLEMeta {
+			continue
+		}
+		reports, err := parseAdvertisingReports(buf[3:n])
+		if err != nil {
+			continue
+		}
+		for _, report := range reports {
+			results.add(report)
+		}
+	}
+	return results.peripherals(), nil
+}
+
+// Connect opens an ATT channel to a peripheral with encryption required,
+// which pairs with devices that demand it, and discovers the config service
+func (t *hciTransport) Connect(ctx context.Context, peripheral Peripheral) (Conn, error) {
+	remote, err := parseAddress(peripheral.Address)
+	if err != nil {
+		return nil, err
+	}
+	local, err := t.localAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, unix.BTPROTO_L2CAP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open L2CAP socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrL2{CID: attCID, Addr: local, AddrType: unix.BDADDR_LE_PUBLIC}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind L2CAP socket: %w", err)
+	}
+	// struct bt_security { uint8_t level; uint8_t key_size; }
+	if err := unix.SetsockoptString(fd, unix.SOL_BLUETOOTH, btSecurity, string([]byte{btSecurityMedium, 0})); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to require encryption: %w", err)
+	}
+
+	addrType := uint8(unix.BDADDR_LE_PUBLIC)
+	if peripheral.Random {
+		addrType = unix.BDADDR_LE_RANDOM
+	}
+	connectCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		connectCtx, cancel = context.WithTimeout(ctx, defaultConnectWindow)
+		defer cancel()
+	}
+
+	// Closing the socket is the only way to abort a blocking connect
+	done := make(chan error, 1)
+	go func() {
+		done <- unix.Connect(fd, &unix.SockaddrL2{CID: attCID, Addr: remote, AddrType: addrType})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("failed to connect to %s: %w", peripheral.Address, err)
+		}
+	case <-connectCtx.Done():
+		unix.Close(fd)
+		<-done
+		return nil, fmt.Errorf("failed to connect to %s: %w", peripheral.Address, connectCtx.Err())
+	}
+
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "l2cap-att")
+	client, err := newGATTClient(ctx, file, ServiceUUID)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// openHCI opens a raw socket on the adapter that receives command
+// completions and LE meta events
+func (t *hciTransport) openHCI() (int, error) {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open HCI socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrHCI{Dev: uint16(t.adapter), Channel: unix.HCI_CHANNEL_RAW}); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to bind hci%d: %w", t.adapter, err)
+	}
+
+	// struct hci_filter { uint32_t type_mask; uint32_t event_mask[2]; uint16_t opcode; }
+	filter := make([]byte, 16)
+	binary.LittleEndian.PutUint32(filter[0:4], 1<<hciEventPacket)
+	binary.LittleEndian.PutUint32(filter[4:8], 1<<hciEventCommandComplete|1<<hciEventCommandStatus)
+	binary.LittleEndian.PutUint32(filter[8:12], 1<<(hciEventLEMeta-32))
+	if err := unix.SetsockoptString(fd, unix.SOL_HCI, hciFilter, string(filter)); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to set HCI filter (scanning needs CAP_NET_RAW): %w", err)
+	}
+	tv := unix.NsecToTimeval(hciReadPollInterval.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// command sends an HCI command and waits for its completion, treating the
+// listed status codes as success
+func (t *hciTransport) command(fd int, opcode uint16, params []byte, allowed ...byte) error {
+	_, err := t.commandResult(fd, opcode, params, allowed...)
+	return err
+}
+
+func (t *hciTransport) commandResult(fd int, opcode uint16, params []byte, allowed ...byte) ([]byte, error) {
+	pkt := []byte{hciCommandPacket, byte(opcode), byte(opcode >> 8), byte(len(params))}
+	if _, err := unix.Write(fd, append(pkt, params...)); err != nil {
+		return nil, fmt.Errorf("HCI write failed: %w", err)
+	}
+
+	buf := make([]byte, 260)
+	deadline := time.Now().Add(hciCommandTimeout)
+	for time.Now().Before(deadline) {
+		n, err := unix.Read(fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("HCI read failed: %w", err)
+		}
+		if n < 3 || buf[0] != hciEventPacket {
+			continue
+		}
+		event := buf[3:n]
+
+		var status byte
+		var result []byte
+		switch buf[1] {
+		case hciEventCommandComplete:
+			// Num packets, opcode, return parameters starting with status
+			if len(event) < 4 || binary.LittleEndian.Uint16(event[1:3]) != opcode {
+				continue
+			}
+			status, result = event[3], event[4:]
+		case hciEventCommandStatus:
+			// Status, num packets, opcode
+			if len(event) < 4 || binary.LittleEndian.Uint16(event[2:4]) != opcode {
+				continue
+			}
+			status = event[0]
+		default:
+			continue
+		}
+
+		if status == 0 {
+			return append([]byte(nil), result...), nil
+		}
+		for _, ok := range allowed {
+			if status == ok {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("HCI command 0x%04x failed with status 0x%02x", opcode, status)
+	}
+	return nil, fmt.Errorf("HCI command 0x%04x timed out", opcode)
+}
+
+// localAddress reads the adapter's public address in display order
+func (t *hciTransport) localAddress() ([6]byte, error) {
+	var addr [6]byte
+	fd, err := t.openHCI()
+	if err != nil {
+		return addr, err
+	}
+	defer unix.Close(fd)
+
+	result, err := t.commandResult(fd, hciReadBDAddr, nil)
+	if err != nil {
+		return addr, fmt.Errorf("failed to read hci%d address: %w", t.adapter, err)
+	}
+	if len(result) < len(addr) {
+		return addr, fmt.Errorf("short hci%d address", t.adapter)
+	}
+	for i := range addr {
+		addr[i] = result[len(addr)-1-i]
+	}
+	return addr, nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package ble
+
+import "context"
+
+// unsupportedTransport is used where no kernel Bluetooth sockets exist
+type unsupportedTransport struct{}
+
+// NewTransport returns a transport that reports ErrUnsupported; BLE
+// provisioning currently needs BlueZ on Linux
+func NewTransport(adapter int) Transport {
+	return unsupportedTransport{}
+}
+
+func (unsupportedTransport) Scan(ctx context.Context) ([]Peripheral, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupportedTransport) Connect(ctx context.Context, peripheral Peripheral) (Conn, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+)
+
+// DeviceRepository is a device.Repository that reads device records
+// through a Store and invalidates them on every write. Listings and
+// queries go straight to the underlying repository.
+//
+// Records are cached in their storage form, which keeps fields the JSON
+// API omits. Writes made by services that do not share the store are seen
+// once the entry expires.
+type DeviceRepository struct {
+	device.Repository
+	store Store
+	ttl   time.Duration
+}
+
+// NewDeviceRepository wraps repo with a read-through cache. A zero ttl
+// disables caching.
+func NewDeviceRepository(repo device.Repository, store Store, ttl time.Duration) *DeviceRepository {
+	return &DeviceRepository{Repository: repo, store: store, ttl: ttl}
+}
+
+// GetDevice returns a device from the cache, loading it on a miss
+func (r *DeviceRepository) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	if r.ttl <= 0 {
+		return r.Repository.GetDevice(ctx, deviceID)
+	}
+
+	key := GenerateDeviceRecordKey(deviceID)
+	var entity device.DeviceEntity
+	if err := r.store.Get(ctx, key, &entity); err == nil {
+		if d, err := entity.FromEntity(); err == nil {
+			return d, nil
+		}
+	}
+
+	d, err := r.Repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if stored, err := d.ToEntity(); err == nil {
+		// A failed cache write only costs the next lookup a read
+		_ = r.store.Set(ctx, key, stored, r.ttl)
+	}
+	return d, nil
+}
+
+// RegisterDevice registers a device and drops any cached record for it
+func (r *DeviceRepository) RegisterDevice(ctx context.Context, d *device.Device) error {
+	defer r.invalidate(ctx, d.DeviceID)
+	return r.Repository.RegisterDevice(ctx, d)
+}
+
+// UpdateDevice updates a device and drops its cached record
+func (r *DeviceRepository) UpdateDevice(ctx context.Context, d *device.Device) error {
+	defer r.invalidate(ctx, d.DeviceID)
+	return r.Repository.UpdateDevice(ctx, d)
+}
+
+// DeleteDevice deletes a device and drops its cached record
+func (r *DeviceRepository) DeleteDevice(ctx context.Context, deviceID string) error {
+	defer r.invalidate(ctx, deviceID)
+	return r.Repository.DeleteDevice(ctx, deviceID)
+}
+
+// UpdateDeviceStatus updates a device's status and drops its cached record
+func (r *DeviceRepository) UpdateDeviceStatus(ctx context.Context, deviceID string, status device.DeviceStatus, lastSeen time.Time) error {
+	defer r.invalidate(ctx, deviceID)
+	return r.Repository.UpdateDeviceStatus(ctx, deviceID, status, lastSeen)
+}
+
+func (r *DeviceRepository) invalidate(ctx context.Context, deviceID string) {
+	if r.ttl > 0 {
+		_ = r.store.Delete(ctx, GenerateDeviceRecordKey(deviceID))
+	}
+}
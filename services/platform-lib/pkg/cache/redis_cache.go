@@ -228,6 +228,9 @@ const (
 	DeviceKeyPrefix   = "device:"
 	UserKeyPrefix     = "user:"
 	SessionKeyPrefix  = "session:"
+
+	DeviceRecordKeyPrefix = "device-record:"
+	ReleaseKeyPrefix      = "release:"
 )
 
 // GenerateTemplateKey generates a cache key for templates
@@ -249,3 +252,13 @@ func GenerateUserKey(userID string) string {
 func GenerateSessionKey(sessionID string) string {
 	return fmt.Sprintf("%s%s", SessionKeyPrefix, sessionID)
 }
+
+// GenerateDeviceRecordKey generates a cache key for stored device records
+func GenerateDeviceRecordKey(deviceID string) string {
+	return fmt.Sprintf("%s%s", DeviceRecordKeyPrefix, deviceID)
+}
+
+// GenerateReleaseKey generates a cache key for firmware releases
+func GenerateReleaseKey(releaseID string) string {
+	return fmt.Sprintf("%s%s", ReleaseKeyPrefix, releaseID)
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// Store is a key-value cache with per-entry expiry. Get returns ErrCacheMiss
+// for missing and expired keys. Values are stored encoded, so callers get
+// their own copy and may modify it.
+type Store interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStoreFromConfig returns the store selected by cache.backend: Redis when
+// it is "redis" and reachable, otherwise an in-memory store
+func NewStoreFromConfig(cfg *config.Config, log *logger.Logger) Store {
+	if cfg.Cache.Backend == "redis" {
+		redisCache, err := NewCache(cfg, *log)
+		if err == nil {
+			return redisCache
+		}
+		log.Warn("Falling back to in-memory cache", "error", err)
+	}
+	return NewMemoryStore(cfg.Cache.MaxEntries)
+}
+
+// defaultMaxEntries bounds a memory store created without a limit
+const defaultMaxEntries = 10000
+
+// MemoryStore is a Store held in process memory. When full, expired entries
+// are dropped first and then arbitrary ones.
+type MemoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates an in-memory store holding up to maxEntries values
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &MemoryStore{
+		entries:    make(map[string]memoryEntry),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get decodes the value stored under key into dest
+func (s *MemoryStore) Get(ctx context.Context, key string, dest interface{}) error {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if ok && !s.now().Before(entry.expires) {
+		delete(s.entries, key)
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		return ErrCacheMiss
+	}
+
+	if err := json.Unmarshal(entry.data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal cache value: %w", err)
+	}
+	return nil
+}
+
+// Set stores value under key until expiration has passed
+func (s *MemoryStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache value: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		s.evict(now)
+	}
+	s.entries[key] = memoryEntry{data: data, expires: now.Add(expiration)}
+	return nil
+}
+
+// Delete removes key
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet dropped
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// evict makes room for one entry. The caller holds the lock.
+func (s *MemoryStore) evict(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	for key := range s.entries {
+		if len(s.entries) < s.maxEntries {
+			return
+		}
+		delete(s.entries, key)
+	}
+}
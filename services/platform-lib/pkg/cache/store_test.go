@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	require.NoError(t, store.Set(ctx, "a", map[string]int{"x": 1}, time.Minute))
+	var value map[string]int
+	require.NoError(t, store.Get(ctx, "a", &value))
+	assert.Equal(t, map[string]int{"x": 1}, value)
+
+	// Callers get their own copy
+	value["x"] = 2
+	require.NoError(t, store.Get(ctx, "a", &value))
+	assert.Equal(t, 1, value["x"])
+
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, store.Get(ctx, "a", &value), ErrCacheMiss)
+
+	// A full store makes room for new keys
+	require.NoError(t, store.Set(ctx, "b", 1, time.Hour))
+	require.NoError(t, store.Set(ctx, "c", 2, time.Hour))
+	require.NoError(t, store.Set(ctx, "d", 3, time.Hour))
+	assert.Equal(t, 2, store.Len())
+	var n int
+	require.NoError(t, store.Get(ctx, "d", &n))
+	assert.Equal(t, 3, n)
+
+	require.NoError(t, store.Delete(ctx, "d"))
+	assert.ErrorIs(t, store.Get(ctx, "d", &n), ErrCacheMiss)
+}
+
+// countingDevices is a device repository that counts lookups
+type countingDevices struct {
+	device.Repository
+	devices map[string]*device.Device
+	gets    int
+}
+
+func (r *countingDevices) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	r.gets++
+	d := *r.devices[deviceID]
+	return &d, nil
+}
+
+func (r *countingDevices) UpdateDevice(ctx context.Context, d *device.Device) error {
+	r.devices[d.DeviceID] = d
+	return nil
+}
+
+func TestDeviceRepository_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	backing := &countingDevices{devices: map[string]*device.Device{
+		"device-1": {
+			DeviceID:   "device-1",
+			TemplateID: "greenhouse",
+			Credentials: map[string]*device.Credential{
+				"mqtt": {Type: device.CredentialTypeToken, Pending: &device.PendingCredential{Version: 2, Material: "secret"}},
+			},
+		},
+	}}
+	repo := NewDeviceRepository(backing, NewMemoryStore(0), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		d, err := repo.GetDevice(ctx, "device-1")
+		require.NoError(t, err)
+		assert.Equal(t, "greenhouse", d.TemplateID)
+		// Fields the JSON API omits survive the cache
+		assert.Equal(t, "secret", d.Credentials["mqtt"].Pending.Material)
+	}
+	assert.Equal(t, 1, backing.gets)
+
+	// Writes invalidate the cached record
+	d, err := repo.GetDevice(ctx, "device-1")
+	require.NoError(t, err)
+	d.TemplateID = "weather-station"
+	require.NoError(t, repo.UpdateDevice(ctx, d))
+	d, err = repo.GetDevice(ctx, "device-1")
+	require.NoError(t, err)
+	assert.Equal(t, "weather-station", d.TemplateID)
+	assert.Equal(t, 2, backing.gets)
+
+	// A zero TTL reads through every time
+	uncached := NewDeviceRepository(backing, NewMemoryStore(0), 0)
+	_, err = uncached.GetDevice(ctx, "device-1")
+	require.NoError(t, err)
+	_, err = uncached.GetDevice(ctx, "device-1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, backing.gets)
+}
@@ -0,0 +1,352 @@
+// Package cbor encodes and decodes the subset of CBOR (RFC 8949) that
+// constrained devices send: integers, floats, strings, byte strings,
+// arrays, maps with text keys, booleans and null. Tags are skipped and
+// their content decoded in place.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxDepth bounds the nesting of arrays and maps in a decoded item
+const maxDepth = 32
+
+// ErrInvalid is returned for malformed or unsupported CBOR
+var ErrInvalid = errors.New("invalid CBOR")
+
+// Major types
+const (
+	majorUnsigned = 0
+	majorNegative = 1
+	majorBytes    = 2
+	majorText     = 3
+	majorArray    = 4
+	majorMap      = 5
+	majorTag      = 6
+	majorSimple   = 7
+)
+
+// indefinite is the additional information of indefinite-length items
+const indefinite = 31
+
+// breakCode ends an indefinite-length item
+const breakCode = 0xff
+
+// Decode decodes a single CBOR data item. Unsigned integers decode to
+// uint64, negative integers to int64, floats to float64, byte strings to
+// []byte, text to string, arrays to []interface{} and maps to
+// map[string]interface{}. Trailing bytes are an error.
+func Decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	value, err := d.item(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalid, len(d.data)-d.pos)
+	}
+	return value, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("%w: unexpected end of data", ErrInvalid)
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("%w: unexpected end of data", ErrInvalid)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head reads the major type and argument of the next item. For
+// indefinite-length items info is 31 and the argument is unused.
+func (d *decoder) head() (major byte, argument uint64, info byte, err error) {
+	initial, err := d.byte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = initial>>5, initial&0x1f
+
+	var size uint64
+	switch {
+	case info < 24:
+		return major, uint64(info), info, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == indefinite:
+		return major, 0, info, nil
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: reserved additional information %d", ErrInvalid, info)
+	}
+
+	b, err := d.bytes(size)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	for _, x := range b {
+		argument = argument<<8 | uint64(x)
+	}
+	return major, argument, info, nil
+}
+
+func (d *decoder) item(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested deeper than %d", ErrInvalid, maxDepth)
+	}
+
+	major, argument, info, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if info == indefinite && (major == majorUnsigned || major == majorNegative || major == majorTag) {
+		return nil, fmt.Errorf("%w: indefinite length for major type %d", ErrInvalid, major)
+	}
+
+	switch major {
+	case majorUnsigned:
+		return argument, nil
+
+	case majorNegative:
+		if argument > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: negative integer out of range", ErrInvalid)
+		}
+		return -1 - int64(argument), nil
+
+	case majorBytes, majorText:
+		var b []byte
+		if info == indefinite {
+			b, err = d.chunks(major)
+		} else {
+			b, err = d.bytes(argument)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+
+	case majorArray:
+		items := []interface{}{}
+		for i := uint64(0); info == indefinite || i < argument; i++ {
+			if info == indefinite && d.atBreak() {
+				break
+			}
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+
+	case majorMap:
+		entries := make(map[string]interface{})
+		for i := uint64(0); info == indefinite || i < argument; i++ {
+			if info == indefinite && d.atBreak() {
+				break
+			}
+			key, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: map key %v is not text", ErrInvalid, key)
+			}
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[name] = value
+		}
+		return entries, nil
+
+	case majorTag:
+		return d.item(depth + 1)
+
+	default:
+		return d.simple(argument, info)
+	}
+}
+
+// atBreak consumes the break code ending an indefinite-length item
+func (d *decoder) atBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == breakCode {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// chunks joins the definite-length chunks of an indefinite-length string
+func (d *decoder) chunks(major byte) ([]byte, error) {
+	var joined []byte
+	for !d.atBreak() {
+		chunkMajor, length, info, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || info == indefinite {
+			return nil, fmt.Errorf("%w: invalid string chunk", ErrInvalid)
+		}
+		b, err := d.bytes(length)
+		if err != nil {
+			return nil, err
+		}
+		joined = append(joined, b...)
+	}
+	return joined, nil
+}
+
+func (d *decoder) simple(argument uint64, info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(argument)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(argument))), nil
+	case 27:
+		return math.Float64frombits(argument), nil
+	case indefinite:
+		return nil, fmt.Errorf("%w: unexpected break", ErrInvalid)
+	}
+	return nil, fmt.Errorf("%w: unsupported simple value %d", ErrInvalid, argument)
+}
+
+// halfToFloat converts an IEEE 754 half-precision float
+func halfToFloat(h uint16) float64 {
+	exponent := int(h>>10) & 0x1f
+	mantissa := float64(h & 0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if h&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
+
+// Encode encodes a value as CBOR. It supports nil, booleans, integers,
+// floats, strings, byte slices, []interface{} and maps with string keys,
+// whose keys are written in sorted order.
+func Encode(value interface{}) ([]byte, error) {
+	return appendItem(nil, value)
+}
+
+func appendHead(buf []byte, major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return append(buf, major<<5|byte(argument))
+	case argument <= math.MaxUint8:
+		return append(buf, major<<5|24, byte(argument))
+	case argument <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major<<5|25), uint16(argument))
+	case argument <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major<<5|26), uint32(argument))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major<<5|27), argument)
+}
+
+func appendInt(buf []byte, value int64) []byte {
+	if value < 0 {
+		return appendHead(buf, majorNegative, uint64(-1-value))
+	}
+	return appendHead(buf, majorUnsigned, uint64(value))
+}
+
+func appendItem(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, majorSimple<<5|22), nil
+	case bool:
+		if v {
+			return append(buf, majorSimple<<5|21), nil
+		}
+		return append(buf, majorSimple<<5|20), nil
+	case int:
+		return appendInt(buf, int64(v)), nil
+	case int32:
+		return appendInt(buf, int64(v)), nil
+	case int64:
+		return appendInt(buf, v), nil
+	case uint:
+		return appendHead(buf, majorUnsigned, uint64(v)), nil
+	case uint32:
+		return appendHead(buf, majorUnsigned, uint64(v)), nil
+	case uint64:
+		return appendHead(buf, majorUnsigned, v), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(buf, majorSimple<<5|26), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, majorSimple<<5|27), math.Float64bits(v)), nil
+	case string:
+		return append(appendHead(buf, majorText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendHead(buf, majorBytes, uint64(len(v))), v...), nil
+	case []interface{}:
+		buf = appendHead(buf, majorArray, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if buf, err = appendItem(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf = appendHead(buf, majorMap, uint64(len(v)))
+		for _, key := range keys {
+			buf = append(appendHead(buf, majorText, uint64(len(key))), key...)
+			var err error
+			if buf, err = appendItem(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported type %T", value)
+}
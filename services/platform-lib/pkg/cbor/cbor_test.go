@@ -0,0 +1,114 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	// Examples from RFC 8949 appendix A
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", uint64(0)},
+		{"1903e8", uint64(1000)},
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f93c00", 1.0},
+		{"f9c400", -4.0},
+		{"f90001", 5.960464477539063e-08},
+		{"fa47c35000", 100000.0},
+		{"fb3ff199999999999a", 1.1},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"83010203", []interface{}{uint64(1), uint64(2), uint64(3)}},
+		{"9f018202039f0405ffff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}},
+		{"a26161016162820203", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+		{"c11a514b67b0", uint64(1363896240)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.hex)
+			require.NoError(t, err)
+			got, err := Decode(data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, err := Decode([]byte{0xf9, 0x7c, 0x00})
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(got.(float64), 1))
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"19",       // truncated argument
+		"62616263", // truncated text
+		"a1016161", // integer map key
+		"0000",     // trailing bytes
+		"1c",       // reserved additional information
+		"ff",       // stray break
+		"5f6161ff", // text chunk in a byte string
+		"3bffffffffffffffff",
+	} {
+		data, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		_, err = Decode(data)
+		assert.ErrorIs(t, err, ErrInvalid, input)
+	}
+
+	// Deeply nested arrays are refused
+	nested := make([]byte, maxDepth+2)
+	for i := range nested {
+		nested[i] = 0x81
+	}
+	_, err := Decode(append(nested, 0x00))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestEncode(t *testing.T) {
+	value := map[string]interface{}{
+		"seq":    uint64(70000),
+		"offset": -12,
+		"points": []interface{}{
+			map[string]interface{}{"ts": int64(1700000000000), "m": map[string]interface{}{"temp": 21.5, "ok": true}},
+		},
+		"raw":  []byte{0xde, 0xad},
+		"note": nil,
+	}
+
+	data, err := Encode(value)
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"seq":    uint64(70000),
+		"offset": int64(-12),
+		"points": []interface{}{
+			map[string]interface{}{"ts": uint64(1700000000000), "m": map[string]interface{}{"temp": 21.5, "ok": true}},
+		},
+		"raw":  []byte{0xde, 0xad},
+		"note": nil,
+	}, decoded)
+
+	data, err = Encode(map[string]interface{}{"b": 1, "a": 2})
+	require.NoError(t, err)
+	assert.Equal(t, "a2616102616201", hex.EncodeToString(data))
+
+	_, err = Encode(struct{}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,438 @@
+// Package chaos injects faults into HTTP routes and MQTT topics so that
+// gateway retries, device backoff and deployment failure handling can be
+// exercised before they are needed. It is never enabled in production.
+package chaos
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultTTL retires faults nobody remembered to remove
+	defaultTTL = 15 * time.Minute
+	maxTTL     = 24 * time.Hour
+	maxLatency = 2 * time.Minute
+
+	// FaultHeader names the fault that affected a response
+	FaultHeader = "X-Athena-Fault"
+)
+
+var (
+	ErrFaultNotFound = errors.New("fault not found")
+	ErrInvalidFault  = errors.New("invalid fault")
+)
+
+// Enabled reports whether fault injection is switched on for cfg. It is
+// always off in production.
+func Enabled(cfg *config.Config) bool {
+	return cfg.Chaos.Enabled && cfg.Environment != "production"
+}
+
+// Fault is an injected latency, error or message drop. A fault applies to
+// either HTTP requests matching Route or MQTT messages matching Topic.
+type Fault struct {
+	ID string `json:"id"`
+	// Method restricts a route fault to one HTTP method
+	Method string `json:"method,omitempty"`
+	// Route is a route as registered, e.g. /api/v1/ota/updates/:deviceId, or
+	// a path prefix ending in *
+	Route string `json:"route,omitempty"`
+	// Topic is an MQTT topic filter and may use + and # wildcards
+	Topic string `json:"topic,omitempty"`
+	// LatencyMS delays matching requests or messages
+	LatencyMS int `json:"latency_ms,omitempty"`
+	// ErrorStatus fails matching requests with this status
+	ErrorStatus int `json:"error_status,omitempty"`
+	// Drop discards matching MQTT messages
+	Drop bool `json:"drop,omitempty"`
+	// Probability is the chance a matching request or message is affected
+	Probability float64 `json:"probability"`
+	// Limit retires the fault after it has been injected this many times;
+	// 0 is unlimited
+	Limit     int       `json:"limit,omitempty"`
+	Injected  int       `json:"injected"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FaultRequest creates a fault. Probability defaults to 1 and TTLSeconds to
+// 15 minutes.
+type FaultRequest struct {
+	Method      string  `json:"method,omitempty"`
+	Route       string  `json:"route,omitempty"`
+	Topic       string  `json:"topic,omitempty"`
+	LatencyMS   int     `json:"latency_ms,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+	Drop        bool    `json:"drop,omitempty"`
+	Probability float64 `json:"probability,omitempty"`
+	Limit       int     `json:"limit,omitempty"`
+	TTLSeconds  int     `json:"ttl_seconds,omitempty"`
+}
+
+func (r *FaultRequest) validate() error {
+	switch {
+	case r.Route == "" && r.Topic == "":
+		return fmt.Errorf("%w: route or topic is required", ErrInvalidFault)
+	case r.Route != "" && r.Topic != "":
+		return fmt.Errorf("%w: a fault targets a route or a topic, not both", ErrInvalidFault)
+	case r.Route != "" && !strings.HasPrefix(r.Route, "/"):
+		return fmt.Errorf("%w: route must start with /", ErrInvalidFault)
+	case r.Route != "" && r.Drop:
+		return fmt.Errorf("%w: drop only applies to topics", ErrInvalidFault)
+	case r.Topic != "" && (r.ErrorStatus != 0 || r.Method != ""):
+		return fmt.Errorf("%w: error_status and method only apply to routes", ErrInvalidFault)
+	case r.ErrorStatus != 0 && (r.ErrorStatus < 400 || r.ErrorStatus > 599):
+		return fmt.Errorf("%w: error_status must be between 400 and 599", ErrInvalidFault)
+	case r.LatencyMS < 0 || time.Duration(r.LatencyMS)*time.Millisecond > maxLatency:
+		return fmt.Errorf("%w: latency_ms must be between 0 and %d", ErrInvalidFault, maxLatency.Milliseconds())
+	case r.LatencyMS == 0 && r.ErrorStatus == 0 && !r.Drop:
+		return fmt.Errorf("%w: latency_ms, error_status or drop is required", ErrInvalidFault)
+	case r.Probability < 0 || r.Probability > 1:
+		return fmt.Errorf("%w: probability must be between 0 and 1", ErrInvalidFault)
+	case r.Limit < 0:
+		return fmt.Errorf("%w: limit must not be negative", ErrInvalidFault)
+	case r.TTLSeconds < 0 || time.Duration(r.TTLSeconds)*time.Second > maxTTL:
+		return fmt.Errorf("%w: ttl_seconds must be between 0 and %d", ErrInvalidFault, int(maxTTL.Seconds()))
+	}
+	return nil
+}
+
+// Injector holds a service's active faults and applies them
+type Injector struct {
+	service string
+	logger  *logger.Logger
+
+	mu     sync.Mutex
+	faults map[string]*Fault
+	now    func() time.Time
+	chance func() float64
+}
+
+// NewInjector creates an injector with no faults
+func NewInjector(service string, log *logger.Logger) *Injector {
+	return &Injector{
+		service: service,
+		logger:  log,
+		faults:  make(map[string]*Fault),
+		now:     time.Now,
+		chance:  mathrand.Float64,
+	}
+}
+
+// Setup enables fault injection on a service's router when cfg allows it,
+// and returns nil otherwise. Call it before registering routes so the
+// middleware sees them. The admin API is served under /admin/chaos/faults.
+func Setup(router *gin.Engine, cfg *config.Config, log *logger.Logger) *Injector {
+	if !Enabled(cfg) {
+		return nil
+	}
+
+	injector := NewInjector(cfg.ServiceName, log)
+	router.Use(injector.Middleware())
+	injector.RegisterRoutes(router.Group("/admin"))
+	log.Warn("Fault injection is enabled; do not use this configuration in production", "environment", cfg.Environment)
+	return injector
+}
+
+// Add activates a fault
+func (i *Injector) Add(req *FaultRequest) (*Fault, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	now := i.now().UTC()
+	ttl := defaultTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	probability := req.Probability
+	if probability == 0 {
+		probability = 1
+	}
+
+	fault := &Fault{
+		ID:          newFaultID(),
+		Method:      strings.ToUpper(req.Method),
+		Route:       req.Route,
+		Topic:       req.Topic,
+		LatencyMS:   req.LatencyMS,
+		ErrorStatus: req.ErrorStatus,
+		Drop:        req.Drop,
+		Probability: probability,
+		Limit:       req.Limit,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	i.mu.Lock()
+	i.faults[fault.ID] = fault
+	i.mu.Unlock()
+
+	i.logger.Warn("Fault injected", "fault_id", fault.ID, "service", i.service, "fault", fault.String())
+	copied := *fault
+	return &copied, nil
+}
+
+// List returns the active faults, oldest first
+func (i *Injector) List() []*Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.expireLocked()
+
+	faults := make([]*Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		copied := *fault
+		faults = append(faults, &copied)
+	}
+	sort.Slice(faults, func(a, b int) bool { return faults[a].CreatedAt.Before(faults[b].CreatedAt) })
+	return faults
+}
+
+// Remove deactivates a fault
+func (i *Injector) Remove(id string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.faults[id]; !ok {
+		return ErrFaultNotFound
+	}
+	delete(i.faults, id)
+	return nil
+}
+
+// Clear deactivates every fault
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = make(map[string]*Fault)
+}
+
+// pick returns a copy of the first active fault that matches and is drawn
+// by its probability, counting the injection
+func (i *Injector) pick(matches func(*Fault) bool) *Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.expireLocked()
+
+	candidates := make([]*Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		if matches(fault) {
+			candidates = append(candidates, fault)
+		}
+	}
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].CreatedAt.Before(candidates[b].CreatedAt) })
+
+	for _, fault := range candidates {
+		if fault.Probability < 1 && i.chance() >= fault.Probability {
+			continue
+		}
+		fault.Injected++
+		picked := *fault
+		if fault.Limit > 0 && fault.Injected >= fault.Limit {
+			delete(i.faults, fault.ID)
+		}
+		return &picked
+	}
+	return nil
+}
+
+func (i *Injector) expireLocked() {
+	now := i.now()
+	for id, fault := range i.faults {
+		if !now.Before(fault.ExpiresAt) {
+			delete(i.faults, id)
+		}
+	}
+}
+
+// Middleware applies route faults to requests. The admin API itself is
+// never affected.
+func (i *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if strings.Contains(route, "/chaos/") {
+			c.Next()
+			return
+		}
+
+		fault := i.pick(func(f *Fault) bool {
+			return f.matchesRequest(c.Request.Method, route, c.Request.URL.Path)
+		})
+		if fault == nil {
+			c.Next()
+			return
+		}
+
+		c.Header(FaultHeader, fault.ID)
+		if !sleep(c.Request.Context(), fault.latency()) {
+			c.Abort()
+			return
+		}
+		if fault.ErrorStatus != 0 {
+			c.AbortWithStatusJSON(fault.ErrorStatus, gin.H{
+				"error":    "Injected fault",
+				"fault_id": fault.ID,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// InterceptMessage applies topic faults to an MQTT message, delaying it if
+// the fault adds latency. It returns false if the message must be dropped.
+func (i *Injector) InterceptMessage(topic string) bool {
+	fault := i.pick(func(f *Fault) bool { return f.matchesTopic(topic) })
+	if fault == nil {
+		return true
+	}
+
+	sleep(context.Background(), fault.latency())
+	if fault.Drop {
+		i.logger.Debug("Fault dropped MQTT message", "fault_id", fault.ID, "topic", topic)
+		return false
+	}
+	return true
+}
+
+func (f *Fault) latency() time.Duration {
+	return time.Duration(f.LatencyMS) * time.Millisecond
+}
+
+func (f *Fault) matchesRequest(method, route, path string) bool {
+	if f.Route == "" || (f.Method != "" && f.Method != method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(f.Route, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return f.Route == route || f.Route == path
+}
+
+func (f *Fault) matchesTopic(topic string) bool {
+	return f.Topic != "" && topicMatches(f.Topic, topic)
+}
+
+func (f *Fault) String() string {
+	var effects []string
+	if f.LatencyMS > 0 {
+		effects = append(effects, fmt.Sprintf("%dms latency", f.LatencyMS))
+	}
+	if f.ErrorStatus != 0 {
+		effects = append(effects, fmt.Sprintf("status %d", f.ErrorStatus))
+	}
+	if f.Drop {
+		effects = append(effects, "drop")
+	}
+
+	target := "topic " + f.Topic
+	if f.Route != "" {
+		target = strings.TrimSpace(f.Method + " " + f.Route)
+	}
+	return fmt.Sprintf("%s on %s (p=%g)", strings.Join(effects, ", "), target, f.Probability)
+}
+
+// topicMatches reports whether an MQTT topic matches a filter
+func topicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for n, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if n >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[n] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// sleep waits for d unless the context ends first, reporting whether the
+// full delay elapsed
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// RegisterRoutes registers the fault admin API. The caller is responsible
+// for authentication.
+func (i *Injector) RegisterRoutes(router *gin.RouterGroup) {
+	faults := router.Group("/chaos/faults")
+	{
+		faults.POST("", i.CreateFault)
+		faults.GET("", i.ListFaults)
+		faults.DELETE("", i.ClearFaults)
+		faults.DELETE("/:id", i.DeleteFault)
+	}
+}
+
+// CreateFault activates a fault
+func (i *Injector) CreateFault(c *gin.Context) {
+	var req FaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	fault, err := i.Add(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, fault)
+}
+
+// ListFaults lists the active faults
+func (i *Injector) ListFaults(c *gin.Context) {
+	faults := i.List()
+	c.JSON(http.StatusOK, gin.H{"service": i.service, "faults": faults, "count": len(faults)})
+}
+
+// DeleteFault deactivates a fault
+func (i *Injector) DeleteFault(c *gin.Context) {
+	if err := i.Remove(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ClearFaults deactivates every fault
+func (i *Injector) ClearFaults(c *gin.Context) {
+	i.Clear()
+	c.Status(http.StatusNoContent)
+}
+
+func newFaultID() string {
+	raw := make([]byte, 6)
+	rand.Read(raw)
+	return "fault_" + hex.EncodeToString(raw)
+}
@@ -0,0 +1,196 @@
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, *Injector) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	injector := Setup(router, &config.Config{
+		ServiceName: "ota-service",
+		Environment: "staging",
+		Chaos:       config.ChaosConfig{Enabled: true},
+	}, logger.New("info", "ota-service"))
+	require.NotNil(t, injector)
+
+	router.GET("/api/v1/ota/updates/:deviceId", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"device_id": c.Param("deviceId")})
+	})
+	router.POST("/api/v1/ota/updates/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+	return router, injector
+}
+
+func get(router http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestEnabled(t *testing.T) {
+	assert.True(t, Enabled(&config.Config{Environment: "development", Chaos: config.ChaosConfig{Enabled: true}}))
+	assert.False(t, Enabled(&config.Config{Environment: "development"}))
+	assert.False(t, Enabled(&config.Config{Environment: "production", Chaos: config.ChaosConfig{Enabled: true}}))
+
+	router := gin.New()
+	assert.Nil(t, Setup(router, &config.Config{Environment: "production", Chaos: config.ChaosConfig{Enabled: true}}, logger.New("info", "ota-service")))
+	assert.Equal(t, http.StatusNotFound, get(router, http.MethodGet, "/admin/chaos/faults").Code)
+}
+
+func TestMiddleware_InjectsErrors(t *testing.T) {
+	router, injector := newTestRouter(t)
+
+	// Fail the first two update checks so a device has to back off and retry
+	fault, err := injector.Add(&FaultRequest{Method: "get", Route: "/api/v1/ota/updates/:deviceId", ErrorStatus: http.StatusServiceUnavailable, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, fault.Method)
+	assert.Equal(t, 1.0, fault.Probability)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		w := get(router, http.MethodGet, "/api/v1/ota/updates/dev-1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, fault.ID, w.Header().Get(FaultHeader))
+		assert.Contains(t, w.Body.String(), "Injected fault")
+	}
+	w := get(router, http.MethodGet, "/api/v1/ota/updates/dev-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(FaultHeader))
+	assert.Empty(t, injector.List())
+
+	// Other methods and routes are unaffected
+	_, err = injector.Add(&FaultRequest{Method: "POST", Route: "/api/v1/ota/*", ErrorStatus: http.StatusInternalServerError})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, get(router, http.MethodGet, "/api/v1/ota/updates/dev-1").Code)
+	assert.Equal(t, http.StatusInternalServerError, get(router, http.MethodPost, "/api/v1/ota/updates/status").Code)
+}
+
+func TestMiddleware_InjectsLatency(t *testing.T) {
+	router, injector := newTestRouter(t)
+	_, err := injector.Add(&FaultRequest{Route: "/api/v1/ota/updates/dev-1", LatencyMS: 50})
+	require.NoError(t, err)
+
+	started := time.Now()
+	assert.Equal(t, http.StatusOK, get(router, http.MethodGet, "/api/v1/ota/updates/dev-1").Code)
+	assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+
+	started = time.Now()
+	assert.Equal(t, http.StatusOK, get(router, http.MethodGet, "/api/v1/ota/updates/dev-2").Code)
+	assert.Less(t, time.Since(started), 50*time.Millisecond)
+}
+
+func TestInjector_ProbabilityAndExpiry(t *testing.T) {
+	injector := NewInjector("telemetry-service", logger.New("info", "telemetry-service"))
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	injector.now = func() time.Time { return now }
+	draws := []float64{0.1, 0.9}
+	injector.chance = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+
+	fault, err := injector.Add(&FaultRequest{Topic: "athena/devices/+/data", Drop: true, Probability: 0.5, TTLSeconds: 60})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), fault.ExpiresAt)
+
+	assert.False(t, injector.InterceptMessage("athena/devices/dev-1/data"))
+	assert.True(t, injector.InterceptMessage("athena/devices/dev-1/data"))
+	assert.True(t, injector.InterceptMessage("athena/devices/dev-1/heartbeat"))
+	assert.Equal(t, 1, injector.List()[0].Injected)
+
+	now = now.Add(time.Minute)
+	assert.Empty(t, injector.List())
+	assert.True(t, injector.InterceptMessage("athena/devices/dev-1/data"))
+}
+
+func TestTopicMatches(t *testing.T) {
+	for _, tc := range []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"athena/devices/+/data", "athena/devices/dev-1/data", true},
+		{"athena/devices/+/data", "athena/devices/dev-1/log", false},
+		{"athena/devices/#", "athena/devices/dev-1/ota/status", true},
+		{"athena/devices/dev-1/data", "athena/devices/dev-1/data", true},
+		{"athena/devices/+", "athena/devices/dev-1/data", false},
+		{"athena/devices/+/data/extra", "athena/devices/dev-1/data", false},
+	} {
+		assert.Equal(t, tc.match, topicMatches(tc.filter, tc.topic), "%s vs %s", tc.filter, tc.topic)
+	}
+}
+
+func TestFaultRequest_Validate(t *testing.T) {
+	for name, req := range map[string]FaultRequest{
+		"no target":            {ErrorStatus: 503},
+		"route and topic":      {Route: "/a", Topic: "a/b", LatencyMS: 10},
+		"relative route":       {Route: "api/v1", ErrorStatus: 503},
+		"drop on route":        {Route: "/a", Drop: true},
+		"status on topic":      {Topic: "a/+", ErrorStatus: 503},
+		"status out of range":  {Route: "/a", ErrorStatus: 302},
+		"no effect":            {Route: "/a"},
+		"negative latency":     {Route: "/a", LatencyMS: -1},
+		"excessive latency":    {Route: "/a", LatencyMS: 10 * 60 * 1000},
+		"probability above 1":  {Route: "/a", ErrorStatus: 503, Probability: 1.5},
+		"ttl beyond a day":     {Route: "/a", ErrorStatus: 503, TTLSeconds: 2 * 24 * 3600},
+		"negative limit":       {Route: "/a", ErrorStatus: 503, Limit: -1},
+		"method on topic only": {Topic: "a/+", Method: "GET", Drop: true},
+	} {
+		req := req
+		assert.ErrorIs(t, req.validate(), ErrInvalidFault, name)
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A catch-all fault does not lock operators out of the admin API
+	w := do(http.MethodPost, "/admin/chaos/faults", `{"route":"/*","error_status":500}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var fault Fault
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fault))
+	assert.Equal(t, http.StatusInternalServerError, get(router, http.MethodGet, "/api/v1/ota/updates/dev-1").Code)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/chaos/faults", `{"route":"/a"}`).Code)
+
+	w = do(http.MethodGet, "/admin/chaos/faults", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Service string   `json:"service"`
+		Faults  []*Fault `json:"faults"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, "ota-service", list.Service)
+	require.Len(t, list.Faults, 1)
+	assert.Equal(t, 1, list.Faults[0].Injected)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/chaos/faults/"+fault.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/chaos/faults/"+fault.ID, "").Code)
+	assert.Equal(t, http.StatusOK, get(router, http.MethodGet, "/api/v1/ota/updates/dev-1").Code)
+
+	do(http.MethodPost, "/admin/chaos/faults", `{"route":"/*","latency_ms":5}`)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/chaos/faults", "").Code)
+	w = do(http.MethodGet, "/admin/chaos/faults", "")
+	assert.Contains(t, w.Body.String(), `"count":0`)
+}
@@ -7,10 +7,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/athena/platform-lib/pkg/admin"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/loadtest"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gorilla/websocket"
 )
 
 // ServiceClient wraps HTTP calls to ATHENA microservices
@@ -33,6 +41,12 @@ func NewServiceClient(cfg *config.Config, logger *logger.Logger) *ServiceClient
 
 // doRequest performs an HTTP request with proper error handling
 func (c *ServiceClient) doRequest(ctx context.Context, method, url string, body interface{}, target interface{}) error {
+	return c.doAuthorizedRequest(ctx, method, url, "", body, target)
+}
+
+// doAuthorizedRequest performs an HTTP request carrying a bearer token, for
+// calls that go through the API gateway
+func (c *ServiceClient) doAuthorizedRequest(ctx context.Context, method, url, token string, body interface{}, target interface{}) error {
 	var reqBody io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -50,6 +64,9 @@ func (c *ServiceClient) doRequest(ctx context.Context, method, url string, body
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -78,14 +95,15 @@ type TemplateListResponse struct {
 }
 
 type Template struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Category    string            `json:"category"`
-	Tags        []string          `json:"tags"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-	Metadata    map[string]string `json:"metadata"`
+	ID              string                     `json:"id"`
+	Name            string                     `json:"name"`
+	Version         string                     `json:"version"`
+	Description     string                     `json:"description"`
+	Category        string                     `json:"category"`
+	BoardsSupported []string                   `json:"boards_supported"`
+	Provenance      *athenatemplate.Provenance `json:"provenance,omitempty"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
 }
 
 // ListTemplates calls template service to list all templates
@@ -100,20 +118,171 @@ func (c *ServiceClient) ListTemplates(ctx context.Context) ([]Template, error) {
 
 // GetTemplate retrieves a specific template by ID
 func (c *ServiceClient) GetTemplate(ctx context.Context, id string) (*Template, error) {
-	url := c.cfg.Services["template-service"] + "/api/v1/templates/" + id
+	return c.GetTemplateVersion(ctx, id, "")
+}
+
+// GetTemplateVersion retrieves a template version; an empty version means
+// the latest
+func (c *ServiceClient) GetTemplateVersion(ctx context.Context, id, version string) (*Template, error) {
+	endpoint := c.cfg.Services["template-service"] + "/api/v1/templates/" + url.PathEscape(id)
+	if version != "" {
+		endpoint += "?version=" + url.QueryEscape(version)
+	}
 	var tmpl Template
-	if err := c.doRequest(ctx, "GET", url, nil, &tmpl); err != nil {
+	if err := c.doRequest(ctx, "GET", endpoint, nil, &tmpl); err != nil {
 		return nil, err
 	}
 	return &tmpl, nil
 }
 
+// GetParameterSchema retrieves a template version's parameter schema with
+// defaults and fleet-based recommendations; an empty version means the latest
+func (c *ServiceClient) GetParameterSchema(ctx context.Context, id, version string) (*athenatemplate.ParameterSchema, error) {
+	endpoint := c.cfg.Services["template-service"] + "/api/v1/templates/" + url.PathEscape(id) + "/parameters"
+	if version != "" {
+		endpoint += "?version=" + url.QueryEscape(version)
+	}
+	var schema athenatemplate.ParameterSchema
+	if err := c.doRequest(ctx, "GET", endpoint, nil, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// ImportTemplateBundle imports a template bundle, which the template
+// service verifies against its registered publishers
+func (c *ServiceClient) ImportTemplateBundle(ctx context.Context, bundle *athenatemplate.TemplateBundle) (*Template, error) {
+	url := c.cfg.Services["template-service"] + "/api/v1/templates/import"
+	var tmpl Template
+	if err := c.doRequest(ctx, "POST", url, bundle, &tmpl); err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// DeleteTemplate deletes a template version. While devices, releases or
+// other templates use it the delete is refused unless force is set; the
+// error then wraps athenatemplate.ErrTemplateInUse and the report lists
+// what uses the version.
+func (c *ServiceClient) DeleteTemplate(ctx context.Context, id, version string, force bool) (*athenatemplate.DeletionReport, error) {
+	endpoint := c.cfg.Services["template-service"] + "/api/v1/templates/" + url.PathEscape(id) + "?version=" + url.QueryEscape(version)
+	if force {
+		endpoint += "&force=true"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var report athenatemplate.DeletionReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return &report, nil
+	case http.StatusConflict:
+		var refused struct {
+			Report *athenatemplate.DeletionReport `json:"report"`
+		}
+		if err := json.Unmarshal(data, &refused); err == nil && refused.Report != nil {
+			return refused.Report, fmt.Errorf("%w: %s version %s", athenatemplate.ErrTemplateInUse, id, version)
+		}
+	}
+	return nil, fmt.Errorf("API error: %d %s - %s", resp.StatusCode, resp.Status, string(data))
+}
+
+type PreviewRequest struct {
+	Version      string                 `json:"version,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Snippets     string                 `json:"snippets,omitempty"`
+	PreviousCode string                 `json:"previous_code,omitempty"`
+}
+
+type PreviewResponse struct {
+	TemplateID   string                 `json:"template_id"`
+	Version      string                 `json:"version"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	RenderedCode string                 `json:"rendered_code"`
+	Blocks       []string               `json:"blocks,omitempty"`
+	Locale       string                 `json:"locale,omitempty"`
+	Diff         string                 `json:"diff,omitempty"`
+	Changed      bool                   `json:"changed"`
+}
+
+// PreviewTemplate renders a template without compiling it
+func (c *ServiceClient) PreviewTemplate(ctx context.Context, id string, req *PreviewRequest) (*PreviewResponse, error) {
+	url := c.cfg.Services["template-service"] + "/api/v1/templates/" + id + "/preview"
+	var resp PreviewResponse
+	if err := c.doRequest(ctx, "POST", url, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// NLP Service methods
+
+type PlanRequest struct {
+	Request string `json:"request"`
+	Board   string `json:"board,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+}
+
+type PlanBOMItem struct {
+	Component   string `json:"component"`
+	Quantity    int    `json:"quantity"`
+	Description string `json:"description"`
+}
+
+type Plan struct {
+	TemplateID      string                 `json:"template_id"`
+	TemplateName    string                 `json:"template_name"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	BOM             []PlanBOMItem          `json:"bom"`
+	Instructions    []string               `json:"instructions"`
+	Warnings        []string               `json:"warnings"`
+	DifficultyLevel string                 `json:"difficulty_level"`
+	Locale          string                 `json:"locale,omitempty"`
+}
+
+// GeneratePlan calls NLP service to plan a project described in natural
+// language
+func (c *ServiceClient) GeneratePlan(ctx context.Context, req *PlanRequest) (*Plan, error) {
+	url := c.cfg.Services["nlp-service"] + "/api/v1/nlp/plan"
+	var resp Plan
+	if err := c.doRequest(ctx, "POST", url, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 // Provisioning Service methods
 
 type CompileRequest struct {
-	TemplateID string            `json:"template_id"`
-	Board      string            `json:"board"`
-	Parameters map[string]string `json:"parameters"`
+	TemplateID      string            `json:"template_id"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	Board           string            `json:"board"`
+	Parameters      map[string]string `json:"parameters"`
+	Overrides       map[string]string `json:"overrides,omitempty"`
+	Snippets        string            `json:"snippets,omitempty"`
+
+	Onboarding map[string]interface{} `json:"onboarding,omitempty"`
+
+	// Project is billed for the compile time, from the profile's project
+	// metadata
+	Project string `json:"project,omitempty"`
 }
 
 type CompileResponse struct {
@@ -129,8 +298,20 @@ type FlashRequest struct {
 }
 
 type FlashResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success  bool   `json:"success"`
+	Message  string `json:"message"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// FlashResult is the outcome of a flash, reported to the device service for
+// the device's debug bundle and timeline
+type FlashResult struct {
+	Success    bool      `json:"success"`
+	ArtifactID string    `json:"artifact_id,omitempty"`
+	Port       string    `json:"port,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+	Errors     []string  `json:"errors,omitempty"`
+	FlashedAt  time.Time `json:"flashed_at"`
 }
 
 // Compile calls provisioning service to compile a template
@@ -153,16 +334,26 @@ func (c *ServiceClient) Flash(ctx context.Context, req *FlashRequest) (*FlashRes
 	return &resp, nil
 }
 
+// ReportFlashResult records the outcome of flashing a device with the device
+// service
+func (c *ServiceClient) ReportFlashResult(ctx context.Context, deviceID string, result *FlashResult) error {
+	url := c.cfg.Services["device-service"] + "/api/v1/devices/" + deviceID + "/flash-result"
+	return c.doRequest(ctx, "POST", url, result, nil)
+}
+
 // Device Service methods
 
 type Device struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	Board     string            `json:"board"`
-	Status    string            `json:"status"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
-	Metadata  map[string]string `json:"metadata"`
+	ID              string            `json:"device_id"`
+	Board           string            `json:"board_type"`
+	Status          string            `json:"status"`
+	TemplateID      string            `json:"template_id"`
+	TemplateVersion string            `json:"template_version"`
+	OTAChannel      string            `json:"ota_channel"`
+	LastSeen        time.Time         `json:"last_seen"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
 }
 
 type DeviceListResponse struct {
@@ -189,12 +380,153 @@ func (c *ServiceClient) GetDevice(ctx context.Context, id string) (*Device, erro
 	return &dev, nil
 }
 
+type DeviceRegistrationRequest struct {
+	DeviceID        string                 `json:"device_id"`
+	BoardType       string                 `json:"board_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	FirmwareHash    string                 `json:"firmware_hash"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+}
+
+type RegisteredDevice struct {
+	DeviceID     string `json:"device_id"`
+	Status       string `json:"status"`
+	TemplateID   string `json:"template_id"`
+	FirmwareHash string `json:"firmware_hash"`
+}
+
+// RegisterDevice registers a provisioned device with the device service
+func (c *ServiceClient) RegisterDevice(ctx context.Context, req *DeviceRegistrationRequest) (*RegisteredDevice, error) {
+	url := c.cfg.Services["device-service"] + "/api/v1/devices"
+	var dev RegisteredDevice
+	if err := c.doRequest(ctx, "POST", url, req, &dev); err != nil {
+		return nil, err
+	}
+	return &dev, nil
+}
+
+// GetDeviceDebugBundle downloads the debug bundle for a device in the given
+// format ("json" or "zip") and returns the raw bundle bytes
+func (c *ServiceClient) GetDeviceDebugBundle(ctx context.Context, id, format string) ([]byte, error) {
+	url := c.cfg.Services["device-service"] + "/api/v1/devices/" + id + "/debug-bundle?format=" + format
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("API error: %d %s - %s", resp.StatusCode, resp.Status, string(data))
+	}
+
+	return data, nil
+}
+
+// ExpiringCredential is a device credential that expires soon or has
+// expired
+type ExpiringCredential struct {
+	DeviceID        string    `json:"device_id"`
+	Name            string    `json:"name"`
+	Type            string    `json:"type"`
+	Version         int       `json:"version"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Expired         bool      `json:"expired"`
+	RotationPending bool      `json:"rotation_pending"`
+}
+
+// ListExpiringCredentials lists device credentials expiring within a
+// duration such as "720h"; empty uses the service default
+func (c *ServiceClient) ListExpiringCredentials(ctx context.Context, within string) ([]ExpiringCredential, error) {
+	target := c.cfg.Services["device-service"] + "/api/v1/devices/credentials/expiring"
+	if within != "" {
+		target += "?within=" + url.QueryEscape(within)
+	}
+	var resp struct {
+		Credentials []ExpiringCredential `json:"credentials"`
+	}
+	if err := c.doRequest(ctx, "GET", target, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Credentials, nil
+}
+
+// CredentialRotationRequest carries a replacement credential; an empty
+// Material has the service generate a token
+type CredentialRotationRequest struct {
+	Material  string     `json:"material,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CredentialRotation is a staged credential rotation
+type CredentialRotation struct {
+	DeviceID    string    `json:"device_id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Token       string    `json:"token,omitempty"`
+}
+
+// RotateCredential stages a new credential for delivery to a device
+func (c *ServiceClient) RotateCredential(ctx context.Context, deviceID, name string, req *CredentialRotationRequest) (*CredentialRotation, error) {
+	target := c.cfg.Services["device-service"] + "/api/v1/devices/" + url.PathEscape(deviceID) +
+		"/credentials/" + url.PathEscape(name) + "/rotate"
+	var rotation CredentialRotation
+	if err := c.doRequest(ctx, "POST", target, req, &rotation); err != nil {
+		return nil, err
+	}
+	return &rotation, nil
+}
+
 // Telemetry Service methods
 
 type TelemetryMetrics struct {
-	DeviceID  string                 `json:"device_id"`
-	Timestamp time.Time              `json:"timestamp"`
-	Metrics   map[string]interface{} `json:"metrics"`
+	DeviceID string        `json:"device_id"`
+	Metrics  []MetricPoint `json:"metrics"`
+	Count    int           `json:"count"`
+}
+
+// MetricPoint is one stored reading of a metric
+type MetricPoint struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Name      string            `json:"metric_name"`
+	Value     interface{}       `json:"metric_value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// Latest returns the most recent reading of each metric
+func (m *TelemetryMetrics) Latest() []MetricPoint {
+	latest := make(map[string]MetricPoint)
+	var names []string
+	for _, point := range m.Metrics {
+		current, ok := latest[point.Name]
+		if !ok {
+			names = append(names, point.Name)
+		}
+		if !ok || point.Timestamp.After(current.Timestamp) {
+			latest[point.Name] = point
+		}
+	}
+
+	points := make([]MetricPoint, 0, len(names))
+	for _, name := range names {
+		points = append(points, latest[name])
+	}
+	return points
 }
 
 // GetTelemetryMetrics calls telemetry service to get device metrics
@@ -207,26 +539,315 @@ func (c *ServiceClient) GetTelemetryMetrics(ctx context.Context, deviceID string
 	return &metrics, nil
 }
 
+// DeviceLogLine is a log line published by a device
+type DeviceLogLine struct {
+	DeviceID  string    `json:"device_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Tag       string    `json:"tag,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// DeviceLogOptions filters device log lines. Since is an RFC 3339 time or a
+// duration such as 15m; Level is the minimum level.
+type DeviceLogOptions struct {
+	Since string
+	Level string
+	Tag   string
+	Limit int
+}
+
+func (o DeviceLogOptions) query() string {
+	query := url.Values{}
+	if o.Since != "" {
+		query.Set("since", o.Since)
+	}
+	if o.Level != "" {
+		query.Set("level", o.Level)
+	}
+	if o.Tag != "" {
+		query.Set("tag", o.Tag)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", fmt.Sprintf("%d", o.Limit))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// GetDeviceLogs calls telemetry service to get recent log lines, oldest first
+func (c *ServiceClient) GetDeviceLogs(ctx context.Context, deviceID string, opts DeviceLogOptions) ([]DeviceLogLine, error) {
+	url := c.cfg.Services["telemetry-service"] + "/api/v1/telemetry/devices/" + url.PathEscape(deviceID) + "/logs" + opts.query()
+	var resp struct {
+		Logs []DeviceLogLine `json:"logs"`
+	}
+	if err := c.doRequest(ctx, "GET", url, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// FollowDeviceLogs streams recent and then new log lines for a device until
+// the context is cancelled or the connection drops, invoking handler per line
+func (c *ServiceClient) FollowDeviceLogs(ctx context.Context, deviceID string, opts DeviceLogOptions, handler func(*DeviceLogLine)) error {
+	streamURL, err := url.Parse(c.cfg.Services["telemetry-service"] + "/api/v1/telemetry/devices/" + url.PathEscape(deviceID) + "/logs" + opts.query())
+	if err != nil {
+		return fmt.Errorf("invalid telemetry service URL: %w", err)
+	}
+	switch streamURL.Scheme {
+	case "https":
+		streamURL.Scheme = "wss"
+	default:
+		streamURL.Scheme = "ws"
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, streamURL.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to follow device logs: %d %s", resp.StatusCode, resp.Status)
+		}
+		return fmt.Errorf("failed to follow device logs: %w", err)
+	}
+	defer conn.Close()
+
+	// Close the connection when the context ends to unblock ReadJSON
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var line DeviceLogLine
+		if err := conn.ReadJSON(&line); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("device log stream closed: %w", err)
+		}
+		handler(&line)
+	}
+}
+
 // OTA Service methods
 
 type Release struct {
-	ID          string    `json:"id"`
-	Version     string    `json:"version"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	ArtifactID  string    `json:"artifact_id"`
+	ID          string            `json:"release_id"`
+	TemplateID  string            `json:"template_id"`
+	Version     string            `json:"version"`
+	Channel     string            `json:"channel"`
+	Description string            `json:"release_notes"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	BinaryHash  string            `json:"binary_hash"`
 }
 
 type ReleaseListResponse struct {
 	Releases []Release `json:"releases"`
 }
 
-// ListReleases calls OTA service to list available releases
-func (c *ServiceClient) ListReleases(ctx context.Context) ([]Release, error) {
-	url := c.cfg.Services["ota-service"] + "/api/v1/ota/releases"
+// ListReleases calls OTA service to list available releases. Each annotation
+// selector is "key=value" or "key" and all of them must match.
+func (c *ServiceClient) ListReleases(ctx context.Context, annotations ...string) ([]Release, error) {
+	url := c.cfg.Services["ota-service"] + "/api/v1/ota/releases" + annotationQuery(annotations)
 	var resp ReleaseListResponse
 	if err := c.doRequest(ctx, "GET", url, nil, &resp); err != nil {
 		return nil, err
 	}
 	return resp.Releases, nil
 }
+
+type Deployment struct {
+	ID                string            `json:"deployment_id"`
+	ReleaseID         string            `json:"release_id"`
+	Strategy          string            `json:"strategy"`
+	Status            string            `json:"status"`
+	TargetDevices     []string          `json:"target_devices"`
+	RolloutPercentage int               `json:"rollout_percentage"`
+	SuccessCount      int               `json:"success_count"`
+	FailureCount      int               `json:"failure_count"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+type DeploymentListResponse struct {
+	Deployments []Deployment `json:"deployments"`
+}
+
+// GetDeployment calls OTA service to get a deployment by ID
+func (c *ServiceClient) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+	url := c.cfg.Services["ota-service"] + "/api/v1/ota/deployments/" + id
+	var deployment Deployment
+	if err := c.doRequest(ctx, "GET", url, nil, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// ListDeployments calls OTA service to list active deployments matching the
+// annotation selectors
+func (c *ServiceClient) ListDeployments(ctx context.Context, annotations ...string) ([]Deployment, error) {
+	url := c.cfg.Services["ota-service"] + "/api/v1/ota/deployments" + annotationQuery(annotations)
+	var resp DeploymentListResponse
+	if err := c.doRequest(ctx, "GET", url, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Deployments, nil
+}
+
+func annotationQuery(annotations []string) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+	query := url.Values{"annotation": annotations}
+	return "?" + query.Encode()
+}
+
+// Load test result methods
+
+// PublishLoadTestResult records a load test result with the API gateway
+func (c *ServiceClient) PublishLoadTestResult(ctx context.Context, token string, result *loadtest.Result) (*loadtest.Result, error) {
+	url := c.cfg.Services["api-gateway"] + "/api/v1/benchmarks"
+	var recorded loadtest.Result
+	if err := c.doAuthorizedRequest(ctx, "POST", url, token, result, &recorded); err != nil {
+		return nil, err
+	}
+	return &recorded, nil
+}
+
+// CompareLoadTestResults compares a scenario's latest results for two
+// releases
+func (c *ServiceClient) CompareLoadTestResults(ctx context.Context, token, scenario, baseline, candidate string, tolerance float64) (*loadtest.Comparison, error) {
+	query := url.Values{
+		"scenario":  {scenario},
+		"baseline":  {baseline},
+		"candidate": {candidate},
+		"tolerance": {strconv.FormatFloat(tolerance, 'f', -1, 64)},
+	}
+	target := c.cfg.Services["api-gateway"] + "/api/v1/benchmarks/compare?" + query.Encode()
+	var comparison loadtest.Comparison
+	if err := c.doAuthorizedRequest(ctx, "GET", target, token, nil, &comparison); err != nil {
+		return nil, err
+	}
+	return &comparison, nil
+}
+
+// Notification stream methods
+
+// AdminTaskList is the gateway's listing of admin tasks. Unavailable names
+// services that could not be asked.
+type AdminTaskList struct {
+	Tasks       []admin.TaskInfo `json:"tasks"`
+	Unavailable []string         `json:"unavailable"`
+}
+
+// AdminJobList is the gateway's listing of admin jobs, newest first
+type AdminJobList struct {
+	Jobs        []*admin.Job `json:"jobs"`
+	Unavailable []string     `json:"unavailable"`
+}
+
+// ListAdminTasks lists the admin tasks of every service, or of one service
+func (c *ServiceClient) ListAdminTasks(ctx context.Context, token, service string) (*AdminTaskList, error) {
+	var list AdminTaskList
+	if err := c.doAuthorizedRequest(ctx, "GET", c.adminURL("/tasks", service), token, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// StartAdminTask starts a task on a service
+func (c *ServiceClient) StartAdminTask(ctx context.Context, token, service, task string, params admin.Params) (*admin.Job, error) {
+	target := c.adminURL("/tasks/"+url.PathEscape(service)+"/"+url.PathEscape(task), "")
+	var job admin.Job
+	if err := c.doAuthorizedRequest(ctx, "POST", target, token, &admin.StartRequest{Params: params}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListAdminJobs lists recent admin jobs of every service, or of one service
+func (c *ServiceClient) ListAdminJobs(ctx context.Context, token, service string) (*AdminJobList, error) {
+	var list AdminJobList
+	if err := c.doAuthorizedRequest(ctx, "GET", c.adminURL("/jobs", service), token, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetAdminJob returns an admin job of a service
+func (c *ServiceClient) GetAdminJob(ctx context.Context, token, service, id string) (*admin.Job, error) {
+	target := c.adminURL("/jobs/"+url.PathEscape(service)+"/"+url.PathEscape(id), "")
+	var job admin.Job
+	if err := c.doAuthorizedRequest(ctx, "GET", target, token, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *ServiceClient) adminURL(path, service string) string {
+	target := c.cfg.Services["api-gateway"] + "/api/v1/admin" + path
+	if service != "" {
+		target += "?service=" + url.QueryEscape(service)
+	}
+	return target
+}
+
+// WatchNotifications streams platform events from the API gateway until the
+// context is cancelled or the connection drops, invoking handler per event
+func (c *ServiceClient) WatchNotifications(ctx context.Context, token string, filter notifications.Filter, handler func(*notifications.Event)) error {
+	streamURL, err := url.Parse(c.cfg.Services["api-gateway"] + "/api/v1/notifications/stream")
+	if err != nil {
+		return fmt.Errorf("invalid gateway URL: %w", err)
+	}
+	switch streamURL.Scheme {
+	case "https":
+		streamURL.Scheme = "wss"
+	default:
+		streamURL.Scheme = "ws"
+	}
+
+	query := streamURL.Query()
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		query.Set("types", strings.Join(types, ","))
+	}
+	if len(filter.ResourceIDs) > 0 {
+		query.Set("resources", strings.Join(filter.ResourceIDs, ","))
+	}
+	streamURL.RawQuery = query.Encode()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, streamURL.String(), header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to connect to notification stream: %d %s", resp.StatusCode, resp.Status)
+		}
+		return fmt.Errorf("failed to connect to notification stream: %w", err)
+	}
+	defer conn.Close()
+
+	// Close the connection when the context ends to unblock ReadJSON
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var event notifications.Event
+		if err := conn.ReadJSON(&event); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("notification stream closed: %w", err)
+		}
+		handler(&event)
+	}
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/contract"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const contractsDir = "../../contracts"
+
+var contractTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// expect declares an interaction on a mock provider with an example
+// response built from the client's own types
+func expect(t *testing.T, provider *contract.MockProvider, description, state, method, path, query string, status int, response interface{}) {
+	t.Helper()
+	body, err := contract.Example(response)
+	require.NoError(t, err)
+	provider.AddInteraction(&contract.Interaction{
+		Description: description,
+		State:       state,
+		Request:     contract.Request{Method: method, Path: path, Query: query},
+		Response:    contract.Response{Status: status, Body: body},
+	})
+}
+
+// TestServiceClientContracts records the requests ServiceClient sends and
+// the responses it understands, and keeps the committed contracts in sync.
+// Rerun with ATHENA_UPDATE_CONTRACTS=1 after changing the client.
+func TestServiceClientContracts(t *testing.T) {
+	providers := make(map[string]*contract.MockProvider)
+	cfg := &config.Config{Services: make(map[string]string)}
+	for _, name := range []string{"template-service", "device-service", "telemetry-service", "ota-service"} {
+		provider := contract.NewMockProvider("athena-cli", name)
+		defer provider.Close()
+		providers[name] = provider
+		cfg.Services[name] = provider.URL()
+	}
+	client := NewServiceClient(cfg, logger.New("info", "athena-cli"))
+	ctx := context.Background()
+
+	t.Run("template-service", func(t *testing.T) {
+		provider := providers["template-service"]
+		template := Template{
+			ID:              "temp-sensor",
+			Name:            "Temperature Sensor",
+			Version:         "1.0.0",
+			Description:     "Reads a DS18B20 and publishes readings",
+			Category:        "sensing",
+			BoardsSupported: []string{"esp32:esp32:esp32"},
+			CreatedAt:       contractTime,
+			UpdatedAt:       contractTime,
+		}
+
+		expect(t, provider, "list templates", "template temp-sensor exists", http.MethodGet, "/api/v1/templates", "", http.StatusOK, TemplateListResponse{Templates: []Template{template}})
+		templates, err := client.ListTemplates(ctx)
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		assert.Equal(t, "temp-sensor", templates[0].ID)
+
+		expect(t, provider, "get a template", "template temp-sensor exists", http.MethodGet, "/api/v1/templates/temp-sensor", "", http.StatusOK, template)
+		got, err := client.GetTemplate(ctx, "temp-sensor")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"esp32:esp32:esp32"}, got.BoardsSupported)
+
+		expect(t, provider, "preview a template", "template temp-sensor exists", http.MethodPost, "/api/v1/templates/temp-sensor/preview", "", http.StatusOK, PreviewResponse{
+			TemplateID:   "temp-sensor",
+			Version:      "1.0.0",
+			Parameters:   map[string]interface{}{"interval_ms": 1000},
+			RenderedCode: "void loop() { delay(1000); }",
+			Changed:      true,
+		})
+		preview, err := client.PreviewTemplate(ctx, "temp-sensor", &PreviewRequest{Parameters: map[string]interface{}{"interval_ms": 1000}})
+		require.NoError(t, err)
+		assert.True(t, preview.Changed)
+	})
+
+	t.Run("device-service", func(t *testing.T) {
+		provider := providers["device-service"]
+		device := Device{
+			ID:              "dev-1",
+			Board:           "esp32:esp32:esp32",
+			Status:          "online",
+			TemplateID:      "temp-sensor",
+			TemplateVersion: "1.0.0",
+			OTAChannel:      "stable",
+			LastSeen:        contractTime,
+			Labels:          map[string]string{"site": "lab"},
+			CreatedAt:       contractTime,
+			UpdatedAt:       contractTime,
+		}
+
+		expect(t, provider, "list devices", "device dev-1 exists", http.MethodGet, "/api/v1/devices", "", http.StatusOK, DeviceListResponse{Devices: []Device{device}})
+		devices, err := client.ListDevices(ctx)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+
+		expect(t, provider, "get a device", "device dev-1 exists", http.MethodGet, "/api/v1/devices/dev-1", "", http.StatusOK, device)
+		got, err := client.GetDevice(ctx, "dev-1")
+		require.NoError(t, err)
+		assert.Equal(t, "lab", got.Labels["site"])
+
+		expect(t, provider, "register a device", "device dev-2 does not exist", http.MethodPost, "/api/v1/devices", "", http.StatusCreated, RegisteredDevice{
+			DeviceID:     "dev-2",
+			Status:       "provisioning",
+			TemplateID:   "temp-sensor",
+			FirmwareHash: "9f86d081884c7d65",
+		})
+		registered, err := client.RegisterDevice(ctx, &DeviceRegistrationRequest{
+			DeviceID:        "dev-2",
+			BoardType:       "esp32:esp32:esp32",
+			TemplateID:      "temp-sensor",
+			TemplateVersion: "1.0.0",
+			FirmwareHash:    "9f86d081884c7d65",
+			OTAChannel:      "stable",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "dev-2", registered.DeviceID)
+
+		expect(t, provider, "report a flash result", "device dev-1 exists", http.MethodPost, "/api/v1/devices/dev-1/flash-result", "", http.StatusOK, map[string]string{
+			"message": "Flash result recorded successfully",
+		})
+		require.NoError(t, client.ReportFlashResult(ctx, "dev-1", &FlashResult{
+			Success:    true,
+			ArtifactID: "artifact-1",
+			Port:       "/dev/ttyUSB0",
+			Duration:   "12.5s",
+			FlashedAt:  contractTime,
+		}))
+	})
+
+	t.Run("telemetry-service", func(t *testing.T) {
+		provider := providers["telemetry-service"]
+
+		expect(t, provider, "get device metrics", "device dev-1 has telemetry", http.MethodGet, "/api/v1/telemetry/metrics/dev-1", "", http.StatusOK, TelemetryMetrics{
+			DeviceID: "dev-1",
+			Metrics:  []MetricPoint{{Timestamp: contractTime, Name: "temperature", Value: 21.5}},
+			Count:    1,
+		})
+		metrics, err := client.GetTelemetryMetrics(ctx, "dev-1")
+		require.NoError(t, err)
+		require.Len(t, metrics.Latest(), 1)
+
+		var logs struct {
+			Logs []DeviceLogLine `json:"logs"`
+		}
+		logs.Logs = []DeviceLogLine{{DeviceID: "dev-1", Timestamp: contractTime, Level: "ERROR", Tag: "i2c", Message: "bus timeout"}}
+		expect(t, provider, "get device logs", "device dev-1 has logs", http.MethodGet, "/api/v1/telemetry/devices/dev-1/logs", "level=warn&since=15m", http.StatusOK, logs)
+		lines, err := client.GetDeviceLogs(ctx, "dev-1", DeviceLogOptions{Since: "15m", Level: "warn"})
+		require.NoError(t, err)
+		require.Len(t, lines, 1)
+	})
+
+	t.Run("ota-service", func(t *testing.T) {
+		provider := providers["ota-service"]
+		deployment := Deployment{
+			ID:            "deploy-1",
+			ReleaseID:     "rel-1",
+			Strategy:      "immediate",
+			Status:        "active",
+			TargetDevices: []string{"dev-1"},
+			Annotations:   map[string]string{"change": "CHG-1"},
+			CreatedAt:     contractTime,
+			UpdatedAt:     contractTime,
+		}
+
+		expect(t, provider, "list releases by annotation", "release rel-1 exists", http.MethodGet, "/api/v1/ota/releases", "annotation=change%3DCHG-1", http.StatusOK, ReleaseListResponse{Releases: []Release{{
+			ID:          "rel-1",
+			TemplateID:  "temp-sensor",
+			Version:     "1.0.1",
+			Channel:     "stable",
+			Description: "Fix sensor timeout",
+			Annotations: map[string]string{"change": "CHG-1"},
+			CreatedAt:   contractTime,
+			BinaryHash:  "5e884898da280471",
+		}}})
+		releases, err := client.ListReleases(ctx, "change=CHG-1")
+		require.NoError(t, err)
+		require.Len(t, releases, 1)
+
+		expect(t, provider, "get a deployment", "deployment deploy-1 exists", http.MethodGet, "/api/v1/ota/deployments/deploy-1", "", http.StatusOK, deployment)
+		got, err := client.GetDeployment(ctx, "deploy-1")
+		require.NoError(t, err)
+		assert.Equal(t, "active", got.Status)
+
+		expect(t, provider, "list deployments by annotation", "deployment deploy-1 exists", http.MethodGet, "/api/v1/ota/deployments", "annotation=change%3DCHG-1", http.StatusOK, DeploymentListResponse{Deployments: []Deployment{deployment}})
+		deployments, err := client.ListDeployments(ctx, "change=CHG-1")
+		require.NoError(t, err)
+		require.Len(t, deployments, 1)
+	})
+
+	for _, provider := range providers {
+		c, err := provider.Contract()
+		require.NoError(t, err)
+		assert.NoError(t, contract.Sync(contractsDir, c))
+	}
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/edge"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/spf13/cobra"
+)
+
+func newEdgeCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edge",
+		Short: "Run the edge gateway agent",
+		Long: `Run on edge gateways, such as a Raspberry Pi, that collect readings from
+local sensors. Readings are buffered on disk and uploaded to the platform
+when it is reachable.`,
+	}
+
+	cmd.AddCommand(newEdgeRunCommand(cfg, logger))
+
+	return cmd
+}
+
+func newEdgeRunCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var agentConfig edge.Config
+	var listen string
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Buffer local readings and sync them to the platform",
+		Long: `Accept readings from local sensors on --listen (POST /telemetry) and
+upload them to the telemetry service as the gateway device. Readings are kept
+in --buffer-dir while the platform is unreachable and uploaded with backoff
+once it is back. GET /status reports the buffer.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if agentConfig.Token == "" {
+				agentConfig.Token = os.Getenv("ATHENA_DEVICE_TOKEN")
+			}
+			if agentConfig.Token == "" {
+				return fmt.Errorf("a device token is required (use --token or ATHENA_DEVICE_TOKEN)")
+			}
+			if agentConfig.ServerURL == "" {
+				agentConfig.ServerURL = cfg.Services["api-gateway"]
+			}
+			if agentConfig.BufferDir == "" {
+				homeDir, err := os.UserHomeDir()
+				if err != nil {
+					return fmt.Errorf("failed to find home directory: %w", err)
+				}
+				agentConfig.BufferDir = filepath.Join(homeDir, ".athena", "edge", agentConfig.DeviceID)
+			}
+
+			agent, err := edge.NewAgent(agentConfig, logger)
+			if err != nil {
+				return err
+			}
+			defer agent.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			server := &http.Server{Addr: listen, Handler: agent.Handler(), ReadHeaderTimeout: 10 * time.Second}
+			serveErr := make(chan error, 1)
+			go func() { serveErr <- server.ListenAndServe() }()
+
+			status := agent.Status()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Edge agent for %s listening on %s (%d readings buffered)\n", agentConfig.DeviceID, listen, status.Buffered)
+
+			runErr := make(chan error, 1)
+			go func() { runErr <- agent.Run(ctx) }()
+
+			select {
+			case err := <-serveErr:
+				stop()
+				<-runErr
+				return fmt.Errorf("local API failed: %w", err)
+			case <-runErr:
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&agentConfig.DeviceID, "device", "", "Device ID of the gateway")
+	cmd.Flags().StringVar(&agentConfig.Token, "token", "", "Device token of the gateway (default $ATHENA_DEVICE_TOKEN)")
+	cmd.Flags().StringVar(&agentConfig.ServerURL, "server", "", "API gateway URL (default from configuration)")
+	cmd.Flags().StringVar(&agentConfig.BufferDir, "buffer-dir", "", "Buffer directory (default ~/.athena/edge/<device>)")
+	cmd.Flags().IntVar(&agentConfig.MaxRecords, "max-records", 1000000, "Readings kept while offline before the oldest are dropped")
+	cmd.Flags().IntVar(&agentConfig.BatchSize, "batch-size", 1000, "Readings per upload")
+	cmd.Flags().DurationVar(&agentConfig.SyncInterval, "sync-interval", 10*time.Second, "Upload interval while connected")
+	cmd.Flags().DurationVar(&agentConfig.MaxBackoff, "max-backoff", 5*time.Minute, "Longest wait between retries while offline")
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:8070", "Address of the local API for sensors")
+	_ = cmd.MarkFlagRequired("device")
+
+	return cmd
+}
@@ -27,7 +27,7 @@ func NewMockServiceClient() *MockServiceClient {
 				Name:        "Basic LED Blink",
 				Description: "Simple LED blinking example",
 				Category:    "beginner",
-				Tags:        []string{"led", "basic"},
+				Version:     "1.0.0",
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			},
@@ -36,19 +36,19 @@ func NewMockServiceClient() *MockServiceClient {
 				Name:        "DHT22 Sensor",
 				Description: "Temperature and humidity sensor",
 				Category:    "sensors",
-				Tags:        []string{"dht22", "temperature", "humidity"},
+				Version:     "1.2.0",
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			},
 		},
 		devices: map[string]Device{
 			"device-001": {
-				ID:        "device-001",
-				Name:      "Arduino Uno 1",
-				Board:     "arduino:avr:uno",
-				Status:    "online",
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
+				ID:         "device-001",
+				Board:      "arduino:avr:uno",
+				Status:     "online",
+				TemplateID: "basic-led",
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
 			},
 		},
 		releases: map[string]Release{
@@ -57,18 +57,17 @@ func NewMockServiceClient() *MockServiceClient {
 				Version:     "1.0.0",
 				Description: "Initial release",
 				CreatedAt:   time.Now(),
-				ArtifactID:  "artifact-001",
+				BinaryHash:  "abc123",
 			},
 		},
 		metrics: map[string]TelemetryMetrics{
 			"device-001": {
-				DeviceID:  "device-001",
-				Timestamp: time.Now(),
-				Metrics: map[string]interface{}{
-					"temperature": 23.5,
-					"humidity":    45.2,
-					"status":      "ok",
+				DeviceID: "device-001",
+				Metrics: []MetricPoint{
+					{Timestamp: time.Now(), Name: "temperature", Value: 23.5},
+					{Timestamp: time.Now(), Name: "humidity", Value: 45.2},
 				},
+				Count: 2,
 			},
 		},
 	}
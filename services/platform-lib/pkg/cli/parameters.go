@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+)
+
+// promptParameters asks for each schema parameter not already set in params.
+// An empty answer takes the recommended value, or the default when there is
+// no recommendation, or leaves the parameter unset.
+func promptParameters(in io.Reader, out io.Writer, schema *athenatemplate.ParameterSchema, params map[string]string) (map[string]string, error) {
+	properties, _ := schema.Schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		if _, ok := params[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	answers := make(map[string]string, len(params)+len(names))
+	for key, value := range params {
+		answers[key] = value
+	}
+
+	reader := bufio.NewReader(in)
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		kind, _ := property["type"].(string)
+
+		var suggested string
+		var hints []string
+		if value, ok := schema.Defaults[name]; ok {
+			suggested = fmt.Sprint(value)
+			hints = append(hints, "default "+suggested)
+		} else if value, ok := property["default"]; ok {
+			suggested = fmt.Sprint(value)
+			hints = append(hints, "default "+suggested)
+		}
+		recommendation, recommended := schema.Recommendations[name]
+		if recommended {
+			suggested = fmt.Sprint(recommendation.Value)
+			hints = append(hints, "recommended "+suggested)
+		}
+
+		fmt.Fprintf(out, "%s", name)
+		if kind != "" {
+			fmt.Fprintf(out, " (%s)", kind)
+		}
+		if len(hints) > 0 {
+			fmt.Fprintf(out, " [%s]", strings.Join(hints, ", "))
+		}
+		fmt.Fprintln(out)
+		if description, _ := property["description"].(string); description != "" {
+			fmt.Fprintf(out, "  %s\n", description)
+		}
+		if recommended {
+			fmt.Fprintf(out, "  Recommended: %s\n", recommendation.Reason)
+		}
+		fmt.Fprint(out, "> ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = suggested
+		}
+		if answer != "" {
+			answers[name] = answer
+		}
+		if err == io.EOF {
+			fmt.Fprintln(out)
+		}
+	}
+	return answers, nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptParameters(t *testing.T) {
+	schema := &athenatemplate.ParameterSchema{
+		TemplateID: "weather",
+		Version:    "1.0.0",
+		Schema: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"dht_pin":          map[string]interface{}{"type": "integer", "description": "Data pin of the sensor"},
+				"mqtt_server":      map[string]interface{}{"type": "string"},
+				"publish_interval": map[string]interface{}{"type": "integer"},
+				"wifi_ssid":        map[string]interface{}{"type": "string"},
+			},
+		},
+		Defaults: map[string]interface{}{"dht_pin": 2, "publish_interval": 5000},
+		Recommendations: map[string]athenatemplate.ParameterRecommendation{
+			"publish_interval": {Parameter: "publish_interval", Value: 30000, Reason: "3 devices drain 5% a day", Devices: 3},
+		},
+	}
+
+	// dht_pin is given; mqtt_server is skipped, publish_interval takes the
+	// recommendation and wifi_ssid is answered
+	var out bytes.Buffer
+	answers, err := promptParameters(strings.NewReader("\n\nfarm\n"), &out, schema, map[string]string{"dht_pin": "4"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"dht_pin": "4", "publish_interval": "30000", "wifi_ssid": "farm"}, answers)
+
+	assert.NotContains(t, out.String(), "dht_pin")
+	assert.Contains(t, out.String(), "publish_interval (integer) [default 5000, recommended 30000]\n  Recommended: 3 devices drain 5% a day\n> ")
+}
@@ -223,3 +223,46 @@ func (pm *ProfileManager) UpdateCurrentProfile(updates map[string]interface{}) e
 
 	return pm.UpdateProfile(profile.Name, *profile)
 }
+
+// renderPath returns where the last rendered sketch of a template is kept
+// for a profile
+func (pm *ProfileManager) renderPath(profileName, templateID string) string {
+	return filepath.Join(filepath.Dir(pm.configPath), "renders", profileName, templateID+".ino")
+}
+
+// LoadLastRender returns the previously stored render of a template for a
+// profile, or an empty string if none exists
+func (pm *ProfileManager) LoadLastRender(profileName, templateID string) (string, error) {
+	data, err := os.ReadFile(pm.renderPath(profileName, templateID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read last render: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// SaveLastRender stores the rendered sketch of a template for a profile.
+// Renders carry parameters such as Wi-Fi passwords and device tokens, so
+// they are only readable by the user, including renders saved before.
+func (pm *ProfileManager) SaveLastRender(profileName, templateID, code string) error {
+	path := pm.renderPath(profileName, templateID)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create renders directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("failed to restrict renders directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(code), 0600); err != nil {
+		return fmt.Errorf("failed to write last render: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to restrict last render: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileManager_SaveLastRender(t *testing.T) {
+	pm := &ProfileManager{configPath: filepath.Join(t.TempDir(), "profiles.yaml")}
+
+	require.NoError(t, pm.SaveLastRender("default", "wifi-sensor", `const char* WIFI_PASSWORD = "secret";`))
+	code, err := pm.LoadLastRender("default", "wifi-sensor")
+	require.NoError(t, err)
+	assert.Contains(t, code, "secret")
+
+	path := pm.renderPath("default", "wifi-sensor")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// Renders saved with wider permissions are restricted on the next save
+	require.NoError(t, os.Chmod(filepath.Dir(path), 0755))
+	require.NoError(t, os.Chmod(path, 0644))
+	require.NoError(t, pm.SaveLastRender("default", "wifi-sensor", "void loop() {}"))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
@@ -2,12 +2,29 @@ package cli
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/ble"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/loadtest"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/athena/platform-lib/pkg/ota"
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
 	"github.com/spf13/cobra"
 )
 
@@ -31,6 +48,10 @@ and device management.`,
 	rootCmd.AddCommand(newProfileCommand(cfg, logger))
 	rootCmd.AddCommand(newTelemetryCommand(cfg, logger))
 	rootCmd.AddCommand(newOTACommand(cfg, logger))
+	rootCmd.AddCommand(newWatchCommand(cfg, logger))
+	rootCmd.AddCommand(newLoadTestCommand(cfg, logger))
+	rootCmd.AddCommand(newAdminCommand(cfg, logger))
+	rootCmd.AddCommand(newEdgeCommand(cfg, logger))
 
 	return rootCmd
 }
@@ -39,12 +60,15 @@ func newTemplateCommand(cfg *config.Config, logger *logger.Logger) *cobra.Comman
 	cmd := &cobra.Command{
 		Use:   "template",
 		Short: "Manage Arduino templates",
-		Long:  "List, inspect, and select Arduino templates for projects",
+		Long:  "List, inspect, and select Arduino templates for projects, sign and import community template bundles, and delete unused versions",
 	}
 
 	cmd.AddCommand(newTemplateListCommand(cfg, logger))
 	cmd.AddCommand(newTemplateInspectCommand(cfg, logger))
 	cmd.AddCommand(newTemplateSelectCommand(cfg, logger))
+	cmd.AddCommand(newTemplateSignCommand())
+	cmd.AddCommand(newTemplateImportCommand(cfg, logger))
+	cmd.AddCommand(newTemplateDeleteCommand(cfg, logger))
 
 	return cmd
 }
@@ -68,21 +92,14 @@ func newTemplateListCommand(cfg *config.Config, logger *logger.Logger) *cobra.Co
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintf(w, "ID\tNAME\tCATEGORY\tTAGS\tDESCRIPTION\n")
+			fmt.Fprintf(w, "ID\tNAME\tCATEGORY\tVERSION\tDESCRIPTION\n")
 			for _, tmpl := range templates {
-				tags := ""
-				if len(tmpl.Tags) > 0 {
-					tags = tmpl.Tags[0]
-					if len(tmpl.Tags) > 1 {
-						tags += "+"
-					}
-				}
 				description := tmpl.Description
 				if len(description) > 50 {
 					description = description[:47] + "..."
 				}
 				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-					tmpl.ID, tmpl.Name, tmpl.Category, tags, description)
+					tmpl.ID, tmpl.Name, tmpl.Category, tmpl.Version, description)
 			}
 			w.Flush()
 
@@ -102,27 +119,31 @@ func newTemplateInspectCommand(cfg *config.Config, logger *logger.Logger) *cobra
 			ctx := context.Background()
 
 			templateID := args[0]
-			template, err := client.GetTemplate(ctx, templateID)
+			template, err := client.GetTemplateVersion(ctx, templateID, version)
 			if err != nil {
 				return fmt.Errorf("failed to get template: %w", err)
 			}
 
 			fmt.Printf("ID: %s\n", template.ID)
 			fmt.Printf("Name: %s\n", template.Name)
+			fmt.Printf("Version: %s\n", template.Version)
 			fmt.Printf("Description: %s\n", template.Description)
 			fmt.Printf("Category: %s\n", template.Category)
 			fmt.Printf("Created: %s\n", template.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Updated: %s\n", template.UpdatedAt.Format("2006-01-02 15:04:05"))
 
-			if len(template.Tags) > 0 {
-				fmt.Printf("Tags: %s\n", template.Tags)
+			if len(template.BoardsSupported) > 0 {
+				fmt.Printf("Boards: %s\n", strings.Join(template.BoardsSupported, ", "))
 			}
-
-			if len(template.Metadata) > 0 {
-				fmt.Println("\nMetadata:")
-				for k, v := range template.Metadata {
-					fmt.Printf("  %s: %s\n", k, v)
+			if p := template.Provenance; p != nil {
+				fmt.Printf("Signature: %s\n", p.Status)
+				if p.PublisherID != "" {
+					fmt.Printf("Publisher: %s\n", publisherLabel(p))
+				}
+				if p.KeyFingerprint != "" {
+					fmt.Printf("Key: %s\n", p.KeyFingerprint)
 				}
+				fmt.Printf("Digest: %s\n", p.Digest)
 			}
 
 			return nil
@@ -172,22 +193,385 @@ func newTemplateSelectCommand(cfg *config.Config, logger *logger.Logger) *cobra.
 	return cmd
 }
 
+func newTemplateSignCommand() *cobra.Command {
+	var publisherID string
+	var keyFile string
+	var output string
+	cmd := &cobra.Command{
+		Use:   "sign [template.json]",
+		Short: "Sign a template as a bundle for publishing",
+		Long: `Sign a template definition with a publisher's RSA private key (PEM),
+producing a bundle the platform verifies on import against the publisher's
+registered public key.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read template: %w", err)
+			}
+			var tmpl athenatemplate.Template
+			if err := json.Unmarshal(data, &tmpl); err != nil {
+				return fmt.Errorf("failed to parse template: %w", err)
+			}
+			key, err := os.ReadFile(keyFile)
+			if err != nil {
+				return fmt.Errorf("failed to read key: %w", err)
+			}
+
+			bundle, err := athenatemplate.SignBundle(&tmpl, publisherID, key)
+			if err != nil {
+				return err
+			}
+			encoded, err := json.MarshalIndent(bundle, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode bundle: %w", err)
+			}
+			encoded = append(encoded, '\n')
+
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(encoded)
+				return err
+			}
+			if err := os.WriteFile(output, encoded, 0644); err != nil {
+				return fmt.Errorf("failed to write bundle: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Signed %s %s as %s: %s\n", tmpl.ID, tmpl.Version, publisherID, output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&publisherID, "publisher", "", "Publisher ID registered with the platform")
+	cmd.Flags().StringVar(&keyFile, "key", "", "Publisher RSA private key (PEM)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the bundle to this file instead of stdout")
+	cmd.MarkFlagRequired("publisher")
+	cmd.MarkFlagRequired("key")
+	return cmd
+}
+
+func newTemplateImportCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import [bundle.json]",
+		Short: "Import a template bundle",
+		Long: `Import a template bundle. The platform rejects bundles whose signature
+does not match their publisher's registered key; unsigned bundles and
+bundles from unregistered publishers are imported and flagged, unless the
+platform requires signed bundles.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read bundle: %w", err)
+			}
+			var bundle athenatemplate.TemplateBundle
+			if err := json.Unmarshal(data, &bundle); err != nil {
+				return fmt.Errorf("failed to parse bundle: %w", err)
+			}
+
+			client := NewServiceClient(cfg, logger)
+			tmpl, err := client.ImportTemplateBundle(context.Background(), &bundle)
+			if err != nil {
+				return fmt.Errorf("failed to import bundle: %w", err)
+			}
+
+			status := "unsigned"
+			if tmpl.Provenance != nil {
+				status = tmpl.Provenance.Status
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported template %s %s (%s)\n", tmpl.ID, tmpl.Version, status)
+			warnUnverifiedTemplate(cmd.ErrOrStderr(), tmpl)
+			return nil
+		},
+	}
+}
+
+func newTemplateDeleteCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var version string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "delete [id]",
+		Short: "Delete a template version",
+		Long: `Delete a template version. Versions that devices run, that firmware
+releases were built from, or that other templates include are not deleted;
+the command lists what uses them instead. --force deletes the version
+anyway and reports what is left referring to it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := NewServiceClient(cfg, logger)
+			report, err := client.DeleteTemplate(context.Background(), args[0], version, force)
+			if errors.Is(err, athenatemplate.ErrTemplateInUse) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Template %s %s is in use:\n", report.TemplateID, report.Version)
+				printTemplateReferences(cmd.ErrOrStderr(), report)
+				return fmt.Errorf("not deleted, use --force to delete it anyway")
+			}
+			if err != nil {
+				return fmt.Errorf("failed to delete template: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted template %s %s\n", report.TemplateID, report.Version)
+			if report.Forced {
+				fmt.Fprintln(cmd.OutOrStdout(), "These still refer to the deleted version:")
+				printTemplateReferences(cmd.OutOrStdout(), report)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&version, "version", "", "Template version to delete")
+	cmd.Flags().BoolVar(&force, "force", false, "Delete the version even if it is in use")
+	cmd.MarkFlagRequired("version")
+	return cmd
+}
+
+// printTemplateReferences lists what uses a template version, grouped by
+// kind
+func printTemplateReferences(w io.Writer, report *athenatemplate.DeletionReport) {
+	counts := report.Counts()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "KIND\tID\n")
+	for _, ref := range report.References {
+		fmt.Fprintf(tw, "%s\t%s\n", ref.Kind, ref.ID)
+	}
+	tw.Flush()
+
+	kinds := make([]string, 0, len(counts))
+	for kind, count := range counts {
+		kinds = append(kinds, fmt.Sprintf("%d %s(s)", count, kind))
+	}
+	sort.Strings(kinds)
+	fmt.Fprintf(w, "Total: %s\n", strings.Join(kinds, ", "))
+}
+
+// publisherLabel names a template's publisher, noting whether the platform
+// knows it
+func publisherLabel(p *athenatemplate.Provenance) string {
+	if p.PublisherName != "" {
+		return fmt.Sprintf("%s (%s)", p.PublisherName, p.PublisherID)
+	}
+	return p.PublisherID + " (not registered)"
+}
+
+// warnUnverifiedTemplate warns when a template was imported without a
+// signature from a registered publisher. Templates created on the platform
+// carry no provenance and are trusted.
+func warnUnverifiedTemplate(w io.Writer, tmpl *Template) {
+	p := tmpl.Provenance
+	if p == nil || p.Verified() {
+		return
+	}
+	switch p.Status {
+	case athenatemplate.ProvenanceUnsigned:
+		fmt.Fprintf(w, "Warning: template %s %s is unsigned; its publisher cannot be verified. Review its code before flashing devices.\n", tmpl.ID, tmpl.Version)
+	default:
+		fmt.Fprintf(w, "Warning: template %s %s is signed by %s, which is not a registered publisher. Review its code before flashing devices.\n", tmpl.ID, tmpl.Version, publisherLabel(p))
+	}
+}
+
+// checkTemplateTrust warns before a profile's template is rendered or
+// compiled if it is not verified. Lookup failures are left to the render
+// or compile request to report.
+func checkTemplateTrust(ctx context.Context, client *ServiceClient, logger *logger.Logger, profile *Profile) {
+	tmpl, err := client.GetTemplateVersion(ctx, profile.TemplateID, profile.TemplateVersion)
+	if err != nil {
+		logger.Debug("Could not check template signature", "error", err)
+		return
+	}
+	warnUnverifiedTemplate(os.Stderr, tmpl)
+}
+
 func newProvisionCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "provision",
 		Short: "Provision Arduino devices",
-		Long:  "Compile and flash Arduino devices using selected templates",
+		Long:  "Compile and flash Arduino devices using selected templates, and provision them over BLE",
 	}
 
+	cmd.AddCommand(newProvisionPreviewCommand(cfg, logger))
 	cmd.AddCommand(newProvisionCompileCommand(cfg, logger))
 	cmd.AddCommand(newProvisionFlashCommand(cfg, logger))
+	cmd.AddCommand(newProvisionBLECommand(cfg, logger))
+
+	return cmd
+}
+
+func newProvisionPreviewCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var params map[string]string
+	var blocks map[string]string
+	var snippetsFile string
+	var full bool
+	var noSave bool
+	var locale string
+	var interactive bool
+	var onboarding onboardingFlags
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Preview the rendered sketch before compiling",
+		Long:  "Render the selected template with parameters resolved and show a unified diff against the previous preview for this profile",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := NewServiceClient(cfg, logger)
+			pm, err := NewProfileManager()
+			if err != nil {
+				return fmt.Errorf("failed to initialize profile manager: %w", err)
+			}
+
+			profile, err := pm.GetCurrentProfile()
+			if err != nil {
+				return fmt.Errorf("failed to get current profile: %w", err)
+			}
+
+			if profile.TemplateID == "" {
+				return fmt.Errorf("no template selected in current profile. Use 'athena template select' first")
+			}
+
+			checkTemplateTrust(context.Background(), client, logger, profile)
+
+			previous, err := pm.LoadLastRender(profile.Name, profile.TemplateID)
+			if err != nil {
+				return err
+			}
+
+			if interactive {
+				if params, err = promptTemplateParameters(cmd, client, profile, params); err != nil {
+					return err
+				}
+			}
+
+			parameters := make(map[string]interface{}, len(params)+1)
+			for key, value := range params {
+				parameters[key] = parseParamValue(value)
+			}
+			if len(blocks) > 0 {
+				parameters["blocks"] = blocks
+			}
+			if opts := onboarding.options(cmd); opts != nil {
+				parameters["onboarding"] = opts
+			}
+			if locale != "" {
+				parameters["locale"] = locale
+			}
+
+			req := &PreviewRequest{
+				Version:      profile.TemplateVersion,
+				Parameters:   parameters,
+				PreviousCode: previous,
+			}
+			if snippetsFile != "" {
+				data, err := os.ReadFile(snippetsFile)
+				if err != nil {
+					return fmt.Errorf("failed to read snippet file: %w", err)
+				}
+				req.Snippets = string(data)
+			}
+
+			resp, err := client.PreviewTemplate(context.Background(), profile.TemplateID, req)
+			if err != nil {
+				return fmt.Errorf("failed to preview template: %w", err)
+			}
+			if locale != "" && resp.Locale == "" {
+				fmt.Fprintf(os.Stderr, "Template has no %s locale pack; using its default text\n", locale)
+			}
+
+			switch {
+			case previous == "" || full:
+				fmt.Print(resp.RenderedCode)
+			case !resp.Changed:
+				fmt.Println("Rendered sketch is unchanged since the last preview")
+			default:
+				fmt.Print(resp.Diff)
+			}
 
+			if !noSave && resp.Changed {
+				if err := pm.SaveLastRender(profile.Name, profile.TemplateID, resp.RenderedCode); err != nil {
+					logger.Warn("Failed to save render to profile", "error", err)
+				}
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().StringToStringVar(&params, "param", nil, "Template parameters (key=value)")
+	cmd.Flags().StringToStringVar(&blocks, "block", nil, "Override block code (name=code)")
+	cmd.Flags().StringVar(&snippetsFile, "snippets", "", "File of {{define \"block\"}}...{{end}} overrides")
+	cmd.Flags().BoolVar(&full, "full", false, "Print the full sketch instead of a diff")
+	cmd.Flags().BoolVar(&noSave, "no-save", false, "Do not store this render as the baseline for the next diff")
+	cmd.Flags().StringVar(&locale, "locale", "", "Render comments and instructions in this locale (e.g. de, pt-BR)")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Prompt for parameters not given with --param, offering recommended values")
+	onboarding.register(cmd)
 	return cmd
 }
 
+// promptTemplateParameters asks for the selected template's parameters that
+// were not given on the command line
+func promptTemplateParameters(cmd *cobra.Command, client *ServiceClient, profile *Profile, params map[string]string) (map[string]string, error) {
+	schema, err := client.GetParameterSchema(context.Background(), profile.TemplateID, profile.TemplateVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template parameters: %w", err)
+	}
+	return promptParameters(cmd.InOrStdin(), cmd.OutOrStdout(), schema, params)
+}
+
+// parseParamValue converts a command line parameter into a JSON value so
+// numbers and booleans validate against template schemas
+func parseParamValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		switch parsed.(type) {
+		case float64, bool:
+			return parsed
+		}
+	}
+	return value
+}
+
+// onboardingFlags holds the captive portal options shared by preview and
+// compile
+type onboardingFlags struct {
+	enabled        bool
+	portalSSID     string
+	portalPassword string
+	brandName      string
+	brandColor     string
+	logoURL        string
+	resetPin       int
+}
+
+func (f *onboardingFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.enabled, "captive-portal", false, "Add a Wi-Fi setup captive portal for self-onboarding (ESP32)")
+	cmd.Flags().StringVar(&f.portalSSID, "portal-ssid", "", "Setup access point name; {chip_id} and {device_id} are filled in")
+	cmd.Flags().StringVar(&f.portalPassword, "portal-password", "", "Setup access point password (8-63 characters, default open)")
+	cmd.Flags().StringVar(&f.brandName, "brand", "", "Brand name shown on the setup page")
+	cmd.Flags().StringVar(&f.brandColor, "brand-color", "", "Setup page accent color (e.g. #1f6feb)")
+	cmd.Flags().StringVar(&f.logoURL, "logo-url", "", "Logo shown on the setup page")
+	cmd.Flags().IntVar(&f.resetPin, "reset-pin", -1, "GPIO that re-opens the setup portal when held")
+}
+
+// options returns the onboarding options, or nil without --captive-portal
+func (f *onboardingFlags) options(cmd *cobra.Command) map[string]interface{} {
+	if !f.enabled {
+		return nil
+	}
+	opts := map[string]interface{}{"mode": "captive_portal"}
+	for key, value := range map[string]string{
+		"portal_ssid":     f.portalSSID,
+		"portal_password": f.portalPassword,
+		"brand_name":      f.brandName,
+		"brand_color":     f.brandColor,
+		"logo_url":        f.logoURL,
+	} {
+		if value != "" {
+			opts[key] = value
+		}
+	}
+	if cmd.Flags().Changed("reset-pin") {
+		opts["reset_pin"] = f.resetPin
+	}
+	return opts
+}
+
 func newProvisionCompileCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
 	var board string
 	var params map[string]string
+	var blocks map[string]string
+	var snippetsFile string
+	var interactive bool
+	var onboarding onboardingFlags
 	cmd := &cobra.Command{
 		Use:   "compile",
 		Short: "Compile the selected template",
@@ -216,11 +600,33 @@ func newProvisionCompileCommand(cfg *config.Config, logger *logger.Logger) *cobr
 				return fmt.Errorf("no board specified. Use --board flag or set it in profile")
 			}
 
+			var snippets string
+			if snippetsFile != "" {
+				data, err := os.ReadFile(snippetsFile)
+				if err != nil {
+					return fmt.Errorf("failed to read snippet file: %w", err)
+				}
+				snippets = string(data)
+			}
+
 			ctx := context.Background()
+			checkTemplateTrust(ctx, client, logger, profile)
+
+			if interactive {
+				if params, err = promptTemplateParameters(cmd, client, profile, params); err != nil {
+					return err
+				}
+			}
+
 			req := &CompileRequest{
-				TemplateID: profile.TemplateID,
-				Board:      targetBoard,
-				Parameters: params,
+				TemplateID:      profile.TemplateID,
+				TemplateVersion: profile.TemplateVersion,
+				Board:           targetBoard,
+				Parameters:      params,
+				Overrides:       blocks,
+				Snippets:        snippets,
+				Onboarding:      onboarding.options(cmd),
+				Project:         profile.Metadata["project"],
 			}
 
 			resp, err := client.Compile(ctx, req)
@@ -239,7 +645,7 @@ func newProvisionCompileCommand(cfg *config.Config, logger *logger.Logger) *cobr
 				"parameters": map[string]string{"last_artifact_id": resp.ArtifactID},
 			}
 			if err := pm.UpdateCurrentProfile(updates); err != nil {
-				logger.Warnf("Failed to save artifact ID to profile: %v", err)
+				logger.Warn("Failed to save artifact ID to profile", "error", err)
 			}
 
 			return nil
@@ -247,6 +653,10 @@ func newProvisionCompileCommand(cfg *config.Config, logger *logger.Logger) *cobr
 	}
 	cmd.Flags().StringVar(&board, "board", "", "Arduino board (e.g., arduino:avr:uno)")
 	cmd.Flags().StringToStringVar(&params, "param", nil, "Template parameters (key=value)")
+	cmd.Flags().StringToStringVar(&blocks, "block", nil, "Override block code (name=code)")
+	cmd.Flags().StringVar(&snippetsFile, "snippets", "", "File of {{define \"block\"}}...{{end}} overrides")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Prompt for parameters not given with --param, offering recommended values")
+	onboarding.register(cmd)
 	return cmd
 }
 
@@ -254,6 +664,7 @@ func newProvisionFlashCommand(cfg *config.Config, logger *logger.Logger) *cobra.
 	var port string
 	var board string
 	var artifactID string
+	var deviceID string
 	cmd := &cobra.Command{
 		Use:   "flash",
 		Short: "Flash firmware to a device",
@@ -305,9 +716,32 @@ func newProvisionFlashCommand(cfg *config.Config, logger *logger.Logger) *cobra.
 				ArtifactID: targetArtifactID,
 			}
 
-			resp, err := client.Flash(ctx, req)
-			if err != nil {
-				return fmt.Errorf("failed to flash: %w", err)
+			resp, flashErr := client.Flash(ctx, req)
+
+			// Failed flashes are reported too: they are what a debug bundle
+			// is usually pulled for
+			if deviceID != "" {
+				result := &FlashResult{
+					Success:    flashErr == nil && resp.Success,
+					ArtifactID: targetArtifactID,
+					Port:       targetPort,
+					FlashedAt:  time.Now(),
+				}
+				switch {
+				case flashErr != nil:
+					result.Errors = []string{flashErr.Error()}
+				case !resp.Success:
+					result.Errors = []string{resp.Message}
+				default:
+					result.Duration = resp.Duration
+				}
+				if err := client.ReportFlashResult(ctx, deviceID, result); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to report flash result for device %s: %v\n", deviceID, err)
+				}
+			}
+
+			if flashErr != nil {
+				return fmt.Errorf("failed to flash: %w", flashErr)
 			}
 
 			if resp.Success {
@@ -322,6 +756,172 @@ func newProvisionFlashCommand(cfg *config.Config, logger *logger.Logger) *cobra.
 	cmd.Flags().StringVar(&port, "port", "", "Serial port (e.g., COM3, /dev/ttyUSB0)")
 	cmd.Flags().StringVar(&board, "board", "", "Arduino board (e.g., arduino:avr:uno)")
 	cmd.Flags().StringVar(&artifactID, "artifact-id", "", "Artifact ID to flash")
+	cmd.Flags().StringVar(&deviceID, "device", "", "Registered device being flashed; the result is recorded for its debug bundle")
+	return cmd
+}
+
+// defaultBLEBoard is registered for BLE-provisioned devices when neither the
+// flag nor the profile names a board; the BLE firmware only targets ESP32
+const defaultBLEBoard = "esp32:esp32:devkitv1"
+
+func newProvisionBLECommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var address string
+	var randomAddress bool
+	var adapter int
+	var scanTimeout time.Duration
+	var timeout time.Duration
+	var ssid string
+	var passphrase string
+	var deviceToken string
+	var pop string
+	var board string
+	var otaChannel string
+	var labels map[string]string
+	var noRegister bool
+	cmd := &cobra.Command{
+		Use:   "ble",
+		Short: "Provision a device over Bluetooth LE",
+		Long: `Find a device running firmware built with the esp32-ble-provisioning
+template, write Wi-Fi credentials and a device token over BLE, wait for it to
+join Wi-Fi and register it with the device service. Needs a Linux host with
+BlueZ; scanning needs CAP_NET_RAW, so pass --address to skip it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if passphrase == "" {
+				passphrase = os.Getenv("ATHENA_WIFI_PASSPHRASE")
+			}
+			if deviceToken == "" {
+				deviceToken = os.Getenv("ATHENA_DEVICE_TOKEN")
+			}
+			generated := deviceToken == ""
+			if generated {
+				raw := make([]byte, 24)
+				if _, err := rand.Read(raw); err != nil {
+					return fmt.Errorf("failed to generate device token: %w", err)
+				}
+				deviceToken = base64.RawURLEncoding.EncodeToString(raw)
+			}
+
+			creds := ble.Credentials{SSID: ssid, Passphrase: passphrase, Token: deviceToken, Proof: pop}
+			if err := creds.Validate(); err != nil {
+				return err
+			}
+
+			// Resolve registration details before touching the device
+			var profile *Profile
+			if !noRegister {
+				pm, err := NewProfileManager()
+				if err != nil {
+					return fmt.Errorf("failed to initialize profile manager: %w", err)
+				}
+				profile, err = pm.GetCurrentProfile()
+				if err != nil {
+					return fmt.Errorf("failed to get current profile: %w", err)
+				}
+				if profile.TemplateID == "" {
+					return fmt.Errorf("no template selected in current profile. Use 'athena template select' first or pass --no-register")
+				}
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			transport := ble.NewTransport(adapter)
+
+			peripheral := ble.Peripheral{Address: strings.ToUpper(address), Random: randomAddress}
+			if address == "" {
+				fmt.Printf("Scanning for devices for %s...\n", scanTimeout)
+				scanCtx, cancel := context.WithTimeout(ctx, scanTimeout)
+				found, err := transport.Scan(scanCtx)
+				cancel()
+				if err != nil {
+					return fmt.Errorf("failed to scan: %w", err)
+				}
+				switch len(found) {
+				case 0:
+					return fmt.Errorf("no devices in BLE provisioning mode found")
+				case 1:
+					peripheral = found[0]
+				default:
+					w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+					fmt.Fprintf(w, "ADDRESS\tNAME\tRSSI\n")
+					for _, p := range found {
+						fmt.Fprintf(w, "%s\t%s\t%d\n", p.Address, p.Name, p.RSSI)
+					}
+					w.Flush()
+					return fmt.Errorf("found %d devices; choose one with --address", len(found))
+				}
+			}
+
+			provisionCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			fmt.Printf("Connecting to %s %s...\n", peripheral.Address, peripheral.Name)
+			conn, err := transport.Connect(provisionCtx, peripheral)
+			if err != nil {
+				return fmt.Errorf("failed to connect: %w", err)
+			}
+			defer conn.Close()
+
+			fmt.Printf("Writing credentials for %q...\n", ssid)
+			info, err := ble.Provision(provisionCtx, conn, creds)
+			if err != nil {
+				return fmt.Errorf("failed to provision device: %w", err)
+			}
+			fmt.Printf("Device %s joined Wi-Fi\n", info.DeviceID)
+			if generated {
+				fmt.Printf("Device token (MQTT password, shown once): %s\n", deviceToken)
+			}
+
+			if noRegister {
+				return nil
+			}
+
+			targetBoard := board
+			if targetBoard == "" {
+				targetBoard = profile.Board
+			}
+			if targetBoard == "" {
+				targetBoard = defaultBLEBoard
+			}
+			templateVersion := profile.TemplateVersion
+			if templateVersion == "" {
+				templateVersion = "latest"
+			}
+
+			req := &DeviceRegistrationRequest{
+				DeviceID:        info.DeviceID,
+				BoardType:       targetBoard,
+				TemplateID:      profile.TemplateID,
+				TemplateVersion: templateVersion,
+				FirmwareHash:    info.FirmwareHash,
+				OTAChannel:      otaChannel,
+				Labels:          labels,
+				Parameters: map[string]interface{}{
+					"provisioning": "ble",
+					"mac":          info.MAC,
+				},
+			}
+			dev, err := NewServiceClient(cfg, logger).RegisterDevice(ctx, req)
+			if err != nil {
+				return fmt.Errorf("device is on Wi-Fi but registration failed: %w", err)
+			}
+			fmt.Printf("Registered device %s (status: %s)\n", dev.DeviceID, dev.Status)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&address, "address", "", "Device Bluetooth address; skips scanning")
+	cmd.Flags().BoolVar(&randomAddress, "random-address", false, "The --address is a random rather than public address")
+	cmd.Flags().IntVar(&adapter, "adapter", 0, "Local Bluetooth adapter index (hciN)")
+	cmd.Flags().DurationVar(&scanTimeout, "scan-timeout", 10*time.Second, "How long to scan for devices")
+	cmd.Flags().DurationVar(&timeout, "timeout", 90*time.Second, "How long to wait for the device to connect and join Wi-Fi")
+	cmd.Flags().StringVar(&ssid, "ssid", "", "Wi-Fi network name")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "Wi-Fi passphrase (defaults to ATHENA_WIFI_PASSPHRASE)")
+	cmd.Flags().StringVar(&deviceToken, "device-token", "", "Device token (defaults to ATHENA_DEVICE_TOKEN, otherwise generated)")
+	cmd.Flags().StringVar(&pop, "pop", "", "Provisioning code the firmware was built with")
+	cmd.Flags().StringVar(&board, "board", "", "Board to register (defaults to the profile board, then "+defaultBLEBoard+")")
+	cmd.Flags().StringVar(&otaChannel, "ota-channel", "", "OTA channel to register the device on")
+	cmd.Flags().StringToStringVar(&labels, "label", nil, "Device labels (key=value)")
+	cmd.Flags().BoolVar(&noRegister, "no-register", false, "Provision the device without registering it")
+	cmd.MarkFlagRequired("ssid")
 	return cmd
 }
 
@@ -334,6 +934,9 @@ func newDeviceCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command
 
 	cmd.AddCommand(newDeviceListCommand(cfg, logger))
 	cmd.AddCommand(newDeviceGetCommand(cfg, logger))
+	cmd.AddCommand(newDeviceDebugCommand(cfg, logger))
+	cmd.AddCommand(newDeviceLogsCommand(cfg, logger))
+	cmd.AddCommand(newDeviceCredentialsCommand(cfg, logger))
 
 	return cmd
 }
@@ -357,10 +960,10 @@ func newDeviceListCommand(cfg *config.Config, logger *logger.Logger) *cobra.Comm
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintf(w, "ID\tNAME\tBOARD\tSTATUS\tUPDATED\n")
+			fmt.Fprintf(w, "ID\tBOARD\tTEMPLATE\tSTATUS\tUPDATED\n")
 			for _, dev := range devices {
 				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-					dev.ID, dev.Name, dev.Board, dev.Status,
+					dev.ID, dev.Board, dev.TemplateID, dev.Status,
 					dev.UpdatedAt.Format("2006-01-02 15:04"))
 			}
 			w.Flush()
@@ -386,15 +989,17 @@ func newDeviceGetCommand(cfg *config.Config, logger *logger.Logger) *cobra.Comma
 			}
 
 			fmt.Printf("ID: %s\n", device.ID)
-			fmt.Printf("Name: %s\n", device.Name)
 			fmt.Printf("Board: %s\n", device.Board)
 			fmt.Printf("Status: %s\n", device.Status)
+			fmt.Printf("Template: %s@%s\n", device.TemplateID, device.TemplateVersion)
+			fmt.Printf("OTA Channel: %s\n", device.OTAChannel)
+			fmt.Printf("Last Seen: %s\n", device.LastSeen.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Created: %s\n", device.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Updated: %s\n", device.UpdatedAt.Format("2006-01-02 15:04:05"))
 
-			if len(device.Metadata) > 0 {
-				fmt.Println("\nMetadata:")
-				for k, v := range device.Metadata {
+			if len(device.Labels) > 0 {
+				fmt.Println("\nLabels:")
+				for k, v := range device.Labels {
 					fmt.Printf("  %s: %s\n", k, v)
 				}
 			}
@@ -404,73 +1009,313 @@ func newDeviceGetCommand(cfg *config.Config, logger *logger.Logger) *cobra.Comma
 	}
 }
 
-func newNLPCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+func newDeviceDebugCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var format string
+	var output string
 	cmd := &cobra.Command{
-		Use:   "plan",
-		Short: "Generate implementation plan from natural language",
-		Long:  "Use natural language to describe your project and get an implementation plan",
-	}
+		Use:   "debug [id]",
+		Short: "Download a debug bundle for a device",
+		Long:  "Fetch device metadata, recent heartbeats, telemetry, alerts, OTA attempts and the last flash result as a single bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "zip" {
+				return fmt.Errorf("unsupported format %q (use json or zip)", format)
+			}
 
-	cmd.AddCommand(newNLPGenerateCommand(cfg, logger))
+			client := NewServiceClient(cfg, logger)
+			ctx := context.Background()
+
+			deviceID := args[0]
+			data, err := client.GetDeviceDebugBundle(ctx, deviceID, format)
+			if err != nil {
+				return fmt.Errorf("failed to get debug bundle: %w", err)
+			}
 
+			if output == "-" {
+				_, err := cmd.OutOrStdout().Write(data)
+				return err
+			}
+
+			if output == "" {
+				output = fmt.Sprintf("%s-debug-bundle.%s", deviceID, format)
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return fmt.Errorf("failed to write debug bundle: %w", err)
+			}
+
+			fmt.Printf("Debug bundle for device %s written to %s (%d bytes)\n", deviceID, output, len(data))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "json", "Bundle format (json or zip)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file path ('-' for stdout)")
 	return cmd
 }
 
-func newNLPGenerateCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
-	return &cobra.Command{
-		Use:   "generate [description]",
-		Short: "Generate a plan from natural language description",
-		Args:  cobra.MinimumNArgs(1),
+func newDeviceLogsCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var opts DeviceLogOptions
+	var follow bool
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "logs [id]",
+		Short: "Show log lines published by a device",
+		Long: `Print recent log lines a device published over MQTT or HTTP. With
+--follow, keep streaming new lines until interrupted.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			description := args[0]
-			for i := 1; i < len(args); i++ {
-				description += " " + args[i]
+			client := NewServiceClient(cfg, logger)
+			out := cmd.OutOrStdout()
+			encoder := json.NewEncoder(out)
+			print := func(line *DeviceLogLine) {
+				if jsonOutput {
+					encoder.Encode(line)
+					return
+				}
+				fmt.Fprintln(out, formatDeviceLogLine(line))
 			}
 
-			// For now, we'll create a simple plan without calling the NLP service
-			// In a full implementation, this would call the NLP service
-			fmt.Printf("Generating plan for: %s\n\n", description)
+			deviceID := args[0]
+			if follow {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
 
-			fmt.Println("This is a placeholder implementation.")
-			fmt.Println("In a full implementation, this would:")
-			fmt.Println("1. Parse the natural language description")
-			fmt.Println("2. Extract requirements (sensors, actuators, communication)")
-			fmt.Println("3. Select appropriate templates")
-			fmt.Println("4. Generate wiring diagram")
-			fmt.Println("5. Create bill of materials")
-			fmt.Println("6. Provide step-by-step instructions")
+				fmt.Fprintf(cmd.ErrOrStderr(), "Following logs for device %s (Ctrl+C to stop)...\n", deviceID)
+				return client.FollowDeviceLogs(ctx, deviceID, opts, print)
+			}
 
+			lines, err := client.GetDeviceLogs(context.Background(), deviceID, opts)
+			if err != nil {
+				return fmt.Errorf("failed to get device logs: %w", err)
+			}
+			if len(lines) == 0 && !jsonOutput {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No log lines found.")
+			}
+			for i := range lines {
+				print(&lines[i])
+			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming new log lines")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "Only lines newer than this (RFC 3339 time or duration such as 15m)")
+	cmd.Flags().StringVar(&opts.Level, "level", "", "Minimum level (debug, info, warn, error)")
+	cmd.Flags().StringVar(&opts.Tag, "tag", "", "Only lines with this tag")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 0, "Number of recent lines to show (default 100)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print lines as JSON")
+	return cmd
 }
 
-func newTelemetryCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+func newDeviceCredentialsCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "telemetry",
-		Short: "Access device telemetry data",
-		Long:  "Stream and monitor telemetry data from devices",
+		Use:   "credentials",
+		Short: "Track and rotate device credentials",
 	}
-
-	cmd.AddCommand(newTelemetryMetricsCommand(cfg, logger))
-
+	cmd.AddCommand(newDeviceCredentialsExpiringCommand(cfg, logger))
+	cmd.AddCommand(newDeviceCredentialsRotateCommand(cfg, logger))
 	return cmd
 }
 
-func newTelemetryMetricsCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+func newDeviceCredentialsExpiringCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var within string
 	cmd := &cobra.Command{
-		Use:   "metrics",
-		Short: "Get telemetry metrics for a device",
+		Use:   "expiring",
+		Short: "List device credentials that expire soon",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			client := NewServiceClient(cfg, logger)
-			ctx := context.Background()
-
-			deviceID, err := cmd.Flags().GetString("device")
+			credentials, err := client.ListExpiringCredentials(context.Background(), within)
 			if err != nil {
-				return fmt.Errorf("failed to get device flag: %w", err)
-			}
-			if deviceID == "" {
-				return fmt.Errorf("device ID is required. Use --device flag")
+				return fmt.Errorf("failed to list expiring credentials: %w", err)
+			}
+
+			if len(credentials) == 0 {
+				fmt.Println("No credentials expire in that time.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "DEVICE\tCREDENTIAL\tTYPE\tVERSION\tEXPIRES\tSTATE\n")
+			for _, credential := range credentials {
+				state := "expiring"
+				if credential.Expired {
+					state = "expired"
+				}
+				if credential.RotationPending {
+					state += ", rotation pending"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					credential.DeviceID, credential.Name, credential.Type, credential.Version,
+					credential.ExpiresAt.Local().Format("2006-01-02 15:04"), state)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&within, "within", "", "Expiry horizon such as 168h (default 720h)")
+	return cmd
+}
+
+func newDeviceCredentialsRotateCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var materialFile string
+	var expiresAt string
+	cmd := &cobra.Command{
+		Use:   "rotate [device-id] [credential]",
+		Short: "Replace a device credential",
+		Long: `Stage a new credential for a device. The device receives it with its next
+heartbeat and the old one is retired once the device reports the new
+version. Tokens are generated unless --material-file is given; certificates
+need a PEM file with the certificate and key.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &CredentialRotationRequest{}
+			if materialFile != "" {
+				data, err := os.ReadFile(materialFile)
+				if err != nil {
+					return fmt.Errorf("failed to read credential material: %w", err)
+				}
+				req.Material = string(data)
+			}
+			if expiresAt != "" {
+				expiry, err := time.Parse(time.RFC3339, expiresAt)
+				if err != nil {
+					return fmt.Errorf("invalid --expires-at (use RFC 3339): %w", err)
+				}
+				req.ExpiresAt = &expiry
+			}
+
+			client := NewServiceClient(cfg, logger)
+			rotation, err := client.RotateCredential(context.Background(), args[0], args[1], req)
+			if err != nil {
+				return fmt.Errorf("failed to rotate credential: %w", err)
+			}
+
+			fmt.Printf("Staged version %d of %s for device %s (expires %s)\n",
+				rotation.Version, rotation.Name, rotation.DeviceID, rotation.ExpiresAt.Local().Format("2006-01-02 15:04"))
+			if rotation.Token != "" {
+				fmt.Printf("New device token (MQTT password, shown once): %s\n", rotation.Token)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&materialFile, "material-file", "", "File holding the new token, PEM certificate and key, or WiFi password")
+	cmd.Flags().StringVar(&expiresAt, "expires-at", "", "Expiry of the new credential (RFC 3339); certificates use their own")
+	return cmd
+}
+
+// formatDeviceLogLine renders a log line like the device's serial console
+func formatDeviceLogLine(line *DeviceLogLine) string {
+	level := "?"
+	if line.Level != "" {
+		level = strings.ToUpper(line.Level[:1])
+	}
+	text := line.Timestamp.Local().Format("2006-01-02 15:04:05.000") + " " + level
+	if line.Tag != "" {
+		text += " [" + line.Tag + "]"
+	}
+	return text + " " + line.Message
+}
+
+func newNLPCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Generate implementation plan from natural language",
+		Long:  "Use natural language to describe your project and get an implementation plan",
+	}
+
+	cmd.AddCommand(newNLPGenerateCommand(cfg, logger))
+
+	return cmd
+}
+
+func newNLPGenerateCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var locale, board string
+	cmd := &cobra.Command{
+		Use:   "generate [description]",
+		Short: "Generate a plan from natural language description",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := NewServiceClient(cfg, logger)
+			plan, err := client.GeneratePlan(context.Background(), &PlanRequest{
+				Request: strings.Join(args, " "),
+				Board:   board,
+				Locale:  locale,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to generate plan: %w", err)
+			}
+			if locale != "" && plan.Locale == "" {
+				fmt.Fprintf(os.Stderr, "Template has no %s locale pack; using its default text\n", locale)
+			}
+
+			fmt.Printf("Template: %s (%s)\n", plan.TemplateName, plan.TemplateID)
+			if plan.DifficultyLevel != "" {
+				fmt.Printf("Difficulty: %s\n", plan.DifficultyLevel)
+			}
+
+			if len(plan.Parameters) > 0 {
+				fmt.Println("\nParameters:")
+				keys := make([]string, 0, len(plan.Parameters))
+				for key := range plan.Parameters {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					fmt.Printf("  %s = %v\n", key, plan.Parameters[key])
+				}
+			}
+
+			if len(plan.BOM) > 0 {
+				fmt.Println("\nBill of materials:")
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				for _, item := range plan.BOM {
+					fmt.Fprintf(w, "  %d\t%s\t%s\n", item.Quantity, item.Component, item.Description)
+				}
+				w.Flush()
+			}
+
+			if len(plan.Instructions) > 0 {
+				fmt.Println("\nInstructions:")
+				for i, step := range plan.Instructions {
+					fmt.Printf("  %d. %s\n", i+1, step)
+				}
+			}
+
+			for _, warning := range plan.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&locale, "locale", "", "Write instructions and plan text in this locale (e.g. de, pt-BR)")
+	cmd.Flags().StringVar(&board, "board", "", "Target board (e.g. uno, esp32)")
+	return cmd
+}
+
+func newTelemetryCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Access device telemetry data",
+		Long:  "Stream and monitor telemetry data from devices",
+	}
+
+	cmd.AddCommand(newTelemetryMetricsCommand(cfg, logger))
+
+	return cmd
+}
+
+func newTelemetryMetricsCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Get telemetry metrics for a device",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := NewServiceClient(cfg, logger)
+			ctx := context.Background()
+
+			deviceID, err := cmd.Flags().GetString("device")
+			if err != nil {
+				return fmt.Errorf("failed to get device flag: %w", err)
+			}
+			if deviceID == "" {
+				return fmt.Errorf("device ID is required. Use --device flag")
 			}
 
 			metrics, err := client.GetTelemetryMetrics(ctx, deviceID)
@@ -479,12 +1324,11 @@ func newTelemetryMetricsCommand(cfg *config.Config, logger *logger.Logger) *cobr
 			}
 
 			fmt.Printf("Device ID: %s\n", metrics.DeviceID)
-			fmt.Printf("Timestamp: %s\n", metrics.Timestamp.Format("2006-01-02 15:04:05"))
 
-			if len(metrics.Metrics) > 0 {
+			if latest := metrics.Latest(); len(latest) > 0 {
 				fmt.Println("\nMetrics:")
-				for k, v := range metrics.Metrics {
-					fmt.Printf("  %s: %v\n", k, v)
+				for _, point := range latest {
+					fmt.Printf("  %s: %v (%s)\n", point.Name, point.Value, point.Timestamp.Format("2006-01-02 15:04:05"))
 				}
 			} else {
 				fmt.Println("No metrics available.")
@@ -505,19 +1349,21 @@ func newOTACommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
 	}
 
 	cmd.AddCommand(newOTAReleasesCommand(cfg, logger))
+	cmd.AddCommand(newOTAStatusCommand(cfg, logger))
 
 	return cmd
 }
 
 func newOTAReleasesCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
-	return &cobra.Command{
+	var annotations []string
+	cmd := &cobra.Command{
 		Use:   "releases",
 		Short: "List available OTA releases",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			client := NewServiceClient(cfg, logger)
 			ctx := context.Background()
 
-			releases, err := client.ListReleases(ctx)
+			releases, err := client.ListReleases(ctx, annotations...)
 			if err != nil {
 				return fmt.Errorf("failed to list releases: %w", err)
 			}
@@ -528,21 +1374,697 @@ func newOTAReleasesCommand(cfg *config.Config, logger *logger.Logger) *cobra.Com
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintf(w, "ID\tVERSION\tDESCRIPTION\tCREATED\n")
+			fmt.Fprintf(w, "ID\tVERSION\tDESCRIPTION\tCREATED\tANNOTATIONS\n")
 			for _, rel := range releases {
 				description := rel.Description
 				if len(description) > 40 {
 					description = description[:37] + "..."
 				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
 					rel.ID, rel.Version, description,
-					rel.CreatedAt.Format("2006-01-02 15:04"))
+					rel.CreatedAt.Format("2006-01-02 15:04"),
+					formatAnnotations(rel.Annotations))
+			}
+			w.Flush()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "Only show releases with this annotation (key=value or key, repeatable)")
+
+	return cmd
+}
+
+func newOTAStatusCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var annotations []string
+	cmd := &cobra.Command{
+		Use:   "status [deployment-id]",
+		Short: "Show OTA deployment status",
+		Long: `Show a deployment's progress and annotations, or list active deployments
+when no ID is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := NewServiceClient(cfg, logger)
+			ctx := context.Background()
+
+			if len(args) == 1 {
+				deployment, err := client.GetDeployment(ctx, args[0])
+				if err != nil {
+					return fmt.Errorf("failed to get deployment: %w", err)
+				}
+
+				fmt.Printf("ID: %s\n", deployment.ID)
+				fmt.Printf("Release: %s\n", deployment.ReleaseID)
+				fmt.Printf("Status: %s\n", deployment.Status)
+				fmt.Printf("Strategy: %s (%d%%)\n", deployment.Strategy, deployment.RolloutPercentage)
+				fmt.Printf("Devices: %d (%d succeeded, %d failed)\n",
+					len(deployment.TargetDevices), deployment.SuccessCount, deployment.FailureCount)
+				fmt.Printf("Created: %s\n", deployment.CreatedAt.Format("2006-01-02 15:04:05"))
+				fmt.Printf("Updated: %s\n", deployment.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+				if len(deployment.Annotations) > 0 {
+					fmt.Println("\nAnnotations:")
+					for _, key := range sortedKeys(deployment.Annotations) {
+						fmt.Printf("  %s: %s\n", key, deployment.Annotations[key])
+					}
+				}
+
+				return nil
+			}
+
+			deployments, err := client.ListDeployments(ctx, annotations...)
+			if err != nil {
+				return fmt.Errorf("failed to list deployments: %w", err)
+			}
+
+			if len(deployments) == 0 {
+				fmt.Println("No active deployments found.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "ID\tRELEASE\tSTATUS\tDEVICES\tFAILED\tANNOTATIONS\n")
+			for _, d := range deployments {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+					d.ID, d.ReleaseID, d.Status, len(d.TargetDevices), d.FailureCount,
+					formatAnnotations(d.Annotations))
+			}
+			w.Flush()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "Only show deployments with this annotation (key=value or key, repeatable)")
+
+	return cmd
+}
+
+// formatAnnotations renders annotations as sorted key=value pairs
+func formatAnnotations(annotations map[string]string) string {
+	pairs := make([]string, 0, len(annotations))
+	for _, key := range sortedKeys(annotations) {
+		pairs = append(pairs, key+"="+annotations[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newWatchCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var types string
+	var resources string
+	var token string
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Stream platform notifications",
+		Long: `Subscribe to deployment completions, alert firings and device offline
+events pushed by the API gateway. Runs until interrupted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if token == "" {
+				token = os.Getenv("ATHENA_TOKEN")
+			}
+			if token == "" {
+				return fmt.Errorf("an access token is required (use --token or ATHENA_TOKEN)")
+			}
+
+			client := NewServiceClient(cfg, logger)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			out := cmd.OutOrStdout()
+			encoder := json.NewEncoder(out)
+			filter := notifications.ParseFilter(types, resources)
+
+			fmt.Fprintln(cmd.ErrOrStderr(), "Watching for notifications (Ctrl+C to stop)...")
+			return client.WatchNotifications(ctx, token, filter, func(event *notifications.Event) {
+				if jsonOutput {
+					encoder.Encode(event)
+					return
+				}
+				fmt.Fprintf(out, "%s  %-22s %s/%s  %s\n",
+					event.Timestamp.Local().Format("2006-01-02 15:04:05"),
+					event.Type, event.ResourceType, event.ResourceID, event.Message)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&types, "types", "", "Comma-separated event types (e.g. deployment.completed,alert.fired,device.offline)")
+	cmd.Flags().StringVar(&resources, "resources", "", "Comma-separated resource IDs to watch (devices or deployments)")
+	cmd.Flags().StringVar(&token, "token", "", "Access token (defaults to ATHENA_TOKEN)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print events as JSON lines")
+	return cmd
+}
+
+func newLoadTestCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "loadtest",
+		Short: "Measure platform performance",
+		Long: `Generate load against the telemetry and OTA services and record the
+results per release, so performance regressions show up before a rollout.`,
+	}
+
+	cmd.AddCommand(newLoadTestScenariosCommand())
+	cmd.AddCommand(newLoadTestRunCommand(cfg, logger))
+	cmd.AddCommand(newLoadTestCompareCommand(cfg, logger))
+
+	return cmd
+}
+
+func newLoadTestScenariosCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "scenarios",
+		Short: "List load test scenarios",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "NAME\tDESCRIPTION\n")
+			for _, scenario := range loadtest.Scenarios() {
+				fmt.Fprintf(w, "%s\t%s\n", scenario.Name(), scenario.Description())
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newLoadTestRunCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var opts loadtest.Options
+	var publish bool
+	var token string
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "run <scenario>",
+		Short: "Run a load test scenario",
+		Long: `Run a scenario against the services in the current configuration and
+print throughput and latency percentiles. With --publish the result is
+recorded with the API gateway under --release.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scenario, err := loadtest.NewScenario(args[0])
+			if err != nil {
+				return err
+			}
+			if publish {
+				if opts.Release == "" {
+					return fmt.Errorf("--release is required to publish a result")
+				}
+				if token == "" {
+					token = os.Getenv("ATHENA_TOKEN")
+				}
+				if token == "" {
+					return fmt.Errorf("an access token is required to publish (use --token or ATHENA_TOKEN)")
+				}
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts.Services = cfg.Services
+			fmt.Fprintf(cmd.ErrOrStderr(), "Running %s for %s...\n", scenario.Name(), opts.Duration)
+			result, err := loadtest.Run(ctx, scenario, opts)
+			if err != nil {
+				return err
+			}
+
+			if publish {
+				client := NewServiceClient(cfg, logger)
+				recorded, err := client.PublishLoadTestResult(context.Background(), token, result)
+				if err != nil {
+					return fmt.Errorf("failed to publish result: %w", err)
+				}
+				result = recorded
+			}
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(result)
+			}
+
+			fmt.Fprintf(out, "Scenario: %s\n", result.Scenario)
+			if result.Release != "" {
+				fmt.Fprintf(out, "Release: %s\n", result.Release)
+			}
+			fmt.Fprintf(out, "Operations: %d (%d failed, %.2f%%)\n", result.Operations, result.Errors, result.ErrorRate()*100)
+			fmt.Fprintf(out, "Throughput: %.1f/s\n", result.Throughput)
+			fmt.Fprintf(out, "Latency: mean %.1fms  p50 %.1fms  p95 %.1fms  p99 %.1fms  max %.1fms\n",
+				result.Latency.Mean, result.Latency.P50, result.Latency.P95, result.Latency.P99, result.Latency.Max)
+			for _, sample := range result.ErrorSamples {
+				fmt.Fprintf(out, "  error: %s\n", sample)
+			}
+			if result.ID != "" {
+				fmt.Fprintf(out, "Recorded as %s\n", result.ID)
 			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&opts.Duration, "duration", 30*time.Second, "How long to generate load")
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 10, "Number of concurrent workers")
+	cmd.Flags().Float64Var(&opts.Rate, "rate", 0, "Maximum operations per second across workers (0 for unlimited)")
+	cmd.Flags().IntVar(&opts.Devices, "devices", 100, "Number of simulated devices")
+	cmd.Flags().IntVar(&opts.Subscribers, "subscribers", 0, "WebSocket subscribers per device (websocket-fanout only, default 10)")
+	cmd.Flags().StringVar(&opts.Release, "release", "", "Platform release under test")
+	cmd.Flags().BoolVar(&publish, "publish", false, "Record the result with the API gateway")
+	cmd.Flags().StringVar(&token, "token", "", "Access token for --publish (defaults to ATHENA_TOKEN)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the result as JSON")
+	return cmd
+}
+
+func newLoadTestCompareCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var baseline, candidate, token string
+	var tolerance float64
+	cmd := &cobra.Command{
+		Use:   "compare <scenario>",
+		Short: "Compare load test results between releases",
+		Long: `Compare the latest recorded results of a scenario for two releases. Exits
+with an error when the candidate regressed beyond the tolerance, so it can
+gate a release pipeline.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if baseline == "" || candidate == "" {
+				return fmt.Errorf("--baseline and --candidate are required")
+			}
+			if token == "" {
+				token = os.Getenv("ATHENA_TOKEN")
+			}
+			if token == "" {
+				return fmt.Errorf("an access token is required (use --token or ATHENA_TOKEN)")
+			}
+
+			client := NewServiceClient(cfg, logger)
+			comparison, err := client.CompareLoadTestResults(context.Background(), token, args[0], baseline, candidate, tolerance)
+			if err != nil {
+				return fmt.Errorf("failed to compare results: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "METRIC\t%s\t%s\tCHANGE\n", baseline, candidate)
+			fmt.Fprintf(w, "throughput/s\t%.1f\t%.1f\t%+.1f%%\n", comparison.Baseline.Throughput, comparison.Candidate.Throughput, comparison.ThroughputChange)
+			fmt.Fprintf(w, "p95 ms\t%.1f\t%.1f\t%+.1f%%\n", comparison.Baseline.Latency.P95, comparison.Candidate.Latency.P95, comparison.P95Change)
+			fmt.Fprintf(w, "p99 ms\t%.1f\t%.1f\t%+.1f%%\n", comparison.Baseline.Latency.P99, comparison.Candidate.Latency.P99, comparison.P99Change)
+			fmt.Fprintf(w, "error rate\t%.2f%%\t%.2f%%\t%+.2f\n", comparison.Baseline.ErrorRate()*100, comparison.Candidate.ErrorRate()*100, comparison.ErrorRateChange*100)
 			w.Flush()
 
+			if len(comparison.Regressions) > 0 {
+				return fmt.Errorf("%s regressed in %s: %s", candidate, comparison.Scenario, strings.Join(comparison.Regressions, ", "))
+			}
+			fmt.Fprintf(out, "No regressions beyond %.0f%%.\n", comparison.Tolerance)
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&baseline, "baseline", "", "Release to compare against")
+	cmd.Flags().StringVar(&candidate, "candidate", "", "Release under test")
+	cmd.Flags().Float64Var(&tolerance, "tolerance", 10, "Allowed change in percent before a metric counts as regressed")
+	cmd.Flags().StringVar(&token, "token", "", "Access token (defaults to ATHENA_TOKEN)")
+	return cmd
+}
+
+// adminPollInterval is how often --wait checks on running admin jobs
+var adminPollInterval = time.Second
+
+func newAdminCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
+	var token string
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Run operational tasks",
+		Long: `Run operational tasks in the platform services through the API gateway:
+rebuild indexes, collect garbage, apply data migrations, close stuck
+deployments and rotate the firmware signing key. Tasks run in the background
+as jobs. Requires an access token with the admin role.`,
+	}
+	cmd.PersistentFlags().StringVar(&token, "token", "", "Access token (defaults to ATHENA_TOKEN)")
+
+	// Resolved when a subcommand runs, after flags are parsed
+	tokenFn := func() (string, error) {
+		if token == "" {
+			token = os.Getenv("ATHENA_TOKEN")
+		}
+		if token == "" {
+			return "", fmt.Errorf("an access token is required (use --token or ATHENA_TOKEN)")
+		}
+		return token, nil
+	}
+
+	cmd.AddCommand(newAdminTasksCommand(cfg, logger, tokenFn))
+	cmd.AddCommand(newAdminJobsCommand(cfg, logger, tokenFn))
+	cmd.AddCommand(newAdminJobCommand(cfg, logger, tokenFn))
+	cmd.AddCommand(newAdminRunCommand(cfg, logger, tokenFn))
+	cmd.AddCommand(newAdminFanOutCommand(cfg, logger, tokenFn, "reindex", "Rebuild search indexes"))
+	cmd.AddCommand(newAdminFanOutCommand(cfg, logger, tokenFn, "gc", "Delete expired data"))
+	cmd.AddCommand(newAdminFanOutCommand(cfg, logger, tokenFn, "migrate", "Apply pending data migrations"))
+	cmd.AddCommand(newAdminCloseDeploymentsCommand(cfg, logger, tokenFn))
+	cmd.AddCommand(newAdminRotateSigningKeyCommand(cfg, logger, tokenFn))
+
+	return cmd
+}
+
+func newAdminTasksCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	var service string
+	cmd := &cobra.Command{
+		Use:   "tasks",
+		Short: "List the tasks each service can run",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			list, err := NewServiceClient(cfg, logger).ListAdminTasks(context.Background(), token, service)
+			if err != nil {
+				return fmt.Errorf("failed to list tasks: %w", err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVICE\tTASK\tDESCRIPTION")
+			for _, task := range list.Tasks {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", task.Service, task.Name, task.Description)
+			}
+			w.Flush()
+			warnUnavailable(cmd.ErrOrStderr(), list.Unavailable)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&service, "service", "", "Only list tasks of this service")
+	return cmd
+}
+
+func newAdminJobsCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	var service string
+	var jsonOutput bool
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List recent and running jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			list, err := NewServiceClient(cfg, logger).ListAdminJobs(context.Background(), token, service)
+			if err != nil {
+				return fmt.Errorf("failed to list jobs: %w", err)
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(list.Jobs)
+			}
+			printAdminJobs(cmd.OutOrStdout(), list.Jobs)
+			warnUnavailable(cmd.ErrOrStderr(), list.Unavailable)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&service, "service", "", "Only list jobs of this service")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print jobs as JSON")
+	return cmd
+}
+
+func newAdminJobCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "job <service> <job-id>",
+		Short: "Show a job",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			job, err := NewServiceClient(cfg, logger).GetAdminJob(context.Background(), token, args[0], args[1])
+			if err != nil {
+				return fmt.Errorf("failed to get job: %w", err)
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(job)
+		},
+	}
+}
+
+func newAdminRunCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	var params []string
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "run <service> <task>",
+		Short: "Run a task on one service",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			taskParams := admin.Params{}
+			for _, param := range params {
+				key, value, ok := strings.Cut(param, "=")
+				if !ok || key == "" {
+					return fmt.Errorf("invalid --param %q: expected key=value", param)
+				}
+				taskParams[key] = value
+			}
+
+			client := NewServiceClient(cfg, logger)
+			job, err := client.StartAdminTask(context.Background(), token, args[0], args[1], taskParams)
+			if err != nil {
+				return fmt.Errorf("failed to start %s on %s: %w", args[1], args[0], err)
+			}
+			return reportAdminJobs(cmd, client, token, []*admin.Job{job}, wait)
+		},
+	}
+	cmd.Flags().StringArrayVar(&params, "param", nil, "Task parameter as key=value (repeatable)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish")
+	return cmd
+}
+
+// newAdminFanOutCommand runs a task on every service that offers it
+func newAdminFanOutCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error), task, short string) *cobra.Command {
+	var service string
+	var olderThan string
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   task,
+		Short: short,
+		Long:  fmt.Sprintf("%s by running the %s task on every service that offers it.", short, task),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			params := admin.Params{}
+			if olderThan != "" {
+				params["older_than"] = olderThan
+			}
+
+			client := NewServiceClient(cfg, logger)
+			list, err := client.ListAdminTasks(context.Background(), token, service)
+			if err != nil {
+				return fmt.Errorf("failed to list tasks: %w", err)
+			}
+			warnUnavailable(cmd.ErrOrStderr(), list.Unavailable)
+
+			var jobs []*admin.Job
+			for _, info := range list.Tasks {
+				if info.Name != task {
+					continue
+				}
+				job, err := client.StartAdminTask(context.Background(), token, info.Service, task, params)
+				if err != nil {
+					return fmt.Errorf("failed to start %s on %s: %w", task, info.Service, err)
+				}
+				jobs = append(jobs, job)
+			}
+			if len(jobs) == 0 {
+				return fmt.Errorf("no service offers the %s task", task)
+			}
+			return reportAdminJobs(cmd, client, token, jobs, wait)
+		},
+	}
+	cmd.Flags().StringVar(&service, "service", "", "Only run on this service")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the jobs to finish")
+	if task == "gc" {
+		cmd.Flags().StringVar(&olderThan, "older-than", "", "Also delete telemetry older than this (e.g. 2160h)")
+	}
+	return cmd
+}
+
+func newAdminCloseDeploymentsCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	var stuckFor string
+	var reason string
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "close-deployments [deployment-id]",
+		Short: "Force-close a deployment, or all stuck deployments",
+		Long: `Force-close a deployment, failing the device updates still in flight. Without
+a deployment ID, closes every active deployment that has made no progress
+for --stuck-for.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+			params := admin.Params{"reason": reason}
+			if len(args) == 1 {
+				params["deployment_id"] = args[0]
+			} else if stuckFor != "" {
+				params["stuck_for"] = stuckFor
+			}
+
+			client := NewServiceClient(cfg, logger)
+			job, err := client.StartAdminTask(context.Background(), token, "ota-service", "close-deployments", params)
+			if err != nil {
+				return fmt.Errorf("failed to close deployments: %w", err)
+			}
+			return reportAdminJobs(cmd, client, token, []*admin.Job{job}, wait)
+		},
+	}
+	cmd.Flags().StringVar(&stuckFor, "stuck-for", "", "Time without progress after which a deployment is stuck (default 24h)")
+	cmd.Flags().StringVar(&reason, "reason", "", "Reason recorded on the failed device updates")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish")
+	return cmd
+}
+
+func newAdminRotateSigningKeyCommand(cfg *config.Config, logger *logger.Logger, tokenFn func() (string, error)) *cobra.Command {
+	var privateKeyFile, publicKeyFile, outDir string
+	var generate, resign, wait bool
+	cmd := &cobra.Command{
+		Use:   "rotate-signing-key",
+		Short: "Rotate the firmware signing key",
+		Long: `Make a new RSA key pair the OTA service's firmware signing key. Releases
+signed with earlier keys keep verifying; --resign re-signs them with the new
+key. With --generate a 3072-bit key pair is created and written to --out-dir;
+keep the private key safe, devices need the public key.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := tokenFn()
+			if err != nil {
+				return err
+			}
+
+			var privateKey, publicKey []byte
+			if generate {
+				if privateKeyFile != "" || publicKeyFile != "" {
+					return fmt.Errorf("--generate cannot be combined with --private-key or --public-key")
+				}
+				if privateKey, publicKey, err = ota.GenerateKeyPair(3072); err != nil {
+					return err
+				}
+				privateKeyFile = filepath.Join(outDir, "firmware-signing.pem")
+				publicKeyFile = filepath.Join(outDir, "firmware-signing.pub.pem")
+				if err := os.WriteFile(privateKeyFile, privateKey, 0600); err != nil {
+					return fmt.Errorf("failed to write private key: %w", err)
+				}
+				if err := os.WriteFile(publicKeyFile, publicKey, 0644); err != nil {
+					return fmt.Errorf("failed to write public key: %w", err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote new key pair to %s and %s\n", privateKeyFile, publicKeyFile)
+			} else {
+				if privateKeyFile == "" || publicKeyFile == "" {
+					return fmt.Errorf("--private-key and --public-key are required (or use --generate)")
+				}
+				if privateKey, err = os.ReadFile(privateKeyFile); err != nil {
+					return fmt.Errorf("failed to read private key: %w", err)
+				}
+				if publicKey, err = os.ReadFile(publicKeyFile); err != nil {
+					return fmt.Errorf("failed to read public key: %w", err)
+				}
+			}
+
+			client := NewServiceClient(cfg, logger)
+			job, err := client.StartAdminTask(context.Background(), token, "ota-service", "rotate-signing-key", admin.Params{
+				"private_key": string(privateKey),
+				"public_key":  string(publicKey),
+				"resign":      fmt.Sprint(resign),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rotate signing key: %w", err)
+			}
+			return reportAdminJobs(cmd, client, token, []*admin.Job{job}, wait)
+		},
+	}
+	cmd.Flags().StringVar(&privateKeyFile, "private-key", "", "PEM file with the new private key")
+	cmd.Flags().StringVar(&publicKeyFile, "public-key", "", "PEM file with the new public key")
+	cmd.Flags().BoolVar(&generate, "generate", false, "Generate the new key pair")
+	cmd.Flags().StringVar(&outDir, "out-dir", ".", "Directory for a generated key pair")
+	cmd.Flags().BoolVar(&resign, "resign", false, "Re-sign existing releases with the new key")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the job to finish")
+	return cmd
+}
+
+// reportAdminJobs prints started jobs and, with wait, polls them until they
+// finish. It fails when a job it waited for failed.
+func reportAdminJobs(cmd *cobra.Command, client *ServiceClient, token string, jobs []*admin.Job, wait bool) error {
+	out := cmd.OutOrStdout()
+	if !wait {
+		for _, job := range jobs {
+			fmt.Fprintf(out, "Started %s on %s (job %s)\n", job.Task, job.Service, job.ID)
+		}
+		return nil
+	}
+
+	for i, job := range jobs {
+		for job.Status == admin.JobStatusRunning {
+			time.Sleep(adminPollInterval)
+			current, err := client.GetAdminJob(context.Background(), token, job.Service, job.ID)
+			if err != nil {
+				return fmt.Errorf("failed to check job %s: %w", job.ID, err)
+			}
+			job = current
+		}
+		jobs[i] = job
+	}
+	printAdminJobs(out, jobs)
+
+	var failed []string
+	for _, job := range jobs {
+		if job.Status == admin.JobStatusFailed {
+			failed = append(failed, fmt.Sprintf("%s on %s: %s", job.Task, job.Service, job.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("job failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func printAdminJobs(out io.Writer, jobs []*admin.Job) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSERVICE\tTASK\tSTATUS\tSTARTED\tBY\tRESULT")
+	for _, job := range jobs {
+		result := formatAdminResult(job.Result)
+		if job.Error != "" {
+			result = job.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Service, job.Task, job.Status,
+			job.StartedAt.Local().Format("2006-01-02 15:04:05"), job.RequestedBy, result)
+	}
+	w.Flush()
+}
+
+// formatAdminResult renders a job result as sorted key=value pairs
+func formatAdminResult(result admin.Result) string {
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, _ := json.Marshal(result[key])
+		pairs = append(pairs, key+"="+string(value))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func warnUnavailable(out io.Writer, services []string) {
+	if len(services) > 0 {
+		fmt.Fprintf(out, "Warning: could not reach %s\n", strings.Join(services, ", "))
+	}
 }
 
 func newProfileCommand(cfg *config.Config, logger *logger.Logger) *cobra.Command {
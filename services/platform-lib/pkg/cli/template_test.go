@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateSignAndImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := logger.New("info", "athena-cli")
+	dir := t.TempDir()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "publisher.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+
+	templateFile := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(templateFile, []byte(`{
+		"id": "soil-monitor",
+		"name": "Soil Monitor",
+		"version": "1.0.0",
+		"category": "sensing",
+		"boards_supported": ["esp32:esp32:esp32"],
+		"schema": {"type": "object", "properties": {"pin": {"type": "integer"}}},
+		"parameters": {"pin": 34}
+	}`), 0644))
+
+	service, err := athenatemplate.NewService(&config.Config{ServiceName: "template-service"}, log, athenatemplate.NewMemoryRepository())
+	require.NoError(t, err)
+	_, err = service.RegisterPublisher(context.Background(), &athenatemplate.Publisher{
+		ID:        "acme",
+		Name:      "Acme Sensors",
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+	})
+	require.NoError(t, err)
+	router := gin.New()
+	athenatemplate.RegisterRoutes(router, service)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	cfg := &config.Config{Services: map[string]string{"template-service": server.URL}}
+
+	run := func(args ...string) (string, string, error) {
+		cmd := newTemplateCommand(cfg, log)
+		var stdout, stderr bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return stdout.String(), stderr.String(), err
+	}
+
+	bundleFile := filepath.Join(dir, "bundle.json")
+	stdout, _, err := run("sign", templateFile, "--publisher", "acme", "--key", keyFile, "-o", bundleFile)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Signed soil-monitor 1.0.0 as acme")
+
+	stdout, stderr, err := run("import", bundleFile)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Imported template soil-monitor 1.0.0 (verified)")
+	assert.Empty(t, stderr)
+
+	// Edits after signing are rejected
+	data, err := os.ReadFile(bundleFile)
+	require.NoError(t, err)
+	var bundle athenatemplate.TemplateBundle
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	bundle.Template.Version = "1.0.1"
+	bundle.Template.Parameters["pin"] = 2
+	data, err = json.Marshal(bundle)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(bundleFile, data, 0644))
+	_, _, err = run("import", bundleFile)
+	assert.ErrorContains(t, err, "signature verification failed")
+
+	// Unsigned bundles import with a warning
+	bundle.Signature = nil
+	data, err = json.Marshal(bundle)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(bundleFile, data, 0644))
+	stdout, stderr, err = run("import", bundleFile)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "(unsigned)")
+	assert.Contains(t, stderr, "Warning: template soil-monitor 1.0.1 is unsigned")
+}
+
+func TestWarnUnverifiedTemplate(t *testing.T) {
+	var out bytes.Buffer
+	warnUnverifiedTemplate(&out, &Template{ID: "blink", Version: "1.0.0"})
+	warnUnverifiedTemplate(&out, &Template{ID: "blink", Version: "1.0.0", Provenance: &athenatemplate.Provenance{Status: athenatemplate.ProvenanceVerified}})
+	assert.Empty(t, out.String())
+
+	warnUnverifiedTemplate(&out, &Template{ID: "blink", Version: "1.0.0", Provenance: &athenatemplate.Provenance{
+		Status:      athenatemplate.ProvenanceUnverified,
+		PublisherID: "someone",
+	}})
+	assert.Contains(t, out.String(), "signed by someone (not registered), which is not a registered publisher")
+}
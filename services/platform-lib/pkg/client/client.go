@@ -0,0 +1,598 @@
+// Package client is a Go client for the public ATHENA API served by the API
+// gateway. Its types are the API contract: the gateway's OpenAPI spec, and
+// the Python and TypeScript clients generated from it, are derived from
+// them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIPrefix is the path prefix of every API route
+const APIPrefix = "/api/v1"
+
+// Client calls the ATHENA API through the gateway
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates requests with a user JWT or service account token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, which times out after 30
+// seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a client for the gateway at baseURL, such as
+// https://athena.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetToken replaces the token requests are authenticated with
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// APIError is returned for responses with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+	Details    string
+}
+
+func (e *APIError) Error() string {
+	if e.Details != "" {
+		return fmt.Sprintf("API error %d: %s: %s", e.StatusCode, e.Message, e.Details)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request to an API path and decodes the JSON response into
+// target
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, target interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	endpoint := c.baseURL + APIPrefix + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		var payload struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		if json.Unmarshal(respBody, &payload) == nil && payload.Error != "" {
+			apiErr.Message, apiErr.Details = payload.Error, payload.Details
+		}
+		return apiErr
+	}
+
+	if target != nil {
+		if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// Authentication
+
+// Login exchanges a username and password for a token, which the client
+// uses from then on
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResponse, error) {
+	var resp LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/auth/login", nil, &LoginRequest{Username: username, Password: password}, &resp); err != nil {
+		return nil, err
+	}
+	c.SetToken(resp.Token)
+	return &resp, nil
+}
+
+// Devices
+
+// ListDevices lists devices matching opts, which may be nil
+func (c *Client) ListDevices(ctx context.Context, opts *DeviceListOptions) (*DeviceList, error) {
+	query := url.Values{}
+	if opts != nil {
+		setQuery(query, "status", opts.Status)
+		setQuery(query, "board_type", opts.BoardType)
+		setQuery(query, "template_id", opts.TemplateID)
+		setQuery(query, "ota_channel", opts.OTAChannel)
+		setQuery(query, "parent_id", opts.ParentID)
+		setQuery(query, "selector", opts.Selector)
+		setQuery(query, "group", opts.Group)
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if opts.Offset > 0 {
+			query.Set("offset", strconv.Itoa(opts.Offset))
+		}
+	}
+	var list DeviceList
+	if err := c.do(ctx, http.MethodGet, "/devices", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetDevice retrieves a device
+func (c *Client) GetDevice(ctx context.Context, deviceID string) (*Device, error) {
+	var device Device
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID), nil, nil, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// RegisterDevice registers a provisioned device
+func (c *Client) RegisterDevice(ctx context.Context, req *DeviceRegistration) (*Device, error) {
+	var device Device
+	if err := c.do(ctx, http.MethodPost, "/devices", nil, req, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// GetTwin retrieves the desired and reported state of a device
+func (c *Client) GetTwin(ctx context.Context, deviceID string) (*Twin, error) {
+	var twin Twin
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/twin", nil, nil, &twin); err != nil {
+		return nil, err
+	}
+	return &twin, nil
+}
+
+// GetTwinDelta retrieves the desired state a device has not reported yet
+func (c *Client) GetTwinDelta(ctx context.Context, deviceID string) (*TwinDelta, error) {
+	var delta TwinDelta
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/twin/delta", nil, nil, &delta); err != nil {
+		return nil, err
+	}
+	return &delta, nil
+}
+
+// UpdateDesiredState patches the state a device should be in
+func (c *Client) UpdateDesiredState(ctx context.Context, deviceID string, patch *TwinPatch) (*Twin, error) {
+	var twin Twin
+	if err := c.do(ctx, http.MethodPatch, "/devices/"+url.PathEscape(deviceID)+"/twin/desired", nil, patch, &twin); err != nil {
+		return nil, err
+	}
+	return &twin, nil
+}
+
+// ReportTwinState patches the state a device reports being in. The client
+// must carry the device's own token, or that of a gateway it sits behind.
+func (c *Client) ReportTwinState(ctx context.Context, deviceID string, patch *TwinPatch) (*Twin, error) {
+	var twin Twin
+	if err := c.do(ctx, http.MethodPost, "/devices/"+url.PathEscape(deviceID)+"/twin/reported", nil, patch, &twin); err != nil {
+		return nil, err
+	}
+	return &twin, nil
+}
+
+// Device commands
+
+// SendCommand queues a command for a device
+func (c *Client) SendCommand(ctx context.Context, deviceID string, req *CommandRequest) (*Command, error) {
+	var command Command
+	if err := c.do(ctx, http.MethodPost, "/devices/"+url.PathEscape(deviceID)+"/commands", nil, req, &command); err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// ListCommands lists the command history of a device, optionally only the
+// commands in one status
+func (c *Client) ListCommands(ctx context.Context, deviceID, status string) (*CommandList, error) {
+	query := url.Values{}
+	setQuery(query, "status", status)
+	var list CommandList
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/commands", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// PollCommands retrieves the commands waiting for a device, oldest first,
+// and marks them delivered
+func (c *Client) PollCommands(ctx context.Context, deviceID string) (*CommandList, error) {
+	var list CommandList
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/commands/pending", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetCommand retrieves a command sent to a device
+func (c *Client) GetCommand(ctx context.Context, deviceID, commandID string) (*Command, error) {
+	var command Command
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/commands/"+url.PathEscape(commandID), nil, nil, &command); err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// AckCommand reports that a device ran a command
+func (c *Client) AckCommand(ctx context.Context, deviceID, commandID string, ack *CommandAck) (*Command, error) {
+	var command Command
+	if err := c.do(ctx, http.MethodPost, "/devices/"+url.PathEscape(deviceID)+"/commands/"+url.PathEscape(commandID)+"/ack", nil, ack, &command); err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// Device credentials
+
+// SignCertificate has a device's certificate signing request signed by the
+// device CA
+func (c *Client) SignCertificate(ctx context.Context, deviceID string, req *CertificateRequest) (*IssuedCredential, error) {
+	var issued IssuedCredential
+	if err := c.do(ctx, http.MethodPost, "/devices/"+url.PathEscape(deviceID)+"/certificates", nil, req, &issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+// RevokeCredential revokes a device credential, so the device can no
+// longer authenticate with it
+func (c *Client) RevokeCredential(ctx context.Context, deviceID, name string) (*Credential, error) {
+	var credential Credential
+	if err := c.do(ctx, http.MethodPost, "/devices/"+url.PathEscape(deviceID)+"/credentials/"+url.PathEscape(name)+"/revoke", nil, nil, &credential); err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// Claim tokens
+
+// CreateClaimToken creates a single-use token a device can register itself
+// with. The token's secret is only returned here.
+func (c *Client) CreateClaimToken(ctx context.Context, req *ClaimTokenRequest) (*ClaimToken, error) {
+	var token ClaimToken
+	if err := c.do(ctx, http.MethodPost, "/devices/claim-tokens", nil, req, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListClaimTokens lists claim tokens, newest first, optionally only those
+// in status
+func (c *Client) ListClaimTokens(ctx context.Context, status string) (*ClaimTokenList, error) {
+	query := url.Values{}
+	setQuery(query, "status", status)
+	var list ClaimTokenList
+	if err := c.do(ctx, http.MethodGet, "/devices/claim-tokens", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetClaimToken retrieves a claim token
+func (c *Client) GetClaimToken(ctx context.Context, tokenID string) (*ClaimToken, error) {
+	var token ClaimToken
+	if err := c.do(ctx, http.MethodGet, "/devices/claim-tokens/"+url.PathEscape(tokenID), nil, nil, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeClaimToken deletes a claim token so no device can claim it
+func (c *Client) RevokeClaimToken(ctx context.Context, tokenID string) error {
+	return c.do(ctx, http.MethodDelete, "/devices/claim-tokens/"+url.PathEscape(tokenID), nil, nil, nil)
+}
+
+// ClaimDevice registers a device with a claim token. It needs no platform
+// token.
+func (c *Client) ClaimDevice(ctx context.Context, claim *DeviceClaim) (*Device, error) {
+	var device Device
+	if err := c.do(ctx, http.MethodPost, "/devices/claim", nil, claim, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// ListDeviceEvents returns one page of a device's timeline, newest first.
+// opts may be nil; pass the page's NextCursor to read the next one.
+func (c *Client) ListDeviceEvents(ctx context.Context, deviceID string, opts *DeviceEventListOptions) (*DeviceEventList, error) {
+	query := url.Values{}
+	if opts != nil {
+		if len(opts.Types) > 0 {
+			query.Set("type", strings.Join(opts.Types, ","))
+		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.UTC().Format(time.RFC3339))
+		}
+		if !opts.Until.IsZero() {
+			query.Set("until", opts.Until.UTC().Format(time.RFC3339))
+		}
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		setQuery(query, "cursor", opts.Cursor)
+	}
+	var list DeviceEventList
+	if err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(deviceID)+"/events", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Device groups
+
+// ListDeviceGroups lists device groups
+func (c *Client) ListDeviceGroups(ctx context.Context) (*DeviceGroupList, error) {
+	var list DeviceGroupList
+	if err := c.do(ctx, http.MethodGet, "/device-groups", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetDeviceGroup retrieves a device group
+func (c *Client) GetDeviceGroup(ctx context.Context, name string) (*DeviceGroup, error) {
+	var group DeviceGroup
+	if err := c.do(ctx, http.MethodGet, "/device-groups/"+url.PathEscape(name), nil, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// CreateDeviceGroup creates a device group of registered devices
+func (c *Client) CreateDeviceGroup(ctx context.Context, group *DeviceGroup) (*DeviceGroup, error) {
+	var created DeviceGroup
+	if err := c.do(ctx, http.MethodPost, "/device-groups", nil, group, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteDeviceGroup removes a device group
+func (c *Client) DeleteDeviceGroup(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/device-groups/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// AddDeviceGroupMembers adds registered devices to a group
+func (c *Client) AddDeviceGroupMembers(ctx context.Context, name string, deviceIDs ...string) (*DeviceGroup, error) {
+	var group DeviceGroup
+	if err := c.do(ctx, http.MethodPost, "/device-groups/"+url.PathEscape(name)+"/members", nil, &DeviceGroupMembers{DeviceIDs: deviceIDs}, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// RemoveDeviceGroupMember removes a device from a group's members
+func (c *Client) RemoveDeviceGroupMember(ctx context.Context, name, deviceID string) (*DeviceGroup, error) {
+	var group DeviceGroup
+	if err := c.do(ctx, http.MethodDelete, "/device-groups/"+url.PathEscape(name)+"/members/"+url.PathEscape(deviceID), nil, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Telemetry
+
+// GetMetrics retrieves a device's metrics between start and end. Zero times
+// default to the last 24 hours.
+func (c *Client) GetMetrics(ctx context.Context, deviceID string, start, end time.Time) (*DeviceMetrics, error) {
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start", start.UTC().Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		query.Set("end", end.UTC().Format(time.RFC3339))
+	}
+	var metrics DeviceMetrics
+	if err := c.do(ctx, http.MethodGet, "/telemetry/metrics/"+url.PathEscape(deviceID), query, nil, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// Wiring
+
+// CheckSafety returns the electrical safety report of a wiring diagram on a
+// board. An unsafe diagram is not an error; its report is not Valid.
+func (c *Client) CheckSafety(ctx context.Context, req *SafetyCheckRequest) (*SafetyReport, error) {
+	var report SafetyReport
+	if err := c.do(ctx, http.MethodPost, "/nlp/safety-check", nil, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// OTA releases and deployments
+
+// ListReleases lists firmware releases, optionally of one template and
+// channel
+func (c *Client) ListReleases(ctx context.Context, templateID, channel string) (*ReleaseList, error) {
+	query := url.Values{}
+	setQuery(query, "template_id", templateID)
+	setQuery(query, "channel", channel)
+	var list ReleaseList
+	if err := c.do(ctx, http.MethodGet, "/ota/releases", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetRelease retrieves a firmware release
+func (c *Client) GetRelease(ctx context.Context, releaseID string) (*Release, error) {
+	var release Release
+	if err := c.do(ctx, http.MethodGet, "/ota/releases/"+url.PathEscape(releaseID), nil, nil, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// PromoteRelease promotes a release, and the template version it was built
+// from, from one environment to the next
+func (c *Client) PromoteRelease(ctx context.Context, releaseID string, req *PromotionRequest) (*Promotion, error) {
+	var promotion Promotion
+	if err := c.do(ctx, http.MethodPost, "/ota/releases/"+url.PathEscape(releaseID)+"/promote", nil, req, &promotion); err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// CreateDeployment deploys a release. Deployments of releases in channels
+// that need approval start out pending_approval.
+func (c *Client) CreateDeployment(ctx context.Context, req *DeploymentRequest) (*Deployment, error) {
+	var deployment Deployment
+	if err := c.do(ctx, http.MethodPost, "/ota/deployments", nil, req, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// ListDeployments lists the deployments of a release, or the active
+// deployments if releaseID is empty
+func (c *Client) ListDeployments(ctx context.Context, releaseID string) (*DeploymentList, error) {
+	query := url.Values{}
+	setQuery(query, "release_id", releaseID)
+	var list DeploymentList
+	if err := c.do(ctx, http.MethodGet, "/ota/deployments", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetDeployment retrieves a deployment
+func (c *Client) GetDeployment(ctx context.Context, deploymentID string) (*Deployment, error) {
+	var deployment Deployment
+	if err := c.do(ctx, http.MethodGet, "/ota/deployments/"+url.PathEscape(deploymentID), nil, nil, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// GetDeploymentStatus retrieves a deployment's progress and failures
+func (c *Client) GetDeploymentStatus(ctx context.Context, deploymentID string) (*DeploymentStatus, error) {
+	var status DeploymentStatus
+	if err := c.do(ctx, http.MethodGet, "/ota/deployments/"+url.PathEscape(deploymentID)+"/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ApproveDeployment starts a deployment that is pending approval
+func (c *Client) ApproveDeployment(ctx context.Context, deploymentID string, req *ApprovalRequest) (*Deployment, error) {
+	return c.deploymentAction(ctx, deploymentID, "approve", req)
+}
+
+// RejectDeployment rejects a deployment that is pending approval
+func (c *Client) RejectDeployment(ctx context.Context, deploymentID string, req *ApprovalRequest) (*Deployment, error) {
+	return c.deploymentAction(ctx, deploymentID, "reject", req)
+}
+
+// CancelDeployment stops a deployment
+func (c *Client) CancelDeployment(ctx context.Context, deploymentID string) (*Deployment, error) {
+	return c.deploymentAction(ctx, deploymentID, "cancel", nil)
+}
+
+func (c *Client) deploymentAction(ctx context.Context, deploymentID, action string, body interface{}) (*Deployment, error) {
+	var deployment Deployment
+	if err := c.do(ctx, http.MethodPost, "/ota/deployments/"+url.PathEscape(deploymentID)+"/"+action, nil, body, &deployment); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// ReportUpdateStatus reports the status of a device's update
+func (c *Client) ReportUpdateStatus(ctx context.Context, deviceID string, report *UpdateStatusReport) error {
+	return c.do(ctx, http.MethodPost, "/ota/devices/"+url.PathEscape(deviceID)+"/updates/status", nil, report, nil)
+}
+
+// ConfirmBoot tells the OTA service a device booted the release it was
+// updated to
+func (c *Client) ConfirmBoot(ctx context.Context, deviceID string, confirmation *BootConfirmation) error {
+	return c.do(ctx, http.MethodPost, "/ota/updates/"+url.PathEscape(deviceID)+"/confirm-boot", nil, confirmation, nil)
+}
+
+// OTA webhooks
+
+// ListWebhooks lists the webhooks receiving OTA events. Secrets are not
+// included.
+func (c *Client) ListWebhooks(ctx context.Context) (*WebhookList, error) {
+	var list WebhookList
+	if err := c.do(ctx, http.MethodGet, "/ota/webhooks", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateWebhook registers a webhook. The returned webhook holds its signing
+// secret, which is not shown again.
+func (c *Client) CreateWebhook(ctx context.Context, req *WebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodPost, "/ota/webhooks", nil, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return c.do(ctx, http.MethodDelete, "/ota/webhooks/"+url.PathEscape(webhookID), nil, nil, nil)
+}
+
+func setQuery(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
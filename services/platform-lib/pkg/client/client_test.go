@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedRequest is what the test server saw of a request
+type recordedRequest struct {
+	Method        string
+	Path          string
+	Query         string
+	Authorization string
+	Body          map[string]interface{}
+}
+
+func newTestServer(t *testing.T, status int, response string) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	recorded := &recordedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded.Method = r.Method
+		recorded.Path = r.URL.EscapedPath()
+		recorded.Query = r.URL.RawQuery
+		recorded.Authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&recorded.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, recorded
+}
+
+func TestClient_Login(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{"token":"jwt-123","expires_at":"2026-01-01T00:00:00Z","user":{"id":"1","username":"admin","roles":["admin"]}}`)
+	c := New(server.URL + "/")
+
+	resp, err := c.Login(context.Background(), "admin", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", resp.User.Username)
+	assert.Equal(t, http.MethodPost, recorded.Method)
+	assert.Equal(t, "/api/v1/auth/login", recorded.Path)
+	assert.Equal(t, map[string]interface{}{"username": "admin", "password": "secret"}, recorded.Body)
+	assert.Empty(t, recorded.Authorization)
+
+	// Later requests use the token
+	_, err = c.GetDevice(context.Background(), "device 1")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer jwt-123", recorded.Authorization)
+	assert.Equal(t, "/api/v1/devices/device%201", recorded.Path)
+}
+
+func TestClient_Queries(t *testing.T) {
+	server, recorded := newTestServer(t, http.StatusOK, `{}`)
+	c := New(server.URL, WithToken("token"))
+	ctx := context.Background()
+
+	_, err := c.ListDevices(ctx, &DeviceListOptions{Status: "online", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "limit=10&status=online", recorded.Query)
+
+	_, err = c.ListDevices(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, recorded.Query)
+
+	_, err = c.ListDevices(ctx, &DeviceListOptions{Selector: "env=prod,site=lab1", Group: "lab"})
+	require.NoError(t, err)
+	assert.Equal(t, "group=lab&selector=env%3Dprod%2Csite%3Dlab1", recorded.Query)
+
+	_, err = c.AddDeviceGroupMembers(ctx, "lab", "device-001", "device-002")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/device-groups/lab/members", recorded.Path)
+	assert.Equal(t, []interface{}{"device-001", "device-002"}, recorded.Body["device_ids"])
+
+	_, err = c.ListCommands(ctx, "device-001", "pending")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/device-001/commands", recorded.Path)
+	assert.Equal(t, "status=pending", recorded.Query)
+
+	_, err = c.AckCommand(ctx, "device-001", "cmd-1", &CommandAck{Status: "failed", Error: "busy"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/device-001/commands/cmd-1/ack", recorded.Path)
+	assert.Equal(t, "busy", recorded.Body["error"])
+
+	_, err = c.SignCertificate(ctx, "device-001", &CertificateRequest{CSR: "-----BEGIN CERTIFICATE REQUEST-----"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/device-001/certificates", recorded.Path)
+	assert.Equal(t, "-----BEGIN CERTIFICATE REQUEST-----", recorded.Body["csr"])
+
+	_, err = c.RevokeCredential(ctx, "device-001", "device-token")
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, recorded.Method)
+	assert.Equal(t, "/api/v1/devices/device-001/credentials/device-token/revoke", recorded.Path)
+
+	_, err = c.ListDeviceEvents(ctx, "device-001", &DeviceEventListOptions{
+		Types: []string{"status_changed", "alert_fired"},
+		Since: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit: 20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/device-001/events", recorded.Path)
+	assert.Equal(t, "limit=20&since=2026-01-01T00%3A00%3A00Z&type=status_changed%2Calert_fired", recorded.Query)
+
+	_, err = c.ListClaimTokens(ctx, "active")
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/claim-tokens", recorded.Path)
+	assert.Equal(t, "status=active", recorded.Query)
+
+	_, err = c.ClaimDevice(ctx, &DeviceClaim{Token: "tok-1.secret", DeviceID: "device-001", FirmwareHash: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/devices/claim", recorded.Path)
+	assert.Equal(t, "tok-1.secret", recorded.Body["token"])
+
+	_, err = c.CheckSafety(ctx, &SafetyCheckRequest{
+		Board: "uno",
+		WiringDiagram: WiringDiagram{
+			Components:  []WiringComponent{{ID: "led1", Type: "led", Name: "LED"}},
+			Connections: []WiringConnection{{FromComponent: "board", FromPin: "D13", ToComponent: "led1", ToPin: "ANODE"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/nlp/safety-check", recorded.Path)
+	assert.Equal(t, "uno", recorded.Body["board"])
+	assert.Len(t, recorded.Body["wiring_diagram"].(map[string]interface{})["connections"], 1)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = c.GetMetrics(ctx, "device-001", start, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/telemetry/metrics/device-001", recorded.Path)
+	assert.Equal(t, "start=2026-01-01T00%3A00%3A00Z", recorded.Query)
+
+	_, err = c.PromoteRelease(ctx, "rel-1", &PromotionRequest{From: "staging", To: "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ota/releases/rel-1/promote", recorded.Path)
+	assert.Equal(t, "staging", recorded.Body["from"])
+	assert.Equal(t, "prod", recorded.Body["to"])
+
+	_, err = c.ApproveDeployment(ctx, "dep-1", &ApprovalRequest{Approver: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ota/deployments/dep-1/approve", recorded.Path)
+	assert.Equal(t, "alice", recorded.Body["approver"])
+
+	err = c.ReportUpdateStatus(ctx, "device-001", &UpdateStatusReport{ReleaseID: "rel-1", Status: "failed", ErrorCode: ErrorCodeHashMismatch})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ota/devices/device-001/updates/status", recorded.Path)
+	assert.Equal(t, "hash_mismatch", recorded.Body["error_code"])
+	assert.NotContains(t, recorded.Body, "error_type")
+
+	err = c.ConfirmBoot(ctx, "device-001", &BootConfirmation{ReleaseID: "rel-1", Slot: "ota_1"})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/ota/updates/device-001/confirm-boot", recorded.Path)
+	assert.Equal(t, "ota_1", recorded.Body["slot"])
+
+	version := int64(3)
+	_, err = c.UpdateDesiredState(ctx, "device-001", &TwinPatch{State: map[string]interface{}{"led": "on"}, Version: &version})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, recorded.Method)
+	assert.Equal(t, "/api/v1/devices/device-001/twin/desired", recorded.Path)
+	assert.Equal(t, map[string]interface{}{"led": "on"}, recorded.Body["state"])
+	assert.Equal(t, float64(3), recorded.Body["version"])
+}
+
+func TestClient_APIError(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusNotFound, `{"error":"Device not found","details":"device-404"}`)
+	c := New(server.URL)
+
+	_, err := c.GetDevice(context.Background(), "device-404")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Device not found", apiErr.Message)
+	assert.Equal(t, "device-404", apiErr.Details)
+
+	// Bodies that are not API errors keep the HTTP status
+	server, _ = newTestServer(t, http.StatusBadGateway, `upstream unavailable`)
+	err = New(server.URL).DeleteWebhook(context.Background(), "wh-1")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "502 Bad Gateway", apiErr.Message)
+}
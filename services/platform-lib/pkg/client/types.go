@@ -0,0 +1,597 @@
+package client
+
+import "time"
+
+// API contract types. These mirror the JSON of the platform services but are
+// declared here so the public API, and the clients generated from it, stay
+// stable when service internals change. Fields the services add are
+// ignored until they are added here.
+
+// LoginRequest authenticates a user
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// User is an authenticated user
+type User struct {
+	ID       string   `json:"id"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles"`
+}
+
+// LoginResponse holds the token for a logged in user
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+// Device is a registered device. OTAEnrollment is auto for devices that
+// join the deployments of their template and channel, or manual for those
+// only updated by deployments that name them.
+type Device struct {
+	DeviceID        string                 `json:"device_id"`
+	Name            string                 `json:"name,omitempty"`
+	BoardType       string                 `json:"board_type"`
+	Status          string                 `json:"status"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	FirmwareHash    string                 `json:"firmware_hash"`
+	LastSeen        time.Time              `json:"last_seen"`
+	OTAChannel      string                 `json:"ota_channel"`
+	OTAEnrollment   string                 `json:"ota_enrollment,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	Reported        map[string]interface{} `json:"reported,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	// IssuedCredential is only set when registering the device
+	IssuedCredential *IssuedCredential `json:"issued_credential,omitempty"`
+}
+
+// DeviceRegistration registers a device. A device registered without an ID
+// or name is given generated ones, and one without an OTA channel or
+// enrollment policy (auto or manual) those of its template.
+type DeviceRegistration struct {
+	DeviceID        string                 `json:"device_id,omitempty"`
+	Name            string                 `json:"name,omitempty"`
+	BoardType       string                 `json:"board_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	FirmwareHash    string                 `json:"firmware_hash"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	OTAEnrollment   string                 `json:"ota_enrollment,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+}
+
+// TwinDocument is one side of a device twin. Version counts the changes
+// to State.
+type TwinDocument struct {
+	State     map[string]interface{} `json:"state"`
+	Version   int64                  `json:"version"`
+	UpdatedAt *time.Time             `json:"updated_at,omitempty"`
+}
+
+// Twin is the state operators want a device in next to the state the
+// device last reported
+type Twin struct {
+	DeviceID string       `json:"device_id"`
+	Desired  TwinDocument `json:"desired"`
+	Reported TwinDocument `json:"reported"`
+}
+
+// TwinDelta is the desired state a device has not reported yet
+type TwinDelta struct {
+	DeviceID        string                 `json:"device_id"`
+	State           map[string]interface{} `json:"state"`
+	DesiredVersion  int64                  `json:"desired_version"`
+	ReportedVersion int64                  `json:"reported_version"`
+}
+
+// TwinPatch is a JSON merge patch to one side of a twin; null removes a
+// key. With Version set the patch only applies to that version.
+type TwinPatch struct {
+	State   map[string]interface{} `json:"state"`
+	Version *int64                 `json:"version,omitempty"`
+}
+
+// Command is an instruction sent to a device. Type is reboot, ping,
+// set_config or custom; Status is pending, delivered, succeeded, failed or
+// expired.
+type Command struct {
+	CommandID   string                 `json:"command_id"`
+	DeviceID    string                 `json:"device_id"`
+	Type        string                 `json:"type"`
+	Payload     map[string]interface{} `json:"payload,omitempty"`
+	Status      string                 `json:"status"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	ExpiresAt   time.Time              `json:"expires_at"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// CommandRequest sends a command to a device. TTLSeconds defaults to ten
+// minutes.
+type CommandRequest struct {
+	Type       string                 `json:"type"`
+	Payload    map[string]interface{} `json:"payload,omitempty"`
+	TTLSeconds int                    `json:"ttl_seconds,omitempty"`
+}
+
+// CommandAck reports that a device ran a command. Status is succeeded or
+// failed; failures carry an Error.
+type CommandAck struct {
+	Status string                 `json:"status"`
+	Result map[string]interface{} `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// CommandList lists the commands of a device, newest first
+type CommandList struct {
+	Commands []Command `json:"commands"`
+	Total    int       `json:"total"`
+}
+
+// IssuedCredential is a credential issued to a device: a token, or a
+// certificate with the CA that signed it. Token and PrivateKey are only
+// returned when the credential is issued.
+type IssuedCredential struct {
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	Version       int       `json:"version"`
+	Fingerprint   string    `json:"fingerprint"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Token         string    `json:"token,omitempty"`
+	Certificate   string    `json:"certificate,omitempty"`
+	PrivateKey    string    `json:"private_key,omitempty"`
+	CACertificate string    `json:"ca_certificate,omitempty"`
+}
+
+// CertificateRequest has a device's PEM certificate signing request
+// signed. Name defaults to device-certificate.
+type CertificateRequest struct {
+	CSR  string `json:"csr"`
+	Name string `json:"name,omitempty"`
+}
+
+// Credential describes a credential a device holds
+type Credential struct {
+	Type        string     `json:"type"`
+	Version     int        `json:"version"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ClaimToken lets a pre-provisioned device register itself once with the
+// token flashed into its firmware. Status is active, claimed or expired;
+// DeviceID is the device that claimed it. Token is only returned when the
+// claim token is created.
+type ClaimToken struct {
+	TokenID         string                 `json:"token_id"`
+	Token           string                 `json:"token,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	BoardType       string                 `json:"board_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	Status          string                 `json:"status"`
+	CreatedAt       time.Time              `json:"created_at"`
+	ExpiresAt       time.Time              `json:"expires_at"`
+	ClaimedAt       *time.Time             `json:"claimed_at,omitempty"`
+	DeviceID        string                 `json:"device_id,omitempty"`
+}
+
+// ClaimTokenRequest creates a claim token for a template and board.
+// TTLSeconds defaults to 30 days.
+type ClaimTokenRequest struct {
+	Description     string                 `json:"description,omitempty"`
+	BoardType       string                 `json:"board_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	TTLSeconds      int                    `json:"ttl_seconds,omitempty"`
+}
+
+// ClaimTokenList is a list of claim tokens
+type ClaimTokenList struct {
+	Tokens []ClaimToken `json:"tokens"`
+	Total  int          `json:"total"`
+}
+
+// DeviceClaim registers a device with a claim token, which supplies its
+// template, board and parameters
+type DeviceClaim struct {
+	Token        string `json:"token"`
+	DeviceID     string `json:"device_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	BoardType    string `json:"board_type,omitempty"`
+	FirmwareHash string `json:"firmware_hash"`
+}
+
+// DeviceListOptions filters a device list. Limit defaults to 50. Selector
+// lists labels devices must carry, such as "env=prod,site=lab1", and Group
+// names a device group.
+type DeviceListOptions struct {
+	Status     string
+	BoardType  string
+	TemplateID string
+	OTAChannel string
+	ParentID   string
+	Selector   string
+	Group      string
+	Limit      int
+	Offset     int
+}
+
+// DeviceEvent is one entry of a device's timeline: registered,
+// status_changed, firmware_updated, config_changed, alert_fired or flashed
+type DeviceEvent struct {
+	EventID   string                 `json:"event_id"`
+	DeviceID  string                 `json:"device_id"`
+	Type      string                 `json:"type"`
+	Source    string                 `json:"source"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// DeviceEventList is one page of a device's timeline
+type DeviceEventList struct {
+	DeviceID   string        `json:"device_id"`
+	Events     []DeviceEvent `json:"events"`
+	Count      int           `json:"count"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// DeviceEventListOptions filters a device's timeline. Since is inclusive
+// and Until exclusive; Limit defaults to 100.
+type DeviceEventListOptions struct {
+	Types  []string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Cursor string
+}
+
+// DeviceGroup names a set of devices: the listed members and those
+// carrying every label in Labels
+type DeviceGroup struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Devices     []string          `json:"devices"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// DeviceGroupList lists device groups by name
+type DeviceGroupList struct {
+	Groups []DeviceGroup `json:"groups"`
+	Total  int           `json:"total"`
+}
+
+// DeviceGroupMembers lists devices to add to a group
+type DeviceGroupMembers struct {
+	DeviceIDs []string `json:"device_ids"`
+}
+
+// DeviceList is a page of devices
+type DeviceList struct {
+	Devices []Device `json:"devices"`
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+}
+
+// MetricPoint is one value of a device metric
+type MetricPoint struct {
+	Timestamp   time.Time         `json:"timestamp"`
+	MetricName  string            `json:"metric_name"`
+	MetricValue interface{}       `json:"metric_value"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// DeviceMetrics are the metrics a device reported in a time range
+type DeviceMetrics struct {
+	DeviceID string        `json:"device_id"`
+	Metrics  []MetricPoint `json:"metrics"`
+	Count    int           `json:"count"`
+}
+
+// WiringDiagram is a circuit of components and the connections between
+// their pins
+type WiringDiagram struct {
+	Components  []WiringComponent      `json:"components"`
+	Connections []WiringConnection     `json:"connections"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// WiringComponent is a hardware component of a wiring diagram. Metadata
+// carries details such as its voltage and interface.
+type WiringComponent struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// WiringConnection wires a pin of one component to a pin of another
+type WiringConnection struct {
+	FromComponent string `json:"from_component"`
+	FromPin       string `json:"from_pin"`
+	ToComponent   string `json:"to_component"`
+	ToPin         string `json:"to_pin"`
+	WireColor     string `json:"wire_color,omitempty"`
+}
+
+// SafetyCheckRequest asks for the safety report of a wiring diagram on a
+// board
+type SafetyCheckRequest struct {
+	Board         string        `json:"board"`
+	WiringDiagram WiringDiagram `json:"wiring_diagram"`
+}
+
+// SafetyReport is the electrical safety report of a wiring diagram. Valid
+// is false if any check failed; Errors explains why. Currents are in mA.
+type SafetyReport struct {
+	Valid            bool                    `json:"valid"`
+	Errors           []string                `json:"errors,omitempty"`
+	Warnings         []string                `json:"warnings,omitempty"`
+	VoltageChecks    []VoltageCheck          `json:"voltage_checks"`
+	CurrentChecks    []CurrentCheck          `json:"current_checks"`
+	PinCompatibility []PinCompatibilityCheck `json:"pin_compatibility"`
+}
+
+// VoltageCheck compares a component's voltage with the board's
+type VoltageCheck struct {
+	Component       string `json:"component"`
+	RequiredVoltage string `json:"required_voltage"`
+	SuppliedVoltage string `json:"supplied_voltage"`
+	Compatible      bool   `json:"compatible"`
+	Message         string `json:"message,omitempty"`
+}
+
+// CurrentCheck compares the current drawn from a pin with its limit
+type CurrentCheck struct {
+	Pin             string  `json:"pin"`
+	Component       string  `json:"component"`
+	RequiredCurrent float64 `json:"required_current"`
+	MaxCurrent      float64 `json:"max_current"`
+	Safe            bool    `json:"safe"`
+	Message         string  `json:"message,omitempty"`
+}
+
+// PinCompatibilityCheck checks a board pin supports what a component needs
+type PinCompatibilityCheck struct {
+	Pin        string `json:"pin"`
+	Component  string `json:"component"`
+	PinType    string `json:"pin_type"`
+	Required   string `json:"required"`
+	Compatible bool   `json:"compatible"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Release is a signed firmware release. Slots is set for firmware
+// installed into A/B app partitions. Environments are those the release was
+// promoted to, starting with the first of the pipeline.
+type Release struct {
+	ReleaseID       string            `json:"release_id"`
+	Name            string            `json:"name,omitempty"`
+	TemplateID      string            `json:"template_id"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	Version         string            `json:"version"`
+	Channel         string            `json:"channel"`
+	BinaryHash      string            `json:"binary_hash"`
+	BinarySize      int64             `json:"binary_size"`
+	Signature       string            `json:"signature"`
+	SigningKeyID    string            `json:"signing_key_id,omitempty"`
+	ReleaseNotes    string            `json:"release_notes"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Slots           *ReleaseSlots     `json:"slots,omitempty"`
+	Environments    []string          `json:"environments,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CreatedBy       string            `json:"created_by"`
+}
+
+// ReleaseSlots describes how a release is installed on devices with A/B
+// app partitions. TargetSlot is a partition such as ota_0, or "inactive"
+// for whichever the device is not running from.
+type ReleaseSlots struct {
+	TargetSlot   string   `json:"target_slot"`
+	RollbackSlot string   `json:"rollback_slot,omitempty"`
+	BootFlags    []string `json:"boot_flags,omitempty"`
+}
+
+// ReleaseList is a list of releases
+type ReleaseList struct {
+	Releases []Release `json:"releases"`
+}
+
+// DeploymentConfig controls how a release rolls out. Strategy is immediate,
+// staged or canary.
+type DeploymentConfig struct {
+	Name               string            `json:"name,omitempty"`
+	Strategy           string            `json:"strategy"`
+	TargetDevices      []string          `json:"target_devices"`
+	RolloutPercentage  int               `json:"rollout_percentage"`
+	FailureThreshold   int               `json:"failure_threshold"`
+	DataBudgetBytes    int64             `json:"data_budget_bytes,omitempty"`
+	StartAt            *time.Time        `json:"start_at,omitempty"`
+	EndAt              *time.Time        `json:"end_at,omitempty"`
+	MaintenanceWindows []string          `json:"maintenance_windows,omitempty"`
+	PromotionTiers     []int             `json:"promotion_tiers,omitempty"`
+	PromotionSuccess   int               `json:"promotion_success_percentage,omitempty"`
+	Annotations        map[string]string `json:"annotations,omitempty"`
+}
+
+// DeploymentRequest deploys a release
+type DeploymentRequest struct {
+	ReleaseID string           `json:"release_id"`
+	Config    DeploymentConfig `json:"config"`
+}
+
+// Approval records who approved or rejected a deployment
+type Approval struct {
+	Approver  string    `json:"approver"`
+	Decision  string    `json:"decision"`
+	Reason    string    `json:"reason,omitempty"`
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// Deployment is the rollout of a release to devices
+type Deployment struct {
+	DeploymentID      string            `json:"deployment_id"`
+	Name              string            `json:"name,omitempty"`
+	ReleaseID         string            `json:"release_id"`
+	Strategy          string            `json:"strategy"`
+	TargetDevices     []string          `json:"target_devices"`
+	RolloutPercentage int               `json:"rollout_percentage"`
+	Status            string            `json:"status"`
+	FailureThreshold  int               `json:"failure_threshold"`
+	SuccessCount      int               `json:"success_count"`
+	FailureCount      int               `json:"failure_count"`
+	BytesServed       int64             `json:"bytes_served"`
+	Approval          *Approval         `json:"approval,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// DeploymentList is a list of deployments
+type DeploymentList struct {
+	Deployments []Deployment `json:"deployments"`
+	Total       int          `json:"total"`
+}
+
+// DeploymentStatus is a deployment's progress, with its device updates
+// counted by status. FailureCodes counts the failed updates by the error
+// code their device reported.
+type DeploymentStatus struct {
+	DeploymentID       string         `json:"deployment_id"`
+	ReleaseID          string         `json:"release_id"`
+	Status             string         `json:"status"`
+	Strategy           string         `json:"strategy"`
+	RolloutPercentage  int            `json:"rollout_percentage"`
+	TotalDevices       int            `json:"total_devices"`
+	PendingCount       int            `json:"pending_count"`
+	DownloadingCount   int            `json:"downloading_count"`
+	InstallingCount    int            `json:"installing_count"`
+	CompletedCount     int            `json:"completed_count"`
+	FailedCount        int            `json:"failed_count"`
+	CancelledCount     int            `json:"cancelled_count"`
+	ProgressPercentage int            `json:"progress_percentage"`
+	FailureCodes       map[string]int `json:"failure_codes,omitempty"`
+	CrashCount         int            `json:"crash_count"`
+	CrashedDevices     int            `json:"crashed_devices"`
+	BytesServed        int64          `json:"bytes_served"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// Update error codes a device reports with a failed update
+const (
+	ErrorCodeDownloadTimeout   = "download_timeout"
+	ErrorCodeHashMismatch      = "hash_mismatch"
+	ErrorCodeSignatureInvalid  = "signature_invalid"
+	ErrorCodeFlashWriteError   = "flash_write_error"
+	ErrorCodeInsufficientSpace = "insufficient_space"
+	ErrorCodeBootVerifyFailed  = "boot_verify_failed"
+)
+
+// UpdateStatusReport is a device's report on its update to a release.
+// Status is pending, downloading, installing, completed or failed. A
+// failure carries one of the ErrorCode constants, which implies its
+// ErrorType (download, verification, install or boot). Downloaded is the
+// bytes of the binary the device holds, from which an interrupted download
+// resumes.
+type UpdateStatusReport struct {
+	ReleaseID    string `json:"release_id"`
+	Status       string `json:"status"`
+	Progress     int    `json:"progress"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	ErrorType    string `json:"error_type,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	Downloaded   int64  `json:"downloaded_bytes,omitempty"`
+}
+
+// BootConfirmation is sent by a device once it has booted a release, as
+// opposed to having flashed it. Slot is the partition it booted from.
+type BootConfirmation struct {
+	ReleaseID string `json:"release_id"`
+	Slot      string `json:"slot,omitempty"`
+}
+
+// PromotionRequest promotes a release from one environment to the next,
+// e.g. from staging to prod
+type PromotionRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PromotedResource is a resource a promotion reached: the release or a
+// template version it was built from. Promoted is false for resources that
+// were already in the target environment.
+type PromotedResource struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	Version  string `json:"version,omitempty"`
+	Promoted bool   `json:"promoted"`
+}
+
+// Promotion is the outcome of a promotion, dependencies first
+type Promotion struct {
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Resources []PromotedResource `json:"resources"`
+}
+
+// ApprovalRequest approves or rejects a deployment
+type ApprovalRequest struct {
+	Approver string `json:"approver"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Webhook receives signed OTA events. Events are deployment.created,
+// deployment.completed, deployment.failed, rollback.triggered and
+// device.update.failed; a webhook without events receives all of them.
+type Webhook struct {
+	WebhookID   string    `json:"webhook_id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookRequest registers a webhook. A secret is generated if none is
+// given.
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+	Secret      string   `json:"secret,omitempty"`
+}
+
+// WebhookList is a list of webhooks
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+	Count    int       `json:"count"`
+}
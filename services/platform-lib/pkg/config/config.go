@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,6 +17,9 @@ type Config struct {
 	HTTPPort    string `mapstructure:"http_port"`
 	GRPCPort    string `mapstructure:"grpc_port"`
 
+	// Log format, component levels and debug sampling
+	Logging LoggingConfig `mapstructure:"logging"`
+
 	// Database configuration
 	DatastoreProject string `mapstructure:"datastore_project"`
 	DatastoreHost    string `mapstructure:"datastore_host"`
@@ -47,6 +52,93 @@ type Config struct {
 
 	// Arduino CLI configuration
 	ArduinoCLIPath string `mapstructure:"arduino_cli_path"`
+
+	// Embedded web dashboard served by the API gateway
+	Dashboard DashboardConfig `mapstructure:"dashboard"`
+
+	// Single sign-on for human users
+	SSO SSOConfig `mapstructure:"sso"`
+
+	// Telemetry storage tuning
+	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+
+	// Self-onboarding options for generated firmware
+	Onboarding OnboardingConfig `mapstructure:"onboarding"`
+
+	// Import policy for community template bundles
+	Templates TemplatesConfig `mapstructure:"templates"`
+
+	// Fault injection for resilience testing (never in production)
+	Chaos ChaosConfig `mapstructure:"chaos"`
+
+	// Billable usage metering per project
+	Metering MeteringConfig `mapstructure:"metering"`
+
+	// Device credential expiry alerts and rotation
+	Credentials CredentialsConfig `mapstructure:"credentials"`
+
+	// Read-through cache for device records and firmware releases
+	Cache CacheConfig `mapstructure:"cache"`
+
+	// Firmware signing keys and binary storage of the OTA service
+	OTA OTAConfig `mapstructure:"ota"`
+
+	// Automatic OTA rollback safeguards
+	Rollback RollbackConfig `mapstructure:"rollback"`
+
+	// Authentication and rate limits for OTA update status reports
+	UpdateReports UpdateReportsConfig `mapstructure:"update_reports"`
+
+	// Binary diff OTA updates for bandwidth-constrained devices
+	Deltas DeltasConfig `mapstructure:"deltas"`
+
+	// Compressed firmware downloads for devices that can decompress them
+	FirmwareCompression FirmwareCompressionConfig `mapstructure:"firmware_compression"`
+
+	// Data budgets for OTA rollouts
+	Bandwidth BandwidthConfig `mapstructure:"bandwidth"`
+
+	// Start times and maintenance windows of scheduled OTA deployments
+	DeploymentSchedule DeploymentScheduleConfig `mapstructure:"deployment_schedule"`
+
+	// Automatic promotion of canary and staged OTA deployments
+	Promotion PromotionConfig `mapstructure:"promotion"`
+
+	// Timeout and requeue of OTA device updates whose device went quiet
+	StaleUpdates StaleUpdatesConfig `mapstructure:"stale_updates"`
+
+	// Pausing or rolling back OTA deployments whose devices' telemetry regresses
+	DeploymentHealth DeploymentHealthConfig `mapstructure:"deployment_health"`
+
+	// Garbage collection of old firmware releases
+	Retention RetentionConfig `mapstructure:"retention"`
+
+	// Firmware download links handed to devices
+	Downloads DownloadsConfig `mapstructure:"downloads"`
+
+	// Enrollment of devices that missed a rollout
+	CatchUp CatchUpConfig `mapstructure:"catch_up"`
+
+	// Named sets of devices OTA deployments can target
+	DeviceGroups []DeviceGroupConfig `mapstructure:"device_groups"`
+
+	// Environments templates and firmware releases are promoted through
+	Environments EnvironmentsConfig `mapstructure:"environments"`
+
+	// Release channels whose OTA deployments need approval
+	Approval ApprovalConfig `mapstructure:"approval"`
+
+	// Delivery of OTA events to registered webhooks
+	Webhooks WebhooksConfig `mapstructure:"webhooks"`
+
+	// Gateway endpoints turning events from external systems into actions
+	InboundWebhooks []InboundWebhookConfig `mapstructure:"inbound_webhooks"`
+
+	// Per-device capture of request and response bodies for debugging firmware
+	DebugCapture DebugCaptureConfig `mapstructure:"debug_capture"`
+
+	// ID scheme and generated names for devices, releases and deployments
+	IDs IDsConfig `mapstructure:"ids"`
 }
 
 // MQTTConfig holds MQTT-specific configuration
@@ -55,6 +147,442 @@ type MQTTConfig struct {
 	BrokerURL string `mapstructure:"broker_url"`
 	Username  string `mapstructure:"username"`
 	Password  string `mapstructure:"password"`
+
+	// TopicTemplate lays out device topics, e.g.
+	// "{tenant}/devices/{device_id}/{kind}". Empty uses the default layout.
+	TopicTemplate string `mapstructure:"topic_template"`
+	// Tenant fills the {tenant} level of TopicTemplate
+	Tenant string `mapstructure:"tenant"`
+}
+
+// DashboardConfig holds configuration for the gateway-hosted web dashboard
+type DashboardConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	BasePath string `mapstructure:"base_path"`
+}
+
+// TemplatesConfig controls template bundle import. With
+// RequireSignedBundles only bundles signed by a registered publisher are
+// imported; otherwise unsigned bundles are imported and flagged. With
+// RegressionBuilds the template service rebuilds templates against newly
+// released board cores and libraries every RegressionInterval. Preview
+// images are kept under ImagePath.
+type TemplatesConfig struct {
+	RequireSignedBundles bool          `mapstructure:"require_signed_bundles"`
+	RegressionBuilds     bool          `mapstructure:"regression_builds"`
+	RegressionInterval   time.Duration `mapstructure:"regression_interval"`
+	ImagePath            string        `mapstructure:"image_path"`
+}
+
+// ChaosConfig enables the fault injection middleware and admin API. It is
+// rejected in production.
+type ChaosConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// MeteringConfig controls usage metering. Devices belong to the project
+// named by their ProjectLabel label, or to DefaultProject. Quotas are
+// monthly limits by project and meter; the "*" project applies to projects
+// without their own.
+type MeteringConfig struct {
+	Enabled        bool                          `mapstructure:"enabled"`
+	ProjectLabel   string                        `mapstructure:"project_label"`
+	DefaultProject string                        `mapstructure:"default_project"`
+	FlushInterval  time.Duration                 `mapstructure:"flush_interval"`
+	Quotas         map[string]map[string]float64 `mapstructure:"quotas"`
+}
+
+// CredentialsConfig controls device credential expiry alerts. A
+// credential.expiring notification is sent as a credential enters each
+// ExpiryWarnings window, and credential.expired once it has expired.
+// Rotated tokens without an explicit expiry are valid for TokenLifetime.
+//
+// Provisioning is the credential issued to a device as it registers:
+// token, certificate or none. Certificates are signed by the device CA,
+// given as PEM in CACertificate and CAKey, usually through
+// ATHENA_CREDENTIALS_CA_CERTIFICATE and ATHENA_CREDENTIALS_CA_KEY, or read
+// from CACertificateFile and CAKeyFile; outside production a missing CA is
+// generated at startup. Signed certificates are valid for
+// CertificateLifetime. With RequireDeviceAuth, heartbeats and OTA device
+// requests must carry one of the device's credentials.
+type CredentialsConfig struct {
+	CheckInterval       time.Duration   `mapstructure:"check_interval"`
+	ExpiryWarnings      []time.Duration `mapstructure:"expiry_warnings"`
+	TokenLifetime       time.Duration   `mapstructure:"token_lifetime"`
+	Provisioning        string          `mapstructure:"provisioning"`
+	CertificateLifetime time.Duration   `mapstructure:"certificate_lifetime"`
+	CACertificate       string          `mapstructure:"ca_certificate"`
+	CAKey               string          `mapstructure:"ca_key"`
+	CACertificateFile   string          `mapstructure:"ca_certificate_file"`
+	CAKeyFile           string          `mapstructure:"ca_key_file"`
+	RequireDeviceAuth   bool            `mapstructure:"require_device_auth"`
+}
+
+// CacheConfig controls the read-through cache for device records and
+// firmware release metadata. Backend is memory, private to each process,
+// or redis, shared by the services so a write in one invalidates the entry
+// for all. A zero TTL disables caching of that kind.
+type CacheConfig struct {
+	Backend    string        `mapstructure:"backend"`
+	DeviceTTL  time.Duration `mapstructure:"device_ttl"`
+	ReleaseTTL time.Duration `mapstructure:"release_ttl"`
+	MaxEntries int           `mapstructure:"max_entries"`
+}
+
+// OTAConfig locates the firmware signing key pair and binary storage of
+// the OTA service. The PEM encoded keys are given in SigningKey and
+// SigningPublicKey, usually through ATHENA_OTA_SIGNING_KEY and
+// ATHENA_OTA_SIGNING_PUBLIC_KEY from a secret, or read from
+// SigningKeyFile and SigningPublicKeyFile. Outside production a missing
+// key pair is replaced by one generated at startup. Firmware binaries are
+// kept under StoragePath. Fleet policies are evaluated every
+// PolicyEvaluationInterval; zero evaluates them only on request.
+type OTAConfig struct {
+	SigningKey               string        `mapstructure:"signing_key"`
+	SigningPublicKey         string        `mapstructure:"signing_public_key"`
+	SigningKeyFile           string        `mapstructure:"signing_key_file"`
+	SigningPublicKeyFile     string        `mapstructure:"signing_public_key_file"`
+	StoragePath              string        `mapstructure:"storage_path"`
+	PolicyEvaluationInterval time.Duration `mapstructure:"policy_evaluation_interval"`
+}
+
+// RollbackConfig bounds automatic OTA rollback. MaxDepth is how many
+// rollbacks may be chained from the original deployment; the default of 1
+// never rolls back a rollback. A rollback deployment is not rolled back
+// automatically within Cooldown of being created. A deployment stopped by
+// either limit is paused for an operator instead.
+type RollbackConfig struct {
+	MaxDepth int           `mapstructure:"max_depth"`
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// UpdateReportsConfig controls OTA update status reports from devices.
+// With RequireDeviceToken, a report must carry one of the reporting
+// device's tracked token credentials as a bearer token. Each device may
+// send Burst reports at once, refilled at PerSecond.
+type UpdateReportsConfig struct {
+	RequireDeviceToken bool `mapstructure:"require_device_token"`
+	Burst              int  `mapstructure:"burst"`
+	PerSecond          int  `mapstructure:"per_second"`
+}
+
+// DeltasConfig controls delta OTA updates. With Enabled, creating a
+// release generates binary patches from the MaxBases most recent earlier
+// releases of the same template; a patch from an older release is
+// generated when a device running it first asks for one.
+type DeltasConfig struct {
+	Enabled  bool `mapstructure:"enabled"`
+	MaxBases int  `mapstructure:"max_bases"`
+}
+
+// FirmwareCompressionConfig controls compressed firmware downloads. With
+// Enabled, creating a release also stores its binary compressed with each
+// of Encodings (gzip, zstd) that makes it smaller. Devices naming one of
+// them when asking for their update download it instead of the raw image.
+type FirmwareCompressionConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Encodings []string `mapstructure:"encodings"`
+}
+
+// BandwidthConfig sets the data budget of OTA deployments created without
+// one, in bytes served to devices. A deployment going over its budget
+// raises a deployment.over_budget notification; 0 sets no budget.
+type BandwidthConfig struct {
+	DeploymentBudget int64 `mapstructure:"deployment_budget"`
+}
+
+// DeploymentScheduleConfig controls how often scheduled OTA deployments
+// are started, paused and resumed as their start time, end time and
+// maintenance windows come and go
+type DeploymentScheduleConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// PromotionConfig controls how deployments expand their rollout. Once the
+// devices updated so far reach SuccessPercentage, the rollout moves to the
+// next of its promotion tiers. Canary deployments created without tiers
+// get CanaryTiers.
+type PromotionConfig struct {
+	CheckInterval     time.Duration `mapstructure:"check_interval"`
+	SuccessPercentage int           `mapstructure:"success_percentage"`
+	CanaryTiers       []int         `mapstructure:"canary_tiers"`
+}
+
+// StaleUpdatesConfig controls the reaping of device updates stuck
+// downloading or installing. An update without a status report for Timeout
+// is offered to its device again until it has been tried MaxRequeues + 1
+// times, and then fails with error type timeout. A Timeout of zero
+// disables the reaper.
+type StaleUpdatesConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	MaxRequeues   int           `mapstructure:"max_requeues"`
+}
+
+// DeploymentHealthConfig controls the health monitor of OTA deployments
+// with health checks. Every CheckInterval the telemetry of each updated
+// device over Window after its update is compared with Window before it.
+// Deployments are judged once MinDevices updated devices have telemetry;
+// both apply to deployments that do not set their own. A CheckInterval of
+// zero disables the monitor.
+type DeploymentHealthConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	Window        time.Duration `mapstructure:"window"`
+	MinDevices    int           `mapstructure:"min_devices"`
+}
+
+// RetentionConfig controls the deletion of old firmware releases. Every
+// SweepInterval, releases that no rule keeps are deleted with their
+// binaries. Sweeping is off unless Enabled; the preview endpoint reports
+// what a sweep would delete either way.
+type RetentionConfig struct {
+	Enabled       bool                  `mapstructure:"enabled"`
+	SweepInterval time.Duration         `mapstructure:"sweep_interval"`
+	Rules         []RetentionRuleConfig `mapstructure:"rules"`
+}
+
+// RetentionRuleConfig applies to the releases of a template and channel;
+// an empty TemplateID or Channel matches any, and the first matching rule
+// applies. Of the releases of each template and channel, the KeepLast
+// newest and those younger than KeepDays days are kept. A rule setting
+// neither, and releases no rule matches, keep everything. Releases of
+// deployments still in progress are never deleted.
+type RetentionRuleConfig struct {
+	TemplateID string `mapstructure:"template_id"`
+	Channel    string `mapstructure:"channel"`
+	KeepLast   int    `mapstructure:"keep_last"`
+	KeepDays   int    `mapstructure:"keep_days"`
+}
+
+// DownloadsConfig controls the firmware download links given to devices.
+// URLExpiry applies to deployments that do not set their own. TokenURL is
+// where devices redeem one-time download tokens and may contain
+// {device_id} and {token}.
+type DownloadsConfig struct {
+	URLExpiry time.Duration `mapstructure:"url_expiry"`
+	TokenURL  string        `mapstructure:"token_url"`
+}
+
+// CatchUpConfig controls catch-up enrollment. With Enabled, a device that
+// checks for an update while running firmware older than the newest
+// release completed on its channel joins a trailing deployment of it.
+type CatchUpConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// EnvironmentsConfig lists the environments of a project in promotion
+// order, e.g. dev, staging and prod. Templates and firmware releases are
+// created in the first and promoted one environment at a time.
+type EnvironmentsConfig struct {
+	Names []string `mapstructure:"names"`
+}
+
+// DeviceGroupConfig names a set of devices: those listed in Devices and
+// those carrying every label in Labels. A deployment targeting the group
+// gets its devices built from the release's template.
+type DeviceGroupConfig struct {
+	Name    string            `mapstructure:"name"`
+	Devices []string          `mapstructure:"devices"`
+	Labels  map[string]string `mapstructure:"labels"`
+}
+
+// LoggingConfig controls service logs. Format is text or json, one object
+// per line for log collectors. Levels gives components, such as scheduler or
+// metering, a level other than log_level; both can be changed at runtime
+// through the admin API.
+type LoggingConfig struct {
+	Format   string            `mapstructure:"format"`
+	Levels   map[string]string `mapstructure:"levels"`
+	Sampling LogSamplingConfig `mapstructure:"sampling"`
+}
+
+// LogSamplingConfig thins out repetitive debug logs: per Tick, the first
+// Initial entries with the same message are logged, then every
+// Thereafter-th. Initial 0 turns sampling off.
+type LogSamplingConfig struct {
+	Initial    int           `mapstructure:"initial"`
+	Thereafter int           `mapstructure:"thereafter"`
+	Tick       time.Duration `mapstructure:"tick"`
+}
+
+// ApprovalConfig lists the release channels whose deployments wait in
+// pending_approval until approved. Rollbacks never wait.
+type ApprovalConfig struct {
+	Channels []string `mapstructure:"channels"`
+}
+
+// WebhooksConfig controls how OTA events are posted to webhooks. A
+// delivery that fails is tried up to MaxAttempts times in all, waiting
+// InitialBackoff before the first retry and twice as long before each next
+// one, up to MaxBackoff.
+type WebhooksConfig struct {
+	Timeout        time.Duration `mapstructure:"timeout"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+}
+
+// InboundWebhookConfig is an endpoint at /api/v1/hooks/{name} that turns
+// events from an external system into a platform action. Requests are
+// verified with Secret: Verify github checks the X-Hub-Signature-256 HMAC
+// of the body, token expects the secret as a bearer token or in
+// X-Webhook-Token. Filter is a CEL expression deciding which events to
+// act on, Transform a CEL expression producing the action's parameters;
+// both see the JSON body as body, the lowercased request headers as headers
+// and the hook name as hook. Action is ota_release, which downloads the
+// artifact_url parameter (with ArtifactToken as bearer token, if set) and
+// creates a release from it, or telemetry_alert. ArtifactHosts limits the
+// hosts artifacts are downloaded from, and is required with ArtifactToken
+// so the token is only sent to them.
+type InboundWebhookConfig struct {
+	Name          string   `mapstructure:"name"`
+	Secret        string   `mapstructure:"secret"`
+	Verify        string   `mapstructure:"verify"`
+	Filter        string   `mapstructure:"filter"`
+	Transform     string   `mapstructure:"transform"`
+	Action        string   `mapstructure:"action"`
+	ArtifactToken string   `mapstructure:"artifact_token"`
+	ArtifactHosts []string `mapstructure:"artifact_hosts"`
+}
+
+// DebugCaptureConfig bounds the per-device capture of telemetry and OTA
+// requests. Each service keeps the last MaxExchanges exchanges per device,
+// with bodies cut to MaxBodyBytes. A capture runs for DefaultDuration unless
+// asked otherwise, and never longer than MaxDuration.
+type DebugCaptureConfig struct {
+	MaxExchanges    int           `mapstructure:"max_exchanges"`
+	MaxBodyBytes    int           `mapstructure:"max_body_bytes"`
+	DefaultDuration time.Duration `mapstructure:"default_duration"`
+	MaxDuration     time.Duration `mapstructure:"max_duration"`
+}
+
+// IDsConfig controls how new devices, releases and deployments are
+// identified. Scheme is uuid (the default), prefixed, short or ulid. With
+// GenerateNames, resources created without a name are given an
+// adjective-noun name such as "brave-otter".
+type IDsConfig struct {
+	Scheme        string `mapstructure:"scheme"`
+	GenerateNames bool   `mapstructure:"generate_names"`
+}
+
+// SSOConfig holds configuration for OIDC single sign-on at the API gateway
+type SSOConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// PublicURL is the externally reachable gateway URL used for callbacks
+	PublicURL    string               `mapstructure:"public_url"`
+	DefaultRoles []string             `mapstructure:"default_roles"`
+	Providers    []OIDCProviderConfig `mapstructure:"providers"`
+}
+
+// TelemetryConfig holds telemetry service storage configuration
+type TelemetryConfig struct {
+	StorageHints []StorageHintConfig `mapstructure:"storage_hints"`
+	CloudBridges []CloudBridgeConfig `mapstructure:"cloud_bridges"`
+	LoRaWAN      LoRaWANConfig       `mapstructure:"lorawan"`
+	// LogRetention is how long device log lines are kept
+	LogRetention time.Duration `mapstructure:"log_retention"`
+	// ClockSkewTolerance is how far a device clock may drift before the
+	// timestamps it reports are corrected. Zero disables correction.
+	ClockSkewTolerance time.Duration `mapstructure:"clock_skew_tolerance"`
+	// ExportLinkExpiry is how long the download link of an asynchronous
+	// export stays valid
+	ExportLinkExpiry time.Duration `mapstructure:"export_link_expiry"`
+	// ExportPath is where finished asynchronous exports are kept
+	ExportPath string `mapstructure:"export_path"`
+	// MaxConcurrentExports bounds the asynchronous exports running at once;
+	// more are refused until one finishes
+	MaxConcurrentExports int `mapstructure:"max_concurrent_exports"`
+	// StreamAuthorization limits WebSocket telemetry streams to the devices
+	// and groups the caller may read. It needs the authenticated principal
+	// of the gateway.
+	StreamAuthorization bool `mapstructure:"stream_authorization"`
+	// StreamReauthorizeInterval is how often open streams are checked
+	// again, closing those whose permissions were revoked
+	StreamReauthorizeInterval time.Duration `mapstructure:"stream_reauthorize_interval"`
+	// HomeAssistant announces devices to Home Assistant over MQTT
+	HomeAssistant HomeAssistantConfig `mapstructure:"home_assistant"`
+}
+
+// HomeAssistantConfig publishes Home Assistant MQTT discovery messages for
+// devices and the metrics of their template's telemetry schema. Discovery
+// messages go under DiscoveryPrefix and readings to
+// {StateTopicPrefix}/{device_id}/{metric}. It needs MQTT.
+type HomeAssistantConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	DiscoveryPrefix  string `mapstructure:"discovery_prefix"`
+	StateTopicPrefix string `mapstructure:"state_topic_prefix"`
+}
+
+// StorageHintConfig selects a compact encoding for a high-frequency metric.
+// Encoding is float64, int16, int32, scaled or delta; Scale is the number of
+// decimal places kept.
+type StorageHintConfig struct {
+	Metric   string `mapstructure:"metric"`
+	Encoding string `mapstructure:"encoding"`
+	Scale    int    `mapstructure:"scale"`
+}
+
+// CloudBridgeConfig connects the telemetry service to AWS IoT Core
+// (provider aws_iot) or Azure IoT Hub (provider azure_iot_hub). Direction is
+// inbound, outbound or both. AWS bridges authenticate with an X.509
+// certificate; Azure bridges sign requests with a shared access policy that
+// has service connect, registry read and device connect permissions.
+type CloudBridgeConfig struct {
+	Name          string `mapstructure:"name"`
+	Provider      string `mapstructure:"provider"`
+	Direction     string `mapstructure:"direction"`
+	Endpoint      string `mapstructure:"endpoint"`
+	TopicTemplate string `mapstructure:"topic_template"`
+	ClientID      string `mapstructure:"client_id"`
+	CertFile      string `mapstructure:"cert_file"`
+	KeyFile       string `mapstructure:"key_file"`
+	CAFile        string `mapstructure:"ca_file"`
+	PolicyName    string `mapstructure:"policy_name"`
+	PolicyKey     string `mapstructure:"policy_key"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
+	SyncState     bool   `mapstructure:"sync_state"`
+}
+
+// LoRaWANConfig configures the uplink webhook for The Things Network and
+// ChirpStack. WebhookSecret must be passed as the code query parameter;
+// Decoders preloads payload decoders by template, replacing at startup any
+// decoder set through the API for the same template.
+type LoRaWANConfig struct {
+	WebhookSecret string                 `mapstructure:"webhook_secret"`
+	Decoders      []PayloadDecoderConfig `mapstructure:"decoders"`
+}
+
+// PayloadDecoderConfig maps metric names to payload expressions for the
+// uplinks of devices built from a template
+type PayloadDecoderConfig struct {
+	TemplateID        string            `mapstructure:"template_id"`
+	Fields            map[string]string `mapstructure:"fields"`
+	Ports             []int             `mapstructure:"ports"`
+	UseNetworkDecoded bool              `mapstructure:"use_network_decoded"`
+}
+
+// OnboardingConfig holds defaults for captive portal onboarding code.
+// ReportURL is where firmware reports the network it joined and may contain
+// {device_id}.
+type OnboardingConfig struct {
+	ReportURL string `mapstructure:"report_url"`
+}
+
+// OIDCProviderConfig configures one identity provider. Type is google,
+// github or oidc; google and github have well-known endpoints, oidc
+// providers are discovered from IssuerURL.
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name"`
+	Type         string   `mapstructure:"type"`
+	IssuerURL    string   `mapstructure:"issuer_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+	// GroupsClaim is the ID token claim holding the user's groups
+	GroupsClaim string `mapstructure:"groups_claim"`
+	// RoleMappings maps IdP groups to platform roles
+	RoleMappings   map[string][]string `mapstructure:"role_mappings"`
+	AllowedDomains []string            `mapstructure:"allowed_domains"`
 }
 
 // Load loads configuration for the specified service
@@ -110,6 +638,14 @@ func Default(serviceName string) *Config {
 		RedisAddr:        "localhost:6379",
 		RedisPassword:    "",
 		RedisDB:          0,
+		Logging: LoggingConfig{
+			Format: "text",
+			Sampling: LogSamplingConfig{
+				Initial:    100,
+				Thereafter: 100,
+				Tick:       time.Second,
+			},
+		},
 		MQTT: MQTTConfig{
 			Enabled:   true,
 			BrokerURL: "tcp://localhost:1883",
@@ -136,6 +672,119 @@ func Default(serviceName string) *Config {
 		LLMAPIKey:            "",
 		LLMEndpoint:          "https://api.openai.com/v1",
 		ArduinoCLIPath:       "arduino-cli",
+		Dashboard: DashboardConfig{
+			Enabled:  true,
+			BasePath: "/dashboard",
+		},
+		SSO: SSOConfig{
+			DefaultRoles: []string{"viewer"},
+		},
+		Telemetry: TelemetryConfig{
+			LogRetention:              7 * 24 * time.Hour,
+			ClockSkewTolerance:        2 * time.Second,
+			ExportLinkExpiry:          time.Hour,
+			ExportPath:                "./data/exports",
+			MaxConcurrentExports:      4,
+			StreamReauthorizeInterval: time.Minute,
+			HomeAssistant: HomeAssistantConfig{
+				DiscoveryPrefix:  "homeassistant",
+				StateTopicPrefix: "athena",
+			},
+		},
+		Onboarding: OnboardingConfig{
+			ReportURL: "http://localhost:8000/api/v1/devices/{device_id}/onboarding",
+		},
+		Metering: MeteringConfig{
+			Enabled:        true,
+			ProjectLabel:   "project",
+			DefaultProject: "default",
+			FlushInterval:  time.Minute,
+		},
+		Credentials: CredentialsConfig{
+			CheckInterval:  time.Hour,
+			ExpiryWarnings: []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour},
+			TokenLifetime:  365 * 24 * time.Hour,
+
+			Provisioning:        "token",
+			CertificateLifetime: 365 * 24 * time.Hour,
+		},
+		Cache: CacheConfig{
+			Backend:    "memory",
+			DeviceTTL:  30 * time.Second,
+			ReleaseTTL: 10 * time.Minute,
+			MaxEntries: 10000,
+		},
+		OTA: OTAConfig{
+			StoragePath:              "./data/firmware",
+			PolicyEvaluationInterval: 15 * time.Minute,
+		},
+		Rollback: RollbackConfig{
+			MaxDepth: 1,
+			Cooldown: 30 * time.Minute,
+		},
+		UpdateReports: UpdateReportsConfig{
+			RequireDeviceToken: true,
+			Burst:              10,
+			PerSecond:          1,
+		},
+		Deltas: DeltasConfig{
+			Enabled:  true,
+			MaxBases: 3,
+		},
+		FirmwareCompression: FirmwareCompressionConfig{
+			Enabled:   true,
+			Encodings: []string{"zstd", "gzip"},
+		},
+		DeploymentSchedule: DeploymentScheduleConfig{
+			CheckInterval: time.Minute,
+		},
+		Promotion: PromotionConfig{
+			CheckInterval:     time.Minute,
+			SuccessPercentage: 95,
+			CanaryTiers:       []int{25, 100},
+		},
+		StaleUpdates: StaleUpdatesConfig{
+			CheckInterval: 5 * time.Minute,
+			Timeout:       time.Hour,
+			MaxRequeues:   1,
+		},
+		DeploymentHealth: DeploymentHealthConfig{
+			CheckInterval: 5 * time.Minute,
+			Window:        time.Hour,
+			MinDevices:    3,
+		},
+		Retention: RetentionConfig{
+			SweepInterval: 24 * time.Hour,
+		},
+		Downloads: DownloadsConfig{
+			URLExpiry: time.Hour,
+			TokenURL:  "http://localhost:8000/api/v1/ota/devices/{device_id}/downloads/{token}",
+		},
+		CatchUp: CatchUpConfig{
+			Enabled: true,
+		},
+		Environments: EnvironmentsConfig{
+			Names: []string{"dev", "staging", "prod"},
+		},
+		Approval: ApprovalConfig{
+			Channels: []string{"stable"},
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:        10 * time.Second,
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		},
+		DebugCapture: DebugCaptureConfig{
+			MaxExchanges:    200,
+			MaxBodyBytes:    64 * 1024,
+			DefaultDuration: 15 * time.Minute,
+			MaxDuration:     4 * time.Hour,
+		},
+		IDs: IDsConfig{
+			Scheme:        "uuid",
+			GenerateNames: true,
+		},
 	}
 }
 
@@ -143,6 +792,10 @@ func setDefaults(serviceName string) {
 	viper.SetDefault("service_name", serviceName)
 	viper.SetDefault("environment", "development")
 	viper.SetDefault("log_level", "info")
+	viper.SetDefault("logging.format", "text")
+	viper.SetDefault("logging.sampling.initial", 100)
+	viper.SetDefault("logging.sampling.thereafter", 100)
+	viper.SetDefault("logging.sampling.tick", "1s")
 	viper.SetDefault("http_port", getDefaultHTTPPort(serviceName))
 	viper.SetDefault("grpc_port", getDefaultGRPCPort(serviceName))
 	viper.SetDefault("datastore_project", "athena-dev")
@@ -154,6 +807,8 @@ func setDefaults(serviceName string) {
 	viper.SetDefault("mqtt.broker_url", "tcp://localhost:1883")
 	viper.SetDefault("mqtt.username", "")
 	viper.SetDefault("mqtt.password", "")
+	viper.SetDefault("mqtt.topic_template", "")
+	viper.SetDefault("mqtt.tenant", "")
 	viper.SetDefault("minio_endpoint", "localhost:9000")
 	viper.SetDefault("minio_access_key", "athena")
 	viper.SetDefault("minio_secret_key", "dev_password")
@@ -163,6 +818,87 @@ func setDefaults(serviceName string) {
 	viper.SetDefault("llm_provider", "openai")
 	viper.SetDefault("llm_endpoint", "https://api.openai.com/v1")
 	viper.SetDefault("arduino_cli_path", "arduino-cli")
+	viper.SetDefault("dashboard.enabled", true)
+	viper.SetDefault("dashboard.base_path", "/dashboard")
+	viper.SetDefault("sso.enabled", false)
+	viper.SetDefault("sso.default_roles", []string{"viewer"})
+	viper.SetDefault("telemetry.log_retention", "168h")
+	viper.SetDefault("telemetry.clock_skew_tolerance", "2s")
+	viper.SetDefault("telemetry.export_link_expiry", "1h")
+	viper.SetDefault("telemetry.export_path", "./data/exports")
+	viper.SetDefault("telemetry.max_concurrent_exports", 4)
+	viper.SetDefault("telemetry.stream_authorization", false)
+	viper.SetDefault("telemetry.stream_reauthorize_interval", "1m")
+	viper.SetDefault("telemetry.home_assistant.enabled", false)
+	viper.SetDefault("telemetry.home_assistant.discovery_prefix", "homeassistant")
+	viper.SetDefault("telemetry.home_assistant.state_topic_prefix", "athena")
+	viper.SetDefault("chaos.enabled", false)
+	viper.SetDefault("templates.require_signed_bundles", false)
+	viper.SetDefault("templates.regression_builds", false)
+	viper.SetDefault("templates.regression_interval", "24h")
+	viper.SetDefault("templates.image_path", "./data/template-images")
+	viper.SetDefault("metering.enabled", true)
+	viper.SetDefault("metering.project_label", "project")
+	viper.SetDefault("metering.default_project", "default")
+	viper.SetDefault("metering.flush_interval", "1m")
+	viper.SetDefault("credentials.check_interval", "1h")
+	viper.SetDefault("credentials.expiry_warnings", []string{"720h", "168h", "24h"})
+	viper.SetDefault("credentials.token_lifetime", "8760h")
+	viper.SetDefault("credentials.provisioning", "token")
+	viper.SetDefault("credentials.certificate_lifetime", "8760h")
+	viper.SetDefault("credentials.ca_certificate", "")
+	viper.SetDefault("credentials.ca_key", "")
+	viper.SetDefault("credentials.ca_certificate_file", "")
+	viper.SetDefault("credentials.ca_key_file", "")
+	viper.SetDefault("credentials.require_device_auth", false)
+	viper.SetDefault("cache.backend", "memory")
+	viper.SetDefault("cache.device_ttl", "30s")
+	viper.SetDefault("cache.release_ttl", "10m")
+	viper.SetDefault("cache.max_entries", 10000)
+	viper.SetDefault("ota.signing_key", "")
+	viper.SetDefault("ota.signing_public_key", "")
+	viper.SetDefault("ota.signing_key_file", "")
+	viper.SetDefault("ota.signing_public_key_file", "")
+	viper.SetDefault("ota.storage_path", "./data/firmware")
+	viper.SetDefault("ota.policy_evaluation_interval", "15m")
+	viper.SetDefault("rollback.max_depth", 1)
+	viper.SetDefault("rollback.cooldown", "30m")
+	viper.SetDefault("update_reports.require_device_token", true)
+	viper.SetDefault("update_reports.burst", 10)
+	viper.SetDefault("update_reports.per_second", 1)
+	viper.SetDefault("deltas.enabled", true)
+	viper.SetDefault("deltas.max_bases", 3)
+	viper.SetDefault("firmware_compression.enabled", true)
+	viper.SetDefault("firmware_compression.encodings", []string{"zstd", "gzip"})
+	viper.SetDefault("bandwidth.deployment_budget", 0)
+	viper.SetDefault("deployment_schedule.check_interval", "1m")
+	viper.SetDefault("promotion.check_interval", "1m")
+	viper.SetDefault("promotion.success_percentage", 95)
+	viper.SetDefault("promotion.canary_tiers", []int{25, 100})
+	viper.SetDefault("stale_updates.check_interval", "5m")
+	viper.SetDefault("stale_updates.timeout", "1h")
+	viper.SetDefault("stale_updates.max_requeues", 1)
+	viper.SetDefault("deployment_health.check_interval", "5m")
+	viper.SetDefault("deployment_health.window", "1h")
+	viper.SetDefault("deployment_health.min_devices", 3)
+	viper.SetDefault("retention.enabled", false)
+	viper.SetDefault("retention.sweep_interval", "24h")
+	viper.SetDefault("downloads.url_expiry", "1h")
+	viper.SetDefault("downloads.token_url", "http://localhost:8000/api/v1/ota/devices/{device_id}/downloads/{token}")
+	viper.SetDefault("catch_up.enabled", true)
+	viper.SetDefault("environments.names", []string{"dev", "staging", "prod"})
+	viper.SetDefault("approval.channels", []string{"stable"})
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.max_attempts", 5)
+	viper.SetDefault("webhooks.initial_backoff", "1s")
+	viper.SetDefault("webhooks.max_backoff", "1m")
+	viper.SetDefault("debug_capture.max_exchanges", 200)
+	viper.SetDefault("debug_capture.max_body_bytes", 65536)
+	viper.SetDefault("debug_capture.default_duration", "15m")
+	viper.SetDefault("debug_capture.max_duration", "4h")
+	viper.SetDefault("ids.scheme", "uuid")
+	viper.SetDefault("ids.generate_names", true)
+	viper.SetDefault("onboarding.report_url", "http://localhost:8000/api/v1/devices/{device_id}/onboarding")
 }
 
 func getDefaultHTTPPort(serviceName string) string {
@@ -209,8 +945,46 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("SECRETS_ENCRYPTION_KEY environment variable is required")
 	}
 
+	if backend := config.Cache.Backend; backend != "" && backend != "memory" && backend != "redis" {
+		return fmt.Errorf("cache.backend must be memory or redis, got %q", backend)
+	}
+
+	switch config.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("logging.format must be text or json, got %q", config.Logging.Format)
+	}
+
+	switch config.IDs.Scheme {
+	case "", "uuid", "prefixed", "short", "ulid":
+	default:
+		return fmt.Errorf("ids.scheme must be uuid, prefixed, short or ulid, got %q", config.IDs.Scheme)
+	}
+
+	switch config.Credentials.Provisioning {
+	case "", "token", "certificate", "none":
+	default:
+		return fmt.Errorf("credentials.provisioning must be token, certificate or none, got %q", config.Credentials.Provisioning)
+	}
+
+	if config.Rollback.MaxDepth < 0 {
+		return fmt.Errorf("rollback.max_depth must not be negative")
+	}
+
+	for i, name := range config.Environments.Names {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("environments.names must not be empty")
+		}
+		if slices.Contains(config.Environments.Names[:i], name) {
+			return fmt.Errorf("environments.names lists %q twice", name)
+		}
+	}
+
 	// For production environment, enforce stricter validation
 	if config.Environment == "production" {
+		if config.Chaos.Enabled {
+			return fmt.Errorf("chaos.enabled must not be set in production")
+		}
 		if len(config.JWTSecret) < 32 {
 			return fmt.Errorf("JWT_SECRET must be at least 32 characters in production")
 		}
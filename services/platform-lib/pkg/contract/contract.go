@@ -0,0 +1,144 @@
+// Package contract implements consumer-driven contract tests between the
+// platform's HTTP clients and the services they call.
+//
+// A consumer's tests run its client against a MockProvider, which answers
+// with the responses the consumer declares and records the requests the
+// client actually sends. The resulting contracts are committed under
+// services/platform-lib/contracts. Each provider's tests replay the
+// contracts it appears in against its real handlers, and the gateway's tests
+// replay all of them through its proxy routes, so a payload change on either
+// side fails a test before it reaches a deployment.
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Contract is the set of interactions a consumer relies on from a provider
+type Contract struct {
+	Consumer     string         `json:"consumer"`
+	Provider     string         `json:"provider"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is one request a consumer sends and the response it expects.
+// State names the data the provider must hold for the response to apply,
+// e.g. "device dev-1 exists".
+type Interaction struct {
+	Description string   `json:"description"`
+	State       string   `json:"state,omitempty"`
+	Request     Request  `json:"request"`
+	Response    Response `json:"response"`
+}
+
+// Request is the HTTP request a consumer sends. Query is the encoded query
+// string without the leading "?".
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the status and body a consumer expects. The body is an
+// example: providers must return every field in it with the same JSON type,
+// but values may differ and extra fields are allowed.
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// FileName returns the file a consumer's contract with a provider is kept in
+func FileName(consumer, provider string) string {
+	return consumer + "-" + provider + ".json"
+}
+
+// Load reads a contract file
+func Load(path string) (*Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var contract Contract
+	if err := json.Unmarshal(data, &contract); err != nil {
+		return nil, fmt.Errorf("invalid contract %s: %w", path, err)
+	}
+	return &contract, nil
+}
+
+// LoadDir reads every contract in dir, keeping those with the given
+// provider. An empty provider keeps all of them.
+func LoadDir(dir, provider string) ([]*Contract, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var contracts []*Contract
+	for _, path := range paths {
+		contract, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if provider == "" || contract.Provider == provider {
+			contracts = append(contracts, contract)
+		}
+	}
+	return contracts, nil
+}
+
+// Marshal encodes a contract the way it is committed: indented, with a
+// trailing newline
+func (c *Contract) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Example encodes v as an example request or response body
+func Example(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode example: %w", err)
+	}
+	return data, nil
+}
+
+// UpdateEnv is the environment variable that makes Sync rewrite committed
+// contracts instead of comparing against them
+const UpdateEnv = "ATHENA_UPDATE_CONTRACTS"
+
+// Sync checks a consumer's contract against the committed copy in dir, or
+// rewrites the copy when UpdateEnv is set
+func Sync(dir string, c *Contract) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, FileName(c.Consumer, c.Provider))
+
+	if os.Getenv(UpdateEnv) != "" {
+		return os.WriteFile(path, data, 0644)
+	}
+
+	committed, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("contract %s is missing, rerun with %s=1: %w", path, UpdateEnv, err)
+	}
+	if !bytes.Equal(committed, data) {
+		return fmt.Errorf("contract %s is stale, rerun with %s=1 and review the diff", path, UpdateEnv)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package contract
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	expected := []byte(`{"id":"dev-1","count":1,"labels":null,"items":[{"name":"a","value":1.5}]}`)
+
+	assert.Empty(t, Match(expected, []byte(`{"id":"dev-9","count":3,"extra":true,"labels":{"site":"lab"},"items":[{"name":"b","value":2},{"name":"c","value":0}]}`)))
+	assert.Empty(t, Match(nil, []byte(`not json`)))
+
+	assert.Equal(t, []string{"$.count: expected number, got string"}, Match(expected, []byte(`{"id":"dev-1","count":"1","labels":null,"items":[{"name":"a","value":1}]}`)))
+	assert.Equal(t, []string{"$.id: missing"}, Match(expected, []byte(`{"count":1,"labels":null,"items":[{"name":"a","value":1}]}`)))
+	assert.Equal(t, []string{"$.items: expected at least one element"}, Match(expected, []byte(`{"id":"dev-1","count":1,"labels":null,"items":[]}`)))
+	assert.Equal(t, []string{"$.items[1].value: missing"}, Match(expected, []byte(`{"id":"dev-1","count":1,"labels":null,"items":[{"name":"a","value":1},{"name":"b"}]}`)))
+	assert.Len(t, Match(expected, []byte(`<html>`)), 1)
+}
+
+func TestMockProvider_RecordsContract(t *testing.T) {
+	provider := NewMockProvider("athena-cli", "device-service")
+	defer provider.Close()
+
+	provider.AddInteraction(&Interaction{
+		Description: "register a device",
+		State:       "device dev-2 does not exist",
+		Request:     Request{Method: http.MethodPost, Path: "/api/v1/devices", Query: "dry_run=true"},
+		Response:    Response{Status: http.StatusCreated, Body: []byte(`{"device_id":"dev-2"}`)},
+	})
+
+	resp, err := http.Post(provider.URL()+"/api/v1/devices?dry_run=true", "application/json", strings.NewReader(`{ "device_id": "dev-2" }`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	c, err := provider.Contract()
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.JSONEq(t, `{"device_id":"dev-2"}`, string(c.Interactions[0].Request.Body))
+	assert.Equal(t, "athena-cli-device-service.json", FileName(c.Consumer, c.Provider))
+}
+
+func TestMockProvider_ReportsDrift(t *testing.T) {
+	provider := NewMockProvider("athena-cli", "device-service")
+	defer provider.Close()
+
+	provider.AddInteraction(&Interaction{
+		Description: "get a device",
+		Request:     Request{Method: http.MethodGet, Path: "/api/v1/devices/dev-1"},
+		Response:    Response{Status: http.StatusOK},
+	})
+
+	resp, err := http.Get(provider.URL() + "/api/v1/devices/dev-1?verbose=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	_, err = provider.Contract()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected request GET /api/v1/devices/dev-1?verbose=1")
+	assert.Contains(t, err.Error(), `"get a device" was never requested`)
+}
+
+func TestInteraction_Verify(t *testing.T) {
+	interaction := &Interaction{
+		Description: "get a device",
+		Request:     Request{Method: http.MethodGet, Path: "/api/v1/devices/dev-1"},
+		Response:    Response{Status: http.StatusOK, Body: []byte(`{"device_id":"dev-1","status":"online"}`)},
+	}
+
+	handler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+	assert.NoError(t, interaction.Verify(handler(`{"device_id":"dev-7","status":"offline","labels":{}}`)))
+
+	err := interaction.Verify(handler(`{"id":"dev-1","status":"online"}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "$.device_id: missing")
+
+	err = interaction.Verify(http.NotFoundHandler())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected status 200, got 404")
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	c := &Contract{
+		Consumer: "athena-cli",
+		Provider: "ota-service",
+		Interactions: []*Interaction{{
+			Description: "get a deployment",
+			Request:     Request{Method: http.MethodGet, Path: "/api/v1/ota/deployments/deploy-1"},
+			Response:    Response{Status: http.StatusOK, Body: []byte(`{"deployment_id":"deploy-1"}`)},
+		}},
+	}
+
+	t.Setenv(UpdateEnv, "")
+	err := Sync(dir, c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is missing")
+
+	t.Setenv(UpdateEnv, "1")
+	require.NoError(t, Sync(dir, c))
+
+	t.Setenv(UpdateEnv, "")
+	require.NoError(t, Sync(dir, c))
+	loaded, err := LoadDir(dir, "ota-service")
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, c.Interactions[0].Request, loaded[0].Interactions[0].Request)
+
+	c.Interactions[0].Response.Status = http.StatusNotFound
+	err = Sync(dir, c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is stale")
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName(c.Consumer, c.Provider)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "}\n"))
+}
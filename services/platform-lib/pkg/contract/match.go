@@ -0,0 +1,91 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Match compares an actual JSON body with an expected example and returns
+// one message per mismatch. Objects must contain every expected field, with
+// extra fields allowed; values only need the same JSON type; every element
+// of an array must match the first expected element; and an expected null
+// matches anything.
+func Match(expected, actual []byte) []string {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		return []string{fmt.Sprintf("$: invalid expected body: %v", err)}
+	}
+	if err := json.Unmarshal(actual, &got); err != nil {
+		return []string{fmt.Sprintf("$: response is not JSON: %v", err)}
+	}
+
+	var mismatches []string
+	match("$", want, got, &mismatches)
+	return mismatches
+}
+
+func match(path string, want, got interface{}, mismatches *[]string) {
+	if want == nil {
+		return
+	}
+	if jsonType(want) != jsonType(got) {
+		*mismatches = append(*mismatches, fmt.Sprintf("%s: expected %s, got %s", path, jsonType(want), jsonType(got)))
+		return
+	}
+
+	switch want := want.(type) {
+	case map[string]interface{}:
+		got := got.(map[string]interface{})
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			value, ok := got[key]
+			if !ok {
+				*mismatches = append(*mismatches, fmt.Sprintf("%s.%s: missing", path, key))
+				continue
+			}
+			match(path+"."+key, want[key], value, mismatches)
+		}
+
+	case []interface{}:
+		got := got.([]interface{})
+		if len(want) == 0 {
+			return
+		}
+		if len(got) == 0 {
+			*mismatches = append(*mismatches, fmt.Sprintf("%s: expected at least one element", path))
+			return
+		}
+		for i, element := range got {
+			match(fmt.Sprintf("%s[%d]", path, i), want[0], element, mismatches)
+		}
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
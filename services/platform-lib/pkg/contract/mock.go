@@ -0,0 +1,135 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// MockProvider stands in for a provider while a consumer's client runs. It
+// answers each declared interaction once and records the request body the
+// client sent, so the contract captures what the client really does.
+type MockProvider struct {
+	consumer string
+	provider string
+	server   *httptest.Server
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	received     []bool
+	unexpected   []string
+}
+
+// NewMockProvider starts a mock provider. Close it when the consumer's
+// tests are done.
+func NewMockProvider(consumer, provider string) *MockProvider {
+	m := &MockProvider{consumer: consumer, provider: provider}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// URL returns the base URL the consumer should call
+func (m *MockProvider) URL() string {
+	return m.server.URL
+}
+
+// Close stops the mock provider
+func (m *MockProvider) Close() {
+	m.server.Close()
+}
+
+// AddInteraction declares a request the consumer is about to send and the
+// response to give it. The request body is taken from the consumer.
+func (m *MockProvider) AddInteraction(interaction *Interaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interactions = append(m.interactions, interaction)
+	m.received = append(m.received, false)
+}
+
+// Contract returns the consumer's contract with the provider. It fails if
+// the consumer sent a request that was not declared or never sent one that
+// was.
+func (m *MockProvider) Contract() (*Contract, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	problems := append([]string(nil), m.unexpected...)
+	for i, interaction := range m.interactions {
+		if !m.received[i] {
+			problems = append(problems, fmt.Sprintf("%q was never requested", interaction.Description))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s contract with %s: %s", m.consumer, m.provider, strings.Join(problems, "; "))
+	}
+
+	return &Contract{
+		Consumer:     m.consumer,
+		Provider:     m.provider,
+		Interactions: m.interactions,
+	}, nil
+}
+
+func (m *MockProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, interaction := range m.interactions {
+		if m.received[i] || !interaction.Request.matches(r) {
+			continue
+		}
+		m.received[i] = true
+
+		if len(bytes.TrimSpace(body)) > 0 {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, body); err != nil {
+				m.unexpected = append(m.unexpected, fmt.Sprintf("%s %s sent a body that is not JSON", r.Method, r.URL.Path))
+			}
+			interaction.Request.Body = compact.Bytes()
+		}
+
+		writeResponse(w, interaction.Response)
+		return
+	}
+
+	m.unexpected = append(m.unexpected, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.RequestURI()))
+	http.Error(w, "no interaction matches this request", http.StatusInternalServerError)
+}
+
+// matches reports whether r has the interaction's method, path and query
+func (req Request) matches(r *http.Request) bool {
+	if r.Method != req.Method || r.URL.Path != req.Path {
+		return false
+	}
+	want, err := url.ParseQuery(req.Query)
+	if err != nil {
+		return false
+	}
+	got := r.URL.Query()
+	if len(want) == 0 && len(got) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func writeResponse(w http.ResponseWriter, response Response) {
+	if len(response.Body) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
@@ -0,0 +1,65 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// HTTPRequest builds the interaction's request against a base URL, which
+// may be empty to build a request for an http.Handler
+func (i *Interaction) HTTPRequest(baseURL string) (*http.Request, error) {
+	target := baseURL + i.Request.Path
+	if i.Request.Query != "" {
+		target += "?" + i.Request.Query
+	}
+
+	var body io.Reader
+	if len(i.Request.Body) > 0 {
+		body = bytes.NewReader(i.Request.Body)
+	}
+	req, err := http.NewRequest(i.Request.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// Verify replays the interaction against a provider's handler. The
+// provider must already hold the interaction's state.
+func (i *Interaction) Verify(handler http.Handler) error {
+	req, err := i.HTTPRequest("")
+	if err != nil {
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return i.CheckResponse(w.Code, w.Body.Bytes())
+}
+
+// CheckResponse compares a provider's response with the one the consumer
+// expects
+func (i *Interaction) CheckResponse(status int, body []byte) error {
+	if status != i.Response.Status {
+		return fmt.Errorf("%s: expected status %d, got %d: %s", i.Description, i.Response.Status, status, truncate(body))
+	}
+	if mismatches := Match(i.Response.Body, body); len(mismatches) > 0 {
+		return fmt.Errorf("%s: response does not match the contract:\n  %s", i.Description, strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+func truncate(body []byte) string {
+	const max = 200
+	if len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,526 @@
+// Package debugcapture records the full requests and responses of devices
+// being debugged, so that firmware which serializes JSON wrongly or
+// misreads a response can be diagnosed from the device's debug bundle.
+// Capture is switched on per device for a limited time, and secrets are
+// redacted before anything is kept.
+package debugcapture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// retention is how long the exchanges of a finished capture are kept
+	retention = 24 * time.Hour
+
+	// redacted replaces secret header, parameter and JSON values
+	redacted = "[redacted]"
+)
+
+var (
+	ErrCaptureNotFound = errors.New("capture not found")
+	ErrInvalidCapture  = errors.New("invalid capture")
+)
+
+// sensitiveNames are the parts of header, parameter and JSON key names whose
+// values are never recorded
+var sensitiveNames = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie",
+	"signature", "credential", "private_key", "api_key", "api-key", "apikey", "psk",
+}
+
+// sensitiveJSONValue matches string values of sensitive keys in bodies that
+// are not valid JSON, such as truncated or malformed ones
+var sensitiveJSONValue = regexp.MustCompile(`(?i)("[^"]*(?:` + strings.Join(sensitiveNames, "|") + `)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// jsonDeviceID finds the device_id field of a body that is not valid JSON
+var jsonDeviceID = regexp.MustCompile(`"device_id"\s*:\s*"([^"\\]+)"`)
+
+// Exchange is one recorded request and its response. Bodies that are not
+// UTF-8 text, such as firmware images, are left out; their sizes are kept.
+type Exchange struct {
+	Time              time.Time         `json:"time"`
+	Method            string            `json:"method"`
+	Route             string            `json:"route"`
+	Path              string            `json:"path"`
+	Query             string            `json:"query,omitempty"`
+	RequestHeaders    map[string]string `json:"request_headers,omitempty"`
+	RequestBody       string            `json:"request_body,omitempty"`
+	RequestSize       int               `json:"request_size"`
+	RequestTruncated  bool              `json:"request_truncated,omitempty"`
+	Status            int               `json:"status"`
+	ResponseBody      string            `json:"response_body,omitempty"`
+	ResponseSize      int               `json:"response_size"`
+	ResponseTruncated bool              `json:"response_truncated,omitempty"`
+	DurationMS        int64             `json:"duration_ms"`
+}
+
+// Capture is a device's capture session and the exchanges recorded so far,
+// oldest first. Dropped counts the exchanges pushed out of the buffer.
+type Capture struct {
+	DeviceID  string      `json:"device_id"`
+	Service   string      `json:"service"`
+	Active    bool        `json:"active"`
+	StartedAt time.Time   `json:"started_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	Exchanges []*Exchange `json:"exchanges"`
+	Dropped   int         `json:"dropped"`
+}
+
+// CaptureRequest starts a capture. DurationSeconds defaults to
+// debug_capture.default_duration.
+type CaptureRequest struct {
+	DurationSeconds int `json:"duration_seconds,omitempty"`
+}
+
+// capture is a session with a ring buffer of exchanges
+type capture struct {
+	startedAt time.Time
+	expiresAt time.Time
+	exchanges []*Exchange
+	next      int
+	recorded  int
+}
+
+// Recorder holds a service's capture sessions and records exchanges for
+// them
+type Recorder struct {
+	service string
+	config  config.DebugCaptureConfig
+	logger  *logger.Logger
+
+	mu       sync.Mutex
+	captures map[string]*capture
+	now      func() time.Time
+}
+
+// NewRecorder creates a recorder with no captures
+func NewRecorder(service string, cfg config.DebugCaptureConfig, log *logger.Logger) *Recorder {
+	if cfg.MaxExchanges <= 0 {
+		cfg.MaxExchanges = 200
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 64 * 1024
+	}
+	if cfg.DefaultDuration <= 0 {
+		cfg.DefaultDuration = 15 * time.Minute
+	}
+	if cfg.MaxDuration < cfg.DefaultDuration {
+		cfg.MaxDuration = cfg.DefaultDuration
+	}
+	return &Recorder{
+		service:  service,
+		config:   cfg,
+		logger:   log,
+		captures: make(map[string]*capture),
+		now:      time.Now,
+	}
+}
+
+// Setup adds capture to a service's router. Call it before registering
+// routes, and before chaos.Setup so that injected faults are recorded as
+// the device saw them. The signed admin API is served under
+// /admin/debug-capture/devices.
+func Setup(router *gin.Engine, cfg *config.Config, log *logger.Logger) *Recorder {
+	recorder := NewRecorder(cfg.ServiceName, cfg.DebugCapture, log)
+	router.Use(recorder.Middleware())
+	recorder.RegisterRoutes(router.Group("/admin", admin.RequireSignature([]byte(cfg.JWTSecret))))
+	return recorder
+}
+
+// Start begins capturing a device's exchanges for duration, or the default
+// duration if it is zero. Starting again extends the capture and keeps the
+// exchanges recorded so far.
+func (r *Recorder) Start(deviceID string, duration time.Duration) (*Capture, error) {
+	switch {
+	case deviceID == "":
+		return nil, fmt.Errorf("%w: device ID is required", ErrInvalidCapture)
+	case duration < 0 || duration > r.config.MaxDuration:
+		return nil, fmt.Errorf("%w: duration must be between 0 and %s", ErrInvalidCapture, r.config.MaxDuration)
+	case duration == 0:
+		duration = r.config.DefaultDuration
+	}
+
+	now := r.now().UTC()
+	r.mu.Lock()
+	r.expireLocked()
+	session, ok := r.captures[deviceID]
+	if !ok || !now.Before(session.expiresAt) {
+		session = &capture{startedAt: now, exchanges: make([]*Exchange, r.config.MaxExchanges)}
+		r.captures[deviceID] = session
+	}
+	session.expiresAt = now.Add(duration)
+	snapshot := r.snapshotLocked(deviceID, session)
+	r.mu.Unlock()
+
+	r.logger.Info("Debug capture started", "device_id", deviceID, "service", r.service, "expires_at", snapshot.ExpiresAt)
+	return snapshot, nil
+}
+
+// Get returns a device's capture, active or finished
+func (r *Recorder) Get(deviceID string) (*Capture, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked()
+
+	session, ok := r.captures[deviceID]
+	if !ok {
+		return nil, ErrCaptureNotFound
+	}
+	return r.snapshotLocked(deviceID, session), nil
+}
+
+// Stop ends a device's capture and discards its exchanges
+func (r *Recorder) Stop(deviceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.captures[deviceID]; !ok {
+		return ErrCaptureNotFound
+	}
+	delete(r.captures, deviceID)
+	return nil
+}
+
+// Active reports whether a device's exchanges are being captured
+func (r *Recorder) Active(deviceID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.captures[deviceID]
+	return ok && r.now().Before(session.expiresAt)
+}
+
+// capturing reports whether any device's exchanges are being captured
+func (r *Recorder) capturing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for _, session := range r.captures {
+		if now.Before(session.expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) record(deviceID string, exchange *Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.captures[deviceID]
+	if !ok || !r.now().Before(session.expiresAt) {
+		return
+	}
+	session.exchanges[session.next] = exchange
+	session.next = (session.next + 1) % len(session.exchanges)
+	session.recorded++
+}
+
+func (r *Recorder) snapshotLocked(deviceID string, session *capture) *Capture {
+	size := len(session.exchanges)
+	count := session.recorded
+	if count > size {
+		count = size
+	}
+
+	exchanges := make([]*Exchange, 0, count)
+	for n := 0; n < count; n++ {
+		exchanges = append(exchanges, session.exchanges[(session.next-count+n+size)%size])
+	}
+	return &Capture{
+		DeviceID:  deviceID,
+		Service:   r.service,
+		Active:    r.now().Before(session.expiresAt),
+		StartedAt: session.startedAt,
+		ExpiresAt: session.expiresAt,
+		Exchanges: exchanges,
+		Dropped:   session.recorded - count,
+	}
+}
+
+// expireLocked forgets captures that finished longer ago than retention
+func (r *Recorder) expireLocked() {
+	now := r.now()
+	for deviceID, session := range r.captures {
+		if now.Sub(session.expiresAt) > retention {
+			delete(r.captures, deviceID)
+		}
+	}
+}
+
+// Middleware records the exchanges of devices being captured. A request
+// belongs to the device in its :deviceId parameter or, failing that, the
+// device_id field of its body. Admin routes and WebSocket upgrades
+// are never recorded.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if !r.capturing() || route == "" || strings.HasPrefix(route, "/admin/") || c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		deviceID := c.Param("deviceId")
+		if deviceID != "" && !r.Active(deviceID) {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if deviceID == "" {
+			deviceID = bodyDeviceID(body)
+			if deviceID == "" || !r.Active(deviceID) {
+				c.Next()
+				return
+			}
+		}
+
+		writer := &captureWriter{ResponseWriter: c.Writer, limit: r.config.MaxBodyBytes}
+		c.Writer = writer
+		started := r.now()
+		c.Next()
+
+		exchange := &Exchange{
+			Time:           started.UTC(),
+			Method:         c.Request.Method,
+			Route:          route,
+			Path:           redactPath(c.Request.URL.Path, c.Params),
+			Query:          redactQuery(c.Request.URL.Query()),
+			RequestHeaders: redactHeaders(c.Request.Header),
+			RequestSize:    len(body),
+			Status:         writer.Status(),
+			ResponseSize:   writer.Size(),
+			DurationMS:     r.now().Sub(started).Milliseconds(),
+		}
+		exchange.RequestBody, exchange.RequestTruncated = r.bodyText(body)
+		exchange.ResponseBody, exchange.ResponseTruncated = r.bodyText(writer.body.Bytes())
+		exchange.ResponseTruncated = exchange.ResponseTruncated || writer.truncated
+		r.record(deviceID, exchange)
+	}
+}
+
+// bodyText redacts a body and cuts it to the configured size, reporting
+// whether it was cut
+func (r *Recorder) bodyText(body []byte) (string, bool) {
+	if len(body) == 0 || !utf8.Valid(body) {
+		return "", false
+	}
+	text := string(redactBody(body))
+	if len(text) <= r.config.MaxBodyBytes {
+		return text, false
+	}
+	return strings.ToValidUTF8(text[:r.config.MaxBodyBytes], ""), true
+}
+
+// captureWriter passes a response through, keeping the first limit bytes
+type captureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) keep(data []byte) {
+	room := w.limit - w.body.Len()
+	if len(data) > room {
+		data = data[:room]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
+// bodyDeviceID returns the device_id field of a JSON body, looking for it
+// in the text of malformed ones
+func bodyDeviceID(body []byte) string {
+	var fields struct {
+		DeviceID string `json:"device_id"`
+	}
+	if json.Unmarshal(body, &fields) != nil {
+		if match := jsonDeviceID.FindSubmatch(body); match != nil {
+			return string(match[1])
+		}
+		return ""
+	}
+	return fields.DeviceID
+}
+
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	recorded := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitive(name) {
+			recorded[name] = redacted
+			continue
+		}
+		recorded[name] = strings.Join(values, ", ")
+	}
+	return recorded
+}
+
+func redactQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	for name := range query {
+		if sensitive(name) {
+			query[name] = []string{redacted}
+		}
+	}
+	return query.Encode()
+}
+
+// redactPath replaces the values of sensitive route parameters, such as
+// download tokens, in a request path
+func redactPath(path string, params gin.Params) string {
+	for _, param := range params {
+		if sensitive(param.Key) && param.Value != "" {
+			path = strings.Replace(path, param.Value, redacted, 1)
+		}
+	}
+	return path
+}
+
+// redactBody redacts sensitive values in a JSON body. Bodies without
+// secrets are returned byte for byte, so formatting mistakes stay visible;
+// bodies that are not valid JSON have the string values of sensitive keys
+// replaced.
+func redactBody(body []byte) []byte {
+	if !json.Valid(body) {
+		return sensitiveJSONValue.ReplaceAll(body, []byte(`$1"`+redacted+`"`))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	if !redactValue(value) {
+		return body
+	}
+	redactedBody, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return redactedBody
+}
+
+// redactValue replaces sensitive values in decoded JSON, reporting whether
+// any were found
+func redactValue(value interface{}) bool {
+	found := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if sensitive(key) {
+				v[key] = redacted
+				found = true
+			} else if redactValue(item) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// RegisterRoutes registers the capture admin API. The caller is responsible
+// for authentication.
+func (r *Recorder) RegisterRoutes(router *gin.RouterGroup) {
+	captures := router.Group("/debug-capture/devices")
+	{
+		captures.PUT("/:deviceId", r.StartCapture)
+		captures.GET("/:deviceId", r.GetCapture)
+		captures.DELETE("/:deviceId", r.StopCapture)
+	}
+}
+
+// StartCapture starts or extends a device's capture
+func (r *Recorder) StartCapture(c *gin.Context) {
+	var req CaptureRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	captured, err := r.Start(c.Param("deviceId"), time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, captured)
+}
+
+// GetCapture returns a device's capture and its exchanges
+func (r *Recorder) GetCapture(c *gin.Context) {
+	captured, err := r.Get(c.Param("deviceId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, captured)
+}
+
+// StopCapture ends a device's capture
+func (r *Recorder) StopCapture(c *gin.Context) {
+	if err := r.Stop(c.Param("deviceId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
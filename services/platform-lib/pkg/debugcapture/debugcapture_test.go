@@ -0,0 +1,159 @@
+package debugcapture
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "capture-secret"
+
+func newTestRouter(t *testing.T, cfg config.DebugCaptureConfig) (*gin.Engine, *Recorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	recorder := Setup(router, &config.Config{
+		ServiceName:  "ota-service",
+		JWTSecret:    testSecret,
+		DebugCapture: cfg,
+	}, logger.New("info", "ota-service"))
+
+	router.GET("/api/v1/ota/updates/:deviceId", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"device_id": c.Param("deviceId"), "download_token": "tok-123", "size": 1024})
+	})
+	router.POST("/api/v1/ota/updates/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+	router.GET("/api/v1/ota/devices/:deviceId/downloads/:token", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/octet-stream", []byte{0xff, 0xfe, 0x00, 0x01})
+	})
+	return router, recorder
+}
+
+func send(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer device-jwt")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func signed(router http.Handler, method, path string, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	admin.SignRequest(req, []byte(testSecret), body, time.Now())
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_RecordsCapturedDevices(t *testing.T) {
+	router, recorder := newTestRouter(t, config.DebugCaptureConfig{})
+
+	_, err := recorder.Start("device-001", time.Minute)
+	require.NoError(t, err)
+
+	send(router, http.MethodGet, "/api/v1/ota/updates/device-001?api_key=abc&channel=beta", "")
+	send(router, http.MethodGet, "/api/v1/ota/updates/device-002", "")
+	send(router, http.MethodPost, "/api/v1/ota/updates/status", `{"device_id": "device-001", "status": "failed", "wifi_password": "hunter2"}`)
+	send(router, http.MethodPost, "/api/v1/ota/updates/status", `{"device_id":"device-001","status":"fail`)
+	send(router, http.MethodGet, "/api/v1/ota/devices/device-001/downloads/tok-123", "")
+
+	captured, err := recorder.Get("device-001")
+	require.NoError(t, err)
+	assert.True(t, captured.Active)
+	require.Len(t, captured.Exchanges, 4)
+
+	update := captured.Exchanges[0]
+	assert.Equal(t, "/api/v1/ota/updates/:deviceId", update.Route)
+	assert.Equal(t, http.StatusOK, update.Status)
+	assert.Equal(t, "api_key=%5Bredacted%5D&channel=beta", update.Query)
+	assert.Equal(t, redacted, update.RequestHeaders["Authorization"])
+	assert.JSONEq(t, `{"device_id":"device-001","download_token":"[redacted]","size":1024}`, update.ResponseBody)
+
+	// Bodies are kept as sent unless a secret has to be redacted, and
+	// malformed JSON is kept too
+	status := captured.Exchanges[1]
+	assert.JSONEq(t, `{"device_id":"device-001","status":"failed","wifi_password":"[redacted]"}`, status.RequestBody)
+	assert.Equal(t, `{"device_id":"device-001","status":"fail`, captured.Exchanges[2].RequestBody)
+
+	download := captured.Exchanges[3]
+	assert.Equal(t, "/api/v1/ota/devices/device-001/downloads/[redacted]", download.Path)
+	assert.Empty(t, download.ResponseBody)
+	assert.Equal(t, 4, download.ResponseSize)
+
+	_, err = recorder.Get("device-002")
+	assert.ErrorIs(t, err, ErrCaptureNotFound)
+}
+
+func TestMiddleware_RingBufferAndExpiry(t *testing.T) {
+	router, recorder := newTestRouter(t, config.DebugCaptureConfig{MaxExchanges: 2, MaxBodyBytes: 16})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	_, err := recorder.Start("device-001", time.Minute)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		send(router, http.MethodGet, "/api/v1/ota/updates/device-001", "")
+	}
+
+	captured, err := recorder.Get("device-001")
+	require.NoError(t, err)
+	assert.Len(t, captured.Exchanges, 2)
+	assert.Equal(t, 1, captured.Dropped)
+	assert.True(t, captured.Exchanges[0].ResponseTruncated)
+	assert.Len(t, captured.Exchanges[0].ResponseBody, 16)
+
+	// Nothing is recorded after the capture ends, but it can still be read
+	now = now.Add(time.Minute)
+	send(router, http.MethodGet, "/api/v1/ota/updates/device-001", "")
+	captured, err = recorder.Get("device-001")
+	require.NoError(t, err)
+	assert.False(t, captured.Active)
+	assert.Equal(t, 1, captured.Dropped)
+
+	now = now.Add(retention + time.Second)
+	_, err = recorder.Get("device-001")
+	assert.ErrorIs(t, err, ErrCaptureNotFound)
+
+	_, err = recorder.Start("device-001", 5*time.Hour)
+	assert.ErrorIs(t, err, ErrInvalidCapture)
+}
+
+func TestCaptureRoutes(t *testing.T) {
+	router, recorder := newTestRouter(t, config.DebugCaptureConfig{})
+
+	// The admin API only accepts signed requests
+	assert.Equal(t, http.StatusUnauthorized, send(router, http.MethodPut, "/admin/debug-capture/devices/device-001", "").Code)
+
+	body, _ := json.Marshal(CaptureRequest{DurationSeconds: 600})
+	w := signed(router, http.MethodPut, "/admin/debug-capture/devices/device-001", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var started Capture
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+	assert.Equal(t, "ota-service", started.Service)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), started.ExpiresAt, time.Minute)
+	assert.True(t, recorder.Active("device-001"))
+
+	send(router, http.MethodGet, "/api/v1/ota/updates/device-001", "")
+	w = signed(router, http.MethodGet, "/admin/debug-capture/devices/device-001", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var captured Capture
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &captured))
+	assert.Len(t, captured.Exchanges, 1)
+
+	body, _ = json.Marshal(CaptureRequest{DurationSeconds: -1})
+	assert.Equal(t, http.StatusBadRequest, signed(router, http.MethodPut, "/admin/debug-capture/devices/device-001", body).Code)
+	assert.Equal(t, http.StatusNoContent, signed(router, http.MethodDelete, "/admin/debug-capture/devices/device-001", nil).Code)
+	assert.Equal(t, http.StatusNotFound, signed(router, http.MethodGet, "/admin/debug-capture/devices/device-001", nil).Code)
+}
@@ -0,0 +1,243 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// applyActor is recorded as the actor of the changes an apply makes
+const applyActor = "athena-apply"
+
+// deviceGroupSpec selects devices explicitly, by label, or both
+type deviceGroupSpec struct {
+	Description string            `json:"description,omitempty"`
+	Devices     []string          `json:"devices,omitempty"`
+	Selector    map[string]string `json:"selector,omitempty"`
+}
+
+// DeviceGroupHandler reconciles DeviceGroup resources through the device
+// service. The resource name is the group name; the selector is stored as
+// the group's labels.
+type DeviceGroupHandler struct {
+	service serviceClient
+}
+
+// NewDeviceGroupHandler creates a handler for the device service at baseURL
+func NewDeviceGroupHandler(baseURL string, client *http.Client) *DeviceGroupHandler {
+	return &DeviceGroupHandler{service: newServiceClient(baseURL, client)}
+}
+
+// Normalize validates a device group spec
+func (h *DeviceGroupHandler) Normalize(spec map[string]interface{}) (map[string]interface{}, error) {
+	var group deviceGroupSpec
+	if err := fromSpec(spec, &group); err != nil {
+		return nil, fmt.Errorf("invalid DeviceGroup spec: %w", err)
+	}
+	if len(group.Devices) == 0 && len(group.Selector) == 0 {
+		return nil, fmt.Errorf("invalid DeviceGroup spec: devices or selector is required")
+	}
+	return toSpec(group)
+}
+
+// Get reads the group from the device service
+func (h *DeviceGroupHandler) Get(ctx context.Context, name, externalID string) (map[string]interface{}, error) {
+	var group struct {
+		Description string            `json:"description"`
+		Devices     []string          `json:"devices"`
+		Labels      map[string]string `json:"labels"`
+	}
+	if err := h.service.do(ctx, http.MethodGet, h.groupPath(name), nil, &group); err != nil {
+		return nil, err
+	}
+	return toSpec(deviceGroupSpec{Description: group.Description, Devices: group.Devices, Selector: group.Labels})
+}
+
+// Create creates the group. Its devices must be registered.
+func (h *DeviceGroupHandler) Create(ctx context.Context, name string, spec map[string]interface{}) (string, error) {
+	group, err := h.group(name, spec)
+	if err != nil {
+		return "", err
+	}
+	return "", h.service.do(ctx, http.MethodPost, "/api/v1/device-groups", group, nil)
+}
+
+// Update replaces the group's description, devices and labels
+func (h *DeviceGroupHandler) Update(ctx context.Context, name, externalID string, current, desired map[string]interface{}) (string, error) {
+	group, err := h.group(name, mergeSpec(current, desired))
+	if err != nil {
+		return "", err
+	}
+	return "", h.service.do(ctx, http.MethodPut, h.groupPath(name), group, nil)
+}
+
+// Delete removes the group
+func (h *DeviceGroupHandler) Delete(ctx context.Context, name, externalID string) error {
+	err := h.service.do(ctx, http.MethodDelete, h.groupPath(name), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (h *DeviceGroupHandler) groupPath(name string) string {
+	return "/api/v1/device-groups/" + url.PathEscape(name)
+}
+
+// group converts a group spec into the device service's group body
+func (h *DeviceGroupHandler) group(name string, spec map[string]interface{}) (map[string]interface{}, error) {
+	var group deviceGroupSpec
+	if err := fromSpec(spec, &group); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":        name,
+		"description": group.Description,
+		"devices":     group.Devices,
+		"labels":      group.Selector,
+	}, nil
+}
+
+// deploymentPolicySpec subscribes the devices of a template in a device
+// group to a release channel. It is a subset of the OTA service's fleet
+// policy, under the same field names.
+type deploymentPolicySpec struct {
+	DeviceGroup       string            `json:"device_group"`
+	TemplateID        string            `json:"template_id"`
+	Channel           string            `json:"channel"`
+	Strategy          string            `json:"strategy"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	RolloutPercentage int               `json:"rollout_percentage,omitempty"`
+	DelayHours        int               `json:"delay_hours,omitempty"`
+	FailureThreshold  int               `json:"failure_threshold,omitempty"`
+}
+
+var (
+	// deploymentStrategies mirrors the OTA service's deployment strategies
+	deploymentStrategies = []string{"immediate", "staged", "canary"}
+	// releaseChannels mirrors the OTA service's release channels
+	releaseChannels = []string{"stable", "beta", "alpha"}
+)
+
+// DeploymentPolicyHandler reconciles DeploymentPolicy resources as OTA
+// fleet policies. The external ID is the policy ID; the OTA service checks
+// that the targeted device group exists.
+type DeploymentPolicyHandler struct {
+	service serviceClient
+}
+
+// NewDeploymentPolicyHandler creates a handler for the OTA service at
+// baseURL
+func NewDeploymentPolicyHandler(baseURL string, client *http.Client) *DeploymentPolicyHandler {
+	return &DeploymentPolicyHandler{service: newServiceClient(baseURL, client)}
+}
+
+// Normalize validates a policy spec. Policies follow the stable channel
+// unless the manifest says otherwise, and immediate rollouts reach every
+// device.
+func (h *DeploymentPolicyHandler) Normalize(spec map[string]interface{}) (map[string]interface{}, error) {
+	var policy deploymentPolicySpec
+	if err := fromSpec(spec, &policy); err != nil {
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: %w", err)
+	}
+	if policy.Channel == "" {
+		policy.Channel = "stable"
+	}
+
+	switch {
+	case policy.DeviceGroup == "":
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: device_group is required")
+	case policy.TemplateID == "":
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: template_id is required")
+	case !containsString(releaseChannels, policy.Channel):
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: channel must be one of %s", strings.Join(releaseChannels, ", "))
+	case !containsString(deploymentStrategies, policy.Strategy):
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: strategy must be one of %s", strings.Join(deploymentStrategies, ", "))
+	case policy.RolloutPercentage < 0 || policy.RolloutPercentage > 100:
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: rollout_percentage must be between 1 and 100")
+	case policy.DelayHours < 0:
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: delay_hours cannot be negative")
+	case policy.FailureThreshold < 0:
+		return nil, fmt.Errorf("invalid DeploymentPolicy spec: failure_threshold cannot be negative")
+	}
+	if policy.Strategy == "immediate" {
+		policy.RolloutPercentage = 100
+	}
+	return toSpec(policy)
+}
+
+// Get reads the policy an earlier apply created
+func (h *DeploymentPolicyHandler) Get(ctx context.Context, name, externalID string) (map[string]interface{}, error) {
+	if externalID == "" {
+		return nil, ErrNotFound
+	}
+	var current map[string]interface{}
+	if err := h.service.do(ctx, http.MethodGet, h.policyPath(externalID), nil, &current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// Create creates a fleet policy named after the resource
+func (h *DeploymentPolicyHandler) Create(ctx context.Context, name string, spec map[string]interface{}) (string, error) {
+	request, err := h.request(name, spec)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		PolicyID string `json:"policy_id"`
+	}
+	if err := h.service.do(ctx, http.MethodPost, "/api/v1/ota/policies", request, &created); err != nil {
+		return "", err
+	}
+	return created.PolicyID, nil
+}
+
+// Update replaces the policy's definition; its pause state is kept
+func (h *DeploymentPolicyHandler) Update(ctx context.Context, name, externalID string, current, desired map[string]interface{}) (string, error) {
+	request, err := h.request(name, mergeSpec(current, desired))
+	if err != nil {
+		return "", err
+	}
+	return externalID, h.service.do(ctx, http.MethodPut, h.policyPath(externalID), request, nil)
+}
+
+// Delete removes the policy; the OTA service keeps its audit trail
+func (h *DeploymentPolicyHandler) Delete(ctx context.Context, name, externalID string) error {
+	if externalID == "" {
+		return nil
+	}
+	err := h.service.do(ctx, http.MethodDelete, h.policyPath(externalID), map[string]string{"actor": applyActor}, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (h *DeploymentPolicyHandler) policyPath(externalID string) string {
+	return "/api/v1/ota/policies/" + url.PathEscape(externalID)
+}
+
+// request converts a policy spec into the OTA service's policy request.
+// Merged specs carry server-managed fields, which are dropped.
+func (h *DeploymentPolicyHandler) request(name string, spec map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var policy deploymentPolicySpec
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	request, err := toSpec(policy)
+	if err != nil {
+		return nil, err
+	}
+	request["name"] = name
+	request["actor"] = applyActor
+	return request, nil
+}
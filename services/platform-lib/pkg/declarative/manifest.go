@@ -0,0 +1,104 @@
+package declarative
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIVersion is the manifest schema version understood by this package
+const APIVersion = "athena.io/v1"
+
+// Resource kinds that can be declared in a manifest
+const (
+	KindTemplate         = "Template"
+	KindDeviceGroup      = "DeviceGroup"
+	KindAlertRule        = "AlertRule"
+	KindDeploymentPolicy = "DeploymentPolicy"
+)
+
+// kindOrder is the order resources are created and updated in, so that
+// references resolve (policies target groups). Deletes run in reverse.
+var kindOrder = []string{KindTemplate, KindDeviceGroup, KindAlertRule, KindDeploymentPolicy}
+
+var resourceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+// Manifest is a set of desired resources
+type Manifest struct {
+	APIVersion string     `json:"api_version" yaml:"api_version"`
+	Resources  []Resource `json:"resources" yaml:"resources"`
+}
+
+// Resource is a single desired resource. Only the fields present in Spec are
+// managed; fields the server fills in are left alone.
+type Resource struct {
+	Kind string                 `json:"kind" yaml:"kind"`
+	Name string                 `json:"name" yaml:"name"`
+	Spec map[string]interface{} `json:"spec" yaml:"spec"`
+}
+
+// Key identifies a resource across manifests
+func (r Resource) Key() string {
+	return r.Kind + "/" + r.Name
+}
+
+// ParseManifest decodes a YAML or JSON manifest and validates its structure
+func ParseManifest(data []byte) (*Manifest, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	// Round-trip through JSON so specs use the same types (map[string]interface{},
+	// float64) as resources read back from services
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(encoded, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Validate checks the manifest version, kinds, names and uniqueness
+func (m *Manifest) Validate() error {
+	if m.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported api_version %q (expected %q)", m.APIVersion, APIVersion)
+	}
+
+	seen := make(map[string]bool, len(m.Resources))
+	for i, resource := range m.Resources {
+		if !isKnownKind(resource.Kind) {
+			return fmt.Errorf("resources[%d]: unknown kind %q", i, resource.Kind)
+		}
+		if !resourceNamePattern.MatchString(resource.Name) {
+			return fmt.Errorf("resources[%d]: invalid name %q (lowercase letters, digits, '.', '_' and '-')", i, resource.Name)
+		}
+		if seen[resource.Key()] {
+			return fmt.Errorf("resources[%d]: duplicate resource %s", i, resource.Key())
+		}
+		seen[resource.Key()] = true
+
+		if resource.Spec == nil {
+			m.Resources[i].Spec = map[string]interface{}{}
+		}
+	}
+	return nil
+}
+
+func isKnownKind(kind string) bool {
+	for _, known := range kindOrder {
+		if kind == known {
+			return true
+		}
+	}
+	return false
+}
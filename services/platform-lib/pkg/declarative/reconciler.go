@@ -0,0 +1,432 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by handlers when a resource does not exist
+	ErrNotFound = errors.New("resource not found")
+	// ErrDeleteNotSupported is returned by handlers for resources that can only
+	// be retained, such as published template versions
+	ErrDeleteNotSupported = errors.New("resource cannot be deleted")
+)
+
+// Handler reconciles one resource kind against the service that owns it.
+// ExternalID is the identifier the owning service assigned on create; it is
+// empty for kinds addressed by name.
+type Handler interface {
+	// Normalize validates a desired spec and returns it in the form Get
+	// reports, so equal resources compare equal
+	Normalize(spec map[string]interface{}) (map[string]interface{}, error)
+	Get(ctx context.Context, name, externalID string) (map[string]interface{}, error)
+	Create(ctx context.Context, name string, spec map[string]interface{}) (externalID string, err error)
+	Update(ctx context.Context, name, externalID string, current, desired map[string]interface{}) (newExternalID string, err error)
+	Delete(ctx context.Context, name, externalID string) error
+}
+
+// UpdateChecker is implemented by handlers that can reject an update while
+// planning, so dry runs surface it
+type UpdateChecker interface {
+	CheckUpdate(current, desired map[string]interface{}) error
+}
+
+// Action is the operation a plan performs on a resource
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "unchanged"
+	ActionRetain    Action = "retain"
+)
+
+// FieldDiff is a single managed field whose live value differs from the manifest
+type FieldDiff struct {
+	Path    string      `json:"path"`
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+}
+
+// Change is the planned (or applied) operation on one resource
+type Change struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Action Action      `json:"action"`
+	Diff   []FieldDiff `json:"diff,omitempty"`
+	Error  string      `json:"error,omitempty"`
+
+	externalID string
+	current    map[string]interface{}
+	desired    map[string]interface{}
+}
+
+// Result is the outcome of planning or applying a manifest
+type Result struct {
+	DryRun  bool           `json:"dry_run"`
+	Prune   bool           `json:"prune"`
+	Changes []Change       `json:"changes"`
+	Summary map[Action]int `json:"summary"`
+	Failed  int            `json:"failed"`
+}
+
+// ManagedResource records a resource created or adopted by an apply so later
+// applies can find it by name and prune it when it leaves the manifest
+type ManagedResource struct {
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	ExternalID string    `json:"external_id,omitempty"`
+	AppliedAt  time.Time `json:"applied_at"`
+}
+
+// StateStore persists the set of managed resources
+type StateStore interface {
+	List(ctx context.Context) ([]ManagedResource, error)
+	Get(ctx context.Context, kind, name string) (*ManagedResource, error)
+	Put(ctx context.Context, resource ManagedResource) error
+	Delete(ctx context.Context, kind, name string) error
+}
+
+// MemoryStateStore is an in-memory StateStore
+type MemoryStateStore struct {
+	mu        sync.RWMutex
+	resources map[string]ManagedResource
+}
+
+// NewMemoryStateStore creates an empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{resources: make(map[string]ManagedResource)}
+}
+
+// List returns managed resources ordered by kind and name
+func (s *MemoryStateStore) List(ctx context.Context) ([]ManagedResource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resources := make([]ManagedResource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	return resources, nil
+}
+
+// Get returns a managed resource or ErrNotFound
+func (s *MemoryStateStore) Get(ctx context.Context, kind, name string) (*ManagedResource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resource, ok := s.resources[kind+"/"+name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &resource, nil
+}
+
+// Put records a managed resource
+func (s *MemoryStateStore) Put(ctx context.Context, resource ManagedResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resources[resource.Kind+"/"+resource.Name] = resource
+	return nil
+}
+
+// Delete forgets a managed resource
+func (s *MemoryStateStore) Delete(ctx context.Context, kind, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.resources, kind+"/"+name)
+	return nil
+}
+
+// Options control how a manifest is reconciled
+type Options struct {
+	// DryRun computes the plan without changing anything
+	DryRun bool
+	// Prune deletes managed resources that are no longer in the manifest
+	Prune bool
+}
+
+// Reconciler diffs manifests against live state and converges them
+type Reconciler struct {
+	handlers map[string]Handler
+	state    StateStore
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// NewReconciler creates a reconciler with no handlers registered
+func NewReconciler(state StateStore) *Reconciler {
+	return &Reconciler{
+		handlers: make(map[string]Handler),
+		state:    state,
+		now:      time.Now,
+	}
+}
+
+// SetStateStore sets where managed resources are recorded
+func (r *Reconciler) SetStateStore(state StateStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+}
+
+// Register sets the handler for a resource kind
+func (r *Reconciler) Register(kind string, handler Handler) {
+	r.handlers[kind] = handler
+}
+
+// Managed lists the resources owned by previous applies
+func (r *Reconciler) Managed(ctx context.Context) ([]ManagedResource, error) {
+	return r.state.List(ctx)
+}
+
+// Apply plans the manifest and, unless DryRun is set, executes the plan.
+// Applying the same manifest twice leaves every resource unchanged the
+// second time. Failures are reported per resource and do not stop the apply.
+func (r *Reconciler) Apply(ctx context.Context, manifest *Manifest, opts Options) (*Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes, err := r.plan(ctx, manifest, opts.Prune)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.DryRun {
+		for i := range changes {
+			if changes[i].Error == "" {
+				r.execute(ctx, &changes[i])
+			}
+		}
+	}
+
+	result := &Result{DryRun: opts.DryRun, Prune: opts.Prune, Summary: make(map[Action]int)}
+	for _, change := range changes {
+		if change.Error != "" {
+			result.Failed++
+		} else {
+			result.Summary[change.Action]++
+		}
+	}
+	result.Changes = changes
+	return result, nil
+}
+
+// plan computes the ordered changes for a manifest
+func (r *Reconciler) plan(ctx context.Context, manifest *Manifest, prune bool) ([]Change, error) {
+	for _, resource := range manifest.Resources {
+		if _, ok := r.handlers[resource.Kind]; !ok {
+			return nil, fmt.Errorf("kind %s is not supported by this server", resource.Kind)
+		}
+	}
+
+	var changes []Change
+	declared := make(map[string]bool, len(manifest.Resources))
+	for _, kind := range kindOrder {
+		for _, resource := range manifest.Resources {
+			if resource.Kind != kind {
+				continue
+			}
+			declared[resource.Key()] = true
+			changes = append(changes, r.planResource(ctx, resource))
+		}
+	}
+
+	if prune {
+		managed, err := r.state.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list managed resources: %w", err)
+		}
+		for i := len(kindOrder) - 1; i >= 0; i-- {
+			for _, resource := range managed {
+				if resource.Kind != kindOrder[i] || declared[resource.Kind+"/"+resource.Name] || r.handlers[resource.Kind] == nil {
+					continue
+				}
+				changes = append(changes, Change{
+					Kind:       resource.Kind,
+					Name:       resource.Name,
+					Action:     ActionDelete,
+					externalID: resource.ExternalID,
+				})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+func (r *Reconciler) planResource(ctx context.Context, resource Resource) Change {
+	change := Change{Kind: resource.Kind, Name: resource.Name}
+	handler := r.handlers[resource.Kind]
+
+	desired, err := handler.Normalize(resource.Spec)
+	if err != nil {
+		change.Action = ActionCreate
+		change.Error = err.Error()
+		return change
+	}
+	change.desired = desired
+
+	if managed, err := r.state.Get(ctx, resource.Kind, resource.Name); err == nil {
+		change.externalID = managed.ExternalID
+	}
+
+	current, err := handler.Get(ctx, resource.Name, change.externalID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		change.Action = ActionCreate
+		return change
+	case err != nil:
+		change.Action = ActionUpdate
+		change.Error = err.Error()
+		return change
+	}
+	change.current = current
+
+	change.Diff = diffSpec("", current, desired)
+	if len(change.Diff) == 0 {
+		change.Action = ActionUnchanged
+	} else {
+		change.Action = ActionUpdate
+		if checker, ok := handler.(UpdateChecker); ok {
+			if err := checker.CheckUpdate(current, desired); err != nil {
+				change.Error = err.Error()
+			}
+		}
+	}
+	return change
+}
+
+// execute performs a planned change and records it in the state store
+func (r *Reconciler) execute(ctx context.Context, change *Change) {
+	handler := r.handlers[change.Kind]
+
+	var err error
+	externalID := change.externalID
+	switch change.Action {
+	case ActionCreate:
+		externalID, err = handler.Create(ctx, change.Name, change.desired)
+	case ActionUpdate:
+		externalID, err = handler.Update(ctx, change.Name, change.externalID, change.current, change.desired)
+	case ActionDelete:
+		err = handler.Delete(ctx, change.Name, change.externalID)
+		if errors.Is(err, ErrDeleteNotSupported) {
+			change.Action = ActionRetain
+			err = nil
+		}
+		if err == nil {
+			err = r.state.Delete(ctx, change.Kind, change.Name)
+		}
+		if err != nil {
+			change.Error = err.Error()
+		}
+		return
+	}
+	if err != nil {
+		change.Error = err.Error()
+		return
+	}
+
+	// Unchanged resources are recorded too, adopting ones created out of band
+	err = r.state.Put(ctx, ManagedResource{
+		Kind:       change.Kind,
+		Name:       change.Name,
+		ExternalID: externalID,
+		AppliedAt:  r.now(),
+	})
+	if err != nil {
+		change.Error = fmt.Sprintf("applied but failed to record state: %v", err)
+	}
+}
+
+// diffSpec compares the fields present in desired against current. Fields
+// only present in current are server-managed and ignored; nested objects are
+// compared field by field.
+func diffSpec(prefix string, current, desired map[string]interface{}) []FieldDiff {
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var diffs []FieldDiff
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		want := desired[key]
+		have, ok := current[key]
+		if wantMap, isMap := want.(map[string]interface{}); isMap {
+			if haveMap, isMap := have.(map[string]interface{}); isMap {
+				diffs = append(diffs, diffSpec(path, haveMap, wantMap)...)
+				continue
+			}
+		}
+		if !ok || !reflect.DeepEqual(have, want) {
+			diffs = append(diffs, FieldDiff{Path: path, Current: have, Desired: want})
+		}
+	}
+	return diffs
+}
+
+// toSpec converts a typed value into the generic form used for diffing
+func toSpec(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// fromSpec decodes a generic spec into a typed value, rejecting unknown fields
+func fromSpec(spec map[string]interface{}, value interface{}) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(value)
+}
+
+// mergeSpec overlays desired onto current, keeping fields the manifest does
+// not manage
+func mergeSpec(current, desired map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(desired))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range desired {
+		wantMap, wantIsMap := value.(map[string]interface{})
+		haveMap, haveIsMap := merged[key].(map[string]interface{})
+		if wantIsMap && haveIsMap {
+			merged[key] = mergeSpec(haveMap, wantMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,342 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServices serves the template, telemetry, device and OTA endpoints
+// used by the handlers
+type fakeServices struct {
+	mu         sync.Mutex
+	templates  map[string]map[string]interface{}
+	thresholds map[string]map[string]interface{}
+	groups     map[string]map[string]interface{}
+	policies   map[string]map[string]interface{}
+	nextID     int
+	server     *httptest.Server
+}
+
+func newFakeServices(t *testing.T) *fakeServices {
+	f := &fakeServices{
+		templates:  make(map[string]map[string]interface{}),
+		thresholds: make(map[string]map[string]interface{}),
+		groups:     make(map[string]map[string]interface{}),
+		policies:   make(map[string]map[string]interface{}),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeServices) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+
+	switch {
+	case parts[0] == "templates" && r.Method == http.MethodGet:
+		template, ok := f.templates[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(template)
+	case parts[0] == "templates" && r.Method == http.MethodPost:
+		body["created_at"] = "2026-01-01T00:00:00Z"
+		f.templates[body["id"].(string)] = body
+		w.WriteHeader(http.StatusCreated)
+	case parts[0] == "device-groups" && r.Method == http.MethodPost:
+		f.groups[body["name"].(string)] = body
+		w.WriteHeader(http.StatusCreated)
+	case parts[0] == "device-groups":
+		f.serveDocument(w, r, f.groups, parts[1], body)
+	case parts[0] == "ota" && r.Method == http.MethodPost:
+		if _, ok := f.groups[body["device_group"].(string)]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("unknown device group %q", body["device_group"])})
+			return
+		}
+		f.nextID++
+		body["policy_id"] = fmt.Sprintf("policy-%d", f.nextID)
+		f.policies[body["policy_id"].(string)] = body
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	case parts[0] == "ota":
+		f.serveDocument(w, r, f.policies, parts[2], body)
+	case r.Method == http.MethodPost:
+		f.nextID++
+		id := "th-" + string(rune('0'+f.nextID))
+		f.thresholds[id] = body
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"threshold_id": id})
+	default:
+		f.serveDocument(w, r, f.thresholds, parts[3], body)
+	}
+}
+
+// serveDocument gets, replaces or deletes a stored document by ID
+func (f *fakeServices) serveDocument(w http.ResponseWriter, r *http.Request, documents map[string]map[string]interface{}, id string, body map[string]interface{}) {
+	if _, ok := documents[id]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(documents[id])
+	case http.MethodPut:
+		documents[id] = body
+	case http.MethodDelete:
+		delete(documents, id)
+	}
+}
+
+func setupReconciler(t *testing.T) (*Reconciler, *fakeServices) {
+	services := newFakeServices(t)
+
+	reconciler := NewReconciler(NewMemoryStateStore())
+	reconciler.Register(KindTemplate, NewTemplateHandler(services.server.URL, nil))
+	reconciler.Register(KindAlertRule, NewAlertRuleHandler(services.server.URL, nil))
+	reconciler.Register(KindDeviceGroup, NewDeviceGroupHandler(services.server.URL, nil))
+	reconciler.Register(KindDeploymentPolicy, NewDeploymentPolicyHandler(services.server.URL, nil))
+	return reconciler, services
+}
+
+const fleetManifest = `
+api_version: athena.io/v1
+resources:
+  - kind: DeploymentPolicy
+    name: greenhouse-canary
+    spec:
+      device_group: greenhouse
+      template_id: soil-monitor
+      strategy: canary
+      rollout_percentage: 10
+  - kind: DeviceGroup
+    name: greenhouse
+    spec:
+      selector:
+        site: greenhouse
+  - kind: Template
+    name: soil-monitor
+    spec:
+      name: Soil Monitor
+      version: 1.0.0
+      boards_supported: [esp32]
+  - kind: AlertRule
+    name: soil-dry
+    spec:
+      device_id: dev-1
+      metric_name: soil_moisture
+      operator: lt
+      value: 20
+      duration: 5m
+      severity: warning
+`
+
+func parseManifest(t *testing.T, data string) *Manifest {
+	manifest, err := ParseManifest([]byte(data))
+	require.NoError(t, err)
+	return manifest
+}
+
+func actions(result *Result) map[string]Action {
+	actions := make(map[string]Action, len(result.Changes))
+	for _, change := range result.Changes {
+		actions[change.Kind+"/"+change.Name] = change.Action
+	}
+	return actions
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest := parseManifest(t, fleetManifest)
+	assert.Len(t, manifest.Resources, 4)
+	assert.Equal(t, float64(10), manifest.Resources[0].Spec["rollout_percentage"])
+
+	_, err := ParseManifest([]byte(`{"api_version": "athena.io/v1", "resources": [{"kind": "Widget", "name": "x"}]}`))
+	assert.ErrorContains(t, err, "unknown kind")
+
+	_, err = ParseManifest([]byte(`{"api_version": "athena.io/v1", "resources": [{"kind": "DeviceGroup", "name": "a"}, {"kind": "DeviceGroup", "name": "a"}]}`))
+	assert.ErrorContains(t, err, "duplicate resource DeviceGroup/a")
+
+	_, err = ParseManifest([]byte(`{"api_version": "v0", "resources": []}`))
+	assert.ErrorContains(t, err, "unsupported api_version")
+
+	_, err = ParseManifest([]byte(`{"api_version": "athena.io/v1", "resources": [{"kind": "DeviceGroup", "name": "Bad Name"}]}`))
+	assert.ErrorContains(t, err, "invalid name")
+}
+
+func TestReconciler_ApplyIsIdempotent(t *testing.T) {
+	reconciler, services := setupReconciler(t)
+	ctx := context.Background()
+	manifest := parseManifest(t, fleetManifest)
+
+	plan, err := reconciler.Apply(ctx, manifest, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, plan.Summary[ActionCreate])
+	assert.Empty(t, services.templates, "dry runs change nothing")
+
+	result, err := reconciler.Apply(ctx, manifest, Options{})
+	require.NoError(t, err)
+	require.Zero(t, result.Failed, result.Changes)
+	assert.Equal(t, []string{KindTemplate, KindDeviceGroup, KindAlertRule, KindDeploymentPolicy},
+		[]string{result.Changes[0].Kind, result.Changes[1].Kind, result.Changes[2].Kind, result.Changes[3].Kind},
+		"groups are created before the policies that target them")
+
+	assert.Equal(t, "Soil Monitor", services.templates["soil-monitor"]["name"])
+	require.Len(t, services.thresholds, 1)
+	for _, threshold := range services.thresholds {
+		assert.Equal(t, float64(300_000_000_000), threshold["duration"])
+		assert.Equal(t, true, threshold["enabled"])
+	}
+	assert.Equal(t, map[string]interface{}{"site": "greenhouse"}, services.groups["greenhouse"]["labels"])
+	require.Len(t, services.policies, 1)
+	for _, policy := range services.policies {
+		assert.Equal(t, "greenhouse-canary", policy["name"])
+		assert.Equal(t, "greenhouse", policy["device_group"])
+		assert.Equal(t, "stable", policy["channel"], "policies follow the stable channel by default")
+		assert.Equal(t, "canary", policy["strategy"])
+	}
+
+	result, err = reconciler.Apply(ctx, manifest, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, map[Action]int{ActionUnchanged: 4}, result.Summary)
+
+	managed, err := reconciler.Managed(ctx)
+	require.NoError(t, err)
+	assert.Len(t, managed, 4)
+}
+
+func TestReconciler_DiffAndUpdate(t *testing.T) {
+	reconciler, services := setupReconciler(t)
+	ctx := context.Background()
+
+	_, err := reconciler.Apply(ctx, parseManifest(t, fleetManifest), Options{})
+	require.NoError(t, err)
+
+	changed := strings.NewReplacer("value: 20", "value: 15", "rollout_percentage: 10", "rollout_percentage: 50").Replace(fleetManifest)
+	result, err := reconciler.Apply(ctx, parseManifest(t, changed), Options{})
+	require.NoError(t, err)
+	require.Zero(t, result.Failed, result.Changes)
+	assert.Equal(t, map[Action]int{ActionUnchanged: 2, ActionUpdate: 2}, result.Summary)
+
+	for _, change := range result.Changes {
+		if change.Kind == KindAlertRule {
+			assert.Equal(t, []FieldDiff{{Path: "value", Current: float64(20), Desired: float64(15)}}, change.Diff)
+		}
+	}
+	for _, threshold := range services.thresholds {
+		assert.Equal(t, float64(15), threshold["value"])
+	}
+	for _, policy := range services.policies {
+		assert.Equal(t, float64(50), policy["rollout_percentage"])
+		assert.Equal(t, "soil-monitor", policy["template_id"])
+	}
+
+	// Changing a published template version is rejected while planning
+	immutable := strings.Replace(fleetManifest, "Soil Monitor", "Soil Monitor Pro", 1)
+	result, err = reconciler.Apply(ctx, parseManifest(t, immutable), Options{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Failed)
+	assert.Contains(t, result.Changes[0].Error, "immutable")
+
+	bumped := strings.Replace(immutable, "version: 1.0.0", "version: 1.1.0", 1)
+	result, err = reconciler.Apply(ctx, parseManifest(t, bumped), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ActionUpdate, actions(result)["Template/soil-monitor"])
+	assert.Equal(t, "1.1.0", services.templates["soil-monitor"]["version"])
+	assert.Equal(t, []interface{}{"esp32"}, services.templates["soil-monitor"]["boards_supported"])
+}
+
+func TestReconciler_Prune(t *testing.T) {
+	reconciler, services := setupReconciler(t)
+	ctx := context.Background()
+
+	_, err := reconciler.Apply(ctx, parseManifest(t, fleetManifest), Options{})
+	require.NoError(t, err)
+
+	empty := parseManifest(t, `{"api_version": "athena.io/v1", "resources": []}`)
+	result, err := reconciler.Apply(ctx, empty, Options{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Changes, "resources are only deleted when pruning")
+
+	result, err = reconciler.Apply(ctx, empty, Options{Prune: true})
+	require.NoError(t, err)
+	require.Zero(t, result.Failed, result.Changes)
+	assert.Equal(t, map[string]Action{
+		"DeploymentPolicy/greenhouse-canary": ActionDelete,
+		"AlertRule/soil-dry":                 ActionDelete,
+		"DeviceGroup/greenhouse":             ActionDelete,
+		"Template/soil-monitor":              ActionRetain,
+	}, actions(result))
+	assert.Equal(t, KindDeploymentPolicy, result.Changes[0].Kind, "deletes run in reverse dependency order")
+
+	assert.Empty(t, services.thresholds)
+	assert.Contains(t, services.templates, "soil-monitor")
+	assert.Empty(t, services.groups)
+	assert.Empty(t, services.policies)
+
+	managed, err := reconciler.Managed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, managed)
+}
+
+func TestReconciler_InvalidSpecs(t *testing.T) {
+	reconciler, _ := setupReconciler(t)
+
+	manifest := parseManifest(t, `
+api_version: athena.io/v1
+resources:
+  - kind: AlertRule
+    name: bad-operator
+    spec: {device_id: dev-1, metric_name: temp, operator: above, value: 30}
+  - kind: DeviceGroup
+    name: empty
+    spec: {description: nothing selected}
+  - kind: DeploymentPolicy
+    name: typo
+    spec: {device_group: greenhouse, template_id: soil-monitor, strategy: canary, rollout_percent: 10}
+  - kind: DeploymentPolicy
+    name: untargeted
+    spec: {device_group: greenhouse, strategy: canary}
+  - kind: DeploymentPolicy
+    name: orphan
+    spec: {device_group: missing, template_id: soil-monitor, strategy: staged}
+`)
+	result, err := reconciler.Apply(context.Background(), manifest, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Failed)
+
+	errs := make(map[string]string)
+	for _, change := range result.Changes {
+		errs[change.Name] = change.Error
+	}
+	assert.Contains(t, errs["bad-operator"], "operator must be one of")
+	assert.Contains(t, errs["empty"], "devices or selector is required")
+	assert.Contains(t, errs["typo"], "unknown field")
+	assert.Contains(t, errs["untargeted"], "template_id is required")
+	assert.Contains(t, errs["orphan"], `unknown device group "missing"`)
+
+	managed, err := reconciler.Managed(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, managed)
+}
+
+func TestManagedResourceEntity(t *testing.T) {
+	resource := ManagedResource{Kind: KindDeploymentPolicy, Name: "greenhouse-canary", ExternalID: "policy-1", AppliedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	assert.Equal(t, resource, resource.ToEntity().FromEntity())
+	assert.Equal(t, "DeploymentPolicy/greenhouse-canary", managedResourceKey(resource.Kind, resource.Name).Name)
+}
@@ -0,0 +1,327 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// serviceClient calls the JSON API of a platform service
+type serviceClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newServiceClient(baseURL string, client *http.Client) serviceClient {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return serviceClient{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// do sends body as JSON and decodes the response into out. A 404 is
+// reported as ErrNotFound; other non-2xx responses carry the service error.
+func (s serviceClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// templateSpec lists the template fields a manifest may manage
+type templateSpec struct {
+	Name            string                   `json:"name,omitempty"`
+	Version         string                   `json:"version"`
+	Category        string                   `json:"category,omitempty"`
+	Description     string                   `json:"description,omitempty"`
+	BoardsSupported []string                 `json:"boards_supported,omitempty"`
+	Schema          map[string]interface{}   `json:"schema,omitempty"`
+	Parameters      map[string]interface{}   `json:"parameters,omitempty"`
+	Libraries       []map[string]interface{} `json:"libraries,omitempty"`
+	Assets          []map[string]interface{} `json:"assets,omitempty"`
+	Includes        []map[string]interface{} `json:"includes,omitempty"`
+}
+
+// TemplateHandler reconciles Template resources through the template service.
+// The resource name is the template ID. Published versions are immutable, so
+// changing a template means declaring a new version; old versions are
+// retained rather than deleted.
+type TemplateHandler struct {
+	service serviceClient
+}
+
+// NewTemplateHandler creates a handler for the template service at baseURL
+func NewTemplateHandler(baseURL string, client *http.Client) *TemplateHandler {
+	return &TemplateHandler{service: newServiceClient(baseURL, client)}
+}
+
+// Normalize validates a template spec
+func (h *TemplateHandler) Normalize(spec map[string]interface{}) (map[string]interface{}, error) {
+	var template templateSpec
+	if err := fromSpec(spec, &template); err != nil {
+		return nil, fmt.Errorf("invalid Template spec: %w", err)
+	}
+	if template.Version == "" {
+		return nil, fmt.Errorf("invalid Template spec: version is required")
+	}
+	return toSpec(template)
+}
+
+// Get returns the latest version of the template
+func (h *TemplateHandler) Get(ctx context.Context, name, externalID string) (map[string]interface{}, error) {
+	var current map[string]interface{}
+	if err := h.service.do(ctx, http.MethodGet, "/api/v1/templates/"+url.PathEscape(name)+"?version=latest", nil, &current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// Create publishes the first version of the template
+func (h *TemplateHandler) Create(ctx context.Context, name string, spec map[string]interface{}) (string, error) {
+	return "", h.publish(ctx, name, spec)
+}
+
+// CheckUpdate rejects changes to an already published version
+func (h *TemplateHandler) CheckUpdate(current, desired map[string]interface{}) error {
+	if current["version"] == desired["version"] {
+		return fmt.Errorf("template version %v is already published and immutable; bump spec.version to publish changes", desired["version"])
+	}
+	return nil
+}
+
+// Update publishes the desired spec as a new version
+func (h *TemplateHandler) Update(ctx context.Context, name, externalID string, current, desired map[string]interface{}) (string, error) {
+	if err := h.CheckUpdate(current, desired); err != nil {
+		return "", err
+	}
+	return "", h.publish(ctx, name, mergeSpec(current, desired))
+}
+
+// Delete retains the template; published versions may be in use by devices
+func (h *TemplateHandler) Delete(ctx context.Context, name, externalID string) error {
+	return ErrDeleteNotSupported
+}
+
+func (h *TemplateHandler) publish(ctx context.Context, name string, spec map[string]interface{}) error {
+	template := mergeSpec(spec, map[string]interface{}{"id": name})
+	delete(template, "created_at")
+	delete(template, "updated_at")
+	return h.service.do(ctx, http.MethodPost, "/api/v1/templates", template, nil)
+}
+
+// alertRuleSpec is an alert threshold on one device metric
+type alertRuleSpec struct {
+	DeviceID   string   `json:"device_id"`
+	MetricName string   `json:"metric_name"`
+	Operator   string   `json:"operator"`
+	Value      *float64 `json:"value"`
+	Duration   string   `json:"duration,omitempty"`
+	Severity   string   `json:"severity,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+var (
+	alertOperators  = []string{"gt", "lt", "eq", "gte", "lte"}
+	alertSeverities = []string{"info", "warning", "critical"}
+)
+
+// AlertRuleHandler reconciles AlertRule resources as telemetry alert
+// thresholds. The external ID is "<device_id>/<threshold_id>".
+type AlertRuleHandler struct {
+	service serviceClient
+}
+
+// NewAlertRuleHandler creates a handler for the telemetry service at baseURL
+func NewAlertRuleHandler(baseURL string, client *http.Client) *AlertRuleHandler {
+	return &AlertRuleHandler{service: newServiceClient(baseURL, client)}
+}
+
+// Normalize validates an alert rule and canonicalizes its duration.
+// Rules are enabled unless the manifest says otherwise.
+func (h *AlertRuleHandler) Normalize(spec map[string]interface{}) (map[string]interface{}, error) {
+	var rule alertRuleSpec
+	if err := fromSpec(spec, &rule); err != nil {
+		return nil, fmt.Errorf("invalid AlertRule spec: %w", err)
+	}
+
+	switch {
+	case rule.DeviceID == "":
+		return nil, fmt.Errorf("invalid AlertRule spec: device_id is required")
+	case rule.MetricName == "":
+		return nil, fmt.Errorf("invalid AlertRule spec: metric_name is required")
+	case rule.Value == nil:
+		return nil, fmt.Errorf("invalid AlertRule spec: value is required")
+	case !containsString(alertOperators, rule.Operator):
+		return nil, fmt.Errorf("invalid AlertRule spec: operator must be one of %s", strings.Join(alertOperators, ", "))
+	case rule.Severity != "" && !containsString(alertSeverities, rule.Severity):
+		return nil, fmt.Errorf("invalid AlertRule spec: severity must be one of %s", strings.Join(alertSeverities, ", "))
+	}
+
+	if rule.Duration != "" {
+		duration, err := time.ParseDuration(rule.Duration)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid AlertRule spec: duration %q is not a valid duration", rule.Duration)
+		}
+		rule.Duration = duration.String()
+	}
+	if rule.Enabled == nil {
+		enabled := true
+		rule.Enabled = &enabled
+	}
+	return toSpec(rule)
+}
+
+// Get reads the threshold an earlier apply created
+func (h *AlertRuleHandler) Get(ctx context.Context, name, externalID string) (map[string]interface{}, error) {
+	if externalID == "" {
+		return nil, ErrNotFound
+	}
+	deviceID, _, _ := strings.Cut(externalID, "/")
+
+	var threshold struct {
+		MetricName string        `json:"metric_name"`
+		Operator   string        `json:"operator"`
+		Value      float64       `json:"value"`
+		Duration   time.Duration `json:"duration"`
+		Severity   string        `json:"severity"`
+		Enabled    bool          `json:"enabled"`
+	}
+	if err := h.service.do(ctx, http.MethodGet, h.thresholdPath(externalID), nil, &threshold); err != nil {
+		return nil, err
+	}
+
+	return toSpec(alertRuleSpec{
+		DeviceID:   deviceID,
+		MetricName: threshold.MetricName,
+		Operator:   threshold.Operator,
+		Value:      &threshold.Value,
+		Duration:   threshold.Duration.String(),
+		Severity:   threshold.Severity,
+		Enabled:    &threshold.Enabled,
+	})
+}
+
+// Create adds a threshold for the rule's device
+func (h *AlertRuleHandler) Create(ctx context.Context, name string, spec map[string]interface{}) (string, error) {
+	threshold, err := h.threshold(name, spec)
+	if err != nil {
+		return "", err
+	}
+	deviceID := spec["device_id"].(string)
+
+	var created struct {
+		ThresholdID string `json:"threshold_id"`
+	}
+	if err := h.service.do(ctx, http.MethodPost, "/api/v1/telemetry/thresholds/"+url.PathEscape(deviceID), threshold, &created); err != nil {
+		return "", err
+	}
+	return deviceID + "/" + created.ThresholdID, nil
+}
+
+// Update replaces the threshold. Moving a rule to another device recreates it.
+func (h *AlertRuleHandler) Update(ctx context.Context, name, externalID string, current, desired map[string]interface{}) (string, error) {
+	spec := mergeSpec(current, desired)
+	if spec["device_id"] != current["device_id"] {
+		newID, err := h.Create(ctx, name, spec)
+		if err != nil {
+			return "", err
+		}
+		return newID, h.Delete(ctx, name, externalID)
+	}
+
+	threshold, err := h.threshold(name, spec)
+	if err != nil {
+		return "", err
+	}
+	return externalID, h.service.do(ctx, http.MethodPut, h.thresholdPath(externalID), threshold, nil)
+}
+
+// Delete removes the threshold
+func (h *AlertRuleHandler) Delete(ctx context.Context, name, externalID string) error {
+	err := h.service.do(ctx, http.MethodDelete, h.thresholdPath(externalID), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (h *AlertRuleHandler) thresholdPath(externalID string) string {
+	deviceID, thresholdID, _ := strings.Cut(externalID, "/")
+	return "/api/v1/telemetry/thresholds/" + url.PathEscape(deviceID) + "/" + url.PathEscape(thresholdID)
+}
+
+// threshold converts a rule spec into the telemetry service's threshold body
+func (h *AlertRuleHandler) threshold(name string, spec map[string]interface{}) (map[string]interface{}, error) {
+	var rule alertRuleSpec
+	if err := fromSpec(spec, &rule); err != nil {
+		return nil, err
+	}
+
+	var duration time.Duration
+	if rule.Duration != "" {
+		duration, _ = time.ParseDuration(rule.Duration)
+	}
+	threshold := map[string]interface{}{
+		"metric_name": rule.MetricName,
+		"operator":    rule.Operator,
+		"value":       *rule.Value,
+		"duration":    duration,
+		"severity":    rule.Severity,
+		"enabled":     rule.Enabled == nil || *rule.Enabled,
+		"metadata":    map[string]interface{}{"managed_by": "athena-apply", "name": name},
+	}
+	return threshold, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package declarative
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ManagedResourceEntity represents a managed resource in Datastore
+type ManagedResourceEntity struct {
+	Kind       string    `datastore:"kind"`
+	Name       string    `datastore:"name"`
+	ExternalID string    `datastore:"external_id,noindex"`
+	AppliedAt  time.Time `datastore:"applied_at,noindex"`
+}
+
+// ToEntity converts a ManagedResource to a ManagedResourceEntity
+func (r *ManagedResource) ToEntity() *ManagedResourceEntity {
+	return &ManagedResourceEntity{
+		Kind:       r.Kind,
+		Name:       r.Name,
+		ExternalID: r.ExternalID,
+		AppliedAt:  r.AppliedAt,
+	}
+}
+
+// FromEntity converts a ManagedResourceEntity to a ManagedResource
+func (re *ManagedResourceEntity) FromEntity() ManagedResource {
+	return ManagedResource{
+		Kind:       re.Kind,
+		Name:       re.Name,
+		ExternalID: re.ExternalID,
+		AppliedAt:  re.AppliedAt,
+	}
+}
+
+// DatastoreStateStore keeps managed resources in Datastore, keyed by kind
+// and name, so every gateway replica prunes the same set
+type DatastoreStateStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreStateStore creates a state store on a Datastore client
+func NewDatastoreStateStore(client *datastore.Client) *DatastoreStateStore {
+	return &DatastoreStateStore{client: client}
+}
+
+func managedResourceKey(kind, name string) *datastore.Key {
+	return datastore.NameKey("ManagedResource", kind+"/"+name, nil)
+}
+
+// List returns managed resources ordered by kind and name
+func (s *DatastoreStateStore) List(ctx context.Context) ([]ManagedResource, error) {
+	query := datastore.NewQuery("ManagedResource").Order("kind").Order("name")
+
+	var entities []*ManagedResourceEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list managed resources from Datastore: %w", err)
+	}
+	resources := make([]ManagedResource, len(entities))
+	for i, entity := range entities {
+		resources[i] = entity.FromEntity()
+	}
+	return resources, nil
+}
+
+// Get returns a managed resource or ErrNotFound
+func (s *DatastoreStateStore) Get(ctx context.Context, kind, name string) (*ManagedResource, error) {
+	var entity ManagedResourceEntity
+	switch err := s.client.Get(ctx, managedResourceKey(kind, name), &entity); err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("failed to get managed resource from Datastore: %w", err)
+	}
+	resource := entity.FromEntity()
+	return &resource, nil
+}
+
+// Put records a managed resource
+func (s *DatastoreStateStore) Put(ctx context.Context, resource ManagedResource) error {
+	if _, err := s.client.Put(ctx, managedResourceKey(resource.Kind, resource.Name), resource.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save managed resource in Datastore: %w", err)
+	}
+	return nil
+}
+
+// Delete forgets a managed resource
+func (s *DatastoreStateStore) Delete(ctx context.Context, kind, name string) error {
+	if err := s.client.Delete(ctx, managedResourceKey(kind, name)); err != nil {
+		return fmt.Errorf("failed to delete managed resource from Datastore: %w", err)
+	}
+	return nil
+}
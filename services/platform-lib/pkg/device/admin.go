@@ -0,0 +1,63 @@
+package device
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+// defaultOTAChannel is the channel devices registered without one follow
+const defaultOTAChannel = "stable"
+
+// AdminTasks returns the device service's operational tasks
+func AdminTasks(repo Repository) []admin.Task {
+	return []admin.Task{
+		{
+			Name:        "reindex",
+			Description: "Rewrite every device so Datastore indexes match the current schema",
+			Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+				devices, err := repo.ListDevices(ctx, &DeviceFilters{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to list devices: %w", err)
+				}
+				for i, d := range devices {
+					if err := repo.UpdateDevice(ctx, d); err != nil {
+						return admin.Result{"reindexed": i}, fmt.Errorf("failed to rewrite device %s: %w", d.DeviceID, err)
+					}
+				}
+				return admin.Result{"reindexed": len(devices)}, nil
+			},
+		},
+		admin.MigrateTask(Migrations(repo)...),
+	}
+}
+
+// Migrations returns the device data migrations in the order they apply
+func Migrations(repo Repository) []admin.Migration {
+	return []admin.Migration{
+		{
+			ID:          "0001-default-ota-channel",
+			Description: "Put devices registered without an OTA channel on the stable channel",
+			Up: func(ctx context.Context) (int, error) {
+				// An empty channel filter matches every device, so filter here
+				devices, err := repo.ListDevices(ctx, &DeviceFilters{})
+				if err != nil {
+					return 0, fmt.Errorf("failed to list devices: %w", err)
+				}
+				changed := 0
+				for _, d := range devices {
+					if d.OTAChannel != "" {
+						continue
+					}
+					d.OTAChannel = defaultOTAChannel
+					if err := repo.UpdateDevice(ctx, d); err != nil {
+						return changed, fmt.Errorf("failed to update device %s: %w", d.DeviceID, err)
+					}
+					changed++
+				}
+				return changed, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package device
+
+import (
+	"context"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_DefaultOTAChannel(t *testing.T) {
+	repo := new(MockRepository)
+	ctx := context.Background()
+	unassigned := &Device{DeviceID: "device-1"}
+	beta := &Device{DeviceID: "device-2", OTAChannel: "beta"}
+	repo.On("ListDevices", ctx, mock.Anything).Return([]*Device{unassigned, beta}, nil)
+	repo.On("UpdateDevice", ctx, unassigned).Return(nil)
+
+	result, err := admin.MigrateTask(Migrations(repo)...).Run(ctx, admin.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"0001-default-ota-channel": 1}, result["migrations"])
+	assert.Equal(t, "stable", unassigned.OTAChannel)
+	assert.Equal(t, "beta", beta.OTAChannel)
+	repo.AssertNumberOfCalls(t, "UpdateDevice", 1)
+}
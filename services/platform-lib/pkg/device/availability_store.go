@@ -0,0 +1,121 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
+)
+
+// OnlinePeriodEntity represents an online period in Datastore
+type OnlinePeriodEntity struct {
+	DeviceID string    `datastore:"device_id"`
+	Start    time.Time `datastore:"start"`
+	End      time.Time `datastore:"end"`
+}
+
+// ToEntity converts an OnlinePeriod to an OnlinePeriodEntity
+func (p *OnlinePeriod) ToEntity() *OnlinePeriodEntity {
+	return &OnlinePeriodEntity{
+		DeviceID: p.DeviceID,
+		Start:    p.Start,
+		End:      p.End,
+	}
+}
+
+// FromEntity converts an OnlinePeriodEntity to an OnlinePeriod
+func (pe *OnlinePeriodEntity) FromEntity() *OnlinePeriod {
+	return &OnlinePeriod{
+		DeviceID: pe.DeviceID,
+		Start:    pe.Start,
+		End:      pe.End,
+	}
+}
+
+// DatastoreAvailabilityStore keeps online periods in Datastore, keyed by
+// device and start, so extending a period overwrites it. Periods older than
+// the retention are dropped when a device starts a new one.
+type DatastoreAvailabilityStore struct {
+	client *datastore.Client
+	now    func() time.Time
+}
+
+// NewDatastoreAvailabilityStore creates an availability store on a
+// Datastore client
+func NewDatastoreAvailabilityStore(client *datastore.Client) *DatastoreAvailabilityStore {
+	return &DatastoreAvailabilityStore{client: client, now: time.Now}
+}
+
+func onlinePeriodKey(deviceID string, start time.Time) *datastore.Key {
+	return datastore.NameKey("OnlinePeriod", fmt.Sprintf("%s#%d", deviceID, start.UnixNano()), nil)
+}
+
+func (s *DatastoreAvailabilityStore) LastPeriod(ctx context.Context, deviceID string) (*OnlinePeriod, error) {
+	query := datastore.NewQuery("OnlinePeriod").
+		Filter("device_id =", deviceID).
+		Order("-start").
+		Limit(1)
+
+	var entities []*OnlinePeriodEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to get online period from Datastore: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities[0].FromEntity(), nil
+}
+
+func (s *DatastoreAvailabilityStore) SavePeriod(ctx context.Context, period *OnlinePeriod) error {
+	if _, err := s.client.Put(ctx, onlinePeriodKey(period.DeviceID, period.Start), period.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save online period in Datastore: %w", err)
+	}
+
+	// Only a new period can push an old one past the retention
+	if !period.End.Equal(period.Start) {
+		return nil
+	}
+	query := datastore.NewQuery("OnlinePeriod").
+		Filter("device_id =", period.DeviceID).
+		Filter("end <", s.now().Add(-availabilityRetention)).
+		KeysOnly()
+	keys, err := s.client.GetAll(ctx, query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to query expired online periods from Datastore: %w", err)
+	}
+	if len(keys) > 0 {
+		if err := s.client.DeleteMulti(ctx, keys); err != nil {
+			return fmt.Errorf("failed to delete expired online periods from Datastore: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListPeriods queries by start only, as Datastore filters one property by
+// inequality; periods ending before from are skipped as they are read
+func (s *DatastoreAvailabilityStore) ListPeriods(ctx context.Context, deviceID string, from, to time.Time) ([]*OnlinePeriod, error) {
+	query := datastore.NewQuery("OnlinePeriod").
+		Filter("device_id =", deviceID).
+		Filter("start <=", to).
+		Order("start")
+
+	var periods []*OnlinePeriod
+	it := s.client.Run(ctx, query)
+	for {
+		var entity OnlinePeriodEntity
+		_, err := it.Next(&entity)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list online periods from Datastore: %w", err)
+		}
+		if entity.End.Before(from) {
+			continue
+		}
+		periods = append(periods, entity.FromEntity())
+	}
+	return periods, nil
+}
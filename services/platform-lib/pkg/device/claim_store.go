@@ -0,0 +1,302 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// MemoryClaimTokenStore is an in-memory ClaimTokenStore
+type MemoryClaimTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*ClaimToken
+}
+
+// NewMemoryClaimTokenStore creates an empty store
+func NewMemoryClaimTokenStore() *MemoryClaimTokenStore {
+	return &MemoryClaimTokenStore{tokens: make(map[string]*ClaimToken)}
+}
+
+func (s *MemoryClaimTokenStore) CreateClaimToken(ctx context.Context, token *ClaimToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tokens[token.TokenID]; exists {
+		return fmt.Errorf("claim token %s already exists", token.TokenID)
+	}
+	stored := *token
+	stored.Token = ""
+	s.tokens[token.TokenID] = &stored
+	return nil
+}
+
+func (s *MemoryClaimTokenStore) GetClaimToken(ctx context.Context, tokenID string) (*ClaimToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(tokenID)
+}
+
+func (s *MemoryClaimTokenStore) ListClaimTokens(ctx context.Context) ([]*ClaimToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := make([]*ClaimToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		listed := *token
+		tokens = append(tokens, &listed)
+	}
+	sortClaimTokens(tokens)
+	return tokens, nil
+}
+
+func (s *MemoryClaimTokenStore) ModifyClaimToken(ctx context.Context, tokenID string, modify func(*ClaimToken) error) (*ClaimToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.load(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(token); err != nil {
+		return nil, err
+	}
+	saved := *token
+	s.tokens[tokenID] = &saved
+	return token, nil
+}
+
+func (s *MemoryClaimTokenStore) DeleteClaimToken(ctx context.Context, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tokens[tokenID]; !exists {
+		return fmt.Errorf("%w: %s", ErrClaimTokenNotFound, tokenID)
+	}
+	delete(s.tokens, tokenID)
+	return nil
+}
+
+// load returns a copy of a stored token. Parameters and labels are
+// replaced rather than changed, so the copy can be modified freely.
+func (s *MemoryClaimTokenStore) load(tokenID string) (*ClaimToken, error) {
+	token, ok := s.tokens[tokenID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClaimTokenNotFound, tokenID)
+	}
+	loaded := *token
+	return &loaded, nil
+}
+
+// sortClaimTokens orders tokens newest first
+func sortClaimTokens(tokens []*ClaimToken) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+}
+
+// ClaimTokenEntity represents a claim token in Datastore. An unclaimed
+// token is stored with a zero claim time.
+type ClaimTokenEntity struct {
+	Description     string    `datastore:"description,noindex"`
+	BoardType       string    `datastore:"board_type"`
+	TemplateID      string    `datastore:"template_id"`
+	TemplateVersion string    `datastore:"template_version"`
+	ParametersJSON  string    `datastore:"parameters_json,noindex"`
+	OTAChannel      string    `datastore:"ota_channel"`
+	LabelsJSON      string    `datastore:"labels_json,noindex"`
+	ParentID        string    `datastore:"parent_id"`
+	Fingerprint     string    `datastore:"fingerprint,noindex"`
+	Status          string    `datastore:"status"`
+	CreatedAt       time.Time `datastore:"created_at"`
+	ExpiresAt       time.Time `datastore:"expires_at"`
+	ClaimedAt       time.Time `datastore:"claimed_at,noindex"`
+	DeviceID        string    `datastore:"device_id"`
+}
+
+// ToEntity converts a ClaimToken to a ClaimTokenEntity. The token's secret
+// is never stored.
+func (t *ClaimToken) ToEntity() (*ClaimTokenEntity, error) {
+	entity := &ClaimTokenEntity{
+		Description:     t.Description,
+		BoardType:       t.BoardType,
+		TemplateID:      t.TemplateID,
+		TemplateVersion: t.TemplateVersion,
+		OTAChannel:      t.OTAChannel,
+		ParentID:        t.ParentID,
+		Fingerprint:     t.Fingerprint,
+		Status:          t.Status,
+		CreatedAt:       t.CreatedAt,
+		ExpiresAt:       t.ExpiresAt,
+		DeviceID:        t.DeviceID,
+	}
+	if len(t.Parameters) > 0 {
+		parametersJSON, err := json.Marshal(t.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal claim token parameters: %w", err)
+		}
+		entity.ParametersJSON = string(parametersJSON)
+	}
+	if len(t.Labels) > 0 {
+		labelsJSON, err := json.Marshal(t.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal claim token labels: %w", err)
+		}
+		entity.LabelsJSON = string(labelsJSON)
+	}
+	if t.ClaimedAt != nil {
+		entity.ClaimedAt = *t.ClaimedAt
+	}
+	return entity, nil
+}
+
+// FromEntity converts a ClaimTokenEntity to the ClaimToken stored under
+// tokenID
+func (ce *ClaimTokenEntity) FromEntity(tokenID string) (*ClaimToken, error) {
+	token := &ClaimToken{
+		TokenID:         tokenID,
+		Description:     ce.Description,
+		BoardType:       ce.BoardType,
+		TemplateID:      ce.TemplateID,
+		TemplateVersion: ce.TemplateVersion,
+		OTAChannel:      ce.OTAChannel,
+		ParentID:        ce.ParentID,
+		Fingerprint:     ce.Fingerprint,
+		Status:          ce.Status,
+		CreatedAt:       ce.CreatedAt,
+		ExpiresAt:       ce.ExpiresAt,
+		DeviceID:        ce.DeviceID,
+	}
+	if ce.ParametersJSON != "" {
+		if err := json.Unmarshal([]byte(ce.ParametersJSON), &token.Parameters); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal claim token parameters: %w", err)
+		}
+	}
+	if ce.LabelsJSON != "" {
+		if err := json.Unmarshal([]byte(ce.LabelsJSON), &token.Labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal claim token labels: %w", err)
+		}
+	}
+	if !ce.ClaimedAt.IsZero() {
+		claimedAt := ce.ClaimedAt
+		token.ClaimedAt = &claimedAt
+	}
+	return token, nil
+}
+
+// DatastoreClaimTokenStore keeps claim tokens in Datastore, keyed by token
+// ID
+type DatastoreClaimTokenStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreClaimTokenStore creates a claim token store on a Datastore
+// client
+func NewDatastoreClaimTokenStore(client *datastore.Client) *DatastoreClaimTokenStore {
+	return &DatastoreClaimTokenStore{client: client}
+}
+
+func (s *DatastoreClaimTokenStore) CreateClaimToken(ctx context.Context, token *ClaimToken) error {
+	entity, err := token.ToEntity()
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Put(ctx, datastore.NameKey("DeviceClaimToken", token.TokenID, nil), entity); err != nil {
+		return fmt.Errorf("failed to store claim token in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreClaimTokenStore) GetClaimToken(ctx context.Context, tokenID string) (*ClaimToken, error) {
+	var entity ClaimTokenEntity
+	if err := s.client.Get(ctx, datastore.NameKey("DeviceClaimToken", tokenID, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, fmt.Errorf("%w: %s", ErrClaimTokenNotFound, tokenID)
+		}
+		return nil, fmt.Errorf("failed to retrieve claim token from Datastore: %w", err)
+	}
+	return entity.FromEntity(tokenID)
+}
+
+// ListClaimTokens sorts the tokens in memory, so no index is needed
+func (s *DatastoreClaimTokenStore) ListClaimTokens(ctx context.Context) ([]*ClaimToken, error) {
+	var entities []ClaimTokenEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("DeviceClaimToken"), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claim tokens from Datastore: %w", err)
+	}
+
+	tokens := make([]*ClaimToken, 0, len(entities))
+	for i := range entities {
+		token, err := entities[i].FromEntity(keys[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	sortClaimTokens(tokens)
+	return tokens, nil
+}
+
+// ModifyClaimToken applies modify inside a transaction, so two devices
+// claiming the same token cannot both succeed
+func (s *DatastoreClaimTokenStore) ModifyClaimToken(ctx context.Context, tokenID string, modify func(*ClaimToken) error) (*ClaimToken, error) {
+	key := datastore.NameKey("DeviceClaimToken", tokenID, nil)
+
+	var token *ClaimToken
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity ClaimTokenEntity
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			return fmt.Errorf("%w: %s", ErrClaimTokenNotFound, tokenID)
+		default:
+			return fmt.Errorf("failed to retrieve claim token from Datastore: %w", err)
+		}
+
+		var err error
+		if token, err = entity.FromEntity(tokenID); err != nil {
+			return err
+		}
+		if err := modify(token); err != nil {
+			return err
+		}
+
+		updated, err := token.ToEntity()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update claim token in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (s *DatastoreClaimTokenStore) DeleteClaimToken(ctx context.Context, tokenID string) error {
+	key := datastore.NameKey("DeviceClaimToken", tokenID, nil)
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity ClaimTokenEntity
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			return fmt.Errorf("%w: %s", ErrClaimTokenNotFound, tokenID)
+		default:
+			return fmt.Errorf("failed to retrieve claim token from Datastore: %w", err)
+		}
+		return tx.Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete claim token from Datastore: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package device
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrClaimTokenNotFound is returned for claim tokens that were never
+	// created or were revoked
+	ErrClaimTokenNotFound = errors.New("claim token not found")
+
+	// ErrInvalidClaimTokenRequest is returned for claim tokens that cannot
+	// be created as requested
+	ErrInvalidClaimTokenRequest = errors.New("invalid claim token request")
+
+	// ErrInvalidClaimToken is returned when a device claims with a token
+	// that is malformed, unknown or bound to another board
+	ErrInvalidClaimToken = errors.New("invalid claim token")
+
+	// ErrClaimTokenUsed is returned when a device claims with a token that
+	// already registered a device
+	ErrClaimTokenUsed = errors.New("claim token already used")
+
+	// ErrClaimTokenExpired is returned when a device claims with an
+	// expired token
+	ErrClaimTokenExpired = errors.New("claim token expired")
+)
+
+// Claim token statuses. Tokens are active until a device claims them or
+// they expire.
+const (
+	ClaimTokenActive  = "active"
+	ClaimTokenClaimed = "claimed"
+	ClaimTokenExpired = "expired"
+)
+
+const (
+	// defaultClaimTokenTTL is how long a claim token is valid when the
+	// request sets no TTL
+	defaultClaimTokenTTL = 30 * 24 * time.Hour
+
+	// maxClaimTokenTTL bounds how long a claim token may stay valid
+	maxClaimTokenTTL = 365 * 24 * time.Hour
+)
+
+// ClaimToken pre-provisions the registration of a device. An operator
+// creates it for a template and board and flashes Token into the firmware;
+// the device then registers itself once with the token. Only the token's
+// fingerprint is stored, and Token is only returned on creation.
+type ClaimToken struct {
+	TokenID         string                 `json:"token_id"`
+	Token           string                 `json:"token,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	BoardType       string                 `json:"board_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	Fingerprint     string                 `json:"-"`
+	Status          string                 `json:"status"`
+	CreatedAt       time.Time              `json:"created_at"`
+	ExpiresAt       time.Time              `json:"expires_at"`
+	ClaimedAt       *time.Time             `json:"claimed_at,omitempty"`
+	DeviceID        string                 `json:"device_id,omitempty"`
+}
+
+// ClaimTokenRequest creates a claim token. TTLSeconds is how long the token
+// can be claimed, 30 days if unset.
+type ClaimTokenRequest struct {
+	Description     string                 `json:"description,omitempty"`
+	BoardType       string                 `json:"board_type" binding:"required"`
+	TemplateID      string                 `json:"template_id" binding:"required"`
+	TemplateVersion string                 `json:"template_version" binding:"required"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	TTLSeconds      int                    `json:"ttl_seconds,omitempty"`
+}
+
+// DeviceClaimRequest is a device registering itself with a claim token.
+// The template, board and parameters come from the token; the device adds
+// what only it knows. A BoardType that differs from the token's is
+// rejected.
+type DeviceClaimRequest struct {
+	Token        string `json:"token" binding:"required"`
+	DeviceID     string `json:"device_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	BoardType    string `json:"board_type,omitempty"`
+	FirmwareHash string `json:"firmware_hash" binding:"required"`
+	FlashSize    int64  `json:"flash_size,omitempty"`
+	OTAFreeSpace int64  `json:"ota_free_space,omitempty"`
+}
+
+// expire marks an unclaimed token past its expiry as expired. Stored
+// tokens are expired when next read or written.
+func (t *ClaimToken) expire(now time.Time) {
+	if t.Status == ClaimTokenActive && now.After(t.ExpiresAt) {
+		t.Status = ClaimTokenExpired
+	}
+}
+
+// registrationRequest is the registration a claim makes with the token
+func (t *ClaimToken) registrationRequest(claim *DeviceClaimRequest) *DeviceRegistrationRequest {
+	return &DeviceRegistrationRequest{
+		DeviceID:        claim.DeviceID,
+		Name:            claim.Name,
+		BoardType:       t.BoardType,
+		TemplateID:      t.TemplateID,
+		TemplateVersion: t.TemplateVersion,
+		Parameters:      t.Parameters,
+		FirmwareHash:    claim.FirmwareHash,
+		OTAChannel:      t.OTAChannel,
+		Labels:          t.Labels,
+		ParentID:        t.ParentID,
+		FlashSize:       claim.FlashSize,
+		OTAFreeSpace:    claim.OTAFreeSpace,
+	}
+}
+
+// ClaimTokenStore persists claim tokens
+type ClaimTokenStore interface {
+	// CreateClaimToken stores a new token
+	CreateClaimToken(ctx context.Context, token *ClaimToken) error
+	// GetClaimToken returns a token, or ErrClaimTokenNotFound
+	GetClaimToken(ctx context.Context, tokenID string) (*ClaimToken, error)
+	// ListClaimTokens returns all tokens, newest first
+	ListClaimTokens(ctx context.Context) ([]*ClaimToken, error)
+	// ModifyClaimToken applies modify to a stored token so that concurrent
+	// claims cannot both use it. An error from modify aborts the write and
+	// is returned unchanged.
+	ModifyClaimToken(ctx context.Context, tokenID string, modify func(*ClaimToken) error) (*ClaimToken, error)
+	// DeleteClaimToken removes a token, or returns ErrClaimTokenNotFound
+	DeleteClaimToken(ctx context.Context, tokenID string) error
+}
+
+// SetClaimTokenStore sets where claim tokens are persisted
+func (s *Service) SetClaimTokenStore(store ClaimTokenStore) {
+	s.claimTokens = store
+}
+
+// CreateClaimToken creates a single-use token a device can register itself
+// with. The returned token carries its secret, which is not stored.
+func (s *Service) CreateClaimToken(ctx context.Context, req *ClaimTokenRequest) (*ClaimToken, error) {
+	if req.TTLSeconds < 0 || time.Duration(req.TTLSeconds)*time.Second > maxClaimTokenTTL {
+		return nil, fmt.Errorf("%w: ttl_seconds must be between 0 and %d", ErrInvalidClaimTokenRequest, int(maxClaimTokenTTL.Seconds()))
+	}
+	if req.ParentID != "" {
+		if _, err := s.repository.GetDevice(ctx, req.ParentID); err != nil {
+			return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+		}
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate claim token: %w", err)
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultClaimTokenTTL
+	}
+	now := time.Now().UTC()
+	token := &ClaimToken{
+		TokenID:         uuid.New().String(),
+		Description:     req.Description,
+		BoardType:       req.BoardType,
+		TemplateID:      req.TemplateID,
+		TemplateVersion: req.TemplateVersion,
+		Parameters:      req.Parameters,
+		OTAChannel:      req.OTAChannel,
+		Labels:          req.Labels,
+		ParentID:        req.ParentID,
+		Fingerprint:     fingerprint([]byte(secret)),
+		Status:          ClaimTokenActive,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+	}
+	if err := s.claimTokens.CreateClaimToken(ctx, token); err != nil {
+		return nil, fmt.Errorf("failed to store claim token: %w", err)
+	}
+	s.logger.Info("Created device claim token", "token_id", token.TokenID, "template_id", token.TemplateID, "board_type", token.BoardType)
+
+	// The token ID travels with the secret, so a claim finds its token
+	// without a lookup by fingerprint
+	token.Token = token.TokenID + "." + secret
+	return token, nil
+}
+
+// GetClaimToken returns a claim token without its secret
+func (s *Service) GetClaimToken(ctx context.Context, tokenID string) (*ClaimToken, error) {
+	token, err := s.claimTokens.GetClaimToken(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	token.expire(time.Now())
+	return token, nil
+}
+
+// ListClaimTokens returns the claim tokens, newest first, optionally only
+// those in status
+func (s *Service) ListClaimTokens(ctx context.Context, status string) ([]*ClaimToken, error) {
+	tokens, err := s.claimTokens.ListClaimTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claim tokens: %w", err)
+	}
+
+	now := time.Now()
+	listed := make([]*ClaimToken, 0, len(tokens))
+	for _, token := range tokens {
+		token.expire(now)
+		if status == "" || token.Status == status {
+			listed = append(listed, token)
+		}
+	}
+	return listed, nil
+}
+
+// RevokeClaimToken deletes a claim token, so no device can claim it.
+// Devices that already claimed it stay registered.
+func (s *Service) RevokeClaimToken(ctx context.Context, tokenID string) error {
+	if err := s.claimTokens.DeleteClaimToken(ctx, tokenID); err != nil {
+		return err
+	}
+	s.logger.Info("Revoked device claim token", "token_id", tokenID)
+	return nil
+}
+
+// reserveClaimToken marks the token of a claim as claimed, so a concurrent
+// claim with the same token fails. The token is released again if the
+// registration fails.
+func (s *Service) reserveClaimToken(ctx context.Context, claim *DeviceClaimRequest) (*ClaimToken, error) {
+	tokenID, secret, ok := strings.Cut(claim.Token, ".")
+	if !ok || tokenID == "" || secret == "" {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidClaimToken)
+	}
+
+	now := time.Now().UTC()
+	token, err := s.claimTokens.ModifyClaimToken(ctx, tokenID, func(token *ClaimToken) error {
+		if subtle.ConstantTimeCompare([]byte(fingerprint([]byte(secret))), []byte(token.Fingerprint)) != 1 {
+			return ErrInvalidClaimToken
+		}
+		token.expire(now)
+		switch token.Status {
+		case ClaimTokenClaimed:
+			return ErrClaimTokenUsed
+		case ClaimTokenExpired:
+			return ErrClaimTokenExpired
+		}
+		if claim.BoardType != "" && claim.BoardType != token.BoardType {
+			return fmt.Errorf("%w: token is for board %s", ErrInvalidClaimToken, token.BoardType)
+		}
+		token.Status = ClaimTokenClaimed
+		token.ClaimedAt = &now
+		return nil
+	})
+	if errors.Is(err, ErrClaimTokenNotFound) {
+		// Unknown and revoked tokens are as invalid as wrong secrets
+		return nil, ErrInvalidClaimToken
+	}
+	return token, err
+}
+
+// completeClaim records the device registered with a reserved token, or
+// releases the token when registration failed
+func (s *Service) completeClaim(ctx context.Context, tokenID string, device *Device) {
+	_, err := s.claimTokens.ModifyClaimToken(ctx, tokenID, func(token *ClaimToken) error {
+		if device != nil {
+			token.DeviceID = device.DeviceID
+			return nil
+		}
+		token.Status = ClaimTokenActive
+		token.ClaimedAt = nil
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to update device claim token", "token_id", tokenID, "error", err)
+	}
+}
+
+func (s *Service) claimDevice(c *gin.Context) {
+	var claim DeviceClaimRequest
+	if err := c.ShouldBindJSON(&claim); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := s.reserveClaimToken(ctx, &claim)
+	if err != nil {
+		s.respondClaimTokenError(c, err)
+		return
+	}
+
+	device, issued, ok := s.register(c, token.registrationRequest(&claim))
+	s.completeClaim(ctx, token.TokenID, device)
+	if !ok {
+		return
+	}
+	s.logger.Info("Device claimed registration token", "device_id", device.DeviceID, "token_id", token.TokenID)
+
+	// As on registration, the issued credential's secret is only returned
+	// here
+	c.JSON(http.StatusCreated, struct {
+		*Device
+		IssuedCredential *IssuedCredential `json:"issued_credential,omitempty"`
+	}{device, issued})
+}
+
+func (s *Service) createClaimToken(c *gin.Context) {
+	var req ClaimTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	token, err := s.CreateClaimToken(c.Request.Context(), &req)
+	if err != nil {
+		s.respondClaimTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, token)
+}
+
+func (s *Service) listClaimTokens(c *gin.Context) {
+	tokens, err := s.ListClaimTokens(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		s.respondClaimTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+		"total":  len(tokens),
+	})
+}
+
+func (s *Service) getClaimToken(c *gin.Context) {
+	token, err := s.GetClaimToken(c.Request.Context(), c.Param("tokenId"))
+	if err != nil {
+		s.respondClaimTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+func (s *Service) revokeClaimToken(c *gin.Context) {
+	if err := s.RevokeClaimToken(c.Request.Context(), c.Param("tokenId")); err != nil {
+		s.respondClaimTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Claim token revoked successfully",
+	})
+}
+
+// respondClaimTokenError maps claim token errors to responses. Claims with
+// unusable tokens are unauthorized, except for tokens already used, which
+// conflict with the device they registered.
+func (s *Service) respondClaimTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidClaimTokenRequest):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid claim token request",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrInvalidClaimToken), errors.Is(err, ErrClaimTokenExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Invalid claim token",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrClaimTokenUsed):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Claim token already used",
+			"details": err.Error(),
+		})
+	case errors.Is(err, errDeviceLookup):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Parent device not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrClaimTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Claim token not found",
+			"details": err.Error(),
+		})
+	default:
+		s.logger.Error("Failed to access device claim tokens", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to access device claim tokens",
+			"details": err.Error(),
+		})
+	}
+}
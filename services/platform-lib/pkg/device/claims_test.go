@@ -0,0 +1,168 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ClaimDevice(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.claimTokens = NewMemoryClaimTokenStore()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	var registered []*Device
+	mockRepo.On("RegisterDevice", mock.Anything, mock.MatchedBy(func(d *Device) bool { return d.DeviceID == "device-fail" })).
+		Return(errors.New("datastore unavailable"))
+	mockRepo.On("RegisterDevice", mock.Anything, mock.AnythingOfType("*device.Device")).
+		Run(func(args mock.Arguments) { registered = append(registered, args.Get(1).(*Device)) }).Return(nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/devices"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	claim := func(token, deviceID, boardType string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(DeviceClaimRequest{Token: token, DeviceID: deviceID, BoardType: boardType, FirmwareHash: "abc"})
+		return send("POST", "/claim", string(body))
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/claim-tokens", `{"board_type":"esp32"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/claim-tokens",
+		`{"board_type":"esp32","template_id":"blink","template_version":"1.0.0","ttl_seconds":-1}`).Code)
+
+	w := send("POST", "/claim-tokens", `{"board_type":"esp32","template_id":"blink","template_version":"1.0.0",
+		"parameters":{"pin":2},"labels":{"site":"lab1"},"ttl_seconds":3600}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var token ClaimToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, ClaimTokenActive, token.Status)
+	assert.True(t, strings.HasPrefix(token.Token, token.TokenID+"."))
+	assert.WithinDuration(t, token.CreatedAt.Add(time.Hour), token.ExpiresAt, time.Second)
+
+	// The secret is only returned on creation
+	w = send("GET", "/claim-tokens", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), token.Token)
+
+	tokenID, _, _ := strings.Cut(token.Token, ".")
+	assert.Equal(t, http.StatusUnauthorized, claim(tokenID+".wrong-secret", "device-1", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, claim("malformed", "device-1", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, claim(token.Token, "device-1", "uno").Code)
+
+	// A failed registration leaves the token claimable
+	assert.Equal(t, http.StatusInternalServerError, claim(token.Token, "device-fail", "").Code)
+
+	w = claim(token.Token, "device-1", "esp32")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var claimed struct {
+		Device
+		IssuedCredential *IssuedCredential `json:"issued_credential"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claimed))
+	assert.Equal(t, "device-1", claimed.DeviceID)
+	assert.NotNil(t, claimed.IssuedCredential)
+	require.Len(t, registered, 1)
+	assert.Equal(t, "blink", registered[0].TemplateID)
+	assert.Equal(t, "esp32", registered[0].BoardType)
+	assert.Equal(t, map[string]string{"site": "lab1"}, registered[0].Labels)
+	assert.Equal(t, float64(2), registered[0].Parameters["pin"])
+
+	// Tokens are single use
+	assert.Equal(t, http.StatusConflict, claim(token.Token, "device-2", "").Code)
+	w = send("GET", "/claim-tokens/"+token.TokenID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, ClaimTokenClaimed, token.Status)
+	assert.Equal(t, "device-1", token.DeviceID)
+	assert.NotNil(t, token.ClaimedAt)
+
+	w = send("GET", "/claim-tokens?status=active", "")
+	var list struct {
+		Tokens []ClaimToken `json:"tokens"`
+		Total  int          `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Zero(t, list.Total)
+}
+
+func TestService_ClaimDevice_ExpiryAndRevocation(t *testing.T) {
+	service, _ := setupTestService()
+	service.claimTokens = NewMemoryClaimTokenStore()
+	router := gin.New()
+	RegisterRoutes(router, service)
+	ctx := context.Background()
+
+	claim := func(token string) int {
+		body, _ := json.Marshal(DeviceClaimRequest{Token: token, DeviceID: "device-1", FirmwareHash: "abc"})
+		req, _ := http.NewRequest("POST", "/api/v1/devices/claim", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	expiring, err := service.CreateClaimToken(ctx, &ClaimTokenRequest{BoardType: "esp32", TemplateID: "blink", TemplateVersion: "1.0.0"})
+	require.NoError(t, err)
+	_, err = service.claimTokens.ModifyClaimToken(ctx, expiring.TokenID, func(token *ClaimToken) error {
+		token.ExpiresAt = time.Now().Add(-time.Minute)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, claim(expiring.Token))
+	expired, err := service.ListClaimTokens(ctx, ClaimTokenExpired)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, expiring.TokenID, expired[0].TokenID)
+
+	revoked, err := service.CreateClaimToken(ctx, &ClaimTokenRequest{BoardType: "esp32", TemplateID: "blink", TemplateVersion: "1.0.0"})
+	require.NoError(t, err)
+	require.NoError(t, service.RevokeClaimToken(ctx, revoked.TokenID))
+	assert.Equal(t, http.StatusUnauthorized, claim(revoked.Token))
+	_, err = service.GetClaimToken(ctx, revoked.TokenID)
+	assert.ErrorIs(t, err, ErrClaimTokenNotFound)
+	assert.ErrorIs(t, service.RevokeClaimToken(ctx, revoked.TokenID), ErrClaimTokenNotFound)
+}
+
+func TestClaimTokenEntity_RoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	token := &ClaimToken{
+		TokenID:         "token-1",
+		Description:     "lab batch",
+		BoardType:       "esp32",
+		TemplateID:      "blink",
+		TemplateVersion: "1.0.0",
+		Parameters:      map[string]interface{}{"pin": float64(2)},
+		Labels:          map[string]string{"site": "lab1"},
+		Fingerprint:     fingerprint([]byte("secret")),
+		Status:          ClaimTokenClaimed,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(defaultClaimTokenTTL),
+		ClaimedAt:       &now,
+		DeviceID:        "device-1",
+	}
+	entity, err := token.ToEntity()
+	require.NoError(t, err)
+
+	restored, err := entity.FromEntity("token-1")
+	require.NoError(t, err)
+	assert.Equal(t, token, restored)
+
+	active, err := (&ClaimTokenEntity{Status: ClaimTokenActive}).FromEntity("token-2")
+	require.NoError(t, err)
+	assert.Nil(t, active.ClaimedAt)
+	assert.Nil(t, active.Labels)
+}
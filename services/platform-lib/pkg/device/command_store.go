@@ -0,0 +1,295 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// MemoryCommandStore is an in-memory CommandStore
+type MemoryCommandStore struct {
+	mu       sync.Mutex
+	commands map[string]*Command
+}
+
+// NewMemoryCommandStore creates an empty store
+func NewMemoryCommandStore() *MemoryCommandStore {
+	return &MemoryCommandStore{commands: make(map[string]*Command)}
+}
+
+func (s *MemoryCommandStore) CreateCommand(ctx context.Context, command *Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.commands[command.CommandID]; exists {
+		return fmt.Errorf("command %s already exists", command.CommandID)
+	}
+	stored := *command
+	s.commands[command.CommandID] = &stored
+	return nil
+}
+
+func (s *MemoryCommandStore) GetCommand(ctx context.Context, deviceID, commandID string) (*Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(deviceID, commandID)
+}
+
+func (s *MemoryCommandStore) ListCommands(ctx context.Context, deviceID string) ([]*Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var commands []*Command
+	for _, command := range s.commands {
+		if command.DeviceID == deviceID {
+			listed := *command
+			commands = append(commands, &listed)
+		}
+	}
+	sortCommands(commands)
+	return commands, nil
+}
+
+func (s *MemoryCommandStore) ModifyCommand(ctx context.Context, deviceID, commandID string, modify func(*Command) error) (*Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	command, err := s.load(deviceID, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(command); err != nil {
+		return nil, err
+	}
+	saved := *command
+	s.commands[commandID] = &saved
+	return command, nil
+}
+
+func (s *MemoryCommandStore) DeleteCommands(ctx context.Context, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, command := range s.commands {
+		if command.DeviceID == deviceID {
+			delete(s.commands, id)
+		}
+	}
+	return nil
+}
+
+// load returns a copy of a stored command of a device. Results and
+// payloads are replaced rather than changed, so the copy can be modified
+// freely.
+func (s *MemoryCommandStore) load(deviceID, commandID string) (*Command, error) {
+	command, ok := s.commands[commandID]
+	if !ok || command.DeviceID != deviceID {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, commandID)
+	}
+	loaded := *command
+	return &loaded, nil
+}
+
+// sortCommands orders commands newest first
+func sortCommands(commands []*Command) {
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].CreatedAt.After(commands[j].CreatedAt)
+	})
+}
+
+// CommandEntity represents a device command in Datastore. Unset delivery
+// and completion times are stored as zero times.
+type CommandEntity struct {
+	DeviceID    string    `datastore:"device_id"`
+	Type        string    `datastore:"type"`
+	PayloadJSON string    `datastore:"payload_json,noindex"`
+	Status      string    `datastore:"status"`
+	ResultJSON  string    `datastore:"result_json,noindex"`
+	Error       string    `datastore:"error,noindex"`
+	CreatedAt   time.Time `datastore:"created_at"`
+	ExpiresAt   time.Time `datastore:"expires_at"`
+	DeliveredAt time.Time `datastore:"delivered_at,noindex"`
+	CompletedAt time.Time `datastore:"completed_at,noindex"`
+}
+
+// ToEntity converts a Command to a CommandEntity
+func (c *Command) ToEntity() (*CommandEntity, error) {
+	entity := &CommandEntity{
+		DeviceID:  c.DeviceID,
+		Type:      c.Type,
+		Status:    c.Status,
+		Error:     c.Error,
+		CreatedAt: c.CreatedAt,
+		ExpiresAt: c.ExpiresAt,
+	}
+	if len(c.Payload) > 0 {
+		payloadJSON, err := json.Marshal(c.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal command payload: %w", err)
+		}
+		entity.PayloadJSON = string(payloadJSON)
+	}
+	if len(c.Result) > 0 {
+		resultJSON, err := json.Marshal(c.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal command result: %w", err)
+		}
+		entity.ResultJSON = string(resultJSON)
+	}
+	if c.DeliveredAt != nil {
+		entity.DeliveredAt = *c.DeliveredAt
+	}
+	if c.CompletedAt != nil {
+		entity.CompletedAt = *c.CompletedAt
+	}
+	return entity, nil
+}
+
+// FromEntity converts a CommandEntity to the Command stored under
+// commandID
+func (ce *CommandEntity) FromEntity(commandID string) (*Command, error) {
+	command := &Command{
+		CommandID: commandID,
+		DeviceID:  ce.DeviceID,
+		Type:      ce.Type,
+		Status:    ce.Status,
+		Error:     ce.Error,
+		CreatedAt: ce.CreatedAt,
+		ExpiresAt: ce.ExpiresAt,
+	}
+	if ce.PayloadJSON != "" {
+		if err := json.Unmarshal([]byte(ce.PayloadJSON), &command.Payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal command payload: %w", err)
+		}
+	}
+	if ce.ResultJSON != "" {
+		if err := json.Unmarshal([]byte(ce.ResultJSON), &command.Result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal command result: %w", err)
+		}
+	}
+	if !ce.DeliveredAt.IsZero() {
+		deliveredAt := ce.DeliveredAt
+		command.DeliveredAt = &deliveredAt
+	}
+	if !ce.CompletedAt.IsZero() {
+		completedAt := ce.CompletedAt
+		command.CompletedAt = &completedAt
+	}
+	return command, nil
+}
+
+// DatastoreCommandStore keeps device commands in Datastore, keyed by
+// command ID
+type DatastoreCommandStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreCommandStore creates a command store on a Datastore client
+func NewDatastoreCommandStore(client *datastore.Client) *DatastoreCommandStore {
+	return &DatastoreCommandStore{client: client}
+}
+
+func (s *DatastoreCommandStore) CreateCommand(ctx context.Context, command *Command) error {
+	entity, err := command.ToEntity()
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Put(ctx, datastore.NameKey("DeviceCommand", command.CommandID, nil), entity); err != nil {
+		return fmt.Errorf("failed to store command in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreCommandStore) GetCommand(ctx context.Context, deviceID, commandID string) (*Command, error) {
+	var entity CommandEntity
+	if err := s.client.Get(ctx, datastore.NameKey("DeviceCommand", commandID, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, commandID)
+		}
+		return nil, fmt.Errorf("failed to retrieve command from Datastore: %w", err)
+	}
+	if entity.DeviceID != deviceID {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, commandID)
+	}
+	return entity.FromEntity(commandID)
+}
+
+// ListCommands queries the commands of a device and sorts them in memory,
+// so no composite index is needed
+func (s *DatastoreCommandStore) ListCommands(ctx context.Context, deviceID string) ([]*Command, error) {
+	var entities []CommandEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("DeviceCommand").Filter("device_id =", deviceID), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commands from Datastore: %w", err)
+	}
+
+	commands := make([]*Command, 0, len(entities))
+	for i := range entities {
+		command, err := entities[i].FromEntity(keys[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	sortCommands(commands)
+	return commands, nil
+}
+
+// ModifyCommand applies modify inside a transaction, so an acknowledgement
+// and a delivery racing for the same command cannot overwrite each other
+func (s *DatastoreCommandStore) ModifyCommand(ctx context.Context, deviceID, commandID string, modify func(*Command) error) (*Command, error) {
+	key := datastore.NameKey("DeviceCommand", commandID, nil)
+
+	var command *Command
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity CommandEntity
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			return fmt.Errorf("%w: %s", ErrCommandNotFound, commandID)
+		default:
+			return fmt.Errorf("failed to retrieve command from Datastore: %w", err)
+		}
+		if entity.DeviceID != deviceID {
+			return fmt.Errorf("%w: %s", ErrCommandNotFound, commandID)
+		}
+
+		var err error
+		if command, err = entity.FromEntity(commandID); err != nil {
+			return err
+		}
+		if err := modify(command); err != nil {
+			return err
+		}
+
+		updated, err := command.ToEntity()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update command in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+func (s *DatastoreCommandStore) DeleteCommands(ctx context.Context, deviceID string) error {
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("DeviceCommand").Filter("device_id =", deviceID).KeysOnly(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to list commands from Datastore: %w", err)
+	}
+	if err := s.client.DeleteMulti(ctx, keys); err != nil {
+		return fmt.Errorf("failed to delete commands from Datastore: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,450 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/topics"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCommandNotFound is returned for commands a device was never sent
+	ErrCommandNotFound = errors.New("command not found")
+
+	// ErrInvalidCommand is returned for commands and acknowledgements that
+	// cannot be accepted
+	ErrInvalidCommand = errors.New("invalid command")
+
+	// ErrCommandCompleted is returned when acknowledging a command that
+	// already succeeded, failed or expired
+	ErrCommandCompleted = errors.New("command already completed")
+)
+
+// Command types. Reboot and ping take no payload, set_config carries the
+// configuration values to apply and custom an application-defined payload.
+const (
+	CommandReboot    = "reboot"
+	CommandPing      = "ping"
+	CommandSetConfig = "set_config"
+	CommandCustom    = "custom"
+)
+
+// Command statuses. Commands are pending until published to or polled by
+// their device, delivered until acknowledged, and expire when their TTL
+// runs out first.
+const (
+	CommandPending   = "pending"
+	CommandDelivered = "delivered"
+	CommandSucceeded = "succeeded"
+	CommandFailed    = "failed"
+	CommandExpired   = "expired"
+)
+
+const (
+	// defaultCommandTTL is how long a command waits for its device when
+	// the request sets no TTL
+	defaultCommandTTL = 10 * time.Minute
+
+	// maxCommandTTL bounds how long a command may wait for its device
+	maxCommandTTL = 7 * 24 * time.Hour
+
+	// defaultCommandHistory is how many commands are listed by default
+	defaultCommandHistory = 50
+)
+
+// Command is an instruction sent to a device, with its delivery and
+// completion
+type Command struct {
+	CommandID   string                 `json:"command_id"`
+	DeviceID    string                 `json:"device_id"`
+	Type        string                 `json:"type"`
+	Payload     map[string]interface{} `json:"payload,omitempty"`
+	Status      string                 `json:"status"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	ExpiresAt   time.Time              `json:"expires_at"`
+	DeliveredAt *time.Time             `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// CommandRequest sends a command to a device. TTLSeconds is how long the
+// command waits to be delivered and acknowledged, ten minutes if unset.
+type CommandRequest struct {
+	Type       string                 `json:"type" binding:"required"`
+	Payload    map[string]interface{} `json:"payload,omitempty"`
+	TTLSeconds int                    `json:"ttl_seconds,omitempty"`
+}
+
+// CommandAck is a device's report that it ran a command. Status is
+// succeeded or failed; failures carry an Error.
+type CommandAck struct {
+	Status string                 `json:"status" binding:"required"`
+	Result map[string]interface{} `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// Done reports whether the command succeeded, failed or expired
+func (c *Command) Done() bool {
+	switch c.Status {
+	case CommandSucceeded, CommandFailed, CommandExpired:
+		return true
+	}
+	return false
+}
+
+// expire marks a command its device did not acknowledge in time as
+// expired. Stored commands are expired when next read or written.
+func (c *Command) expire(now time.Time) {
+	if !c.Done() && now.After(c.ExpiresAt) {
+		expiresAt := c.ExpiresAt
+		c.Status = CommandExpired
+		c.CompletedAt = &expiresAt
+	}
+}
+
+// validate checks the command type, payload and TTL of a request
+func (r *CommandRequest) validate() error {
+	switch r.Type {
+	case CommandReboot, CommandPing:
+	case CommandSetConfig, CommandCustom:
+		if len(r.Payload) == 0 {
+			return fmt.Errorf("%w: %s commands need a payload", ErrInvalidCommand, r.Type)
+		}
+	default:
+		return fmt.Errorf("%w: unknown command type %q", ErrInvalidCommand, r.Type)
+	}
+	if r.TTLSeconds < 0 || time.Duration(r.TTLSeconds)*time.Second > maxCommandTTL {
+		return fmt.Errorf("%w: ttl_seconds must be between 0 and %d", ErrInvalidCommand, int(maxCommandTTL.Seconds()))
+	}
+	return nil
+}
+
+// CommandStore persists the commands sent to devices
+type CommandStore interface {
+	// CreateCommand stores a new command
+	CreateCommand(ctx context.Context, command *Command) error
+	// GetCommand returns a command of a device, or ErrCommandNotFound
+	GetCommand(ctx context.Context, deviceID, commandID string) (*Command, error)
+	// ListCommands returns the commands of a device, newest first
+	ListCommands(ctx context.Context, deviceID string) ([]*Command, error)
+	// ModifyCommand applies modify to a stored command so that concurrent
+	// writers cannot overwrite each other. An error from modify aborts the
+	// write and is returned unchanged.
+	ModifyCommand(ctx context.Context, deviceID, commandID string, modify func(*Command) error) (*Command, error)
+	// DeleteCommands removes the command history of a device
+	DeleteCommands(ctx context.Context, deviceID string) error
+}
+
+// CommandDispatcher pushes commands to devices as they are sent. Devices
+// that miss a push poll for their pending commands instead.
+type CommandDispatcher interface {
+	DispatchCommand(ctx context.Context, command *Command) error
+}
+
+// MessagePublisher publishes MQTT messages. telemetry.MQTTClient satisfies
+// it.
+type MessagePublisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) error
+}
+
+// MQTTCommandDispatcher publishes commands on the command topic of their
+// device, which the device subscribes to
+type MQTTCommandDispatcher struct {
+	publisher MessagePublisher
+	topics    *topics.Namespace
+}
+
+// NewMQTTCommandDispatcher creates a dispatcher publishing through
+// publisher under the topics of namespace
+func NewMQTTCommandDispatcher(publisher MessagePublisher, namespace *topics.Namespace) *MQTTCommandDispatcher {
+	return &MQTTCommandDispatcher{publisher: publisher, topics: namespace}
+}
+
+// DispatchCommand publishes a command with QoS 1
+func (d *MQTTCommandDispatcher) DispatchCommand(ctx context.Context, command *Command) error {
+	topic, err := d.topics.Topic(command.DeviceID, topics.KindCommand)
+	if err != nil {
+		return err
+	}
+	return d.publisher.Publish(topic, 1, false, command)
+}
+
+// SetCommandStore sets where device commands are persisted
+func (s *Service) SetCommandStore(store CommandStore) {
+	s.commands = store
+}
+
+// SetCommandDispatcher pushes commands to devices as they are sent.
+// Without one, devices poll for their commands.
+func (s *Service) SetCommandDispatcher(dispatcher CommandDispatcher) {
+	s.commandDispatcher = dispatcher
+}
+
+// SendCommand queues a command for a device and pushes it to the device
+// when a dispatcher is set. A command that cannot be pushed stays pending
+// until the device polls for it.
+func (s *Service) SendCommand(ctx context.Context, deviceID string, req *CommandRequest) (*Command, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultCommandTTL
+	}
+	now := time.Now().UTC()
+	command := &Command{
+		CommandID: uuid.New().String(),
+		DeviceID:  deviceID,
+		Type:      req.Type,
+		Payload:   req.Payload,
+		Status:    CommandPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.commands.CreateCommand(ctx, command); err != nil {
+		return nil, fmt.Errorf("failed to store command: %w", err)
+	}
+	s.logger.Info("Queued device command", "device_id", deviceID, "command_id", command.CommandID, "type", command.Type)
+
+	if s.commandDispatcher == nil {
+		return command, nil
+	}
+	if err := s.commandDispatcher.DispatchCommand(ctx, command); err != nil {
+		s.logger.Warn("Failed to push device command, leaving it for the device to poll",
+			"device_id", deviceID, "command_id", command.CommandID, "error", err)
+		return command, nil
+	}
+	return s.markDelivered(ctx, command)
+}
+
+// markDelivered records that a command reached its device
+func (s *Service) markDelivered(ctx context.Context, command *Command) (*Command, error) {
+	now := time.Now().UTC()
+	return s.commands.ModifyCommand(ctx, command.DeviceID, command.CommandID, func(stored *Command) error {
+		stored.expire(now)
+		if stored.Status == CommandPending {
+			stored.Status = CommandDelivered
+			stored.DeliveredAt = &now
+		}
+		return nil
+	})
+}
+
+// GetCommand returns a command sent to a device
+func (s *Service) GetCommand(ctx context.Context, deviceID, commandID string) (*Command, error) {
+	command, err := s.commands.GetCommand(ctx, deviceID, commandID)
+	if err != nil {
+		return nil, err
+	}
+	command.expire(time.Now())
+	return command, nil
+}
+
+// ListCommands returns the command history of a device, newest first,
+// optionally only the commands in status
+func (s *Service) ListCommands(ctx context.Context, deviceID, status string) ([]*Command, error) {
+	if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	commands, err := s.commands.ListCommands(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commands: %w", err)
+	}
+
+	now := time.Now()
+	listed := make([]*Command, 0, len(commands))
+	for _, command := range commands {
+		command.expire(now)
+		if status == "" || command.Status == status {
+			listed = append(listed, command)
+		}
+	}
+	return listed, nil
+}
+
+// PollCommands returns the commands waiting for a device, oldest first, and
+// marks them delivered
+func (s *Service) PollCommands(ctx context.Context, deviceID string) ([]*Command, error) {
+	pending, err := s.ListCommands(ctx, deviceID, CommandPending)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(pending)
+
+	delivered := make([]*Command, 0, len(pending))
+	for _, command := range pending {
+		command, err := s.markDelivered(ctx, command)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mark command delivered: %w", err)
+		}
+		if command.Status == CommandDelivered {
+			delivered = append(delivered, command)
+		}
+	}
+	return delivered, nil
+}
+
+// AckCommand records that a device ran a command
+func (s *Service) AckCommand(ctx context.Context, deviceID, commandID string, ack *CommandAck) (*Command, error) {
+	if ack.Status != CommandSucceeded && ack.Status != CommandFailed {
+		return nil, fmt.Errorf("%w: acknowledgement status must be %s or %s", ErrInvalidCommand, CommandSucceeded, CommandFailed)
+	}
+	if ack.Status == CommandFailed && ack.Error == "" {
+		return nil, fmt.Errorf("%w: failed commands need an error", ErrInvalidCommand)
+	}
+
+	now := time.Now().UTC()
+	command, err := s.commands.ModifyCommand(ctx, deviceID, commandID, func(command *Command) error {
+		command.expire(now)
+		if command.Done() {
+			return fmt.Errorf("%w: command is %s", ErrCommandCompleted, command.Status)
+		}
+		if command.DeliveredAt == nil {
+			command.DeliveredAt = &now
+		}
+		command.Status = ack.Status
+		command.Result = ack.Result
+		command.Error = ack.Error
+		command.CompletedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Device acknowledged command", "device_id", deviceID, "command_id", commandID, "status", command.Status)
+	return command, nil
+}
+
+func (s *Service) sendCommand(c *gin.Context) {
+	var req CommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	deviceID := c.Param("id")
+	command, err := s.SendCommand(c.Request.Context(), deviceID, &req)
+	if err != nil {
+		s.respondCommandError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, command)
+}
+
+func (s *Service) listCommands(c *gin.Context) {
+	limit := defaultCommandHistory
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		limit = parsed
+	}
+
+	deviceID := c.Param("id")
+	commands, err := s.ListCommands(c.Request.Context(), deviceID, c.Query("status"))
+	if err != nil {
+		s.respondCommandError(c, deviceID, err)
+		return
+	}
+
+	total := len(commands)
+	if len(commands) > limit {
+		commands = commands[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"commands": commands,
+		"total":    total,
+	})
+}
+
+func (s *Service) pollCommands(c *gin.Context) {
+	deviceID := c.Param("id")
+	commands, err := s.PollCommands(c.Request.Context(), deviceID)
+	if err != nil {
+		s.respondCommandError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"commands": commands,
+		"total":    len(commands),
+	})
+}
+
+func (s *Service) getCommand(c *gin.Context) {
+	deviceID := c.Param("id")
+	command, err := s.GetCommand(c.Request.Context(), deviceID, c.Param("cmdId"))
+	if err != nil {
+		s.respondCommandError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, command)
+}
+
+func (s *Service) ackCommand(c *gin.Context) {
+	var ack CommandAck
+	if err := c.ShouldBindJSON(&ack); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	deviceID := c.Param("id")
+	command, err := s.AckCommand(c.Request.Context(), deviceID, c.Param("cmdId"), &ack)
+	if err != nil {
+		s.respondCommandError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, command)
+}
+
+// respondCommandError maps command errors to responses
+func (s *Service) respondCommandError(c *gin.Context, deviceID string, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidCommand):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid command",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrCommandCompleted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Command already completed",
+			"details": err.Error(),
+		})
+	case errors.Is(err, errDeviceLookup):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+	case errors.Is(err, ErrCommandNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Command not found",
+			"details": err.Error(),
+		})
+	default:
+		s.logger.Error("Failed to access device commands", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to access device commands",
+			"details": err.Error(),
+		})
+	}
+}
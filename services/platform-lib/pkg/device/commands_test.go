@@ -0,0 +1,183 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/topics"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher records published MQTT messages, failing with err
+type recordingPublisher struct {
+	topics   []string
+	payloads []interface{}
+	err      error
+}
+
+func (p *recordingPublisher) Publish(topic string, qos byte, retained bool, payload interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.topics = append(p.topics, topic)
+	p.payloads = append(p.payloads, payload)
+	return nil
+}
+
+func TestService_Commands(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.commands = NewMemoryCommandStore()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetDevice", mock.Anything, "device-001").Return(createTestDevice("device-001"), nil)
+	mockRepo.On("GetDevice", mock.Anything, "device-404").Return(nil, errors.New("device device-404 not found"))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/devices"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/device-001/commands", `{"type":"set_config","payload":{"interval":30},"ttl_seconds":60}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var command Command
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &command))
+	assert.Equal(t, CommandPending, command.Status)
+	assert.WithinDuration(t, command.CreatedAt.Add(time.Minute), command.ExpiresAt, time.Second)
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/device-001/commands", `{"type":"set_config"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/device-001/commands", `{"type":"self_destruct"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/device-001/commands", `{"type":"ping","ttl_seconds":-1}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/device-404/commands", `{"type":"ping"}`).Code)
+
+	// Polling delivers pending commands once
+	w = send("GET", "/device-001/commands/pending", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var polled struct {
+		Commands []Command `json:"commands"`
+		Total    int       `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &polled))
+	require.Equal(t, 1, polled.Total)
+	assert.Equal(t, CommandDelivered, polled.Commands[0].Status)
+	assert.NotNil(t, polled.Commands[0].DeliveredAt)
+	require.NoError(t, json.Unmarshal(send("GET", "/device-001/commands/pending", "").Body.Bytes(), &polled))
+	assert.Zero(t, polled.Total)
+
+	path := "/device-001/commands/" + command.CommandID
+	assert.Equal(t, http.StatusBadRequest, send("POST", path+"/ack", `{"status":"failed"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", path+"/ack", `{"status":"delivered"}`).Code)
+	w = send("POST", path+"/ack", `{"status":"succeeded","result":{"interval":30}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &command))
+	assert.Equal(t, CommandSucceeded, command.Status)
+	assert.NotNil(t, command.CompletedAt)
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/ack", `{"status":"succeeded"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/device-001/commands/missing/ack", `{"status":"succeeded"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/device-404/commands/"+command.CommandID, "").Code)
+
+	w = send("GET", path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &command))
+	assert.Equal(t, map[string]interface{}{"interval": float64(30)}, command.Result)
+}
+
+func TestService_Commands_Expiry(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.commands = NewMemoryCommandStore()
+	mockRepo.On("GetDevice", mock.Anything, "device-001").Return(createTestDevice("device-001"), nil)
+	ctx := context.Background()
+
+	created := time.Now().Add(-time.Hour)
+	require.NoError(t, service.commands.CreateCommand(ctx, &Command{
+		CommandID: "cmd-old",
+		DeviceID:  "device-001",
+		Type:      CommandReboot,
+		Status:    CommandPending,
+		CreatedAt: created,
+		ExpiresAt: created.Add(defaultCommandTTL),
+	}))
+	_, err := service.SendCommand(ctx, "device-001", &CommandRequest{Type: CommandPing})
+	require.NoError(t, err)
+
+	expired, err := service.ListCommands(ctx, "device-001", CommandExpired)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "cmd-old", expired[0].CommandID)
+
+	// Expired commands are neither delivered nor acknowledged
+	polled, err := service.PollCommands(ctx, "device-001")
+	require.NoError(t, err)
+	require.Len(t, polled, 1)
+	assert.Equal(t, CommandPing, polled[0].Type)
+	_, err = service.AckCommand(ctx, "device-001", "cmd-old", &CommandAck{Status: CommandSucceeded})
+	assert.ErrorIs(t, err, ErrCommandCompleted)
+
+	history, err := service.ListCommands(ctx, "device-001", "")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, CommandPing, history[0].Type)
+}
+
+func TestService_SendCommand_Dispatch(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.commands = NewMemoryCommandStore()
+	mockRepo.On("GetDevice", mock.Anything, "device-001").Return(createTestDevice("device-001"), nil)
+	ctx := context.Background()
+
+	namespace, err := topics.New("", "")
+	require.NoError(t, err)
+	publisher := &recordingPublisher{}
+	service.SetCommandDispatcher(NewMQTTCommandDispatcher(publisher, namespace))
+
+	command, err := service.SendCommand(ctx, "device-001", &CommandRequest{Type: CommandReboot})
+	require.NoError(t, err)
+	assert.Equal(t, CommandDelivered, command.Status)
+	assert.Equal(t, []string{"telemetry/device-001/commands"}, publisher.topics)
+
+	// Commands that cannot be pushed wait for the device to poll
+	publisher.err = errors.New("broker unavailable")
+	command, err = service.SendCommand(ctx, "device-001", &CommandRequest{Type: CommandPing})
+	require.NoError(t, err)
+	assert.Equal(t, CommandPending, command.Status)
+}
+
+func TestCommandEntity_RoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	command := &Command{
+		CommandID:   "cmd-1",
+		DeviceID:    "device-001",
+		Type:        CommandCustom,
+		Payload:     map[string]interface{}{"action": "blink"},
+		Status:      CommandFailed,
+		Error:       "led missing",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(defaultCommandTTL),
+		DeliveredAt: &now,
+		CompletedAt: &now,
+	}
+	entity, err := command.ToEntity()
+	require.NoError(t, err)
+
+	restored, err := entity.FromEntity("cmd-1")
+	require.NoError(t, err)
+	assert.Equal(t, command, restored)
+
+	pending, err := (&CommandEntity{DeviceID: "device-001", Status: CommandPending}).FromEntity("cmd-2")
+	require.NoError(t, err)
+	assert.Nil(t, pending.DeliveredAt)
+	assert.Nil(t, pending.Payload)
+}
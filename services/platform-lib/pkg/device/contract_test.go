@@ -0,0 +1,60 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/contract"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// contractStates put the mock repository in the state a consumer's
+// interaction requires
+var contractStates = map[string]func(repo *MockRepository){
+	"device dev-1 exists": func(repo *MockRepository) {
+		device := &Device{
+			DeviceID:        "dev-1",
+			BoardType:       "esp32:esp32:esp32",
+			Status:          DeviceStatusOnline,
+			TemplateID:      "temp-sensor",
+			TemplateVersion: "1.0.0",
+			OTAChannel:      "stable",
+			LastSeen:        time.Now(),
+			Labels:          map[string]string{"site": "lab"},
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		repo.On("GetDevice", mock.Anything, "dev-1").Return(device, nil).Maybe()
+		repo.On("ListDevices", mock.Anything, mock.Anything).Return([]*Device{device}, nil).Maybe()
+		repo.On("GetDeviceCount", mock.Anything, mock.Anything).Return(int64(1), nil).Maybe()
+	},
+	"device dev-2 does not exist": func(repo *MockRepository) {
+		repo.On("RegisterDevice", mock.Anything, mock.MatchedBy(func(d *Device) bool { return d.DeviceID == "dev-2" })).Return(nil)
+	},
+}
+
+func TestProviderContracts(t *testing.T) {
+	contracts, err := contract.LoadDir("../../contracts", "device-service")
+	require.NoError(t, err)
+	require.NotEmpty(t, contracts)
+
+	for _, c := range contracts {
+		for _, interaction := range c.Interactions {
+			t.Run(c.Consumer+"/"+interaction.Description, func(t *testing.T) {
+				setState, ok := contractStates[interaction.State]
+				require.True(t, ok, "unknown provider state %q", interaction.State)
+
+				service, repo := setupTestService()
+				setState(repo)
+				router := gin.New()
+				RegisterRoutes(router, service)
+
+				assert.NoError(t, interaction.Verify(router))
+				repo.AssertExpectations(t)
+			})
+		}
+	}
+}
@@ -0,0 +1,700 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+)
+
+// CredentialType identifies what a device credential authenticates
+type CredentialType string
+
+const (
+	// CredentialTypeToken is the token a device uses as its MQTT password
+	CredentialTypeToken CredentialType = "token"
+	// CredentialTypeCertificate is an X.509 client certificate
+	CredentialTypeCertificate CredentialType = "certificate"
+	// CredentialTypeWiFiEnterprise is a WPA2-Enterprise identity
+	CredentialTypeWiFiEnterprise CredentialType = "wifi_enterprise"
+)
+
+var (
+	// ErrInvalidCredential is returned for credential records and
+	// rotations that cannot be applied
+	ErrInvalidCredential = errors.New("invalid credential")
+	// ErrCredentialNotFound is returned for credentials a device does not
+	// have
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// errDeviceLookup wraps failures to load the device a credential
+	// belongs to
+	errDeviceLookup = errors.New("failed to get device")
+)
+
+// Credential tracks the lifetime of a credential a device holds. Only
+// metadata is kept; the secret itself stays on the device, apart from a
+// rotated one waiting to be delivered.
+type Credential struct {
+	Type        CredentialType `json:"type"`
+	Version     int            `json:"version"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	IssuedAt    time.Time      `json:"issued_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	// NotifiedAt is when the last expiry notification was sent
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+	// Pending is a rotated credential the device has not applied yet
+	Pending *PendingCredential `json:"pending,omitempty"`
+	// RevokedAt is when the credential was revoked. Revoked credentials
+	// are kept so the device cannot authenticate with them again.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// PendingCredential is a rotated credential staged for delivery. Its
+// material is handed to the device in heartbeat responses and is never
+// returned by the API.
+type PendingCredential struct {
+	Version     int       `json:"version"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	StagedAt    time.Time `json:"staged_at"`
+	Material    string    `json:"-"`
+}
+
+// CredentialRecord records a credential issued outside the platform. When
+// Certificate holds a PEM certificate, its validity and fingerprint are
+// used.
+type CredentialRecord struct {
+	Type        CredentialType `json:"type" binding:"required,oneof=token certificate wifi_enterprise"`
+	Certificate string         `json:"certificate,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	IssuedAt    *time.Time     `json:"issued_at,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+}
+
+// CredentialRotationRequest asks for a credential to be replaced. Material
+// is the new token, PEM certificate and key, or WiFi enterprise password;
+// tokens are generated when it is empty.
+type CredentialRotationRequest struct {
+	Material  string     `json:"material,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CredentialRotation reports a staged rotation. Token is set only for
+// generated tokens and is not shown again.
+type CredentialRotation struct {
+	DeviceID    string    `json:"device_id"`
+	Name        string    `json:"name"`
+	Version     int       `json:"version"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Token       string    `json:"token,omitempty"`
+}
+
+// CredentialDelivery hands a rotated credential to its device
+type CredentialDelivery struct {
+	Name      string         `json:"name"`
+	Type      CredentialType `json:"type"`
+	Version   int            `json:"version"`
+	Material  string         `json:"material"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// ExpiringCredential is an entry of the expiring-soon report
+type ExpiringCredential struct {
+	DeviceID    string         `json:"device_id"`
+	Name        string         `json:"name"`
+	Type        CredentialType `json:"type"`
+	Version     int            `json:"version"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	Expired     bool           `json:"expired"`
+	// RotationPending is set when a replacement awaits delivery
+	RotationPending bool `json:"rotation_pending"`
+}
+
+// storedCredential is the Datastore form of a credential, which keeps the
+// pending material the JSON API omits
+type storedCredential struct {
+	Credential
+	PendingMaterial string `json:"pending_material,omitempty"`
+}
+
+func marshalCredentials(credentials map[string]*Credential) (string, error) {
+	if len(credentials) == 0 {
+		return "", nil
+	}
+	stored := make(map[string]storedCredential, len(credentials))
+	for name, credential := range credentials {
+		entry := storedCredential{Credential: *credential}
+		if credential.Pending != nil {
+			entry.PendingMaterial = credential.Pending.Material
+		}
+		stored[name] = entry
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalCredentials(data string) (map[string]*Credential, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var stored map[string]storedCredential
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+	credentials := make(map[string]*Credential, len(stored))
+	for name, entry := range stored {
+		credential := entry.Credential
+		if credential.Pending != nil {
+			credential.Pending.Material = entry.PendingMaterial
+		}
+		credentials[name] = &credential
+	}
+	return credentials, nil
+}
+
+// noticeDue reports whether an expiry notification is due at now, and
+// whether the credential has expired. A notice is due once per warning
+// window the credential enters, and once more when it expires.
+func (c *Credential) noticeDue(now time.Time, warnings []time.Duration) (due, expired bool) {
+	if c.ExpiresAt.IsZero() || c.RevokedAt != nil {
+		return false, false
+	}
+	threshold := c.ExpiresAt
+	if now.Before(c.ExpiresAt) {
+		remaining := c.ExpiresAt.Sub(now)
+		window := time.Duration(0)
+		for _, warning := range warnings {
+			if remaining <= warning && (window == 0 || warning < window) {
+				window = warning
+			}
+		}
+		if window == 0 {
+			return false, false
+		}
+		threshold = c.ExpiresAt.Add(-window)
+	} else {
+		expired = true
+	}
+	return c.NotifiedAt == nil || c.NotifiedAt.Before(threshold), expired
+}
+
+// credentialSettings fills unset credential options with the defaults
+func credentialSettings(cfg *config.Config) config.CredentialsConfig {
+	var settings config.CredentialsConfig
+	if cfg != nil {
+		settings = cfg.Credentials
+	}
+	if settings.CheckInterval <= 0 {
+		settings.CheckInterval = time.Hour
+	}
+	if len(settings.ExpiryWarnings) == 0 {
+		settings.ExpiryWarnings = []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}
+	}
+	if settings.TokenLifetime <= 0 {
+		settings.TokenLifetime = 365 * 24 * time.Hour
+	}
+	if settings.Provisioning == "" {
+		settings.Provisioning = "token"
+	}
+	if settings.CertificateLifetime <= 0 {
+		settings.CertificateLifetime = 365 * 24 * time.Hour
+	}
+	return settings
+}
+
+// parseCertificate reads the first certificate in PEM data
+func parseCertificate(data string) (*x509.Certificate, error) {
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("%w: no PEM certificate found", ErrInvalidCredential)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+		}
+		return certificate, nil
+	}
+}
+
+// fingerprint identifies secret material without revealing it
+func fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyToken reports whether token matches one of the device's unexpired
+// token credentials. A staged rotation is accepted too, since the device
+// switches to the new token before its next heartbeat confirms the change.
+func (d *Device) VerifyToken(token string, now time.Time) bool {
+	if token == "" {
+		return false
+	}
+	presented := []byte(fingerprint([]byte(token)))
+	matches := func(stored string, expiresAt time.Time) bool {
+		return stored != "" && now.Before(expiresAt) && subtle.ConstantTimeCompare([]byte(stored), presented) == 1
+	}
+
+	for _, credential := range d.Credentials {
+		if credential.Type != CredentialTypeToken || credential.RevokedAt != nil {
+			continue
+		}
+		if matches(credential.Fingerprint, credential.ExpiresAt) {
+			return true
+		}
+		if pending := credential.Pending; pending != nil && matches(pending.Fingerprint, pending.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyCertificate reports whether certificate is one of the device's
+// unexpired, unrevoked certificate credentials, or a staged replacement
+func (d *Device) VerifyCertificate(certificate *x509.Certificate, now time.Time) bool {
+	if certificate == nil || now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+		return false
+	}
+	presented := []byte(fingerprint(certificate.Raw))
+	matches := func(stored string, expiresAt time.Time) bool {
+		return stored != "" && now.Before(expiresAt) && subtle.ConstantTimeCompare([]byte(stored), presented) == 1
+	}
+
+	for _, credential := range d.Credentials {
+		if credential.Type != CredentialTypeCertificate || credential.RevokedAt != nil {
+			continue
+		}
+		if matches(credential.Fingerprint, credential.ExpiresAt) {
+			return true
+		}
+		if pending := credential.Pending; pending != nil && matches(pending.Fingerprint, pending.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyRequest reports whether r carries one of the device's credentials:
+// a token as a bearer token, or a certificate as its TLS client
+// certificate
+func (d *Device) VerifyRequest(r *http.Request, now time.Time) bool {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && d.VerifyCertificate(r.TLS.PeerCertificates[0], now) {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && d.VerifyToken(token, now)
+}
+
+// AuthenticateRequest reports whether r carries a credential of deviceID,
+// or of a gateway deviceID sits behind, so gateways can relay requests of
+// their children. The error reports a failed device lookup, which callers
+// treat as a failed authentication.
+func AuthenticateRequest(ctx context.Context, repository Repository, deviceID string, r *http.Request) (bool, error) {
+	dev, err := repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	for depth := 0; !dev.VerifyRequest(r, now); depth++ {
+		if dev.ParentID == "" || depth >= MaxHierarchyDepth {
+			return false, nil
+		}
+		if dev, err = repository.GetDevice(ctx, dev.ParentID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// generateToken returns a random device token
+func generateToken() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// TrackCredential records or replaces the metadata of a device credential
+func (s *Service) TrackCredential(ctx context.Context, deviceID, name string, record *CredentialRecord) (*Credential, error) {
+	credential := &Credential{
+		Type:        record.Type,
+		Fingerprint: record.Fingerprint,
+		IssuedAt:    time.Now(),
+	}
+	if record.Certificate != "" {
+		certificate, err := parseCertificate(record.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		credential.Fingerprint = fingerprint(certificate.Raw)
+		credential.IssuedAt = certificate.NotBefore
+		credential.ExpiresAt = certificate.NotAfter
+	}
+	if record.IssuedAt != nil {
+		credential.IssuedAt = *record.IssuedAt
+	}
+	if record.ExpiresAt != nil {
+		credential.ExpiresAt = *record.ExpiresAt
+	}
+	if credential.ExpiresAt.IsZero() {
+		return nil, fmt.Errorf("%w: expires_at or a certificate is required", ErrInvalidCredential)
+	}
+
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	credential.Version = 1
+	if current, ok := device.Credentials[name]; ok {
+		credential.Version = current.Version
+		credential.Pending = current.Pending
+	}
+	if device.Credentials == nil {
+		device.Credentials = make(map[string]*Credential)
+	}
+	device.Credentials[name] = credential
+
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+	return credential, nil
+}
+
+// RemoveCredential stops tracking a device credential
+func (s *Service) RemoveCredential(ctx context.Context, deviceID, name string) error {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	if _, ok := device.Credentials[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	delete(device.Credentials, name)
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+	return nil
+}
+
+// RevokeCredential revokes a device credential, and any rotation staged
+// for it. The record is kept so the device cannot authenticate with the
+// credential again; revoking it twice changes nothing.
+func (s *Service) RevokeCredential(ctx context.Context, deviceID, name string) (*Credential, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	credential, ok := device.Credentials[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	if credential.RevokedAt != nil {
+		return credential, nil
+	}
+
+	now := time.Now()
+	credential.RevokedAt = &now
+	credential.Pending = nil
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+	s.logger.Info("Revoked credential", "credential", name, "device_id", deviceID)
+	return credential, nil
+}
+
+// RotateCredential stages a replacement for a device credential. The device
+// receives it with its next heartbeat and it takes effect once the device
+// reports the new version.
+func (s *Service) RotateCredential(ctx context.Context, deviceID, name string, req *CredentialRotationRequest) (*CredentialRotation, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	credential, ok := device.Credentials[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	if credential.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: %s has been revoked", ErrInvalidCredential, name)
+	}
+
+	now := time.Now()
+	pending := &PendingCredential{
+		Version:  credential.Version + 1,
+		Material: req.Material,
+		StagedAt: now,
+	}
+	if req.ExpiresAt != nil {
+		pending.ExpiresAt = *req.ExpiresAt
+	}
+	rotation := &CredentialRotation{DeviceID: deviceID, Name: name}
+
+	switch credential.Type {
+	case CredentialTypeToken:
+		if pending.Material == "" {
+			if pending.Material, err = generateToken(); err != nil {
+				return nil, fmt.Errorf("failed to generate token: %w", err)
+			}
+			rotation.Token = pending.Material
+		}
+		if pending.ExpiresAt.IsZero() {
+			pending.ExpiresAt = now.Add(credentialSettings(s.config).TokenLifetime)
+		}
+		pending.Fingerprint = fingerprint([]byte(pending.Material))
+	case CredentialTypeCertificate:
+		certificate, err := parseCertificate(pending.Material)
+		if err != nil {
+			return nil, err
+		}
+		pending.ExpiresAt = certificate.NotAfter
+		pending.Fingerprint = fingerprint(certificate.Raw)
+	default:
+		if pending.Material == "" || pending.ExpiresAt.IsZero() {
+			return nil, fmt.Errorf("%w: material and expires_at are required for %s credentials", ErrInvalidCredential, credential.Type)
+		}
+		pending.Fingerprint = fingerprint([]byte(pending.Material))
+	}
+	if !pending.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: the new credential has already expired", ErrInvalidCredential)
+	}
+
+	credential.Pending = pending
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+	s.logger.Info("Staged credential version", "credential", name, "version", pending.Version, "device_id", deviceID)
+
+	rotation.Version = pending.Version
+	rotation.Fingerprint = pending.Fingerprint
+	rotation.ExpiresAt = pending.ExpiresAt
+	return rotation, nil
+}
+
+// SyncCredentials reconciles the credential versions a device reports in
+// its heartbeat: rotations it has applied take effect, and those it has
+// not are returned for delivery.
+func (s *Service) SyncCredentials(ctx context.Context, deviceID string, versions map[string]int) ([]CredentialDelivery, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+
+	deliveries := []CredentialDelivery{}
+	applied := false
+	for name, credential := range device.Credentials {
+		pending := credential.Pending
+		if pending == nil {
+			continue
+		}
+		if versions[name] >= pending.Version {
+			credential.Version = pending.Version
+			credential.Fingerprint = pending.Fingerprint
+			credential.IssuedAt = pending.StagedAt
+			credential.ExpiresAt = pending.ExpiresAt
+			credential.NotifiedAt = nil
+			credential.Pending = nil
+			applied = true
+			s.logger.Info("Device applied credential version", "device_id", deviceID, "credential", name, "version", pending.Version)
+			continue
+		}
+		deliveries = append(deliveries, CredentialDelivery{
+			Name:      name,
+			Type:      credential.Type,
+			Version:   pending.Version,
+			Material:  pending.Material,
+			ExpiresAt: pending.ExpiresAt,
+		})
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Name < deliveries[j].Name })
+
+	if applied {
+		if err := s.repository.UpdateDevice(ctx, device); err != nil {
+			return nil, fmt.Errorf("failed to update device: %w", err)
+		}
+	}
+	return deliveries, nil
+}
+
+// ExpiringCredentials reports credentials that expire within the given
+// duration, including those already expired, soonest first
+func (s *Service) ExpiringCredentials(ctx context.Context, within time.Duration) ([]ExpiringCredential, error) {
+	devices, err := s.repository.ListDevices(ctx, &DeviceFilters{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	now := time.Now()
+	cutoff := now.Add(within)
+	report := []ExpiringCredential{}
+	for _, device := range devices {
+		for name, credential := range device.Credentials {
+			if credential.ExpiresAt.IsZero() || credential.ExpiresAt.After(cutoff) || credential.RevokedAt != nil {
+				continue
+			}
+			report = append(report, ExpiringCredential{
+				DeviceID:        device.DeviceID,
+				Name:            name,
+				Type:            credential.Type,
+				Version:         credential.Version,
+				Fingerprint:     credential.Fingerprint,
+				ExpiresAt:       credential.ExpiresAt,
+				Expired:         !credential.ExpiresAt.After(now),
+				RotationPending: credential.Pending != nil,
+			})
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if !report[i].ExpiresAt.Equal(report[j].ExpiresAt) {
+			return report[i].ExpiresAt.Before(report[j].ExpiresAt)
+		}
+		if report[i].DeviceID != report[j].DeviceID {
+			return report[i].DeviceID < report[j].DeviceID
+		}
+		return report[i].Name < report[j].Name
+	})
+	return report, nil
+}
+
+// CredentialMonitor periodically checks device credentials and publishes
+// notifications ahead of and on their expiry
+type CredentialMonitor struct {
+	repository Repository
+	logger     *logger.Logger
+	settings   config.CredentialsConfig
+	publisher  notifications.Publisher
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewCredentialMonitor creates a credential monitor
+func NewCredentialMonitor(repository Repository, logger *logger.Logger, cfg *config.Config) *CredentialMonitor {
+	return &CredentialMonitor{
+		repository: repository,
+		logger:     logger,
+		settings:   credentialSettings(cfg),
+		stop:       make(chan struct{}),
+	}
+}
+
+// SetPublisher sets the publisher used for expiry notifications
+func (m *CredentialMonitor) SetPublisher(publisher notifications.Publisher) {
+	m.publisher = publisher
+}
+
+// Start runs checks in the background until Stop is called
+func (m *CredentialMonitor) Start(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.settings.CheckInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Check(ctx); err != nil {
+				m.logger.Error("Failed to check credential expiry", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops background checks
+func (m *CredentialMonitor) Stop() {
+	close(m.stop)
+	m.wg.Wait()
+}
+
+// Check publishes the expiry notifications that are due and returns how
+// many were sent
+func (m *CredentialMonitor) Check(ctx context.Context) (int, error) {
+	devices, err := m.repository.ListDevices(ctx, &DeviceFilters{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	now := time.Now()
+	sent := 0
+	for _, device := range devices {
+		type notice struct {
+			name    string
+			expired bool
+		}
+		var notices []notice
+		for name, credential := range device.Credentials {
+			if due, expired := credential.noticeDue(now, m.settings.ExpiryWarnings); due {
+				credential.NotifiedAt = &now
+				notices = append(notices, notice{name, expired})
+			}
+		}
+		if len(notices) == 0 {
+			continue
+		}
+
+		// Record the notices first so a failed write never repeats them
+		if err := m.repository.UpdateDevice(ctx, device); err != nil {
+			m.logger.Error("Failed to record expiry notices", "device_id", device.DeviceID, "error", err)
+			continue
+		}
+		for _, n := range notices {
+			m.notify(device, n.name, device.Credentials[n.name], n.expired)
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+func (m *CredentialMonitor) notify(device *Device, name string, credential *Credential, expired bool) {
+	event := &notifications.Event{
+		Type:         notifications.EventCredentialExpiring,
+		ResourceType: "device",
+		ResourceID:   device.DeviceID,
+		Message: fmt.Sprintf("Credential %s of device %s expires %s", name, device.DeviceID,
+			credential.ExpiresAt.Format(time.RFC3339)),
+		Data: map[string]interface{}{
+			"credential":       name,
+			"type":             credential.Type,
+			"version":          credential.Version,
+			"expires_at":       credential.ExpiresAt,
+			"rotation_pending": credential.Pending != nil,
+		},
+	}
+	if expired {
+		event.Type = notifications.EventCredentialExpired
+		event.Message = fmt.Sprintf("Credential %s of device %s expired %s", name, device.DeviceID,
+			credential.ExpiresAt.Format(time.RFC3339))
+	}
+	notifications.PublishAsync(m.publisher, m.logger, event)
+}
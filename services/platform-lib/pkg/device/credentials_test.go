@@ -0,0 +1,283 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// channelPublisher hands published events to a channel
+type channelPublisher chan *notifications.Event
+
+func (p channelPublisher) Publish(ctx context.Context, event *notifications.Event) error {
+	p <- event
+	return nil
+}
+
+func testCertificatePEM(t *testing.T, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device-1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCredential_NoticeDue(t *testing.T) {
+	warnings := []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}
+	now := time.Now()
+	credential := &Credential{ExpiresAt: now.Add(10 * 24 * time.Hour)}
+
+	due, expired := credential.noticeDue(now.Add(-30*24*time.Hour), warnings)
+	assert.False(t, due, "outside every warning window")
+
+	due, expired = credential.noticeDue(now, warnings)
+	assert.True(t, due)
+	assert.False(t, expired)
+	credential.NotifiedAt = &now
+
+	// Once per window: nothing more until the 7 day window opens
+	due, _ = credential.noticeDue(now.Add(time.Hour), warnings)
+	assert.False(t, due)
+	due, _ = credential.noticeDue(now.Add(4*24*time.Hour), warnings)
+	assert.True(t, due)
+
+	later := now.Add(4 * 24 * time.Hour)
+	credential.NotifiedAt = &later
+	due, expired = credential.noticeDue(now.Add(11*24*time.Hour), warnings)
+	assert.True(t, due)
+	assert.True(t, expired)
+}
+
+func TestDevice_VerifyToken(t *testing.T) {
+	now := time.Now()
+	device := &Device{
+		Credentials: map[string]*Credential{
+			"mqtt": {
+				Type:        CredentialTypeToken,
+				Fingerprint: fingerprint([]byte("old-token")),
+				ExpiresAt:   now.Add(time.Hour),
+				Pending:     &PendingCredential{Fingerprint: fingerprint([]byte("new-token")), ExpiresAt: now.Add(48 * time.Hour)},
+			},
+			"client-cert": {Type: CredentialTypeCertificate, Fingerprint: fingerprint([]byte("cert")), ExpiresAt: now.Add(time.Hour)},
+		},
+	}
+
+	assert.True(t, device.VerifyToken("old-token", now))
+	assert.True(t, device.VerifyToken("new-token", now), "a rotated token works before the device confirms it")
+	assert.False(t, device.VerifyToken("cert", now), "only token credentials authenticate reports")
+	assert.False(t, device.VerifyToken("", now))
+	assert.False(t, device.VerifyToken("old-token", now.Add(2*time.Hour)), "expired")
+	assert.True(t, device.VerifyToken("new-token", now.Add(2*time.Hour)))
+}
+
+func TestDevice_CredentialsEntityRoundTrip(t *testing.T) {
+	device := &Device{
+		DeviceID: "device-1",
+		Credentials: map[string]*Credential{
+			"mqtt": {
+				Type:      CredentialTypeToken,
+				Version:   1,
+				ExpiresAt: time.Now().Add(time.Hour).UTC(),
+				Pending:   &PendingCredential{Version: 2, Material: "new-token"},
+			},
+		},
+	}
+
+	entity, err := device.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, "new-token", restored.Credentials["mqtt"].Pending.Material)
+
+	// The API never shows pending material
+	data, err := json.Marshal(restored)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "new-token")
+}
+
+func TestService_TrackCredential_Certificate(t *testing.T) {
+	service, mockRepo := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	notAfter := time.Now().Add(90 * 24 * time.Hour).UTC().Truncate(time.Second)
+	device := &Device{DeviceID: "device-1"}
+	mockRepo.On("GetDevice", mock.Anything, "device-1").Return(device, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, device).Return(nil)
+
+	body, _ := json.Marshal(CredentialRecord{Type: CredentialTypeCertificate, Certificate: testCertificatePEM(t, notAfter)})
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/devices/device-1/credentials/tls", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	credential := device.Credentials["tls"]
+	require.NotNil(t, credential)
+	assert.True(t, notAfter.Equal(credential.ExpiresAt))
+	assert.Len(t, credential.Fingerprint, 64)
+	assert.Equal(t, 1, credential.Version)
+
+	// A record needs an expiry
+	body, _ = json.Marshal(CredentialRecord{Type: CredentialTypeWiFiEnterprise})
+	req, _ = http.NewRequest(http.MethodPut, "/api/v1/devices/device-1/credentials/wifi", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestService_RotateCredential_DeliveredByHeartbeat(t *testing.T) {
+	service, mockRepo := setupTestService()
+	mockMonitoring := &MockMonitoringService{}
+	service.monitoring = mockMonitoring
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	device := &Device{
+		DeviceID: "device-1",
+		Credentials: map[string]*Credential{
+			"mqtt": {Type: CredentialTypeToken, Version: 1, ExpiresAt: time.Now().Add(24 * time.Hour)},
+		},
+	}
+	mockRepo.On("GetDevice", mock.Anything, "device-1").Return(device, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, device).Return(nil)
+	mockMonitoring.On("ProcessHeartbeat", mock.Anything, mock.Anything).Return(nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/devices/device-1/credentials/mqtt/rotate", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var rotation CredentialRotation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotation))
+	assert.Equal(t, 2, rotation.Version)
+	assert.NotEmpty(t, rotation.Token)
+	assert.True(t, rotation.ExpiresAt.After(time.Now().Add(300*24*time.Hour)))
+
+	heartbeat := func(versions map[string]int) map[string]interface{} {
+		body, _ := json.Marshal(DeviceHeartbeat{DeviceID: "device-1", Credentials: versions})
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/devices/device-1/heartbeat", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// Until the device reports the new version, every heartbeat carries it
+	resp := heartbeat(map[string]int{"mqtt": 1})
+	require.Len(t, resp["credentials"], 1)
+	delivery := resp["credentials"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "mqtt", delivery["name"])
+	assert.Equal(t, rotation.Token, delivery["material"])
+	assert.EqualValues(t, 2, delivery["version"])
+
+	resp = heartbeat(map[string]int{"mqtt": 2})
+	assert.NotContains(t, resp, "credentials")
+	credential := device.Credentials["mqtt"]
+	assert.Equal(t, 2, credential.Version)
+	assert.Nil(t, credential.Pending)
+	assert.True(t, rotation.ExpiresAt.Equal(credential.ExpiresAt))
+
+	// Credentials that are not tracked cannot be rotated
+	req, _ = http.NewRequest(http.MethodPost, "/api/v1/devices/device-1/credentials/wifi/rotate", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestService_ExpiringCredentials(t *testing.T) {
+	service, mockRepo := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	now := time.Now()
+	mockRepo.On("ListDevices", mock.Anything, mock.Anything).Return([]*Device{
+		{DeviceID: "device-1", Credentials: map[string]*Credential{
+			"mqtt": {Type: CredentialTypeToken, ExpiresAt: now.Add(3 * 24 * time.Hour)},
+			"tls":  {Type: CredentialTypeCertificate, ExpiresAt: now.Add(365 * 24 * time.Hour)},
+		}},
+		{DeviceID: "device-2", Credentials: map[string]*Credential{
+			"wifi": {Type: CredentialTypeWiFiEnterprise, ExpiresAt: now.Add(-time.Hour)},
+		}},
+	}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/devices/credentials/expiring?within=168h", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Credentials []ExpiringCredential `json:"credentials"`
+		Count       int                  `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 2, resp.Count)
+	assert.Equal(t, "device-2", resp.Credentials[0].DeviceID)
+	assert.True(t, resp.Credentials[0].Expired)
+	assert.Equal(t, "mqtt", resp.Credentials[1].Name)
+	assert.False(t, resp.Credentials[1].Expired)
+}
+
+func TestCredentialMonitor_Check(t *testing.T) {
+	mockRepo := new(MockRepository)
+	monitor := NewCredentialMonitor(mockRepo, logger.New("info", "test"), &config.Config{})
+	events := make(channelPublisher, 4)
+	monitor.SetPublisher(events)
+
+	now := time.Now()
+	device := &Device{DeviceID: "device-1", Credentials: map[string]*Credential{
+		"mqtt": {Type: CredentialTypeToken, Version: 3, ExpiresAt: now.Add(5 * 24 * time.Hour)},
+		"tls":  {Type: CredentialTypeCertificate, ExpiresAt: now.Add(-time.Minute)},
+	}}
+	mockRepo.On("ListDevices", mock.Anything, mock.Anything).Return([]*Device{device}, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, device).Return(nil)
+
+	sent, err := monitor.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+
+	types := map[string]notifications.EventType{}
+	for i := 0; i < 2; i++ {
+		event := <-events
+		assert.Equal(t, "device-1", event.ResourceID)
+		types[event.Data["credential"].(string)] = event.Type
+	}
+	assert.Equal(t, map[string]notifications.EventType{
+		"mqtt": notifications.EventCredentialExpiring,
+		"tls":  notifications.EventCredentialExpired,
+	}, types)
+
+	// Notices are not repeated within the same window
+	sent, err = monitor.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDevice", 1)
+}
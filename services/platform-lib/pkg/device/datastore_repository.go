@@ -159,25 +159,36 @@ func (r *DatastoreRepository) ListDevices(ctx context.Context, filters *DeviceFi
 		if filters.Status != "" {
 			query = query.Filter("status =", string(filters.Status))
 		}
+		if filters.Name != "" {
+			query = query.Filter("name =", filters.Name)
+		}
 		if filters.BoardType != "" {
 			query = query.Filter("board_type =", filters.BoardType)
 		}
 		if filters.TemplateID != "" {
 			query = query.Filter("template_id =", filters.TemplateID)
 		}
+		if filters.TemplateVersion != "" {
+			query = query.Filter("template_version =", filters.TemplateVersion)
+		}
 		if filters.OTAChannel != "" {
 			query = query.Filter("ota_channel =", filters.OTAChannel)
 		}
+		if filters.ParentID != "" {
+			query = query.Filter("parent_id =", filters.ParentID)
+		}
 		if filters.LastSeenBefore != nil {
 			query = query.Filter("last_seen <", *filters.LastSeenBefore)
 		}
 		if filters.LastSeenAfter != nil {
 			query = query.Filter("last_seen >", *filters.LastSeenAfter)
 		}
-		if filters.Limit > 0 {
+		// Labels are not indexed, so label and group filters page through
+		// the matching devices in memory
+		if filters.Limit > 0 && !filters.selective() {
 			query = query.Limit(filters.Limit)
 		}
-		if filters.Offset > 0 {
+		if filters.Offset > 0 && !filters.selective() {
 			query = query.Offset(filters.Offset)
 		}
 	}
@@ -202,11 +213,58 @@ func (r *DatastoreRepository) ListDevices(ctx context.Context, filters *DeviceFi
 		devices = append(devices, device)
 	}
 
+	if filters != nil && filters.selective() {
+		return r.selectDevices(ctx, filters, devices)
+	}
 	return devices, nil
 }
 
+// selective reports whether the filters select devices by label or group
+func (f *DeviceFilters) selective() bool {
+	return len(f.Labels) > 0 || f.Group != ""
+}
+
+// selectDevices narrows devices down to those matching the label and group
+// filters, then applies the filters' offset and limit
+func (r *DatastoreRepository) selectDevices(ctx context.Context, filters *DeviceFilters, devices []*Device) ([]*Device, error) {
+	var group *DeviceGroup
+	if filters.Group != "" {
+		var err error
+		if group, err = getGroup(ctx, r.client, filters.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	var selected []*Device
+	for _, device := range devices {
+		if device.MatchesLabels(filters.Labels) && (group == nil || group.Contains(device)) {
+			selected = append(selected, device)
+		}
+	}
+
+	if filters.Offset >= len(selected) {
+		return nil, nil
+	}
+	selected = selected[filters.Offset:]
+	if filters.Limit > 0 && filters.Limit < len(selected) {
+		selected = selected[:filters.Limit]
+	}
+	return selected, nil
+}
+
 // GetDeviceCount returns the count of devices matching the filters from Datastore
 func (r *DatastoreRepository) GetDeviceCount(ctx context.Context, filters *DeviceFilters) (int64, error) {
+	if filters != nil && filters.selective() {
+		unpaged := *filters
+		unpaged.Limit = 0
+		unpaged.Offset = 0
+		devices, err := r.ListDevices(ctx, &unpaged)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(devices)), nil
+	}
+
 	query := datastore.NewQuery("Device")
 
 	// Apply filters
@@ -214,15 +272,24 @@ func (r *DatastoreRepository) GetDeviceCount(ctx context.Context, filters *Devic
 		if filters.Status != "" {
 			query = query.Filter("status =", string(filters.Status))
 		}
+		if filters.Name != "" {
+			query = query.Filter("name =", filters.Name)
+		}
 		if filters.BoardType != "" {
 			query = query.Filter("board_type =", filters.BoardType)
 		}
 		if filters.TemplateID != "" {
 			query = query.Filter("template_id =", filters.TemplateID)
 		}
+		if filters.TemplateVersion != "" {
+			query = query.Filter("template_version =", filters.TemplateVersion)
+		}
 		if filters.OTAChannel != "" {
 			query = query.Filter("ota_channel =", filters.OTAChannel)
 		}
+		if filters.ParentID != "" {
+			query = query.Filter("parent_id =", filters.ParentID)
+		}
 		if filters.LastSeenBefore != nil {
 			query = query.Filter("last_seen <", *filters.LastSeenBefore)
 		}
@@ -391,11 +458,15 @@ func (r *DatastoreRepository) matchesQuery(device *Device, query string) bool {
 		return true
 	}
 
-	// Simple text search in device ID, board type, and template ID
+	// Simple text search in device ID, name, board type, and template ID
 	if strings.Contains(strings.ToLower(device.DeviceID), query) {
 		return true
 	}
 
+	if strings.Contains(strings.ToLower(device.Name), query) {
+		return true
+	}
+
 	if strings.Contains(strings.ToLower(device.BoardType), query) {
 		return true
 	}
@@ -0,0 +1,401 @@
+package device
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+const (
+	// defaultHeartbeatHistory is the number of recent heartbeats kept per device
+	defaultHeartbeatHistory = 50
+
+	// debugBundleWindow is how far back telemetry is collected for a bundle
+	debugBundleWindow = 24 * time.Hour
+)
+
+// debugCaptureServices record the exchanges of devices being captured
+var debugCaptureServices = []string{"telemetry-service", "ota-service"}
+
+// errServiceNotFound is returned when a service has nothing at a path
+var errServiceNotFound = errors.New("not found")
+
+// FlashResult records the outcome of the most recent flash of a device
+type FlashResult struct {
+	Success      bool      `json:"success"`
+	FirmwareHash string    `json:"firmware_hash,omitempty"`
+	ArtifactID   string    `json:"artifact_id,omitempty"`
+	Port         string    `json:"port,omitempty"`
+	Duration     string    `json:"duration,omitempty"`
+	Errors       []string  `json:"errors,omitempty"`
+	FlashedAt    time.Time `json:"flashed_at"`
+}
+
+// DebugBundle aggregates the data support needs to diagnose a single device
+type DebugBundle struct {
+	DeviceID         string            `json:"device_id"`
+	GeneratedAt      time.Time         `json:"generated_at"`
+	Device           *Device           `json:"device"`
+	IsOnline         bool              `json:"is_online"`
+	LastSeenDuration string            `json:"last_seen_duration"`
+	Heartbeats       []DeviceHeartbeat `json:"heartbeats"`
+	LastFlash        *FlashResult      `json:"last_flash,omitempty"`
+	Telemetry        json.RawMessage   `json:"telemetry,omitempty"`
+	Alerts           json.RawMessage   `json:"alerts,omitempty"`
+	OTAUpdates       json.RawMessage   `json:"ota_updates,omitempty"`
+	CrashReports     json.RawMessage   `json:"crash_reports,omitempty"`
+	TelemetryCapture json.RawMessage   `json:"telemetry_capture,omitempty"`
+	OTACapture       json.RawMessage   `json:"ota_capture,omitempty"`
+	Errors           map[string]string `json:"errors,omitempty"`
+}
+
+// DebugCaptureResult reports a debug capture started or stopped on each
+// service that records device exchanges
+type DebugCaptureResult struct {
+	DeviceID string                     `json:"device_id"`
+	Captures map[string]json.RawMessage `json:"captures,omitempty"`
+	Errors   map[string]string          `json:"errors,omitempty"`
+}
+
+// DebugHistoryStore keeps the recent heartbeats and the last flash result
+// of each device for debug bundles
+type DebugHistoryStore interface {
+	// RecordHeartbeat appends a heartbeat, dropping the oldest once the
+	// device has defaultHeartbeatHistory of them
+	RecordHeartbeat(ctx context.Context, heartbeat DeviceHeartbeat) error
+	// RecordFlash replaces the last flash result of a device
+	RecordFlash(ctx context.Context, deviceID string, result *FlashResult) error
+	// Heartbeats returns the recent heartbeats of a device, newest last
+	Heartbeats(ctx context.Context, deviceID string) ([]DeviceHeartbeat, error)
+	// LastFlash returns the last flash result of a device, or nil if it was
+	// never reported
+	LastFlash(ctx context.Context, deviceID string) (*FlashResult, error)
+}
+
+// MemoryDebugHistoryStore is an in-memory DebugHistoryStore
+type MemoryDebugHistoryStore struct {
+	mu            sync.RWMutex
+	maxHeartbeats int
+	heartbeats    map[string][]DeviceHeartbeat
+	flashes       map[string]*FlashResult
+}
+
+// NewMemoryDebugHistoryStore creates a store keeping up to maxHeartbeats per device
+func NewMemoryDebugHistoryStore(maxHeartbeats int) *MemoryDebugHistoryStore {
+	return &MemoryDebugHistoryStore{
+		maxHeartbeats: maxHeartbeats,
+		heartbeats:    make(map[string][]DeviceHeartbeat),
+		flashes:       make(map[string]*FlashResult),
+	}
+}
+
+func (h *MemoryDebugHistoryStore) RecordHeartbeat(ctx context.Context, heartbeat DeviceHeartbeat) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.heartbeats[heartbeat.DeviceID] = appendHeartbeat(h.heartbeats[heartbeat.DeviceID], heartbeat, h.maxHeartbeats)
+	return nil
+}
+
+func (h *MemoryDebugHistoryStore) RecordFlash(ctx context.Context, deviceID string, result *FlashResult) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stored := *result
+	h.flashes[deviceID] = &stored
+	return nil
+}
+
+func (h *MemoryDebugHistoryStore) Heartbeats(ctx context.Context, deviceID string) ([]DeviceHeartbeat, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	beats := make([]DeviceHeartbeat, len(h.heartbeats[deviceID]))
+	copy(beats, h.heartbeats[deviceID])
+	return beats, nil
+}
+
+func (h *MemoryDebugHistoryStore) LastFlash(ctx context.Context, deviceID string) (*FlashResult, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result, ok := h.flashes[deviceID]
+	if !ok {
+		return nil, nil
+	}
+	listed := *result
+	return &listed, nil
+}
+
+// appendHeartbeat appends a heartbeat to beats, keeping the newest max
+func appendHeartbeat(beats []DeviceHeartbeat, heartbeat DeviceHeartbeat, max int) []DeviceHeartbeat {
+	beats = append(beats, heartbeat)
+	if len(beats) > max {
+		beats = beats[len(beats)-max:]
+	}
+	return beats
+}
+
+// SetDebugHistoryStore sets where the heartbeats and flash results shown in
+// debug bundles are kept
+func (s *Service) SetDebugHistoryStore(store DebugHistoryStore) {
+	s.history = store
+}
+
+// RecordFlashResult stores the outcome of flashing a device and adds it to
+// the device's timeline
+func (s *Service) RecordFlashResult(ctx context.Context, deviceID string, result *FlashResult) error {
+	if result.FlashedAt.IsZero() {
+		result.FlashedAt = time.Now()
+	}
+	if err := s.history.RecordFlash(ctx, deviceID, result); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Device %s was flashed", deviceID)
+	if !result.Success {
+		message = fmt.Sprintf("Flashing device %s failed", deviceID)
+	}
+	data := map[string]interface{}{"success": result.Success}
+	if result.ArtifactID != "" {
+		data["artifact_id"] = result.ArtifactID
+	}
+	if result.FirmwareHash != "" {
+		data["firmware_hash"] = result.FirmwareHash
+	}
+	if len(result.Errors) > 0 {
+		data["errors"] = result.Errors
+	}
+	s.events.Record(ctx, deviceID, DeviceEventFlashed, message, data)
+	return nil
+}
+
+// BuildDebugBundle assembles device metadata, recent history and data from
+// the telemetry and OTA services, including the exchanges recorded by a
+// debug capture if one was started. Failures of individual sections are
+// recorded in the bundle instead of failing the whole request.
+func (s *Service) BuildDebugBundle(ctx context.Context, deviceID string) (*DebugBundle, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	now := time.Now()
+	bundle := &DebugBundle{
+		DeviceID:         deviceID,
+		GeneratedAt:      now,
+		Device:           device,
+		IsOnline:         device.IsOnline(s.offlineTimeout()),
+		LastSeenDuration: now.Sub(device.LastSeen).String(),
+		Errors:           make(map[string]string),
+	}
+
+	if bundle.Heartbeats, err = s.history.Heartbeats(ctx, deviceID); err != nil {
+		s.logger.Warn("Debug bundle section unavailable", "section", "heartbeats", "device_id", deviceID, "error", err)
+		bundle.Errors["heartbeats"] = err.Error()
+	}
+	if bundle.LastFlash, err = s.history.LastFlash(ctx, deviceID); err != nil {
+		s.logger.Warn("Debug bundle section unavailable", "section", "last_flash", "device_id", deviceID, "error", err)
+		bundle.Errors["last_flash"] = err.Error()
+	}
+
+	query := url.Values{}
+	query.Set("start", now.Add(-debugBundleWindow).Format(time.RFC3339))
+	query.Set("end", now.Format(time.RFC3339))
+
+	// Captures are optional: a device that was never captured has none
+	sections := []struct {
+		name    string
+		service string
+		path    string
+		target  *json.RawMessage
+		capture bool
+	}{
+		{"telemetry", "telemetry-service", "/api/v1/telemetry/metrics/" + url.PathEscape(deviceID) + "?" + query.Encode(), &bundle.Telemetry, false},
+		{"alerts", "telemetry-service", "/api/v1/telemetry/alerts/" + url.PathEscape(deviceID), &bundle.Alerts, false},
+		{"ota_updates", "ota-service", "/api/v1/ota/devices/" + url.PathEscape(deviceID) + "/updates", &bundle.OTAUpdates, false},
+		{"crash_reports", "ota-service", "/api/v1/ota/devices/" + url.PathEscape(deviceID) + "/crashes", &bundle.CrashReports, false},
+		{"telemetry_capture", "telemetry-service", debugCapturePath(deviceID), &bundle.TelemetryCapture, true},
+		{"ota_capture", "ota-service", debugCapturePath(deviceID), &bundle.OTACapture, true},
+	}
+
+	for _, section := range sections {
+		data, err := s.callService(ctx, http.MethodGet, section.service, section.path, nil, section.capture)
+		if section.capture && errors.Is(err, errServiceNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("Debug bundle section unavailable", "section", section.name, "device_id", deviceID, "error", err)
+			bundle.Errors[section.name] = err.Error()
+			continue
+		}
+		*section.target = data
+	}
+
+	return bundle, nil
+}
+
+// StartDebugCapture starts recording a device's telemetry and OTA requests
+// and responses on each service for duration, or the services' default if
+// it is zero. Services that could not start a capture are reported in the
+// result.
+func (s *Service) StartDebugCapture(ctx context.Context, deviceID string, duration time.Duration) (*DebugCaptureResult, error) {
+	if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]int{"duration_seconds": int(duration.Seconds())})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal capture request: %w", err)
+	}
+
+	result := &DebugCaptureResult{DeviceID: deviceID, Captures: make(map[string]json.RawMessage), Errors: make(map[string]string)}
+	for _, service := range debugCaptureServices {
+		data, err := s.callService(ctx, http.MethodPut, service, debugCapturePath(deviceID), body, true)
+		if err != nil {
+			s.logger.Warn("Failed to start debug capture", "service", service, "device_id", deviceID, "error", err)
+			result.Errors[service] = err.Error()
+			continue
+		}
+		result.Captures[service] = data
+	}
+
+	s.logger.Info("Debug capture requested", "device_id", deviceID, "services", len(result.Captures))
+	return result, nil
+}
+
+// StopDebugCapture ends a device's capture on each service, discarding the
+// recorded exchanges
+func (s *Service) StopDebugCapture(ctx context.Context, deviceID string) *DebugCaptureResult {
+	result := &DebugCaptureResult{DeviceID: deviceID, Errors: make(map[string]string)}
+	for _, service := range debugCaptureServices {
+		_, err := s.callService(ctx, http.MethodDelete, service, debugCapturePath(deviceID), nil, true)
+		if err != nil && !errors.Is(err, errServiceNotFound) {
+			result.Errors[service] = err.Error()
+		}
+	}
+	return result
+}
+
+func debugCapturePath(deviceID string) string {
+	return "/admin/debug-capture/devices/" + url.PathEscape(deviceID)
+}
+
+// callService sends a request to another platform service and returns its
+// raw JSON response. Requests to admin APIs must be signed.
+func (s *Service) callService(ctx context.Context, method, service, path string, payload []byte, signed bool) (json.RawMessage, error) {
+	if s.config == nil || s.config.Services[service] == "" {
+		return nil, fmt.Errorf("%s is not configured", service)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, s.config.Services[service]+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if signed {
+		admin.SignRequest(req, []byte(s.config.JWTSecret), payload, time.Now())
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", service, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s returned HTTP %d: %w", service, resp.StatusCode, errServiceNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned HTTP %d", service, resp.StatusCode)
+	}
+
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("%s returned invalid JSON", service)
+	}
+
+	return json.RawMessage(body), nil
+}
+
+// Zip packages the bundle as an archive with one JSON file per section
+func (b *DebugBundle) Zip() ([]byte, error) {
+	files := map[string]interface{}{
+		"bundle.json":     b,
+		"device.json":     b.Device,
+		"heartbeats.json": b.Heartbeats,
+	}
+	if b.LastFlash != nil {
+		files["last_flash.json"] = b.LastFlash
+	}
+	if b.Telemetry != nil {
+		files["telemetry.json"] = b.Telemetry
+	}
+	if b.Alerts != nil {
+		files["alerts.json"] = b.Alerts
+	}
+	if b.OTAUpdates != nil {
+		files["ota_updates.json"] = b.OTAUpdates
+	}
+	if b.CrashReports != nil {
+		files["crash_reports.json"] = b.CrashReports
+	}
+	if b.TelemetryCapture != nil {
+		files["telemetry_capture.json"] = b.TelemetryCapture
+	}
+	if b.OTACapture != nil {
+		files["ota_capture.json"] = b.OTACapture
+	}
+	if len(b.Errors) > 0 {
+		files["errors.json"] = b.Errors
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		data, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     b.DeviceID + "/" + name,
+			Method:   zip.Deflate,
+			Modified: b.GeneratedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %w", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to write %s to archive: %w", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
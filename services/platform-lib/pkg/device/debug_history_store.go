@@ -0,0 +1,134 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// HeartbeatHistoryEntity represents the recent heartbeats of a device in
+// Datastore
+type HeartbeatHistoryEntity struct {
+	HeartbeatsJSON string    `datastore:"heartbeats_json,noindex"`
+	UpdatedAt      time.Time `datastore:"updated_at"`
+}
+
+// FlashResultEntity represents the last flash result of a device in
+// Datastore
+type FlashResultEntity struct {
+	Success      bool      `datastore:"success"`
+	FirmwareHash string    `datastore:"firmware_hash,noindex"`
+	ArtifactID   string    `datastore:"artifact_id"`
+	Port         string    `datastore:"port,noindex"`
+	Duration     string    `datastore:"duration,noindex"`
+	Errors       []string  `datastore:"errors,noindex"`
+	FlashedAt    time.Time `datastore:"flashed_at"`
+}
+
+// ToEntity converts a FlashResult to a FlashResultEntity
+func (r *FlashResult) ToEntity() *FlashResultEntity {
+	return &FlashResultEntity{
+		Success:      r.Success,
+		FirmwareHash: r.FirmwareHash,
+		ArtifactID:   r.ArtifactID,
+		Port:         r.Port,
+		Duration:     r.Duration,
+		Errors:       r.Errors,
+		FlashedAt:    r.FlashedAt,
+	}
+}
+
+// FromEntity converts a FlashResultEntity to a FlashResult
+func (fe *FlashResultEntity) FromEntity() *FlashResult {
+	return &FlashResult{
+		Success:      fe.Success,
+		FirmwareHash: fe.FirmwareHash,
+		ArtifactID:   fe.ArtifactID,
+		Port:         fe.Port,
+		Duration:     fe.Duration,
+		Errors:       fe.Errors,
+		FlashedAt:    fe.FlashedAt,
+	}
+}
+
+// DatastoreDebugHistoryStore keeps debug history in Datastore, keyed by
+// device ID. The recent heartbeats of a device are kept in one entity, so
+// the history stays bounded without a cleanup query.
+type DatastoreDebugHistoryStore struct {
+	client        *datastore.Client
+	maxHeartbeats int
+}
+
+// NewDatastoreDebugHistoryStore creates a debug history store on a
+// Datastore client
+func NewDatastoreDebugHistoryStore(client *datastore.Client) *DatastoreDebugHistoryStore {
+	return &DatastoreDebugHistoryStore{client: client, maxHeartbeats: defaultHeartbeatHistory}
+}
+
+// RecordHeartbeat appends the heartbeat inside a transaction, so heartbeats
+// handled by different replicas are not lost
+func (s *DatastoreDebugHistoryStore) RecordHeartbeat(ctx context.Context, heartbeat DeviceHeartbeat) error {
+	key := datastore.NameKey("HeartbeatHistory", heartbeat.DeviceID, nil)
+
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity HeartbeatHistoryEntity
+		var beats []DeviceHeartbeat
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+			if err := json.Unmarshal([]byte(entity.HeartbeatsJSON), &beats); err != nil {
+				return fmt.Errorf("failed to unmarshal heartbeat history: %w", err)
+			}
+		case datastore.ErrNoSuchEntity:
+		default:
+			return fmt.Errorf("failed to retrieve heartbeat history from Datastore: %w", err)
+		}
+
+		beatsJSON, err := json.Marshal(appendHeartbeat(beats, heartbeat, s.maxHeartbeats))
+		if err != nil {
+			return fmt.Errorf("failed to marshal heartbeat history: %w", err)
+		}
+		updated := &HeartbeatHistoryEntity{HeartbeatsJSON: string(beatsJSON), UpdatedAt: time.Now()}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to save heartbeat history in Datastore: %w", err)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *DatastoreDebugHistoryStore) RecordFlash(ctx context.Context, deviceID string, result *FlashResult) error {
+	if _, err := s.client.Put(ctx, datastore.NameKey("FlashResult", deviceID, nil), result.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save flash result in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreDebugHistoryStore) Heartbeats(ctx context.Context, deviceID string) ([]DeviceHeartbeat, error) {
+	var entity HeartbeatHistoryEntity
+	if err := s.client.Get(ctx, datastore.NameKey("HeartbeatHistory", deviceID, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return []DeviceHeartbeat{}, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve heartbeat history from Datastore: %w", err)
+	}
+
+	beats := []DeviceHeartbeat{}
+	if err := json.Unmarshal([]byte(entity.HeartbeatsJSON), &beats); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal heartbeat history: %w", err)
+	}
+	return beats, nil
+}
+
+func (s *DatastoreDebugHistoryStore) LastFlash(ctx context.Context, deviceID string) (*FlashResult, error) {
+	var entity FlashResultEntity
+	if err := s.client.Get(ctx, datastore.NameKey("FlashResult", deviceID, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve flash result from Datastore: %w", err)
+	}
+	return entity.FromEntity(), nil
+}
@@ -0,0 +1,209 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
+)
+
+// MemoryEventStore is an in-memory EventStore. Its cursors are offsets into
+// the filtered timeline.
+type MemoryEventStore struct {
+	mu     sync.Mutex
+	events map[string][]*DeviceEvent
+}
+
+// NewMemoryEventStore creates an empty store
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{events: make(map[string][]*DeviceEvent)}
+}
+
+func (s *MemoryEventStore) RecordEvent(ctx context.Context, event *DeviceEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *event
+	s.events[event.DeviceID] = append(s.events[event.DeviceID], &stored)
+	return nil
+}
+
+func (s *MemoryEventStore) QueryEvents(ctx context.Context, deviceID string, q *DeviceEventQuery) ([]*DeviceEvent, string, error) {
+	offset := 0
+	if q.Cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(q.Cursor); err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDeviceEventQuery)
+		}
+	}
+
+	s.mu.Lock()
+	var matched []*DeviceEvent
+	for _, event := range s.events[deviceID] {
+		if len(q.Types) > 0 && !slices.Contains(q.Types, event.Type) {
+			continue
+		}
+		if !q.Since.IsZero() && event.Timestamp.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !event.Timestamp.Before(q.Until) {
+			continue
+		}
+		listed := *event
+		matched = append(matched, &listed)
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.After(matched[j].Timestamp)
+	})
+	if offset >= len(matched) {
+		return nil, "", nil
+	}
+	matched = matched[offset:]
+	if q.Limit <= 0 || len(matched) <= q.Limit {
+		return matched, "", nil
+	}
+	return matched[:q.Limit], strconv.Itoa(offset + q.Limit), nil
+}
+
+// DeviceEventEntity represents a device event in Datastore
+type DeviceEventEntity struct {
+	DeviceID  string    `datastore:"device_id"`
+	Type      string    `datastore:"type"`
+	Source    string    `datastore:"source"`
+	Message   string    `datastore:"message,noindex"`
+	DataJSON  string    `datastore:"data_json,noindex"`
+	Timestamp time.Time `datastore:"timestamp"`
+}
+
+// ToEntity converts a DeviceEvent to a DeviceEventEntity
+func (e *DeviceEvent) ToEntity() (*DeviceEventEntity, error) {
+	entity := &DeviceEventEntity{
+		DeviceID:  e.DeviceID,
+		Type:      string(e.Type),
+		Source:    e.Source,
+		Message:   e.Message,
+		Timestamp: e.Timestamp,
+	}
+	if len(e.Data) > 0 {
+		dataJSON, err := json.Marshal(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal device event data: %w", err)
+		}
+		entity.DataJSON = string(dataJSON)
+	}
+	return entity, nil
+}
+
+// FromEntity converts a DeviceEventEntity to the DeviceEvent stored under
+// eventID
+func (de *DeviceEventEntity) FromEntity(eventID string) (*DeviceEvent, error) {
+	event := &DeviceEvent{
+		EventID:   eventID,
+		DeviceID:  de.DeviceID,
+		Type:      DeviceEventType(de.Type),
+		Source:    de.Source,
+		Message:   de.Message,
+		Timestamp: de.Timestamp,
+	}
+	if de.DataJSON != "" {
+		if err := json.Unmarshal([]byte(de.DataJSON), &event.Data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal device event data: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// DatastoreEventStore keeps device timelines in Datastore, keyed by event
+// ID. Every service recording events writes to the same kind, so the
+// timeline of a device is read in one query.
+type DatastoreEventStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreEventStore creates an event store on a Datastore client
+func NewDatastoreEventStore(client *datastore.Client) *DatastoreEventStore {
+	return &DatastoreEventStore{client: client}
+}
+
+func (s *DatastoreEventStore) RecordEvent(ctx context.Context, event *DeviceEvent) error {
+	entity, err := event.ToEntity()
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Put(ctx, datastore.NameKey("DeviceEvent", event.EventID, nil), entity); err != nil {
+		return fmt.Errorf("failed to store device event in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreEventStore) QueryEvents(ctx context.Context, deviceID string, q *DeviceEventQuery) ([]*DeviceEvent, string, error) {
+	query := datastore.NewQuery("DeviceEvent").
+		Filter("device_id =", deviceID)
+
+	switch len(q.Types) {
+	case 0:
+	case 1:
+		query = query.Filter("type =", string(q.Types[0]))
+	default:
+		types := make([]interface{}, len(q.Types))
+		for i, eventType := range q.Types {
+			types[i] = string(eventType)
+		}
+		query = query.FilterField("type", "in", types)
+	}
+	if !q.Since.IsZero() {
+		query = query.Filter("timestamp >=", q.Since)
+	}
+	if !q.Until.IsZero() {
+		query = query.Filter("timestamp <", q.Until)
+	}
+	query = query.Order("-timestamp")
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	if q.Cursor != "" {
+		cursor, err := datastore.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDeviceEventQuery)
+		}
+		query = query.Start(cursor)
+	}
+
+	var events []*DeviceEvent
+	it := s.client.Run(ctx, query)
+	for {
+		var entity DeviceEventEntity
+		key, err := it.Next(&entity)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query device events from Datastore: %w", err)
+		}
+		event, err := entity.FromEntity(key.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, event)
+	}
+
+	// A short page is the last one
+	if q.Limit <= 0 || len(events) < q.Limit {
+		return events, "", nil
+	}
+	cursor, err := it.Cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get device event cursor: %w", err)
+	}
+	return events, cursor.String(), nil
+}
@@ -0,0 +1,54 @@
+package device
+
+import (
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+)
+
+// publishDeviceEvent announces a change to a device, so live device lists
+// can update without polling. The event data carries the device list filter
+// fields, which stream subscribers filter on, and the device itself.
+func publishDeviceEvent(publisher notifications.Publisher, log *logger.Logger, eventType notifications.EventType, device *Device) {
+	if publisher == nil || device == nil {
+		return
+	}
+
+	var message string
+	switch eventType {
+	case notifications.EventDeviceAdded:
+		message = fmt.Sprintf("Device %s was registered", device.DeviceID)
+	case notifications.EventDeviceStatusChanged:
+		message = fmt.Sprintf("Device %s is now %s", device.DeviceID, device.Status)
+	default:
+		message = fmt.Sprintf("Device %s was updated", device.DeviceID)
+	}
+
+	notifications.PublishAsync(publisher, log, &notifications.Event{
+		Type:         eventType,
+		ResourceType: "device",
+		ResourceID:   device.DeviceID,
+		Message:      message,
+		Data: map[string]interface{}{
+			"status":           string(device.Status),
+			"board_type":       device.BoardType,
+			"template_id":      device.TemplateID,
+			"template_version": device.TemplateVersion,
+			"ota_channel":      device.OTAChannel,
+			"parent_id":        device.ParentID,
+			"device":           device,
+		},
+	})
+}
+
+// publishDeviceRemoved announces that a device was deleted. The event only
+// carries the device ID, so it reaches every filtered device list.
+func publishDeviceRemoved(publisher notifications.Publisher, log *logger.Logger, deviceID string) {
+	notifications.PublishAsync(publisher, log, &notifications.Event{
+		Type:         notifications.EventDeviceRemoved,
+		ResourceType: "device",
+		ResourceID:   deviceID,
+		Message:      fmt.Sprintf("Device %s was removed", deviceID),
+	})
+}
@@ -0,0 +1,563 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrGroupNotFound is returned for device groups that do not exist
+	ErrGroupNotFound = errors.New("device group not found")
+
+	// ErrGroupExists is returned when creating a device group under a name
+	// already taken, including by the groups in the configuration
+	ErrGroupExists = errors.New("device group already exists")
+
+	// ErrInvalidGroup is returned for device groups and label selectors
+	// that cannot be stored or parsed
+	ErrInvalidGroup = errors.New("invalid device group")
+)
+
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// DeviceGroup names a set of devices: the members listed in Devices and
+// those carrying every label in Labels. Groups are managed through the API,
+// next to the groups in the configuration, and can be targeted by OTA
+// deployments and telemetry comparisons.
+type DeviceGroup struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Devices     []string          `json:"devices"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Contains reports whether a device belongs to the group
+func (g *DeviceGroup) Contains(d *Device) bool {
+	return slices.Contains(g.Devices, d.DeviceID) || (len(g.Labels) > 0 && d.MatchesLabels(g.Labels))
+}
+
+// validate checks the group's name and label selector
+func (g *DeviceGroup) validate() error {
+	if !groupNamePattern.MatchString(g.Name) {
+		return fmt.Errorf("%w: name %q must be letters, digits, dots, dashes and underscores", ErrInvalidGroup, g.Name)
+	}
+	for key := range g.Labels {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: label keys cannot be empty", ErrInvalidGroup)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector parses a selector such as "env=prod,site=lab1" into
+// the labels a device must carry
+func ParseLabelSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, term := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(term), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: selector term %q is not key=value", ErrInvalidGroup, term)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// GroupStore persists device groups
+type GroupStore interface {
+	// CreateGroup stores a new group, failing with ErrGroupExists if the
+	// name is taken
+	CreateGroup(ctx context.Context, group *DeviceGroup) error
+	GetGroup(ctx context.Context, name string) (*DeviceGroup, error)
+	// ListGroups returns groups ordered by name
+	ListGroups(ctx context.Context) ([]*DeviceGroup, error)
+	// ModifyGroup applies modify to the stored group so that concurrent
+	// writers cannot overwrite each other. An error from modify aborts the
+	// write and is returned unchanged.
+	ModifyGroup(ctx context.Context, name string, modify func(*DeviceGroup) error) (*DeviceGroup, error)
+	DeleteGroup(ctx context.Context, name string) error
+}
+
+// SetGroupStore sets where device groups are persisted
+func (s *Service) SetGroupStore(store GroupStore) {
+	s.groups = store
+}
+
+// MemoryGroupStore keeps device groups in memory
+type MemoryGroupStore struct {
+	mu     sync.Mutex
+	groups map[string]*DeviceGroup
+}
+
+// NewMemoryGroupStore creates an empty store
+func NewMemoryGroupStore() *MemoryGroupStore {
+	return &MemoryGroupStore{groups: make(map[string]*DeviceGroup)}
+}
+
+func (m *MemoryGroupStore) CreateGroup(ctx context.Context, group *DeviceGroup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.groups[group.Name]; exists {
+		return fmt.Errorf("%w: %s", ErrGroupExists, group.Name)
+	}
+	m.groups[group.Name] = copyGroup(group)
+	return nil
+}
+
+func (m *MemoryGroupStore) GetGroup(ctx context.Context, name string) (*DeviceGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.groups[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	return copyGroup(group), nil
+}
+
+func (m *MemoryGroupStore) ListGroups(ctx context.Context) ([]*DeviceGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	groups := make([]*DeviceGroup, 0, len(m.groups))
+	for _, group := range m.groups {
+		groups = append(groups, copyGroup(group))
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+func (m *MemoryGroupStore) ModifyGroup(ctx context.Context, name string, modify func(*DeviceGroup) error) (*DeviceGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.groups[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	group := copyGroup(stored)
+	if err := modify(group); err != nil {
+		return nil, err
+	}
+	m.groups[name] = copyGroup(group)
+	return group, nil
+}
+
+func (m *MemoryGroupStore) DeleteGroup(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.groups[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	delete(m.groups, name)
+	return nil
+}
+
+// copyGroup copies a group so that neither copy sees changes to the other's
+// members
+func copyGroup(group *DeviceGroup) *DeviceGroup {
+	copied := *group
+	copied.Devices = slices.Clone(group.Devices)
+	return &copied
+}
+
+// DeviceGroupEntity represents a device group in Datastore. Devices is
+// indexed so the groups of a device can be queried.
+type DeviceGroupEntity struct {
+	Description string    `datastore:"description,noindex"`
+	Devices     []string  `datastore:"devices"`
+	LabelsJSON  string    `datastore:"labels_json,noindex"`
+	CreatedAt   time.Time `datastore:"created_at"`
+	UpdatedAt   time.Time `datastore:"updated_at"`
+}
+
+// ToEntity converts a DeviceGroup to a DeviceGroupEntity
+func (g *DeviceGroup) ToEntity() (*DeviceGroupEntity, error) {
+	var labelsJSON []byte
+	if len(g.Labels) > 0 {
+		var err error
+		if labelsJSON, err = json.Marshal(g.Labels); err != nil {
+			return nil, fmt.Errorf("failed to marshal labels: %w", err)
+		}
+	}
+	return &DeviceGroupEntity{
+		Description: g.Description,
+		Devices:     g.Devices,
+		LabelsJSON:  string(labelsJSON),
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}, nil
+}
+
+// FromEntity converts a DeviceGroupEntity to the group called name
+func (ge *DeviceGroupEntity) FromEntity(name string) (*DeviceGroup, error) {
+	var labels map[string]string
+	if ge.LabelsJSON != "" {
+		if err := json.Unmarshal([]byte(ge.LabelsJSON), &labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+		}
+	}
+	devices := ge.Devices
+	if devices == nil {
+		devices = []string{}
+	}
+	return &DeviceGroup{
+		Name:        name,
+		Description: ge.Description,
+		Devices:     devices,
+		Labels:      labels,
+		CreatedAt:   ge.CreatedAt,
+		UpdatedAt:   ge.UpdatedAt,
+	}, nil
+}
+
+// DatastoreGroupStore keeps device groups in Datastore, keyed by name
+type DatastoreGroupStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreGroupStore creates a group store on a Datastore client
+func NewDatastoreGroupStore(client *datastore.Client) *DatastoreGroupStore {
+	return &DatastoreGroupStore{client: client}
+}
+
+func (s *DatastoreGroupStore) CreateGroup(ctx context.Context, group *DeviceGroup) error {
+	entity, err := group.ToEntity()
+	if err != nil {
+		return err
+	}
+	key := datastore.NameKey("DeviceGroup", group.Name, nil)
+	_, err = s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var existing DeviceGroupEntity
+		switch err := tx.Get(key, &existing); err {
+		case nil:
+			return fmt.Errorf("%w: %s", ErrGroupExists, group.Name)
+		case datastore.ErrNoSuchEntity:
+		default:
+			return fmt.Errorf("failed to retrieve device group from Datastore: %w", err)
+		}
+		if _, err := tx.Put(key, entity); err != nil {
+			return fmt.Errorf("failed to store device group in Datastore: %w", err)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *DatastoreGroupStore) GetGroup(ctx context.Context, name string) (*DeviceGroup, error) {
+	return getGroup(ctx, s.client, name)
+}
+
+// getGroup loads a group with client, which the device repository shares
+// to resolve group filters
+func getGroup(ctx context.Context, client *datastore.Client, name string) (*DeviceGroup, error) {
+	var entity DeviceGroupEntity
+	if err := client.Get(ctx, datastore.NameKey("DeviceGroup", name, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to retrieve device group from Datastore: %w", err)
+	}
+	return entity.FromEntity(name)
+}
+
+func (s *DatastoreGroupStore) ListGroups(ctx context.Context) ([]*DeviceGroup, error) {
+	var entities []DeviceGroupEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("DeviceGroup").Order("__key__"), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device groups from Datastore: %w", err)
+	}
+	groups := make([]*DeviceGroup, 0, len(entities))
+	for i := range entities {
+		group, err := entities[i].FromEntity(keys[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func (s *DatastoreGroupStore) ModifyGroup(ctx context.Context, name string, modify func(*DeviceGroup) error) (*DeviceGroup, error) {
+	key := datastore.NameKey("DeviceGroup", name, nil)
+
+	var group *DeviceGroup
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity DeviceGroupEntity
+		if err := tx.Get(key, &entity); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+			}
+			return fmt.Errorf("failed to retrieve device group from Datastore: %w", err)
+		}
+
+		var err error
+		if group, err = entity.FromEntity(name); err != nil {
+			return err
+		}
+		if err := modify(group); err != nil {
+			return err
+		}
+
+		updated, err := group.ToEntity()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update device group in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *DatastoreGroupStore) DeleteGroup(ctx context.Context, name string) error {
+	if _, err := s.GetGroup(ctx, name); err != nil {
+		return err
+	}
+	if err := s.client.Delete(ctx, datastore.NameKey("DeviceGroup", name, nil)); err != nil {
+		return fmt.Errorf("failed to delete device group from Datastore: %w", err)
+	}
+	return nil
+}
+
+// configuredGroup reports whether name is taken by a group in the
+// configuration
+func (s *Service) configuredGroup(name string) bool {
+	if s.config == nil {
+		return false
+	}
+	for _, group := range s.config.DeviceGroups {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateGroup stores a new device group. Its members must be registered.
+func (s *Service) CreateGroup(ctx context.Context, group *DeviceGroup) (*DeviceGroup, error) {
+	if err := group.validate(); err != nil {
+		return nil, err
+	}
+	if s.configuredGroup(group.Name) {
+		return nil, fmt.Errorf("%w: %s is defined in the configuration", ErrGroupExists, group.Name)
+	}
+	if err := s.checkMembers(ctx, group.Devices); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created := copyGroup(group)
+	created.Devices = mergeMembers(nil, group.Devices)
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	if err := s.groups.CreateGroup(ctx, created); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Device group created", "group", created.Name, "devices", len(created.Devices))
+	return created, nil
+}
+
+// UpdateGroup replaces the description, members and labels of a group.
+// Its members must be registered.
+func (s *Service) UpdateGroup(ctx context.Context, name string, update *DeviceGroup) (*DeviceGroup, error) {
+	replacement := copyGroup(update)
+	replacement.Name = name
+	if err := replacement.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkMembers(ctx, replacement.Devices); err != nil {
+		return nil, err
+	}
+	return s.groups.ModifyGroup(ctx, name, func(group *DeviceGroup) error {
+		group.Description = replacement.Description
+		group.Devices = mergeMembers(nil, replacement.Devices)
+		group.Labels = replacement.Labels
+		group.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// AddGroupMembers adds registered devices to a group
+func (s *Service) AddGroupMembers(ctx context.Context, name string, deviceIDs []string) (*DeviceGroup, error) {
+	if err := s.checkMembers(ctx, deviceIDs); err != nil {
+		return nil, err
+	}
+	return s.groups.ModifyGroup(ctx, name, func(group *DeviceGroup) error {
+		group.Devices = mergeMembers(group.Devices, deviceIDs)
+		group.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// RemoveGroupMember removes a device from a group's members. Devices still
+// matching the group's labels stay in the group.
+func (s *Service) RemoveGroupMember(ctx context.Context, name, deviceID string) (*DeviceGroup, error) {
+	return s.groups.ModifyGroup(ctx, name, func(group *DeviceGroup) error {
+		group.Devices = slices.DeleteFunc(group.Devices, func(member string) bool { return member == deviceID })
+		group.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// checkMembers checks that the devices to add to a group are registered
+func (s *Service) checkMembers(ctx context.Context, deviceIDs []string) error {
+	for _, deviceID := range deviceIDs {
+		if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+			return fmt.Errorf("%w: %v", errDeviceLookup, err)
+		}
+	}
+	return nil
+}
+
+// mergeMembers appends the devices of added not already in members
+func mergeMembers(members, added []string) []string {
+	if members == nil {
+		members = []string{}
+	}
+	for _, deviceID := range added {
+		if !slices.Contains(members, deviceID) {
+			members = append(members, deviceID)
+		}
+	}
+	return members
+}
+
+// HTTP handlers
+
+func (s *Service) createGroup(c *gin.Context) {
+	var group DeviceGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	created, err := s.CreateGroup(c.Request.Context(), &group)
+	if err != nil {
+		s.respondGroupError(c, "Failed to create device group", err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (s *Service) listGroups(c *gin.Context) {
+	groups, err := s.groups.ListGroups(c.Request.Context())
+	if err != nil {
+		s.respondGroupError(c, "Failed to list device groups", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+func (s *Service) getGroup(c *gin.Context) {
+	group, err := s.groups.GetGroup(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		s.respondGroupError(c, "Failed to get device group", err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func (s *Service) updateGroup(c *gin.Context) {
+	var group DeviceGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updated, err := s.UpdateGroup(c.Request.Context(), c.Param("name"), &group)
+	if err != nil {
+		s.respondGroupError(c, "Failed to update device group", err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (s *Service) deleteGroup(c *gin.Context) {
+	name := c.Param("name")
+	if err := s.groups.DeleteGroup(c.Request.Context(), name); err != nil {
+		s.respondGroupError(c, "Failed to delete device group", err)
+		return
+	}
+
+	s.logger.Info("Device group deleted", "group", name)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Device group deleted successfully",
+	})
+}
+
+// GroupMembersRequest lists devices to add to a group
+type GroupMembersRequest struct {
+	DeviceIDs []string `json:"device_ids" binding:"required,min=1"`
+}
+
+func (s *Service) addGroupMembers(c *gin.Context) {
+	var req GroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	group, err := s.AddGroupMembers(c.Request.Context(), c.Param("name"), req.DeviceIDs)
+	if err != nil {
+		s.respondGroupError(c, "Failed to add device group members", err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func (s *Service) removeGroupMember(c *gin.Context) {
+	group, err := s.RemoveGroupMember(c.Request.Context(), c.Param("name"), c.Param("deviceId"))
+	if err != nil {
+		s.respondGroupError(c, "Failed to remove device group member", err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func (s *Service) respondGroupError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidGroup):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrGroupNotFound), errors.Is(err, errDeviceLookup):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrGroupExists):
+		status = http.StatusConflict
+	default:
+		s.logger.Error(message, "error", err)
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,150 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	labels, err := ParseLabelSelector("env=prod, site=lab1,empty=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "site": "lab1", "empty": ""}, labels)
+
+	for _, invalid := range []string{"env", "env=prod,", "=prod"} {
+		_, err := ParseLabelSelector(invalid)
+		assert.ErrorIs(t, err, ErrInvalidGroup, invalid)
+	}
+}
+
+func TestDeviceGroup_Contains(t *testing.T) {
+	group := &DeviceGroup{Name: "lab", Devices: []string{"device-001"}, Labels: map[string]string{"site": "lab1"}}
+
+	assert.True(t, group.Contains(&Device{DeviceID: "device-001"}))
+	assert.True(t, group.Contains(&Device{DeviceID: "device-002", Labels: map[string]string{"site": "lab1", "env": "prod"}}))
+	assert.False(t, group.Contains(&Device{DeviceID: "device-003", Labels: map[string]string{"site": "lab2"}}))
+
+	// Groups without labels only contain their members
+	group.Labels = nil
+	assert.False(t, group.Contains(&Device{DeviceID: "device-002"}))
+}
+
+func TestDeviceGroupEntity_RoundTrip(t *testing.T) {
+	group := &DeviceGroup{Name: "lab", Description: "Bench devices", Devices: []string{"device-001"}, Labels: map[string]string{"site": "lab1"}}
+	entity, err := group.ToEntity()
+	require.NoError(t, err)
+
+	restored, err := entity.FromEntity("lab")
+	require.NoError(t, err)
+	assert.Equal(t, group, restored)
+
+	empty, err := (&DeviceGroupEntity{}).FromEntity("empty")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, empty.Devices)
+	assert.Nil(t, empty.Labels)
+}
+
+func TestService_DeviceGroups(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.groups = NewMemoryGroupStore()
+	service.config = &config.Config{DeviceGroups: []config.DeviceGroupConfig{{Name: "pilot"}}}
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetDevice", mock.Anything, "device-001").Return(createTestDevice("device-001"), nil)
+	mockRepo.On("GetDevice", mock.Anything, "device-002").Return(createTestDevice("device-002"), nil)
+	mockRepo.On("GetDevice", mock.Anything, "device-404").Return(nil, errors.New("device device-404 not found"))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/device-groups"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "", `{"name":"lab","devices":["device-001","device-001"],"labels":{"site":"lab1"}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var group DeviceGroup
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+	assert.Equal(t, []string{"device-001"}, group.Devices)
+	assert.False(t, group.CreatedAt.IsZero())
+
+	assert.Equal(t, http.StatusConflict, send("POST", "", `{"name":"lab"}`).Code)
+	assert.Equal(t, http.StatusConflict, send("POST", "", `{"name":"pilot"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "", `{"name":"lab 2"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "", `{"name":"lab2","devices":["device-404"]}`).Code)
+
+	w = send("POST", "/lab/members", `{"device_ids":["device-002","device-001"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+	assert.Equal(t, []string{"device-001", "device-002"}, group.Devices)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/lab/members", `{"device_ids":["device-404"]}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/missing/members", `{"device_ids":["device-001"]}`).Code)
+
+	w = send("DELETE", "/lab/members/device-001", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+	assert.Equal(t, []string{"device-002"}, group.Devices)
+
+	w = send("PUT", "/lab", `{"description":"Lab one","devices":["device-001"],"labels":{"site":"lab2"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &group))
+	assert.Equal(t, "lab", group.Name)
+	assert.Equal(t, "Lab one", group.Description)
+	assert.Equal(t, []string{"device-001"}, group.Devices)
+	assert.Equal(t, map[string]string{"site": "lab2"}, group.Labels)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/lab", `{"devices":["device-404"]}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/pilot", `{"devices":["device-001"]}`).Code)
+
+	w = send("GET", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Groups []DeviceGroup `json:"groups"`
+		Total  int           `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 1, list.Total)
+	assert.Equal(t, "lab", list.Groups[0].Name)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/lab", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/lab", "").Code)
+
+	_, err := service.groups.GetGroup(context.Background(), "lab")
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestService_ListDevices_Selector(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	selective := mock.MatchedBy(func(filters *DeviceFilters) bool {
+		return filters.Group == "lab" && filters.Labels["env"] == "prod" && filters.Labels["site"] == "lab1"
+	})
+	mockRepo.On("ListDevices", mock.Anything, selective).Return([]*Device{createTestDevice("device-001")}, nil)
+	mockRepo.On("GetDeviceCount", mock.Anything, selective).Return(int64(1), nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices?selector=env=prod,site=lab1&group=lab", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/api/v1/devices?selector=env", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertExpectations(t)
+}
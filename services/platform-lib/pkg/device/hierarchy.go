@@ -0,0 +1,195 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MaxHierarchyDepth bounds how many gateways a device may sit behind. It
+// also stops walks over hierarchies corrupted by concurrent updates.
+const MaxHierarchyDepth = 8
+
+var (
+	// ErrInvalidParent is returned when a device cannot be placed behind
+	// the requested gateway, such as one that would create a cycle
+	ErrInvalidParent = errors.New("invalid parent device")
+
+	// ErrDeviceHasChildren is returned when deleting a gateway that still
+	// has devices behind it
+	ErrDeviceHasChildren = errors.New("device has child devices")
+)
+
+// DeviceHierarchy describes where a device sits in a gateway topology.
+// Ancestors start with the direct parent and end with the root gateway.
+type DeviceHierarchy struct {
+	DeviceID  string           `json:"device_id"`
+	Ancestors []string         `json:"ancestors"`
+	Children  []*HierarchyNode `json:"children"`
+}
+
+// HierarchyNode is a device behind a gateway along with its own children
+type HierarchyNode struct {
+	DeviceID  string           `json:"device_id"`
+	Name      string           `json:"name,omitempty"`
+	BoardType string           `json:"board_type"`
+	Status    DeviceStatus     `json:"status"`
+	Children  []*HierarchyNode `json:"children,omitempty"`
+}
+
+// SetParentRequest places a device behind a gateway. An empty parent_id
+// detaches the device.
+type SetParentRequest struct {
+	ParentID string `json:"parent_id"`
+}
+
+// validateParent checks that deviceID may be placed behind parentID: the
+// parent must exist and must not be the device itself or behind it
+func (s *Service) validateParent(ctx context.Context, deviceID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if parentID == deviceID {
+		return fmt.Errorf("%w: a device cannot be its own parent", ErrInvalidParent)
+	}
+
+	depth := 1
+	for id := parentID; id != ""; depth++ {
+		if depth > MaxHierarchyDepth {
+			return fmt.Errorf("%w: hierarchy is deeper than %d levels", ErrInvalidParent, MaxHierarchyDepth)
+		}
+		parent, err := s.repository.GetDevice(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%w: parent %s not found: %v", ErrInvalidParent, id, err)
+		}
+		if parent.ParentID == deviceID {
+			return fmt.Errorf("%w: %s is behind %s", ErrInvalidParent, parentID, deviceID)
+		}
+		id = parent.ParentID
+	}
+
+	// The device's own children sit below the new parent too
+	height, err := s.subtreeHeight(ctx, deviceID, MaxHierarchyDepth-depth+2)
+	if err != nil {
+		return err
+	}
+	if depth+height > MaxHierarchyDepth+1 {
+		return fmt.Errorf("%w: hierarchy would be deeper than %d levels", ErrInvalidParent, MaxHierarchyDepth)
+	}
+	return nil
+}
+
+// subtreeHeight returns how many levels of children are below deviceID,
+// looking no further than limit levels
+func (s *Service) subtreeHeight(ctx context.Context, deviceID string, limit int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	children, err := s.ListChildren(ctx, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	height := 0
+	for _, child := range children {
+		below, err := s.subtreeHeight(ctx, child.DeviceID, limit-1)
+		if err != nil {
+			return 0, err
+		}
+		if below+1 > height {
+			height = below + 1
+		}
+	}
+	return height, nil
+}
+
+// SetParent places a device behind a gateway, or detaches it when
+// parentID is empty
+func (s *Service) SetParent(ctx context.Context, deviceID, parentID string) (*Device, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	if device.ParentID == parentID {
+		return device, nil
+	}
+	if err := s.validateParent(ctx, deviceID, parentID); err != nil {
+		return nil, err
+	}
+
+	device.ParentID = parentID
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+	return device, nil
+}
+
+// ListChildren returns the devices directly behind a gateway
+func (s *Service) ListChildren(ctx context.Context, deviceID string) ([]*Device, error) {
+	children, err := s.repository.ListDevices(ctx, &DeviceFilters{ParentID: deviceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list child devices: %w", err)
+	}
+	return children, nil
+}
+
+// GetHierarchy returns the gateways a device sits behind and the tree of
+// devices behind it
+func (s *Service) GetHierarchy(ctx context.Context, deviceID string) (*DeviceHierarchy, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+
+	hierarchy := &DeviceHierarchy{DeviceID: deviceID, Ancestors: []string{}}
+	for id := device.ParentID; id != "" && len(hierarchy.Ancestors) < MaxHierarchyDepth; {
+		hierarchy.Ancestors = append(hierarchy.Ancestors, id)
+		parent, err := s.repository.GetDevice(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent device %s: %w", id, err)
+		}
+		id = parent.ParentID
+	}
+
+	hierarchy.Children, err = s.childTree(ctx, deviceID, MaxHierarchyDepth)
+	if err != nil {
+		return nil, err
+	}
+	return hierarchy, nil
+}
+
+func (s *Service) childTree(ctx context.Context, deviceID string, limit int) ([]*HierarchyNode, error) {
+	nodes := []*HierarchyNode{}
+	if limit <= 0 {
+		return nodes, nil
+	}
+	children, err := s.ListChildren(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		node := &HierarchyNode{
+			DeviceID:  child.DeviceID,
+			Name:      child.Name,
+			BoardType: child.BoardType,
+			Status:    child.Status,
+		}
+		if node.Children, err = s.childTree(ctx, child.DeviceID, limit-1); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// checkNoChildren returns ErrDeviceHasChildren when devices sit behind
+// deviceID, so deleting a gateway does not leave them orphaned
+func (s *Service) checkNoChildren(ctx context.Context, deviceID string) error {
+	children, err := s.repository.ListDevices(ctx, &DeviceFilters{ParentID: deviceID, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list child devices: %w", err)
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("%w: detach or delete them first", ErrDeviceHasChildren)
+	}
+	return nil
+}
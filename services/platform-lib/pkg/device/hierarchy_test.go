@@ -0,0 +1,133 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTopology sets up gateway-1 with sensor-1 and sensor-2 behind it and
+// sensor-1a behind sensor-1
+func mockTopology(mockRepo *MockRepository) {
+	devices := map[string]*Device{
+		"gateway-1": {DeviceID: "gateway-1", BoardType: "rpi4", Status: DeviceStatusOnline},
+		"sensor-1":  {DeviceID: "sensor-1", BoardType: "esp32", Status: DeviceStatusOnline, ParentID: "gateway-1"},
+		"sensor-2":  {DeviceID: "sensor-2", BoardType: "esp32", Status: DeviceStatusOffline, ParentID: "gateway-1"},
+		"sensor-1a": {DeviceID: "sensor-1a", BoardType: "nano", Status: DeviceStatusOnline, ParentID: "sensor-1"},
+	}
+	for id, device := range devices {
+		mockRepo.On("GetDevice", mock.Anything, id).Return(device, nil).Maybe()
+	}
+	mockRepo.On("GetDevice", mock.Anything, mock.Anything).Return(nil, errors.New("device not found")).Maybe()
+	for parentID := range devices {
+		children := []*Device{}
+		for _, device := range devices {
+			if device.ParentID == parentID {
+				children = append(children, device)
+			}
+		}
+		mockRepo.On("ListDevices", mock.Anything, mock.MatchedBy(func(filters *DeviceFilters) bool {
+			return filters.ParentID == parentID
+		})).Return(children, nil).Maybe()
+	}
+	mockRepo.On("ListDevices", mock.Anything, mock.Anything).Return([]*Device{}, nil).Maybe()
+}
+
+func TestService_ValidateParent(t *testing.T) {
+	service, mockRepo := setupTestService()
+	mockTopology(mockRepo)
+	ctx := context.Background()
+
+	assert.NoError(t, service.validateParent(ctx, "new-sensor", ""))
+	assert.NoError(t, service.validateParent(ctx, "new-sensor", "sensor-1a"))
+	assert.NoError(t, service.validateParent(ctx, "sensor-2", "sensor-1"))
+
+	tests := []struct {
+		deviceID string
+		parentID string
+	}{
+		{"sensor-1", "sensor-1"},
+		{"gateway-1", "sensor-1a"},
+		{"sensor-1", "sensor-1a"},
+		{"sensor-2", "missing"},
+	}
+	for _, tt := range tests {
+		err := service.validateParent(ctx, tt.deviceID, tt.parentID)
+		assert.ErrorIs(t, err, ErrInvalidParent, "%s behind %s", tt.deviceID, tt.parentID)
+	}
+}
+
+func TestService_GetHierarchy(t *testing.T) {
+	service, mockRepo := setupTestService()
+	mockTopology(mockRepo)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/sensor-1/hierarchy", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var hierarchy DeviceHierarchy
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hierarchy))
+	assert.Equal(t, []string{"gateway-1"}, hierarchy.Ancestors)
+	require.Len(t, hierarchy.Children, 1)
+	assert.Equal(t, "sensor-1a", hierarchy.Children[0].DeviceID)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/devices/gateway-1/children", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Children []Device `json:"children"`
+		Count    int      `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Count)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/devices/missing/hierarchy", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestService_SetParent(t *testing.T) {
+	service, mockRepo := setupTestService()
+	mockTopology(mockRepo)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(d *Device) bool {
+		return d.DeviceID == "sensor-2" && d.ParentID == "sensor-1"
+	})).Return(nil).Once()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	setParent := func(deviceID, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/devices/"+deviceID+"/parent", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, setParent("sensor-2", `{"parent_id": "sensor-1"}`))
+	assert.Equal(t, http.StatusBadRequest, setParent("gateway-1", `{"parent_id": "sensor-1a"}`))
+	assert.Equal(t, http.StatusNotFound, setParent("missing", `{"parent_id": "gateway-1"}`))
+	mockRepo.AssertExpectations(t)
+
+	// A gateway with children cannot be deleted
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/devices/gateway-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertNotCalled(t, "DeleteDevice", mock.Anything, "gateway-1")
+}
@@ -0,0 +1,52 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/ids"
+)
+
+// ErrDeviceNameTaken is returned when registering a device under the name
+// of another device
+var ErrDeviceNameTaken = errors.New("device name is already taken")
+
+// assignIdentity gives a device being registered an ID and a human-friendly
+// name when it has none, and checks a requested name is free
+func (s *Service) assignIdentity(ctx context.Context, device *Device) error {
+	if device.DeviceID == "" {
+		id, err := s.ids.NewID(ctx, ids.KindDevice, s.repository.DeviceExists)
+		if err != nil {
+			return fmt.Errorf("failed to generate device ID: %w", err)
+		}
+		device.DeviceID = id
+	}
+
+	if device.Name != "" {
+		taken, err := s.deviceNameTaken(ctx, device.Name)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("%w: %s", ErrDeviceNameTaken, device.Name)
+		}
+		return nil
+	}
+
+	name, err := s.ids.NewName(ctx, s.deviceNameTaken)
+	if err != nil {
+		return fmt.Errorf("failed to generate device name: %w", err)
+	}
+	device.Name = name
+	return nil
+}
+
+// deviceNameTaken reports whether a device already has name
+func (s *Service) deviceNameTaken(ctx context.Context, name string) (bool, error) {
+	count, err := s.repository.GetDeviceCount(ctx, &DeviceFilters{Name: name})
+	if err != nil {
+		return false, fmt.Errorf("failed to check device name: %w", err)
+	}
+	return count > 0, nil
+}
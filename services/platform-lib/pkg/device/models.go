@@ -15,9 +15,13 @@ const (
 	DeviceStatusError       DeviceStatus = "error"
 )
 
-// Device represents an Arduino device in the registry
+// Device represents an Arduino device in the registry. FlashSize and
+// OTAFreeSpace are the device's flash and the free space of its OTA
+// partition in bytes, zero until it reports them. OTAEnrollment is
+// OTAEnrollmentAuto or OTAEnrollmentManual.
 type Device struct {
 	DeviceID        string                 `json:"device_id"`
+	Name            string                 `json:"name,omitempty"`
 	BoardType       string                 `json:"board_type"`
 	Status          DeviceStatus           `json:"status"`
 	TemplateID      string                 `json:"template_id"`
@@ -27,6 +31,13 @@ type Device struct {
 	FirmwareHash    string                 `json:"firmware_hash"`
 	LastSeen        time.Time              `json:"last_seen"`
 	OTAChannel      string                 `json:"ota_channel"`
+	OTAEnrollment   string                 `json:"ota_enrollment,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	Reported        map[string]interface{} `json:"reported,omitempty"`
+	Credentials     map[string]*Credential `json:"credentials,omitempty"`
+	FlashSize       int64                  `json:"flash_size,omitempty"`
+	OTAFreeSpace    int64                  `json:"ota_free_space,omitempty"`
 	CreatedAt       time.Time              `json:"created_at"`
 	UpdatedAt       time.Time              `json:"updated_at"`
 }
@@ -34,6 +45,7 @@ type Device struct {
 // DeviceEntity represents the Datastore entity for devices
 type DeviceEntity struct {
 	DeviceID        string    `datastore:"device_id"`
+	Name            string    `datastore:"name"`
 	BoardType       string    `datastore:"board_type"`
 	Status          string    `datastore:"status"`
 	TemplateID      string    `datastore:"template_id"`
@@ -43,25 +55,42 @@ type DeviceEntity struct {
 	FirmwareHash    string    `datastore:"firmware_hash"`
 	LastSeen        time.Time `datastore:"last_seen"`
 	OTAChannel      string    `datastore:"ota_channel"`
+	OTAEnrollment   string    `datastore:"ota_enrollment,noindex"`
+	LabelsJSON      string    `datastore:"labels_json,noindex"`
+	ParentID        string    `datastore:"parent_id"`
+	ReportedJSON    string    `datastore:"reported_json,noindex"`
+	CredentialsJSON string    `datastore:"credentials_json,noindex"`
+	FlashSize       int64     `datastore:"flash_size,noindex"`
+	OTAFreeSpace    int64     `datastore:"ota_free_space,noindex"`
 	CreatedAt       time.Time `datastore:"created_at"`
 	UpdatedAt       time.Time `datastore:"updated_at"`
 }
 
-// DeviceFilters represents filters for device queries
+// DeviceFilters represents filters for device queries. Labels selects
+// devices carrying every label, and Group the devices of a managed device
+// group.
 type DeviceFilters struct {
-	Status         DeviceStatus `json:"status,omitempty"`
-	BoardType      string       `json:"board_type,omitempty"`
-	TemplateID     string       `json:"template_id,omitempty"`
-	OTAChannel     string       `json:"ota_channel,omitempty"`
-	LastSeenBefore *time.Time   `json:"last_seen_before,omitempty"`
-	LastSeenAfter  *time.Time   `json:"last_seen_after,omitempty"`
-	Limit          int          `json:"limit,omitempty"`
-	Offset         int          `json:"offset,omitempty"`
+	Name            string            `json:"name,omitempty"`
+	Status          DeviceStatus      `json:"status,omitempty"`
+	BoardType       string            `json:"board_type,omitempty"`
+	TemplateID      string            `json:"template_id,omitempty"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	OTAChannel      string            `json:"ota_channel,omitempty"`
+	ParentID        string            `json:"parent_id,omitempty"`
+	LastSeenBefore  *time.Time        `json:"last_seen_before,omitempty"`
+	LastSeenAfter   *time.Time        `json:"last_seen_after,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Group           string            `json:"group,omitempty"`
+	Limit           int               `json:"limit,omitempty"`
+	Offset          int               `json:"offset,omitempty"`
 }
 
-// DeviceRegistrationRequest represents a request to register a new device
+// DeviceRegistrationRequest represents a request to register a new device.
+// A device registered without an ID or name is given generated ones, and
+// one without an OTA channel or enrollment policy those of its template.
 type DeviceRegistrationRequest struct {
-	DeviceID        string                 `json:"device_id" binding:"required"`
+	DeviceID        string                 `json:"device_id"`
+	Name            string                 `json:"name,omitempty"`
 	BoardType       string                 `json:"board_type" binding:"required"`
 	TemplateID      string                 `json:"template_id" binding:"required"`
 	TemplateVersion string                 `json:"template_version" binding:"required"`
@@ -69,6 +98,11 @@ type DeviceRegistrationRequest struct {
 	SecretsRef      string                 `json:"secrets_ref,omitempty"`
 	FirmwareHash    string                 `json:"firmware_hash" binding:"required"`
 	OTAChannel      string                 `json:"ota_channel,omitempty"`
+	OTAEnrollment   string                 `json:"ota_enrollment,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+	ParentID        string                 `json:"parent_id,omitempty"`
+	FlashSize       int64                  `json:"flash_size,omitempty"`
+	OTAFreeSpace    int64                  `json:"ota_free_space,omitempty"`
 }
 
 // DeviceStatusUpdate represents a device status update
@@ -83,6 +117,26 @@ type DeviceHeartbeat struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Status    DeviceStatus           `json:"status"`
 	Metrics   map[string]interface{} `json:"metrics,omitempty"`
+	// Credentials holds the version of each credential the device is using.
+	// Devices that send it are handed rotated credentials in the response.
+	Credentials map[string]int `json:"credentials,omitempty"`
+	// FlashSize and OTAFreeSpace update the device's storage capacity in
+	// bytes when reported
+	FlashSize    int64 `json:"flash_size,omitempty"`
+	OTAFreeSpace int64 `json:"ota_free_space,omitempty"`
+}
+
+// OnboardingReport is sent by firmware the first time it joins a network
+// chosen during self-onboarding. It names the network but never carries the
+// passphrase.
+type OnboardingReport struct {
+	Method     string `json:"method" binding:"required"`
+	SSID       string `json:"ssid" binding:"required,max=32"`
+	BSSID      string `json:"bssid,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	RSSI       int    `json:"rssi,omitempty"`
+	MAC        string `json:"mac,omitempty"`
+	PortalSSID string `json:"portal_ssid,omitempty"`
 }
 
 // DeviceListResponse represents the response for device listing
@@ -108,8 +162,28 @@ func (d *Device) ToEntity() (*DeviceEntity, error) {
 		return nil, err
 	}
 
+	var labelsJSON []byte
+	if len(d.Labels) > 0 {
+		if labelsJSON, err = json.Marshal(d.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	var reportedJSON []byte
+	if len(d.Reported) > 0 {
+		if reportedJSON, err = json.Marshal(d.Reported); err != nil {
+			return nil, err
+		}
+	}
+
+	credentialsJSON, err := marshalCredentials(d.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DeviceEntity{
 		DeviceID:        d.DeviceID,
+		Name:            d.Name,
 		BoardType:       d.BoardType,
 		Status:          string(d.Status),
 		TemplateID:      d.TemplateID,
@@ -119,6 +193,13 @@ func (d *Device) ToEntity() (*DeviceEntity, error) {
 		FirmwareHash:    d.FirmwareHash,
 		LastSeen:        d.LastSeen,
 		OTAChannel:      d.OTAChannel,
+		OTAEnrollment:   d.OTAEnrollment,
+		LabelsJSON:      string(labelsJSON),
+		ParentID:        d.ParentID,
+		ReportedJSON:    string(reportedJSON),
+		CredentialsJSON: credentialsJSON,
+		FlashSize:       d.FlashSize,
+		OTAFreeSpace:    d.OTAFreeSpace,
 		CreatedAt:       d.CreatedAt,
 		UpdatedAt:       d.UpdatedAt,
 	}, nil
@@ -133,8 +214,28 @@ func (de *DeviceEntity) FromEntity() (*Device, error) {
 		}
 	}
 
+	var labels map[string]string
+	if de.LabelsJSON != "" {
+		if err := json.Unmarshal([]byte(de.LabelsJSON), &labels); err != nil {
+			return nil, err
+		}
+	}
+
+	var reported map[string]interface{}
+	if de.ReportedJSON != "" {
+		if err := json.Unmarshal([]byte(de.ReportedJSON), &reported); err != nil {
+			return nil, err
+		}
+	}
+
+	credentials, err := unmarshalCredentials(de.CredentialsJSON)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Device{
 		DeviceID:        de.DeviceID,
+		Name:            de.Name,
 		BoardType:       de.BoardType,
 		Status:          DeviceStatus(de.Status),
 		TemplateID:      de.TemplateID,
@@ -144,6 +245,13 @@ func (de *DeviceEntity) FromEntity() (*Device, error) {
 		FirmwareHash:    de.FirmwareHash,
 		LastSeen:        de.LastSeen,
 		OTAChannel:      de.OTAChannel,
+		OTAEnrollment:   de.OTAEnrollment,
+		Labels:          labels,
+		ParentID:        de.ParentID,
+		Reported:        reported,
+		FlashSize:       de.FlashSize,
+		OTAFreeSpace:    de.OTAFreeSpace,
+		Credentials:     credentials,
 		CreatedAt:       de.CreatedAt,
 		UpdatedAt:       de.UpdatedAt,
 	}, nil
@@ -154,10 +262,21 @@ func (d *Device) IsOnline(timeout time.Duration) bool {
 	return time.Since(d.LastSeen) <= timeout && d.Status == DeviceStatusOnline
 }
 
+// MatchesLabels reports whether the device carries every label in selector
+func (d *Device) MatchesLabels(selector map[string]string) bool {
+	for key, value := range selector {
+		if current, ok := d.Labels[key]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
 // ToRegistrationRequest converts a Device to a DeviceRegistrationRequest
 func (d *Device) ToRegistrationRequest() *DeviceRegistrationRequest {
 	return &DeviceRegistrationRequest{
 		DeviceID:        d.DeviceID,
+		Name:            d.Name,
 		BoardType:       d.BoardType,
 		TemplateID:      d.TemplateID,
 		TemplateVersion: d.TemplateVersion,
@@ -165,6 +284,11 @@ func (d *Device) ToRegistrationRequest() *DeviceRegistrationRequest {
 		SecretsRef:      d.SecretsRef,
 		FirmwareHash:    d.FirmwareHash,
 		OTAChannel:      d.OTAChannel,
+		OTAEnrollment:   d.OTAEnrollment,
+		Labels:          d.Labels,
+		ParentID:        d.ParentID,
+		FlashSize:       d.FlashSize,
+		OTAFreeSpace:    d.OTAFreeSpace,
 	}
 }
 
@@ -176,9 +300,14 @@ func FromRegistrationRequest(req *DeviceRegistrationRequest) *Device {
 	if otaChannel == "" {
 		otaChannel = "stable"
 	}
+	enrollment := req.OTAEnrollment
+	if enrollment == "" {
+		enrollment = OTAEnrollmentAuto
+	}
 
 	return &Device{
 		DeviceID:        req.DeviceID,
+		Name:            req.Name,
 		BoardType:       req.BoardType,
 		Status:          DeviceStatusProvisioned,
 		TemplateID:      req.TemplateID,
@@ -188,6 +317,11 @@ func FromRegistrationRequest(req *DeviceRegistrationRequest) *Device {
 		FirmwareHash:    req.FirmwareHash,
 		LastSeen:        now,
 		OTAChannel:      otaChannel,
+		OTAEnrollment:   enrollment,
+		Labels:          req.Labels,
+		ParentID:        req.ParentID,
+		FlashSize:       req.FlashSize,
+		OTAFreeSpace:    req.OTAFreeSpace,
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
@@ -271,3 +271,38 @@ func TestDevice_ToEntity_InvalidParameters(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, entity)
 }
+
+func TestDevice_Labels(t *testing.T) {
+	device := &Device{
+		DeviceID: "test-device-001",
+		Labels:   map[string]string{"env": "prod", "site": "greenhouse"},
+	}
+
+	entity, err := device.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, device.Labels, restored.Labels)
+
+	assert.True(t, device.MatchesLabels(nil))
+	assert.True(t, device.MatchesLabels(map[string]string{"env": "prod"}))
+	assert.False(t, device.MatchesLabels(map[string]string{"env": "dev"}))
+	assert.False(t, device.MatchesLabels(map[string]string{"tier": ""}))
+}
+
+func TestDevice_Reported(t *testing.T) {
+	device := &Device{
+		DeviceID: "test-device-001",
+		Reported: map[string]interface{}{"firmware": "1.2.0", "interval": 30.0},
+	}
+
+	entity, err := device.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, device.Reported, restored.Reported)
+
+	entity, err = (&Device{DeviceID: "test-device-002"}).ToEntity()
+	require.NoError(t, err)
+	assert.Empty(t, entity.ReportedJSON)
+}
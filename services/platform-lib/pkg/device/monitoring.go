@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
 )
 
 // MonitoringServiceInterface defines the interface for device monitoring operations
@@ -33,6 +34,9 @@ type MonitoringService struct {
 	wg             sync.WaitGroup
 	mu             sync.RWMutex
 	isRunning      bool
+	publisher      notifications.Publisher
+	events         *EventRecorder
+	owners         *DeviceOwners
 }
 
 // MonitoringConfig holds configuration for the monitoring service
@@ -64,6 +68,28 @@ func NewMonitoringService(repository Repository, logger *logger.Logger, config *
 	}
 }
 
+// SetPublisher sets the publisher used to announce devices going offline
+func (m *MonitoringService) SetPublisher(publisher notifications.Publisher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publisher = publisher
+}
+
+// SetOwners sets how offline notifications are addressed to the owners of
+// their devices. Without it they only reach admins.
+func (m *MonitoringService) SetOwners(owners *DeviceOwners) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.owners = owners
+}
+
+// SetEventRecorder sets where status changes are added to device timelines
+func (m *MonitoringService) SetEventRecorder(events *EventRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = events
+}
+
 // Start begins the monitoring service
 func (m *MonitoringService) Start(ctx context.Context) error {
 	m.mu.Lock()
@@ -74,8 +100,7 @@ func (m *MonitoringService) Start(ctx context.Context) error {
 	}
 
 	m.isRunning = true
-	m.logger.Infof("Starting device monitoring service with offline timeout: %v, check interval: %v",
-		m.offlineTimeout, m.checkInterval)
+	m.logger.Info("Starting device monitoring service", "offline_timeout", m.offlineTimeout, "check_interval", m.checkInterval)
 
 	// Start the monitoring goroutine
 	m.wg.Add(1)
@@ -141,7 +166,7 @@ func (m *MonitoringService) checkDeviceStatus(ctx context.Context) {
 	cutoffTime := time.Now().Add(-m.offlineTimeout)
 	staleDevices, err := m.repository.GetDevicesLastSeenBefore(ctx, cutoffTime)
 	if err != nil {
-		m.logger.Errorf("Failed to get stale devices: %v", err)
+		m.logger.Error("Failed to get stale devices", "error", err)
 		return
 	}
 
@@ -152,32 +177,68 @@ func (m *MonitoringService) checkDeviceStatus(ctx context.Context) {
 		if device.Status == DeviceStatusOnline {
 			err := m.repository.UpdateDeviceStatus(ctx, device.DeviceID, DeviceStatusOffline, device.LastSeen)
 			if err != nil {
-				m.logger.Errorf("Failed to mark device %s as offline: %v", device.DeviceID, err)
+				m.logger.Error("Failed to mark device as offline", "device_id", device.DeviceID, "error", err)
 				continue
 			}
 			offlineCount++
-			m.logger.Infof("Device %s marked as offline (last seen: %v)", device.DeviceID, device.LastSeen)
+			m.logger.Info("Device marked as offline", "device_id", device.DeviceID, "last_seen", device.LastSeen)
+			m.notifyDeviceOffline(ctx, device)
+			m.eventRecorder().recordStatusChange(ctx, device.DeviceID, device.Status, DeviceStatusOffline)
 		}
 	}
 
 	if offlineCount > 0 {
-		m.logger.Infof("Marked %d devices as offline", offlineCount)
+		m.logger.Info("Marked devices as offline", "devices", offlineCount)
 	}
 
 	// Log health summary
 	m.logHealthSummary(ctx)
 }
 
+// notifyDeviceOffline publishes an offline event for a device to its owner
+func (m *MonitoringService) notifyDeviceOffline(ctx context.Context, device *Device) {
+	m.mu.RLock()
+	publisher := m.publisher
+	owners := m.owners
+	m.mu.RUnlock()
+	if publisher == nil {
+		return
+	}
+
+	notifications.PublishAsync(publisher, m.logger, &notifications.Event{
+		Type:         notifications.EventDeviceOffline,
+		ResourceType: "device",
+		ResourceID:   device.DeviceID,
+		Owner:        owners.Owner(ctx, device),
+		Message:      fmt.Sprintf("Device %s went offline (last seen %s)", device.DeviceID, device.LastSeen.Format(time.RFC3339)),
+		Data: map[string]interface{}{
+			"board_type":  device.BoardType,
+			"template_id": device.TemplateID,
+			"last_seen":   device.LastSeen,
+		},
+	})
+
+	offline := *device
+	offline.Status = DeviceStatusOffline
+	publishDeviceEvent(publisher, m.logger, notifications.EventDeviceStatusChanged, &offline)
+}
+
+func (m *MonitoringService) eventRecorder() *EventRecorder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.events
+}
+
 // logHealthSummary logs a summary of device health status
 func (m *MonitoringService) logHealthSummary(ctx context.Context) {
 	health, err := m.repository.GetDeviceHealthStatus(ctx)
 	if err != nil {
-		m.logger.Errorf("Failed to get device health status: %v", err)
+		m.logger.Error("Failed to get device health status", "error", err)
 		return
 	}
 
-	m.logger.Debugf("Device health summary - Total: %d, Online: %d, Offline: %d, Error: %d",
-		health.TotalDevices, health.OnlineDevices, health.OfflineDevices, health.ErrorDevices)
+	m.logger.Debug("Device health summary", "total", health.TotalDevices, "online", health.OnlineDevices,
+		"offline", health.OfflineDevices, "error", health.ErrorDevices)
 }
 
 // ProcessHeartbeat processes a device heartbeat and updates status
@@ -201,16 +262,64 @@ func (m *MonitoringService) ProcessHeartbeat(ctx context.Context, heartbeat *Dev
 		heartbeat.Status = DeviceStatusOnline
 	}
 
+	// Live device lists and timelines are told when a heartbeat changes the
+	// status, which needs the previous status; it is only read when either
+	// is set
+	m.mu.RLock()
+	publisher := m.publisher
+	events := m.events
+	m.mu.RUnlock()
+	var previous *Device
+	if publisher != nil || events != nil {
+		previous, _ = m.repository.GetDevice(ctx, heartbeat.DeviceID)
+	}
+
 	// Update device status
 	err := m.repository.UpdateDeviceStatus(ctx, heartbeat.DeviceID, heartbeat.Status, heartbeat.Timestamp)
 	if err != nil {
 		return fmt.Errorf("failed to update device status from heartbeat: %w", err)
 	}
 
-	m.logger.Debugf("Processed heartbeat from device %s with status %s", heartbeat.DeviceID, heartbeat.Status)
+	if previous != nil && previous.Status != heartbeat.Status {
+		updated := *previous
+		updated.Status = heartbeat.Status
+		updated.LastSeen = heartbeat.Timestamp
+		publishDeviceEvent(publisher, m.logger, notifications.EventDeviceStatusChanged, &updated)
+		events.recordStatusChange(ctx, heartbeat.DeviceID, previous.Status, heartbeat.Status)
+	}
+
+	if heartbeat.FlashSize > 0 || heartbeat.OTAFreeSpace > 0 {
+		if err := m.recordStorage(ctx, heartbeat); err != nil {
+			m.logger.Warn("Failed to record device storage", "device_id", heartbeat.DeviceID, "error", err)
+		}
+	}
+
+	m.logger.Debug("Processed heartbeat", "device_id", heartbeat.DeviceID, "status", heartbeat.Status)
 	return nil
 }
 
+// recordStorage stores the storage capacity a heartbeat reports. The device
+// is only written when the capacity changed.
+func (m *MonitoringService) recordStorage(ctx context.Context, heartbeat *DeviceHeartbeat) error {
+	device, err := m.repository.GetDevice(ctx, heartbeat.DeviceID)
+	if err != nil {
+		return err
+	}
+	changed := false
+	if heartbeat.FlashSize > 0 && heartbeat.FlashSize != device.FlashSize {
+		device.FlashSize = heartbeat.FlashSize
+		changed = true
+	}
+	if heartbeat.OTAFreeSpace > 0 && heartbeat.OTAFreeSpace != device.OTAFreeSpace {
+		device.OTAFreeSpace = heartbeat.OTAFreeSpace
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return m.repository.UpdateDevice(ctx, device)
+}
+
 // GetOfflineDevices returns devices that are considered offline
 func (m *MonitoringService) GetOfflineDevices(ctx context.Context) ([]*Device, error) {
 	return m.repository.GetOfflineDevices(ctx, m.offlineTimeout)
@@ -261,7 +370,7 @@ func (m *MonitoringService) SetOfflineTimeout(timeout time.Duration) {
 	defer m.mu.Unlock()
 
 	m.offlineTimeout = timeout
-	m.logger.Infof("Updated offline timeout to %v", timeout)
+	m.logger.Info("Updated offline timeout", "offline_timeout", timeout)
 }
 
 // SetCheckInterval updates the check interval configuration
@@ -270,7 +379,7 @@ func (m *MonitoringService) SetCheckInterval(interval time.Duration) {
 	defer m.mu.Unlock()
 
 	m.checkInterval = interval
-	m.logger.Infof("Updated check interval to %v", interval)
+	m.logger.Info("Updated check interval", "check_interval", interval)
 }
 
 // GetConfiguration returns the current monitoring configuration
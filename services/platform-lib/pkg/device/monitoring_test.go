@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/athena/platform-lib/pkg/template"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -368,3 +370,103 @@ func TestMonitoringService_DeviceNotFound(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestMonitoringService_ProcessHeartbeat_RecordsStorage(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewMonitoringService(mockRepo, logger.New("debug", "test"), nil)
+	ctx := context.Background()
+
+	dev := createTestDevice("device-001")
+	mockRepo.On("UpdateDeviceStatus", ctx, "device-001", DeviceStatusOnline, mock.AnythingOfType("time.Time")).Return(nil)
+	mockRepo.On("GetDevice", ctx, "device-001").Return(dev, nil)
+	mockRepo.On("UpdateDevice", ctx, dev).Return(nil).Once()
+
+	require.NoError(t, service.ProcessHeartbeat(ctx, &DeviceHeartbeat{DeviceID: "device-001", FlashSize: 4 << 20, OTAFreeSpace: 1 << 20}))
+	assert.Equal(t, int64(4<<20), dev.FlashSize)
+	assert.Equal(t, int64(1<<20), dev.OTAFreeSpace)
+
+	// Unchanged capacity is not written again
+	require.NoError(t, service.ProcessHeartbeat(ctx, &DeviceHeartbeat{DeviceID: "device-001", OTAFreeSpace: 1 << 20}))
+	mockRepo.AssertNumberOfCalls(t, "UpdateDevice", 1)
+
+	// Heartbeats without capacity do not load the device
+	require.NoError(t, service.ProcessHeartbeat(ctx, &DeviceHeartbeat{DeviceID: "device-001"}))
+	mockRepo.AssertNumberOfCalls(t, "GetDevice", 2)
+}
+
+func TestMonitoringService_ProcessHeartbeat_PublishesStatusChanges(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewMonitoringService(mockRepo, logger.New("debug", "test"), nil)
+	events := make(channelPublisher, 4)
+	service.SetPublisher(events)
+	ctx := context.Background()
+
+	offline := createTestDevice("device-001")
+	offline.Status = DeviceStatusOffline
+	mockRepo.On("GetDevice", ctx, "device-001").Return(offline, nil).Once()
+	mockRepo.On("UpdateDeviceStatus", ctx, "device-001", DeviceStatusOnline, mock.AnythingOfType("time.Time")).Return(nil)
+	require.NoError(t, service.ProcessHeartbeat(ctx, &DeviceHeartbeat{DeviceID: "device-001"}))
+
+	select {
+	case event := <-events:
+		assert.Equal(t, notifications.EventDeviceStatusChanged, event.Type)
+		assert.Equal(t, "device-001", event.ResourceID)
+		assert.Equal(t, "online", event.Data["status"])
+		assert.Equal(t, "stable", event.Data["ota_channel"])
+	case <-time.After(time.Second):
+		t.Fatal("no status change published")
+	}
+
+	// A heartbeat that keeps the status publishes nothing
+	mockRepo.On("GetDevice", ctx, "device-001").Return(createTestDevice("device-001"), nil).Once()
+	require.NoError(t, service.ProcessHeartbeat(ctx, &DeviceHeartbeat{DeviceID: "device-001"}))
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected %s event", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMonitoringService_NotifyDeviceOffline_TemplateOwner(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := NewMonitoringService(mockRepo, logger.New("debug", "test"), nil)
+	events := make(channelPublisher, 4)
+	service.SetPublisher(events)
+	ctx := context.Background()
+
+	offlineEvent := func() *notifications.Event {
+		t.Helper()
+		for {
+			select {
+			case event := <-events:
+				if event.Type == notifications.EventDeviceOffline {
+					return event
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no offline event published")
+			}
+		}
+	}
+
+	// Without owners the event is left for admins
+	service.notifyDeviceOffline(ctx, createTestDevice("device-001"))
+	assert.Empty(t, offlineEvent().Owner)
+
+	templates := template.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(ctx, &template.Template{
+		ID:      "sensor-template",
+		Version: "1.0.0",
+		Name:    "Sensor",
+		Owner:   "user-1",
+	}))
+	service.SetOwners(NewDeviceOwners(mockRepo, templates))
+
+	service.notifyDeviceOffline(ctx, createTestDevice("device-001"))
+	assert.Equal(t, "user-1", offlineEvent().Owner)
+
+	// Devices of unknown templates have no owner
+	orphan := createTestDevice("device-002")
+	orphan.TemplateID = "missing"
+	service.notifyDeviceOffline(ctx, orphan)
+	assert.Empty(t, offlineEvent().Owner)
+}
@@ -0,0 +1,78 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+// ErrInvalidOTAEnrollment is returned for unknown OTA enrollment policies
+var ErrInvalidOTAEnrollment = errors.New("invalid OTA enrollment")
+
+// OTA enrollment policies of devices
+const (
+	// OTAEnrollmentAuto enrolls a device in the deployments of its template
+	// and channel, the groups and labels it matches and catch-up
+	// deployments. Devices without a policy are enrolled automatically.
+	OTAEnrollmentAuto = "auto"
+	// OTAEnrollmentManual only updates a device in deployments that name it
+	OTAEnrollmentManual = "manual"
+)
+
+// TemplateDirectory looks up the templates devices are built from.
+// template.Repository satisfies it.
+type TemplateDirectory interface {
+	GetTemplate(ctx context.Context, id, version string) (*template.Template, error)
+}
+
+// SetTemplateDirectory gives registration the templates whose OTA defaults
+// apply to the devices registered from them. Offline notifications go to
+// the owners of the devices' templates.
+func (s *Service) SetTemplateDirectory(templates TemplateDirectory) {
+	s.templates = templates
+	if monitoring, ok := s.monitoring.(*MonitoringService); ok {
+		monitoring.SetOwners(NewDeviceOwners(s.repository, templates))
+	}
+}
+
+// AutoEnrolled reports whether the device joins deployments without being
+// named in them
+func (d *Device) AutoEnrolled() bool {
+	return d.OTAEnrollment != OTAEnrollmentManual
+}
+
+// validateOTAEnrollment checks an enrollment policy; empty means the
+// default
+func validateOTAEnrollment(enrollment string) error {
+	switch enrollment {
+	case "", OTAEnrollmentAuto, OTAEnrollmentManual:
+		return nil
+	}
+	return fmt.Errorf("%w: %q is not auto or manual", ErrInvalidOTAEnrollment, enrollment)
+}
+
+// applyTemplateOTADefaults fills in the OTA channel and enrollment policy a
+// registration leaves out from the OTA defaults of the device's template.
+// Registration goes ahead with the service defaults when the template
+// cannot be looked up.
+func (s *Service) applyTemplateOTADefaults(ctx context.Context, req *DeviceRegistrationRequest) {
+	if s.templates == nil || (req.OTAChannel != "" && req.OTAEnrollment != "") {
+		return
+	}
+	tmpl, err := s.templates.GetTemplate(ctx, req.TemplateID, req.TemplateVersion)
+	if err != nil {
+		s.logger.Warn("Failed to look up template OTA defaults", "template_id", req.TemplateID, "template_version", req.TemplateVersion, "error", err)
+		return
+	}
+	if tmpl.OTA == nil {
+		return
+	}
+	if req.OTAChannel == "" {
+		req.OTAChannel = tmpl.OTA.Channel
+	}
+	if req.OTAEnrollment == "" {
+		req.OTAEnrollment = tmpl.OTA.Enrollment
+	}
+}
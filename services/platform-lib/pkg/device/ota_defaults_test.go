@@ -0,0 +1,109 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_RegisterDevice_TemplateOTADefaults(t *testing.T) {
+	service, mockRepo := setupTestService()
+	templates := template.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(context.Background(), &template.Template{
+		ID:      "sensor-template",
+		Version: "1.0.0",
+		Name:    "Sensor",
+		OTA:     &template.OTADefaults{Channel: "beta", Enrollment: OTAEnrollmentManual},
+	}))
+	service.SetTemplateDirectory(templates)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	mockRepo.On("RegisterDevice", mock.Anything, mock.AnythingOfType("*device.Device")).Return(nil)
+
+	register := func(req *DeviceRegistrationRequest) (*httptest.ResponseRecorder, Device) {
+		reqBody, _ := json.Marshal(req)
+		httpReq, _ := http.NewRequest("POST", "/api/v1/devices", bytes.NewBuffer(reqBody))
+		httpReq.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httpReq)
+		var response Device
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, dev := register(&DeviceRegistrationRequest{
+		DeviceID:        "device-001",
+		BoardType:       "esp32",
+		TemplateID:      "sensor-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "beta", dev.OTAChannel)
+	assert.Equal(t, OTAEnrollmentManual, dev.OTAEnrollment)
+	assert.False(t, dev.AutoEnrolled())
+
+	// Settings given at registration win over the template's
+	w, dev = register(&DeviceRegistrationRequest{
+		DeviceID:        "device-002",
+		BoardType:       "esp32",
+		TemplateID:      "sensor-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123",
+		OTAChannel:      "stable",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "stable", dev.OTAChannel)
+	assert.Equal(t, OTAEnrollmentManual, dev.OTAEnrollment)
+
+	// Unknown templates fall back to the service defaults
+	w, dev = register(&DeviceRegistrationRequest{
+		DeviceID:        "device-003",
+		BoardType:       "esp32",
+		TemplateID:      "other-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "stable", dev.OTAChannel)
+	assert.Equal(t, OTAEnrollmentAuto, dev.OTAEnrollment)
+
+	w, _ = register(&DeviceRegistrationRequest{
+		DeviceID:        "device-004",
+		BoardType:       "esp32",
+		TemplateID:      "sensor-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123",
+		OTAEnrollment:   "sometimes",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNumberOfCalls(t, "RegisterDevice", 3)
+}
+
+func TestService_UpdateDevice_OTAEnrollment(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	dev := createTestDevice("test-device-001")
+	dev.OTAEnrollment = "sometimes"
+	reqBody, _ := json.Marshal(dev)
+	req, _ := http.NewRequest("PUT", "/api/v1/devices/test-device-001", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,53 @@
+package device
+
+import (
+	"context"
+)
+
+// DeviceGetter looks up registered devices. Repository satisfies it.
+type DeviceGetter interface {
+	GetDevice(ctx context.Context, deviceID string) (*Device, error)
+}
+
+// DeviceOwners resolves the user who owns a device, who is notified about
+// it, such as when it goes offline or fires an alert. A device is owned by
+// the owner of the template it is built from.
+type DeviceOwners struct {
+	devices   DeviceGetter
+	templates TemplateDirectory
+}
+
+// NewDeviceOwners creates a resolver looking devices up in devices and
+// their templates in templates
+func NewDeviceOwners(devices DeviceGetter, templates TemplateDirectory) *DeviceOwners {
+	return &DeviceOwners{
+		devices:   devices,
+		templates: templates,
+	}
+}
+
+// Owner returns the owner of a device, or "" when its template has no
+// owner or cannot be looked up
+func (o *DeviceOwners) Owner(ctx context.Context, device *Device) string {
+	if o == nil || device == nil || device.TemplateID == "" {
+		return ""
+	}
+	tmpl, err := o.templates.GetTemplate(ctx, device.TemplateID, device.TemplateVersion)
+	if err != nil {
+		return ""
+	}
+	return tmpl.Owner
+}
+
+// OwnerOf returns the owner of the device with the given ID, or "" when it
+// cannot be resolved
+func (o *DeviceOwners) OwnerOf(ctx context.Context, deviceID string) string {
+	if o == nil {
+		return ""
+	}
+	device, err := o.devices.GetDevice(ctx, deviceID)
+	if err != nil {
+		return ""
+	}
+	return o.Owner(ctx, device)
+}
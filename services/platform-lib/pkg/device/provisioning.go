@@ -0,0 +1,398 @@
+package device
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DeviceTokenCredential names the token issued to a device as it
+	// registers
+	DeviceTokenCredential = "device-token"
+	// DeviceCertificateCredential names the certificate issued to a device
+	// as it registers, and certificates signed without another name
+	DeviceCertificateCredential = "device-certificate"
+
+	// caLifetime is the validity of a generated device CA
+	caLifetime = 10 * 365 * 24 * time.Hour
+	// clockSkew backdates signed certificates for devices with slow clocks
+	clockSkew = 5 * time.Minute
+)
+
+// ErrNoCertificateAuthority is returned when a certificate is requested
+// from a service without a device CA
+var ErrNoCertificateAuthority = errors.New("no device certificate authority configured")
+
+// CertificateRequest asks for a device's certificate signing request to be
+// signed. The certificate is tracked as the credential Name, by default
+// device-certificate.
+type CertificateRequest struct {
+	CSR  string `json:"csr" binding:"required"`
+	Name string `json:"name,omitempty"`
+}
+
+// IssuedCredential is a credential the platform issued to a device. Token
+// and PrivateKey are only returned when the credential is issued.
+type IssuedCredential struct {
+	Name          string         `json:"name"`
+	Type          CredentialType `json:"type"`
+	Version       int            `json:"version"`
+	Fingerprint   string         `json:"fingerprint"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	Token         string         `json:"token,omitempty"`
+	Certificate   string         `json:"certificate,omitempty"`
+	PrivateKey    string         `json:"private_key,omitempty"`
+	CACertificate string         `json:"ca_certificate,omitempty"`
+}
+
+// CertificateAuthority signs device client certificates
+type CertificateAuthority struct {
+	certificate    *x509.Certificate
+	certificatePEM string
+	key            crypto.Signer
+}
+
+// NewCertificateAuthority creates a CA from a PEM certificate and its PEM
+// private key
+func NewCertificateAuthority(certificatePEM, keyPEM []byte) (*CertificateAuthority, error) {
+	certificate, err := parseCertificate(string(certificatePEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	if !certificate.IsCA {
+		return nil, fmt.Errorf("the device CA certificate is not a CA certificate")
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode CA key PEM")
+	}
+	var parsed interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA key type %T", parsed)
+	}
+	public, ok := certificate.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(key.Public()) {
+		return nil, fmt.Errorf("CA key does not match CA certificate")
+	}
+
+	return &CertificateAuthority{
+		certificate:    certificate,
+		certificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})),
+		key:            key,
+	}, nil
+}
+
+// GenerateCertificateAuthority creates a self-signed ECDSA P-256 CA
+func GenerateCertificateAuthority() (*CertificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Athena Device CA"},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(caLifetime),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal CA key: %w", err)
+	}
+
+	return NewCertificateAuthority(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	)
+}
+
+// NewCertificateAuthorityFromConfig creates the device CA from the
+// credentials section of the configuration. Without a configured CA one is
+// generated, whose certificates stop chaining to a trusted CA once the
+// service restarts; in production none is, and nil is returned instead.
+func NewCertificateAuthorityFromConfig(cfg *config.Config, log *logger.Logger) (*CertificateAuthority, error) {
+	certificatePEM, err := readCAFile(cfg.Credentials.CACertificate, cfg.Credentials.CACertificateFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := readCAFile(cfg.Credentials.CAKey, cfg.Credentials.CAKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case certificatePEM == nil && keyPEM == nil:
+		if cfg.Environment == "production" {
+			log.Warn("No device CA configured, device certificates cannot be signed")
+			return nil, nil
+		}
+		log.Warn("No device CA configured, generated one for this run")
+		return GenerateCertificateAuthority()
+	case certificatePEM == nil || keyPEM == nil:
+		return nil, fmt.Errorf("the device CA certificate and key must be configured together")
+	}
+	return NewCertificateAuthority(certificatePEM, keyPEM)
+}
+
+// readCAFile returns PEM data given inline or read from file, or nil when
+// neither is set
+func readCAFile(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device CA: %w", err)
+	}
+	return data, nil
+}
+
+// CertificatePEM returns the PEM certificate of the CA, which brokers and
+// services verifying device certificates trust
+func (a *CertificateAuthority) CertificatePEM() string {
+	return a.certificatePEM
+}
+
+// Sign issues a client certificate for deviceID's public key. It is valid
+// for lifetime, but never beyond the CA certificate.
+func (a *CertificateAuthority) Sign(deviceID string, publicKey crypto.PublicKey, lifetime time.Duration) (*x509.Certificate, error) {
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(lifetime)
+	if notAfter.After(a.certificate.NotAfter) {
+		notAfter = a.certificate.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: deviceID},
+		NotBefore:    now.Add(-clockSkew),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, publicKey, a.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign device certificate: %w", err)
+	}
+	return x509.ParseCertificate(der)
+}
+
+// serialNumber returns a random 128-bit certificate serial number
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+// SetCertificateAuthority sets the CA signing device certificates
+func (s *Service) SetCertificateAuthority(authority *CertificateAuthority) {
+	s.authority = authority
+}
+
+// provisionCredential issues the credential credentials.provisioning asks
+// for to a device being registered, and records it on the device. It
+// returns nil when provisioning is disabled.
+func (s *Service) provisionCredential(device *Device) (*IssuedCredential, error) {
+	settings := credentialSettings(s.config)
+
+	var issued *IssuedCredential
+	switch settings.Provisioning {
+	case "token":
+		token, err := generateToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate token: %w", err)
+		}
+		issued = &IssuedCredential{
+			Name:        DeviceTokenCredential,
+			Type:        CredentialTypeToken,
+			Fingerprint: fingerprint([]byte(token)),
+			ExpiresAt:   time.Now().Add(settings.TokenLifetime),
+			Token:       token,
+		}
+	case "certificate":
+		if s.authority == nil {
+			return nil, ErrNoCertificateAuthority
+		}
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate device key: %w", err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal device key: %w", err)
+		}
+		certificate, err := s.authority.Sign(device.DeviceID, &key.PublicKey, settings.CertificateLifetime)
+		if err != nil {
+			return nil, err
+		}
+		issued = s.certificateCredential(DeviceCertificateCredential, certificate)
+		issued.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	default:
+		return nil, nil
+	}
+
+	issued.Version = 1
+	if device.Credentials == nil {
+		device.Credentials = make(map[string]*Credential)
+	}
+	device.Credentials[issued.Name] = &Credential{
+		Type:        issued.Type,
+		Version:     issued.Version,
+		Fingerprint: issued.Fingerprint,
+		IssuedAt:    time.Now(),
+		ExpiresAt:   issued.ExpiresAt,
+	}
+	return issued, nil
+}
+
+// certificateCredential describes a certificate signed for a device
+func (s *Service) certificateCredential(name string, certificate *x509.Certificate) *IssuedCredential {
+	return &IssuedCredential{
+		Name:          name,
+		Type:          CredentialTypeCertificate,
+		Fingerprint:   fingerprint(certificate.Raw),
+		ExpiresAt:     certificate.NotAfter,
+		Certificate:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})),
+		CACertificate: s.authority.CertificatePEM(),
+	}
+}
+
+// parseCertificateRequest reads a PEM certificate signing request and
+// checks its signature
+func parseCertificateRequest(data string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("%w: no PEM certificate request found", ErrInvalidCredential)
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	return csr, nil
+}
+
+// SignCertificate signs a device's certificate signing request and tracks
+// the certificate as one of its credentials, replacing the one of the same
+// name. The CSR's common name, when set, must be the device ID.
+func (s *Service) SignCertificate(ctx context.Context, deviceID string, req *CertificateRequest) (*IssuedCredential, error) {
+	if s.authority == nil {
+		return nil, ErrNoCertificateAuthority
+	}
+	csr, err := parseCertificateRequest(req.CSR)
+	if err != nil {
+		return nil, err
+	}
+	if cn := csr.Subject.CommonName; cn != "" && cn != deviceID {
+		return nil, fmt.Errorf("%w: certificate request is for %s", ErrInvalidCredential, cn)
+	}
+	name := req.Name
+	if name == "" {
+		name = DeviceCertificateCredential
+	}
+
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	certificate, err := s.authority.Sign(deviceID, csr.PublicKey, credentialSettings(s.config).CertificateLifetime)
+	if err != nil {
+		return nil, err
+	}
+	issued := s.certificateCredential(name, certificate)
+
+	issued.Version = 1
+	if current, ok := device.Credentials[name]; ok {
+		issued.Version = current.Version + 1
+	}
+	if device.Credentials == nil {
+		device.Credentials = make(map[string]*Credential)
+	}
+	device.Credentials[name] = &Credential{
+		Type:        CredentialTypeCertificate,
+		Version:     issued.Version,
+		Fingerprint: issued.Fingerprint,
+		IssuedAt:    certificate.NotBefore,
+		ExpiresAt:   certificate.NotAfter,
+	}
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+	s.logger.Info("Signed device certificate", "credential", name, "device_id", deviceID, "serial", certificate.SerialNumber.String())
+	return issued, nil
+}
+
+// signCertificate signs a CSR a device generated, so its private key never
+// leaves it
+func (s *Service) signCertificate(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	var req CertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.logger.Error("Invalid certificate request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	issued, err := s.SignCertificate(c.Request.Context(), deviceID, &req)
+	if err != nil {
+		s.respondCredentialError(c, "Failed to sign certificate", err)
+		return
+	}
+	c.JSON(http.StatusCreated, issued)
+}
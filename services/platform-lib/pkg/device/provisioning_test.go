@@ -0,0 +1,196 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testCSR returns a PEM certificate signing request for commonName
+func testCSR(t *testing.T, commonName string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+// verifyIssuedCertificate checks an issued certificate chains to its CA as
+// a client certificate of deviceID
+func verifyIssuedCertificate(t *testing.T, issued *IssuedCredential, deviceID string) *x509.Certificate {
+	certificate, err := parseCertificate(issued.Certificate)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM([]byte(issued.CACertificate)))
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, certificate.Subject.CommonName)
+	assert.Equal(t, fingerprint(certificate.Raw), issued.Fingerprint)
+	return certificate
+}
+
+func TestService_RegisterDevice_ProvisionsCredential(t *testing.T) {
+	authority, err := GenerateCertificateAuthority()
+	require.NoError(t, err)
+
+	register := func(provisioning string) (*Device, *IssuedCredential) {
+		service, mockRepo := setupTestService()
+		service.config.Credentials = config.CredentialsConfig{Provisioning: provisioning}
+		service.SetCertificateAuthority(authority)
+		router := gin.New()
+		RegisterRoutes(router, service)
+
+		var registered *Device
+		mockRepo.On("RegisterDevice", mock.Anything, mock.AnythingOfType("*device.Device")).
+			Run(func(args mock.Arguments) { registered = args.Get(1).(*Device) }).Return(nil)
+
+		body := `{"device_id":"device-1","board_type":"esp32","template_id":"blink","template_version":"1.0.0","firmware_hash":"abc"}`
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/devices", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp struct {
+			Device
+			IssuedCredential *IssuedCredential `json:"issued_credential"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "device-1", resp.DeviceID)
+		return registered, resp.IssuedCredential
+	}
+
+	device, issued := register("")
+	require.NotNil(t, issued)
+	assert.Equal(t, DeviceTokenCredential, issued.Name)
+	assert.True(t, device.VerifyToken(issued.Token, time.Now()))
+	assert.Equal(t, issued.Fingerprint, device.Credentials[DeviceTokenCredential].Fingerprint)
+
+	device, issued = register("certificate")
+	require.NotNil(t, issued)
+	assert.Equal(t, DeviceCertificateCredential, issued.Name)
+	assert.NotEmpty(t, issued.PrivateKey)
+	certificate := verifyIssuedCertificate(t, issued, "device-1")
+	assert.True(t, device.VerifyCertificate(certificate, time.Now()))
+
+	device, issued = register("none")
+	assert.Nil(t, issued)
+	assert.Empty(t, device.Credentials)
+}
+
+func TestService_SignCertificate(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.config.Credentials = config.CredentialsConfig{RequireDeviceAuth: true}
+	mockMonitoring := &MockMonitoringService{}
+	service.monitoring = mockMonitoring
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	device := &Device{DeviceID: "device-1"}
+	mockRepo.On("GetDevice", mock.Anything, "device-1").Return(device, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, device).Return(nil)
+	mockMonitoring.On("ProcessHeartbeat", mock.Anything, mock.Anything).Return(nil)
+
+	send := func(path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/devices/device-1"+path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	csr, _ := json.Marshal(CertificateRequest{CSR: testCSR(t, "device-1")})
+
+	assert.Equal(t, http.StatusServiceUnavailable, send("/certificates", string(csr)).Code)
+
+	authority, err := GenerateCertificateAuthority()
+	require.NoError(t, err)
+	service.SetCertificateAuthority(authority)
+
+	w := send("/certificates", string(csr))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var issued IssuedCredential
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Empty(t, issued.PrivateKey)
+	assert.Equal(t, 1, issued.Version)
+	certificate := verifyIssuedCertificate(t, &issued, "device-1")
+
+	other, _ := json.Marshal(CertificateRequest{CSR: testCSR(t, "device-2")})
+	assert.Equal(t, http.StatusBadRequest, send("/certificates", string(other)).Code)
+	assert.Equal(t, http.StatusBadRequest, send("/certificates", `{"csr":"not a csr"}`).Code)
+
+	// Heartbeats authenticate with the certificate as TLS client certificate
+	heartbeat := func(peer *x509.Certificate) int {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/devices/device-1/heartbeat", bytes.NewBufferString(`{"device_id":"device-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if peer != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{peer}}
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, heartbeat(certificate))
+	assert.Equal(t, http.StatusUnauthorized, heartbeat(nil))
+
+	w = send("/credentials/"+DeviceCertificateCredential+"/revoke", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotNil(t, device.Credentials[DeviceCertificateCredential].RevokedAt)
+	assert.Equal(t, http.StatusUnauthorized, heartbeat(certificate))
+	assert.Equal(t, http.StatusOK, send("/credentials/"+DeviceCertificateCredential+"/revoke", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("/credentials/missing/revoke", "").Code)
+
+	// Revoked credentials are neither rotated nor reported as expiring
+	_, err = service.RotateCredential(context.Background(), "device-1", DeviceCertificateCredential, &CredentialRotationRequest{})
+	assert.ErrorIs(t, err, ErrInvalidCredential)
+	mockRepo.On("ListDevices", mock.Anything, &DeviceFilters{}).Return([]*Device{device}, nil)
+	expiring, err := service.ExpiringCredentials(context.Background(), 10*365*24*time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, expiring)
+
+	// Signing again replaces the revoked certificate
+	w = send("/certificates", string(csr))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Equal(t, 2, issued.Version)
+	assert.Nil(t, device.Credentials[DeviceCertificateCredential].RevokedAt)
+}
+
+func TestAuthenticateRequest_Gateway(t *testing.T) {
+	mockRepo := new(MockRepository)
+	sum := fingerprint([]byte("gateway-token"))
+	mockRepo.On("GetDevice", mock.Anything, "child").Return(&Device{DeviceID: "child", ParentID: "gateway"}, nil)
+	mockRepo.On("GetDevice", mock.Anything, "gateway").Return(&Device{
+		DeviceID: "gateway",
+		Credentials: map[string]*Credential{
+			DeviceTokenCredential: {Type: CredentialTypeToken, Fingerprint: sum, ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer gateway-token")
+	ok, err := AuthenticateRequest(context.Background(), mockRepo, "child", req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	req.Header.Set("Authorization", "Bearer other-token")
+	ok, err = AuthenticateRequest(context.Background(), mockRepo, "child", req)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -1,14 +1,18 @@
 package device
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/ids"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,19 +22,67 @@ type Service struct {
 	logger     *logger.Logger
 	repository Repository
 	monitoring MonitoringServiceInterface
+	history    DebugHistoryStore
+	httpClient *http.Client
+	publisher  notifications.Publisher
+	ids        *ids.Generator
+
+	availability      AvailabilityStore
+	twins             TwinStore
+	groups            GroupStore
+	commands          CommandStore
+	claimTokens       ClaimTokenStore
+	events            *EventRecorder
+	commandDispatcher CommandDispatcher
+	credentialMonitor *CredentialMonitor
+	authority         *CertificateAuthority
+	templates         TemplateDirectory
 }
 
 // NewService creates a new device service instance
 func NewService(cfg *config.Config, logger *logger.Logger, repository Repository) (*Service, error) {
+	idGenerator, err := ids.NewGeneratorFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize monitoring service
 	monitoringConfig := DefaultMonitoringConfig()
 	monitoring := NewMonitoringService(repository, logger, monitoringConfig)
+	publisher := notifications.NewPublisherFromConfig(cfg)
+	monitoring.SetPublisher(publisher)
+	events := NewEventRecorder(NewMemoryEventStore(), cfg.ServiceName, logger)
+	monitoring.SetEventRecorder(events)
+
+	credentialMonitor := NewCredentialMonitor(repository, logger, cfg)
+	credentialMonitor.SetPublisher(publisher)
+
+	authority, err := NewCertificateAuthorityFromConfig(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if authority == nil && credentialSettings(cfg).Provisioning == "certificate" {
+		return nil, fmt.Errorf("credentials.provisioning is certificate but no device CA is configured")
+	}
 
 	service := &Service{
 		config:     cfg,
 		logger:     logger,
 		repository: repository,
 		monitoring: monitoring,
+		history:    NewMemoryDebugHistoryStore(defaultHeartbeatHistory),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		publisher:  publisher,
+		ids:        idGenerator,
+
+		availability:      NewMemoryAvailabilityStore(),
+		twins:             NewMemoryTwinStore(),
+		groups:            NewMemoryGroupStore(),
+		commands:          NewMemoryCommandStore(),
+		claimTokens:       NewMemoryClaimTokenStore(),
+		events:            events,
+		credentialMonitor: credentialMonitor,
+		authority:         authority,
 	}
 
 	// Start monitoring service
@@ -38,6 +90,7 @@ func NewService(cfg *config.Config, logger *logger.Logger, repository Repository
 	if err := monitoring.Start(ctx); err != nil {
 		return nil, fmt.Errorf("failed to start monitoring service: %w", err)
 	}
+	credentialMonitor.Start(ctx)
 
 	return service, nil
 }
@@ -56,9 +109,47 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 		v1.PUT("/devices/:id", service.updateDevice)
 		v1.DELETE("/devices/:id", service.deleteDevice)
 
+		// Pre-provisioned registration: operators create single-use claim
+		// tokens, devices register themselves with one
+		v1.POST("/devices/claim", service.claimDevice)
+		v1.POST("/devices/claim-tokens", service.createClaimToken)
+		v1.GET("/devices/claim-tokens", service.listClaimTokens)
+		v1.GET("/devices/claim-tokens/:tokenId", service.getClaimToken)
+		v1.DELETE("/devices/claim-tokens/:tokenId", service.revokeClaimToken)
+
+		// Device timeline
+		v1.GET("/devices/:id/events", service.listDeviceEvents)
+
 		// Device status operations
 		v1.PUT("/devices/:id/status", service.updateDeviceStatus)
 		v1.POST("/devices/:id/heartbeat", service.deviceHeartbeat)
+		v1.POST("/devices/:id/onboarding", service.recordOnboarding)
+
+		// Device twin
+		v1.GET("/devices/:id/twin", service.getTwin)
+		v1.PATCH("/devices/:id/twin/desired", service.patchDesiredState)
+		v1.POST("/devices/:id/twin/reported", service.reportTwinState)
+		v1.GET("/devices/:id/twin/delta", service.getTwinDelta)
+
+		v1.POST("/devices/:id/commands", service.sendCommand)
+		v1.GET("/devices/:id/commands", service.listCommands)
+		v1.GET("/devices/:id/commands/pending", service.pollCommands)
+		v1.GET("/devices/:id/commands/:cmdId", service.getCommand)
+		v1.POST("/devices/:id/commands/:cmdId/ack", service.ackCommand)
+
+		// Device groups
+		v1.POST("/device-groups", service.createGroup)
+		v1.GET("/device-groups", service.listGroups)
+		v1.GET("/device-groups/:name", service.getGroup)
+		v1.PUT("/device-groups/:name", service.updateGroup)
+		v1.DELETE("/device-groups/:name", service.deleteGroup)
+		v1.POST("/device-groups/:name/members", service.addGroupMembers)
+		v1.DELETE("/device-groups/:name/members/:deviceId", service.removeGroupMember)
+
+		// Gateway hierarchy
+		v1.GET("/devices/:id/children", service.listChildren)
+		v1.PUT("/devices/:id/parent", service.setParent)
+		v1.GET("/devices/:id/hierarchy", service.getHierarchy)
 
 		// Device monitoring and health
 		v1.GET("/devices/health", service.getDeviceHealth)
@@ -66,6 +157,12 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 		v1.GET("/devices/offline", service.getOfflineDevices)
 		v1.GET("/devices/:id/uptime", service.getDeviceUptime)
 		v1.GET("/devices/:id/last-seen", service.getDeviceLastSeen)
+		v1.GET("/devices/:id/sla", service.getDeviceSLA)
+		v1.GET("/devices/sla", service.getGroupSLA)
+		v1.GET("/devices/:id/debug-bundle", service.getDeviceDebugBundle)
+		v1.POST("/devices/:id/debug-capture", service.startDebugCapture)
+		v1.DELETE("/devices/:id/debug-capture", service.stopDebugCapture)
+		v1.POST("/devices/:id/flash-result", service.recordFlashResult)
 		v1.GET("/monitoring/config", service.getMonitoringConfig)
 		v1.PUT("/monitoring/config", service.updateMonitoringConfig)
 
@@ -73,6 +170,15 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 		v1.GET("/devices/search", service.searchDevices)
 		v1.GET("/devices/template/:templateId", service.getDevicesByTemplate)
 		v1.GET("/devices/ota-channel/:channel", service.getDevicesByOTAChannel)
+
+		// Device credential expiry and rotation
+		v1.GET("/devices/credentials/expiring", service.getExpiringCredentials)
+		v1.GET("/devices/:id/credentials", service.listCredentials)
+		v1.PUT("/devices/:id/credentials/:name", service.trackCredential)
+		v1.DELETE("/devices/:id/credentials/:name", service.removeCredential)
+		v1.POST("/devices/:id/credentials/:name/rotate", service.rotateCredential)
+		v1.POST("/devices/:id/credentials/:name/revoke", service.revokeCredential)
+		v1.POST("/devices/:id/certificates", service.signCertificate)
 	}
 }
 
@@ -86,7 +192,7 @@ func (s *Service) healthCheck(c *gin.Context) {
 func (s *Service) registerDevice(c *gin.Context) {
 	var req DeviceRegistrationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		s.logger.Errorf("Invalid device registration request: %v", err)
+		s.logger.Error("Invalid device registration request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -94,28 +200,90 @@ func (s *Service) registerDevice(c *gin.Context) {
 		return
 	}
 
+	device, issued, ok := s.register(c, &req)
+	if !ok {
+		return
+	}
+
+	// The issued credential's secret is only ever returned here
+	c.JSON(http.StatusCreated, struct {
+		*Device
+		IssuedCredential *IssuedCredential `json:"issued_credential,omitempty"`
+	}{device, issued})
+}
+
+// register creates the device of a registration request with its
+// credential. Failures are responded to and reported by ok false.
+func (s *Service) register(c *gin.Context, req *DeviceRegistrationRequest) (device *Device, issued *IssuedCredential, ok bool) {
+	ctx := context.Background()
+	s.applyTemplateOTADefaults(ctx, req)
+	if err := validateOTAEnrollment(req.OTAEnrollment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+
 	// Create device from request
-	device := FromRegistrationRequest(&req)
+	device = FromRegistrationRequest(req)
+
+	if err := s.assignIdentity(ctx, device); err != nil {
+		s.logger.Error("Failed to assign device identity", "error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDeviceNameTaken) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to register device",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	if err := s.validateParent(ctx, device.DeviceID, device.ParentID); err != nil {
+		s.respondHierarchyError(c, "Failed to register device", err)
+		return nil, nil, false
+	}
+
+	issued, err := s.provisionCredential(device)
+	if err != nil {
+		s.logger.Error("Failed to provision device credential", "device_id", device.DeviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to register device",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
 
 	// Register device
-	ctx := context.Background()
 	if err := s.repository.RegisterDevice(ctx, device); err != nil {
-		s.logger.Errorf("Failed to register device %s: %v", req.DeviceID, err)
+		s.logger.Error("Failed to register device", "device_id", device.DeviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to register device",
 			"details": err.Error(),
 		})
-		return
+		return nil, nil, false
 	}
 
-	s.logger.Infof("Device %s registered successfully", device.DeviceID)
-	c.JSON(http.StatusCreated, device)
+	s.logger.Info("Device registered", "device_id", device.DeviceID)
+	publishDeviceEvent(s.publisher, s.logger, notifications.EventDeviceAdded, device)
+	s.events.Record(ctx, device.DeviceID, DeviceEventRegistered, fmt.Sprintf("Device %s was registered", device.DeviceID), map[string]interface{}{
+		"board_type":       device.BoardType,
+		"template_id":      device.TemplateID,
+		"template_version": device.TemplateVersion,
+	})
+
+	return device, issued, true
 }
 
 func (s *Service) listDevices(c *gin.Context) {
 	// Parse query parameters
 	filters := &DeviceFilters{}
 
+	if name := c.Query("name"); name != "" {
+		filters.Name = name
+	}
 	if status := c.Query("status"); status != "" {
 		filters.Status = DeviceStatus(status)
 	}
@@ -125,9 +293,29 @@ func (s *Service) listDevices(c *gin.Context) {
 	if templateID := c.Query("template_id"); templateID != "" {
 		filters.TemplateID = templateID
 	}
+	if templateVersion := c.Query("template_version"); templateVersion != "" {
+		filters.TemplateVersion = templateVersion
+	}
 	if otaChannel := c.Query("ota_channel"); otaChannel != "" {
 		filters.OTAChannel = otaChannel
 	}
+	if parentID := c.Query("parent_id"); parentID != "" {
+		filters.ParentID = parentID
+	}
+	if selector := c.Query("selector"); selector != "" {
+		labels, err := ParseLabelSelector(selector)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid label selector",
+				"details": err.Error(),
+			})
+			return
+		}
+		filters.Labels = labels
+	}
+	if group := c.Query("group"); group != "" {
+		filters.Group = group
+	}
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
 			filters.Limit = limit
@@ -148,8 +336,15 @@ func (s *Service) listDevices(c *gin.Context) {
 
 	// Get devices
 	devices, err := s.repository.ListDevices(ctx, filters)
+	if errors.Is(err, ErrGroupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device group not found",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		s.logger.Errorf("Failed to list devices: %v", err)
+		s.logger.Error("Failed to list devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to list devices",
 			"details": err.Error(),
@@ -160,7 +355,7 @@ func (s *Service) listDevices(c *gin.Context) {
 	// Get total count
 	total, err := s.repository.GetDeviceCount(ctx, filters)
 	if err != nil {
-		s.logger.Errorf("Failed to get device count: %v", err)
+		s.logger.Error("Failed to get device count", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device count",
 			"details": err.Error(),
@@ -196,7 +391,7 @@ func (s *Service) getDevice(c *gin.Context) {
 	ctx := context.Background()
 	device, err := s.repository.GetDevice(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "Device not found",
 			"details": err.Error(),
@@ -218,7 +413,7 @@ func (s *Service) updateDevice(c *gin.Context) {
 
 	var device Device
 	if err := c.ShouldBindJSON(&device); err != nil {
-		s.logger.Errorf("Invalid device update request: %v", err)
+		s.logger.Error("Invalid device update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -228,10 +423,26 @@ func (s *Service) updateDevice(c *gin.Context) {
 
 	// Ensure device ID matches URL parameter
 	device.DeviceID = deviceID
+	if err := validateOTAEnrollment(device.OTAEnrollment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
 
 	ctx := context.Background()
+	if err := s.validateParent(ctx, deviceID, device.ParentID); err != nil {
+		s.respondHierarchyError(c, "Failed to update device", err)
+		return
+	}
+	// The previous version is only read for the timeline
+	var previous *Device
+	if s.events.currentStore() != nil {
+		previous, _ = s.repository.GetDevice(ctx, deviceID)
+	}
 	if err := s.repository.UpdateDevice(ctx, &device); err != nil {
-		s.logger.Errorf("Failed to update device %s: %v", deviceID, err)
+		s.logger.Error("Failed to update device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update device",
 			"details": err.Error(),
@@ -239,7 +450,11 @@ func (s *Service) updateDevice(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s updated successfully", deviceID)
+	s.logger.Info("Device updated", "device_id", deviceID)
+	publishDeviceEvent(s.publisher, s.logger, notifications.EventDeviceUpdated, &device)
+	if previous != nil {
+		s.events.recordDeviceUpdate(ctx, previous, &device)
+	}
 	c.JSON(http.StatusOK, device)
 }
 
@@ -253,8 +468,12 @@ func (s *Service) deleteDevice(c *gin.Context) {
 	}
 
 	ctx := context.Background()
+	if err := s.checkNoChildren(ctx, deviceID); err != nil {
+		s.respondHierarchyError(c, "Failed to delete device", err)
+		return
+	}
 	if err := s.repository.DeleteDevice(ctx, deviceID); err != nil {
-		s.logger.Errorf("Failed to delete device %s: %v", deviceID, err)
+		s.logger.Error("Failed to delete device", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to delete device",
 			"details": err.Error(),
@@ -262,7 +481,19 @@ func (s *Service) deleteDevice(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s deleted successfully", deviceID)
+	if s.twins != nil {
+		if err := s.twins.DeleteTwin(ctx, deviceID); err != nil {
+			s.logger.Warn("Failed to delete device twin", "device_id", deviceID, "error", err)
+		}
+	}
+	if s.commands != nil {
+		if err := s.commands.DeleteCommands(ctx, deviceID); err != nil {
+			s.logger.Warn("Failed to delete device commands", "device_id", deviceID, "error", err)
+		}
+	}
+
+	s.logger.Info("Device deleted", "device_id", deviceID)
+	publishDeviceRemoved(s.publisher, s.logger, deviceID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Device deleted successfully",
 	})
@@ -279,7 +510,7 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 
 	var statusUpdate DeviceStatusUpdate
 	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
-		s.logger.Errorf("Invalid status update request: %v", err)
+		s.logger.Error("Invalid status update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -294,8 +525,12 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 	}
 
 	ctx := context.Background()
+	var previous *Device
+	if s.events.currentStore() != nil {
+		previous, _ = s.repository.GetDevice(ctx, deviceID)
+	}
 	if err := s.repository.UpdateDeviceStatus(ctx, deviceID, statusUpdate.Status, lastSeen); err != nil {
-		s.logger.Errorf("Failed to update device status for %s: %v", deviceID, err)
+		s.logger.Error("Failed to update device status", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update device status",
 			"details": err.Error(),
@@ -303,7 +538,15 @@ func (s *Service) updateDeviceStatus(c *gin.Context) {
 		return
 	}
 
-	s.logger.Infof("Device %s status updated to %s", deviceID, statusUpdate.Status)
+	s.logger.Info("Device status updated", "device_id", deviceID, "status", statusUpdate.Status)
+	if s.publisher != nil {
+		if device, err := s.repository.GetDevice(ctx, deviceID); err == nil {
+			publishDeviceEvent(s.publisher, s.logger, notifications.EventDeviceStatusChanged, device)
+		}
+	}
+	if previous != nil && previous.Status != statusUpdate.Status {
+		s.events.recordStatusChange(ctx, deviceID, previous.Status, statusUpdate.Status)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Device status updated successfully",
 	})
@@ -320,7 +563,7 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 
 	var heartbeat DeviceHeartbeat
 	if err := c.ShouldBindJSON(&heartbeat); err != nil {
-		s.logger.Errorf("Invalid heartbeat request: %v", err)
+		s.logger.Error("Invalid heartbeat request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -331,10 +574,24 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 	// Ensure device ID matches URL parameter
 	heartbeat.DeviceID = deviceID
 
-	// Use monitoring service to process heartbeat
 	ctx := context.Background()
+	if credentialSettings(s.config).RequireDeviceAuth {
+		ok, err := AuthenticateRequest(ctx, s.repository, deviceID, c.Request)
+		if err != nil {
+			s.logger.Warn("Failed to look up heartbeat device", "device_id", deviceID, "error", err)
+		}
+		// Failures are not told apart, so callers cannot probe for devices
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Device credentials required",
+			})
+			return
+		}
+	}
+
+	// Use monitoring service to process heartbeat
 	if err := s.monitoring.ProcessHeartbeat(ctx, &heartbeat); err != nil {
-		s.logger.Errorf("Failed to process heartbeat for device %s: %v", deviceID, err)
+		s.logger.Error("Failed to process heartbeat", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to process heartbeat",
 			"details": err.Error(),
@@ -342,9 +599,106 @@ func (s *Service) deviceHeartbeat(c *gin.Context) {
 		return
 	}
 
-	s.logger.Debugf("Heartbeat received from device %s", deviceID)
-	c.JSON(http.StatusOK, gin.H{
+	if err := s.history.RecordHeartbeat(ctx, heartbeat); err != nil {
+		s.logger.Error("Failed to record heartbeat history", "device_id", deviceID, "error", err)
+	}
+	if s.availability != nil {
+		if err := RecordAvailability(ctx, s.availability, &heartbeat, s.offlineTimeout()); err != nil {
+			s.logger.Error("Failed to record availability", "device_id", deviceID, "error", err)
+		}
+	}
+
+	response := gin.H{
 		"message": "Heartbeat processed successfully",
+	}
+
+	// Devices that report credential versions are handed rotated ones
+	if heartbeat.Credentials != nil {
+		deliveries, err := s.SyncCredentials(ctx, deviceID, heartbeat.Credentials)
+		if err != nil {
+			s.logger.Error("Failed to sync credentials", "device_id", deviceID, "error", err)
+		} else if len(deliveries) > 0 {
+			response["credentials"] = deliveries
+		}
+	}
+
+	s.logger.Debug("Heartbeat received", "device_id", deviceID)
+	c.JSON(http.StatusOK, response)
+}
+
+// recordOnboarding stores the network a self-onboarded device joined in its
+// reported state. The report is authenticated by the device's own token,
+// which the onboarding firmware sends.
+func (s *Service) recordOnboarding(c *gin.Context) {
+	deviceID := c.Param("id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Device ID is required",
+		})
+		return
+	}
+
+	var report OnboardingReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		s.logger.Error("Invalid onboarding report", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	ok, err := AuthenticateRequest(ctx, s.repository, deviceID, c.Request)
+	if err != nil {
+		s.logger.Warn("Failed to look up onboarding device", "device_id", deviceID, "error", err)
+	}
+	// Failures are not told apart, so callers cannot probe for devices
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Device credentials required",
+		})
+		return
+	}
+
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		s.logger.Error("Failed to get device", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if device.Reported == nil {
+		device.Reported = make(map[string]interface{})
+	}
+	device.Reported["network"] = map[string]interface{}{
+		"ssid":  report.SSID,
+		"bssid": report.BSSID,
+		"ip":    report.IP,
+		"rssi":  report.RSSI,
+		"mac":   report.MAC,
+	}
+	device.Reported["onboarding"] = map[string]interface{}{
+		"method":       report.Method,
+		"portal_ssid":  report.PortalSSID,
+		"onboarded_at": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := s.repository.UpdateDevice(ctx, device); err != nil {
+		s.logger.Error("Failed to record onboarding", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record onboarding",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	s.logger.Info("Device onboarded", "device_id", deviceID, "ssid", report.SSID, "method", report.Method)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Onboarding recorded",
 	})
 }
 
@@ -352,7 +706,7 @@ func (s *Service) getDeviceHealth(c *gin.Context) {
 	ctx := context.Background()
 	health, err := s.repository.GetDeviceHealthStatus(ctx)
 	if err != nil {
-		s.logger.Errorf("Failed to get device health status: %v", err)
+		s.logger.Error("Failed to get device health status", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device health status",
 			"details": err.Error(),
@@ -375,7 +729,7 @@ func (s *Service) getDevicesByStatus(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByStatus(ctx, status)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by status %s: %v", status, err)
+		s.logger.Error("Failed to get devices by status", "status", status, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by status",
 			"details": err.Error(),
@@ -411,7 +765,7 @@ func (s *Service) getOfflineDevices(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetOfflineDevices(ctx, timeout)
 	if err != nil {
-		s.logger.Errorf("Failed to get offline devices: %v", err)
+		s.logger.Error("Failed to get offline devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get offline devices",
 			"details": err.Error(),
@@ -463,7 +817,7 @@ func (s *Service) searchDevices(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.SearchDevices(ctx, query, filters)
 	if err != nil {
-		s.logger.Errorf("Failed to search devices: %v", err)
+		s.logger.Error("Failed to search devices", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to search devices",
 			"details": err.Error(),
@@ -496,7 +850,7 @@ func (s *Service) getDevicesByTemplate(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByTemplate(ctx, templateID)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by template %s: %v", templateID, err)
+		s.logger.Error("Failed to get devices by template", "template_id", templateID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by template",
 			"details": err.Error(),
@@ -529,7 +883,7 @@ func (s *Service) getDevicesByOTAChannel(c *gin.Context) {
 	ctx := context.Background()
 	devices, err := s.repository.GetDevicesByOTAChannel(ctx, channel)
 	if err != nil {
-		s.logger.Errorf("Failed to get devices by OTA channel %s: %v", channel, err)
+		s.logger.Error("Failed to get devices by OTA channel", "channel", channel, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get devices by OTA channel",
 			"details": err.Error(),
@@ -562,7 +916,7 @@ func (s *Service) getDeviceUptime(c *gin.Context) {
 	ctx := context.Background()
 	uptime, err := s.monitoring.GetDeviceUptime(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device uptime for %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device uptime", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device uptime",
 			"details": err.Error(),
@@ -589,7 +943,7 @@ func (s *Service) getDeviceLastSeen(c *gin.Context) {
 	ctx := context.Background()
 	lastSeenDuration, err := s.monitoring.GetDeviceLastSeenDuration(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to get device last seen duration for %s: %v", deviceID, err)
+		s.logger.Error("Failed to get device last seen duration", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get device last seen duration",
 			"details": err.Error(),
@@ -599,7 +953,7 @@ func (s *Service) getDeviceLastSeen(c *gin.Context) {
 
 	isOnline, err := s.monitoring.CheckDeviceOnlineStatus(ctx, deviceID)
 	if err != nil {
-		s.logger.Errorf("Failed to check device online status for %s: %v", deviceID, err)
+		s.logger.Error("Failed to check device online status", "device_id", deviceID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to check device online status",
 			"details": err.Error(),
@@ -615,6 +969,238 @@ func (s *Service) getDeviceLastSeen(c *gin.Context) {
 	})
 }
 
+func (s *Service) getDeviceSLA(c *gin.Context) {
+	deviceID := c.Param("id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Device ID is required",
+		})
+		return
+	}
+
+	format, from, to, ok := s.parseSLAQuery(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	report, err := s.DeviceSLA(ctx, deviceID, from, to)
+	if err != nil {
+		s.logger.Error("Failed to compute SLA", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "csv" {
+		s.writeSLACSV(c, fmt.Sprintf("%s-sla", deviceID), []*SLAReport{report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *Service) getGroupSLA(c *gin.Context) {
+	format, from, to, ok := s.parseSLAQuery(c)
+	if !ok {
+		return
+	}
+
+	filters := &DeviceFilters{
+		Status:          DeviceStatus(c.Query("status")),
+		BoardType:       c.Query("board_type"),
+		TemplateID:      c.Query("template_id"),
+		TemplateVersion: c.Query("template_version"),
+		OTAChannel:      c.Query("ota_channel"),
+	}
+
+	ctx := context.Background()
+	report, err := s.GroupSLA(ctx, filters, from, to)
+	if err != nil {
+		s.logger.Error("Failed to compute group SLA", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute SLA",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "csv" {
+		s.writeSLACSV(c, "devices-sla", report.Reports)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// parseSLAQuery reads the report format and window of an SLA request,
+// responding with an error if either is invalid
+func (s *Service) parseSLAQuery(c *gin.Context) (string, time.Time, time.Time, bool) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report format",
+			"details": "Supported formats are 'json' and 'csv'",
+		})
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	from, to, err := ParseSLAWindow(c.Query("window"), c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report window",
+			"details": err.Error(),
+		})
+		return "", time.Time{}, time.Time{}, false
+	}
+	return format, from, to, true
+}
+
+func (s *Service) writeSLACSV(c *gin.Context, filename string, reports []*SLAReport) {
+	var buf bytes.Buffer
+	if err := WriteSLACSV(&buf, reports); err != nil {
+		s.logger.Error("Failed to write SLA report", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to write SLA report",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+func (s *Service) getDeviceDebugBundle(c *gin.Context) {
+	deviceID := c.Param("id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Device ID is required",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid bundle format",
+			"details": "Supported formats are 'json' and 'zip'",
+		})
+		return
+	}
+
+	ctx := context.Background()
+	bundle, err := s.BuildDebugBundle(ctx, deviceID)
+	if err != nil {
+		s.logger.Error("Failed to build debug bundle", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-debug-bundle-%s", deviceID, bundle.GeneratedAt.UTC().Format("20060102T150405Z"))
+	if format == "zip" {
+		data, err := bundle.Zip()
+		if err != nil {
+			s.logger.Error("Failed to archive debug bundle", "device_id", deviceID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to archive debug bundle",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+		c.Data(http.StatusOK, "application/zip", data)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+	c.JSON(http.StatusOK, bundle)
+}
+
+func (s *Service) startDebugCapture(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	var req struct {
+		DurationSeconds int `json:"duration_seconds,omitempty"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	if req.DurationSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "duration_seconds must not be negative",
+		})
+		return
+	}
+
+	result, err := s.StartDebugCapture(c.Request.Context(), deviceID, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// A capture that no service started is of no use
+	if len(result.Captures) == 0 {
+		c.JSON(http.StatusBadGateway, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Service) stopDebugCapture(c *gin.Context) {
+	result := s.StopDebugCapture(c.Request.Context(), c.Param("id"))
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusBadGateway, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Service) recordFlashResult(c *gin.Context) {
+	deviceID := c.Param("id")
+	if deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Device ID is required",
+		})
+		return
+	}
+
+	var result FlashResult
+	if err := c.ShouldBindJSON(&result); err != nil {
+		s.logger.Error("Invalid flash result request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := s.RecordFlashResult(c.Request.Context(), deviceID, &result); err != nil {
+		s.logger.Error("Failed to record flash result", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to record flash result",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	s.logger.Info("Flash result recorded", "device_id", deviceID, "success", result.Success)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Flash result recorded successfully",
+	})
+}
+
 func (s *Service) getMonitoringConfig(c *gin.Context) {
 	config := s.monitoring.GetConfiguration()
 
@@ -632,7 +1218,7 @@ func (s *Service) updateMonitoringConfig(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&configUpdate); err != nil {
-		s.logger.Errorf("Invalid monitoring config update request: %v", err)
+		s.logger.Error("Invalid monitoring config update request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -668,7 +1254,7 @@ func (s *Service) updateMonitoringConfig(c *gin.Context) {
 
 	// Return updated configuration
 	config := s.monitoring.GetConfiguration()
-	s.logger.Infof("Monitoring configuration updated")
+	s.logger.Info("Monitoring configuration updated")
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Monitoring configuration updated successfully",
@@ -678,14 +1264,246 @@ func (s *Service) updateMonitoringConfig(c *gin.Context) {
 	})
 }
 
+func (s *Service) getExpiringCredentials(c *gin.Context) {
+	within := 30 * 24 * time.Hour
+	if withinStr := c.Query("within"); withinStr != "" {
+		parsed, err := time.ParseDuration(withinStr)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid within format",
+				"details": "Use duration format like '168h', '720h'",
+			})
+			return
+		}
+		within = parsed
+	}
+
+	ctx := context.Background()
+	credentials, err := s.ExpiringCredentials(ctx, within)
+	if err != nil {
+		s.logger.Error("Failed to report expiring credentials", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to report expiring credentials",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+		"count":       len(credentials),
+		"within":      within.String(),
+	})
+}
+
+func (s *Service) listCredentials(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	ctx := context.Background()
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		s.logger.Error("Failed to get device", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	credentials := device.Credentials
+	if credentials == nil {
+		credentials = map[string]*Credential{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"device_id":   deviceID,
+		"credentials": credentials,
+	})
+}
+
+func (s *Service) trackCredential(c *gin.Context) {
+	deviceID := c.Param("id")
+	name := c.Param("name")
+
+	var record CredentialRecord
+	if err := c.ShouldBindJSON(&record); err != nil {
+		s.logger.Error("Invalid credential record", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	credential, err := s.TrackCredential(ctx, deviceID, name, &record)
+	if err != nil {
+		s.respondCredentialError(c, "Failed to record credential", err)
+		return
+	}
+
+	s.logger.Info("Credential expiry recorded", "credential", name, "device_id", deviceID, "expires_at", credential.ExpiresAt.Format(time.RFC3339))
+	c.JSON(http.StatusOK, credential)
+}
+
+func (s *Service) removeCredential(c *gin.Context) {
+	deviceID := c.Param("id")
+	name := c.Param("name")
+
+	ctx := context.Background()
+	if err := s.RemoveCredential(ctx, deviceID, name); err != nil {
+		s.respondCredentialError(c, "Failed to remove credential", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Credential removed successfully",
+	})
+}
+
+// rotateCredential stages a new credential, delivered to the device with
+// its next heartbeat
+func (s *Service) rotateCredential(c *gin.Context) {
+	deviceID := c.Param("id")
+	name := c.Param("name")
+
+	var req CredentialRotationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			s.logger.Error("Invalid credential rotation request", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx := context.Background()
+	rotation, err := s.RotateCredential(ctx, deviceID, name, &req)
+	if err != nil {
+		s.respondCredentialError(c, "Failed to rotate credential", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, rotation)
+}
+
+// revokeCredential stops a device authenticating with a credential
+func (s *Service) revokeCredential(c *gin.Context) {
+	deviceID := c.Param("id")
+	name := c.Param("name")
+
+	credential, err := s.RevokeCredential(c.Request.Context(), deviceID, name)
+	if err != nil {
+		s.respondCredentialError(c, "Failed to revoke credential", err)
+		return
+	}
+	c.JSON(http.StatusOK, credential)
+}
+
+// respondCredentialError maps credential errors to HTTP responses
+func (s *Service) respondCredentialError(c *gin.Context, message string, err error) {
+	s.logger.Error(message, "error", err)
+	switch {
+	case errors.Is(err, ErrInvalidCredential):
+		c.JSON(http.StatusBadRequest, gin.H{"error": message, "details": err.Error()})
+	case errors.Is(err, errDeviceLookup):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found", "details": err.Error()})
+	case errors.Is(err, ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found", "details": err.Error()})
+	case errors.Is(err, ErrNoCertificateAuthority):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": message, "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+func (s *Service) listChildren(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	ctx := context.Background()
+	children, err := s.ListChildren(ctx, deviceID)
+	if err != nil {
+		s.respondHierarchyError(c, "Failed to list child devices", err)
+		return
+	}
+
+	deviceList := make([]Device, len(children))
+	for i, device := range children {
+		deviceList[i] = *device
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": deviceID,
+		"children":  deviceList,
+		"count":     len(deviceList),
+	})
+}
+
+// setParent places a device behind a gateway, or detaches it when
+// parent_id is empty
+func (s *Service) setParent(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	var req SetParentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.logger.Error("Invalid set parent request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	device, err := s.SetParent(ctx, deviceID, req.ParentID)
+	if err != nil {
+		s.respondHierarchyError(c, "Failed to set parent device", err)
+		return
+	}
+
+	s.logger.Info("Device parent set", "device_id", deviceID, "parent_id", req.ParentID)
+	publishDeviceEvent(s.publisher, s.logger, notifications.EventDeviceUpdated, device)
+	c.JSON(http.StatusOK, device)
+}
+
+func (s *Service) getHierarchy(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	ctx := context.Background()
+	hierarchy, err := s.GetHierarchy(ctx, deviceID)
+	if err != nil {
+		s.respondHierarchyError(c, "Failed to get device hierarchy", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hierarchy)
+}
+
+func (s *Service) respondHierarchyError(c *gin.Context, message string, err error) {
+	s.logger.Error(message, "error", err)
+	switch {
+	case errors.Is(err, ErrInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": message, "details": err.Error()})
+	case errors.Is(err, errDeviceLookup):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found", "details": err.Error()})
+	case errors.Is(err, ErrDeviceHasChildren):
+		c.JSON(http.StatusConflict, gin.H{"error": message, "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
 // Shutdown gracefully shuts down the device service
 func (s *Service) Shutdown() error {
 	s.logger.Info("Shutting down device service...")
 
 	if err := s.monitoring.Stop(); err != nil {
-		s.logger.Errorf("Failed to stop monitoring service: %v", err)
+		s.logger.Error("Failed to stop monitoring service", "error", err)
 		return err
 	}
+	if s.credentialMonitor != nil {
+		s.credentialMonitor.Stop()
+	}
 
 	s.logger.Info("Device service shutdown complete")
 	return nil
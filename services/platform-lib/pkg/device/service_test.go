@@ -1,15 +1,19 @@
 package device
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/debugcapture"
+	"github.com/athena/platform-lib/pkg/ids"
 	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -32,6 +36,8 @@ func setupTestService() (*Service, *MockRepository) {
 		config:     cfg,
 		logger:     logger,
 		repository: mockRepo,
+		history:    NewMemoryDebugHistoryStore(defaultHeartbeatHistory),
+		httpClient: http.DefaultClient,
 	}
 
 	return service, mockRepo
@@ -93,6 +99,53 @@ func TestService_RegisterDevice(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestService_RegisterDevice_GeneratedIdentity(t *testing.T) {
+	service, mockRepo := setupTestService()
+	generator, err := ids.NewGenerator(ids.SchemePrefixed, true)
+	require.NoError(t, err)
+	service.ids = generator
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetDeviceCount", mock.Anything, &DeviceFilters{Name: "taken-name"}).Return(int64(1), nil)
+	mockRepo.On("GetDeviceCount", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return(int64(0), nil)
+	mockRepo.On("RegisterDevice", mock.Anything, mock.AnythingOfType("*device.Device")).Return(nil)
+
+	register := func(req *DeviceRegistrationRequest) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(req)
+		httpReq, _ := http.NewRequest("POST", "/api/v1/devices", bytes.NewBuffer(reqBody))
+		httpReq.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	w := register(&DeviceRegistrationRequest{
+		BoardType:       "arduino-uno",
+		TemplateID:      "sensor-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123def456",
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var response Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Regexp(t, `^dev_[0-9a-z]{26}$`, response.DeviceID)
+	assert.Regexp(t, `^[a-z]+-[a-z]+$`, response.Name)
+
+	// A requested name must be free
+	w = register(&DeviceRegistrationRequest{
+		Name:            "taken-name",
+		BoardType:       "arduino-uno",
+		TemplateID:      "sensor-template",
+		TemplateVersion: "1.0.0",
+		FirmwareHash:    "abc123def456",
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertNumberOfCalls(t, "RegisterDevice", 1)
+}
+
 func TestService_RegisterDevice_InvalidRequest(t *testing.T) {
 	service, _ := setupTestService()
 
@@ -256,6 +309,7 @@ func TestService_DeleteDevice(t *testing.T) {
 	RegisterRoutes(router, service)
 
 	deviceID := "test-device-001"
+	mockRepo.On("ListDevices", mock.Anything, &DeviceFilters{ParentID: deviceID, Limit: 1}).Return([]*Device{}, nil)
 	mockRepo.On("DeleteDevice", mock.Anything, deviceID).Return(nil)
 
 	req, _ := http.NewRequest("DELETE", "/api/v1/devices/"+deviceID, nil)
@@ -443,6 +497,302 @@ func TestService_SearchDevices(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestService_GetDeviceDebugBundle(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/telemetry/metrics/bundle-device":
+			w.Write([]byte(`{"device_id":"bundle-device","metrics":[],"count":0}`))
+		case "/api/v1/telemetry/alerts/bundle-device":
+			w.Write([]byte(`{"device_id":"bundle-device","alerts":[],"count":0}`))
+		case "/api/v1/ota/devices/bundle-device/crashes":
+			w.Write([]byte(`{"device_id":"bundle-device","crash_reports":[{"reason":"watchdog"}],"count":1}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	service.config.Services = map[string]string{
+		"telemetry-service": upstream.URL,
+		"ota-service":       upstream.URL,
+	}
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "bundle-device"
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(createTestDevice(deviceID), nil)
+
+	ctx := context.Background()
+	require.NoError(t, service.history.RecordHeartbeat(ctx, DeviceHeartbeat{DeviceID: deviceID, Timestamp: time.Now(), Status: DeviceStatusOnline}))
+	require.NoError(t, service.history.RecordFlash(ctx, deviceID, &FlashResult{Success: true, FirmwareHash: "abc123"}))
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices/"+deviceID+"/debug-bundle", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	var bundle DebugBundle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+
+	assert.Equal(t, deviceID, bundle.DeviceID)
+	assert.Len(t, bundle.Heartbeats, 1)
+	require.NotNil(t, bundle.LastFlash)
+	assert.Equal(t, "abc123", bundle.LastFlash.FirmwareHash)
+	assert.NotEmpty(t, bundle.Telemetry)
+	assert.NotEmpty(t, bundle.Alerts)
+	assert.Empty(t, bundle.OTAUpdates)
+	assert.Contains(t, bundle.Errors, "ota_updates")
+	assert.Contains(t, string(bundle.CrashReports), `"reason":"watchdog"`)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetDeviceDebugBundle_Zip(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "bundle-device"
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(createTestDevice(deviceID), nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices/"+deviceID+"/debug-bundle?format=zip", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(archive.File))
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, deviceID+"/bundle.json")
+	assert.Contains(t, names, deviceID+"/device.json")
+	assert.Contains(t, names, deviceID+"/errors.json")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_GetDeviceDebugBundle_NotFound(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetDevice", mock.Anything, "missing").Return(nil, assert.AnError)
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices/missing/debug-bundle", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_DebugCapture(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.config.JWTSecret = "internal-secret"
+
+	// The OTA service captures exchanges, the telemetry service is down
+	otaRouter := gin.New()
+	debugcapture.Setup(otaRouter, &config.Config{ServiceName: "ota-service", JWTSecret: "internal-secret"}, logger.New("info", "ota-service"))
+	otaRouter.GET("/api/v1/ota/updates/:deviceId", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"update_available": false})
+	})
+	ota := httptest.NewServer(otaRouter)
+	defer ota.Close()
+	telemetry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer telemetry.Close()
+	service.config.Services = map[string]string{"ota-service": ota.URL, "telemetry-service": telemetry.URL}
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "capture-device"
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(createTestDevice(deviceID), nil)
+
+	reqBody, _ := json.Marshal(map[string]int{"duration_seconds": 600})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/devices/"+deviceID+"/debug-capture", bytes.NewReader(reqBody)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var result DebugCaptureResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Contains(t, result.Captures, "ota-service")
+	assert.Contains(t, result.Errors, "telemetry-service")
+
+	resp, err := http.Get(ota.URL + "/api/v1/ota/updates/" + deviceID)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The recorded exchanges are part of the debug bundle
+	bundle, err := service.BuildDebugBundle(context.Background(), deviceID)
+	require.NoError(t, err)
+	var captured debugcapture.Capture
+	require.NoError(t, json.Unmarshal(bundle.OTACapture, &captured))
+	require.Len(t, captured.Exchanges, 1)
+	assert.JSONEq(t, `{"update_available":false}`, captured.Exchanges[0].ResponseBody)
+	assert.Contains(t, bundle.Errors, "telemetry_capture")
+	assert.NotContains(t, bundle.Errors, "ota_capture")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/devices/"+deviceID+"/debug-capture", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	bundle, err = service.BuildDebugBundle(context.Background(), deviceID)
+	require.NoError(t, err)
+	assert.Nil(t, bundle.OTACapture)
+}
+
+func TestService_RecordFlashResult(t *testing.T) {
+	service, _ := setupTestService()
+	events := NewMemoryEventStore()
+	service.events = NewEventRecorder(events, "device-service", service.logger)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	reqBody, _ := json.Marshal(FlashResult{Success: false, ArtifactID: "artifact-1", Errors: []string{"port busy"}})
+	req, _ := http.NewRequest("POST", "/api/v1/devices/flash-device/flash-result", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	result, err := service.history.LastFlash(context.Background(), "flash-device")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.False(t, result.Success)
+	assert.Equal(t, []string{"port busy"}, result.Errors)
+	assert.False(t, result.FlashedAt.IsZero())
+
+	timeline, _, err := events.QueryEvents(context.Background(), "flash-device", &DeviceEventQuery{Types: []DeviceEventType{DeviceEventFlashed}})
+	require.NoError(t, err)
+	require.Len(t, timeline, 1)
+	assert.Equal(t, false, timeline[0].Data["success"])
+	assert.Equal(t, "artifact-1", timeline[0].Data["artifact_id"])
+}
+
+// failingDebugHistoryStore fails every write
+type failingDebugHistoryStore struct {
+	*MemoryDebugHistoryStore
+}
+
+func (failingDebugHistoryStore) RecordFlash(ctx context.Context, deviceID string, result *FlashResult) error {
+	return assert.AnError
+}
+
+func TestService_RecordFlashResult_StoreFailure(t *testing.T) {
+	service, _ := setupTestService()
+	service.SetDebugHistoryStore(failingDebugHistoryStore{NewMemoryDebugHistoryStore(defaultHeartbeatHistory)})
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	reqBody, _ := json.Marshal(FlashResult{Success: true})
+	req, _ := http.NewRequest("POST", "/api/v1/devices/flash-device/flash-result", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestService_RecordOnboarding(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "test-device-001"
+	device := createTestDevice(deviceID)
+	device.Credentials = map[string]*Credential{
+		DeviceTokenCredential: {Type: CredentialTypeToken, Fingerprint: fingerprint([]byte("device-token")), ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(device, nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.MatchedBy(func(device *Device) bool {
+		network, ok := device.Reported["network"].(map[string]interface{})
+		onboarding, _ := device.Reported["onboarding"].(map[string]interface{})
+		return ok && network["ssid"] == "Shop Floor" && network["rssi"] == -61 &&
+			onboarding["method"] == "captive_portal" && onboarding["portal_ssid"] == "Acme-Setup-A1B2C3"
+	})).Return(nil)
+
+	reqBody, _ := json.Marshal(OnboardingReport{
+		Method:     "captive_portal",
+		SSID:       "Shop Floor",
+		BSSID:      "AA:BB:CC:DD:EE:FF",
+		IP:         "10.0.4.17",
+		RSSI:       -61,
+		PortalSSID: "Acme-Setup-A1B2C3",
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/devices/"+deviceID+"/onboarding", bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer device-token")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Onboarding recorded"}`, w.Body.String(), "the device record is not returned")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_RecordOnboarding_Invalid(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	device := createTestDevice("test-device-001")
+	device.Credentials = map[string]*Credential{
+		DeviceTokenCredential: {Type: CredentialTypeToken, Fingerprint: fingerprint([]byte("device-token")), ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	mockRepo.On("GetDevice", mock.Anything, "test-device-001").Return(device, nil)
+	mockRepo.On("GetDevice", mock.Anything, "unknown-device").Return(nil, assert.AnError)
+
+	tests := []struct {
+		name     string
+		deviceID string
+		token    string
+		body     string
+		status   int
+	}{
+		{"missing ssid", "test-device-001", "device-token", `{"method":"captive_portal"}`, http.StatusBadRequest},
+		{"ssid too long", "test-device-001", "device-token", `{"method":"captive_portal","ssid":"` + strings.Repeat("x", 33) + `"}`, http.StatusBadRequest},
+		{"no token", "test-device-001", "", `{"method":"captive_portal","ssid":"Shop Floor"}`, http.StatusUnauthorized},
+		{"wrong token", "test-device-001", "other-token", `{"method":"captive_portal","ssid":"Shop Floor"}`, http.StatusUnauthorized},
+		{"unknown device", "unknown-device", "device-token", `{"method":"captive_portal","ssid":"Shop Floor"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/api/v1/devices/"+tt.deviceID+"/onboarding", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything)
+}
+
 // MockMonitoringService for testing
 type MockMonitoringService struct {
 	mock.Mock
@@ -0,0 +1,403 @@
+package device
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// availabilityRetention is how long online periods are kept, which bounds
+	// the longest SLA window
+	availabilityRetention = 400 * 24 * time.Hour
+
+	// defaultSLAWindow is the report window when none is requested
+	defaultSLAWindow = 30 * 24 * time.Hour
+
+	// maxSLAReportDevices bounds the devices in a group report
+	maxSLAReportDevices = 1000
+)
+
+// OnlinePeriod is a span of consecutive heartbeats from a device. A
+// heartbeat arriving within the offline timeout of the previous one extends
+// the period; a later one starts a new period.
+type OnlinePeriod struct {
+	DeviceID string    `json:"device_id"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// AvailabilityStore persists the online periods of devices
+type AvailabilityStore interface {
+	// LastPeriod returns the most recent period of a device, or nil
+	LastPeriod(ctx context.Context, deviceID string) (*OnlinePeriod, error)
+	// SavePeriod creates a period, or updates the one with the same start
+	SavePeriod(ctx context.Context, period *OnlinePeriod) error
+	// ListPeriods returns the periods of a device overlapping [from, to],
+	// oldest first
+	ListPeriods(ctx context.Context, deviceID string, from, to time.Time) ([]*OnlinePeriod, error)
+}
+
+// MemoryAvailabilityStore is an in-memory AvailabilityStore. Periods older
+// than the retention are dropped as new ones are saved.
+type MemoryAvailabilityStore struct {
+	mu      sync.RWMutex
+	periods map[string][]*OnlinePeriod
+	now     func() time.Time
+}
+
+// NewMemoryAvailabilityStore creates an empty store
+func NewMemoryAvailabilityStore() *MemoryAvailabilityStore {
+	return &MemoryAvailabilityStore{periods: make(map[string][]*OnlinePeriod), now: time.Now}
+}
+
+func (s *MemoryAvailabilityStore) LastPeriod(ctx context.Context, deviceID string) (*OnlinePeriod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	periods := s.periods[deviceID]
+	if len(periods) == 0 {
+		return nil, nil
+	}
+	last := *periods[len(periods)-1]
+	return &last, nil
+}
+
+func (s *MemoryAvailabilityStore) SavePeriod(ctx context.Context, period *OnlinePeriod) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *period
+	periods := s.periods[period.DeviceID]
+	if n := len(periods); n > 0 && periods[n-1].Start.Equal(period.Start) {
+		periods[n-1] = &saved
+	} else {
+		periods = append(periods, &saved)
+	}
+
+	cutoff := s.now().Add(-availabilityRetention)
+	for len(periods) > 0 && periods[0].End.Before(cutoff) {
+		periods = periods[1:]
+	}
+	s.periods[period.DeviceID] = periods
+	return nil
+}
+
+func (s *MemoryAvailabilityStore) ListPeriods(ctx context.Context, deviceID string, from, to time.Time) ([]*OnlinePeriod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var periods []*OnlinePeriod
+	for _, period := range s.periods[deviceID] {
+		if period.End.Before(from) || period.Start.After(to) {
+			continue
+		}
+		copied := *period
+		periods = append(periods, &copied)
+	}
+	return periods, nil
+}
+
+// RecordAvailability folds a heartbeat into the device's online periods.
+// Heartbeats reporting a status other than online do not count as up time.
+func RecordAvailability(ctx context.Context, store AvailabilityStore, heartbeat *DeviceHeartbeat, offlineTimeout time.Duration) error {
+	if heartbeat.Status != "" && heartbeat.Status != DeviceStatusOnline {
+		return nil
+	}
+
+	last, err := store.LastPeriod(ctx, heartbeat.DeviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get online period: %w", err)
+	}
+
+	at := heartbeat.Timestamp
+	switch {
+	case last != nil && !at.After(last.End):
+		// Late or duplicate heartbeat
+		return nil
+	case last != nil && at.Sub(last.End) <= offlineTimeout:
+		last.End = at
+	default:
+		last = &OnlinePeriod{DeviceID: heartbeat.DeviceID, Start: at, End: at}
+	}
+	return store.SavePeriod(ctx, last)
+}
+
+// SLAReport is the availability of a device over a window
+type SLAReport struct {
+	DeviceID string    `json:"device_id"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	// UptimePercent is the share of the window the device was online. Time
+	// before the device was registered is not counted.
+	UptimePercent        float64 `json:"uptime_percent"`
+	UptimeSeconds        float64 `json:"uptime_seconds"`
+	DowntimeSeconds      float64 `json:"downtime_seconds"`
+	Outages              int     `json:"outages"`
+	LongestOutageSeconds float64 `json:"longest_outage_seconds"`
+	// Failures counts the times the device went offline in the window
+	Failures int `json:"failures"`
+	// MTBFSeconds is the mean up time between failures, absent when the
+	// device did not fail in the window
+	MTBFSeconds *float64 `json:"mtbf_seconds,omitempty"`
+}
+
+// ComputeSLA computes the availability of a device over [from, to] from its
+// online periods. A period still open at now, with its last heartbeat within
+// the offline timeout, counts as online until now.
+func ComputeSLA(deviceID string, periods []*OnlinePeriod, from, to, now time.Time, offlineTimeout time.Duration) *SLAReport {
+	report := &SLAReport{DeviceID: deviceID, From: from, To: to}
+	if !to.After(from) {
+		return report
+	}
+
+	sorted := make([]*OnlinePeriod, len(periods))
+	copy(sorted, periods)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var uptime, longest time.Duration
+	outage := func(gap time.Duration) {
+		if gap > 0 {
+			report.Outages++
+			if gap > longest {
+				longest = gap
+			}
+		}
+	}
+
+	cursor := from
+	for i, period := range sorted {
+		start, end := period.Start, period.End
+		if i == len(sorted)-1 && now.Sub(end) <= offlineTimeout {
+			end = now
+		}
+		if start.Before(cursor) {
+			start = cursor
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		outage(start.Sub(cursor))
+		uptime += end.Sub(start)
+		cursor = end
+		// The device went down before the window ended
+		if end.Before(to) {
+			report.Failures++
+		}
+	}
+	outage(to.Sub(cursor))
+
+	window := to.Sub(from)
+	report.UptimeSeconds = uptime.Seconds()
+	report.DowntimeSeconds = (window - uptime).Seconds()
+	report.UptimePercent = roundPercent(float64(uptime) / float64(window) * 100)
+	report.LongestOutageSeconds = longest.Seconds()
+	if report.Failures > 0 {
+		mtbf := uptime.Seconds() / float64(report.Failures)
+		report.MTBFSeconds = &mtbf
+	}
+	return report
+}
+
+// GroupSLAReport is the availability of a group of devices over a window
+type GroupSLAReport struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Devices int       `json:"devices"`
+	// UptimePercent is the mean uptime of the devices
+	UptimePercent        float64      `json:"uptime_percent"`
+	Outages              int          `json:"outages"`
+	LongestOutageSeconds float64      `json:"longest_outage_seconds"`
+	Failures             int          `json:"failures"`
+	MTBFSeconds          *float64     `json:"mtbf_seconds,omitempty"`
+	Reports              []*SLAReport `json:"reports"`
+}
+
+// SummarizeSLA combines device reports into a group report. Devices are
+// listed least available first.
+func SummarizeSLA(from, to time.Time, reports []*SLAReport) *GroupSLAReport {
+	group := &GroupSLAReport{From: from, To: to, Devices: len(reports), Reports: reports}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].UptimePercent < reports[j].UptimePercent })
+
+	var uptimePercent, uptime float64
+	failures := 0
+	for _, report := range reports {
+		uptimePercent += report.UptimePercent
+		uptime += report.UptimeSeconds
+		group.Outages += report.Outages
+		if report.LongestOutageSeconds > group.LongestOutageSeconds {
+			group.LongestOutageSeconds = report.LongestOutageSeconds
+		}
+		failures += report.Failures
+	}
+	if len(reports) > 0 {
+		group.UptimePercent = roundPercent(uptimePercent / float64(len(reports)))
+	}
+	group.Failures = failures
+	if failures > 0 {
+		mtbf := uptime / float64(failures)
+		group.MTBFSeconds = &mtbf
+	}
+	return group
+}
+
+// WriteSLACSV writes device reports as CSV, one row per device
+func WriteSLACSV(w io.Writer, reports []*SLAReport) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"device_id", "from", "to", "uptime_percent", "uptime_seconds", "downtime_seconds", "outages", "longest_outage_seconds", "failures", "mtbf_seconds"})
+	for _, report := range reports {
+		mtbf := ""
+		if report.MTBFSeconds != nil {
+			mtbf = formatSeconds(*report.MTBFSeconds)
+		}
+		out.Write([]string{
+			report.DeviceID,
+			report.From.UTC().Format(time.RFC3339),
+			report.To.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(report.UptimePercent, 'f', 3, 64),
+			formatSeconds(report.UptimeSeconds),
+			formatSeconds(report.DowntimeSeconds),
+			strconv.Itoa(report.Outages),
+			formatSeconds(report.LongestOutageSeconds),
+			strconv.Itoa(report.Failures),
+			mtbf,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ParseSLAWindow returns the report window ending at now. window is a
+// duration such as 30d, 12h or 90m; from and to are RFC 3339 times and take
+// precedence.
+func ParseSLAWindow(window, fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	to := now
+	if toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to time: %w", err)
+		}
+		to = parsed
+	}
+
+	var from time.Time
+	if fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from time: %w", err)
+		}
+		from = parsed
+	} else {
+		length := defaultSLAWindow
+		if window != "" {
+			var err error
+			if length, err = parseWindowDuration(window); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+		}
+		from = to.Add(-length)
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("window must end after it starts")
+	}
+	if to.Sub(from) > availabilityRetention {
+		return time.Time{}, time.Time{}, fmt.Errorf("window must not exceed %d days", int(availabilityRetention.Hours()/24))
+	}
+	return from, to, nil
+}
+
+// parseWindowDuration parses a duration, accepting a "d" suffix for days
+func parseWindowDuration(window string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", window)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	length, err := time.ParseDuration(window)
+	if err != nil || length <= 0 {
+		return 0, fmt.Errorf("invalid window %q", window)
+	}
+	return length, nil
+}
+
+func roundPercent(percent float64) float64 {
+	return float64(int64(percent*1000+0.5)) / 1000
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 0, 64)
+}
+
+// SetAvailabilityStore sets where device online periods are persisted
+func (s *Service) SetAvailabilityStore(store AvailabilityStore) {
+	s.availability = store
+}
+
+// offlineTimeout returns the heartbeat gap after which a device counts as
+// offline
+func (s *Service) offlineTimeout() time.Duration {
+	if s.monitoring != nil {
+		return s.monitoring.GetConfiguration().OfflineTimeout
+	}
+	return DefaultMonitoringConfig().OfflineTimeout
+}
+
+// DeviceSLA reports the availability of a device over [from, to]
+func (s *Service) DeviceSLA(ctx context.Context, deviceID string, from, to time.Time) (*SLAReport, error) {
+	device, err := s.repository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return s.deviceSLA(ctx, device, from, to)
+}
+
+// GroupSLA reports the availability of the devices matching filters over
+// [from, to]
+func (s *Service) GroupSLA(ctx context.Context, filters *DeviceFilters, from, to time.Time) (*GroupSLAReport, error) {
+	filters.Limit = maxSLAReportDevices
+	filters.Offset = 0
+	devices, err := s.repository.ListDevices(ctx, filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	reports := make([]*SLAReport, 0, len(devices))
+	for _, device := range devices {
+		report, err := s.deviceSLA(ctx, device, from, to)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return SummarizeSLA(from, to, reports), nil
+}
+
+// deviceSLA computes a device report, starting the window no earlier than
+// the device's registration
+func (s *Service) deviceSLA(ctx context.Context, device *Device, from, to time.Time) (*SLAReport, error) {
+	if device.CreatedAt.After(from) {
+		from = device.CreatedAt
+	}
+	if from.After(to) {
+		from = to
+	}
+
+	periods, err := s.availability.ListPeriods(ctx, device.DeviceID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list online periods: %w", err)
+	}
+	return ComputeSLA(device.DeviceID, periods, from, to, time.Now(), s.offlineTimeout()), nil
+}
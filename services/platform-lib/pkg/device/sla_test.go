@@ -0,0 +1,205 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAvailability(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAvailabilityStore()
+	base := time.Now().Add(-time.Hour)
+	timeout := 5 * time.Minute
+
+	beat := func(offset time.Duration, status DeviceStatus) {
+		require.NoError(t, RecordAvailability(ctx, store, &DeviceHeartbeat{
+			DeviceID:  "device-001",
+			Timestamp: base.Add(offset),
+			Status:    status,
+		}, timeout))
+	}
+	beat(0, DeviceStatusOnline)
+	beat(time.Minute, DeviceStatusOnline)
+	beat(30*time.Second, DeviceStatusOnline) // late heartbeat is ignored
+	beat(3*time.Minute, "")
+	beat(20*time.Minute, DeviceStatusOnline) // gap beyond the timeout
+	beat(21*time.Minute, DeviceStatusError)  // not counted as up time
+
+	periods, err := store.ListPeriods(ctx, "device-001", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+	assert.Equal(t, base, periods[0].Start)
+	assert.Equal(t, base.Add(3*time.Minute), periods[0].End)
+	assert.Equal(t, base.Add(20*time.Minute), periods[1].Start)
+	assert.Equal(t, base.Add(20*time.Minute), periods[1].End)
+}
+
+func TestComputeSLA(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	now := to.Add(time.Hour)
+	periods := []*OnlinePeriod{
+		{DeviceID: "device-001", Start: from.Add(5 * time.Hour), End: from.Add(9 * time.Hour)},
+		{DeviceID: "device-001", Start: from.Add(-time.Hour), End: from.Add(3 * time.Hour)},
+	}
+
+	report := ComputeSLA("device-001", periods, from, to, now, 5*time.Minute)
+
+	assert.Equal(t, 70.0, report.UptimePercent)
+	assert.Equal(t, (7 * time.Hour).Seconds(), report.UptimeSeconds)
+	assert.Equal(t, (3 * time.Hour).Seconds(), report.DowntimeSeconds)
+	assert.Equal(t, 2, report.Outages)
+	assert.Equal(t, (2 * time.Hour).Seconds(), report.LongestOutageSeconds)
+	assert.Equal(t, 2, report.Failures)
+	require.NotNil(t, report.MTBFSeconds)
+	assert.Equal(t, (3*time.Hour + 30*time.Minute).Seconds(), *report.MTBFSeconds)
+}
+
+func TestComputeSLA_OpenPeriod(t *testing.T) {
+	now := time.Now()
+	from := now.Add(-time.Hour)
+	periods := []*OnlinePeriod{
+		{DeviceID: "device-001", Start: from, End: now.Add(-time.Minute)},
+	}
+
+	// The last heartbeat is within the offline timeout, so the device is up
+	report := ComputeSLA("device-001", periods, from, now, now, 5*time.Minute)
+	assert.Equal(t, 100.0, report.UptimePercent)
+	assert.Zero(t, report.Outages)
+	assert.Zero(t, report.Failures)
+	assert.Nil(t, report.MTBFSeconds)
+}
+
+func TestSummarizeSLA(t *testing.T) {
+	mtbf := 3600.0
+	reports := []*SLAReport{
+		{DeviceID: "device-001", UptimePercent: 100, UptimeSeconds: 7200},
+		{DeviceID: "device-002", UptimePercent: 50, UptimeSeconds: 3600, Outages: 1, LongestOutageSeconds: 3600, Failures: 1, MTBFSeconds: &mtbf},
+	}
+
+	group := SummarizeSLA(time.Time{}, time.Time{}, reports)
+
+	assert.Equal(t, 2, group.Devices)
+	assert.Equal(t, 75.0, group.UptimePercent)
+	assert.Equal(t, 1, group.Failures)
+	require.NotNil(t, group.MTBFSeconds)
+	assert.Equal(t, 10800.0, *group.MTBFSeconds)
+	assert.Equal(t, "device-002", group.Reports[0].DeviceID)
+}
+
+func TestParseSLAWindow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		window  string
+		from    string
+		to      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "default", want: 30 * 24 * time.Hour},
+		{name: "days", window: "7d", want: 7 * 24 * time.Hour},
+		{name: "hours", window: "12h", want: 12 * time.Hour},
+		{name: "explicit range", from: "2024-05-31T00:00:00Z", to: "2024-05-31T06:00:00Z", want: 6 * time.Hour},
+		{name: "invalid window", window: "soon", wantErr: true},
+		{name: "zero days", window: "0d", wantErr: true},
+		{name: "beyond retention", window: "500d", wantErr: true},
+		{name: "reversed range", from: "2024-05-31T06:00:00Z", to: "2024-05-31T00:00:00Z", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := ParseSLAWindow(tt.window, tt.from, tt.to, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, to.Sub(from))
+		})
+	}
+}
+
+func TestOnlinePeriodEntity(t *testing.T) {
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	period := &OnlinePeriod{DeviceID: "device-001", Start: start, End: start.Add(time.Hour)}
+	assert.Equal(t, period, period.ToEntity().FromEntity())
+
+	// Periods are keyed by their start, so extending one overwrites it
+	assert.Equal(t, fmt.Sprintf("device-001#%d", start.UnixNano()), onlinePeriodKey(period.DeviceID, period.Start).Name)
+}
+
+func TestService_GetDeviceSLA(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.availability = NewMemoryAvailabilityStore()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "device-001"
+	device := createTestDevice(deviceID)
+	device.CreatedAt = time.Now().Add(-48 * time.Hour)
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(device, nil)
+
+	ctx := context.Background()
+	require.NoError(t, service.availability.SavePeriod(ctx, &OnlinePeriod{
+		DeviceID: deviceID,
+		Start:    time.Now().Add(-36 * time.Hour),
+		End:      time.Now().Add(-12 * time.Hour),
+	}))
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices/"+deviceID+"/sla?window=30d", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report SLAReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	// The window starts when the device was registered
+	assert.WithinDuration(t, device.CreatedAt, report.From, time.Second)
+	assert.InDelta(t, 50.0, report.UptimePercent, 0.01)
+	assert.Equal(t, 2, report.Outages)
+	assert.Equal(t, 1, report.Failures)
+
+	req, _ = http.NewRequest("GET", "/api/v1/devices/"+deviceID+"/sla?format=xml", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestService_GetGroupSLA_CSV(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.availability = NewMemoryAvailabilityStore()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("ListDevices", mock.Anything, mock.MatchedBy(func(filters *DeviceFilters) bool {
+		return filters.OTAChannel == "beta" && filters.Limit == maxSLAReportDevices
+	})).Return([]*Device{createTestDevice("device-001"), createTestDevice("device-002")}, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/devices/sla?window=7d&ota_channel=beta&format=csv", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "devices-sla.csv")
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "device_id,from,to,uptime_percent"))
+}
@@ -0,0 +1,53 @@
+package device
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+// TemplateReferences finds the devices running a template version, so the
+// template service can refuse to delete versions still in use and show
+// authors how their template is used
+type TemplateReferences struct {
+	repository Repository
+}
+
+// NewTemplateReferences creates a template reference finder over repository
+func NewTemplateReferences(repository Repository) *TemplateReferences {
+	return &TemplateReferences{repository: repository}
+}
+
+// FindTemplateReferences lists the devices running a template version
+func (f *TemplateReferences) FindTemplateReferences(ctx context.Context, templateID, version string) ([]template.Reference, error) {
+	devices, err := f.repository.ListDevices(ctx, &DeviceFilters{TemplateID: templateID, TemplateVersion: version})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	references := make([]template.Reference, 0, len(devices))
+	for _, d := range devices {
+		references = append(references, template.Reference{Kind: template.ReferenceDevice, ID: d.DeviceID})
+	}
+	return references, nil
+}
+
+// FindTemplateUsage lists the devices built from any version of a template
+func (f *TemplateReferences) FindTemplateUsage(ctx context.Context, templateID string) ([]template.DeviceUsage, error) {
+	devices, err := f.repository.ListDevices(ctx, &DeviceFilters{TemplateID: templateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	usage := make([]template.DeviceUsage, 0, len(devices))
+	for _, d := range devices {
+		usage = append(usage, template.DeviceUsage{
+			DeviceID:   d.DeviceID,
+			Version:    d.TemplateVersion,
+			Board:      d.BoardType,
+			Parameters: d.Parameters,
+		})
+	}
+	return usage, nil
+}
@@ -0,0 +1,314 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultDeviceEventPageSize is used when a timeline query sets no limit
+	defaultDeviceEventPageSize = 100
+
+	// maxDeviceEventPageSize caps a single page of device events
+	maxDeviceEventPageSize = 1000
+)
+
+// ErrInvalidDeviceEventQuery is returned for timeline parameters that cannot
+// be applied, including cursors from another query
+var ErrInvalidDeviceEventQuery = errors.New("invalid device event query")
+
+// DeviceEventType identifies a lifecycle event on a device's timeline
+type DeviceEventType string
+
+const (
+	DeviceEventRegistered      DeviceEventType = "registered"
+	DeviceEventStatusChanged   DeviceEventType = "status_changed"
+	DeviceEventFirmwareUpdated DeviceEventType = "firmware_updated"
+	DeviceEventConfigChanged   DeviceEventType = "config_changed"
+	DeviceEventAlertFired      DeviceEventType = "alert_fired"
+	DeviceEventFlashed         DeviceEventType = "flashed"
+)
+
+// deviceEventTypes lists every DeviceEventType
+var deviceEventTypes = []DeviceEventType{
+	DeviceEventRegistered,
+	DeviceEventStatusChanged,
+	DeviceEventFirmwareUpdated,
+	DeviceEventConfigChanged,
+	DeviceEventAlertFired,
+	DeviceEventFlashed,
+}
+
+// DeviceEvent is one entry of a device's timeline. Source names the service
+// that recorded it and Data holds details specific to the event type.
+type DeviceEvent struct {
+	EventID   string                 `json:"event_id"`
+	DeviceID  string                 `json:"device_id"`
+	Type      DeviceEventType        `json:"type"`
+	Source    string                 `json:"source"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// DeviceEventQuery selects one page of a device's timeline, newest first.
+// Zero values leave the matching filter off. Cursor is the NextCursor of the
+// previous page and is only valid with the same filters.
+type DeviceEventQuery struct {
+	Types  []DeviceEventType
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Cursor string
+}
+
+// DeviceEventPage is one page of a device's timeline
+type DeviceEventPage struct {
+	DeviceID   string         `json:"device_id"`
+	Events     []*DeviceEvent `json:"events"`
+	Count      int            `json:"count"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ParseDeviceEventQuery builds a query from request parameters. Types may be
+// repeated or comma separated; since and until are RFC 3339 times.
+func ParseDeviceEventQuery(types []string, since, until, limit, cursor string) (*DeviceEventQuery, error) {
+	query := &DeviceEventQuery{Cursor: cursor}
+
+	for _, value := range types {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			eventType := DeviceEventType(name)
+			if !isDeviceEventType(eventType) {
+				return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidDeviceEventQuery, name)
+			}
+			query.Types = append(query.Types, eventType)
+		}
+	}
+
+	var err error
+	if since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("%w: since must be an RFC 3339 time", ErrInvalidDeviceEventQuery)
+		}
+	}
+	if until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, fmt.Errorf("%w: until must be an RFC 3339 time", ErrInvalidDeviceEventQuery)
+		}
+	}
+	if limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			return nil, fmt.Errorf("%w: limit must be a positive integer", ErrInvalidDeviceEventQuery)
+		}
+	}
+
+	return query, nil
+}
+
+func isDeviceEventType(eventType DeviceEventType) bool {
+	for _, known := range deviceEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EventStore persists device timelines
+type EventStore interface {
+	RecordEvent(ctx context.Context, event *DeviceEvent) error
+	// QueryEvents returns one page of a device's events, newest first, and
+	// a cursor for the next page. The cursor is empty once the last page
+	// has been read.
+	QueryEvents(ctx context.Context, deviceID string, query *DeviceEventQuery) (events []*DeviceEvent, nextCursor string, err error)
+}
+
+// EventRecorder adds events to device timelines on behalf of a service. A
+// nil recorder records nothing, so services can run without a timeline.
+// Failures are logged rather than returned: the timeline must never block
+// the change it describes.
+type EventRecorder struct {
+	mu     sync.RWMutex
+	store  EventStore
+	source string
+	logger *logger.Logger
+}
+
+// NewEventRecorder creates a recorder writing to store, naming source as
+// the origin of its events
+func NewEventRecorder(store EventStore, source string, logger *logger.Logger) *EventRecorder {
+	return &EventRecorder{store: store, source: source, logger: logger}
+}
+
+// Record adds an event to a device's timeline
+func (r *EventRecorder) Record(ctx context.Context, deviceID string, eventType DeviceEventType, message string, data map[string]interface{}) {
+	if r == nil || deviceID == "" {
+		return
+	}
+	store := r.currentStore()
+	if store == nil {
+		return
+	}
+
+	event := &DeviceEvent{
+		EventID:   uuid.New().String(),
+		DeviceID:  deviceID,
+		Type:      eventType,
+		Source:    r.source,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().UTC(),
+	}
+	if err := store.RecordEvent(ctx, event); err != nil {
+		r.logger.Warn("Failed to record device event", "device_id", deviceID, "type", eventType, "error", err)
+	}
+}
+
+// setStore replaces where events are written
+func (r *EventRecorder) setStore(store EventStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = store
+}
+
+func (r *EventRecorder) currentStore() EventStore {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.store
+}
+
+// recordStatusChange adds a status change to a device's timeline
+func (r *EventRecorder) recordStatusChange(ctx context.Context, deviceID string, from, to DeviceStatus) {
+	r.Record(ctx, deviceID, DeviceEventStatusChanged, fmt.Sprintf("Device %s is now %s", deviceID, to), map[string]interface{}{
+		"from": string(from),
+		"to":   string(to),
+	})
+}
+
+// recordDeviceUpdate adds the firmware and configuration changes between
+// two versions of a device to its timeline
+func (r *EventRecorder) recordDeviceUpdate(ctx context.Context, before, after *Device) {
+	if before.FirmwareHash != after.FirmwareHash {
+		r.Record(ctx, after.DeviceID, DeviceEventFirmwareUpdated, fmt.Sprintf("Device %s firmware changed", after.DeviceID), map[string]interface{}{
+			"from": before.FirmwareHash,
+			"to":   after.FirmwareHash,
+		})
+	}
+	if changed := changedConfigFields(before, after); len(changed) > 0 {
+		r.Record(ctx, after.DeviceID, DeviceEventConfigChanged, fmt.Sprintf("Device %s configuration changed: %s", after.DeviceID, strings.Join(changed, ", ")), map[string]interface{}{
+			"fields": changed,
+		})
+	}
+}
+
+// changedConfigFields names the configuration fields that differ between
+// two versions of a device
+func changedConfigFields(before, after *Device) []string {
+	var changed []string
+	check := func(field string, differs bool) {
+		if differs {
+			changed = append(changed, field)
+		}
+	}
+	check("name", before.Name != after.Name)
+	check("template_id", before.TemplateID != after.TemplateID)
+	check("template_version", before.TemplateVersion != after.TemplateVersion)
+	check("parameters", (len(before.Parameters) > 0 || len(after.Parameters) > 0) && !reflect.DeepEqual(before.Parameters, after.Parameters))
+	check("ota_channel", before.OTAChannel != after.OTAChannel)
+	check("ota_enrollment", before.OTAEnrollment != after.OTAEnrollment)
+	check("labels", (len(before.Labels) > 0 || len(after.Labels) > 0) && !reflect.DeepEqual(before.Labels, after.Labels))
+	check("parent_id", before.ParentID != after.ParentID)
+	return changed
+}
+
+// SetEventStore sets where device timelines are persisted
+func (s *Service) SetEventStore(store EventStore) {
+	s.events.setStore(store)
+}
+
+// ListDeviceEvents returns one page of a device's timeline. Events outlive
+// their device, so the timeline of a deleted device can still be read.
+func (s *Service) ListDeviceEvents(ctx context.Context, deviceID string, query *DeviceEventQuery) (*DeviceEventPage, error) {
+	if query == nil {
+		query = &DeviceEventQuery{}
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return nil, fmt.Errorf("%w: since must be before until", ErrInvalidDeviceEventQuery)
+	}
+	switch {
+	case query.Limit <= 0:
+		query.Limit = defaultDeviceEventPageSize
+	case query.Limit > maxDeviceEventPageSize:
+		query.Limit = maxDeviceEventPageSize
+	}
+
+	store := s.events.currentStore()
+	if store == nil {
+		return nil, fmt.Errorf("device timeline is not configured")
+	}
+	events, nextCursor, err := store.QueryEvents(ctx, deviceID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DeviceEventPage{
+		DeviceID:   deviceID,
+		Events:     events,
+		Count:      len(events),
+		NextCursor: nextCursor,
+	}
+	if page.Events == nil {
+		page.Events = []*DeviceEvent{}
+	}
+	return page, nil
+}
+
+func (s *Service) listDeviceEvents(c *gin.Context) {
+	deviceID := c.Param("id")
+
+	query, err := ParseDeviceEventQuery(c.QueryArray("type"), c.Query("since"), c.Query("until"), c.Query("limit"), c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid event query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	page, err := s.ListDeviceEvents(c.Request.Context(), deviceID, query)
+	if err != nil {
+		if errors.Is(err, ErrInvalidDeviceEventQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid event query",
+				"details": err.Error(),
+			})
+			return
+		}
+		s.logger.Error("Failed to list device events", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list device events",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
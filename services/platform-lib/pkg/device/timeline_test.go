@@ -0,0 +1,151 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeviceEventQuery(t *testing.T) {
+	query, err := ParseDeviceEventQuery([]string{"registered,alert_fired", "status_changed"}, "2026-01-01T00:00:00Z", "", "10", "cursor")
+	require.NoError(t, err)
+	assert.Equal(t, []DeviceEventType{DeviceEventRegistered, DeviceEventAlertFired, DeviceEventStatusChanged}, query.Types)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), query.Since)
+	assert.Equal(t, 10, query.Limit)
+	assert.Equal(t, "cursor", query.Cursor)
+
+	for _, tc := range []struct{ types, since, limit string }{
+		{types: "rebooted"},
+		{since: "yesterday"},
+		{limit: "0"},
+	} {
+		_, err := ParseDeviceEventQuery([]string{tc.types}, tc.since, "", tc.limit, "")
+		assert.ErrorIs(t, err, ErrInvalidDeviceEventQuery)
+	}
+}
+
+func TestService_RecordsDeviceChanges(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.events = NewEventRecorder(NewMemoryEventStore(), "device-service", service.logger)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	previous := createTestDevice("device-001")
+	previous.Parameters = map[string]interface{}{"sensor_pin": float64(2)}
+	mockRepo.On("GetDevice", mock.Anything, "device-001").Return(previous, nil)
+	mockRepo.On("UpdateDeviceStatus", mock.Anything, "device-001", DeviceStatusOffline, mock.Anything).Return(nil)
+	mockRepo.On("UpdateDevice", mock.Anything, mock.AnythingOfType("*device.Device")).Return(nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/devices/device-001"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, send("PUT", "/status", `{"status":"offline"}`).Code)
+
+	updated := *previous
+	updated.TemplateVersion = "1.1.0"
+	updated.FirmwareHash = "fedcba"
+	body, _ := json.Marshal(updated)
+	require.Equal(t, http.StatusOK, send("PUT", "", string(body)).Code)
+
+	w := send("GET", "/events", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page DeviceEventPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Equal(t, 3, page.Count)
+	types := map[DeviceEventType]*DeviceEvent{}
+	for _, event := range page.Events {
+		assert.Equal(t, "device-service", event.Source)
+		types[event.Type] = event
+	}
+	require.Contains(t, types, DeviceEventStatusChanged)
+	assert.Equal(t, "offline", types[DeviceEventStatusChanged].Data["to"])
+	require.Contains(t, types, DeviceEventConfigChanged)
+	assert.Equal(t, []interface{}{"template_version"}, types[DeviceEventConfigChanged].Data["fields"])
+	require.Contains(t, types, DeviceEventFirmwareUpdated)
+	assert.Equal(t, "fedcba", types[DeviceEventFirmwareUpdated].Data["to"])
+
+	w = send("GET", "/events?type=status_changed", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, page.Count)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/events?type=rebooted", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/events?since=2026-02-01T00:00:00Z&until=2026-01-01T00:00:00Z", "").Code)
+}
+
+func TestService_ListDeviceEvents_Pages(t *testing.T) {
+	service, _ := setupTestService()
+	store := NewMemoryEventStore()
+	service.events = NewEventRecorder(store, "device-service", service.logger)
+	ctx := context.Background()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.RecordEvent(ctx, &DeviceEvent{
+			EventID:   "event-" + string(rune('a'+i)),
+			DeviceID:  "device-001",
+			Type:      DeviceEventAlertFired,
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	require.NoError(t, store.RecordEvent(ctx, &DeviceEvent{EventID: "other", DeviceID: "device-002", Type: DeviceEventAlertFired, Timestamp: start}))
+
+	query := &DeviceEventQuery{Since: start.Add(time.Hour), Until: start.Add(5 * time.Hour), Limit: 3}
+	page, err := service.ListDeviceEvents(ctx, "device-001", query)
+	require.NoError(t, err)
+	require.Equal(t, 3, page.Count)
+	assert.Equal(t, []string{"event-e", "event-d", "event-c"}, eventIDs(page.Events))
+	require.NotEmpty(t, page.NextCursor)
+
+	query.Cursor = page.NextCursor
+	page, err = service.ListDeviceEvents(ctx, "device-001", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"event-b"}, eventIDs(page.Events))
+	assert.Empty(t, page.NextCursor)
+
+	page, err = service.ListDeviceEvents(ctx, "device-404", nil)
+	require.NoError(t, err)
+	assert.NotNil(t, page.Events)
+	assert.Zero(t, page.Count)
+
+	_, err = service.ListDeviceEvents(ctx, "device-001", &DeviceEventQuery{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidDeviceEventQuery)
+}
+
+func TestDeviceEventEntity_RoundTrip(t *testing.T) {
+	event := &DeviceEvent{
+		EventID:   "event-1",
+		DeviceID:  "device-001",
+		Type:      DeviceEventFirmwareUpdated,
+		Source:    "ota-service",
+		Message:   "Device device-001 was updated to firmware 1.2.0",
+		Data:      map[string]interface{}{"version": "1.2.0"},
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+	}
+	entity, err := event.ToEntity()
+	require.NoError(t, err)
+
+	restored, err := entity.FromEntity("event-1")
+	require.NoError(t, err)
+	assert.Equal(t, event, restored)
+}
+
+func eventIDs(events []*DeviceEvent) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.EventID
+	}
+	return ids
+}
@@ -0,0 +1,257 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrTwinVersionConflict is returned for twin updates made against a
+// version of the document that is no longer current
+var ErrTwinVersionConflict = errors.New("twin version conflict")
+
+// TwinDocument is one side of a device twin. Version counts the changes
+// to State, starting from zero for a document never written.
+type TwinDocument struct {
+	State     map[string]interface{} `json:"state"`
+	Version   int64                  `json:"version"`
+	UpdatedAt *time.Time             `json:"updated_at,omitempty"`
+}
+
+// Twin is the state operators want a device in, Desired, next to the
+// state the device last reported, Reported
+type Twin struct {
+	DeviceID string       `json:"device_id"`
+	Desired  TwinDocument `json:"desired"`
+	Reported TwinDocument `json:"reported"`
+}
+
+// TwinDelta is the desired state a device has not reported yet
+type TwinDelta struct {
+	DeviceID        string                 `json:"device_id"`
+	State           map[string]interface{} `json:"state"`
+	DesiredVersion  int64                  `json:"desired_version"`
+	ReportedVersion int64                  `json:"reported_version"`
+}
+
+// TwinPatch changes one side of a twin as a JSON merge patch: objects are
+// merged key by key and null removes a key. With Version set the patch
+// only applies to that version of the document.
+type TwinPatch struct {
+	State   map[string]interface{} `json:"state" binding:"required"`
+	Version *int64                 `json:"version,omitempty"`
+}
+
+// TwinStore persists device twins
+type TwinStore interface {
+	// GetTwin returns the twin of a device, empty if none was stored
+	GetTwin(ctx context.Context, deviceID string) (*Twin, error)
+	// ModifyTwin applies modify to the stored twin so that concurrent
+	// writers cannot overwrite each other. An error from modify aborts the
+	// write and is returned unchanged.
+	ModifyTwin(ctx context.Context, deviceID string, modify func(*Twin) error) (*Twin, error)
+	// DeleteTwin removes the twin of a device
+	DeleteTwin(ctx context.Context, deviceID string) error
+}
+
+// SetTwinStore sets where device twins are persisted
+func (s *Service) SetTwinStore(store TwinStore) {
+	s.twins = store
+}
+
+// Delta returns the desired state whose values differ from, or are
+// missing in, the reported state
+func (t *Twin) Delta() *TwinDelta {
+	return &TwinDelta{
+		DeviceID:        t.DeviceID,
+		State:           stateDelta(t.Desired.State, t.Reported.State),
+		DesiredVersion:  t.Desired.Version,
+		ReportedVersion: t.Reported.Version,
+	}
+}
+
+// stateDelta returns the entries of desired that reported does not match.
+// Nested objects are compared key by key.
+func stateDelta(desired, reported map[string]interface{}) map[string]interface{} {
+	delta := make(map[string]interface{})
+	for key, want := range desired {
+		have, ok := reported[key]
+		wantObject, wantIsObject := want.(map[string]interface{})
+		haveObject, haveIsObject := have.(map[string]interface{})
+		switch {
+		case ok && wantIsObject && haveIsObject:
+			if nested := stateDelta(wantObject, haveObject); len(nested) > 0 {
+				delta[key] = nested
+			}
+		case !ok || !reflect.DeepEqual(want, have):
+			delta[key] = want
+		}
+	}
+	return delta
+}
+
+// mergeState applies a JSON merge patch to state and returns the result
+func mergeState(state, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(state)+len(patch))
+	for key, value := range state {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			current, _ := merged[key].(map[string]interface{})
+			merged[key] = mergeState(current, object)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// applyPatch merges patch into doc, moving it to the next version when its
+// state changes
+func (doc *TwinDocument) applyPatch(patch *TwinPatch, now time.Time) error {
+	if patch.Version != nil && *patch.Version != doc.Version {
+		return fmt.Errorf("%w: patch is for version %d, current version is %d", ErrTwinVersionConflict, *patch.Version, doc.Version)
+	}
+	merged := mergeState(doc.State, patch.State)
+	if reflect.DeepEqual(merged, doc.State) {
+		return nil
+	}
+	doc.State = merged
+	doc.Version++
+	doc.UpdatedAt = &now
+	return nil
+}
+
+// GetTwin returns the twin of a registered device
+func (s *Service) GetTwin(ctx context.Context, deviceID string) (*Twin, error) {
+	if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	return s.twins.GetTwin(ctx, deviceID)
+}
+
+// UpdateDesiredState patches the state operators want a device in. Patches
+// that change the state are added to the device's timeline.
+func (s *Service) UpdateDesiredState(ctx context.Context, deviceID string, patch *TwinPatch) (*Twin, error) {
+	var previousVersion int64
+	twin, err := s.patchTwin(ctx, deviceID, func(twin *Twin) *TwinDocument {
+		previousVersion = twin.Desired.Version
+		return &twin.Desired
+	}, patch)
+	if err != nil || twin.Desired.Version == previousVersion {
+		return twin, err
+	}
+	s.events.Record(ctx, deviceID, DeviceEventConfigChanged, fmt.Sprintf("Device %s desired state changed", deviceID), map[string]interface{}{
+		"desired": patch.State,
+		"version": twin.Desired.Version,
+	})
+	return twin, nil
+}
+
+// UpdateReportedState patches the state a device reports being in
+func (s *Service) UpdateReportedState(ctx context.Context, deviceID string, patch *TwinPatch) (*Twin, error) {
+	return s.patchTwin(ctx, deviceID, func(twin *Twin) *TwinDocument { return &twin.Reported }, patch)
+}
+
+func (s *Service) patchTwin(ctx context.Context, deviceID string, side func(*Twin) *TwinDocument, patch *TwinPatch) (*Twin, error) {
+	if _, err := s.repository.GetDevice(ctx, deviceID); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDeviceLookup, err)
+	}
+	now := time.Now()
+	return s.twins.ModifyTwin(ctx, deviceID, func(twin *Twin) error {
+		return side(twin).applyPatch(patch, now)
+	})
+}
+
+func (s *Service) getTwin(c *gin.Context) {
+	deviceID := c.Param("id")
+	twin, err := s.GetTwin(c.Request.Context(), deviceID)
+	if err != nil {
+		s.respondTwinError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, twin)
+}
+
+func (s *Service) getTwinDelta(c *gin.Context) {
+	deviceID := c.Param("id")
+	twin, err := s.GetTwin(c.Request.Context(), deviceID)
+	if err != nil {
+		s.respondTwinError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, twin.Delta())
+}
+
+func (s *Service) patchDesiredState(c *gin.Context) {
+	s.handleTwinPatch(c, s.UpdateDesiredState)
+}
+
+func (s *Service) reportTwinState(c *gin.Context) {
+	deviceID := c.Param("id")
+	ok, err := AuthenticateRequest(c.Request.Context(), s.repository, deviceID, c.Request)
+	if err != nil {
+		s.logger.Warn("Failed to look up twin reporting device", "device_id", deviceID, "error", err)
+	}
+	// Failures are not told apart, so callers cannot probe for devices
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Device credentials required",
+		})
+		return
+	}
+
+	s.handleTwinPatch(c, s.UpdateReportedState)
+}
+
+func (s *Service) handleTwinPatch(c *gin.Context, update func(context.Context, string, *TwinPatch) (*Twin, error)) {
+	var patch TwinPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	deviceID := c.Param("id")
+	twin, err := update(c.Request.Context(), deviceID, &patch)
+	if err != nil {
+		s.respondTwinError(c, deviceID, err)
+		return
+	}
+	c.JSON(http.StatusOK, twin)
+}
+
+// respondTwinError maps twin errors to responses
+func (s *Service) respondTwinError(c *gin.Context, deviceID string, err error) {
+	if errors.Is(err, ErrTwinVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Twin version conflict",
+			"details": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, errDeviceLookup) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Device not found",
+			"details": err.Error(),
+		})
+		return
+	}
+	s.logger.Error("Failed to access device twin", "device_id", deviceID, "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to access device twin",
+		"details": err.Error(),
+	})
+}
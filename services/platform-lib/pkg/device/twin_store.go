@@ -0,0 +1,180 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// MemoryTwinStore is an in-memory TwinStore
+type MemoryTwinStore struct {
+	mu    sync.Mutex
+	twins map[string]*Twin
+}
+
+// NewMemoryTwinStore creates an empty store
+func NewMemoryTwinStore() *MemoryTwinStore {
+	return &MemoryTwinStore{twins: make(map[string]*Twin)}
+}
+
+func (s *MemoryTwinStore) GetTwin(ctx context.Context, deviceID string) (*Twin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(deviceID), nil
+}
+
+func (s *MemoryTwinStore) ModifyTwin(ctx context.Context, deviceID string, modify func(*Twin) error) (*Twin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twin := s.load(deviceID)
+	if err := modify(twin); err != nil {
+		return nil, err
+	}
+	saved := *twin
+	s.twins[deviceID] = &saved
+	return twin, nil
+}
+
+func (s *MemoryTwinStore) DeleteTwin(ctx context.Context, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.twins, deviceID)
+	return nil
+}
+
+// load returns a copy of the stored twin of a device, or an empty one.
+// Merges replace state maps rather than change them, so the copy can be
+// modified freely.
+func (s *MemoryTwinStore) load(deviceID string) *Twin {
+	if twin, ok := s.twins[deviceID]; ok {
+		loaded := *twin
+		return &loaded
+	}
+	return newTwin(deviceID)
+}
+
+// newTwin returns the twin of a device nothing was written to yet
+func newTwin(deviceID string) *Twin {
+	return &Twin{
+		DeviceID: deviceID,
+		Desired:  TwinDocument{State: map[string]interface{}{}},
+		Reported: TwinDocument{State: map[string]interface{}{}},
+	}
+}
+
+// TwinEntity represents a device twin in Datastore
+type TwinEntity struct {
+	DesiredJSON  string    `datastore:"desired_json,noindex"`
+	ReportedJSON string    `datastore:"reported_json,noindex"`
+	UpdatedAt    time.Time `datastore:"updated_at"`
+}
+
+// ToEntity converts a Twin to a TwinEntity
+func (t *Twin) ToEntity() (*TwinEntity, error) {
+	desiredJSON, err := json.Marshal(t.Desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal desired state: %w", err)
+	}
+	reportedJSON, err := json.Marshal(t.Reported)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reported state: %w", err)
+	}
+	return &TwinEntity{
+		DesiredJSON:  string(desiredJSON),
+		ReportedJSON: string(reportedJSON),
+		UpdatedAt:    time.Now(),
+	}, nil
+}
+
+// FromEntity converts a TwinEntity to the Twin of a device
+func (te *TwinEntity) FromEntity(deviceID string) (*Twin, error) {
+	twin := newTwin(deviceID)
+	if err := json.Unmarshal([]byte(te.DesiredJSON), &twin.Desired); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal desired state: %w", err)
+	}
+	if err := json.Unmarshal([]byte(te.ReportedJSON), &twin.Reported); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reported state: %w", err)
+	}
+	if twin.Desired.State == nil {
+		twin.Desired.State = map[string]interface{}{}
+	}
+	if twin.Reported.State == nil {
+		twin.Reported.State = map[string]interface{}{}
+	}
+	return twin, nil
+}
+
+// DatastoreTwinStore keeps device twins in Datastore, keyed by device ID
+type DatastoreTwinStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreTwinStore creates a twin store on a Datastore client
+func NewDatastoreTwinStore(client *datastore.Client) *DatastoreTwinStore {
+	return &DatastoreTwinStore{client: client}
+}
+
+func (s *DatastoreTwinStore) GetTwin(ctx context.Context, deviceID string) (*Twin, error) {
+	var entity TwinEntity
+	if err := s.client.Get(ctx, datastore.NameKey("DeviceTwin", deviceID, nil), &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return newTwin(deviceID), nil
+		}
+		return nil, fmt.Errorf("failed to retrieve twin from Datastore: %w", err)
+	}
+	return entity.FromEntity(deviceID)
+}
+
+// ModifyTwin applies modify inside a transaction. Datastore aborts the
+// transaction when another writer commits the twin first, and the write
+// is retried on the newer twin.
+func (s *DatastoreTwinStore) ModifyTwin(ctx context.Context, deviceID string, modify func(*Twin) error) (*Twin, error) {
+	key := datastore.NameKey("DeviceTwin", deviceID, nil)
+
+	var twin *Twin
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity TwinEntity
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+			var err error
+			if twin, err = entity.FromEntity(deviceID); err != nil {
+				return err
+			}
+		case datastore.ErrNoSuchEntity:
+			twin = newTwin(deviceID)
+		default:
+			return fmt.Errorf("failed to retrieve twin from Datastore: %w", err)
+		}
+
+		if err := modify(twin); err != nil {
+			return err
+		}
+
+		updated, err := twin.ToEntity()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update twin in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return twin, nil
+}
+
+func (s *DatastoreTwinStore) DeleteTwin(ctx context.Context, deviceID string) error {
+	if err := s.client.Delete(ctx, datastore.NameKey("DeviceTwin", deviceID, nil)); err != nil {
+		return fmt.Errorf("failed to delete twin from Datastore: %w", err)
+	}
+	return nil
+}
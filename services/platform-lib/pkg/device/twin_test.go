@@ -0,0 +1,152 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeState(t *testing.T) {
+	state := map[string]interface{}{
+		"led":    "off",
+		"fan":    float64(20),
+		"wifi":   map[string]interface{}{"ssid": "office", "power": float64(10)},
+		"legacy": true,
+	}
+	merged := mergeState(state, map[string]interface{}{
+		"led":    "on",
+		"wifi":   map[string]interface{}{"power": float64(15), "channel": float64(6)},
+		"legacy": nil,
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"led":  "on",
+		"fan":  float64(20),
+		"wifi": map[string]interface{}{"ssid": "office", "power": float64(15), "channel": float64(6)},
+	}, merged)
+	// The original state is left unchanged
+	assert.Equal(t, "off", state["led"])
+	assert.Equal(t, float64(10), state["wifi"].(map[string]interface{})["power"])
+}
+
+func TestTwin_Delta(t *testing.T) {
+	twin := newTwin("device-001")
+	twin.Desired = TwinDocument{Version: 4, State: map[string]interface{}{
+		"led":  "on",
+		"fan":  float64(20),
+		"wifi": map[string]interface{}{"ssid": "office", "power": float64(15)},
+	}}
+	twin.Reported = TwinDocument{Version: 7, State: map[string]interface{}{
+		"led":    "on",
+		"fan":    float64(10),
+		"wifi":   map[string]interface{}{"ssid": "office", "power": float64(10)},
+		"uptime": float64(3600),
+	}}
+
+	delta := twin.Delta()
+	assert.Equal(t, map[string]interface{}{
+		"fan":  float64(20),
+		"wifi": map[string]interface{}{"power": float64(15)},
+	}, delta.State)
+	assert.Equal(t, int64(4), delta.DesiredVersion)
+	assert.Equal(t, int64(7), delta.ReportedVersion)
+}
+
+func TestTwinDocument_ApplyPatch(t *testing.T) {
+	now := time.Now()
+	doc := newTwin("device-001").Desired
+	require.NoError(t, doc.applyPatch(&TwinPatch{State: map[string]interface{}{"led": "on"}}, now))
+	assert.Equal(t, int64(1), doc.Version)
+	require.NotNil(t, doc.UpdatedAt)
+
+	// Patches that change nothing keep the version
+	require.NoError(t, doc.applyPatch(&TwinPatch{State: map[string]interface{}{"led": "on", "fan": nil}}, now))
+	assert.Equal(t, int64(1), doc.Version)
+
+	stale := int64(0)
+	err := doc.applyPatch(&TwinPatch{State: map[string]interface{}{"led": "off"}, Version: &stale}, now)
+	assert.True(t, errors.Is(err, ErrTwinVersionConflict))
+	assert.Equal(t, "on", doc.State["led"])
+
+	current := int64(1)
+	require.NoError(t, doc.applyPatch(&TwinPatch{State: map[string]interface{}{"led": "off"}, Version: &current}, now))
+	assert.Equal(t, int64(2), doc.Version)
+}
+
+func TestService_Twin(t *testing.T) {
+	service, mockRepo := setupTestService()
+	service.twins = NewMemoryTwinStore()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	deviceID := "device-001"
+	device := createTestDevice(deviceID)
+	device.Credentials = map[string]*Credential{
+		DeviceTokenCredential: {Type: CredentialTypeToken, Fingerprint: fingerprint([]byte("device-token")), ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	mockRepo.On("GetDevice", mock.Anything, deviceID).Return(device, nil)
+	mockRepo.On("GetDevice", mock.Anything, "device-404").Return(nil, errors.New("device device-404 not found"))
+
+	token := "device-token"
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/devices/"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("PATCH", deviceID+"/twin/desired", `{"state":{"led":"on","fan":20}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = send("POST", deviceID+"/twin/reported", `{"state":{"led":"on","fan":10}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var twin Twin
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &twin))
+	assert.Equal(t, int64(1), twin.Desired.Version)
+	assert.Equal(t, int64(1), twin.Reported.Version)
+
+	w = send("GET", deviceID+"/twin/delta", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var delta TwinDelta
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &delta))
+	assert.Equal(t, map[string]interface{}{"fan": float64(20)}, delta.State)
+
+	// Writers holding an old version are turned away
+	w = send("PATCH", deviceID+"/twin/desired", `{"state":{"fan":30},"version":0}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = send("GET", "device-404/twin", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("POST", deviceID+"/twin/reported", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Only the device itself reports its state
+	token = "other-token"
+	w = send("POST", deviceID+"/twin/reported", `{"state":{"led":"off"}}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	token = ""
+	w = send("POST", deviceID+"/twin/reported", `{"state":{"led":"off"}}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send("POST", "device-404/twin/reported", `{"state":{"led":"off"}}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	stored, err := service.twins.GetTwin(context.Background(), deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, float64(20), stored.Desired.State["fan"])
+	assert.Equal(t, "on", stored.Reported.State["led"])
+}
@@ -83,7 +83,7 @@ func (sr *ServiceRegistry) RegisterService(instance *ServiceInstance) error {
 		sr.services[instance.Name] = append(sr.services[instance.Name], instance)
 	}
 
-	sr.logger.Infof("Registered service instance: %s (%s:%d)", instance.ID, instance.Address, instance.Port)
+	sr.logger.Info("Registered service instance", "instance_id", instance.ID, "address", instance.Address, "port", instance.Port)
 	return nil
 }
 
@@ -100,7 +100,7 @@ func (sr *ServiceRegistry) DeregisterService(serviceName, instanceID string) err
 	for i, instance := range instances {
 		if instance.ID == instanceID {
 			sr.services[serviceName] = append(instances[:i], instances[i+1:]...)
-			sr.logger.Infof("Deregistered service instance: %s", instanceID)
+			sr.logger.Info("Deregistered service instance", "instance_id", instanceID)
 			return nil
 		}
 	}
@@ -207,7 +207,7 @@ func (sr *ServiceRegistry) markInstanceHealthy(serviceName, instanceID string) {
 			if instance.Status != "healthy" {
 				instance.Status = "healthy"
 				instance.LastSeen = time.Now()
-				sr.logger.Infof("Service instance %s marked as healthy", instanceID)
+				sr.logger.Info("Service instance marked as healthy", "instance_id", instanceID)
 			}
 			return
 		}
@@ -228,7 +228,7 @@ func (sr *ServiceRegistry) markInstanceUnhealthy(serviceName, instanceID string)
 		if instance.ID == instanceID {
 			if instance.Status != "unhealthy" {
 				instance.Status = "unhealthy"
-				sr.logger.Warnf("Service instance %s marked as unhealthy", instanceID)
+				sr.logger.Warn("Service instance marked as unhealthy", "instance_id", instanceID)
 			}
 			return
 		}
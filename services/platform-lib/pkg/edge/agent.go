@@ -0,0 +1,399 @@
+package edge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// Config configures an edge agent
+type Config struct {
+	// ServerURL is the base URL of the API gateway
+	ServerURL string
+	DeviceID  string
+	Token     string
+	BufferDir string
+	// MaxRecords bounds the buffer; the oldest records are dropped beyond it
+	MaxRecords int
+	// BatchSize is the number of records per upload
+	BatchSize int
+	// SyncInterval is how often the buffer is uploaded while connected
+	SyncInterval time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	HTTPClient   *http.Client
+}
+
+func (c *Config) setDefaults() {
+	if c.MaxRecords == 0 {
+		c.MaxRecords = 1000000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = 10 * time.Second
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = time.Second
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 5 * time.Minute
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+}
+
+// SyncError is a failed upload
+type SyncError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("sync failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// rejected reports whether the service can never store the batch
+func (e *SyncError) rejected() bool {
+	return e.StatusCode == http.StatusBadRequest
+}
+
+// syncResponse is the service's answer to an upload
+type syncResponse struct {
+	Acked      uint64 `json:"acked"`
+	Stored     int    `json:"stored"`
+	Duplicates int    `json:"duplicates"`
+}
+
+// Status reports the state of an agent
+type Status struct {
+	DeviceID   string     `json:"device_id"`
+	Stream     string     `json:"stream"`
+	Buffered   int        `json:"buffered"`
+	Dropped    uint64     `json:"dropped"`
+	Rejected   uint64     `json:"rejected"`
+	Duplicates uint64     `json:"duplicates"`
+	LastSync   *time.Time `json:"last_sync,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// Agent buffers readings and uploads them to the telemetry service
+type Agent struct {
+	config Config
+	logger *logger.Logger
+	buffer *Buffer
+	wake   chan struct{}
+
+	mu         sync.Mutex
+	rejected   uint64
+	duplicates uint64
+	lastSync   *time.Time
+	lastError  string
+}
+
+// NewAgent opens the agent's buffer
+func NewAgent(config Config, logger *logger.Logger) (*Agent, error) {
+	if config.ServerURL == "" || config.DeviceID == "" || config.Token == "" {
+		return nil, errors.New("server URL, device ID and token are required")
+	}
+	if config.BufferDir == "" {
+		return nil, errors.New("buffer directory is required")
+	}
+	config.setDefaults()
+
+	buffer, err := OpenBuffer(config.BufferDir, config.MaxRecords)
+	if err != nil {
+		return nil, err
+	}
+	return &Agent{
+		config: config,
+		logger: logger,
+		buffer: buffer,
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Record buffers a reading of the gateway. A zero timestamp is the current
+// time.
+func (a *Agent) Record(timestamp time.Time, metrics map[string]interface{}, tags map[string]string) error {
+	return a.RecordFor("", timestamp, metrics, tags)
+}
+
+// RecordFor buffers a reading of a device behind the gateway. The platform
+// attributes it to that device and rejects devices that are not behind the
+// gateway. An empty deviceID is the gateway itself.
+func (a *Agent) RecordFor(deviceID string, timestamp time.Time, metrics map[string]interface{}, tags map[string]string) error {
+	if len(metrics) == 0 {
+		return errors.New("a reading needs at least one metric")
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if deviceID == a.config.DeviceID {
+		deviceID = ""
+	}
+	record := Record{DeviceID: deviceID, Timestamp: timestamp.UTC(), Metrics: metrics, Tags: tags}
+	if _, err := a.buffer.Append(record); err != nil {
+		return err
+	}
+
+	// A full batch is uploaded without waiting for the sync interval
+	if a.buffer.Len() >= a.config.BatchSize {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run uploads the buffer until ctx is done, backing off while the service
+// is unreachable
+func (a *Agent) Run(ctx context.Context) error {
+	backoff := time.Duration(0)
+	for {
+		wait := a.config.SyncInterval
+		if err := a.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			backoff = nextBackoff(backoff, a.config.MinBackoff, a.config.MaxBackoff)
+			wait = jitter(backoff)
+			a.logger.Warn("Edge sync failed, retrying", "retry_in", wait.Round(time.Millisecond), "error", err)
+		} else {
+			backoff = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-a.wake:
+			// Wake-ups while backing off wait for the backoff to pass
+			if backoff > 0 {
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// nextBackoff doubles the backoff between min and max
+func nextBackoff(current, min, max time.Duration) time.Duration {
+	if current < min {
+		return min
+	}
+	if current*2 > max {
+		return max
+	}
+	return current * 2
+}
+
+// jitter spreads a backoff over its upper half
+func jitter(backoff time.Duration) time.Duration {
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Sync uploads the buffer in batches until it is empty or an upload fails
+func (a *Agent) Sync(ctx context.Context) error {
+	if err := a.buffer.Sync(); err != nil {
+		a.logger.Warn("Failed to flush edge buffer", "error", err)
+	}
+
+	for {
+		batch := a.buffer.Pending(a.config.BatchSize)
+		if len(batch) == 0 {
+			a.recordSync(nil)
+			return nil
+		}
+
+		response, err := a.upload(ctx, batch)
+		var syncErr *SyncError
+		if errors.As(err, &syncErr) && syncErr.rejected() {
+			// Nothing in the batch can be stored, and keeping it would block
+			// the rest of the buffer
+			a.logger.Error("Dropping records rejected by the service", "records", len(batch), "reason", syncErr.Message)
+			a.mu.Lock()
+			a.rejected += uint64(len(batch))
+			a.mu.Unlock()
+			if err := a.buffer.Ack(batch[len(batch)-1].Seq); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			a.recordSync(err)
+			return err
+		}
+
+		a.mu.Lock()
+		a.duplicates += uint64(response.Duplicates)
+		a.mu.Unlock()
+		if err := a.buffer.Ack(response.Acked); err != nil {
+			return err
+		}
+		if response.Acked < batch[len(batch)-1].Seq {
+			err := fmt.Errorf("service acknowledged %d of records up to %d", response.Acked, batch[len(batch)-1].Seq)
+			a.recordSync(err)
+			return err
+		}
+	}
+}
+
+func (a *Agent) recordSync(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.lastError = err.Error()
+		return
+	}
+	now := time.Now()
+	a.lastSync = &now
+	a.lastError = ""
+}
+
+func (a *Agent) upload(ctx context.Context, batch []Record) (*syncResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"stream": a.buffer.Stream(), "records": batch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	endpoint := strings.TrimRight(a.config.ServerURL, "/") + "/api/v1/telemetry/sync/" + url.PathEscape(a.config.DeviceID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.config.Token)
+
+	resp, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var problem struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &problem) == nil && problem.Error != "" {
+			message = problem.Error
+			if problem.Details != "" {
+				message += ": " + problem.Details
+			}
+		}
+		return nil, &SyncError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	var response syncResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode sync response: %w", err)
+	}
+	return &response, nil
+}
+
+// Status reports the buffer and the outcome of the last sync
+func (a *Agent) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return Status{
+		DeviceID:   a.config.DeviceID,
+		Stream:     a.buffer.Stream(),
+		Buffered:   a.buffer.Len(),
+		Dropped:    a.buffer.Dropped(),
+		Rejected:   a.rejected,
+		Duplicates: a.duplicates,
+		LastSync:   a.lastSync,
+		LastError:  a.lastError,
+	}
+}
+
+// reading is a reading posted by a local sensor. Sensors that are devices
+// of their own set device_id.
+type reading struct {
+	DeviceID  string                 `json:"device_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	Tags      map[string]string      `json:"tags,omitempty"`
+}
+
+// Handler serves the agent's local API: local sensors POST readings to
+// /telemetry, either one reading or {"readings": [...]}, and GET /status
+// reports the buffer. It is meant to listen on the gateway's loopback or
+// local network only.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /telemetry", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			reading
+			Readings []reading `json:"readings"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid reading: " + err.Error()})
+			return
+		}
+		readings := body.Readings
+		if len(readings) == 0 {
+			readings = []reading{body.reading}
+		}
+		for _, entry := range readings {
+			if len(entry.Metrics) == 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a reading needs at least one metric"})
+				return
+			}
+		}
+		for _, entry := range readings {
+			if err := a.RecordFor(entry.DeviceID, entry.Timestamp, entry.Metrics, entry.Tags); err != nil {
+				a.logger.Error("Failed to buffer reading", "error", err)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to buffer reading"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusAccepted, map[string]int{"buffered": len(readings)})
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.Status())
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Close flushes the buffer to disk
+func (a *Agent) Close() error {
+	return a.buffer.Close()
+}
@@ -0,0 +1,251 @@
+package edge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/telemetry"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storedTelemetry is a telemetry repository that records batch writes
+type storedTelemetry struct {
+	telemetry.Repository
+	mu     sync.Mutex
+	points []*telemetry.TelemetryData
+}
+
+func (r *storedTelemetry) StoreTelemetryBatch(ctx context.Context, batch []*telemetry.TelemetryData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.points = append(r.points, batch...)
+	return nil
+}
+
+func (r *storedTelemetry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.points)
+}
+
+// gatewayDirectory knows a single gateway with a token
+type gatewayDirectory struct{}
+
+func (gatewayDirectory) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	if deviceID != "pi-1" {
+		return nil, fmt.Errorf("device not found")
+	}
+	sum := sha256.Sum256([]byte("pi-token"))
+	return &device.Device{
+		DeviceID: deviceID,
+		Credentials: map[string]*device.Credential{
+			"mqtt": {Type: device.CredentialTypeToken, Fingerprint: hex.EncodeToString(sum[:]), ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}, nil
+}
+
+func (gatewayDirectory) ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error) {
+	return nil, nil
+}
+
+// flakyHandler fails requests while down is set and, with dropResponse,
+// drops responses after the service handled the request
+type flakyHandler struct {
+	next         http.Handler
+	mu           sync.Mutex
+	down         bool
+	dropResponse bool
+	requests     int
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	down, drop := h.down, h.dropResponse
+	h.dropResponse = false
+	h.requests++
+	h.mu.Unlock()
+
+	switch {
+	case down:
+		w.WriteHeader(http.StatusBadGateway)
+	case drop:
+		h.next.ServeHTTP(httptest.NewRecorder(), r)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	default:
+		h.next.ServeHTTP(w, r)
+	}
+}
+
+func newSyncServer(t *testing.T) (*httptest.Server, *flakyHandler, *storedTelemetry) {
+	gin.SetMode(gin.TestMode)
+	repository := &storedTelemetry{}
+	service, err := telemetry.NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	service.SetDeviceDirectory(gatewayDirectory{})
+
+	router := gin.New()
+	telemetry.RegisterRoutes(router, service)
+	handler := &flakyHandler{next: router}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, handler, repository
+}
+
+func newTestAgent(t *testing.T, serverURL string) *Agent {
+	agent, err := NewAgent(Config{
+		ServerURL:  serverURL,
+		DeviceID:   "pi-1",
+		Token:      "pi-token",
+		BufferDir:  t.TempDir(),
+		BatchSize:  4,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	}, logger.New("info", "edge-agent"))
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+	return agent
+}
+
+func record(t *testing.T, agent *Agent, n int) {
+	for i := 0; i < n; i++ {
+		require.NoError(t, agent.Record(time.Time{}, map[string]interface{}{"temperature": float64(i)}, map[string]string{"sensor": "greenhouse-1"}))
+	}
+}
+
+func TestAgent_SyncAfterOutage(t *testing.T) {
+	server, handler, repository := newSyncServer(t)
+	agent := newTestAgent(t, server.URL)
+
+	handler.down = true
+	record(t, agent, 10)
+	assert.Error(t, agent.Sync(context.Background()))
+	assert.Equal(t, 10, agent.Status().Buffered)
+	assert.NotEmpty(t, agent.Status().LastError)
+
+	// Once connectivity returns the whole buffer is uploaded in batches
+	handler.mu.Lock()
+	handler.down = false
+	handler.requests = 0
+	handler.mu.Unlock()
+	require.NoError(t, agent.Sync(context.Background()))
+	handler.mu.Lock()
+	assert.Equal(t, 3, handler.requests)
+	handler.mu.Unlock()
+	assert.Equal(t, 10, repository.count())
+	status := agent.Status()
+	assert.Zero(t, status.Buffered)
+	assert.NotNil(t, status.LastSync)
+	assert.Empty(t, status.LastError)
+}
+
+func TestAgent_LostResponseIsNotStoredTwice(t *testing.T) {
+	server, handler, repository := newSyncServer(t)
+	agent := newTestAgent(t, server.URL)
+	record(t, agent, 3)
+
+	handler.dropResponse = true
+	assert.Error(t, agent.Sync(context.Background()))
+	assert.Equal(t, 3, repository.count())
+	assert.Equal(t, 3, agent.Status().Buffered)
+
+	record(t, agent, 2)
+	require.NoError(t, agent.Sync(context.Background()))
+	assert.Equal(t, 5, repository.count())
+	assert.Equal(t, uint64(3), agent.Status().Duplicates)
+}
+
+func TestAgent_Run(t *testing.T) {
+	server, handler, repository := newSyncServer(t)
+	agent := newTestAgent(t, server.URL)
+	handler.down = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- agent.Run(ctx) }()
+
+	record(t, agent, 6)
+	time.Sleep(30 * time.Millisecond)
+	handler.mu.Lock()
+	handler.down = false
+	handler.mu.Unlock()
+
+	require.Eventually(t, func() bool { return repository.count() == 6 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestAgent_RejectedBatchIsDropped(t *testing.T) {
+	var mu sync.Mutex
+	var streams []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream  string   `json:"stream"`
+			Records []Record `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		streams = append(streams, body.Stream)
+		mu.Unlock()
+		if body.Records[0].Seq == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"Invalid sync request","details":"bad metric"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"acked": body.Records[len(body.Records)-1].Seq})
+	}))
+	defer server.Close()
+
+	agent := newTestAgent(t, server.URL)
+	record(t, agent, 6)
+	require.NoError(t, agent.Sync(context.Background()))
+	status := agent.Status()
+	assert.Equal(t, uint64(4), status.Rejected)
+	assert.Zero(t, status.Buffered)
+	assert.Len(t, streams, 2)
+	assert.Equal(t, streams[0], streams[1])
+}
+
+func TestAgent_Handler(t *testing.T) {
+	agent := newTestAgent(t, "http://localhost:0")
+	handler := agent.Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/telemetry", strings.NewReader(`{"metrics":{"temperature":21.5},"tags":{"sensor":"s1"}}`)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	body := `{"readings":[{"timestamp":"2024-03-01T10:00:00Z","metrics":{"humidity":40}},{"metrics":{"humidity":41}}]}`
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/telemetry", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/telemetry", bytes.NewReader([]byte(`{"metrics":{}}`))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	pending := agent.buffer.Pending(0)
+	require.Len(t, pending, 3)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), pending[1].Timestamp)
+	assert.False(t, pending[0].Timestamp.IsZero())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, 3, status.Buffered)
+	assert.Equal(t, "pi-1", status.DeviceID)
+}
@@ -0,0 +1,310 @@
+package edge
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	recordsFile = "records.jsonl"
+	stateFile   = "state.json"
+
+	// compactThreshold is the number of acknowledged records left in the
+	// records file before it is rewritten
+	compactThreshold = 1000
+)
+
+// Record is a buffered reading
+type Record struct {
+	Seq       uint64                 `json:"seq"`
+	DeviceID  string                 `json:"device_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	Tags      map[string]string      `json:"tags,omitempty"`
+}
+
+// bufferState is persisted next to the records
+type bufferState struct {
+	Stream string `json:"stream"`
+	Acked  uint64 `json:"acked"`
+}
+
+// Buffer is a durable queue of records. Records are appended to a
+// JSON-lines file and kept in memory until acknowledged. Acknowledged
+// records are removed from the file in bulk.
+type Buffer struct {
+	mu         sync.Mutex
+	dir        string
+	maxRecords int
+	state      bufferState
+	records    []Record
+	nextSeq    uint64
+	file       *os.File
+	// onDisk is the number of records in the file, acknowledged or not
+	onDisk  int
+	dropped uint64
+}
+
+// OpenBuffer opens the buffer in dir, creating it if needed. maxRecords
+// bounds the unacknowledged records kept; zero means no bound.
+func OpenBuffer(dir string, maxRecords int) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+	b := &Buffer{dir: dir, maxRecords: maxRecords}
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		stream := make([]byte, 16)
+		if _, err := rand.Read(stream); err != nil {
+			return nil, err
+		}
+		b.state.Stream = hex.EncodeToString(stream)
+		if err := b.saveState(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read buffer state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &b.state); err != nil || b.state.Stream == "" {
+			return nil, fmt.Errorf("corrupt buffer state in %s", dir)
+		}
+	}
+
+	clean, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	b.nextSeq = b.state.Acked + 1
+	if n := len(b.records); n > 0 && b.records[n-1].Seq >= b.nextSeq {
+		b.nextSeq = b.records[n-1].Seq + 1
+	}
+
+	// A torn last line from a power cut, or acknowledged records, are
+	// removed before anything is appended
+	if !clean || b.onDisk > len(b.records) {
+		if err := b.rewrite(); err != nil {
+			return nil, err
+		}
+	} else if err := b.openFile(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load reads the unacknowledged records of the records file. It reports
+// false if the file had lines that could not be read.
+func (b *Buffer) load() (bool, error) {
+	file, err := os.Open(filepath.Join(b.dir, recordsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open buffer: %w", err)
+	}
+	defer file.Close()
+
+	clean := true
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Seq == 0 {
+			clean = false
+			continue
+		}
+		b.onDisk++
+		if record.Seq > b.state.Acked {
+			b.records = append(b.records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read buffer: %w", err)
+	}
+	return clean, nil
+}
+
+func (b *Buffer) openFile() error {
+	file, err := os.OpenFile(filepath.Join(b.dir, recordsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open buffer: %w", err)
+	}
+	b.file = file
+	return nil
+}
+
+// rewrite replaces the records file with the unacknowledged records
+func (b *Buffer) rewrite() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range b.records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+	if err := writeFileAtomic(filepath.Join(b.dir, recordsFile), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compact buffer: %w", err)
+	}
+	b.onDisk = len(b.records)
+	return b.openFile()
+}
+
+func (b *Buffer) saveState() error {
+	data, err := json.Marshal(b.state)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(b.dir, stateFile), data); err != nil {
+		return fmt.Errorf("failed to save buffer state: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file so that readers see either the old or
+// the new content, even across a power cut
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// Append buffers a reading and returns its record
+func (b *Buffer) Append(record Record) (Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.file == nil {
+		return Record{}, errors.New("buffer closed")
+	}
+	record.Seq = b.nextSeq
+	line, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to encode record: %w", err)
+	}
+	if _, err := b.file.Write(append(line, '\n')); err != nil {
+		return Record{}, fmt.Errorf("failed to write record: %w", err)
+	}
+	b.nextSeq++
+	b.records = append(b.records, record)
+	b.onDisk++
+
+	// Drop the oldest tenth at once, so the file is not rewritten on
+	// every append while the buffer is full
+	if b.maxRecords > 0 && len(b.records) > b.maxRecords {
+		drop := len(b.records) - b.maxRecords + b.maxRecords/10
+		b.records = append([]Record(nil), b.records[drop:]...)
+		b.dropped += uint64(drop)
+		if err := b.rewrite(); err != nil {
+			return record, err
+		}
+	}
+	return record, nil
+}
+
+// Pending returns up to limit of the oldest unacknowledged records
+func (b *Buffer) Pending(limit int) []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit <= 0 || limit > len(b.records) {
+		limit = len(b.records)
+	}
+	return append([]Record(nil), b.records[:limit]...)
+}
+
+// Ack removes every record up to seq from the buffer
+func (b *Buffer) Ack(seq uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq <= b.state.Acked {
+		return nil
+	}
+	b.state.Acked = seq
+	if err := b.saveState(); err != nil {
+		return err
+	}
+
+	acked := 0
+	for acked < len(b.records) && b.records[acked].Seq <= seq {
+		acked++
+	}
+	b.records = b.records[acked:]
+
+	if stale := b.onDisk - len(b.records); stale >= compactThreshold || (stale > 0 && len(b.records) == 0) {
+		return b.rewrite()
+	}
+	return nil
+}
+
+// Sync flushes appended records to stable storage
+func (b *Buffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	return b.file.Sync()
+}
+
+// Stream is the ID of the buffer's record stream
+func (b *Buffer) Stream() string {
+	return b.state.Stream
+}
+
+// Len is the number of unacknowledged records
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.records)
+}
+
+// Dropped is the number of records dropped because the buffer was full
+func (b *Buffer) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Close flushes and closes the buffer
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Sync()
+	if closeErr := b.file.Close(); err == nil {
+		err = closeErr
+	}
+	b.file = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package edge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendReadings(t *testing.T, buffer *Buffer, n int) {
+	for i := 0; i < n; i++ {
+		_, err := buffer.Append(Record{Timestamp: time.Date(2024, 3, 1, 10, 0, i, 0, time.UTC), Metrics: map[string]interface{}{"temperature": 20.0 + float64(i)}})
+		require.NoError(t, err)
+	}
+}
+
+func TestBuffer_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	stream := buffer.Stream()
+	appendReadings(t, buffer, 5)
+	require.NoError(t, buffer.Ack(2))
+	require.NoError(t, buffer.Close())
+
+	// Unacknowledged records and the stream survive a restart
+	buffer, err = OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, stream, buffer.Stream())
+	pending := buffer.Pending(0)
+	require.Len(t, pending, 3)
+	assert.Equal(t, uint64(3), pending[0].Seq)
+	assert.Equal(t, 22.0, pending[0].Metrics["temperature"])
+
+	// Sequence numbers continue after the last record
+	record, err := buffer.Append(Record{Timestamp: time.Now(), Metrics: map[string]interface{}{"temperature": 1.0}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), record.Seq)
+	require.NoError(t, buffer.Close())
+
+	// A new buffer starts a new stream
+	other, err := OpenBuffer(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer other.Close()
+	assert.NotEqual(t, stream, other.Stream())
+}
+
+func TestBuffer_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	appendReadings(t, buffer, 2)
+	require.NoError(t, buffer.Close())
+
+	// A power cut left half a line behind
+	file, err := os.OpenFile(filepath.Join(dir, recordsFile), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"seq":3,"timest`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	buffer, err = OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	appendReadings(t, buffer, 1)
+	require.NoError(t, buffer.Close())
+
+	buffer, err = OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	defer buffer.Close()
+	pending := buffer.Pending(0)
+	require.Len(t, pending, 3)
+	assert.Equal(t, uint64(3), pending[2].Seq)
+}
+
+func TestBuffer_AckCompacts(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 0)
+	require.NoError(t, err)
+	defer buffer.Close()
+	appendReadings(t, buffer, 3)
+
+	require.NoError(t, buffer.Ack(3))
+	assert.Zero(t, buffer.Len())
+	info, err := os.Stat(filepath.Join(dir, recordsFile))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// Acknowledging again is harmless
+	require.NoError(t, buffer.Ack(1))
+	assert.Empty(t, buffer.Pending(10))
+}
+
+func TestBuffer_DropsOldestWhenFull(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 20)
+	require.NoError(t, err)
+	appendReadings(t, buffer, 21)
+
+	// The oldest tenth is dropped at once
+	assert.Equal(t, 18, buffer.Len())
+	assert.Equal(t, uint64(3), buffer.Dropped())
+	assert.Equal(t, uint64(4), buffer.Pending(1)[0].Seq)
+	require.NoError(t, buffer.Close())
+
+	buffer, err = OpenBuffer(dir, 20)
+	require.NoError(t, err)
+	defer buffer.Close()
+	assert.Equal(t, 18, buffer.Len())
+}
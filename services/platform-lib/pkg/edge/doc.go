@@ -0,0 +1,60 @@
+// Package edge is the buffering agent for edge gateways, such as a
+// Raspberry Pi collecting readings from local sensors. The agent writes
+// every reading to a buffer on disk and uploads the buffer to the telemetry
+// service whenever it is reachable, so readings taken while offline are
+// kept and delivered once connectivity returns.
+//
+// # Sync protocol
+//
+// The buffer is a stream of records. Each record has a sequence number
+// that increases by one per record and never repeats within the stream.
+// The stream is named by a random ID chosen when the buffer is created; a
+// new buffer, e.g. after the gateway's storage was wiped, starts a new
+// stream with sequence numbers starting at 1 again.
+//
+// The agent uploads the oldest unacknowledged records, in order and in
+// batches, to
+//
+//	POST /api/v1/telemetry/sync/{device_id}
+//	Authorization: Bearer {device token}
+//
+//	{"stream": "9f2c...", "records": [
+//	  {"seq": 41, "timestamp": "2024-03-01T10:00:00Z", "metrics": {"temperature": 21.5}, "tags": {"sensor": "greenhouse-1"}},
+//	  {"seq": 42, "timestamp": "2024-03-01T10:00:10Z", "metrics": {"temperature": 21.6}}
+//	]}
+//
+// The service stores the records and answers
+//
+//	{"acked": 42, "stored": 2, "duplicates": 0}
+//
+// acknowledging every record of the stream up to acked. The agent then
+// deletes those records from its buffer. Timestamps are those of the
+// readings, not of the upload.
+//
+// The service remembers the highest sequence number it stored per stream.
+// Records at or below it are duplicates, typically resent because the
+// response to an earlier upload was lost, and are counted but not stored
+// again. Uploads of one gateway are handled one at a time, so a retry
+// racing the request it retries is also detected.
+//
+// Failed uploads are retried with exponential backoff and jitter, so a
+// fleet of gateways regaining connectivity together does not overwhelm the
+// service. A 400 response means the batch can never be stored; the agent
+// logs and drops it so the rest of the buffer is not blocked behind it.
+// Every other failure keeps the batch for the next attempt.
+//
+// # Child devices
+//
+// Sensors that are registered devices of their own, with the gateway as
+// their parent, are carried by the gateway. Their records set device_id:
+//
+//	{"seq": 43, "device_id": "soil-probe-7", "metrics": {"moisture": 31}}
+//
+// The service attributes such records to the child and tags them with
+// gateway_id. A batch naming a device that is not behind the gateway is
+// rejected with 400.
+//
+// When the buffer reaches its size limit the oldest records are dropped.
+// Sequence numbers of dropped records are never reused, and gaps in a
+// stream are accepted by the service.
+package edge
@@ -0,0 +1,132 @@
+package environments
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/athena/platform-lib/pkg/config"
+)
+
+var (
+	// ErrUnknownEnvironment is returned for environments not in the
+	// pipeline
+	ErrUnknownEnvironment = errors.New("unknown environment")
+
+	// ErrInvalidPromotion is returned for promotions that skip or go back
+	// along the pipeline, and for resources not in the environment they are
+	// promoted from
+	ErrInvalidPromotion = errors.New("invalid promotion")
+)
+
+// DefaultNames is the pipeline used when none is configured
+var DefaultNames = []string{"dev", "staging", "prod"}
+
+// Resource kinds of a promotion
+const (
+	KindTemplate = "template"
+	KindRelease  = "release"
+)
+
+// PromotionRequest promotes a resource from one environment to the next
+type PromotionRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// Resource is a resource a promotion reached. Promoted is false for
+// resources that were already in the target environment.
+type Resource struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	Version  string `json:"version,omitempty"`
+	Promoted bool   `json:"promoted"`
+}
+
+// Promotion is the outcome of promoting a resource: the resource and the
+// resources it depends on, dependencies first
+type Promotion struct {
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Resources []Resource `json:"resources"`
+}
+
+// Pipeline is the ordered list of environments resources are promoted
+// through, e.g. dev, staging and prod. Resources are created in the first
+// environment and promoted one environment at a time.
+type Pipeline struct {
+	names []string
+}
+
+// NewPipeline creates a pipeline of names, or of DefaultNames when empty
+func NewPipeline(names []string) *Pipeline {
+	if len(names) == 0 {
+		names = DefaultNames
+	}
+	return &Pipeline{names: slices.Clone(names)}
+}
+
+// NewPipelineFromConfig creates the pipeline set by environments.names
+func NewPipelineFromConfig(cfg *config.Config) *Pipeline {
+	if cfg == nil {
+		return NewPipeline(nil)
+	}
+	return NewPipeline(cfg.Environments.Names)
+}
+
+// Names returns the environments in promotion order
+func (p *Pipeline) Names() []string {
+	return slices.Clone(p.names)
+}
+
+// Initial returns the environment new resources are created in
+func (p *Pipeline) Initial() string {
+	return p.names[0]
+}
+
+// Validate checks that name is an environment of the pipeline
+func (p *Pipeline) Validate(name string) error {
+	if !slices.Contains(p.names, name) {
+		return fmt.Errorf("%w: %q", ErrUnknownEnvironment, name)
+	}
+	return nil
+}
+
+// Includes reports whether a resource in environments is in name.
+// Resources recorded without environments predate them and are in the
+// initial one.
+func (p *Pipeline) Includes(environments []string, name string) bool {
+	if len(environments) == 0 {
+		return name == p.Initial()
+	}
+	return slices.Contains(environments, name)
+}
+
+// CheckPromotion checks that to directly follows from
+func (p *Pipeline) CheckPromotion(from, to string) error {
+	if err := p.Validate(from); err != nil {
+		return err
+	}
+	if err := p.Validate(to); err != nil {
+		return err
+	}
+	next := slices.Index(p.names, from) + 1
+	if next == len(p.names) || p.names[next] != to {
+		return fmt.Errorf("%w: %s cannot be promoted to %s", ErrInvalidPromotion, from, to)
+	}
+	return nil
+}
+
+// Add returns environments with name added, recording the initial
+// environment of resources that predate environments
+func (p *Pipeline) Add(environments []string, name string) []string {
+	if len(environments) == 0 {
+		environments = []string{p.Initial()}
+	} else {
+		environments = slices.Clone(environments)
+	}
+	if !slices.Contains(environments, name) {
+		environments = append(environments, name)
+	}
+	return environments
+}
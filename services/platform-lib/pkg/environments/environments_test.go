@@ -0,0 +1,41 @@
+package environments
+
+import (
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline_CheckPromotion(t *testing.T) {
+	pipeline := NewPipelineFromConfig(&config.Config{})
+	assert.Equal(t, DefaultNames, pipeline.Names())
+	assert.Equal(t, "dev", pipeline.Initial())
+
+	assert.NoError(t, pipeline.CheckPromotion("dev", "staging"))
+	assert.NoError(t, pipeline.CheckPromotion("staging", "prod"))
+	assert.ErrorIs(t, pipeline.CheckPromotion("dev", "prod"), ErrInvalidPromotion)
+	assert.ErrorIs(t, pipeline.CheckPromotion("prod", "staging"), ErrInvalidPromotion)
+	assert.ErrorIs(t, pipeline.CheckPromotion("prod", "prod"), ErrInvalidPromotion)
+	assert.ErrorIs(t, pipeline.CheckPromotion("dev", "qa"), ErrUnknownEnvironment)
+
+	custom := NewPipeline([]string{"qa", "live"})
+	assert.Equal(t, "qa", custom.Initial())
+	assert.NoError(t, custom.CheckPromotion("qa", "live"))
+	assert.ErrorIs(t, custom.Validate("dev"), ErrUnknownEnvironment)
+}
+
+func TestPipeline_IncludesAndAdd(t *testing.T) {
+	pipeline := NewPipeline(nil)
+
+	// Resources without environments predate them and are in the first one
+	assert.True(t, pipeline.Includes(nil, "dev"))
+	assert.False(t, pipeline.Includes(nil, "staging"))
+	assert.Equal(t, []string{"dev", "staging"}, pipeline.Add(nil, "staging"))
+
+	environments := []string{"dev", "staging"}
+	assert.True(t, pipeline.Includes(environments, "staging"))
+	assert.Equal(t, []string{"dev", "staging", "prod"}, pipeline.Add(environments, "prod"))
+	assert.Equal(t, []string{"dev", "staging"}, pipeline.Add(environments, "staging"))
+	assert.Equal(t, []string{"dev", "staging"}, environments)
+}
@@ -0,0 +1,253 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves the operational task API. Requests are forwarded,
+// signed, to the admin API of each service; listings are gathered from
+// every service that serves one.
+type AdminHandler struct {
+	services map[string]string
+	secret   []byte
+	client   *http.Client
+	logger   *logger.Logger
+}
+
+// NewAdminHandler creates the handler
+func NewAdminHandler(cfg *config.Config, log *logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		services: cfg.Services,
+		secret:   []byte(cfg.JWTSecret),
+		client:   &http.Client{Timeout: 30 * time.Second},
+		logger:   log,
+	}
+}
+
+// RegisterRoutes registers the admin API under /admin/tasks, /admin/jobs and
+// /admin/log-levels. The caller is responsible for authentication.
+func (h *AdminHandler) RegisterRoutes(router *gin.RouterGroup) {
+	adminGroup := router.Group("/admin")
+	{
+		adminGroup.GET("/tasks", h.listTasks)
+		adminGroup.POST("/tasks/:service/:task", h.startTask)
+		adminGroup.GET("/jobs", h.listJobs)
+		adminGroup.GET("/jobs/:service/:id", h.getJob)
+		adminGroup.GET("/log-levels", h.listLogLevels)
+		adminGroup.PUT("/log-levels/:service", h.setLogLevel)
+	}
+}
+
+// listTasks lists the tasks of every service
+func (h *AdminHandler) listTasks(c *gin.Context) {
+	tasks := []admin.TaskInfo{}
+	unavailable := h.gather(c.Request.Context(), c.Query("service"), "/admin/tasks", func(body []byte) error {
+		var resp struct {
+			Tasks []admin.TaskInfo `json:"tasks"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return err
+		}
+		tasks = append(tasks, resp.Tasks...)
+		return nil
+	})
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Service < tasks[j].Service })
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks, "count": len(tasks), "unavailable": unavailable})
+}
+
+// listJobs lists the jobs of every service, newest first
+func (h *AdminHandler) listJobs(c *gin.Context) {
+	jobs := []*admin.Job{}
+	unavailable := h.gather(c.Request.Context(), c.Query("service"), "/admin/jobs", func(body []byte) error {
+		var resp struct {
+			Jobs []*admin.Job `json:"jobs"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return err
+		}
+		jobs = append(jobs, resp.Jobs...)
+		return nil
+	})
+	admin.SortJobs(jobs)
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs), "unavailable": unavailable})
+}
+
+func (h *AdminHandler) startTask(c *gin.Context) {
+	var req admin.StartRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	req.RequestedBy = c.GetString("username")
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build request"})
+		return
+	}
+	service := c.Param("service")
+	h.logger.Warn("Admin task started", "task", c.Param("task"), "service", service, "requested_by", req.RequestedBy)
+	h.forward(c, service, http.MethodPost, "/admin/tasks/"+url.PathEscape(c.Param("task")), body)
+}
+
+func (h *AdminHandler) getJob(c *gin.Context) {
+	h.forward(c, c.Param("service"), http.MethodGet, "/admin/jobs/"+url.PathEscape(c.Param("id")), nil)
+}
+
+// listLogLevels lists the log levels of every service, the gateway's own
+// included
+func (h *AdminHandler) listLogLevels(c *gin.Context) {
+	levels := map[string]logger.Levels{}
+	service := c.Query("service")
+	if service == "" || service == "api-gateway" {
+		levels["api-gateway"] = h.logger.Levels()
+	}
+	unavailable := h.gather(c.Request.Context(), service, "/admin/log-levels", func(body []byte) error {
+		var resp struct {
+			Service   string        `json:"service"`
+			LogLevels logger.Levels `json:"log_levels"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return err
+		}
+		levels[resp.Service] = resp.LogLevels
+		return nil
+	})
+	c.JSON(http.StatusOK, gin.H{"log_levels": levels, "unavailable": unavailable})
+}
+
+// setLogLevel changes a log level of a service, or of the gateway itself
+func (h *AdminHandler) setLogLevel(c *gin.Context) {
+	var req admin.LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	req.RequestedBy = c.GetString("username")
+
+	service := c.Param("service")
+	if service == "api-gateway" {
+		if err := h.logger.SetComponentLevel(req.Component, req.Level); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Info("Log level changed", "log_component", req.Component, "level", req.Level, "requested_by", req.RequestedBy)
+		c.JSON(http.StatusOK, gin.H{"service": service, "log_levels": h.logger.Levels()})
+		return
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build request"})
+		return
+	}
+	h.forward(c, service, http.MethodPut, "/admin/log-levels", body)
+}
+
+// forward sends a signed request to a service's admin API and relays the
+// response
+func (h *AdminHandler) forward(c *gin.Context, service, method, path string, body []byte) {
+	if service == "api-gateway" || h.services[service] == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown service: " + service})
+		return
+	}
+
+	resp, err := h.do(c.Request.Context(), method, h.services[service]+path, body)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach " + service, "details": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	c.Status(resp.StatusCode)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	io.Copy(c.Writer, resp.Body)
+}
+
+// gather fetches path from every service, or only from service when set,
+// in parallel and hands each successful response to collect. It returns
+// the services that could not be reached; services without an admin API
+// are skipped.
+func (h *AdminHandler) gather(ctx context.Context, service, path string, collect func(body []byte) error) []string {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	unavailable := []string{}
+	for name, baseURL := range h.services {
+		if name == "api-gateway" || baseURL == "" || (service != "" && name != service) {
+			continue
+		}
+		wg.Add(1)
+		go func(name, baseURL string) {
+			defer wg.Done()
+			body, status, err := h.get(ctx, baseURL+path)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if status == http.StatusNotFound {
+				return
+			}
+			if err == nil {
+				err = collect(body)
+			}
+			if err != nil {
+				h.logger.Warn("Failed to query admin API", "service", name, "error", err)
+				unavailable = append(unavailable, name)
+			}
+		}(name, baseURL)
+	}
+	wg.Wait()
+	sort.Strings(unavailable)
+	return unavailable
+}
+
+func (h *AdminHandler) get(ctx context.Context, target string) ([]byte, int, error) {
+	resp, err := h.do(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return body, resp.StatusCode, nil
+}
+
+func (h *AdminHandler) do(ctx context.Context, method, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	admin.SignRequest(req, h.secret, body, time.Now())
+	return h.client.Do(req)
+}
@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_AdminAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// A device service with admin tasks, as its main wires it
+	backend := gin.New()
+	runner := admin.Setup(backend, &config.Config{ServiceName: "device-service", JWTSecret: testJWTSecret}, logger.New("info", "device-service"), admin.Task{
+		Name:        "reindex",
+		Description: "Rewrite every device",
+		Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+			return admin.Result{"reindexed": 2, "dry_run": params["dry_run"]}, nil
+		},
+	})
+	deviceService := httptest.NewServer(backend)
+	defer deviceService.Close()
+
+	// A template service without an admin API, and a service that is down
+	templateService := httptest.NewServer(gin.New())
+	defer templateService.Close()
+	downService := httptest.NewServer(gin.New())
+	downService.Close()
+
+	cfg := &config.Config{
+		ServiceName: "api-gateway",
+		JWTSecret:   testJWTSecret,
+		Services: map[string]string{
+			"device-service":    deviceService.URL,
+			"template-service":  templateService.URL,
+			"telemetry-service": downService.URL,
+		},
+	}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	adminTokens, err := gw.jwtAuth.GenerateTokenPair("user-1", "root", []string{"admin"}, nil, nil)
+	require.NoError(t, err)
+	userTokens, err := gw.jwtAuth.GenerateTokenPair("user-2", "bob", []string{"user"}, nil, nil)
+	require.NoError(t, err)
+
+	do := func(token, method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.Equal(t, http.StatusForbidden, do(userTokens.AccessToken, http.MethodGet, "/api/v1/admin/tasks", "").StatusCode)
+	assert.Equal(t, http.StatusForbidden, do(userTokens.AccessToken, http.MethodPost, "/api/v1/admin/tasks/device-service/reindex", "").StatusCode)
+
+	resp := do(adminTokens.AccessToken, http.MethodGet, "/api/v1/admin/tasks", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tasks struct {
+		Tasks       []admin.TaskInfo `json:"tasks"`
+		Unavailable []string         `json:"unavailable"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+	assert.Equal(t, []admin.TaskInfo{{Service: "device-service", Name: "reindex", Description: "Rewrite every device"}}, tasks.Tasks)
+	assert.Equal(t, []string{"telemetry-service"}, tasks.Unavailable)
+
+	resp = do(adminTokens.AccessToken, http.MethodPost, "/api/v1/admin/tasks/device-service/reindex", `{"params":{"dry_run":"true"},"requested_by":"mallory"}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var job admin.Job
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	// The gateway records who asked, whatever the request claims
+	assert.Equal(t, "root", job.RequestedBy)
+	runner.Wait()
+
+	resp = do(adminTokens.AccessToken, http.MethodGet, "/api/v1/admin/jobs/device-service/"+job.ID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	assert.Equal(t, admin.JobStatusSucceeded, job.Status)
+	assert.Equal(t, "true", job.Result["dry_run"])
+
+	resp = do(adminTokens.AccessToken, http.MethodGet, "/api/v1/admin/jobs?service=device-service", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var jobs struct {
+		Jobs        []*admin.Job `json:"jobs"`
+		Unavailable []string     `json:"unavailable"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobs))
+	require.Len(t, jobs.Jobs, 1)
+	assert.Equal(t, job.ID, jobs.Jobs[0].ID)
+	assert.Empty(t, jobs.Unavailable)
+
+	assert.Equal(t, http.StatusNotFound, do(adminTokens.AccessToken, http.MethodPost, "/api/v1/admin/tasks/device-service/vacuum", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(adminTokens.AccessToken, http.MethodPost, "/api/v1/admin/tasks/billing-service/gc", "").StatusCode)
+
+	// Log levels are changed per service and component at runtime
+	assert.Equal(t, http.StatusForbidden, do(userTokens.AccessToken, http.MethodGet, "/api/v1/admin/log-levels", "").StatusCode)
+	resp = do(adminTokens.AccessToken, http.MethodPut, "/api/v1/admin/log-levels/device-service", `{"component":"monitoring","level":"debug"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(adminTokens.AccessToken, http.MethodPut, "/api/v1/admin/log-levels/device-service", `{"level":"loud"}`).StatusCode)
+	resp = do(adminTokens.AccessToken, http.MethodPut, "/api/v1/admin/log-levels/api-gateway", `{"level":"warning"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(adminTokens.AccessToken, http.MethodGet, "/api/v1/admin/log-levels", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var levels struct {
+		LogLevels   map[string]logger.Levels `json:"log_levels"`
+		Unavailable []string                 `json:"unavailable"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&levels))
+	assert.Equal(t, map[string]string{"monitoring": "debug"}, levels.LogLevels["device-service"].Overrides)
+	assert.Equal(t, "info", levels.LogLevels["device-service"].Level)
+	assert.Equal(t, "warning", levels.LogLevels["api-gateway"].Level)
+	assert.Equal(t, []string{"telemetry-service"}, levels.Unavailable)
+
+	// Services only accept admin requests the gateway signed
+	direct, err := http.Post(deviceService.URL+"/admin/tasks/reindex", "application/json", nil)
+	require.NoError(t, err)
+	direct.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, direct.StatusCode)
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/declarative"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// maxManifestSize bounds the manifest body accepted by the apply endpoint
+const maxManifestSize = 4 << 20
+
+// ApplyHandler serves the declarative apply API used to manage fleets as
+// code (CI pipelines, Terraform and Pulumi providers)
+type ApplyHandler struct {
+	reconciler *declarative.Reconciler
+	logger     *logger.Logger
+}
+
+// NewApplyHandler creates an apply handler that reconciles every kind
+// through the service that owns it: templates, alert rules, device groups
+// and deployment policies as OTA fleet policies. Managed resources are
+// recorded in memory until SetStateStore is called.
+func NewApplyHandler(cfg *config.Config, log *logger.Logger) *ApplyHandler {
+	reconciler := declarative.NewReconciler(declarative.NewMemoryStateStore())
+	reconciler.Register(declarative.KindTemplate, declarative.NewTemplateHandler(cfg.Services["template-service"], nil))
+	reconciler.Register(declarative.KindAlertRule, declarative.NewAlertRuleHandler(cfg.Services["telemetry-service"], nil))
+	reconciler.Register(declarative.KindDeviceGroup, declarative.NewDeviceGroupHandler(cfg.Services["device-service"], nil))
+	reconciler.Register(declarative.KindDeploymentPolicy, declarative.NewDeploymentPolicyHandler(cfg.Services["ota-service"], nil))
+
+	return &ApplyHandler{reconciler: reconciler, logger: log}
+}
+
+// SetStateStore sets where managed resources are recorded
+func (h *ApplyHandler) SetStateStore(store declarative.StateStore) {
+	h.reconciler.SetStateStore(store)
+}
+
+// RegisterRoutes registers the apply routes
+func (h *ApplyHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/apply", h.Apply)
+	router.GET("/apply/resources", h.ListManaged)
+}
+
+// Apply reconciles a YAML or JSON manifest. ?dry_run=true returns the plan
+// without changing anything; ?prune=true deletes managed resources missing
+// from the manifest.
+func (h *ApplyHandler) Apply(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxManifestSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read manifest"})
+		return
+	}
+	if len(body) > maxManifestSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Manifest is too large"})
+		return
+	}
+
+	manifest, err := declarative.ParseManifest(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	prune, _ := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+
+	result, err := h.reconciler.Apply(c.Request.Context(), manifest, declarative.Options{DryRun: dryRun, Prune: prune})
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !dryRun {
+		h.logger.Info("Manifest applied", "username", c.GetString("username"), "resources", len(manifest.Resources),
+			"summary", result.Summary, "failed", result.Failed)
+	}
+
+	status := http.StatusOK
+	if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, result)
+}
+
+// ListManaged returns the resources owned by previous applies
+func (h *ApplyHandler) ListManaged(c *gin.Context) {
+	resources, err := h.reconciler.Managed(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list managed resources", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list managed resources"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resources": resources,
+		"total":     len(resources),
+	})
+}
@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/declarative"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeFleetServices serves the device group and fleet policy endpoints
+// the apply handler reconciles against. Policies for unknown groups are
+// rejected as the OTA service does.
+func newFakeFleetServices(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	groups := make(map[string][]byte)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/api/v1/device-groups" && r.Method == http.MethodPost:
+			var group struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(body, &group)
+			groups[group.Name] = body
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(r.URL.Path, "/api/v1/device-groups/") && r.Method == http.MethodGet:
+			group, ok := groups[strings.TrimPrefix(r.URL.Path, "/api/v1/device-groups/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(group)
+		case r.URL.Path == "/api/v1/ota/policies" && r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid deployment targets: unknown device group \"missing\""}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func setupApplyRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	services := newFakeFleetServices(t)
+	cfg := &config.Config{Services: map[string]string{"device-service": services.URL, "ota-service": services.URL}}
+	handler := NewApplyHandler(cfg, logger.New("info", "api-gateway"))
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+	return router
+}
+
+func postManifest(router *gin.Engine, query, manifest string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/apply"+query, strings.NewReader(manifest))
+	req.Header.Set("Content-Type", "application/yaml")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+const groupManifest = `
+api_version: athena.io/v1
+resources:
+  - kind: DeviceGroup
+    name: lab
+    spec:
+      devices: [dev-1, dev-2]
+`
+
+func TestApplyHandler_Apply(t *testing.T) {
+	router := setupApplyRouter(t)
+
+	w := postManifest(router, "?dry_run=true", groupManifest)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result declarative.Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, declarative.ActionCreate, result.Changes[0].Action)
+
+	w = postManifest(router, "", groupManifest)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = postManifest(router, "", groupManifest)
+	require.Equal(t, http.StatusOK, w.Code)
+	var reapplied declarative.Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reapplied))
+	assert.Equal(t, map[declarative.Action]int{declarative.ActionUnchanged: 1}, reapplied.Summary)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/apply/resources", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+}
+
+func TestApplyHandler_ApplyErrors(t *testing.T) {
+	router := setupApplyRouter(t)
+
+	w := postManifest(router, "", `{"api_version": "athena.io/v1", "resources": [{"kind": "Widget", "name": "x"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postManifest(router, "", `
+api_version: athena.io/v1
+resources:
+  - kind: DeploymentPolicy
+    name: rollout
+    spec: {device_group: missing, template_id: soil-monitor, strategy: canary}
+`)
+	assert.Equal(t, http.StatusMultiStatus, w.Code, "per-resource failures are reported in the result")
+	assert.Contains(t, w.Body.String(), `unknown device group \"missing\"`)
+}
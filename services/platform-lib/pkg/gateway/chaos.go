@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/chaos"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// ChaosHandler serves the fault injection admin API. Faults for the gateway
+// are applied in-process; faults for other services are forwarded to the
+// admin API each service serves when fault injection is enabled.
+type ChaosHandler struct {
+	injector *chaos.Injector
+	services map[string]string
+	client   *http.Client
+	logger   *logger.Logger
+}
+
+// NewChaosHandler creates the handler, or returns nil when fault injection
+// is disabled for cfg
+func NewChaosHandler(cfg *config.Config, log *logger.Logger) *ChaosHandler {
+	if !chaos.Enabled(cfg) {
+		return nil
+	}
+	log.Warn("Fault injection is enabled; do not use this configuration in production", "environment", cfg.Environment)
+	return &ChaosHandler{
+		injector: chaos.NewInjector("api-gateway", log),
+		services: cfg.Services,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   log,
+	}
+}
+
+// RegisterRoutes registers the admin API under /admin/chaos/:service/faults.
+// The caller is responsible for authentication.
+func (h *ChaosHandler) RegisterRoutes(router *gin.RouterGroup) {
+	faults := router.Group("/admin/chaos/:service/faults")
+	{
+		faults.POST("", h.handle)
+		faults.GET("", h.handle)
+		faults.DELETE("", h.handle)
+		faults.DELETE("/:id", h.handle)
+	}
+}
+
+func (h *ChaosHandler) handle(c *gin.Context) {
+	service := c.Param("service")
+	if service == "api-gateway" {
+		h.handleLocal(c)
+		return
+	}
+
+	baseURL := h.services[service]
+	if baseURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown service: " + service})
+		return
+	}
+	target := baseURL + "/admin/chaos/faults"
+	if id := c.Param("id"); id != "" {
+		target += "/" + url.PathEscape(id)
+	}
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build request"})
+		return
+	}
+	req.Header.Set("Content-Type", c.GetHeader("Content-Type"))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach " + service, "details": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	// Services without fault injection enabled do not serve the admin API
+	if resp.StatusCode == http.StatusNotFound && c.Param("id") == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Fault injection is not enabled on " + service})
+		return
+	}
+
+	if c.Request.Method != http.MethodGet {
+		h.logger.Warn("Fault injection changed", "service", service, "username", c.GetString("username"), "method", c.Request.Method, "path", c.Request.URL.Path)
+	}
+	c.Status(resp.StatusCode)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	io.Copy(c.Writer, resp.Body)
+}
+
+func (h *ChaosHandler) handleLocal(c *gin.Context) {
+	switch {
+	case c.Request.Method == http.MethodPost:
+		h.injector.CreateFault(c)
+	case c.Request.Method == http.MethodGet:
+		h.injector.ListFaults(c)
+	case c.Param("id") != "":
+		h.injector.DeleteFault(c)
+	default:
+		h.injector.ClearFaults(c)
+	}
+}
@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/chaos"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_ChaosAdminAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	chaosEnabled := config.ChaosConfig{Enabled: true}
+
+	// A device service with fault injection enabled, as its main wires it
+	backend := gin.New()
+	chaos.Setup(backend, &config.Config{ServiceName: "device-service", Environment: "staging", Chaos: chaosEnabled}, logger.New("info", "device-service"))
+	backend.GET("/api/v1/devices/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"device_id": c.Param("id")})
+	})
+	deviceService := httptest.NewServer(backend)
+	defer deviceService.Close()
+
+	// A template service without it
+	templateService := httptest.NewServer(gin.New())
+	defer templateService.Close()
+
+	cfg := &config.Config{
+		ServiceName: "api-gateway",
+		Environment: "staging",
+		JWTSecret:   testJWTSecret,
+		Chaos:       chaosEnabled,
+		Services: map[string]string{
+			"device-service":   deviceService.URL,
+			"template-service": templateService.URL,
+		},
+	}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Login only grants the user role, so issue an administrator token directly
+	tokens, err := gw.jwtAuth.GenerateTokenPair("user-1", "admin", []string{"admin"}, nil, nil)
+	require.NoError(t, err)
+
+	do := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// A fault created through the gateway is applied by the device service
+	resp := do(http.MethodPost, "/api/v1/admin/chaos/device-service/faults", `{"route":"/api/v1/devices/:id","error_status":503,"limit":1}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var fault chaos.Fault
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fault))
+
+	resp = do(http.MethodGet, "/api/v1/devices/dev-1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, fault.ID, resp.Header.Get(chaos.FaultHeader))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/devices/dev-1", "").StatusCode)
+
+	// Faults on the gateway itself are applied before proxying
+	resp = do(http.MethodPost, "/api/v1/admin/chaos/api-gateway/faults", `{"route":"/api/v1/devices/:id","error_status":429}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "/api/v1/devices/dev-1", "").StatusCode)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/admin/chaos/api-gateway/faults", "").StatusCode)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/devices/dev-1", "").StatusCode)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodGet, "/api/v1/admin/chaos/template-service/faults", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/admin/chaos/billing-service/faults", "").StatusCode)
+}
+
+func TestGateway_ChaosDisabledInProduction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		ServiceName: "api-gateway",
+		Environment: "production",
+		JWTSecret:   testJWTSecret,
+		Chaos:       config.ChaosConfig{Enabled: true},
+	}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	assert.Nil(t, gw.chaos)
+
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	for _, route := range router.Routes() {
+		assert.NotContains(t, route.Path, "/chaos/")
+	}
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// dashboardAssets holds the static export of the web dashboard. The dist
+// directory is populated by `make dashboard-embed` before building the gateway.
+//
+//go:embed all:dashboard/dist
+var dashboardAssets embed.FS
+
+// registerDashboardRoutes serves the embedded dashboard SPA under the configured base path
+func registerDashboardRoutes(router *gin.Engine, cfg config.DashboardConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	assets, err := fs.Sub(dashboardAssets, "dashboard/dist")
+	if err != nil {
+		return err
+	}
+
+	basePath := "/" + strings.Trim(cfg.BasePath, "/")
+	handler := newDashboardHandler(assets, basePath)
+
+	if basePath == "/" {
+		router.NoRoute(handler)
+		return nil
+	}
+
+	router.GET(basePath, handler)
+	router.HEAD(basePath, handler)
+	router.GET(basePath+"/*filepath", handler)
+	router.HEAD(basePath+"/*filepath", handler)
+	router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, basePath+"/")
+	})
+
+	return nil
+}
+
+// newDashboardHandler serves static assets, falling back to index.html so
+// client-side routes resolve on reload
+func newDashboardHandler(assets fs.FS, basePath string) gin.HandlerFunc {
+	fileServer := http.FileServer(http.FS(assets))
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		name := strings.TrimPrefix(c.Request.URL.Path, basePath)
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+		// API paths that fall through to NoRoute must not be answered with HTML
+		if basePath == "/" && (strings.HasPrefix(name, "api/") || name == "api") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		switch {
+		case name == "":
+			name = "index.html"
+		case isDashboardFile(assets, name):
+		case isDashboardFile(assets, name+".html"):
+			name += ".html"
+		case isDashboardFile(assets, path.Join(name, "index.html")):
+			name = path.Join(name, "index.html")
+		default:
+			name = "index.html"
+		}
+
+		if strings.HasSuffix(name, ".html") {
+			c.Header("Cache-Control", "no-cache")
+		} else {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		req := c.Request.Clone(c.Request.Context())
+		req.URL.Path = "/" + name
+		if path.Base(name) == "index.html" {
+			// http.FileServer redirects explicit index.html requests to the directory
+			req.URL.Path = "/" + strings.TrimSuffix(name, "index.html")
+		}
+		fileServer.ServeHTTP(c.Writer, req)
+	}
+}
+
+// isDashboardFile reports whether name is a regular file in the asset tree
+func isDashboardFile(assets fs.FS, name string) bool {
+	info, err := fs.Stat(assets, name)
+	return err == nil && !info.IsDir()
+}
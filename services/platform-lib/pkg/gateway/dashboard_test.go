@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDashboardRouter(basePath string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	assets := fstest.MapFS{
+		"index.html":          {Data: []byte("<html>index</html>")},
+		"devices.html":        {Data: []byte("<html>devices</html>")},
+		"_next/static/app.js": {Data: []byte("console.log('app')")},
+	}
+
+	router := gin.New()
+	handler := newDashboardHandler(assets, basePath)
+	router.GET(basePath+"/*filepath", handler)
+	return router
+}
+
+func TestDashboardHandler(t *testing.T) {
+	router := setupDashboardRouter("/dashboard")
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedBody string
+		cacheControl string
+	}{
+		{"root serves index", "/dashboard/", "<html>index</html>", "no-cache"},
+		{"static asset", "/dashboard/_next/static/app.js", "console.log('app')", "public, max-age=31536000, immutable"},
+		{"exported page without extension", "/dashboard/devices", "<html>devices</html>", "no-cache"},
+		{"unknown route falls back to index", "/dashboard/devices/abc/settings", "<html>index</html>", "no-cache"},
+		{"path traversal stays inside assets", "/dashboard/../../etc/passwd", "<html>index</html>", "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+			assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRegisterDashboardRoutes_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	err := registerDashboardRoutes(router, config.DashboardConfig{Enabled: false, BasePath: "/dashboard"})
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/dashboard/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRegisterDashboardRoutes_Embedded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	err := registerDashboardRoutes(router, config.DashboardConfig{Enabled: true, BasePath: "/dashboard"})
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/dashboard/", w.Header().Get("Location"))
+
+	req, _ = http.NewRequest("GET", "/dashboard/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "ATHENA Dashboard")
+}
+
+func TestRegisterDashboardRoutes_RootBasePathSkipsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	err := registerDashboardRoutes(router, config.DashboardConfig{Enabled: true, BasePath: "/"})
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/api/v1/unknown", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/devices", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,356 @@
+package gateway
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run ./editorgen -o ../../../../clients/editor-ts/src/client.ts
+
+// EditorAPIVersion is the version of the editor extension API. Endpoints and
+// types under a version only change in backwards compatible ways.
+const EditorAPIVersion = "v1"
+
+// editorBasePath is the prefix of the editor extension API
+const editorBasePath = "/api/editor/" + EditorAPIVersion
+
+// Editor API contract types. These mirror the JSON of the template and
+// provisioning services but are declared here so the editor API stays stable
+// when service internals change.
+
+// EditorSourceFile is an editable code file of a template
+type EditorSourceFile struct {
+	Path    string `json:"path"`
+	Section string `json:"section,omitempty"`
+	Content string `json:"content"`
+}
+
+// EditorLibrary is an Arduino library dependency
+type EditorLibrary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+}
+
+// EditorTemplateSource is a template opened in an editor
+type EditorTemplateSource struct {
+	TemplateID string                 `json:"template_id"`
+	Version    string                 `json:"version"`
+	Name       string                 `json:"name"`
+	Files      []EditorSourceFile     `json:"files"`
+	Schema     map[string]interface{} `json:"schema,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Libraries  []EditorLibrary        `json:"libraries,omitempty"`
+	Blocks     []string               `json:"blocks,omitempty"`
+	Generated  bool                   `json:"generated"`
+}
+
+// EditorForkRequest pushes local edits back as a new template
+type EditorForkRequest struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name,omitempty"`
+	Version     string                 `json:"version,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Files       []EditorSourceFile     `json:"files"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// EditorTemplate is the template created by a fork
+type EditorTemplate struct {
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name"`
+	Version         string                 `json:"version"`
+	Category        string                 `json:"category"`
+	Description     string                 `json:"description"`
+	BoardsSupported []string               `json:"boards_supported"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	Libraries       []EditorLibrary        `json:"libraries"`
+	ForkedFrom      string                 `json:"forked_from,omitempty"`
+}
+
+// EditorPreviewRequest renders a template without compiling it
+type EditorPreviewRequest struct {
+	Version      string                 `json:"version,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Snippets     string                 `json:"snippets,omitempty"`
+	PreviousCode string                 `json:"previous_code,omitempty"`
+}
+
+// EditorPreviewResult is a rendered sketch and its diff from a previous render
+type EditorPreviewResult struct {
+	TemplateID   string                 `json:"template_id"`
+	Version      string                 `json:"version"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	RenderedCode string                 `json:"rendered_code"`
+	Blocks       []string               `json:"blocks,omitempty"`
+	Diff         string                 `json:"diff,omitempty"`
+	Changed      bool                   `json:"changed"`
+}
+
+// EditorCompileRequest compiles the current editor buffer
+type EditorCompileRequest struct {
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version,omitempty"`
+	TemplateCode    string                 `json:"template_code"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	Schema          map[string]interface{} `json:"schema,omitempty"` // from EditorTemplateSource, types the parameters
+	Board           string                 `json:"board"`
+	Libraries       []EditorLibrary        `json:"libraries,omitempty"`
+	Overrides       map[string]string      `json:"overrides,omitempty"`
+	Snippets        string                 `json:"snippets,omitempty"`
+}
+
+// EditorDiagnostic is a compiler error or warning in the buffer
+type EditorDiagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+	Type    string `json:"type,omitempty"`
+}
+
+// EditorBinarySize reports program and data usage of a compiled sketch
+type EditorBinarySize struct {
+	ProgramSize int `json:"program_size"`
+	DataSize    int `json:"data_size"`
+	MaxProgram  int `json:"max_program"`
+	MaxData     int `json:"max_data"`
+}
+
+// EditorCompileResult is the outcome of compiling a buffer
+type EditorCompileResult struct {
+	Success    bool               `json:"success"`
+	ArtifactID string             `json:"artifact_id,omitempty"`
+	Duration   string             `json:"duration"`
+	CacheHit   bool               `json:"cache_hit,omitempty"`
+	BinaryHash string             `json:"binary_hash,omitempty"`
+	Size       *EditorBinarySize  `json:"size,omitempty"`
+	Errors     []EditorDiagnostic `json:"errors,omitempty"`
+	Warnings   []EditorDiagnostic `json:"warnings,omitempty"`
+}
+
+// EditorFlashRequest flashes a compiled artifact to a device
+type EditorFlashRequest struct {
+	Port        string `json:"port"`
+	Board       string `json:"board"`
+	ArtifactID  string `json:"artifact_id"`
+	VerifyFlash bool   `json:"verify_flash"`
+	HealthCheck bool   `json:"health_check"`
+}
+
+// EditorFlashError describes a failed flash step
+type EditorFlashError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// EditorFlashResult is the outcome of flashing a device
+type EditorFlashResult struct {
+	Success      bool                   `json:"success"`
+	Port         string                 `json:"port,omitempty"`
+	Board        string                 `json:"board,omitempty"`
+	Duration     string                 `json:"duration"`
+	VerifyResult map[string]interface{} `json:"verify_result,omitempty"`
+	HealthCheck  map[string]interface{} `json:"health_check,omitempty"`
+	Errors       []EditorFlashError     `json:"errors,omitempty"`
+}
+
+// EditorPortList lists serial ports available for flashing
+type EditorPortList struct {
+	Ports []string `json:"ports"`
+	Count int      `json:"count"`
+}
+
+// EditorEndpointInfo describes one editor API endpoint
+type EditorEndpointInfo struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// EditorAPIInfo lets extensions discover the API version and endpoints
+type EditorAPIInfo struct {
+	APIVersion string               `json:"api_version"`
+	BasePath   string               `json:"base_path"`
+	Endpoints  []EditorEndpointInfo `json:"endpoints"`
+}
+
+// editorEndpoint maps an editor API route onto a platform service route. The
+// same table drives route registration and the generated TypeScript client.
+type editorEndpoint struct {
+	Name     string // client method name
+	Doc      string
+	Method   string
+	Path     string // relative to editorBasePath
+	Service  string
+	Upstream string   // service path, with the same :params as Path
+	Query    []string // query parameters forwarded to the service
+	Request  interface{}
+	Response interface{}
+}
+
+var editorEndpoints = []editorEndpoint{
+	{
+		Name:     "getTemplateSource",
+		Doc:      "Open a template in the editor",
+		Method:   http.MethodGet,
+		Path:     "/templates/:id/source",
+		Service:  "template-service",
+		Upstream: "/api/v1/templates/:id/source",
+		Query:    []string{"version"},
+		Response: EditorTemplateSource{},
+	},
+	{
+		Name:     "forkTemplate",
+		Doc:      "Push local edits back as a fork of the template",
+		Method:   http.MethodPost,
+		Path:     "/templates/:id/fork",
+		Service:  "template-service",
+		Upstream: "/api/v1/templates/:id/fork",
+		Query:    []string{"version"},
+		Request:  EditorForkRequest{},
+		Response: EditorTemplate{},
+	},
+	{
+		Name:     "previewTemplate",
+		Doc:      "Render a template with parameters resolved",
+		Method:   http.MethodPost,
+		Path:     "/templates/:id/preview",
+		Service:  "template-service",
+		Upstream: "/api/v1/templates/:id/preview",
+		Request:  EditorPreviewRequest{},
+		Response: EditorPreviewResult{},
+	},
+	{
+		Name:     "compile",
+		Doc:      "Compile the current buffer through the provisioning service",
+		Method:   http.MethodPost,
+		Path:     "/compile",
+		Service:  "provisioning-service",
+		Upstream: "/api/v1/provisioning/compile",
+		Request:  EditorCompileRequest{},
+		Response: EditorCompileResult{},
+	},
+	{
+		Name:     "flash",
+		Doc:      "Flash a compiled artifact to a device",
+		Method:   http.MethodPost,
+		Path:     "/flash",
+		Service:  "provisioning-service",
+		Upstream: "/api/v1/provisioning/flash",
+		Request:  EditorFlashRequest{},
+		Response: EditorFlashResult{},
+	},
+	{
+		Name:     "listPorts",
+		Doc:      "List serial ports available for flashing",
+		Method:   http.MethodGet,
+		Path:     "/ports",
+		Service:  "provisioning-service",
+		Upstream: "/api/v1/provisioning/ports",
+		Response: EditorPortList{},
+	},
+}
+
+// registerEditorRoutes registers the editor extension API. Every route
+// accepts cross-origin requests from local editors; all but the info
+// endpoint require a bearer token.
+func registerEditorRoutes(router *gin.Engine, gateway *Gateway) {
+	editor := router.Group(editorBasePath)
+	editor.Use(editorCORS())
+
+	// Preflight requests never carry credentials
+	editor.OPTIONS("/*path", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusNoContent)
+	})
+	editor.GET("/info", gateway.getEditorInfo)
+
+	authenticated := editor.Group("")
+	authenticated.Use(gateway.jwtAuth.RequireAuth())
+	for _, endpoint := range editorEndpoints {
+		authenticated.Handle(endpoint.Method, endpoint.Path, gateway.proxyEditorRequest(endpoint))
+	}
+}
+
+// proxyEditorRequest forwards an editor API request to its service route
+func (g *Gateway) proxyEditorRequest(endpoint editorEndpoint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rewriteEditorRequest(c, endpoint)
+		g.reverseProxy.ProxyHandler(endpoint.Service)(c)
+	}
+}
+
+// rewriteEditorRequest points the request at the upstream service path,
+// substituting path parameters and keeping only the declared query parameters
+func rewriteEditorRequest(c *gin.Context, endpoint editorEndpoint) {
+	path := endpoint.Upstream
+	for _, param := range c.Params {
+		path = strings.ReplaceAll(path, ":"+param.Key, url.PathEscape(param.Value))
+	}
+	c.Request.URL.Path = path
+	c.Request.URL.RawPath = ""
+
+	query := url.Values{}
+	for _, name := range endpoint.Query {
+		if value := c.Query(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	c.Request.URL.RawQuery = query.Encode()
+}
+
+func (g *Gateway) getEditorInfo(c *gin.Context) {
+	info := EditorAPIInfo{
+		APIVersion: EditorAPIVersion,
+		BasePath:   editorBasePath,
+	}
+	for _, endpoint := range editorEndpoints {
+		info.Endpoints = append(info.Endpoints, EditorEndpointInfo{
+			Name:   endpoint.Name,
+			Method: endpoint.Method,
+			Path:   editorBasePath + endpoint.Path,
+		})
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// editorCORS allows requests from editor webviews and local development servers
+func editorCORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin != "" && isEditorOrigin(origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			c.Header("Access-Control-Max-Age", "600")
+			c.Header("Vary", "Origin")
+		}
+		c.Next()
+	}
+}
+
+// isEditorOrigin reports whether an origin belongs to a local editor:
+// VS Code webviews or a loopback host (Arduino IDE, local dev servers)
+func isEditorOrigin(origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "vscode-webview", "vscode-file":
+		return true
+	case "http", "https":
+		switch parsed.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return true
+		}
+	}
+
+	return false
+}
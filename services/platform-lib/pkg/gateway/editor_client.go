@@ -0,0 +1,236 @@
+package gateway
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// WriteEditorClient writes a TypeScript client for the editor extension API.
+// Interfaces are derived from the editor contract types and methods from the
+// endpoint table, so the client always matches the routes the gateway serves.
+func WriteEditorClient(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	gen := &tsGenerator{seen: map[reflect.Type]bool{}}
+
+	for _, endpoint := range editorEndpoints {
+		if endpoint.Request != nil {
+			gen.collect(reflect.TypeOf(endpoint.Request))
+		}
+		gen.collect(reflect.TypeOf(endpoint.Response))
+	}
+	gen.collect(reflect.TypeOf(EditorAPIInfo{}))
+
+	fmt.Fprintf(out, "// Code generated by editorgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "export const EDITOR_API_VERSION = %q;\n\n", EditorAPIVersion)
+
+	for _, t := range gen.order {
+		gen.writeInterface(out, t)
+	}
+
+	fmt.Fprintf(out, "export class EditorApiError extends Error {\n")
+	fmt.Fprintf(out, "  constructor(public readonly status: number, message: string) {\n")
+	fmt.Fprintf(out, "    super(message);\n")
+	fmt.Fprintf(out, "    this.name = \"EditorApiError\";\n")
+	fmt.Fprintf(out, "  }\n")
+	fmt.Fprintf(out, "}\n\n")
+
+	fmt.Fprintf(out, "export interface EditorClientOptions {\n")
+	fmt.Fprintf(out, "  baseUrl?: string;\n")
+	fmt.Fprintf(out, "  token?: string;\n")
+	fmt.Fprintf(out, "  fetch?: typeof fetch;\n")
+	fmt.Fprintf(out, "}\n\n")
+
+	fmt.Fprintf(out, "export class EditorClient {\n")
+	fmt.Fprintf(out, "  private readonly baseUrl: string;\n")
+	fmt.Fprintf(out, "  private readonly fetchImpl: typeof fetch;\n")
+	fmt.Fprintf(out, "  token?: string;\n\n")
+	fmt.Fprintf(out, "  constructor(options: EditorClientOptions = {}) {\n")
+	fmt.Fprintf(out, "    this.baseUrl = (options.baseUrl ?? \"http://localhost:8080\").replace(/\\/+$/, \"\");\n")
+	fmt.Fprintf(out, "    this.token = options.token;\n")
+	fmt.Fprintf(out, "    this.fetchImpl = options.fetch ?? fetch;\n")
+	fmt.Fprintf(out, "  }\n\n")
+
+	fmt.Fprintf(out, "  /** Discover the API version and endpoints */\n")
+	fmt.Fprintf(out, "  info(): Promise<EditorAPIInfo> {\n")
+	fmt.Fprintf(out, "    return this.request(\"GET\", \"/info\");\n")
+	fmt.Fprintf(out, "  }\n")
+
+	for _, endpoint := range editorEndpoints {
+		writeEditorMethod(out, endpoint)
+	}
+
+	fmt.Fprintf(out, "\n  private async request<T>(method: string, path: string, body?: unknown, query?: Record<string, string | undefined>): Promise<T> {\n")
+	fmt.Fprintf(out, "    const url = new URL(this.baseUrl + %q + path);\n", editorBasePath)
+	fmt.Fprintf(out, "    for (const [key, value] of Object.entries(query ?? {})) {\n")
+	fmt.Fprintf(out, "      if (value !== undefined && value !== \"\") {\n")
+	fmt.Fprintf(out, "        url.searchParams.set(key, value);\n")
+	fmt.Fprintf(out, "      }\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    const headers: Record<string, string> = {};\n")
+	fmt.Fprintf(out, "    if (body !== undefined) {\n")
+	fmt.Fprintf(out, "      headers[\"Content-Type\"] = \"application/json\";\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    if (this.token) {\n")
+	fmt.Fprintf(out, "      headers[\"Authorization\"] = `Bearer ${this.token}`;\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    const response = await this.fetchImpl(url.toString(), {\n")
+	fmt.Fprintf(out, "      method,\n")
+	fmt.Fprintf(out, "      headers,\n")
+	fmt.Fprintf(out, "      body: body === undefined ? undefined : JSON.stringify(body),\n")
+	fmt.Fprintf(out, "    });\n")
+	fmt.Fprintf(out, "    const payload = await response.json().catch(() => undefined);\n")
+	fmt.Fprintf(out, "    if (!response.ok) {\n")
+	fmt.Fprintf(out, "      throw new EditorApiError(response.status, payload?.error ?? response.statusText);\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    return payload as T;\n")
+	fmt.Fprintf(out, "  }\n")
+	fmt.Fprintf(out, "}\n")
+
+	return out.Flush()
+}
+
+// writeEditorMethod writes the client method for one endpoint. Path
+// parameters become leading arguments, followed by the request body and an
+// optional query object.
+func writeEditorMethod(out io.Writer, endpoint editorEndpoint) {
+	var args, pathParams []string
+	segments := strings.Split(endpoint.Path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			name := strings.TrimPrefix(segment, ":")
+			pathParams = append(pathParams, name)
+			args = append(args, name+": string")
+			segments[i] = "${encodeURIComponent(" + name + ")}"
+		}
+	}
+
+	body := ""
+	if endpoint.Request != nil {
+		args = append(args, "body: "+reflect.TypeOf(endpoint.Request).Name())
+		body = ", body"
+	}
+
+	query := ""
+	if len(endpoint.Query) > 0 {
+		if body == "" {
+			body = ", undefined"
+		}
+		fields := make([]string, len(endpoint.Query))
+		for i, name := range endpoint.Query {
+			fields[i] = name + "?: string"
+		}
+		args = append(args, "query: { "+strings.Join(fields, "; ")+" } = {}")
+		query = ", query"
+	}
+
+	path := strings.Join(segments, "/")
+	quote := "\""
+	if len(pathParams) > 0 {
+		quote = "`"
+	}
+
+	fmt.Fprintf(out, "\n  /** %s */\n", endpoint.Doc)
+	fmt.Fprintf(out, "  %s(%s): Promise<%s> {\n", endpoint.Name, strings.Join(args, ", "), reflect.TypeOf(endpoint.Response).Name())
+	fmt.Fprintf(out, "    return this.request(%q, %s%s%s%s%s);\n", endpoint.Method, quote, path, quote, body, query)
+	fmt.Fprintf(out, "  }\n")
+}
+
+// tsGenerator collects named struct types in dependency order
+type tsGenerator struct {
+	seen  map[reflect.Type]bool
+	order []reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *tsGenerator) collect(t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || g.seen[t] {
+		return
+	}
+	g.seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		g.collect(t.Field(i).Type)
+	}
+	g.order = append(g.order, t)
+}
+
+func (g *tsGenerator) writeInterface(out io.Writer, t reflect.Type) {
+	fmt.Fprintf(out, "export interface %s {\n", t.Name())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, optional, ok := jsonField(field)
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.Ptr {
+			optional = true
+		}
+		marker := ""
+		if optional {
+			marker = "?"
+		}
+		fmt.Fprintf(out, "  %s%s: %s;\n", name, marker, tsType(field.Type))
+	}
+	fmt.Fprintf(out, "}\n\n")
+}
+
+// jsonField returns the JSON name of a struct field and whether it is omitted when empty
+func jsonField(field reflect.StructField) (string, bool, bool) {
+	if field.PkgPath != "" {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	omitempty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+
+	return name, omitempty, true
+}
+
+// tsType maps a Go type onto its TypeScript JSON representation
+func tsType(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return tsType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return tsType(t.Elem()) + "[]"
+	case reflect.Map:
+		return "Record<string, " + tsType(t.Elem()) + ">"
+	case reflect.Struct:
+		return t.Name()
+	default:
+		return "unknown"
+	}
+}
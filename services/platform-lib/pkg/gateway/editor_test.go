@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsEditorOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"vscode-webview://1a2b3c", true},
+		{"vscode-file://vscode-app", true},
+		{"http://localhost:3000", true},
+		{"https://127.0.0.1:8443", true},
+		{"http://[::1]:5173", true},
+		{"https://example.com", false},
+		{"http://localhost.example.com", false},
+		{"file://localhost", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, isEditorOrigin(tt.origin))
+		})
+	}
+}
+
+func TestEditorCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	editor := router.Group(editorBasePath)
+	editor.Use(editorCORS())
+	editor.OPTIONS("/*path", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, editorBasePath+"/compile", nil)
+	req.Header.Set("Origin", "vscode-webview://abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "vscode-webview://abc", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+
+	req = httptest.NewRequest(http.MethodOptions, editorBasePath+"/compile", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestRewriteEditorRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var fork editorEndpoint
+	for _, endpoint := range editorEndpoints {
+		if endpoint.Name == "forkTemplate" {
+			fork = endpoint
+		}
+	}
+	require.NotEmpty(t, fork.Upstream)
+
+	var rewritten string
+	router := gin.New()
+	router.POST(editorBasePath+fork.Path, func(c *gin.Context) {
+		rewriteEditorRequest(c, fork)
+		rewritten = c.Request.URL.String()
+	})
+
+	req := httptest.NewRequest(http.MethodPost, editorBasePath+"/templates/blink/fork?version=1.2.0&debug=1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "/api/v1/templates/blink/fork?version=1.2.0", rewritten)
+}
+
+func TestGetEditorInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(editorBasePath+"/info", (&Gateway{}).getEditorInfo)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, editorBasePath+"/info", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var info EditorAPIInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, EditorAPIVersion, info.APIVersion)
+	assert.Len(t, info.Endpoints, len(editorEndpoints))
+	assert.Contains(t, info.Endpoints, EditorEndpointInfo{Name: "compile", Method: http.MethodPost, Path: "/api/editor/v1/compile"})
+}
+
+func TestWriteEditorClient_UpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteEditorClient(&buf))
+
+	committed, err := os.ReadFile("../../../../clients/editor-ts/src/client.ts")
+	require.NoError(t, err)
+	assert.Equal(t, string(committed), buf.String(), "editor client is stale, run go generate ./pkg/gateway")
+}
@@ -0,0 +1,29 @@
+// Command editorgen writes the TypeScript client for the editor extension API
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/athena/platform-lib/pkg/gateway"
+)
+
+func main() {
+	output := flag.String("o", "", "output file (defaults to stdout)")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := gateway.WriteEditorClient(&buf); err != nil {
+		log.Fatalf("failed to generate editor client: %v", err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", *output, err)
+	}
+}
@@ -2,14 +2,22 @@ package gateway
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/declarative"
 	"github.com/athena/platform-lib/pkg/discovery"
 	"github.com/athena/platform-lib/pkg/health"
+	"github.com/athena/platform-lib/pkg/loadtest"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
 	"github.com/athena/platform-lib/pkg/middleware"
+	"github.com/athena/platform-lib/pkg/notifications"
 	"github.com/athena/platform-lib/pkg/proxy"
 	"github.com/athena/platform-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
@@ -21,18 +29,29 @@ type Gateway struct {
 	config        *config.Config
 	logger        *logger.Logger
 	authHandler   *AuthHandler
+	ssoHandler    *SSOHandler
+	accounts      *ServiceAccountHandler
+	apply         *ApplyHandler
+	benchmarks    *loadtest.ResultsHandler
+	chaos         *ChaosHandler
+	admin         *AdminHandler
+	graphql       *GraphQLHandler
+	hooks         *InboundWebhookHandler
 	jwtAuth       *middleware.JWTAuth
 	healthChecker *health.HealthChecker
 	registry      *discovery.ServiceRegistry
 	reverseProxy  *proxy.ReverseProxy
 	tracingMgr    *tracing.TracingManager
+	notifications *notifications.Hub
+	usage         *metering.Ledger
 }
 
 // NewGateway creates a new API gateway instance
 func NewGateway(cfg *config.Config, log *logger.Logger) (*Gateway, error) {
 	// Initialize components
 	authHandler := NewAuthHandler(cfg, *log)
-	jwtAuth := middleware.NewJWTAuth(cfg.JWTSecret, "athena-platform")
+	// Share the login handler's sessions so the tokens it issues are accepted
+	jwtAuth := authHandler.jwtAuth
 	healthChecker := health.NewHealthChecker("1.0.0")
 
 	// Initialize service discovery
@@ -51,7 +70,7 @@ func NewGateway(cfg *config.Config, log *logger.Logger) (*Gateway, error) {
 		EnableTracing: true,
 	}, log)
 	if err != nil {
-		log.Warnf("Failed to initialize tracing: %v", err)
+		log.Warn("Failed to initialize tracing", "error", err)
 	}
 
 	// Initialize reverse proxy
@@ -71,38 +90,111 @@ func NewGateway(cfg *config.Config, log *logger.Logger) (*Gateway, error) {
 	// Register services from configuration
 	registerServicesFromConfig(registry, cfg)
 
+	// Usage ledger; quota notifications go to the notification stream
+	hub := notifications.NewHub(log, cfg.JWTSecret)
+	usage := metering.NewLedger(metering.NewMemoryUsageStore(), cfg, log)
+	usage.SetPublisher(hub.Publish)
+
+	hooks, err := NewInboundWebhookHandler(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Gateway{
 		config:        cfg,
 		logger:        log,
 		authHandler:   authHandler,
+		ssoHandler:    NewSSOHandler(cfg, jwtAuth, log),
+		accounts:      NewServiceAccountHandler(NewMemoryServiceAccountStore(), log),
+		apply:         NewApplyHandler(cfg, log),
+		benchmarks:    loadtest.NewResultsHandler(loadtest.NewMemoryResultStore(), log),
+		chaos:         NewChaosHandler(cfg, log),
+		admin:         NewAdminHandler(cfg, log),
+		graphql:       NewGraphQLHandler(cfg, log),
+		hooks:         hooks,
 		jwtAuth:       jwtAuth,
 		healthChecker: healthChecker,
 		registry:      registry,
 		reverseProxy:  reverseProxy,
 		tracingMgr:    tracingMgr,
+		notifications: hub,
+		usage:         usage,
 	}, nil
 }
 
 // registerServicesFromConfig registers services from configuration
 func registerServicesFromConfig(registry *discovery.ServiceRegistry, cfg *config.Config) {
 	for serviceName, serviceURL := range cfg.Services {
-		// Parse URL to extract address and port
-		if serviceURL != "" {
-			instance := &discovery.ServiceInstance{
-				ID:      serviceName + "-1",
-				Name:    serviceName,
-				Address: "localhost", // Extract from URL in production
-				Port:    8001,        // Extract from URL in production
-				Status:  "healthy",
-				Metadata: map[string]string{
-					"url": serviceURL,
-				},
-			}
-			registry.RegisterService(instance)
+		if serviceURL == "" {
+			continue
 		}
+
+		address, port, err := splitServiceURL(serviceURL)
+		if err != nil {
+			continue
+		}
+
+		instance := &discovery.ServiceInstance{
+			ID:      serviceName + "-1",
+			Name:    serviceName,
+			Address: address,
+			Port:    port,
+			Status:  "healthy",
+			Metadata: map[string]string{
+				"url": serviceURL,
+			},
+		}
+		registry.RegisterService(instance)
 	}
 }
 
+// splitServiceURL returns the host and port of a configured service URL,
+// defaulting the port from the scheme
+func splitServiceURL(serviceURL string) (string, int, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return "", 0, err
+	}
+	if u.Hostname() == "" {
+		return "", 0, fmt.Errorf("service URL %q has no host", serviceURL)
+	}
+
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			return u.Hostname(), 443, nil
+		}
+		return u.Hostname(), 80, nil
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return "", 0, fmt.Errorf("service URL %q has an invalid port: %w", serviceURL, err)
+	}
+	return u.Hostname(), port, nil
+}
+
+// SetServiceAccountStore sets where service accounts and their token
+// hashes are persisted
+func (g *Gateway) SetServiceAccountStore(store ServiceAccountStore) {
+	g.accounts.store = store
+}
+
+// SetApplyStateStore sets where the resources managed by applies are
+// recorded
+func (g *Gateway) SetApplyStateStore(store declarative.StateStore) {
+	g.apply.SetStateStore(store)
+}
+
+// SetBenchmarkStore sets where load test results are persisted
+func (g *Gateway) SetBenchmarkStore(store loadtest.ResultStore) {
+	g.benchmarks.SetStore(store)
+}
+
+// SetUsageStore sets where the usage ledger is persisted
+func (g *Gateway) SetUsageStore(store metering.UsageStore) {
+	g.usage.SetStore(store)
+}
+
 // Shutdown gracefully shuts down the gateway
 func (g *Gateway) Shutdown() error {
 	if g.tracingMgr != nil {
@@ -119,6 +211,9 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 	router.Use(middleware.SecurityHeadersMiddleware())
 	router.Use(middleware.NewRateLimitMiddleware(100).RateLimit()) // 100 requests per minute
 	router.Use(middleware.NewValidationMiddleware().SanitizeInput())
+	if gateway.chaos != nil {
+		router.Use(gateway.chaos.injector.Middleware())
+	}
 
 	// Health endpoints (public, no auth required)
 	router.GET("/health", gin.WrapH(http.HandlerFunc(gateway.healthChecker.HealthHandlerFunc())))
@@ -129,6 +224,56 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 	router.GET("/services/health", gateway.getServiceHealth)
 	router.GET("/services/health/:serviceName", gateway.getServiceHealthByName)
 
+	// Event intake from platform services (authenticated by HMAC signature)
+	router.POST(notifications.PublishPath, gateway.notifications.HandlePublish)
+
+	// Usage intake from platform services (authenticated by HMAC signature)
+	router.POST(metering.ReportPath, gateway.usage.HandleReport)
+
+	// Embedded web dashboard (public static assets, disabled via dashboard.enabled)
+	if err := registerDashboardRoutes(router, gateway.config.Dashboard); err != nil {
+		gateway.logger.Warn("Failed to register dashboard routes", "error", err)
+	}
+
+	// Onboarding reports from firmware, authorized by the device token
+	// baked into the onboarding code
+	router.POST("/api/v1/devices/:id/onboarding", gateway.proxyToDeviceService)
+
+	// Self-registration of pre-provisioned devices, authorized by the claim
+	// token flashed into their firmware
+	router.POST("/api/v1/devices/claim", gateway.proxyToDeviceService)
+
+	// Reported twin state, authorized by the device's own token or client
+	// certificate
+	router.POST("/api/v1/devices/:id/twin/reported", gateway.proxyToDeviceService)
+
+	// Telemetry export downloads, authorized by the signature in the link
+	router.GET("/api/v1/telemetry/exports/:id/download", gateway.proxyToTelemetryService)
+
+	// Streaming ingest and edge buffer sync, authorized by the device's own
+	// token
+	router.GET("/api/v1/telemetry/ingest/:deviceId/ws", gateway.proxyToTelemetryService)
+	router.POST("/api/v1/telemetry/sync/:deviceId", gateway.proxyToTelemetryService)
+
+	// One-time firmware downloads, authorized by the token in the link and,
+	// with update_reports.require_device_token, the device's own token
+	router.GET("/api/v1/ota/devices/:deviceId/downloads/:token", gateway.proxyToOTAService)
+
+	// Update checks and reports, authorized by the device's own token when
+	// update_reports.require_device_token or credentials.require_device_auth
+	// is set
+	router.GET("/api/v1/ota/updates/:deviceId", gateway.proxyToOTAService)
+	router.GET("/api/v1/ota/updates/:deviceId/delta", gateway.proxyToOTAService)
+	router.POST("/api/v1/ota/updates/:deviceId/confirm-boot", gateway.proxyToOTAService)
+	router.POST("/api/v1/ota/devices/:deviceId/updates/status", gateway.proxyToOTAService)
+
+	// Editor extension API (localhost CORS, versioned separately from /api/v1)
+	registerEditorRoutes(router, gateway)
+
+	// OpenAPI spec of the public API, which the generated clients are built
+	// from
+	router.GET(OpenAPIPath, gateway.getOpenAPI)
+
 	// Authentication routes (public, but validated)
 	auth := router.Group("/api/v1")
 	{
@@ -136,6 +281,12 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 		auth.POST("/auth/refresh", gateway.authHandler.RefreshToken)
 		auth.POST("/auth/logout", gateway.jwtAuth.RequireAuth(), gateway.authHandler.Logout)
 
+		// OIDC single sign-on and the dashboard cookie session
+		gateway.ssoHandler.RegisterRoutes(auth)
+
+		// Inbound webhooks from external systems, verified per hook
+		gateway.hooks.RegisterRoutes(auth)
+
 		// Protected routes for testing
 		protected := auth.Group("/auth/protected")
 		protected.Use(gateway.jwtAuth.RequireAuth())
@@ -147,8 +298,34 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 
 	// API routes with service proxying
 	v1 := router.Group("/api/v1")
-	v1.Use(gateway.jwtAuth.RequireAuth())
+	v1.Use(gateway.requireAuth())
 	{
+		// Service account management (administrators only)
+		gateway.accounts.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin")))
+
+		// Declarative fleet management (manifests applied from CI or IaC providers)
+		gateway.apply.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin", "operator", "service-account")))
+
+		// Load test results, recorded per release to catch performance regressions
+		gateway.benchmarks.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin", "operator", "service-account")))
+
+		// Billable usage per project, for chargeback
+		gateway.usage.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin", "operator")))
+
+		// Operational tasks run in the services (administrators only)
+		gateway.admin.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin")))
+
+		// Dashboard queries composing device, telemetry, template and OTA data
+		gateway.graphql.RegisterRoutes(v1)
+
+		// Fault injection (non-production only, administrators only)
+		if gateway.chaos != nil {
+			gateway.chaos.RegisterRoutes(v1.Group("", gateway.jwtAuth.RequireRole("admin")))
+		}
+
+		// Notification stream (WebSocket)
+		v1.GET("/notifications/stream", gateway.notifications.HandleStream)
+
 		// Template service routes (with validation)
 		templates := v1.Group("/templates")
 		templates.Use(middleware.NewValidationMiddleware().ValidateQuery(map[string]string{
@@ -158,7 +335,39 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 		}))
 		{
 			templates.GET("", gateway.proxyToTemplateService)
+			templates.POST("", gateway.proxyToTemplateService)
 			templates.GET("/:id", gateway.proxyToTemplateService)
+			templates.GET("/:id/composition", gateway.proxyToTemplateService)
+			templates.GET("/:id/parameters", gateway.proxyToTemplateService)
+			templates.GET("/:id/compatibility", gateway.proxyToTemplateService)
+			templates.POST("/:id/builds", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			templates.GET("/:id/analytics", gateway.proxyToTemplateService)
+			templates.GET("/:id/wiring.svg", gateway.proxyToTemplateService)
+			templates.GET("/:id/wiring.png", gateway.proxyToTemplateService)
+			templates.POST("/:id/preview", gateway.proxyToTemplateService)
+			templates.GET("/:id/images/:role", gateway.proxyToTemplateService)
+			templates.PUT("/:id/images/:role", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			templates.DELETE("/:id/images/:role", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			templates.POST("/import", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			templates.POST("/:id/promote", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			templates.DELETE("/:id", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+		}
+
+		// Template publishers whose signed bundles are trusted on import
+		publishers := v1.Group("/publishers")
+		{
+			publishers.GET("", gateway.proxyToTemplateService)
+			publishers.GET("/:id", gateway.proxyToTemplateService)
+			publishers.POST("", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToTemplateService)
+		}
+
+		// Driver snippets generated sketches are composed from
+		drivers := v1.Group("/drivers")
+		{
+			drivers.GET("", gateway.proxyToTemplateService)
+			drivers.GET("/:driverId", gateway.proxyToTemplateService)
+			drivers.PUT("/:driverId", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
+			drivers.DELETE("/:driverId", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToTemplateService)
 		}
 
 		// NLP service routes (with validation)
@@ -171,7 +380,12 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 			nlp.POST("/plan", middleware.NewValidationMiddleware().ValidateBody(&struct {
 				Request string `json:"request" binding:"required"`
 				Context string `json:"context,omitempty"`
+				Board   string `json:"board,omitempty"`
+				Locale  string `json:"locale,omitempty"`
 			}{}), gateway.proxyToNLPService)
+			nlp.POST("/safety-check", gateway.proxyToNLPService)
+			nlp.PATCH("/wiring-diagram", gateway.proxyToNLPService)
+			nlp.POST("/pin-assignment", gateway.proxyToNLPService)
 		}
 
 		// Provisioning service routes (with validation)
@@ -197,7 +411,71 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 		}))
 		{
 			devices.GET("", gateway.proxyToDeviceService)
+			devices.POST("", gateway.proxyToDeviceService)
+
+			// Live device list (WebSocket or server-sent events)
+			devices.GET("/stream", gateway.streamDevices)
 			devices.GET("/:id", gateway.proxyToDeviceService)
+
+			// Lifecycle timeline
+			devices.GET("/:id/events", gateway.proxyToDeviceService)
+
+			// Gateway hierarchy
+			devices.GET("/:id/children", gateway.proxyToDeviceService)
+			devices.GET("/:id/hierarchy", gateway.proxyToDeviceService)
+			devices.PUT("/:id/parent", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.GET("/:id/debug-bundle", middleware.NewValidationMiddleware().ValidateQuery(map[string]string{
+				"format": "oneof=json zip",
+			}), gateway.proxyToDeviceService)
+			devices.POST("/:id/debug-capture", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.DELETE("/:id/debug-capture", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.POST("/:id/flash-result", gateway.proxyToDeviceService)
+
+			// Availability reports (uptime, outages, MTBF)
+			slaQuery := middleware.NewValidationMiddleware().ValidateQuery(map[string]string{
+				"format": "oneof=json csv",
+			})
+			devices.GET("/sla", slaQuery, gateway.proxyToDeviceService)
+			devices.GET("/:id/sla", slaQuery, gateway.proxyToDeviceService)
+
+			// Credential expiry tracking and rotation
+			devices.GET("/credentials/expiring", gateway.proxyToDeviceService)
+			devices.GET("/:id/credentials", gateway.proxyToDeviceService)
+			devices.PUT("/:id/credentials/:name", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.DELETE("/:id/credentials/:name", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.POST("/:id/credentials/:name/rotate", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.POST("/:id/credentials/:name/revoke", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.POST("/:id/certificates", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+
+			// Claim tokens for pre-provisioned registration
+			devices.GET("/claim-tokens", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.POST("/claim-tokens", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.GET("/claim-tokens/:tokenId", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.DELETE("/claim-tokens/:tokenId", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+
+			// Device twin: operators set desired state; devices report
+			// theirs on the public route above
+			devices.GET("/:id/twin", gateway.proxyToDeviceService)
+			devices.GET("/:id/twin/delta", gateway.proxyToDeviceService)
+			devices.PATCH("/:id/twin/desired", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+
+			// Remote commands: operators send them, devices poll and acknowledge
+			devices.POST("/:id/commands", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			devices.GET("/:id/commands", gateway.proxyToDeviceService)
+			devices.GET("/:id/commands/pending", gateway.proxyToDeviceService)
+			devices.GET("/:id/commands/:cmdId", gateway.proxyToDeviceService)
+			devices.POST("/:id/commands/:cmdId/ack", gateway.proxyToDeviceService)
+		}
+
+		// Device groups, which OTA deployments and fleet comparisons can target
+		deviceGroups := v1.Group("/device-groups")
+		{
+			deviceGroups.GET("", gateway.proxyToDeviceService)
+			deviceGroups.POST("", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			deviceGroups.GET("/:name", gateway.proxyToDeviceService)
+			deviceGroups.DELETE("/:name", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			deviceGroups.POST("/:name/members", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
+			deviceGroups.DELETE("/:name/members/:deviceId", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToDeviceService)
 		}
 
 		// Telemetry service routes (with validation)
@@ -208,11 +486,42 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 				Data      map[string]interface{} `json:"data" binding:"required"`
 				Timestamp int64                  `json:"timestamp,omitempty"`
 			}{}), gateway.proxyToTelemetryService)
+			telemetry.POST("/ingest/:deviceId/batch", gateway.proxyToTelemetryService)
 			telemetry.GET("/metrics/:deviceId", middleware.NewValidationMiddleware().ValidateQuery(map[string]string{
 				"start":    "min=0",
 				"end":      "min=0",
 				"interval": "oneof=1m 5m 15m 1h",
 			}), gateway.proxyToTelemetryService)
+			telemetry.GET("/forecast/:deviceId/:metric", gateway.proxyToTelemetryService)
+			telemetry.POST("/compare", gateway.proxyToTelemetryService)
+			telemetry.POST("/devices/:deviceId/logs", gateway.proxyToTelemetryService)
+			telemetry.GET("/devices/:deviceId/logs", gateway.proxyToTelemetryService)
+			telemetry.GET("/log-alert-rules", gateway.proxyToTelemetryService)
+			telemetry.POST("/log-alert-rules", gateway.proxyToTelemetryService)
+			telemetry.GET("/log-alert-rules/:name", gateway.proxyToTelemetryService)
+			telemetry.PUT("/log-alert-rules/:name", gateway.proxyToTelemetryService)
+			telemetry.DELETE("/log-alert-rules/:name", gateway.proxyToTelemetryService)
+			telemetry.PUT("/thresholds/bulk", gateway.proxyToTelemetryService)
+			telemetry.GET("/thresholds/templates/:templateId", gateway.proxyToTelemetryService)
+			telemetry.GET("/thresholds/:deviceId/effective", gateway.proxyToTelemetryService)
+			telemetry.GET("/mqtt/acl", gateway.proxyToTelemetryService)
+			telemetry.GET("/time", gateway.proxyToTelemetryService)
+			telemetry.GET("/clock-skew", gateway.proxyToTelemetryService)
+			telemetry.GET("/clock-skew/:deviceId", gateway.proxyToTelemetryService)
+			telemetry.GET("/bridges", gateway.proxyToTelemetryService)
+			telemetry.POST("/bridges/:name/devices/:deviceId/sync", gateway.proxyToTelemetryService)
+			telemetry.GET("/lorawan/decoders", gateway.proxyToTelemetryService)
+			telemetry.GET("/lorawan/decoders/:templateId", gateway.proxyToTelemetryService)
+			telemetry.PUT("/lorawan/decoders/:templateId", gateway.proxyToTelemetryService)
+			telemetry.DELETE("/lorawan/decoders/:templateId", gateway.proxyToTelemetryService)
+			telemetry.POST("/lorawan/decoders/:templateId/test", gateway.proxyToTelemetryService)
+			telemetry.GET("/derived-metrics", gateway.proxyToTelemetryService)
+			telemetry.POST("/derived-metrics", gateway.proxyToTelemetryService)
+			telemetry.GET("/derived-metrics/:name", gateway.proxyToTelemetryService)
+			telemetry.PUT("/derived-metrics/:name", gateway.proxyToTelemetryService)
+			telemetry.DELETE("/derived-metrics/:name", gateway.proxyToTelemetryService)
+			telemetry.POST("/export/archive", gateway.proxyToTelemetryService)
+			telemetry.GET("/exports/:id", gateway.proxyToTelemetryService)
 		}
 
 		// OTA service routes (with validation)
@@ -224,11 +533,69 @@ func RegisterRoutes(router *gin.Engine, gateway *Gateway) {
 				Firmware    string `json:"firmware" binding:"required"`
 				DeviceType  string `json:"device_type" binding:"required"`
 			}{}), gateway.proxyToOTAService)
-			ota.GET("/updates/:deviceId", gateway.proxyToOTAService)
+			ota.GET("/releases", gateway.proxyToOTAService)
+			ota.GET("/releases/:releaseId", gateway.proxyToOTAService)
+			ota.PATCH("/releases/:releaseId/annotations", gateway.proxyToOTAService)
+			ota.POST("/releases/:releaseId/verify", gateway.proxyToOTAService)
+			ota.POST("/releases/:releaseId/promote", gateway.jwtAuth.RequireRole("admin", "operator"), gateway.proxyToOTAService)
+			ota.GET("/releases/:releaseId/bandwidth", gateway.proxyToOTAService)
+			ota.GET("/releases/:releaseId/binary", gateway.proxyToOTAService)
+			ota.GET("/retention/preview", gateway.proxyToOTAService)
+			ota.GET("/keys", gateway.proxyToOTAService)
+			ota.POST("/keys/rotate", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
+			ota.POST("/deployments", gateway.proxyToOTAService)
+			ota.GET("/deployments", gateway.proxyToOTAService)
+			ota.GET("/deployments/:deploymentId", gateway.proxyToOTAService)
+			ota.GET("/deployments/:deploymentId/status", gateway.proxyToOTAService)
+			ota.GET("/deployments/:deploymentId/updates", gateway.proxyToOTAService)
+			ota.POST("/deployments/:deploymentId/approve", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
+			ota.POST("/deployments/:deploymentId/reject", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
+			ota.POST("/deployments/:deploymentId/cancel", gateway.proxyToOTAService)
+			ota.POST("/deployments/:deploymentId/retry-failed", gateway.proxyToOTAService)
+			ota.POST("/deployments/:deploymentId/resolve-targets", gateway.proxyToOTAService)
+			ota.GET("/deployments/:deploymentId/health", gateway.proxyToOTAService)
+			ota.PATCH("/deployments/:deploymentId/annotations", gateway.proxyToOTAService)
+			ota.GET("/devices/:deviceId/updates", gateway.proxyToOTAService)
+			ota.GET("/devices/:deviceId/children/updates", gateway.proxyToOTAService)
+			ota.POST("/devices/:deviceId/crashes", gateway.proxyToOTAService)
+			ota.GET("/devices/:deviceId/crashes", gateway.proxyToOTAService)
+
+			// Fleet policies (automatic OTA subscription)
+			ota.GET("/policies", gateway.proxyToOTAService)
+			ota.POST("/policies", gateway.proxyToOTAService)
+			ota.POST("/policies/evaluate", gateway.proxyToOTAService)
+			ota.GET("/policies/:policyId", gateway.proxyToOTAService)
+			ota.PUT("/policies/:policyId", gateway.proxyToOTAService)
+			ota.DELETE("/policies/:policyId", gateway.proxyToOTAService)
+			ota.PUT("/policies/:policyId/pause", gateway.proxyToOTAService)
+			ota.PUT("/policies/:policyId/resume", gateway.proxyToOTAService)
+			ota.GET("/policies/:policyId/audit", gateway.proxyToOTAService)
+
+			// Webhooks receiving deployment events, for CI/CD pipelines
+			ota.GET("/webhooks", gateway.proxyToOTAService)
+			ota.POST("/webhooks", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
+			ota.GET("/webhooks/:webhookId", gateway.proxyToOTAService)
+			ota.PUT("/webhooks/:webhookId", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
+			ota.DELETE("/webhooks/:webhookId", gateway.jwtAuth.RequireRole("admin"), gateway.proxyToOTAService)
 		}
 	}
 }
 
+// requireAuth authenticates users with JWTs and CI pipelines with service
+// account tokens
+func (g *Gateway) requireAuth() gin.HandlerFunc {
+	userAuth := g.jwtAuth.RequireAuth()
+	serviceAuth := g.accounts.RequireToken()
+
+	return func(c *gin.Context) {
+		if IsServiceAccountToken(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")) {
+			serviceAuth(c)
+			return
+		}
+		userAuth(c)
+	}
+}
+
 func (g *Gateway) proxyToTemplateService(c *gin.Context) {
 	g.reverseProxy.ProxyHandler("template-service")(c)
 }
@@ -253,6 +620,33 @@ func (g *Gateway) proxyToOTAService(c *gin.Context) {
 	g.reverseProxy.ProxyHandler("ota-service")(c)
 }
 
+// deviceStreamFilters are the device list query parameters a device stream
+// accepts, matched against the data of device events
+var deviceStreamFilters = []string{"status", "board_type", "template_id", "template_version", "ota_channel", "parent_id"}
+
+// streamDevices streams device added, updated, status changed and removed
+// events matching the device list filters, so dashboards need not poll
+// GET /devices. ?ids= limits the stream to specific devices. Device events
+// have no owner, so only admins receive them.
+func (g *Gateway) streamDevices(c *gin.Context) {
+	filter := notifications.ParseFilter("", c.Query("ids"))
+	filter.Types = []notifications.EventType{
+		notifications.EventDeviceAdded,
+		notifications.EventDeviceUpdated,
+		notifications.EventDeviceStatusChanged,
+		notifications.EventDeviceRemoved,
+	}
+	for _, key := range deviceStreamFilters {
+		if value := c.Query(key); value != "" {
+			if filter.Data == nil {
+				filter.Data = make(map[string]string)
+			}
+			filter.Data[key] = value
+		}
+	}
+	g.notifications.ServeStream(c, filter)
+}
+
 // getServiceHealth returns health information for all services
 func (g *Gateway) getServiceHealth(c *gin.Context) {
 	health := g.reverseProxy.GetAllServicesHealth()
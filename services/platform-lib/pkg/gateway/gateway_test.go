@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitServiceURL(t *testing.T) {
+	for serviceURL, expected := range map[string]struct {
+		address string
+		port    int
+	}{
+		"http://127.0.0.1:41234":     {"127.0.0.1", 41234},
+		"http://device-service:8003": {"device-service", 8003},
+		"http://templates.internal":  {"templates.internal", 80},
+		"https://ota.example.com/":   {"ota.example.com", 443},
+	} {
+		address, port, err := splitServiceURL(serviceURL)
+		require.NoError(t, err, serviceURL)
+		assert.Equal(t, expected.address, address, serviceURL)
+		assert.Equal(t, expected.port, port, serviceURL)
+	}
+
+	for _, serviceURL := range []string{"device-service", "http://host:port", "://"} {
+		_, _, err := splitServiceURL(serviceURL)
+		assert.Error(t, err, serviceURL)
+	}
+}
+
+func TestGateway_LoginTokenReachesConfiguredService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var proxiedPath string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"device_id":"dev-1"}`))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		ServiceName: "api-gateway",
+		JWTSecret:   testJWTSecret,
+		Services:    map[string]string{"device-service": backend.URL},
+	}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	// The reverse proxy needs a real connection rather than a recorder
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/auth/login", "application/json", strings.NewReader(`{"username":"admin","password":"admin123"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login LoginResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&login))
+
+	// Optional query parameters may be left out of proxied requests
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/devices/dev-1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, "/api/v1/devices/dev-1", proxiedPath)
+	assert.Contains(t, string(body), `"device_id":"dev-1"`)
+}
+
+func TestGateway_DeviceStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{ServiceName: "api-gateway", JWTSecret: testJWTSecret}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tokens, err := gw.jwtAuth.GenerateTokenPair("user-1", "alice", []string{"admin"}, nil, nil)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/devices/stream?status=online", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Eventually(t, func() bool { return gw.notifications.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Other event types and devices outside the filter are not streamed
+	gw.notifications.Publish(&notifications.Event{Type: notifications.EventAlertFired, ResourceID: "dev-1"})
+	gw.notifications.Publish(&notifications.Event{Type: notifications.EventDeviceStatusChanged, ResourceID: "dev-2",
+		Data: map[string]interface{}{"status": "offline"}})
+	gw.notifications.Publish(&notifications.Event{Type: notifications.EventDeviceStatusChanged, ResourceID: "dev-1",
+		Data: map[string]interface{}{"status": "online"}})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+	assert.Equal(t, "event: device.status_changed\n", lines[1])
+	assert.Contains(t, lines[2], `"resource_id":"dev-1"`)
+}
@@ -0,0 +1,512 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/graphql"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxGraphQLRequestSize bounds the request body accepted by /graphql
+	maxGraphQLRequestSize = 1 << 20
+	// maxConcurrentFetches bounds the service requests one batch runs at once
+	maxConcurrentFetches = 8
+)
+
+// errResourceNotFound is returned when a service has no resource for a key,
+// which resolves to null rather than an error
+var errResourceNotFound = errors.New("resource not found")
+
+// GraphQLHandler serves the dashboard GraphQL API. It composes device,
+// telemetry, template and OTA data from the services, so a dashboard can
+// fetch a device with its latest metrics, active alerts and pending update
+// in one query. Lookups go through per-request loaders, so every object at
+// a level of the query is fetched in one batch and each resource once.
+type GraphQLHandler struct {
+	schema   *graphql.Schema
+	services map[string]string
+	client   *http.Client
+	logger   *logger.Logger
+}
+
+// NewGraphQLHandler creates the handler
+func NewGraphQLHandler(cfg *config.Config, log *logger.Logger) *GraphQLHandler {
+	h := &GraphQLHandler{
+		services: cfg.Services,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   log,
+	}
+	h.schema = h.buildSchema()
+	return h
+}
+
+// RegisterRoutes registers the GraphQL endpoint. The caller is responsible
+// for authentication.
+func (h *GraphQLHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/graphql", h.Query)
+	router.POST("/graphql", h.Query)
+}
+
+// Query executes a GraphQL query, sent as a JSON body or, for GET, as the
+// query, operationName and variables parameters
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variables", "details": err.Error()})
+				return
+			}
+		}
+	} else {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGraphQLRequestSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request"})
+			return
+		}
+		if len(body) > maxGraphQLRequestSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request is too large"})
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+			return
+		}
+	}
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), dashboardLoadersKey{}, h.newLoaders())
+	resp := h.schema.Execute(ctx, &req)
+
+	// Requests that could not be executed at all are client errors; field
+	// errors are reported alongside the partial data
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, resp)
+}
+
+// dashboardLoaders are the loaders of one request
+type dashboardLoaders struct {
+	devices   *graphql.Loader
+	templates *graphql.Loader
+	metrics   *graphql.Loader
+	alerts    *graphql.Loader
+	updates   *graphql.Loader
+	releases  *graphql.Loader
+}
+
+type dashboardLoadersKey struct{}
+
+func loadersFrom(ctx context.Context) *dashboardLoaders {
+	return ctx.Value(dashboardLoadersKey{}).(*dashboardLoaders)
+}
+
+func (h *GraphQLHandler) newLoaders() *dashboardLoaders {
+	return &dashboardLoaders{
+		devices: graphql.NewLoader(h.fetcher(func(ctx context.Context, id string) (interface{}, error) {
+			return h.getObject(ctx, "device-service", "/api/v1/devices/"+url.PathEscape(id))
+		})),
+		// Templates are keyed by "id@version"
+		templates: graphql.NewLoader(h.fetcher(func(ctx context.Context, key string) (interface{}, error) {
+			id, version := splitTemplateKey(key)
+			path := "/api/v1/templates/" + url.PathEscape(id)
+			if version != "" {
+				path += "?version=" + url.QueryEscape(version)
+			}
+			return h.getObject(ctx, "template-service", path)
+		})),
+		metrics: graphql.NewLoader(h.fetcher(h.latestMetrics)),
+		alerts: graphql.NewLoader(h.fetcher(func(ctx context.Context, deviceID string) (interface{}, error) {
+			var resp struct {
+				Alerts []interface{} `json:"alerts"`
+			}
+			err := h.getJSON(ctx, "telemetry-service", "/api/v1/telemetry/alerts/"+url.PathEscape(deviceID)+"?status=active", &resp)
+			if err != nil && !errors.Is(err, errResourceNotFound) {
+				return nil, err
+			}
+			if resp.Alerts == nil {
+				resp.Alerts = []interface{}{}
+			}
+			return resp.Alerts, nil
+		})),
+		updates: graphql.NewLoader(h.fetcher(h.pendingUpdate)),
+		releases: graphql.NewLoader(h.fetcher(func(ctx context.Context, id string) (interface{}, error) {
+			return h.getObject(ctx, "ota-service", "/api/v1/ota/releases/"+url.PathEscape(id))
+		})),
+	}
+}
+
+// fetcher turns a single-resource lookup into a batch function. The
+// services look resources up one at a time, so a batch fans out over at
+// most maxConcurrentFetches requests at once.
+func (h *GraphQLHandler) fetcher(fetch func(ctx context.Context, key string) (interface{}, error)) graphql.BatchFunc {
+	return func(ctx context.Context, keys []string) ([]interface{}, error) {
+		values := make([]interface{}, len(keys))
+		slots := make(chan struct{}, maxConcurrentFetches)
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int, key string) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				value, err := fetch(ctx, key)
+				switch {
+				case errors.Is(err, errResourceNotFound):
+					values[i] = nil
+				case err != nil:
+					values[i] = err
+				default:
+					values[i] = value
+				}
+			}(i, key)
+		}
+		wg.Wait()
+		return values, nil
+	}
+}
+
+// latestMetrics returns the newest reading of each metric a device reported
+// in the last day, ordered by metric name
+func (h *GraphQLHandler) latestMetrics(ctx context.Context, deviceID string) (interface{}, error) {
+	var resp struct {
+		Metrics []struct {
+			Timestamp   time.Time   `json:"timestamp"`
+			MetricName  string      `json:"metric_name"`
+			MetricValue interface{} `json:"metric_value"`
+		} `json:"metrics"`
+	}
+	if err := h.getJSON(ctx, "telemetry-service", "/api/v1/telemetry/metrics/"+url.PathEscape(deviceID), &resp); err != nil {
+		if errors.Is(err, errResourceNotFound) {
+			return []interface{}{}, nil
+		}
+		return nil, err
+	}
+
+	latest := make(map[string]int)
+	for i, point := range resp.Metrics {
+		if j, seen := latest[point.MetricName]; !seen || point.Timestamp.After(resp.Metrics[j].Timestamp) {
+			latest[point.MetricName] = i
+		}
+	}
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]interface{}, len(names))
+	for i, name := range names {
+		point := resp.Metrics[latest[name]]
+		metrics[i] = map[string]interface{}{
+			"name":      name,
+			"value":     point.MetricValue,
+			"timestamp": point.Timestamp.Format(time.RFC3339),
+		}
+	}
+	return metrics, nil
+}
+
+// pendingUpdate returns a device's most recent update while it is still in
+// progress
+func (h *GraphQLHandler) pendingUpdate(ctx context.Context, deviceID string) (interface{}, error) {
+	var resp struct {
+		Updates []map[string]interface{} `json:"updates"`
+	}
+	if err := h.getJSON(ctx, "ota-service", "/api/v1/ota/devices/"+url.PathEscape(deviceID)+"/updates?limit=1", &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Updates) == 0 {
+		return nil, nil
+	}
+	switch resp.Updates[0]["status"] {
+	case "pending", "downloading", "installing":
+		return resp.Updates[0], nil
+	}
+	return nil, nil
+}
+
+// getObject fetches a JSON object from a service
+func (h *GraphQLHandler) getObject(ctx context.Context, service, path string) (interface{}, error) {
+	var object map[string]interface{}
+	if err := h.getJSON(ctx, service, path, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// getJSON fetches path from a service and decodes the response into out
+func (h *GraphQLHandler) getJSON(ctx context.Context, service, path string, out interface{}) error {
+	baseURL := h.services[service]
+	if baseURL == "" {
+		return fmt.Errorf("%s is not configured", service)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.logger.Warn("GraphQL lookup failed", "service", service, "error", err)
+		return fmt.Errorf("%s is unavailable", service)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errResourceNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s returned HTTP %d", service, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", service, err)
+	}
+	return nil
+}
+
+func templateKey(id, version string) string {
+	if id == "" {
+		return ""
+	}
+	return id + "@" + version
+}
+
+func splitTemplateKey(key string) (string, string) {
+	if i := strings.LastIndex(key, "@"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// buildSchema defines the dashboard schema:
+//
+//	type Query {
+//	  device(id: ID!): Device
+//	  devices(status: String, templateId: ID, otaChannel: String, limit: Int = 50, offset: Int = 0): [Device]
+//	  template(id: ID!, version: String): Template
+//	}
+//	type Device {
+//	  id, boardType, status, templateId, templateVersion, otaChannel, lastSeen, labels
+//	  template: Template
+//	  latestMetrics: [Metric]
+//	  activeAlerts: [Alert]
+//	  pendingUpdate: DeviceUpdate
+//	}
+//	type DeviceUpdate { releaseId, deploymentId, status, progress, startedAt, release: Release }
+func (h *GraphQLHandler) buildSchema() *graphql.Schema {
+	template := &graphql.Object{Name: "Template", Fields: map[string]*graphql.FieldDef{
+		"id":              {Key: "id"},
+		"name":            {Key: "name"},
+		"version":         {Key: "version"},
+		"category":        {Key: "category"},
+		"description":     {Key: "description"},
+		"boardsSupported": {Key: "boards_supported"},
+	}}
+
+	metric := &graphql.Object{Name: "Metric", Fields: map[string]*graphql.FieldDef{
+		"name":      {Key: "name"},
+		"value":     {Key: "value"},
+		"timestamp": {Key: "timestamp"},
+	}}
+
+	alert := &graphql.Object{Name: "Alert", Fields: map[string]*graphql.FieldDef{
+		"id":             {Key: "alert_id"},
+		"metricName":     {Key: "metric_name"},
+		"severity":       {Key: "severity"},
+		"message":        {Key: "message"},
+		"currentValue":   {Key: "current_value"},
+		"thresholdValue": {Key: "threshold_value"},
+		"status":         {Key: "status"},
+		"triggeredAt":    {Key: "triggered_at"},
+	}}
+
+	release := &graphql.Object{Name: "Release", Fields: map[string]*graphql.FieldDef{
+		"id":           {Key: "release_id"},
+		"name":         {Key: "name"},
+		"version":      {Key: "version"},
+		"channel":      {Key: "channel"},
+		"templateId":   {Key: "template_id"},
+		"releaseNotes": {Key: "release_notes"},
+		"createdAt":    {Key: "created_at"},
+	}}
+
+	update := &graphql.Object{Name: "DeviceUpdate", Fields: map[string]*graphql.FieldDef{
+		"releaseId":    {Key: "release_id"},
+		"deploymentId": {Key: "deployment_id"},
+		"status":       {Key: "status"},
+		"progress":     {Key: "progress"},
+		"startedAt":    {Key: "started_at"},
+		"release": {Type: release, Resolve: loadBy(
+			func(l *dashboardLoaders) *graphql.Loader { return l.releases },
+			func(u map[string]interface{}) string { return stringField(u, "release_id") },
+		)},
+	}}
+
+	deviceID := func(d map[string]interface{}) string { return stringField(d, "device_id") }
+	device := &graphql.Object{Name: "Device", Fields: map[string]*graphql.FieldDef{
+		"id":              {Key: "device_id"},
+		"name":            {Key: "name"},
+		"boardType":       {Key: "board_type"},
+		"status":          {Key: "status"},
+		"templateId":      {Key: "template_id"},
+		"templateVersion": {Key: "template_version"},
+		"otaChannel":      {Key: "ota_channel"},
+		"lastSeen":        {Key: "last_seen"},
+		"labels":          {Key: "labels"},
+		"template": {Type: template, Resolve: loadBy(
+			func(l *dashboardLoaders) *graphql.Loader { return l.templates },
+			func(d map[string]interface{}) string {
+				return templateKey(stringField(d, "template_id"), stringField(d, "template_version"))
+			},
+		)},
+		"latestMetrics": {Type: metric, List: true, Resolve: loadBy(
+			func(l *dashboardLoaders) *graphql.Loader { return l.metrics }, deviceID,
+		)},
+		"activeAlerts": {Type: alert, List: true, Resolve: loadBy(
+			func(l *dashboardLoaders) *graphql.Loader { return l.alerts }, deviceID,
+		)},
+		"pendingUpdate": {Type: update, Resolve: loadBy(
+			func(l *dashboardLoaders) *graphql.Loader { return l.updates }, deviceID,
+		)},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.FieldDef{
+		"device": {
+			Type:      device,
+			Arguments: map[string]*graphql.Argument{"id": {Type: "ID!"}},
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				return loadRoot(ctx, loadersFrom(ctx).devices, args["id"].(string), len(sources))
+			},
+		},
+		"devices": {
+			Type: device,
+			List: true,
+			Arguments: map[string]*graphql.Argument{
+				"status":     {Type: "String"},
+				"templateId": {Type: "ID"},
+				"otaChannel": {Type: "String"},
+				"limit":      {Type: "Int", Default: 50},
+				"offset":     {Type: "Int", Default: 0},
+			},
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				devices, err := h.listDevices(ctx, args)
+				if err != nil {
+					return nil, err
+				}
+				values := make([]interface{}, len(sources))
+				for i := range values {
+					values[i] = devices
+				}
+				return values, nil
+			},
+		},
+		"template": {
+			Type: template,
+			Arguments: map[string]*graphql.Argument{
+				"id":      {Type: "ID!"},
+				"version": {Type: "String"},
+			},
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				version, _ := args["version"].(string)
+				return loadRoot(ctx, loadersFrom(ctx).templates, templateKey(args["id"].(string), version), len(sources))
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
+
+// listDevices lists devices from the device service and primes the device
+// loader with them
+func (h *GraphQLHandler) listDevices(ctx context.Context, args map[string]interface{}) ([]interface{}, error) {
+	limit := args["limit"].(int)
+	if limit <= 0 || limit > 100 {
+		return nil, fmt.Errorf("limit must be between 1 and 100")
+	}
+	offset := args["offset"].(int)
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	if status, ok := args["status"].(string); ok {
+		query.Set("status", status)
+	}
+	if templateID, ok := args["templateId"].(string); ok {
+		query.Set("template_id", templateID)
+	}
+	if channel, ok := args["otaChannel"].(string); ok {
+		query.Set("ota_channel", channel)
+	}
+
+	var resp struct {
+		Devices []map[string]interface{} `json:"devices"`
+	}
+	if err := h.getJSON(ctx, "device-service", "/api/v1/devices?"+query.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	loaders := loadersFrom(ctx)
+	devices := make([]interface{}, len(resp.Devices))
+	for i, device := range resp.Devices {
+		loaders.devices.Prime(stringField(device, "device_id"), device)
+		devices[i] = device
+	}
+	return devices, nil
+}
+
+// loadBy resolves an object field through a loader, keyed by a field of the
+// parent. All parents at a level are loaded in one batch.
+func loadBy(loader func(*dashboardLoaders) *graphql.Loader, keyOf func(map[string]interface{}) string) graphql.BatchResolver {
+	return func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		keys := make([]string, len(sources))
+		for i, src := range sources {
+			if parent, ok := src.(map[string]interface{}); ok {
+				keys[i] = keyOf(parent)
+			}
+		}
+		return loader(loadersFrom(ctx)).LoadMany(ctx, keys)
+	}
+}
+
+// loadRoot resolves a root field by loading a single key
+func loadRoot(ctx context.Context, loader *graphql.Loader, key string, sources int) ([]interface{}, error) {
+	values, err := loader.LoadMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	resolved := make([]interface{}, sources)
+	for i := range resolved {
+		resolved[i] = values[0]
+	}
+	return resolved, nil
+}
+
+func stringField(object map[string]interface{}, key string) string {
+	value, _ := object[key].(string)
+	return value
+}
@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLHandler_DashboardQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// One backend stands in for the device, template, telemetry and OTA
+	// services and counts the requests each path receives
+	var mu sync.Mutex
+	requests := map[string]int{}
+	backend := gin.New()
+	backend.Use(func(c *gin.Context) {
+		mu.Lock()
+		requests[c.Request.URL.Path]++
+		mu.Unlock()
+	})
+	backend.GET("/api/v1/devices", func(c *gin.Context) {
+		assert.Equal(t, "online", c.Query("status"))
+		c.JSON(http.StatusOK, gin.H{"devices": []gin.H{
+			{"device_id": "dev-1", "status": "online", "template_id": "tpl", "template_version": "1.0.0"},
+			{"device_id": "dev-2", "status": "online", "template_id": "tpl", "template_version": "1.0.0"},
+			{"device_id": "dev-3", "status": "online", "template_id": "missing", "template_version": "1.0.0"},
+		}, "total": 3})
+	})
+	backend.GET("/api/v1/templates/:id", func(c *gin.Context) {
+		if c.Param("id") != "tpl" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": "tpl", "name": "Weather Station", "version": c.Query("version")})
+	})
+	backend.GET("/api/v1/telemetry/metrics/:deviceId", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"metrics": []gin.H{
+			{"metric_name": "temperature", "metric_value": 20.5, "timestamp": "2026-10-16T10:00:00Z"},
+			{"metric_name": "temperature", "metric_value": 21.5, "timestamp": "2026-10-16T10:05:00Z"},
+			{"metric_name": "humidity", "metric_value": 40, "timestamp": "2026-10-16T10:00:00Z"},
+		}})
+	})
+	backend.GET("/api/v1/telemetry/alerts/:deviceId", func(c *gin.Context) {
+		assert.Equal(t, "active", c.Query("status"))
+		if c.Param("deviceId") == "dev-3" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"alerts": []gin.H{{"alert_id": "al-" + c.Param("deviceId"), "severity": "high"}}})
+	})
+	backend.GET("/api/v1/ota/devices/:deviceId/updates", func(c *gin.Context) {
+		status := "completed"
+		if c.Param("deviceId") == "dev-1" {
+			status = "downloading"
+		}
+		c.JSON(http.StatusOK, gin.H{"updates": []gin.H{{"release_id": "rel-1", "status": status, "progress": 40}}})
+	})
+	backend.GET("/api/v1/ota/releases/:releaseId", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"release_id": c.Param("releaseId"), "version": "1.2.0"})
+	})
+	services := httptest.NewServer(backend)
+	defer services.Close()
+
+	cfg := &config.Config{Services: map[string]string{
+		"device-service":    services.URL,
+		"template-service":  services.URL,
+		"telemetry-service": services.URL,
+		"ota-service":       services.URL,
+	}}
+	router := gin.New()
+	NewGraphQLHandler(cfg, logger.New("info", "api-gateway")).RegisterRoutes(router.Group("/api/v1"))
+
+	query := `{"query": "query Dashboard($status: String) { devices(status: $status) { id template { name version } latestMetrics { name value } activeAlerts { id } pendingUpdate { status progress release { version } } } device(id: \"dev-1\") { status } }", "variables": {"status": "online"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(query))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"data": {
+			"devices": [
+				{
+					"id": "dev-1",
+					"template": {"name": "Weather Station", "version": "1.0.0"},
+					"latestMetrics": [{"name": "humidity", "value": 40}, {"name": "temperature", "value": 21.5}],
+					"activeAlerts": [{"id": "al-dev-1"}],
+					"pendingUpdate": {"status": "downloading", "progress": 40, "release": {"version": "1.2.0"}}
+				},
+				{
+					"id": "dev-2",
+					"template": {"name": "Weather Station", "version": "1.0.0"},
+					"latestMetrics": [{"name": "humidity", "value": 40}, {"name": "temperature", "value": 21.5}],
+					"activeAlerts": [{"id": "al-dev-2"}],
+					"pendingUpdate": null
+				},
+				{
+					"id": "dev-3",
+					"template": null,
+					"latestMetrics": [{"name": "humidity", "value": 40}, {"name": "temperature", "value": 21.5}],
+					"activeAlerts": null,
+					"pendingUpdate": null
+				}
+			],
+			"device": {"status": "online"}
+		},
+		"errors": [{"message": "telemetry-service returned HTTP 500", "path": ["devices", 2, "activeAlerts"]}]
+	}`, w.Body.String())
+
+	// Shared templates are fetched once, and listed devices are not fetched
+	// again by id
+	assert.Equal(t, 1, requests["/api/v1/templates/tpl"])
+	assert.Equal(t, 1, requests["/api/v1/templates/missing"])
+	assert.Zero(t, requests["/api/v1/devices/dev-1"])
+	assert.Equal(t, 1, requests["/api/v1/ota/releases/rel-1"])
+}
+
+func TestGraphQLHandler_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewGraphQLHandler(&config.Config{}, logger.New("info", "api-gateway")).RegisterRoutes(router.Group("/api/v1"))
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		message string
+	}{
+		{"unknown field", httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query=%7B+devices+%7B+secret+%7D+%7D", nil), `cannot query field \"secret\" on type \"Device\"`},
+		{"mutation", httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{"query": "mutation { devices { id } }"}`)), "mutation operations are not supported"},
+		{"empty query", httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{}`)), "query is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// maxInboundWebhookSize bounds the body of an inbound webhook request
+	maxInboundWebhookSize = 1 << 20
+	// maxArtifactSize bounds a downloaded firmware artifact, matching what
+	// the OTA service accepts for a release
+	maxArtifactSize = 100 << 20
+	// hookCostLimit bounds the work a filter or transform may do per event
+	hookCostLimit = 100000
+)
+
+// Inbound webhook actions
+const (
+	HookActionOTARelease     = "ota_release"
+	HookActionTelemetryAlert = "telemetry_alert"
+)
+
+var (
+	// ErrInvalidHookParams is returned when a transform produces parameters
+	// its action cannot use
+	ErrInvalidHookParams = errors.New("invalid webhook action parameters")
+
+	hookNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// inboundHook is a configured inbound webhook with its compiled expressions
+type inboundHook struct {
+	config.InboundWebhookConfig
+	filter    cel.Program
+	transform cel.Program
+}
+
+// InboundWebhookHandler receives events from external systems, such as
+// GitHub releases, Grafana alerts or LoRaWAN uplinks, and carries out the
+// action each hook maps them to
+type InboundWebhookHandler struct {
+	hooks      map[string]*inboundHook
+	services   map[string]string
+	httpClient *http.Client
+	logger     *logger.Logger
+}
+
+// NewInboundWebhookHandler compiles the configured hooks. A hook that is
+// misconfigured is an error, so a typo cannot silently drop events.
+func NewInboundWebhookHandler(cfg *config.Config, log *logger.Logger) (*InboundWebhookHandler, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("body", cel.DynType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("hook", cel.StringType),
+		cel.CrossTypeNumericComparisons(true),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook expression environment: %w", err)
+	}
+
+	h := &InboundWebhookHandler{
+		hooks:      make(map[string]*inboundHook),
+		services:   cfg.Services,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		logger:     log,
+	}
+	for _, hookConfig := range cfg.InboundWebhooks {
+		hook, err := compileHook(env, hookConfig)
+		if err != nil {
+			return nil, fmt.Errorf("inbound webhook %q: %w", hookConfig.Name, err)
+		}
+		if _, ok := h.hooks[hook.Name]; ok {
+			return nil, fmt.Errorf("inbound webhook %q is configured twice", hook.Name)
+		}
+		h.hooks[hook.Name] = hook
+	}
+	return h, nil
+}
+
+// compileHook validates a hook's settings and compiles its expressions
+func compileHook(env *cel.Env, hookConfig config.InboundWebhookConfig) (*inboundHook, error) {
+	switch {
+	case !hookNamePattern.MatchString(hookConfig.Name):
+		return nil, fmt.Errorf("name must be lowercase letters, digits and dashes")
+	case hookConfig.Secret == "":
+		return nil, fmt.Errorf("secret is required")
+	case hookConfig.Verify != "github" && hookConfig.Verify != "token":
+		return nil, fmt.Errorf("verify must be github or token")
+	case hookConfig.Action != HookActionOTARelease && hookConfig.Action != HookActionTelemetryAlert:
+		return nil, fmt.Errorf("action must be %s or %s", HookActionOTARelease, HookActionTelemetryAlert)
+	case hookConfig.Transform == "":
+		return nil, fmt.Errorf("transform is required")
+	case hookConfig.ArtifactToken != "" && len(hookConfig.ArtifactHosts) == 0:
+		return nil, fmt.Errorf("artifact_hosts is required with artifact_token")
+	}
+
+	hook := &inboundHook{InboundWebhookConfig: hookConfig}
+	var err error
+	if hookConfig.Filter != "" {
+		if hook.filter, err = compileExpression(env, hookConfig.Filter, cel.BoolType); err != nil {
+			return nil, fmt.Errorf("filter: %w", err)
+		}
+	}
+	if hook.transform, err = compileExpression(env, hookConfig.Transform, cel.MapType(cel.StringType, cel.DynType)); err != nil {
+		return nil, fmt.Errorf("transform: %w", err)
+	}
+	return hook, nil
+}
+
+// compileExpression compiles a CEL expression that must produce the given
+// type, or a value only known at runtime
+func compileExpression(env *cel.Env, expression string, want *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if got := ast.OutputType(); !got.IsExactType(want) && !got.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression produces %s, want %s", got, want)
+	}
+	return env.Program(ast, cel.CostLimit(hookCostLimit))
+}
+
+// RegisterRoutes registers the public inbound webhook endpoint
+func (h *InboundWebhookHandler) RegisterRoutes(router gin.IRoutes) {
+	router.POST("/hooks/:name", h.Receive)
+}
+
+// Receive verifies an event, filters and transforms it and carries out the
+// hook's action. Events the filter rejects are acknowledged and ignored.
+func (h *InboundWebhookHandler) Receive(c *gin.Context) {
+	hook, ok := h.hooks[c.Param("name")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook"})
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxInboundWebhookSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook body"})
+		return
+	}
+	if len(payload) > maxInboundWebhookSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Webhook body is too large"})
+		return
+	}
+	if !hook.verify(c.Request.Header, payload) {
+		h.logger.Warn("Rejected inbound webhook with invalid signature", "hook", hook.Name, "client_ip", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	vars := hookVariables(hook.Name, c.Request.Header, payload)
+	if hook.filter != nil {
+		matched, _, err := hook.filter.Eval(vars)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Webhook filter failed", "details": err.Error()})
+			return
+		}
+		if matched.Value() != true {
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+	}
+
+	params, err := hook.params(vars)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Webhook transform failed", "details": err.Error()})
+		return
+	}
+
+	result, err := h.run(c.Request.Context(), hook, params)
+	if errors.Is(err, ErrInvalidHookParams) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Inbound webhook action failed", "hook", hook.Name, "action", hook.Action, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Webhook action failed", "details": err.Error()})
+		return
+	}
+
+	h.logger.Info("Inbound webhook processed", "hook", hook.Name, "action", hook.Action)
+	c.JSON(http.StatusOK, gin.H{"status": "processed", "action": hook.Action, "result": result})
+}
+
+// verify checks a request against the hook's secret
+func (hook *inboundHook) verify(header http.Header, payload []byte) bool {
+	switch hook.Verify {
+	case "github":
+		signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(payload)
+		return hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+	case "token":
+		token := header.Get("X-Webhook-Token")
+		if bearer, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+		return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(hook.Secret)) == 1
+	}
+	return false
+}
+
+// hookVariables are what a hook's expressions see of a request. A body
+// that is not JSON is passed as a string.
+func hookVariables(name string, header http.Header, payload []byte) map[string]interface{} {
+	var body interface{}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		body = string(payload)
+	}
+	headers := make(map[string]string, len(header))
+	for key := range header {
+		headers[strings.ToLower(key)] = header.Get(key)
+	}
+	return map[string]interface{}{"body": body, "headers": headers, "hook": name}
+}
+
+// params evaluates the hook's transform into plain JSON values
+func (hook *inboundHook) params(vars map[string]interface{}) (map[string]interface{}, error) {
+	out, _, err := hook.transform.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	native, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, err
+	}
+	params, ok := native.(*structpb.Value).AsInterface().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("transform must produce a map, got %s", out.Type())
+	}
+	return params, nil
+}
+
+// run carries out a hook's action with the parameters of one event
+func (h *InboundWebhookHandler) run(ctx context.Context, hook *inboundHook, params map[string]interface{}) (interface{}, error) {
+	switch hook.Action {
+	case HookActionOTARelease:
+		return h.createRelease(ctx, hook, params)
+	case HookActionTelemetryAlert:
+		return h.raiseAlert(ctx, hook, params)
+	}
+	return nil, fmt.Errorf("unknown action %q", hook.Action)
+}
+
+// releaseParams are the parameters of the ota_release action
+type releaseParams struct {
+	TemplateID   string            `json:"template_id"`
+	Version      string            `json:"version"`
+	Channel      string            `json:"channel"`
+	ArtifactURL  string            `json:"artifact_url"`
+	Name         string            `json:"name"`
+	ReleaseNotes string            `json:"release_notes"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+// createRelease downloads a CI artifact and creates an OTA release from it
+func (h *InboundWebhookHandler) createRelease(ctx context.Context, hook *inboundHook, params map[string]interface{}) (interface{}, error) {
+	var p releaseParams
+	if err := decodeHookParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.TemplateID == "" || p.Version == "" || p.Channel == "" || p.ArtifactURL == "" {
+		return nil, fmt.Errorf("%w: template_id, version, channel and artifact_url are required", ErrInvalidHookParams)
+	}
+
+	artifact, err := h.download(ctx, hook, p.ArtifactURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"name":          p.Name,
+		"template_id":   p.TemplateID,
+		"version":       p.Version,
+		"channel":       p.Channel,
+		"release_notes": p.ReleaseNotes,
+		"created_by":    "webhook:" + hook.Name,
+	}
+	if len(p.Annotations) > 0 {
+		annotations, err := json.Marshal(p.Annotations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal annotations: %w", err)
+		}
+		fields["annotations"] = string(annotations)
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("failed to build release request: %w", err)
+		}
+	}
+	file, err := form.CreateFormFile("binary", path.Base(p.ArtifactURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build release request: %w", err)
+	}
+	if _, err := file.Write(artifact); err != nil {
+		return nil, fmt.Errorf("failed to build release request: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build release request: %w", err)
+	}
+
+	return h.callService(ctx, "ota-service", "/api/v1/ota/releases", form.FormDataContentType(), &body)
+}
+
+// download fetches a firmware artifact. With artifact hosts configured,
+// the URL must be on one of them, so the hook's artifact token only goes to
+// hosts it was issued for; redirects to other domains drop it.
+func (h *InboundWebhookHandler) download(ctx context.Context, hook *inboundHook, artifactURL string) ([]byte, error) {
+	target, err := url.Parse(artifactURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: invalid artifact_url", ErrInvalidHookParams)
+	}
+	if len(hook.ArtifactHosts) > 0 && !hook.allowsArtifactHost(target) {
+		return nil, fmt.Errorf("%w: artifact_url host %s is not in artifact_hosts", ErrInvalidHookParams, target.Host)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid artifact_url: %v", ErrInvalidHookParams, err)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	if hook.ArtifactToken != "" {
+		req.Header.Set("Authorization", "Bearer "+hook.ArtifactToken)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download artifact: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download artifact: %s", resp.Status)
+	}
+
+	artifact, err := io.ReadAll(io.LimitReader(resp.Body, maxArtifactSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download artifact: %w", err)
+	}
+	if len(artifact) > maxArtifactSize {
+		return nil, fmt.Errorf("artifact is larger than %d bytes", maxArtifactSize)
+	}
+	return artifact, nil
+}
+
+// allowsArtifactHost reports whether a URL is on one of the hook's artifact
+// hosts. Hosts listed without a port match any port.
+func (hook *inboundHook) allowsArtifactHost(target *url.URL) bool {
+	for _, host := range hook.ArtifactHosts {
+		if strings.EqualFold(host, target.Host) || strings.EqualFold(host, target.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// alertParams are the parameters of the telemetry_alert action
+type alertParams struct {
+	DeviceID     string                 `json:"device_id"`
+	Message      string                 `json:"message"`
+	Severity     string                 `json:"severity,omitempty"`
+	MetricName   string                 `json:"metric_name,omitempty"`
+	CurrentValue float64                `json:"current_value,omitempty"`
+	Source       string                 `json:"source"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// raiseAlert raises a telemetry alert on a device
+func (h *InboundWebhookHandler) raiseAlert(ctx context.Context, hook *inboundHook, params map[string]interface{}) (interface{}, error) {
+	var p alertParams
+	if err := decodeHookParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.DeviceID == "" || p.Message == "" {
+		return nil, fmt.Errorf("%w: device_id and message are required", ErrInvalidHookParams)
+	}
+	p.Source = "webhook:" + hook.Name
+
+	body, _ := json.Marshal(p)
+	return h.callService(ctx, "telemetry-service", "/api/v1/telemetry/alerts", "application/json", bytes.NewReader(body))
+}
+
+// decodeHookParams decodes transform output into an action's parameters
+func decodeHookParams(params map[string]interface{}, target interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHookParams, err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHookParams, err)
+	}
+	return nil
+}
+
+// callService posts to a platform service and returns its JSON response
+func (h *InboundWebhookHandler) callService(ctx context.Context, service, path, contentType string, body io.Reader) (interface{}, error) {
+	baseURL := h.services[service]
+	if baseURL == "" {
+		return nil, fmt.Errorf("%s is not configured", service)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+
+	var result interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if body, ok := result.(map[string]interface{}); ok && body["error"] != nil {
+			return nil, fmt.Errorf("%s: %v", service, body["error"])
+		}
+		return nil, fmt.Errorf("%s: %s", service, resp.Status)
+	}
+	return result, nil
+}
@@ -0,0 +1,236 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_InboundWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// CI artifact storage, requiring the hook's artifact token
+	artifacts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ci-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("firmware-image"))
+	}))
+	defer artifacts.Close()
+	artifactsURL, err := url.Parse(artifacts.URL)
+	require.NoError(t, err)
+
+	// Any other host must never see the artifact token
+	var leaked []string
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = append(leaked, r.Header.Get("Authorization"))
+		w.Write([]byte("other-image"))
+	}))
+	defer elsewhere.Close()
+
+	// OTA and telemetry services recording what the hooks send them
+	var release map[string]string
+	var binary string
+	var alert map[string]interface{}
+	backend := gin.New()
+	backend.POST("/api/v1/ota/releases", func(c *gin.Context) {
+		require.NoError(t, c.Request.ParseMultipartForm(1<<20))
+		release = map[string]string{}
+		for name, values := range c.Request.MultipartForm.Value {
+			release[name] = values[0]
+		}
+		file, _, err := c.Request.FormFile("binary")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(file)
+		binary = string(data)
+		c.JSON(http.StatusCreated, gin.H{"release_id": "rel-1"})
+	})
+	backend.POST("/api/v1/telemetry/alerts", func(c *gin.Context) {
+		require.NoError(t, c.ShouldBindJSON(&alert))
+		c.JSON(http.StatusCreated, gin.H{"id": "alert-1"})
+	})
+	services := httptest.NewServer(backend)
+	defer services.Close()
+
+	cfg := &config.Config{
+		ServiceName: "api-gateway",
+		JWTSecret:   testJWTSecret,
+		Services: map[string]string{
+			"ota-service":       services.URL,
+			"telemetry-service": services.URL,
+		},
+		InboundWebhooks: []config.InboundWebhookConfig{
+			{
+				Name:   "github-releases",
+				Secret: "gh-secret",
+				Verify: "github",
+				Filter: `headers["x-github-event"] == "release" && body.action == "published"`,
+				Transform: `{"template_id": "sensor-node", "channel": "stable",
+					"version": body.release.tag_name.replace("v", "", 1),
+					"artifact_url": body.release.assets[0].url,
+					"annotations": {"git.commit": body.release.target_commitish}}`,
+				Action:        HookActionOTARelease,
+				ArtifactToken: "ci-token",
+				ArtifactHosts: []string{artifactsURL.Host},
+			},
+			{
+				Name:   "grafana",
+				Secret: "grafana-token",
+				Verify: "token",
+				Filter: `body.status == "firing"`,
+				Transform: `{"device_id": body.alerts[0].labels.device_id, "message": body.title,
+					"severity": body.commonLabels.severity, "current_value": body.alerts[0].values.B}`,
+				Action: HookActionTelemetryAlert,
+			},
+		},
+	}
+	gw, err := NewGateway(cfg, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+
+	post := func(hook, body string, headers map[string]string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/hooks/"+hook, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	githubHeaders := func(body, event string) map[string]string {
+		mac := hmac.New(sha256.New, []byte("gh-secret"))
+		mac.Write([]byte(body))
+		return map[string]string{
+			"X-GitHub-Event":      event,
+			"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		}
+	}
+
+	published := `{"action": "published", "release": {"tag_name": "v1.4.0", "target_commitish": "abc123",
+		"assets": [{"url": "` + artifacts.URL + `/sensor-node.bin"}]}}`
+
+	t.Run("unknown hook", func(t *testing.T) {
+		code, _ := post("missing", `{}`, nil)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		headers := githubHeaders(published, "release")
+		headers["X-Hub-Signature-256"] = "sha256=" + strings.Repeat("0", 64)
+		code, _ := post("github-releases", published, headers)
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = post("grafana", `{"status": "firing"}`, map[string]string{"Authorization": "Bearer wrong"})
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("filtered event", func(t *testing.T) {
+		code, resp := post("github-releases", `{"zen": "Keep it simple."}`, githubHeaders(`{"zen": "Keep it simple."}`, "ping"))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ignored", resp["status"])
+		assert.Nil(t, release)
+	})
+
+	t.Run("github release creates an OTA release", func(t *testing.T) {
+		code, resp := post("github-releases", published, githubHeaders(published, "release"))
+		require.Equal(t, http.StatusOK, code, resp)
+		assert.Equal(t, "processed", resp["status"])
+		assert.Equal(t, "rel-1", resp["result"].(map[string]interface{})["release_id"])
+
+		assert.Equal(t, "sensor-node", release["template_id"])
+		assert.Equal(t, "1.4.0", release["version"])
+		assert.Equal(t, "stable", release["channel"])
+		assert.Equal(t, "webhook:github-releases", release["created_by"])
+		assert.JSONEq(t, `{"git.commit": "abc123"}`, release["annotations"])
+		assert.Equal(t, "firmware-image", binary)
+	})
+
+	t.Run("artifact on another host", func(t *testing.T) {
+		release, binary = nil, ""
+		for _, artifactURL := range []string{elsewhere.URL + "/sensor-node.bin", "file:///etc/passwd", "not a url"} {
+			body := `{"action": "published", "release": {"tag_name": "v1.4.1", "target_commitish": "abc123",
+				"assets": [{"url": "` + artifactURL + `"}]}}`
+			code, resp := post("github-releases", body, githubHeaders(body, "release"))
+			assert.Equal(t, http.StatusUnprocessableEntity, code, resp)
+		}
+		assert.Nil(t, release)
+		assert.Empty(t, leaked)
+	})
+
+	t.Run("grafana alert creates a telemetry alert", func(t *testing.T) {
+		body := `{"status": "firing", "title": "Overheating", "commonLabels": {"severity": "critical"},
+			"alerts": [{"labels": {"device_id": "dev-7"}, "values": {"B": 91.5}}]}`
+		code, resp := post("grafana", body, map[string]string{"X-Webhook-Token": "grafana-token"})
+		require.Equal(t, http.StatusOK, code, resp)
+
+		assert.Equal(t, "dev-7", alert["device_id"])
+		assert.Equal(t, "Overheating", alert["message"])
+		assert.Equal(t, "critical", alert["severity"])
+		assert.Equal(t, 91.5, alert["current_value"])
+		assert.Equal(t, "webhook:grafana", alert["source"])
+	})
+
+	t.Run("transform missing parameters", func(t *testing.T) {
+		body := `{"status": "firing", "title": "", "commonLabels": {},
+			"alerts": [{"labels": {"device_id": "dev-7"}, "values": {"B": 1}}]}`
+		code, _ := post("grafana", body, map[string]string{"Authorization": "Bearer grafana-token"})
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+	})
+}
+
+func TestNewInboundWebhookHandler_InvalidConfig(t *testing.T) {
+	valid := config.InboundWebhookConfig{
+		Name:      "ci",
+		Secret:    "secret",
+		Verify:    "token",
+		Transform: `{"device_id": body.id, "message": "hi"}`,
+		Action:    HookActionTelemetryAlert,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config.InboundWebhookConfig)
+	}{
+		{"missing secret", func(h *config.InboundWebhookConfig) { h.Secret = "" }},
+		{"unknown verification", func(h *config.InboundWebhookConfig) { h.Verify = "none" }},
+		{"unknown action", func(h *config.InboundWebhookConfig) { h.Action = "reboot" }},
+		{"invalid name", func(h *config.InboundWebhookConfig) { h.Name = "CI Hook" }},
+		{"filter syntax error", func(h *config.InboundWebhookConfig) { h.Filter = `body.action ==` }},
+		{"non-boolean filter", func(h *config.InboundWebhookConfig) { h.Filter = `"published"` }},
+		{"non-map transform", func(h *config.InboundWebhookConfig) { h.Transform = `[1, 2]` }},
+		{"artifact token without hosts", func(h *config.InboundWebhookConfig) { h.ArtifactToken = "ci-token" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := valid
+			tt.modify(&hook)
+			_, err := NewInboundWebhookHandler(&config.Config{InboundWebhooks: []config.InboundWebhookConfig{hook}}, logger.New("info", "api-gateway"))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewInboundWebhookHandler(&config.Config{InboundWebhooks: []config.InboundWebhookConfig{valid, valid}}, logger.New("info", "api-gateway"))
+	assert.Error(t, err)
+
+	_, err = NewInboundWebhookHandler(&config.Config{InboundWebhooks: []config.InboundWebhookConfig{valid}}, logger.New("info", "api-gateway"))
+	assert.NoError(t, err)
+}
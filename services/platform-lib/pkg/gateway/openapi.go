@@ -0,0 +1,687 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/athena/platform-lib/pkg/client"
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run ./sdkgen -openapi ../../../../clients/openapi.json -ts ../../../../clients/athena-ts/src/client.ts -python ../../../../clients/athena-py/athena_client/client.py
+
+// SDKVersion is the version of the OpenAPI spec and the clients generated
+// from it. Bump it, and the package versions in clients/, when the public
+// endpoints or their types change.
+const SDKVersion = "1.12.1"
+
+// OpenAPIPath serves the spec of the public API
+const OpenAPIPath = client.APIPrefix + "/openapi.json"
+
+// apiEndpoint is a public API route described in the OpenAPI spec. Request
+// and response types come from the Go client package, which is the API
+// contract.
+type apiEndpoint struct {
+	Name     string // operation ID and client method name
+	Tag      string
+	Doc      string
+	Method   string
+	Path     string   // relative to /api/v1, with :params as routed
+	Query    []string // optional query parameters
+	Request  interface{}
+	Response interface{} // nil if the response body is not modelled
+	Status   int         // success status, 200 if zero
+	Public   bool        // no token required
+}
+
+var apiEndpoints = []apiEndpoint{
+	{
+		Name:     "login",
+		Tag:      "auth",
+		Doc:      "Exchange a username and password for a token",
+		Method:   http.MethodPost,
+		Path:     "/auth/login",
+		Request:  client.LoginRequest{},
+		Response: client.LoginResponse{},
+		Public:   true,
+	},
+	{
+		Name:     "listDevices",
+		Tag:      "devices",
+		Doc:      "List devices",
+		Method:   http.MethodGet,
+		Path:     "/devices",
+		Query:    []string{"status", "board_type", "template_id", "ota_channel", "parent_id", "selector", "group", "limit", "offset"},
+		Response: client.DeviceList{},
+	},
+	{
+		Name:     "registerDevice",
+		Tag:      "devices",
+		Doc:      "Register a provisioned device",
+		Method:   http.MethodPost,
+		Path:     "/devices",
+		Request:  client.DeviceRegistration{},
+		Response: client.Device{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:     "getDevice",
+		Tag:      "devices",
+		Doc:      "Get a device",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id",
+		Response: client.Device{},
+	},
+	{
+		Name:     "getTwin",
+		Tag:      "devices",
+		Doc:      "Get the desired and reported state of a device",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/twin",
+		Response: client.Twin{},
+	},
+	{
+		Name:     "getTwinDelta",
+		Tag:      "devices",
+		Doc:      "Get the desired state a device has not reported yet",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/twin/delta",
+		Response: client.TwinDelta{},
+	},
+	{
+		Name:     "updateDesiredState",
+		Tag:      "devices",
+		Doc:      "Patch the desired state of a device (operators only); 409 if version is stale",
+		Method:   http.MethodPatch,
+		Path:     "/devices/:id/twin/desired",
+		Request:  client.TwinPatch{},
+		Response: client.Twin{},
+	},
+	{
+		Name:     "reportTwinState",
+		Tag:      "devices",
+		Doc:      "Patch the reported state of a device, authorized by the device's own token; 409 if version is stale",
+		Method:   http.MethodPost,
+		Path:     "/devices/:id/twin/reported",
+		Request:  client.TwinPatch{},
+		Response: client.Twin{},
+		Public:   true,
+	},
+	{
+		Name:     "sendCommand",
+		Tag:      "devices",
+		Doc:      "Send a command to a device (operators only)",
+		Method:   http.MethodPost,
+		Path:     "/devices/:id/commands",
+		Request:  client.CommandRequest{},
+		Response: client.Command{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:     "listCommands",
+		Tag:      "devices",
+		Doc:      "List the commands sent to a device, newest first",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/commands",
+		Query:    []string{"status", "limit"},
+		Response: client.CommandList{},
+	},
+	{
+		Name:     "pollCommands",
+		Tag:      "devices",
+		Doc:      "Get the commands waiting for a device and mark them delivered",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/commands/pending",
+		Response: client.CommandList{},
+	},
+	{
+		Name:     "getCommand",
+		Tag:      "devices",
+		Doc:      "Get a command sent to a device",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/commands/:cmdId",
+		Response: client.Command{},
+	},
+	{
+		Name:     "ackCommand",
+		Tag:      "devices",
+		Doc:      "Report that a device ran a command; 409 if it already completed or expired",
+		Method:   http.MethodPost,
+		Path:     "/devices/:id/commands/:cmdId/ack",
+		Request:  client.CommandAck{},
+		Response: client.Command{},
+	},
+	{
+		Name:     "signCertificate",
+		Tag:      "devices",
+		Doc:      "Sign a device's certificate signing request (operators only)",
+		Method:   http.MethodPost,
+		Path:     "/devices/:id/certificates",
+		Request:  client.CertificateRequest{},
+		Response: client.IssuedCredential{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:     "revokeCredential",
+		Tag:      "devices",
+		Doc:      "Revoke a device credential (operators only)",
+		Method:   http.MethodPost,
+		Path:     "/devices/:id/credentials/:name/revoke",
+		Response: client.Credential{},
+	},
+	{
+		Name:     "createClaimToken",
+		Tag:      "devices",
+		Doc:      "Create a single-use claim token a pre-provisioned device registers itself with (operators only)",
+		Method:   http.MethodPost,
+		Path:     "/devices/claim-tokens",
+		Request:  client.ClaimTokenRequest{},
+		Response: client.ClaimToken{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:     "listClaimTokens",
+		Tag:      "devices",
+		Doc:      "List claim tokens, newest first (operators only)",
+		Method:   http.MethodGet,
+		Path:     "/devices/claim-tokens",
+		Query:    []string{"status"},
+		Response: client.ClaimTokenList{},
+	},
+	{
+		Name:     "getClaimToken",
+		Tag:      "devices",
+		Doc:      "Get a claim token (operators only)",
+		Method:   http.MethodGet,
+		Path:     "/devices/claim-tokens/:tokenId",
+		Response: client.ClaimToken{},
+	},
+	{
+		Name:   "revokeClaimToken",
+		Tag:    "devices",
+		Doc:    "Revoke a claim token (operators only)",
+		Method: http.MethodDelete,
+		Path:   "/devices/claim-tokens/:tokenId",
+	},
+	{
+		Name:     "claimDevice",
+		Tag:      "devices",
+		Doc:      "Register a device with a claim token; 401 for invalid or expired tokens, 409 for used ones",
+		Method:   http.MethodPost,
+		Path:     "/devices/claim",
+		Request:  client.DeviceClaim{},
+		Response: client.Device{},
+		Status:   http.StatusCreated,
+		Public:   true,
+	},
+	{
+		Name:     "listDeviceEvents",
+		Tag:      "devices",
+		Doc:      "List a device's lifecycle events, newest first, filtered by comma separated types and an RFC 3339 time range",
+		Method:   http.MethodGet,
+		Path:     "/devices/:id/events",
+		Query:    []string{"type", "since", "until", "limit", "cursor"},
+		Response: client.DeviceEventList{},
+	},
+	{
+		Name:     "listDeviceGroups",
+		Tag:      "devices",
+		Doc:      "List device groups",
+		Method:   http.MethodGet,
+		Path:     "/device-groups",
+		Response: client.DeviceGroupList{},
+	},
+	{
+		Name:     "createDeviceGroup",
+		Tag:      "devices",
+		Doc:      "Create a device group of registered devices (operators only); 409 if the name is taken",
+		Method:   http.MethodPost,
+		Path:     "/device-groups",
+		Request:  client.DeviceGroup{},
+		Response: client.DeviceGroup{},
+	},
+	{
+		Name:     "getDeviceGroup",
+		Tag:      "devices",
+		Doc:      "Get a device group",
+		Method:   http.MethodGet,
+		Path:     "/device-groups/:name",
+		Response: client.DeviceGroup{},
+	},
+	{
+		Name:   "deleteDeviceGroup",
+		Tag:    "devices",
+		Doc:    "Remove a device group (operators only)",
+		Method: http.MethodDelete,
+		Path:   "/device-groups/:name",
+	},
+	{
+		Name:     "addDeviceGroupMembers",
+		Tag:      "devices",
+		Doc:      "Add registered devices to a device group (operators only)",
+		Method:   http.MethodPost,
+		Path:     "/device-groups/:name/members",
+		Request:  client.DeviceGroupMembers{},
+		Response: client.DeviceGroup{},
+	},
+	{
+		Name:     "removeDeviceGroupMember",
+		Tag:      "devices",
+		Doc:      "Remove a device from a device group's members (operators only)",
+		Method:   http.MethodDelete,
+		Path:     "/device-groups/:name/members/:deviceId",
+		Response: client.DeviceGroup{},
+	},
+	{
+		Name:     "getMetrics",
+		Tag:      "telemetry",
+		Doc:      "Get a device's metrics, by default of the last 24 hours",
+		Method:   http.MethodGet,
+		Path:     "/telemetry/metrics/:deviceId",
+		Query:    []string{"start", "end"},
+		Response: client.DeviceMetrics{},
+	},
+	{
+		Name:     "checkSafety",
+		Tag:      "nlp",
+		Doc:      "Check the electrical safety of a wiring diagram on a board, e.g. after editing a generated plan",
+		Method:   http.MethodPost,
+		Path:     "/nlp/safety-check",
+		Request:  client.SafetyCheckRequest{},
+		Response: client.SafetyReport{},
+	},
+	{
+		Name:     "listReleases",
+		Tag:      "ota",
+		Doc:      "List firmware releases",
+		Method:   http.MethodGet,
+		Path:     "/ota/releases",
+		Query:    []string{"template_id", "channel", "environment"},
+		Response: client.ReleaseList{},
+	},
+	{
+		Name:     "getRelease",
+		Tag:      "ota",
+		Doc:      "Get a firmware release",
+		Method:   http.MethodGet,
+		Path:     "/ota/releases/:releaseId",
+		Response: client.Release{},
+	},
+	{
+		Name:     "promoteRelease",
+		Tag:      "ota",
+		Doc:      "Promote a release and its template version to the next environment",
+		Method:   http.MethodPost,
+		Path:     "/ota/releases/:releaseId/promote",
+		Request:  client.PromotionRequest{},
+		Response: client.Promotion{},
+	},
+	{
+		Name:     "createDeployment",
+		Tag:      "ota",
+		Doc:      "Deploy a release to devices",
+		Method:   http.MethodPost,
+		Path:     "/ota/deployments",
+		Request:  client.DeploymentRequest{},
+		Response: client.Deployment{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:     "listDeployments",
+		Tag:      "ota",
+		Doc:      "List the deployments of a release, or the active deployments",
+		Method:   http.MethodGet,
+		Path:     "/ota/deployments",
+		Query:    []string{"release_id"},
+		Response: client.DeploymentList{},
+	},
+	{
+		Name:     "getDeployment",
+		Tag:      "ota",
+		Doc:      "Get a deployment",
+		Method:   http.MethodGet,
+		Path:     "/ota/deployments/:deploymentId",
+		Response: client.Deployment{},
+	},
+	{
+		Name:     "getDeploymentStatus",
+		Tag:      "ota",
+		Doc:      "Get a deployment's progress, with failures counted by error code",
+		Method:   http.MethodGet,
+		Path:     "/ota/deployments/:deploymentId/status",
+		Response: client.DeploymentStatus{},
+	},
+	{
+		Name:     "approveDeployment",
+		Tag:      "ota",
+		Doc:      "Approve a deployment pending approval (administrators only)",
+		Method:   http.MethodPost,
+		Path:     "/ota/deployments/:deploymentId/approve",
+		Request:  client.ApprovalRequest{},
+		Response: client.Deployment{},
+	},
+	{
+		Name:     "rejectDeployment",
+		Tag:      "ota",
+		Doc:      "Reject a deployment pending approval (administrators only)",
+		Method:   http.MethodPost,
+		Path:     "/ota/deployments/:deploymentId/reject",
+		Request:  client.ApprovalRequest{},
+		Response: client.Deployment{},
+	},
+	{
+		Name:     "cancelDeployment",
+		Tag:      "ota",
+		Doc:      "Cancel a deployment",
+		Method:   http.MethodPost,
+		Path:     "/ota/deployments/:deploymentId/cancel",
+		Response: client.Deployment{},
+	},
+	{
+		Name:    "reportUpdateStatus",
+		Tag:     "ota",
+		Doc:     "Report the status of a device's update, with an error code if it failed",
+		Method:  http.MethodPost,
+		Path:    "/ota/devices/:deviceId/updates/status",
+		Request: client.UpdateStatusReport{},
+	},
+	{
+		Name:    "confirmBoot",
+		Tag:     "ota",
+		Doc:     "Confirm a device booted the release it was updated to, after flashing it",
+		Method:  http.MethodPost,
+		Path:    "/ota/updates/:deviceId/confirm-boot",
+		Request: client.BootConfirmation{},
+	},
+	{
+		Name:     "listWebhooks",
+		Tag:      "ota",
+		Doc:      "List the webhooks receiving OTA events",
+		Method:   http.MethodGet,
+		Path:     "/ota/webhooks",
+		Response: client.WebhookList{},
+	},
+	{
+		Name:     "createWebhook",
+		Tag:      "ota",
+		Doc:      "Register a webhook; its secret is only returned here (administrators only)",
+		Method:   http.MethodPost,
+		Path:     "/ota/webhooks",
+		Request:  client.WebhookRequest{},
+		Response: client.Webhook{},
+		Status:   http.StatusCreated,
+	},
+	{
+		Name:   "deleteWebhook",
+		Tag:    "ota",
+		Doc:    "Remove a webhook (administrators only)",
+		Method: http.MethodDelete,
+		Path:   "/ota/webhooks/:webhookId",
+	},
+}
+
+// OpenAPIDocument is an OpenAPI 3.0 document, limited to what the public
+// API uses
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+// OpenAPIInfo describes the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// OpenAPIOperation is one endpoint
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	// Security is empty for public endpoints
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+// OpenAPIParameter is a path or query parameter
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody is a JSON request body
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a body
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIComponents holds the named schemas and the security scheme
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme is how requests are authenticated
+type OpenAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// OpenAPISchema is a JSON schema. An empty schema allows any value.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// RefName returns the name of the component a schema refers to
+func (s *OpenAPISchema) RefName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// BuildOpenAPI describes the public API. Schemas are derived from the Go
+// client types and operations from the endpoint table, so the spec always
+// matches the routes the gateway serves.
+func BuildOpenAPI() *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:       "ATHENA API",
+			Version:     SDKVersion,
+			Description: "Devices, telemetry and OTA updates of the ATHENA platform, served by the API gateway.",
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*OpenAPISchema),
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}},
+	}
+
+	for _, endpoint := range apiEndpoints {
+		operation := &OpenAPIOperation{
+			OperationID: endpoint.Name,
+			Summary:     endpoint.Doc,
+			Tags:        []string{endpoint.Tag},
+			Responses:   make(map[string]*OpenAPIResponse),
+		}
+		if endpoint.Public {
+			operation.Security = &[]map[string][]string{}
+		}
+
+		segments := strings.Split(endpoint.Path, "/")
+		for i, segment := range segments {
+			if name, ok := strings.CutPrefix(segment, ":"); ok {
+				operation.Parameters = append(operation.Parameters, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+				segments[i] = "{" + name + "}"
+			}
+		}
+		for _, name := range endpoint.Query {
+			operation.Parameters = append(operation.Parameters, OpenAPIParameter{Name: name, In: "query", Schema: &OpenAPISchema{Type: "string"}})
+		}
+
+		if endpoint.Request != nil {
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  jsonContent(doc.schema(reflect.TypeOf(endpoint.Request))),
+			}
+		}
+
+		status := endpoint.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := &OpenAPIResponse{Description: http.StatusText(status)}
+		if endpoint.Response != nil {
+			response.Content = jsonContent(doc.schema(reflect.TypeOf(endpoint.Response)))
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+		operation.Responses["default"] = &OpenAPIResponse{
+			Description: "Error",
+			Content:     jsonContent(doc.schema(reflect.TypeOf(APIErrorBody{}))),
+		}
+
+		path := client.APIPrefix + strings.Join(segments, "/")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(endpoint.Method)] = operation
+	}
+
+	return doc
+}
+
+// APIErrorBody is the body of error responses
+type APIErrorBody struct {
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// Operations returns the operations of the document ordered by path and
+// method, with their path and method
+func (d *OpenAPIDocument) Operations() []OpenAPIRoute {
+	var routes []OpenAPIRoute
+	for path, methods := range d.Paths {
+		for method, operation := range methods {
+			routes = append(routes, OpenAPIRoute{Path: path, Method: strings.ToUpper(method), Operation: operation})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// OpenAPIRoute is an operation with the route it is served at
+type OpenAPIRoute struct {
+	Path      string
+	Method    string
+	Operation *OpenAPIOperation
+}
+
+// schema returns the schema of a Go type, adding named structs to the
+// components and referring to them
+func (d *OpenAPIDocument) schema(t reflect.Type) *OpenAPISchema {
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := d.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &OpenAPISchema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		ref := &OpenAPISchema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := d.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+
+		schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+		d.Components.Schemas[t.Name()] = schema
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, optional, ok := jsonField(field)
+			if !ok {
+				continue
+			}
+			schema.Properties[name] = d.schema(field.Type)
+			if !optional && field.Type.Kind() != reflect.Ptr {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		return ref
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+func jsonContent(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// WriteOpenAPI writes the spec of the public API as indented JSON
+func WriteOpenAPI(w io.Writer) error {
+	data, err := json.MarshalIndent(BuildOpenAPI(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// getOpenAPI serves the spec of the public API
+func (g *Gateway) getOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, BuildOpenAPI())
+}
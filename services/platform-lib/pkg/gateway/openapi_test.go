@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/client"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOpenAPIRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gw, err := NewGateway(&config.Config{ServiceName: "api-gateway", JWTSecret: testJWTSecret}, logger.New("info", "api-gateway"))
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, gw)
+	return router
+}
+
+// TestAPIEndpoints_Registered makes sure the spec only describes routes the
+// gateway serves
+func TestAPIEndpoints_Registered(t *testing.T) {
+	router := setupOpenAPIRouter(t)
+
+	routes := make(map[string]bool)
+	for _, route := range router.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	for _, endpoint := range apiEndpoints {
+		assert.True(t, routes[endpoint.Method+" "+client.APIPrefix+endpoint.Path], "%s %s is not routed", endpoint.Method, endpoint.Path)
+	}
+}
+
+func TestBuildOpenAPI(t *testing.T) {
+	doc := BuildOpenAPI()
+	assert.Len(t, doc.Operations(), len(apiEndpoints))
+
+	login := doc.Paths["/api/v1/auth/login"]["post"]
+	require.NotNil(t, login)
+	require.NotNil(t, login.Security)
+	assert.Empty(t, *login.Security)
+
+	getMetrics := doc.Paths["/api/v1/telemetry/metrics/{deviceId}"]["get"]
+	require.NotNil(t, getMetrics)
+	assert.Equal(t, []OpenAPIParameter{
+		{Name: "deviceId", In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}},
+		{Name: "start", In: "query", Schema: &OpenAPISchema{Type: "string"}},
+		{Name: "end", In: "query", Schema: &OpenAPISchema{Type: "string"}},
+	}, getMetrics.Parameters)
+	assert.Nil(t, getMetrics.Security)
+
+	deployment := doc.Components.Schemas["Deployment"]
+	require.NotNil(t, deployment)
+	assert.Contains(t, deployment.Required, "deployment_id")
+	assert.NotContains(t, deployment.Required, "approval")
+	assert.Equal(t, "#/components/schemas/Approval", deployment.Properties["approval"].Ref)
+	assert.Equal(t, "date-time", deployment.Properties["created_at"].Format)
+
+	config := doc.Components.Schemas["DeploymentConfig"]
+	require.NotNil(t, config)
+	assert.True(t, config.Properties["start_at"].Nullable)
+	assert.Equal(t, "integer", config.Properties["promotion_tiers"].Items.Type)
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	router := setupOpenAPIRouter(t)
+
+	// The spec is public
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, SDKVersion, doc.Info.Version)
+	assert.Contains(t, doc.Paths, "/api/v1/devices/{id}")
+}
+
+func TestClient_LoginThroughGateway(t *testing.T) {
+	server := httptest.NewServer(setupOpenAPIRouter(t))
+	defer server.Close()
+
+	c := client.New(server.URL)
+	resp, err := c.Login(context.Background(), "admin", "admin123")
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, "admin", resp.User.Username)
+
+	_, err = c.Login(context.Background(), "admin", "wrong")
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestSDK_UpToDate(t *testing.T) {
+	doc := BuildOpenAPI()
+	generated := map[string]func(io.Writer) error{
+		"../../../../clients/openapi.json":                      WriteOpenAPI,
+		"../../../../clients/athena-ts/src/client.ts":           func(w io.Writer) error { return WriteTypeScriptSDK(w, doc) },
+		"../../../../clients/athena-py/athena_client/client.py": func(w io.Writer) error { return WritePythonSDK(w, doc) },
+	}
+	for path, generate := range generated {
+		var buf bytes.Buffer
+		require.NoError(t, generate(&buf))
+
+		committed, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(committed), buf.String(), "%s is stale, run go generate ./pkg/gateway", path)
+	}
+
+	// Published package versions follow the spec
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	data, err := os.ReadFile("../../../../clients/athena-ts/package.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &pkg))
+	assert.Equal(t, SDKVersion, pkg.Version)
+
+	pyproject, err := os.ReadFile("../../../../clients/athena-py/pyproject.toml")
+	require.NoError(t, err)
+	assert.Contains(t, string(pyproject), `version = "`+SDKVersion+`"`)
+}
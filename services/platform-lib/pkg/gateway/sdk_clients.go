@@ -0,0 +1,437 @@
+package gateway
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// WriteTypeScriptSDK writes the TypeScript client for the public API from
+// its OpenAPI spec
+func WriteTypeScriptSDK(w io.Writer, doc *OpenAPIDocument) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "// Code generated by sdkgen from the OpenAPI spec. DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "export const SDK_VERSION = %q;\n\n", doc.Info.Version)
+
+	for _, name := range schemaNames(doc) {
+		schema := doc.Components.Schemas[name]
+		fmt.Fprintf(out, "export interface %s {\n", name)
+		for _, property := range propertyNames(schema) {
+			marker := "?"
+			if isRequired(schema, property) {
+				marker = ""
+			}
+			fmt.Fprintf(out, "  %s%s: %s;\n", property, marker, tsSchemaType(schema.Properties[property]))
+		}
+		fmt.Fprintf(out, "}\n\n")
+	}
+
+	fmt.Fprintf(out, "export class AthenaApiError extends Error {\n")
+	fmt.Fprintf(out, "  constructor(public readonly status: number, message: string, public readonly details?: string) {\n")
+	fmt.Fprintf(out, "    super(message);\n")
+	fmt.Fprintf(out, "    this.name = \"AthenaApiError\";\n")
+	fmt.Fprintf(out, "  }\n")
+	fmt.Fprintf(out, "}\n\n")
+
+	fmt.Fprintf(out, "export interface AthenaClientOptions {\n")
+	fmt.Fprintf(out, "  baseUrl?: string;\n")
+	fmt.Fprintf(out, "  token?: string;\n")
+	fmt.Fprintf(out, "  fetch?: typeof fetch;\n")
+	fmt.Fprintf(out, "}\n\n")
+
+	fmt.Fprintf(out, "export class AthenaClient {\n")
+	fmt.Fprintf(out, "  private readonly baseUrl: string;\n")
+	fmt.Fprintf(out, "  private readonly fetchImpl: typeof fetch;\n")
+	fmt.Fprintf(out, "  token?: string;\n\n")
+	fmt.Fprintf(out, "  constructor(options: AthenaClientOptions = {}) {\n")
+	fmt.Fprintf(out, "    this.baseUrl = (options.baseUrl ?? \"http://localhost:8000\").replace(/\\/+$/, \"\");\n")
+	fmt.Fprintf(out, "    this.token = options.token;\n")
+	fmt.Fprintf(out, "    this.fetchImpl = options.fetch ?? fetch;\n")
+	fmt.Fprintf(out, "  }\n")
+
+	for _, route := range doc.Operations() {
+		writeTypeScriptMethod(out, route)
+	}
+
+	fmt.Fprintf(out, "\n  private async request<T>(method: string, path: string, body?: unknown, query?: Record<string, string | undefined>): Promise<T> {\n")
+	fmt.Fprintf(out, "    const url = new URL(this.baseUrl + path);\n")
+	fmt.Fprintf(out, "    for (const [key, value] of Object.entries(query ?? {})) {\n")
+	fmt.Fprintf(out, "      if (value !== undefined && value !== \"\") {\n")
+	fmt.Fprintf(out, "        url.searchParams.set(key, value);\n")
+	fmt.Fprintf(out, "      }\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    const headers: Record<string, string> = { Accept: \"application/json\" };\n")
+	fmt.Fprintf(out, "    if (body !== undefined) {\n")
+	fmt.Fprintf(out, "      headers[\"Content-Type\"] = \"application/json\";\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    if (this.token) {\n")
+	fmt.Fprintf(out, "      headers[\"Authorization\"] = `Bearer ${this.token}`;\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    const response = await this.fetchImpl(url.toString(), {\n")
+	fmt.Fprintf(out, "      method,\n")
+	fmt.Fprintf(out, "      headers,\n")
+	fmt.Fprintf(out, "      body: body === undefined ? undefined : JSON.stringify(body),\n")
+	fmt.Fprintf(out, "    });\n")
+	fmt.Fprintf(out, "    const payload = await response.json().catch(() => undefined);\n")
+	fmt.Fprintf(out, "    if (!response.ok) {\n")
+	fmt.Fprintf(out, "      throw new AthenaApiError(response.status, payload?.error ?? response.statusText, payload?.details);\n")
+	fmt.Fprintf(out, "    }\n")
+	fmt.Fprintf(out, "    return payload as T;\n")
+	fmt.Fprintf(out, "  }\n")
+	fmt.Fprintf(out, "}\n")
+
+	return out.Flush()
+}
+
+// writeTypeScriptMethod writes the client method for one operation. Path
+// parameters become leading arguments, followed by the request body and an
+// optional query object.
+func writeTypeScriptMethod(out io.Writer, route OpenAPIRoute) {
+	op := route.Operation
+	var args, query []string
+	path := route.Path
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			args = append(args, param.Name+": string")
+			path = strings.Replace(path, "{"+param.Name+"}", "${encodeURIComponent("+param.Name+")}", 1)
+		case "query":
+			query = append(query, param.Name+"?: string")
+		}
+	}
+
+	call := ""
+	if body := requestSchema(op); body != nil {
+		args = append(args, "body: "+tsSchemaType(body))
+		call = ", body"
+	}
+	if len(query) > 0 {
+		if call == "" {
+			call = ", undefined"
+		}
+		args = append(args, "query: { "+strings.Join(query, "; ")+" } = {}")
+		call += ", query"
+	}
+
+	result := "void"
+	if response := responseSchema(op); response != nil {
+		result = tsSchemaType(response)
+	}
+
+	quote := "\""
+	if strings.Contains(path, "${") {
+		quote = "`"
+	}
+
+	fmt.Fprintf(out, "\n  /** %s */\n", op.Summary)
+	fmt.Fprintf(out, "  %s(%s): Promise<%s> {\n", op.OperationID, strings.Join(args, ", "), result)
+	fmt.Fprintf(out, "    return this.request(%q, %s%s%s%s);\n", route.Method, quote, path, quote, call)
+	fmt.Fprintf(out, "  }\n")
+}
+
+// tsSchemaType maps a schema onto its TypeScript type
+func tsSchemaType(s *OpenAPISchema) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.RefName()
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsSchemaType(s.Items) + "[]"
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Record<string, " + tsSchemaType(s.AdditionalProperties) + ">"
+	default:
+		t = "unknown"
+	}
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+// WritePythonSDK writes the Python client for the public API from its
+// OpenAPI spec. Schemas become TypedDicts, so responses are plain dicts,
+// and the client only needs the standard library.
+func WritePythonSDK(w io.Writer, doc *OpenAPIDocument) error {
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# Code generated by sdkgen from the OpenAPI spec. DO NOT EDIT.\n")
+	fmt.Fprintf(out, "\"\"\"Client for the ATHENA API.\"\"\"\n\n")
+	fmt.Fprintf(out, "import json\n")
+	fmt.Fprintf(out, "import urllib.error\n")
+	fmt.Fprintf(out, "import urllib.parse\n")
+	fmt.Fprintf(out, "import urllib.request\n")
+	fmt.Fprintf(out, "from typing import Any, Dict, List, Optional, TypedDict\n\n")
+	fmt.Fprintf(out, "SDK_VERSION = %q\n\n", doc.Info.Version)
+
+	for _, name := range pythonSchemaOrder(doc) {
+		writePythonTypedDict(out, name, doc.Components.Schemas[name])
+	}
+
+	fmt.Fprintf(out, "\nclass AthenaApiError(Exception):\n")
+	fmt.Fprintf(out, "    \"\"\"Raised for responses with a non-2xx status.\"\"\"\n\n")
+	fmt.Fprintf(out, "    def __init__(self, status: int, message: str, details: Optional[str] = None) -> None:\n")
+	fmt.Fprintf(out, "        super().__init__(f\"{status}: {message}\" + (f\": {details}\" if details else \"\"))\n")
+	fmt.Fprintf(out, "        self.status = status\n")
+	fmt.Fprintf(out, "        self.message = message\n")
+	fmt.Fprintf(out, "        self.details = details\n\n\n")
+
+	fmt.Fprintf(out, "class AthenaClient:\n")
+	fmt.Fprintf(out, "    \"\"\"Calls the ATHENA API through the gateway.\"\"\"\n\n")
+	fmt.Fprintf(out, "    def __init__(self, base_url: str = \"http://localhost:8000\", token: Optional[str] = None, timeout: float = 30.0) -> None:\n")
+	fmt.Fprintf(out, "        self.base_url = base_url.rstrip(\"/\")\n")
+	fmt.Fprintf(out, "        self.token = token\n")
+	fmt.Fprintf(out, "        self.timeout = timeout\n")
+
+	for _, route := range doc.Operations() {
+		writePythonMethod(out, route)
+	}
+
+	fmt.Fprintf(out, "\n    def _request(self, method: str, path: str, body: Any = None, query: Optional[Dict[str, Optional[str]]] = None) -> Any:\n")
+	fmt.Fprintf(out, "        url = self.base_url + path\n")
+	fmt.Fprintf(out, "        params = {key: value for key, value in (query or {}).items() if value}\n")
+	fmt.Fprintf(out, "        if params:\n")
+	fmt.Fprintf(out, "            url += \"?\" + urllib.parse.urlencode(params)\n")
+	fmt.Fprintf(out, "        headers = {\"Accept\": \"application/json\"}\n")
+	fmt.Fprintf(out, "        data = None\n")
+	fmt.Fprintf(out, "        if body is not None:\n")
+	fmt.Fprintf(out, "            headers[\"Content-Type\"] = \"application/json\"\n")
+	fmt.Fprintf(out, "            data = json.dumps(body).encode(\"utf-8\")\n")
+	fmt.Fprintf(out, "        if self.token:\n")
+	fmt.Fprintf(out, "            headers[\"Authorization\"] = \"Bearer \" + self.token\n")
+	fmt.Fprintf(out, "        request = urllib.request.Request(url, data=data, headers=headers, method=method)\n")
+	fmt.Fprintf(out, "        try:\n")
+	fmt.Fprintf(out, "            with urllib.request.urlopen(request, timeout=self.timeout) as response:\n")
+	fmt.Fprintf(out, "                payload = response.read()\n")
+	fmt.Fprintf(out, "        except urllib.error.HTTPError as err:\n")
+	fmt.Fprintf(out, "            try:\n")
+	fmt.Fprintf(out, "                error = json.loads(err.read() or b\"{}\")\n")
+	fmt.Fprintf(out, "            except ValueError:\n")
+	fmt.Fprintf(out, "                error = {}\n")
+	fmt.Fprintf(out, "            raise AthenaApiError(err.code, error.get(\"error\") or err.reason, error.get(\"details\")) from None\n")
+	fmt.Fprintf(out, "        return json.loads(payload) if payload else None\n\n\n")
+
+	fmt.Fprintf(out, "def _quote(value: str) -> str:\n")
+	fmt.Fprintf(out, "    return urllib.parse.quote(value, safe=\"\")\n")
+
+	return out.Flush()
+}
+
+// writePythonTypedDict writes a schema as a TypedDict. Optional keys of a
+// schema that also has required ones go in a subclass with total=False.
+func writePythonTypedDict(out io.Writer, name string, schema *OpenAPISchema) {
+	var required, optional []string
+	for _, property := range propertyNames(schema) {
+		if isRequired(schema, property) {
+			required = append(required, property)
+		} else {
+			optional = append(optional, property)
+		}
+	}
+
+	writeClass := func(class, bases string, properties []string) {
+		fmt.Fprintf(out, "\nclass %s(%s):\n", class, bases)
+		for _, property := range properties {
+			fmt.Fprintf(out, "    %s: %s\n", property, pythonSchemaType(schema.Properties[property]))
+		}
+		fmt.Fprintf(out, "\n")
+	}
+
+	switch {
+	case len(optional) == 0:
+		writeClass(name, "TypedDict", required)
+	case len(required) == 0:
+		writeClass(name, "TypedDict, total=False", optional)
+	default:
+		writeClass("_"+name+"Required", "TypedDict", required)
+		writeClass(name, "_"+name+"Required, total=False", optional)
+	}
+}
+
+// writePythonMethod writes the client method for one operation. Path
+// parameters and the request body are positional, query parameters are
+// keyword-only.
+func writePythonMethod(out io.Writer, route OpenAPIRoute) {
+	op := route.Operation
+	args := []string{"self"}
+	var query []string
+	path := route.Path
+	for _, param := range op.Parameters {
+		name := snakeCase(param.Name)
+		switch param.In {
+		case "path":
+			args = append(args, name+": str")
+			path = strings.Replace(path, "{"+param.Name+"}", "{_quote("+name+")}", 1)
+		case "query":
+			query = append(query, name)
+		}
+	}
+
+	call := ""
+	if body := requestSchema(op); body != nil {
+		args = append(args, "body: "+pythonSchemaType(body))
+		call = ", body"
+	}
+	if len(query) > 0 {
+		args = append(args, "*")
+		values := make([]string, len(query))
+		for i, name := range query {
+			args = append(args, name+": Optional[str] = None")
+			values[i] = fmt.Sprintf("%q: %s", name, name)
+		}
+		if call == "" {
+			call = ", None"
+		}
+		call += ", {" + strings.Join(values, ", ") + "}"
+	}
+
+	result := "None"
+	if response := responseSchema(op); response != nil {
+		result = pythonSchemaType(response)
+	}
+
+	prefix := ""
+	if strings.Contains(path, "{") {
+		prefix = "f"
+	}
+
+	fmt.Fprintf(out, "\n    def %s(%s) -> %s:\n", snakeCase(op.OperationID), strings.Join(args, ", "), result)
+	fmt.Fprintf(out, "        \"\"\"%s.\"\"\"\n", op.Summary)
+	if result == "None" {
+		fmt.Fprintf(out, "        self._request(%q, %s%q%s)\n", route.Method, prefix, path, call)
+		return
+	}
+	fmt.Fprintf(out, "        return self._request(%q, %s%q%s)\n", route.Method, prefix, path, call)
+}
+
+// pythonSchemaType maps a schema onto its Python type
+func pythonSchemaType(s *OpenAPISchema) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.RefName()
+	case s.Type == "string":
+		t = "str"
+	case s.Type == "integer":
+		t = "int"
+	case s.Type == "number":
+		t = "float"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "array":
+		t = "List[" + pythonSchemaType(s.Items) + "]"
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Dict[str, " + pythonSchemaType(s.AdditionalProperties) + "]"
+	default:
+		t = "Any"
+	}
+	if s.Nullable {
+		t = "Optional[" + t + "]"
+	}
+	return t
+}
+
+// pythonSchemaOrder orders schemas so that each comes after the schemas it
+// refers to
+func pythonSchemaOrder(doc *OpenAPIDocument) []string {
+	var order []string
+	seen := make(map[string]bool)
+	var visit func(name string)
+	var refs func(s *OpenAPISchema)
+	refs = func(s *OpenAPISchema) {
+		switch {
+		case s == nil:
+		case s.Ref != "":
+			visit(s.RefName())
+		case s.Items != nil:
+			refs(s.Items)
+		case s.AdditionalProperties != nil:
+			refs(s.AdditionalProperties)
+		}
+	}
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		schema := doc.Components.Schemas[name]
+		for _, property := range propertyNames(schema) {
+			refs(schema.Properties[property])
+		}
+		order = append(order, name)
+	}
+	for _, name := range schemaNames(doc) {
+		visit(name)
+	}
+	return order
+}
+
+func schemaNames(doc *OpenAPIDocument) []string {
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func propertyNames(schema *OpenAPISchema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isRequired(schema *OpenAPISchema, property string) bool {
+	for _, name := range schema.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSchema returns the JSON request body schema of an operation
+func requestSchema(op *OpenAPIOperation) *OpenAPISchema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// responseSchema returns the JSON schema of an operation's success response
+func responseSchema(op *OpenAPIOperation) *OpenAPISchema {
+	for status, response := range op.Responses {
+		if strings.HasPrefix(status, "2") && response.Content != nil {
+			return response.Content["application/json"].Schema
+		}
+	}
+	return nil
+}
+
+// snakeCase converts a camelCase name, such as an operation ID, to
+// snake_case
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+// Command sdkgen writes the OpenAPI spec of the public API and the Python and
+// TypeScript clients generated from it
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/athena/platform-lib/pkg/gateway"
+)
+
+func main() {
+	openapi := flag.String("openapi", "", "OpenAPI spec output file")
+	ts := flag.String("ts", "", "TypeScript client output file")
+	python := flag.String("python", "", "Python client output file")
+	flag.Parse()
+
+	doc := gateway.BuildOpenAPI()
+	write(*openapi, gateway.WriteOpenAPI)
+	write(*ts, func(w io.Writer) error { return gateway.WriteTypeScriptSDK(w, doc) })
+	write(*python, func(w io.Writer) error { return gateway.WritePythonSDK(w, doc) })
+}
+
+// write generates one output, skipping outputs without a file
+func write(path string, generate func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+	var buf bytes.Buffer
+	if err := generate(&buf); err != nil {
+		log.Fatalf("failed to generate %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", path, err)
+	}
+}
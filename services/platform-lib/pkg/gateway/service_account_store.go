@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ServiceAccountEntity represents a service account in Datastore
+type ServiceAccountEntity struct {
+	Name        string    `datastore:"name"`
+	Description string    `datastore:"description,noindex"`
+	Scopes      []string  `datastore:"scopes,noindex"`
+	CreatedBy   string    `datastore:"created_by"`
+	CreatedAt   time.Time `datastore:"created_at"`
+}
+
+// ToEntity converts a ServiceAccount to a ServiceAccountEntity
+func (a *ServiceAccount) ToEntity() *ServiceAccountEntity {
+	return &ServiceAccountEntity{
+		Name:        a.Name,
+		Description: a.Description,
+		Scopes:      a.Scopes,
+		CreatedBy:   a.CreatedBy,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// FromEntity converts a ServiceAccountEntity to the ServiceAccount stored
+// under id
+func (ae *ServiceAccountEntity) FromEntity(id string) *ServiceAccount {
+	return &ServiceAccount{
+		ID:          id,
+		Name:        ae.Name,
+		Description: ae.Description,
+		Scopes:      ae.Scopes,
+		CreatedBy:   ae.CreatedBy,
+		CreatedAt:   ae.CreatedAt,
+	}
+}
+
+// ServiceAccountTokenEntity represents a service account token in
+// Datastore. Only the hash of the secret is stored; zero times stand for
+// tokens never used or revoked.
+type ServiceAccountTokenEntity struct {
+	AccountID  string    `datastore:"account_id"`
+	Name       string    `datastore:"name,noindex"`
+	Scopes     []string  `datastore:"scopes,noindex"`
+	Hash       string    `datastore:"hash,noindex"`
+	CreatedAt  time.Time `datastore:"created_at"`
+	ExpiresAt  time.Time `datastore:"expires_at"`
+	LastUsedAt time.Time `datastore:"last_used_at,noindex"`
+	RevokedAt  time.Time `datastore:"revoked_at,noindex"`
+}
+
+// ToEntity converts a ServiceAccountToken to a ServiceAccountTokenEntity
+func (t *ServiceAccountToken) ToEntity() *ServiceAccountTokenEntity {
+	entity := &ServiceAccountTokenEntity{
+		AccountID: t.AccountID,
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		Hash:      t.Hash,
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+	if t.LastUsedAt != nil {
+		entity.LastUsedAt = *t.LastUsedAt
+	}
+	if t.RevokedAt != nil {
+		entity.RevokedAt = *t.RevokedAt
+	}
+	return entity
+}
+
+// FromEntity converts a ServiceAccountTokenEntity to the token stored
+// under id
+func (te *ServiceAccountTokenEntity) FromEntity(id string) *ServiceAccountToken {
+	token := &ServiceAccountToken{
+		ID:        id,
+		AccountID: te.AccountID,
+		Name:      te.Name,
+		Scopes:    te.Scopes,
+		Hash:      te.Hash,
+		CreatedAt: te.CreatedAt,
+		ExpiresAt: te.ExpiresAt,
+	}
+	if !te.LastUsedAt.IsZero() {
+		lastUsed := te.LastUsedAt
+		token.LastUsedAt = &lastUsed
+	}
+	if !te.RevokedAt.IsZero() {
+		revoked := te.RevokedAt
+		token.RevokedAt = &revoked
+	}
+	return token
+}
+
+// DatastoreServiceAccountStore keeps service accounts and their tokens in
+// Datastore. Tokens are keyed by their own ID, which is all a bearer token
+// carries.
+type DatastoreServiceAccountStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreServiceAccountStore creates a service account store on a
+// Datastore client
+func NewDatastoreServiceAccountStore(client *datastore.Client) *DatastoreServiceAccountStore {
+	return &DatastoreServiceAccountStore{client: client}
+}
+
+func serviceAccountKey(id string) *datastore.Key {
+	return datastore.NameKey("ServiceAccount", id, nil)
+}
+
+func serviceAccountTokenKey(id string) *datastore.Key {
+	return datastore.NameKey("ServiceAccountToken", id, nil)
+}
+
+func (s *DatastoreServiceAccountStore) SaveAccount(ctx context.Context, account *ServiceAccount) error {
+	if _, err := s.client.Put(ctx, serviceAccountKey(account.ID), account.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save service account in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreServiceAccountStore) GetAccount(ctx context.Context, id string) (*ServiceAccount, error) {
+	var entity ServiceAccountEntity
+	switch err := s.client.Get(ctx, serviceAccountKey(id), &entity); err {
+	case nil:
+		return entity.FromEntity(id), nil
+	case datastore.ErrNoSuchEntity:
+		return nil, ErrServiceAccountNotFound
+	default:
+		return nil, fmt.Errorf("failed to get service account from Datastore: %w", err)
+	}
+}
+
+func (s *DatastoreServiceAccountStore) ListAccounts(ctx context.Context) ([]*ServiceAccount, error) {
+	var entities []*ServiceAccountEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("ServiceAccount").Order("name"), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts from Datastore: %w", err)
+	}
+	accounts := make([]*ServiceAccount, len(entities))
+	for i, entity := range entities {
+		accounts[i] = entity.FromEntity(keys[i].Name)
+	}
+	return accounts, nil
+}
+
+// DeleteAccount deletes an account and its tokens in one transaction, so
+// none of its tokens can outlive it
+func (s *DatastoreServiceAccountStore) DeleteAccount(ctx context.Context, id string) error {
+	tokenKeys, err := s.client.GetAll(ctx, datastore.NewQuery("ServiceAccountToken").Filter("account_id =", id).KeysOnly(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to query service account tokens from Datastore: %w", err)
+	}
+
+	_, err = s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity ServiceAccountEntity
+		if err := tx.Get(serviceAccountKey(id), &entity); err != nil {
+			return err
+		}
+		return tx.DeleteMulti(append(tokenKeys, serviceAccountKey(id)))
+	})
+	switch err {
+	case nil:
+		return nil
+	case datastore.ErrNoSuchEntity:
+		return ErrServiceAccountNotFound
+	default:
+		return fmt.Errorf("failed to delete service account from Datastore: %w", err)
+	}
+}
+
+// SaveToken saves a token of an existing account
+func (s *DatastoreServiceAccountStore) SaveToken(ctx context.Context, token *ServiceAccountToken) error {
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var account ServiceAccountEntity
+		if err := tx.Get(serviceAccountKey(token.AccountID), &account); err != nil {
+			return err
+		}
+		_, err := tx.Put(serviceAccountTokenKey(token.ID), token.ToEntity())
+		return err
+	})
+	switch err {
+	case nil:
+		return nil
+	case datastore.ErrNoSuchEntity:
+		return ErrServiceAccountNotFound
+	default:
+		return fmt.Errorf("failed to save service account token in Datastore: %w", err)
+	}
+}
+
+func (s *DatastoreServiceAccountStore) GetToken(ctx context.Context, id string) (*ServiceAccountToken, error) {
+	var entity ServiceAccountTokenEntity
+	switch err := s.client.Get(ctx, serviceAccountTokenKey(id), &entity); err {
+	case nil:
+		return entity.FromEntity(id), nil
+	case datastore.ErrNoSuchEntity:
+		return nil, ErrTokenNotFound
+	default:
+		return nil, fmt.Errorf("failed to get service account token from Datastore: %w", err)
+	}
+}
+
+func (s *DatastoreServiceAccountStore) ListTokens(ctx context.Context, accountID string) ([]*ServiceAccountToken, error) {
+	var entities []*ServiceAccountTokenEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("ServiceAccountToken").Filter("account_id =", accountID), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service account tokens from Datastore: %w", err)
+	}
+	tokens := make([]*ServiceAccountToken, len(entities))
+	for i, entity := range entities {
+		tokens[i] = entity.FromEntity(keys[i].Name)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServiceAccountRouter() (*gin.Engine, *ServiceAccountHandler) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewServiceAccountHandler(NewMemoryServiceAccountStore(), logger.New("info", "api-gateway"))
+	router := gin.New()
+
+	admin := router.Group("/admin", func(c *gin.Context) {
+		c.Set("username", "admin")
+		c.Next()
+	})
+	handler.RegisterRoutes(admin)
+
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id")})
+	}
+	v1 := router.Group("/api/v1", handler.RequireToken())
+	v1.GET("/templates", ok)
+	v1.POST("/templates", ok)
+	v1.POST("/ota/deployments", ok)
+	v1.GET("/devices", ok)
+
+	return router, handler
+}
+
+func doJSON(router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func createServiceAccount(t *testing.T, router *gin.Engine, scopes []string) ServiceAccount {
+	w := doJSON(router, http.MethodPost, "/admin/service-accounts", "", CreateServiceAccountRequest{
+		Name:   "ci-pipeline",
+		Scopes: scopes,
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var account ServiceAccount
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	return account
+}
+
+func issueToken(t *testing.T, router *gin.Engine, accountID string, req CreateTokenRequest) CreateTokenResponse {
+	w := doJSON(router, http.MethodPost, "/admin/service-accounts/"+accountID+"/tokens", "", req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp CreateTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestServiceAccountHandler_CreateAccount(t *testing.T) {
+	router, _ := setupServiceAccountRouter()
+
+	account := createServiceAccount(t, router, []string{ScopeTemplatesPublish, ScopeTemplatesRead, ScopeTemplatesRead})
+	assert.Equal(t, []string{ScopeTemplatesPublish, ScopeTemplatesRead}, account.Scopes)
+	assert.Equal(t, "admin", account.CreatedBy)
+
+	w := doJSON(router, http.MethodPost, "/admin/service-accounts", "", CreateServiceAccountRequest{
+		Name:   "bad",
+		Scopes: []string{"devices:wipe"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown scope")
+
+	w = doJSON(router, http.MethodGet, "/admin/service-accounts", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+}
+
+func TestServiceAccountHandler_CreateToken(t *testing.T) {
+	router, _ := setupServiceAccountRouter()
+	account := createServiceAccount(t, router, []string{ScopeTemplatesPublish})
+
+	resp := issueToken(t, router, account.ID, CreateTokenRequest{Name: "github-actions"})
+	assert.True(t, strings.HasPrefix(resp.Token, "athena_sa_"+resp.TokenInfo.ID+"_"))
+	assert.Equal(t, []string{ScopeTemplatesPublish}, resp.TokenInfo.Scopes)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), resp.TokenInfo.ExpiresAt, time.Minute)
+
+	w := doJSON(router, http.MethodPost, "/admin/service-accounts/"+account.ID+"/tokens", "", CreateTokenRequest{
+		Name:   "escalate",
+		Scopes: []string{ScopeDeploymentsTrigger},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "token scopes must be granted to the account")
+
+	w = doJSON(router, http.MethodPost, "/admin/service-accounts/"+account.ID+"/tokens", "", CreateTokenRequest{
+		Name:          "forever",
+		ExpiresInDays: 1000,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(router, http.MethodGet, "/admin/service-accounts/"+account.ID+"/tokens", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), resp.Token[len("athena_sa_"+resp.TokenInfo.ID+"_"):], "secrets are never listed")
+}
+
+func TestServiceAccountHandler_RequireToken(t *testing.T) {
+	router, handler := setupServiceAccountRouter()
+	account := createServiceAccount(t, router, []string{ScopeTemplatesRead, ScopeTemplatesPublish})
+	token := issueToken(t, router, account.ID, CreateTokenRequest{Name: "ci", Scopes: []string{ScopeTemplatesPublish}, ExpiresInDays: 7}).Token
+
+	w := doJSON(router, http.MethodPost, "/api/v1/templates", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "service-account:"+account.ID)
+
+	w = doJSON(router, http.MethodGet, "/api/v1/templates", token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "token scopes narrow the account scopes")
+	assert.Contains(t, w.Body.String(), "INSUFFICIENT_SCOPE")
+
+	w = doJSON(router, http.MethodGet, "/api/v1/devices", token, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "routes without a scope are denied")
+
+	w = doJSON(router, http.MethodPost, "/api/v1/templates", token[:len(token)-2]+"xx", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	handler.now = func() time.Time { return time.Now().AddDate(0, 0, 8) }
+	w = doJSON(router, http.MethodPost, "/api/v1/templates", token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "expired tokens are rejected")
+}
+
+func TestServiceAccountHandler_RevokeToken(t *testing.T) {
+	router, handler := setupServiceAccountRouter()
+	account := createServiceAccount(t, router, []string{ScopeDeploymentsTrigger})
+	resp := issueToken(t, router, account.ID, CreateTokenRequest{Name: "ci"})
+
+	w := doJSON(router, http.MethodPost, "/api/v1/ota/deployments", resp.Token, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := handler.store.GetToken(context.Background(), resp.TokenInfo.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	w = doJSON(router, http.MethodDelete, "/admin/service-accounts/"+account.ID+"/tokens/"+resp.TokenInfo.ID, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(router, http.MethodPost, "/api/v1/ota/deployments", resp.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doJSON(router, http.MethodDelete, "/admin/service-accounts/"+account.ID, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = handler.store.GetToken(context.Background(), resp.TokenInfo.ID)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+}
+
+func TestServiceAccountTokenEntity(t *testing.T) {
+	created := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	account := &ServiceAccount{ID: "sa-1", Name: "ci", Scopes: []string{ScopeFleetApply}, CreatedBy: "admin", CreatedAt: created}
+	assert.Equal(t, account, account.ToEntity().FromEntity("sa-1"))
+
+	token := &ServiceAccountToken{ID: "t1", AccountID: "sa-1", Name: "deploy", Scopes: []string{ScopeFleetApply}, Hash: hashSecret("secret"), CreatedAt: created, ExpiresAt: created.AddDate(0, 0, 30)}
+	assert.Equal(t, token, token.ToEntity().FromEntity("t1"))
+
+	used := created.Add(time.Hour)
+	token.LastUsedAt = &used
+	token.RevokedAt = &used
+	entity := token.ToEntity()
+	assert.Equal(t, token, entity.FromEntity("t1"))
+	assert.NotContains(t, entity.Hash, "secret", "only the hash is stored")
+}
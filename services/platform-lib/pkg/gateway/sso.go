@@ -0,0 +1,409 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// SessionCookieName holds the dashboard session (a refresh token)
+	SessionCookieName = "athena_session"
+	ssoStateCookie    = "athena_sso_state"
+
+	ssoBasePath       = "/api/v1/auth/sso"
+	sessionCookiePath = "/api/v1/auth"
+	ssoStateTTL       = 10 * time.Minute
+	sessionTTL        = 7 * 24 * time.Hour
+)
+
+// rolePermissions grants permissions to SSO users by platform role
+var rolePermissions = map[string][]string{
+	"admin":    {"read", "write", "admin"},
+	"operator": {"read", "write"},
+	"viewer":   {"read"},
+}
+
+// SSOHandler handles OIDC single sign-on for human users and the cookie
+// session used by the web dashboard
+type SSOHandler struct {
+	config    config.SSOConfig
+	providers map[string]identityProvider
+	settings  map[string]config.OIDCProviderConfig
+	jwtAuth   *middleware.JWTAuth
+	stateKey  []byte
+	returnTo  string
+	logger    *logger.Logger
+}
+
+// SSOProviderInfo describes a configured identity provider
+type SSOProviderInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	LoginURL string `json:"login_url"`
+}
+
+// ssoState is the signed login state kept in a cookie between the login
+// redirect and the provider callback
+type ssoState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+	jwt.RegisteredClaims
+}
+
+// NewSSOHandler creates an SSO handler. Providers with invalid configuration
+// are logged and skipped.
+func NewSSOHandler(cfg *config.Config, jwtAuth *middleware.JWTAuth, log *logger.Logger) *SSOHandler {
+	h := &SSOHandler{
+		config:    cfg.SSO,
+		providers: make(map[string]identityProvider),
+		settings:  make(map[string]config.OIDCProviderConfig),
+		jwtAuth:   jwtAuth,
+		stateKey:  []byte(cfg.JWTSecret),
+		returnTo:  strings.TrimSuffix(cfg.Dashboard.BasePath, "/") + "/login?sso=1",
+		logger:    log,
+	}
+	if !cfg.SSO.Enabled {
+		return h
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, providerCfg := range cfg.SSO.Providers {
+		provider, err := newIdentityProvider(providerCfg, client)
+		if err != nil {
+			log.Warn("Skipping SSO provider", "error", err)
+			continue
+		}
+		h.providers[providerCfg.Name] = provider
+		h.settings[providerCfg.Name] = providerCfg
+	}
+
+	return h
+}
+
+// RegisterRoutes registers SSO and dashboard session routes
+func (h *SSOHandler) RegisterRoutes(router *gin.RouterGroup) {
+	sso := router.Group("/auth/sso")
+	{
+		sso.GET("/providers", h.ListProviders)
+		sso.GET("/:provider/login", h.Login)
+		sso.GET("/:provider/callback", h.Callback)
+	}
+
+	router.POST("/auth/session", h.RefreshSession)
+	router.DELETE("/auth/session", h.EndSession)
+}
+
+// ListProviders lists the identity providers users can sign in with
+func (h *SSOHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	providers := make([]SSOProviderInfo, 0, len(names))
+	for _, name := range names {
+		providerType := h.settings[name].Type
+		if providerType == "" {
+			providerType = "oidc"
+		}
+		providers = append(providers, SSOProviderInfo{
+			Name:     name,
+			Type:     providerType,
+			LoginURL: ssoBasePath + "/" + name + "/login",
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// Login redirects the browser to the identity provider
+func (h *SSOHandler) Login(c *gin.Context) {
+	name := c.Param("provider")
+	provider, ok := h.providers[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown SSO provider"})
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if !isLocalRedirect(returnTo) {
+		returnTo = h.returnTo
+	}
+
+	state := &ssoState{
+		Provider: name,
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		ReturnTo: returnTo,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ssoStateTTL)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, state).SignedString(h.stateKey)
+	if err != nil {
+		h.logger.Error("Failed to sign SSO state", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start SSO login"})
+		return
+	}
+
+	loginURL, err := provider.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, state.Verifier, h.callbackURL(c, name))
+	if err != nil {
+		h.logger.Error("Failed to build SSO login URL", "provider", name, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	h.setCookie(c, ssoStateCookie, signed, ssoBasePath+"/"+name, ssoStateTTL)
+	c.Redirect(http.StatusFound, loginURL)
+}
+
+// Callback completes the login, maps the identity to a platform user and
+// starts a dashboard session
+func (h *SSOHandler) Callback(c *gin.Context) {
+	name := c.Param("provider")
+	provider, ok := h.providers[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown SSO provider"})
+		return
+	}
+
+	if idpError := c.Query("error"); idpError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "SSO login was rejected by the identity provider",
+			"details": idpError,
+		})
+		return
+	}
+
+	state, err := h.readState(c, name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid SSO state",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.setCookie(c, ssoStateCookie, "", ssoBasePath+"/"+name, -1)
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), state.Nonce, state.Verifier, h.callbackURL(c, name))
+	if err != nil {
+		h.logger.Warn("SSO login failed", "provider", name, "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "SSO login failed"})
+		return
+	}
+
+	if !h.domainAllowed(name, identity.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account domain is not allowed"})
+		return
+	}
+
+	user := h.mapIdentity(identity)
+	tokenPair, err := h.jwtAuth.GenerateTokenPair(
+		user.ID,
+		user.Username,
+		user.Roles,
+		permissionsForRoles(user.Roles),
+		map[string]string{
+			"provider": identity.Provider,
+			"email":    identity.Email,
+			"name":     identity.Name,
+		},
+	)
+	if err != nil {
+		h.logger.Error("Failed to generate token for SSO user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
+		return
+	}
+
+	h.logger.Info("SSO login", "provider", name, "username", user.Username, "roles", user.Roles)
+	h.setCookie(c, SessionCookieName, tokenPair.RefreshToken, sessionCookiePath, sessionTTL)
+	c.Redirect(http.StatusFound, state.ReturnTo)
+}
+
+// RefreshSession exchanges the session cookie for a fresh access token,
+// rotating the cookie
+func (h *SSOHandler) RefreshSession(c *gin.Context) {
+	session, err := c.Cookie(SessionCookieName)
+	if err != nil || session == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No active session"})
+		return
+	}
+
+	tokenPair, err := h.jwtAuth.RefreshToken(session)
+	if err != nil {
+		h.setCookie(c, SessionCookieName, "", sessionCookiePath, -1)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+		return
+	}
+
+	claims, err := h.jwtAuth.ValidateToken(tokenPair.AccessToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+		return
+	}
+
+	h.setCookie(c, SessionCookieName, tokenPair.RefreshToken, sessionCookiePath, sessionTTL)
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:     tokenPair.AccessToken,
+		ExpiresAt: tokenPair.ExpiresAt,
+		User: UserInfo{
+			ID:       claims.UserID,
+			Username: claims.Username,
+			Roles:    claims.Roles,
+		},
+	})
+}
+
+// EndSession revokes the dashboard session and clears its cookie
+func (h *SSOHandler) EndSession(c *gin.Context) {
+	if session, err := c.Cookie(SessionCookieName); err == nil && session != "" {
+		if err := h.jwtAuth.RevokeToken(session); err != nil {
+			h.logger.Debug("Failed to revoke session", "error", err)
+		}
+	}
+
+	h.setCookie(c, SessionCookieName, "", sessionCookiePath, -1)
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+}
+
+// mapIdentity maps an external identity to a platform user. Users are keyed
+// by provider and subject so the same person signing in through two
+// providers gets two platform users.
+func (h *SSOHandler) mapIdentity(identity *ExternalIdentity) UserInfo {
+	roles := append([]string(nil), h.config.DefaultRoles...)
+	mappings := h.settings[identity.Provider].RoleMappings
+	for _, group := range identity.Groups {
+		for _, role := range mappings[group] {
+			if !containsString(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	sort.Strings(roles)
+
+	return UserInfo{
+		ID:       identity.Provider + ":" + identity.Subject,
+		Username: identity.Username,
+		Roles:    roles,
+	}
+}
+
+// domainAllowed reports whether the user's email domain is accepted by the provider
+func (h *SSOHandler) domainAllowed(provider, email string) bool {
+	allowed := h.settings[provider].AllowedDomains
+	if len(allowed) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	return containsString(allowed, strings.ToLower(email[at+1:]))
+}
+
+func (h *SSOHandler) readState(c *gin.Context, provider string) (*ssoState, error) {
+	cookie, err := c.Cookie(ssoStateCookie)
+	if err != nil {
+		return nil, fmt.Errorf("login state cookie missing")
+	}
+
+	state := &ssoState{}
+	_, err = jwt.ParseWithClaims(cookie, state, func(token *jwt.Token) (interface{}, error) {
+		return h.stateKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("login state invalid: %w", err)
+	}
+
+	if state.Provider != provider || state.State != c.Query("state") {
+		return nil, fmt.Errorf("login state mismatch")
+	}
+
+	return state, nil
+}
+
+// callbackURL returns the provider callback URL registered with the IdP
+func (h *SSOHandler) callbackURL(c *gin.Context, provider string) string {
+	base := strings.TrimSuffix(h.config.PublicURL, "/")
+	if base == "" {
+		base = requestScheme(c) + "://" + c.Request.Host
+	}
+	return base + ssoBasePath + "/" + provider + "/callback"
+}
+
+func (h *SSOHandler) setCookie(c *gin.Context, name, value, path string, ttl time.Duration) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", requestScheme(c) == "https", true)
+}
+
+// permissionsForRoles returns the union of permissions granted by roles
+func permissionsForRoles(roles []string) []string {
+	var permissions []string
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			if !containsString(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// isLocalRedirect reports whether a return URL stays on this host
+func isLocalRedirect(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return false
+	}
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Host == "" && parsed.Scheme == ""
+}
+
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func randomToken() string {
+	bytes := make([]byte, 24)
+	rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,416 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const (
+	googleIssuer = "https://accounts.google.com"
+	githubAPIURL = "https://api.github.com"
+)
+
+// ExternalIdentity is a user identity asserted by an identity provider
+type ExternalIdentity struct {
+	Provider string   `json:"provider"`
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	Email    string   `json:"email,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// identityProvider runs the authorization code flow against one IdP
+type identityProvider interface {
+	// AuthCodeURL returns the IdP login URL for a state, nonce and PKCE verifier
+	AuthCodeURL(ctx context.Context, state, nonce, verifier, redirectURL string) (string, error)
+	// Exchange redeems an authorization code for the user's identity
+	Exchange(ctx context.Context, code, nonce, verifier, redirectURL string) (*ExternalIdentity, error)
+}
+
+// newIdentityProvider builds a provider from configuration. OIDC providers
+// are discovered lazily on first use so an unreachable IdP does not stop the
+// gateway from starting.
+func newIdentityProvider(cfg config.OIDCProviderConfig, client *http.Client) (identityProvider, error) {
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("provider %s: client_id is required", cfg.Name)
+	}
+
+	switch cfg.Type {
+	case "github":
+		return &githubProvider{config: cfg, client: client, apiURL: githubAPIURL}, nil
+	case "google":
+		if cfg.IssuerURL == "" {
+			cfg.IssuerURL = googleIssuer
+		}
+		return &oidcProvider{config: cfg, client: client}, nil
+	case "oidc", "":
+		if cfg.IssuerURL == "" {
+			return nil, fmt.Errorf("provider %s: issuer_url is required", cfg.Name)
+		}
+		return &oidcProvider{config: cfg, client: client}, nil
+	default:
+		return nil, fmt.Errorf("provider %s: unsupported type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// oidcDiscovery is the subset of the OpenID provider metadata used for login
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider authenticates users against an OpenID Connect provider and
+// verifies the returned ID token against the provider's published keys
+type oidcProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	mu        sync.RWMutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	keysAt    time.Time
+}
+
+func (p *oidcProvider) oauthConfig(ctx context.Context, redirectURL string) (*oauth2.Config, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+		RedirectURL: redirectURL,
+		Scopes:      scopes,
+	}, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier, redirectURL string) (string, error) {
+	cfg, err := p.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce, verifier, redirectURL string) (*ExternalIdentity, error) {
+	cfg, err := p.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := cfg.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.identityFromClaims(claims)
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("invalid id_token: nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+func (p *oidcProvider) identityFromClaims(claims jwt.MapClaims) (*ExternalIdentity, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("id_token has no subject")
+	}
+
+	identity := &ExternalIdentity{
+		Provider: p.config.Name,
+		Subject:  subject,
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims["preferred_username"].(string)
+
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		identity.Email = ""
+	}
+	if identity.Username == "" {
+		identity.Username = identity.Email
+	}
+	if identity.Username == "" {
+		identity.Username = subject
+	}
+
+	groupsClaim := p.config.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := claims[groupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(groups)
+	}
+
+	// Google Workspace users carry their domain in the hd claim
+	if domain, ok := claims["hd"].(string); ok && domain != "" {
+		identity.Groups = append(identity.Groups, "domain:"+domain)
+	}
+
+	return identity, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.RLock()
+	discovery := p.discovery
+	p.mu.RUnlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	discovery = &oidcDiscovery{}
+	if err := getJSON(ctx, p.client, wellKnown, "", discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover provider %s: %w", p.config.Name, err)
+	}
+	if discovery.Issuer != strings.TrimSuffix(p.config.IssuerURL, "/") {
+		return nil, fmt.Errorf("provider %s: issuer mismatch %q", p.config.Name, discovery.Issuer)
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
+
+	return discovery, nil
+}
+
+// publicKey returns the signing key for a key ID, refreshing the key set
+// when the ID is unknown so provider key rotation is picked up
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	fresh := time.Since(p.keysAt) < time.Minute
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if fresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, discovery.JWKSURI, "", &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysAt = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// githubProvider authenticates users with GitHub OAuth. GitHub is not an
+// OIDC provider, so the identity is read from the REST API and organization
+// teams are reported as "org/team" groups.
+type githubProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+	apiURL string
+}
+
+func (p *githubProvider) oauthConfig(redirectURL string) *oauth2.Config {
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email", "read:org"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     github.Endpoint,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier, redirectURL string) (string, error) {
+	return p.oauthConfig(redirectURL).AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, nonce, verifier, redirectURL string) (*ExternalIdentity, error) {
+	token, err := p.oauthConfig(redirectURL).Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	return p.fetchIdentity(ctx, token.AccessToken)
+}
+
+func (p *githubProvider) fetchIdentity(ctx context.Context, accessToken string) (*ExternalIdentity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL+"/user", accessToken, &user); err != nil {
+		return nil, fmt.Errorf("failed to fetch GitHub user: %w", err)
+	}
+
+	identity := &ExternalIdentity{
+		Provider: p.config.Name,
+		Subject:  fmt.Sprintf("%d", user.ID),
+		Username: user.Login,
+		Name:     user.Name,
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL+"/user/emails", accessToken, &emails); err == nil {
+		for _, email := range emails {
+			if email.Primary && email.Verified {
+				identity.Email = email.Email
+			}
+		}
+	}
+
+	var teams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := getJSON(ctx, p.client, p.apiURL+"/user/teams", accessToken, &teams); err == nil {
+		orgs := make(map[string]bool)
+		for _, team := range teams {
+			if !orgs[team.Organization.Login] {
+				orgs[team.Organization.Login] = true
+				identity.Groups = append(identity.Groups, team.Organization.Login)
+			}
+			identity.Groups = append(identity.Groups, team.Organization.Login+"/"+team.Slug)
+		}
+	}
+
+	return identity, nil
+}
+
+// getJSON fetches a JSON document, optionally with a bearer token
+func getJSON(ctx context.Context, client *http.Client, url, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
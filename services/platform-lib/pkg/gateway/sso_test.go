@@ -0,0 +1,274 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "test-secret-key-that-is-long-enough"
+
+// fakeOIDCProvider serves discovery, keys and a token endpoint that issues an
+// ID token for the nonce of the last authorization request
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	claims jwt.MapClaims
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &fakeOIDCProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		claims := jwt.MapClaims{
+			"iss":   p.server.URL,
+			"aud":   "athena",
+			"sub":   "user-42",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": p.nonce,
+			"email": "ada@example.com",
+		}
+		for name, value := range p.claims {
+			claims[name] = value
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "idp-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func setupSSORouter(t *testing.T, provider *fakeOIDCProvider, providerCfg config.OIDCProviderConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	providerCfg.Name = "corp"
+	providerCfg.Type = "oidc"
+	providerCfg.IssuerURL = provider.server.URL
+	providerCfg.ClientID = "athena"
+	providerCfg.ClientSecret = "secret"
+
+	cfg := &config.Config{
+		JWTSecret: testJWTSecret,
+		Dashboard: config.DashboardConfig{BasePath: "/dashboard"},
+		SSO: config.SSOConfig{
+			Enabled:      true,
+			DefaultRoles: []string{"viewer"},
+			Providers:    []config.OIDCProviderConfig{providerCfg},
+		},
+	}
+
+	handler := NewSSOHandler(cfg, middleware.NewJWTAuth(testJWTSecret, "athena-platform"), logger.New("info", "api-gateway"))
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+	return router
+}
+
+// startLogin follows the login redirect and returns the state cookie and query
+func startLogin(t *testing.T, router *gin.Engine, provider *fakeOIDCProvider, path string) (*http.Cookie, url.Values) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, provider.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	provider.nonce = location.Query().Get("nonce")
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0], location.Query()
+}
+
+func TestSSOHandler_LoginFlow(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	provider.claims = jwt.MapClaims{"groups": []string{"platform-admins", "unmapped"}}
+	router := setupSSORouter(t, provider, config.OIDCProviderConfig{
+		RoleMappings: map[string][]string{"platform-admins": {"admin", "operator"}},
+	})
+
+	stateCookie, query := startLogin(t, router, provider, "/api/v1/auth/sso/corp/login?return_to=/dashboard/devices")
+	assert.Equal(t, "athena", query.Get("client_id"))
+	assert.Equal(t, "http://example.com/api/v1/auth/sso/corp/callback", query.Get("redirect_uri"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/corp/callback?code=abc&state="+query.Get("state"), nil)
+	req.AddCookie(stateCookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/dashboard/devices", w.Header().Get("Location"))
+
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			session = cookie
+		}
+	}
+	require.NotNil(t, session)
+	assert.True(t, session.HttpOnly)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/session", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp LoginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, "corp:user-42", resp.User.ID)
+	assert.Equal(t, "ada@example.com", resp.User.Username)
+	assert.Equal(t, []string{"admin", "operator", "viewer"}, resp.User.Roles)
+
+	rotated := w.Result().Cookies()[0]
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/auth/session", nil)
+	req.AddCookie(rotated)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/session", nil)
+	req.AddCookie(rotated)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSSOHandler_CallbackRejectsBadState(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setupSSORouter(t, provider, config.OIDCProviderConfig{})
+
+	stateCookie, _ := startLogin(t, router, provider, "/api/v1/auth/sso/corp/login")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/corp/callback?code=abc&state=forged", nil)
+	req.AddCookie(stateCookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/corp/callback?code=abc&state=forged", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSSOHandler_CallbackRejectsDisallowedDomain(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setupSSORouter(t, provider, config.OIDCProviderConfig{AllowedDomains: []string{"athena.dev"}})
+
+	stateCookie, query := startLogin(t, router, provider, "/api/v1/auth/sso/corp/login")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/corp/callback?code=abc&state="+query.Get("state"), nil)
+	req.AddCookie(stateCookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSSOHandler_ListProviders(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	router := setupSSORouter(t, provider, config.OIDCProviderConfig{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/providers", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Providers []SSOProviderInfo `json:"providers"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []SSOProviderInfo{{Name: "corp", Type: "oidc", LoginURL: "/api/v1/auth/sso/corp/login"}}, resp.Providers)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/sso/unknown/login", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGithubProvider_FetchIdentity(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"id": 7, "login": "octocat", "name": "Mona"}`))
+		case "/user/emails":
+			w.Write([]byte(`[{"email": "old@example.com", "primary": false, "verified": true}, {"email": "mona@example.com", "primary": true, "verified": true}]`))
+		case "/user/teams":
+			w.Write([]byte(`[{"slug": "firmware", "organization": {"login": "athena"}}, {"slug": "ops", "organization": {"login": "athena"}}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	provider := &githubProvider{config: config.OIDCProviderConfig{Name: "github"}, client: api.Client(), apiURL: api.URL}
+	identity, err := provider.fetchIdentity(context.Background(), "gh-token")
+	require.NoError(t, err)
+
+	assert.Equal(t, "7", identity.Subject)
+	assert.Equal(t, "octocat", identity.Username)
+	assert.Equal(t, "mona@example.com", identity.Email)
+	assert.Equal(t, []string{"athena", "athena/firmware", "athena/ops"}, identity.Groups)
+}
+
+func TestIsLocalRedirect(t *testing.T) {
+	assert.True(t, isLocalRedirect("/dashboard"))
+	assert.True(t, isLocalRedirect("/dashboard/login?sso=1"))
+	assert.False(t, isLocalRedirect(""))
+	assert.False(t, isLocalRedirect("https://evil.example.com"))
+	assert.False(t, isLocalRedirect("//evil.example.com"))
+	assert.False(t, isLocalRedirect("/\\evil.example.com"))
+}
+
+func TestPermissionsForRoles(t *testing.T) {
+	assert.Equal(t, []string{"read"}, permissionsForRoles([]string{"viewer"}))
+	assert.Equal(t, []string{"read", "write", "admin"}, permissionsForRoles([]string{"admin", "viewer"}))
+	assert.Empty(t, permissionsForRoles([]string{"unknown"}))
+}
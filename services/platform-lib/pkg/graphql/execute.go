@@ -0,0 +1,579 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Schema is a read-only GraphQL schema. Mutations, subscriptions and
+// introspection are not supported.
+type Schema struct {
+	Query *Object
+}
+
+// Object is an object type and its fields
+type Object struct {
+	Name   string
+	Fields map[string]*FieldDef
+}
+
+// FieldDef defines a field of an object type
+type FieldDef struct {
+	// Type is the object type of the field's value, or nil for scalars
+	Type *Object
+	// List is set when the field's value is a list
+	List      bool
+	Arguments map[string]*Argument
+	// Key is the map key the default resolver reads from the parent, which
+	// must be a map[string]interface{}
+	Key string
+	// Resolve resolves the field for parents without a key
+	Resolve BatchResolver
+}
+
+// Argument defines a field argument. Type is a scalar name (ID, String, Int,
+// Float or Boolean), with a trailing "!" when the argument is required.
+type Argument struct {
+	Type    string
+	Default interface{}
+}
+
+// BatchResolver resolves a field for every parent at a level of the query at
+// once, so lookups for a list of objects can be batched. It returns one
+// value per source; an error in place of a value fails only that item.
+type BatchResolver func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error)
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL response. Data is absent when the request could not
+// be executed at all.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error, with the path of the field it applies to
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Execute parses, validates and executes a request
+func (s *Schema) Execute(ctx context.Context, req *Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return errorResponse(err)
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if op.Type != "query" {
+		return errorResponse(fmt.Errorf("%s operations are not supported", op.Type))
+	}
+
+	e := &executor{doc: doc}
+	if e.variables, err = coerceVariables(op.Variables, req.Variables); err != nil {
+		return errorResponse(err)
+	}
+	if err := e.validate(s.Query, op.Selections, map[string]bool{}); err != nil {
+		return errorResponse(err)
+	}
+
+	results := e.executeFields(ctx, s.Query, []source{{value: nil}}, op.Selections)
+	return &Response{Data: results[0], Errors: e.errors}
+}
+
+func errorResponse(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when a document has several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	doc       *Document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// source is a parent object and the path to it
+type source struct {
+	value interface{}
+	path  []interface{}
+}
+
+// validate checks selections against the schema before anything runs
+func (e *executor) validate(obj *Object, selections []Selection, spreading map[string]bool) error {
+	for _, selection := range selections {
+		switch sel := selection.(type) {
+		case *Field:
+			if sel.Name == "__typename" {
+				if len(sel.Selections) > 0 {
+					return fmt.Errorf("field __typename cannot have a selection set")
+				}
+				continue
+			}
+			def, ok := obj.Fields[sel.Name]
+			if !ok {
+				return fmt.Errorf("cannot query field %q on type %q", sel.Name, obj.Name)
+			}
+			for name := range sel.Arguments {
+				if _, ok := def.Arguments[name]; !ok {
+					return fmt.Errorf("unknown argument %q on field %s.%s", name, obj.Name, sel.Name)
+				}
+			}
+			for name, arg := range def.Arguments {
+				if _, given := sel.Arguments[name]; !given && strings.HasSuffix(arg.Type, "!") && arg.Default == nil {
+					return fmt.Errorf("field %s.%s requires argument %q", obj.Name, sel.Name, name)
+				}
+			}
+			if def.Type == nil && len(sel.Selections) > 0 {
+				return fmt.Errorf("field %s.%s is a scalar and cannot have a selection set", obj.Name, sel.Name)
+			}
+			if def.Type != nil {
+				if len(sel.Selections) == 0 {
+					return fmt.Errorf("field %s.%s of type %s needs a selection set", obj.Name, sel.Name, def.Type.Name)
+				}
+				if err := e.validate(def.Type, sel.Selections, spreading); err != nil {
+					return err
+				}
+			}
+		case *InlineFragment:
+			if sel.TypeCondition != "" && sel.TypeCondition != obj.Name {
+				return fmt.Errorf("fragment on %s cannot be spread within %s", sel.TypeCondition, obj.Name)
+			}
+			if err := e.validate(obj, sel.Selections, spreading); err != nil {
+				return err
+			}
+		case *FragmentSpread:
+			fragment, ok := e.doc.Fragments[sel.Name]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", sel.Name)
+			}
+			if fragment.TypeCondition != obj.Name {
+				return fmt.Errorf("fragment %s on %s cannot be spread within %s", sel.Name, fragment.TypeCondition, obj.Name)
+			}
+			if spreading[sel.Name] {
+				return fmt.Errorf("fragment %q spreads itself", sel.Name)
+			}
+			spreading[sel.Name] = true
+			err := e.validate(obj, fragment.Selections, spreading)
+			delete(spreading, sel.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// collectFields flattens fragments and merges fields sharing a response key,
+// in query order
+func (e *executor) collectFields(selections []Selection, keys *[]string, fields map[string][]*Field) error {
+	for _, selection := range selections {
+		include, err := e.included(selection.directives())
+		if err != nil {
+			return err
+		}
+		if !include {
+			continue
+		}
+		switch sel := selection.(type) {
+		case *Field:
+			key := sel.ResponseKey()
+			if _, seen := fields[key]; !seen {
+				*keys = append(*keys, key)
+			} else if fields[key][0].Name != sel.Name {
+				return fmt.Errorf("fields %q and %q both answer as %q", fields[key][0].Name, sel.Name, key)
+			}
+			fields[key] = append(fields[key], sel)
+		case *InlineFragment:
+			if err := e.collectFields(sel.Selections, keys, fields); err != nil {
+				return err
+			}
+		case *FragmentSpread:
+			if err := e.collectFields(e.doc.Fragments[sel.Name].Selections, keys, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// included evaluates @skip and @include
+func (e *executor) included(directives []*Directive) (bool, error) {
+	for _, directive := range directives {
+		if directive.Name != "skip" && directive.Name != "include" {
+			continue
+		}
+		value, err := e.resolveValue(directive.Arguments["if"])
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("@%s needs a Boolean if argument", directive.Name)
+		}
+		if condition == (directive.Name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// executeFields resolves selections for every source, one batch per field,
+// and returns a result object per source
+func (e *executor) executeFields(ctx context.Context, obj *Object, sources []source, selections []Selection) []*orderedMap {
+	results := make([]*orderedMap, len(sources))
+	for i := range results {
+		results[i] = &orderedMap{values: make(map[string]interface{})}
+	}
+
+	var keys []string
+	fields := make(map[string][]*Field)
+	if err := e.collectFields(selections, &keys, fields); err != nil {
+		for i, src := range sources {
+			e.fail(src.path, err)
+			results[i] = nil
+		}
+		return results
+	}
+
+	for _, key := range keys {
+		field := fields[key][0]
+		for _, result := range results {
+			result.keys = append(result.keys, key)
+		}
+		if field.Name == "__typename" {
+			for _, result := range results {
+				result.values[key] = obj.Name
+			}
+			continue
+		}
+
+		def := obj.Fields[field.Name]
+		values, err := e.resolveField(ctx, def, field, sources)
+		if err != nil {
+			for i, src := range sources {
+				e.fail(appendPath(src.path, key), err)
+				results[i].values[key] = nil
+			}
+			continue
+		}
+
+		if def.Type == nil {
+			for i, src := range sources {
+				if itemErr, ok := values[i].(error); ok {
+					e.fail(appendPath(src.path, key), itemErr)
+					values[i] = nil
+				}
+				results[i].values[key] = values[i]
+			}
+			continue
+		}
+
+		// Resolve the children of every parent together, so their fields
+		// are batched too
+		var subSelections []Selection
+		for _, f := range fields[key] {
+			subSelections = append(subSelections, f.Selections...)
+		}
+		var children []source
+		var owners []int
+		var indexes []int
+		for i, src := range sources {
+			path := appendPath(src.path, key)
+			value := values[i]
+			if itemErr, ok := value.(error); ok {
+				e.fail(path, itemErr)
+				value = nil
+			}
+			if value == nil {
+				results[i].values[key] = nil
+				continue
+			}
+			if !def.List {
+				children = append(children, source{value: value, path: path})
+				owners = append(owners, i)
+				indexes = append(indexes, -1)
+				continue
+			}
+			items, ok := value.([]interface{})
+			if !ok {
+				e.fail(path, fmt.Errorf("expected a list for %s", key))
+				results[i].values[key] = nil
+				continue
+			}
+			results[i].values[key] = make([]interface{}, len(items))
+			for j, item := range items {
+				children = append(children, source{value: item, path: appendPath(path, j)})
+				owners = append(owners, i)
+				indexes = append(indexes, j)
+			}
+		}
+
+		var nonNil []source
+		var positions []int
+		for c, child := range children {
+			if child.value == nil {
+				continue
+			}
+			nonNil = append(nonNil, child)
+			positions = append(positions, c)
+		}
+		childResults := make([]*orderedMap, len(children))
+		if len(nonNil) > 0 {
+			for n, result := range e.executeFields(ctx, def.Type, nonNil, subSelections) {
+				childResults[positions[n]] = result
+			}
+		}
+		for c, result := range childResults {
+			var value interface{}
+			if result != nil {
+				value = result
+			}
+			if indexes[c] < 0 {
+				results[owners[c]].values[key] = value
+			} else {
+				results[owners[c]].values[key].([]interface{})[indexes[c]] = value
+			}
+		}
+	}
+	return results
+}
+
+// resolveField runs a field's resolver, or reads its key from each parent
+func (e *executor) resolveField(ctx context.Context, def *FieldDef, field *Field, sources []source) ([]interface{}, error) {
+	args, err := e.coerceArguments(def, field)
+	if err != nil {
+		return nil, err
+	}
+
+	parents := make([]interface{}, len(sources))
+	for i, src := range sources {
+		parents[i] = src.value
+	}
+	if def.Resolve != nil {
+		values, err := def.Resolve(ctx, parents, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(parents) {
+			return nil, fmt.Errorf("resolver for %s returned %d values for %d parents", field.Name, len(values), len(parents))
+		}
+		return values, nil
+	}
+
+	values := make([]interface{}, len(parents))
+	for i, parent := range parents {
+		if m, ok := parent.(map[string]interface{}); ok {
+			values[i] = m[def.Key]
+		}
+	}
+	return values, nil
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+func appendPath(path []interface{}, element interface{}) []interface{} {
+	extended := make([]interface{}, len(path), len(path)+1)
+	copy(extended, path)
+	return append(extended, element)
+}
+
+func (e *executor) coerceArguments(def *FieldDef, field *Field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.Arguments))
+	for name, arg := range def.Arguments {
+		value := arg.Default
+		if literal, given := field.Arguments[name]; given {
+			if variable, isVariable := literal.(Variable); !isVariable || e.variables[string(variable)] != nil {
+				resolved, err := e.resolveValue(literal)
+				if err != nil {
+					return nil, err
+				}
+				if value, err = coerceValue(arg.Type, resolved); err != nil {
+					return nil, fmt.Errorf("argument %q: %w", name, err)
+				}
+			}
+		}
+		if value == nil && strings.HasSuffix(arg.Type, "!") {
+			return nil, fmt.Errorf("argument %q is required", name)
+		}
+		if value != nil {
+			args[name] = value
+		}
+	}
+	return args, nil
+}
+
+// resolveValue substitutes variables into a document value
+func (e *executor) resolveValue(value Value) (interface{}, error) {
+	switch v := value.(type) {
+	case Variable:
+		return e.variables[string(v)], nil
+	case EnumValue:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = resolved
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+func coerceVariables(definitions []*VariableDefinition, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(definitions))
+	for _, def := range definitions {
+		value, given := values[def.Name]
+		if !given || value == nil {
+			value = def.Default
+		}
+		if value == nil {
+			if def.Required {
+				return nil, fmt.Errorf("variable $%s of type %s is required", def.Name, def.Type)
+			}
+			continue
+		}
+		coerced, err := coerceValue(def.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", def.Name, err)
+		}
+		variables[def.Name] = coerced
+	}
+	return variables, nil
+}
+
+// coerceValue converts a literal, JSON or already coerced value to a scalar
+// type
+func coerceValue(typ string, value interface{}) (interface{}, error) {
+	base := strings.TrimSuffix(typ, "!")
+	if value == nil {
+		return nil, nil
+	}
+	switch base {
+	case "ID":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case int, int64:
+			return fmt.Sprint(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return fmt.Sprint(int64(v)), nil
+			}
+		}
+	case "String":
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case "Int":
+		switch v := value.(type) {
+		case int:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return v, nil
+			}
+		case int64:
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v), nil
+			}
+		}
+	case "Float":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case "Boolean":
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil, fmt.Errorf("%v is not a valid %s", value, base)
+}
+
+// orderedMap is a result object that keeps fields in query order
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// Get returns a field of the result
+func (m *orderedMap) Get(key string) interface{} {
+	return m.values[key]
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
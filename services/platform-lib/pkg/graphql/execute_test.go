@@ -0,0 +1,133 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema(calls *[][]interface{}) *Schema {
+	owner := &Object{Name: "Owner", Fields: map[string]*FieldDef{
+		"name": {Key: "name"},
+	}}
+	item := &Object{Name: "Item", Fields: map[string]*FieldDef{
+		"id":   {Key: "id"},
+		"size": {Key: "size"},
+		"owner": {Type: owner, Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			*calls = append(*calls, sources)
+			values := make([]interface{}, len(sources))
+			for i, src := range sources {
+				id := src.(map[string]interface{})["id"].(string)
+				if id == "broken" {
+					values[i] = fmt.Errorf("owner of %s is unavailable", id)
+					continue
+				}
+				values[i] = map[string]interface{}{"name": "owner-" + id}
+			}
+			return values, nil
+		}},
+	}}
+	query := &Object{Name: "Query", Fields: map[string]*FieldDef{
+		"items": {
+			Type:      item,
+			List:      true,
+			Arguments: map[string]*Argument{"first": {Type: "Int", Default: 10}},
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				items := []interface{}{
+					map[string]interface{}{"id": "a", "size": 1},
+					map[string]interface{}{"id": "broken", "size": 2},
+					map[string]interface{}{"id": "c", "size": 3},
+				}
+				if first := args["first"].(int); first < len(items) {
+					items = items[:first]
+				}
+				return []interface{}{items}, nil
+			},
+		},
+		"item": {
+			Type:      item,
+			Arguments: map[string]*Argument{"id": {Type: "ID!"}},
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				return []interface{}{map[string]interface{}{"id": args["id"], "size": 5}}, nil
+			},
+		},
+	}}
+	return &Schema{Query: query}
+}
+
+func execute(t *testing.T, schema *Schema, req *Request) string {
+	t.Helper()
+	body, err := json.Marshal(schema.Execute(context.Background(), req))
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestExecute(t *testing.T) {
+	var calls [][]interface{}
+	schema := testSchema(&calls)
+
+	body := execute(t, schema, &Request{Query: `
+		query Items($n: Int) {
+			items(first: $n) { id ...Sized owner { name } }
+		}
+		fragment Sized on Item { big: size }`,
+		Variables: map[string]interface{}{"n": float64(3)},
+	})
+	assert.JSONEq(t, `{
+		"data": {"items": [
+			{"id": "a", "big": 1, "owner": {"name": "owner-a"}},
+			{"id": "broken", "big": 2, "owner": null},
+			{"id": "c", "big": 3, "owner": {"name": "owner-c"}}
+		]},
+		"errors": [{"message": "owner of broken is unavailable", "path": ["items", 1, "owner"]}]
+	}`, body)
+
+	// Owners of every item are resolved in one batch
+	require.Len(t, calls, 1)
+	assert.Len(t, calls[0], 3)
+}
+
+func TestExecute_FieldOrderAndDirectives(t *testing.T) {
+	var calls [][]interface{}
+	schema := testSchema(&calls)
+
+	body := execute(t, schema, &Request{
+		Query:     `query($skip: Boolean!) { item(id: 7) { size __typename id @skip(if: $skip) } }`,
+		Variables: map[string]interface{}{"skip": true},
+	})
+	assert.Equal(t, `{"data":{"item":{"size":5,"__typename":"Item"}}}`, body)
+}
+
+func TestExecute_Errors(t *testing.T) {
+	var calls [][]interface{}
+	schema := testSchema(&calls)
+
+	tests := []struct {
+		name    string
+		req     *Request
+		message string
+	}{
+		{"syntax", &Request{Query: `{ items { id }`}, "syntax error: unexpected end of document"},
+		{"unknown field", &Request{Query: `{ items { color } }`}, `cannot query field "color" on type "Item"`},
+		{"missing argument", &Request{Query: `{ item { id } }`}, `field Query.item requires argument "id"`},
+		{"missing selection", &Request{Query: `{ items }`}, "field Query.items of type Item needs a selection set"},
+		{"required variable", &Request{Query: `query($id: ID!) { item(id: $id) { id } }`}, "variable $id of type ID! is required"},
+		{"invalid variable", &Request{Query: `query($n: Int) { items(first: $n) { id } }`, Variables: map[string]interface{}{"n": "ten"}}, "variable $n: ten is not a valid Int"},
+		{"mutation", &Request{Query: `mutation { items { id } }`}, "mutation operations are not supported"},
+		{"fragment cycle", &Request{Query: `{ items { ...A } } fragment A on Item { ...A }`}, `fragment "A" spreads itself`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := schema.Execute(context.Background(), tt.req)
+			assert.Nil(t, resp.Data)
+			require.Len(t, resp.Errors, 1)
+			assert.Equal(t, tt.message, resp.Errors[0].Message)
+		})
+	}
+	assert.Empty(t, calls)
+}
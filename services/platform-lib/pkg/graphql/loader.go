@@ -0,0 +1,79 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BatchFunc fetches the values for a batch of keys. It returns one value per
+// key; an error in place of a value fails only that key.
+type BatchFunc func(ctx context.Context, keys []string) ([]interface{}, error)
+
+// Loader batches and caches lookups by key. A loader lives for a single
+// request, so every lookup within the request sees the same value and a
+// key is fetched at most once.
+type Loader struct {
+	batch BatchFunc
+
+	mu    sync.Mutex
+	cache map[string]interface{}
+}
+
+// NewLoader creates a loader backed by batch
+func NewLoader(batch BatchFunc) *Loader {
+	return &Loader{batch: batch, cache: make(map[string]interface{})}
+}
+
+// LoadMany returns the value for each key, fetching the keys not loaded yet
+// in one batch. Empty keys load as nil without a fetch.
+func (l *Loader) LoadMany(ctx context.Context, keys []string) ([]interface{}, error) {
+	l.mu.Lock()
+	var missing []string
+	pending := make(map[string]bool)
+	for _, key := range keys {
+		if key == "" || pending[key] {
+			continue
+		}
+		if _, cached := l.cache[key]; !cached {
+			missing = append(missing, key)
+			pending[key] = true
+		}
+	}
+	l.mu.Unlock()
+
+	if len(missing) > 0 {
+		values, err := l.batch(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(missing) {
+			return nil, fmt.Errorf("batch returned %d values for %d keys", len(values), len(missing))
+		}
+		l.mu.Lock()
+		for i, key := range missing {
+			l.cache[key] = values[i]
+		}
+		l.mu.Unlock()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	results := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if key != "" {
+			results[i] = l.cache[key]
+		}
+	}
+	return results, nil
+}
+
+// Prime stores a value for a key, so a later load does not fetch it. Lists
+// can prime the loader with the objects they already returned.
+func (l *Loader) Prime(key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, cached := l.cache[key]; !cached {
+		l.cache[key] = value
+	}
+}
@@ -0,0 +1,48 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	var batches [][]string
+	loader := NewLoader(func(ctx context.Context, keys []string) ([]interface{}, error) {
+		batches = append(batches, keys)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			if key == "bad" {
+				values[i] = errors.New("bad key")
+				continue
+			}
+			values[i] = "value-" + key
+		}
+		return values, nil
+	})
+
+	values, err := loader.LoadMany(ctx, []string{"a", "b", "a", "", "bad"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"value-a", "value-b", "value-a", nil, errors.New("bad key")}, values)
+	assert.Equal(t, [][]string{{"a", "b", "bad"}}, batches)
+
+	// Loaded and primed keys are not fetched again
+	loader.Prime("c", "primed")
+	values, err = loader.LoadMany(ctx, []string{"b", "c", "d"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"value-b", "primed", "value-d"}, values)
+	assert.Equal(t, [][]string{{"a", "b", "bad"}, {"d"}}, batches)
+}
+
+func TestLoader_BatchError(t *testing.T) {
+	loader := NewLoader(func(ctx context.Context, keys []string) ([]interface{}, error) {
+		return []interface{}{"only one"}, nil
+	})
+
+	_, err := loader.LoadMany(context.Background(), []string{"a", "b"})
+	assert.EqualError(t, err, "batch returned 1 values for 2 keys")
+}
@@ -0,0 +1,650 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is a parsed GraphQL request document
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query in a document. Mutations and subscriptions are parsed
+// so they can be refused with a clear error.
+type Operation struct {
+	Type       string // "query", "mutation" or "subscription"
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []Selection
+}
+
+// VariableDefinition declares an operation variable
+type VariableDefinition struct {
+	Name     string
+	Type     string // e.g. "ID!" or "[String]"
+	Default  Value
+	Required bool
+}
+
+// Fragment is a named fragment definition
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Selection is a field, a fragment spread or an inline fragment
+type Selection interface {
+	directives() []*Directive
+}
+
+// Field selects a field, optionally under an alias
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]Value
+	Directives []*Directive
+	Selections []Selection
+}
+
+// ResponseKey is the key the field's value appears under in the result
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread includes a named fragment
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment groups selections, optionally for a type
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	Selections    []Selection
+}
+
+// Directive is a directive such as @include(if: $flag)
+type Directive struct {
+	Name      string
+	Arguments map[string]Value
+}
+
+func (f *Field) directives() []*Directive          { return f.Directives }
+func (f *FragmentSpread) directives() []*Directive { return f.Directives }
+func (f *InlineFragment) directives() []*Directive { return f.Directives }
+
+// Value is a literal or variable in a document
+type Value interface{}
+
+// Variable refers to an operation variable
+type Variable string
+
+// EnumValue is an unquoted enum literal
+type EnumValue string
+
+// Parse parses a GraphQL document
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: newLexer(source)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Fragments: make(map[string]*Fragment)}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.token.is(tokenPunct, "{"):
+			selections, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: selections})
+		case p.token.is(tokenName, "query"), p.token.is(tokenName, "mutation"), p.token.is(tokenName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.token.is(tokenName, "fragment"):
+			fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.Fragments[fragment.Name]; exists {
+				return nil, fmt.Errorf("fragment %q is defined more than once", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lexer *lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.token.kind == tokenEOF {
+		return fmt.Errorf("syntax error: unexpected end of document")
+	}
+	return fmt.Errorf("syntax error at %d: unexpected %q", p.token.pos, p.token.value)
+}
+
+// expect consumes a punctuator
+func (p *parser) expect(punct string) error {
+	if !p.token.is(tokenPunct, punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// skip consumes a punctuator if it is next
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.token.is(tokenPunct, punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.token.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.advance()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Type: p.token.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tokenName {
+		op.Name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.token.is(tokenPunct, ")") {
+			def, err := p.parseVariableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = selections
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinition() (*VariableDefinition, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	def := &VariableDefinition{Name: name, Type: typ, Required: strings.HasSuffix(typ, "!")}
+	if ok, err := p.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if def.Default, err = p.parseValue(true); err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+func (p *parser) parseType() (string, error) {
+	var typ string
+	if ok, err := p.skip("["); err != nil {
+		return "", err
+	} else if ok {
+		inner, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if ok, err := p.skip("!"); err != nil {
+		return "", err
+	} else if ok {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *parser) parseFragment() (*Fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !p.token.is(tokenName, "on") {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: typeCondition, Selections: selections}, nil
+}
+
+func (p *parser) parseSelectionSet() ([]Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []Selection
+	for !p.token.is(tokenPunct, "}") {
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("syntax error at %d: empty selection set", p.token.pos)
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) parseSelection() (Selection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		return p.parseFragmentSelection()
+	}
+
+	field := &Field{}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.Alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+
+	if field.Arguments, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if p.token.is(tokenPunct, "{") {
+		if field.Selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseFragmentSelection() (Selection, error) {
+	if p.token.kind == tokenName && p.token.value != "on" {
+		spread := &FragmentSpread{Name: p.token.value}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		spread.Directives, err = p.parseDirectives()
+		return spread, err
+	}
+
+	inline := &InlineFragment{}
+	if p.token.is(tokenName, "on") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		typeCondition, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		inline.TypeCondition = typeCondition
+	}
+	var err error
+	if inline.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if inline.Selections, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return inline, nil
+}
+
+func (p *parser) parseDirectives() ([]*Directive, error) {
+	var directives []*Directive
+	for p.token.is(tokenPunct, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, &Directive{Name: name, Arguments: arguments})
+	}
+	return directives, nil
+}
+
+func (p *parser) parseArguments() (map[string]Value, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	arguments := make(map[string]Value)
+	for !p.token.is(tokenPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arguments[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return arguments, p.advance()
+}
+
+// parseValue parses a value; constant values may not contain variables
+func (p *parser) parseValue(constant bool) (Value, error) {
+	tok := p.token
+	switch {
+	case tok.is(tokenPunct, "$"):
+		if constant {
+			return nil, fmt.Errorf("syntax error at %d: variables are not allowed here", tok.pos)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return Variable(name), err
+	case tok.is(tokenPunct, "["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.token.is(tokenPunct, "]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.advance()
+	case tok.is(tokenPunct, "{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{})
+		for !p.token.is(tokenPunct, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.advance()
+	case tok.kind == tokenInt:
+		value, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("syntax error at %d: invalid integer %s", tok.pos, tok.value)
+		}
+		return value, p.advance()
+	case tok.kind == tokenFloat:
+		value, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("syntax error at %d: invalid number %s", tok.pos, tok.value)
+		}
+		return value, p.advance()
+	case tok.kind == tokenString:
+		return tok.value, p.advance()
+	case tok.kind == tokenName:
+		var value Value
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = EnumValue(tok.value)
+		}
+		return value, p.advance()
+	}
+	return nil, p.unexpected()
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+// lexer splits a document into tokens, skipping whitespace, commas and
+// comments
+type lexer struct {
+	source string
+	pos    int
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{source: source}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		if c == '#' {
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.pos++
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.source[l.pos]
+	switch {
+	case strings.HasPrefix(l.source[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, value: "...", pos: start}, nil
+	case strings.IndexByte("{}()[]:$!=@", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.source[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	}
+	return token{}, fmt.Errorf("syntax error at %d: unexpected character %q", start, c)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if l.source[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		from := l.pos
+		for l.pos < len(l.source) && isDigit(l.source[l.pos]) {
+			l.pos++
+		}
+		return l.pos - from
+	}
+	if digits() == 0 {
+		return token{}, fmt.Errorf("syntax error at %d: invalid number", start)
+	}
+
+	kind := tokenInt
+	if l.pos < len(l.source) && l.source[l.pos] == '.' {
+		l.pos++
+		kind = tokenFloat
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at %d: invalid number", start)
+		}
+	}
+	if l.pos < len(l.source) && (l.source[l.pos] == 'e' || l.source[l.pos] == 'E') {
+		l.pos++
+		kind = tokenFloat
+		if l.pos < len(l.source) && (l.source[l.pos] == '+' || l.source[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at %d: invalid number", start)
+		}
+	}
+	return token{kind: kind, value: l.source[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.source[l.pos:], `"""`) {
+		end := strings.Index(l.source[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+		}
+		value := l.source[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return token{kind: tokenString, value: value, pos: start}, nil
+	}
+
+	l.pos++
+	for l.pos < len(l.source) {
+		switch l.source[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"':
+			l.pos++
+			value, err := strconv.Unquote(l.source[start:l.pos])
+			if err != nil {
+				return token{}, fmt.Errorf("syntax error at %d: invalid string", start)
+			}
+			return token{kind: tokenString, value: value, pos: start}, nil
+		case '\n':
+			return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+		default:
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("syntax error at %d: unterminated string", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
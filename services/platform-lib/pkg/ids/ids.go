@@ -0,0 +1,175 @@
+package ids
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/google/uuid"
+)
+
+// Scheme is the format of generated IDs
+type Scheme string
+
+// ID schemes
+const (
+	// SchemeUUID issues random UUIDs, e.g. 0f8fad5b-d9cb-469f-a165-70867728950e
+	SchemeUUID Scheme = "uuid"
+	// SchemePrefixed issues ULIDs prefixed with the resource kind, e.g.
+	// dev_01j9zq3k5mxv8t4w2c6r0n7hbd
+	SchemePrefixed Scheme = "prefixed"
+	// SchemeShort issues 10 random base32 characters, e.g. 7m3qk9x2vh.
+	// They are checked for collisions.
+	SchemeShort Scheme = "short"
+	// SchemeULID issues ULIDs, which sort by creation time, e.g.
+	// 01J9ZQ3K5MXV8T4W2C6R0N7HBD
+	SchemeULID Scheme = "ulid"
+)
+
+// Kind is the type of resource an ID identifies. It is the prefix of
+// prefixed IDs.
+type Kind string
+
+// Resource kinds
+const (
+	KindDevice     Kind = "dev"
+	KindRelease    Kind = "rel"
+	KindDeployment Kind = "dep"
+)
+
+// maxAttempts bounds the retries when a generated ID or name is taken
+const maxAttempts = 10
+
+// crockford is the Crockford base32 alphabet, which leaves out I, L, O
+// and U to avoid misreadings
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var lowerCrockford = strings.ToLower(crockford)
+
+// errExhausted is returned when every attempt was taken
+var errExhausted = fmt.Errorf("no free value after %d attempts", maxAttempts)
+
+// ExistsFunc reports whether an ID or name is already taken
+type ExistsFunc func(ctx context.Context, value string) (bool, error)
+
+// Generator issues IDs in the configured scheme and, when enabled,
+// human-friendly names. A nil Generator issues UUIDs and no names.
+type Generator struct {
+	scheme Scheme
+	names  bool
+	random io.Reader
+	now    func() time.Time
+}
+
+// NewGenerator creates a generator for scheme. An empty scheme is
+// SchemeUUID.
+func NewGenerator(scheme Scheme, names bool) (*Generator, error) {
+	switch scheme {
+	case "":
+		scheme = SchemeUUID
+	case SchemeUUID, SchemePrefixed, SchemeShort, SchemeULID:
+	default:
+		return nil, fmt.Errorf("unknown ID scheme %q", scheme)
+	}
+	return &Generator{scheme: scheme, names: names, random: rand.Reader, now: time.Now}, nil
+}
+
+// NewGeneratorFromConfig creates a generator from the ids configuration
+func NewGeneratorFromConfig(cfg *config.Config) (*Generator, error) {
+	return NewGenerator(Scheme(cfg.IDs.Scheme), cfg.IDs.GenerateNames)
+}
+
+// Scheme returns the scheme of generated IDs
+func (g *Generator) Scheme() Scheme {
+	if g == nil {
+		return SchemeUUID
+	}
+	return g.scheme
+}
+
+// NewID issues an ID for a resource of kind. Short IDs are retried while
+// exists reports them taken; the other schemes are random enough not to
+// collide, and exists may be nil.
+func (g *Generator) NewID(ctx context.Context, kind Kind, exists ExistsFunc) (string, error) {
+	if g == nil {
+		return uuid.New().String(), nil
+	}
+
+	switch g.scheme {
+	case SchemePrefixed:
+		id, err := g.ulid()
+		if err != nil {
+			return "", err
+		}
+		return string(kind) + "_" + strings.ToLower(id), nil
+	case SchemeULID:
+		return g.ulid()
+	case SchemeShort:
+		return unique(ctx, exists, func() (string, error) {
+			return g.short()
+		})
+	default:
+		return uuid.New().String(), nil
+	}
+}
+
+// ulid encodes a 48-bit millisecond timestamp and 80 random bits as 26
+// base32 characters
+func (g *Generator) ulid() (string, error) {
+	var b [16]byte
+	ms := uint64(g.now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	if _, err := io.ReadFull(g.random, b[6:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+
+	// 128 bits take 26 characters; the first holds the top 3 bits
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:]), nil
+}
+
+// short returns 10 random base32 characters, 50 bits in all
+func (g *Generator) short() (string, error) {
+	var b [10]byte
+	if _, err := io.ReadFull(g.random, b[:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	for i := range b {
+		b[i] = lowerCrockford[b[i]&31]
+	}
+	return string(b[:]), nil
+}
+
+// unique generates values until one is not taken
+func unique(ctx context.Context, exists ExistsFunc, generate func() (string, error)) (string, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		value, err := generate()
+		if err != nil {
+			return "", err
+		}
+		if exists == nil {
+			return value, nil
+		}
+		taken, err := exists(ctx, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to check %q: %w", value, err)
+		}
+		if !taken {
+			return value, nil
+		}
+	}
+	return "", errExhausted
+}
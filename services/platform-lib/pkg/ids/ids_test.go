@@ -0,0 +1,122 @@
+package ids
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_NewID(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		scheme  Scheme
+		pattern string
+	}{
+		{SchemeUUID, `^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+		{SchemePrefixed, `^rel_[0-9a-hjkmnp-tv-z]{26}$`},
+		{SchemeShort, `^[0-9a-hjkmnp-tv-z]{10}$`},
+		{SchemeULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scheme), func(t *testing.T) {
+			g, err := NewGenerator(tt.scheme, false)
+			require.NoError(t, err)
+
+			id, err := g.NewID(ctx, KindRelease, nil)
+			require.NoError(t, err)
+			assert.Regexp(t, regexp.MustCompile(tt.pattern), id)
+		})
+	}
+
+	_, err := NewGenerator("snowflake", false)
+	assert.Error(t, err)
+}
+
+func TestGenerator_ULIDSortsByTime(t *testing.T) {
+	g, err := NewGenerator(SchemeULID, false)
+	require.NoError(t, err)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var generated []string
+	for i := 0; i < 5; i++ {
+		g.now = func() time.Time { return base.Add(time.Duration(i) * time.Millisecond) }
+		id, err := g.NewID(context.Background(), KindDevice, nil)
+		require.NoError(t, err)
+		generated = append(generated, id)
+	}
+
+	assert.True(t, sort.StringsAreSorted(generated))
+	// The first 10 characters encode the timestamp
+	assert.Equal(t, "01HK153X00", generated[0][:10])
+}
+
+func TestGenerator_ShortIDCollision(t *testing.T) {
+	g, err := NewGenerator(SchemeShort, false)
+	require.NoError(t, err)
+
+	var checked []string
+	exists := func(ctx context.Context, id string) (bool, error) {
+		checked = append(checked, id)
+		return len(checked) < 3, nil
+	}
+
+	id, err := g.NewID(context.Background(), KindDevice, exists)
+	require.NoError(t, err)
+	require.Len(t, checked, 3)
+	assert.Equal(t, checked[2], id)
+
+	_, err = g.NewID(context.Background(), KindDevice, func(ctx context.Context, id string) (bool, error) {
+		return false, errors.New("datastore unavailable")
+	})
+	assert.ErrorContains(t, err, "datastore unavailable")
+}
+
+func TestGenerator_NewName(t *testing.T) {
+	ctx := context.Background()
+	g, err := NewGenerator(SchemeUUID, true)
+	require.NoError(t, err)
+
+	name, err := g.NewName(ctx, nil)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[a-z]+-[a-z]+$`, name)
+
+	// Once every plain name is taken, names are numbered
+	name, err = g.NewName(ctx, func(ctx context.Context, name string) (bool, error) {
+		return strings.Count(name, "-") == 1, nil
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `^[a-z]+-[a-z]+-[0-9]{4}$`, name)
+
+	_, err = g.NewName(ctx, func(ctx context.Context, name string) (bool, error) {
+		return true, nil
+	})
+	assert.Error(t, err)
+}
+
+func TestGenerator_Disabled(t *testing.T) {
+	ctx := context.Background()
+
+	g, err := NewGenerator("", false)
+	require.NoError(t, err)
+	assert.Equal(t, SchemeUUID, g.Scheme())
+
+	name, err := g.NewName(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	// A nil generator issues UUIDs and no names
+	var none *Generator
+	id, err := none.NewID(ctx, KindDevice, nil)
+	require.NoError(t, err)
+	assert.Len(t, id, 36)
+	assert.False(t, none.NamesEnabled())
+}
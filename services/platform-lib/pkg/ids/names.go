@@ -0,0 +1,71 @@
+package ids
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Word lists for generated names
+var (
+	adjectives = []string{
+		"amber", "ancient", "autumn", "bold", "brave", "bright", "calm", "clever",
+		"cosmic", "crimson", "crisp", "curious", "daring", "dusty", "eager", "early",
+		"fancy", "fearless", "gentle", "golden", "grand", "happy", "hidden", "humble",
+		"icy", "jolly", "keen", "lively", "lucky", "lunar", "merry", "misty",
+		"mellow", "nimble", "noble", "patient", "polar", "proud", "quick", "quiet",
+		"rapid", "rustic", "silent", "silver", "snowy", "solar", "steady", "swift",
+		"tidy", "vivid", "wandering", "witty",
+	}
+	nouns = []string{
+		"badger", "beacon", "bison", "cedar", "comet", "condor", "coral", "crane",
+		"delta", "dolphin", "falcon", "fern", "finch", "fjord", "gecko", "glacier",
+		"harbor", "heron", "ibis", "jaguar", "kestrel", "koala", "lagoon", "lark",
+		"lynx", "maple", "meadow", "meteor", "moose", "nebula", "ocelot", "orca",
+		"otter", "owl", "panda", "pebble", "pine", "puffin", "quartz", "raven",
+		"reef", "river", "sparrow", "summit", "tern", "thistle", "tundra", "valley",
+		"walrus", "willow", "yak", "zephyr",
+	}
+)
+
+// NamesEnabled reports whether the generator issues names
+func (g *Generator) NamesEnabled() bool {
+	return g != nil && g.names
+}
+
+// NewName issues a human-friendly adjective-noun name, such as
+// "brave-otter", that exists does not report taken. Once plain names keep
+// colliding, a number is appended, e.g. "brave-otter-4821". It returns an
+// empty name when names are disabled.
+func (g *Generator) NewName(ctx context.Context, exists ExistsFunc) (string, error) {
+	if !g.NamesEnabled() {
+		return "", nil
+	}
+
+	name, err := unique(ctx, exists, func() (string, error) {
+		return g.name(false)
+	})
+	if !errors.Is(err, errExhausted) {
+		return name, err
+	}
+	name, err = unique(ctx, exists, func() (string, error) {
+		return g.name(true)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate name: %w", err)
+	}
+	return name, nil
+}
+
+func (g *Generator) name(numbered bool) (string, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(g.random, b[:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	name := adjectives[int(b[0])%len(adjectives)] + "-" + nouns[int(b[1])%len(nouns)]
+	if numbered {
+		name += fmt.Sprintf("-%04d", (int(b[2])<<8|int(b[3]))%10000)
+	}
+	return name, nil
+}
@@ -0,0 +1,123 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ResultEntity represents a load test result in Datastore
+type ResultEntity struct {
+	ID           string    `datastore:"id"`
+	Scenario     string    `datastore:"scenario"`
+	Release      string    `datastore:"release"`
+	StartedAt    time.Time `datastore:"started_at"`
+	Duration     float64   `datastore:"duration_seconds,noindex"`
+	Concurrency  int       `datastore:"concurrency,noindex"`
+	Devices      int       `datastore:"devices,noindex"`
+	Subscribers  int       `datastore:"subscribers,noindex"`
+	Operations   int       `datastore:"operations,noindex"`
+	Errors       int       `datastore:"errors,noindex"`
+	Throughput   float64   `datastore:"throughput_per_second,noindex"`
+	LatencyMean  float64   `datastore:"latency_mean_ms,noindex"`
+	LatencyP50   float64   `datastore:"latency_p50_ms,noindex"`
+	LatencyP95   float64   `datastore:"latency_p95_ms,noindex"`
+	LatencyP99   float64   `datastore:"latency_p99_ms,noindex"`
+	LatencyMax   float64   `datastore:"latency_max_ms,noindex"`
+	ErrorSamples []string  `datastore:"error_samples,noindex"`
+}
+
+// ToEntity converts a Result to a ResultEntity
+func (r *Result) ToEntity() *ResultEntity {
+	return &ResultEntity{
+		ID:           r.ID,
+		Scenario:     r.Scenario,
+		Release:      r.Release,
+		StartedAt:    r.StartedAt,
+		Duration:     r.Duration,
+		Concurrency:  r.Concurrency,
+		Devices:      r.Devices,
+		Subscribers:  r.Subscribers,
+		Operations:   r.Operations,
+		Errors:       r.Errors,
+		Throughput:   r.Throughput,
+		LatencyMean:  r.Latency.Mean,
+		LatencyP50:   r.Latency.P50,
+		LatencyP95:   r.Latency.P95,
+		LatencyP99:   r.Latency.P99,
+		LatencyMax:   r.Latency.Max,
+		ErrorSamples: r.ErrorSamples,
+	}
+}
+
+// FromEntity converts a ResultEntity to a Result
+func (re *ResultEntity) FromEntity() *Result {
+	return &Result{
+		ID:          re.ID,
+		Scenario:    re.Scenario,
+		Release:     re.Release,
+		StartedAt:   re.StartedAt,
+		Duration:    re.Duration,
+		Concurrency: re.Concurrency,
+		Devices:     re.Devices,
+		Subscribers: re.Subscribers,
+		Operations:  re.Operations,
+		Errors:      re.Errors,
+		Throughput:  re.Throughput,
+		Latency: Latency{
+			Mean: re.LatencyMean,
+			P50:  re.LatencyP50,
+			P95:  re.LatencyP95,
+			P99:  re.LatencyP99,
+			Max:  re.LatencyMax,
+		},
+		ErrorSamples: re.ErrorSamples,
+	}
+}
+
+// DatastoreResultStore keeps load test results in Datastore, so release
+// comparisons survive gateway restarts and agree across replicas
+type DatastoreResultStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreResultStore creates a result store on a Datastore client
+func NewDatastoreResultStore(client *datastore.Client) *DatastoreResultStore {
+	return &DatastoreResultStore{client: client}
+}
+
+// SaveResult stores a result under its ID
+func (s *DatastoreResultStore) SaveResult(ctx context.Context, result *Result) error {
+	key := datastore.NameKey("LoadTestResult", result.ID, nil)
+	if _, err := s.client.Put(ctx, key, result.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save load test result in Datastore: %w", err)
+	}
+	return nil
+}
+
+// ListResults returns matching results, newest first
+func (s *DatastoreResultStore) ListResults(ctx context.Context, filter ResultFilter) ([]*Result, error) {
+	query := datastore.NewQuery("LoadTestResult")
+	if filter.Scenario != "" {
+		query = query.Filter("scenario =", filter.Scenario)
+	}
+	if filter.Release != "" {
+		query = query.Filter("release =", filter.Release)
+	}
+	query = query.Order("-started_at")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var entities []*ResultEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list load test results from Datastore: %w", err)
+	}
+	results := make([]*Result, len(entities))
+	for i, entity := range entities {
+		results[i] = entity.FromEntity()
+	}
+	return results, nil
+}
@@ -0,0 +1,248 @@
+// Package loadtest generates load against ATHENA services and summarises
+// throughput and latency, so runs of the same scenario can be compared
+// across releases.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency = 10
+	defaultDevices     = 100
+	defaultDuration    = 30 * time.Second
+	maxErrorSamples    = 5
+)
+
+// Options configure a load test run
+type Options struct {
+	// Services maps service names to base URLs, as in config.Config
+	Services map[string]string
+	// Concurrency is the number of workers issuing operations
+	Concurrency int
+	// Duration is how long workers keep issuing operations
+	Duration time.Duration
+	// Rate caps operations per second across all workers; 0 is unlimited
+	Rate float64
+	// Devices is the number of simulated devices operations are spread over
+	Devices int
+	// Subscribers is the number of WebSocket clients per device; only the
+	// fan-out scenario uses it
+	Subscribers int
+	// Release labels the result with the platform version under test
+	Release string
+}
+
+func (o *Options) applyDefaults() {
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultConcurrency
+	}
+	if o.Duration <= 0 {
+		o.Duration = defaultDuration
+	}
+	if o.Devices <= 0 {
+		o.Devices = defaultDevices
+	}
+}
+
+// service returns the base URL of a service the scenario needs
+func (o *Options) service(name string) (string, error) {
+	url := o.Services[name]
+	if url == "" {
+		return "", fmt.Errorf("no URL configured for %s", name)
+	}
+	return url, nil
+}
+
+// DeviceID names the simulated device with the given index
+func DeviceID(index int) string {
+	return fmt.Sprintf("loadtest-%04d", index)
+}
+
+// Scenario is a repeatable operation whose throughput and latency a load
+// test measures
+type Scenario interface {
+	Name() string
+	Description() string
+	// Setup prepares the scenario before workers start, e.g. by opening
+	// subscriptions, and may fill in defaults for the options it uses
+	Setup(ctx context.Context, opts *Options) error
+	// Step performs one operation for a worker and returns its latency
+	Step(ctx context.Context, worker, iteration int) (time.Duration, error)
+	// Teardown releases whatever Setup acquired
+	Teardown() error
+}
+
+// Latency summarises operation latencies in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// Result is the outcome of one load test run
+type Result struct {
+	ID           string    `json:"id,omitempty"`
+	Scenario     string    `json:"scenario" binding:"required"`
+	Release      string    `json:"release" binding:"required"`
+	StartedAt    time.Time `json:"started_at"`
+	Duration     float64   `json:"duration_seconds"`
+	Concurrency  int       `json:"concurrency"`
+	Devices      int       `json:"devices"`
+	Subscribers  int       `json:"subscribers,omitempty"`
+	Operations   int       `json:"operations"`
+	Errors       int       `json:"errors"`
+	Throughput   float64   `json:"throughput_per_second"`
+	Latency      Latency   `json:"latency"`
+	ErrorSamples []string  `json:"error_samples,omitempty"`
+}
+
+// ErrorRate is the fraction of operations that failed
+func (r *Result) ErrorRate() float64 {
+	if r.Operations == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Operations)
+}
+
+// recorder collects the outcome of every operation
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	samples   []string
+}
+
+func (r *recorder) record(latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors++
+		if len(r.samples) < maxErrorSamples {
+			r.samples = append(r.samples, err.Error())
+		}
+		return
+	}
+	r.latencies = append(r.latencies, latency)
+}
+
+// Run drives a scenario with opts.Concurrency workers for opts.Duration, or
+// until the context is cancelled, and summarises the operations
+func Run(ctx context.Context, scenario Scenario, opts Options) (*Result, error) {
+	opts.applyDefaults()
+
+	if err := scenario.Setup(ctx, &opts); err != nil {
+		scenario.Teardown()
+		return nil, fmt.Errorf("failed to set up %s: %w", scenario.Name(), err)
+	}
+	defer scenario.Teardown()
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	// A shared ticker paces workers when a rate is set
+	var pace <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	rec := &recorder{}
+	started := time.Now()
+	var wg sync.WaitGroup
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for iteration := 0; ; iteration++ {
+				if pace != nil {
+					select {
+					case <-pace:
+					case <-runCtx.Done():
+						return
+					}
+				}
+				if runCtx.Err() != nil {
+					return
+				}
+
+				latency, err := scenario.Step(runCtx, worker, iteration)
+				// Operations cut short by the end of the run are not failures
+				if err != nil && runCtx.Err() != nil && errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				rec.record(latency, err)
+			}
+		}(worker)
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	result := summarise(rec, elapsed)
+	result.Scenario = scenario.Name()
+	result.Release = opts.Release
+	result.StartedAt = started.UTC()
+	result.Concurrency = opts.Concurrency
+	result.Devices = opts.Devices
+	result.Subscribers = opts.Subscribers
+	return result, nil
+}
+
+func summarise(rec *recorder, elapsed time.Duration) *Result {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	result := &Result{
+		Duration:     round(elapsed.Seconds()),
+		Operations:   len(rec.latencies) + rec.errors,
+		Errors:       rec.errors,
+		ErrorSamples: rec.samples,
+	}
+	if elapsed > 0 {
+		result.Throughput = round(float64(len(rec.latencies)) / elapsed.Seconds())
+	}
+	if len(rec.latencies) == 0 {
+		return result
+	}
+
+	latencies := append([]time.Duration(nil), rec.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	result.Latency = Latency{
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+		P50:  milliseconds(percentile(latencies, 50)),
+		P95:  milliseconds(percentile(latencies, 95)),
+		P99:  milliseconds(percentile(latencies, 99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return round(float64(d) / float64(time.Millisecond))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,285 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelemetryService accepts points and broadcasts them to the device's
+// stream subscribers, like the telemetry service
+type fakeTelemetryService struct {
+	mu          sync.Mutex
+	subscribers map[string][]*websocket.Conn
+}
+
+func newFakeTelemetryService(t *testing.T) *httptest.Server {
+	fake := &fakeTelemetryService{subscribers: make(map[string][]*websocket.Conn)}
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/telemetry/stream/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		deviceID := strings.TrimPrefix(r.URL.Path, "/api/v1/telemetry/stream/")
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		// Historical data arrives first and carries no load test tag
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"device_id":"`+deviceID+`","tags":{}}`))
+		fake.subscribers[deviceID] = append(fake.subscribers[deviceID], conn)
+	})
+	mux.HandleFunc("/api/v1/telemetry/ingest/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deviceID := strings.TrimPrefix(r.URL.Path, "/api/v1/telemetry/ingest/")
+		fake.mu.Lock()
+		for _, conn := range fake.subscribers[deviceID] {
+			conn.WriteMessage(websocket.TextMessage, body)
+		}
+		fake.mu.Unlock()
+		w.Write([]byte(`{"message":"Telemetry data ingested successfully"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		fake.mu.Lock()
+		for _, conns := range fake.subscribers {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}
+		fake.mu.Unlock()
+		server.Close()
+	})
+	return server
+}
+
+func TestRun_TelemetryIngest(t *testing.T) {
+	server := newFakeTelemetryService(t)
+	scenario, err := NewScenario(ScenarioTelemetryIngest)
+	require.NoError(t, err)
+
+	result, err := Run(context.Background(), scenario, Options{
+		Services:    map[string]string{"telemetry-service": server.URL},
+		Concurrency: 4,
+		Duration:    200 * time.Millisecond,
+		Devices:     8,
+		Release:     "1.4.0",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ScenarioTelemetryIngest, result.Scenario)
+	assert.Equal(t, "1.4.0", result.Release)
+	assert.Greater(t, result.Operations, 0)
+	assert.Zero(t, result.Errors, result.ErrorSamples)
+	assert.Greater(t, result.Throughput, 0.0)
+	assert.LessOrEqual(t, result.Latency.P50, result.Latency.P95)
+	assert.LessOrEqual(t, result.Latency.P95, result.Latency.P99)
+	assert.LessOrEqual(t, result.Latency.P99, result.Latency.Max)
+}
+
+func TestRun_WebSocketFanout(t *testing.T) {
+	server := newFakeTelemetryService(t)
+	scenario, err := NewScenario(ScenarioWebSocketFanout)
+	require.NoError(t, err)
+
+	result, err := Run(context.Background(), scenario, Options{
+		Services:    map[string]string{"telemetry-service": server.URL},
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+		Devices:     2,
+		Subscribers: 3,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Subscribers)
+	assert.Greater(t, result.Operations, 0)
+	assert.Zero(t, result.Errors, result.ErrorSamples)
+	assert.Greater(t, result.Latency.Max, 0.0)
+}
+
+func TestRun_OTAUpdateCheck(t *testing.T) {
+	var checks int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every other device has no pending update
+		if atomic.AddInt64(&checks, 1)%2 == 0 {
+			http.Error(w, `{"error":"no updates"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"release_id":"rel-1"}`))
+	}))
+	defer server.Close()
+
+	scenario, err := NewScenario(ScenarioOTAUpdateCheck)
+	require.NoError(t, err)
+	result, err := Run(context.Background(), scenario, Options{
+		Services: map[string]string{"ota-service": server.URL},
+		Duration: 100 * time.Millisecond,
+		Rate:     200,
+	})
+	require.NoError(t, err)
+
+	assert.Zero(t, result.Errors, result.ErrorSamples)
+	assert.Greater(t, result.Operations, 0)
+	// The rate caps operations at roughly 200/s over 0.1s
+	assert.LessOrEqual(t, result.Operations, 30)
+}
+
+func TestRun_ReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	scenario, err := NewScenario(ScenarioOTAUpdateCheck)
+	require.NoError(t, err)
+	result, err := Run(context.Background(), scenario, Options{
+		Services:    map[string]string{"ota-service": server.URL},
+		Concurrency: 2,
+		Duration:    50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, result.Operations, result.Errors)
+	assert.Equal(t, 1.0, result.ErrorRate())
+	assert.Zero(t, result.Throughput)
+	require.NotEmpty(t, result.ErrorSamples)
+	assert.LessOrEqual(t, len(result.ErrorSamples), maxErrorSamples)
+	assert.Contains(t, result.ErrorSamples[0], "503")
+}
+
+func TestRun_MissingService(t *testing.T) {
+	scenario, err := NewScenario(ScenarioTelemetryIngest)
+	require.NoError(t, err)
+	_, err = Run(context.Background(), scenario, Options{Duration: time.Millisecond})
+	assert.ErrorContains(t, err, "no URL configured for telemetry-service")
+
+	_, err = NewScenario("disk-io")
+	assert.Error(t, err)
+}
+
+func TestSummarise_Percentiles(t *testing.T) {
+	rec := &recorder{}
+	for i := 100; i >= 1; i-- {
+		rec.record(time.Duration(i)*time.Millisecond, nil)
+	}
+	result := summarise(rec, 2*time.Second)
+
+	assert.Equal(t, 100, result.Operations)
+	assert.Equal(t, 50.0, result.Throughput)
+	assert.Equal(t, Latency{Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}, result.Latency)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &Result{Scenario: ScenarioTelemetryIngest, Release: "1.3.0", Operations: 1000, Throughput: 500, Latency: Latency{P95: 20, P99: 40}}
+
+	improved := &Result{Scenario: ScenarioTelemetryIngest, Release: "1.4.0", Operations: 1100, Throughput: 550, Latency: Latency{P95: 21, P99: 30}}
+	comparison := Compare(baseline, improved, 10)
+	assert.Equal(t, 10.0, comparison.ThroughputChange)
+	assert.Equal(t, 5.0, comparison.P95Change)
+	assert.Equal(t, -25.0, comparison.P99Change)
+	assert.Empty(t, comparison.Regressions)
+
+	regressed := &Result{Scenario: ScenarioTelemetryIngest, Release: "1.4.0", Operations: 800, Errors: 8, Throughput: 400, Latency: Latency{P95: 30, P99: 40}}
+	comparison = Compare(baseline, regressed, 10)
+	assert.Equal(t, []string{"throughput", "p95 latency", "error rate"}, comparison.Regressions)
+}
+
+func TestResultsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewResultsHandler(NewMemoryResultStore(), logger.New("info", "api-gateway"))
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api/v1"))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/benchmarks", `{"scenario":"ota-update-check","release":"1.3.0","started_at":"2024-05-01T12:00:00Z","operations":1000,"throughput_per_second":900,"latency":{"p95_ms":8,"p99_ms":12}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var recorded Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &recorded))
+	assert.True(t, strings.HasPrefix(recorded.ID, "bench_"))
+
+	w = do(http.MethodPost, "/api/v1/benchmarks", `{"scenario":"ota-update-check","release":"1.4.0","started_at":"2024-06-01T12:00:00Z","operations":1000,"throughput_per_second":600,"latency":{"p95_ms":8,"p99_ms":12}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/benchmarks", `{"scenario":"disk-io","release":"1.4.0"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/benchmarks", `{"scenario":"ota-update-check"}`).Code)
+
+	w = do(http.MethodGet, "/api/v1/benchmarks?scenario=ota-update-check", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Results []*Result `json:"results"`
+		Count   int       `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Equal(t, 2, list.Count)
+	assert.Equal(t, "1.4.0", list.Results[0].Release)
+
+	w = do(http.MethodGet, "/api/v1/benchmarks/compare?scenario=ota-update-check&baseline=1.3.0&candidate=1.4.0", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var comparison Comparison
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comparison))
+	assert.Equal(t, []string{"throughput"}, comparison.Regressions)
+
+	w = do(http.MethodGet, "/api/v1/benchmarks/compare?scenario=ota-update-check&baseline=1.3.0&candidate=1.4.0&tolerance=50", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var tolerated Comparison
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tolerated))
+	assert.Empty(t, tolerated.Regressions)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/benchmarks/compare?scenario=ota-update-check&baseline=1.2.0&candidate=1.4.0", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/benchmarks/compare?scenario=ota-update-check", "").Code)
+}
+
+func TestResultEntity_RoundTrip(t *testing.T) {
+	result := &Result{
+		ID:           "result-1",
+		Scenario:     "telemetry-ingest",
+		Release:      "v1.2.0",
+		StartedAt:    time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Duration:     60,
+		Concurrency:  10,
+		Devices:      100,
+		Operations:   6000,
+		Errors:       3,
+		Throughput:   100,
+		Latency:      Latency{Mean: 12, P50: 10, P95: 30, P99: 45, Max: 80},
+		ErrorSamples: []string{"timeout"},
+	}
+	assert.Equal(t, result, result.ToEntity().FromEntity())
+}
+
+func TestMemoryResultStore_Limit(t *testing.T) {
+	store := NewMemoryResultStore()
+	ctx := context.Background()
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, store.SaveResult(ctx, &Result{ID: "old", Scenario: "telemetry-ingest", Release: "v1", StartedAt: started}))
+	require.NoError(t, store.SaveResult(ctx, &Result{ID: "new", Scenario: "telemetry-ingest", Release: "v1", StartedAt: started.Add(2 * time.Hour)}))
+	require.NoError(t, store.SaveResult(ctx, &Result{ID: "middle", Scenario: "telemetry-ingest", Release: "v1", StartedAt: started.Add(time.Hour)}))
+
+	results, err := store.ListResults(ctx, ResultFilter{Scenario: "telemetry-ingest", Release: "v1", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "new", results[0].ID)
+	assert.Equal(t, "middle", results[1].ID)
+}
@@ -0,0 +1,268 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTolerance is the percentage a metric may worsen between releases
+// before the comparison reports a regression
+const defaultTolerance = 10.0
+
+var ErrResultNotFound = errors.New("no load test result found")
+
+// ResultFilter selects stored results. Empty fields match everything; a
+// zero Limit returns every match.
+type ResultFilter struct {
+	Scenario string
+	Release  string
+	Limit    int
+}
+
+// ResultStore persists load test results
+type ResultStore interface {
+	SaveResult(ctx context.Context, result *Result) error
+	ListResults(ctx context.Context, filter ResultFilter) ([]*Result, error)
+}
+
+// MemoryResultStore keeps results in memory
+type MemoryResultStore struct {
+	mu      sync.RWMutex
+	results []*Result
+}
+
+// NewMemoryResultStore creates an empty in-memory store
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{}
+}
+
+func (m *MemoryResultStore) SaveResult(ctx context.Context, result *Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *result
+	m.results = append(m.results, &stored)
+	return nil
+}
+
+// ListResults returns matching results, newest first
+func (m *MemoryResultStore) ListResults(ctx context.Context, filter ResultFilter) ([]*Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []*Result
+	for _, result := range m.results {
+		if filter.Scenario != "" && result.Scenario != filter.Scenario {
+			continue
+		}
+		if filter.Release != "" && result.Release != filter.Release {
+			continue
+		}
+		copied := *result
+		results = append(results, &copied)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].StartedAt.After(results[j].StartedAt) })
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+	return results, nil
+}
+
+// Comparison reports how a scenario's latest result for a candidate release
+// differs from its latest result for a baseline release. Changes are
+// percentages of the baseline value.
+type Comparison struct {
+	Scenario         string   `json:"scenario"`
+	Baseline         *Result  `json:"baseline"`
+	Candidate        *Result  `json:"candidate"`
+	ThroughputChange float64  `json:"throughput_change_percent"`
+	P95Change        float64  `json:"p95_change_percent"`
+	P99Change        float64  `json:"p99_change_percent"`
+	ErrorRateChange  float64  `json:"error_rate_change"`
+	Tolerance        float64  `json:"tolerance_percent"`
+	Regressions      []string `json:"regressions,omitempty"`
+}
+
+// Compare compares a candidate result with a baseline. Lower throughput or
+// higher tail latency beyond tolerance percent, or a higher error rate,
+// counts as a regression.
+func Compare(baseline, candidate *Result, tolerance float64) *Comparison {
+	comparison := &Comparison{
+		Scenario:         candidate.Scenario,
+		Baseline:         baseline,
+		Candidate:        candidate,
+		ThroughputChange: change(baseline.Throughput, candidate.Throughput),
+		P95Change:        change(baseline.Latency.P95, candidate.Latency.P95),
+		P99Change:        change(baseline.Latency.P99, candidate.Latency.P99),
+		ErrorRateChange:  round(candidate.ErrorRate() - baseline.ErrorRate()),
+		Tolerance:        tolerance,
+	}
+
+	if comparison.ThroughputChange < -tolerance {
+		comparison.Regressions = append(comparison.Regressions, "throughput")
+	}
+	if comparison.P95Change > tolerance {
+		comparison.Regressions = append(comparison.Regressions, "p95 latency")
+	}
+	if comparison.P99Change > tolerance {
+		comparison.Regressions = append(comparison.Regressions, "p99 latency")
+	}
+	if comparison.ErrorRateChange > 0 {
+		comparison.Regressions = append(comparison.Regressions, "error rate")
+	}
+	return comparison
+}
+
+func change(baseline, candidate float64) float64 {
+	if baseline == 0 {
+		return 0
+	}
+	return round((candidate - baseline) / baseline * 100)
+}
+
+// ResultsHandler serves the load test results API, which CI pipelines post
+// results to and compare releases with
+type ResultsHandler struct {
+	store  ResultStore
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+// SetStore sets where results are persisted
+func (h *ResultsHandler) SetStore(store ResultStore) {
+	h.store = store
+}
+
+// NewResultsHandler creates a results handler
+func NewResultsHandler(store ResultStore, log *logger.Logger) *ResultsHandler {
+	return &ResultsHandler{
+		store:  store,
+		logger: log,
+		now:    time.Now,
+	}
+}
+
+// RegisterRoutes registers the results API. The caller is responsible for
+// authentication.
+func (h *ResultsHandler) RegisterRoutes(router *gin.RouterGroup) {
+	benchmarks := router.Group("/benchmarks")
+	{
+		benchmarks.POST("", h.RecordResult)
+		benchmarks.GET("", h.ListResults)
+		benchmarks.GET("/compare", h.CompareReleases)
+	}
+}
+
+// RecordResult stores the result of a load test run
+func (h *ResultsHandler) RecordResult(c *gin.Context) {
+	var result Result
+	if err := c.ShouldBindJSON(&result); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	if _, ok := scenarios[result.Scenario]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scenario: " + result.Scenario})
+		return
+	}
+
+	result.ID = newResultID()
+	if result.StartedAt.IsZero() {
+		result.StartedAt = h.now().UTC()
+	}
+	if err := h.store.SaveResult(c.Request.Context(), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store result"})
+		return
+	}
+
+	h.logger.Info("Load test result recorded", "result_id", result.ID, "username", c.GetString("username"),
+		"scenario", result.Scenario, "release", result.Release, "throughput", result.Throughput, "p95_ms", result.Latency.P95)
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListResults lists results, newest first, optionally filtered by
+// ?scenario= and ?release=
+func (h *ResultsHandler) ListResults(c *gin.Context) {
+	results, err := h.store.ListResults(c.Request.Context(), ResultFilter{
+		Scenario: c.Query("scenario"),
+		Release:  c.Query("release"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list results"})
+		return
+	}
+	if results == nil {
+		results = []*Result{}
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "count": len(results)})
+}
+
+// CompareReleases compares the latest results of ?scenario= for ?baseline=
+// and ?candidate= releases. ?tolerance= sets the allowed change in percent.
+func (h *ResultsHandler) CompareReleases(c *gin.Context) {
+	scenario := c.Query("scenario")
+	baselineRelease := c.Query("baseline")
+	candidateRelease := c.Query("candidate")
+	if scenario == "" || baselineRelease == "" || candidateRelease == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scenario, baseline and candidate are required"})
+		return
+	}
+
+	tolerance := defaultTolerance
+	if value := c.Query("tolerance"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tolerance must be a non-negative number"})
+			return
+		}
+		tolerance = parsed
+	}
+
+	baseline, err := h.latest(c.Request.Context(), scenario, baselineRelease)
+	if err != nil {
+		h.respondLookupError(c, err, baselineRelease)
+		return
+	}
+	candidate, err := h.latest(c.Request.Context(), scenario, candidateRelease)
+	if err != nil {
+		h.respondLookupError(c, err, candidateRelease)
+		return
+	}
+
+	c.JSON(http.StatusOK, Compare(baseline, candidate, tolerance))
+}
+
+func (h *ResultsHandler) latest(ctx context.Context, scenario, release string) (*Result, error) {
+	results, err := h.store.ListResults(ctx, ResultFilter{Scenario: scenario, Release: release, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrResultNotFound
+	}
+	return results[0], nil
+}
+
+func (h *ResultsHandler) respondLookupError(c *gin.Context, err error, release string) {
+	if errors.Is(err, ErrResultNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No result for release " + release})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list results"})
+}
+
+func newResultID() string {
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	return "bench_" + hex.EncodeToString(raw)
+}
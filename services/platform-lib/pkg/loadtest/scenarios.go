@@ -0,0 +1,356 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Scenario names
+const (
+	ScenarioTelemetryIngest = "telemetry-ingest"
+	ScenarioWebSocketFanout = "websocket-fanout"
+	ScenarioOTAUpdateCheck  = "ota-update-check"
+)
+
+const (
+	defaultSubscribers = 10
+	deliveryTimeout    = 5 * time.Second
+	// loadTestTag marks telemetry written by a load test so it can be told
+	// apart from real device data
+	loadTestTag = "loadtest"
+)
+
+var scenarios = map[string]func() Scenario{
+	ScenarioTelemetryIngest: func() Scenario { return &ingestScenario{} },
+	ScenarioWebSocketFanout: func() Scenario { return &fanoutScenario{} },
+	ScenarioOTAUpdateCheck:  func() Scenario { return &updateCheckScenario{} },
+}
+
+// NewScenario returns the named scenario
+func NewScenario(name string) (Scenario, error) {
+	newScenario, ok := scenarios[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q", name)
+	}
+	return newScenario(), nil
+}
+
+// Scenarios returns every scenario, ordered by name
+func Scenarios() []Scenario {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]Scenario, len(names))
+	for i, name := range names {
+		list[i] = scenarios[name]()
+	}
+	return list
+}
+
+func newHTTPClient(opts *Options) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.Concurrency
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}
+}
+
+// telemetryPoint builds the body a device posts to the ingest endpoint
+func telemetryPoint(deviceID string, iteration int, tags map[string]string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"device_id": deviceID,
+		"timestamp": time.Now().UTC(),
+		"metrics": map[string]interface{}{
+			"temperature": 20 + float64(iteration%100)/10,
+			"humidity":    40 + float64(iteration%50)/5,
+		},
+		"tags": tags,
+	})
+	return body
+}
+
+// post sends a JSON body and fails on any non-2xx status
+func post(ctx context.Context, client *http.Client, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %d %s", req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// ingestScenario measures how fast the telemetry service accepts points
+// over HTTP
+type ingestScenario struct {
+	client  *http.Client
+	baseURL string
+	devices int
+}
+
+func (s *ingestScenario) Name() string { return ScenarioTelemetryIngest }
+
+func (s *ingestScenario) Description() string {
+	return "Devices post single telemetry points to the telemetry service"
+}
+
+func (s *ingestScenario) Setup(ctx context.Context, opts *Options) error {
+	baseURL, err := opts.service("telemetry-service")
+	if err != nil {
+		return err
+	}
+	s.client = newHTTPClient(opts)
+	s.baseURL = baseURL
+	s.devices = opts.Devices
+	return nil
+}
+
+func (s *ingestScenario) Step(ctx context.Context, worker, iteration int) (time.Duration, error) {
+	deviceID := DeviceID((worker + iteration*7919) % s.devices)
+	body := telemetryPoint(deviceID, iteration, map[string]string{"source": loadTestTag})
+
+	started := time.Now()
+	err := post(ctx, s.client, s.baseURL+"/api/v1/telemetry/ingest/"+deviceID, body)
+	return time.Since(started), err
+}
+
+func (s *ingestScenario) Teardown() error {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// updateCheckScenario measures how many update checks the OTA service
+// answers, as devices poll for firmware
+type updateCheckScenario struct {
+	client  *http.Client
+	baseURL string
+	devices int
+}
+
+func (s *updateCheckScenario) Name() string { return ScenarioOTAUpdateCheck }
+
+func (s *updateCheckScenario) Description() string {
+	return "Devices poll the OTA service for pending firmware updates"
+}
+
+func (s *updateCheckScenario) Setup(ctx context.Context, opts *Options) error {
+	baseURL, err := opts.service("ota-service")
+	if err != nil {
+		return err
+	}
+	s.client = newHTTPClient(opts)
+	s.baseURL = baseURL
+	s.devices = opts.Devices
+	return nil
+}
+
+func (s *updateCheckScenario) Step(ctx context.Context, worker, iteration int) (time.Duration, error) {
+	deviceID := DeviceID((worker + iteration*7919) % s.devices)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/api/v1/ota/updates/"+deviceID, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	started := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	latency := time.Since(started)
+
+	// Not found means no update is pending, which is the common answer
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return 0, fmt.Errorf("GET %s: %d %s", req.URL.Path, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return latency, nil
+}
+
+func (s *updateCheckScenario) Teardown() error {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// fanoutScenario measures how long a telemetry point takes to reach every
+// WebSocket client streaming the device. Each step posts one point and waits
+// until the slowest subscriber has it.
+type fanoutScenario struct {
+	client      *http.Client
+	baseURL     string
+	devices     int
+	subscribers int
+
+	conns []*websocket.Conn
+	// publishing serialises posts per device; the stream manager writes to
+	// a device's connections from the ingesting request
+	publishing []sync.Mutex
+	sequence   int64
+
+	mu      sync.Mutex
+	pending map[string]*delivery
+}
+
+// delivery tracks one point until every subscriber has received it
+type delivery struct {
+	remaining int
+	last      time.Time
+	done      chan struct{}
+}
+
+func (s *fanoutScenario) Name() string { return ScenarioWebSocketFanout }
+
+func (s *fanoutScenario) Description() string {
+	return "Telemetry points are broadcast to several WebSocket subscribers per device"
+}
+
+func (s *fanoutScenario) Setup(ctx context.Context, opts *Options) error {
+	baseURL, err := opts.service("telemetry-service")
+	if err != nil {
+		return err
+	}
+	if opts.Subscribers <= 0 {
+		opts.Subscribers = defaultSubscribers
+	}
+	s.client = newHTTPClient(opts)
+	s.baseURL = baseURL
+	s.devices = opts.Devices
+	s.subscribers = opts.Subscribers
+	s.publishing = make([]sync.Mutex, opts.Devices)
+	s.pending = make(map[string]*delivery)
+
+	streamURL, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid telemetry service URL: %w", err)
+	}
+	switch streamURL.Scheme {
+	case "https":
+		streamURL.Scheme = "wss"
+	default:
+		streamURL.Scheme = "ws"
+	}
+
+	for device := 0; device < s.devices; device++ {
+		target := streamURL.String() + "/api/v1/telemetry/stream/" + DeviceID(device)
+		for i := 0; i < s.subscribers; i++ {
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, target, nil)
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to %s: %w", DeviceID(device), err)
+			}
+			s.conns = append(s.conns, conn)
+			go s.receive(conn)
+		}
+	}
+	return nil
+}
+
+// receive records the arrival of load test points on one subscription
+func (s *fanoutScenario) receive(conn *websocket.Conn) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		received := time.Now()
+
+		var point struct {
+			Tags map[string]string `json:"tags"`
+		}
+		if json.Unmarshal(message, &point) != nil {
+			continue
+		}
+		id := point.Tags[loadTestTag]
+		if id == "" {
+			// Historical data sent on connect
+			continue
+		}
+
+		s.mu.Lock()
+		if d, ok := s.pending[id]; ok {
+			d.remaining--
+			d.last = received
+			if d.remaining == 0 {
+				close(d.done)
+				delete(s.pending, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *fanoutScenario) Step(ctx context.Context, worker, iteration int) (time.Duration, error) {
+	device := (worker + iteration*7919) % s.devices
+	deviceID := DeviceID(device)
+	id := strconv.FormatInt(atomic.AddInt64(&s.sequence, 1), 10)
+	d := &delivery{remaining: s.subscribers, done: make(chan struct{})}
+
+	s.mu.Lock()
+	s.pending[id] = d
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	body := telemetryPoint(deviceID, iteration, map[string]string{"source": loadTestTag, loadTestTag: id})
+	s.publishing[device].Lock()
+	started := time.Now()
+	err := post(ctx, s.client, s.baseURL+"/api/v1/telemetry/ingest/"+deviceID, body)
+	s.publishing[device].Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := time.NewTimer(deliveryTimeout)
+	defer timeout.Stop()
+	select {
+	case <-d.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return d.last.Sub(started), nil
+	case <-timeout.C:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return 0, fmt.Errorf("%d of %d subscribers of %s did not receive the point within %s", d.remaining, s.subscribers, deviceID, deliveryTimeout)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (s *fanoutScenario) Teardown() error {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+	return nil
+}
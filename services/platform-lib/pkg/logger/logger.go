@@ -2,19 +2,79 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/athena/platform-lib/pkg/config"
 	"github.com/sirupsen/logrus"
 )
 
-// Logger wraps logrus.Logger with additional functionality
+const (
+	// FormatText writes human-readable lines
+	FormatText = "text"
+	// FormatJSON writes one JSON object per line, for log collectors
+	FormatJSON = "json"
+
+	// badKey is the field given to a trailing value without a key
+	badKey = "!BADKEY"
+
+	timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Logger wraps logrus.Logger with additional functionality. Log calls take
+// a message followed by alternating keys and values, which become fields:
+//
+//	log.Info("Created deployment", "deployment_id", id, "devices", len(targets))
+//
+// Loggers returned by Component share the service's output, sampling and
+// level, and can be given a level of their own at runtime.
 type Logger struct {
 	*logrus.Logger
 	serviceName string
+	component   string
+	shared      *shared
 }
 
-// New creates a new logger instance
+// Levels describes the log levels of a service: the service level and the
+// levels given to components, with the components logged so far
+type Levels struct {
+	Level      string            `json:"level"`
+	Overrides  map[string]string `json:"overrides"`
+	Components []string          `json:"components"`
+}
+
+// shared is the state common to a service logger and its components
+type shared struct {
+	mu         sync.RWMutex
+	root       *logrus.Logger
+	level      logrus.Level
+	overrides  map[string]logrus.Level
+	components map[string]*logrus.Logger
+	sampler    *sampler
+}
+
+// New creates a new logger instance writing text to stdout, without
+// sampling
 func New(level, serviceName string) *Logger {
+	return newLogger(level, serviceName, FormatText, nil)
+}
+
+// FromConfig creates the logger of a service from log_level and the
+// logging section of its configuration
+func FromConfig(cfg *config.Config) *Logger {
+	l := newLogger(cfg.LogLevel, cfg.ServiceName, cfg.Logging.Format, newSampler(cfg.Logging.Sampling))
+	for component, level := range cfg.Logging.Levels {
+		if err := l.SetComponentLevel(component, level); err != nil {
+			l.Warn("Ignoring log level override", "component", component, "level", level, "error", err)
+		}
+	}
+	return l
+}
+
+func newLogger(level, serviceName, format string, sampler *sampler) *Logger {
 	logger := logrus.New()
 
 	// Set log level
@@ -25,14 +85,16 @@ func New(level, serviceName string) *Logger {
 	logger.SetLevel(logLevel)
 
 	// Set formatter
-	logger.SetFormatter(&logrus.JSONFormatter{
-		TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
-		FieldMap: logrus.FieldMap{
-			logrus.FieldKeyTime:  "timestamp",
-			logrus.FieldKeyLevel: "level",
-			logrus.FieldKeyMsg:   "message",
-		},
-	})
+	fieldMap := logrus.FieldMap{
+		logrus.FieldKeyTime:  "timestamp",
+		logrus.FieldKeyLevel: "level",
+		logrus.FieldKeyMsg:   "message",
+	}
+	if strings.EqualFold(format, FormatJSON) {
+		logger.SetFormatter(&logrus.JSONFormatter{TimestampFormat: timestampFormat, FieldMap: fieldMap})
+	} else {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true, TimestampFormat: timestampFormat, FieldMap: fieldMap})
+	}
 
 	// Set output
 	logger.SetOutput(os.Stdout)
@@ -40,12 +102,141 @@ func New(level, serviceName string) *Logger {
 	return &Logger{
 		Logger:      logger,
 		serviceName: serviceName,
+		shared: &shared{
+			root:       logger,
+			level:      logLevel,
+			overrides:  make(map[string]logrus.Level),
+			components: make(map[string]*logrus.Logger),
+			sampler:    sampler,
+		},
+	}
+}
+
+// Component returns a logger for a part of the service, such as
+// "scheduler". Its entries carry a component field; components of a
+// component are named parent.child.
+func (l *Logger) Component(name string) *Logger {
+	if l.component != "" {
+		name = l.component + "." + name
+	}
+	return &Logger{
+		Logger:      l.shared.logger(name),
+		serviceName: l.serviceName,
+		component:   name,
+		shared:      l.shared,
+	}
+}
+
+// SetComponentLevel changes a log level at runtime. An empty component sets
+// the service level, which components without a level of their own
+// follow; an empty level removes a component's own level.
+func (l *Logger) SetComponentLevel(component, level string) error {
+	var parsed logrus.Level
+	if level != "" || component == "" {
+		var err error
+		if parsed, err = logrus.ParseLevel(level); err != nil {
+			return err
+		}
+	}
+
+	s := l.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case component == "":
+		s.level = parsed
+	case level == "":
+		delete(s.overrides, component)
+	default:
+		s.overrides[component] = parsed
+	}
+
+	s.root.SetLevel(s.level)
+	for name, logger := range s.components {
+		logger.SetLevel(s.levelOf(name))
+	}
+	return nil
+}
+
+// Levels returns the current log levels
+func (l *Logger) Levels() Levels {
+	s := l.shared
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	levels := Levels{Level: s.level.String(), Overrides: make(map[string]string), Components: []string{}}
+	for name, level := range s.overrides {
+		levels.Overrides[name] = level.String()
+	}
+	for name := range s.components {
+		levels.Components = append(levels.Components, name)
 	}
+	sort.Strings(levels.Components)
+	return levels
+}
+
+// logger returns the logrus logger of a component, creating it on first use
+func (s *shared) logger(name string) *logrus.Logger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if logger, ok := s.components[name]; ok {
+		return logger
+	}
+	logger := logrus.New()
+	logger.SetOutput(s.root.Out)
+	logger.SetFormatter(s.root.Formatter)
+	logger.ReplaceHooks(s.root.Hooks)
+	logger.ExitFunc = s.root.ExitFunc
+	logger.SetLevel(s.levelOf(name))
+	s.components[name] = logger
+	return logger
+}
+
+// levelOf returns the level of a component: its own, the nearest parent's,
+// or the service level. The caller holds the lock.
+func (s *shared) levelOf(name string) logrus.Level {
+	for {
+		if level, ok := s.overrides[name]; ok {
+			return level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return s.level
+		}
+		name = name[:i]
+	}
+}
+
+// base returns an entry with the service and component fields
+func (l *Logger) base() *logrus.Entry {
+	entry := l.Logger.WithField("service", l.serviceName)
+	if l.component != "" {
+		entry = entry.WithField("component", l.component)
+	}
+	return entry
+}
+
+// entry returns an entry with the fields given as alternating keys and
+// values. A trailing value without a key is logged under !BADKEY.
+func (l *Logger) entry(keysAndValues []interface{}) *logrus.Entry {
+	fields := make(logrus.Fields, len(keysAndValues)/2+2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields[badKey] = keysAndValues[i]
+			break
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		fields[key] = keysAndValues[i+1]
+	}
+	return l.base().WithFields(fields)
 }
 
 // WithContext adds context information to log entries
 func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
-	entry := l.Logger.WithField("service", l.serviceName)
+	entry := l.base()
 
 	// Add request ID if available
 	if requestID := ctx.Value("request_id"); requestID != nil {
@@ -67,66 +258,79 @@ func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
 
 // WithField adds a single field to the log entry
 func (l *Logger) WithField(key string, value interface{}) *logrus.Entry {
-	return l.Logger.WithField("service", l.serviceName).WithField(key, value)
+	return l.base().WithField(key, value)
 }
 
 // WithFields adds multiple fields to the log entry
 func (l *Logger) WithFields(fields logrus.Fields) *logrus.Entry {
-	fields["service"] = l.serviceName
-	return l.Logger.WithFields(fields)
+	return l.base().WithFields(fields)
 }
 
 // WithError adds an error field to the log entry
 func (l *Logger) WithError(err error) *logrus.Entry {
-	return l.Logger.WithField("service", l.serviceName).WithError(err)
+	return l.base().WithError(err)
 }
 
-// Info logs an info message with service context
-func (l *Logger) Info(args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Info(args...)
+// Debug logs a debug message with key-value fields. Repeated debug
+// messages are sampled when sampling is configured.
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	if !l.IsLevelEnabled(logrus.DebugLevel) || !l.shared.sampler.allow(l.component, msg) {
+		return
+	}
+	l.entry(keysAndValues).Debug(msg)
 }
 
-// Infof logs a formatted info message with service context
-func (l *Logger) Infof(format string, args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Infof(format, args...)
+// Info logs an info message with key-value fields
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Info(msg)
 }
 
-// Debug logs a debug message with service context
-func (l *Logger) Debug(args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Debug(args...)
+// Warn logs a warning message with key-value fields
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Warn(msg)
 }
 
-// Debugf logs a formatted debug message with service context
-func (l *Logger) Debugf(format string, args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Debugf(format, args...)
+// Error logs an error message with key-value fields
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Error(msg)
 }
 
-// Warn logs a warning message with service context
-func (l *Logger) Warn(args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Warn(args...)
+// Fatal logs a fatal message with key-value fields and exits
+func (l *Logger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.entry(keysAndValues).Fatal(msg)
 }
 
-// Warnf logs a formatted warning message with service context
-func (l *Logger) Warnf(format string, args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Warnf(format, args...)
+// Infof logs a formatted info message with service context
+//
+// Deprecated: use Info with key-value fields.
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.base().Infof(format, args...)
 }
 
-// Error logs an error message with service context
-func (l *Logger) Error(args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Error(args...)
+// Debugf logs a formatted debug message with service context
+//
+// Deprecated: use Debug with key-value fields.
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.base().Debugf(format, args...)
 }
 
-// Errorf logs a formatted error message with service context
-func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Errorf(format, args...)
+// Warnf logs a formatted warning message with service context
+//
+// Deprecated: use Warn with key-value fields.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.base().Warnf(format, args...)
 }
 
-// Fatal logs a fatal message with service context and exits
-func (l *Logger) Fatal(args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Fatal(args...)
+// Errorf logs a formatted error message with service context
+//
+// Deprecated: use Error with key-value fields.
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.base().Errorf(format, args...)
 }
 
 // Fatalf logs a formatted fatal message with service context and exits
+//
+// Deprecated: use Fatal with key-value fields.
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	l.Logger.WithField("service", l.serviceName).Fatalf(format, args...)
+	l.base().Fatalf(format, args...)
 }
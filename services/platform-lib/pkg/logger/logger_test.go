@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonLogger returns a logger writing JSON to a buffer
+func jsonLogger(t *testing.T, cfg *config.Config) (*Logger, *bytes.Buffer) {
+	t.Helper()
+	cfg.Logging.Format = FormatJSON
+	log := FromConfig(cfg)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	return log, &buf
+}
+
+// entries decodes the JSON lines written to buf
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		out = append(out, entry)
+	}
+	return out
+}
+
+func TestLogger_KeyValueFields(t *testing.T) {
+	log, buf := jsonLogger(t, &config.Config{LogLevel: "info", ServiceName: "ota-service"})
+
+	log.Info("Created deployment", "deployment_id", "deployment-001", "devices", 3)
+	log.Warn("Odd fields", "key")
+
+	logged := entries(t, buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "Created deployment", logged[0]["message"])
+	assert.Equal(t, "info", logged[0]["level"])
+	assert.Equal(t, "ota-service", logged[0]["service"])
+	assert.Equal(t, "deployment-001", logged[0]["deployment_id"])
+	assert.Equal(t, float64(3), logged[0]["devices"])
+	assert.Contains(t, logged[0], "timestamp")
+	assert.NotContains(t, logged[0], "component")
+	assert.Equal(t, "key", logged[1][badKey])
+}
+
+func TestLogger_ComponentLevels(t *testing.T) {
+	cfg := &config.Config{LogLevel: "info", ServiceName: "ota-service"}
+	cfg.Logging.Levels = map[string]string{"scheduler": "debug"}
+	log, buf := jsonLogger(t, cfg)
+
+	scheduler := log.Component("scheduler")
+	windows := scheduler.Component("windows")
+	promoter := log.Component("promoter")
+
+	scheduler.Debug("Checking windows")
+	windows.Debug("Window opened")
+	promoter.Debug("Checking tiers")
+
+	logged := entries(t, buf)
+	require.Len(t, logged, 2)
+	assert.Equal(t, "scheduler", logged[0]["component"])
+	assert.Equal(t, "scheduler.windows", logged[1]["component"])
+
+	// Levels change at runtime, for components created before and after
+	require.NoError(t, log.SetComponentLevel("promoter", "debug"))
+	require.NoError(t, log.SetComponentLevel("scheduler", ""))
+	require.NoError(t, log.SetComponentLevel("", "warning"))
+	assert.True(t, promoter.IsLevelEnabled(logrus.DebugLevel))
+	assert.False(t, windows.IsLevelEnabled(logrus.InfoLevel))
+	assert.False(t, log.Component("metering").IsLevelEnabled(logrus.InfoLevel))
+	assert.Error(t, log.SetComponentLevel("promoter", "loud"))
+
+	assert.Equal(t, Levels{
+		Level:      "warning",
+		Overrides:  map[string]string{"promoter": "debug"},
+		Components: []string{"metering", "promoter", "scheduler", "scheduler.windows"},
+	}, log.Levels())
+}
+
+func TestLogger_DebugSampling(t *testing.T) {
+	cfg := &config.Config{LogLevel: "debug", ServiceName: "telemetry-service"}
+	cfg.Logging.Sampling = config.LogSamplingConfig{Initial: 2, Thereafter: 3, Tick: time.Second}
+	log, buf := jsonLogger(t, cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	log.shared.sampler.now = func() time.Time { return now }
+
+	for i := 0; i < 8; i++ {
+		log.Debug("Received heartbeat", "n", i)
+		log.Info("Stored telemetry", "n", i)
+	}
+	now = now.Add(time.Second)
+	log.Debug("Received heartbeat", "n", 8)
+
+	var sampled []float64
+	stored := 0
+	for _, entry := range entries(t, buf) {
+		if entry["message"] == "Received heartbeat" {
+			sampled = append(sampled, entry["n"].(float64))
+		} else {
+			stored++
+		}
+	}
+	// The first two per second, then every third; other levels are not sampled
+	assert.Equal(t, []float64{0, 1, 4, 7, 8}, sampled)
+	assert.Equal(t, 8, stored)
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+)
+
+// sampler thins out repetitive debug logs. Within each tick, the first
+// initial entries with the same component and message are logged, then
+// every thereafter-th; with thereafter 0 the rest are dropped.
+type sampler struct {
+	initial    int
+	thereafter int
+	tick       time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+// newSampler returns a sampler for the configuration, or nil when sampling
+// is off
+func newSampler(cfg config.LogSamplingConfig) *sampler {
+	if cfg.Initial <= 0 {
+		return nil
+	}
+	tick := cfg.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return &sampler{
+		initial:    cfg.Initial,
+		thereafter: cfg.Thereafter,
+		tick:       tick,
+		now:        time.Now,
+		counts:     make(map[string]int),
+	}
+}
+
+// allow reports whether an entry is logged. A nil sampler allows all.
+func (s *sampler) allow(component, msg string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.window) >= s.tick {
+		s.window = now
+		s.counts = make(map[string]int)
+	}
+
+	key := component + "\x00" + msg
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
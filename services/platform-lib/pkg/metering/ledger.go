@@ -0,0 +1,572 @@
+package metering
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxReportSize bounds a usage report; a flush holds one record per
+	// project, meter and hour
+	maxReportSize = 1 << 20
+
+	// allProjects is the quota key applying to projects without their own
+	allProjects = "*"
+)
+
+// Granularity is the period usage is summed over
+type Granularity string
+
+const (
+	GranularityHour  Granularity = "hour"
+	GranularityDay   Granularity = "day"
+	GranularityMonth Granularity = "month"
+)
+
+// ErrInvalidUsage is returned for malformed usage records and queries
+var ErrInvalidUsage = errors.New("invalid usage")
+
+// UsageFilter selects stored usage. Empty fields match everything; To is
+// exclusive.
+type UsageFilter struct {
+	Project string
+	From    time.Time
+	To      time.Time
+}
+
+// UsageStore persists hourly usage
+type UsageStore interface {
+	AddUsage(ctx context.Context, records []*UsageRecord) error
+	ListUsage(ctx context.Context, filter UsageFilter) ([]*UsageRecord, error)
+}
+
+// MemoryUsageStore keeps hourly usage in memory, summing records for the
+// same project, meter and hour
+type MemoryUsageStore struct {
+	mu    sync.RWMutex
+	usage map[pendingKey]float64
+}
+
+// NewMemoryUsageStore creates an empty in-memory store
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{usage: make(map[pendingKey]float64)}
+}
+
+func (m *MemoryUsageStore) AddUsage(ctx context.Context, records []*UsageRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, record := range records {
+		key := pendingKey{project: record.Project, meter: record.Meter, hour: record.Hour.UTC().Truncate(time.Hour)}
+		m.usage[key] += record.Quantity
+	}
+	return nil
+}
+
+// ListUsage returns matching hourly usage ordered by hour, project and meter
+func (m *MemoryUsageStore) ListUsage(ctx context.Context, filter UsageFilter) ([]*UsageRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var records []*UsageRecord
+	for key, quantity := range m.usage {
+		if filter.Project != "" && key.project != filter.Project {
+			continue
+		}
+		if !filter.From.IsZero() && key.hour.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !key.hour.Before(filter.To) {
+			continue
+		}
+		records = append(records, &UsageRecord{Project: key.project, Meter: key.meter, Quantity: quantity, Hour: key.hour})
+	}
+	sortUsage(records)
+	return records, nil
+}
+
+// sortUsage orders usage by hour, project and meter
+func sortUsage(records []*UsageRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return meterOrder(a.Meter) < meterOrder(b.Meter)
+	})
+}
+
+// UsageLine is one project's use of one meter over a period
+type UsageLine struct {
+	Project     string    `json:"project"`
+	Meter       Meter     `json:"meter"`
+	Unit        string    `json:"unit"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Quantity    float64   `json:"quantity"`
+}
+
+// Summarize sums hourly usage into periods of the given granularity,
+// ordered by project, period and meter
+func Summarize(records []*UsageRecord, granularity Granularity) []*UsageLine {
+	lines := make(map[pendingKey]*UsageLine)
+	var ordered []*UsageLine
+	for _, record := range records {
+		start, end := period(record.Hour, granularity)
+		key := pendingKey{project: record.Project, meter: record.Meter, hour: start}
+		if line, ok := lines[key]; ok {
+			line.Quantity += record.Quantity
+			continue
+		}
+		line := &UsageLine{
+			Project:     record.Project,
+			Meter:       record.Meter,
+			Unit:        record.Meter.Unit(),
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Quantity:    record.Quantity,
+		}
+		lines[key] = line
+		ordered = append(ordered, line)
+	}
+
+	for _, line := range ordered {
+		line.Quantity = roundQuantity(line.Quantity)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if !a.PeriodStart.Equal(b.PeriodStart) {
+			return a.PeriodStart.Before(b.PeriodStart)
+		}
+		return meterOrder(a.Meter) < meterOrder(b.Meter)
+	})
+	return ordered
+}
+
+// period returns the UTC period of the given granularity containing t
+func period(t time.Time, granularity Granularity) (time.Time, time.Time) {
+	t = t.UTC()
+	switch granularity {
+	case GranularityHour:
+		start := t.Truncate(time.Hour)
+		return start, start.Add(time.Hour)
+	case GranularityDay:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+func meterOrder(m Meter) int {
+	for i, meter := range Meters {
+		if meter == m {
+			return i
+		}
+	}
+	return len(Meters)
+}
+
+// roundQuantity drops float noise from summed fractional quantities such
+// as device-months
+func roundQuantity(q float64) float64 {
+	return math.Round(q*1e6) / 1e6
+}
+
+// QuotaStatus is a project's use of a meter this month against its quota
+type QuotaStatus struct {
+	Project   string  `json:"project"`
+	Meter     Meter   `json:"meter"`
+	Unit      string  `json:"unit"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Remaining float64 `json:"remaining"`
+	Exceeded  bool    `json:"exceeded"`
+}
+
+// Ledger keeps the usage services report, serves the usage API and raises
+// a notification when a project goes over a monthly quota. Quotas are
+// reported, not enforced.
+type Ledger struct {
+	store     UsageStore
+	quotas    map[string]map[string]float64
+	secret    []byte
+	publisher func(*notifications.Event)
+	logger    *logger.Logger
+	now       func() time.Time
+
+	// mu serialises reports so quota crossings are detected once
+	mu sync.Mutex
+}
+
+// NewLedger creates a ledger with quotas from the metering configuration.
+// Reports must be signed with the JWT secret.
+func NewLedger(store UsageStore, cfg *config.Config, log *logger.Logger) *Ledger {
+	return &Ledger{
+		store:  store,
+		quotas: cfg.Metering.Quotas,
+		secret: []byte(cfg.JWTSecret),
+		logger: log,
+		now:    time.Now,
+	}
+}
+
+// SetStore sets where usage is persisted
+func (l *Ledger) SetStore(store UsageStore) {
+	l.store = store
+}
+
+// SetPublisher sets where quota notifications are published
+func (l *Ledger) SetPublisher(publish func(*notifications.Event)) {
+	l.publisher = publish
+}
+
+// Report stores usage records, so the ledger is also a Sink for usage
+// recorded in the gateway itself
+func (l *Ledger) Report(ctx context.Context, records []*UsageRecord) error {
+	for _, record := range records {
+		if record.Project == "" || !record.Meter.Valid() || record.Quantity < 0 || math.IsNaN(record.Quantity) || math.IsInf(record.Quantity, 0) {
+			return fmt.Errorf("%w: bad usage record for project %q meter %q", ErrInvalidUsage, record.Project, record.Meter)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	before := l.monthQuotas(ctx, records)
+	if err := l.store.AddUsage(ctx, records); err != nil {
+		return err
+	}
+	for _, status := range l.monthQuotas(ctx, records) {
+		if previous := before[quotaKey(status.Project, status.Meter)]; !status.Exceeded || (previous != nil && previous.Exceeded) {
+			continue
+		}
+		l.logger.Warn("Project is over its quota", "project", status.Project, "meter", status.Meter, "used", status.Used, "limit", status.Limit, "unit", status.Unit)
+		if l.publisher != nil {
+			l.publisher(&notifications.Event{
+				Type:         notifications.EventQuotaExceeded,
+				ResourceType: "project",
+				ResourceID:   status.Project,
+				Source:       "metering",
+				Message:      fmt.Sprintf("Project %s has used %g of its %g %s %s quota this month", status.Project, status.Used, status.Limit, status.Unit, status.Meter),
+				Data:         map[string]interface{}{"meter": status.Meter, "used": status.Used, "limit": status.Limit},
+			})
+		}
+	}
+	return nil
+}
+
+// monthQuotas returns this month's quota status for the projects and meters
+// in records that have a quota
+func (l *Ledger) monthQuotas(ctx context.Context, records []*UsageRecord) map[string]*QuotaStatus {
+	statuses := make(map[string]*QuotaStatus)
+	for _, record := range records {
+		key := quotaKey(record.Project, record.Meter)
+		if _, seen := statuses[key]; seen {
+			continue
+		}
+		if _, ok := l.quotaLimit(record.Project, record.Meter); !ok {
+			continue
+		}
+		status, err := l.QuotaStatus(ctx, record.Project)
+		if err != nil {
+			continue
+		}
+		for _, s := range status {
+			statuses[quotaKey(s.Project, s.Meter)] = s
+		}
+	}
+	return statuses
+}
+
+func quotaKey(project string, meter Meter) string {
+	return project + "/" + string(meter)
+}
+
+// quotaLimit returns a project's monthly limit for a meter
+func (l *Ledger) quotaLimit(project string, meter Meter) (float64, bool) {
+	if limits, ok := l.quotas[project]; ok {
+		if limit, ok := limits[string(meter)]; ok {
+			return limit, true
+		}
+	}
+	limit, ok := l.quotas[allProjects][string(meter)]
+	return limit, ok
+}
+
+// QuotaStatus returns the project's use this month of each meter it has a
+// quota for
+func (l *Ledger) QuotaStatus(ctx context.Context, project string) ([]*QuotaStatus, error) {
+	start, end := period(l.now(), GranularityMonth)
+	records, err := l.store.ListUsage(ctx, UsageFilter{Project: project, From: start, To: end})
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[Meter]float64)
+	for _, record := range records {
+		used[record.Meter] += record.Quantity
+	}
+
+	var statuses []*QuotaStatus
+	for _, meter := range Meters {
+		limit, ok := l.quotaLimit(project, meter)
+		if !ok {
+			continue
+		}
+		status := &QuotaStatus{
+			Project:  project,
+			Meter:    meter,
+			Unit:     meter.Unit(),
+			Limit:    limit,
+			Used:     roundQuantity(used[meter]),
+			Exceeded: used[meter] > limit,
+		}
+		status.Remaining = math.Max(0, roundQuantity(limit-used[meter]))
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Projects returns the projects with usage, plus those with their own
+// quota, in name order
+func (l *Ledger) Projects(ctx context.Context) ([]string, error) {
+	records, err := l.store.ListUsage(ctx, UsageFilter{})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, record := range records {
+		seen[record.Project] = true
+	}
+	for project := range l.quotas {
+		if project != allProjects {
+			seen[project] = true
+		}
+	}
+	projects := make([]string, 0, len(seen))
+	for project := range seen {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// RegisterRoutes registers the usage API. The caller is responsible for
+// authentication.
+func (l *Ledger) RegisterRoutes(router *gin.RouterGroup) {
+	usage := router.Group("/usage")
+	{
+		usage.GET("", l.GetUsage)
+		usage.GET("/export", l.ExportUsage)
+		usage.GET("/quotas", l.GetQuotas)
+	}
+}
+
+// HandleReport accepts signed usage from a platform service
+func (l *Ledger) HandleReport(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxReportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if len(l.secret) == 0 || !notifications.VerifySignature(l.secret, body, c.GetHeader(notifications.SignatureHeader)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid usage signature"})
+		return
+	}
+
+	var report usageReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid usage format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := l.Report(c.Request.Context(), report.Records); err != nil {
+		if errors.Is(err, ErrInvalidUsage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store usage"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"records": len(report.Records)})
+}
+
+// GetUsage returns usage summed per ?granularity= (hour, day or month,
+// default month) for ?project= between ?from= and ?to= (RFC 3339 or
+// YYYY-MM-DD; default this month)
+func (l *Ledger) GetUsage(c *gin.Context) {
+	filter, granularity, err := l.parseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lines, err := l.usage(c.Request.Context(), filter, granularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list usage"})
+		return
+	}
+
+	totals := make(map[string]map[Meter]float64)
+	for _, line := range lines {
+		if totals[line.Project] == nil {
+			totals[line.Project] = make(map[Meter]float64)
+		}
+		totals[line.Project][line.Meter] = roundQuantity(totals[line.Project][line.Meter] + line.Quantity)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":        filter.From,
+		"to":          filter.To,
+		"granularity": granularity,
+		"usage":       lines,
+		"totals":      totals,
+	})
+}
+
+// ExportUsage downloads usage as ?format=csv (default) or json, with the
+// same query parameters as GetUsage, for import into billing systems
+func (l *Ledger) ExportUsage(c *gin.Context) {
+	filter, granularity, err := l.parseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	lines, err := l.usage(c.Request.Context(), filter, granularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list usage"})
+		return
+	}
+
+	filename := fmt.Sprintf("usage-%s-%s.%s", filter.From.Format("20060102"), filter.To.Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		c.JSON(http.StatusOK, lines)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	if err := WriteCSV(c.Writer, lines); err != nil {
+		l.logger.Warn("Failed to write usage export", "error", err)
+	}
+}
+
+// GetQuotas returns this month's quota status for ?project=, or for every
+// project
+func (l *Ledger) GetQuotas(c *gin.Context) {
+	projects := []string{c.Query("project")}
+	if projects[0] == "" {
+		var err error
+		if projects, err = l.Projects(c.Request.Context()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list projects"})
+			return
+		}
+	}
+
+	quotas := []*QuotaStatus{}
+	for _, project := range projects {
+		statuses, err := l.QuotaStatus(c.Request.Context(), project)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list usage"})
+			return
+		}
+		quotas = append(quotas, statuses...)
+	}
+	c.JSON(http.StatusOK, gin.H{"quotas": quotas, "count": len(quotas)})
+}
+
+func (l *Ledger) usage(ctx context.Context, filter UsageFilter, granularity Granularity) ([]*UsageLine, error) {
+	records, err := l.store.ListUsage(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	lines := Summarize(records, granularity)
+	if lines == nil {
+		lines = []*UsageLine{}
+	}
+	return lines, nil
+}
+
+func (l *Ledger) parseQuery(c *gin.Context) (UsageFilter, Granularity, error) {
+	granularity := Granularity(c.DefaultQuery("granularity", string(GranularityMonth)))
+	switch granularity {
+	case GranularityHour, GranularityDay, GranularityMonth:
+	default:
+		return UsageFilter{}, "", fmt.Errorf("%w: granularity must be hour, day or month", ErrInvalidUsage)
+	}
+
+	from, to := period(l.now(), GranularityMonth)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = parseTime(value); err != nil {
+			return UsageFilter{}, "", fmt.Errorf("%w: invalid from: %v", ErrInvalidUsage, err)
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = parseTime(value); err != nil {
+			return UsageFilter{}, "", fmt.Errorf("%w: invalid to: %v", ErrInvalidUsage, err)
+		}
+	}
+	if !from.Before(to) {
+		return UsageFilter{}, "", fmt.Errorf("%w: from must be before to", ErrInvalidUsage)
+	}
+	return UsageFilter{Project: c.Query("project"), From: from, To: to}, granularity, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// WriteCSV writes usage lines with a header row
+func WriteCSV(w io.Writer, lines []*UsageLine) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"project", "meter", "unit", "period_start", "period_end", "quantity"}); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if err := writer.Write([]string{
+			line.Project,
+			string(line.Meter),
+			line.Unit,
+			line.PeriodStart.Format(time.RFC3339),
+			line.PeriodEnd.Format(time.RFC3339),
+			strconv.FormatFloat(line.Quantity, 'f', -1, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,146 @@
+// Package metering records billable usage per project so that installs
+// shared by several teams can charge each one back. Services buffer usage
+// in a Recorder and report it hourly-bucketed to the API gateway, which
+// keeps the ledger, serves the usage API and watches monthly quotas.
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/notifications"
+)
+
+// ReportPath is the gateway endpoint services report usage to
+const ReportPath = "/internal/metering/usage"
+
+// Meter identifies a kind of billable usage
+type Meter string
+
+const (
+	// MeterDeviceMonths is registered devices over time; one device
+	// registered for a whole calendar month is one device-month
+	MeterDeviceMonths Meter = "device_months"
+	// MeterTelemetryPoints is metric values stored
+	MeterTelemetryPoints Meter = "telemetry_points"
+	// MeterCompileMinutes is time spent compiling firmware
+	MeterCompileMinutes Meter = "compile_minutes"
+	// MeterOTABytes is firmware downloaded by devices
+	MeterOTABytes Meter = "ota_bytes"
+)
+
+// Meters lists every meter in report order
+var Meters = []Meter{MeterDeviceMonths, MeterTelemetryPoints, MeterCompileMinutes, MeterOTABytes}
+
+var meterUnits = map[Meter]string{
+	MeterDeviceMonths:    "device-month",
+	MeterTelemetryPoints: "point",
+	MeterCompileMinutes:  "minute",
+	MeterOTABytes:        "byte",
+}
+
+// Valid reports whether m is a known meter
+func (m Meter) Valid() bool {
+	_, ok := meterUnits[m]
+	return ok
+}
+
+// Unit returns the unit a meter's quantities are given in
+func (m Meter) Unit() string {
+	return meterUnits[m]
+}
+
+// UsageRecord is the quantity of one meter a project used during the hour
+// starting at Hour
+type UsageRecord struct {
+	Project  string    `json:"project"`
+	Meter    Meter     `json:"meter"`
+	Quantity float64   `json:"quantity"`
+	Hour     time.Time `json:"hour"`
+	Source   string    `json:"source,omitempty"`
+}
+
+// Sink receives usage reported by a Recorder
+type Sink interface {
+	Report(ctx context.Context, records []*UsageRecord) error
+}
+
+// DeviceLookup finds the device usage is attributed to. device.Repository
+// satisfies it.
+type DeviceLookup interface {
+	GetDevice(ctx context.Context, deviceID string) (*device.Device, error)
+}
+
+// DeviceLister lists registered devices for device-month sampling.
+// device.Repository satisfies it.
+type DeviceLister interface {
+	ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error)
+}
+
+// ProjectOf returns the project a device belongs to: the value of its
+// project label, or the default project
+func ProjectOf(cfg config.MeteringConfig, d *device.Device) string {
+	if d != nil && cfg.ProjectLabel != "" {
+		if project := d.Labels[cfg.ProjectLabel]; project != "" {
+			return project
+		}
+	}
+	return cfg.DefaultProject
+}
+
+// HTTPSink reports usage to the API gateway, signed like notification
+// events
+type HTTPSink struct {
+	endpoint string
+	secret   []byte
+	client   *http.Client
+}
+
+// NewHTTPSink creates a sink targeting the gateway from the service
+// configuration
+func NewHTTPSink(cfg *config.Config) *HTTPSink {
+	return &HTTPSink{
+		endpoint: cfg.Services["api-gateway"] + ReportPath,
+		secret:   []byte(cfg.JWTSecret),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Report sends usage records to the gateway
+func (s *HTTPSink) Report(ctx context.Context, records []*UsageRecord) error {
+	body, err := json.Marshal(usageReport{Records: records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(notifications.SignatureHeader, notifications.Sign(s.secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to report usage: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("usage ledger returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// usageReport is the body services post to ReportPath
+type usageReport struct {
+	Records []*UsageRecord `json:"records"`
+}
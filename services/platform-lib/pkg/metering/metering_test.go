@@ -0,0 +1,365 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deviceDirectory map[string]*device.Device
+
+func (d deviceDirectory) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	if found, ok := d[deviceID]; ok {
+		return found, nil
+	}
+	return nil, errors.New("device not found")
+}
+
+func (d deviceDirectory) ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error) {
+	var devices []*device.Device
+	for _, found := range d {
+		devices = append(devices, found)
+	}
+	return devices, nil
+}
+
+// failingSink fails until it is told to accept reports
+type failingSink struct {
+	fail    bool
+	records []*UsageRecord
+}
+
+func (s *failingSink) Report(ctx context.Context, records []*UsageRecord) error {
+	if s.fail {
+		return errors.New("gateway unavailable")
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		ServiceName: "telemetry-service",
+		JWTSecret:   "test-secret",
+		Metering: config.MeteringConfig{
+			Enabled:        true,
+			ProjectLabel:   "project",
+			DefaultProject: "default",
+			Quotas: map[string]map[string]float64{
+				"greenhouse": {"telemetry_points": 100},
+				"*":          {"compile_minutes": 60},
+			},
+		},
+	}
+}
+
+var testDevices = deviceDirectory{
+	"gh-1":   {DeviceID: "gh-1", Labels: map[string]string{"project": "greenhouse"}},
+	"gh-2":   {DeviceID: "gh-2", Labels: map[string]string{"project": "greenhouse"}},
+	"lab-1":  {DeviceID: "lab-1", Labels: map[string]string{"project": "lab"}},
+	"spare1": {DeviceID: "spare1"},
+}
+
+func sumUsage(records []*UsageRecord, project string, meter Meter) float64 {
+	var total float64
+	for _, record := range records {
+		if record.Project == project && record.Meter == meter {
+			total += record.Quantity
+		}
+	}
+	return total
+}
+
+func TestRecorder_AttributesUsageToProjects(t *testing.T) {
+	now := time.Date(2026, 4, 10, 14, 20, 0, 0, time.UTC)
+	sink := &failingSink{}
+	recorder := NewRecorder(testConfig(), logger.New("info", "test"), sink, testDevices)
+	recorder.now = func() time.Time { return now }
+
+	recorder.AddForDevice("gh-1", MeterTelemetryPoints, 3)
+	recorder.AddForDevice("gh-2", MeterTelemetryPoints, 2)
+	recorder.AddForDevice("lab-1", MeterOTABytes, 1024)
+	recorder.AddForDevice("spare1", MeterTelemetryPoints, 1)
+	recorder.AddForDevice("unknown", MeterTelemetryPoints, 1)
+	recorder.Add("lab", MeterCompileMinutes, 1.5)
+	recorder.Add("", MeterCompileMinutes, 0.5)
+	recorder.Add("lab", MeterCompileMinutes, 0)
+
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 5.0, sumUsage(sink.records, "greenhouse", MeterTelemetryPoints))
+	assert.Equal(t, 2.0, sumUsage(sink.records, "default", MeterTelemetryPoints))
+	assert.Equal(t, 1024.0, sumUsage(sink.records, "lab", MeterOTABytes))
+	assert.Equal(t, 1.5, sumUsage(sink.records, "lab", MeterCompileMinutes))
+	assert.Equal(t, 0.5, sumUsage(sink.records, "default", MeterCompileMinutes))
+	for _, record := range sink.records {
+		assert.Equal(t, time.Date(2026, 4, 10, 14, 0, 0, 0, time.UTC), record.Hour)
+		assert.Equal(t, "telemetry-service", record.Source)
+	}
+
+	// Greenhouse points are reported as one record
+	count := 0
+	for _, record := range sink.records {
+		if record.Project == "greenhouse" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestRecorder_KeepsUsageWhenReportFails(t *testing.T) {
+	sink := &failingSink{fail: true}
+	recorder := NewRecorder(testConfig(), logger.New("info", "test"), sink, testDevices)
+
+	recorder.AddForDevice("gh-1", MeterTelemetryPoints, 3)
+	assert.Error(t, recorder.Flush(context.Background()))
+	recorder.AddForDevice("gh-1", MeterTelemetryPoints, 4)
+
+	sink.fail = false
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 7.0, sumUsage(sink.records, "greenhouse", MeterTelemetryPoints))
+
+	// Nothing is reported twice
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 7.0, sumUsage(sink.records, "greenhouse", MeterTelemetryPoints))
+}
+
+func TestRecorder_MeterDevices(t *testing.T) {
+	now := time.Date(2026, 4, 10, 14, 0, 0, 0, time.UTC)
+	sink := &failingSink{}
+	recorder := NewRecorder(testConfig(), logger.New("info", "test"), sink, nil)
+	recorder.now = func() time.Time { return now }
+	recorder.MeterDevices(testDevices)
+
+	// April has 720 hours, so two devices for 90 minutes is 3/720
+	now = now.Add(90 * time.Minute)
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.InDelta(t, 3.0/720, sumUsage(sink.records, "greenhouse", MeterDeviceMonths), 1e-9)
+	assert.InDelta(t, 1.5/720, sumUsage(sink.records, "lab", MeterDeviceMonths), 1e-9)
+	assert.InDelta(t, 1.5/720, sumUsage(sink.records, "default", MeterDeviceMonths), 1e-9)
+
+	// A long gap is capped rather than billed in full
+	sink.records = nil
+	now = now.Add(48 * time.Hour)
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.InDelta(t, 2.0/720, sumUsage(sink.records, "lab", MeterDeviceMonths), 1e-9)
+
+	// Devices seen by the sampler resolve without a device lookup
+	sink.records = nil
+	recorder.AddForDevice("lab-1", MeterOTABytes, 10)
+	require.NoError(t, recorder.Flush(context.Background()))
+	assert.Equal(t, 10.0, sumUsage(sink.records, "lab", MeterOTABytes))
+}
+
+func TestNilRecorder(t *testing.T) {
+	var recorder *Recorder
+	recorder.Add("lab", MeterCompileMinutes, 1)
+	recorder.AddForDevice("gh-1", MeterTelemetryPoints, 1)
+	recorder.MeterDevices(testDevices)
+	recorder.Start()
+	recorder.Stop()
+	assert.NoError(t, recorder.Flush(context.Background()))
+
+	assert.Nil(t, NewRecorderFromConfig(&config.Config{Services: map[string]string{"api-gateway": "http://localhost:8000"}}, logger.New("info", "test"), nil))
+	cfg := testConfig()
+	assert.Nil(t, NewRecorderFromConfig(cfg, logger.New("info", "test"), nil))
+	cfg.Services = map[string]string{"api-gateway": "http://localhost:8000"}
+	assert.NotNil(t, NewRecorderFromConfig(cfg, logger.New("info", "test"), nil))
+}
+
+func TestSummarize(t *testing.T) {
+	hour := func(day, h int) time.Time { return time.Date(2026, 3, day, h, 0, 0, 0, time.UTC) }
+	records := []*UsageRecord{
+		{Project: "lab", Meter: MeterTelemetryPoints, Quantity: 10, Hour: hour(1, 0)},
+		{Project: "lab", Meter: MeterTelemetryPoints, Quantity: 5, Hour: hour(1, 1)},
+		{Project: "lab", Meter: MeterDeviceMonths, Quantity: 0.1, Hour: hour(2, 0)},
+		{Project: "lab", Meter: MeterDeviceMonths, Quantity: 0.2, Hour: hour(2, 1)},
+		{Project: "greenhouse", Meter: MeterOTABytes, Quantity: 2048, Hour: hour(31, 23)},
+	}
+
+	lines := Summarize(records, GranularityMonth)
+	require.Len(t, lines, 3)
+	assert.Equal(t, &UsageLine{
+		Project:     "greenhouse",
+		Meter:       MeterOTABytes,
+		Unit:        "byte",
+		PeriodStart: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		Quantity:    2048,
+	}, lines[0])
+	assert.Equal(t, MeterDeviceMonths, lines[1].Meter)
+	assert.Equal(t, 0.3, lines[1].Quantity)
+	assert.Equal(t, 15.0, lines[2].Quantity)
+
+	lines = Summarize(records, GranularityDay)
+	require.Len(t, lines, 3)
+	assert.Equal(t, time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), lines[0].PeriodStart)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), lines[1].PeriodStart)
+	assert.Equal(t, MeterTelemetryPoints, lines[1].Meter)
+
+	assert.Len(t, Summarize(records, GranularityHour), 5)
+}
+
+func newTestLedger(t *testing.T, now time.Time) (*Ledger, *[]*notifications.Event) {
+	t.Helper()
+	ledger := NewLedger(NewMemoryUsageStore(), testConfig(), logger.New("info", "test"))
+	ledger.now = func() time.Time { return now }
+	var events []*notifications.Event
+	ledger.SetPublisher(func(event *notifications.Event) { events = append(events, event) })
+	return ledger, &events
+}
+
+func TestUsageEntity(t *testing.T) {
+	hour := time.Date(2026, 4, 10, 14, 0, 0, 0, time.UTC)
+	record := &UsageRecord{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 12.5, Hour: hour}
+	assert.Equal(t, record, record.ToEntity().FromEntity())
+
+	// Usage is keyed by the hour it falls in
+	late := &UsageRecord{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 1, Hour: hour.Add(59 * time.Minute)}
+	assert.Equal(t, usageKey(record.ToEntity()), usageKey(late.ToEntity()))
+	other := &UsageRecord{Project: "greenhouse", Meter: MeterCompileMinutes, Quantity: 1, Hour: hour}
+	assert.NotEqual(t, usageKey(record.ToEntity()), usageKey(other.ToEntity()))
+}
+
+func TestLedger_Quotas(t *testing.T) {
+	now := time.Date(2026, 4, 10, 14, 0, 0, 0, time.UTC)
+	ledger, events := newTestLedger(t, now)
+	ctx := context.Background()
+
+	require.NoError(t, ledger.Report(ctx, []*UsageRecord{
+		{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 80, Hour: now},
+		{Project: "greenhouse", Meter: MeterCompileMinutes, Quantity: 5, Hour: now},
+		// Last month's usage does not count against this month's quota
+		{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 500, Hour: now.AddDate(0, -1, 0)},
+	}))
+	assert.Empty(t, *events)
+
+	statuses, err := ledger.QuotaStatus(ctx, "greenhouse")
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, &QuotaStatus{Project: "greenhouse", Meter: MeterTelemetryPoints, Unit: "point", Limit: 100, Used: 80, Remaining: 20}, statuses[0])
+	assert.Equal(t, MeterCompileMinutes, statuses[1].Meter)
+	assert.Equal(t, 60.0, statuses[1].Limit)
+
+	// Crossing the quota notifies once
+	require.NoError(t, ledger.Report(ctx, []*UsageRecord{{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 30, Hour: now}}))
+	require.NoError(t, ledger.Report(ctx, []*UsageRecord{{Project: "greenhouse", Meter: MeterTelemetryPoints, Quantity: 30, Hour: now}}))
+	require.Len(t, *events, 1)
+	event := (*events)[0]
+	assert.Equal(t, notifications.EventQuotaExceeded, event.Type)
+	assert.Equal(t, "greenhouse", event.ResourceID)
+	assert.Equal(t, MeterTelemetryPoints, event.Data["meter"])
+
+	statuses, err = ledger.QuotaStatus(ctx, "greenhouse")
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Exceeded)
+	assert.Equal(t, 0.0, statuses[0].Remaining)
+
+	// Projects without their own quota get the "*" quota
+	statuses, err = ledger.QuotaStatus(ctx, "lab")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, MeterCompileMinutes, statuses[0].Meter)
+
+	assert.ErrorIs(t, ledger.Report(ctx, []*UsageRecord{{Project: "lab", Meter: "coffee", Quantity: 1, Hour: now}}), ErrInvalidUsage)
+	assert.ErrorIs(t, ledger.Report(ctx, []*UsageRecord{{Meter: MeterOTABytes, Quantity: 1, Hour: now}}), ErrInvalidUsage)
+}
+
+func TestLedger_API(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 4, 10, 14, 0, 0, 0, time.UTC)
+	ledger, _ := newTestLedger(t, now)
+	router := gin.New()
+	router.POST(ReportPath, ledger.HandleReport)
+	ledger.RegisterRoutes(router.Group("/api/v1"))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// Services report through the HTTP sink
+	cfg := testConfig()
+	cfg.Services = map[string]string{"api-gateway": server.URL}
+	recorder := NewRecorder(cfg, logger.New("info", "test"), NewHTTPSink(cfg), testDevices)
+	recorder.now = func() time.Time { return now }
+	recorder.AddForDevice("gh-1", MeterTelemetryPoints, 40)
+	recorder.AddForDevice("lab-1", MeterTelemetryPoints, 7)
+	recorder.Add("lab", MeterCompileMinutes, 2.25)
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	// Unsigned reports are rejected
+	body, err := json.Marshal(usageReport{Records: []*UsageRecord{{Project: "lab", Meter: MeterOTABytes, Quantity: 1, Hour: now}}})
+	require.NoError(t, err)
+	resp, err := http.Post(server.URL+ReportPath, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/usage")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var usage struct {
+		Usage  []*UsageLine                 `json:"usage"`
+		Totals map[string]map[Meter]float64 `json:"totals"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Len(t, usage.Usage, 3)
+	assert.Equal(t, 40.0, usage.Totals["greenhouse"][MeterTelemetryPoints])
+	assert.Equal(t, 2.25, usage.Totals["lab"][MeterCompileMinutes])
+
+	w = get("/api/v1/usage?project=lab&granularity=day&from=2026-04-01&to=2026-05-01")
+	require.Equal(t, http.StatusOK, w.Code)
+	usage.Usage, usage.Totals = nil, nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Len(t, usage.Usage, 2)
+	assert.Equal(t, time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC), usage.Usage[0].PeriodStart)
+	assert.Nil(t, usage.Totals["greenhouse"])
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/usage?granularity=week").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/usage?from=2026-05-01&to=2026-04-01").Code)
+
+	w = get("/api/v1/usage/export?project=lab")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "usage-20260401-20260501.csv")
+	assert.Equal(t, strings.Join([]string{
+		"project,meter,unit,period_start,period_end,quantity",
+		"lab,telemetry_points,point,2026-04-01T00:00:00Z,2026-05-01T00:00:00Z,7",
+		"lab,compile_minutes,minute,2026-04-01T00:00:00Z,2026-05-01T00:00:00Z,2.25",
+		"",
+	}, "\n"), w.Body.String())
+
+	w = get("/api/v1/usage/export?format=json")
+	require.Equal(t, http.StatusOK, w.Code)
+	var lines []*UsageLine
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lines))
+	assert.Len(t, lines, 3)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/usage/export?format=xml").Code)
+
+	w = get("/api/v1/usage/quotas")
+	require.Equal(t, http.StatusOK, w.Code)
+	var quotas struct {
+		Quotas []*QuotaStatus `json:"quotas"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quotas))
+	require.Len(t, quotas.Quotas, 3)
+	assert.Equal(t, "greenhouse", quotas.Quotas[0].Project)
+	assert.Equal(t, 60.0, quotas.Quotas[0].Remaining)
+	assert.Equal(t, "lab", quotas.Quotas[2].Project)
+	assert.Equal(t, 57.75, quotas.Quotas[2].Remaining)
+}
@@ -0,0 +1,288 @@
+package metering
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+const (
+	defaultFlushInterval = time.Minute
+	// projectCacheTTL bounds how long a device's project is remembered, so
+	// relabelled devices are billed to their new project soon after
+	projectCacheTTL = 10 * time.Minute
+	// maxSamplePeriod caps the device-months recorded for one sample, so a
+	// stalled sampler does not bill an outage it could not observe
+	maxSamplePeriod = 2 * time.Hour
+)
+
+// pendingKey identifies buffered usage. Usage recorded for a device has no
+// project until the recorder resolves it on flush.
+type pendingKey struct {
+	project  string
+	deviceID string
+	meter    Meter
+	hour     time.Time
+}
+
+type cachedProject struct {
+	project string
+	expires time.Time
+}
+
+// Recorder buffers usage in memory and reports it to a Sink every flush
+// interval, so metering adds no I/O to the paths it measures. A nil
+// Recorder discards usage, which lets services run with metering off.
+type Recorder struct {
+	sink     Sink
+	devices  DeviceLookup
+	cfg      config.MeteringConfig
+	source   string
+	interval time.Duration
+	logger   *logger.Logger
+	now      func() time.Time
+
+	mu         sync.Mutex
+	pending    map[pendingKey]float64
+	projects   map[string]cachedProject
+	lister     DeviceLister
+	lastSample time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder creates a recorder reporting to sink. devices resolves the
+// project of usage recorded for a device and may be nil, in which case such
+// usage goes to the default project.
+func NewRecorder(cfg *config.Config, log *logger.Logger, sink Sink, devices DeviceLookup) *Recorder {
+	interval := cfg.Metering.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	return &Recorder{
+		sink:     sink,
+		devices:  devices,
+		cfg:      cfg.Metering,
+		source:   cfg.ServiceName,
+		interval: interval,
+		logger:   log,
+		now:      time.Now,
+		pending:  make(map[pendingKey]float64),
+		projects: make(map[string]cachedProject),
+	}
+}
+
+// NewRecorderFromConfig returns a recorder reporting to the API gateway, or
+// nil when metering is disabled or the gateway address is not configured
+func NewRecorderFromConfig(cfg *config.Config, log *logger.Logger, devices DeviceLookup) *Recorder {
+	if cfg == nil || !cfg.Metering.Enabled || cfg.Services["api-gateway"] == "" {
+		return nil
+	}
+	return NewRecorder(cfg, log, NewHTTPSink(cfg), devices)
+}
+
+// Add records usage for a project. An empty project is the default project.
+func (r *Recorder) Add(project string, meter Meter, quantity float64) {
+	if project == "" {
+		project = r.defaultProject()
+	}
+	r.add(pendingKey{project: project, meter: meter}, quantity)
+}
+
+// AddForDevice records usage for the project a device belongs to
+func (r *Recorder) AddForDevice(deviceID string, meter Meter, quantity float64) {
+	r.add(pendingKey{deviceID: deviceID, meter: meter}, quantity)
+}
+
+func (r *Recorder) add(key pendingKey, quantity float64) {
+	if r == nil || quantity <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.hour = r.now().UTC().Truncate(time.Hour)
+	r.pending[key] += quantity
+}
+
+func (r *Recorder) defaultProject() string {
+	if r == nil {
+		return ""
+	}
+	return r.cfg.DefaultProject
+}
+
+// MeterDevices makes the recorder sample registered devices on every flush
+// and record device-months for the time since the previous sample. Devices
+// are listed from lister and counted against their project label.
+func (r *Recorder) MeterDevices(lister DeviceLister) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lister = lister
+	r.lastSample = r.now()
+}
+
+// Start flushes buffered usage every flush interval until Stop is called
+func (r *Recorder) Start() {
+	if r == nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.flushInBackground()
+			}
+		}
+	}()
+}
+
+// Stop ends the flush loop and reports what is still buffered
+func (r *Recorder) Stop() {
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.flushInBackground()
+}
+
+func (r *Recorder) flushInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+	if err := r.Flush(ctx); err != nil {
+		r.logger.Warn("Failed to report usage", "error", err)
+	}
+}
+
+// Flush samples devices if MeterDevices was called and reports buffered
+// usage. Usage that cannot be reported stays buffered for the next flush.
+func (r *Recorder) Flush(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	if err := r.sampleDevices(ctx); err != nil {
+		r.logger.Warn("Failed to sample devices for metering", "error", err)
+	}
+
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[pendingKey]float64)
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	records := make(map[pendingKey]*UsageRecord)
+	var ordered []*UsageRecord
+	for key, quantity := range pending {
+		project := key.project
+		if key.deviceID != "" {
+			project = r.projectOf(ctx, key.deviceID)
+		}
+		recordKey := pendingKey{project: project, meter: key.meter, hour: key.hour}
+		if record, ok := records[recordKey]; ok {
+			record.Quantity += quantity
+			continue
+		}
+		record := &UsageRecord{Project: project, Meter: key.meter, Quantity: quantity, Hour: key.hour, Source: r.source}
+		records[recordKey] = record
+		ordered = append(ordered, record)
+	}
+
+	if err := r.sink.Report(ctx, ordered); err != nil {
+		r.mu.Lock()
+		for key, quantity := range pending {
+			r.pending[key] += quantity
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sampleDevices records device-months for every registered device over the
+// time since the previous sample
+func (r *Recorder) sampleDevices(ctx context.Context) error {
+	r.mu.Lock()
+	lister := r.lister
+	r.mu.Unlock()
+	if lister == nil {
+		return nil
+	}
+
+	devices, err := lister.ListDevices(ctx, &device.DeviceFilters{})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	period := now.Sub(r.lastSample)
+	r.lastSample = now
+	if period > maxSamplePeriod {
+		period = maxSamplePeriod
+	}
+	if period <= 0 {
+		return nil
+	}
+
+	share := period.Hours() / monthHours(now)
+	hour := now.UTC().Truncate(time.Hour)
+	for _, d := range devices {
+		project := ProjectOf(r.cfg, d)
+		r.projects[d.DeviceID] = cachedProject{project: project, expires: now.Add(projectCacheTTL)}
+		r.pending[pendingKey{project: project, meter: MeterDeviceMonths, hour: hour}] += share
+	}
+	return nil
+}
+
+// projectOf resolves a device's project, caching the answer
+func (r *Recorder) projectOf(ctx context.Context, deviceID string) string {
+	now := r.now()
+	r.mu.Lock()
+	cached, ok := r.projects[deviceID]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.project
+	}
+
+	project := r.cfg.DefaultProject
+	if r.devices != nil {
+		d, err := r.devices.GetDevice(ctx, deviceID)
+		if err != nil {
+			r.logger.Debug("Billing usage of unknown device", "device_id", deviceID, "project", project, "error", err)
+		} else {
+			project = ProjectOf(r.cfg, d)
+		}
+	}
+
+	r.mu.Lock()
+	r.projects[deviceID] = cachedProject{project: project, expires: now.Add(projectCacheTTL)}
+	r.mu.Unlock()
+	return project
+}
+
+// monthHours returns the length in hours of the calendar month containing t
+func monthHours(t time.Time) float64 {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start.AddDate(0, 1, 0).Sub(start).Hours()
+}
@@ -0,0 +1,139 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// maxUsageBatch is the most hourly entities written in one transaction,
+// Datastore's limit for a single commit
+const maxUsageBatch = 500
+
+// UsageEntity is one project's hourly use of one meter in Datastore
+type UsageEntity struct {
+	Project  string    `datastore:"project"`
+	Meter    string    `datastore:"meter"`
+	Hour     time.Time `datastore:"hour"`
+	Quantity float64   `datastore:"quantity,noindex"`
+}
+
+// ToEntity converts a UsageRecord to a UsageEntity for its hour
+func (r *UsageRecord) ToEntity() *UsageEntity {
+	return &UsageEntity{
+		Project:  r.Project,
+		Meter:    string(r.Meter),
+		Hour:     r.Hour.UTC().Truncate(time.Hour),
+		Quantity: r.Quantity,
+	}
+}
+
+// FromEntity converts a UsageEntity to a UsageRecord
+func (ue *UsageEntity) FromEntity() *UsageRecord {
+	return &UsageRecord{
+		Project:  ue.Project,
+		Meter:    Meter(ue.Meter),
+		Quantity: ue.Quantity,
+		Hour:     ue.Hour.UTC(),
+	}
+}
+
+// DatastoreUsageStore keeps hourly usage in Datastore, one entity per
+// project, meter and hour. Reports for the same hour are summed in a
+// transaction, so gateway replicas can share the ledger.
+type DatastoreUsageStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreUsageStore creates a usage store on a Datastore client
+func NewDatastoreUsageStore(client *datastore.Client) *DatastoreUsageStore {
+	return &DatastoreUsageStore{client: client}
+}
+
+func usageKey(entity *UsageEntity) *datastore.Key {
+	return datastore.NameKey("UsageHour", fmt.Sprintf("%s/%s/%d", entity.Project, entity.Meter, entity.Hour.Unix()), nil)
+}
+
+func (s *DatastoreUsageStore) AddUsage(ctx context.Context, records []*UsageRecord) error {
+	// Sum records for the same hour first, as a transaction cannot write an
+	// entity twice
+	var keys []*datastore.Key
+	var entities []*UsageEntity
+	index := make(map[string]int)
+	for _, record := range records {
+		entity := record.ToEntity()
+		key := usageKey(entity)
+		if i, ok := index[key.Name]; ok {
+			entities[i].Quantity += entity.Quantity
+			continue
+		}
+		index[key.Name] = len(keys)
+		keys = append(keys, key)
+		entities = append(entities, entity)
+	}
+
+	for start := 0; start < len(keys); start += maxUsageBatch {
+		end := min(start+maxUsageBatch, len(keys))
+		if err := s.addBatch(ctx, keys[start:end], entities[start:end]); err != nil {
+			return fmt.Errorf("failed to add usage in Datastore: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *DatastoreUsageStore) addBatch(ctx context.Context, keys []*datastore.Key, entities []*UsageEntity) error {
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		stored := make([]*UsageEntity, len(keys))
+		if err := tx.GetMulti(keys, stored); err != nil {
+			var multi datastore.MultiError
+			if !errors.As(err, &multi) {
+				return err
+			}
+			for _, err := range multi {
+				if err != nil && err != datastore.ErrNoSuchEntity {
+					return err
+				}
+			}
+		}
+
+		updated := make([]*UsageEntity, len(keys))
+		for i, entity := range entities {
+			sum := *entity
+			if stored[i] != nil {
+				sum.Quantity += stored[i].Quantity
+			}
+			updated[i] = &sum
+		}
+		_, err := tx.PutMulti(keys, updated)
+		return err
+	})
+	return err
+}
+
+// ListUsage returns matching hourly usage ordered by hour, project and meter
+func (s *DatastoreUsageStore) ListUsage(ctx context.Context, filter UsageFilter) ([]*UsageRecord, error) {
+	query := datastore.NewQuery("UsageHour")
+	if filter.Project != "" {
+		query = query.Filter("project =", filter.Project)
+	}
+	if !filter.From.IsZero() {
+		query = query.Filter("hour >=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Filter("hour <", filter.To)
+	}
+
+	var entities []*UsageEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list usage from Datastore: %w", err)
+	}
+	records := make([]*UsageRecord, len(entities))
+	for i, entity := range entities {
+		records[i] = entity.FromEntity()
+	}
+	sortUsage(records)
+	return records, nil
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+)
+
+// DeviceRepository is a device.Repository that records the latency,
+// outcome and entity count of every call to the repository it wraps
+type DeviceRepository struct {
+	repo device.Repository
+	instrumented
+}
+
+// NewDeviceRepository wraps repo with metrics recorded on m
+func NewDeviceRepository(repo device.Repository, m *Metrics) *DeviceRepository {
+	return &DeviceRepository{repo: repo, instrumented: instrumented{metrics: m, repository: "device"}}
+}
+
+// RegisterDevice registers a device
+func (r *DeviceRepository) RegisterDevice(ctx context.Context, d *device.Device) error {
+	call := r.start("RegisterDevice")
+	err := r.repo.RegisterDevice(ctx, d)
+	call.done(err)
+	return err
+}
+
+// GetDevice returns a device
+func (r *DeviceRepository) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	call := r.start("GetDevice")
+	d, err := r.repo.GetDevice(ctx, deviceID)
+	call.done(err)
+	return d, err
+}
+
+// UpdateDevice updates a device
+func (r *DeviceRepository) UpdateDevice(ctx context.Context, d *device.Device) error {
+	call := r.start("UpdateDevice")
+	err := r.repo.UpdateDevice(ctx, d)
+	call.done(err)
+	return err
+}
+
+// DeleteDevice deletes a device
+func (r *DeviceRepository) DeleteDevice(ctx context.Context, deviceID string) error {
+	call := r.start("DeleteDevice")
+	err := r.repo.DeleteDevice(ctx, deviceID)
+	call.done(err)
+	return err
+}
+
+// ListDevices lists the devices matching filters
+func (r *DeviceRepository) ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error) {
+	call := r.start("ListDevices")
+	devices, err := r.repo.ListDevices(ctx, filters)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// GetDeviceCount counts the devices matching filters
+func (r *DeviceRepository) GetDeviceCount(ctx context.Context, filters *device.DeviceFilters) (int64, error) {
+	call := r.start("GetDeviceCount")
+	count, err := r.repo.GetDeviceCount(ctx, filters)
+	call.done(err)
+	return count, err
+}
+
+// SearchDevices searches the devices matching filters
+func (r *DeviceRepository) SearchDevices(ctx context.Context, query string, filters *device.DeviceFilters) ([]*device.Device, error) {
+	call := r.start("SearchDevices")
+	devices, err := r.repo.SearchDevices(ctx, query, filters)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// UpdateDeviceStatus updates a device's status
+func (r *DeviceRepository) UpdateDeviceStatus(ctx context.Context, deviceID string, status device.DeviceStatus, lastSeen time.Time) error {
+	call := r.start("UpdateDeviceStatus")
+	err := r.repo.UpdateDeviceStatus(ctx, deviceID, status, lastSeen)
+	call.done(err)
+	return err
+}
+
+// GetDevicesByStatus lists the devices with a status
+func (r *DeviceRepository) GetDevicesByStatus(ctx context.Context, status device.DeviceStatus) ([]*device.Device, error) {
+	call := r.start("GetDevicesByStatus")
+	devices, err := r.repo.GetDevicesByStatus(ctx, status)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// GetOfflineDevices lists the devices not seen within timeout
+func (r *DeviceRepository) GetOfflineDevices(ctx context.Context, timeout time.Duration) ([]*device.Device, error) {
+	call := r.start("GetOfflineDevices")
+	devices, err := r.repo.GetOfflineDevices(ctx, timeout)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// GetDeviceHealthStatus summarizes the health of the fleet
+func (r *DeviceRepository) GetDeviceHealthStatus(ctx context.Context) (*device.DeviceHealthStatus, error) {
+	call := r.start("GetDeviceHealthStatus")
+	status, err := r.repo.GetDeviceHealthStatus(ctx)
+	call.done(err)
+	return status, err
+}
+
+// GetDevicesByTemplate lists the devices built from a template
+func (r *DeviceRepository) GetDevicesByTemplate(ctx context.Context, templateID string) ([]*device.Device, error) {
+	call := r.start("GetDevicesByTemplate")
+	devices, err := r.repo.GetDevicesByTemplate(ctx, templateID)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// GetDevicesByOTAChannel lists the devices on an OTA channel
+func (r *DeviceRepository) GetDevicesByOTAChannel(ctx context.Context, channel string) ([]*device.Device, error) {
+	call := r.start("GetDevicesByOTAChannel")
+	devices, err := r.repo.GetDevicesByOTAChannel(ctx, channel)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
+
+// DeviceExists reports whether a device is registered
+func (r *DeviceRepository) DeviceExists(ctx context.Context, deviceID string) (bool, error) {
+	call := r.start("DeviceExists")
+	exists, err := r.repo.DeviceExists(ctx, deviceID)
+	call.done(err)
+	return exists, err
+}
+
+// GetDevicesLastSeenBefore lists the devices last seen before a time
+func (r *DeviceRepository) GetDevicesLastSeenBefore(ctx context.Context, before time.Time) ([]*device.Device, error) {
+	call := r.start("GetDevicesLastSeenBefore")
+	devices, err := r.repo.GetDevicesLastSeenBefore(ctx, before)
+	call.doneWith(len(devices), err)
+	return devices, err
+}
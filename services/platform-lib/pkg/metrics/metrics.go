@@ -30,6 +30,11 @@ type Metrics struct {
 	dbQueriesTotal    *prometheus.CounterVec
 	dbQueryDuration   *prometheus.HistogramVec
 
+	// Repository metrics
+	repositoryCalls        *prometheus.CounterVec
+	repositoryCallDuration *prometheus.HistogramVec
+	repositoryEntities     *prometheus.HistogramVec
+
 	// Business metrics
 	devicesRegistered prometheus.Gauge
 	devicesOnline     prometheus.Gauge
@@ -181,6 +186,42 @@ func NewMetrics(serviceName string, logger logger.Logger) *Metrics {
 		[]string{"operation", "table"},
 	)
 
+	// Initialize repository metrics
+	m.repositoryCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "repository_calls_total",
+			Help: "Total number of repository calls",
+			ConstLabels: prometheus.Labels{
+				"service": serviceName,
+			},
+		},
+		[]string{"repository", "method", "outcome"},
+	)
+
+	m.repositoryCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "repository_call_duration_seconds",
+			Help: "Repository call duration in seconds",
+			ConstLabels: prometheus.Labels{
+				"service": serviceName,
+			},
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"repository", "method"},
+	)
+
+	m.repositoryEntities = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "repository_call_entities",
+			Help: "Number of entities read or written by a repository call",
+			ConstLabels: prometheus.Labels{
+				"service": serviceName,
+			},
+			Buckets: []float64{0, 1, 10, 100, 1000, 10000},
+		},
+		[]string{"repository", "method"},
+	)
+
 	// Initialize business metrics
 	m.devicesRegistered = prometheus.NewGauge(
 		prometheus.GaugeOpts{
@@ -237,6 +278,9 @@ func NewMetrics(serviceName string, logger logger.Logger) *Metrics {
 		m.dbConnections,
 		m.dbQueriesTotal,
 		m.dbQueryDuration,
+		m.repositoryCalls,
+		m.repositoryCallDuration,
+		m.repositoryEntities,
 		m.devicesRegistered,
 		m.devicesOnline,
 		m.templatesDeployed,
@@ -326,6 +370,22 @@ func (m *Metrics) RecordDBQuery(operation, table string, duration time.Duration)
 	m.dbQueryDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
 }
 
+// RecordRepositoryCall records a repository call and whether it failed
+func (m *Metrics) RecordRepositoryCall(repository, method string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.repositoryCalls.WithLabelValues(repository, method, outcome).Inc()
+	m.repositoryCallDuration.WithLabelValues(repository, method).Observe(duration.Seconds())
+}
+
+// RecordRepositoryEntities records the number of entities a repository call
+// read or wrote
+func (m *Metrics) RecordRepositoryEntities(repository, method string, count int) {
+	m.repositoryEntities.WithLabelValues(repository, method).Observe(float64(count))
+}
+
 // RecordError records an error
 func (m *Metrics) RecordError(errorType, component string) {
 	m.errorsTotal.WithLabelValues(errorType, component).Inc()
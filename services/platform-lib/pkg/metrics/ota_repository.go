@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/athena/platform-lib/pkg/ota"
+)
+
+// OTARepository is an ota.Repository that records the latency, outcome and
+// entity count of every call to the repository it wraps
+type OTARepository struct {
+	repo ota.Repository
+	instrumented
+}
+
+// NewOTARepository wraps repo with metrics recorded on m
+func NewOTARepository(repo ota.Repository, m *Metrics) *OTARepository {
+	return &OTARepository{repo: repo, instrumented: instrumented{metrics: m, repository: "ota"}}
+}
+
+// CreateRelease stores a firmware release
+func (r *OTARepository) CreateRelease(ctx context.Context, release *ota.FirmwareRelease) error {
+	call := r.start("CreateRelease")
+	err := r.repo.CreateRelease(ctx, release)
+	call.done(err)
+	return err
+}
+
+// GetRelease returns a firmware release
+func (r *OTARepository) GetRelease(ctx context.Context, releaseID string) (*ota.FirmwareRelease, error) {
+	call := r.start("GetRelease")
+	release, err := r.repo.GetRelease(ctx, releaseID)
+	call.done(err)
+	return release, err
+}
+
+// GetReleaseByVersion returns a template's release of a version on a channel
+func (r *OTARepository) GetReleaseByVersion(ctx context.Context, templateID, version string, channel ota.ReleaseChannel) (*ota.FirmwareRelease, error) {
+	call := r.start("GetReleaseByVersion")
+	release, err := r.repo.GetReleaseByVersion(ctx, templateID, version, channel)
+	call.done(err)
+	return release, err
+}
+
+// ListReleases lists a template's releases on a channel
+func (r *OTARepository) ListReleases(ctx context.Context, templateID string, channel ota.ReleaseChannel) ([]*ota.FirmwareRelease, error) {
+	call := r.start("ListReleases")
+	releases, err := r.repo.ListReleases(ctx, templateID, channel)
+	call.doneWith(len(releases), err)
+	return releases, err
+}
+
+// UpdateRelease updates a firmware release
+func (r *OTARepository) UpdateRelease(ctx context.Context, release *ota.FirmwareRelease) error {
+	call := r.start("UpdateRelease")
+	err := r.repo.UpdateRelease(ctx, release)
+	call.done(err)
+	return err
+}
+
+// DeleteRelease deletes a firmware release
+func (r *OTARepository) DeleteRelease(ctx context.Context, releaseID string) error {
+	call := r.start("DeleteRelease")
+	err := r.repo.DeleteRelease(ctx, releaseID)
+	call.done(err)
+	return err
+}
+
+// ReleaseExists reports whether a firmware release is stored
+func (r *OTARepository) ReleaseExists(ctx context.Context, releaseID string) (bool, error) {
+	call := r.start("ReleaseExists")
+	exists, err := r.repo.ReleaseExists(ctx, releaseID)
+	call.done(err)
+	return exists, err
+}
+
+// CreateDeployment stores a deployment
+func (r *OTARepository) CreateDeployment(ctx context.Context, deployment *ota.OTADeployment) error {
+	call := r.start("CreateDeployment")
+	err := r.repo.CreateDeployment(ctx, deployment)
+	call.done(err)
+	return err
+}
+
+// GetDeployment returns a deployment
+func (r *OTARepository) GetDeployment(ctx context.Context, deploymentID string) (*ota.OTADeployment, error) {
+	call := r.start("GetDeployment")
+	deployment, err := r.repo.GetDeployment(ctx, deploymentID)
+	call.done(err)
+	return deployment, err
+}
+
+// UpdateDeployment updates a deployment
+func (r *OTARepository) UpdateDeployment(ctx context.Context, deployment *ota.OTADeployment) error {
+	call := r.start("UpdateDeployment")
+	err := r.repo.UpdateDeployment(ctx, deployment)
+	call.done(err)
+	return err
+}
+
+// ModifyDeviceUpdate applies modify to a device update. The recorded
+// latency includes modify and any retries of the transaction.
+func (r *OTARepository) ModifyDeviceUpdate(ctx context.Context, deviceID, releaseID string, modify func(*ota.DeviceUpdate) error) (*ota.DeviceUpdate, error) {
+	call := r.start("ModifyDeviceUpdate")
+	update, err := r.repo.ModifyDeviceUpdate(ctx, deviceID, releaseID, modify)
+	call.done(err)
+	return update, err
+}
+
+// ModifyDeployment applies modify to a deployment. The recorded latency
+// includes modify and any retries of the transaction.
+func (r *OTARepository) ModifyDeployment(ctx context.Context, deploymentID string, modify func(*ota.OTADeployment) error) (*ota.OTADeployment, error) {
+	call := r.start("ModifyDeployment")
+	deployment, err := r.repo.ModifyDeployment(ctx, deploymentID, modify)
+	call.done(err)
+	return deployment, err
+}
+
+// ListDeployments lists a release's deployments
+func (r *OTARepository) ListDeployments(ctx context.Context, releaseID string) ([]*ota.OTADeployment, error) {
+	call := r.start("ListDeployments")
+	deployments, err := r.repo.ListDeployments(ctx, releaseID)
+	call.doneWith(len(deployments), err)
+	return deployments, err
+}
+
+// GetActiveDeployments lists the active deployments
+func (r *OTARepository) GetActiveDeployments(ctx context.Context) ([]*ota.OTADeployment, error) {
+	call := r.start("GetActiveDeployments")
+	deployments, err := r.repo.GetActiveDeployments(ctx)
+	call.doneWith(len(deployments), err)
+	return deployments, err
+}
+
+// CreateDeviceUpdate stores a device update
+func (r *OTARepository) CreateDeviceUpdate(ctx context.Context, update *ota.DeviceUpdate) error {
+	call := r.start("CreateDeviceUpdate")
+	err := r.repo.CreateDeviceUpdate(ctx, update)
+	call.done(err)
+	return err
+}
+
+// GetDeviceUpdate returns a device's update to a release
+func (r *OTARepository) GetDeviceUpdate(ctx context.Context, deviceID, releaseID string) (*ota.DeviceUpdate, error) {
+	call := r.start("GetDeviceUpdate")
+	update, err := r.repo.GetDeviceUpdate(ctx, deviceID, releaseID)
+	call.done(err)
+	return update, err
+}
+
+// UpdateDeviceUpdate updates a device update
+func (r *OTARepository) UpdateDeviceUpdate(ctx context.Context, update *ota.DeviceUpdate) error {
+	call := r.start("UpdateDeviceUpdate")
+	err := r.repo.UpdateDeviceUpdate(ctx, update)
+	call.done(err)
+	return err
+}
+
+// ListDeviceUpdates lists a deployment's device updates
+func (r *OTARepository) ListDeviceUpdates(ctx context.Context, deploymentID string) ([]*ota.DeviceUpdate, error) {
+	call := r.start("ListDeviceUpdates")
+	updates, err := r.repo.ListDeviceUpdates(ctx, deploymentID)
+	call.doneWith(len(updates), err)
+	return updates, err
+}
+
+// QueryDeviceUpdates returns a page of a deployment's device updates
+func (r *OTARepository) QueryDeviceUpdates(ctx context.Context, deploymentID string, query *ota.DeviceUpdateQuery) ([]*ota.DeviceUpdate, string, error) {
+	call := r.start("QueryDeviceUpdates")
+	updates, nextCursor, err := r.repo.QueryDeviceUpdates(ctx, deploymentID, query)
+	call.doneWith(len(updates), err)
+	return updates, nextCursor, err
+}
+
+// CountDeviceUpdatesByStatus counts a deployment's device updates by status
+func (r *OTARepository) CountDeviceUpdatesByStatus(ctx context.Context, deploymentID string) (map[ota.UpdateStatus]int, error) {
+	call := r.start("CountDeviceUpdatesByStatus")
+	counts, err := r.repo.CountDeviceUpdatesByStatus(ctx, deploymentID)
+	call.done(err)
+	return counts, err
+}
+
+// GetDeviceUpdatesByStatus lists a deployment's device updates with a status
+func (r *OTARepository) GetDeviceUpdatesByStatus(ctx context.Context, deploymentID string, status ota.UpdateStatus) ([]*ota.DeviceUpdate, error) {
+	call := r.start("GetDeviceUpdatesByStatus")
+	updates, err := r.repo.GetDeviceUpdatesByStatus(ctx, deploymentID, status)
+	call.doneWith(len(updates), err)
+	return updates, err
+}
+
+// GetLatestUpdateForDevice returns a device's latest update
+func (r *OTARepository) GetLatestUpdateForDevice(ctx context.Context, deviceID string) (*ota.DeviceUpdate, error) {
+	call := r.start("GetLatestUpdateForDevice")
+	update, err := r.repo.GetLatestUpdateForDevice(ctx, deviceID)
+	call.done(err)
+	return update, err
+}
+
+// ListUpdatesForDevice lists a device's updates
+func (r *OTARepository) ListUpdatesForDevice(ctx context.Context, deviceID string, limit int) ([]*ota.DeviceUpdate, error) {
+	call := r.start("ListUpdatesForDevice")
+	updates, err := r.repo.ListUpdatesForDevice(ctx, deviceID, limit)
+	call.doneWith(len(updates), err)
+	return updates, err
+}
+
+// CreateCrashReport stores a crash report
+func (r *OTARepository) CreateCrashReport(ctx context.Context, report *ota.CrashReport) error {
+	call := r.start("CreateCrashReport")
+	err := r.repo.CreateCrashReport(ctx, report)
+	call.done(err)
+	return err
+}
+
+// ListCrashReportsForDevice lists a device's crash reports
+func (r *OTARepository) ListCrashReportsForDevice(ctx context.Context, deviceID string, limit int) ([]*ota.CrashReport, error) {
+	call := r.start("ListCrashReportsForDevice")
+	reports, err := r.repo.ListCrashReportsForDevice(ctx, deviceID, limit)
+	call.doneWith(len(reports), err)
+	return reports, err
+}
+
+// ListCrashReportsForDeployment lists a deployment's crash reports
+func (r *OTARepository) ListCrashReportsForDeployment(ctx context.Context, deploymentID string) ([]*ota.CrashReport, error) {
+	call := r.start("ListCrashReportsForDeployment")
+	reports, err := r.repo.ListCrashReportsForDeployment(ctx, deploymentID)
+	call.doneWith(len(reports), err)
+	return reports, err
+}
+
+// CreateWebhook stores a webhook
+func (r *OTARepository) CreateWebhook(ctx context.Context, webhook *ota.Webhook) error {
+	call := r.start("CreateWebhook")
+	err := r.repo.CreateWebhook(ctx, webhook)
+	call.done(err)
+	return err
+}
+
+// GetWebhook returns a webhook
+func (r *OTARepository) GetWebhook(ctx context.Context, webhookID string) (*ota.Webhook, error) {
+	call := r.start("GetWebhook")
+	webhook, err := r.repo.GetWebhook(ctx, webhookID)
+	call.done(err)
+	return webhook, err
+}
+
+// ListWebhooks lists the webhooks
+func (r *OTARepository) ListWebhooks(ctx context.Context) ([]*ota.Webhook, error) {
+	call := r.start("ListWebhooks")
+	webhooks, err := r.repo.ListWebhooks(ctx)
+	call.doneWith(len(webhooks), err)
+	return webhooks, err
+}
+
+// UpdateWebhook updates a webhook
+func (r *OTARepository) UpdateWebhook(ctx context.Context, webhook *ota.Webhook) error {
+	call := r.start("UpdateWebhook")
+	err := r.repo.UpdateWebhook(ctx, webhook)
+	call.done(err)
+	return err
+}
+
+// DeleteWebhook deletes a webhook
+func (r *OTARepository) DeleteWebhook(ctx context.Context, webhookID string) error {
+	call := r.start("DeleteWebhook")
+	err := r.repo.DeleteWebhook(ctx, webhookID)
+	call.done(err)
+	return err
+}
+
+// GetDeploymentStats counts a deployment's succeeded, failed and pending
+// device updates
+func (r *OTARepository) GetDeploymentStats(ctx context.Context, deploymentID string) (int, int, int, error) {
+	call := r.start("GetDeploymentStats")
+	successCount, failureCount, pendingCount, err := r.repo.GetDeploymentStats(ctx, deploymentID)
+	call.done(err)
+	return successCount, failureCount, pendingCount, err
+}
+
+// GetDevicesPendingUpdate lists a deployment's pending device updates
+func (r *OTARepository) GetDevicesPendingUpdate(ctx context.Context, deploymentID string, limit int) ([]*ota.DeviceUpdate, error) {
+	call := r.start("GetDevicesPendingUpdate")
+	updates, err := r.repo.GetDevicesPendingUpdate(ctx, deploymentID, limit)
+	call.doneWith(len(updates), err)
+	return updates, err
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import "time"
+
+// instrumented records the calls of one repository. The repository
+// wrappers time every method, count failed calls, and record how many
+// entities listings return and batch writes store.
+type instrumented struct {
+	metrics    *Metrics
+	repository string
+}
+
+// start begins timing a call of method
+func (i instrumented) start(method string) repositoryCall {
+	return repositoryCall{instrumented: i, method: method, started: time.Now()}
+}
+
+// repositoryCall is one timed repository call
+type repositoryCall struct {
+	instrumented
+	method  string
+	started time.Time
+}
+
+// done records the call's latency and outcome
+func (c repositoryCall) done(err error) {
+	c.metrics.RecordRepositoryCall(c.repository, c.method, time.Since(c.started), err)
+}
+
+// doneWith records the call like done, and the entities it read or wrote
+// when it succeeded
+func (c repositoryCall) doneWith(entities int, err error) {
+	c.done(err)
+	if err == nil {
+		c.metrics.RecordRepositoryEntities(c.repository, c.method, entities)
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRepository_RecordsCalls(t *testing.T) {
+	m := NewMetrics("template-service", *logger.New("debug", "test"))
+	repo := NewTemplateRepository(template.NewMemoryRepository(), m)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateTemplate(ctx, &template.Template{ID: "sensor", Version: "1.0.0", Name: "Sensor"}))
+	require.NoError(t, repo.CreateTemplate(ctx, &template.Template{ID: "sensor", Version: "1.1.0", Name: "Sensor"}))
+	assert.Error(t, repo.CreateTemplate(ctx, &template.Template{ID: "sensor", Version: "1.0.0", Name: "Sensor"}))
+
+	_, err := repo.GetTemplate(ctx, "missing", "1.0.0")
+	assert.Error(t, err)
+
+	versions, err := repo.GetTemplateVersions(ctx, "sensor")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Contains(t, body, `repository_calls_total{method="CreateTemplate",outcome="success",repository="template",service="template-service"} 2`)
+	assert.Contains(t, body, `repository_calls_total{method="CreateTemplate",outcome="error",repository="template",service="template-service"} 1`)
+	assert.Contains(t, body, `repository_calls_total{method="GetTemplate",outcome="error",repository="template",service="template-service"} 1`)
+	assert.Contains(t, body, `repository_call_duration_seconds_count{method="CreateTemplate",repository="template",service="template-service"} 3`)
+
+	// Entity counts are recorded for listings that succeeded
+	assert.Contains(t, body, `repository_call_entities_sum{method="GetTemplateVersions",repository="template",service="template-service"} 2`)
+	assert.NotContains(t, body, `repository_call_entities_count{method="CreateTemplate"`)
+}
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/telemetry"
+)
+
+// TelemetryRepository is a telemetry.Repository that records the latency,
+// outcome and entity count of every call to the repository it wraps
+type TelemetryRepository struct {
+	repo telemetry.Repository
+	instrumented
+}
+
+// NewTelemetryRepository wraps repo with metrics recorded on m
+func NewTelemetryRepository(repo telemetry.Repository, m *Metrics) *TelemetryRepository {
+	return &TelemetryRepository{repo: repo, instrumented: instrumented{metrics: m, repository: "telemetry"}}
+}
+
+// StoreTelemetry stores a telemetry message
+func (r *TelemetryRepository) StoreTelemetry(ctx context.Context, data *telemetry.TelemetryData) error {
+	call := r.start("StoreTelemetry")
+	err := r.repo.StoreTelemetry(ctx, data)
+	call.done(err)
+	return err
+}
+
+// StoreTelemetryBatch stores a batch of telemetry messages
+func (r *TelemetryRepository) StoreTelemetryBatch(ctx context.Context, batch []*telemetry.TelemetryData) error {
+	call := r.start("StoreTelemetryBatch")
+	err := r.repo.StoreTelemetryBatch(ctx, batch)
+	call.doneWith(len(batch), err)
+	return err
+}
+
+// GetDeviceMetrics returns a device's metric points within a time range
+func (r *TelemetryRepository) GetDeviceMetrics(ctx context.Context, deviceID string, timeRange telemetry.TimeRange) ([]*telemetry.MetricPoint, error) {
+	call := r.start("GetDeviceMetrics")
+	points, err := r.repo.GetDeviceMetrics(ctx, deviceID, timeRange)
+	call.doneWith(len(points), err)
+	return points, err
+}
+
+// GetDeviceMetricsByName returns a device's points of one metric within a
+// time range
+func (r *TelemetryRepository) GetDeviceMetricsByName(ctx context.Context, deviceID string, metricName string, timeRange telemetry.TimeRange) ([]*telemetry.MetricPoint, error) {
+	call := r.start("GetDeviceMetricsByName")
+	points, err := r.repo.GetDeviceMetricsByName(ctx, deviceID, metricName, timeRange)
+	call.doneWith(len(points), err)
+	return points, err
+}
+
+// GetLatestMetrics returns a device's latest metric points
+func (r *TelemetryRepository) GetLatestMetrics(ctx context.Context, deviceID string, limit int) ([]*telemetry.MetricPoint, error) {
+	call := r.start("GetLatestMetrics")
+	points, err := r.repo.GetLatestMetrics(ctx, deviceID, limit)
+	call.doneWith(len(points), err)
+	return points, err
+}
+
+// AggregateMetrics aggregates metric points
+func (r *TelemetryRepository) AggregateMetrics(ctx context.Context, query *telemetry.AggregationQuery) ([]*telemetry.AggregationResult, error) {
+	call := r.start("AggregateMetrics")
+	results, err := r.repo.AggregateMetrics(ctx, query)
+	call.doneWith(len(results), err)
+	return results, err
+}
+
+// CreateThreshold stores a device's alert threshold
+func (r *TelemetryRepository) CreateThreshold(ctx context.Context, deviceID string, threshold *telemetry.AlertThreshold) (string, error) {
+	call := r.start("CreateThreshold")
+	thresholdID, err := r.repo.CreateThreshold(ctx, deviceID, threshold)
+	call.done(err)
+	return thresholdID, err
+}
+
+// GetThreshold returns an alert threshold
+func (r *TelemetryRepository) GetThreshold(ctx context.Context, thresholdID string) (*telemetry.AlertThreshold, error) {
+	call := r.start("GetThreshold")
+	threshold, err := r.repo.GetThreshold(ctx, thresholdID)
+	call.done(err)
+	return threshold, err
+}
+
+// ListThresholds lists a device's alert thresholds
+func (r *TelemetryRepository) ListThresholds(ctx context.Context, deviceID string) ([]*telemetry.AlertThreshold, error) {
+	call := r.start("ListThresholds")
+	thresholds, err := r.repo.ListThresholds(ctx, deviceID)
+	call.doneWith(len(thresholds), err)
+	return thresholds, err
+}
+
+// UpdateThreshold updates an alert threshold
+func (r *TelemetryRepository) UpdateThreshold(ctx context.Context, thresholdID string, threshold *telemetry.AlertThreshold) error {
+	call := r.start("UpdateThreshold")
+	err := r.repo.UpdateThreshold(ctx, thresholdID, threshold)
+	call.done(err)
+	return err
+}
+
+// DeleteThreshold deletes an alert threshold
+func (r *TelemetryRepository) DeleteThreshold(ctx context.Context, thresholdID string) error {
+	call := r.start("DeleteThreshold")
+	err := r.repo.DeleteThreshold(ctx, thresholdID)
+	call.done(err)
+	return err
+}
+
+// ListScopedThresholds lists the thresholds of a template or device
+func (r *TelemetryRepository) ListScopedThresholds(ctx context.Context, scope telemetry.ThresholdScope, scopeID string) ([]*telemetry.ScopedThreshold, error) {
+	call := r.start("ListScopedThresholds")
+	thresholds, err := r.repo.ListScopedThresholds(ctx, scope, scopeID)
+	call.doneWith(len(thresholds), err)
+	return thresholds, err
+}
+
+// SaveScopedThreshold creates or updates a scoped threshold
+func (r *TelemetryRepository) SaveScopedThreshold(ctx context.Context, threshold *telemetry.ScopedThreshold) error {
+	call := r.start("SaveScopedThreshold")
+	err := r.repo.SaveScopedThreshold(ctx, threshold)
+	call.done(err)
+	return err
+}
+
+// CreateAlert stores an alert
+func (r *TelemetryRepository) CreateAlert(ctx context.Context, alert *telemetry.Alert) error {
+	call := r.start("CreateAlert")
+	err := r.repo.CreateAlert(ctx, alert)
+	call.done(err)
+	return err
+}
+
+// GetAlert returns an alert
+func (r *TelemetryRepository) GetAlert(ctx context.Context, alertID string) (*telemetry.Alert, error) {
+	call := r.start("GetAlert")
+	alert, err := r.repo.GetAlert(ctx, alertID)
+	call.done(err)
+	return alert, err
+}
+
+// ListAlerts lists a device's alerts with a status
+func (r *TelemetryRepository) ListAlerts(ctx context.Context, deviceID string, status string) ([]*telemetry.Alert, error) {
+	call := r.start("ListAlerts")
+	alerts, err := r.repo.ListAlerts(ctx, deviceID, status)
+	call.doneWith(len(alerts), err)
+	return alerts, err
+}
+
+// AcknowledgeAlert acknowledges an alert
+func (r *TelemetryRepository) AcknowledgeAlert(ctx context.Context, alertID string) error {
+	call := r.start("AcknowledgeAlert")
+	err := r.repo.AcknowledgeAlert(ctx, alertID)
+	call.done(err)
+	return err
+}
+
+// ResolveAlert resolves an alert
+func (r *TelemetryRepository) ResolveAlert(ctx context.Context, alertID string) error {
+	call := r.start("ResolveAlert")
+	err := r.repo.ResolveAlert(ctx, alertID)
+	call.done(err)
+	return err
+}
+
+// StoreDeviceLogs stores device log lines
+func (r *TelemetryRepository) StoreDeviceLogs(ctx context.Context, entries []*telemetry.DeviceLogEntry) error {
+	call := r.start("StoreDeviceLogs")
+	err := r.repo.StoreDeviceLogs(ctx, entries)
+	call.doneWith(len(entries), err)
+	return err
+}
+
+// QueryDeviceLogs returns the device log lines matching query
+func (r *TelemetryRepository) QueryDeviceLogs(ctx context.Context, query *telemetry.DeviceLogQuery) ([]*telemetry.DeviceLogEntry, error) {
+	call := r.start("QueryDeviceLogs")
+	entries, err := r.repo.QueryDeviceLogs(ctx, query)
+	call.doneWith(len(entries), err)
+	return entries, err
+}
+
+// DeleteOldTelemetry deletes telemetry stored before a time
+func (r *TelemetryRepository) DeleteOldTelemetry(ctx context.Context, before time.Time) (int64, error) {
+	call := r.start("DeleteOldTelemetry")
+	deleted, err := r.repo.DeleteOldTelemetry(ctx, before)
+	call.doneWith(int(deleted), err)
+	return deleted, err
+}
+
+// DeleteOldDeviceLogs deletes device log lines stored before a time
+func (r *TelemetryRepository) DeleteOldDeviceLogs(ctx context.Context, before time.Time) (int64, error) {
+	call := r.start("DeleteOldDeviceLogs")
+	deleted, err := r.repo.DeleteOldDeviceLogs(ctx, before)
+	call.doneWith(int(deleted), err)
+	return deleted, err
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+// TemplateRepository is a template.Repository that records the latency,
+// outcome and entity count of every call to the repository it wraps
+type TemplateRepository struct {
+	repo template.Repository
+	instrumented
+}
+
+// NewTemplateRepository wraps repo with metrics recorded on m
+func NewTemplateRepository(repo template.Repository, m *Metrics) *TemplateRepository {
+	return &TemplateRepository{repo: repo, instrumented: instrumented{metrics: m, repository: "template"}}
+}
+
+// CreateTemplate stores a template version
+func (r *TemplateRepository) CreateTemplate(ctx context.Context, t *template.Template) error {
+	call := r.start("CreateTemplate")
+	err := r.repo.CreateTemplate(ctx, t)
+	call.done(err)
+	return err
+}
+
+// GetTemplate returns a template version
+func (r *TemplateRepository) GetTemplate(ctx context.Context, id, version string) (*template.Template, error) {
+	call := r.start("GetTemplate")
+	t, err := r.repo.GetTemplate(ctx, id, version)
+	call.done(err)
+	return t, err
+}
+
+// UpdateTemplate updates a template version
+func (r *TemplateRepository) UpdateTemplate(ctx context.Context, t *template.Template) error {
+	call := r.start("UpdateTemplate")
+	err := r.repo.UpdateTemplate(ctx, t)
+	call.done(err)
+	return err
+}
+
+// DeleteTemplate deletes a template version
+func (r *TemplateRepository) DeleteTemplate(ctx context.Context, id, version string) error {
+	call := r.start("DeleteTemplate")
+	err := r.repo.DeleteTemplate(ctx, id, version)
+	call.done(err)
+	return err
+}
+
+// ListTemplates lists the templates matching filters
+func (r *TemplateRepository) ListTemplates(ctx context.Context, filters *template.TemplateFilters) ([]*template.Template, error) {
+	call := r.start("ListTemplates")
+	templates, err := r.repo.ListTemplates(ctx, filters)
+	call.doneWith(len(templates), err)
+	return templates, err
+}
+
+// SearchTemplates searches the templates matching filters
+func (r *TemplateRepository) SearchTemplates(ctx context.Context, query string, filters *template.TemplateFilters) ([]*template.Template, error) {
+	call := r.start("SearchTemplates")
+	templates, err := r.repo.SearchTemplates(ctx, query, filters)
+	call.doneWith(len(templates), err)
+	return templates, err
+}
+
+// GetTemplateVersions lists a template's versions
+func (r *TemplateRepository) GetTemplateVersions(ctx context.Context, id string) ([]string, error) {
+	call := r.start("GetTemplateVersions")
+	versions, err := r.repo.GetTemplateVersions(ctx, id)
+	call.doneWith(len(versions), err)
+	return versions, err
+}
+
+// CreateAsset stores an asset of a template version
+func (r *TemplateRepository) CreateAsset(ctx context.Context, templateID, templateVersion string, asset *template.Asset) error {
+	call := r.start("CreateAsset")
+	err := r.repo.CreateAsset(ctx, templateID, templateVersion, asset)
+	call.done(err)
+	return err
+}
+
+// GetAssets lists the assets of a template version
+func (r *TemplateRepository) GetAssets(ctx context.Context, templateID, templateVersion string) ([]*template.Asset, error) {
+	call := r.start("GetAssets")
+	assets, err := r.repo.GetAssets(ctx, templateID, templateVersion)
+	call.doneWith(len(assets), err)
+	return assets, err
+}
+
+// DeleteAsset deletes an asset of a template version
+func (r *TemplateRepository) DeleteAsset(ctx context.Context, templateID, templateVersion, assetType, assetPath string) error {
+	call := r.start("DeleteAsset")
+	err := r.repo.DeleteAsset(ctx, templateID, templateVersion, assetType, assetPath)
+	call.done(err)
+	return err
+}
+
+// TemplateExists reports whether a template version is stored
+func (r *TemplateRepository) TemplateExists(ctx context.Context, id, version string) (bool, error) {
+	call := r.start("TemplateExists")
+	exists, err := r.repo.TemplateExists(ctx, id, version)
+	call.done(err)
+	return exists, err
+}
+
+// GetTemplateCount counts the templates matching filters
+func (r *TemplateRepository) GetTemplateCount(ctx context.Context, filters *template.TemplateFilters) (int64, error) {
+	call := r.start("GetTemplateCount")
+	count, err := r.repo.GetTemplateCount(ctx, filters)
+	call.done(err)
+	return count, err
+}
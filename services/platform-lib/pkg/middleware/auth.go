@@ -158,6 +158,7 @@ func (j *JWTAuth) GenerateTokenPair(userID, username string, roles, permissions
 		Permissions: permissions,
 		TokenType:   "access",
 		SessionID:   accessJti,
+		JTI:         accessJti,
 		Metadata:    metadata,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
@@ -183,6 +184,7 @@ func (j *JWTAuth) GenerateTokenPair(userID, username string, roles, permissions
 		Permissions: permissions,
 		TokenType:   "refresh",
 		SessionID:   sessionID,
+		JTI:         refreshJti,
 		Metadata:    metadata,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
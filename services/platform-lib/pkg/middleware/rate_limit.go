@@ -123,7 +123,7 @@ func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 		if rl.redisClient != nil {
 			allowed, err := rl.checkRedisRateLimit(key)
 			if err != nil {
-				rl.logger.Errorf("Redis rate limit check failed: %v", err)
+				rl.logger.Error("Redis rate limit check failed", "error", err)
 				// Fallback to in-memory rate limiting
 			} else {
 				if !allowed {
@@ -234,8 +234,7 @@ func (rl *RateLimiter) handleRateLimitExceeded(c *gin.Context, key string) {
 
 	// Log rate limit event
 	if rl.logger != nil {
-		rl.logger.Warnf("Rate limit exceeded for key: %s, IP: %s, Path: %s",
-			key, c.ClientIP(), c.Request.URL.Path)
+		rl.logger.Warn("Rate limit exceeded", "key", key, "ip", c.ClientIP(), "path", c.Request.URL.Path)
 	}
 
 	c.JSON(http.StatusTooManyRequests, gin.H{
@@ -325,7 +324,7 @@ func (arl *AdvancedRateLimiter) MultiRateLimit(limiterNames ...string) gin.Handl
 				if limiter.redisClient != nil {
 					allowed, err := limiter.checkRedisRateLimit(key)
 					if err != nil {
-						arl.logger.Errorf("Redis rate limit check failed for %s: %v", name, err)
+						arl.logger.Error("Redis rate limit check failed", "limit", name, "error", err)
 						// Fallback to in-memory rate limiting
 					} else {
 						if !allowed {
@@ -45,13 +45,17 @@ func (vm *ValidationMiddleware) ValidateBody(obj interface{}) gin.HandlerFunc {
 	}
 }
 
-// ValidateQuery validates query parameters
+// ValidateQuery validates query parameters. Parameters absent from the
+// request are optional and are not validated.
 func (vm *ValidationMiddleware) ValidateQuery(rules map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		errors := make(map[string]string)
 
 		for field, rule := range rules {
-			value := c.Query(field)
+			value, ok := c.GetQuery(field)
+			if !ok {
+				continue
+			}
 			if err := vm.validator.ValidateVar(value, rule); err != nil {
 				errors[field] = err.Error()
 			}
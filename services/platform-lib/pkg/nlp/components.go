@@ -0,0 +1,287 @@
+package nlp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+)
+
+// Reasons a requested component gets suggested alternatives
+const (
+	SuggestionUnknownModel = "unknown_model"
+	SuggestionOutOfStock   = "out_of_stock"
+)
+
+// ErrNotAnAlternative is returned when a plan is regenerated with a part
+// that was not suggested for the requested one
+var ErrNotAnAlternative = errors.New("not a suggested alternative")
+
+// ComponentSpec describes a sensor in the component library
+type ComponentSpec struct {
+	Model     string   `json:"model"`
+	Measures  []string `json:"measures"`  // sensor types it covers, e.g. temperature
+	Interface string   `json:"interface"` // "digital", "onewire" or "i2c"
+	Voltage   string   `json:"voltage"`   // supply voltage, e.g. "3.3V" or "3.3V-5V"
+	Price     float64  `json:"price"`
+	InStock   bool     `json:"in_stock"`
+	Notes     string   `json:"notes,omitempty"` // accuracy and range
+}
+
+// measures reports whether the part covers a sensor type
+func (c *ComponentSpec) measures(sensorType string) bool {
+	for _, m := range c.Measures {
+		if strings.EqualFold(m, sensorType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ComponentAlternative is a part that can stand in for a requested one
+type ComponentAlternative struct {
+	Model     string   `json:"model"`
+	Interface string   `json:"interface"`
+	Price     float64  `json:"price"`
+	Tradeoffs []string `json:"tradeoffs"`
+}
+
+// ComponentSuggestion lists alternatives for a requested part that is not
+// in the component library or is out of stock
+type ComponentSuggestion struct {
+	Requested    string                 `json:"requested"`
+	SensorType   string                 `json:"sensor_type"`
+	Reason       string                 `json:"reason"`
+	Alternatives []ComponentAlternative `json:"alternatives"`
+}
+
+// ComponentLibrary holds the parts plans are built from, with their stock
+type ComponentLibrary struct {
+	mu    sync.RWMutex
+	specs map[string]*ComponentSpec
+}
+
+// NewComponentLibrary creates a library of common hobbyist sensors, all in
+// stock
+func NewComponentLibrary() *ComponentLibrary {
+	library := &ComponentLibrary{specs: make(map[string]*ComponentSpec)}
+	for _, spec := range []*ComponentSpec{
+		{Model: "DHT11", Measures: []string{"temperature", "humidity"}, Interface: "digital", Voltage: "3.3V-5V", Price: 3.0, Notes: "±2°C, ±5% RH, 1 reading per second"},
+		{Model: "DHT22", Measures: []string{"temperature", "humidity"}, Interface: "digital", Voltage: "3.3V-5V", Price: 5.0, Notes: "±0.5°C, ±2% RH, 1 reading every 2 seconds"},
+		{Model: "BME280", Measures: []string{"temperature", "humidity", "pressure"}, Interface: "i2c", Voltage: "3.3V", Price: 7.0, Notes: "±1°C, ±3% RH, ±1 hPa"},
+		{Model: "SHT31", Measures: []string{"temperature", "humidity"}, Interface: "i2c", Voltage: "3.3V-5V", Price: 9.0, Notes: "±0.3°C, ±2% RH"},
+		{Model: "DS18B20", Measures: []string{"temperature"}, Interface: "onewire", Voltage: "3.3V-5V", Price: 3.0, Notes: "±0.5°C, waterproof probes available"},
+		{Model: "HC-SR04", Measures: []string{"distance", "ultrasonic"}, Interface: "digital", Voltage: "5V", Price: 2.0, Notes: "2-400 cm, needs trigger and echo pins"},
+		{Model: "VL53L0X", Measures: []string{"distance"}, Interface: "i2c", Voltage: "3.3V", Price: 6.0, Notes: "3-200 cm time of flight, narrow beam"},
+		{Model: "HC-SR501", Measures: []string{"motion", "pir"}, Interface: "digital", Voltage: "5V", Price: 3.0, Notes: "up to 7 m, adjustable delay"},
+		{Model: "AM312", Measures: []string{"motion", "pir"}, Interface: "digital", Voltage: "3.3V-5V", Price: 2.0, Notes: "up to 5 m, fixed 2 second delay"},
+	} {
+		spec.InStock = true
+		library.specs[strings.ToLower(spec.Model)] = spec
+	}
+	return library
+}
+
+// Lookup returns a copy of the part with the given model, if known
+func (l *ComponentLibrary) Lookup(model string) (*ComponentSpec, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	spec, ok := l.specs[strings.ToLower(model)]
+	if !ok {
+		return nil, false
+	}
+	copied := *spec
+	return &copied, true
+}
+
+// Add adds or replaces a part
+func (l *ComponentLibrary) Add(spec ComponentSpec) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.specs[strings.ToLower(spec.Model)] = &spec
+}
+
+// SetInStock records whether a part can be ordered
+func (l *ComponentLibrary) SetInStock(model string, inStock bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	spec, ok := l.specs[strings.ToLower(model)]
+	if !ok {
+		return fmt.Errorf("component %s is not in the library", model)
+	}
+	spec.InStock = inStock
+	return nil
+}
+
+// Suggest returns alternatives for a sensor whose model is not in the
+// library or is out of stock, or nil when the requested part is fine or no
+// model was asked for. Alternatives using the same interface come first,
+// then the cheapest.
+func (l *ComponentLibrary) Suggest(sensor SensorSpec) *ComponentSuggestion {
+	if sensor.Model == "" {
+		return nil
+	}
+	requested, known := l.Lookup(sensor.Model)
+	if known && requested.InStock {
+		return nil
+	}
+
+	suggestion := &ComponentSuggestion{
+		Requested:    sensor.Model,
+		SensorType:   sensor.Type,
+		Reason:       SuggestionUnknownModel,
+		Alternatives: []ComponentAlternative{},
+	}
+	if known {
+		suggestion.Reason = SuggestionOutOfStock
+	}
+
+	l.mu.RLock()
+	candidates := make([]*ComponentSpec, 0, len(l.specs))
+	for _, spec := range l.specs {
+		if !spec.InStock || strings.EqualFold(spec.Model, sensor.Model) {
+			continue
+		}
+		if known {
+			if !coversAll(spec, requested.Measures) {
+				continue
+			}
+		} else if !spec.measures(sensor.Type) {
+			continue
+		}
+		candidates = append(candidates, spec)
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if known {
+			si, sj := candidates[i].Interface == requested.Interface, candidates[j].Interface == requested.Interface
+			if si != sj {
+				return si
+			}
+		}
+		if candidates[i].Price != candidates[j].Price {
+			return candidates[i].Price < candidates[j].Price
+		}
+		return candidates[i].Model < candidates[j].Model
+	})
+
+	for _, spec := range candidates {
+		suggestion.Alternatives = append(suggestion.Alternatives, ComponentAlternative{
+			Model:     spec.Model,
+			Interface: spec.Interface,
+			Price:     spec.Price,
+			Tradeoffs: tradeoffs(requested, spec),
+		})
+	}
+	return suggestion
+}
+
+// Substitute returns a copy of requirements with the requested model replaced
+// by one of the alternatives suggested for it. Plans generated from the copy
+// are wired for the alternative.
+func (l *ComponentLibrary) Substitute(requirements *ParsedRequirements, requested, alternative string) (*ParsedRequirements, error) {
+	substituted := *requirements
+	substituted.Sensors = make([]SensorSpec, len(requirements.Sensors))
+	copy(substituted.Sensors, requirements.Sensors)
+
+	replaced := false
+	for i, sensor := range substituted.Sensors {
+		if !strings.EqualFold(sensor.Model, requested) {
+			continue
+		}
+		suggestion := l.Suggest(sensor)
+		if suggestion == nil || !suggestion.offers(alternative) {
+			return nil, fmt.Errorf("%w: %s for %s", ErrNotAnAlternative, alternative, requested)
+		}
+		spec, _ := l.Lookup(alternative)
+		substituted.Sensors[i].Model = spec.Model
+		replaced = true
+	}
+	if !replaced {
+		return nil, fmt.Errorf("%w: %s is not in the requirements", ErrNotAnAlternative, requested)
+	}
+	return &substituted, nil
+}
+
+// offers reports whether model is one of the suggested alternatives
+func (s *ComponentSuggestion) offers(model string) bool {
+	for _, alt := range s.Alternatives {
+		if strings.EqualFold(alt.Model, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestionWarning describes a suggestion as a plan warning
+func suggestionWarning(suggestion *ComponentSuggestion, messages athenatemplate.Messages) string {
+	models := make([]string, 0, len(suggestion.Alternatives))
+	for _, alt := range suggestion.Alternatives {
+		models = append(models, alt.Model)
+	}
+	if len(models) == 0 {
+		if suggestion.Reason == SuggestionOutOfStock {
+			return messages.Translate("plan.component_out_of_stock_no_alternative", "%s is out of stock and no alternative is available", suggestion.Requested)
+		}
+		return messages.Translate("plan.component_unknown_no_alternative", "%s is not in the component library and no alternative is available", suggestion.Requested)
+	}
+	if suggestion.Reason == SuggestionOutOfStock {
+		return messages.Translate("plan.component_out_of_stock", "%s is out of stock; alternatives: %s", suggestion.Requested, strings.Join(models, ", "))
+	}
+	return messages.Translate("plan.component_unknown", "%s is not in the component library; alternatives: %s", suggestion.Requested, strings.Join(models, ", "))
+}
+
+// coversAll reports whether a part measures everything in sensorTypes
+func coversAll(spec *ComponentSpec, sensorTypes []string) bool {
+	for _, sensorType := range sensorTypes {
+		if !spec.measures(sensorType) {
+			return false
+		}
+	}
+	return true
+}
+
+// tradeoffs describes how an alternative differs from the requested part,
+// which is nil when it is not in the library
+func tradeoffs(requested, alternative *ComponentSpec) []string {
+	var notes []string
+	if alternative.Notes != "" {
+		notes = append(notes, alternative.Notes)
+	}
+	if requested == nil {
+		return notes
+	}
+
+	if diff := alternative.Price - requested.Price; diff > 0 {
+		notes = append(notes, fmt.Sprintf("$%.2f more than the %s", diff, requested.Model))
+	} else if diff < 0 {
+		notes = append(notes, fmt.Sprintf("$%.2f less than the %s", -diff, requested.Model))
+	}
+	if alternative.Interface != requested.Interface {
+		notes = append(notes, fmt.Sprintf("uses %s instead of %s wiring; the wiring diagram changes", interfaceName(alternative.Interface), interfaceName(requested.Interface)))
+	}
+	if alternative.Voltage != requested.Voltage {
+		notes = append(notes, fmt.Sprintf("runs on %s rather than %s", alternative.Voltage, requested.Voltage))
+	}
+	for _, m := range alternative.Measures {
+		if !requested.measures(m) {
+			notes = append(notes, "also measures "+m)
+		}
+	}
+	return notes
+}
+
+func interfaceName(iface string) string {
+	switch iface {
+	case "i2c":
+		return "I2C"
+	case "onewire":
+		return "1-Wire"
+	default:
+		return "single-pin " + iface
+	}
+}
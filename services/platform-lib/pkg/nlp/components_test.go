@@ -0,0 +1,72 @@
+package nlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponentLibrary_Suggest(t *testing.T) {
+	library := NewComponentLibrary()
+
+	assert.Nil(t, library.Suggest(SensorSpec{Type: "temperature", Model: "DHT22"}))
+	assert.Nil(t, library.Suggest(SensorSpec{Type: "temperature"}))
+
+	require.NoError(t, library.SetInStock("DHT22", false))
+	suggestion := library.Suggest(SensorSpec{Type: "temperature", Model: "DHT22"})
+	require.NotNil(t, suggestion)
+	assert.Equal(t, SuggestionOutOfStock, suggestion.Reason)
+
+	models := []string{}
+	for _, alt := range suggestion.Alternatives {
+		models = append(models, alt.Model)
+	}
+	// Same interface first, then by price; DS18B20 does not measure humidity
+	assert.Equal(t, []string{"DHT11", "BME280", "SHT31"}, models)
+	assert.Contains(t, suggestion.Alternatives[1].Tradeoffs, "$2.00 more than the DHT22")
+	assert.Contains(t, suggestion.Alternatives[1].Tradeoffs, "uses I2C instead of single-pin digital wiring; the wiring diagram changes")
+	assert.Contains(t, suggestion.Alternatives[1].Tradeoffs, "also measures pressure")
+
+	unknown := library.Suggest(SensorSpec{Type: "distance", Model: "LIDAR-X9"})
+	require.NotNil(t, unknown)
+	assert.Equal(t, SuggestionUnknownModel, unknown.Reason)
+	require.Len(t, unknown.Alternatives, 2)
+	assert.Equal(t, "HC-SR04", unknown.Alternatives[0].Model)
+
+	assert.Error(t, library.SetInStock("LIDAR-X9", false))
+}
+
+func TestService_GenerateAlternativePlan(t *testing.T) {
+	service, err := NewService(nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	template := &TemplateInfo{ID: "sensing", Name: "Sensing"}
+	requirements := &ParsedRequirements{Sensors: []SensorSpec{{Type: "temperature", Model: "DHT22"}}}
+	require.NoError(t, service.Components().SetInStock("DHT22", false))
+
+	plan, err := service.GeneratePlan(ctx, requirements, template, map[string]interface{}{}, "arduino:avr:uno")
+	require.NoError(t, err)
+	require.Len(t, plan.Suggestions, 1)
+	assert.Contains(t, plan.Warnings, "DHT22 is out of stock; alternatives: DHT11, BME280, SHT31")
+
+	_, err = service.GenerateAlternativePlan(ctx, requirements, template, map[string]interface{}{}, "arduino:avr:uno", "", "DHT22", "DS18B20")
+	assert.ErrorIs(t, err, ErrNotAnAlternative)
+
+	alternative, err := service.GenerateAlternativePlan(ctx, requirements, template, map[string]interface{}{}, "arduino:avr:uno", "", "DHT22", "bme280")
+	require.NoError(t, err)
+	assert.Empty(t, alternative.Suggestions)
+	assert.Equal(t, "DHT22", requirements.Sensors[0].Model, "the original requirements are unchanged")
+
+	wired := map[string]string{}
+	for _, conn := range alternative.WiringDiagram.Connections {
+		if conn.ToComponent == "sensor_0" {
+			wired[conn.ToPin] = conn.FromPin
+		}
+	}
+	assert.Equal(t, map[string]string{"SDA": "A4", "SCL": "A5", "VCC": "3.3V", "GND": "GND"}, wired)
+	assert.Equal(t, "BME280", alternative.BOM[1].Component)
+	assert.Equal(t, 7.0, alternative.BOM[1].Price)
+	assert.Contains(t, alternative.Instructions, "Connect temperature sensor to the I2C bus (SDA to A4, SCL to A5, VCC to 3.3V, GND to GND)")
+}
@@ -0,0 +1,127 @@
+package nlp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PlanCurrency is the currency of BOM prices and plan cost estimates
+const PlanCurrency = "USD"
+
+// Build time allowances, in minutes
+const (
+	baseBuildMinutes          = 20 // placing the board, uploading and testing
+	componentBuildMinutes     = 10 // per sensor or actuator
+	communicationBuildMinutes = 20 // per network or radio module
+	connectionBuildMinutes    = 2  // per wire
+)
+
+// PlanEstimate summarises what building a plan takes, so plan variants can
+// be compared. Difficulty scores of 5 and under are beginner projects, up
+// to 10 intermediate and above that advanced.
+type PlanEstimate struct {
+	TotalCost       float64  `json:"total_cost"`
+	Currency        string   `json:"currency"`
+	BuildMinutes    int      `json:"build_minutes"`
+	DifficultyScore int      `json:"difficulty_score"`
+	DifficultyLevel string   `json:"difficulty_level"`
+	Factors         []string `json:"factors,omitempty"`
+}
+
+// estimatePlan estimates the cost, build time and difficulty of a plan from
+// its BOM and wiring
+func (pg *PlanGenerator) estimatePlan(requirements *ParsedRequirements, diagram *WiringDiagram, bom []BOMItem) *PlanEstimate {
+	components := len(requirements.Sensors) + len(requirements.Actuators)
+	modules := len(requirements.Communication)
+	connections := len(diagram.Connections)
+
+	estimate := &PlanEstimate{
+		TotalCost: math.Round(pg.calculateTotalCost(bom)*100) / 100,
+		Currency:  PlanCurrency,
+		BuildMinutes: baseBuildMinutes +
+			components*componentBuildMinutes +
+			modules*communicationBuildMinutes +
+			connections*connectionBuildMinutes,
+	}
+
+	score := components + modules*2 + connections/3
+	if components > 0 {
+		estimate.Factors = append(estimate.Factors, fmt.Sprintf("%d components", components))
+	}
+	if modules > 0 {
+		estimate.Factors = append(estimate.Factors, fmt.Sprintf("%d communication modules", modules))
+	}
+	if connections > 0 {
+		estimate.Factors = append(estimate.Factors, fmt.Sprintf("%d connections", connections))
+	}
+
+	// Signals beyond plain digital I/O take more care to wire
+	signals, voltages := wiringSignals(diagram)
+	score += len(signals)
+	for _, signal := range signals {
+		estimate.Factors = append(estimate.Factors, signal+" signals")
+	}
+	if len(voltages) > 1 {
+		score++
+		estimate.Factors = append(estimate.Factors, "mixed supply voltages")
+	}
+
+	estimate.DifficultyScore = score
+	estimate.DifficultyLevel = difficultyLevel(score)
+	return estimate
+}
+
+// wiringSignals returns the non-digital signal types and the supply voltages
+// the diagram's components use, sorted
+func wiringSignals(diagram *WiringDiagram) (signals, voltages []string) {
+	seenSignals := make(map[string]bool)
+	seenVoltages := make(map[string]bool)
+	for _, component := range diagram.Components {
+		for _, pin := range component.Pins {
+			switch pin.Type {
+			case "pwm", "analog", "i2c", "spi", "serial":
+				if !seenSignals[pin.Type] {
+					seenSignals[pin.Type] = true
+					signals = append(signals, pin.Type)
+				}
+			case "power":
+				if pin.Voltage != "" && !seenVoltages[pin.Voltage] {
+					seenVoltages[pin.Voltage] = true
+					voltages = append(voltages, pin.Voltage)
+				}
+			}
+		}
+	}
+	sort.Strings(signals)
+	sort.Strings(voltages)
+	return signals, voltages
+}
+
+func difficultyLevel(score int) string {
+	if score <= 5 {
+		return "beginner"
+	} else if score <= 10 {
+		return "intermediate"
+	}
+	return "advanced"
+}
+
+// RankPlans orders plan variants from the most to the least feasible: by
+// difficulty score, then build time, then cost. Plans without an estimate
+// sort last.
+func RankPlans(plans []*ImplementationPlan) {
+	sort.SliceStable(plans, func(i, j int) bool {
+		a, b := plans[i].Estimate, plans[j].Estimate
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		case a.DifficultyScore != b.DifficultyScore:
+			return a.DifficultyScore < b.DifficultyScore
+		case a.BuildMinutes != b.BuildMinutes:
+			return a.BuildMinutes < b.BuildMinutes
+		default:
+			return a.TotalCost < b.TotalCost
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package nlp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanGenerator_Estimate(t *testing.T) {
+	pg := NewPlanGenerator(nil)
+	template := &TemplateInfo{ID: "sensing", Name: "Sensing"}
+	ctx := context.Background()
+
+	simple, err := pg.GeneratePlan(ctx, &ParsedRequirements{
+		Sensors: []SensorSpec{{Type: "temperature", Model: "DHT22"}},
+	}, template, map[string]interface{}{}, "arduino:avr:uno")
+	require.NoError(t, err)
+	require.NotNil(t, simple.Estimate)
+
+	// Uno, DHT22 at its library price, breadboard, jumper wires and USB cable
+	assert.Equal(t, 41.0, simple.Estimate.TotalCost)
+	assert.Equal(t, PlanCurrency, simple.Estimate.Currency)
+	assert.Equal(t, 36, simple.Estimate.BuildMinutes)
+	assert.Equal(t, 2, simple.Estimate.DifficultyScore)
+	assert.Equal(t, "beginner", simple.DifficultyLevel)
+	assert.Equal(t, simple.Estimate.TotalCost, simple.EstimatedCost)
+
+	connected, err := pg.GeneratePlan(ctx, &ParsedRequirements{
+		Sensors:       []SensorSpec{{Type: "temperature"}},
+		Actuators:     []ActuatorSpec{{Type: "servo"}, {Type: "led"}, {Type: "led"}},
+		Communication: []CommSpec{{Protocol: "wifi"}},
+	}, template, map[string]interface{}{}, "esp32:esp32:esp32")
+	require.NoError(t, err)
+
+	// 4 components, wifi counting double, 10 wires and a PWM signal
+	assert.Equal(t, 10, connected.Estimate.DifficultyScore)
+	assert.Equal(t, "intermediate", connected.Estimate.DifficultyLevel)
+	assert.Equal(t, 100, connected.Estimate.BuildMinutes)
+	assert.Contains(t, connected.Estimate.Factors, "pwm signals")
+
+	plans := []*ImplementationPlan{connected, {TemplateID: "unestimated"}, simple}
+	RankPlans(plans)
+	assert.Equal(t, []*ImplementationPlan{simple, connected, plans[2]}, plans)
+	assert.Equal(t, "unestimated", plans[2].TemplateID)
+}
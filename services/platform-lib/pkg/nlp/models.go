@@ -61,6 +61,9 @@ type ImplementationPlan struct {
 	SafetyChecks    *SafetyValidation      `json:"safety_checks"`
 	EstimatedCost   float64                `json:"estimated_cost,omitempty"`
 	DifficultyLevel string                 `json:"difficulty_level"`
+	Estimate        *PlanEstimate          `json:"estimate,omitempty"`
+	Suggestions     []ComponentSuggestion  `json:"suggestions,omitempty"`
+	Locale          string                 `json:"locale,omitempty"`
 	CreatedAt       time.Time              `json:"created_at"`
 }
 
@@ -121,6 +124,76 @@ type SafetyValidation struct {
 	PinCompatibility []PinCompatibilityCheck `json:"pin_compatibility"`
 }
 
+// SafetyCheckRequest asks for the safety report of a wiring diagram, such
+// as a plan's diagram modified by the user, on a board
+type SafetyCheckRequest struct {
+	Board         string         `json:"board" binding:"required"`
+	WiringDiagram *WiringDiagram `json:"wiring_diagram" binding:"required"`
+}
+
+// PinMove rewires a component of a wiring diagram to another board pin.
+// ComponentPin names the component's pin to move and may be left out for
+// components with a single signal pin.
+type PinMove struct {
+	Component    string `json:"component" binding:"required"`
+	ComponentPin string `json:"component_pin,omitempty"`
+	BoardPin     string `json:"board_pin" binding:"required"`
+}
+
+// WiringEditRequest moves pins in the wiring diagram of a generated plan.
+// Requirements are those the plan was generated from.
+type WiringEditRequest struct {
+	Board        string              `json:"board" binding:"required"`
+	Requirements *ParsedRequirements `json:"requirements" binding:"required"`
+	Plan         *ImplementationPlan `json:"plan" binding:"required"`
+	Moves        []PinMove           `json:"moves" binding:"required,min=1,dive"`
+}
+
+// WiringEdit is a plan regenerated after moving pins. Parameters are the
+// pin parameters the moves changed, which the generated code's pin
+// constants are rendered from; SVG is the new diagram as an image.
+type WiringEdit struct {
+	Plan       *ImplementationPlan    `json:"plan"`
+	Parameters map[string]interface{} `json:"parameters"`
+	SVG        string                 `json:"svg"`
+}
+
+// PlanRequest asks for an implementation plan for a project described in
+// natural language. Without a locale the request's Accept-Language header
+// picks the template's locale pack.
+type PlanRequest struct {
+	Request string `json:"request" binding:"required"`
+	Context string `json:"context,omitempty"`
+	Board   string `json:"board,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+}
+
+// PinAssignmentRequest asks for board pins for the sensors and actuators
+// of a project. Pins set on the requirements or in parameters are kept.
+type PinAssignmentRequest struct {
+	Board        string                 `json:"board" binding:"required"`
+	Requirements *ParsedRequirements    `json:"requirements" binding:"required"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// PinChoice is the board pin of one component. Requires is the capability
+// the component needs of the pin; Fixed choices were made by the user.
+type PinChoice struct {
+	Component string `json:"component"`
+	Parameter string `json:"parameter"`
+	Requires  string `json:"requires"`
+	BoardPin  string `json:"board_pin"`
+	Fixed     bool   `json:"fixed,omitempty"`
+}
+
+// PinAssignment gives every component of a project its own board pin.
+// Parameters are the template parameters with every pin parameter set.
+type PinAssignment struct {
+	Parameters    map[string]interface{} `json:"parameters"`
+	Pins          []PinChoice            `json:"pins"`
+	WiringDiagram *WiringDiagram         `json:"wiring_diagram,omitempty"`
+}
+
 // VoltageCheck represents a voltage compatibility check
 type VoltageCheck struct {
 	Component       string `json:"component"`
@@ -0,0 +1,323 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoPinAssignment is returned when a board has too few suitable free pins
+// for the components of a project
+var ErrNoPinAssignment = errors.New("no conflict-free pin assignment")
+
+// maxPinComponents caps the sensors and actuators of one solve. No board
+// has more pins than this.
+const maxPinComponents = 64
+
+// Pin capabilities a component can require
+const (
+	pinDigital   = "digital"
+	pinPWM       = "pwm"
+	pinAnalog    = "analog"
+	pinInterrupt = "interrupt"
+)
+
+// PinSolver assigns board pins to the sensors and actuators of a project.
+// Every component gets a pin of its own with the capability its type
+// requires, and pins with more capabilities are left for the components
+// that need them.
+type PinSolver struct {
+	validator  *SafetyValidator
+	components *ComponentLibrary
+}
+
+// NewPinSolver creates a pin solver using the board specifications of a
+// safety validator and the interfaces of library components
+func NewPinSolver(validator *SafetyValidator, components *ComponentLibrary) *PinSolver {
+	return &PinSolver{
+		validator:  validator,
+		components: components,
+	}
+}
+
+// requiredPinCapability returns the capability a component type needs of
+// the board pin it is wired to
+func requiredPinCapability(componentType string) string {
+	componentType = strings.ToLower(componentType)
+	switch {
+	case strings.Contains(componentType, "servo"):
+		return pinPWM
+	case strings.Contains(componentType, "analog") || strings.Contains(componentType, "potentiometer"):
+		return pinAnalog
+	case strings.Contains(componentType, "motion") || strings.Contains(componentType, "pir") ||
+		strings.Contains(componentType, "button") || strings.Contains(componentType, "encoder") ||
+		strings.Contains(componentType, "flow"):
+		return pinInterrupt
+	}
+	return pinDigital
+}
+
+// Solve assigns a pin to every sensor and actuator without one. Pins set on
+// the requirements or as string parameters are kept. I2C sensors share the
+// board's bus, whose pins are then kept free of other components.
+func (ps *PinSolver) Solve(requirements *ParsedRequirements, parameters map[string]interface{}, boardType string) (*PinAssignment, error) {
+	if count := len(requirements.Sensors) + len(requirements.Actuators); count > maxPinComponents {
+		return nil, fmt.Errorf("%w: %d components exceed the limit of %d", ErrNoPinAssignment, count, maxPinComponents)
+	}
+
+	spec, _ := ps.validator.boardSpec(boardType)
+	assignment := &PinAssignment{
+		Parameters: make(map[string]interface{}, len(parameters)),
+		Pins:       []PinChoice{},
+	}
+	for key, value := range parameters {
+		assignment.Parameters[key] = value
+	}
+
+	used := make(map[string]bool)
+	for _, pin := range spec.ReservedPins {
+		used[pin] = true
+	}
+	var open []int
+	add := func(componentID, componentType string, index int, pin string) {
+		choice := PinChoice{
+			Component: componentID,
+			Parameter: pinParameter(componentType, index),
+			Requires:  requiredPinCapability(componentType),
+		}
+		if pin == "" {
+			pin, _ = parameters[choice.Parameter].(string)
+		}
+		if pin == "" {
+			open = append(open, len(assignment.Pins))
+		} else {
+			choice.BoardPin = pin
+			choice.Fixed = true
+			used[strings.ToUpper(pin)] = true
+			assignment.Parameters[choice.Parameter] = pin
+		}
+		assignment.Pins = append(assignment.Pins, choice)
+	}
+
+	usesI2C := false
+	for i, sensor := range requirements.Sensors {
+		if component, known := ps.components.Lookup(sensor.Model); known && component.Interface == "i2c" {
+			usesI2C = true
+			continue
+		}
+		add(fmt.Sprintf("sensor_%d", i), sensor.Type, i, sensor.Pin)
+	}
+	for i, actuator := range requirements.Actuators {
+		add(fmt.Sprintf("actuator_%d", i), actuator.Type, i, actuator.Pin)
+	}
+	if usesI2C {
+		sda, scl := i2cPins(boardType)
+		used[sda] = true
+		used[scl] = true
+	}
+
+	// Free candidates only, most constrained components first
+	candidates := make(map[int][]string, len(open))
+	demand := make(map[string]int)
+	for _, i := range open {
+		requires := assignment.Pins[i].Requires
+		for _, pin := range ps.candidatePins(spec, boardType, requires) {
+			if !used[pin] {
+				candidates[i] = append(candidates[i], pin)
+			}
+		}
+		demand[requires]++
+	}
+	sort.SliceStable(open, func(a, b int) bool {
+		return len(candidates[open[a]]) < len(candidates[open[b]])
+	})
+
+	// A capability with fewer free pins than components needing it cannot
+	// be matched; report it without searching
+	for _, i := range open {
+		if requires := assignment.Pins[i].Requires; len(candidates[i]) < demand[requires] {
+			return nil, fmt.Errorf("%w: %s has %d free %s pins for %d components", ErrNoPinAssignment, spec.Name, len(candidates[i]), requires, demand[requires])
+		}
+	}
+	if !matchPins(assignment.Pins, open, candidates) {
+		return nil, fmt.Errorf("%w: %s has too few free pins for %s", ErrNoPinAssignment, spec.Name, describeDemand(assignment.Pins, open))
+	}
+
+	for _, i := range open {
+		assignment.Parameters[assignment.Pins[i].Parameter] = assignment.Pins[i].BoardPin
+	}
+	return assignment, nil
+}
+
+// candidatePins lists the board pins with a capability, those with the
+// fewest other capabilities first
+func (ps *PinSolver) candidatePins(spec *BoardSpecification, boardType, capability string) []string {
+	var pins []string
+	switch capability {
+	case pinPWM:
+		pins = slices.Clone(spec.PWMPins)
+	case pinAnalog:
+		pins = slices.Clone(spec.AnalogPins)
+	case pinInterrupt:
+		pins = slices.Clone(spec.InterruptPins)
+	default:
+		pins = slices.Clone(spec.DigitalPins)
+		for _, pin := range spec.PWMPins {
+			if !slices.Contains(pins, pin) {
+				pins = append(pins, pin)
+			}
+		}
+	}
+
+	sda, scl := i2cPins(boardType)
+	capabilities := func(pin string) int {
+		count := 0
+		for _, has := range []bool{
+			slices.Contains(spec.PWMPins, pin),
+			slices.Contains(spec.AnalogPins, pin),
+			slices.Contains(spec.InterruptPins, pin),
+			pin == sda || pin == scl,
+		} {
+			if has {
+				count++
+			}
+		}
+		return count
+	}
+	sort.SliceStable(pins, func(i, j int) bool {
+		return capabilities(pins[i]) < capabilities(pins[j])
+	})
+	return pins
+}
+
+// matchPins gives each open choice one of its candidate pins by
+// Hopcroft-Karp maximum bipartite matching, which takes polynomial time
+// even when no assignment exists. Candidates are tried in order, so
+// choices that do not compete for pins get their preferred one. It reports
+// whether every choice got a pin.
+func matchPins(choices []PinChoice, open []int, candidates map[int][]string) bool {
+	const unreached = -1
+	matched := make([]string, len(open)) // choice -> pin
+	owner := make(map[string]int)        // pin -> choice
+	dist := make([]int, len(open))
+
+	// layer searches breadth first from the unmatched choices, reporting
+	// whether an augmenting path exists
+	layer := func() bool {
+		var queue []int
+		for u := range open {
+			dist[u] = unreached
+			if matched[u] == "" {
+				dist[u] = 0
+				queue = append(queue, u)
+			}
+		}
+		found := false
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			for _, pin := range candidates[open[u]] {
+				v, taken := owner[pin]
+				switch {
+				case !taken:
+					found = true
+				case dist[v] == unreached:
+					dist[v] = dist[u] + 1
+					queue = append(queue, v)
+				}
+			}
+		}
+		return found
+	}
+
+	// augment follows the layers from choice u to a free pin
+	var augment func(u int) bool
+	augment = func(u int) bool {
+		for _, pin := range candidates[open[u]] {
+			v, taken := owner[pin]
+			if !taken || (dist[v] == dist[u]+1 && augment(v)) {
+				owner[pin] = u
+				matched[u] = pin
+				return true
+			}
+		}
+		dist[u] = unreached
+		return false
+	}
+
+	size := 0
+	for layer() {
+		for u := range open {
+			if matched[u] == "" && augment(u) {
+				size++
+			}
+		}
+	}
+	for u, i := range open {
+		choices[i].BoardPin = matched[u]
+	}
+	return size == len(open)
+}
+
+// describeDemand counts the open choices by the capability they require,
+// e.g. "2 pwm, 1 digital"
+func describeDemand(choices []PinChoice, open []int) string {
+	counts := make(map[string]int)
+	var order []string
+	for _, i := range open {
+		requires := choices[i].Requires
+		if counts[requires] == 0 {
+			order = append(order, requires)
+		}
+		counts[requires]++
+	}
+	parts := make([]string, len(order))
+	for i, requires := range order {
+		parts[i] = fmt.Sprintf("%d %s", counts[requires], requires)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AssignPins solves the board pins of a project's components and wires
+// them, without generating a full plan
+func (s *Service) AssignPins(ctx context.Context, requirements *ParsedRequirements, parameters map[string]interface{}, boardType string) (*PinAssignment, error) {
+	if requirements == nil {
+		return nil, fmt.Errorf("requirements cannot be empty")
+	}
+	assignment, err := s.planGenerator.pinSolver.Solve(requirements, parameters, boardType)
+	if err != nil {
+		return nil, err
+	}
+	diagram, err := s.planGenerator.generateWiringDiagram(ctx, requirements, nil, assignment.Parameters, boardType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate wiring diagram: %w", err)
+	}
+	assignment.WiringDiagram = diagram
+	return assignment, nil
+}
+
+// assignPinsHandler returns pins for a project's components and the wiring
+// diagram they give
+func (s *Service) assignPinsHandler(c *gin.Context) {
+	var req PinAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	assignment, err := s.AssignPins(c.Request.Context(), req.Requirements, req.Parameters, req.Board)
+	switch {
+	case errors.Is(err, ErrNoPinAssignment):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, assignment)
+	}
+}
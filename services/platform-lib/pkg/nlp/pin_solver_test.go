@@ -0,0 +1,188 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinSolver_Solve(t *testing.T) {
+	solver := NewPinSolver(NewSafetyValidator(), NewComponentLibrary())
+
+	requirements := &ParsedRequirements{
+		Sensors: []SensorSpec{
+			{Type: "temperature"},
+			{Type: "motion"},
+			{Type: "potentiometer"},
+			{Type: "temperature", Model: "BME280"},
+		},
+		Actuators: []ActuatorSpec{{Type: "servo"}, {Type: "led", Pin: "D13"}, {Type: "relay"}},
+	}
+	assignment, err := solver.Solve(requirements, map[string]interface{}{"relay_pin_3": "D8", "ssid": "lab"}, "uno")
+	require.NoError(t, err)
+
+	pins := map[string]PinChoice{}
+	for _, choice := range assignment.Pins {
+		pins[choice.Component] = choice
+	}
+	assert.Equal(t, PinChoice{Component: "sensor_0", Parameter: "temperature_pin", Requires: "digital", BoardPin: "D4"}, pins["sensor_0"])
+	assert.Equal(t, "D2", pins["sensor_1"].BoardPin)
+	assert.Equal(t, "interrupt", pins["sensor_1"].Requires)
+	assert.Equal(t, "A0", pins["sensor_2"].BoardPin)
+	assert.NotContains(t, pins, "sensor_3", "I2C sensors use the bus")
+	assert.Equal(t, "D5", pins["actuator_0"].BoardPin)
+	assert.Equal(t, PinChoice{Component: "actuator_1", Parameter: "led_pin_2", Requires: "digital", BoardPin: "D13", Fixed: true}, pins["actuator_1"])
+	assert.True(t, pins["actuator_2"].Fixed)
+
+	assert.Equal(t, map[string]interface{}{
+		"temperature_pin":     "D4",
+		"motion_pin_2":        "D2",
+		"potentiometer_pin_3": "A0",
+		"servo_pin":           "D5",
+		"led_pin_2":           "D13",
+		"relay_pin_3":         "D8",
+		"ssid":                "lab",
+	}, assignment.Parameters)
+
+	// An Uno has two interrupt pins
+	_, err = solver.Solve(&ParsedRequirements{Sensors: []SensorSpec{{Type: "pir"}, {Type: "pir"}, {Type: "pir"}}}, nil, "uno")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+	assert.Contains(t, err.Error(), "2 free interrupt pins for 3 components")
+
+	// Enough interrupt and PWM pins each, but D3 is both
+	_, err = solver.Solve(&ParsedRequirements{
+		Sensors:   []SensorSpec{{Type: "pir"}, {Type: "pir"}},
+		Actuators: []ActuatorSpec{{Type: "servo"}, {Type: "servo"}, {Type: "servo"}, {Type: "servo"}, {Type: "servo"}, {Type: "servo"}},
+	}, nil, "uno")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+	assert.Contains(t, err.Error(), "2 interrupt, 6 pwm")
+
+	// Servos are kept off the PWM pins that also take interrupts
+	assignment, err = solver.Solve(&ParsedRequirements{
+		Sensors:   []SensorSpec{{Type: "pir", Pin: "D2"}, {Type: "button"}},
+		Actuators: []ActuatorSpec{{Type: "servo"}},
+	}, nil, "uno")
+	require.NoError(t, err)
+	assert.Equal(t, "D3", assignment.Parameters["button_pin_2"])
+	assert.Equal(t, "D5", assignment.Parameters["servo_pin"])
+}
+
+func TestPinSolver_Infeasible(t *testing.T) {
+	solver := NewPinSolver(NewSafetyValidator(), NewComponentLibrary())
+	leds := func(n int) *ParsedRequirements {
+		requirements := &ParsedRequirements{Actuators: make([]ActuatorSpec, n)}
+		for i := range requirements.Actuators {
+			requirements.Actuators[i] = ActuatorSpec{Type: "led"}
+		}
+		return requirements
+	}
+
+	_, err := solver.Solve(leds(12), nil, "uno")
+	require.NoError(t, err)
+
+	// One LED more than the free pins fails at once rather than after
+	// trying every permutation
+	start := time.Now()
+	_, err = solver.Solve(leds(13), nil, "uno")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// So does a mix that passes the count for each capability
+	mixed := leds(11)
+	mixed.Sensors = []SensorSpec{{Type: "button"}, {Type: "button"}}
+	start = time.Now()
+	_, err = solver.Solve(mixed, nil, "uno")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = solver.Solve(leds(maxPinComponents+1), nil, "esp32")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+	assert.Contains(t, err.Error(), "exceed the limit")
+}
+
+func TestPinSolver_AvoidsI2CBus(t *testing.T) {
+	solver := NewPinSolver(NewSafetyValidator(), NewComponentLibrary())
+	analog := make([]SensorSpec, 4)
+	for i := range analog {
+		analog[i] = SensorSpec{Type: "potentiometer"}
+	}
+
+	assignment, err := solver.Solve(&ParsedRequirements{Sensors: analog}, nil, "uno")
+	require.NoError(t, err)
+	assert.Equal(t, "A3", assignment.Parameters["potentiometer_pin_4"])
+
+	// With an I2C sensor, A4 and A5 are the bus
+	analog = append(analog, SensorSpec{Type: "potentiometer"}, SensorSpec{Type: "temperature", Model: "BME280"})
+	_, err = solver.Solve(&ParsedRequirements{Sensors: analog}, nil, "uno")
+	assert.ErrorIs(t, err, ErrNoPinAssignment)
+}
+
+func TestService_GeneratePlan_AssignsPins(t *testing.T) {
+	service, err := NewService(nil)
+	require.NoError(t, err)
+
+	requirements := &ParsedRequirements{
+		Sensors:   []SensorSpec{{Type: "temperature"}, {Type: "humidity"}},
+		Actuators: []ActuatorSpec{{Type: "led"}, {Type: "led"}},
+	}
+	plan, err := service.GeneratePlan(context.Background(), requirements, &TemplateInfo{ID: "climate", Name: "Climate"}, map[string]interface{}{}, "uno")
+	require.NoError(t, err)
+
+	seen := map[string]string{}
+	for _, choice := range []struct{ component, pin, parameter string }{
+		{"sensor_0", "OUT", "temperature_pin"},
+		{"sensor_1", "OUT", "humidity_pin_2"},
+		{"actuator_0", "ANODE", "led_pin"},
+		{"actuator_1", "ANODE", "led_pin_2"},
+	} {
+		pin := plan.WiringDiagram.boardPin(choice.component, choice.pin)
+		require.NotEmpty(t, pin)
+		assert.NotContains(t, seen, pin, "%s shares %s with %s", choice.component, pin, seen[pin])
+		seen[pin] = choice.component
+		assert.Equal(t, pin, plan.Parameters[choice.parameter])
+	}
+	for _, message := range plan.SafetyChecks.Errors {
+		assert.NotContains(t, message, "assigned to multiple components")
+	}
+}
+
+func TestService_AssignPinsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(nil)
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(req PinAssignmentRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r, _ := http.NewRequest(http.MethodPost, "/api/v1/nlp/pin-assignment", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(PinAssignmentRequest{Board: "esp32", Requirements: &ParsedRequirements{Actuators: []ActuatorSpec{{Type: "servo"}}}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var assignment PinAssignment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &assignment))
+	require.Len(t, assignment.Pins, 1)
+	assert.Equal(t, assignment.Pins[0].BoardPin, assignment.Parameters["servo_pin"])
+	require.NotNil(t, assignment.WiringDiagram)
+	assert.Equal(t, assignment.Pins[0].BoardPin, assignment.WiringDiagram.boardPin("actuator_0", "IN"))
+
+	servos := make([]ActuatorSpec, 7)
+	for i := range servos {
+		servos[i] = ActuatorSpec{Type: "servo"}
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity, send(PinAssignmentRequest{Board: "uno", Requirements: &ParsedRequirements{Actuators: servos}}).Code)
+	assert.Equal(t, http.StatusBadRequest, send(PinAssignmentRequest{Board: "uno"}).Code)
+}
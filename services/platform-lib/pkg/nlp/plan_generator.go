@@ -5,28 +5,53 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
 )
 
 // PlanGenerator generates implementation plans
 type PlanGenerator struct {
 	llmClient       *LLMClient
 	safetyValidator *SafetyValidator
+	components      *ComponentLibrary
+	pinSolver       *PinSolver
 }
 
 // NewPlanGenerator creates a new plan generator
 func NewPlanGenerator(llmClient *LLMClient) *PlanGenerator {
+	safetyValidator := NewSafetyValidator()
+	components := NewComponentLibrary()
 	return &PlanGenerator{
 		llmClient:       llmClient,
-		safetyValidator: NewSafetyValidator(),
+		safetyValidator: safetyValidator,
+		components:      components,
+		pinSolver:       NewPinSolver(safetyValidator, components),
 	}
 }
 
 // GeneratePlan generates a complete implementation plan
 func (pg *PlanGenerator) GeneratePlan(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, parameters map[string]interface{}, boardType string) (*ImplementationPlan, error) {
+	return pg.GenerateLocalizedPlan(ctx, requirements, template, parameters, boardType, "")
+}
+
+// GenerateLocalizedPlan generates a complete implementation plan, translating
+// instructions and plan warnings with the template's pack for locale. Without
+// a matching pack the plan is in English.
+func (pg *PlanGenerator) GenerateLocalizedPlan(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, parameters map[string]interface{}, boardType string, locale string) (*ImplementationPlan, error) {
+	locale, messages := athenatemplate.ResolveLocale(template.LocalePacks, locale)
+
+	// Assign the pins the requirements and parameters leave open
+	pins, err := pg.pinSolver.Solve(requirements, parameters, boardType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign pins: %w", err)
+	}
+	parameters = pins.Parameters
+
 	plan := &ImplementationPlan{
 		TemplateID:   template.ID,
 		TemplateName: template.Name,
 		Parameters:   parameters,
+		Locale:       locale,
 		CreatedAt:    time.Now(),
 	}
 
@@ -37,6 +62,16 @@ func (pg *PlanGenerator) GeneratePlan(ctx context.Context, requirements *ParsedR
 	}
 	plan.WiringDiagram = wiringDiagram
 
+	// Suggest alternatives for unknown or out of stock parts
+	for _, sensor := range requirements.Sensors {
+		suggestion := pg.components.Suggest(sensor)
+		if suggestion == nil {
+			continue
+		}
+		plan.Suggestions = append(plan.Suggestions, *suggestion)
+		plan.Warnings = append(plan.Warnings, suggestionWarning(suggestion, messages))
+	}
+
 	// Perform safety validation
 	safetyChecks, err := pg.safetyValidator.ValidateSafety(ctx, plan, boardType)
 	if err != nil {
@@ -47,7 +82,7 @@ func (pg *PlanGenerator) GeneratePlan(ctx context.Context, requirements *ParsedR
 	// Add safety warnings to plan warnings
 	plan.Warnings = append(plan.Warnings, safetyChecks.Warnings...)
 	if !safetyChecks.Valid {
-		plan.Warnings = append(plan.Warnings, "SAFETY ERRORS DETECTED - Review and fix before proceeding")
+		plan.Warnings = append(plan.Warnings, messages.Translate("plan.safety_errors", "SAFETY ERRORS DETECTED - Review and fix before proceeding"))
 		plan.Warnings = append(plan.Warnings, safetyChecks.Errors...)
 	}
 
@@ -58,18 +93,17 @@ func (pg *PlanGenerator) GeneratePlan(ctx context.Context, requirements *ParsedR
 	}
 	plan.BOM = bom
 
-	// Calculate estimated cost
-	plan.EstimatedCost = pg.calculateTotalCost(bom)
-
 	// Generate step-by-step instructions
-	instructions, err := pg.generateInstructions(ctx, requirements, template, wiringDiagram, parameters)
+	instructions, err := pg.generateInstructions(ctx, requirements, template, wiringDiagram, parameters, messages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate instructions: %w", err)
 	}
 	plan.Instructions = instructions
 
-	// Determine difficulty level
-	plan.DifficultyLevel = pg.determineDifficultyLevel(requirements, wiringDiagram)
+	// Estimate cost, build time and difficulty
+	plan.Estimate = pg.estimatePlan(requirements, wiringDiagram, bom)
+	plan.EstimatedCost = plan.Estimate.TotalCost
+	plan.DifficultyLevel = plan.Estimate.DifficultyLevel
 
 	return plan, nil
 }
@@ -92,53 +126,7 @@ func (pg *PlanGenerator) generateWiringDiagram(ctx context.Context, requirements
 
 	// Add sensors
 	for i, sensor := range requirements.Sensors {
-		componentID := fmt.Sprintf("sensor_%d", i)
-		component := Component{
-			ID:   componentID,
-			Type: sensor.Type,
-			Name: fmt.Sprintf("%s Sensor", strings.Title(sensor.Type)),
-			Pins: []Pin{
-				{Number: "VCC", Name: "Power", Type: "power", Voltage: "5V"},
-				{Number: "GND", Name: "Ground", Type: "ground"},
-				{Number: "OUT", Name: "Signal", Type: "digital"},
-			},
-		}
-
-		if sensor.Model != "" {
-			component.Name = sensor.Model
-		}
-
-		diagram.Components = append(diagram.Components, component)
-
-		// Add connections
-		pin := sensor.Pin
-		if pin == "" {
-			pin = pg.assignPin(sensor.Type, i, parameters)
-		}
-
-		diagram.Connections = append(diagram.Connections, Connection{
-			FromComponent: "board",
-			FromPin:       pin,
-			ToComponent:   componentID,
-			ToPin:         "OUT",
-			WireColor:     "yellow",
-		})
-
-		diagram.Connections = append(diagram.Connections, Connection{
-			FromComponent: "board",
-			FromPin:       "5V",
-			ToComponent:   componentID,
-			ToPin:         "VCC",
-			WireColor:     "red",
-		})
-
-		diagram.Connections = append(diagram.Connections, Connection{
-			FromComponent: "board",
-			FromPin:       "GND",
-			ToComponent:   componentID,
-			ToPin:         "GND",
-			WireColor:     "black",
-		})
+		pg.wireSensor(diagram, i, sensor, parameters, boardType)
 	}
 
 	// Add actuators
@@ -225,6 +213,88 @@ func (pg *PlanGenerator) generateWiringDiagram(ctx context.Context, requirements
 	return diagram, nil
 }
 
+// wireSensor adds a sensor and its connections to a diagram. Parts in the
+// component library are wired for their interface and supply voltage; other
+// sensors get a single signal pin on 5V.
+func (pg *PlanGenerator) wireSensor(diagram *WiringDiagram, index int, sensor SensorSpec, parameters map[string]interface{}, boardType string) {
+	componentID := fmt.Sprintf("sensor_%d", index)
+	component := Component{
+		ID:   componentID,
+		Type: sensor.Type,
+		Name: fmt.Sprintf("%s Sensor", strings.Title(sensor.Type)),
+	}
+	if sensor.Model != "" {
+		component.Name = sensor.Model
+	}
+
+	supply := "5V"
+	spec, known := pg.components.Lookup(sensor.Model)
+	if known {
+		if spec.Voltage == "3.3V" {
+			supply = "3.3V"
+		}
+		component.Metadata = map[string]interface{}{"interface": spec.Interface}
+	}
+	component.Pins = []Pin{
+		{Number: "VCC", Name: "Power", Type: "power", Voltage: supply},
+		{Number: "GND", Name: "Ground", Type: "ground"},
+	}
+
+	if known && spec.Interface == "i2c" {
+		sda, scl := i2cPins(boardType)
+		component.Pins = append(component.Pins,
+			Pin{Number: "SDA", Name: "I2C Data", Type: "i2c"},
+			Pin{Number: "SCL", Name: "I2C Clock", Type: "i2c"},
+		)
+		diagram.Connections = append(diagram.Connections,
+			Connection{FromComponent: "board", FromPin: sda, ToComponent: componentID, ToPin: "SDA", WireColor: "green"},
+			Connection{FromComponent: "board", FromPin: scl, ToComponent: componentID, ToPin: "SCL", WireColor: "blue"},
+		)
+	} else {
+		pin := sensor.Pin
+		if pin == "" {
+			pin = pg.assignPin(sensor.Type, index, parameters)
+		}
+		component.Pins = append(component.Pins, Pin{Number: "OUT", Name: "Signal", Type: "digital"})
+		diagram.Connections = append(diagram.Connections, Connection{
+			FromComponent: "board",
+			FromPin:       pin,
+			ToComponent:   componentID,
+			ToPin:         "OUT",
+			WireColor:     "yellow",
+		})
+	}
+
+	diagram.Components = append(diagram.Components, component)
+	diagram.Connections = append(diagram.Connections,
+		Connection{FromComponent: "board", FromPin: supply, ToComponent: componentID, ToPin: "VCC", WireColor: "red"},
+		Connection{FromComponent: "board", FromPin: "GND", ToComponent: componentID, ToPin: "GND", WireColor: "black"},
+	)
+}
+
+// boardPin returns the board pin wired to a component's pin, if any
+func (d *WiringDiagram) boardPin(componentID, pin string) string {
+	for _, conn := range d.Connections {
+		if conn.FromComponent == "board" && conn.ToComponent == componentID && conn.ToPin == pin {
+			return conn.FromPin
+		}
+	}
+	return ""
+}
+
+// i2cPins returns a board's I2C data and clock pins
+func i2cPins(boardType string) (sda, scl string) {
+	board := strings.ToLower(boardType)
+	switch {
+	case strings.Contains(board, "esp32"):
+		return "D21", "D22"
+	case strings.Contains(board, "esp8266"):
+		return "D2", "D1"
+	default:
+		return "A4", "A5"
+	}
+}
+
 // generateBOM generates a bill of materials
 func (pg *PlanGenerator) generateBOM(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, diagram *WiringDiagram, boardType string) ([]BOMItem, error) {
 	bom := []BOMItem{}
@@ -249,6 +319,9 @@ func (pg *PlanGenerator) generateBOM(ctx context.Context, requirements *ParsedRe
 			item.Component = sensor.Model
 			item.Description = fmt.Sprintf("%s %s sensor", sensor.Model, sensor.Type)
 		}
+		if spec, ok := pg.components.Lookup(sensor.Model); ok {
+			item.Price = spec.Price
+		}
 		bom = append(bom, item)
 	}
 
@@ -305,23 +378,30 @@ func (pg *PlanGenerator) generateBOM(ctx context.Context, requirements *ParsedRe
 	return bom, nil
 }
 
-// generateInstructions generates step-by-step assembly instructions
-func (pg *PlanGenerator) generateInstructions(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, diagram *WiringDiagram, parameters map[string]interface{}) ([]string, error) {
+// generateInstructions generates step-by-step assembly instructions. Each
+// step has a message key so locale packs can translate it.
+func (pg *PlanGenerator) generateInstructions(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, diagram *WiringDiagram, parameters map[string]interface{}, messages athenatemplate.Messages) ([]string, error) {
 	instructions := []string{}
 
 	// Step 1: Gather components
-	instructions = append(instructions, "Gather all components from the bill of materials")
+	instructions = append(instructions, messages.Translate("plan.gather_components", "Gather all components from the bill of materials"))
 
 	// Step 2: Board setup
-	instructions = append(instructions, "Place the Arduino board on the breadboard or work surface")
+	instructions = append(instructions, messages.Translate("plan.place_board", "Place the Arduino board on the breadboard or work surface"))
 
 	// Step 3: Connect sensors
 	for i, sensor := range requirements.Sensors {
+		componentID := fmt.Sprintf("sensor_%d", i)
+		if sda := diagram.boardPin(componentID, "SDA"); sda != "" {
+			instructions = append(instructions, messages.Translate("plan.connect_i2c_sensor", "Connect %s sensor to the I2C bus (SDA to %s, SCL to %s, VCC to %s, GND to GND)",
+				sensor.Type, sda, diagram.boardPin(componentID, "SCL"), diagram.boardPin(componentID, "VCC")))
+			continue
+		}
 		pin := sensor.Pin
 		if pin == "" {
 			pin = pg.assignPin(sensor.Type, i, parameters)
 		}
-		instructions = append(instructions, fmt.Sprintf("Connect %s sensor to pin %s (VCC to 5V, GND to GND)", sensor.Type, pin))
+		instructions = append(instructions, messages.Translate("plan.connect_sensor", "Connect %s sensor to pin %s (VCC to 5V, GND to GND)", sensor.Type, pin))
 	}
 
 	// Step 4: Connect actuators
@@ -331,39 +411,43 @@ func (pg *PlanGenerator) generateInstructions(ctx context.Context, requirements
 			pin = pg.assignPin(actuator.Type, i, parameters)
 		}
 		if actuator.Type == "led" {
-			instructions = append(instructions, fmt.Sprintf("Connect LED to pin %s through a 220Ω resistor (cathode to GND)", pin))
+			instructions = append(instructions, messages.Translate("plan.connect_led", "Connect LED to pin %s through a 220Ω resistor (cathode to GND)", pin))
 		} else {
-			instructions = append(instructions, fmt.Sprintf("Connect %s to pin %s (VCC to 5V, GND to GND)", actuator.Type, pin))
+			instructions = append(instructions, messages.Translate("plan.connect_actuator", "Connect %s to pin %s (VCC to 5V, GND to GND)", actuator.Type, pin))
 		}
 	}
 
 	// Step 5: Double-check connections
-	instructions = append(instructions, "Double-check all connections against the wiring diagram")
+	instructions = append(instructions, messages.Translate("plan.check_connections", "Double-check all connections against the wiring diagram"))
 
 	// Step 6: Upload code
-	instructions = append(instructions, "Connect Arduino to computer via USB cable")
-	instructions = append(instructions, "Open Arduino IDE and upload the generated code")
+	instructions = append(instructions, messages.Translate("plan.connect_usb", "Connect Arduino to computer via USB cable"))
+	instructions = append(instructions, messages.Translate("plan.upload_code", "Open Arduino IDE and upload the generated code"))
 
 	// Step 7: Test
-	instructions = append(instructions, "Open Serial Monitor at 9600 baud to view output")
-	instructions = append(instructions, "Verify that sensors are reading correctly and actuators are responding")
+	instructions = append(instructions, messages.Translate("plan.open_serial_monitor", "Open Serial Monitor at 9600 baud to view output"))
+	instructions = append(instructions, messages.Translate("plan.verify_operation", "Verify that sensors are reading correctly and actuators are responding"))
 
 	// Step 8: Troubleshooting
-	instructions = append(instructions, "If issues occur, check power connections and verify pin assignments match the code")
+	instructions = append(instructions, messages.Translate("plan.troubleshoot", "If issues occur, check power connections and verify pin assignments match the code"))
 
 	return instructions, nil
 }
 
 // Helper methods
 
-func (pg *PlanGenerator) assignPin(componentType string, index int, parameters map[string]interface{}) string {
-	// Try to get pin from parameters
-	pinKey := fmt.Sprintf("%s_pin", componentType)
+// pinParameter names the template parameter holding the pin of the index'th
+// component of a type
+func pinParameter(componentType string, index int) string {
 	if index > 0 {
-		pinKey = fmt.Sprintf("%s_pin_%d", componentType, index+1)
+		return fmt.Sprintf("%s_pin_%d", componentType, index+1)
 	}
+	return fmt.Sprintf("%s_pin", componentType)
+}
 
-	if pin, ok := parameters[pinKey]; ok {
+func (pg *PlanGenerator) assignPin(componentType string, index int, parameters map[string]interface{}) string {
+	// Try to get pin from parameters
+	if pin, ok := parameters[pinParameter(componentType, index)]; ok {
 		if pinStr, ok := pin.(string); ok {
 			return pinStr
 		}
@@ -433,25 +517,6 @@ func (pg *PlanGenerator) calculateTotalCost(bom []BOMItem) float64 {
 	return total
 }
 
-func (pg *PlanGenerator) determineDifficultyLevel(requirements *ParsedRequirements, diagram *WiringDiagram) string {
-	score := 0
-
-	// Count components
-	score += len(requirements.Sensors)
-	score += len(requirements.Actuators)
-	score += len(requirements.Communication) * 2
-
-	// Count connections
-	score += len(diagram.Connections) / 3
-
-	if score <= 5 {
-		return "beginner"
-	} else if score <= 10 {
-		return "intermediate"
-	}
-	return "advanced"
-}
-
 func (pg *PlanGenerator) generateMermaidSyntax(diagram *WiringDiagram) string {
 	var sb strings.Builder
 
@@ -0,0 +1,204 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoTemplates is returned when plans are requested without a template
+// directory
+var ErrNoTemplates = errors.New("no template directory configured")
+
+// TemplateDirectory lists the templates plans are generated from
+type TemplateDirectory interface {
+	ListTemplates(ctx context.Context, filters *athenatemplate.TemplateFilters) ([]*athenatemplate.Template, error)
+}
+
+// SetTemplateDirectory sets where plan templates and their locale packs
+// are read from
+func (s *Service) SetTemplateDirectory(directory TemplateDirectory) {
+	s.templates = directory
+}
+
+// NewTemplateInfo describes a template for matching and planning, with its
+// locale packs
+func NewTemplateInfo(tmpl *athenatemplate.Template) (*TemplateInfo, error) {
+	packs, err := athenatemplate.LocalePacks(tmpl.Assets)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", tmpl.ID, err)
+	}
+	libraries := make([]string, len(tmpl.Libraries))
+	for i, library := range tmpl.Libraries {
+		libraries[i] = library.Name
+	}
+	return &TemplateInfo{
+		ID:              tmpl.ID,
+		Name:            tmpl.Name,
+		Category:        tmpl.Category,
+		Description:     tmpl.Description,
+		BoardsSupported: tmpl.BoardsSupported,
+		Libraries:       libraries,
+		LocalePacks:     packs,
+	}, nil
+}
+
+// latestTemplates returns the newest version of every template in the
+// directory
+func (s *Service) latestTemplates(ctx context.Context) ([]*athenatemplate.Template, error) {
+	if s.templates == nil {
+		return nil, ErrNoTemplates
+	}
+	templates, err := s.templates.ListTemplates(ctx, &athenatemplate.TemplateFilters{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	// Templates are listed newest first
+	seen := make(map[string]bool)
+	var latest []*athenatemplate.Template
+	for _, tmpl := range templates {
+		if !seen[tmpl.ID] {
+			seen[tmpl.ID] = true
+			latest = append(latest, tmpl)
+		}
+	}
+	return latest, nil
+}
+
+// templateInfo returns a plan's template with its locale packs. Without a
+// directory, or once the template is gone, only its ID and name are known.
+func (s *Service) templateInfo(ctx context.Context, id, name string) (*TemplateInfo, error) {
+	if s.templates == nil {
+		return &TemplateInfo{ID: id, Name: name}, nil
+	}
+	templates, err := s.latestTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, tmpl := range templates {
+		if tmpl.ID == id {
+			return NewTemplateInfo(tmpl)
+		}
+	}
+	return &TemplateInfo{ID: id, Name: name}, nil
+}
+
+// Plan parses a project description, selects the best matching template,
+// fills its parameters and generates a plan in locale
+func (s *Service) Plan(ctx context.Context, req *PlanRequest) (*ImplementationPlan, error) {
+	input := req.Request
+	if req.Context != "" {
+		input += "\n\nContext: " + req.Context
+	}
+	requirements, err := s.ParseRequirements(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.latestTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]TemplateInfo, 0, len(templates))
+	for _, tmpl := range templates {
+		info, err := NewTemplateInfo(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, *info)
+	}
+
+	selected, err := s.SelectTemplate(ctx, requirements, infos)
+	if err != nil {
+		return nil, err
+	}
+	var template *TemplateInfo
+	var schema map[string]interface{}
+	for i, tmpl := range templates {
+		if tmpl.ID == selected.TemplateID {
+			template, schema = &infos[i], tmpl.Schema
+			break
+		}
+	}
+
+	parameters, err := s.FillTemplateParameters(ctx, requirements, template, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	board := req.Board
+	if board == "" {
+		board = requirements.BoardPreference
+	}
+	if board == "" && len(template.BoardsSupported) > 0 {
+		board = template.BoardsSupported[0]
+	}
+	return s.GenerateLocalizedPlan(ctx, requirements, template, parameters, board, req.Locale)
+}
+
+// preferredLocale returns the highest weighted language of an
+// Accept-Language header, or "" when none is acceptable
+func preferredLocale(header string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			languages = append(languages, weighted{locale, q})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+	if len(languages) == 0 {
+		return ""
+	}
+	return languages[0].locale
+}
+
+// planHandler generates a plan from a project description. The plan's
+// locale is the one used, or empty when the template has no matching pack.
+func (s *Service) planHandler(c *gin.Context) {
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Locale == "" {
+		req.Locale = preferredLocale(c.GetHeader("Accept-Language"))
+	}
+
+	plan, err := s.Plan(c.Request.Context(), &req)
+	switch {
+	case errors.Is(err, ErrNoTemplates):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrNoPinAssignment):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, plan)
+	}
+}
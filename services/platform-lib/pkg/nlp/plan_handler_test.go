@@ -0,0 +1,116 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPlanService returns a service whose LLM parses every request into
+// one temperature sensor and whose directory holds a sensing template with
+// a German locale pack
+func newPlanService(t *testing.T) *Service {
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requirements := `{"intent": "sensing", "sensors": [{"type": "temperature"}], "board_preference": "uno"}`
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": requirements}}},
+		})
+	}))
+	t.Cleanup(llm.Close)
+
+	service, err := NewService(&ServiceConfig{LLMEndpoint: llm.URL, LLMTimeout: 5})
+	require.NoError(t, err)
+
+	templates := athenatemplate.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(context.Background(), &athenatemplate.Template{
+		ID:              "climate",
+		Name:            "Climate",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"uno"},
+		Assets: []athenatemplate.Asset{{
+			Type:     athenatemplate.LocaleAssetType,
+			Path:     "locales/de.json",
+			Metadata: map[string]interface{}{"messages": map[string]interface{}{"plan.gather_components": "Alle Bauteile bereitlegen"}},
+		}},
+	}))
+	service.SetTemplateDirectory(templates)
+	return service
+}
+
+func TestPreferredLocale(t *testing.T) {
+	assert.Equal(t, "de-DE", preferredLocale("de-DE,de;q=0.9,en;q=0.8"))
+	assert.Equal(t, "fr", preferredLocale("en;q=0.5, fr"))
+	assert.Equal(t, "", preferredLocale("*"))
+	assert.Equal(t, "", preferredLocale(""))
+}
+
+func TestService_PlanHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, newPlanService(t))
+
+	send := func(req PlanRequest, acceptLanguage string) *ImplementationPlan {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/nlp/plan", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if acceptLanguage != "" {
+			r.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var plan ImplementationPlan
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+		return &plan
+	}
+
+	plan := send(PlanRequest{Request: "monitor the greenhouse temperature"}, "de-DE,en;q=0.5")
+	assert.Equal(t, "climate", plan.TemplateID)
+	assert.Equal(t, "de", plan.Locale)
+	assert.Contains(t, plan.Instructions, "Alle Bauteile bereitlegen")
+
+	// An explicit locale wins over the header
+	plan = send(PlanRequest{Request: "monitor the greenhouse temperature", Locale: "en"}, "de")
+	assert.Empty(t, plan.Locale)
+	assert.Contains(t, plan.Instructions, "Gather all components from the bill of materials")
+}
+
+func TestService_PlanWithoutTemplates(t *testing.T) {
+	service := newPlanService(t)
+	service.SetTemplateDirectory(nil)
+
+	_, err := service.Plan(context.Background(), &PlanRequest{Request: "monitor the greenhouse temperature"})
+	assert.ErrorIs(t, err, ErrNoTemplates)
+}
+
+func TestService_EditWiringHandler_KeepsLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := newPlanService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	plan, err := service.Plan(context.Background(), &PlanRequest{Request: "monitor the greenhouse temperature", Locale: "de"})
+	require.NoError(t, err)
+	requirements := &ParsedRequirements{Sensors: []SensorSpec{{Type: "temperature"}}}
+
+	body, _ := json.Marshal(WiringEditRequest{Board: "uno", Requirements: requirements, Plan: plan, Moves: []PinMove{{Component: "sensor_0", BoardPin: "D7"}}})
+	r := httptest.NewRequest(http.MethodPatch, "/api/v1/nlp/wiring-diagram", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var edit WiringEdit
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &edit))
+	assert.Equal(t, "de", edit.Plan.Locale)
+	assert.Contains(t, edit.Plan.Instructions, "Alle Bauteile bereitlegen")
+}
@@ -21,6 +21,8 @@ type BoardSpecification struct {
 	DigitalPins      []string
 	AnalogPins       []string
 	PWMPins          []string
+	InterruptPins    []string          // pins with external interrupts
+	ReservedPins     []string          // pins the board itself uses, e.g. for USB serial
 	PowerPins        map[string]string // pin -> voltage
 }
 
@@ -43,10 +45,9 @@ func (sv *SafetyValidator) ValidateSafety(ctx context.Context, plan *Implementat
 	}
 
 	// Get board specifications
-	boardSpec, exists := sv.boardSpecs[strings.ToLower(boardType)]
+	boardSpec, exists := sv.boardSpec(boardType)
 	if !exists {
 		validation.Warnings = append(validation.Warnings, fmt.Sprintf("Board specifications not found for %s, using generic validation", boardType))
-		boardSpec = sv.getGenericBoardSpec()
 	}
 
 	// Validate voltage compatibility
@@ -195,8 +196,8 @@ func (sv *SafetyValidator) validatePinConflicts(plan *ImplementationPlan, valida
 	pinUsage := make(map[string][]string)
 
 	for _, connection := range plan.WiringDiagram.Connections {
-		// Skip power and ground pins
-		if sv.isPowerPin(connection.FromPin) {
+		// Skip power and ground pins, and the I2C bus, which devices share
+		if sv.isPowerPin(connection.FromPin) || connection.ToPin == "SDA" || connection.ToPin == "SCL" {
 			continue
 		}
 
@@ -305,6 +306,9 @@ func (sv *SafetyValidator) findComponent(components []Component, id string) *Com
 }
 
 func (sv *SafetyValidator) getRequiredPinType(component *Component) string {
+	if iface, ok := component.Metadata["interface"].(string); ok && iface == "i2c" {
+		return "i2c"
+	}
 	componentType := strings.ToLower(component.Type)
 
 	switch {
@@ -358,7 +362,8 @@ func (sv *SafetyValidator) isPowerPin(pin string) bool {
 func (sv *SafetyValidator) hasMultipleI2CDevices(components []Component) bool {
 	count := 0
 	for _, component := range components {
-		if strings.Contains(strings.ToLower(component.Type), "i2c") {
+		iface, _ := component.Metadata["interface"].(string)
+		if iface == "i2c" || strings.Contains(strings.ToLower(component.Type), "i2c") {
 			count++
 		}
 	}
@@ -384,6 +389,15 @@ func (sv *SafetyValidator) contains(slice []string, item string) bool {
 	return false
 }
 
+// boardSpec returns the specifications of a board, or those of a generic
+// Arduino if the board is unknown
+func (sv *SafetyValidator) boardSpec(boardType string) (*BoardSpecification, bool) {
+	if spec, exists := sv.boardSpecs[strings.ToLower(boardType)]; exists {
+		return spec, true
+	}
+	return sv.getGenericBoardSpec(), false
+}
+
 func (sv *SafetyValidator) getGenericBoardSpec() *BoardSpecification {
 	return &BoardSpecification{
 		Name:             "Generic Arduino",
@@ -394,6 +408,8 @@ func (sv *SafetyValidator) getGenericBoardSpec() *BoardSpecification {
 		DigitalPins:      []string{"D0", "D1", "D2", "D3", "D4", "D5", "D6", "D7", "D8", "D9", "D10", "D11", "D12", "D13"},
 		AnalogPins:       []string{"A0", "A1", "A2", "A3", "A4", "A5"},
 		PWMPins:          []string{"D3", "D5", "D6", "D9", "D10", "D11"},
+		InterruptPins:    []string{"D2", "D3"},
+		ReservedPins:     []string{"D0", "D1"},
 		PowerPins:        map[string]string{"5V": "5V", "3.3V": "3.3V", "GND": "0V"},
 	}
 }
@@ -412,6 +428,8 @@ func initializeBoardSpecs() map[string]*BoardSpecification {
 		DigitalPins:      []string{"D0", "D1", "D2", "D3", "D4", "D5", "D6", "D7", "D8", "D9", "D10", "D11", "D12", "D13"},
 		AnalogPins:       []string{"A0", "A1", "A2", "A3", "A4", "A5"},
 		PWMPins:          []string{"D3", "D5", "D6", "D9", "D10", "D11"},
+		InterruptPins:    []string{"D2", "D3"},
+		ReservedPins:     []string{"D0", "D1"},
 		PowerPins:        map[string]string{"5V": "5V", "3.3V": "3.3V", "GND": "0V"},
 	}
 
@@ -428,6 +446,8 @@ func initializeBoardSpecs() map[string]*BoardSpecification {
 		DigitalPins:      []string{"D0", "D1", "D2", "D3", "D4", "D5", "D12", "D13", "D14", "D15", "D16", "D17", "D18", "D19", "D21", "D22", "D23", "D25", "D26", "D27", "D32", "D33"},
 		AnalogPins:       []string{"A0", "A3", "A4", "A5", "A6", "A7", "A10", "A11", "A12", "A13", "A14", "A15"},
 		PWMPins:          []string{"D2", "D4", "D5", "D12", "D13", "D14", "D15", "D16", "D17", "D18", "D19", "D21", "D22", "D23", "D25", "D26", "D27", "D32", "D33"},
+		InterruptPins:    []string{"D2", "D4", "D5", "D12", "D13", "D14", "D15", "D16", "D17", "D18", "D19", "D21", "D22", "D23", "D25", "D26", "D27", "D32", "D33"},
+		ReservedPins:     []string{"D0", "D1", "D3"},
 		PowerPins:        map[string]string{"3.3V": "3.3V", "GND": "0V"},
 	}
 
@@ -441,6 +461,7 @@ func initializeBoardSpecs() map[string]*BoardSpecification {
 		DigitalPins:      []string{"D0", "D1", "D2", "D3", "D4", "D5", "D6", "D7", "D8"},
 		AnalogPins:       []string{"A0"},
 		PWMPins:          []string{"D1", "D2", "D3", "D4", "D5", "D6", "D7", "D8"},
+		InterruptPins:    []string{"D1", "D2", "D3", "D4", "D5", "D6", "D7", "D8"},
 		PowerPins:        map[string]string{"3.3V": "3.3V", "GND": "0V"},
 	}
 
@@ -2,9 +2,16 @@ package nlp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
 )
 
+// ErrInvalidDiagram is returned for wiring diagrams that cannot be checked
+var ErrInvalidDiagram = errors.New("invalid wiring diagram")
+
 // Service represents the NLP planner service
 type Service struct {
 	llmClient       *LLMClient
@@ -13,6 +20,7 @@ type Service struct {
 	parameterFiller *ParameterFiller
 	planGenerator   *PlanGenerator
 	safetyValidator *SafetyValidator
+	templates       TemplateDirectory
 }
 
 // ServiceConfig represents NLP service configuration
@@ -73,6 +81,17 @@ func NewService(config *ServiceConfig) (*Service, error) {
 	}, nil
 }
 
+// RegisterRoutes registers the NLP service HTTP routes
+func RegisterRoutes(router *gin.Engine, service *Service) {
+	v1 := router.Group("/api/v1/nlp")
+	{
+		v1.POST("/plan", service.planHandler)
+		v1.POST("/safety-check", service.checkSafetyHandler)
+		v1.PATCH("/wiring-diagram", service.editWiringHandler)
+		v1.POST("/pin-assignment", service.assignPinsHandler)
+	}
+}
+
 // ParseRequirements parses natural language input and extracts requirements
 func (s *Service) ParseRequirements(ctx context.Context, input string) (*ParsedRequirements, error) {
 	if input == "" {
@@ -140,7 +159,13 @@ func (s *Service) ValidateTemplateParameters(ctx context.Context, parameters map
 
 // GeneratePlan generates a complete implementation plan
 func (s *Service) GeneratePlan(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, parameters map[string]interface{}, boardType string) (*ImplementationPlan, error) {
-	plan, err := s.planGenerator.GeneratePlan(ctx, requirements, template, parameters, boardType)
+	return s.GenerateLocalizedPlan(ctx, requirements, template, parameters, boardType, "")
+}
+
+// GenerateLocalizedPlan generates an implementation plan with instructions
+// translated by the template's locale pack for the given locale
+func (s *Service) GenerateLocalizedPlan(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, parameters map[string]interface{}, boardType string, locale string) (*ImplementationPlan, error) {
+	plan, err := s.planGenerator.GenerateLocalizedPlan(ctx, requirements, template, parameters, boardType, locale)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate plan: %w", err)
 	}
@@ -148,6 +173,23 @@ func (s *Service) GeneratePlan(ctx context.Context, requirements *ParsedRequirem
 	return plan, nil
 }
 
+// Components returns the component library plans are built from, so stock
+// can be updated
+func (s *Service) Components() *ComponentLibrary {
+	return s.planGenerator.components
+}
+
+// GenerateAlternativePlan regenerates a plan with a suggested alternative in
+// place of the requested part. Wiring, BOM and instructions follow the
+// alternative's interface and supply voltage.
+func (s *Service) GenerateAlternativePlan(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, parameters map[string]interface{}, boardType, locale, requested, alternative string) (*ImplementationPlan, error) {
+	substituted, err := s.planGenerator.components.Substitute(requirements, requested, alternative)
+	if err != nil {
+		return nil, err
+	}
+	return s.GenerateLocalizedPlan(ctx, substituted, template, parameters, boardType, locale)
+}
+
 // ValidatePlan validates an implementation plan for safety
 func (s *Service) ValidatePlan(ctx context.Context, plan *ImplementationPlan, boardType string) (*ValidationResult, error) {
 	safetyValidation, err := s.safetyValidator.ValidateSafety(ctx, plan, boardType)
@@ -163,3 +205,43 @@ func (s *Service) ValidatePlan(ctx context.Context, plan *ImplementationPlan, bo
 
 	return result, nil
 }
+
+// CheckSafety validates a wiring diagram on a board without generating a
+// plan. Every connection must lead to a component of the diagram, since
+// the checks would skip it otherwise.
+func (s *Service) CheckSafety(ctx context.Context, diagram *WiringDiagram, boardType string) (*SafetyValidation, error) {
+	if diagram == nil {
+		return nil, fmt.Errorf("%w: no wiring diagram", ErrInvalidDiagram)
+	}
+	for _, connection := range diagram.Connections {
+		if s.safetyValidator.findComponent(diagram.Components, connection.ToComponent) == nil {
+			return nil, fmt.Errorf("%w: connection from %s to unknown component %q", ErrInvalidDiagram, connection.FromPin, connection.ToComponent)
+		}
+	}
+
+	validation, err := s.safetyValidator.ValidateSafety(ctx, &ImplementationPlan{WiringDiagram: diagram}, boardType)
+	if err != nil {
+		return nil, fmt.Errorf("safety validation failed: %w", err)
+	}
+	return validation, nil
+}
+
+// checkSafetyHandler returns the full safety report of a wiring diagram.
+// Unsafe diagrams are reported with valid false, not as errors.
+func (s *Service) checkSafetyHandler(c *gin.Context) {
+	var req SafetyCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	validation, err := s.CheckSafety(c.Request.Context(), req.WiringDiagram, req.Board)
+	switch {
+	case errors.Is(err, ErrInvalidDiagram):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, validation)
+	}
+}
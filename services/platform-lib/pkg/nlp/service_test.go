@@ -0,0 +1,51 @@
+package nlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_CheckSafetyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(nil)
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	check := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/nlp/safety-check", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := check(`{"board":"uno","wiring_diagram":{
+		"components":[{"id":"led1","type":"led","name":"LED"},{"id":"relay1","type":"relay","name":"Relay"}],
+		"connections":[
+			{"from_component":"board","from_pin":"D13","to_component":"led1","to_pin":"ANODE"},
+			{"from_component":"board","from_pin":"D13","to_component":"relay1","to_pin":"IN"}]}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report SafetyValidation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(t, report.Valid)
+	assert.NotEmpty(t, report.CurrentChecks)
+	assert.Contains(t, report.Errors, "Pin D13 is assigned to multiple components: [LED Relay]")
+
+	w = check(`{"board":"esp32","wiring_diagram":{"components":[{"id":"led1","type":"led","name":"LED"}],
+		"connections":[{"from_component":"board","from_pin":"D13","to_component":"led1","to_pin":"ANODE"}]}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.Valid, report.Errors)
+
+	assert.Equal(t, http.StatusBadRequest, check(`{"board":"uno"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, check(`{"board":"uno","wiring_diagram":{"components":[],
+		"connections":[{"from_component":"board","from_pin":"D13","to_component":"missing","to_pin":"IN"}]}}`).Code)
+}
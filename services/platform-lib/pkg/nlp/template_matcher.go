@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
 )
 
 // TemplateMatcher handles template selection based on requirements
@@ -329,4 +331,7 @@ type TemplateInfo struct {
 	BoardsSupported []string
 	RequiredSensors []string
 	Libraries       []string
+	// LocalePacks holds the template's locale packs by locale, used to
+	// translate plan text
+	LocalePacks map[string]athenatemplate.Messages
 }
@@ -0,0 +1,179 @@
+package nlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+)
+
+// EditWiring moves pins in the wiring diagram of a generated plan and
+// regenerates the plan from its requirements, so that the diagram, Mermaid
+// syntax, safety checks, instructions and pin parameters agree again. Only
+// signal pins of the diagram's components can be moved, never onto power
+// pins; I2C pins are fixed by the board. Moves that make the circuit unsafe
+// are reported in the new plan's safety checks, not as errors.
+func (s *Service) EditWiring(ctx context.Context, requirements *ParsedRequirements, template *TemplateInfo, plan *ImplementationPlan, boardType string, moves []PinMove) (*WiringEdit, error) {
+	if plan == nil || plan.WiringDiagram == nil {
+		return nil, fmt.Errorf("%w: plan has no wiring diagram", ErrInvalidDiagram)
+	}
+	if requirements == nil {
+		return nil, fmt.Errorf("%w: no requirements to regenerate the plan from", ErrInvalidDiagram)
+	}
+
+	edited := *requirements
+	edited.Sensors = slices.Clone(requirements.Sensors)
+	edited.Actuators = slices.Clone(requirements.Actuators)
+	parameters := make(map[string]interface{}, len(plan.Parameters)+len(moves))
+	for key, value := range plan.Parameters {
+		parameters[key] = value
+	}
+	changed := make(map[string]interface{}, len(moves))
+
+	for _, move := range moves {
+		component := s.safetyValidator.findComponent(plan.WiringDiagram.Components, move.Component)
+		if component == nil || component.ID == "board" {
+			return nil, fmt.Errorf("%w: no component %q to move", ErrInvalidDiagram, move.Component)
+		}
+		if err := s.checkMovablePin(plan.WiringDiagram, component, move.ComponentPin); err != nil {
+			return nil, err
+		}
+		boardPin := strings.ToUpper(strings.TrimSpace(move.BoardPin))
+		if s.safetyValidator.isPowerPin(boardPin) {
+			return nil, fmt.Errorf("%w: %s cannot be wired to power pin %s", ErrInvalidDiagram, component.ID, boardPin)
+		}
+
+		index, err := setRequirementPin(&edited, component, boardPin)
+		if err != nil {
+			return nil, err
+		}
+		key := pinParameter(component.Type, index)
+		parameters[key] = boardPin
+		changed[key] = boardPin
+	}
+
+	regenerated, err := s.GenerateLocalizedPlan(ctx, &edited, template, parameters, boardType, plan.Locale)
+	if err != nil {
+		return nil, err
+	}
+	svg := athenatemplate.RenderDiagramSVG(toTemplateDiagram(regenerated.WiringDiagram))
+
+	return &WiringEdit{
+		Plan:       regenerated,
+		Parameters: changed,
+		SVG:        string(svg),
+	}, nil
+}
+
+// checkMovablePin checks that a component is wired to the board by the
+// signal pin a move names. Components with a single signal pin need not
+// name it.
+func (s *Service) checkMovablePin(diagram *WiringDiagram, component *Component, pin string) error {
+	if s.safetyValidator.getRequiredPinType(component) == "i2c" {
+		return fmt.Errorf("%w: %s is on the board's I2C bus, whose pins cannot be moved", ErrInvalidDiagram, component.ID)
+	}
+
+	var signals []string
+	for _, connection := range diagram.Connections {
+		if connection.FromComponent == "board" && connection.ToComponent == component.ID && !s.safetyValidator.isPowerPin(connection.FromPin) {
+			signals = append(signals, connection.ToPin)
+		}
+	}
+
+	switch {
+	case pin == "" && len(signals) == 1:
+		return nil
+	case pin == "":
+		return fmt.Errorf("%w: %s has %d signal pins, name the one to move", ErrInvalidDiagram, component.ID, len(signals))
+	case slices.Contains(signals, strings.ToUpper(pin)):
+		return nil
+	}
+	return fmt.Errorf("%w: %s has no signal pin %q", ErrInvalidDiagram, component.ID, pin)
+}
+
+// setRequirementPin pins the sensor or actuator a diagram component was
+// generated from to a board pin and returns its index. Components are
+// matched by the IDs and types the plan generator gives them.
+func setRequirementPin(requirements *ParsedRequirements, component *Component, boardPin string) (int, error) {
+	if suffix, ok := strings.CutPrefix(component.ID, "sensor_"); ok {
+		index, err := strconv.Atoi(suffix)
+		if err == nil && index >= 0 && index < len(requirements.Sensors) && requirements.Sensors[index].Type == component.Type {
+			requirements.Sensors[index].Pin = boardPin
+			return index, nil
+		}
+	}
+	if suffix, ok := strings.CutPrefix(component.ID, "actuator_"); ok {
+		index, err := strconv.Atoi(suffix)
+		if err == nil && index >= 0 && index < len(requirements.Actuators) && requirements.Actuators[index].Type == component.Type {
+			requirements.Actuators[index].Pin = boardPin
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s does not match the plan's requirements", ErrInvalidDiagram, component.ID)
+}
+
+// toTemplateDiagram converts a plan's wiring diagram for rendering
+func toTemplateDiagram(diagram *WiringDiagram) *athenatemplate.WiringDiagram {
+	converted := &athenatemplate.WiringDiagram{
+		MermaidSyntax: diagram.MermaidSyntax,
+		Components:    make([]athenatemplate.Component, len(diagram.Components)),
+		Connections:   make([]athenatemplate.Connection, len(diagram.Connections)),
+		Metadata:      diagram.Metadata,
+	}
+	for i, component := range diagram.Components {
+		pins := make([]athenatemplate.Pin, len(component.Pins))
+		for j, pin := range component.Pins {
+			pins[j] = athenatemplate.Pin{
+				Number:      pin.Number,
+				Name:        pin.Name,
+				Type:        pin.Type,
+				Voltage:     pin.Voltage,
+				Description: pin.Description,
+			}
+		}
+		converted.Components[i] = athenatemplate.Component{
+			ID:       component.ID,
+			Type:     component.Type,
+			Name:     component.Name,
+			Pins:     pins,
+			Metadata: component.Metadata,
+		}
+	}
+	for i, connection := range diagram.Connections {
+		converted.Connections[i] = athenatemplate.Connection(connection)
+	}
+	return converted
+}
+
+// editWiringHandler moves pins in a plan sent with the request, so edits
+// need no state on the server. The template's locale packs are read from
+// the template directory, so regenerated instructions keep the plan's
+// locale.
+func (s *Service) editWiringHandler(c *gin.Context) {
+	var req WiringEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	template, err := s.templateInfo(c.Request.Context(), req.Plan.TemplateID, req.Plan.TemplateName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	edit, err := s.EditWiring(c.Request.Context(), req.Requirements, template, req.Plan, req.Board, req.Moves)
+	switch {
+	case errors.Is(err, ErrInvalidDiagram):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, edit)
+	}
+}
@@ -0,0 +1,115 @@
+package nlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_EditWiring(t *testing.T) {
+	service, err := NewService(nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	requirements := &ParsedRequirements{
+		Sensors:   []SensorSpec{{Type: "temperature"}, {Type: "temperature", Model: "BME280"}},
+		Actuators: []ActuatorSpec{{Type: "servo"}},
+	}
+	template := &TemplateInfo{ID: "servo-thermostat", Name: "Servo Thermostat"}
+	plan, err := service.GeneratePlan(ctx, requirements, template, map[string]interface{}{"servo_pin": "D5"}, "uno")
+	require.NoError(t, err)
+	require.Equal(t, "D5", plan.WiringDiagram.boardPin("actuator_0", "IN"))
+
+	edit, err := service.EditWiring(ctx, requirements, template, plan, "uno", []PinMove{
+		{Component: "actuator_0", BoardPin: "d6"},
+		{Component: "sensor_0", ComponentPin: "out", BoardPin: "D7"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"servo_pin": "D6", "temperature_pin": "D7"}, edit.Parameters)
+	assert.Equal(t, "D6", edit.Plan.Parameters["servo_pin"])
+	assert.Equal(t, "D6", edit.Plan.WiringDiagram.boardPin("actuator_0", "IN"))
+	assert.Equal(t, "D7", edit.Plan.WiringDiagram.boardPin("sensor_0", "OUT"))
+	assert.Contains(t, edit.Plan.WiringDiagram.MermaidSyntax, "board -->|D6| actuator_0")
+	assert.True(t, servoPinCheck(edit.Plan).Compatible)
+	assert.True(t, strings.HasPrefix(edit.SVG, "<svg"))
+	assert.Contains(t, edit.SVG, "D6")
+	// The plan the edit started from is left alone
+	assert.Equal(t, "D5", plan.Parameters["servo_pin"])
+	assert.Empty(t, requirements.Actuators[0].Pin)
+
+	// Servos need PWM, which D4 does not have
+	edit, err = service.EditWiring(ctx, requirements, template, plan, "uno", []PinMove{{Component: "actuator_0", BoardPin: "D4"}})
+	require.NoError(t, err)
+	assert.False(t, edit.Plan.SafetyChecks.Valid)
+	assert.False(t, servoPinCheck(edit.Plan).Compatible)
+
+	for _, moves := range [][]PinMove{
+		{{Component: "board", BoardPin: "D4"}},
+		{{Component: "actuator_9", BoardPin: "D4"}},
+		{{Component: "actuator_0", BoardPin: "GND"}},
+		{{Component: "actuator_0", ComponentPin: "VCC", BoardPin: "D4"}},
+		{{Component: "sensor_1", BoardPin: "D4"}},
+	} {
+		_, err := service.EditWiring(ctx, requirements, template, plan, "uno", moves)
+		assert.ErrorIs(t, err, ErrInvalidDiagram, "%+v", moves)
+	}
+
+	// Requirements that no longer match the diagram are rejected
+	_, err = service.EditWiring(ctx, &ParsedRequirements{Actuators: []ActuatorSpec{{Type: "led"}}}, template, plan, "uno",
+		[]PinMove{{Component: "actuator_0", BoardPin: "D6"}})
+	assert.ErrorIs(t, err, ErrInvalidDiagram)
+}
+
+// servoPinCheck returns the pin compatibility check of a plan's servo
+// signal pin
+func servoPinCheck(plan *ImplementationPlan) PinCompatibilityCheck {
+	pin := plan.WiringDiagram.boardPin("actuator_0", "IN")
+	for _, check := range plan.SafetyChecks.PinCompatibility {
+		if check.Pin == pin && check.Component == "Servo" {
+			return check
+		}
+	}
+	return PinCompatibilityCheck{}
+}
+
+func TestService_EditWiringHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(nil)
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	requirements := &ParsedRequirements{Actuators: []ActuatorSpec{{Type: "led"}}}
+	plan, err := service.GeneratePlan(context.Background(), requirements, &TemplateInfo{ID: "blink", Name: "Blink"}, map[string]interface{}{}, "uno")
+	require.NoError(t, err)
+
+	send := func(req interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r, _ := http.NewRequest(http.MethodPatch, "/api/v1/nlp/wiring-diagram", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(WiringEditRequest{Board: "uno", Requirements: requirements, Plan: plan, Moves: []PinMove{{Component: "actuator_0", BoardPin: "D13"}}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var edit WiringEdit
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &edit))
+	assert.Equal(t, "blink", edit.Plan.TemplateID)
+	assert.Equal(t, "D13", edit.Parameters["led_pin"])
+	assert.Equal(t, "D13", edit.Plan.WiringDiagram.boardPin("actuator_0", "ANODE"))
+	assert.NotEmpty(t, edit.SVG)
+
+	assert.Equal(t, http.StatusBadRequest, send(WiringEditRequest{Board: "uno", Requirements: requirements, Plan: plan}).Code)
+	assert.Equal(t, http.StatusBadRequest, send(WiringEditRequest{Board: "uno", Requirements: requirements, Plan: plan,
+		Moves: []PinMove{{Component: "actuator_0", BoardPin: "5V"}}}).Code)
+}
@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of platform event pushed to subscribers
+type EventType string
+
+const (
+	EventDeploymentCompleted  EventType = "deployment.completed"
+	EventDeploymentFailed     EventType = "deployment.failed"
+	EventDeploymentCancelled  EventType = "deployment.cancelled"
+	EventDeploymentRolledBack EventType = "deployment.rolled_back"
+	EventRollbackBlocked      EventType = "deployment.rollback_blocked"
+	EventDeploymentOverBudget EventType = "deployment.over_budget"
+	EventDeploymentUnhealthy  EventType = "deployment.health_regressed"
+	EventAlertFired           EventType = "alert.fired"
+	EventDeviceOffline        EventType = "device.offline"
+	EventDeviceAdded          EventType = "device.added"
+	EventDeviceUpdated        EventType = "device.updated"
+	EventDeviceStatusChanged  EventType = "device.status_changed"
+	EventDeviceRemoved        EventType = "device.removed"
+	EventQuotaExceeded        EventType = "quota.exceeded"
+	EventCredentialExpiring   EventType = "credential.expiring"
+	EventCredentialExpired    EventType = "credential.expired"
+	EventTemplateBuildBroken  EventType = "template.build_broken"
+)
+
+// Event represents a notification published by a platform service
+type Event struct {
+	ID           string                 `json:"id"`
+	Type         EventType              `json:"type"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	Owner        string                 `json:"owner,omitempty"`
+	Source       string                 `json:"source"`
+	Message      string                 `json:"message"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	Timestamp    time.Time              `json:"timestamp"`
+}
+
+// Filter narrows the events delivered to a subscriber
+type Filter struct {
+	Types       []EventType `json:"types,omitempty"`
+	ResourceIDs []string    `json:"resource_ids,omitempty"`
+	// Data holds values event data must match. Events that do not carry a
+	// key pass, so events about removed resources still reach subscribers.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// ParseFilter builds a filter from comma-separated type and resource lists
+func ParseFilter(types, resources string) Filter {
+	var filter Filter
+	for _, t := range splitList(types) {
+		filter.Types = append(filter.Types, EventType(t))
+	}
+	filter.ResourceIDs = splitList(resources)
+	return filter
+}
+
+// Matches reports whether an event passes the filter
+func (f Filter) Matches(event *Event) bool {
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for key, want := range f.Data {
+		if value, ok := event.Data[key]; ok && fmt.Sprint(value) != want {
+			return false
+		}
+	}
+
+	if len(f.ResourceIDs) > 0 {
+		for _, id := range f.ResourceIDs {
+			if id == event.ResourceID {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
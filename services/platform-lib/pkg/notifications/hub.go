@@ -0,0 +1,258 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscriberBufferSize = 64
+	pingInterval         = 30 * time.Second
+	writeTimeout         = 10 * time.Second
+	maxEventSize         = 64 << 10
+)
+
+// subscriber is a single WebSocket client of the hub
+type subscriber struct {
+	userID string
+	admin  bool
+	filter Filter
+	send   chan *Event
+}
+
+// canReceive reports whether the subscriber may see an event. Events with an
+// owner are only delivered to that user and admins. Device offline and alert
+// events are owned by the owner of the device's template; unowned events
+// cannot be scoped to a user, so only admins see them.
+func (s *subscriber) canReceive(event *Event) bool {
+	if !s.admin && (event.Owner == "" || event.Owner != s.userID) {
+		return false
+	}
+	return s.filter.Matches(event)
+}
+
+// Hub fans out platform events to connected WebSocket subscribers
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	logger      *logger.Logger
+	secret      []byte
+	upgrader    websocket.Upgrader
+}
+
+// NewHub creates a notification hub that accepts events signed with secret
+func NewHub(logger *logger.Logger, secret string) *Hub {
+	return &Hub{
+		subscribers: make(map[*subscriber]struct{}),
+		logger:      logger,
+		secret:      []byte(secret),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				// Clients authenticate with a bearer token, not cookies
+				return true
+			},
+		},
+	}
+}
+
+// Publish delivers an event to every matching subscriber. Slow subscribers
+// whose buffers are full miss the event rather than blocking the hub.
+func (h *Hub) Publish(event *Event) {
+	prepareEvent(event, "")
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if !sub.canReceive(event) {
+			continue
+		}
+		select {
+		case sub.send <- event:
+		default:
+			h.logger.Warn("Dropping notification for slow subscriber", "type", event.Type, "user_id", sub.userID)
+		}
+	}
+}
+
+// SubscriberCount returns the number of connected subscribers
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+func (h *Hub) subscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[sub] = struct{}{}
+}
+
+func (h *Hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, sub)
+}
+
+// HandleStream streams notifications to an authenticated client, filtered by
+// the types and resources query parameters. It expects the JWT middleware to
+// have populated user_id and roles.
+func (h *Hub) HandleStream(c *gin.Context) {
+	h.ServeStream(c, ParseFilter(c.Query("types"), c.Query("resources")))
+}
+
+// ServeStream streams the events matching filter. WebSocket upgrade requests
+// get a WebSocket; other requests get server-sent events, which pass through
+// proxies that do not support WebSockets.
+func (h *Hub) ServeStream(c *gin.Context, filter Filter) {
+	sub := &subscriber{
+		userID: c.GetString("user_id"),
+		filter: filter,
+		send:   make(chan *Event, subscriberBufferSize),
+	}
+	if roles, ok := c.Get("roles"); ok {
+		if roleList, ok := roles.([]string); ok {
+			for _, role := range roleList {
+				if role == "admin" {
+					sub.admin = true
+				}
+			}
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.serveWebSocket(c, sub)
+		return
+	}
+	h.serveEvents(c, sub)
+}
+
+func (h *Hub) serveWebSocket(c *gin.Context, sub *subscriber) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.Error("Failed to upgrade notification stream", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	h.subscribe(sub)
+	defer h.unsubscribe(sub)
+
+	h.logger.Info("Notification subscriber connected", "user_id", sub.userID)
+
+	// Reader detects client disconnects; clients never send data
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			h.logger.Info("Notification subscriber disconnected", "user_id", sub.userID)
+			return
+		case event := <-sub.send:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				h.logger.Warn("Failed to write notification", "error", err)
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveEvents streams events as server-sent events, named by event type
+func (h *Hub) serveEvents(c *gin.Context, sub *subscriber) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	h.subscribe(sub)
+	defer h.unsubscribe(sub)
+
+	h.logger.Info("Notification subscriber connected over SSE", "user_id", sub.userID)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			h.logger.Info("Notification subscriber disconnected", "user_id", sub.userID)
+			return
+		case event := <-sub.send:
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Warn("Failed to encode notification", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-ticker.C:
+			// Comments keep idle connections open through proxies
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// HandlePublish accepts a signed event from a platform service
+func (h *Hub) HandlePublish(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxEventSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if len(h.secret) == 0 || !VerifySignature(h.secret, body, c.GetHeader(SignatureHeader)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid event signature"})
+		return
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid event format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if event.Type == "" || event.ResourceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event type and resource_id are required"})
+		return
+	}
+
+	h.Publish(&event)
+	c.JSON(http.StatusAccepted, gin.H{"id": event.ID})
+}
@@ -0,0 +1,184 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func setupTestHub(userID string, roles []string) (*Hub, *httptest.Server) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(logger.New("debug", "test"), testSecret)
+
+	router := gin.New()
+	router.POST(PublishPath, hub.HandlePublish)
+	router.GET("/stream", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("roles", roles)
+		hub.HandleStream(c)
+	})
+
+	return hub, httptest.NewServer(router)
+}
+
+func dialStream(t *testing.T, server *httptest.Server, hub *Hub, query string) *websocket.Conn {
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/stream" + query
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return hub.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+	return conn
+}
+
+func TestFilter_Matches(t *testing.T) {
+	event := &Event{Type: EventAlertFired, ResourceID: "device-001"}
+
+	assert.True(t, Filter{}.Matches(event))
+	assert.True(t, ParseFilter("alert.fired, device.offline", "").Matches(event))
+	assert.False(t, ParseFilter("deployment.completed", "").Matches(event))
+	assert.True(t, ParseFilter("", "device-001,device-002").Matches(event))
+	assert.False(t, ParseFilter("alert.fired", "device-002").Matches(event))
+}
+
+func TestFilter_MatchesData(t *testing.T) {
+	online := &Event{Type: EventDeviceStatusChanged, ResourceID: "device-001", Data: map[string]interface{}{"status": "online", "progress": 40}}
+	removed := &Event{Type: EventDeviceRemoved, ResourceID: "device-002", Data: map[string]interface{}{"device_id": "device-002"}}
+
+	assert.True(t, Filter{Data: map[string]string{"status": "online"}}.Matches(online))
+	assert.False(t, Filter{Data: map[string]string{"status": "offline"}}.Matches(online))
+	assert.True(t, Filter{Data: map[string]string{"progress": "40"}}.Matches(online))
+	// Events without the key pass
+	assert.True(t, Filter{Data: map[string]string{"status": "offline"}}.Matches(removed))
+}
+
+func TestSubscriber_CanReceive(t *testing.T) {
+	owned := &Event{Type: EventDeploymentCompleted, ResourceID: "dep-1", Owner: "alice"}
+	unowned := &Event{Type: EventDeviceOffline, ResourceID: "device-001"}
+
+	alice := &subscriber{userID: "alice"}
+	bob := &subscriber{userID: "bob"}
+	admin := &subscriber{userID: "root", admin: true}
+
+	assert.True(t, alice.canReceive(owned))
+	assert.False(t, bob.canReceive(owned))
+	assert.True(t, admin.canReceive(owned))
+	assert.False(t, bob.canReceive(unowned))
+	assert.True(t, admin.canReceive(unowned))
+}
+
+func TestHub_StreamReceivesPublishedEvents(t *testing.T) {
+	hub, server := setupTestHub("alice", []string{"user"})
+	defer server.Close()
+
+	conn := dialStream(t, server, hub, "?types=deployment.completed")
+	defer conn.Close()
+
+	publisher := NewHTTPPublisher(&config.Config{
+		ServiceName: "ota-service",
+		JWTSecret:   testSecret,
+		Services:    map[string]string{"api-gateway": server.URL},
+	})
+
+	ctx := context.Background()
+	// Filtered out by type
+	require.NoError(t, publisher.Publish(ctx, &Event{Type: EventDeviceOffline, ResourceID: "device-001"}))
+	// Owned by another user
+	require.NoError(t, publisher.Publish(ctx, &Event{Type: EventDeploymentCompleted, ResourceID: "dep-0", Owner: "bob"}))
+	require.NoError(t, publisher.Publish(ctx, &Event{Type: EventDeploymentCompleted, ResourceID: "dep-1", Owner: "alice", Message: "done"}))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var received Event
+	require.NoError(t, conn.ReadJSON(&received))
+
+	assert.Equal(t, EventDeploymentCompleted, received.Type)
+	assert.Equal(t, "dep-1", received.ResourceID)
+	assert.Equal(t, "ota-service", received.Source)
+	assert.NotEmpty(t, received.ID)
+	assert.False(t, received.Timestamp.IsZero())
+}
+
+func TestHub_StreamServesServerSentEvents(t *testing.T) {
+	hub, server := setupTestHub("root", []string{"admin"})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream?types=alert.fired")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return hub.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	hub.Publish(&Event{Type: EventDeviceOffline, ResourceID: "device-001"})
+	hub.Publish(&Event{ID: "evt-1", Type: EventAlertFired, ResourceID: "device-001", Message: "too hot"})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	assert.Equal(t, "id: evt-1", lines[0])
+	assert.Equal(t, "event: alert.fired", lines[1])
+
+	var received Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &received))
+	assert.Equal(t, "too hot", received.Message)
+}
+
+func TestHub_HandlePublish_RejectsBadSignature(t *testing.T) {
+	_, server := setupTestHub("alice", nil)
+	defer server.Close()
+
+	body, _ := json.Marshal(Event{Type: EventAlertFired, ResourceID: "device-001"})
+
+	req, _ := http.NewRequest("POST", server.URL+PublishPath, bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, Sign([]byte("wrong-secret"), body))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ = http.NewRequest("POST", server.URL+PublishPath, bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, Sign([]byte(testSecret), body))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestHub_HandlePublish_RequiresResource(t *testing.T) {
+	_, server := setupTestHub("alice", nil)
+	defer server.Close()
+
+	body, _ := json.Marshal(Event{Type: EventAlertFired})
+	req, _ := http.NewRequest("POST", server.URL+PublishPath, bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, Sign([]byte(testSecret), body))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestNewPublisherFromConfig(t *testing.T) {
+	assert.Nil(t, NewPublisherFromConfig(nil))
+	assert.Nil(t, NewPublisherFromConfig(&config.Config{}))
+	assert.NotNil(t, NewPublisherFromConfig(&config.Config{
+		Services: map[string]string{"api-gateway": "http://localhost:8000"},
+	}))
+}
@@ -0,0 +1,130 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the event body
+	SignatureHeader = "X-Athena-Signature"
+
+	// PublishPath is the gateway endpoint services post events to
+	PublishPath = "/internal/notifications/events"
+)
+
+// Publisher delivers events to the notification hub
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// HTTPPublisher posts signed events to the API gateway
+type HTTPPublisher struct {
+	endpoint string
+	secret   []byte
+	source   string
+	client   *http.Client
+}
+
+// NewHTTPPublisher creates a publisher targeting the gateway from the service configuration
+func NewHTTPPublisher(cfg *config.Config) *HTTPPublisher {
+	return &HTTPPublisher{
+		endpoint: cfg.Services["api-gateway"] + PublishPath,
+		secret:   []byte(cfg.JWTSecret),
+		source:   cfg.ServiceName,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// NewPublisherFromConfig returns an HTTP publisher when the gateway address is
+// configured, or nil so callers can skip publishing
+func NewPublisherFromConfig(cfg *config.Config) Publisher {
+	if cfg == nil || cfg.Services["api-gateway"] == "" {
+		return nil
+	}
+	return NewHTTPPublisher(cfg)
+}
+
+// Publish sends an event to the gateway
+func (p *HTTPPublisher) Publish(ctx context.Context, event *Event) error {
+	prepareEvent(event, p.source)
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(p.secret, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification hub returned HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PublishAsync publishes an event in the background, logging failures.
+// A nil publisher is a no-op so services can run without a hub.
+func PublishAsync(publisher Publisher, logger *logger.Logger, event *Event) {
+	if publisher == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := publisher.Publish(ctx, event); err != nil {
+			logger.Warn("Failed to publish notification", "type", event.Type, "resource_id", event.ResourceID, "error", err)
+		}
+	}()
+}
+
+// Sign returns the signature header value for a request body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature header value against a request body
+func VerifySignature(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// prepareEvent fills in identifiers and timestamps left empty by the caller
+func prepareEvent(event *Event, source string) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Source == "" {
+		event.Source = source
+	}
+}
@@ -0,0 +1,223 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+// defaultStuckAfter is how long an active deployment may go without progress
+// before close-deployments treats it as stuck
+const defaultStuckAfter = 24 * time.Hour
+
+// KeyRotation reports the outcome of a signing key rotation
+type KeyRotation struct {
+	PreviousKeyID string `json:"previous_key_id,omitempty"`
+	KeyID         string `json:"key_id"`
+	// Resigned counts releases re-signed with the new key
+	Resigned int `json:"resigned"`
+	// Failed lists releases that could not be re-signed
+	Failed []string `json:"failed,omitempty"`
+}
+
+// AdminTasks returns the OTA service's operational tasks
+func (s *Service) AdminTasks() []admin.Task {
+	return []admin.Task{
+		{
+			Name: "close-deployments",
+			Description: "Force-close deployment_id, or every active deployment without progress for stuck_for " +
+				"(default 24h), failing its unfinished device updates",
+			Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+				reason := params["reason"]
+				if id := params["deployment_id"]; id != "" {
+					deployment, err := s.CloseDeployment(ctx, id, reason)
+					if err != nil {
+						return nil, err
+					}
+					return admin.Result{"closed": []string{deployment.DeploymentID}}, nil
+				}
+				stuckFor, err := params.Duration("stuck_for", defaultStuckAfter)
+				if err != nil {
+					return nil, err
+				}
+				closed, err := s.CloseStuckDeployments(ctx, stuckFor, reason)
+				return admin.Result{"closed": closed}, err
+			},
+		},
+		{
+			Name: "rotate-signing-key",
+			Description: "Make private_key/public_key (PEM) the firmware signing key; " +
+				"with resign=true, re-sign existing releases with it",
+			Secret: []string{"private_key"},
+			Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+				resign, err := params.Bool("resign")
+				if err != nil {
+					return nil, err
+				}
+				if params["private_key"] == "" || params["public_key"] == "" {
+					return nil, fmt.Errorf("%w: private_key and public_key are required", admin.ErrInvalidParams)
+				}
+				rotation, err := s.RotateSigningKey(ctx, []byte(params["private_key"]), []byte(params["public_key"]), resign)
+				if errors.Is(err, ErrInvalidSigningKey) {
+					return nil, fmt.Errorf("%w: %v", admin.ErrInvalidParams, err)
+				}
+				if err != nil {
+					return nil, err
+				}
+				return admin.Result{
+					"previous_key_id": rotation.PreviousKeyID,
+					"key_id":          rotation.KeyID,
+					"resigned":        rotation.Resigned,
+					"failed":          rotation.Failed,
+				}, nil
+			},
+		},
+	}
+}
+
+// CloseDeployment force-closes a deployment that is not finished: device
+// updates still in flight are failed with the reason, and the deployment
+// is settled from the results it has
+func (s *Service) CloseDeployment(ctx context.Context, deploymentID, reason string) (*OTADeployment, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if deployment.Status == DeploymentStatusCompleted || deployment.Status == DeploymentStatusFailed || deployment.Status == DeploymentStatusCancelled || deployment.Status == DeploymentStatusRejected {
+		return nil, fmt.Errorf("deployment %s is already %s", deploymentID, deployment.Status)
+	}
+	if deployment.Status == DeploymentStatusPendingApproval {
+		return nil, fmt.Errorf("deployment %s is awaiting approval; reject it instead", deploymentID)
+	}
+
+	updates, err := s.repository.ListDeviceUpdates(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device updates: %w", err)
+	}
+
+	message := "closed by administrator"
+	if reason != "" {
+		message += ": " + reason
+	}
+	now := time.Now()
+	for _, update := range updates {
+		switch update.Status {
+		case UpdateStatusPending, UpdateStatusDownloading, UpdateStatusInstalling:
+		default:
+			continue
+		}
+		update.Status = UpdateStatusFailed
+		update.ErrorMessage = message
+		update.CompletedAt = &now
+		if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+			return nil, fmt.Errorf("failed to close update for device %s: %w", update.DeviceID, err)
+		}
+	}
+
+	if err := s.updateDeploymentStats(ctx, deploymentID); err != nil {
+		return nil, err
+	}
+	s.logger.Warn("Force-closed deployment", "deployment_id", deploymentID, "reason", reason)
+
+	return s.repository.GetDeployment(ctx, deploymentID)
+}
+
+// CloseStuckDeployments force-closes active deployments that have not
+// progressed for stuckFor and returns their IDs
+func (s *Service) CloseStuckDeployments(ctx context.Context, stuckFor time.Duration, reason string) ([]string, error) {
+	deployments, err := s.repository.GetActiveDeployments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active deployments: %w", err)
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("no progress for %s", stuckFor)
+	}
+	cutoff := time.Now().Add(-stuckFor)
+	closed := []string{}
+	for _, deployment := range deployments {
+		if deployment.UpdatedAt.After(cutoff) {
+			continue
+		}
+		if _, err := s.CloseDeployment(ctx, deployment.DeploymentID, reason); err != nil {
+			return closed, err
+		}
+		closed = append(closed, deployment.DeploymentID)
+	}
+	return closed, nil
+}
+
+// RotateSigningKey makes a new key pair the firmware signing key and saves
+// it with the retired keys to the signing key store, so the rotation
+// survives restarts. Releases signed with earlier keys keep verifying; with
+// resign they are re-signed with the new key, so devices given only the new
+// public key accept them.
+func (s *Service) RotateSigningKey(ctx context.Context, privateKeyPEM, publicKeyPEM []byte, resign bool) (*KeyRotation, error) {
+	rotation := &KeyRotation{}
+	if s.signer == nil {
+		signer, err := NewSigner(privateKeyPEM, publicKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSigningKey, err)
+		}
+		if !signer.privateKey.PublicKey.Equal(signer.publicKey) {
+			return nil, fmt.Errorf("%w: public key does not match private key", ErrInvalidSigningKey)
+		}
+		if s.signingKeys != nil {
+			if err := s.signingKeys.SaveSigningKeys(ctx, signer.storedKeys()); err != nil {
+				return nil, fmt.Errorf("failed to save signing keys: %w", err)
+			}
+		}
+		s.signer = signer
+	} else {
+		rotation.PreviousKeyID = s.signer.KeyID()
+		if err := s.signer.RotateAndSave(ctx, privateKeyPEM, publicKeyPEM, s.signingKeys); err != nil {
+			return nil, err
+		}
+	}
+	rotation.KeyID = s.signer.KeyID()
+	s.logger.Warn("Rotated firmware signing key", "previous_key_id", rotation.PreviousKeyID, "key_id", rotation.KeyID)
+
+	if !resign {
+		return rotation, nil
+	}
+
+	releases, err := s.repository.ListReleases(ctx, "", "")
+	if err != nil {
+		return rotation, fmt.Errorf("failed to list releases: %w", err)
+	}
+	for _, release := range releases {
+		if release.SigningKeyID == rotation.KeyID {
+			continue
+		}
+		if err := s.resignRelease(ctx, release); err != nil {
+			s.logger.Warn("Failed to re-sign release", "release_id", release.ReleaseID, "error", err)
+			rotation.Failed = append(rotation.Failed, release.ReleaseID)
+			continue
+		}
+		rotation.Resigned++
+	}
+	return rotation, nil
+}
+
+// resignRelease signs a release's stored binary with the current key,
+// refusing binaries that no longer match the release hash
+func (s *Service) resignRelease(ctx context.Context, release *FirmwareRelease) error {
+	binaryData, err := s.storageBackend.GetBinary(ctx, release.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("failed to get binary: %w", err)
+	}
+	if hash := ComputeHash(binaryData); hash != release.BinaryHash {
+		return fmt.Errorf("binary hash mismatch: expected %s, got %s", release.BinaryHash, hash)
+	}
+
+	signature, keyID, err := s.signer.SignBinaryWithKeyID(binaryData)
+	if err != nil {
+		return err
+	}
+	release.Signature = signature
+	release.SigningKeyID = keyID
+	return s.repository.UpdateRelease(ctx, release)
+}
@@ -0,0 +1,192 @@
+package ota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_Rotate(t *testing.T) {
+	oldPrivate, oldPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	newPrivate, newPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+
+	signer, err := NewSigner(oldPrivate, oldPublic)
+	require.NoError(t, err)
+	oldKeyID := signer.KeyID()
+	binary := []byte("firmware signed before the rotation")
+	oldSignature, err := signer.SignBinary(binary)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, signer.Rotate(newPrivate, oldPublic), "does not match")
+	assert.ErrorContains(t, signer.Rotate(oldPrivate, oldPublic), "already the signing key")
+	require.NoError(t, signer.Rotate(newPrivate, newPublic))
+	assert.NotEqual(t, oldKeyID, signer.KeyID())
+	assert.Len(t, signer.KeyID(), 16)
+
+	// Releases signed with the old key still verify; new ones use the new key
+	assert.NoError(t, signer.VerifySignature(binary, oldSignature))
+	newSignature, err := signer.SignBinary(binary)
+	require.NoError(t, err)
+	newOnly, err := NewSigner(newPrivate, newPublic)
+	require.NoError(t, err)
+	assert.NoError(t, newOnly.VerifySignature(binary, newSignature))
+	assert.Error(t, newOnly.VerifySignature(binary, oldSignature))
+}
+
+func TestSigner_Restore(t *testing.T) {
+	ctx := context.Background()
+	configPrivate, configPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	rotatedPrivate, rotatedPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	store := NewMemorySigningKeyStore()
+
+	// The first start saves the configured key
+	signer, err := NewSigner(configPrivate, configPublic)
+	require.NoError(t, err)
+	configKeyID := signer.KeyID()
+	require.NoError(t, signer.Restore(ctx, store))
+	stored, err := store.ListSigningKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, configKeyID, stored[0].KeyID)
+	assert.NotEmpty(t, stored[0].PrivateKey)
+
+	// A key rotated in through the API outlives a restart with the old
+	// configuration, and the configured key keeps verifying
+	binary := []byte("firmware")
+	configSignature, err := signer.SignBinary(binary)
+	require.NoError(t, err)
+	require.NoError(t, signer.RotateAndSave(ctx, rotatedPrivate, rotatedPublic, store))
+	rotatedKeyID := signer.KeyID()
+
+	restarted, err := NewSigner(configPrivate, configPublic)
+	require.NoError(t, err)
+	require.NoError(t, restarted.Restore(ctx, store))
+	assert.Equal(t, rotatedKeyID, restarted.KeyID())
+	assert.Equal(t, signer.Keys(), restarted.Keys())
+	assert.NoError(t, restarted.VerifySignatureWithKeyID(binary, configSignature, configKeyID))
+	_, keyID, err := restarted.SignBinaryWithKeyID(binary)
+	require.NoError(t, err)
+	assert.Equal(t, rotatedKeyID, keyID)
+	stored, err = store.ListSigningKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Empty(t, stored[1].PrivateKey, "retired keys are stored without their private key")
+
+	// A key the store does not know was rotated in by configuration
+	newPrivate, newPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	reconfigured, err := NewSigner(newPrivate, newPublic)
+	require.NoError(t, err)
+	require.NoError(t, reconfigured.Restore(ctx, store))
+	keys := reconfigured.Keys()
+	require.Len(t, keys, 3)
+	assert.Equal(t, KeyStatusSigning, keys[0].Status)
+	assert.Equal(t, reconfigured.KeyID(), keys[0].KeyID)
+	assert.Equal(t, rotatedKeyID, keys[1].KeyID)
+	assert.Equal(t, configKeyID, keys[2].KeyID)
+}
+
+// failingKeyStore is a signing key store that cannot save
+type failingKeyStore struct{ MemorySigningKeyStore }
+
+func (*failingKeyStore) SaveSigningKeys(ctx context.Context, keys []*StoredSigningKey) error {
+	return assert.AnError
+}
+
+func TestService_RotateSigningKey_Saves(t *testing.T) {
+	service, _, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+	store := NewMemorySigningKeyStore()
+	service.SetSigningKeyStore(store)
+	previousKeyID := service.signer.KeyID()
+
+	newPrivate, newPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	rotation, err := service.RotateSigningKey(ctx, newPrivate, newPublic, false)
+	require.NoError(t, err)
+	stored, err := store.ListSigningKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, rotation.KeyID, stored[0].KeyID)
+	assert.Equal(t, previousKeyID, stored[1].KeyID)
+
+	// A rotation that cannot be saved is not made
+	service.SetSigningKeyStore(&failingKeyStore{})
+	otherPrivate, otherPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	_, err = service.RotateSigningKey(ctx, otherPrivate, otherPublic, false)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, rotation.KeyID, service.signer.KeyID())
+}
+
+func TestService_RotateSigningKey_Resign(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupDeploymentTestService()
+	ctx := context.Background()
+	oldKeyID := service.signer.KeyID()
+
+	binary := []byte("firmware v1")
+	signature, err := service.signer.SignBinary(binary)
+	require.NoError(t, err)
+	signed := &FirmwareRelease{ReleaseID: "release-1", BinaryPath: "releases/1", BinaryHash: ComputeHash(binary), Signature: signature, SigningKeyID: oldKeyID}
+	tampered := &FirmwareRelease{ReleaseID: "release-2", BinaryPath: "releases/2", BinaryHash: "deadbeef", SigningKeyID: oldKeyID}
+	mockRepo.On("ListReleases", ctx, "", ReleaseChannel("")).Return([]*FirmwareRelease{signed, tampered}, nil)
+	mockRepo.On("UpdateRelease", ctx, signed).Return(nil)
+	mockStorage.On("GetBinary", ctx, "releases/1").Return(binary, nil)
+	mockStorage.On("GetBinary", ctx, "releases/2").Return([]byte("swapped"), nil)
+
+	newPrivate, newPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	rotation, err := service.RotateSigningKey(ctx, newPrivate, newPublic, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, oldKeyID, rotation.PreviousKeyID)
+	assert.Equal(t, service.signer.KeyID(), rotation.KeyID)
+	assert.Equal(t, 1, rotation.Resigned)
+	assert.Equal(t, []string{"release-2"}, rotation.Failed)
+	assert.Equal(t, rotation.KeyID, signed.SigningKeyID)
+
+	newOnly, err := NewSigner(newPrivate, newPublic)
+	require.NoError(t, err)
+	assert.NoError(t, newOnly.VerifySignature(binary, signed.Signature))
+	mockRepo.AssertNumberOfCalls(t, "UpdateRelease", 1)
+}
+
+func TestService_CloseStuckDeployments(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	stuck := &OTADeployment{DeploymentID: "deployment-1", Status: DeploymentStatusActive, UpdatedAt: time.Now().Add(-48 * time.Hour)}
+	moving := &OTADeployment{DeploymentID: "deployment-2", Status: DeploymentStatusActive, UpdatedAt: time.Now()}
+	done := &DeviceUpdate{DeviceID: "device-1", DeploymentID: "deployment-1", Status: UpdateStatusCompleted}
+	hung := &DeviceUpdate{DeviceID: "device-2", DeploymentID: "deployment-1", Status: UpdateStatusDownloading}
+	mockRepo.On("GetActiveDeployments", ctx).Return([]*OTADeployment{stuck, moving}, nil)
+	mockRepo.On("GetDeployment", ctx, "deployment-1").Return(stuck, nil)
+	mockRepo.On("ListDeviceUpdates", ctx, "deployment-1").Return([]*DeviceUpdate{done, hung}, nil)
+	mockRepo.On("UpdateDeviceUpdate", ctx, hung).Return(nil)
+	mockRepo.On("GetDeploymentStats", ctx, "deployment-1").Return(1, 1, 0, nil)
+	mockRepo.On("UpdateDeployment", ctx, mock.Anything).Return(nil)
+	mockRepo.On("GetRelease", ctx, mock.Anything).Return(&FirmwareRelease{}, nil)
+
+	closed, err := service.CloseStuckDeployments(ctx, 24*time.Hour, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deployment-1"}, closed)
+
+	assert.Equal(t, UpdateStatusCompleted, done.Status)
+	assert.Equal(t, UpdateStatusFailed, hung.Status)
+	assert.Equal(t, "closed by administrator: no progress for 24h0m0s", hung.ErrorMessage)
+	assert.NotNil(t, hung.CompletedAt)
+	assert.Equal(t, DeploymentStatusCompleted, stuck.Status)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDeviceUpdate", 1)
+
+	// Finished deployments cannot be closed again
+	_, err = service.CloseDeployment(ctx, "deployment-1", "")
+	assert.ErrorContains(t, err, "already completed")
+}
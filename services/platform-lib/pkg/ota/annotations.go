@@ -0,0 +1,172 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Annotation limits. Keys may carry a prefix such as "athena.io/" and values
+// hold short references (ticket IDs, owners, change-request links).
+const (
+	MaxAnnotations          = 64
+	maxAnnotationValueBytes = 1024
+)
+
+// Annotations set by the platform itself
+const (
+	AnnotationPolicyID   = "athena.io/policy-id"
+	AnnotationPolicyName = "athena.io/policy"
+	AnnotationRollbackOf = "athena.io/rollback-of"
+
+	// AnnotationRolledBackBy names the deployment that rolled this one back
+	AnnotationRolledBackBy = "athena.io/rolled-back-by"
+	// AnnotationRollbackDepth counts the rollbacks chained before this one
+	AnnotationRollbackDepth = "athena.io/rollback-depth"
+	// AnnotationRollbackStarted marks a rollback in progress
+	AnnotationRollbackStarted = "athena.io/rollback-started-at"
+	// AnnotationScheduleHold marks a deployment paused outside its
+	// maintenance windows, which the scheduler resumes
+	AnnotationScheduleHold = "athena.io/schedule-hold"
+	// AnnotationCatchUpOf marks a trailing deployment updating devices that
+	// missed the named deployment's rollout
+	AnnotationCatchUpOf = "athena.io/catch-up-of"
+)
+
+var annotationKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,126}[A-Za-z0-9])?$`)
+
+// UpdateAnnotationsRequest merges annotations into a release or deployment.
+// A null value removes the key.
+type UpdateAnnotationsRequest struct {
+	Annotations map[string]*string `json:"annotations" binding:"required"`
+}
+
+// ValidateAnnotations checks annotation keys, values and count
+func ValidateAnnotations(annotations map[string]string) error {
+	if len(annotations) > MaxAnnotations {
+		return fmt.Errorf("too many annotations: %d (max %d)", len(annotations), MaxAnnotations)
+	}
+	for key, value := range annotations {
+		if !annotationKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid annotation key %q", key)
+		}
+		if value == "" {
+			return fmt.Errorf("annotation %q has an empty value", key)
+		}
+		if len(value) > maxAnnotationValueBytes {
+			return fmt.Errorf("annotation %q exceeds %d bytes", key, maxAnnotationValueBytes)
+		}
+	}
+	return nil
+}
+
+// ParseAnnotationFilter parses "key=value" (exact match) and "key" (key is
+// present) selectors as used by the ?annotation= listing filter
+func ParseAnnotationFilter(selectors []string) (map[string]string, error) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	filter := make(map[string]string, len(selectors))
+	for _, selector := range selectors {
+		key, value, _ := strings.Cut(selector, "=")
+		if !annotationKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid annotation filter %q", selector)
+		}
+		filter[key] = value
+	}
+	return filter, nil
+}
+
+// MatchesAnnotations reports whether annotations satisfy every selector in
+// filter. An empty selector value only requires the key to be present.
+func MatchesAnnotations(annotations, filter map[string]string) bool {
+	for key, want := range filter {
+		have, ok := annotations[key]
+		if !ok || (want != "" && have != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeAnnotations applies a patch to a copy of current
+func mergeAnnotations(current map[string]string, patch map[string]*string) (map[string]string, error) {
+	merged := make(map[string]string, len(current)+len(patch))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+
+	if err := ValidateAnnotations(merged); err != nil {
+		return nil, err
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// FormatAnnotations renders annotations as sorted "key=value" pairs
+func FormatAnnotations(annotations map[string]string) string {
+	pairs := make([]string, 0, len(annotations))
+	for key, value := range annotations {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// AnnotateRelease merges annotations into a release
+func (s *Service) AnnotateRelease(ctx context.Context, releaseID string, patch map[string]*string) (*FirmwareRelease, error) {
+	release, err := s.repository.GetRelease(ctx, releaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	annotations, err := mergeAnnotations(release.Annotations, patch)
+	if err != nil {
+		return nil, err
+	}
+	release.Annotations = annotations
+
+	if err := s.repository.UpdateRelease(ctx, release); err != nil {
+		return nil, fmt.Errorf("failed to update release: %w", err)
+	}
+
+	s.logger.Info("Updated release annotations", "release_id", releaseID, "annotations", len(annotations))
+
+	return release, nil
+}
+
+// AnnotateDeployment merges annotations into a deployment
+func (s *Service) AnnotateDeployment(ctx context.Context, deploymentID string, patch map[string]*string) (*OTADeployment, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	annotations, err := mergeAnnotations(deployment.Annotations, patch)
+	if err != nil {
+		return nil, err
+	}
+	deployment.Annotations = annotations
+	deployment.UpdatedAt = time.Now()
+
+	if err := s.repository.UpdateDeployment(ctx, deployment); err != nil {
+		return nil, fmt.Errorf("failed to update deployment: %w", err)
+	}
+
+	s.logger.Info("Updated deployment annotations", "deployment_id", deploymentID, "annotations", len(annotations))
+
+	return deployment, nil
+}
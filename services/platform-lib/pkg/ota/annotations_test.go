@@ -0,0 +1,123 @@
+package ota
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	assert.NoError(t, ValidateAnnotations(nil))
+	assert.NoError(t, ValidateAnnotations(map[string]string{
+		"ticket":              "OPS-1234",
+		"athena.io/policy-id": "policy-1",
+		"change-request":      "https://example.com/cr/42",
+	}))
+
+	assert.Error(t, ValidateAnnotations(map[string]string{"bad key": "x"}))
+	assert.Error(t, ValidateAnnotations(map[string]string{"-leading": "x"}))
+	assert.Error(t, ValidateAnnotations(map[string]string{"owner": ""}))
+	assert.Error(t, ValidateAnnotations(map[string]string{"notes": strings.Repeat("x", maxAnnotationValueBytes+1)}))
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxAnnotations; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	assert.Error(t, ValidateAnnotations(tooMany))
+}
+
+func TestAnnotationFilter(t *testing.T) {
+	filter, err := ParseAnnotationFilter([]string{"ticket=OPS-1", "owner"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ticket": "OPS-1", "owner": ""}, filter)
+
+	assert.True(t, MatchesAnnotations(map[string]string{"ticket": "OPS-1", "owner": "alice"}, filter))
+	assert.False(t, MatchesAnnotations(map[string]string{"ticket": "OPS-2", "owner": "alice"}, filter))
+	assert.False(t, MatchesAnnotations(map[string]string{"ticket": "OPS-1"}, filter))
+	assert.True(t, MatchesAnnotations(nil, nil))
+
+	_, err = ParseAnnotationFilter([]string{"=value"})
+	assert.Error(t, err)
+}
+
+func TestModels_AnnotationsRoundTrip(t *testing.T) {
+	release := &FirmwareRelease{ReleaseID: "release-001", Annotations: map[string]string{"ticket": "OPS-1"}}
+	releaseEntity, err := release.ToEntity()
+	require.NoError(t, err)
+	restoredRelease, err := releaseEntity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, release.Annotations, restoredRelease.Annotations)
+
+	deployment := &OTADeployment{DeploymentID: "deployment-001"}
+	deploymentEntity, err := deployment.ToEntity()
+	require.NoError(t, err)
+	assert.Empty(t, deploymentEntity.AnnotationsJSON, "no annotations are stored as an empty property")
+	restoredDeployment, err := deploymentEntity.FromEntity()
+	require.NoError(t, err)
+	assert.Nil(t, restoredDeployment.Annotations)
+}
+
+func patchAnnotations(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestService_AnnotationRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, mockRepo, _, _ := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	release := &FirmwareRelease{ReleaseID: "release-001", TemplateID: "template-001", Annotations: map[string]string{"ticket": "OPS-1", "owner": "alice"}}
+	other := &FirmwareRelease{ReleaseID: "release-002", TemplateID: "template-001"}
+	deployment := &OTADeployment{DeploymentID: "deployment-001", ReleaseID: "release-001", Annotations: map[string]string{"ticket": "OPS-1"}}
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannel("")).Return([]*FirmwareRelease{release, other}, nil)
+	mockRepo.On("UpdateRelease", mock.Anything, release).Return(nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-001").Return([]*OTADeployment{deployment}, nil)
+
+	// Merge: add one key, remove another
+	w := patchAnnotations(router, "/api/v1/ota/releases/release-001/annotations", `{"annotations": {"change-request": "CR-9", "owner": null}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]string{"ticket": "OPS-1", "change-request": "CR-9"}, release.Annotations)
+
+	w = patchAnnotations(router, "/api/v1/ota/releases/release-001/annotations", `{"annotations": {"bad key": "x"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases?template_id=template-001&annotation=ticket=OPS-1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var releases struct {
+		Releases []*FirmwareRelease `json:"releases"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &releases))
+	require.Len(t, releases.Releases, 1)
+	assert.Equal(t, "release-001", releases.Releases[0].ReleaseID)
+
+	w = patchAnnotations(router, "/api/v1/ota/deployments/deployment-001/annotations", `{"annotations": {"rollout-owner": "bob"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "bob", deployment.Annotations["rollout-owner"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments?release_id=release-001&annotation=rollout-owner=bob", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments?release_id=release-001&annotation=rollout-owner=carol", nil))
+	assert.Contains(t, w.Body.String(), `"total":0`)
+}
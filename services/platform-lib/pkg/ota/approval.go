@@ -0,0 +1,133 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNotPendingApproval is returned when approving or rejecting a
+// deployment that is not awaiting approval
+var ErrNotPendingApproval = errors.New("deployment is not awaiting approval")
+
+// ApprovalRequest is the body of approve and reject calls. Approver names
+// the principal making the decision.
+type ApprovalRequest struct {
+	Approver string `json:"approver" binding:"required"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// approvalRequired reports whether a deployment of the release waits for
+// approval. Rollbacks restore a release that already went out, so they
+// never wait.
+func (s *Service) approvalRequired(release *FirmwareRelease, config *DeploymentConfig) bool {
+	if s.config == nil {
+		return false
+	}
+	if _, rollback := config.Annotations[AnnotationRollbackOf]; rollback {
+		return false
+	}
+	for _, channel := range s.config.Approval.Channels {
+		if ReleaseChannel(channel) == release.Channel {
+			return true
+		}
+	}
+	return false
+}
+
+// ApproveDeployment approves a deployment awaiting approval, then creates
+// its device updates and starts it as DeployRelease would have
+func (s *Service) ApproveDeployment(ctx context.Context, deploymentID string, req *ApprovalRequest) (*OTADeployment, error) {
+	deployment, err := s.decideApproval(ctx, deploymentID, req, ApprovalApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.startDeployment(ctx, deployment); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Approved deployment", "deployment_id", deploymentID, "approver", deployment.Approval.Approver, "status", deployment.Status)
+
+	return deployment, nil
+}
+
+// RejectDeployment turns down a deployment awaiting approval. No device
+// updates are created and the deployment cannot be approved afterwards.
+func (s *Service) RejectDeployment(ctx context.Context, deploymentID string, req *ApprovalRequest) (*OTADeployment, error) {
+	deployment, err := s.decideApproval(ctx, deploymentID, req, ApprovalRejected)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Rejected deployment", "deployment_id", deploymentID, "approver", deployment.Approval.Approver, "reason", deployment.Approval.Reason)
+
+	return deployment, nil
+}
+
+// decideApproval records the decision on a deployment awaiting approval.
+// Only the first decision is recorded; later ones get ErrNotPendingApproval.
+func (s *Service) decideApproval(ctx context.Context, deploymentID string, req *ApprovalRequest, decision ApprovalDecision) (*OTADeployment, error) {
+	if req == nil || strings.TrimSpace(req.Approver) == "" {
+		return nil, fmt.Errorf("approver is required")
+	}
+
+	status := DeploymentStatusPending
+	if decision == ApprovalRejected {
+		status = DeploymentStatusRejected
+	}
+
+	deployment, err := s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		if d.Status != DeploymentStatusPendingApproval {
+			return fmt.Errorf("%w, current status: %s", ErrNotPendingApproval, d.Status)
+		}
+		now := time.Now()
+		d.Approval = &Approval{
+			Approver:  strings.TrimSpace(req.Approver),
+			Decision:  decision,
+			Reason:    req.Reason,
+			DecidedAt: now,
+		}
+		d.Status = status
+		d.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s decision: %w", decision, err)
+	}
+	return deployment, nil
+}
+
+func (s *Service) approveDeploymentHandler(c *gin.Context) {
+	s.approvalHandler(c, s.ApproveDeployment)
+}
+
+func (s *Service) rejectDeploymentHandler(c *gin.Context) {
+	s.approvalHandler(c, s.RejectDeployment)
+}
+
+// approvalHandler binds an approval request and applies the decision
+func (s *Service) approvalHandler(c *gin.Context, decide func(context.Context, string, *ApprovalRequest) (*OTADeployment, error)) {
+	var req ApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployment, err := decide(c.Request.Context(), c.Param("deploymentId"), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotPendingApproval) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deployment)
+}
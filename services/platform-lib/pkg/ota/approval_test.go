@@ -0,0 +1,109 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_DeployRelease_AwaitsApproval(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	service.config.Approval.Channels = []string{"stable"}
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	release := createTestRelease("release-001")
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return([]*device.Device{
+		{DeviceID: "device-001"},
+		{DeviceID: "device-002"},
+	}, nil)
+	var stored *OTADeployment
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*OTADeployment)
+	}).Return(nil)
+
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{Strategy: DeploymentStrategyImmediate})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStatusPendingApproval, deployment.Status)
+	mockRepo.AssertNotCalled(t, "CreateDeviceUpdate", mock.Anything, mock.Anything)
+
+	decide := func(action string, body interface{}) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/ota/deployments/"+stored.DeploymentID+"/"+action, bytes.NewReader(payload)))
+		return w
+	}
+
+	// The approver principal is required
+	w := decide("approve", map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockRepo.On("GetDeployment", mock.Anything, stored.DeploymentID).Return(stored, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, stored).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil).Times(2)
+	mockRepo.On("CountDeviceUpdatesByStatus", mock.Anything, stored.DeploymentID).Return(map[UpdateStatus]int{UpdateStatusPending: 2}, nil)
+	mockRepo.On("ListCrashReportsForDeployment", mock.Anything, stored.DeploymentID).Return([]*CrashReport{}, nil)
+
+	w = decide("approve", ApprovalRequest{Approver: "alice"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, DeploymentStatusActive, stored.Status)
+	mockRepo.AssertNumberOfCalls(t, "CreateDeviceUpdate", 2)
+
+	report, err := service.GetDeploymentStatus(context.Background(), stored.DeploymentID)
+	require.NoError(t, err)
+	require.NotNil(t, report.Approval)
+	assert.Equal(t, "alice", report.Approval.Approver)
+	assert.Equal(t, ApprovalApproved, report.Approval.Decision)
+	assert.False(t, report.Approval.DecidedAt.IsZero())
+
+	// A deployment is decided once
+	w = decide("reject", ApprovalRequest{Approver: "bob"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "alice", stored.Approval.Approver)
+}
+
+func TestService_RejectDeployment(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+
+	deployment := &OTADeployment{DeploymentID: "deployment-001", ReleaseID: "release-001", Status: DeploymentStatusPendingApproval}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+
+	rejected, err := service.RejectDeployment(context.Background(), "deployment-001", &ApprovalRequest{Approver: "bob", Reason: "change freeze"})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStatusRejected, rejected.Status)
+	assert.Equal(t, &Approval{Approver: "bob", Decision: ApprovalRejected, Reason: "change freeze", DecidedAt: rejected.Approval.DecidedAt}, rejected.Approval)
+	assert.False(t, rejected.issuesUpdates())
+	mockRepo.AssertNotCalled(t, "CreateDeviceUpdate", mock.Anything, mock.Anything)
+
+	_, err = service.ApproveDeployment(context.Background(), "deployment-001", &ApprovalRequest{Approver: "alice"})
+	assert.ErrorIs(t, err, ErrNotPendingApproval)
+}
+
+// Rollbacks and releases on other channels start without approval
+func TestService_ApprovalRequired(t *testing.T) {
+	service, _, _, _ := setupDeploymentTestService()
+	service.config.Approval.Channels = []string{"stable"}
+
+	stable := createTestRelease("release-001")
+	beta := createTestRelease("release-002")
+	beta.Channel = ReleaseChannelBeta
+
+	assert.True(t, service.approvalRequired(stable, &DeploymentConfig{}))
+	assert.False(t, service.approvalRequired(beta, &DeploymentConfig{}))
+	assert.False(t, service.approvalRequired(stable, &DeploymentConfig{Annotations: map[string]string{AnnotationRollbackOf: "deployment-001"}}))
+
+	service.config.Approval.Channels = nil
+	assert.False(t, service.approvalRequired(stable, &DeploymentConfig{}))
+}
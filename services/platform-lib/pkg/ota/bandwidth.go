@@ -0,0 +1,186 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/athena/platform-lib/pkg/metering"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidDownloadReport is returned for download reports without a
+// positive byte count
+var ErrInvalidDownloadReport = errors.New("invalid download report")
+
+// ReleaseBandwidth is the data served for a release, by deployment
+type ReleaseBandwidth struct {
+	ReleaseID   string                 `json:"release_id"`
+	BytesServed int64                  `json:"bytes_served"`
+	Deployments []*DeploymentBandwidth `json:"deployments"`
+}
+
+// DeploymentBandwidth is the data served for one deployment of a release
+type DeploymentBandwidth struct {
+	DeploymentID    string           `json:"deployment_id"`
+	Status          DeploymentStatus `json:"status"`
+	BytesServed     int64            `json:"bytes_served"`
+	DataBudgetBytes int64            `json:"data_budget_bytes,omitempty"`
+}
+
+// ReportDownload records the bytes a device fetched for its update. Once a
+// device reports downloads, only its reports are counted for the update.
+func (s *Service) ReportDownload(ctx context.Context, report *DownloadReport) error {
+	if report.Bytes <= 0 {
+		return fmt.Errorf("%w: bytes must be positive", ErrInvalidDownloadReport)
+	}
+
+	update, err := s.repository.GetDeviceUpdate(ctx, report.DeviceID, report.ReleaseID)
+	if err != nil {
+		return fmt.Errorf("failed to get device update: %w", err)
+	}
+	update.BytesServed += report.Bytes
+	if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+		return fmt.Errorf("failed to update device update: %w", err)
+	}
+
+	s.accountBytes(ctx, update, report.Bytes)
+	return nil
+}
+
+// accountDownload counts a finished download of a device that does not
+// report its downloads as the size of the release binary in storage
+func (s *Service) accountDownload(ctx context.Context, update *DeviceUpdate) {
+	if update.BytesServed > 0 {
+		return
+	}
+	release, err := s.repository.GetRelease(ctx, update.ReleaseID)
+	if err != nil {
+		s.logger.Warn("Failed to account firmware download", "device_id", update.DeviceID, "release_id", update.ReleaseID, "error", err)
+		return
+	}
+	s.accountBytes(ctx, update, release.BinarySize)
+}
+
+// accountBytes adds bytes served to a device to its deployment's total and
+// to OTA usage metering, and raises an alert when the deployment goes over
+// its data budget
+func (s *Service) accountBytes(ctx context.Context, update *DeviceUpdate, bytes int64) {
+	if s.usage != nil {
+		s.usage.AddForDevice(update.DeviceID, metering.MeterOTABytes, float64(bytes))
+	}
+
+	var overBudget bool
+	deployment, err := s.repository.ModifyDeployment(ctx, update.DeploymentID, func(d *OTADeployment) error {
+		before := d.BytesServed
+		d.BytesServed += bytes
+		overBudget = d.DataBudgetBytes > 0 && before <= d.DataBudgetBytes && d.BytesServed > d.DataBudgetBytes
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("Failed to account deployment bandwidth", "deployment_id", update.DeploymentID, "error", err)
+		return
+	}
+
+	if overBudget {
+		s.logger.Warn("Deployment over data budget", "deployment_id", deployment.DeploymentID, "bytes_served", deployment.BytesServed, "data_budget_bytes", deployment.DataBudgetBytes)
+		s.notifyOverBudget(ctx, deployment)
+	}
+}
+
+// notifyOverBudget tells a deployment's owner it has served more data than
+// its budget. Only the download that crosses the budget notifies.
+func (s *Service) notifyOverBudget(ctx context.Context, deployment *OTADeployment) {
+	if s.publisher == nil {
+		return
+	}
+
+	notifications.PublishAsync(s.publisher, s.logger, &notifications.Event{
+		Type:         notifications.EventDeploymentOverBudget,
+		ResourceType: "deployment",
+		ResourceID:   deployment.DeploymentID,
+		Owner:        s.deploymentOwner(ctx, deployment),
+		Message: fmt.Sprintf("Deployment %s has served %d bytes, over its data budget of %d",
+			deployment.DeploymentID, deployment.BytesServed, deployment.DataBudgetBytes),
+		Data: map[string]interface{}{
+			"release_id":        deployment.ReleaseID,
+			"bytes_served":      deployment.BytesServed,
+			"data_budget_bytes": deployment.DataBudgetBytes,
+		},
+	})
+}
+
+// GetReleaseBandwidth totals the data served for a release over its
+// deployments
+func (s *Service) GetReleaseBandwidth(ctx context.Context, releaseID string) (*ReleaseBandwidth, error) {
+	if _, err := s.repository.GetRelease(ctx, releaseID); err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	deployments, err := s.repository.ListDeployments(ctx, releaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	bandwidth := &ReleaseBandwidth{ReleaseID: releaseID, Deployments: []*DeploymentBandwidth{}}
+	for _, deployment := range deployments {
+		bandwidth.BytesServed += deployment.BytesServed
+		bandwidth.Deployments = append(bandwidth.Deployments, &DeploymentBandwidth{
+			DeploymentID:    deployment.DeploymentID,
+			Status:          deployment.Status,
+			BytesServed:     deployment.BytesServed,
+			DataBudgetBytes: deployment.DataBudgetBytes,
+		})
+	}
+	return bandwidth, nil
+}
+
+func (s *Service) getReleaseBandwidthHandler(c *gin.Context) {
+	releaseID := c.Param("releaseId")
+
+	bandwidth, err := s.GetReleaseBandwidth(c.Request.Context(), releaseID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bandwidth)
+}
+
+// reportDownloadHandler records bytes a device downloaded for its update.
+// Reports are authenticated and rate limited like update status reports.
+func (s *Service) reportDownloadHandler(c *gin.Context) {
+	var report DownloadReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deviceID := c.Param("deviceId")
+	if report.DeviceID != "" && report.DeviceID != deviceID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id does not match the reporting device"})
+		return
+	}
+	report.DeviceID = deviceID
+
+	if err := s.authenticateDevice(c.Request, report.DeviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.reportLimits.Allow(report.DeviceID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": ErrReportRateLimited.Error()})
+		return
+	}
+
+	if err := s.ReportDownload(c.Request.Context(), &report); err != nil {
+		if errors.Is(err, ErrInvalidDownloadReport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "download reported successfully"})
+}
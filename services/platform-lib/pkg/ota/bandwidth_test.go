@@ -0,0 +1,145 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ReportDownload_DataBudget(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	ctx := context.Background()
+	events := make(channelPublisher, 2)
+	service.publisher = events
+
+	deployment := &OTADeployment{DeploymentID: "deployment-001", ReleaseID: "release-001", Status: DeploymentStatusActive, DataBudgetBytes: 1000}
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending, Attempts: 1}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(0, 0, 1, nil)
+
+	report := func(size int64) {
+		require.NoError(t, service.ReportDownload(ctx, &DownloadReport{DeviceID: "device-001", ReleaseID: "release-001", Bytes: size}))
+	}
+
+	report(600)
+	assert.Equal(t, int64(600), deployment.BytesServed)
+	assert.Empty(t, events)
+
+	// Crossing the budget alerts once
+	report(600)
+	select {
+	case event := <-events:
+		assert.Equal(t, notifications.EventDeploymentOverBudget, event.Type)
+		assert.Equal(t, "deployment-001", event.ResourceID)
+		assert.Equal(t, int64(1200), event.Data["bytes_served"])
+	case <-time.After(time.Second):
+		t.Fatal("expected a deployment.over_budget notification")
+	}
+	report(100)
+	assert.Equal(t, int64(1300), deployment.BytesServed)
+	assert.Equal(t, int64(1300), update.BytesServed)
+
+	// The device reports its own downloads, so finishing one adds nothing
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusInstalling}))
+	assert.Equal(t, int64(1300), deployment.BytesServed)
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected %s notification", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err := service.ReportDownload(ctx, &DownloadReport{DeviceID: "device-001", ReleaseID: "release-001", Bytes: -1})
+	assert.ErrorIs(t, err, ErrInvalidDownloadReport)
+}
+
+// Devices that do not report downloads are charged the binary size
+func TestService_ReportUpdateStatus_AccountsBinarySize(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	ctx := context.Background()
+
+	release := createTestRelease("release-001")
+	release.BinarySize = 4096
+	deployment := &OTADeployment{DeploymentID: "deployment-001", ReleaseID: "release-001", Status: DeploymentStatusActive, BytesServed: 100}
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusDownloading}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(1, 0, 0, nil)
+
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusCompleted, Progress: 100}))
+	assert.Equal(t, int64(4196), deployment.BytesServed)
+	assert.Zero(t, update.BytesServed)
+}
+
+func TestService_ReportUpdateStatus_RecordsDeviceEvent(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	events := device.NewMemoryEventStore()
+	service.SetDeviceEvents(device.NewEventRecorder(events, "ota-service", service.logger))
+	ctx := context.Background()
+
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusInstalling}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{DeploymentID: "deployment-001", Status: DeploymentStatusActive}, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(1, 0, 0, nil)
+
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusCompleted, Progress: 100}))
+	// Repeated reports of the completion are not recorded again
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusCompleted, Progress: 100}))
+
+	recorded, _, err := events.QueryEvents(ctx, "device-001", &device.DeviceEventQuery{})
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+	assert.Equal(t, device.DeviceEventFirmwareUpdated, recorded[0].Type)
+	assert.Equal(t, "ota-service", recorded[0].Source)
+	assert.Equal(t, "deployment-001", recorded[0].Data["deployment_id"])
+	assert.Equal(t, createTestRelease("release-001").Version, recorded[0].Data["version"])
+}
+
+func TestService_GetReleaseBandwidth(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-001").Return([]*OTADeployment{
+		{DeploymentID: "deployment-001", Status: DeploymentStatusCompleted, BytesServed: 3000},
+		{DeploymentID: "deployment-002", Status: DeploymentStatusActive, BytesServed: 500, DataBudgetBytes: 10000},
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-404").Return(nil, assert.AnError)
+
+	bandwidth, err := service.GetReleaseBandwidth(context.Background(), "release-001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3500), bandwidth.BytesServed)
+	require.Len(t, bandwidth.Deployments, 2)
+	assert.Equal(t, int64(10000), bandwidth.Deployments[1].DataBudgetBytes)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases/release-404/bandwidth", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/ota/devices/device-001/updates/downloaded",
+		bytes.NewBufferString(`{"device_id":"device-002","release_id":"release-001","bytes":10}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,232 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrInvalidRange is returned for byte ranges that cannot be parsed
+	ErrInvalidRange = errors.New("invalid byte range")
+
+	// ErrRangeNotSatisfiable is returned for byte ranges that start past
+	// the end of the binary
+	ErrRangeNotSatisfiable = errors.New("byte range not satisfiable")
+)
+
+// unsatisfiableRange is an ErrRangeNotSatisfiable carrying the size of the
+// binary, which 416 responses report
+type unsatisfiableRange struct {
+	offset int64
+	size   int64
+}
+
+func (e *unsatisfiableRange) Error() string {
+	return fmt.Sprintf("%s: offset %d of a %d byte binary", ErrRangeNotSatisfiable, e.offset, e.size)
+}
+
+func (e *unsatisfiableRange) Unwrap() error {
+	return ErrRangeNotSatisfiable
+}
+
+// ByteRange is a requested part of a binary. A negative Start asks for the
+// last Length bytes; a Length of zero asks for everything from Start.
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+// resolve returns the offset and length of the range within a binary of
+// size bytes. Ranges running past the end are cut short.
+func (r *ByteRange) resolve(size int64) (int64, int64, error) {
+	if r == nil {
+		return 0, size, nil
+	}
+	offset, length := r.Start, r.Length
+	if offset < 0 {
+		offset = max(size-length, 0)
+	}
+	if offset >= size {
+		return 0, 0, &unsatisfiableRange{offset: offset, size: size}
+	}
+	if length <= 0 || length > size-offset {
+		length = size - offset
+	}
+	return offset, length, nil
+}
+
+// parseRangeHeader parses a single range Range header, such as
+// bytes=0-1023, bytes=1024- or bytes=-512
+func parseRangeHeader(header string) (*ByteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, fmt.Errorf("%w: only a single bytes range is supported", ErrInvalidRange)
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+		}
+		return &ByteRange{Start: -1, Length: suffix}, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+	}
+	if last == "" {
+		return &ByteRange{Start: start}, nil
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRange, header)
+	}
+	return &ByteRange{Start: start, Length: end - start + 1}, nil
+}
+
+// parseRangeQuery parses the offset and length query parameters; neither
+// being set asks for the whole binary
+func parseRangeQuery(offsetParam, lengthParam string) (*ByteRange, error) {
+	if offsetParam == "" && lengthParam == "" {
+		return nil, nil
+	}
+	var r ByteRange
+	if offsetParam != "" {
+		offset, err := strconv.ParseInt(offsetParam, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w: offset must be a non-negative number", ErrInvalidRange)
+		}
+		r.Start = offset
+	}
+	if lengthParam != "" {
+		length, err := strconv.ParseInt(lengthParam, 10, 64)
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("%w: length must be a positive number", ErrInvalidRange)
+		}
+		r.Length = length
+	}
+	return &r, nil
+}
+
+// BinaryChunk is part of a release binary. Size and Hash are of the whole
+// binary, or of its compressed copy.
+type BinaryChunk struct {
+	ReleaseID string
+	Encoding  string
+	Offset    int64
+	Data      []byte
+	Size      int64
+	Hash      string
+}
+
+// ReadBinaryChunk reads a range of a release's binary, or of its copy
+// compressed with encoding, from storage. A nil range reads all of it.
+// Chunks read for a device advance the download progress of its update to
+// the release.
+func (s *Service) ReadBinaryChunk(ctx context.Context, releaseID, encoding, deviceID string, r *ByteRange) (*BinaryChunk, error) {
+	release, err := s.repository.GetRelease(ctx, releaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	path, size, hash := release.BinaryPath, release.BinarySize, release.BinaryHash
+	if encoding != "" {
+		encoded := negotiateEncoding(release.Encodings, []string{encoding})
+		if encoded == nil {
+			return nil, fmt.Errorf("release %s has no %s binary", releaseID, encoding)
+		}
+		path, size, hash = encoded.Path, encoded.Size, encoded.Hash
+	}
+
+	offset, length, err := r.resolve(size)
+	if err != nil {
+		return nil, err
+	}
+	data, stored, err := s.storageBackend.GetBinaryRange(ctx, path, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary: %w", err)
+	}
+
+	if deviceID != "" {
+		s.recordDownloadProgress(ctx, deviceID, releaseID, offset+int64(len(data)))
+	}
+
+	return &BinaryChunk{
+		ReleaseID: releaseID,
+		Encoding:  encoding,
+		Offset:    offset,
+		Data:      data,
+		Size:      stored,
+		Hash:      hash,
+	}, nil
+}
+
+// recordDownloadProgress moves a device's update to a release on to end,
+// the furthest byte served to it. Devices without an update in progress
+// are not tracked.
+func (s *Service) recordDownloadProgress(ctx context.Context, deviceID, releaseID string, end int64) {
+	update, err := s.repository.GetDeviceUpdate(ctx, deviceID, releaseID)
+	if err != nil || update.finished() || end <= update.Downloaded {
+		return
+	}
+	update.Downloaded = end
+	if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+		s.logger.Warn("Failed to record download progress", "device_id", deviceID, "release_id", releaseID, "error", err)
+	}
+}
+
+// downloadBinaryHandler serves a release binary, whole or in chunks chosen
+// with a Range header or the offset and length query parameters, so that
+// devices on flaky links can resume downloads. Devices pass device_id to
+// have their progress tracked.
+func (s *Service) downloadBinaryHandler(c *gin.Context) {
+	deviceID := c.Query("device_id")
+	if err := s.authenticateDevice(c.Request, deviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A Range header takes precedence over the query parameters
+	r, err := parseRangeQuery(c.Query("offset"), c.Query("length"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if header := c.GetHeader("Range"); header != "" {
+		if r, err = parseRangeHeader(header); err != nil {
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	chunk, err := s.ReadBinaryChunk(c.Request.Context(), c.Param("releaseId"), c.Query("encoding"), deviceID, r)
+	if err != nil {
+		var unsatisfiable *unsatisfiableRange
+		if errors.As(err, &unsatisfiable) {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", unsatisfiable.size))
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", strconv.Quote(chunk.Hash))
+	status := http.StatusOK
+	if r != nil {
+		status = http.StatusPartialContent
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", chunk.Offset, chunk.Offset+int64(len(chunk.Data))-1, chunk.Size))
+	}
+	c.Data(status, "application/octet-stream", chunk.Data)
+}
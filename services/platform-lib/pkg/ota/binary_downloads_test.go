@@ -0,0 +1,149 @@
+package ota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorageBackend_GetBinaryRange(t *testing.T) {
+	storage, err := NewLocalStorageBackend(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	path, err := storage.StoreBinary(ctx, "release-001", []byte("0123456789"))
+	require.NoError(t, err)
+
+	data, size, err := storage.GetBinaryRange(ctx, path, 2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "2345", string(data))
+	assert.Equal(t, int64(10), size)
+
+	// Reads past the end are cut short
+	data, _, err = storage.GetBinaryRange(ctx, path, 8, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "89", string(data))
+
+	_, _, err = storage.GetBinaryRange(ctx, path, 11, 1)
+	assert.Error(t, err)
+}
+
+func TestParseRangeHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *ByteRange
+	}{
+		{"bytes=0-1023", &ByteRange{Start: 0, Length: 1024}},
+		{"bytes=1024-", &ByteRange{Start: 1024}},
+		{"bytes=-512", &ByteRange{Start: -1, Length: 512}},
+		{"bytes=10-5", nil},
+		{"bytes=0-1,4-5", nil},
+		{"items=0-10", nil},
+		{"bytes=abc-", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRangeHeader(tt.header)
+		if tt.want == nil {
+			assert.ErrorIs(t, err, ErrInvalidRange, tt.header)
+			continue
+		}
+		require.NoError(t, err, tt.header)
+		assert.Equal(t, tt.want, got, tt.header)
+	}
+}
+
+func TestByteRange_Resolve(t *testing.T) {
+	offset, length, err := (&ByteRange{Start: -1, Length: 100}).resolve(1024)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{924, 100}, []int64{offset, length})
+
+	offset, length, err = (&ByteRange{Start: 1000, Length: 100}).resolve(1024)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1000, 24}, []int64{offset, length})
+
+	_, _, err = (&ByteRange{Start: 1024}).resolve(1024)
+	assert.ErrorIs(t, err, ErrRangeNotSatisfiable)
+}
+
+func TestDownloadBinaryHandler_Range(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	release := createTestRelease("release-001")
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockStorage.On("GetBinaryRange", mock.Anything, release.BinaryPath, int64(512), int64(256)).Return(make([]byte, 256), int64(1024), nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(&DeviceUpdate{
+		DeviceID:   "device-001",
+		ReleaseID:  "release-001",
+		Status:     UpdateStatusDownloading,
+		Downloaded: 512,
+	}, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.MatchedBy(func(u *DeviceUpdate) bool {
+		return u.Downloaded == 768
+	})).Return(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases/release-001/binary?device_id=device-001", nil)
+	req.Header.Set("Range", "bytes=512-767")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 512-767/1024", w.Header().Get("Content-Range"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, 256, w.Body.Len())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDownloadBinaryHandler_Query(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	release := createTestRelease("release-001")
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockStorage.On("GetBinaryRange", mock.Anything, release.BinaryPath, int64(1000), int64(24)).Return(make([]byte, 24), int64(1024), nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases/release-001/binary?offset=1000&length=4096", nil))
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 1000-1023/1024", w.Header().Get("Content-Range"))
+
+	// Offsets past the end report the binary's size
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases/release-001/binary?offset=2048", nil))
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */1024", w.Header().Get("Content-Range"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/releases/release-001/binary?offset=-5", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Progress is only tracked for devices
+	mockRepo.AssertNotCalled(t, "GetDeviceUpdate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRecordDownloadProgress_IgnoresStaleChunks(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(&DeviceUpdate{
+		Status:     UpdateStatusDownloading,
+		Downloaded: 1024,
+	}, nil).Once()
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-002", "release-001").Return(&DeviceUpdate{
+		Status: UpdateStatusCompleted,
+	}, nil).Once()
+
+	// A re-fetched earlier chunk and a finished update leave progress as is
+	service.recordDownloadProgress(context.Background(), "device-001", "release-001", 512)
+	service.recordDownloadProgress(context.Background(), "device-002", "release-001", 512)
+
+	mockRepo.AssertNotCalled(t, "UpdateDeviceUpdate", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,64 @@
+package ota
+
+import (
+	"context"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/cache"
+)
+
+// CachedRepository is a Repository that reads firmware releases through a
+// cache, sparing the update check a Datastore read per device. Releases
+// only change when annotated, re-signed or deleted, and those writes
+// invalidate the entry. Everything else goes straight to the underlying
+// repository.
+type CachedRepository struct {
+	Repository
+	store cache.Store
+	ttl   time.Duration
+}
+
+// NewCachedRepository wraps repo with a read-through release cache. A zero
+// ttl disables caching.
+func NewCachedRepository(repo Repository, store cache.Store, ttl time.Duration) *CachedRepository {
+	return &CachedRepository{Repository: repo, store: store, ttl: ttl}
+}
+
+// GetRelease returns a release from the cache, loading it on a miss
+func (r *CachedRepository) GetRelease(ctx context.Context, releaseID string) (*FirmwareRelease, error) {
+	if r.ttl <= 0 {
+		return r.Repository.GetRelease(ctx, releaseID)
+	}
+
+	key := cache.GenerateReleaseKey(releaseID)
+	var release FirmwareRelease
+	if err := r.store.Get(ctx, key, &release); err == nil {
+		return &release, nil
+	}
+
+	loaded, err := r.Repository.GetRelease(ctx, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	// A failed cache write only costs the next lookup a read
+	_ = r.store.Set(ctx, key, loaded, r.ttl)
+	return loaded, nil
+}
+
+// UpdateRelease updates a release and drops its cached copy
+func (r *CachedRepository) UpdateRelease(ctx context.Context, release *FirmwareRelease) error {
+	defer r.invalidate(ctx, release.ReleaseID)
+	return r.Repository.UpdateRelease(ctx, release)
+}
+
+// DeleteRelease deletes a release and drops its cached copy
+func (r *CachedRepository) DeleteRelease(ctx context.Context, releaseID string) error {
+	defer r.invalidate(ctx, releaseID)
+	return r.Repository.DeleteRelease(ctx, releaseID)
+}
+
+func (r *CachedRepository) invalidate(ctx context.Context, releaseID string) {
+	if r.ttl > 0 {
+		_ = r.store.Delete(ctx, cache.GenerateReleaseKey(releaseID))
+	}
+}
@@ -0,0 +1,33 @@
+package ota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedRepository_Releases(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockRepository)
+	release := &FirmwareRelease{ReleaseID: "release-1", Version: "1.0.0", Signature: "sig", Annotations: map[string]string{"ticket": "OPS-1"}}
+	mockRepo.On("GetRelease", ctx, "release-1").Return(release, nil)
+	mockRepo.On("UpdateRelease", ctx, release).Return(nil)
+	repo := NewCachedRepository(mockRepo, cache.NewMemoryStore(0), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got, err := repo.GetRelease(ctx, "release-1")
+		require.NoError(t, err)
+		assert.Equal(t, release, got)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetRelease", 1)
+
+	// Re-signing or annotating a release invalidates it
+	require.NoError(t, repo.UpdateRelease(ctx, release))
+	_, err := repo.GetRelease(ctx, "release-1")
+	require.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "GetRelease", 2)
+}
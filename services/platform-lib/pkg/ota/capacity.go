@@ -0,0 +1,109 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/device"
+)
+
+// SkipReason is why a deployment left out a device it targeted
+type SkipReason string
+
+const (
+	// SkipReasonInsufficientFlash marks devices whose flash is smaller
+	// than the release binary
+	SkipReasonInsufficientFlash SkipReason = "insufficient_flash"
+	// SkipReasonInsufficientOTASpace marks devices whose OTA partition has
+	// less free space than the release binary
+	SkipReasonInsufficientOTASpace SkipReason = "insufficient_ota_space"
+)
+
+// SkippedDevice is a targeted device a deployment did not enroll, so that
+// it is not left to fail mid-download
+type SkippedDevice struct {
+	DeviceID string     `json:"device_id"`
+	Reason   SkipReason `json:"reason"`
+	Detail   string     `json:"detail"`
+}
+
+// capacityShortfall returns the reason dev cannot hold release's binary.
+// Devices that have not reported their storage are assumed to have room.
+func capacityShortfall(dev *device.Device, release *FirmwareRelease) *SkippedDevice {
+	switch {
+	case release.BinarySize <= 0:
+		return nil
+	case dev.OTAFreeSpace > 0 && dev.OTAFreeSpace < release.BinarySize:
+		return &SkippedDevice{
+			DeviceID: dev.DeviceID,
+			Reason:   SkipReasonInsufficientOTASpace,
+			Detail:   fmt.Sprintf("OTA partition has %d bytes free, release needs %d", dev.OTAFreeSpace, release.BinarySize),
+		}
+	case dev.FlashSize > 0 && dev.FlashSize < release.BinarySize:
+		return &SkippedDevice{
+			DeviceID: dev.DeviceID,
+			Reason:   SkipReasonInsufficientFlash,
+			Detail:   fmt.Sprintf("flash is %d bytes, release needs %d", dev.FlashSize, release.BinarySize),
+		}
+	}
+	return nil
+}
+
+// splitByCapacity splits devices into the IDs of those with room for
+// release's binary and those skipped for lack of it
+func splitByCapacity(devices []*device.Device, release *FirmwareRelease) ([]string, []*SkippedDevice) {
+	var fitting []string
+	var skipped []*SkippedDevice
+	for _, dev := range devices {
+		if shortfall := capacityShortfall(dev, release); shortfall != nil {
+			skipped = append(skipped, shortfall)
+			continue
+		}
+		fitting = append(fitting, dev.DeviceID)
+	}
+	return fitting, skipped
+}
+
+// excludeUndersized looks up the devices of targets and splits them like
+// splitByCapacity. Devices that cannot be looked up are kept.
+func (s *Service) excludeUndersized(ctx context.Context, release *FirmwareRelease, targets []string) ([]string, []*SkippedDevice) {
+	if len(targets) == 0 || s.deviceRepository == nil || release.BinarySize <= 0 {
+		return targets, nil
+	}
+
+	kept := make([]string, 0, len(targets))
+	var skipped []*SkippedDevice
+	for _, deviceID := range targets {
+		dev, err := s.deviceRepository.GetDevice(ctx, deviceID)
+		if err != nil {
+			s.logger.Warn("Failed to check device storage", "device_id", deviceID, "error", err)
+			kept = append(kept, deviceID)
+			continue
+		}
+		if shortfall := capacityShortfall(dev, release); shortfall != nil {
+			skipped = append(skipped, shortfall)
+			continue
+		}
+		kept = append(kept, deviceID)
+	}
+	return kept, skipped
+}
+
+// mergeSkipped records the devices of extra as skipped, replacing earlier
+// records of them, and drops the records of devices now among targets
+func mergeSkipped(skipped, extra []*SkippedDevice, targets []string) []*SkippedDevice {
+	drop := make(map[string]bool, len(extra)+len(targets))
+	for _, deviceID := range targets {
+		drop[deviceID] = true
+	}
+	for _, s := range extra {
+		drop[s.DeviceID] = true
+	}
+	merged := make([]*SkippedDevice, 0, len(skipped)+len(extra))
+	for _, s := range skipped {
+		if !drop[s.DeviceID] {
+			merged = append(merged, s)
+		}
+	}
+	return append(merged, extra...)
+}
@@ -0,0 +1,87 @@
+package ota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapacityShortfall(t *testing.T) {
+	release := &FirmwareRelease{BinarySize: 1024}
+
+	assert.Nil(t, capacityShortfall(&device.Device{DeviceID: "unreported"}, release))
+	assert.Nil(t, capacityShortfall(&device.Device{DeviceID: "roomy", FlashSize: 4096, OTAFreeSpace: 2048}, release))
+	assert.Nil(t, capacityShortfall(&device.Device{DeviceID: "small"}, &FirmwareRelease{}))
+
+	// The free OTA partition is what the download has to fit into
+	shortfall := capacityShortfall(&device.Device{DeviceID: "full", FlashSize: 4096, OTAFreeSpace: 512}, release)
+	require.NotNil(t, shortfall)
+	assert.Equal(t, SkipReasonInsufficientOTASpace, shortfall.Reason)
+	assert.Equal(t, "OTA partition has 512 bytes free, release needs 1024", shortfall.Detail)
+
+	shortfall = capacityShortfall(&device.Device{DeviceID: "tiny", FlashSize: 1000}, release)
+	require.NotNil(t, shortfall)
+	assert.Equal(t, SkipReasonInsufficientFlash, shortfall.Reason)
+}
+
+func TestService_DeployRelease_SkipsUndersizedDevices(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	release := createTestRelease("release-001")
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return([]*device.Device{
+		{DeviceID: "device-001", FlashSize: 4096, OTAFreeSpace: 2048},
+		{DeviceID: "device-002", FlashSize: 4096, OTAFreeSpace: 100},
+		{DeviceID: "device-003"},
+	}, nil)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil).Times(2)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{Strategy: DeploymentStrategyImmediate})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-001", "device-003"}, deployment.TargetDevices)
+	require.Len(t, deployment.SkippedDevices, 1)
+	assert.Equal(t, "device-002", deployment.SkippedDevices[0].DeviceID)
+	assert.Equal(t, SkipReasonInsufficientOTASpace, deployment.SkippedDevices[0].Reason)
+
+	// Skipped devices survive storage
+	entity, err := deployment.ToEntity()
+	require.NoError(t, err)
+	stored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, deployment.SkippedDevices, stored.SkippedDevices)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_DeployRelease_AllDevicesUndersized(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(&device.Device{DeviceID: "device-001", FlashSize: 512}, nil)
+
+	_, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:      DeploymentStrategyImmediate,
+		TargetDevices: []string{"device-001"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidTargets)
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+}
+
+func TestMergeSkipped(t *testing.T) {
+	skipped := []*SkippedDevice{
+		{DeviceID: "device-001", Reason: SkipReasonInsufficientFlash},
+		{DeviceID: "device-002", Reason: SkipReasonInsufficientOTASpace, Detail: "old"},
+	}
+	extra := []*SkippedDevice{{DeviceID: "device-002", Reason: SkipReasonInsufficientOTASpace, Detail: "new"}}
+
+	// device-001 made room and is now targeted; device-002 is re-checked
+	merged := mergeSkipped(skipped, extra, []string{"device-001"})
+	require.Len(t, merged, 1)
+	assert.Equal(t, "new", merged[0].Detail)
+}
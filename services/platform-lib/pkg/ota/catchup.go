@@ -0,0 +1,158 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/ids"
+)
+
+// finished reports whether an update has reached a final status
+func (u *DeviceUpdate) finished() bool {
+	return u.Status == UpdateStatusCompleted || u.Status == UpdateStatusFailed || u.Status == UpdateStatusCancelled
+}
+
+// catchUp enrolls a device that missed the rollout of the newest release
+// completed on its channel in a trailing deployment of that release. It
+// returns nil when the device is not behind or catch-up is disabled.
+func (s *Service) catchUp(ctx context.Context, deviceID string) (*DeviceUpdate, *OTADeployment, error) {
+	if s.config == nil || !s.config.CatchUp.Enabled || s.deviceRepository == nil {
+		return nil, nil, nil
+	}
+	dev, err := s.deviceRepository.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	if dev.FirmwareHash == "" || dev.TemplateID == "" || !dev.AutoEnrolled() {
+		return nil, nil, nil
+	}
+
+	channel := ReleaseChannel(dev.OTAChannel)
+	if channel == "" {
+		channel = ReleaseChannelStable
+	}
+	releases, err := s.repository.ListReleases(ctx, dev.TemplateID, channel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].CreatedAt.After(releases[j].CreatedAt)
+	})
+
+	// Walking newest first, the device is behind when its firmware is an
+	// older release than the newest completed one. Firmware that matches
+	// no release, such as a development build, is left alone.
+	var latest *FirmwareRelease
+	var rollout *OTADeployment
+	var deployments []*OTADeployment
+	behind := false
+	for _, release := range releases {
+		if release.BinaryHash == dev.FirmwareHash {
+			behind = latest != nil
+			break
+		}
+		if latest != nil {
+			continue
+		}
+		deployments, err = s.repository.ListDeployments(ctx, release.ReleaseID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list deployments: %w", err)
+		}
+		for _, deployment := range deployments {
+			_, trailing := deployment.Annotations[AnnotationCatchUpOf]
+			if deployment.Status == DeploymentStatusCompleted && !trailing {
+				latest, rollout = release, deployment
+				break
+			}
+		}
+	}
+	if !behind {
+		return nil, nil, nil
+	}
+
+	// A device that already had an update for the release is not enrolled
+	// again; failures are left to the deployment's retry policy
+	if _, err := s.repository.GetDeviceUpdate(ctx, deviceID, latest.ReleaseID); err == nil {
+		return nil, nil, nil
+	}
+
+	trailing, err := s.trailingDeployment(ctx, latest, rollout, deployments, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	update := &DeviceUpdate{
+		DeviceID:     deviceID,
+		ReleaseID:    latest.ReleaseID,
+		DeploymentID: trailing.DeploymentID,
+		Status:       UpdateStatusPending,
+		Progress:     0,
+		Attempts:     1,
+		StartedAt:    time.Now(),
+	}
+	if err := s.repository.CreateDeviceUpdate(ctx, update); err != nil {
+		return nil, nil, fmt.Errorf("failed to create device update: %w", err)
+	}
+
+	s.logger.Info("Enrolled lagging device in trailing deployment", "device_id", deviceID, "release_id", latest.ReleaseID, "deployment_id", trailing.DeploymentID)
+
+	return update, trailing, nil
+}
+
+// trailingDeployment adds a device to the active catch-up deployment of a
+// release, creating one that follows the completed rollout's settings when
+// there is none
+func (s *Service) trailingDeployment(ctx context.Context, release *FirmwareRelease, rollout *OTADeployment, deployments []*OTADeployment, deviceID string) (*OTADeployment, error) {
+	for _, deployment := range deployments {
+		if _, ok := deployment.Annotations[AnnotationCatchUpOf]; !ok || deployment.Status != DeploymentStatusActive {
+			continue
+		}
+		return s.repository.ModifyDeployment(ctx, deployment.DeploymentID, func(d *OTADeployment) error {
+			for _, target := range d.TargetDevices {
+				if target == deviceID {
+					return nil
+				}
+			}
+			d.TargetDevices = append(d.TargetDevices, deviceID)
+			d.UpdatedAt = time.Now()
+			return nil
+		})
+	}
+
+	deploymentID, err := s.ids.NewID(ctx, ids.KindDeployment, s.deploymentExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate deployment ID: %w", err)
+	}
+	name, err := s.deploymentName(ctx, release.ReleaseID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deployment := &OTADeployment{
+		DeploymentID:      deploymentID,
+		Name:              name,
+		ReleaseID:         release.ReleaseID,
+		Strategy:          DeploymentStrategyImmediate,
+		TargetDevices:     []string{deviceID},
+		RolloutPercentage: 100,
+		Status:            DeploymentStatusActive,
+		FailureThreshold:  rollout.FailureThreshold,
+		DataBudgetBytes:   rollout.DataBudgetBytes,
+		URLExpirySeconds:  rollout.URLExpirySeconds,
+		OneTimeDownloads:  rollout.OneTimeDownloads,
+		Annotations:       map[string]string{AnnotationCatchUpOf: rollout.DeploymentID},
+		RetryPolicy:       rollout.RetryPolicy,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if err := s.repository.CreateDeployment(ctx, deployment); err != nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	s.logger.Info("Created trailing deployment", "deployment_id", deploymentID, "release_id", release.ReleaseID, "catch_up_of", rollout.DeploymentID)
+
+	return deployment, nil
+}
@@ -0,0 +1,109 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// catchUpReleases returns three releases of a template, newest first: one
+// still rolling out, one completed and the one before it
+func catchUpReleases() []*FirmwareRelease {
+	now := time.Now()
+	releases := []*FirmwareRelease{createTestRelease("release-003"), createTestRelease("release-002"), createTestRelease("release-001")}
+	for i, release := range releases {
+		release.BinaryHash = "hash-" + release.ReleaseID
+		release.CreatedAt = now.Add(-time.Duration(i) * 24 * time.Hour)
+	}
+	return releases
+}
+
+func TestService_GetUpdateForDevice_CatchUp(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, mockStorage := setupTestService()
+	service.config.CatchUp.Enabled = true
+	ctx := context.Background()
+
+	releases := catchUpReleases()
+	rollout := &OTADeployment{DeploymentID: "deployment-002", ReleaseID: "release-002", Status: DeploymentStatusCompleted,
+		FailureThreshold: 20, RetryPolicy: &RetryPolicy{MaxAttempts: 3}}
+
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(&device.Device{
+		DeviceID: "device-001", TemplateID: "template-001", FirmwareHash: "hash-release-001",
+	}, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusCompleted,
+	}, nil)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannelStable).Return(releases, nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-003").Return([]*OTADeployment{
+		{DeploymentID: "deployment-003", ReleaseID: "release-003", Status: DeploymentStatusActive},
+	}, nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-002").Return([]*OTADeployment{rollout}, nil).Once()
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-002").Return(nil, errors.New("not found"))
+	var trailing *OTADeployment
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Run(func(args mock.Arguments) {
+		trailing = args.Get(1).(*OTADeployment)
+	}).Return(nil).Once()
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-002").Return(releases[1], nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, releases[1].BinaryPath, mock.Anything).Return("https://storage/release-002", nil)
+
+	update, err := service.GetUpdateForDevice(ctx, "device-001")
+	require.NoError(t, err)
+	assert.Equal(t, "release-002", update.ReleaseID)
+
+	require.NotNil(t, trailing)
+	assert.Equal(t, DeploymentStatusActive, trailing.Status)
+	assert.Equal(t, []string{"device-001"}, trailing.TargetDevices)
+	assert.Equal(t, "deployment-002", trailing.Annotations[AnnotationCatchUpOf])
+	assert.Equal(t, 20, trailing.FailureThreshold)
+	assert.Equal(t, rollout.RetryPolicy, trailing.RetryPolicy)
+
+	// The next lagging device joins the same trailing deployment
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-002").Return(&device.Device{
+		DeviceID: "device-002", TemplateID: "template-001", FirmwareHash: "hash-release-001",
+	}, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-002").Return(nil, errors.New("no updates found"))
+	mockRepo.On("ListDeployments", mock.Anything, "release-002").Return([]*OTADeployment{rollout, trailing}, nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-002", "release-002").Return(nil, errors.New("not found"))
+	mockRepo.On("GetDeployment", mock.Anything, trailing.DeploymentID).Return(trailing, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, trailing).Return(nil)
+
+	update, err = service.GetUpdateForDevice(ctx, "device-002")
+	require.NoError(t, err)
+	assert.Equal(t, "release-002", update.ReleaseID)
+	assert.Equal(t, []string{"device-001", "device-002"}, trailing.TargetDevices)
+	mockRepo.AssertNumberOfCalls(t, "CreateDeployment", 1)
+}
+
+// Devices already on the newest completed release, or on firmware that
+// matches no release, are not enrolled
+func TestService_GetUpdateForDevice_NoCatchUp(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupTestService()
+	service.config.CatchUp.Enabled = true
+
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannelBeta).Return(catchUpReleases(), nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-003").Return([]*OTADeployment{}, nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-002").Return([]*OTADeployment{
+		{DeploymentID: "deployment-002", Status: DeploymentStatusCompleted},
+	}, nil)
+	mockRepo.On("ListDeployments", mock.Anything, "release-001").Return([]*OTADeployment{}, nil)
+
+	for deviceID, hash := range map[string]string{"device-current": "hash-release-002", "device-custom": "hash-dev-build"} {
+		mockDeviceRepo.On("GetDevice", mock.Anything, deviceID).Return(&device.Device{
+			DeviceID: deviceID, TemplateID: "template-001", OTAChannel: "beta", FirmwareHash: hash,
+		}, nil)
+		mockRepo.On("GetLatestUpdateForDevice", mock.Anything, deviceID).Return(nil, errors.New("no updates found"))
+
+		update, err := service.GetUpdateForDevice(context.Background(), deviceID)
+		assert.Nil(t, update, deviceID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no pending update")
+	}
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,190 @@
+package ota
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encodings firmware binaries are compressed with
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+const (
+	// zstdWindowSize bounds the history a device keeps while decompressing
+	// a zstd binary as it downloads it
+	zstdWindowSize = 64 << 10
+
+	// maxDecompressedSize bounds the image a compressed binary may expand to
+	maxDecompressedSize = 64 << 20
+)
+
+// ErrUnsupportedEncoding is returned for encodings firmware binaries
+// cannot be compressed with
+var ErrUnsupportedEncoding = errors.New("unsupported firmware encoding")
+
+// errEncodingNotSmaller is returned when compressing a binary does not make
+// it smaller, so it is only served uncompressed
+var errEncodingNotSmaller = errors.New("compressed binary is not smaller than the image")
+
+// CompressBinary compresses a firmware image with encoding
+func CompressBinary(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		encoder, err := zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstd.SpeedBestCompression),
+			zstd.WithWindowSize(zstdWindowSize),
+			zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// DecompressBinary restores a firmware image compressed with encoding
+func DecompressBinary(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		image, err := io.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(image) > maxDecompressedSize {
+			return nil, fmt.Errorf("decompressed image is larger than %d bytes", maxDecompressedSize)
+		}
+		return image, nil
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+}
+
+// compressionSettings returns the firmware compression options
+func compressionSettings(cfg *config.Config) config.FirmwareCompressionConfig {
+	if cfg == nil {
+		return config.FirmwareCompressionConfig{}
+	}
+	return cfg.FirmwareCompression
+}
+
+// compressRelease stores a new release's binary in each configured
+// encoding. Encodings that fail or do not make the binary smaller are
+// skipped; devices asking for them download the raw image.
+func (s *Service) compressRelease(ctx context.Context, release *FirmwareRelease, binaryData []byte) {
+	settings := compressionSettings(s.config)
+	if !settings.Enabled {
+		return
+	}
+
+	for _, encoding := range settings.Encodings {
+		encoded, err := s.encodeBinary(ctx, release, binaryData, encoding)
+		if err != nil {
+			s.logger.Warn("Skipped compressed binary", "release_id", release.ReleaseID, "encoding", encoding, "error", err)
+			continue
+		}
+		release.Encodings = append(release.Encodings, *encoded)
+	}
+}
+
+// encodeBinary compresses and stores the binary of a release. The result
+// is checked to decompress to the release image before it is stored.
+func (s *Service) encodeBinary(ctx context.Context, release *FirmwareRelease, binaryData []byte, encoding string) (*EncodedBinary, error) {
+	compressed, err := CompressBinary(binaryData, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) >= len(binaryData) {
+		return nil, errEncodingNotSmaller
+	}
+	image, err := DecompressBinary(compressed, encoding)
+	if err != nil || ComputeHash(image) != release.BinaryHash {
+		return nil, fmt.Errorf("compressed binary does not decompress to the release")
+	}
+
+	path, err := s.storageBackend.StoreBinary(ctx, release.ReleaseID+"/encodings/"+encoding, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store compressed binary: %w", err)
+	}
+	return &EncodedBinary{
+		Encoding: encoding,
+		Path:     path,
+		Hash:     ComputeHash(compressed),
+		Size:     int64(len(compressed)),
+	}, nil
+}
+
+// deleteEncodings removes the stored compressed binaries of a release
+func (s *Service) deleteEncodings(ctx context.Context, release *FirmwareRelease) {
+	for _, encoded := range release.Encodings {
+		if err := s.storageBackend.DeleteBinary(ctx, encoded.Path); err != nil {
+			s.logger.Warn("Failed to delete compressed binary from storage", "release_id", release.ReleaseID, "encoding", encoded.Encoding, "error", err)
+		}
+	}
+}
+
+// negotiateEncoding returns the compressed binary of the first accepted
+// encoding the release has, or nil to serve the raw image
+func negotiateEncoding(encodings []EncodedBinary, accepted []string) *EncodedBinary {
+	for _, encoding := range accepted {
+		for i := range encodings {
+			if encodings[i].Encoding == encoding {
+				return &encodings[i]
+			}
+		}
+	}
+	return nil
+}
+
+// parseAcceptedEncodings reads the encodings a device can decompress, in
+// order of preference, from an Accept-Encoding style list such as
+// "zstd, gzip;q=0.5". Parameters are ignored except that q=0 rules an
+// encoding out.
+func parseAcceptedEncodings(value string) []string {
+	var accepted []string
+	for _, part := range strings.Split(value, ",") {
+		encoding, params, _ := strings.Cut(part, ";")
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding == "" || strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		accepted = append(accepted, encoding)
+	}
+	return accepted
+}
@@ -0,0 +1,164 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// compressibleFirmware returns an image with the repetition of real
+// firmware: strings, padding and repeated code
+func compressibleFirmware() []byte {
+	var image bytes.Buffer
+	for i := 0; i < 500; i++ {
+		image.WriteString("sensor_read temperature humidity ")
+		image.Write(make([]byte, 32))
+	}
+	return image.Bytes()
+}
+
+func TestCompressBinary_RoundTrip(t *testing.T) {
+	image := compressibleFirmware()
+
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		compressed, err := CompressBinary(image, encoding)
+		require.NoError(t, err, encoding)
+		assert.Less(t, len(compressed), len(image)/4, encoding)
+
+		restored, err := DecompressBinary(compressed, encoding)
+		require.NoError(t, err, encoding)
+		assert.True(t, bytes.Equal(image, restored), encoding)
+
+		_, err = DecompressBinary(compressed[:len(compressed)/2], encoding)
+		assert.Error(t, err, encoding)
+	}
+
+	_, err := CompressBinary(image, "br")
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+	_, err = DecompressBinary(image, "br")
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+}
+
+func TestParseAcceptedEncodings(t *testing.T) {
+	assert.Equal(t, []string{"zstd", "gzip"}, parseAcceptedEncodings("zstd, GZIP"))
+	assert.Equal(t, []string{"gzip"}, parseAcceptedEncodings("zstd;q=0, gzip;q=0.5"))
+	assert.Nil(t, parseAcceptedEncodings(""))
+
+	encodings := []EncodedBinary{{Encoding: EncodingGzip}, {Encoding: EncodingZstd}}
+	assert.Equal(t, EncodingZstd, negotiateEncoding(encodings, []string{"br", "zstd", "gzip"}).Encoding)
+	assert.Nil(t, negotiateEncoding(encodings, []string{"identity"}))
+	assert.Nil(t, negotiateEncoding(nil, []string{"gzip"}))
+}
+
+func TestService_CreateRelease_Compression(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	service.config.FirmwareCompression.Enabled = true
+	service.config.FirmwareCompression.Encodings = []string{EncodingZstd, "br", EncodingGzip}
+	image := compressibleFirmware()
+
+	mockStorage.On("StoreBinary", mock.Anything, mock.AnythingOfType("string"), image).Return("/binaries/release.bin", nil)
+	mockStorage.On("StoreBinary", mock.Anything, mock.MatchedBy(func(path string) bool {
+		return strings.Contains(path, "/encodings/")
+	}), mock.Anything).Return("/binaries/release.bin.compressed", nil).Twice()
+	mockRepo.On("CreateRelease", mock.Anything, mock.AnythingOfType("*ota.FirmwareRelease")).Return(nil)
+
+	release, err := service.CreateRelease(context.Background(), &CreateReleaseRequest{
+		TemplateID: "template-001",
+		Version:    "1.0.0",
+		Channel:    ReleaseChannelStable,
+		BinaryData: image,
+	})
+	require.NoError(t, err)
+
+	// The unsupported encoding is skipped
+	require.Len(t, release.Encodings, 2)
+	assert.Equal(t, EncodingZstd, release.Encodings[0].Encoding)
+	assert.Equal(t, EncodingGzip, release.Encodings[1].Encoding)
+	for _, encoded := range release.Encodings {
+		assert.Less(t, encoded.Size, release.BinarySize)
+		assert.NotEqual(t, release.BinaryHash, encoded.Hash)
+	}
+
+	entity, err := release.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, release.Encodings, restored.Encodings)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestService_CreateRelease_IncompressibleBinary(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	service.config.FirmwareCompression.Enabled = true
+	service.config.FirmwareCompression.Encodings = []string{EncodingZstd, EncodingGzip}
+	image, _ := testFirmware(8 << 10)
+
+	mockStorage.On("StoreBinary", mock.Anything, mock.AnythingOfType("string"), image).Return("/binaries/release.bin", nil).Once()
+	mockRepo.On("CreateRelease", mock.Anything, mock.AnythingOfType("*ota.FirmwareRelease")).Return(nil)
+
+	release, err := service.CreateRelease(context.Background(), &CreateReleaseRequest{
+		TemplateID: "template-001",
+		Version:    "1.0.0",
+		Channel:    ReleaseChannelStable,
+		BinaryData: image,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, release.Encodings)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestService_GetUpdateForDevice_Encoding(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	release := createTestRelease("release-001")
+	release.Encodings = []EncodedBinary{
+		{Encoding: EncodingGzip, Path: "release-001/encodings/gzip", Hash: "gzip-hash", Size: 400},
+		{Encoding: EncodingZstd, Path: "release-001/encodings/zstd", Hash: "zstd-hash", Size: 300},
+	}
+
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusPending,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, release.BinaryPath, time.Hour).Return("https://storage/raw", nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, "release-001/encodings/gzip", time.Hour).Return("https://storage/gzip", nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, "release-001/encodings/zstd", time.Hour).Return("https://storage/zstd", nil)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	get := func(query string) *FirmwareUpdate {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/updates/device-001"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var update FirmwareUpdate
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &update))
+		return &update
+	}
+
+	// The device's first preference the release has is served
+	update := get("?accept_encoding=gzip,zstd")
+	assert.Equal(t, "https://storage/gzip", update.BinaryURL)
+	assert.Equal(t, EncodingGzip, update.Encoding)
+	assert.Equal(t, int64(400), update.CompressedSize)
+	assert.Equal(t, "gzip-hash", update.CompressedHash)
+	assert.Equal(t, release.BinaryHash, update.BinaryHash)
+	assert.Equal(t, release.BinarySize, update.BinarySize)
+
+	update = get("?accept_encoding=br,zstd")
+	assert.Equal(t, EncodingZstd, update.Encoding)
+
+	// Devices that advertise nothing get the raw image
+	update = get("")
+	assert.Equal(t, "https://storage/raw", update.BinaryURL)
+	assert.Empty(t, update.Encoding)
+	assert.Zero(t, update.CompressedSize)
+}
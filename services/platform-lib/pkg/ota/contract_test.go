@@ -0,0 +1,59 @@
+package ota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/contract"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// contractStates put the mock repository in the state a consumer's
+// interaction requires
+var contractStates = map[string]func(repo *MockRepository){
+	"release rel-1 exists": func(repo *MockRepository) {
+		release := createTestRelease("rel-1")
+		release.Annotations = map[string]string{"change": "CHG-1"}
+		repo.On("ListReleases", mock.Anything, "", ReleaseChannel("")).Return([]*FirmwareRelease{release}, nil)
+	},
+	"deployment deploy-1 exists": func(repo *MockRepository) {
+		deployment := &OTADeployment{
+			DeploymentID:  "deploy-1",
+			ReleaseID:     "rel-1",
+			Strategy:      DeploymentStrategyImmediate,
+			Status:        DeploymentStatusActive,
+			TargetDevices: []string{"dev-1"},
+			Annotations:   map[string]string{"change": "CHG-1"},
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		repo.On("GetDeployment", mock.Anything, "deploy-1").Return(deployment, nil).Maybe()
+		repo.On("GetActiveDeployments", mock.Anything).Return([]*OTADeployment{deployment}, nil).Maybe()
+	},
+}
+
+func TestProviderContracts(t *testing.T) {
+	contracts, err := contract.LoadDir("../../contracts", "ota-service")
+	require.NoError(t, err)
+	require.NotEmpty(t, contracts)
+
+	for _, c := range contracts {
+		for _, interaction := range c.Interactions {
+			t.Run(c.Consumer+"/"+interaction.Description, func(t *testing.T) {
+				setState, ok := contractStates[interaction.State]
+				require.True(t, ok, "unknown provider state %q", interaction.State)
+
+				service, repo, _, _ := setupTestService()
+				setState(repo)
+				router := gin.New()
+				RegisterRoutes(router, service)
+
+				assert.NoError(t, interaction.Verify(router))
+				repo.AssertExpectations(t)
+			})
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// crashCorrelationWindow is how long after an update a crash is still
+	// attributed to it
+	crashCorrelationWindow = 24 * time.Hour
+
+	// maxCrashBacktraceBytes caps the backtrace kept per report
+	maxCrashBacktraceBytes = 8 * 1024
+)
+
+// ErrInvalidCrashReport is returned for crash reports that cannot be stored
+var ErrInvalidCrashReport = errors.New("invalid crash report")
+
+// crashReasonAliases maps reset reasons as firmware reports them, including
+// ESP-IDF esp_reset_reason_t names, to a CrashReason
+var crashReasonAliases = map[string]CrashReason{
+	"watchdog": CrashReasonWatchdog,
+	"wdt":      CrashReasonWatchdog,
+	"task_wdt": CrashReasonWatchdog,
+	"int_wdt":  CrashReasonWatchdog,
+	"brownout": CrashReasonBrownout,
+	"panic":    CrashReasonPanic,
+	"software": CrashReasonSoftware,
+	"sw":       CrashReasonSoftware,
+	"unknown":  CrashReasonUnknown,
+}
+
+// CrashReportRequest is sent by a device after it restarts from a crash or
+// unexpected reset
+type CrashReportRequest struct {
+	DeviceID        string     `json:"device_id,omitempty"`
+	Reason          string     `json:"reason" binding:"required"`
+	Message         string     `json:"message,omitempty"`
+	Backtrace       string     `json:"backtrace,omitempty"`
+	FirmwareVersion string     `json:"firmware_version,omitempty"`
+	FirmwareHash    string     `json:"firmware_hash,omitempty"`
+	UptimeSeconds   int64      `json:"uptime_seconds,omitempty"`
+	BootCount       int        `json:"boot_count,omitempty"`
+	Timestamp       *time.Time `json:"timestamp,omitempty"`
+}
+
+// ParseCrashReason normalizes a reported reset reason such as "WDT" or
+// "ESP_RST_BROWNOUT". Unrecognized reasons are classified as unknown.
+func ParseCrashReason(reason string) CrashReason {
+	key := strings.ToLower(strings.TrimSpace(reason))
+	key = strings.TrimPrefix(key, "esp_rst_")
+	if parsed, ok := crashReasonAliases[key]; ok {
+		return parsed
+	}
+	return CrashReasonUnknown
+}
+
+// RecordCrashReport stores a crash report for a device, attributing it to
+// the device's latest OTA update when it happened during or shortly after it
+func (s *Service) RecordCrashReport(ctx context.Context, deviceID string, req *CrashReportRequest) (*CrashReport, error) {
+	if deviceID == "" {
+		return nil, fmt.Errorf("%w: device ID is required", ErrInvalidCrashReport)
+	}
+	if req.DeviceID != "" && req.DeviceID != deviceID {
+		return nil, fmt.Errorf("%w: device %s may not report for device %s", ErrInvalidCrashReport, deviceID, req.DeviceID)
+	}
+
+	now := time.Now()
+	report := &CrashReport{
+		ReportID:        uuid.New().String(),
+		DeviceID:        deviceID,
+		Reason:          ParseCrashReason(req.Reason),
+		RawReason:       req.Reason,
+		Message:         req.Message,
+		Backtrace:       req.Backtrace,
+		FirmwareVersion: req.FirmwareVersion,
+		FirmwareHash:    req.FirmwareHash,
+		UptimeSeconds:   req.UptimeSeconds,
+		BootCount:       req.BootCount,
+		OccurredAt:      now,
+		ReceivedAt:      now,
+	}
+	if req.Timestamp != nil && !req.Timestamp.IsZero() && !req.Timestamp.After(now) {
+		report.OccurredAt = *req.Timestamp
+	}
+	if len(report.Backtrace) > maxCrashBacktraceBytes {
+		report.Backtrace = report.Backtrace[:maxCrashBacktraceBytes]
+	}
+
+	// A device without update history simply has nothing to correlate with
+	if update, err := s.repository.GetLatestUpdateForDevice(ctx, deviceID); err == nil {
+		s.correlateCrash(ctx, report, update)
+	}
+
+	if err := s.repository.CreateCrashReport(ctx, report); err != nil {
+		return nil, fmt.Errorf("failed to store crash report: %w", err)
+	}
+
+	s.logger.Warn("Device crash reported", "device_id", deviceID, "reason", report.Reason, "firmware_version", report.FirmwareVersion, "deployment_id", report.DeploymentID)
+
+	return report, nil
+}
+
+// correlateCrash attributes a crash to an update that was being installed
+// when it happened, or that completed within crashCorrelationWindow of it
+func (s *Service) correlateCrash(ctx context.Context, report *CrashReport, update *DeviceUpdate) {
+	if update.Status == UpdateStatusPending || report.OccurredAt.Before(update.StartedAt) {
+		return
+	}
+
+	since := update.StartedAt
+	if update.CompletedAt != nil {
+		since = *update.CompletedAt
+	}
+	elapsed := report.OccurredAt.Sub(since)
+	if elapsed > crashCorrelationWindow {
+		return
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	report.ReleaseID = update.ReleaseID
+	report.DeploymentID = update.DeploymentID
+	report.SinceUpdate = elapsed.Round(time.Second).String()
+
+	// Firmware that does not report its version is assumed to run the
+	// release it was just updated to
+	if report.FirmwareVersion == "" && update.Status == UpdateStatusCompleted {
+		if release, err := s.repository.GetRelease(ctx, update.ReleaseID); err == nil {
+			report.FirmwareVersion = release.Version
+		}
+	}
+}
+
+// ListCrashReports returns the recent crash reports for a device, newest first
+func (s *Service) ListCrashReports(ctx context.Context, deviceID string, limit int) ([]*CrashReport, error) {
+	reports, err := s.repository.ListCrashReportsForDevice(ctx, deviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list crash reports: %w", err)
+	}
+	return reports, nil
+}
+
+// summarizeCrashes counts crash reports and affected devices by reason
+func summarizeCrashes(reports []*CrashReport) (devices int, reasons map[CrashReason]int) {
+	if len(reports) == 0 {
+		return 0, nil
+	}
+
+	seen := make(map[string]bool)
+	reasons = make(map[CrashReason]int)
+	for _, report := range reports {
+		seen[report.DeviceID] = true
+		reasons[report.Reason]++
+	}
+	return len(seen), reasons
+}
@@ -0,0 +1,120 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCrashReason(t *testing.T) {
+	assert.Equal(t, CrashReasonWatchdog, ParseCrashReason("ESP_RST_TASK_WDT"))
+	assert.Equal(t, CrashReasonWatchdog, ParseCrashReason("wdt"))
+	assert.Equal(t, CrashReasonBrownout, ParseCrashReason("ESP_RST_BROWNOUT"))
+	assert.Equal(t, CrashReasonPanic, ParseCrashReason(" Panic "))
+	assert.Equal(t, CrashReasonSoftware, ParseCrashReason("ESP_RST_SW"))
+	assert.Equal(t, CrashReasonUnknown, ParseCrashReason("cosmic ray"))
+}
+
+func TestService_RecordCrashReport_CorrelatesRecentUpdate(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	completed := time.Now().Add(-2 * time.Hour)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID:     "device-001",
+		ReleaseID:    "release-002",
+		DeploymentID: "deployment-002",
+		Status:       UpdateStatusCompleted,
+		StartedAt:    completed.Add(-time.Minute),
+		CompletedAt:  &completed,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-002").Return(&FirmwareRelease{ReleaseID: "release-002", Version: "1.2.0"}, nil)
+	mockRepo.On("CreateCrashReport", mock.Anything, mock.AnythingOfType("*ota.CrashReport")).Return(nil)
+
+	report, err := service.RecordCrashReport(context.Background(), "device-001", &CrashReportRequest{
+		Reason:    "ESP_RST_PANIC",
+		Backtrace: "Backtrace: 0x400d1234:0x3ffb1f50 0x400d5678:0x3ffb1f70",
+	})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, report.ReportID)
+	assert.Equal(t, CrashReasonPanic, report.Reason)
+	assert.Equal(t, "ESP_RST_PANIC", report.RawReason)
+	assert.Equal(t, "release-002", report.ReleaseID)
+	assert.Equal(t, "deployment-002", report.DeploymentID)
+	assert.Equal(t, "1.2.0", report.FirmwareVersion, "the version comes from the release the device was updated to")
+	assert.NotEmpty(t, report.SinceUpdate)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_RecordCrashReport_OldOrNoUpdate(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	completed := time.Now().Add(-3 * crashCorrelationWindow)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		ReleaseID:    "release-001",
+		DeploymentID: "deployment-001",
+		Status:       UpdateStatusCompleted,
+		StartedAt:    completed,
+		CompletedAt:  &completed,
+	}, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-002").Return(nil, errors.New("no updates found for device device-002"))
+	mockRepo.On("CreateCrashReport", mock.Anything, mock.AnythingOfType("*ota.CrashReport")).Return(nil)
+
+	report, err := service.RecordCrashReport(context.Background(), "device-001", &CrashReportRequest{Reason: "brownout", FirmwareVersion: "1.0.0"})
+	require.NoError(t, err)
+	assert.Empty(t, report.DeploymentID)
+	assert.Equal(t, "1.0.0", report.FirmwareVersion)
+
+	report, err = service.RecordCrashReport(context.Background(), "device-002", &CrashReportRequest{Reason: "watchdog"})
+	require.NoError(t, err)
+	assert.Empty(t, report.DeploymentID)
+
+	_, err = service.RecordCrashReport(context.Background(), "device-001", &CrashReportRequest{DeviceID: "device-009", Reason: "panic"})
+	assert.True(t, errors.Is(err, ErrInvalidCrashReport))
+}
+
+func TestService_CrashReportRoutes(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(nil, errors.New("no updates found"))
+	mockRepo.On("CreateCrashReport", mock.Anything, mock.AnythingOfType("*ota.CrashReport")).Return(nil)
+	mockRepo.On("ListCrashReportsForDevice", mock.Anything, "device-001", 5).Return([]*CrashReport{
+		{ReportID: "report-001", DeviceID: "device-001", Reason: CrashReasonWatchdog},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ota/devices/device-001/crashes", bytes.NewBufferString(`{"reason": "ESP_RST_INT_WDT", "uptime_seconds": 3600}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var report CrashReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, CrashReasonWatchdog, report.Reason)
+	assert.Equal(t, int64(3600), report.UptimeSeconds)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/ota/devices/device-001/crashes", bytes.NewBufferString(`{"device_id": "device-002", "reason": "panic"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/devices/device-001/crashes?limit=5", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"report_id":"report-001"`)
+}
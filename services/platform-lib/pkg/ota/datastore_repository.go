@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
 )
 
 // DatastoreRepository implements the Repository interface using Google Cloud Datastore
@@ -140,6 +141,27 @@ func (r *DatastoreRepository) ListReleases(ctx context.Context, templateID strin
 	return releases, nil
 }
 
+// UpdateRelease updates the metadata of an existing firmware release in Datastore
+func (r *DatastoreRepository) UpdateRelease(ctx context.Context, release *FirmwareRelease) error {
+	if release == nil {
+		return fmt.Errorf("release cannot be nil")
+	}
+
+	entity, err := release.ToEntity()
+	if err != nil {
+		return fmt.Errorf("failed to convert release to entity: %w", err)
+	}
+
+	key := datastore.NameKey("FirmwareRelease", release.ReleaseID, nil)
+
+	_, err = r.client.Put(ctx, key, entity)
+	if err != nil {
+		return fmt.Errorf("failed to update release in Datastore: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteRelease deletes a firmware release from Datastore
 func (r *DatastoreRepository) DeleteRelease(ctx context.Context, releaseID string) error {
 	if releaseID == "" {
@@ -247,6 +269,48 @@ func (r *DatastoreRepository) UpdateDeployment(ctx context.Context, deployment *
 	return nil
 }
 
+// ModifyDeployment applies modify to the stored deployment inside a
+// transaction, so concurrent callers cannot both act on the same state. An
+// error from modify aborts the write and is returned unchanged.
+func (r *DatastoreRepository) ModifyDeployment(ctx context.Context, deploymentID string, modify func(*OTADeployment) error) (*OTADeployment, error) {
+	key := datastore.NameKey("OTADeployment", deploymentID, nil)
+
+	var deployment *OTADeployment
+	_, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity OTADeploymentEntity
+		if err := tx.Get(key, &entity); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return fmt.Errorf("deployment %s not found", deploymentID)
+			}
+			return fmt.Errorf("failed to retrieve deployment from Datastore: %w", err)
+		}
+
+		var err error
+		deployment, err = entity.FromEntity()
+		if err != nil {
+			return fmt.Errorf("failed to convert entity to deployment: %w", err)
+		}
+		if err := modify(deployment); err != nil {
+			return err
+		}
+
+		deployment.UpdatedAt = time.Now()
+		updated, err := deployment.ToEntity()
+		if err != nil {
+			return fmt.Errorf("failed to convert deployment to entity: %w", err)
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update deployment in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
 // ListDeployments lists all deployments for a release
 func (r *DatastoreRepository) ListDeployments(ctx context.Context, releaseID string) ([]*OTADeployment, error) {
 	query := datastore.NewQuery("OTADeployment")
@@ -370,6 +434,46 @@ func (r *DatastoreRepository) UpdateDeviceUpdate(ctx context.Context, update *De
 	return nil
 }
 
+// ModifyDeviceUpdate applies modify to a device update in a transaction,
+// so concurrent changes are not lost. An error from modify aborts it.
+func (r *DatastoreRepository) ModifyDeviceUpdate(ctx context.Context, deviceID, releaseID string, modify func(*DeviceUpdate) error) (*DeviceUpdate, error) {
+	key := datastore.NameKey("DeviceUpdate", fmt.Sprintf("%s#%s", deviceID, releaseID), nil)
+
+	var update *DeviceUpdate
+	_, err := r.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity DeviceUpdateEntity
+		if err := tx.Get(key, &entity); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				return fmt.Errorf("device update not found for device %s and release %s", deviceID, releaseID)
+			}
+			return fmt.Errorf("failed to retrieve device update from Datastore: %w", err)
+		}
+
+		var err error
+		update, err = entity.FromEntity()
+		if err != nil {
+			return fmt.Errorf("failed to convert entity to update: %w", err)
+		}
+		if err := modify(update); err != nil {
+			return err
+		}
+
+		updated, err := update.ToEntity()
+		if err != nil {
+			return fmt.Errorf("failed to convert update to entity: %w", err)
+		}
+		if _, err := tx.Put(key, updated); err != nil {
+			return fmt.Errorf("failed to update device update in Datastore: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
 // ListDeviceUpdates lists all device updates for a deployment
 func (r *DatastoreRepository) ListDeviceUpdates(ctx context.Context, deploymentID string) ([]*DeviceUpdate, error) {
 	query := datastore.NewQuery("DeviceUpdate").
@@ -419,6 +523,105 @@ func (r *DatastoreRepository) GetDeviceUpdatesByStatus(ctx context.Context, depl
 	return updates, nil
 }
 
+// QueryDeviceUpdates returns one page of a deployment's device updates,
+// newest first, and a cursor for the next page. The cursor is empty once
+// the last page has been read.
+func (r *DatastoreRepository) QueryDeviceUpdates(ctx context.Context, deploymentID string, q *DeviceUpdateQuery) ([]*DeviceUpdate, string, error) {
+	query := datastore.NewQuery("DeviceUpdate").
+		Filter("deployment_id =", deploymentID)
+
+	switch len(q.Statuses) {
+	case 0:
+	case 1:
+		query = query.Filter("status =", string(q.Statuses[0]))
+	default:
+		statuses := make([]interface{}, len(q.Statuses))
+		for i, status := range q.Statuses {
+			statuses[i] = string(status)
+		}
+		query = query.FilterField("status", "in", statuses)
+	}
+	if !q.StartedAfter.IsZero() {
+		query = query.Filter("started_at >=", q.StartedAfter)
+	}
+	if !q.StartedBefore.IsZero() {
+		query = query.Filter("started_at <", q.StartedBefore)
+	}
+	query = query.Order("-started_at")
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+	if q.Cursor != "" {
+		cursor, err := datastore.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDeviceUpdateQuery)
+		}
+		query = query.Start(cursor)
+	}
+
+	var updates []*DeviceUpdate
+	it := r.client.Run(ctx, query)
+	for {
+		var entity DeviceUpdateEntity
+		_, err := it.Next(&entity)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query device updates from Datastore: %w", err)
+		}
+		update, err := entity.FromEntity()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to convert entity to update: %w", err)
+		}
+		updates = append(updates, update)
+	}
+
+	// A short page is the last one
+	if q.Limit <= 0 || len(updates) < q.Limit {
+		return updates, "", nil
+	}
+	cursor, err := it.Cursor()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get device update cursor: %w", err)
+	}
+	return updates, cursor.String(), nil
+}
+
+// CountDeviceUpdatesByStatus counts a deployment's device updates in each
+// status without loading them, using one count aggregation per status
+func (r *DatastoreRepository) CountDeviceUpdatesByStatus(ctx context.Context, deploymentID string) (map[UpdateStatus]int, error) {
+	counts := make(map[UpdateStatus]int, len(updateStatuses))
+	for _, status := range updateStatuses {
+		query := datastore.NewQuery("DeviceUpdate").
+			Filter("deployment_id =", deploymentID).
+			Filter("status =", string(status))
+
+		count, err := r.countDeviceUpdates(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s device updates: %w", status, err)
+		}
+		counts[status] = count
+	}
+	return counts, nil
+}
+
+// countDeviceUpdates counts the entities matched by query with a
+// server-side aggregation, so the cost does not grow with the fleet
+func (r *DatastoreRepository) countDeviceUpdates(ctx context.Context, query *datastore.Query) (int, error) {
+	result, err := r.client.RunAggregationQuery(ctx, query.NewAggregationQuery().WithCount("count"))
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := result["count"].(interface{ GetIntegerValue() int64 })
+	if !ok {
+		return 0, fmt.Errorf("unexpected count aggregation result %T", result["count"])
+	}
+	return int(value.GetIntegerValue()), nil
+}
+
 // GetLatestUpdateForDevice retrieves the latest update for a device
 func (r *DatastoreRepository) GetLatestUpdateForDevice(ctx context.Context, deviceID string) (*DeviceUpdate, error) {
 	query := datastore.NewQuery("DeviceUpdate").
@@ -444,24 +647,181 @@ func (r *DatastoreRepository) GetLatestUpdateForDevice(ctx context.Context, devi
 	return update, nil
 }
 
-// GetDeploymentStats retrieves deployment statistics
-func (r *DatastoreRepository) GetDeploymentStats(ctx context.Context, deploymentID string) (successCount, failureCount, pendingCount int, err error) {
-	// Get all updates for the deployment
-	updates, err := r.ListDeviceUpdates(ctx, deploymentID)
+// ListUpdatesForDevice retrieves the most recent update attempts for a device
+func (r *DatastoreRepository) ListUpdatesForDevice(ctx context.Context, deviceID string, limit int) ([]*DeviceUpdate, error) {
+	query := datastore.NewQuery("DeviceUpdate").
+		Filter("device_id =", deviceID).
+		Order("-started_at")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var entities []DeviceUpdateEntity
+	_, err := r.client.GetAll(ctx, query, &entities)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to get device updates: %w", err)
+		return nil, fmt.Errorf("failed to query device updates from Datastore: %w", err)
+	}
+
+	var updates []*DeviceUpdate
+	for _, entity := range entities {
+		update, err := entity.FromEntity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert entity to update: %w", err)
+		}
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+// CreateCrashReport stores a crash report in Datastore
+func (r *DatastoreRepository) CreateCrashReport(ctx context.Context, report *CrashReport) error {
+	if report == nil {
+		return fmt.Errorf("crash report cannot be nil")
+	}
+
+	key := datastore.NameKey("CrashReport", report.ReportID, nil)
+	if _, err := r.client.Put(ctx, key, report.ToEntity()); err != nil {
+		return fmt.Errorf("failed to store crash report in Datastore: %w", err)
+	}
+
+	return nil
+}
+
+// ListCrashReportsForDevice retrieves the most recent crash reports for a device
+func (r *DatastoreRepository) ListCrashReportsForDevice(ctx context.Context, deviceID string, limit int) ([]*CrashReport, error) {
+	query := datastore.NewQuery("CrashReport").
+		Filter("device_id =", deviceID).
+		Order("-occurred_at")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	return r.queryCrashReports(ctx, query)
+}
+
+// ListCrashReportsForDeployment retrieves the crash reports attributed to a deployment
+func (r *DatastoreRepository) ListCrashReportsForDeployment(ctx context.Context, deploymentID string) ([]*CrashReport, error) {
+	query := datastore.NewQuery("CrashReport").
+		Filter("deployment_id =", deploymentID).
+		Order("-occurred_at")
+
+	return r.queryCrashReports(ctx, query)
+}
+
+func (r *DatastoreRepository) queryCrashReports(ctx context.Context, query *datastore.Query) ([]*CrashReport, error) {
+	var entities []CrashReportEntity
+	if _, err := r.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query crash reports from Datastore: %w", err)
+	}
+
+	reports := make([]*CrashReport, 0, len(entities))
+	for i := range entities {
+		reports = append(reports, entities[i].FromEntity())
 	}
 
-	// Count by status
-	for _, update := range updates {
-		switch update.Status {
-		case UpdateStatusCompleted:
-			successCount++
-		case UpdateStatusFailed:
-			failureCount++
-		case UpdateStatusPending, UpdateStatusDownloading, UpdateStatusInstalling:
-			pendingCount++
+	return reports, nil
+}
+
+// CreateWebhook stores a webhook in Datastore
+func (r *DatastoreRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	if webhook == nil {
+		return fmt.Errorf("webhook cannot be nil")
+	}
+
+	key := datastore.NameKey("Webhook", webhook.WebhookID, nil)
+	if _, err := r.client.Put(ctx, key, webhook.ToEntity()); err != nil {
+		return fmt.Errorf("failed to store webhook in Datastore: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (r *DatastoreRepository) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	if webhookID == "" {
+		return nil, fmt.Errorf("webhook ID cannot be empty")
+	}
+
+	key := datastore.NameKey("Webhook", webhookID, nil)
+
+	var entity WebhookEntity
+	if err := r.client.Get(ctx, key, &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, fmt.Errorf("%w: %s", ErrWebhookNotFound, webhookID)
+		}
+		return nil, fmt.Errorf("failed to retrieve webhook from Datastore: %w", err)
+	}
+
+	return entity.FromEntity(), nil
+}
+
+// ListWebhooks retrieves all webhooks, oldest first
+func (r *DatastoreRepository) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	query := datastore.NewQuery("Webhook").Order("created_at")
+
+	var entities []WebhookEntity
+	if _, err := r.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query webhooks from Datastore: %w", err)
+	}
+
+	webhooks := make([]*Webhook, 0, len(entities))
+	for i := range entities {
+		webhooks = append(webhooks, entities[i].FromEntity())
+	}
+
+	return webhooks, nil
+}
+
+// UpdateWebhook updates an existing webhook in Datastore
+func (r *DatastoreRepository) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	if webhook == nil {
+		return fmt.Errorf("webhook cannot be nil")
+	}
+
+	key := datastore.NameKey("Webhook", webhook.WebhookID, nil)
+	if _, err := r.client.Put(ctx, key, webhook.ToEntity()); err != nil {
+		return fmt.Errorf("failed to update webhook in Datastore: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook removes a webhook from Datastore
+func (r *DatastoreRepository) DeleteWebhook(ctx context.Context, webhookID string) error {
+	if webhookID == "" {
+		return fmt.Errorf("webhook ID cannot be empty")
+	}
+
+	key := datastore.NameKey("Webhook", webhookID, nil)
+	if err := r.client.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete webhook from Datastore: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeploymentStats retrieves deployment statistics. Counts come from
+// aggregation queries rather than a scan of the deployment's updates.
+func (r *DatastoreRepository) GetDeploymentStats(ctx context.Context, deploymentID string) (successCount, failureCount, pendingCount int, err error) {
+	byStatus := func(statuses ...UpdateStatus) *datastore.Query {
+		values := make([]interface{}, len(statuses))
+		for i, status := range statuses {
+			values[i] = string(status)
 		}
+		return datastore.NewQuery("DeviceUpdate").
+			Filter("deployment_id =", deploymentID).
+			FilterField("status", "in", values)
+	}
+
+	if successCount, err = r.countDeviceUpdates(ctx, byStatus(UpdateStatusCompleted)); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count completed device updates: %w", err)
+	}
+	if failureCount, err = r.countDeviceUpdates(ctx, byStatus(UpdateStatusFailed)); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count failed device updates: %w", err)
+	}
+	if pendingCount, err = r.countDeviceUpdates(ctx, byStatus(UpdateStatusPending, UpdateStatusDownloading, UpdateStatusInstalling)); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count pending device updates: %w", err)
 	}
 
 	return successCount, failureCount, pendingCount, nil
@@ -0,0 +1,190 @@
+package ota
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DeltaFormat identifies the patch format produced by GenerateDelta.
+//
+// A patch is the magic "ADP1", the base and target image sizes as
+// uvarints, then a raw DEFLATE stream of operations:
+//
+//	0x01 offset length   copy length bytes of the base image from offset
+//	0x02 length data     append length literal bytes
+//
+// with offsets and lengths as uvarints. Operations are applied in order
+// and must produce exactly the target size. Devices inflate the stream as
+// they go, so a patch is applied without holding it in memory, and check
+// the result against the release's binary hash and signature.
+const DeltaFormat = "athena-delta/1"
+
+const (
+	deltaMagic     = "ADP1"
+	deltaBlockSize = 16 // shortest run of the base that is copied
+
+	deltaOpCopy   byte = 0x01
+	deltaOpInsert byte = 0x02
+
+	// maxDeltaTargetSize bounds the image a patch may claim to produce
+	maxDeltaTargetSize = 64 << 20
+)
+
+// ErrInvalidDelta is returned for patches that are malformed or do not
+// apply to the given base image
+var ErrInvalidDelta = errors.New("invalid delta patch")
+
+// GenerateDelta computes a patch turning base into target. Runs of target
+// found in base are copied from it, everything else is sent literally.
+func GenerateDelta(base, target []byte) ([]byte, error) {
+	if len(target) > maxDeltaTargetSize {
+		return nil, fmt.Errorf("target image of %d bytes is too large for a delta", len(target))
+	}
+
+	// Blocks of the base at aligned offsets; matches found through them
+	// are extended in both directions byte by byte
+	index := make(map[string]int, len(base)/deltaBlockSize)
+	for offset := 0; offset+deltaBlockSize <= len(base); offset += deltaBlockSize {
+		key := string(base[offset : offset+deltaBlockSize])
+		if _, exists := index[key]; !exists {
+			index[key] = offset
+		}
+	}
+
+	var ops bytes.Buffer
+	var scratch [binary.MaxVarintLen64]byte
+	writeUvarint := func(value int) {
+		n := binary.PutUvarint(scratch[:], uint64(value))
+		ops.Write(scratch[:n])
+	}
+	insert := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		ops.WriteByte(deltaOpInsert)
+		writeUvarint(len(data))
+		ops.Write(data)
+	}
+
+	literal := 0 // start of the target not covered by operations yet
+	for i := 0; i+deltaBlockSize <= len(target); {
+		offset, found := index[string(target[i:i+deltaBlockSize])]
+		if !found {
+			i++
+			continue
+		}
+
+		start := i
+		for start > literal && offset > 0 && target[start-1] == base[offset-1] {
+			start--
+			offset--
+		}
+		length := i - start + deltaBlockSize
+		for start+length < len(target) && offset+length < len(base) && target[start+length] == base[offset+length] {
+			length++
+		}
+
+		insert(target[literal:start])
+		ops.WriteByte(deltaOpCopy)
+		writeUvarint(offset)
+		writeUvarint(length)
+
+		i = start + length
+		literal = i
+	}
+	insert(target[literal:])
+
+	var patch bytes.Buffer
+	patch.WriteString(deltaMagic)
+	n := binary.PutUvarint(scratch[:], uint64(len(base)))
+	patch.Write(scratch[:n])
+	n = binary.PutUvarint(scratch[:], uint64(len(target)))
+	patch.Write(scratch[:n])
+
+	writer, err := flate.NewWriter(&patch, flate.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress delta: %w", err)
+	}
+	if _, err := writer.Write(ops.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to compress delta: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress delta: %w", err)
+	}
+	return patch.Bytes(), nil
+}
+
+// ApplyDelta applies a patch to the base image it was generated from
+func ApplyDelta(base, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, []byte(deltaMagic)) {
+		return nil, fmt.Errorf("%w: not an %s patch", ErrInvalidDelta, DeltaFormat)
+	}
+	header := bytes.NewReader(patch[len(deltaMagic):])
+	baseSize, err := binary.ReadUvarint(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrInvalidDelta)
+	}
+	targetSize, err := binary.ReadUvarint(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrInvalidDelta)
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("%w: patch is for a %d byte base, got %d bytes", ErrInvalidDelta, baseSize, len(base))
+	}
+	if targetSize > maxDeltaTargetSize {
+		return nil, fmt.Errorf("%w: target size %d is too large", ErrInvalidDelta, targetSize)
+	}
+
+	ops := bufio.NewReader(flate.NewReader(header))
+	target := make([]byte, 0, targetSize)
+	for {
+		op, err := ops.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDelta, err)
+		}
+
+		switch op {
+		case deltaOpCopy:
+			offset, err := binary.ReadUvarint(ops)
+			if err != nil {
+				return nil, fmt.Errorf("%w: truncated copy", ErrInvalidDelta)
+			}
+			length, err := binary.ReadUvarint(ops)
+			if err != nil {
+				return nil, fmt.Errorf("%w: truncated copy", ErrInvalidDelta)
+			}
+			if offset > baseSize || length > baseSize-offset || length > targetSize-uint64(len(target)) {
+				return nil, fmt.Errorf("%w: copy out of range", ErrInvalidDelta)
+			}
+			target = append(target, base[offset:offset+length]...)
+		case deltaOpInsert:
+			length, err := binary.ReadUvarint(ops)
+			if err != nil {
+				return nil, fmt.Errorf("%w: truncated insert", ErrInvalidDelta)
+			}
+			if length > targetSize-uint64(len(target)) {
+				return nil, fmt.Errorf("%w: insert out of range", ErrInvalidDelta)
+			}
+			start := len(target)
+			target = target[:start+int(length)]
+			if _, err := io.ReadFull(ops, target[start:]); err != nil {
+				return nil, fmt.Errorf("%w: truncated insert", ErrInvalidDelta)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown operation 0x%02x", ErrInvalidDelta, op)
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("%w: produced %d of %d bytes", ErrInvalidDelta, len(target), targetSize)
+	}
+	return target, nil
+}
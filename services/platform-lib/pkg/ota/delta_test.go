@@ -0,0 +1,174 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testFirmware returns a pseudo-random image and a next version of it with
+// code inserted, patched and removed
+func testFirmware(size int) (base, target []byte) {
+	random := rand.New(rand.NewSource(1))
+	base = make([]byte, size)
+	random.Read(base)
+
+	inserted := make([]byte, 300)
+	random.Read(inserted)
+	target = append([]byte{}, base[:size/4]...)
+	target = append(target, inserted...)
+	target = append(target, base[size/4:size/2]...)
+	target = append(target, base[size/2+500:]...)
+	target[size/3] ^= 0xff
+	return base, target
+}
+
+func TestGenerateDelta_RoundTrip(t *testing.T) {
+	base, target := testFirmware(64 << 10)
+
+	patch, err := GenerateDelta(base, target)
+	require.NoError(t, err)
+	assert.Less(t, len(patch), len(target)/20)
+
+	patched, err := ApplyDelta(base, patch)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(target, patched))
+
+	// Unrelated and empty images still round trip
+	for _, pair := range [][2][]byte{{nil, target}, {base, nil}, {[]byte("short"), []byte("shorter")}} {
+		patch, err := GenerateDelta(pair[0], pair[1])
+		require.NoError(t, err)
+		patched, err := ApplyDelta(pair[0], patch)
+		require.NoError(t, err)
+		assert.Equal(t, len(pair[1]), len(patched))
+	}
+}
+
+func TestApplyDelta_Invalid(t *testing.T) {
+	base, target := testFirmware(8 << 10)
+	patch, err := GenerateDelta(base, target)
+	require.NoError(t, err)
+
+	_, err = ApplyDelta(base[:len(base)-1], patch)
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	_, err = ApplyDelta(base, patch[:len(patch)/2])
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+	_, err = ApplyDelta(base, []byte("BSDIFF40"))
+	assert.ErrorIs(t, err, ErrInvalidDelta)
+}
+
+func TestService_CreateRelease_Deltas(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	service.config.Deltas.Enabled = true
+	ctx := context.Background()
+
+	base, target := testFirmware(32 << 10)
+	previous := createTestRelease("release-001")
+	previous.BinaryHash = ComputeHash(base)
+	unrelated := createTestRelease("release-000")
+	unrelated.Version = "0.9.0"
+	unrelated.BinaryHash = ComputeHash(make([]byte, 1000))
+	unrelated.CreatedAt = previous.CreatedAt.Add(-time.Hour)
+
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannel("")).Return([]*FirmwareRelease{unrelated, previous}, nil)
+	mockStorage.On("StoreBinary", mock.Anything, mock.AnythingOfType("string"), target).Return("/binaries/release-002.bin", nil)
+	mockStorage.On("GetBinary", mock.Anything, previous.BinaryPath).Return(base, nil)
+	mockStorage.On("GetBinary", mock.Anything, unrelated.BinaryPath).Return(make([]byte, 1000), nil)
+	mockStorage.On("StoreBinary", mock.Anything, mock.MatchedBy(func(path string) bool {
+		return strings.HasSuffix(path, "/deltas/release-001")
+	}), mock.Anything).Return("/binaries/release-002/deltas/release-001.bin", nil)
+	mockRepo.On("CreateRelease", mock.Anything, mock.AnythingOfType("*ota.FirmwareRelease")).Return(nil)
+
+	release, err := service.CreateRelease(ctx, &CreateReleaseRequest{
+		TemplateID: "template-001",
+		Version:    "1.1.0",
+		Channel:    ReleaseChannelStable,
+		BinaryData: target,
+	})
+	require.NoError(t, err)
+
+	// The unrelated release gives no smaller patch and is skipped
+	require.Len(t, release.Deltas, 1)
+	patch := release.Deltas[0]
+	assert.Equal(t, "release-001", patch.BaseReleaseID)
+	assert.Equal(t, "1.0.0", patch.BaseVersion)
+	assert.Equal(t, DeltaFormat, patch.Format)
+	assert.Less(t, patch.PatchSize, release.BinarySize/10)
+	assert.NotEmpty(t, patch.Signature)
+
+	entity, err := release.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	require.Len(t, restored.Deltas, 1)
+	assert.Equal(t, patch.PatchPath, restored.Deltas[0].PatchPath)
+	assert.Equal(t, patch.Signature, restored.Deltas[0].Signature)
+}
+
+func TestService_GetDeltaUpdateForDevice(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, mockStorage := setupTestService()
+	service.config.Deltas.Enabled = true
+
+	base, target := testFirmware(32 << 10)
+	previous := createTestRelease("release-001")
+	previous.BinaryHash = ComputeHash(base)
+	release := createTestRelease("release-002")
+	release.Version = "1.1.0"
+	release.BinaryHash = ComputeHash(target)
+	release.BinarySize = int64(len(target))
+
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, mock.Anything).Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-002", Status: UpdateStatusPending,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-002").Return(release, nil)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannel("")).Return([]*FirmwareRelease{release, previous}, nil)
+	mockRepo.On("UpdateRelease", mock.Anything, mock.AnythingOfType("*ota.FirmwareRelease")).Return(nil).Once()
+	mockStorage.On("GetBinary", mock.Anything, previous.BinaryPath).Return(base, nil)
+	mockStorage.On("GetBinary", mock.Anything, release.BinaryPath).Return(target, nil)
+	mockStorage.On("StoreBinary", mock.Anything, "release-002/deltas/release-001", mock.Anything).Return("release-002/deltas/release-001/firmware.bin", nil).Once()
+	mockStorage.On("GetBinaryURL", mock.Anything, mock.Anything, time.Hour).Return("https://storage/firmware", nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(nil, assert.AnError)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	get := func(query string) *FirmwareUpdate {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/updates/device-001/delta"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var update FirmwareUpdate
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &update))
+		return &update
+	}
+
+	// The first request generates the patch, later ones reuse it
+	update := get("?version=1.0.0")
+	require.NotNil(t, update.Delta)
+	assert.Equal(t, "release-001", update.Delta.BaseReleaseID)
+	assert.Equal(t, release.BinaryHash, update.BinaryHash)
+	require.Len(t, release.Deltas, 1)
+
+	update = get("?hash=" + previous.BinaryHash)
+	require.NotNil(t, update.Delta)
+	assert.Equal(t, update.Delta.PatchSize, release.Deltas[0].PatchSize)
+
+	// Unknown firmware falls back to the full image
+	update = get("?version=0.1.0")
+	assert.Nil(t, update.Delta)
+	assert.Equal(t, "https://storage/firmware", update.BinaryURL)
+	update = get("")
+	assert.Nil(t, update.Delta)
+
+	mockRepo.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
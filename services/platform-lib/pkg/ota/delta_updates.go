@@ -0,0 +1,254 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// errDeltaNotSmaller is returned when a patch would be no smaller than the
+// full image, so the image is sent instead
+var errDeltaNotSmaller = errors.New("delta patch is not smaller than the image")
+
+// deltaSettings fills unset delta options with the defaults
+func deltaSettings(cfg *config.Config) config.DeltasConfig {
+	var settings config.DeltasConfig
+	if cfg != nil {
+		settings = cfg.Deltas
+	}
+	if settings.MaxBases <= 0 {
+		settings.MaxBases = 3
+	}
+	return settings
+}
+
+// generateDeltas adds patches to a new release from the most recent earlier
+// releases of its template. Patches that fail are skipped; devices running
+// those releases download the full image.
+func (s *Service) generateDeltas(ctx context.Context, release *FirmwareRelease, binaryData []byte) {
+	settings := deltaSettings(s.config)
+	if !settings.Enabled {
+		return
+	}
+
+	releases, err := s.repository.ListReleases(ctx, release.TemplateID, "")
+	if err != nil {
+		s.logger.Warn("Failed to list base releases for deltas", "release_id", release.ReleaseID, "error", err)
+		return
+	}
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].CreatedAt.After(releases[j].CreatedAt) })
+
+	for _, base := range releases {
+		if len(release.Deltas) >= settings.MaxBases {
+			break
+		}
+		if base.ReleaseID == release.ReleaseID || base.BinaryHash == release.BinaryHash {
+			continue
+		}
+		patch, err := s.createDelta(ctx, base, release, binaryData)
+		if err != nil {
+			s.logger.Warn("Skipped delta patch", "release_id", release.ReleaseID, "base_release_id", base.ReleaseID, "error", err)
+			continue
+		}
+		release.Deltas = append(release.Deltas, *patch)
+	}
+}
+
+// createDelta generates, signs and stores the patch from base to target.
+// The patch is checked to reproduce the target before it is stored.
+func (s *Service) createDelta(ctx context.Context, base, target *FirmwareRelease, targetData []byte) (*DeltaPatch, error) {
+	baseData, err := s.storageBackend.GetBinary(ctx, base.BinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base binary: %w", err)
+	}
+	if ComputeHash(baseData) != base.BinaryHash {
+		return nil, fmt.Errorf("base binary does not match its hash")
+	}
+
+	patch, err := GenerateDelta(baseData, targetData)
+	if err != nil {
+		return nil, err
+	}
+	if len(patch) >= len(targetData) {
+		return nil, errDeltaNotSmaller
+	}
+	patched, err := ApplyDelta(baseData, patch)
+	if err != nil || ComputeHash(patched) != target.BinaryHash {
+		return nil, fmt.Errorf("generated patch does not reproduce the release")
+	}
+
+	signature, keyID, err := s.signer.SignBinaryWithKeyID(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign patch: %w", err)
+	}
+	path, err := s.storageBackend.StoreBinary(ctx, target.ReleaseID+"/deltas/"+base.ReleaseID, patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store patch: %w", err)
+	}
+
+	return &DeltaPatch{
+		BaseReleaseID: base.ReleaseID,
+		BaseVersion:   base.Version,
+		BaseHash:      base.BinaryHash,
+		Format:        DeltaFormat,
+		PatchPath:     path,
+		PatchHash:     ComputeHash(patch),
+		PatchSize:     int64(len(patch)),
+		Signature:     signature,
+		SigningKeyID:  keyID,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// deleteDeltas removes the stored patches of a release
+func (s *Service) deleteDeltas(ctx context.Context, release *FirmwareRelease) {
+	for _, patch := range release.Deltas {
+		if err := s.storageBackend.DeleteBinary(ctx, patch.PatchPath); err != nil {
+			s.logger.Warn("Failed to delete delta patch from storage", "release_id", release.ReleaseID, "error", err)
+		}
+	}
+}
+
+// GetDeltaUpdateForDevice describes the pending update of a device as a
+// patch from the firmware it runs, given by version or binary hash. With
+// neither, the firmware hash the device last registered is used. A patch
+// from an older release is generated on first request. When no patch
+// applies, the full image is described, compressed with the first of
+// encodings the release has, and Delta is left empty.
+func (s *Service) GetDeltaUpdateForDevice(ctx context.Context, deviceID, currentVersion, currentHash string, encodings ...string) (*FirmwareUpdate, error) {
+	pending, deployment, err := s.pendingUpdate(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	update, err := s.firmwareUpdate(ctx, pending, deployment, encodings)
+	if err != nil {
+		return nil, err
+	}
+
+	if currentVersion == "" && currentHash == "" && s.deviceRepository != nil {
+		if dev, err := s.deviceRepository.GetDevice(ctx, deviceID); err == nil {
+			currentHash = dev.FirmwareHash
+		}
+	}
+	if currentVersion == "" && currentHash == "" {
+		return update, nil
+	}
+
+	release, err := s.repository.GetRelease(ctx, update.ReleaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	patch := findDelta(release.Deltas, currentVersion, currentHash)
+	if patch == nil {
+		patch, err = s.deltaOnDemand(ctx, release, currentVersion, currentHash)
+		if err != nil {
+			s.logger.Info("Serving full image instead of a delta", "device_id", deviceID, "release_id", release.ReleaseID, "reason", err)
+			return update, nil
+		}
+	}
+
+	patchURL, err := s.downloadURL(ctx, pending, deployment, patch.PatchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate patch URL: %w", err)
+	}
+	update.Delta = &DeltaDownload{
+		Format:        patch.Format,
+		BaseReleaseID: patch.BaseReleaseID,
+		BaseVersion:   patch.BaseVersion,
+		BaseHash:      patch.BaseHash,
+		PatchURL:      patchURL,
+		PatchHash:     patch.PatchHash,
+		PatchSize:     patch.PatchSize,
+		Signature:     patch.Signature,
+		SigningKeyID:  patch.SigningKeyID,
+	}
+	return update, nil
+}
+
+// findDelta returns the patch from the given firmware. A hash identifies
+// the firmware exactly, so it is preferred over the version.
+func findDelta(deltas []DeltaPatch, version, hash string) *DeltaPatch {
+	for i := range deltas {
+		if hash != "" && deltas[i].BaseHash == hash {
+			return &deltas[i]
+		}
+	}
+	if hash != "" {
+		return nil
+	}
+	for i := range deltas {
+		if deltas[i].BaseVersion == version {
+			return &deltas[i]
+		}
+	}
+	return nil
+}
+
+// deltaOnDemand generates the patch to a release from an earlier release of
+// its template that is not among the ones patched on creation
+func (s *Service) deltaOnDemand(ctx context.Context, release *FirmwareRelease, version, hash string) (*DeltaPatch, error) {
+	if !deltaSettings(s.config).Enabled {
+		return nil, fmt.Errorf("delta updates are disabled")
+	}
+	if hash == release.BinaryHash || (hash == "" && version == release.Version) {
+		return nil, fmt.Errorf("device already runs release %s", release.ReleaseID)
+	}
+
+	releases, err := s.repository.ListReleases(ctx, release.TemplateID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	var base *FirmwareRelease
+	for _, candidate := range releases {
+		if (hash != "" && candidate.BinaryHash == hash) || (hash == "" && candidate.Version == version) {
+			base = candidate
+			break
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("device firmware is not a release of template %s", release.TemplateID)
+	}
+
+	targetData, err := s.storageBackend.GetBinary(ctx, release.BinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release binary: %w", err)
+	}
+	patch, err := s.createDelta(ctx, base, release, targetData)
+	if err != nil {
+		return nil, err
+	}
+
+	release.Deltas = append(release.Deltas, *patch)
+	if err := s.repository.UpdateRelease(ctx, release); err != nil {
+		// The patch is still served; it is generated again next time
+		s.logger.Warn("Failed to save delta patch", "release_id", release.ReleaseID, "error", err)
+	}
+	s.logger.Info("Generated delta patch", "release_id", release.ReleaseID, "base_release_id", base.ReleaseID, "patch_size", patch.PatchSize)
+	return patch, nil
+}
+
+// getDeltaUpdateHandler serves the pending update of a device as a patch
+// from its current firmware, reported in the version or hash query
+// parameter, or as the full image in an encoding from accept_encoding
+func (s *Service) getDeltaUpdateHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	if err := s.authenticateUpdateCheck(c.Request, deviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	update, err := s.GetDeltaUpdateForDevice(c.Request.Context(), deviceID, c.Query("version"), c.Query("hash"), parseAcceptedEncodings(c.Query("accept_encoding"))...)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, update)
+}
@@ -2,12 +2,14 @@ package ota
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
 
-	"github.com/athena/platform-lib/internal/device"
-	"github.com/google/uuid"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/ids"
+	"github.com/athena/platform-lib/pkg/notifications"
 )
 
 // DeployRelease creates and initiates a new deployment for a firmware release
@@ -19,33 +21,69 @@ func (s *Service) DeployRelease(ctx context.Context, releaseID string, config *D
 	}
 
 	// Validate deployment configuration
-	if err := s.validateDeploymentConfig(config); err != nil {
+	if err := s.validateDeploymentConfig(ctx, config); err != nil {
 		return nil, fmt.Errorf("invalid deployment configuration: %w", err)
 	}
 
-	// Determine target devices
-	targetDevices, err := s.determineTargetDevices(ctx, release, config)
+	// Determine target devices. Devices without room for the binary are
+	// reported as skipped rather than left to fail mid-download.
+	targetDevices, skipped, err := s.determineTargetDevices(ctx, release, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine target devices: %w", err)
 	}
 
 	if len(targetDevices) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("%w: none of the %d target devices has room for the %d byte release", ErrInvalidTargets, len(skipped), release.BinarySize)
+		}
 		return nil, fmt.Errorf("no target devices found for deployment")
 	}
 
+	deploymentID, err := s.ids.NewID(ctx, ids.KindDeployment, s.deploymentExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate deployment ID: %w", err)
+	}
+	name, err := s.deploymentName(ctx, releaseID, config.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Releases on channels that need approval wait for it before any
+	// device updates are created
+	status := DeploymentStatusPending
+	if s.approvalRequired(release, config) {
+		status = DeploymentStatusPendingApproval
+	}
+
 	// Create deployment
 	deployment := &OTADeployment{
-		DeploymentID:      uuid.New().String(),
-		ReleaseID:         releaseID,
-		Strategy:          config.Strategy,
-		TargetDevices:     targetDevices,
-		RolloutPercentage: config.RolloutPercentage,
-		Status:            DeploymentStatusPending,
-		FailureThreshold:  config.FailureThreshold,
-		SuccessCount:      0,
-		FailureCount:      0,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
+		DeploymentID:       deploymentID,
+		Name:               name,
+		ReleaseID:          releaseID,
+		Strategy:           config.Strategy,
+		TargetDevices:      targetDevices,
+		SkippedDevices:     skipped,
+		TargetGroups:       config.TargetGroups,
+		TargetLabels:       config.TargetLabels,
+		RollingTargets:     config.RollingTargets,
+		RolloutPercentage:  config.RolloutPercentage,
+		Status:             status,
+		FailureThreshold:   config.FailureThreshold,
+		SuccessCount:       0,
+		FailureCount:       0,
+		Annotations:        config.Annotations,
+		DataBudgetBytes:    config.DataBudgetBytes,
+		StartAt:            config.StartAt,
+		EndAt:              config.EndAt,
+		MaintenanceWindows: config.MaintenanceWindows,
+		PromotionTiers:     config.PromotionTiers,
+		PromotionSuccess:   config.PromotionSuccess,
+		URLExpirySeconds:   config.URLExpirySeconds,
+		OneTimeDownloads:   config.OneTimeDownloads,
+		RetryPolicy:        config.RetryPolicy,
+		HealthPolicy:       config.HealthPolicy,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
 	}
 
 	// Store deployment
@@ -54,28 +92,52 @@ func (s *Service) DeployRelease(ctx context.Context, releaseID string, config *D
 		return nil, fmt.Errorf("failed to create deployment: %w", err)
 	}
 
+	if status == DeploymentStatusPendingApproval {
+		s.logger.Info("Created deployment awaiting approval", "deployment_id", deployment.DeploymentID, "release_id", releaseID, "channel", release.Channel, "target_devices", len(targetDevices))
+		s.notifyDeploymentCreated(deployment, release)
+		return deployment, nil
+	}
+
+	if err := s.startDeployment(ctx, deployment); err != nil {
+		return nil, err
+	}
+	s.notifyDeploymentCreated(deployment, release)
+
+	s.logger.Info("Created deployment", "deployment_id", deployment.DeploymentID, "release_id", releaseID, "strategy", config.Strategy, "target_devices", len(targetDevices), "skipped_devices", len(skipped))
+
+	return deployment, nil
+}
+
+// startDeployment creates the device updates of a pending deployment and
+// activates it when its strategy calls for that
+func (s *Service) startDeployment(ctx context.Context, deployment *OTADeployment) error {
 	// Initialize device updates based on strategy
-	err = s.initializeDeviceUpdates(ctx, deployment)
+	err := s.initializeDeviceUpdates(ctx, deployment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize device updates: %w", err)
+		return fmt.Errorf("failed to initialize device updates: %w", err)
 	}
 
-	// Start deployment if immediate strategy
-	if config.Strategy == DeploymentStrategyImmediate {
+	// Start deployment if immediate strategy. Scheduled deployments start
+	// now only when inside their schedule, otherwise the scheduler starts
+	// them later.
+	if deployment.scheduled() {
+		if applySchedule(deployment, time.Now()) != "" {
+			if err := s.repository.UpdateDeployment(ctx, deployment); err != nil {
+				return fmt.Errorf("failed to activate deployment: %w", err)
+			}
+		}
+	} else if deployment.Strategy == DeploymentStrategyImmediate {
 		deployment.Status = DeploymentStatusActive
 		err = s.repository.UpdateDeployment(ctx, deployment)
 		if err != nil {
-			return nil, fmt.Errorf("failed to activate deployment: %w", err)
+			return fmt.Errorf("failed to activate deployment: %w", err)
 		}
 	}
-
-	s.logger.Info("Created deployment", "deployment_id", deployment.DeploymentID, "release_id", releaseID, "strategy", config.Strategy, "target_devices", len(targetDevices))
-
-	return deployment, nil
+	return nil
 }
 
 // validateDeploymentConfig validates the deployment configuration
-func (s *Service) validateDeploymentConfig(config *DeploymentConfig) error {
+func (s *Service) validateDeploymentConfig(ctx context.Context, config *DeploymentConfig) error {
 	if config == nil {
 		return fmt.Errorf("deployment config cannot be nil")
 	}
@@ -93,24 +155,64 @@ func (s *Service) validateDeploymentConfig(config *DeploymentConfig) error {
 			return fmt.Errorf("rollout percentage must be between 1 and 100")
 		}
 	}
+	if err := s.validatePromotion(config); err != nil {
+		return err
+	}
+
+	if err := ValidateAnnotations(config.Annotations); err != nil {
+		return err
+	}
+	if err := validateRetryPolicy(config.RetryPolicy); err != nil {
+		return err
+	}
+	if config.DataBudgetBytes < 0 {
+		return fmt.Errorf("data budget cannot be negative")
+	}
+	if err := validateSchedule(config, time.Now()); err != nil {
+		return err
+	}
+	if err := validateDownloads(config); err != nil {
+		return err
+	}
+	if err := s.validateTargets(ctx, config); err != nil {
+		return err
+	}
+	if err := validateHealthPolicy(config.HealthPolicy); err != nil {
+		return err
+	}
 
 	// Set default failure threshold if not specified
 	if config.FailureThreshold == 0 {
 		config.FailureThreshold = 10 // Default 10% failure threshold
 	}
+	if config.DataBudgetBytes == 0 && s.config != nil {
+		config.DataBudgetBytes = s.config.Bandwidth.DeploymentBudget
+	}
 
 	return nil
 }
 
 // determineTargetDevices determines which devices should receive the update
-func (s *Service) determineTargetDevices(ctx context.Context, release *FirmwareRelease, config *DeploymentConfig) ([]string, error) {
+func (s *Service) determineTargetDevices(ctx context.Context, release *FirmwareRelease, config *DeploymentConfig) ([]string, []*SkippedDevice, error) {
 	var targetDevices []string
-
-	// If specific devices are provided, use them
-	if len(config.TargetDevices) > 0 {
-		targetDevices = config.TargetDevices
+	var skipped []*SkippedDevice
+
+	// If specific devices, groups or labels are provided, use them
+	if len(config.TargetDevices) > 0 || config.targeted() {
+		listed, undersized := s.excludeUndersized(ctx, release, config.TargetDevices)
+		targetDevices, skipped = append(targetDevices, listed...), undersized
+		if config.targeted() {
+			resolved, err := s.resolveTargets(ctx, release, config.TargetGroups, config.TargetLabels)
+			if err != nil {
+				return nil, nil, err
+			}
+			fitting, undersized := splitByCapacity(resolved, release)
+			targetDevices = mergeTargets(targetDevices, fitting)
+			skipped = mergeSkipped(skipped, undersized, targetDevices)
+		}
 	} else {
-		// Query devices by template and OTA channel
+		// Query devices by template and OTA channel. Devices enrolled
+		// manually are left to deployments that name them.
 		filters := &device.DeviceFilters{
 			TemplateID: release.TemplateID,
 			OTAChannel: string(release.Channel),
@@ -118,15 +220,13 @@ func (s *Service) determineTargetDevices(ctx context.Context, release *FirmwareR
 
 		devices, err := s.deviceRepository.ListDevices(ctx, filters)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list devices: %w", err)
+			return nil, nil, fmt.Errorf("failed to list devices: %w", err)
 		}
 
-		for _, dev := range devices {
-			targetDevices = append(targetDevices, dev.DeviceID)
-		}
+		targetDevices, skipped = splitByCapacity(autoEnrolled(devices), release)
 	}
 
-	return targetDevices, nil
+	return targetDevices, skipped, nil
 }
 
 // initializeDeviceUpdates creates device update records for the deployment
@@ -141,6 +241,7 @@ func (s *Service) initializeDeviceUpdates(ctx context.Context, deployment *OTADe
 			DeploymentID: deployment.DeploymentID,
 			Status:       UpdateStatusPending,
 			Progress:     0,
+			Attempts:     1,
 			StartedAt:    time.Now(),
 		}
 
@@ -165,18 +266,11 @@ func (s *Service) selectDevicesForUpdate(deployment *OTADeployment) []string {
 
 	case DeploymentStrategyStaged:
 		// Update a percentage of devices
-		numDevices := (totalDevices * deployment.RolloutPercentage) / 100
-		if numDevices == 0 {
-			numDevices = 1
-		}
-		return deployment.TargetDevices[:numDevices]
+		return deployment.TargetDevices[:rolloutSize(totalDevices, deployment.RolloutPercentage)]
 
 	case DeploymentStrategyCanary:
 		// Start with a small canary group (use rollout percentage or default to 5%)
-		numDevices := (totalDevices * deployment.RolloutPercentage) / 100
-		if numDevices == 0 {
-			numDevices = 1
-		}
+		numDevices := rolloutSize(totalDevices, deployment.RolloutPercentage)
 		// Shuffle to get random canary devices
 		shuffled := make([]string, len(deployment.TargetDevices))
 		copy(shuffled, deployment.TargetDevices)
@@ -219,7 +313,8 @@ func (s *Service) PauseDeployment(ctx context.Context, deploymentID string) erro
 	return nil
 }
 
-// ResumeDeployment resumes a paused deployment
+// ResumeDeployment resumes a paused deployment. A deployment resumed
+// outside its maintenance windows is paused again by the scheduler.
 func (s *Service) ResumeDeployment(ctx context.Context, deploymentID string) error {
 	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
 	if err != nil {
@@ -232,6 +327,7 @@ func (s *Service) ResumeDeployment(ctx context.Context, deploymentID string) err
 
 	deployment.Status = DeploymentStatusActive
 	deployment.UpdatedAt = time.Now()
+	delete(deployment.Annotations, AnnotationScheduleHold)
 
 	err = s.repository.UpdateDeployment(ctx, deployment)
 	if err != nil {
@@ -243,84 +339,132 @@ func (s *Service) ResumeDeployment(ctx context.Context, deploymentID string) err
 	return nil
 }
 
-// RollbackDeployment rolls back a deployment to the previous firmware version
-func (s *Service) RollbackDeployment(ctx context.Context, deploymentID string) error {
-	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
-	if err != nil {
-		return fmt.Errorf("failed to get deployment: %w", err)
-	}
-
-	// Get the release being deployed
-	release, err := s.repository.GetRelease(ctx, deployment.ReleaseID)
+// CancelDeployment ends a deployment that has not finished. No further
+// updates are issued, pending device updates are cancelled and the
+// statistics are settled. Updates already downloading or installing are
+// left to finish and still count. A cancelled deployment cannot be resumed.
+func (s *Service) CancelDeployment(ctx context.Context, deploymentID string) (*OTADeployment, error) {
+	// Cancel before touching the updates, so status reports arriving
+	// meanwhile cannot complete the deployment
+	_, err := s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		switch d.Status {
+		case DeploymentStatusPending, DeploymentStatusPendingApproval, DeploymentStatusActive, DeploymentStatusPaused:
+		default:
+			return fmt.Errorf("can only cancel unfinished deployments, current status: %s", d.Status)
+		}
+		d.Status = DeploymentStatusCancelled
+		d.UpdatedAt = time.Now()
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get release: %w", err)
+		return nil, fmt.Errorf("failed to cancel deployment: %w", err)
 	}
 
-	// Find previous stable release for the same template
-	releases, err := s.repository.ListReleases(ctx, release.TemplateID, ReleaseChannelStable)
+	pending, err := s.repository.GetDeviceUpdatesByStatus(ctx, deploymentID, UpdateStatusPending)
 	if err != nil {
-		return fmt.Errorf("failed to list releases: %w", err)
+		return nil, fmt.Errorf("failed to get pending device updates: %w", err)
 	}
-
-	var previousRelease *FirmwareRelease
-	for _, r := range releases {
-		if r.ReleaseID != release.ReleaseID && r.CreatedAt.Before(release.CreatedAt) {
-			previousRelease = r
-			break
+	now := time.Now()
+	for _, update := range pending {
+		update.Status = UpdateStatusCancelled
+		update.CompletedAt = &now
+		if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+			return nil, fmt.Errorf("failed to cancel update for device %s: %w", update.DeviceID, err)
 		}
 	}
 
-	if previousRelease == nil {
-		return fmt.Errorf("no previous release found for rollback")
+	if err := s.updateDeploymentStats(ctx, deploymentID); err != nil {
+		return nil, err
 	}
-
-	// Mark current deployment as failed
-	deployment.Status = DeploymentStatusFailed
-	deployment.UpdatedAt = time.Now()
-	err = s.repository.UpdateDeployment(ctx, deployment)
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
 	if err != nil {
-		return fmt.Errorf("failed to update deployment status: %w", err)
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
+	s.notifyDeploymentFinished(ctx, deployment)
 
-	// Create a new deployment for the previous release
-	rollbackConfig := &DeploymentConfig{
-		Strategy:          DeploymentStrategyImmediate,
-		TargetDevices:     deployment.TargetDevices,
-		RolloutPercentage: 100,
-		FailureThreshold:  deployment.FailureThreshold,
-	}
+	s.logger.Info("Cancelled deployment", "deployment_id", deploymentID, "cancelled_updates", len(pending))
 
-	rollbackDeployment, err := s.DeployRelease(ctx, previousRelease.ReleaseID, rollbackConfig)
+	return deployment, nil
+}
+
+// GetUpdateForDevice retrieves the pending update for a device. The binary
+// is served compressed with the first of encodings, in the device's order
+// of preference, the release has been compressed with.
+func (s *Service) GetUpdateForDevice(ctx context.Context, deviceID string, encodings ...string) (*FirmwareUpdate, error) {
+	update, deployment, err := s.pendingUpdate(ctx, deviceID)
 	if err != nil {
-		return fmt.Errorf("failed to create rollback deployment: %w", err)
+		return nil, err
 	}
 
-	s.logger.Info("Rolled back deployment", "original_deployment_id", deploymentID, "rollback_deployment_id", rollbackDeployment.DeploymentID, "previous_release_id", previousRelease.ReleaseID)
-
-	return nil
+	return s.firmwareUpdate(ctx, update, deployment, encodings)
 }
 
-// GetUpdateForDevice retrieves the pending update for a device
-func (s *Service) GetUpdateForDevice(ctx context.Context, deviceID string) (*FirmwareUpdate, error) {
+// pendingUpdate returns the update a device should install now and its
+// deployment, which is nil for updates outside a deployment
+func (s *Service) pendingUpdate(ctx context.Context, deviceID string) (*DeviceUpdate, *OTADeployment, error) {
 	// Get the latest update for the device
 	update, err := s.repository.GetLatestUpdateForDevice(ctx, deviceID)
+
+	// A device with nothing in progress may have missed a rollout
+	if err != nil || update.finished() {
+		caught, deployment, catchUpErr := s.catchUp(ctx, deviceID)
+		if catchUpErr != nil {
+			s.logger.Warn("Failed to check device for catch-up", "device_id", deviceID, "error", catchUpErr)
+		}
+		if caught != nil {
+			return caught, deployment, nil
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("no pending update for device: %w", err)
+		return nil, nil, fmt.Errorf("no pending update for device: %w", err)
 	}
 
-	// Only return if status is pending
-	if update.Status != UpdateStatusPending {
-		return nil, fmt.Errorf("no pending update for device")
+	// Only return if status is pending and any retry backoff has passed
+	if !update.due(time.Now()) {
+		return nil, nil, fmt.Errorf("no pending update for device")
+	}
+	deployment, err := s.issuingDeployment(ctx, update)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	return update, deployment, nil
+}
+
+// issuingDeployment returns the deployment of a pending update, or an
+// error when it is holding its updates back, being paused or not yet
+// started on schedule
+func (s *Service) issuingDeployment(ctx context.Context, update *DeviceUpdate) (*OTADeployment, error) {
+	if update.DeploymentID == "" {
+		return nil, nil
+	}
+	deployment, err := s.repository.GetDeployment(ctx, update.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if !deployment.issuesUpdates() {
+		return nil, fmt.Errorf("no pending update for device: deployment %s is %s", deployment.DeploymentID, deployment.Status)
+	}
+	return deployment, nil
+}
+
+// firmwareUpdate describes the release of a pending update for download,
+// compressed with the first of encodings the release has
+func (s *Service) firmwareUpdate(ctx context.Context, update *DeviceUpdate, deployment *OTADeployment, encodings []string) (*FirmwareUpdate, error) {
 	// Get the release details
 	release, err := s.repository.GetRelease(ctx, update.ReleaseID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get release: %w", err)
 	}
 
-	// Generate signed URL for binary download
-	binaryURL, err := s.storageBackend.GetBinaryURL(ctx, release.BinaryPath, 1*time.Hour)
+	binaryPath := release.BinaryPath
+	encoded := negotiateEncoding(release.Encodings, encodings)
+	if encoded != nil {
+		binaryPath = encoded.Path
+	}
+
+	// Generate signed URL or one-time link for binary download
+	binaryURL, err := s.downloadURL(ctx, update, deployment, binaryPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate binary URL: %w", err)
 	}
@@ -332,13 +476,29 @@ func (s *Service) GetUpdateForDevice(ctx context.Context, deviceID string) (*Fir
 		BinaryHash:   release.BinaryHash,
 		BinarySize:   release.BinarySize,
 		Signature:    release.Signature,
+		SigningKeyID: release.SigningKeyID,
 		ReleaseNotes: release.ReleaseNotes,
+		Slots:        release.Slots,
 		CreatedAt:    release.CreatedAt,
 	}
+	if encoded != nil {
+		firmwareUpdate.Encoding = encoded.Encoding
+		firmwareUpdate.CompressedSize = encoded.Size
+		firmwareUpdate.CompressedHash = encoded.Hash
+	}
 
 	return firmwareUpdate, nil
 }
 
+// ListUpdatesForDevice returns the recent update attempts for a device, newest first
+func (s *Service) ListUpdatesForDevice(ctx context.Context, deviceID string, limit int) ([]*DeviceUpdate, error) {
+	updates, err := s.repository.ListUpdatesForDevice(ctx, deviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list updates for device: %w", err)
+	}
+	return updates, nil
+}
+
 // ReportUpdateStatus updates the status of a device update
 func (s *Service) ReportUpdateStatus(ctx context.Context, report *UpdateStatusReport) error {
 	// Get the device update
@@ -347,41 +507,114 @@ func (s *Service) ReportUpdateStatus(ctx context.Context, report *UpdateStatusRe
 		return fmt.Errorf("failed to get device update: %w", err)
 	}
 
+	if err := validateStatusReport(update.Status, report); err != nil {
+		return err
+	}
+	// Repeated reports of a final status change nothing
+	if update.Status == report.Status && (update.Status == UpdateStatusCompleted || update.Status == UpdateStatusFailed || update.Status == UpdateStatusCancelled) {
+		return nil
+	}
+
 	// Update status
+	previousStatus := update.Status
 	update.Status = report.Status
 	update.Progress = report.Progress
 	update.ErrorMessage = report.ErrorMessage
+	update.ErrorType = report.ErrorType
+	update.ErrorCode = report.ErrorCode
+	if report.Downloaded > 0 {
+		update.Downloaded = report.Downloaded
+	}
+	if update.ErrorType == "" && report.ErrorCode != "" {
+		update.ErrorType = updateErrorCodeTypes[report.ErrorCode]
+	}
+	now := time.Now()
+	update.ReportedAt = &now
 
 	// Set completion time if completed or failed
 	if report.Status == UpdateStatusCompleted || report.Status == UpdateStatusFailed {
-		now := time.Now()
 		update.CompletedAt = &now
 	}
 
-	err = s.repository.UpdateDeviceUpdate(ctx, update)
-	if err != nil {
-		return fmt.Errorf("failed to update device update: %w", err)
+	// A failure the retry policy allows another attempt for stays pending
+	if report.Status == UpdateStatusFailed {
+		s.scheduleRetry(ctx, update)
 	}
 
-	// Update deployment statistics
-	err = s.updateDeploymentStats(ctx, update.DeploymentID)
+	err = s.repository.UpdateDeviceUpdate(ctx, update)
 	if err != nil {
-		s.logger.Warn("Failed to update deployment stats", "deployment_id", update.DeploymentID, "error", err)
+		return fmt.Errorf("failed to update device update: %w", err)
 	}
 
-	// Check for automatic failure detection and rollback
-	if report.Status == UpdateStatusFailed {
-		err = s.checkAndHandleFailures(ctx, update.DeploymentID)
+	// Progress-only reports cannot change the deployment statistics
+	if update.Status != previousStatus {
+		err = s.updateDeploymentStats(ctx, update.DeploymentID)
 		if err != nil {
-			s.logger.Warn("Failed to handle deployment failures", "deployment_id", update.DeploymentID, "error", err)
+			s.logger.Warn("Failed to update deployment stats", "deployment_id", update.DeploymentID, "error", err)
 		}
 	}
 
+	if update.Status == UpdateStatusFailed {
+		s.handleUpdateFailure(ctx, update)
+	}
+
+	if downloadFinished(previousStatus, report.Status) {
+		s.accountDownload(ctx, update)
+	}
+
+	if update.Status == UpdateStatusCompleted && previousStatus != UpdateStatusCompleted {
+		s.recordFirmwareUpdated(ctx, update)
+	}
+
 	s.logger.Info("Updated device update status", "device_id", report.DeviceID, "release_id", report.ReleaseID, "status", report.Status)
 
 	return nil
 }
 
+// recordFirmwareUpdated adds a completed update to the device's timeline
+func (s *Service) recordFirmwareUpdated(ctx context.Context, update *DeviceUpdate) {
+	if s.deviceEvents == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"release_id":    update.ReleaseID,
+		"deployment_id": update.DeploymentID,
+	}
+	message := fmt.Sprintf("Device %s was updated to release %s", update.DeviceID, update.ReleaseID)
+	if release, err := s.repository.GetRelease(ctx, update.ReleaseID); err == nil {
+		data["version"] = release.Version
+		data["template_id"] = release.TemplateID
+		message = fmt.Sprintf("Device %s was updated to firmware %s", update.DeviceID, release.Version)
+	}
+	s.deviceEvents.Record(ctx, update.DeviceID, device.DeviceEventFirmwareUpdated, message, data)
+}
+
+// handleUpdateFailure tells webhooks about a device update that failed for
+// good and checks its deployment for automatic failure detection and
+// rollback
+func (s *Service) handleUpdateFailure(ctx context.Context, update *DeviceUpdate) {
+	s.emitWebhookEvent(WebhookDeviceUpdateFailed, map[string]interface{}{
+		"device_id":     update.DeviceID,
+		"release_id":    update.ReleaseID,
+		"deployment_id": update.DeploymentID,
+		"error_type":    update.ErrorType,
+		"error_code":    update.ErrorCode,
+		"error_message": update.ErrorMessage,
+		"attempts":      update.attempts(),
+	})
+	if err := s.checkAndHandleFailures(ctx, update.DeploymentID); err != nil {
+		s.logger.Warn("Failed to handle deployment failures", "deployment_id", update.DeploymentID, "error", err)
+	}
+}
+
+// downloadFinished reports whether a status change means the device has
+// just finished downloading the firmware binary
+func downloadFinished(previous, current UpdateStatus) bool {
+	before := previous == UpdateStatusPending || previous == UpdateStatusDownloading
+	after := current == UpdateStatusInstalling || current == UpdateStatusCompleted
+	return before && after
+}
+
 // updateDeploymentStats updates the success and failure counts for a deployment
 func (s *Service) updateDeploymentStats(ctx context.Context, deploymentID string) error {
 	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
@@ -395,12 +628,16 @@ func (s *Service) updateDeploymentStats(ctx context.Context, deploymentID string
 		return fmt.Errorf("failed to get deployment stats: %w", err)
 	}
 
+	previousStatus := deployment.Status
 	deployment.SuccessCount = successCount
 	deployment.FailureCount = failureCount
 	deployment.UpdatedAt = time.Now()
 
-	// Check if deployment is complete
-	if pendingCount == 0 {
+	// Check if deployment is complete. A cancelled deployment keeps its
+	// status while updates already in flight finish, and one due for
+	// promotion stays active until the promoter expands it.
+	promoting := deployment.promotionDue(successCount, successCount+failureCount)
+	if pendingCount == 0 && deployment.Status != DeploymentStatusCancelled && !promoting {
 		if failureCount == 0 {
 			deployment.Status = DeploymentStatusCompleted
 		} else if successCount == 0 {
@@ -415,9 +652,87 @@ func (s *Service) updateDeploymentStats(ctx context.Context, deploymentID string
 		return fmt.Errorf("failed to update deployment: %w", err)
 	}
 
+	if deployment.Status != previousStatus {
+		s.notifyDeploymentFinished(ctx, deployment)
+	}
+
 	return nil
 }
 
+// notifyDeploymentCreated tells webhooks about a new deployment
+func (s *Service) notifyDeploymentCreated(deployment *OTADeployment, release *FirmwareRelease) {
+	s.emitWebhookEvent(WebhookDeploymentCreated, map[string]interface{}{
+		"deployment_id":  deployment.DeploymentID,
+		"name":           deployment.Name,
+		"release_id":     release.ReleaseID,
+		"template_id":    release.TemplateID,
+		"version":        release.Version,
+		"channel":        release.Channel,
+		"strategy":       deployment.Strategy,
+		"status":         deployment.Status,
+		"target_devices": len(deployment.TargetDevices),
+		"annotations":    deployment.Annotations,
+	})
+}
+
+// notifyDeploymentFinished publishes a completion, failure or cancellation
+// event for a deployment, and tells webhooks about completions and failures
+func (s *Service) notifyDeploymentFinished(ctx context.Context, deployment *OTADeployment) {
+	webhookData := map[string]interface{}{
+		"deployment_id": deployment.DeploymentID,
+		"release_id":    deployment.ReleaseID,
+		"status":        deployment.Status,
+		"success_count": deployment.SuccessCount,
+		"failure_count": deployment.FailureCount,
+		"annotations":   deployment.Annotations,
+	}
+	switch deployment.Status {
+	case DeploymentStatusCompleted:
+		s.emitWebhookEvent(WebhookDeploymentCompleted, webhookData)
+	case DeploymentStatusFailed:
+		s.emitWebhookEvent(WebhookDeploymentFailed, webhookData)
+	}
+
+	if s.publisher == nil {
+		return
+	}
+
+	var eventType notifications.EventType
+	switch deployment.Status {
+	case DeploymentStatusCompleted:
+		eventType = notifications.EventDeploymentCompleted
+	case DeploymentStatusFailed:
+		eventType = notifications.EventDeploymentFailed
+	case DeploymentStatusCancelled:
+		eventType = notifications.EventDeploymentCancelled
+	default:
+		return
+	}
+
+	notifications.PublishAsync(s.publisher, s.logger, &notifications.Event{
+		Type:         eventType,
+		ResourceType: "deployment",
+		ResourceID:   deployment.DeploymentID,
+		Owner:        s.deploymentOwner(ctx, deployment),
+		Message: fmt.Sprintf("Deployment %s %s: %d succeeded, %d failed",
+			deployment.DeploymentID, deployment.Status, deployment.SuccessCount, deployment.FailureCount),
+		Data: map[string]interface{}{
+			"release_id":    deployment.ReleaseID,
+			"success_count": deployment.SuccessCount,
+			"failure_count": deployment.FailureCount,
+		},
+	})
+}
+
+// deploymentOwner returns the author of a deployment's release, which
+// deployments inherit ownership from
+func (s *Service) deploymentOwner(ctx context.Context, deployment *OTADeployment) string {
+	if release, err := s.repository.GetRelease(ctx, deployment.ReleaseID); err == nil {
+		return release.CreatedBy
+	}
+	return ""
+}
+
 // checkAndHandleFailures checks if failure threshold is exceeded and triggers rollback
 func (s *Service) checkAndHandleFailures(ctx context.Context, deploymentID string) error {
 	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
@@ -443,8 +758,14 @@ func (s *Service) checkAndHandleFailures(ctx context.Context, deploymentID strin
 		s.logger.Warn("Failure threshold exceeded, triggering automatic rollback", "deployment_id", deploymentID, "failure_rate", failureRate, "threshold", deployment.FailureThreshold)
 
 		// Trigger automatic rollback
-		err = s.RollbackDeployment(ctx, deploymentID)
-		if err != nil {
+		err = s.rollbackDeployment(ctx, deploymentID, true)
+		switch {
+		case errors.Is(err, ErrAlreadyRolledBack):
+			// Another failure report got there first
+			return nil
+		case errors.Is(err, ErrRollbackDepthExceeded), errors.Is(err, ErrRollbackCooldown):
+			return s.holdForOperator(ctx, deploymentID, err)
+		case err != nil:
 			return fmt.Errorf("failed to rollback deployment: %w", err)
 		}
 	}
@@ -459,28 +780,17 @@ func (s *Service) GetDeploymentStatus(ctx context.Context, deploymentID string)
 		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	// Get device updates
-	updates, err := s.repository.ListDeviceUpdates(ctx, deploymentID)
+	// Count device updates without loading them
+	counts, err := s.repository.CountDeviceUpdatesByStatus(ctx, deploymentID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list device updates: %w", err)
-	}
-
-	// Calculate statistics
-	var pendingCount, downloadingCount, installingCount, completedCount, failedCount int
-	for _, update := range updates {
-		switch update.Status {
-		case UpdateStatusPending:
-			pendingCount++
-		case UpdateStatusDownloading:
-			downloadingCount++
-		case UpdateStatusInstalling:
-			installingCount++
-		case UpdateStatusCompleted:
-			completedCount++
-		case UpdateStatusFailed:
-			failedCount++
-		}
+		return nil, fmt.Errorf("failed to count device updates: %w", err)
 	}
+	pendingCount := counts[UpdateStatusPending]
+	downloadingCount := counts[UpdateStatusDownloading]
+	installingCount := counts[UpdateStatusInstalling]
+	completedCount := counts[UpdateStatusCompleted]
+	failedCount := counts[UpdateStatusFailed]
+	cancelledCount := counts[UpdateStatusCancelled]
 
 	totalDevices := len(deployment.TargetDevices)
 	progressPercentage := 0
@@ -488,38 +798,104 @@ func (s *Service) GetDeploymentStatus(ctx context.Context, deploymentID string)
 		progressPercentage = (completedCount * 100) / totalDevices
 	}
 
+	_, pausedBySchedule := deployment.Annotations[AnnotationScheduleHold]
 	report := &DeploymentStatusReport{
 		DeploymentID:       deployment.DeploymentID,
 		ReleaseID:          deployment.ReleaseID,
 		Status:             deployment.Status,
 		Strategy:           deployment.Strategy,
+		RolloutPercentage:  deployment.RolloutPercentage,
+		Promotions:         deployment.Promotions,
 		TotalDevices:       totalDevices,
 		PendingCount:       pendingCount,
 		DownloadingCount:   downloadingCount,
 		InstallingCount:    installingCount,
 		CompletedCount:     completedCount,
 		FailedCount:        failedCount,
+		CancelledCount:     cancelledCount,
 		ProgressPercentage: progressPercentage,
+		SkippedCount:       len(deployment.SkippedDevices),
+		Approval:           deployment.Approval,
+		BytesServed:        deployment.BytesServed,
+		DataBudgetBytes:    deployment.DataBudgetBytes,
+		StartAt:            deployment.StartAt,
+		EndAt:              deployment.EndAt,
+		MaintenanceWindows: deployment.MaintenanceWindows,
+		PausedBySchedule:   pausedBySchedule,
 		CreatedAt:          deployment.CreatedAt,
 		UpdatedAt:          deployment.UpdatedAt,
 	}
 
+	// Crashes are reported by devices separately from update status, so a
+	// missing crash history should not hide the rest of the report
+	crashes, err := s.repository.ListCrashReportsForDeployment(ctx, deploymentID)
+	if err != nil {
+		s.logger.Warn("Failed to list crash reports for deployment", "deployment_id", deploymentID, "error", err)
+	} else {
+		report.CrashCount = len(crashes)
+		report.CrashedDevices, report.CrashReasons = summarizeCrashes(crashes)
+	}
+
+	// Failures are only loaded when there are some to break down by code
+	if failedCount > 0 {
+		failed, err := s.repository.GetDeviceUpdatesByStatus(ctx, deploymentID, UpdateStatusFailed)
+		if err != nil {
+			s.logger.Warn("Failed to list failed device updates", "deployment_id", deploymentID, "error", err)
+		} else {
+			report.FailureCodes = countFailureCodes(failed)
+		}
+	}
+
 	return report, nil
 }
 
-// DeploymentStatusReport represents the status report for a deployment
+// countFailureCodes counts failed updates by the error code their device
+// reported. Failures without a code are left out.
+func countFailureCodes(updates []*DeviceUpdate) map[UpdateErrorCode]int {
+	var counts map[UpdateErrorCode]int
+	for _, update := range updates {
+		if update.ErrorCode == "" {
+			continue
+		}
+		if counts == nil {
+			counts = make(map[UpdateErrorCode]int)
+		}
+		counts[update.ErrorCode]++
+	}
+	return counts
+}
+
+// DeploymentStatusReport represents the status report for a deployment.
+// FailureCodes counts the failed device updates by reported error code.
+// SkippedCount is the number of targeted devices left out for lack of
+// storage.
 type DeploymentStatusReport struct {
-	DeploymentID       string             `json:"deployment_id"`
-	ReleaseID          string             `json:"release_id"`
-	Status             DeploymentStatus   `json:"status"`
-	Strategy           DeploymentStrategy `json:"strategy"`
-	TotalDevices       int                `json:"total_devices"`
-	PendingCount       int                `json:"pending_count"`
-	DownloadingCount   int                `json:"downloading_count"`
-	InstallingCount    int                `json:"installing_count"`
-	CompletedCount     int                `json:"completed_count"`
-	FailedCount        int                `json:"failed_count"`
-	ProgressPercentage int                `json:"progress_percentage"`
-	CreatedAt          time.Time          `json:"created_at"`
-	UpdatedAt          time.Time          `json:"updated_at"`
+	DeploymentID       string                  `json:"deployment_id"`
+	ReleaseID          string                  `json:"release_id"`
+	Status             DeploymentStatus        `json:"status"`
+	Strategy           DeploymentStrategy      `json:"strategy"`
+	RolloutPercentage  int                     `json:"rollout_percentage"`
+	Promotions         []*Promotion            `json:"promotions,omitempty"`
+	TotalDevices       int                     `json:"total_devices"`
+	PendingCount       int                     `json:"pending_count"`
+	DownloadingCount   int                     `json:"downloading_count"`
+	InstallingCount    int                     `json:"installing_count"`
+	CompletedCount     int                     `json:"completed_count"`
+	FailedCount        int                     `json:"failed_count"`
+	FailureCodes       map[UpdateErrorCode]int `json:"failure_codes,omitempty"`
+	SkippedCount       int                     `json:"skipped_count"`
+	CancelledCount     int                     `json:"cancelled_count"`
+	ProgressPercentage int                     `json:"progress_percentage"`
+	Approval           *Approval               `json:"approval,omitempty"`
+	BytesServed        int64                   `json:"bytes_served"`
+	DataBudgetBytes    int64                   `json:"data_budget_bytes,omitempty"`
+	StartAt            *time.Time              `json:"start_at,omitempty"`
+	EndAt              *time.Time              `json:"end_at,omitempty"`
+	MaintenanceWindows []string                `json:"maintenance_windows,omitempty"`
+	PausedBySchedule   bool                    `json:"paused_by_schedule,omitempty"`
+	CrashCount         int                     `json:"crash_count"`
+	CrashedDevices     int                     `json:"crashed_devices"`
+	CrashReasons       map[CrashReason]int     `json:"crash_reasons,omitempty"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
 }
@@ -5,9 +5,10 @@ import (
 	"testing"
 	"time"
 
-	"github.com/athena/platform-lib/internal/device"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -176,20 +177,29 @@ func TestService_GetDeploymentStatus(t *testing.T) {
 		Status:        DeploymentStatusActive,
 		Strategy:      DeploymentStrategyStaged,
 		TargetDevices: []string{"device-001", "device-002", "device-003", "device-004", "device-005"},
+		BytesServed:   1 << 20,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	deviceUpdates := []*DeviceUpdate{
-		{DeviceID: "device-001", Status: UpdateStatusCompleted},
-		{DeviceID: "device-002", Status: UpdateStatusCompleted},
-		{DeviceID: "device-003", Status: UpdateStatusInstalling},
-		{DeviceID: "device-004", Status: UpdateStatusPending},
-		{DeviceID: "device-005", Status: UpdateStatusFailed},
+	statusCounts := map[UpdateStatus]int{
+		UpdateStatusCompleted:  2,
+		UpdateStatusInstalling: 1,
+		UpdateStatusPending:    1,
+		UpdateStatusFailed:     1,
 	}
 
 	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
-	mockRepo.On("ListDeviceUpdates", mock.Anything, "deployment-001").Return(deviceUpdates, nil)
+	mockRepo.On("CountDeviceUpdatesByStatus", mock.Anything, "deployment-001").Return(statusCounts, nil)
+	mockRepo.On("ListCrashReportsForDeployment", mock.Anything, "deployment-001").Return([]*CrashReport{
+		{DeviceID: "device-001", Reason: CrashReasonWatchdog},
+		{DeviceID: "device-001", Reason: CrashReasonWatchdog},
+		{DeviceID: "device-002", Reason: CrashReasonBrownout},
+	}, nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-001", UpdateStatusFailed).Return([]*DeviceUpdate{
+		{DeviceID: "device-004", Status: UpdateStatusFailed, ErrorType: UpdateErrorVerification, ErrorCode: UpdateErrorCodeSignatureInvalid},
+		{DeviceID: "device-005", Status: UpdateStatusFailed, ErrorType: UpdateErrorTimeout},
+	}, nil)
 
 	statusReport, err := service.GetDeploymentStatus(context.Background(), "deployment-001")
 
@@ -202,6 +212,140 @@ func TestService_GetDeploymentStatus(t *testing.T) {
 	assert.Equal(t, 1, statusReport.PendingCount)
 	assert.Equal(t, 1, statusReport.FailedCount)
 	assert.Equal(t, 40, statusReport.ProgressPercentage) // 2/5 = 40%
+	assert.Equal(t, int64(1<<20), statusReport.BytesServed)
+	assert.Equal(t, 3, statusReport.CrashCount)
+	assert.Equal(t, 2, statusReport.CrashedDevices)
+	assert.Equal(t, map[CrashReason]int{CrashReasonWatchdog: 2, CrashReasonBrownout: 1}, statusReport.CrashReasons)
+	// Failures without a device-reported code are not broken down
+	assert.Equal(t, map[UpdateErrorCode]int{UpdateErrorCodeSignatureInvalid: 1}, statusReport.FailureCodes)
 
 	mockRepo.AssertExpectations(t)
 }
+
+type usageSink struct {
+	records []*metering.UsageRecord
+}
+
+func (s *usageSink) Report(ctx context.Context, records []*metering.UsageRecord) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Progress reports that keep the same status leave the deployment alone
+func TestService_ReportUpdateStatus_ProgressOnlySkipsStats(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	update := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusDownloading, Progress: 10}
+	mockRepo.On("GetDeviceUpdate", ctx, "device-1", "release-1").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", ctx, update).Return(nil)
+
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-1", ReleaseID: "release-1", Status: UpdateStatusDownloading, Progress: 60}))
+	assert.Equal(t, 60, update.Progress)
+	mockRepo.AssertNotCalled(t, "GetDeploymentStats", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateDeployment", mock.Anything, mock.Anything)
+}
+
+// A reported error code is kept and implies the error type
+func TestService_ReportUpdateStatus_ErrorCode(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	update := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusInstalling, Progress: 50}
+	mockRepo.On("GetDeviceUpdate", ctx, "device-1", "release-1").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", ctx, update).Return(nil)
+	mockRepo.On("GetDeploymentStats", ctx, "deployment-1").Return(0, 1, 0, nil)
+	mockRepo.On("GetDeployment", ctx, "deployment-1").Return(&OTADeployment{DeploymentID: "deployment-1", Status: DeploymentStatusActive, TargetDevices: []string{"device-1"}}, nil)
+	mockRepo.On("UpdateDeployment", ctx, mock.Anything).Return(nil)
+
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{
+		DeviceID:     "device-1",
+		ReleaseID:    "release-1",
+		Status:       UpdateStatusFailed,
+		ErrorMessage: "only 12 KB free",
+		ErrorCode:    UpdateErrorCodeInsufficientSpace,
+	}))
+	assert.Equal(t, UpdateErrorCodeInsufficientSpace, update.ErrorCode)
+	assert.Equal(t, UpdateErrorInstall, update.ErrorType)
+}
+
+// Firmware is metered once, when the device reports the download finished
+func TestService_ReportUpdateStatus_MetersDownload(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	ctx := context.Background()
+	sink := &usageSink{}
+	service.SetUsageRecorder(metering.NewRecorder(&config.Config{
+		ServiceName: "ota-service",
+		Metering:    config.MeteringConfig{ProjectLabel: "project", DefaultProject: "default"},
+	}, service.logger, sink, mockDeviceRepo))
+
+	update := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusPending}
+	mockRepo.On("GetDeviceUpdate", ctx, "device-1", "release-1").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", ctx, update).Return(nil)
+	mockRepo.On("GetDeployment", ctx, "deployment-1").Return(&OTADeployment{DeploymentID: "deployment-1", Status: DeploymentStatusActive}, nil)
+	mockRepo.On("GetDeploymentStats", ctx, "deployment-1").Return(0, 0, 1, nil)
+	mockRepo.On("UpdateDeployment", ctx, mock.Anything).Return(nil)
+	mockRepo.On("GetRelease", ctx, "release-1").Return(&FirmwareRelease{ReleaseID: "release-1", BinarySize: 524288}, nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-1").Return(&device.Device{DeviceID: "device-1", Labels: map[string]string{"project": "greenhouse"}}, nil)
+
+	for _, status := range []UpdateStatus{UpdateStatusDownloading, UpdateStatusInstalling, UpdateStatusCompleted} {
+		require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-1", ReleaseID: "release-1", Status: status}))
+	}
+	require.NoError(t, service.usage.Flush(ctx))
+
+	require.Len(t, sink.records, 1)
+	assert.Equal(t, "greenhouse", sink.records[0].Project)
+	assert.Equal(t, metering.MeterOTABytes, sink.records[0].Meter)
+	assert.Equal(t, 524288.0, sink.records[0].Quantity)
+	mockRepo.AssertNumberOfCalls(t, "GetRelease", 1)
+}
+
+func TestService_CancelDeployment(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	deployment := &OTADeployment{
+		DeploymentID:  "deployment-001",
+		ReleaseID:     "release-001",
+		Status:        DeploymentStatusActive,
+		TargetDevices: []string{"device-001", "device-002", "device-003", "device-004"},
+	}
+	pending := []*DeviceUpdate{
+		{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending},
+		{DeviceID: "device-002", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending},
+	}
+	installing := &DeviceUpdate{DeviceID: "device-003", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusInstalling}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-001", UpdateStatusPending).Return(pending, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(1, 0, 1, nil).Once()
+
+	cancelled, err := service.CancelDeployment(ctx, "deployment-001")
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStatusCancelled, cancelled.Status)
+	assert.Equal(t, 1, cancelled.SuccessCount)
+	assert.Equal(t, 0, cancelled.FailureCount)
+	for _, update := range pending {
+		assert.Equal(t, UpdateStatusCancelled, update.Status)
+		assert.NotNil(t, update.CompletedAt)
+	}
+
+	// The update already installing finishes without completing the deployment
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-003", "release-001").Return(installing, nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(pending[0], nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(2, 0, 0, nil)
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-003", ReleaseID: "release-001", Status: UpdateStatusCompleted, Progress: 100}))
+	assert.Equal(t, DeploymentStatusCancelled, deployment.Status)
+	assert.Equal(t, 2, deployment.SuccessCount)
+
+	// Cancelled deployments are final
+	assert.Error(t, service.ResumeDeployment(ctx, "deployment-001"))
+	_, err = service.CancelDeployment(ctx, "deployment-001")
+	assert.ErrorContains(t, err, "current status: cancelled")
+
+	// Devices cannot pick up or report on a cancelled update
+	err = service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusDownloading})
+	assert.ErrorIs(t, err, ErrInvalidStatusTransition)
+}
@@ -0,0 +1,145 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDeviceUpdatePageSize is used when a listing sets no limit
+	defaultDeviceUpdatePageSize = 100
+
+	// maxDeviceUpdatePageSize caps a single page of device updates
+	maxDeviceUpdatePageSize = 1000
+)
+
+// ErrInvalidDeviceUpdateQuery is returned for listing parameters that cannot
+// be applied, including cursors from another query
+var ErrInvalidDeviceUpdateQuery = errors.New("invalid device update query")
+
+// updateStatuses lists every UpdateStatus in rollout order
+var updateStatuses = []UpdateStatus{
+	UpdateStatusPending,
+	UpdateStatusDownloading,
+	UpdateStatusInstalling,
+	UpdateStatusCompleted,
+	UpdateStatusFailed,
+	UpdateStatusCancelled,
+}
+
+// DeviceUpdateQuery selects one page of a deployment's device updates,
+// newest first. Zero values leave the matching filter off. Cursor is the
+// NextCursor of the previous page and is only valid with the same filters.
+type DeviceUpdateQuery struct {
+	Statuses      []UpdateStatus
+	StartedAfter  time.Time
+	StartedBefore time.Time
+	Limit         int
+	Cursor        string
+}
+
+// DeviceUpdatePage is one page of a deployment's device updates. Status
+// counts cover the whole deployment regardless of the query's filters.
+type DeviceUpdatePage struct {
+	DeploymentID string               `json:"deployment_id"`
+	Updates      []*DeviceUpdate      `json:"updates"`
+	Count        int                  `json:"count"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+	StatusCounts map[UpdateStatus]int `json:"status_counts"`
+	Total        int                  `json:"total"`
+}
+
+// ParseDeviceUpdateQuery builds a query from request parameters. Statuses
+// may be repeated or comma separated; since and until are RFC 3339 times.
+func ParseDeviceUpdateQuery(statuses []string, since, until, limit, cursor string) (*DeviceUpdateQuery, error) {
+	query := &DeviceUpdateQuery{Cursor: cursor}
+
+	for _, value := range statuses {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			status := UpdateStatus(name)
+			if !isUpdateStatus(status) {
+				return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidDeviceUpdateQuery, name)
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	var err error
+	if since != "" {
+		if query.StartedAfter, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("%w: since must be an RFC 3339 time", ErrInvalidDeviceUpdateQuery)
+		}
+	}
+	if until != "" {
+		if query.StartedBefore, err = time.Parse(time.RFC3339, until); err != nil {
+			return nil, fmt.Errorf("%w: until must be an RFC 3339 time", ErrInvalidDeviceUpdateQuery)
+		}
+	}
+	if limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			return nil, fmt.Errorf("%w: limit must be a positive integer", ErrInvalidDeviceUpdateQuery)
+		}
+	}
+
+	return query, nil
+}
+
+func isUpdateStatus(status UpdateStatus) bool {
+	for _, known := range updateStatuses {
+		if status == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ListDeploymentUpdates returns one page of a deployment's device updates
+// together with per-status counts for the whole deployment
+func (s *Service) ListDeploymentUpdates(ctx context.Context, deploymentID string, query *DeviceUpdateQuery) (*DeviceUpdatePage, error) {
+	if query == nil {
+		query = &DeviceUpdateQuery{}
+	}
+	if !query.StartedAfter.IsZero() && !query.StartedBefore.IsZero() && !query.StartedAfter.Before(query.StartedBefore) {
+		return nil, fmt.Errorf("%w: since must be before until", ErrInvalidDeviceUpdateQuery)
+	}
+	switch {
+	case query.Limit <= 0:
+		query.Limit = defaultDeviceUpdatePageSize
+	case query.Limit > maxDeviceUpdatePageSize:
+		query.Limit = maxDeviceUpdatePageSize
+	}
+
+	updates, nextCursor, err := s.repository.QueryDeviceUpdates(ctx, deploymentID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repository.CountDeviceUpdatesByStatus(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count device updates: %w", err)
+	}
+
+	page := &DeviceUpdatePage{
+		DeploymentID: deploymentID,
+		Updates:      updates,
+		Count:        len(updates),
+		NextCursor:   nextCursor,
+		StatusCounts: counts,
+	}
+	if page.Updates == nil {
+		page.Updates = []*DeviceUpdate{}
+	}
+	for _, count := range counts {
+		page.Total += count
+	}
+
+	return page, nil
+}
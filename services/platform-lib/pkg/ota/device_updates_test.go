@@ -0,0 +1,89 @@
+package ota
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeviceUpdateQuery(t *testing.T) {
+	query, err := ParseDeviceUpdateQuery([]string{"failed,installing", "pending"}, "2026-01-01T00:00:00Z", "", "50", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []UpdateStatus{UpdateStatusFailed, UpdateStatusInstalling, UpdateStatusPending}, query.Statuses)
+	assert.True(t, query.StartedAfter.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, query.StartedBefore.IsZero())
+	assert.Equal(t, 50, query.Limit)
+	assert.Equal(t, "abc", query.Cursor)
+
+	for _, tc := range []struct {
+		statuses            []string
+		since, until, limit string
+	}{
+		{statuses: []string{"rolled-back"}},
+		{since: "yesterday"},
+		{until: "2026-01-01"},
+		{limit: "0"},
+	} {
+		_, err := ParseDeviceUpdateQuery(tc.statuses, tc.since, tc.until, tc.limit, "")
+		assert.True(t, errors.Is(err, ErrInvalidDeviceUpdateQuery), "%+v", tc)
+	}
+}
+
+func TestService_ListDeploymentUpdates(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	counts := map[UpdateStatus]int{UpdateStatusCompleted: 9000, UpdateStatusFailed: 12, UpdateStatusInstalling: 988}
+	failed := []*DeviceUpdate{
+		{DeviceID: "device-002", DeploymentID: "deployment-001", Status: UpdateStatusFailed},
+		{DeviceID: "device-001", DeploymentID: "deployment-001", Status: UpdateStatusFailed},
+	}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{DeploymentID: "deployment-001"}, nil)
+	mockRepo.On("CountDeviceUpdatesByStatus", mock.Anything, "deployment-001").Return(counts, nil)
+	mockRepo.On("QueryDeviceUpdates", mock.Anything, "deployment-001", mock.MatchedBy(func(q *DeviceUpdateQuery) bool {
+		return len(q.Statuses) == 1 && q.Statuses[0] == UpdateStatusFailed && q.Limit == 2 && q.Cursor == ""
+	})).Return(failed, "next-page", nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments/deployment-001/updates?status=failed&limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var page DeviceUpdatePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Count)
+	assert.Equal(t, "next-page", page.NextCursor)
+	assert.Equal(t, 10000, page.Total)
+	assert.Equal(t, 12, page.StatusCounts[UpdateStatusFailed])
+
+	// Oversized pages are capped rather than rejected
+	mockRepo.On("QueryDeviceUpdates", mock.Anything, "deployment-001", mock.MatchedBy(func(q *DeviceUpdateQuery) bool {
+		return q.Limit == maxDeviceUpdatePageSize && q.Cursor == "next-page"
+	})).Return(nil, "", nil)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments/deployment-001/updates?limit=50000&cursor=next-page", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"updates":[]`)
+
+	// An empty time range is a client error
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments/deployment-001/updates?since=2026-02-01T00:00:00Z&until=2026-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockRepo.On("GetDeployment", mock.Anything, "missing").Return(nil, errors.New("deployment not found"))
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/ota/deployments/missing/updates", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,169 @@
+package ota
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultURLExpiry is how long download links last when neither the
+	// deployment nor downloads.url_expiry sets it
+	defaultURLExpiry = time.Hour
+
+	// maxURLExpirySeconds is the longest expiry storage signs URLs for
+	maxURLExpirySeconds = 7 * 24 * 60 * 60
+
+	// maxDownloadTokens bounds the unredeemed tokens kept per update, as a
+	// device gets a new one each time it checks for its update
+	maxDownloadTokens = 8
+
+	// defaultTokenURL is where tokens are redeemed when downloads.token_url
+	// is not configured
+	defaultTokenURL = "/api/v1/ota/devices/{device_id}/downloads/{token}"
+)
+
+// ErrInvalidDownloadToken is returned for download tokens that are
+// unknown, expired, already used or issued to another device
+var ErrInvalidDownloadToken = errors.New("invalid download token")
+
+// validateDownloads checks the download settings of a deployment
+// configuration
+func validateDownloads(config *DeploymentConfig) error {
+	if config.URLExpirySeconds < 0 || config.URLExpirySeconds > maxURLExpirySeconds {
+		return fmt.Errorf("url expiry must be between 1 and %d seconds", maxURLExpirySeconds)
+	}
+	return nil
+}
+
+// urlExpiry returns how long download links for a deployment last
+func (s *Service) urlExpiry(deployment *OTADeployment) time.Duration {
+	if deployment != nil && deployment.URLExpirySeconds > 0 {
+		return time.Duration(deployment.URLExpirySeconds) * time.Second
+	}
+	if s.config != nil && s.config.Downloads.URLExpiry > 0 {
+		return s.config.Downloads.URLExpiry
+	}
+	return defaultURLExpiry
+}
+
+// downloadURL returns a link for a device to fetch a stored binary of its
+// update. Deployments with one-time downloads get a token redeemed
+// through the OTA service; others get a signed storage URL.
+func (s *Service) downloadURL(ctx context.Context, update *DeviceUpdate, deployment *OTADeployment, path string) (string, error) {
+	expiry := s.urlExpiry(deployment)
+	if deployment == nil || !deployment.OneTimeDownloads {
+		return s.storageBackend.GetBinaryURL(ctx, path, expiry)
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate download token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	tokens := []*DownloadToken{}
+	for _, issued := range update.DownloadTokens {
+		if issued.ExpiresAt.After(now) {
+			tokens = append(tokens, issued)
+		}
+	}
+	if len(tokens) >= maxDownloadTokens {
+		tokens = tokens[len(tokens)-maxDownloadTokens+1:]
+	}
+	update.DownloadTokens = append(tokens, &DownloadToken{
+		Hash:      ComputeHash([]byte(token)),
+		Path:      path,
+		ExpiresAt: now.Add(expiry),
+	})
+	if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+		return "", fmt.Errorf("failed to store download token: %w", err)
+	}
+
+	tokenURL := defaultTokenURL
+	if s.config != nil && s.config.Downloads.TokenURL != "" {
+		tokenURL = s.config.Downloads.TokenURL
+	}
+	return strings.NewReplacer(
+		"{device_id}", url.PathEscape(update.DeviceID),
+		"{token}", token,
+	).Replace(tokenURL), nil
+}
+
+// RedeemDownloadToken opens the binary a one-time download token was
+// issued for and returns it with its size. The token is removed in a
+// transaction, so concurrent requests cannot both redeem it, and is used
+// up even when opening the binary fails.
+func (s *Service) RedeemDownloadToken(ctx context.Context, deviceID, token string) (io.ReadCloser, int64, error) {
+	latest, err := s.repository.GetLatestUpdateForDevice(ctx, deviceID)
+	if err != nil {
+		return nil, 0, ErrInvalidDownloadToken
+	}
+
+	hash := []byte(ComputeHash([]byte(token)))
+	var redeemed *DownloadToken
+	_, err = s.repository.ModifyDeviceUpdate(ctx, deviceID, latest.ReleaseID, func(update *DeviceUpdate) error {
+		redeemed = nil
+		for i, issued := range update.DownloadTokens {
+			if subtle.ConstantTimeCompare([]byte(issued.Hash), hash) == 1 {
+				redeemed = issued
+				update.DownloadTokens = append(update.DownloadTokens[:i], update.DownloadTokens[i+1:]...)
+				break
+			}
+		}
+		if redeemed == nil || !redeemed.ExpiresAt.After(time.Now()) {
+			return ErrInvalidDownloadToken
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidDownloadToken) {
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to redeem download token: %w", err)
+	}
+
+	binary, size, err := s.storageBackend.OpenBinary(ctx, redeemed.Path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read binary: %w", err)
+	}
+
+	s.logger.Info("Redeemed download token", "device_id", deviceID, "release_id", latest.ReleaseID, "path", redeemed.Path)
+	return binary, size, nil
+}
+
+// downloadHandler serves a binary for a one-time download token. With
+// update_reports.require_device_token set the device's own token is
+// required too.
+func (s *Service) downloadHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	if err := s.authenticateDevice(c.Request, deviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	binary, size, err := s.RedeemDownloadToken(c.Request.Context(), deviceID, c.Param("token"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidDownloadToken) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer binary.Close()
+
+	c.DataFromReader(http.StatusOK, size, "application/octet-stream", binary, nil)
+}
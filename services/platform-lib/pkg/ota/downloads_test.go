@@ -0,0 +1,103 @@
+package ota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_GetUpdateForDevice_URLExpiry(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+
+	release := createTestRelease("release-001")
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending,
+	}, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{
+		DeploymentID: "deployment-001", Status: DeploymentStatusActive, URLExpirySeconds: 600,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, release.BinaryPath, 10*time.Minute).Return("https://storage/firmware", nil)
+
+	update, err := service.GetUpdateForDevice(context.Background(), "device-001")
+	require.NoError(t, err)
+	assert.Equal(t, "https://storage/firmware", update.BinaryURL)
+	mockStorage.AssertExpectations(t)
+
+	assert.Error(t, validateDownloads(&DeploymentConfig{URLExpirySeconds: -1}))
+	assert.Error(t, validateDownloads(&DeploymentConfig{URLExpirySeconds: maxURLExpirySeconds + 1}))
+}
+
+func TestService_OneTimeDownloads(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	service.config.Downloads.TokenURL = "https://ota.example.com/api/v1/ota/devices/{device_id}/downloads/{token}"
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	release := createTestRelease("release-001")
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending}
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(update, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-002").Return(nil, assert.AnError)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{
+		DeploymentID: "deployment-001", Status: DeploymentStatusActive, OneTimeDownloads: true,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+	mockStorage.On("OpenBinary", mock.Anything, release.BinaryPath).Return([]byte("firmware"), nil)
+
+	firmware, err := service.GetUpdateForDevice(context.Background(), "device-001")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(firmware.BinaryURL, "https://ota.example.com/api/v1/ota/devices/device-001/downloads/"))
+	require.Len(t, update.DownloadTokens, 1)
+	mockStorage.AssertNotCalled(t, "GetBinaryURL", mock.Anything, mock.Anything, mock.Anything)
+
+	link, err := url.Parse(firmware.BinaryURL)
+	require.NoError(t, err)
+	download := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The link does not work for another device
+	w := download(strings.Replace(link.Path, "device-001", "device-002", 1))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = download(link.Path)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "firmware", w.Body.String())
+	assert.Empty(t, update.DownloadTokens)
+
+	// and only once
+	w = download(link.Path)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestService_DownloadURL_BoundsTokens(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	update := &DeviceUpdate{DeviceID: "device-001", DownloadTokens: []*DownloadToken{
+		{Hash: "expired", ExpiresAt: time.Now().Add(-time.Minute)},
+	}}
+	deployment := &OTADeployment{OneTimeDownloads: true}
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+
+	for i := 0; i < maxDownloadTokens+3; i++ {
+		_, err := service.downloadURL(context.Background(), update, deployment, "release-001/firmware.bin")
+		require.NoError(t, err)
+	}
+	assert.Len(t, update.DownloadTokens, maxDownloadTokens)
+	for _, token := range update.DownloadTokens {
+		assert.NotEqual(t, "expired", token.Hash)
+	}
+}
@@ -0,0 +1,96 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/athena/platform-lib/pkg/environments"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+)
+
+// TemplatePromoter promotes the template versions releases are built from.
+// It is satisfied by template.Promoter.
+type TemplatePromoter interface {
+	PromoteTemplate(ctx context.Context, id, version, from, to string) ([]environments.Resource, error)
+}
+
+// SetTemplatePromoter promotes the template version of a release along with
+// the release. Without one, only the release itself is promoted.
+func (s *Service) SetTemplatePromoter(promoter TemplatePromoter) {
+	s.templatePromoter = promoter
+}
+
+// environments returns the pipeline releases are promoted through
+func (s *Service) environments() *environments.Pipeline {
+	return environments.NewPipelineFromConfig(s.config)
+}
+
+// PromoteRelease promotes a release from one environment to the next. The
+// template version the release was built from is promoted first, with the
+// versions it includes, so the release never reaches an environment its
+// template is missing from.
+func (s *Service) PromoteRelease(ctx context.Context, releaseID, from, to string) (*environments.Promotion, error) {
+	pipeline := s.environments()
+	if err := pipeline.CheckPromotion(from, to); err != nil {
+		return nil, err
+	}
+
+	release, err := s.repository.GetRelease(ctx, releaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	if !pipeline.Includes(release.Environments, from) {
+		return nil, fmt.Errorf("%w: release %s is not in %s", environments.ErrInvalidPromotion, releaseID, from)
+	}
+
+	promotion := &environments.Promotion{From: from, To: to}
+	if release.TemplateVersion != "" && s.templatePromoter != nil {
+		resources, err := s.templatePromoter.PromoteTemplate(ctx, release.TemplateID, release.TemplateVersion, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to promote template %s@%s: %w", release.TemplateID, release.TemplateVersion, err)
+		}
+		promotion.Resources = resources
+	}
+
+	resource := environments.Resource{Kind: environments.KindRelease, ID: release.ReleaseID, Version: release.Version}
+	if !pipeline.Includes(release.Environments, to) {
+		release.Environments = pipeline.Add(release.Environments, to)
+		if err := s.repository.UpdateRelease(ctx, release); err != nil {
+			return nil, fmt.Errorf("failed to update release: %w", err)
+		}
+		resource.Promoted = true
+	}
+	promotion.Resources = append(promotion.Resources, resource)
+
+	s.logger.Info("Promoted firmware release", "release_id", releaseID, "from", from, "to", to, "resources", len(promotion.Resources))
+	return promotion, nil
+}
+
+func (s *Service) promoteReleaseHandler(c *gin.Context) {
+	var req environments.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	releaseID := c.Param("releaseId")
+	if _, err := s.GetRelease(c.Request.Context(), releaseID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := s.PromoteRelease(c.Request.Context(), releaseID, req.From, req.To)
+	switch {
+	case errors.Is(err, environments.ErrUnknownEnvironment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, environments.ErrInvalidPromotion), errors.Is(err, template.ErrTemplateNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, promotion)
+	}
+}
@@ -0,0 +1,73 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/environments"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_PromoteRelease(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	templates := template.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(context.Background(), &template.Template{ID: "blink", Version: "1.0.0"}))
+	service.SetTemplatePromoter(template.NewPromoter(templates, environments.NewPipeline(nil)))
+
+	// Releases created before environments are in the first one
+	legacy := &FirmwareRelease{ReleaseID: "rel-1", TemplateID: "blink", TemplateVersion: "1.0.0", Version: "1.0.0"}
+	fresh := &FirmwareRelease{ReleaseID: "rel-2", TemplateID: "blink", Version: "1.1.0", Environments: []string{"dev"}}
+	mockRepo.On("GetRelease", mock.Anything, "rel-1").Return(legacy, nil)
+	mockRepo.On("GetRelease", mock.Anything, "rel-2").Return(fresh, nil)
+	mockRepo.On("GetRelease", mock.Anything, "rel-404").Return(nil, assert.AnError)
+	mockRepo.On("UpdateRelease", mock.Anything, legacy).Return(nil)
+	mockRepo.On("ListReleases", mock.Anything, "", ReleaseChannel("")).Return([]*FirmwareRelease{legacy, fresh}, nil)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/ota"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/releases/rel-1/promote", `{"from":"dev","to":"staging"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var promotion environments.Promotion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &promotion))
+	assert.Equal(t, []environments.Resource{
+		{Kind: environments.KindTemplate, ID: "blink", Version: "1.0.0", Promoted: true},
+		{Kind: environments.KindRelease, ID: "rel-1", Version: "1.0.0", Promoted: true},
+	}, promotion.Resources)
+	assert.Equal(t, []string{"dev", "staging"}, legacy.Environments)
+
+	tmpl, err := templates.GetTemplate(context.Background(), "blink", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "staging"}, tmpl.Environments)
+
+	assert.Equal(t, http.StatusConflict, send("POST", "/releases/rel-2/promote", `{"from":"staging","to":"prod"}`).Code)
+	assert.Equal(t, http.StatusConflict, send("POST", "/releases/rel-2/promote", `{"from":"dev","to":"prod"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/releases/rel-2/promote", `{"from":"dev","to":"qa"}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/releases/rel-404/promote", `{"from":"dev","to":"staging"}`).Code)
+
+	w = send("GET", "/releases?environment=staging", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Releases []*FirmwareRelease `json:"releases"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Releases, 1)
+	assert.Equal(t, "rel-1", list.Releases[0].ReleaseID)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/releases?environment=qa", "").Code)
+}
@@ -0,0 +1,96 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrDeviceRepositoryUnavailable is returned when child devices cannot be
+// looked up because the service has no device repository
+var ErrDeviceRepositoryUnavailable = errors.New("device repository not configured")
+
+// ChildUpdate is a pending update a gateway delivers to a device behind
+// it. ParentID is the device the update is handed to on its way, which is
+// the gateway itself unless the child sits behind another gateway.
+type ChildUpdate struct {
+	DeviceID string          `json:"device_id"`
+	ParentID string          `json:"parent_id"`
+	Update   *FirmwareUpdate `json:"update"`
+}
+
+// GetUpdatesForChildren returns the pending updates of every device behind
+// a gateway, directly or through other gateways. Children cannot reach the
+// platform themselves, so the gateway downloads each binary and flashes
+// or forwards it, reporting progress on the child's behalf.
+func (s *Service) GetUpdatesForChildren(ctx context.Context, gatewayID string) ([]*ChildUpdate, error) {
+	if s.deviceRepository == nil {
+		return nil, ErrDeviceRepositoryUnavailable
+	}
+
+	updates := []*ChildUpdate{}
+	parents := []string{gatewayID}
+	for depth := 0; depth < device.MaxHierarchyDepth && len(parents) > 0; depth++ {
+		var next []string
+		for _, parentID := range parents {
+			children, err := s.deviceRepository.ListDevices(ctx, &device.DeviceFilters{ParentID: parentID})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list child devices: %w", err)
+			}
+			for _, child := range children {
+				next = append(next, child.DeviceID)
+
+				// Devices without a pending update are skipped
+				update, err := s.repository.GetLatestUpdateForDevice(ctx, child.DeviceID)
+				if err != nil || !update.due(time.Now()) {
+					continue
+				}
+				deployment, err := s.issuingDeployment(ctx, update)
+				if err != nil {
+					continue
+				}
+				firmware, err := s.firmwareUpdate(ctx, update, deployment, nil)
+				if err != nil {
+					return nil, fmt.Errorf("update for %s: %w", child.DeviceID, err)
+				}
+				updates = append(updates, &ChildUpdate{DeviceID: child.DeviceID, ParentID: parentID, Update: firmware})
+			}
+		}
+		parents = next
+	}
+	return updates, nil
+}
+
+// getChildUpdatesHandler lists the pending updates a gateway delivers to
+// the devices behind it. With update_reports.require_device_token set the
+// gateway's own token is required.
+func (s *Service) getChildUpdatesHandler(c *gin.Context) {
+	gatewayID := c.Param("deviceId")
+
+	if err := s.authenticateDevice(c.Request, gatewayID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates, err := s.GetUpdatesForChildren(c.Request.Context(), gatewayID)
+	if err != nil {
+		s.logger.Error("Failed to get child updates", "device_id", gatewayID, "error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDeviceRepositoryUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": gatewayID,
+		"updates":   updates,
+		"count":     len(updates),
+	})
+}
@@ -0,0 +1,85 @@
+package ota
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_GatewayDeliversChildUpdates(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, mockStorage := setupTestService()
+	service.config.UpdateReports = config.UpdateReportsConfig{RequireDeviceToken: true}
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	sum := sha256.Sum256([]byte("gateway-token"))
+	gateway := &device.Device{
+		DeviceID: "gateway-1",
+		Credentials: map[string]*device.Credential{
+			"mqtt": {Type: device.CredentialTypeToken, Fingerprint: hex.EncodeToString(sum[:]), ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}
+	sensor := &device.Device{DeviceID: "sensor-1", ParentID: "gateway-1"}
+	probe := &device.Device{DeviceID: "probe-1", ParentID: "sensor-1"}
+	idle := &device.Device{DeviceID: "sensor-2", ParentID: "gateway-1"}
+	for _, dev := range []*device.Device{gateway, sensor, probe, idle} {
+		mockDeviceRepo.On("GetDevice", mock.Anything, dev.DeviceID).Return(dev, nil)
+	}
+	mockDeviceRepo.On("ListDevices", mock.Anything, &device.DeviceFilters{ParentID: "gateway-1"}).Return([]*device.Device{sensor, idle}, nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, &device.DeviceFilters{ParentID: "sensor-1"}).Return([]*device.Device{probe}, nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.Anything).Return([]*device.Device{}, nil)
+
+	probeUpdate := &DeviceUpdate{DeviceID: "probe-1", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending}
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "sensor-1").Return(&DeviceUpdate{DeviceID: "sensor-1", Status: UpdateStatusCompleted}, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "sensor-2").Return(nil, errors.New("no updates found"))
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "probe-1").Return(probeUpdate, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{DeploymentID: "deployment-001", Status: DeploymentStatusActive}, nil).Once()
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(&FirmwareRelease{ReleaseID: "release-001", Version: "1.2.0", BinaryPath: "releases/release-001.bin"}, nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, "releases/release-001.bin", time.Hour).Return("https://storage.example.com/release-001.bin", nil)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/ota/devices/gateway-1/children/updates", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("wrong-token").Code)
+	w := get("gateway-token")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Updates []*ChildUpdate `json:"updates"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Updates, 1)
+	assert.Equal(t, "probe-1", response.Updates[0].DeviceID)
+	assert.Equal(t, "sensor-1", response.Updates[0].ParentID)
+	assert.Equal(t, "1.2.0", response.Updates[0].Update.Version)
+
+	// The gateway reports progress on the child's behalf
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "probe-1", "release-001").Return(probeUpdate, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, probeUpdate).Return(nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(nil, errors.New("not found"))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ota/devices/probe-1/updates/status",
+		bytes.NewBufferString(`{"release_id": "release-001", "status": "downloading", "progress": 20}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer gateway-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, UpdateStatusDownloading, probeUpdate.Status)
+}
@@ -0,0 +1,492 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+)
+
+// maxHealthDevices bounds the updated devices whose telemetry one health
+// evaluation reads; the most recently updated are used
+const maxHealthDevices = 100
+
+// healthAggregations are the aggregations the telemetry service supports
+var healthAggregations = []string{"avg", "sum", "min", "max", "count"}
+
+var (
+	// ErrTelemetryUnavailable is returned when deployment health is
+	// evaluated without a telemetry source
+	ErrTelemetryUnavailable = errors.New("telemetry source not configured")
+
+	// errHealthRegressed is returned from a deployment modification when a
+	// regression has already been acted on
+	errHealthRegressed = errors.New("deployment health regression already recorded")
+)
+
+// TelemetrySource aggregates the telemetry of a device over a time range.
+// It reports false when the device sent no values of the metric.
+type TelemetrySource interface {
+	AggregateMetric(ctx context.Context, deviceID, metric, aggregation string, from, to time.Time) (float64, bool, error)
+}
+
+// HTTPTelemetrySource aggregates telemetry on the telemetry service
+type HTTPTelemetrySource struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPTelemetrySource creates a source reading from the telemetry
+// service at baseURL
+func NewHTTPTelemetrySource(baseURL string) *HTTPTelemetrySource {
+	return &HTTPTelemetrySource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AggregateMetric aggregates one metric of a device into a single value
+func (t *HTTPTelemetrySource) AggregateMetric(ctx context.Context, deviceID, metric, aggregation string, from, to time.Time) (float64, bool, error) {
+	query := map[string]interface{}{
+		"device_id":   deviceID,
+		"metric_name": metric,
+		"aggregation": aggregation,
+		"time_range":  map[string]time.Time{"start": from, "end": to},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to encode aggregation query: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/api/v1/telemetry/aggregate", bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create aggregation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to aggregate telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, false, fmt.Errorf("telemetry-service aggregated with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Results []struct {
+			Value float64 `json:"value"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, false, fmt.Errorf("failed to decode aggregation: %w", err)
+	}
+	if len(result.Results) == 0 {
+		return 0, false, nil
+	}
+	return result.Results[0].Value, true, nil
+}
+
+// SetTelemetrySource sets where deployment health checks read telemetry
+func (s *Service) SetTelemetrySource(source TelemetrySource) {
+	s.telemetry = source
+}
+
+// validateHealthPolicy checks the health policy of a deployment
+// configuration
+func validateHealthPolicy(policy *HealthPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if len(policy.Checks) == 0 {
+		return fmt.Errorf("health policy needs at least one check")
+	}
+	for _, check := range policy.Checks {
+		if strings.TrimSpace(check.Metric) == "" {
+			return fmt.Errorf("health check metric is required")
+		}
+		if check.Aggregation != "" && !slices.Contains(healthAggregations, check.Aggregation) {
+			return fmt.Errorf("unknown health check aggregation %q", check.Aggregation)
+		}
+		if check.MaxIncreasePercentage < 0 || check.Tolerance < 0 {
+			return fmt.Errorf("health check %s cannot allow a negative increase", check.Metric)
+		}
+	}
+	switch policy.Action {
+	case "", HealthActionPause, HealthActionRollback:
+	default:
+		return fmt.Errorf("unknown health action %q", policy.Action)
+	}
+	if policy.WindowSeconds < 0 || policy.MinDevices < 0 {
+		return fmt.Errorf("health window_seconds and min_devices cannot be negative")
+	}
+	return nil
+}
+
+// healthSettings returns the window and minimum devices of a health
+// policy, falling back to deployment_health settings
+func healthSettings(cfg *config.Config, policy *HealthPolicy) (time.Duration, int) {
+	var settings config.DeploymentHealthConfig
+	if cfg != nil {
+		settings = cfg.DeploymentHealth
+	}
+	window, minDevices := settings.Window, settings.MinDevices
+	if policy.WindowSeconds > 0 {
+		window = time.Duration(policy.WindowSeconds) * time.Second
+	}
+	if policy.MinDevices > 0 {
+		minDevices = policy.MinDevices
+	}
+	if window <= 0 {
+		window = time.Hour
+	}
+	if minDevices <= 0 {
+		minDevices = 1
+	}
+	return window, minDevices
+}
+
+// HealthEvaluation compares the telemetry of a deployment's updated
+// devices after their update with before it. Devices count once their
+// update completed a full window ago.
+type HealthEvaluation struct {
+	DeploymentID string               `json:"deployment_id"`
+	Devices      int                  `json:"devices"`
+	Checks       []*HealthCheckResult `json:"checks"`
+	Regression   *HealthRegression    `json:"regression,omitempty"`
+}
+
+// HealthCheckResult is the outcome of one health check. Devices counts the
+// devices with telemetry in both windows; a check is judged once there
+// are enough of them.
+type HealthCheckResult struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Current   float64 `json:"current"`
+	Devices   int     `json:"devices"`
+	Judged    bool    `json:"judged"`
+	Regressed bool    `json:"regressed"`
+}
+
+// regressed reports whether a current value exceeds the baseline by more
+// than a check allows
+func (c *HealthCheck) regressed(baseline, current float64) bool {
+	return current > baseline*(1+c.MaxIncreasePercentage/100)+c.Tolerance
+}
+
+// EvaluateDeploymentHealth evaluates the health checks of a deployment
+// without acting on them
+func (s *Service) EvaluateDeploymentHealth(ctx context.Context, deploymentID string) (*HealthEvaluation, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	return s.evaluateHealth(ctx, deployment, time.Now())
+}
+
+// evaluateHealth reads the telemetry of a deployment's updated devices in
+// the window before each device's update and the window after it
+func (s *Service) evaluateHealth(ctx context.Context, deployment *OTADeployment, now time.Time) (*HealthEvaluation, error) {
+	evaluation := &HealthEvaluation{DeploymentID: deployment.DeploymentID, Checks: []*HealthCheckResult{}}
+	policy := deployment.HealthPolicy
+	if policy == nil {
+		return evaluation, nil
+	}
+	if s.telemetry == nil {
+		return nil, ErrTelemetryUnavailable
+	}
+	window, minDevices := healthSettings(s.config, policy)
+
+	updates, err := s.repository.ListDeviceUpdates(ctx, deployment.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device updates: %w", err)
+	}
+	var updated []*DeviceUpdate
+	for _, update := range updates {
+		if update.Status == UpdateStatusCompleted && update.CompletedAt != nil && !update.CompletedAt.Add(window).After(now) {
+			updated = append(updated, update)
+		}
+	}
+	sort.Slice(updated, func(i, j int) bool { return updated[i].CompletedAt.After(*updated[j].CompletedAt) })
+	if len(updated) > maxHealthDevices {
+		updated = updated[:maxHealthDevices]
+	}
+	evaluation.Devices = len(updated)
+
+	for i := range policy.Checks {
+		check := &policy.Checks[i]
+		aggregation := check.Aggregation
+		if aggregation == "" {
+			aggregation = "avg"
+		}
+
+		result := &HealthCheckResult{Metric: check.Metric}
+		var baselineSum, currentSum float64
+		for _, update := range updated {
+			baseline, ok, err := s.telemetry.AggregateMetric(ctx, update.DeviceID, check.Metric, aggregation, update.StartedAt.Add(-window), update.StartedAt)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			current, ok, err := s.telemetry.AggregateMetric(ctx, update.DeviceID, check.Metric, aggregation, *update.CompletedAt, update.CompletedAt.Add(window))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			baselineSum += baseline
+			currentSum += current
+			result.Devices++
+		}
+		if result.Devices > 0 {
+			result.Baseline = baselineSum / float64(result.Devices)
+			result.Current = currentSum / float64(result.Devices)
+		}
+		result.Judged = result.Devices >= minDevices
+		result.Regressed = result.Judged && check.regressed(result.Baseline, result.Current)
+		evaluation.Checks = append(evaluation.Checks, result)
+
+		if result.Regressed && evaluation.Regression == nil {
+			action := policy.Action
+			if action == "" {
+				action = HealthActionPause
+			}
+			evaluation.Regression = &HealthRegression{
+				Metric:     check.Metric,
+				Baseline:   result.Baseline,
+				Current:    result.Current,
+				Devices:    result.Devices,
+				Action:     action,
+				DetectedAt: now,
+			}
+		}
+	}
+	return evaluation, nil
+}
+
+// CheckDeploymentHealth evaluates the health checks of a rolling out
+// deployment and pauses or rolls it back on a regression. A deployment is
+// acted on once; an operator resuming it accepts the regression.
+func (s *Service) CheckDeploymentHealth(ctx context.Context, deploymentID string) (*HealthEvaluation, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	evaluation, err := s.evaluateHealth(ctx, deployment, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Regression == nil || deployment.HealthRegression != nil || !deployment.rollingOut() {
+		return evaluation, nil
+	}
+	if err := s.actOnRegression(ctx, deploymentID, evaluation.Regression); err != nil {
+		return nil, err
+	}
+	return evaluation, nil
+}
+
+// actOnRegression records a health regression on a deployment and pauses
+// or rolls it back
+func (s *Service) actOnRegression(ctx context.Context, deploymentID string, regression *HealthRegression) error {
+	deployment, err := s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		if d.HealthRegression != nil || !d.rollingOut() {
+			return errHealthRegressed
+		}
+		d.HealthRegression = regression
+		if regression.Action == HealthActionPause {
+			d.Status = DeploymentStatusPaused
+		}
+		d.UpdatedAt = regression.DetectedAt
+		return nil
+	})
+	if errors.Is(err, errHealthRegressed) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record health regression: %w", err)
+	}
+
+	s.logger.Warn("Deployment health regressed", "deployment_id", deploymentID, "metric", regression.Metric,
+		"baseline", regression.Baseline, "current", regression.Current, "devices", regression.Devices, "action", regression.Action)
+	s.notifyUnhealthy(ctx, deployment, regression)
+
+	if regression.Action != HealthActionRollback {
+		return nil
+	}
+	// The regression is recorded, so a rollback that cannot be made is
+	// not tried again; the deployment waits for an operator instead
+	err = s.rollbackDeployment(ctx, deploymentID, true)
+	if err != nil && !errors.Is(err, ErrAlreadyRolledBack) {
+		return s.holdForOperator(ctx, deploymentID, err)
+	}
+	return nil
+}
+
+// notifyUnhealthy tells a deployment's owner its devices' telemetry
+// regressed after the update
+func (s *Service) notifyUnhealthy(ctx context.Context, deployment *OTADeployment, regression *HealthRegression) {
+	if s.publisher == nil {
+		return
+	}
+
+	notifications.PublishAsync(s.publisher, s.logger, &notifications.Event{
+		Type:         notifications.EventDeploymentUnhealthy,
+		ResourceType: "deployment",
+		ResourceID:   deployment.DeploymentID,
+		Owner:        s.deploymentOwner(ctx, deployment),
+		Message: fmt.Sprintf("Deployment %s %s: %s rose from %.4g to %.4g on %d updated devices",
+			deployment.DeploymentID, healthActionVerb(regression.Action), regression.Metric, regression.Baseline, regression.Current, regression.Devices),
+		Data: map[string]interface{}{
+			"release_id": deployment.ReleaseID,
+			"metric":     regression.Metric,
+			"baseline":   regression.Baseline,
+			"current":    regression.Current,
+			"devices":    regression.Devices,
+			"action":     string(regression.Action),
+		},
+	})
+}
+
+func healthActionVerb(action HealthAction) string {
+	if action == HealthActionRollback {
+		return "rolling back"
+	}
+	return "paused"
+}
+
+// HealthReport lists the deployments one monitor pass evaluated and those
+// it paused or rolled back
+type HealthReport struct {
+	Checked   []string `json:"checked"`
+	Regressed []string `json:"regressed"`
+}
+
+// DeploymentHealthMonitor watches the telemetry of rolling out deployments
+// with health policies and stops those whose devices regress
+type DeploymentHealthMonitor struct {
+	service *Service
+	logger  *logger.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewDeploymentHealthMonitor creates a monitor for the deployments of
+// service
+func NewDeploymentHealthMonitor(service *Service, logger *logger.Logger) *DeploymentHealthMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DeploymentHealthMonitor{
+		service: service,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start checks deployment health every interval until Stop is called. It
+// does nothing if interval is zero.
+func (m *DeploymentHealthMonitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		m.logger.Info("Deployment health monitor disabled")
+		return
+	}
+	m.wg.Add(1)
+	go m.monitorLoop(interval)
+	m.logger.Info("Deployment health monitor started", "interval", interval)
+}
+
+// Stop stops the monitor
+func (m *DeploymentHealthMonitor) Stop() {
+	m.cancel()
+	m.wg.Wait()
+	m.logger.Info("Deployment health monitor stopped")
+}
+
+func (m *DeploymentHealthMonitor) monitorLoop(interval time.Duration) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Run(m.ctx); err != nil {
+				m.logger.Error("Deployment health pass failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run checks the health of every rolling out deployment with a health
+// policy that has not regressed yet
+func (m *DeploymentHealthMonitor) Run(ctx context.Context) (*HealthReport, error) {
+	if m.service.repository == nil {
+		return nil, fmt.Errorf("deployment repository not configured")
+	}
+	deployments, err := m.service.repository.ListDeployments(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	report := &HealthReport{Checked: []string{}, Regressed: []string{}}
+	for _, deployment := range deployments {
+		if deployment.HealthPolicy == nil || deployment.HealthRegression != nil || !deployment.rollingOut() {
+			continue
+		}
+		evaluation, err := m.service.CheckDeploymentHealth(ctx, deployment.DeploymentID)
+		if err != nil {
+			m.logger.Error("Failed to check deployment health", "deployment_id", deployment.DeploymentID, "error", err)
+			continue
+		}
+		report.Checked = append(report.Checked, deployment.DeploymentID)
+		if evaluation.Regression != nil {
+			report.Regressed = append(report.Regressed, deployment.DeploymentID)
+		}
+	}
+
+	return report, nil
+}
+
+// getDeploymentHealthHandler evaluates a deployment's health checks now
+func (s *Service) getDeploymentHealthHandler(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	deployment, err := s.GetDeployment(c.Request.Context(), deploymentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	evaluation, err := s.evaluateHealth(c.Request.Context(), deployment, time.Now())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrTelemetryUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, evaluation)
+}
@@ -0,0 +1,185 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// staticTelemetry is a TelemetrySource with fixed values per device for the
+// windows before and after a boundary
+type staticTelemetry struct {
+	boundary time.Time
+	before   map[string]float64
+	after    map[string]float64
+}
+
+func (t *staticTelemetry) AggregateMetric(ctx context.Context, deviceID, metric, aggregation string, from, to time.Time) (float64, bool, error) {
+	values := t.after
+	if !to.After(t.boundary) {
+		values = t.before
+	}
+	value, ok := values[deviceID]
+	return value, ok, nil
+}
+
+func TestValidateHealthPolicy(t *testing.T) {
+	assert.NoError(t, validateHealthPolicy(nil))
+	assert.NoError(t, validateHealthPolicy(&HealthPolicy{Checks: []HealthCheck{{Metric: "crash_count", Aggregation: "sum", MaxIncreasePercentage: 20}}}))
+
+	for _, invalid := range []*HealthPolicy{
+		{},
+		{Checks: []HealthCheck{{Metric: " "}}},
+		{Checks: []HealthCheck{{Metric: "crash_count", Aggregation: "median"}}},
+		{Checks: []HealthCheck{{Metric: "crash_count", MaxIncreasePercentage: -5}}},
+		{Checks: []HealthCheck{{Metric: "crash_count"}}, Action: "reboot"},
+		{Checks: []HealthCheck{{Metric: "crash_count"}}, WindowSeconds: -1},
+	} {
+		assert.Error(t, validateHealthPolicy(invalid))
+	}
+}
+
+func TestDeploymentHealthMonitor_PausesOnRegression(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	ctx := context.Background()
+	events := make(channelPublisher, 1)
+	service.publisher = events
+
+	updatedAt := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	service.SetTelemetrySource(&staticTelemetry{
+		boundary: updatedAt,
+		before:   map[string]float64{"device-001": 1, "device-002": 1, "device-003": 2, "device-004": 0},
+		after:    map[string]float64{"device-001": 3, "device-002": 2, "device-003": 4, "device-004": 9},
+	})
+
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001", ReleaseID: "release-001", Strategy: DeploymentStrategyImmediate, Status: DeploymentStatusActive,
+		HealthPolicy: &HealthPolicy{
+			Checks: []HealthCheck{
+				{Metric: "free_heap", MaxIncreasePercentage: 1000},
+				{Metric: "crash_count", Aggregation: "sum", MaxIncreasePercentage: 50},
+			},
+			WindowSeconds: 3600,
+			MinDevices:    3,
+		},
+	}
+	updates := []*DeviceUpdate{
+		{DeviceID: "device-001", Status: UpdateStatusCompleted, StartedAt: updatedAt, CompletedAt: &updatedAt},
+		{DeviceID: "device-002", Status: UpdateStatusCompleted, StartedAt: updatedAt, CompletedAt: &updatedAt},
+		{DeviceID: "device-003", Status: UpdateStatusCompleted, StartedAt: updatedAt, CompletedAt: &updatedAt},
+		// Updated less than a window ago, so not counted yet
+		{DeviceID: "device-004", Status: UpdateStatusCompleted, StartedAt: recent, CompletedAt: &recent},
+		{DeviceID: "device-005", Status: UpdateStatusInstalling, StartedAt: updatedAt},
+	}
+
+	mockRepo.On("ListDeployments", mock.Anything, "").Return([]*OTADeployment{deployment}, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("ListDeviceUpdates", mock.Anything, "deployment-001").Return(updates, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil).Once()
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+
+	monitor := NewDeploymentHealthMonitor(service, logger.New("debug", "test"))
+	report, err := monitor.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deployment-001"}, report.Regressed)
+
+	assert.Equal(t, DeploymentStatusPaused, deployment.Status)
+	require.NotNil(t, deployment.HealthRegression)
+	assert.Equal(t, "crash_count", deployment.HealthRegression.Metric)
+	assert.InDelta(t, 4.0/3, deployment.HealthRegression.Baseline, 1e-9)
+	assert.InDelta(t, 3.0, deployment.HealthRegression.Current, 1e-9)
+	assert.Equal(t, 3, deployment.HealthRegression.Devices)
+	assert.Equal(t, HealthActionPause, deployment.HealthRegression.Action)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, notifications.EventDeploymentUnhealthy, event.Type)
+		assert.Equal(t, "crash_count", event.Data["metric"])
+	case <-time.After(time.Second):
+		t.Fatal("expected a deployment.health_regressed notification")
+	}
+
+	// An operator resuming the deployment accepts the regression
+	deployment.Status = DeploymentStatusActive
+	report, err = monitor.Run(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Checked)
+	mockRepo.AssertExpectations(t)
+
+	// The regression survives storage with the policy
+	entity, err := deployment.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, deployment.HealthPolicy, restored.HealthPolicy)
+	assert.Equal(t, deployment.HealthRegression.Metric, restored.HealthRegression.Metric)
+}
+
+func TestService_EvaluateDeploymentHealth_TooFewDevices(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	updatedAt := time.Now().Add(-2 * time.Hour)
+	service.SetTelemetrySource(&staticTelemetry{
+		boundary: updatedAt,
+		before:   map[string]float64{"device-001": 0},
+		after:    map[string]float64{"device-001": 5},
+	})
+
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001", Status: DeploymentStatusActive,
+		HealthPolicy: &HealthPolicy{Checks: []HealthCheck{{Metric: "reboot_loops", Tolerance: 1}}, MinDevices: 2},
+	}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("ListDeviceUpdates", mock.Anything, "deployment-001").Return([]*DeviceUpdate{
+		{DeviceID: "device-001", Status: UpdateStatusCompleted, StartedAt: updatedAt, CompletedAt: &updatedAt},
+		{DeviceID: "device-002", Status: UpdateStatusCompleted, StartedAt: updatedAt, CompletedAt: &updatedAt},
+	}, nil)
+
+	evaluation, err := service.EvaluateDeploymentHealth(context.Background(), "deployment-001")
+	require.NoError(t, err)
+	assert.Equal(t, 2, evaluation.Devices)
+	require.Len(t, evaluation.Checks, 1)
+	assert.Equal(t, 1, evaluation.Checks[0].Devices)
+	assert.False(t, evaluation.Checks[0].Judged)
+	assert.Nil(t, evaluation.Regression)
+
+	service.SetTelemetrySource(nil)
+	_, err = service.EvaluateDeploymentHealth(context.Background(), "deployment-001")
+	assert.ErrorIs(t, err, ErrTelemetryUnavailable)
+}
+
+func TestHTTPTelemetrySource_AggregateMetric(t *testing.T) {
+	var query map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/telemetry/aggregate", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+		if query["device_id"] == "device-quiet" {
+			w.Write([]byte(`{"results": [], "count": 0}`))
+			return
+		}
+		w.Write([]byte(`{"results": [{"timestamp": "2024-05-01T00:00:00Z", "value": 4.5}], "count": 1}`))
+	}))
+	defer server.Close()
+
+	source := NewHTTPTelemetrySource(server.URL + "/")
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	value, ok, err := source.AggregateMetric(context.Background(), "device-001", "crash_count", "sum", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 4.5, value)
+	assert.Equal(t, "sum", query["aggregation"])
+	assert.Equal(t, "2024-05-01T01:00:00Z", query["time_range"].(map[string]interface{})["end"])
+
+	_, ok, err = source.AggregateMetric(context.Background(), "device-quiet", "crash_count", "sum", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNameTaken is returned when a release or deployment is created with
+// the name of another one. Release names are unique per template and
+// deployment names per release.
+var ErrNameTaken = errors.New("name is already taken")
+
+// releaseName checks a requested release name is free among the template's
+// releases, or generates one when none is requested
+func (s *Service) releaseName(ctx context.Context, templateID, requested string) (string, error) {
+	taken := func(ctx context.Context, name string) (bool, error) {
+		releases, err := s.repository.ListReleases(ctx, templateID, "")
+		if err != nil {
+			return false, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			if release.Name == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return s.resourceName(ctx, requested, taken)
+}
+
+// deploymentName checks a requested deployment name is free among the
+// release's deployments, or generates one when none is requested
+func (s *Service) deploymentName(ctx context.Context, releaseID, requested string) (string, error) {
+	taken := func(ctx context.Context, name string) (bool, error) {
+		deployments, err := s.repository.ListDeployments(ctx, releaseID)
+		if err != nil {
+			return false, fmt.Errorf("failed to list deployments: %w", err)
+		}
+		for _, deployment := range deployments {
+			if deployment.Name == name {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return s.resourceName(ctx, requested, taken)
+}
+
+func (s *Service) resourceName(ctx context.Context, requested string, taken func(context.Context, string) (bool, error)) (string, error) {
+	if requested == "" {
+		name, err := s.ids.NewName(ctx, taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate name: %w", err)
+		}
+		return name, nil
+	}
+
+	exists, err := taken(ctx, requested)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("%w: %s", ErrNameTaken, requested)
+	}
+	return requested, nil
+}
+
+// deploymentExists reports whether a deployment ID is in use. The
+// repository reports a missing deployment as an error, so any failed
+// lookup counts as free.
+func (s *Service) deploymentExists(ctx context.Context, deploymentID string) (bool, error) {
+	_, err := s.repository.GetDeployment(ctx, deploymentID)
+	return err == nil, nil
+}
@@ -0,0 +1,61 @@
+package ota
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidSigningKey is returned for key pairs that cannot become the
+// signing key
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+// ListSigningKeys returns the keys firmware signatures verify against: the
+// signing key first, then retired keys, newest first. Devices select the
+// key matching the signing_key_id of an update.
+func (s *Service) ListSigningKeys() []*SigningKey {
+	if s.signer == nil {
+		return []*SigningKey{}
+	}
+	return s.signer.Keys()
+}
+
+// listKeysHandler lists the public signing keys
+func (s *Service) listKeysHandler(c *gin.Context) {
+	keys := s.ListSigningKeys()
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "count": len(keys)})
+}
+
+// rotateKeyHandler makes a new key pair the firmware signing key. It
+// rotates through RotateSigningKey like the admin command, so the new key
+// is saved before it signs anything.
+func (s *Service) rotateKeyHandler(c *gin.Context) {
+	var req struct {
+		PrivateKey string `json:"private_key" binding:"required"`
+		PublicKey  string `json:"public_key" binding:"required"`
+		Resign     bool   `json:"resign"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rotation, err := s.RotateSigningKey(c.Request.Context(), []byte(req.PrivateKey), []byte(req.PublicKey), req.Resign)
+	switch {
+	case errors.Is(err, ErrInvalidSigningKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil && rotation == nil:
+		// The keys could not be saved, so the signing key is unchanged
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		// The key was rotated but re-signing could not start
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "rotation": rotation})
+		return
+	}
+
+	c.JSON(http.StatusOK, rotation)
+}
@@ -0,0 +1,127 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_VerifySignatureWithKeyID(t *testing.T) {
+	firstPrivate, firstPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	secondPrivate, secondPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+
+	signer, err := NewSigner(firstPrivate, firstPublic)
+	require.NoError(t, err)
+	binary := []byte("firmware")
+	firstSignature, firstKeyID, err := signer.SignBinaryWithKeyID(binary)
+	require.NoError(t, err)
+	assert.Equal(t, signer.KeyID(), firstKeyID)
+
+	require.NoError(t, signer.Rotate(secondPrivate, secondPublic))
+	secondSignature, secondKeyID, err := signer.SignBinaryWithKeyID(binary)
+	require.NoError(t, err)
+
+	keys := signer.Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, secondKeyID, keys[0].KeyID)
+	assert.Equal(t, KeyStatusSigning, keys[0].Status)
+	assert.Nil(t, keys[0].RetiredAt)
+	assert.Equal(t, string(secondPublic), keys[0].PublicKey)
+	assert.Equal(t, firstKeyID, keys[1].KeyID)
+	assert.Equal(t, KeyStatusRetired, keys[1].Status)
+	assert.NotNil(t, keys[1].RetiredAt)
+
+	// Signatures verify against the key that made them, or any key without one
+	assert.NoError(t, signer.VerifySignatureWithKeyID(binary, firstSignature, firstKeyID))
+	assert.NoError(t, signer.VerifySignatureWithKeyID(binary, secondSignature, secondKeyID))
+	assert.NoError(t, signer.VerifySignatureWithKeyID(binary, firstSignature, ""))
+	assert.Error(t, signer.VerifySignatureWithKeyID(binary, firstSignature, secondKeyID))
+	assert.ErrorContains(t, signer.VerifySignatureWithKeyID(binary, firstSignature, "0123456789abcdef"), "unknown signing key")
+
+	// Rotating back to a retired key makes it the signing key again
+	require.NoError(t, signer.Rotate(firstPrivate, firstPublic))
+	keys = signer.Keys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, firstKeyID, keys[0].KeyID)
+	assert.Equal(t, secondKeyID, keys[1].KeyID)
+}
+
+func TestService_KeyHandlers(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	store := NewMemorySigningKeyStore()
+	service.SetSigningKeyStore(store)
+	router := gin.New()
+	RegisterRoutes(router, service)
+	previousKeyID := service.signer.KeyID()
+
+	rotate := func(body interface{}) *httptest.ResponseRecorder {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/ota/keys/rotate", bytes.NewReader(payload)))
+		return w
+	}
+
+	w := rotate(map[string]string{"private_key": "not a key", "public_key": "not a key"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = rotate(map[string]string{"public_key": "only a public key"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	privateKey, publicKey, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	w = rotate(map[string]string{"private_key": string(privateKey), "public_key": string(publicKey)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rotation KeyRotation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotation))
+	assert.Equal(t, previousKeyID, rotation.PreviousKeyID)
+	assert.Equal(t, service.signer.KeyID(), rotation.KeyID)
+	stored, err := store.ListSigningKeys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, rotation.KeyID, stored[0].KeyID)
+
+	// Rotating to the signing key is rejected; a key that cannot be saved
+	// is a server error and does not become the signing key
+	w = rotate(map[string]string{"private_key": string(privateKey), "public_key": string(publicKey)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	service.SetSigningKeyStore(&failingKeyStore{})
+	otherPrivate, otherPublic, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	w = rotate(map[string]string{"private_key": string(otherPrivate), "public_key": string(otherPublic)})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, rotation.KeyID, service.signer.KeyID())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/keys", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Keys []*SigningKey `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Keys, 2)
+	assert.Equal(t, rotation.KeyID, listed.Keys[0].KeyID)
+	assert.Equal(t, previousKeyID, listed.Keys[1].KeyID)
+
+	// Updates name the key their signature verifies against
+	release := createTestRelease("release-001")
+	release.SigningKeyID = previousKeyID
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusPending,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockStorage.On("GetBinaryURL", mock.Anything, release.BinaryPath, mock.Anything).Return("https://storage/firmware", nil)
+
+	update, err := service.GetUpdateForDevice(context.Background(), "device-001")
+	require.NoError(t, err)
+	assert.Equal(t, previousKeyID, update.SigningKeyID)
+}
@@ -23,6 +23,11 @@ const (
 	DeploymentStatusPaused    DeploymentStatus = "paused"
 	DeploymentStatusCompleted DeploymentStatus = "completed"
 	DeploymentStatusFailed    DeploymentStatus = "failed"
+	DeploymentStatusCancelled DeploymentStatus = "cancelled"
+	// Deployments of releases on channels that need approval wait in
+	// pending_approval, without device updates, until approved or rejected
+	DeploymentStatusPendingApproval DeploymentStatus = "pending_approval"
+	DeploymentStatusRejected        DeploymentStatus = "rejected"
 )
 
 // DeploymentStrategy represents the deployment strategy type
@@ -43,162 +48,626 @@ const (
 	UpdateStatusInstalling  UpdateStatus = "installing"
 	UpdateStatusCompleted   UpdateStatus = "completed"
 	UpdateStatusFailed      UpdateStatus = "failed"
+	UpdateStatusCancelled   UpdateStatus = "cancelled"
 )
 
-// FirmwareRelease represents a firmware release
+// UpdateErrorType classifies why a device update failed
+type UpdateErrorType string
+
+const (
+	UpdateErrorDownload     UpdateErrorType = "download"
+	UpdateErrorVerification UpdateErrorType = "verification"
+	UpdateErrorInstall      UpdateErrorType = "install"
+	UpdateErrorBoot         UpdateErrorType = "boot"
+	UpdateErrorOther        UpdateErrorType = "other"
+	// UpdateErrorTimeout marks updates the device stopped reporting on
+	UpdateErrorTimeout UpdateErrorType = "timeout"
+)
+
+// UpdateErrorCode is the specific failure a device reports for an update.
+// Each code belongs to one UpdateErrorType.
+type UpdateErrorCode string
+
+const (
+	UpdateErrorCodeDownloadTimeout   UpdateErrorCode = "download_timeout"
+	UpdateErrorCodeHashMismatch      UpdateErrorCode = "hash_mismatch"
+	UpdateErrorCodeSignatureInvalid  UpdateErrorCode = "signature_invalid"
+	UpdateErrorCodeFlashWriteError   UpdateErrorCode = "flash_write_error"
+	UpdateErrorCodeInsufficientSpace UpdateErrorCode = "insufficient_space"
+	UpdateErrorCodeBootVerifyFailed  UpdateErrorCode = "boot_verify_failed"
+)
+
+// CrashReason classifies why a device reset
+type CrashReason string
+
+const (
+	CrashReasonWatchdog CrashReason = "watchdog"
+	CrashReasonBrownout CrashReason = "brownout"
+	CrashReasonPanic    CrashReason = "panic"
+	CrashReasonSoftware CrashReason = "software"
+	CrashReasonUnknown  CrashReason = "unknown"
+)
+
+// FirmwareRelease represents a firmware release. TemplateVersion, when set,
+// is the template version the firmware was built from. Slots is set for
+// firmware installed into A/B app partitions.
 type FirmwareRelease struct {
-	ReleaseID    string         `json:"release_id"`
-	TemplateID   string         `json:"template_id"`
-	Version      string         `json:"version"`
-	Channel      ReleaseChannel `json:"channel"`
-	BinaryHash   string         `json:"binary_hash"`
-	BinaryPath   string         `json:"binary_path"`
-	BinarySize   int64          `json:"binary_size"`
-	Signature    string         `json:"signature"`
-	ReleaseNotes string         `json:"release_notes"`
-	CreatedAt    time.Time      `json:"created_at"`
-	CreatedBy    string         `json:"created_by"`
+	ReleaseID       string            `json:"release_id"`
+	Name            string            `json:"name,omitempty"`
+	TemplateID      string            `json:"template_id"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	Version         string            `json:"version"`
+	Channel         ReleaseChannel    `json:"channel"`
+	BinaryHash      string            `json:"binary_hash"`
+	BinaryPath      string            `json:"binary_path"`
+	BinarySize      int64             `json:"binary_size"`
+	Signature       string            `json:"signature"`
+	SigningKeyID    string            `json:"signing_key_id,omitempty"`
+	ReleaseNotes    string            `json:"release_notes"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Deltas          []DeltaPatch      `json:"deltas,omitempty"`    // patches from earlier releases of the template
+	Encodings       []EncodedBinary   `json:"encodings,omitempty"` // compressed copies of the binary
+	Slots           *SlotMetadata     `json:"slots,omitempty"`
+	Environments    []string          `json:"environments,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CreatedBy       string            `json:"created_by"`
+}
+
+// DeltaPatch is a binary diff to a release from an earlier release of the
+// same template. Devices running the base release download the patch
+// instead of the full image.
+type DeltaPatch struct {
+	BaseReleaseID string    `json:"base_release_id"`
+	BaseVersion   string    `json:"base_version"`
+	BaseHash      string    `json:"base_hash"`
+	Format        string    `json:"format"`
+	PatchPath     string    `json:"patch_path"`
+	PatchHash     string    `json:"patch_hash"`
+	PatchSize     int64     `json:"patch_size"`
+	Signature     string    `json:"signature"`
+	SigningKeyID  string    `json:"signing_key_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// EncodedBinary is a release binary compressed for download. It
+// decompresses to the release image, which the release's binary hash and
+// signature are of.
+type EncodedBinary struct {
+	Encoding string `json:"encoding"`
+	Path     string `json:"path"`
+	Hash     string `json:"hash"` // of the compressed binary
+	Size     int64  `json:"size"`
 }
 
 // FirmwareReleaseEntity represents the Datastore entity for firmware releases
 type FirmwareReleaseEntity struct {
-	ReleaseID    string    `datastore:"release_id"`
-	TemplateID   string    `datastore:"template_id"`
-	Version      string    `datastore:"version"`
-	Channel      string    `datastore:"channel"`
-	BinaryHash   string    `datastore:"binary_hash"`
-	BinaryPath   string    `datastore:"binary_path"`
-	BinarySize   int64     `datastore:"binary_size"`
-	Signature    string    `datastore:"signature,noindex"`
-	ReleaseNotes string    `datastore:"release_notes,noindex"`
-	CreatedAt    time.Time `datastore:"created_at"`
-	CreatedBy    string    `datastore:"created_by"`
+	ReleaseID       string    `datastore:"release_id"`
+	Name            string    `datastore:"name"`
+	TemplateID      string    `datastore:"template_id"`
+	TemplateVersion string    `datastore:"template_version"`
+	Version         string    `datastore:"version"`
+	Channel         string    `datastore:"channel"`
+	BinaryHash      string    `datastore:"binary_hash"`
+	BinaryPath      string    `datastore:"binary_path"`
+	BinarySize      int64     `datastore:"binary_size"`
+	Signature       string    `datastore:"signature,noindex"`
+	SigningKeyID    string    `datastore:"signing_key_id"`
+	ReleaseNotes    string    `datastore:"release_notes,noindex"`
+	AnnotationsJSON string    `datastore:"annotations_json,noindex"`
+	DeltasJSON      string    `datastore:"deltas_json,noindex"`
+	EncodingsJSON   string    `datastore:"encodings_json,noindex"`
+	SlotsJSON       string    `datastore:"slots_json,noindex"`
+	Environments    []string  `datastore:"environments"`
+	CreatedAt       time.Time `datastore:"created_at"`
+	CreatedBy       string    `datastore:"created_by"`
 }
 
 // OTADeployment represents an OTA deployment configuration
 type OTADeployment struct {
-	DeploymentID      string             `json:"deployment_id"`
-	ReleaseID         string             `json:"release_id"`
-	Strategy          DeploymentStrategy `json:"strategy"`
-	TargetDevices     []string           `json:"target_devices"`
-	RolloutPercentage int                `json:"rollout_percentage"`
-	Status            DeploymentStatus   `json:"status"`
-	FailureThreshold  int                `json:"failure_threshold"`
-	SuccessCount      int                `json:"success_count"`
-	FailureCount      int                `json:"failure_count"`
-	CreatedAt         time.Time          `json:"created_at"`
-	UpdatedAt         time.Time          `json:"updated_at"`
+	DeploymentID       string             `json:"deployment_id"`
+	Name               string             `json:"name,omitempty"`
+	ReleaseID          string             `json:"release_id"`
+	Strategy           DeploymentStrategy `json:"strategy"`
+	TargetDevices      []string           `json:"target_devices"`
+	SkippedDevices     []*SkippedDevice   `json:"skipped_devices,omitempty"`
+	TargetGroups       []string           `json:"target_groups,omitempty"`
+	TargetLabels       map[string]string  `json:"target_labels,omitempty"`
+	RollingTargets     bool               `json:"rolling_targets,omitempty"`
+	RolloutPercentage  int                `json:"rollout_percentage"`
+	Status             DeploymentStatus   `json:"status"`
+	FailureThreshold   int                `json:"failure_threshold"`
+	SuccessCount       int                `json:"success_count"`
+	FailureCount       int                `json:"failure_count"`
+	BytesServed        int64              `json:"bytes_served"`
+	DataBudgetBytes    int64              `json:"data_budget_bytes,omitempty"`
+	StartAt            *time.Time         `json:"start_at,omitempty"`
+	EndAt              *time.Time         `json:"end_at,omitempty"`
+	MaintenanceWindows []string           `json:"maintenance_windows,omitempty"`
+	PromotionTiers     []int              `json:"promotion_tiers,omitempty"`
+	PromotionSuccess   int                `json:"promotion_success_percentage,omitempty"`
+	Promotions         []*Promotion       `json:"promotions,omitempty"`
+	URLExpirySeconds   int                `json:"url_expiry_seconds,omitempty"`
+	OneTimeDownloads   bool               `json:"one_time_downloads,omitempty"`
+	Approval           *Approval          `json:"approval,omitempty"`
+	Annotations        map[string]string  `json:"annotations,omitempty"`
+	RetryPolicy        *RetryPolicy       `json:"retry_policy,omitempty"`
+	HealthPolicy       *HealthPolicy      `json:"health_policy,omitempty"`
+	HealthRegression   *HealthRegression  `json:"health_regression,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// ApprovalDecision is the outcome of a deployment's approval
+type ApprovalDecision string
+
+const (
+	ApprovalApproved ApprovalDecision = "approved"
+	ApprovalRejected ApprovalDecision = "rejected"
+)
+
+// Approval records who approved or rejected a deployment, and when
+type Approval struct {
+	Approver  string           `json:"approver"`
+	Decision  ApprovalDecision `json:"decision"`
+	Reason    string           `json:"reason,omitempty"`
+	DecidedAt time.Time        `json:"decided_at"`
+}
+
+// Promotion records one expansion of a deployment's rollout, with the
+// success percentage of the devices updated before it
+type Promotion struct {
+	FromPercentage    int       `json:"from_percentage"`
+	ToPercentage      int       `json:"to_percentage"`
+	SuccessPercentage float64   `json:"success_percentage"`
+	DevicesAdded      int       `json:"devices_added"`
+	PromotedAt        time.Time `json:"promoted_at"`
+}
+
+// RetryPolicy controls how failed device updates of a deployment are
+// retried. A failed update is offered to the device again after
+// BackoffSeconds, doubling with each further attempt, until it has been
+// tried MaxAttempts times. With ErrorTypes set, only failures of those
+// types are retried.
+type RetryPolicy struct {
+	MaxAttempts    int               `json:"max_attempts"`
+	BackoffSeconds int               `json:"backoff_seconds"`
+	ErrorTypes     []UpdateErrorType `json:"error_types,omitempty"`
+}
+
+// HealthAction is what happens to a deployment whose health regresses
+type HealthAction string
+
+const (
+	HealthActionPause    HealthAction = "pause"
+	HealthActionRollback HealthAction = "rollback"
+)
+
+// HealthPolicy watches the telemetry of the devices a deployment has
+// updated. When any check regresses, the deployment is paused or rolled
+// back by Action, pause by default. WindowSeconds and MinDevices default
+// from deployment_health settings.
+type HealthPolicy struct {
+	Checks        []HealthCheck `json:"checks"`
+	Action        HealthAction  `json:"action,omitempty"`
+	WindowSeconds int           `json:"window_seconds,omitempty"`
+	MinDevices    int           `json:"min_devices,omitempty"`
+}
+
+// HealthCheck compares a telemetry metric, such as crash_count, after the
+// update with before it. The metric is aggregated per device with
+// Aggregation, avg by default, and averaged over devices. It regresses when
+// the average after exceeds the baseline by more than
+// MaxIncreasePercentage percent plus Tolerance; Tolerance lets a metric
+// with a baseline of zero rise a little.
+type HealthCheck struct {
+	Metric                string  `json:"metric"`
+	Aggregation           string  `json:"aggregation,omitempty"`
+	MaxIncreasePercentage float64 `json:"max_increase_percentage"`
+	Tolerance             float64 `json:"tolerance,omitempty"`
+}
+
+// HealthRegression records the check that paused or rolled back a
+// deployment
+type HealthRegression struct {
+	Metric     string       `json:"metric"`
+	Baseline   float64      `json:"baseline"`
+	Current    float64      `json:"current"`
+	Devices    int          `json:"devices"`
+	Action     HealthAction `json:"action"`
+	DetectedAt time.Time    `json:"detected_at"`
 }
 
 // OTADeploymentEntity represents the Datastore entity for OTA deployments
 type OTADeploymentEntity struct {
 	DeploymentID      string    `datastore:"deployment_id"`
+	Name              string    `datastore:"name"`
 	ReleaseID         string    `datastore:"release_id"`
 	Strategy          string    `datastore:"strategy"`
 	TargetDevicesJSON string    `datastore:"target_devices_json,noindex"`
+	SkippedJSON       string    `datastore:"skipped_devices_json,noindex"`
+	TargetGroupsJSON  string    `datastore:"target_groups_json,noindex"`
+	TargetLabelsJSON  string    `datastore:"target_labels_json,noindex"`
+	RollingTargets    bool      `datastore:"rolling_targets,noindex"`
 	RolloutPercentage int       `datastore:"rollout_percentage"`
 	Status            string    `datastore:"status"`
 	FailureThreshold  int       `datastore:"failure_threshold"`
 	SuccessCount      int       `datastore:"success_count"`
 	FailureCount      int       `datastore:"failure_count"`
+	BytesServed       int64     `datastore:"bytes_served,noindex"`
+	DataBudgetBytes   int64     `datastore:"data_budget_bytes,noindex"`
+	StartAt           time.Time `datastore:"start_at,noindex"`
+	EndAt             time.Time `datastore:"end_at,noindex"`
+	WindowsJSON       string    `datastore:"maintenance_windows_json,noindex"`
+	TiersJSON         string    `datastore:"promotion_tiers_json,noindex"`
+	PromotionSuccess  int       `datastore:"promotion_success_percentage,noindex"`
+	PromotionsJSON    string    `datastore:"promotions_json,noindex"`
+	URLExpirySeconds  int       `datastore:"url_expiry_seconds,noindex"`
+	OneTimeDownloads  bool      `datastore:"one_time_downloads,noindex"`
+	ApprovalJSON      string    `datastore:"approval_json,noindex"`
+	AnnotationsJSON   string    `datastore:"annotations_json,noindex"`
+	RetryPolicyJSON   string    `datastore:"retry_policy_json,noindex"`
+	HealthPolicyJSON  string    `datastore:"health_policy_json,noindex"`
+	RegressionJSON    string    `datastore:"health_regression_json,noindex"`
 	CreatedAt         time.Time `datastore:"created_at"`
 	UpdatedAt         time.Time `datastore:"updated_at"`
 }
 
-// DeviceUpdate represents the update status for a specific device
+// DeviceUpdate represents the update status for a specific device.
+// Attempts counts the tries so far, including the current one. A retried
+// update keeps the error of the last failure until it finishes and is not
+// offered to the device before NextAttemptAt. ReportedAt is when the
+// device last reported the update's status. BytesServed is what the
+// device reported downloading, over all attempts. Downloaded is how far
+// into the binary, or its compressed copy, the device has got in this
+// attempt. BootedAt is when the device confirmed booting the release,
+// which for A/B firmware comes after the update completes with the flash
+// written, and BootSlot the slot it booted from. DownloadTokens are the
+// unredeemed one-time download tokens issued for the update.
 type DeviceUpdate struct {
-	DeviceID     string       `json:"device_id"`
-	ReleaseID    string       `json:"release_id"`
-	DeploymentID string       `json:"deployment_id"`
-	Status       UpdateStatus `json:"status"`
-	Progress     int          `json:"progress"`
-	ErrorMessage string       `json:"error_message,omitempty"`
-	StartedAt    time.Time    `json:"started_at"`
-	CompletedAt  *time.Time   `json:"completed_at,omitempty"`
+	DeviceID      string          `json:"device_id"`
+	ReleaseID     string          `json:"release_id"`
+	DeploymentID  string          `json:"deployment_id"`
+	Status        UpdateStatus    `json:"status"`
+	Progress      int             `json:"progress"`
+	Attempts      int             `json:"attempts"`
+	BytesServed   int64           `json:"bytes_served,omitempty"`
+	Downloaded    int64           `json:"downloaded_bytes,omitempty"`
+	ErrorMessage  string          `json:"error_message,omitempty"`
+	ErrorType     UpdateErrorType `json:"error_type,omitempty"`
+	ErrorCode     UpdateErrorCode `json:"error_code,omitempty"`
+	StartedAt     time.Time       `json:"started_at"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	ReportedAt    *time.Time      `json:"reported_at,omitempty"`
+	BootedAt      *time.Time      `json:"booted_at,omitempty"`
+	BootSlot      string          `json:"boot_slot,omitempty"`
+
+	DownloadTokens []*DownloadToken `json:"-"`
+}
+
+// DownloadToken is a one-time download token for a stored binary. Only a
+// hash of the token is kept.
+type DownloadToken struct {
+	Hash      string    `json:"hash"`
+	Path      string    `json:"path"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // DeviceUpdateEntity represents the Datastore entity for device updates
 type DeviceUpdateEntity struct {
-	DeviceID     string    `datastore:"device_id"`
-	ReleaseID    string    `datastore:"release_id"`
-	DeploymentID string    `datastore:"deployment_id"`
-	Status       string    `datastore:"status"`
-	Progress     int       `datastore:"progress"`
-	ErrorMessage string    `datastore:"error_message,noindex"`
-	StartedAt    time.Time `datastore:"started_at"`
-	CompletedAt  time.Time `datastore:"completed_at"`
+	DeviceID      string    `datastore:"device_id"`
+	ReleaseID     string    `datastore:"release_id"`
+	DeploymentID  string    `datastore:"deployment_id"`
+	Status        string    `datastore:"status"`
+	Progress      int       `datastore:"progress"`
+	Attempts      int       `datastore:"attempts,noindex"`
+	BytesServed   int64     `datastore:"bytes_served,noindex"`
+	Downloaded    int64     `datastore:"downloaded_bytes,noindex"`
+	ErrorMessage  string    `datastore:"error_message,noindex"`
+	ErrorType     string    `datastore:"error_type"`
+	ErrorCode     string    `datastore:"error_code"`
+	StartedAt     time.Time `datastore:"started_at"`
+	CompletedAt   time.Time `datastore:"completed_at"`
+	NextAttemptAt time.Time `datastore:"next_attempt_at,noindex"`
+	ReportedAt    time.Time `datastore:"reported_at,noindex"`
+	BootedAt      time.Time `datastore:"booted_at,noindex"`
+	BootSlot      string    `datastore:"boot_slot,noindex"`
+	TokensJSON    string    `datastore:"download_tokens_json,noindex"`
+}
+
+// CrashReport records a crash or unexpected reset reported by a device.
+// ReleaseID and DeploymentID are set when the crash followed an OTA update
+// of the device closely enough to be attributed to it.
+type CrashReport struct {
+	ReportID        string      `json:"report_id"`
+	DeviceID        string      `json:"device_id"`
+	Reason          CrashReason `json:"reason"`
+	RawReason       string      `json:"raw_reason,omitempty"`
+	Message         string      `json:"message,omitempty"`
+	Backtrace       string      `json:"backtrace,omitempty"`
+	FirmwareVersion string      `json:"firmware_version,omitempty"`
+	FirmwareHash    string      `json:"firmware_hash,omitempty"`
+	UptimeSeconds   int64       `json:"uptime_seconds,omitempty"`
+	BootCount       int         `json:"boot_count,omitempty"`
+	ReleaseID       string      `json:"release_id,omitempty"`
+	DeploymentID    string      `json:"deployment_id,omitempty"`
+	SinceUpdate     string      `json:"since_update,omitempty"`
+	OccurredAt      time.Time   `json:"occurred_at"`
+	ReceivedAt      time.Time   `json:"received_at"`
+}
+
+// CrashReportEntity represents the Datastore entity for crash reports
+type CrashReportEntity struct {
+	ReportID        string    `datastore:"report_id"`
+	DeviceID        string    `datastore:"device_id"`
+	Reason          string    `datastore:"reason"`
+	RawReason       string    `datastore:"raw_reason,noindex"`
+	Message         string    `datastore:"message,noindex"`
+	Backtrace       string    `datastore:"backtrace,noindex"`
+	FirmwareVersion string    `datastore:"firmware_version"`
+	FirmwareHash    string    `datastore:"firmware_hash"`
+	UptimeSeconds   int64     `datastore:"uptime_seconds,noindex"`
+	BootCount       int       `datastore:"boot_count,noindex"`
+	ReleaseID       string    `datastore:"release_id"`
+	DeploymentID    string    `datastore:"deployment_id"`
+	SinceUpdate     string    `datastore:"since_update,noindex"`
+	OccurredAt      time.Time `datastore:"occurred_at"`
+	ReceivedAt      time.Time `datastore:"received_at"`
+}
+
+// Webhook is an endpoint OTA events are posted to, such as a CI/CD
+// pipeline. It receives the events listed in Events, or all events when
+// none are listed. Secret signs each delivery and is only returned when the
+// webhook is created.
+type Webhook struct {
+	WebhookID   string             `json:"webhook_id"`
+	URL         string             `json:"url"`
+	Events      []WebhookEventType `json:"events,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enabled     bool               `json:"enabled"`
+	Secret      string             `json:"secret,omitempty"`
+	CreatedBy   string             `json:"created_by,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// WebhookEntity represents the Datastore entity for webhooks
+type WebhookEntity struct {
+	WebhookID   string    `datastore:"webhook_id"`
+	URL         string    `datastore:"url,noindex"`
+	Events      []string  `datastore:"events,noindex"`
+	Description string    `datastore:"description,noindex"`
+	Enabled     bool      `datastore:"enabled"`
+	Secret      string    `datastore:"secret,noindex"`
+	CreatedBy   string    `datastore:"created_by"`
+	CreatedAt   time.Time `datastore:"created_at"`
+	UpdatedAt   time.Time `datastore:"updated_at"`
 }
 
 // CreateReleaseRequest represents a request to create a new firmware release
 type CreateReleaseRequest struct {
-	TemplateID   string         `json:"template_id" binding:"required"`
-	Version      string         `json:"version" binding:"required"`
-	Channel      ReleaseChannel `json:"channel" binding:"required"`
-	BinaryData   []byte         `json:"binary_data" binding:"required"`
-	ReleaseNotes string         `json:"release_notes"`
-	CreatedBy    string         `json:"created_by"`
+	// Name is generated when left empty and names are enabled
+	Name            string            `json:"name,omitempty"`
+	TemplateID      string            `json:"template_id" binding:"required"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	Version         string            `json:"version" binding:"required"`
+	Channel         ReleaseChannel    `json:"channel" binding:"required"`
+	BinaryData      []byte            `json:"binary_data" binding:"required"`
+	ReleaseNotes    string            `json:"release_notes"`
+	CreatedBy       string            `json:"created_by"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Slots           *SlotMetadata     `json:"slots,omitempty"`
 }
 
 // DeploymentConfig represents the configuration for a deployment
 type DeploymentConfig struct {
+	// Name is generated when left empty and names are enabled
+	Name              string             `json:"name,omitempty"`
 	Strategy          DeploymentStrategy `json:"strategy" binding:"required"`
 	TargetDevices     []string           `json:"target_devices"`
 	RolloutPercentage int                `json:"rollout_percentage"`
 	FailureThreshold  int                `json:"failure_threshold"`
+	// TargetGroups names device groups and TargetLabels is a label
+	// selector such as {"region": "eu"}; both add devices of the release's
+	// template to TargetDevices. With RollingTargets the groups and
+	// selector are resolved again while the deployment rolls out, so
+	// devices joining them are updated and devices leaving them before
+	// their update are dropped.
+	TargetGroups   []string          `json:"target_groups,omitempty"`
+	TargetLabels   map[string]string `json:"target_labels,omitempty"`
+	RollingTargets bool              `json:"rolling_targets,omitempty"`
+	// DataBudgetBytes defaults to bandwidth.deployment_budget
+	DataBudgetBytes int64 `json:"data_budget_bytes,omitempty"`
+	// A scheduled deployment rolls out only after StartAt, before EndAt and
+	// inside one of its maintenance windows, such as "02:00-04:00 UTC"
+	StartAt            *time.Time `json:"start_at,omitempty"`
+	EndAt              *time.Time `json:"end_at,omitempty"`
+	MaintenanceWindows []string   `json:"maintenance_windows,omitempty"`
+	// A canary or staged rollout expands to each of PromotionTiers in turn,
+	// such as 25 then 100 percent, once PromotionSuccess percent of the
+	// devices updated so far have succeeded. Both default from promotion
+	// settings.
+	PromotionTiers   []int `json:"promotion_tiers,omitempty"`
+	PromotionSuccess int   `json:"promotion_success_percentage,omitempty"`
+	// Download links expire after URLExpirySeconds, by default
+	// downloads.url_expiry. With OneTimeDownloads devices get a link that
+	// works once, through the OTA service, for that device only.
+	URLExpirySeconds int               `json:"url_expiry_seconds,omitempty"`
+	OneTimeDownloads bool              `json:"one_time_downloads,omitempty"`
+	Annotations      map[string]string `json:"annotations,omitempty"`
+	RetryPolicy      *RetryPolicy      `json:"retry_policy,omitempty"`
+	HealthPolicy     *HealthPolicy     `json:"health_policy,omitempty"`
 }
 
-// UpdateStatusReport represents a status report from a device
+// UpdateStatusReport represents a status report from a device. DeviceID
+// may be left out when reporting to /devices/:deviceId/updates/status.
+// ErrorType classifies a failure for the deployment's retry policy.
+// ErrorCode names the failure and implies its ErrorType, which may then be
+// left out. Downloaded is how many bytes of the binary the device holds,
+// for devices that resume downloads.
 type UpdateStatusReport struct {
-	DeviceID     string       `json:"device_id" binding:"required"`
-	ReleaseID    string       `json:"release_id" binding:"required"`
-	Status       UpdateStatus `json:"status" binding:"required"`
-	Progress     int          `json:"progress"`
-	ErrorMessage string       `json:"error_message,omitempty"`
+	DeviceID     string          `json:"device_id"`
+	ReleaseID    string          `json:"release_id" binding:"required"`
+	Status       UpdateStatus    `json:"status" binding:"required"`
+	Progress     int             `json:"progress"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	ErrorType    UpdateErrorType `json:"error_type,omitempty"`
+	ErrorCode    UpdateErrorCode `json:"error_code,omitempty"`
+	Downloaded   int64           `json:"downloaded_bytes,omitempty"`
+}
+
+// DownloadReport is sent by a device after downloading an update's binary
+// or patch, with the number of bytes it fetched. DeviceID may be left out
+// when reporting to /devices/:deviceId/updates/downloaded.
+type DownloadReport struct {
+	DeviceID  string `json:"device_id"`
+	ReleaseID string `json:"release_id" binding:"required"`
+	Bytes     int64  `json:"bytes" binding:"required"`
 }
 
-// FirmwareUpdate represents the update information for a device
+// FirmwareUpdate represents the update information for a device.
+// SigningKeyID names the key Signature verifies against, for devices that
+// hold several public keys. With Encoding set, the binary at BinaryURL is
+// compressed; BinaryHash, BinarySize and Signature are always of the
+// decompressed image. Slots tells A/B devices where to install the image
+// and how to boot it.
 type FirmwareUpdate struct {
-	ReleaseID    string    `json:"release_id"`
-	Version      string    `json:"version"`
-	BinaryURL    string    `json:"binary_url"`
-	BinaryHash   string    `json:"binary_hash"`
-	BinarySize   int64     `json:"binary_size"`
-	Signature    string    `json:"signature"`
-	ReleaseNotes string    `json:"release_notes"`
-	CreatedAt    time.Time `json:"created_at"`
+	ReleaseID      string         `json:"release_id"`
+	Version        string         `json:"version"`
+	BinaryURL      string         `json:"binary_url"`
+	BinaryHash     string         `json:"binary_hash"`
+	BinarySize     int64          `json:"binary_size"`
+	Encoding       string         `json:"encoding,omitempty"`
+	CompressedSize int64          `json:"compressed_size,omitempty"`
+	CompressedHash string         `json:"compressed_hash,omitempty"`
+	Signature      string         `json:"signature"`
+	SigningKeyID   string         `json:"signing_key_id,omitempty"`
+	ReleaseNotes   string         `json:"release_notes"`
+	Delta          *DeltaDownload `json:"delta,omitempty"`
+	Slots          *SlotMetadata  `json:"slots,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// DeltaDownload describes a patch from the firmware a device runs to its
+// pending update. The patched image must match the update's BinaryHash
+// and Signature.
+type DeltaDownload struct {
+	Format        string `json:"format"`
+	BaseReleaseID string `json:"base_release_id"`
+	BaseVersion   string `json:"base_version"`
+	BaseHash      string `json:"base_hash"`
+	PatchURL      string `json:"patch_url"`
+	PatchHash     string `json:"patch_hash"`
+	PatchSize     int64  `json:"patch_size"`
+	Signature     string `json:"signature"` // of the patch itself
+	SigningKeyID  string `json:"signing_key_id,omitempty"`
 }
 
 // ToEntity converts a FirmwareRelease to a FirmwareReleaseEntity
 func (r *FirmwareRelease) ToEntity() (*FirmwareReleaseEntity, error) {
+	annotationsJSON, err := marshalAnnotations(r.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltasJSON string
+	if len(r.Deltas) > 0 {
+		data, err := json.Marshal(r.Deltas)
+		if err != nil {
+			return nil, err
+		}
+		deltasJSON = string(data)
+	}
+
+	var encodingsJSON string
+	if len(r.Encodings) > 0 {
+		data, err := json.Marshal(r.Encodings)
+		if err != nil {
+			return nil, err
+		}
+		encodingsJSON = string(data)
+	}
+
+	var slotsJSON string
+	if r.Slots != nil {
+		data, err := json.Marshal(r.Slots)
+		if err != nil {
+			return nil, err
+		}
+		slotsJSON = string(data)
+	}
+
 	return &FirmwareReleaseEntity{
-		ReleaseID:    r.ReleaseID,
-		TemplateID:   r.TemplateID,
-		Version:      r.Version,
-		Channel:      string(r.Channel),
-		BinaryHash:   r.BinaryHash,
-		BinaryPath:   r.BinaryPath,
-		BinarySize:   r.BinarySize,
-		Signature:    r.Signature,
-		ReleaseNotes: r.ReleaseNotes,
-		CreatedAt:    r.CreatedAt,
-		CreatedBy:    r.CreatedBy,
+		ReleaseID:       r.ReleaseID,
+		Name:            r.Name,
+		TemplateID:      r.TemplateID,
+		TemplateVersion: r.TemplateVersion,
+		Version:         r.Version,
+		Channel:         string(r.Channel),
+		BinaryHash:      r.BinaryHash,
+		BinaryPath:      r.BinaryPath,
+		BinarySize:      r.BinarySize,
+		Signature:       r.Signature,
+		SigningKeyID:    r.SigningKeyID,
+		ReleaseNotes:    r.ReleaseNotes,
+		AnnotationsJSON: annotationsJSON,
+		DeltasJSON:      deltasJSON,
+		EncodingsJSON:   encodingsJSON,
+		SlotsJSON:       slotsJSON,
+		Environments:    r.Environments,
+		CreatedAt:       r.CreatedAt,
+		CreatedBy:       r.CreatedBy,
 	}, nil
 }
 
 // FromEntity converts a FirmwareReleaseEntity to a FirmwareRelease
 func (e *FirmwareReleaseEntity) FromEntity() (*FirmwareRelease, error) {
+	annotations, err := unmarshalAnnotations(e.AnnotationsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltas []DeltaPatch
+	if e.DeltasJSON != "" {
+		if err := json.Unmarshal([]byte(e.DeltasJSON), &deltas); err != nil {
+			return nil, err
+		}
+	}
+
+	var encodings []EncodedBinary
+	if e.EncodingsJSON != "" {
+		if err := json.Unmarshal([]byte(e.EncodingsJSON), &encodings); err != nil {
+			return nil, err
+		}
+	}
+
+	var slots *SlotMetadata
+	if e.SlotsJSON != "" {
+		if err := json.Unmarshal([]byte(e.SlotsJSON), &slots); err != nil {
+			return nil, err
+		}
+	}
+
 	return &FirmwareRelease{
-		ReleaseID:    e.ReleaseID,
-		TemplateID:   e.TemplateID,
-		Version:      e.Version,
-		Channel:      ReleaseChannel(e.Channel),
-		BinaryHash:   e.BinaryHash,
-		BinaryPath:   e.BinaryPath,
-		BinarySize:   e.BinarySize,
-		Signature:    e.Signature,
-		ReleaseNotes: e.ReleaseNotes,
-		CreatedAt:    e.CreatedAt,
-		CreatedBy:    e.CreatedBy,
+		ReleaseID:       e.ReleaseID,
+		Name:            e.Name,
+		TemplateID:      e.TemplateID,
+		TemplateVersion: e.TemplateVersion,
+		Version:         e.Version,
+		Channel:         ReleaseChannel(e.Channel),
+		BinaryHash:      e.BinaryHash,
+		BinaryPath:      e.BinaryPath,
+		BinarySize:      e.BinarySize,
+		Signature:       e.Signature,
+		SigningKeyID:    e.SigningKeyID,
+		ReleaseNotes:    e.ReleaseNotes,
+		Annotations:     annotations,
+		Deltas:          deltas,
+		Encodings:       encodings,
+		Slots:           slots,
+		Environments:    e.Environments,
+		CreatedAt:       e.CreatedAt,
+		CreatedBy:       e.CreatedBy,
 	}, nil
 }
 
@@ -209,19 +678,140 @@ func (d *OTADeployment) ToEntity() (*OTADeploymentEntity, error) {
 		return nil, err
 	}
 
-	return &OTADeploymentEntity{
+	annotationsJSON, err := marshalAnnotations(d.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	var retryPolicyJSON string
+	if d.RetryPolicy != nil {
+		data, err := json.Marshal(d.RetryPolicy)
+		if err != nil {
+			return nil, err
+		}
+		retryPolicyJSON = string(data)
+	}
+
+	var healthPolicyJSON string
+	if d.HealthPolicy != nil {
+		data, err := json.Marshal(d.HealthPolicy)
+		if err != nil {
+			return nil, err
+		}
+		healthPolicyJSON = string(data)
+	}
+
+	var regressionJSON string
+	if d.HealthRegression != nil {
+		data, err := json.Marshal(d.HealthRegression)
+		if err != nil {
+			return nil, err
+		}
+		regressionJSON = string(data)
+	}
+
+	var skippedJSON string
+	if len(d.SkippedDevices) > 0 {
+		data, err := json.Marshal(d.SkippedDevices)
+		if err != nil {
+			return nil, err
+		}
+		skippedJSON = string(data)
+	}
+
+	var groupsJSON string
+	if len(d.TargetGroups) > 0 {
+		data, err := json.Marshal(d.TargetGroups)
+		if err != nil {
+			return nil, err
+		}
+		groupsJSON = string(data)
+	}
+
+	var labelsJSON string
+	if len(d.TargetLabels) > 0 {
+		data, err := json.Marshal(d.TargetLabels)
+		if err != nil {
+			return nil, err
+		}
+		labelsJSON = string(data)
+	}
+
+	var windowsJSON string
+	if len(d.MaintenanceWindows) > 0 {
+		data, err := json.Marshal(d.MaintenanceWindows)
+		if err != nil {
+			return nil, err
+		}
+		windowsJSON = string(data)
+	}
+
+	var tiersJSON string
+	if len(d.PromotionTiers) > 0 {
+		data, err := json.Marshal(d.PromotionTiers)
+		if err != nil {
+			return nil, err
+		}
+		tiersJSON = string(data)
+	}
+
+	var promotionsJSON string
+	if len(d.Promotions) > 0 {
+		data, err := json.Marshal(d.Promotions)
+		if err != nil {
+			return nil, err
+		}
+		promotionsJSON = string(data)
+	}
+
+	var approvalJSON string
+	if d.Approval != nil {
+		data, err := json.Marshal(d.Approval)
+		if err != nil {
+			return nil, err
+		}
+		approvalJSON = string(data)
+	}
+
+	entity := &OTADeploymentEntity{
 		DeploymentID:      d.DeploymentID,
+		Name:              d.Name,
 		ReleaseID:         d.ReleaseID,
 		Strategy:          string(d.Strategy),
 		TargetDevicesJSON: string(targetDevicesJSON),
+		SkippedJSON:       skippedJSON,
+		TargetGroupsJSON:  groupsJSON,
+		TargetLabelsJSON:  labelsJSON,
+		RollingTargets:    d.RollingTargets,
 		RolloutPercentage: d.RolloutPercentage,
 		Status:            string(d.Status),
 		FailureThreshold:  d.FailureThreshold,
 		SuccessCount:      d.SuccessCount,
 		FailureCount:      d.FailureCount,
+		BytesServed:       d.BytesServed,
+		DataBudgetBytes:   d.DataBudgetBytes,
+		AnnotationsJSON:   annotationsJSON,
+		WindowsJSON:       windowsJSON,
+		TiersJSON:         tiersJSON,
+		PromotionSuccess:  d.PromotionSuccess,
+		PromotionsJSON:    promotionsJSON,
+		URLExpirySeconds:  d.URLExpirySeconds,
+		OneTimeDownloads:  d.OneTimeDownloads,
+		ApprovalJSON:      approvalJSON,
+		RetryPolicyJSON:   retryPolicyJSON,
+		HealthPolicyJSON:  healthPolicyJSON,
+		RegressionJSON:    regressionJSON,
 		CreatedAt:         d.CreatedAt,
 		UpdatedAt:         d.UpdatedAt,
-	}, nil
+	}
+	if d.StartAt != nil {
+		entity.StartAt = *d.StartAt
+	}
+	if d.EndAt != nil {
+		entity.EndAt = *d.EndAt
+	}
+
+	return entity, nil
 }
 
 // FromEntity converts an OTADeploymentEntity to an OTADeployment
@@ -233,19 +823,124 @@ func (e *OTADeploymentEntity) FromEntity() (*OTADeployment, error) {
 		}
 	}
 
-	return &OTADeployment{
-		DeploymentID:      e.DeploymentID,
-		ReleaseID:         e.ReleaseID,
-		Strategy:          DeploymentStrategy(e.Strategy),
-		TargetDevices:     targetDevices,
-		RolloutPercentage: e.RolloutPercentage,
-		Status:            DeploymentStatus(e.Status),
-		FailureThreshold:  e.FailureThreshold,
-		SuccessCount:      e.SuccessCount,
-		FailureCount:      e.FailureCount,
-		CreatedAt:         e.CreatedAt,
-		UpdatedAt:         e.UpdatedAt,
-	}, nil
+	annotations, err := unmarshalAnnotations(e.AnnotationsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var retryPolicy *RetryPolicy
+	if e.RetryPolicyJSON != "" {
+		retryPolicy = &RetryPolicy{}
+		if err := json.Unmarshal([]byte(e.RetryPolicyJSON), retryPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	var healthPolicy *HealthPolicy
+	if e.HealthPolicyJSON != "" {
+		healthPolicy = &HealthPolicy{}
+		if err := json.Unmarshal([]byte(e.HealthPolicyJSON), healthPolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	var regression *HealthRegression
+	if e.RegressionJSON != "" {
+		regression = &HealthRegression{}
+		if err := json.Unmarshal([]byte(e.RegressionJSON), regression); err != nil {
+			return nil, err
+		}
+	}
+
+	var skipped []*SkippedDevice
+	if e.SkippedJSON != "" {
+		if err := json.Unmarshal([]byte(e.SkippedJSON), &skipped); err != nil {
+			return nil, err
+		}
+	}
+
+	var groups []string
+	if e.TargetGroupsJSON != "" {
+		if err := json.Unmarshal([]byte(e.TargetGroupsJSON), &groups); err != nil {
+			return nil, err
+		}
+	}
+
+	var labels map[string]string
+	if e.TargetLabelsJSON != "" {
+		if err := json.Unmarshal([]byte(e.TargetLabelsJSON), &labels); err != nil {
+			return nil, err
+		}
+	}
+
+	var windows []string
+	if e.WindowsJSON != "" {
+		if err := json.Unmarshal([]byte(e.WindowsJSON), &windows); err != nil {
+			return nil, err
+		}
+	}
+
+	var tiers []int
+	if e.TiersJSON != "" {
+		if err := json.Unmarshal([]byte(e.TiersJSON), &tiers); err != nil {
+			return nil, err
+		}
+	}
+
+	var promotions []*Promotion
+	if e.PromotionsJSON != "" {
+		if err := json.Unmarshal([]byte(e.PromotionsJSON), &promotions); err != nil {
+			return nil, err
+		}
+	}
+
+	var approval *Approval
+	if e.ApprovalJSON != "" {
+		approval = &Approval{}
+		if err := json.Unmarshal([]byte(e.ApprovalJSON), approval); err != nil {
+			return nil, err
+		}
+	}
+
+	deployment := &OTADeployment{
+		DeploymentID:       e.DeploymentID,
+		Name:               e.Name,
+		ReleaseID:          e.ReleaseID,
+		Strategy:           DeploymentStrategy(e.Strategy),
+		TargetDevices:      targetDevices,
+		SkippedDevices:     skipped,
+		TargetGroups:       groups,
+		TargetLabels:       labels,
+		RollingTargets:     e.RollingTargets,
+		RolloutPercentage:  e.RolloutPercentage,
+		Status:             DeploymentStatus(e.Status),
+		FailureThreshold:   e.FailureThreshold,
+		SuccessCount:       e.SuccessCount,
+		FailureCount:       e.FailureCount,
+		BytesServed:        e.BytesServed,
+		DataBudgetBytes:    e.DataBudgetBytes,
+		MaintenanceWindows: windows,
+		PromotionTiers:     tiers,
+		PromotionSuccess:   e.PromotionSuccess,
+		Promotions:         promotions,
+		URLExpirySeconds:   e.URLExpirySeconds,
+		OneTimeDownloads:   e.OneTimeDownloads,
+		Approval:           approval,
+		Annotations:        annotations,
+		RetryPolicy:        retryPolicy,
+		HealthPolicy:       healthPolicy,
+		HealthRegression:   regression,
+		CreatedAt:          e.CreatedAt,
+		UpdatedAt:          e.UpdatedAt,
+	}
+	if !e.StartAt.IsZero() {
+		deployment.StartAt = &e.StartAt
+	}
+	if !e.EndAt.IsZero() {
+		deployment.EndAt = &e.EndAt
+	}
+
+	return deployment, nil
 }
 
 // ToEntity converts a DeviceUpdate to a DeviceUpdateEntity
@@ -256,13 +951,35 @@ func (u *DeviceUpdate) ToEntity() (*DeviceUpdateEntity, error) {
 		DeploymentID: u.DeploymentID,
 		Status:       string(u.Status),
 		Progress:     u.Progress,
+		Attempts:     u.Attempts,
+		BytesServed:  u.BytesServed,
+		Downloaded:   u.Downloaded,
 		ErrorMessage: u.ErrorMessage,
+		ErrorType:    string(u.ErrorType),
+		ErrorCode:    string(u.ErrorCode),
 		StartedAt:    u.StartedAt,
+		BootSlot:     u.BootSlot,
 	}
 
 	if u.CompletedAt != nil {
 		entity.CompletedAt = *u.CompletedAt
 	}
+	if u.NextAttemptAt != nil {
+		entity.NextAttemptAt = *u.NextAttemptAt
+	}
+	if u.ReportedAt != nil {
+		entity.ReportedAt = *u.ReportedAt
+	}
+	if u.BootedAt != nil {
+		entity.BootedAt = *u.BootedAt
+	}
+	if len(u.DownloadTokens) > 0 {
+		data, err := json.Marshal(u.DownloadTokens)
+		if err != nil {
+			return nil, err
+		}
+		entity.TokensJSON = string(data)
+	}
 
 	return entity, nil
 }
@@ -275,13 +992,136 @@ func (e *DeviceUpdateEntity) FromEntity() (*DeviceUpdate, error) {
 		DeploymentID: e.DeploymentID,
 		Status:       UpdateStatus(e.Status),
 		Progress:     e.Progress,
+		Attempts:     e.Attempts,
+		BytesServed:  e.BytesServed,
+		Downloaded:   e.Downloaded,
 		ErrorMessage: e.ErrorMessage,
+		ErrorType:    UpdateErrorType(e.ErrorType),
+		ErrorCode:    UpdateErrorCode(e.ErrorCode),
 		StartedAt:    e.StartedAt,
+		BootSlot:     e.BootSlot,
 	}
 
 	if !e.CompletedAt.IsZero() {
 		update.CompletedAt = &e.CompletedAt
 	}
+	if !e.NextAttemptAt.IsZero() {
+		update.NextAttemptAt = &e.NextAttemptAt
+	}
+	if !e.ReportedAt.IsZero() {
+		update.ReportedAt = &e.ReportedAt
+	}
+	if !e.BootedAt.IsZero() {
+		update.BootedAt = &e.BootedAt
+	}
+	if e.TokensJSON != "" {
+		if err := json.Unmarshal([]byte(e.TokensJSON), &update.DownloadTokens); err != nil {
+			return nil, err
+		}
+	}
 
 	return update, nil
 }
+
+// ToEntity converts a CrashReport to a CrashReportEntity
+func (r *CrashReport) ToEntity() *CrashReportEntity {
+	return &CrashReportEntity{
+		ReportID:        r.ReportID,
+		DeviceID:        r.DeviceID,
+		Reason:          string(r.Reason),
+		RawReason:       r.RawReason,
+		Message:         r.Message,
+		Backtrace:       r.Backtrace,
+		FirmwareVersion: r.FirmwareVersion,
+		FirmwareHash:    r.FirmwareHash,
+		UptimeSeconds:   r.UptimeSeconds,
+		BootCount:       r.BootCount,
+		ReleaseID:       r.ReleaseID,
+		DeploymentID:    r.DeploymentID,
+		SinceUpdate:     r.SinceUpdate,
+		OccurredAt:      r.OccurredAt,
+		ReceivedAt:      r.ReceivedAt,
+	}
+}
+
+// FromEntity converts a CrashReportEntity to a CrashReport
+func (e *CrashReportEntity) FromEntity() *CrashReport {
+	return &CrashReport{
+		ReportID:        e.ReportID,
+		DeviceID:        e.DeviceID,
+		Reason:          CrashReason(e.Reason),
+		RawReason:       e.RawReason,
+		Message:         e.Message,
+		Backtrace:       e.Backtrace,
+		FirmwareVersion: e.FirmwareVersion,
+		FirmwareHash:    e.FirmwareHash,
+		UptimeSeconds:   e.UptimeSeconds,
+		BootCount:       e.BootCount,
+		ReleaseID:       e.ReleaseID,
+		DeploymentID:    e.DeploymentID,
+		SinceUpdate:     e.SinceUpdate,
+		OccurredAt:      e.OccurredAt,
+		ReceivedAt:      e.ReceivedAt,
+	}
+}
+
+// ToEntity converts a Webhook to a WebhookEntity for Datastore storage
+func (w *Webhook) ToEntity() *WebhookEntity {
+	events := make([]string, len(w.Events))
+	for i, event := range w.Events {
+		events[i] = string(event)
+	}
+	return &WebhookEntity{
+		WebhookID:   w.WebhookID,
+		URL:         w.URL,
+		Events:      events,
+		Description: w.Description,
+		Enabled:     w.Enabled,
+		Secret:      w.Secret,
+		CreatedBy:   w.CreatedBy,
+		CreatedAt:   w.CreatedAt,
+		UpdatedAt:   w.UpdatedAt,
+	}
+}
+
+// FromEntity converts a WebhookEntity to a Webhook
+func (e *WebhookEntity) FromEntity() *Webhook {
+	var events []WebhookEventType
+	for _, event := range e.Events {
+		events = append(events, WebhookEventType(event))
+	}
+	return &Webhook{
+		WebhookID:   e.WebhookID,
+		URL:         e.URL,
+		Events:      events,
+		Description: e.Description,
+		Enabled:     e.Enabled,
+		Secret:      e.Secret,
+		CreatedBy:   e.CreatedBy,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// marshalAnnotations encodes annotations for storage; none are stored as ""
+func marshalAnnotations(annotations map[string]string) (string, error) {
+	if len(annotations) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalAnnotations(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var annotations map[string]string
+	if err := json.Unmarshal([]byte(data), &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
@@ -0,0 +1,208 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrPolicyNotFound is returned when a fleet policy does not exist
+var ErrPolicyNotFound = errors.New("fleet policy not found")
+
+// FleetPolicy subscribes the devices running a template (optionally narrowed
+// to a device group and by labels) to a release channel. Once a release on the channel has been
+// published for Delay, the policy engine deploys it to the matching devices
+// unless the policy is paused.
+type FleetPolicy struct {
+	PolicyID          string             `json:"policy_id"`
+	Name              string             `json:"name"`
+	TemplateID        string             `json:"template_id"`
+	DeviceGroup       string             `json:"device_group,omitempty"`
+	Labels            map[string]string  `json:"labels,omitempty"`
+	Channel           ReleaseChannel     `json:"channel"`
+	DelayHours        int                `json:"delay_hours"`
+	Strategy          DeploymentStrategy `json:"strategy"`
+	RolloutPercentage int                `json:"rollout_percentage"`
+	FailureThreshold  int                `json:"failure_threshold"`
+	Paused            bool               `json:"paused"`
+	PausedReason      string             `json:"paused_reason,omitempty"`
+	LastReleaseID     string             `json:"last_release_id,omitempty"`
+	LastDeploymentID  string             `json:"last_deployment_id,omitempty"`
+	LastEvaluatedAt   *time.Time         `json:"last_evaluated_at,omitempty"`
+	CreatedBy         string             `json:"created_by"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+// Delay is how long a release must have been published before it is deployed
+func (p *FleetPolicy) Delay() time.Duration {
+	return time.Duration(p.DelayHours) * time.Hour
+}
+
+// FleetPolicyRequest creates or replaces a fleet policy
+type FleetPolicyRequest struct {
+	Name              string             `json:"name" binding:"required"`
+	TemplateID        string             `json:"template_id" binding:"required"`
+	DeviceGroup       string             `json:"device_group,omitempty"`
+	Labels            map[string]string  `json:"labels,omitempty"`
+	Channel           ReleaseChannel     `json:"channel" binding:"required"`
+	DelayHours        int                `json:"delay_hours"`
+	Strategy          DeploymentStrategy `json:"strategy,omitempty"`
+	RolloutPercentage int                `json:"rollout_percentage,omitempty"`
+	FailureThreshold  int                `json:"failure_threshold,omitempty"`
+	Actor             string             `json:"actor,omitempty"`
+}
+
+// PolicyActionRequest carries who paused, resumed or deleted a policy and why
+type PolicyActionRequest struct {
+	Actor  string `json:"actor,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyAuditAction identifies an entry in a policy's audit trail
+type PolicyAuditAction string
+
+const (
+	PolicyAuditCreated           PolicyAuditAction = "policy_created"
+	PolicyAuditUpdated           PolicyAuditAction = "policy_updated"
+	PolicyAuditPaused            PolicyAuditAction = "policy_paused"
+	PolicyAuditResumed           PolicyAuditAction = "policy_resumed"
+	PolicyAuditDeleted           PolicyAuditAction = "policy_deleted"
+	PolicyAuditDeploymentCreated PolicyAuditAction = "deployment_created"
+	PolicyAuditDeploymentFailed  PolicyAuditAction = "deployment_failed"
+	PolicyAuditNoMatchingDevices PolicyAuditAction = "no_matching_devices"
+)
+
+// PolicyAuditEntry records a change to a policy or a decision the engine made
+type PolicyAuditEntry struct {
+	EntryID      string            `json:"entry_id"`
+	PolicyID     string            `json:"policy_id"`
+	Action       PolicyAuditAction `json:"action"`
+	Actor        string            `json:"actor"`
+	ReleaseID    string            `json:"release_id,omitempty"`
+	DeploymentID string            `json:"deployment_id,omitempty"`
+	DeviceCount  int               `json:"device_count,omitempty"`
+	Message      string            `json:"message"`
+	Timestamp    time.Time         `json:"timestamp"`
+}
+
+// PolicyStore persists fleet policies and their audit trail
+type PolicyStore interface {
+	CreatePolicy(ctx context.Context, policy *FleetPolicy) error
+	GetPolicy(ctx context.Context, policyID string) (*FleetPolicy, error)
+	UpdatePolicy(ctx context.Context, policy *FleetPolicy) error
+	DeletePolicy(ctx context.Context, policyID string) error
+	ListPolicies(ctx context.Context) ([]*FleetPolicy, error)
+
+	AppendAudit(ctx context.Context, entry *PolicyAuditEntry) error
+	// ListAudit returns the newest entries first; limit <= 0 returns all
+	ListAudit(ctx context.Context, policyID string, limit int) ([]*PolicyAuditEntry, error)
+}
+
+// MemoryPolicyStore is an in-memory PolicyStore
+type MemoryPolicyStore struct {
+	mu       sync.RWMutex
+	policies map[string]*FleetPolicy
+	audit    map[string][]*PolicyAuditEntry
+}
+
+// NewMemoryPolicyStore creates an empty in-memory policy store
+func NewMemoryPolicyStore() *MemoryPolicyStore {
+	return &MemoryPolicyStore{
+		policies: make(map[string]*FleetPolicy),
+		audit:    make(map[string][]*PolicyAuditEntry),
+	}
+}
+
+// CreatePolicy stores a new policy
+func (s *MemoryPolicyStore) CreatePolicy(ctx context.Context, policy *FleetPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *policy
+	s.policies[policy.PolicyID] = &stored
+	return nil
+}
+
+// GetPolicy returns a copy of a policy
+func (s *MemoryPolicyStore) GetPolicy(ctx context.Context, policyID string) (*FleetPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policy, ok := s.policies[policyID]
+	if !ok {
+		return nil, ErrPolicyNotFound
+	}
+	copied := *policy
+	return &copied, nil
+}
+
+// UpdatePolicy replaces a stored policy
+func (s *MemoryPolicyStore) UpdatePolicy(ctx context.Context, policy *FleetPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[policy.PolicyID]; !ok {
+		return ErrPolicyNotFound
+	}
+	stored := *policy
+	s.policies[policy.PolicyID] = &stored
+	return nil
+}
+
+// DeletePolicy removes a policy. Its audit trail is kept.
+func (s *MemoryPolicyStore) DeletePolicy(ctx context.Context, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[policyID]; !ok {
+		return ErrPolicyNotFound
+	}
+	delete(s.policies, policyID)
+	return nil
+}
+
+// ListPolicies returns all policies ordered by creation time
+func (s *MemoryPolicyStore) ListPolicies(ctx context.Context) ([]*FleetPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policies := make([]*FleetPolicy, 0, len(s.policies))
+	for _, policy := range s.policies {
+		copied := *policy
+		policies = append(policies, &copied)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].CreatedAt.Before(policies[j].CreatedAt)
+	})
+	return policies, nil
+}
+
+// AppendAudit adds an entry to a policy's audit trail
+func (s *MemoryPolicyStore) AppendAudit(ctx context.Context, entry *PolicyAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *entry
+	s.audit[entry.PolicyID] = append(s.audit[entry.PolicyID], &stored)
+	return nil
+}
+
+// ListAudit returns a policy's audit entries, newest first
+func (s *MemoryPolicyStore) ListAudit(ctx context.Context, policyID string, limit int) ([]*PolicyAuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.audit[policyID]
+	result := make([]*PolicyAuditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		copied := *entries[i]
+		result = append(result, &copied)
+	}
+	return result, nil
+}
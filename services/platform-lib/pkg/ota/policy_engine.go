@@ -0,0 +1,536 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// policyEngineActor is recorded as the actor of deployments the engine creates
+const policyEngineActor = "policy-engine"
+
+// Defaults for policies that do not choose a rollout
+const (
+	defaultPolicyStrategy          = DeploymentStrategyStaged
+	defaultPolicyRolloutPercentage = 10
+)
+
+// PolicyEngine evaluates fleet policies and creates deployments for releases
+// that have become due. Every policy change and engine decision is recorded
+// in the policy's audit trail.
+type PolicyEngine struct {
+	service *Service
+	store   PolicyStore
+	logger  *logger.Logger
+	now     func() time.Time
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewPolicyEngine creates a policy engine that deploys through service
+func NewPolicyEngine(service *Service, store PolicyStore, logger *logger.Logger) *PolicyEngine {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &PolicyEngine{
+		service: service,
+		store:   store,
+		logger:  logger,
+		now:     time.Now,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start evaluates all policies every interval until Stop is called
+func (e *PolicyEngine) Start(interval time.Duration) {
+	e.wg.Add(1)
+	go e.evaluationLoop(interval)
+	e.logger.Info("Policy engine started", "interval", interval)
+}
+
+// Stop stops periodic evaluation
+func (e *PolicyEngine) Stop() {
+	e.cancel()
+	e.wg.Wait()
+	e.logger.Info("Policy engine stopped")
+}
+
+func (e *PolicyEngine) evaluationLoop(interval time.Duration) {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.EvaluateAll(e.ctx); err != nil {
+				e.logger.Error("Policy evaluation failed", "error", err)
+			}
+		}
+	}
+}
+
+// CreatePolicy validates and stores a new policy
+func (e *PolicyEngine) CreatePolicy(ctx context.Context, req *FleetPolicyRequest) (*FleetPolicy, error) {
+	now := e.now()
+	policy := &FleetPolicy{
+		PolicyID:  uuid.New().String(),
+		CreatedBy: req.Actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.applyPolicyRequest(ctx, policy, req); err != nil {
+		return nil, err
+	}
+
+	if err := e.store.CreatePolicy(ctx, policy); err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+	e.audit(ctx, policy, PolicyAuditCreated, req.Actor, fmt.Sprintf("Policy %q created", policy.Name))
+
+	return policy, nil
+}
+
+// UpdatePolicy replaces a policy's definition. Pause state and the last
+// deployed release are kept.
+func (e *PolicyEngine) UpdatePolicy(ctx context.Context, policyID string, req *FleetPolicyRequest) (*FleetPolicy, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy, err := e.store.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.applyPolicyRequest(ctx, policy, req); err != nil {
+		return nil, err
+	}
+	policy.UpdatedAt = e.now()
+
+	if err := e.store.UpdatePolicy(ctx, policy); err != nil {
+		return nil, fmt.Errorf("failed to update policy: %w", err)
+	}
+	e.audit(ctx, policy, PolicyAuditUpdated, req.Actor, fmt.Sprintf("Policy %q updated", policy.Name))
+
+	return policy, nil
+}
+
+// DeletePolicy removes a policy; its audit trail is retained
+func (e *PolicyEngine) DeletePolicy(ctx context.Context, policyID string, req *PolicyActionRequest) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy, err := e.store.GetPolicy(ctx, policyID)
+	if err != nil {
+		return err
+	}
+	if err := e.store.DeletePolicy(ctx, policyID); err != nil {
+		return fmt.Errorf("failed to delete policy: %w", err)
+	}
+	e.audit(ctx, policy, PolicyAuditDeleted, req.Actor, fmt.Sprintf("Policy %q deleted", policy.Name))
+
+	return nil
+}
+
+// PausePolicy stops the engine from creating deployments for a policy
+func (e *PolicyEngine) PausePolicy(ctx context.Context, policyID string, req *PolicyActionRequest) (*FleetPolicy, error) {
+	return e.setPaused(ctx, policyID, true, req)
+}
+
+// ResumePolicy lets the engine create deployments for a paused policy again
+func (e *PolicyEngine) ResumePolicy(ctx context.Context, policyID string, req *PolicyActionRequest) (*FleetPolicy, error) {
+	return e.setPaused(ctx, policyID, false, req)
+}
+
+func (e *PolicyEngine) setPaused(ctx context.Context, policyID string, paused bool, req *PolicyActionRequest) (*FleetPolicy, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy, err := e.store.GetPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Paused == paused {
+		return nil, fmt.Errorf("policy is already %s", map[bool]string{true: "paused", false: "active"}[paused])
+	}
+
+	policy.Paused = paused
+	policy.PausedReason = ""
+	action, message := PolicyAuditResumed, "Policy resumed"
+	if paused {
+		policy.PausedReason = req.Reason
+		action, message = PolicyAuditPaused, "Policy paused"
+	}
+	if req.Reason != "" {
+		message += ": " + req.Reason
+	}
+	policy.UpdatedAt = e.now()
+
+	if err := e.store.UpdatePolicy(ctx, policy); err != nil {
+		return nil, fmt.Errorf("failed to update policy: %w", err)
+	}
+	e.audit(ctx, policy, action, req.Actor, message)
+
+	return policy, nil
+}
+
+// EvaluateAll evaluates every active policy and returns the decisions made
+func (e *PolicyEngine) EvaluateAll(ctx context.Context) ([]*PolicyAuditEntry, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policies, err := e.store.ListPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies: %w", err)
+	}
+
+	var decisions []*PolicyAuditEntry
+	for _, policy := range policies {
+		if policy.Paused {
+			continue
+		}
+		entry, err := e.evaluate(ctx, policy)
+		if err != nil {
+			e.logger.Error("Failed to evaluate policy", "policy_id", policy.PolicyID, "error", err)
+			continue
+		}
+		if entry != nil {
+			decisions = append(decisions, entry)
+		}
+	}
+
+	return decisions, nil
+}
+
+// evaluate deploys the newest release that is due for a policy. It returns
+// the audit entry for the decision, or nil when there was nothing to do.
+func (e *PolicyEngine) evaluate(ctx context.Context, policy *FleetPolicy) (*PolicyAuditEntry, error) {
+	now := e.now()
+	policy.LastEvaluatedAt = &now
+	defer e.store.UpdatePolicy(ctx, policy)
+
+	releases, err := e.service.repository.ListReleases(ctx, policy.TemplateID, policy.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	// Only the newest due release is deployed; older ones are superseded
+	var due *FirmwareRelease
+	for _, release := range releases {
+		if release.CreatedAt.Add(policy.Delay()).After(now) {
+			continue
+		}
+		if due == nil || release.CreatedAt.After(due.CreatedAt) {
+			due = release
+		}
+	}
+	if due == nil || due.ReleaseID == policy.LastReleaseID {
+		return nil, nil
+	}
+
+	devices, err := e.policyDevices(ctx, policy, due)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, dev := range devices {
+		if dev.MatchesLabels(policy.Labels) {
+			targets = append(targets, dev.DeviceID)
+		}
+	}
+
+	if len(targets) == 0 {
+		return e.auditOnce(ctx, policy, &PolicyAuditEntry{
+			Action:    PolicyAuditNoMatchingDevices,
+			ReleaseID: due.ReleaseID,
+			Message:   fmt.Sprintf("Release %s is due but no devices match the policy", due.Version),
+		}), nil
+	}
+
+	deployment, err := e.service.DeployRelease(ctx, due.ReleaseID, &DeploymentConfig{
+		Strategy:          policy.Strategy,
+		TargetDevices:     targets,
+		RolloutPercentage: policy.RolloutPercentage,
+		FailureThreshold:  policy.FailureThreshold,
+		Annotations: map[string]string{
+			AnnotationPolicyID:   policy.PolicyID,
+			AnnotationPolicyName: policy.Name,
+		},
+	})
+	if err != nil {
+		return e.auditOnce(ctx, policy, &PolicyAuditEntry{
+			Action:      PolicyAuditDeploymentFailed,
+			ReleaseID:   due.ReleaseID,
+			DeviceCount: len(targets),
+			Message:     fmt.Sprintf("Failed to deploy release %s: %v", due.Version, err),
+		}), nil
+	}
+
+	policy.LastReleaseID = due.ReleaseID
+	policy.LastDeploymentID = deployment.DeploymentID
+	e.logger.Info("Policy created deployment", "policy_id", policy.PolicyID, "release_id", due.ReleaseID, "deployment_id", deployment.DeploymentID, "devices", len(targets))
+
+	entry := &PolicyAuditEntry{
+		Action:       PolicyAuditDeploymentCreated,
+		ReleaseID:    due.ReleaseID,
+		DeploymentID: deployment.DeploymentID,
+		DeviceCount:  len(targets),
+		Message: fmt.Sprintf("Deployed release %s to %d devices (%s), %s after publication",
+			due.Version, len(targets), policy.Strategy, now.Sub(due.CreatedAt).Round(time.Minute)),
+	}
+	e.record(ctx, policy, entry, policyEngineActor)
+	return entry, nil
+}
+
+// policyDevices lists the devices of a policy's template, only those in
+// its device group if it has one
+func (e *PolicyEngine) policyDevices(ctx context.Context, policy *FleetPolicy, release *FirmwareRelease) ([]*device.Device, error) {
+	if policy.DeviceGroup != "" {
+		return e.service.resolveTargets(ctx, release, []string{policy.DeviceGroup}, nil)
+	}
+	devices, err := e.service.deviceRepository.ListDevices(ctx, &device.DeviceFilters{TemplateID: policy.TemplateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	return devices, nil
+}
+
+// auditOnce records a decision unless it repeats the policy's latest entry,
+// so a release that keeps failing does not flood the audit trail
+func (e *PolicyEngine) auditOnce(ctx context.Context, policy *FleetPolicy, entry *PolicyAuditEntry) *PolicyAuditEntry {
+	latest, err := e.store.ListAudit(ctx, policy.PolicyID, 1)
+	if err == nil && len(latest) == 1 && latest[0].Action == entry.Action && latest[0].ReleaseID == entry.ReleaseID {
+		return nil
+	}
+	e.record(ctx, policy, entry, policyEngineActor)
+	return entry
+}
+
+func (e *PolicyEngine) audit(ctx context.Context, policy *FleetPolicy, action PolicyAuditAction, actor, message string) {
+	e.record(ctx, policy, &PolicyAuditEntry{Action: action, Message: message}, actor)
+}
+
+func (e *PolicyEngine) record(ctx context.Context, policy *FleetPolicy, entry *PolicyAuditEntry, actor string) {
+	entry.EntryID = uuid.New().String()
+	entry.PolicyID = policy.PolicyID
+	entry.Actor = actor
+	entry.Timestamp = e.now()
+
+	if err := e.store.AppendAudit(ctx, entry); err != nil {
+		e.logger.Error("Failed to record policy audit entry", "policy_id", policy.PolicyID, "action", entry.Action, "error", err)
+	}
+}
+
+// applyPolicyRequest validates a request and copies it onto policy
+func (e *PolicyEngine) applyPolicyRequest(ctx context.Context, policy *FleetPolicy, req *FleetPolicyRequest) error {
+	if req.Name == "" || req.TemplateID == "" {
+		return fmt.Errorf("name and template ID are required")
+	}
+	if req.Channel != ReleaseChannelStable && req.Channel != ReleaseChannelBeta && req.Channel != ReleaseChannelAlpha {
+		return fmt.Errorf("invalid release channel: %s", req.Channel)
+	}
+	if req.DelayHours < 0 {
+		return fmt.Errorf("delay hours cannot be negative")
+	}
+
+	strategy := req.Strategy
+	rollout := req.RolloutPercentage
+	if strategy == "" {
+		strategy = defaultPolicyStrategy
+	}
+	if rollout == 0 && strategy != DeploymentStrategyImmediate {
+		rollout = defaultPolicyRolloutPercentage
+	}
+	if strategy == DeploymentStrategyImmediate {
+		rollout = 100
+	}
+
+	config := &DeploymentConfig{Strategy: strategy, RolloutPercentage: rollout, FailureThreshold: req.FailureThreshold}
+	if err := e.service.validateDeploymentConfig(ctx, config); err != nil {
+		return err
+	}
+	if req.DeviceGroup != "" {
+		if _, err := e.service.deviceGroup(ctx, req.DeviceGroup); err != nil {
+			return err
+		}
+	}
+
+	policy.Name = req.Name
+	policy.TemplateID = req.TemplateID
+	policy.DeviceGroup = req.DeviceGroup
+	policy.Labels = req.Labels
+	policy.Channel = req.Channel
+	policy.DelayHours = req.DelayHours
+	policy.Strategy = config.Strategy
+	policy.RolloutPercentage = config.RolloutPercentage
+	policy.FailureThreshold = config.FailureThreshold
+	return nil
+}
+
+// RegisterPolicyRoutes registers the fleet policy routes
+func RegisterPolicyRoutes(router *gin.Engine, engine *PolicyEngine) {
+	policies := router.Group("/api/v1/ota/policies")
+	{
+		policies.POST("", engine.createPolicyHandler)
+		policies.GET("", engine.listPoliciesHandler)
+		policies.POST("/evaluate", engine.evaluatePoliciesHandler)
+		policies.GET("/:policyId", engine.getPolicyHandler)
+		policies.PUT("/:policyId", engine.updatePolicyHandler)
+		policies.DELETE("/:policyId", engine.deletePolicyHandler)
+		policies.PUT("/:policyId/pause", engine.pausePolicyHandler)
+		policies.PUT("/:policyId/resume", engine.resumePolicyHandler)
+		policies.GET("/:policyId/audit", engine.listPolicyAuditHandler)
+	}
+}
+
+func (e *PolicyEngine) createPolicyHandler(c *gin.Context) {
+	var req FleetPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := e.CreatePolicy(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+func (e *PolicyEngine) listPoliciesHandler(c *gin.Context) {
+	policies, err := e.store.ListPolicies(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies": policies,
+		"count":    len(policies),
+	})
+}
+
+func (e *PolicyEngine) getPolicyHandler(c *gin.Context) {
+	policy, err := e.store.GetPolicy(c.Request.Context(), c.Param("policyId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (e *PolicyEngine) updatePolicyHandler(c *gin.Context) {
+	var req FleetPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := e.UpdatePolicy(c.Request.Context(), c.Param("policyId"), &req)
+	if err != nil {
+		c.JSON(policyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (e *PolicyEngine) deletePolicyHandler(c *gin.Context) {
+	var req PolicyActionRequest
+	c.ShouldBindJSON(&req)
+
+	if err := e.DeletePolicy(c.Request.Context(), c.Param("policyId"), &req); err != nil {
+		c.JSON(policyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "policy deleted successfully", "policy_id": c.Param("policyId")})
+}
+
+func (e *PolicyEngine) pausePolicyHandler(c *gin.Context) {
+	var req PolicyActionRequest
+	c.ShouldBindJSON(&req)
+
+	policy, err := e.PausePolicy(c.Request.Context(), c.Param("policyId"), &req)
+	if err != nil {
+		c.JSON(policyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (e *PolicyEngine) resumePolicyHandler(c *gin.Context) {
+	var req PolicyActionRequest
+	c.ShouldBindJSON(&req)
+
+	policy, err := e.ResumePolicy(c.Request.Context(), c.Param("policyId"), &req)
+	if err != nil {
+		c.JSON(policyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+func (e *PolicyEngine) listPolicyAuditHandler(c *gin.Context) {
+	policyID := c.Param("policyId")
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := e.store.ListAudit(c.Request.Context(), policyID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy_id": policyID,
+		"entries":   entries,
+		"count":     len(entries),
+	})
+}
+
+func (e *PolicyEngine) evaluatePoliciesHandler(c *gin.Context) {
+	decisions, err := e.EvaluateAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"decisions": decisions,
+		"count":     len(decisions),
+	})
+}
+
+func policyErrorStatus(err error) int {
+	if errors.Is(err, ErrPolicyNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
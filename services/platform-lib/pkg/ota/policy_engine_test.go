@@ -0,0 +1,337 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPolicyEngine(t *testing.T, devices []*device.Device, releases ...*FirmwareRelease) (*PolicyEngine, *MockRepository, *time.Time) {
+	service, mockRepo, mockDeviceRepo, _ := setupTestService()
+
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannelStable).Return(releases, nil)
+	for _, release := range releases {
+		mockRepo.On("GetRelease", mock.Anything, release.ReleaseID).Return(release, nil)
+	}
+	mockRepo.On("CreateDeployment", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.Anything).Return(nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, &device.DeviceFilters{TemplateID: "template-001"}).Return(devices, nil)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewPolicyEngine(service, NewMemoryPolicyStore(), service.logger)
+	engine.now = func() time.Time { return now }
+	return engine, mockRepo, &now
+}
+
+func fleetDevices() []*device.Device {
+	return []*device.Device{
+		{DeviceID: "prod-1", TemplateID: "template-001", Labels: map[string]string{"env": "prod"}},
+		{DeviceID: "prod-2", TemplateID: "template-001", Labels: map[string]string{"env": "prod", "site": "lab"}},
+		{DeviceID: "dev-1", TemplateID: "template-001", Labels: map[string]string{"env": "dev"}},
+		{DeviceID: "unlabelled", TemplateID: "template-001"},
+	}
+}
+
+func prodPolicyRequest() *FleetPolicyRequest {
+	return &FleetPolicyRequest{
+		Name:       "prod-stable",
+		TemplateID: "template-001",
+		Labels:     map[string]string{"env": "prod"},
+		Channel:    ReleaseChannelStable,
+		DelayHours: 48,
+		Actor:      "alice",
+	}
+}
+
+func auditActions(t *testing.T, engine *PolicyEngine, policyID string) []PolicyAuditAction {
+	entries, err := engine.store.ListAudit(context.Background(), policyID, 0)
+	require.NoError(t, err)
+
+	actions := make([]PolicyAuditAction, len(entries))
+	for i, entry := range entries {
+		actions[len(entries)-1-i] = entry.Action
+	}
+	return actions
+}
+
+func TestPolicyEngine_DeploysDueReleases(t *testing.T) {
+	release := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0", Channel: ReleaseChannelStable}
+	engine, mockRepo, now := setupPolicyEngine(t, fleetDevices(), release)
+	ctx := context.Background()
+
+	policy, err := engine.CreatePolicy(ctx, prodPolicyRequest())
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStrategyStaged, policy.Strategy)
+	assert.Equal(t, 10, policy.RolloutPercentage)
+
+	release.CreatedAt = now.Add(-47 * time.Hour)
+	decisions, err := engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, decisions, "releases are held until the delay has passed")
+
+	*now = now.Add(2 * time.Hour)
+	decisions, err = engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+	assert.Equal(t, PolicyAuditDeploymentCreated, decisions[0].Action)
+	assert.Equal(t, policyEngineActor, decisions[0].Actor)
+	assert.Equal(t, 2, decisions[0].DeviceCount)
+
+	mockRepo.AssertCalled(t, "CreateDeployment", mock.Anything, mock.MatchedBy(func(d *OTADeployment) bool {
+		return d.ReleaseID == "release-2" && assert.ObjectsAreEqual([]string{"prod-1", "prod-2"}, d.TargetDevices) &&
+			d.Annotations[AnnotationPolicyID] == policy.PolicyID
+	}))
+
+	decisions, err = engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, decisions, "a release is deployed once per policy")
+	mockRepo.AssertNumberOfCalls(t, "CreateDeployment", 1)
+
+	stored, err := engine.store.GetPolicy(ctx, policy.PolicyID)
+	require.NoError(t, err)
+	assert.Equal(t, "release-2", stored.LastReleaseID)
+	assert.Equal(t, latestDeploymentID(t, engine, policy.PolicyID), stored.LastDeploymentID)
+	assert.Equal(t, []PolicyAuditAction{PolicyAuditCreated, PolicyAuditDeploymentCreated}, auditActions(t, engine, policy.PolicyID))
+}
+
+func latestDeploymentID(t *testing.T, engine *PolicyEngine, policyID string) string {
+	entries, err := engine.store.ListAudit(context.Background(), policyID, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	return entries[0].DeploymentID
+}
+
+func TestPolicyEngine_DeploysNewestDueRelease(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	older := &FirmwareRelease{ReleaseID: "release-1", TemplateID: "template-001", Version: "1.0.0", CreatedAt: now.Add(-30 * 24 * time.Hour)}
+	due := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0", CreatedAt: now.Add(-72 * time.Hour)}
+	pending := &FirmwareRelease{ReleaseID: "release-3", TemplateID: "template-001", Version: "3.0.0", CreatedAt: now.Add(-time.Hour)}
+	engine, mockRepo, _ := setupPolicyEngine(t, fleetDevices(), older, due, pending)
+
+	_, err := engine.CreatePolicy(context.Background(), prodPolicyRequest())
+	require.NoError(t, err)
+
+	decisions, err := engine.EvaluateAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "release-2", decisions[0].ReleaseID)
+	mockRepo.AssertNumberOfCalls(t, "CreateDeployment", 1)
+}
+
+func TestPolicyEngine_PausedPoliciesDoNotDeploy(t *testing.T) {
+	release := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0"}
+	engine, mockRepo, now := setupPolicyEngine(t, fleetDevices(), release)
+	release.CreatedAt = now.Add(-72 * time.Hour)
+	ctx := context.Background()
+
+	policy, err := engine.CreatePolicy(ctx, prodPolicyRequest())
+	require.NoError(t, err)
+
+	_, err = engine.PausePolicy(ctx, policy.PolicyID, &PolicyActionRequest{Actor: "bob", Reason: "holiday freeze"})
+	require.NoError(t, err)
+	_, err = engine.PausePolicy(ctx, policy.PolicyID, &PolicyActionRequest{Actor: "bob"})
+	assert.Error(t, err)
+
+	decisions, err := engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, decisions)
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+
+	resumed, err := engine.ResumePolicy(ctx, policy.PolicyID, &PolicyActionRequest{Actor: "bob"})
+	require.NoError(t, err)
+	assert.False(t, resumed.Paused)
+	assert.Empty(t, resumed.PausedReason)
+
+	decisions, err = engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, decisions, 1)
+
+	entries, err := engine.store.ListAudit(ctx, policy.PolicyID, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, PolicyAuditPaused, entries[2].Action)
+	assert.Equal(t, "bob", entries[2].Actor)
+	assert.Equal(t, "Policy paused: holiday freeze", entries[2].Message)
+}
+
+func TestPolicyEngine_NoMatchingDevicesIsAuditedOnce(t *testing.T) {
+	release := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0"}
+	engine, mockRepo, now := setupPolicyEngine(t, fleetDevices(), release)
+	release.CreatedAt = now.Add(-72 * time.Hour)
+	ctx := context.Background()
+
+	req := prodPolicyRequest()
+	req.Labels = map[string]string{"env": "staging"}
+	policy, err := engine.CreatePolicy(ctx, req)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := engine.EvaluateAll(ctx)
+		require.NoError(t, err)
+	}
+
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+	assert.Equal(t, []PolicyAuditAction{PolicyAuditCreated, PolicyAuditNoMatchingDevices}, auditActions(t, engine, policy.PolicyID))
+}
+
+func TestPolicyEngine_ValidatesPolicies(t *testing.T) {
+	engine, _, _ := setupPolicyEngine(t, nil)
+	ctx := context.Background()
+
+	req := prodPolicyRequest()
+	req.Channel = "nightly"
+	_, err := engine.CreatePolicy(ctx, req)
+	assert.ErrorContains(t, err, "invalid release channel")
+
+	req = prodPolicyRequest()
+	req.Strategy = DeploymentStrategyCanary
+	req.RolloutPercentage = 150
+	_, err = engine.CreatePolicy(ctx, req)
+	assert.ErrorContains(t, err, "rollout percentage")
+
+	req = prodPolicyRequest()
+	req.Strategy = DeploymentStrategyImmediate
+	policy, err := engine.CreatePolicy(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 100, policy.RolloutPercentage)
+
+	_, err = engine.UpdatePolicy(ctx, "missing", prodPolicyRequest())
+	assert.ErrorIs(t, err, ErrPolicyNotFound)
+
+	req = prodPolicyRequest()
+	req.DeviceGroup = "missing"
+	_, err = engine.CreatePolicy(ctx, req)
+	assert.ErrorIs(t, err, ErrInvalidTargets)
+}
+
+func TestPolicyEngine_DeviceGroup(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	release := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0", CreatedAt: now.Add(-72 * time.Hour)}
+	engine, mockRepo, _ := setupPolicyEngine(t, fleetDevices(), release)
+	ctx := context.Background()
+
+	groups := device.NewMemoryGroupStore()
+	require.NoError(t, groups.CreateGroup(ctx, &device.DeviceGroup{Name: "lab", Devices: []string{"prod-2", "dev-1"}}))
+	engine.service.SetDeviceGroups(groups)
+
+	// The group narrows the devices the labels select
+	req := prodPolicyRequest()
+	req.DeviceGroup = "lab"
+	policy, err := engine.CreatePolicy(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "lab", policy.DeviceGroup)
+
+	decisions, err := engine.EvaluateAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+	assert.Equal(t, 1, decisions[0].DeviceCount)
+	mockRepo.AssertCalled(t, "CreateDeployment", mock.Anything, mock.MatchedBy(func(d *OTADeployment) bool {
+		return assert.ObjectsAreEqual([]string{"prod-2"}, d.TargetDevices)
+	}))
+}
+
+func TestPolicyEngine_Routes(t *testing.T) {
+	release := &FirmwareRelease{ReleaseID: "release-2", TemplateID: "template-001", Version: "2.0.0"}
+	engine, _, now := setupPolicyEngine(t, fleetDevices(), release)
+	release.CreatedAt = now.Add(-72 * time.Hour)
+
+	router := gin.New()
+	RegisterPolicyRoutes(router, engine)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/ota/policies", map[string]string{"name": "missing-fields"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/api/v1/ota/policies", prodPolicyRequest())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var policy FleetPolicy
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &policy))
+
+	w = do(http.MethodPost, "/api/v1/ota/policies/evaluate", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	w = do(http.MethodPut, "/api/v1/ota/policies/"+policy.PolicyID+"/pause", PolicyActionRequest{Actor: "bob"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"paused":true`)
+
+	w = do(http.MethodGet, "/api/v1/ota/policies/"+policy.PolicyID+"/audit?limit=2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var audit struct {
+		Entries []PolicyAuditEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &audit))
+	require.Len(t, audit.Entries, 2)
+	assert.Equal(t, PolicyAuditPaused, audit.Entries[0].Action)
+	assert.Equal(t, PolicyAuditDeploymentCreated, audit.Entries[1].Action)
+
+	w = do(http.MethodDelete, "/api/v1/ota/policies/"+policy.PolicyID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodGet, "/api/v1/ota/policies/"+policy.PolicyID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = do(http.MethodGet, "/api/v1/ota/policies/"+policy.PolicyID+"/audit", nil)
+	assert.Contains(t, w.Body.String(), `"count":4`, "the audit trail outlives the policy")
+}
+
+func TestFleetPolicyEntity(t *testing.T) {
+	evaluated := time.Now().UTC().Truncate(time.Second)
+	policy := &FleetPolicy{
+		PolicyID:          "policy-1",
+		Name:              "Greenhouses",
+		TemplateID:        "weather",
+		DeviceGroup:       "greenhouses",
+		Labels:            map[string]string{"site": "greenhouse"},
+		Channel:           ReleaseChannelBeta,
+		DelayHours:        24,
+		Strategy:          DeploymentStrategyCanary,
+		RolloutPercentage: 10,
+		FailureThreshold:  5,
+		Paused:            true,
+		PausedReason:      "maintenance",
+		LastReleaseID:     "release-1",
+		LastDeploymentID:  "deployment-1",
+		LastEvaluatedAt:   &evaluated,
+		CreatedBy:         "alice",
+		CreatedAt:         evaluated.Add(-time.Hour),
+		UpdatedAt:         evaluated,
+	}
+
+	entity, err := policy.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, policy, restored)
+
+	policy.Labels = nil
+	policy.LastEvaluatedAt = nil
+	entity, err = policy.ToEntity()
+	require.NoError(t, err)
+	restored, err = entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, policy, restored)
+
+	entry := &PolicyAuditEntry{EntryID: "entry-1", PolicyID: "policy-1", Action: PolicyAuditDeploymentCreated,
+		Actor: "policy-engine", ReleaseID: "release-1", DeploymentID: "deployment-1", DeviceCount: 3, Message: "deployed", Timestamp: evaluated}
+	assert.Equal(t, entry, entry.ToEntity().FromEntity())
+}
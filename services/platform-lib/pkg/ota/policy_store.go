@@ -0,0 +1,273 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// FleetPolicyEntity represents the Datastore entity for fleet policies
+type FleetPolicyEntity struct {
+	PolicyID          string    `datastore:"policy_id"`
+	Name              string    `datastore:"name"`
+	TemplateID        string    `datastore:"template_id"`
+	DeviceGroup       string    `datastore:"device_group"`
+	LabelsJSON        string    `datastore:"labels_json,noindex"`
+	Channel           string    `datastore:"channel"`
+	DelayHours        int       `datastore:"delay_hours,noindex"`
+	Strategy          string    `datastore:"strategy,noindex"`
+	RolloutPercentage int       `datastore:"rollout_percentage,noindex"`
+	FailureThreshold  int       `datastore:"failure_threshold,noindex"`
+	Paused            bool      `datastore:"paused"`
+	PausedReason      string    `datastore:"paused_reason,noindex"`
+	LastReleaseID     string    `datastore:"last_release_id,noindex"`
+	LastDeploymentID  string    `datastore:"last_deployment_id,noindex"`
+	LastEvaluatedAt   time.Time `datastore:"last_evaluated_at,noindex"`
+	CreatedBy         string    `datastore:"created_by"`
+	CreatedAt         time.Time `datastore:"created_at"`
+	UpdatedAt         time.Time `datastore:"updated_at"`
+}
+
+// PolicyAuditEntryEntity represents the Datastore entity for policy audit
+// entries
+type PolicyAuditEntryEntity struct {
+	EntryID      string    `datastore:"entry_id"`
+	PolicyID     string    `datastore:"policy_id"`
+	Action       string    `datastore:"action"`
+	Actor        string    `datastore:"actor"`
+	ReleaseID    string    `datastore:"release_id,noindex"`
+	DeploymentID string    `datastore:"deployment_id,noindex"`
+	DeviceCount  int       `datastore:"device_count,noindex"`
+	Message      string    `datastore:"message,noindex"`
+	Timestamp    time.Time `datastore:"timestamp"`
+}
+
+// ToEntity converts a FleetPolicy to a FleetPolicyEntity
+func (p *FleetPolicy) ToEntity() (*FleetPolicyEntity, error) {
+	labelsJSON, err := json.Marshal(p.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal labels: %w", err)
+	}
+
+	entity := &FleetPolicyEntity{
+		PolicyID:          p.PolicyID,
+		Name:              p.Name,
+		TemplateID:        p.TemplateID,
+		DeviceGroup:       p.DeviceGroup,
+		LabelsJSON:        string(labelsJSON),
+		Channel:           string(p.Channel),
+		DelayHours:        p.DelayHours,
+		Strategy:          string(p.Strategy),
+		RolloutPercentage: p.RolloutPercentage,
+		FailureThreshold:  p.FailureThreshold,
+		Paused:            p.Paused,
+		PausedReason:      p.PausedReason,
+		LastReleaseID:     p.LastReleaseID,
+		LastDeploymentID:  p.LastDeploymentID,
+		CreatedBy:         p.CreatedBy,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+	if p.LastEvaluatedAt != nil {
+		entity.LastEvaluatedAt = *p.LastEvaluatedAt
+	}
+	return entity, nil
+}
+
+// FromEntity converts a FleetPolicyEntity to a FleetPolicy
+func (e *FleetPolicyEntity) FromEntity() (*FleetPolicy, error) {
+	policy := &FleetPolicy{
+		PolicyID:          e.PolicyID,
+		Name:              e.Name,
+		TemplateID:        e.TemplateID,
+		DeviceGroup:       e.DeviceGroup,
+		Channel:           ReleaseChannel(e.Channel),
+		DelayHours:        e.DelayHours,
+		Strategy:          DeploymentStrategy(e.Strategy),
+		RolloutPercentage: e.RolloutPercentage,
+		FailureThreshold:  e.FailureThreshold,
+		Paused:            e.Paused,
+		PausedReason:      e.PausedReason,
+		LastReleaseID:     e.LastReleaseID,
+		LastDeploymentID:  e.LastDeploymentID,
+		CreatedBy:         e.CreatedBy,
+		CreatedAt:         e.CreatedAt,
+		UpdatedAt:         e.UpdatedAt,
+	}
+	if e.LabelsJSON != "" {
+		if err := json.Unmarshal([]byte(e.LabelsJSON), &policy.Labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+		}
+	}
+	if !e.LastEvaluatedAt.IsZero() {
+		policy.LastEvaluatedAt = &e.LastEvaluatedAt
+	}
+	return policy, nil
+}
+
+// ToEntity converts a PolicyAuditEntry to a PolicyAuditEntryEntity
+func (a *PolicyAuditEntry) ToEntity() *PolicyAuditEntryEntity {
+	return &PolicyAuditEntryEntity{
+		EntryID:      a.EntryID,
+		PolicyID:     a.PolicyID,
+		Action:       string(a.Action),
+		Actor:        a.Actor,
+		ReleaseID:    a.ReleaseID,
+		DeploymentID: a.DeploymentID,
+		DeviceCount:  a.DeviceCount,
+		Message:      a.Message,
+		Timestamp:    a.Timestamp,
+	}
+}
+
+// FromEntity converts a PolicyAuditEntryEntity to a PolicyAuditEntry
+func (e *PolicyAuditEntryEntity) FromEntity() *PolicyAuditEntry {
+	return &PolicyAuditEntry{
+		EntryID:      e.EntryID,
+		PolicyID:     e.PolicyID,
+		Action:       PolicyAuditAction(e.Action),
+		Actor:        e.Actor,
+		ReleaseID:    e.ReleaseID,
+		DeploymentID: e.DeploymentID,
+		DeviceCount:  e.DeviceCount,
+		Message:      e.Message,
+		Timestamp:    e.Timestamp,
+	}
+}
+
+// DatastorePolicyStore implements PolicyStore using Google Cloud Datastore
+type DatastorePolicyStore struct {
+	client *datastore.Client
+}
+
+// NewDatastorePolicyStore creates a new Datastore policy store
+func NewDatastorePolicyStore(client *datastore.Client) *DatastorePolicyStore {
+	return &DatastorePolicyStore{client: client}
+}
+
+// CreatePolicy stores a new policy
+func (s *DatastorePolicyStore) CreatePolicy(ctx context.Context, policy *FleetPolicy) error {
+	entity, err := policy.ToEntity()
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NameKey("FleetPolicy", policy.PolicyID, nil)
+	if _, err := s.client.Put(ctx, key, entity); err != nil {
+		return fmt.Errorf("failed to store fleet policy in Datastore: %w", err)
+	}
+	return nil
+}
+
+// GetPolicy retrieves a policy by ID
+func (s *DatastorePolicyStore) GetPolicy(ctx context.Context, policyID string) (*FleetPolicy, error) {
+	key := datastore.NameKey("FleetPolicy", policyID, nil)
+
+	var entity FleetPolicyEntity
+	if err := s.client.Get(ctx, key, &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, ErrPolicyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve fleet policy from Datastore: %w", err)
+	}
+	return entity.FromEntity()
+}
+
+// UpdatePolicy replaces a stored policy
+func (s *DatastorePolicyStore) UpdatePolicy(ctx context.Context, policy *FleetPolicy) error {
+	entity, err := policy.ToEntity()
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NameKey("FleetPolicy", policy.PolicyID, nil)
+	_, err = s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var existing FleetPolicyEntity
+		switch err := tx.Get(key, &existing); err {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			return ErrPolicyNotFound
+		default:
+			return fmt.Errorf("failed to retrieve fleet policy from Datastore: %w", err)
+		}
+		_, err := tx.Put(key, entity)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update fleet policy in Datastore: %w", err)
+	}
+	return nil
+}
+
+// DeletePolicy removes a policy. Its audit trail is kept.
+func (s *DatastorePolicyStore) DeletePolicy(ctx context.Context, policyID string) error {
+	key := datastore.NameKey("FleetPolicy", policyID, nil)
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var existing FleetPolicyEntity
+		switch err := tx.Get(key, &existing); err {
+		case nil:
+		case datastore.ErrNoSuchEntity:
+			return ErrPolicyNotFound
+		default:
+			return fmt.Errorf("failed to retrieve fleet policy from Datastore: %w", err)
+		}
+		return tx.Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete fleet policy from Datastore: %w", err)
+	}
+	return nil
+}
+
+// ListPolicies returns all policies ordered by creation time
+func (s *DatastorePolicyStore) ListPolicies(ctx context.Context) ([]*FleetPolicy, error) {
+	query := datastore.NewQuery("FleetPolicy").Order("created_at")
+
+	var entities []FleetPolicyEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query fleet policies from Datastore: %w", err)
+	}
+
+	policies := make([]*FleetPolicy, 0, len(entities))
+	for i := range entities {
+		policy, err := entities[i].FromEntity()
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// AppendAudit adds an entry to a policy's audit trail
+func (s *DatastorePolicyStore) AppendAudit(ctx context.Context, entry *PolicyAuditEntry) error {
+	key := datastore.NameKey("FleetPolicyAudit", entry.EntryID, nil)
+	if _, err := s.client.Put(ctx, key, entry.ToEntity()); err != nil {
+		return fmt.Errorf("failed to store policy audit entry in Datastore: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns a policy's audit entries, newest first
+func (s *DatastorePolicyStore) ListAudit(ctx context.Context, policyID string, limit int) ([]*PolicyAuditEntry, error) {
+	query := datastore.NewQuery("FleetPolicyAudit").
+		Filter("policy_id =", policyID).
+		Order("-timestamp")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var entities []PolicyAuditEntryEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query policy audit entries from Datastore: %w", err)
+	}
+
+	entries := make([]*PolicyAuditEntry, 0, len(entities))
+	for i := range entities {
+		entries = append(entries, entities[i].FromEntity())
+	}
+	return entries, nil
+}
@@ -0,0 +1,312 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// defaultPromotionSuccess is the success percentage a rollout needs before
+// promotion when promotion settings leave it unset
+const defaultPromotionSuccess = 95
+
+// errNoPromotion is returned from a deployment modification when the
+// deployment is no longer ready for its next promotion tier
+var errNoPromotion = errors.New("deployment not ready for promotion")
+
+// validatePromotion checks the promotion tiers and success percentage of a
+// deployment configuration and fills in their defaults
+func (s *Service) validatePromotion(config *DeploymentConfig) error {
+	rollout := config.Strategy == DeploymentStrategyStaged || config.Strategy == DeploymentStrategyCanary
+	if !rollout {
+		if len(config.PromotionTiers) > 0 {
+			return fmt.Errorf("promotion tiers need a staged or canary strategy")
+		}
+		return nil
+	}
+
+	if config.PromotionTiers == nil && config.Strategy == DeploymentStrategyCanary && s.config != nil {
+		for _, tier := range s.config.Promotion.CanaryTiers {
+			if tier > config.RolloutPercentage {
+				config.PromotionTiers = append(config.PromotionTiers, tier)
+			}
+		}
+	}
+
+	previous := config.RolloutPercentage
+	for _, tier := range config.PromotionTiers {
+		if tier <= previous || tier > 100 {
+			return fmt.Errorf("promotion tiers must increase from the rollout percentage up to 100")
+		}
+		previous = tier
+	}
+
+	if config.PromotionSuccess < 0 || config.PromotionSuccess > 100 {
+		return fmt.Errorf("promotion success percentage must be between 1 and 100")
+	}
+	if config.PromotionSuccess == 0 && len(config.PromotionTiers) > 0 {
+		config.PromotionSuccess = defaultPromotionSuccess
+		if s.config != nil && s.config.Promotion.SuccessPercentage > 0 {
+			config.PromotionSuccess = s.config.Promotion.SuccessPercentage
+		}
+	}
+	return nil
+}
+
+// rolloutSize is the number of devices a rollout percentage covers, at
+// least one
+func rolloutSize(totalDevices, percentage int) int {
+	size := (totalDevices * percentage) / 100
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// nextTier returns the rollout percentage the deployment is promoted to
+// next, or 0 when it has no tiers left
+func (d *OTADeployment) nextTier() int {
+	for _, tier := range d.PromotionTiers {
+		if tier > d.RolloutPercentage {
+			return tier
+		}
+	}
+	return 0
+}
+
+// rollingOut reports whether a deployment is offering updates to its
+// devices. Staged and canary deployments roll out while still pending.
+func (d *OTADeployment) rollingOut() bool {
+	switch d.Status {
+	case DeploymentStatusActive:
+		return true
+	case DeploymentStatusPending:
+		return d.issuesUpdates()
+	default:
+		return false
+	}
+}
+
+// promotionDue reports whether enough of the devices updated so far have
+// succeeded for the deployment to move to its next tier
+func (d *OTADeployment) promotionDue(successCount, cohortSize int) bool {
+	if !d.rollingOut() || d.nextTier() == 0 || cohortSize == 0 {
+		return false
+	}
+	return successCount*100 >= d.PromotionSuccess*cohortSize
+}
+
+// PromoteDeployment expands a rolling out deployment to its next promotion
+// tier when its current cohort has reached the success percentage. It
+// returns nil when the deployment is not ready.
+func (s *Service) PromoteDeployment(ctx context.Context, deploymentID string) (*Promotion, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	updates, err := s.repository.ListDeviceUpdates(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device updates: %w", err)
+	}
+
+	successCount := 0
+	cohort := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		cohort[update.DeviceID] = true
+		if update.Status == UpdateStatusCompleted {
+			successCount++
+		}
+	}
+	if !deployment.promotionDue(successCount, len(updates)) {
+		return nil, nil
+	}
+
+	// Devices of the next tier that have no update yet
+	from, to := deployment.RolloutPercentage, deployment.nextTier()
+	remaining := cohortAdditions(deployment, cohort, to)
+
+	promotion := &Promotion{
+		FromPercentage:    from,
+		ToPercentage:      to,
+		SuccessPercentage: float64(successCount) * 100 / float64(len(updates)),
+		DevicesAdded:      len(remaining),
+		PromotedAt:        time.Now(),
+	}
+
+	// Claim the promotion, as an operator or another replica may have acted
+	deployment, err = s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		if !d.rollingOut() || d.RolloutPercentage != from {
+			return errNoPromotion
+		}
+		d.RolloutPercentage = to
+		d.Promotions = append(d.Promotions, promotion)
+		d.UpdatedAt = promotion.PromotedAt
+		return nil
+	})
+	if errors.Is(err, errNoPromotion) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote deployment: %w", err)
+	}
+
+	s.addToCohort(ctx, deployment, remaining, promotion.PromotedAt)
+
+	s.logger.Info("Promoted deployment", "deployment_id", deploymentID, "from_percentage", from, "to_percentage", to,
+		"success_percentage", promotion.SuccessPercentage, "devices_added", promotion.DevicesAdded)
+
+	return promotion, nil
+}
+
+// cohortAdditions returns the target devices without an update that grow
+// the deployment's cohort to percentage of its targets. Canary deployments
+// pick them at random.
+func cohortAdditions(deployment *OTADeployment, cohort map[string]bool, percentage int) []string {
+	var remaining []string
+	for _, deviceID := range deployment.TargetDevices {
+		if !cohort[deviceID] {
+			remaining = append(remaining, deviceID)
+		}
+	}
+	if deployment.Strategy == DeploymentStrategyCanary {
+		rand.Shuffle(len(remaining), func(i, j int) {
+			remaining[i], remaining[j] = remaining[j], remaining[i]
+		})
+	}
+	added := rolloutSize(len(deployment.TargetDevices), percentage) - len(cohort)
+	if added < 0 {
+		added = 0
+	}
+	if added > len(remaining) {
+		added = len(remaining)
+	}
+	return remaining[:added]
+}
+
+// addToCohort creates the updates of devices joining a deployment's cohort
+func (s *Service) addToCohort(ctx context.Context, deployment *OTADeployment, deviceIDs []string, startedAt time.Time) {
+	for _, deviceID := range deviceIDs {
+		update := &DeviceUpdate{
+			DeviceID:     deviceID,
+			ReleaseID:    deployment.ReleaseID,
+			DeploymentID: deployment.DeploymentID,
+			Status:       UpdateStatusPending,
+			Progress:     0,
+			Attempts:     1,
+			StartedAt:    startedAt,
+		}
+		if err := s.repository.CreateDeviceUpdate(ctx, update); err != nil {
+			s.logger.Warn("Failed to create device update", "device_id", deviceID, "error", err)
+		}
+	}
+}
+
+// PromotionReport lists the deployments one promoter pass expanded, and
+// those whose rolling targets changed
+type PromotionReport struct {
+	Promoted []string `json:"promoted"`
+	Resolved []string `json:"resolved"`
+}
+
+// DeploymentPromoter expands canary and staged deployments tier by tier as
+// their devices update successfully, and resolves the groups and labels of
+// deployments with rolling targets again
+type DeploymentPromoter struct {
+	service *Service
+	logger  *logger.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewDeploymentPromoter creates a promoter for the deployments of service
+func NewDeploymentPromoter(service *Service, logger *logger.Logger) *DeploymentPromoter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DeploymentPromoter{
+		service: service,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start promotes deployments every interval until Stop is called
+func (p *DeploymentPromoter) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	p.wg.Add(1)
+	go p.promoteLoop(interval)
+	p.logger.Info("Deployment promoter started", "interval", interval)
+}
+
+// Stop stops the promoter
+func (p *DeploymentPromoter) Stop() {
+	p.cancel()
+	p.wg.Wait()
+	p.logger.Info("Deployment promoter stopped")
+}
+
+func (p *DeploymentPromoter) promoteLoop(interval time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Run(p.ctx); err != nil {
+				p.logger.Error("Deployment promotion pass failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run resolves the targets of every rolling out deployment with rolling
+// targets, then promotes those ready for their next tier
+func (p *DeploymentPromoter) Run(ctx context.Context) (*PromotionReport, error) {
+	if p.service.repository == nil {
+		return nil, fmt.Errorf("deployment repository not configured")
+	}
+	deployments, err := p.service.repository.ListDeployments(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	report := &PromotionReport{Promoted: []string{}, Resolved: []string{}}
+	for _, deployment := range deployments {
+		if !deployment.rollingOut() {
+			continue
+		}
+		if deployment.RollingTargets {
+			resolution, err := p.service.ResolveDeploymentTargets(ctx, deployment.DeploymentID)
+			if err != nil {
+				p.logger.Error("Failed to resolve deployment targets", "deployment_id", deployment.DeploymentID, "error", err)
+			} else if resolution.changed() {
+				report.Resolved = append(report.Resolved, deployment.DeploymentID)
+			}
+		}
+		if deployment.nextTier() == 0 {
+			continue
+		}
+		promotion, err := p.service.PromoteDeployment(ctx, deployment.DeploymentID)
+		if err != nil {
+			p.logger.Error("Failed to promote deployment", "deployment_id", deployment.DeploymentID, "error", err)
+			continue
+		}
+		if promotion != nil {
+			report.Promoted = append(report.Promoted, deployment.DeploymentID)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,111 @@
+package ota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ValidatePromotion(t *testing.T) {
+	service, _, _, _ := setupTestService()
+	service.config = &config.Config{Promotion: config.PromotionConfig{SuccessPercentage: 90, CanaryTiers: []int{25, 100}}}
+
+	// Canary deployments default to the configured tiers above their rollout
+	canary := &DeploymentConfig{Strategy: DeploymentStrategyCanary, RolloutPercentage: 30}
+	require.NoError(t, service.validatePromotion(canary))
+	assert.Equal(t, []int{100}, canary.PromotionTiers)
+	assert.Equal(t, 90, canary.PromotionSuccess)
+
+	// Staged deployments promote only with explicit tiers
+	staged := &DeploymentConfig{Strategy: DeploymentStrategyStaged, RolloutPercentage: 10}
+	require.NoError(t, service.validatePromotion(staged))
+	assert.Empty(t, staged.PromotionTiers)
+	assert.Zero(t, staged.PromotionSuccess)
+
+	for _, invalid := range []*DeploymentConfig{
+		{Strategy: DeploymentStrategyImmediate, PromotionTiers: []int{100}},
+		{Strategy: DeploymentStrategyStaged, RolloutPercentage: 10, PromotionTiers: []int{50, 25}},
+		{Strategy: DeploymentStrategyStaged, RolloutPercentage: 10, PromotionTiers: []int{10, 100}},
+		{Strategy: DeploymentStrategyStaged, RolloutPercentage: 10, PromotionTiers: []int{150}},
+		{Strategy: DeploymentStrategyStaged, RolloutPercentage: 10, PromotionTiers: []int{100}, PromotionSuccess: 101},
+	} {
+		assert.Error(t, service.validatePromotion(invalid))
+	}
+}
+
+func TestService_PromoteDeployment(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	ctx := context.Background()
+
+	targets := []string{"device-001", "device-002", "device-003", "device-004", "device-005",
+		"device-006", "device-007", "device-008", "device-009", "device-010"}
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001", ReleaseID: "release-001", Strategy: DeploymentStrategyCanary,
+		Status: DeploymentStatusPending, TargetDevices: targets, RolloutPercentage: 20,
+		PromotionTiers: []int{50, 100}, PromotionSuccess: 90,
+	}
+	cohort := []*DeviceUpdate{
+		{DeviceID: "device-003", DeploymentID: "deployment-001", Status: UpdateStatusCompleted},
+		{DeviceID: "device-007", DeploymentID: "deployment-001", Status: UpdateStatusInstalling},
+	}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("ListDeviceUpdates", mock.Anything, "deployment-001").Return(cohort, nil)
+
+	// Half of the canary cohort is not enough
+	promotion, err := service.PromoteDeployment(ctx, "deployment-001")
+	require.NoError(t, err)
+	assert.Nil(t, promotion)
+	assert.Equal(t, 20, deployment.RolloutPercentage)
+
+	cohort[1].Status = UpdateStatusCompleted
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	var created []string
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Run(func(args mock.Arguments) {
+		update := args.Get(1).(*DeviceUpdate)
+		assert.Equal(t, "release-001", update.ReleaseID)
+		assert.Equal(t, UpdateStatusPending, update.Status)
+		created = append(created, update.DeviceID)
+	}).Return(nil)
+
+	promotion, err = service.PromoteDeployment(ctx, "deployment-001")
+	require.NoError(t, err)
+	require.NotNil(t, promotion)
+	assert.Equal(t, 20, promotion.FromPercentage)
+	assert.Equal(t, 50, promotion.ToPercentage)
+	assert.Equal(t, float64(100), promotion.SuccessPercentage)
+	assert.Equal(t, 3, promotion.DevicesAdded)
+	assert.Equal(t, 50, deployment.RolloutPercentage)
+	assert.Equal(t, []*Promotion{promotion}, deployment.Promotions)
+
+	// Only devices outside the canary cohort are added
+	require.Len(t, created, 3)
+	assert.NotContains(t, created, "device-003")
+	assert.NotContains(t, created, "device-007")
+}
+
+// A cohort that meets the success percentage is not completed while
+// promotion tiers remain
+func TestService_UpdateDeploymentStats_AwaitsPromotion(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001", Strategy: DeploymentStrategyStaged, Status: DeploymentStatusPending,
+		RolloutPercentage: 10, PromotionTiers: []int{100}, PromotionSuccess: 95,
+	}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(2, 0, 0, nil).Once()
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+
+	require.NoError(t, service.updateDeploymentStats(context.Background(), "deployment-001"))
+	assert.Equal(t, DeploymentStatusPending, deployment.Status)
+
+	// A failing cohort finishes the deployment without promotion
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(1, 1, 0, nil).Once()
+	require.NoError(t, service.updateDeploymentStats(context.Background(), "deployment-001"))
+	assert.Equal(t, DeploymentStatusCompleted, deployment.Status)
+}
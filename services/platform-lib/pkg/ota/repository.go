@@ -11,6 +11,7 @@ type Repository interface {
 	GetRelease(ctx context.Context, releaseID string) (*FirmwareRelease, error)
 	GetReleaseByVersion(ctx context.Context, templateID, version string, channel ReleaseChannel) (*FirmwareRelease, error)
 	ListReleases(ctx context.Context, templateID string, channel ReleaseChannel) ([]*FirmwareRelease, error)
+	UpdateRelease(ctx context.Context, release *FirmwareRelease) error
 	DeleteRelease(ctx context.Context, releaseID string) error
 	ReleaseExists(ctx context.Context, releaseID string) (bool, error)
 
@@ -18,6 +19,7 @@ type Repository interface {
 	CreateDeployment(ctx context.Context, deployment *OTADeployment) error
 	GetDeployment(ctx context.Context, deploymentID string) (*OTADeployment, error)
 	UpdateDeployment(ctx context.Context, deployment *OTADeployment) error
+	ModifyDeployment(ctx context.Context, deploymentID string, modify func(*OTADeployment) error) (*OTADeployment, error)
 	ListDeployments(ctx context.Context, releaseID string) ([]*OTADeployment, error)
 	GetActiveDeployments(ctx context.Context) ([]*OTADeployment, error)
 
@@ -25,9 +27,25 @@ type Repository interface {
 	CreateDeviceUpdate(ctx context.Context, update *DeviceUpdate) error
 	GetDeviceUpdate(ctx context.Context, deviceID, releaseID string) (*DeviceUpdate, error)
 	UpdateDeviceUpdate(ctx context.Context, update *DeviceUpdate) error
+	ModifyDeviceUpdate(ctx context.Context, deviceID, releaseID string, modify func(*DeviceUpdate) error) (*DeviceUpdate, error)
 	ListDeviceUpdates(ctx context.Context, deploymentID string) ([]*DeviceUpdate, error)
+	QueryDeviceUpdates(ctx context.Context, deploymentID string, query *DeviceUpdateQuery) (updates []*DeviceUpdate, nextCursor string, err error)
+	CountDeviceUpdatesByStatus(ctx context.Context, deploymentID string) (map[UpdateStatus]int, error)
 	GetDeviceUpdatesByStatus(ctx context.Context, deploymentID string, status UpdateStatus) ([]*DeviceUpdate, error)
 	GetLatestUpdateForDevice(ctx context.Context, deviceID string) (*DeviceUpdate, error)
+	ListUpdatesForDevice(ctx context.Context, deviceID string, limit int) ([]*DeviceUpdate, error)
+
+	// Crash report operations. Listings are newest first.
+	CreateCrashReport(ctx context.Context, report *CrashReport) error
+	ListCrashReportsForDevice(ctx context.Context, deviceID string, limit int) ([]*CrashReport, error)
+	ListCrashReportsForDeployment(ctx context.Context, deploymentID string) ([]*CrashReport, error)
+
+	// Webhook operations
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, webhookID string) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, webhookID string) error
 
 	// Query operations
 	GetDeploymentStats(ctx context.Context, deploymentID string) (successCount, failureCount, pendingCount int, err error)
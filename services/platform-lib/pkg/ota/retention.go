@@ -0,0 +1,248 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionDecision says whether retention keeps or deletes a release, and
+// why
+type RetentionDecision struct {
+	ReleaseID  string         `json:"release_id"`
+	TemplateID string         `json:"template_id"`
+	Version    string         `json:"version"`
+	Channel    ReleaseChannel `json:"channel"`
+	BinarySize int64          `json:"binary_size"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Reason     string         `json:"reason"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// RetentionReport lists the releases a retention pass keeps and deletes.
+// In a dry run nothing is deleted.
+type RetentionReport struct {
+	DryRun     bool                 `json:"dry_run"`
+	Delete     []*RetentionDecision `json:"delete"`
+	Keep       []*RetentionDecision `json:"keep"`
+	FreedBytes int64                `json:"freed_bytes"`
+}
+
+// inProgress reports whether a deployment may still hand its release to
+// devices
+func (d *OTADeployment) inProgress() bool {
+	switch d.Status {
+	case DeploymentStatusPending, DeploymentStatusPendingApproval, DeploymentStatusActive, DeploymentStatusPaused:
+		return true
+	}
+	return false
+}
+
+// retentionRule returns the first rule matching a release, if any
+func retentionRule(rules []config.RetentionRuleConfig, release *FirmwareRelease) *config.RetentionRuleConfig {
+	for i := range rules {
+		rule := &rules[i]
+		if (rule.TemplateID == "" || rule.TemplateID == release.TemplateID) &&
+			(rule.Channel == "" || rule.Channel == string(release.Channel)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// PlanRetention decides which releases the retention rules keep and which
+// they delete, without deleting anything
+func (s *Service) PlanRetention(ctx context.Context, now time.Time) (*RetentionReport, error) {
+	releases, err := s.repository.ListReleases(ctx, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	deployments, err := s.repository.ListDeployments(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	referenced := make(map[string]string)
+	for _, deployment := range deployments {
+		if deployment.inProgress() {
+			referenced[deployment.ReleaseID] = deployment.DeploymentID
+		}
+	}
+
+	var rules []config.RetentionRuleConfig
+	if s.config != nil {
+		rules = s.config.Retention.Rules
+	}
+
+	// Releases are ranked by age within their template and channel
+	groups := make(map[string][]*FirmwareRelease)
+	for _, release := range releases {
+		key := release.TemplateID + "/" + string(release.Channel)
+		groups[key] = append(groups[key], release)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	report := &RetentionReport{DryRun: true, Delete: []*RetentionDecision{}, Keep: []*RetentionDecision{}}
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].CreatedAt.After(group[j].CreatedAt) })
+
+		for rank, release := range group {
+			decision := &RetentionDecision{
+				ReleaseID:  release.ReleaseID,
+				TemplateID: release.TemplateID,
+				Version:    release.Version,
+				Channel:    release.Channel,
+				BinarySize: release.BinarySize,
+				CreatedAt:  release.CreatedAt,
+			}
+			keep := true
+			rule := retentionRule(rules, release)
+			switch {
+			case referenced[release.ReleaseID] != "":
+				decision.Reason = fmt.Sprintf("used by deployment %s", referenced[release.ReleaseID])
+			case rule == nil:
+				decision.Reason = "no retention rule"
+			case rule.KeepLast <= 0 && rule.KeepDays <= 0:
+				decision.Reason = "retention rule keeps all releases"
+			case rank < rule.KeepLast:
+				decision.Reason = fmt.Sprintf("among the %d newest", rule.KeepLast)
+			case rule.KeepDays > 0 && now.Sub(release.CreatedAt) < time.Duration(rule.KeepDays)*24*time.Hour:
+				decision.Reason = fmt.Sprintf("younger than %d days", rule.KeepDays)
+			default:
+				keep = false
+				decision.Reason = retentionDeleteReason(rule)
+			}
+
+			if keep {
+				report.Keep = append(report.Keep, decision)
+			} else {
+				report.Delete = append(report.Delete, decision)
+				report.FreedBytes += release.BinarySize
+			}
+		}
+	}
+	return report, nil
+}
+
+// retentionDeleteReason explains why a rule lets a release go
+func retentionDeleteReason(rule *config.RetentionRuleConfig) string {
+	switch {
+	case rule.KeepLast > 0 && rule.KeepDays > 0:
+		return fmt.Sprintf("not among the %d newest and older than %d days", rule.KeepLast, rule.KeepDays)
+	case rule.KeepLast > 0:
+		return fmt.Sprintf("not among the %d newest", rule.KeepLast)
+	default:
+		return fmt.Sprintf("older than %d days", rule.KeepDays)
+	}
+}
+
+// ReleaseSweeper periodically deletes the releases, and their binaries,
+// that no retention rule keeps
+type ReleaseSweeper struct {
+	service *Service
+	logger  *logger.Logger
+	now     func() time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewReleaseSweeper creates a sweeper for the releases of service
+func NewReleaseSweeper(service *Service, logger *logger.Logger) *ReleaseSweeper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReleaseSweeper{
+		service: service,
+		logger:  logger,
+		now:     time.Now,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start sweeps releases every interval until Stop is called. It does
+// nothing unless retention is enabled.
+func (r *ReleaseSweeper) Start(interval time.Duration) {
+	if r.service.config == nil || !r.service.config.Retention.Enabled {
+		r.logger.Info("Release retention disabled")
+		return
+	}
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	r.wg.Add(1)
+	go r.sweepLoop(interval)
+	r.logger.Info("Release sweeper started", "interval", interval, "rules", len(r.service.config.Retention.Rules))
+}
+
+// Stop stops the sweeper
+func (r *ReleaseSweeper) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	r.logger.Info("Release sweeper stopped")
+}
+
+func (r *ReleaseSweeper) sweepLoop(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Run(r.ctx); err != nil {
+				r.logger.Error("Release sweep failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run deletes the releases no retention rule keeps once. A release that
+// fails to delete is reported with its error and tried again next sweep.
+func (r *ReleaseSweeper) Run(ctx context.Context) (*RetentionReport, error) {
+	report, err := r.service.PlanRetention(ctx, r.now())
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = false
+	report.FreedBytes = 0
+
+	for _, decision := range report.Delete {
+		if err := r.service.DeleteRelease(ctx, decision.ReleaseID); err != nil {
+			decision.Error = err.Error()
+			r.logger.Error("Failed to delete expired release", "release_id", decision.ReleaseID, "error", err)
+			continue
+		}
+		report.FreedBytes += decision.BinarySize
+		r.logger.Info("Deleted expired release", "release_id", decision.ReleaseID, "template_id", decision.TemplateID, "version", decision.Version, "reason", decision.Reason)
+	}
+
+	if len(report.Delete) > 0 {
+		r.logger.Info("Swept firmware releases", "deleted", len(report.Delete), "freed_bytes", report.FreedBytes)
+	}
+	return report, nil
+}
+
+func (s *Service) previewRetentionHandler(c *gin.Context) {
+	report, err := s.PlanRetention(c.Request.Context(), time.Now())
+	if err != nil {
+		s.logger.Error("Failed to plan release retention", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,140 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func retentionReleases(now time.Time) []*FirmwareRelease {
+	release := func(id, templateID string, channel ReleaseChannel, age time.Duration) *FirmwareRelease {
+		return &FirmwareRelease{
+			ReleaseID:  id,
+			TemplateID: templateID,
+			Version:    id,
+			Channel:    channel,
+			BinaryPath: "releases/" + id + ".bin",
+			BinarySize: 1000,
+			CreatedAt:  now.Add(-age),
+		}
+	}
+	day := 24 * time.Hour
+	return []*FirmwareRelease{
+		release("stable-4", "sensor", ReleaseChannelStable, 1*day),
+		release("stable-3", "sensor", ReleaseChannelStable, 60*day),
+		release("stable-2", "sensor", ReleaseChannelStable, 100*day),
+		release("stable-1", "sensor", ReleaseChannelStable, 200*day),
+		release("beta-2", "sensor", ReleaseChannelBeta, 10*day),
+		release("beta-1", "sensor", ReleaseChannelBeta, 40*day),
+		release("relay-1", "relay", ReleaseChannelStable, 400*day),
+	}
+}
+
+func setupRetentionTestService(now time.Time) (*Service, *MockRepository, *MockStorageBackend) {
+	service, mockRepo, _, mockStorage := setupDeploymentTestService()
+	service.config.Retention = config.RetentionConfig{Rules: []config.RetentionRuleConfig{
+		{TemplateID: "sensor", Channel: "stable", KeepLast: 1, KeepDays: 90},
+		{TemplateID: "sensor", KeepLast: 1},
+	}}
+
+	mockRepo.On("ListReleases", mock.Anything, "", ReleaseChannel("")).Return(retentionReleases(now), nil)
+	mockRepo.On("ListDeployments", mock.Anything, "").Return([]*OTADeployment{
+		{DeploymentID: "deployment-1", ReleaseID: "stable-1", Status: DeploymentStatusPaused},
+		{DeploymentID: "deployment-2", ReleaseID: "stable-2", Status: DeploymentStatusCompleted},
+	}, nil)
+	return service, mockRepo, mockStorage
+}
+
+func decisionIDs(decisions []*RetentionDecision) []string {
+	ids := make([]string, 0, len(decisions))
+	for _, decision := range decisions {
+		ids = append(ids, decision.ReleaseID)
+	}
+	return ids
+}
+
+func TestService_PlanRetention(t *testing.T) {
+	now := time.Now()
+	service, _, _ := setupRetentionTestService(now)
+
+	report, err := service.PlanRetention(context.Background(), now)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+
+	// stable-3 is younger than 90 days, stable-1 is still being deployed and
+	// relay releases have no rule; stable-2 only had a completed deployment
+	assert.ElementsMatch(t, []string{"stable-2", "beta-1"}, decisionIDs(report.Delete))
+	assert.ElementsMatch(t, []string{"stable-4", "stable-3", "stable-1", "beta-2", "relay-1"}, decisionIDs(report.Keep))
+	assert.Equal(t, int64(2000), report.FreedBytes)
+
+	reasons := make(map[string]string)
+	for _, decision := range append(report.Keep, report.Delete...) {
+		reasons[decision.ReleaseID] = decision.Reason
+	}
+	assert.Equal(t, "among the 1 newest", reasons["stable-4"])
+	assert.Equal(t, "younger than 90 days", reasons["stable-3"])
+	assert.Equal(t, "used by deployment deployment-1", reasons["stable-1"])
+	assert.Equal(t, "no retention rule", reasons["relay-1"])
+	assert.Equal(t, "not among the 1 newest and older than 90 days", reasons["stable-2"])
+	assert.Equal(t, "not among the 1 newest", reasons["beta-1"])
+}
+
+func TestReleaseSweeper_Run(t *testing.T) {
+	now := time.Now()
+	service, mockRepo, mockStorage := setupRetentionTestService(now)
+	releases := retentionReleases(now)
+	for _, release := range releases {
+		mockRepo.On("GetRelease", mock.Anything, release.ReleaseID).Return(release, nil).Maybe()
+	}
+	mockStorage.On("DeleteBinary", mock.Anything, "releases/stable-2.bin").Return(nil)
+	mockStorage.On("DeleteBinary", mock.Anything, "releases/beta-1.bin").Return(nil)
+	mockRepo.On("DeleteRelease", mock.Anything, "stable-2").Return(nil)
+	mockRepo.On("DeleteRelease", mock.Anything, "beta-1").Return(assert.AnError)
+
+	sweeper := NewReleaseSweeper(service, logger.New("info", "test"))
+	sweeper.now = func() time.Time { return now }
+	report, err := sweeper.Run(context.Background())
+	require.NoError(t, err)
+
+	// A release that fails to delete is reported and not counted as freed
+	assert.False(t, report.DryRun)
+	assert.ElementsMatch(t, []string{"stable-2", "beta-1"}, decisionIDs(report.Delete))
+	assert.Equal(t, int64(1000), report.FreedBytes)
+	for _, decision := range report.Delete {
+		if decision.ReleaseID == "beta-1" {
+			assert.NotEmpty(t, decision.Error)
+		} else {
+			assert.Empty(t, decision.Error)
+		}
+	}
+	mockStorage.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "DeleteRelease", mock.Anything, "stable-1")
+}
+
+func TestService_PreviewRetentionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	service, _, mockStorage := setupRetentionTestService(now)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/retention/preview", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report RetentionReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.DryRun)
+	assert.Len(t, report.Delete, 2)
+	mockStorage.AssertNotCalled(t, "DeleteBinary", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,220 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRetryBackoff caps the wait before an attempt as the backoff doubles
+const maxRetryBackoff = 24 * time.Hour
+
+var updateErrorTypes = []UpdateErrorType{
+	UpdateErrorDownload,
+	UpdateErrorVerification,
+	UpdateErrorInstall,
+	UpdateErrorBoot,
+	UpdateErrorOther,
+	UpdateErrorTimeout,
+}
+
+// updateErrorCodeTypes maps each error code to the type it belongs to
+var updateErrorCodeTypes = map[UpdateErrorCode]UpdateErrorType{
+	UpdateErrorCodeDownloadTimeout:   UpdateErrorDownload,
+	UpdateErrorCodeHashMismatch:      UpdateErrorVerification,
+	UpdateErrorCodeSignatureInvalid:  UpdateErrorVerification,
+	UpdateErrorCodeFlashWriteError:   UpdateErrorInstall,
+	UpdateErrorCodeInsufficientSpace: UpdateErrorInstall,
+	UpdateErrorCodeBootVerifyFailed:  UpdateErrorBoot,
+}
+
+func isUpdateErrorType(errorType UpdateErrorType) bool {
+	for _, known := range updateErrorTypes {
+		if errorType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRetryPolicy checks a deployment's retry policy; none is valid
+func validateRetryPolicy(policy *RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts cannot be negative")
+	}
+	if policy.BackoffSeconds < 0 {
+		return fmt.Errorf("retry backoff_seconds cannot be negative")
+	}
+	for _, errorType := range policy.ErrorTypes {
+		if !isUpdateErrorType(errorType) {
+			return fmt.Errorf("unknown retry error type %q", errorType)
+		}
+	}
+	return nil
+}
+
+// allows reports whether an update that just failed may be tried again
+func (p *RetryPolicy) allows(update *DeviceUpdate) bool {
+	if p == nil || update.attempts() >= p.MaxAttempts {
+		return false
+	}
+	if len(p.ErrorTypes) == 0 {
+		return true
+	}
+	for _, errorType := range p.ErrorTypes {
+		if update.ErrorType == errorType {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the given attempt, doubling from the
+// second attempt on
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait := time.Duration(p.BackoffSeconds) * time.Second
+	for i := 2; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	return wait
+}
+
+// attempts returns the number of tries of an update so far. Updates
+// created before attempts were counted are on their first.
+func (u *DeviceUpdate) attempts() int {
+	if u.Attempts < 1 {
+		return 1
+	}
+	return u.Attempts
+}
+
+// due reports whether an update is waiting to be offered to its device
+func (u *DeviceUpdate) due(now time.Time) bool {
+	return u.Status == UpdateStatusPending && (u.NextAttemptAt == nil || !u.NextAttemptAt.After(now))
+}
+
+// restart puts an update back to pending for another attempt from at
+func (u *DeviceUpdate) restart(at time.Time) {
+	u.Attempts = u.attempts() + 1
+	u.Status = UpdateStatusPending
+	u.Progress = 0
+	u.Downloaded = 0
+	u.StartedAt = at
+	u.CompletedAt = nil
+	u.NextAttemptAt = &at
+}
+
+// scheduleRetry puts a failed update back to pending when its deployment's
+// retry policy allows another attempt, and reports whether it did
+func (s *Service) scheduleRetry(ctx context.Context, update *DeviceUpdate) bool {
+	deployment, err := s.repository.GetDeployment(ctx, update.DeploymentID)
+	if err != nil {
+		s.logger.Warn("Failed to get deployment for update retry", "deployment_id", update.DeploymentID, "error", err)
+		return false
+	}
+	if deployment.Status == DeploymentStatusCancelled || !deployment.RetryPolicy.allows(update) {
+		return false
+	}
+
+	attempt := update.attempts() + 1
+	update.restart(time.Now().Add(deployment.RetryPolicy.backoff(attempt)))
+	s.logger.Info("Scheduled device update retry", "device_id", update.DeviceID, "deployment_id", update.DeploymentID, "attempt", attempt, "next_attempt_at", update.NextAttemptAt)
+	return true
+}
+
+// RetryFailedResult reports the device updates a manual retry restarted
+type RetryFailedResult struct {
+	DeploymentID string   `json:"deployment_id"`
+	Retried      int      `json:"retried"`
+	Devices      []string `json:"devices"`
+}
+
+// RetryFailedUpdates offers every failed update of a deployment to its
+// device again right away, regardless of the retry policy. A deployment
+// that finished is reactivated; cancelled and rolled back deployments
+// cannot be retried.
+func (s *Service) RetryFailedUpdates(ctx context.Context, deploymentID string) (*RetryFailedResult, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if err := checkRetryable(deployment); err != nil {
+		return nil, err
+	}
+
+	failed, err := s.repository.GetDeviceUpdatesByStatus(ctx, deploymentID, UpdateStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed device updates: %w", err)
+	}
+	result := &RetryFailedResult{DeploymentID: deploymentID, Devices: []string{}}
+	if len(failed) == 0 {
+		return result, nil
+	}
+
+	// Reactivate first, so the restarted updates cannot be settled into a
+	// deployment that stays finished
+	if deployment.Status == DeploymentStatusCompleted || deployment.Status == DeploymentStatusFailed {
+		_, err = s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+			if err := checkRetryable(d); err != nil {
+				return err
+			}
+			d.Status = DeploymentStatusActive
+			d.UpdatedAt = time.Now()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to reactivate deployment: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for _, update := range failed {
+		update.restart(now)
+		if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+			return nil, fmt.Errorf("failed to retry update for device %s: %w", update.DeviceID, err)
+		}
+		result.Retried++
+		result.Devices = append(result.Devices, update.DeviceID)
+	}
+
+	if err := s.updateDeploymentStats(ctx, deploymentID); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Retrying failed device updates", "deployment_id", deploymentID, "retried", result.Retried)
+
+	return result, nil
+}
+
+// checkRetryable refuses retries of deployments that were ended on purpose
+func checkRetryable(deployment *OTADeployment) error {
+	if deployment.Status == DeploymentStatusCancelled {
+		return fmt.Errorf("cannot retry updates of a cancelled deployment")
+	}
+	_, rolledBack := deployment.Annotations[AnnotationRolledBackBy]
+	_, rollingBack := deployment.Annotations[AnnotationRollbackStarted]
+	if rolledBack || rollingBack {
+		return fmt.Errorf("cannot retry updates of a rolled back deployment")
+	}
+	return nil
+}
+
+func (s *Service) retryFailedUpdatesHandler(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	result, err := s.RetryFailedUpdates(c.Request.Context(), deploymentID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
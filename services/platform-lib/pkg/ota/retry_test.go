@@ -0,0 +1,124 @@
+package ota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 20, BackoffSeconds: 60}
+
+	assert.Equal(t, time.Minute, policy.backoff(2))
+	assert.Equal(t, 2*time.Minute, policy.backoff(3))
+	assert.Equal(t, 4*time.Minute, policy.backoff(4))
+	assert.Equal(t, maxRetryBackoff, policy.backoff(20))
+
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: -1}))
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 3, ErrorTypes: []UpdateErrorType{"flaky"}}))
+	assert.NoError(t, validateRetryPolicy(nil))
+}
+
+func TestService_ReportUpdateStatus_RetryPolicy(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	deployment := &OTADeployment{
+		DeploymentID:     "deployment-1",
+		ReleaseID:        "release-1",
+		Status:           DeploymentStatusActive,
+		FailureThreshold: 50,
+		RetryPolicy: &RetryPolicy{
+			MaxAttempts:    3,
+			BackoffSeconds: 60,
+			ErrorTypes:     []UpdateErrorType{UpdateErrorDownload},
+		},
+	}
+	update := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusDownloading, Attempts: 1}
+	other := &DeviceUpdate{DeviceID: "device-2", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusInstalling, Attempts: 1}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-1").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-1", "release-1").Return(update, nil)
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-2", "release-1").Return(other, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-1").Return(0, 0, 2, nil)
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-1").Return(update, nil)
+
+	fail := func(deviceID string, errorType UpdateErrorType) {
+		require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{
+			DeviceID: deviceID, ReleaseID: "release-1", Status: UpdateStatusFailed, ErrorMessage: "timed out", ErrorType: errorType,
+		}))
+	}
+
+	// A download failure is tried again after the backoff
+	fail("device-1", UpdateErrorDownload)
+	assert.Equal(t, UpdateStatusPending, update.Status)
+	assert.Equal(t, 2, update.Attempts)
+	assert.Equal(t, UpdateErrorDownload, update.ErrorType)
+	require.NotNil(t, update.NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *update.NextAttemptAt, 5*time.Second)
+	_, err := service.GetUpdateForDevice(ctx, "device-1")
+	assert.Error(t, err, "update is not offered before its backoff passes")
+
+	// The backoff doubles, and the last attempt stays failed
+	require.NoError(t, service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-1", ReleaseID: "release-1", Status: UpdateStatusDownloading}))
+	fail("device-1", UpdateErrorDownload)
+	assert.Equal(t, 3, update.Attempts)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), *update.NextAttemptAt, 5*time.Second)
+	fail("device-1", UpdateErrorDownload)
+	assert.Equal(t, UpdateStatusFailed, update.Status)
+	assert.Equal(t, 3, update.Attempts)
+
+	// Error types outside the policy are not retried
+	fail("device-2", UpdateErrorBoot)
+	assert.Equal(t, UpdateStatusFailed, other.Status)
+	assert.Equal(t, 1, other.Attempts)
+
+	err = service.ReportUpdateStatus(ctx, &UpdateStatusReport{DeviceID: "device-2", ReleaseID: "release-1", Status: UpdateStatusFailed, ErrorType: "gremlins"})
+	assert.ErrorIs(t, err, ErrInvalidStatusReport)
+}
+
+func TestService_RetryFailedUpdates(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	completedAt := time.Now().Add(-time.Hour)
+	deployment := &OTADeployment{DeploymentID: "deployment-1", ReleaseID: "release-1", Status: DeploymentStatusFailed}
+	failed := []*DeviceUpdate{
+		{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusFailed, Attempts: 1, Progress: 40, CompletedAt: &completedAt},
+		{DeviceID: "device-2", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusFailed, CompletedAt: &completedAt},
+	}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-1").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-1", UpdateStatusFailed).Return(failed, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-1").Return(0, 0, 2, nil)
+
+	result, err := service.RetryFailedUpdates(ctx, "deployment-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Retried)
+	assert.Equal(t, []string{"device-1", "device-2"}, result.Devices)
+	assert.Equal(t, DeploymentStatusActive, deployment.Status)
+	for _, update := range failed {
+		assert.Equal(t, UpdateStatusPending, update.Status)
+		assert.Equal(t, 2, update.Attempts)
+		assert.Equal(t, 0, update.Progress)
+		assert.Nil(t, update.CompletedAt)
+		assert.True(t, update.due(time.Now()))
+	}
+
+	// Deployments ended on purpose are not retried
+	deployment.Status = DeploymentStatusCancelled
+	_, err = service.RetryFailedUpdates(ctx, "deployment-1")
+	assert.ErrorContains(t, err, "cancelled")
+	deployment.Status = DeploymentStatusFailed
+	deployment.Annotations = map[string]string{AnnotationRolledBackBy: "deployment-2"}
+	_, err = service.RetryFailedUpdates(ctx, "deployment-1")
+	assert.ErrorContains(t, err, "rolled back")
+}
@@ -0,0 +1,259 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/notifications"
+)
+
+// rollbackClaimTimeout is how long a rollback in progress holds its claim
+// on a deployment. An older claim was left by a rollback that died part way
+// and may be taken over.
+const rollbackClaimTimeout = 10 * time.Minute
+
+var (
+	// ErrAlreadyRolledBack is returned for deployments that have been, or
+	// are being, rolled back
+	ErrAlreadyRolledBack = errors.New("deployment has already been rolled back")
+
+	// ErrRollbackDepthExceeded is returned when rolling back would chain
+	// more rollbacks than rollback.max_depth allows
+	ErrRollbackDepthExceeded = errors.New("rollback depth limit reached")
+
+	// ErrRollbackCooldown is returned when a rollback deployment is too new
+	// to be rolled back automatically
+	ErrRollbackCooldown = errors.New("rollback deployment is still in its cooldown")
+
+	errDeploymentNotActive = errors.New("deployment is not active")
+)
+
+// rollbackSettings returns the rollback configuration with defaults applied
+func rollbackSettings(cfg *config.Config) config.RollbackConfig {
+	var settings config.RollbackConfig
+	if cfg != nil {
+		settings = cfg.Rollback
+	}
+	if settings.MaxDepth <= 0 {
+		settings.MaxDepth = 1
+	}
+	if settings.Cooldown <= 0 {
+		settings.Cooldown = 30 * time.Minute
+	}
+	return settings
+}
+
+// RollbackDepth returns how many rollbacks were chained to produce a
+// deployment, zero for one created by an operator or policy
+func RollbackDepth(deployment *OTADeployment) int {
+	if value, ok := deployment.Annotations[AnnotationRollbackDepth]; ok {
+		if depth, err := strconv.Atoi(value); err == nil && depth > 0 {
+			return depth
+		}
+	}
+	// Rollbacks made before the depth was recorded
+	if _, ok := deployment.Annotations[AnnotationRollbackOf]; ok {
+		return 1
+	}
+	return 0
+}
+
+// RollbackDeployment rolls back a deployment to the previous firmware
+// version. A deployment is rolled back at most once, and not at all when
+// that would chain more rollbacks than rollback.max_depth allows.
+func (s *Service) RollbackDeployment(ctx context.Context, deploymentID string) error {
+	return s.rollbackDeployment(ctx, deploymentID, false)
+}
+
+// rollbackDeployment rolls back a deployment. Automatic rollbacks also
+// respect the cooldown of deployments that are themselves rollbacks.
+func (s *Service) rollbackDeployment(ctx context.Context, deploymentID string, automatic bool) error {
+	settings := rollbackSettings(s.config)
+
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if _, done := deployment.Annotations[AnnotationRolledBackBy]; done {
+		return ErrAlreadyRolledBack
+	}
+
+	depth := RollbackDepth(deployment)
+	if depth >= settings.MaxDepth {
+		return fmt.Errorf("%w: deployment %s is rollback %d of at most %d", ErrRollbackDepthExceeded, deploymentID, depth, settings.MaxDepth)
+	}
+	if age := time.Since(deployment.CreatedAt); automatic && depth > 0 && age < settings.Cooldown {
+		return fmt.Errorf("%w: deployment %s was created %s ago", ErrRollbackCooldown, deploymentID, age.Round(time.Second))
+	}
+
+	// Get the release being deployed
+	release, err := s.repository.GetRelease(ctx, deployment.ReleaseID)
+	if err != nil {
+		return fmt.Errorf("failed to get release: %w", err)
+	}
+
+	// Find previous stable release for the same template
+	releases, err := s.repository.ListReleases(ctx, release.TemplateID, ReleaseChannelStable)
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	var previousRelease *FirmwareRelease
+	for _, r := range releases {
+		if r.ReleaseID != release.ReleaseID && r.CreatedAt.Before(release.CreatedAt) {
+			previousRelease = r
+			break
+		}
+	}
+
+	if previousRelease == nil {
+		return fmt.Errorf("no previous release found for rollback")
+	}
+
+	// Claim the rollback and mark the deployment failed in one write, so
+	// concurrent failure reports and operators cannot start a second one
+	now := time.Now()
+	deployment, err = s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		if _, done := d.Annotations[AnnotationRolledBackBy]; done {
+			return ErrAlreadyRolledBack
+		}
+		if started, ok := d.Annotations[AnnotationRollbackStarted]; ok {
+			if at, err := time.Parse(time.RFC3339, started); err == nil && now.Sub(at) < rollbackClaimTimeout {
+				return ErrAlreadyRolledBack
+			}
+		}
+		if d.Annotations == nil {
+			d.Annotations = make(map[string]string)
+		}
+		d.Annotations[AnnotationRollbackStarted] = now.UTC().Format(time.RFC3339)
+		// Rolling back a cancelled deployment reverts the devices it reached
+		// without recounting it as failed
+		if d.Status != DeploymentStatusCancelled {
+			d.Status = DeploymentStatusFailed
+		}
+		return nil
+	})
+	if errors.Is(err, ErrAlreadyRolledBack) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update deployment status: %w", err)
+	}
+
+	// Create a new deployment for the previous release
+	rollbackConfig := &DeploymentConfig{
+		Strategy:          DeploymentStrategyImmediate,
+		TargetDevices:     deployment.TargetDevices,
+		RolloutPercentage: 100,
+		FailureThreshold:  deployment.FailureThreshold,
+		Annotations: map[string]string{
+			AnnotationRollbackOf:    deploymentID,
+			AnnotationRollbackDepth: strconv.Itoa(depth + 1),
+		},
+	}
+	for key, value := range deployment.Annotations {
+		switch key {
+		case AnnotationRollbackOf, AnnotationRollbackDepth, AnnotationRolledBackBy, AnnotationRollbackStarted:
+		default:
+			rollbackConfig.Annotations[key] = value
+		}
+	}
+
+	rollbackDeployment, err := s.DeployRelease(ctx, previousRelease.ReleaseID, rollbackConfig)
+	if err != nil {
+		// Let the rollback be retried rather than wait out the claim
+		s.finishRollbackClaim(ctx, deploymentID, "")
+		return fmt.Errorf("failed to create rollback deployment: %w", err)
+	}
+	s.finishRollbackClaim(ctx, deploymentID, rollbackDeployment.DeploymentID)
+
+	s.logger.Info("Rolled back deployment", "original_deployment_id", deploymentID, "rollback_deployment_id", rollbackDeployment.DeploymentID, "previous_release_id", previousRelease.ReleaseID, "automatic", automatic)
+
+	s.emitWebhookEvent(WebhookRollbackTriggered, map[string]interface{}{
+		"deployment_id":          deploymentID,
+		"release_id":             release.ReleaseID,
+		"rollback_deployment_id": rollbackDeployment.DeploymentID,
+		"previous_release_id":    previousRelease.ReleaseID,
+		"rollback_depth":         depth + 1,
+		"automatic":              automatic,
+	})
+
+	if s.publisher != nil {
+		notifications.PublishAsync(s.publisher, s.logger, &notifications.Event{
+			Type:         notifications.EventDeploymentRolledBack,
+			ResourceType: "deployment",
+			ResourceID:   deploymentID,
+			Owner:        release.CreatedBy,
+			Message: fmt.Sprintf("Deployment %s rolled back to release %s by deployment %s",
+				deploymentID, previousRelease.ReleaseID, rollbackDeployment.DeploymentID),
+			Data: map[string]interface{}{
+				"rollback_deployment_id": rollbackDeployment.DeploymentID,
+				"previous_release_id":    previousRelease.ReleaseID,
+				"rollback_depth":         depth + 1,
+				"automatic":              automatic,
+			},
+		})
+	}
+
+	return nil
+}
+
+// finishRollbackClaim drops the in-progress marker from a deployment and,
+// when the rollback succeeded, records the deployment that replaced it
+func (s *Service) finishRollbackClaim(ctx context.Context, deploymentID, rollbackDeploymentID string) {
+	_, err := s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		delete(d.Annotations, AnnotationRollbackStarted)
+		if rollbackDeploymentID != "" {
+			if d.Annotations == nil {
+				d.Annotations = make(map[string]string)
+			}
+			d.Annotations[AnnotationRolledBackBy] = rollbackDeploymentID
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("Failed to record rollback on deployment", "deployment_id", deploymentID, "rollback_deployment_id", rollbackDeploymentID, "error", err)
+	}
+}
+
+// holdForOperator pauses an active deployment whose automatic rollback was
+// refused and tells its owner. Only the report that pauses it notifies.
+func (s *Service) holdForOperator(ctx context.Context, deploymentID string, reason error) error {
+	deployment, err := s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		if d.Status != DeploymentStatusActive {
+			return errDeploymentNotActive
+		}
+		d.Status = DeploymentStatusPaused
+		return nil
+	})
+	if errors.Is(err, errDeploymentNotActive) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pause deployment: %w", err)
+	}
+
+	s.logger.Warn("Automatic rollback blocked, deployment paused", "deployment_id", deploymentID, "reason", reason)
+
+	if s.publisher != nil {
+		notifications.PublishAsync(s.publisher, s.logger, &notifications.Event{
+			Type:         notifications.EventRollbackBlocked,
+			ResourceType: "deployment",
+			ResourceID:   deploymentID,
+			Owner:        s.deploymentOwner(ctx, deployment),
+			Message:      fmt.Sprintf("Deployment %s paused, automatic rollback blocked: %v", deploymentID, reason),
+			Data: map[string]interface{}{
+				"release_id":     deployment.ReleaseID,
+				"rollback_depth": RollbackDepth(deployment),
+				"success_count":  deployment.SuccessCount,
+				"failure_count":  deployment.FailureCount,
+			},
+		})
+	}
+
+	return nil
+}
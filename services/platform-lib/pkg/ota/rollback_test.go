@@ -0,0 +1,157 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// channelPublisher hands published events to a channel
+type channelPublisher chan *notifications.Event
+
+func (p channelPublisher) Publish(ctx context.Context, event *notifications.Event) error {
+	p <- event
+	return nil
+}
+
+// mockRollbackReleases sets up release-002 as the deployed release with
+// release-001 before it
+func mockRollbackReleases(mockRepo *MockRepository) {
+	current := createTestRelease("release-002")
+	previous := createTestRelease("release-001")
+	previous.CreatedAt = current.CreatedAt.Add(-24 * time.Hour)
+
+	mockRepo.On("GetRelease", mock.Anything, "release-002").Return(current, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(previous, nil)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannelStable).Return([]*FirmwareRelease{current, previous}, nil)
+}
+
+func TestService_RollbackDeployment_OnlyOnce(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	events := make(channelPublisher, 1)
+	service.publisher = events
+	ctx := context.Background()
+
+	deployment := &OTADeployment{
+		DeploymentID:     "deployment-001",
+		ReleaseID:        "release-002",
+		Status:           DeploymentStatusActive,
+		TargetDevices:    []string{"device-001"},
+		FailureThreshold: 10,
+		Annotations:      map[string]string{"ticket": "OPS-12"},
+		CreatedAt:        time.Now(),
+	}
+	mockRollbackReleases(mockRepo)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.Anything).Return(nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(&device.Device{DeviceID: "device-001"}, nil)
+
+	var rollback *OTADeployment
+	mockRepo.On("CreateDeployment", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		rollback = args.Get(1).(*OTADeployment)
+	}).Return(nil).Once()
+
+	require.NoError(t, service.RollbackDeployment(ctx, "deployment-001"))
+	require.NotNil(t, rollback)
+	assert.Equal(t, "release-001", rollback.ReleaseID)
+	assert.Equal(t, "deployment-001", rollback.Annotations[AnnotationRollbackOf])
+	assert.Equal(t, "1", rollback.Annotations[AnnotationRollbackDepth])
+	assert.Equal(t, "OPS-12", rollback.Annotations["ticket"])
+
+	assert.Equal(t, DeploymentStatusFailed, deployment.Status)
+	assert.Equal(t, rollback.DeploymentID, deployment.Annotations[AnnotationRolledBackBy])
+	assert.NotContains(t, deployment.Annotations, AnnotationRollbackStarted)
+
+	event := <-events
+	assert.Equal(t, notifications.EventDeploymentRolledBack, event.Type)
+	assert.Equal(t, rollback.DeploymentID, event.Data["rollback_deployment_id"])
+
+	// A second request, from an operator or another failure report, is refused
+	err := service.RollbackDeployment(ctx, "deployment-001")
+	assert.True(t, errors.Is(err, ErrAlreadyRolledBack))
+	mockRepo.AssertNumberOfCalls(t, "CreateDeployment", 1)
+}
+
+func TestService_RollbackDeployment_ClaimInProgress(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	ctx := context.Background()
+
+	started := time.Now().UTC().Format(time.RFC3339)
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001",
+		ReleaseID:    "release-002",
+		Status:       DeploymentStatusActive,
+		Annotations:  map[string]string{AnnotationRollbackStarted: started},
+	}
+	mockRollbackReleases(mockRepo)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+
+	err := service.RollbackDeployment(ctx, "deployment-001")
+	assert.True(t, errors.Is(err, ErrAlreadyRolledBack))
+	mockRepo.AssertNotCalled(t, "UpdateDeployment", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+}
+
+func TestService_RollbackDeployment_DepthLimit(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-002",
+		ReleaseID:    "release-001",
+		Status:       DeploymentStatusActive,
+		Annotations:  map[string]string{AnnotationRollbackOf: "deployment-001"},
+		CreatedAt:    time.Now(),
+	}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-002").Return(deployment, nil)
+
+	err := service.RollbackDeployment(context.Background(), "deployment-002")
+	assert.True(t, errors.Is(err, ErrRollbackDepthExceeded))
+
+	// A deeper limit allows the rollback of a rollback
+	service.config.Rollback = config.RollbackConfig{MaxDepth: 2}
+	assert.Equal(t, 1, RollbackDepth(deployment))
+	err = service.rollbackDeployment(context.Background(), "deployment-002", true)
+	assert.True(t, errors.Is(err, ErrRollbackCooldown), "a new rollback deployment is in its cooldown")
+}
+
+func TestService_CheckAndHandleFailures_RollbackBlocked(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	events := make(channelPublisher, 2)
+	service.publisher = events
+	ctx := context.Background()
+
+	deployment := &OTADeployment{
+		DeploymentID:     "deployment-002",
+		ReleaseID:        "release-001",
+		Status:           DeploymentStatusActive,
+		FailureThreshold: 10,
+		FailureCount:     5,
+		SuccessCount:     5,
+		Annotations:      map[string]string{AnnotationRollbackOf: "deployment-001", AnnotationRollbackDepth: "1"},
+	}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-002").Return(deployment, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+
+	require.NoError(t, service.checkAndHandleFailures(ctx, "deployment-002"))
+	assert.Equal(t, DeploymentStatusPaused, deployment.Status)
+
+	event := <-events
+	assert.Equal(t, notifications.EventRollbackBlocked, event.Type)
+	assert.Equal(t, "admin", event.Owner)
+
+	// Later failure reports find the deployment paused and stay quiet
+	require.NoError(t, service.checkAndHandleFailures(ctx, "deployment-002"))
+	assert.Empty(t, events)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDeployment", 1)
+	mockRepo.AssertNotCalled(t, "CreateDeployment", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,290 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// errNoScheduleChange is returned from a deployment modification when the
+// schedule leaves the deployment as it is
+var errNoScheduleChange = errors.New("no schedule change")
+
+// maintenanceWindow is a daily time range in a time zone. A window whose
+// end is before its start runs past midnight.
+type maintenanceWindow struct {
+	start, end int // minutes after midnight
+	location   *time.Location
+}
+
+// parseMaintenanceWindow parses "HH:MM-HH:MM", optionally followed by a
+// time zone name such as "UTC" or "Europe/Berlin". The default is UTC.
+func parseMaintenanceWindow(spec string) (*maintenanceWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid maintenance window %q: want \"HH:MM-HH:MM [zone]\"", spec)
+	}
+
+	window := &maintenanceWindow{location: time.UTC}
+	if len(fields) == 2 {
+		location, err := time.LoadLocation(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: unknown time zone %q", spec, fields[1])
+		}
+		window.location = location
+	}
+
+	from, to, found := strings.Cut(fields[0], "-")
+	if !found {
+		return nil, fmt.Errorf("invalid maintenance window %q: want \"HH:MM-HH:MM [zone]\"", spec)
+	}
+	for _, bound := range []struct {
+		value  string
+		minute *int
+	}{{from, &window.start}, {to, &window.end}} {
+		clock, err := time.Parse("15:04", bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: bad time %q", spec, bound.value)
+		}
+		*bound.minute = clock.Hour()*60 + clock.Minute()
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("invalid maintenance window %q: start and end are equal", spec)
+	}
+
+	return window, nil
+}
+
+// contains reports whether t falls inside the window
+func (w *maintenanceWindow) contains(t time.Time) bool {
+	local := t.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// validateSchedule checks the start time, end time and maintenance windows
+// of a deployment configuration
+func validateSchedule(config *DeploymentConfig, now time.Time) error {
+	if config.EndAt != nil {
+		if !config.EndAt.After(now) {
+			return fmt.Errorf("end_at is in the past")
+		}
+		if config.StartAt != nil && !config.EndAt.After(*config.StartAt) {
+			return fmt.Errorf("end_at must be after start_at")
+		}
+	}
+	for _, spec := range config.MaintenanceWindows {
+		if _, err := parseMaintenanceWindow(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduled reports whether a deployment has a start time, end time or
+// maintenance windows
+func (d *OTADeployment) scheduled() bool {
+	return d.StartAt != nil || d.EndAt != nil || len(d.MaintenanceWindows) > 0
+}
+
+// inSchedule reports whether a deployment may roll out at now
+func (d *OTADeployment) inSchedule(now time.Time) bool {
+	if d.StartAt != nil && now.Before(*d.StartAt) {
+		return false
+	}
+	if d.EndAt != nil && !now.Before(*d.EndAt) {
+		return false
+	}
+	if len(d.MaintenanceWindows) == 0 {
+		return true
+	}
+	for _, spec := range d.MaintenanceWindows {
+		window, err := parseMaintenanceWindow(spec)
+		if err == nil && window.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// issuesUpdates reports whether devices are offered the deployment's
+// pending updates. Paused deployments, scheduled ones that have not
+// started and ones not approved hold them back.
+func (d *OTADeployment) issuesUpdates() bool {
+	switch d.Status {
+	case DeploymentStatusPaused, DeploymentStatusPendingApproval, DeploymentStatusRejected:
+		return false
+	case DeploymentStatusPending:
+		return !d.scheduled()
+	default:
+		return true
+	}
+}
+
+// scheduleTransition returns the status a scheduled deployment should
+// move to at now, or "" when it should stay as it is. Deployments paused
+// by an operator are left alone.
+func scheduleTransition(d *OTADeployment, now time.Time) DeploymentStatus {
+	if !d.scheduled() {
+		return ""
+	}
+	inSchedule := d.inSchedule(now)
+	_, held := d.Annotations[AnnotationScheduleHold]
+
+	switch {
+	case d.Status == DeploymentStatusPending && inSchedule:
+		return DeploymentStatusActive
+	case d.Status == DeploymentStatusActive && !inSchedule:
+		return DeploymentStatusPaused
+	case d.Status == DeploymentStatusPaused && held && inSchedule:
+		return DeploymentStatusActive
+	}
+	return ""
+}
+
+// applySchedule moves a deployment to the status its schedule calls for
+// at now and returns it, or "" when nothing changed. A deployment paused
+// by its schedule is marked with AnnotationScheduleHold.
+func applySchedule(d *OTADeployment, now time.Time) DeploymentStatus {
+	status := scheduleTransition(d, now)
+	switch status {
+	case "":
+		return ""
+	case DeploymentStatusPaused:
+		if d.Annotations == nil {
+			d.Annotations = make(map[string]string)
+		}
+		d.Annotations[AnnotationScheduleHold] = now.UTC().Format(time.RFC3339)
+	default:
+		delete(d.Annotations, AnnotationScheduleHold)
+	}
+	d.Status = status
+	d.UpdatedAt = now
+	return status
+}
+
+// ScheduleReport lists the deployments one scheduler pass changed
+type ScheduleReport struct {
+	Started []string `json:"started"`
+	Paused  []string `json:"paused"`
+	Resumed []string `json:"resumed"`
+}
+
+// DeploymentScheduler starts scheduled deployments once they are due and
+// inside a maintenance window, pauses them when the window closes and
+// resumes them when it opens again
+type DeploymentScheduler struct {
+	service *Service
+	logger  *logger.Logger
+	now     func() time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewDeploymentScheduler creates a scheduler for the deployments of service
+func NewDeploymentScheduler(service *Service, logger *logger.Logger) *DeploymentScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DeploymentScheduler{
+		service: service,
+		logger:  logger,
+		now:     time.Now,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start applies deployment schedules every interval until Stop is called
+func (s *DeploymentScheduler) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	s.wg.Add(1)
+	go s.scheduleLoop(interval)
+	s.logger.Info("Deployment scheduler started", "interval", interval)
+}
+
+// Stop stops the scheduler
+func (s *DeploymentScheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.logger.Info("Deployment scheduler stopped")
+}
+
+func (s *DeploymentScheduler) scheduleLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Run(s.ctx); err != nil {
+				s.logger.Error("Deployment schedule pass failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run applies the schedule of every scheduled deployment once
+func (s *DeploymentScheduler) Run(ctx context.Context) (*ScheduleReport, error) {
+	if s.service.repository == nil {
+		return nil, fmt.Errorf("deployment repository not configured")
+	}
+	deployments, err := s.service.repository.ListDeployments(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	report := &ScheduleReport{Started: []string{}, Paused: []string{}, Resumed: []string{}}
+	now := s.now()
+	for _, deployment := range deployments {
+		if !deployment.scheduled() {
+			continue
+		}
+		if scheduleTransition(deployment, now) == "" {
+			continue
+		}
+
+		// Decide again in the write, as an operator may have acted since
+		var previous, status DeploymentStatus
+		_, err := s.service.repository.ModifyDeployment(ctx, deployment.DeploymentID, func(d *OTADeployment) error {
+			previous = d.Status
+			if status = applySchedule(d, now); status == "" {
+				return errNoScheduleChange
+			}
+			return nil
+		})
+		if errors.Is(err, errNoScheduleChange) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to apply deployment schedule", "deployment_id", deployment.DeploymentID, "error", err)
+			continue
+		}
+
+		switch {
+		case previous == DeploymentStatusPending:
+			report.Started = append(report.Started, deployment.DeploymentID)
+		case status == DeploymentStatusPaused:
+			report.Paused = append(report.Paused, deployment.DeploymentID)
+		default:
+			report.Resumed = append(report.Resumed, deployment.DeploymentID)
+		}
+		s.logger.Info("Applied deployment schedule", "deployment_id", deployment.DeploymentID, "from", previous, "to", status)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,134 @@
+package ota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, "2026-03-02T"+clock+":00Z")
+		require.NoError(t, err)
+		return parsed
+	}
+
+	window, err := parseMaintenanceWindow("02:00-04:00 UTC")
+	require.NoError(t, err)
+	assert.True(t, window.contains(at("02:00")))
+	assert.True(t, window.contains(at("03:59")))
+	assert.False(t, window.contains(at("04:00")))
+	assert.False(t, window.contains(at("12:00")))
+
+	// A window past midnight wraps around
+	window, err = parseMaintenanceWindow("22:00-02:00")
+	require.NoError(t, err)
+	assert.True(t, window.contains(at("23:30")))
+	assert.True(t, window.contains(at("01:00")))
+	assert.False(t, window.contains(at("02:30")))
+
+	// Berlin is UTC+1 in March
+	window, err = parseMaintenanceWindow("03:00-05:00 Europe/Berlin")
+	require.NoError(t, err)
+	assert.True(t, window.contains(at("02:30")))
+	assert.False(t, window.contains(at("04:30")))
+
+	for _, spec := range []string{"", "02:00", "2am-4am", "02:00-04:00 Mars/Olympus", "02:00-02:00", "02:00-04:00 UTC extra"} {
+		_, err := parseMaintenanceWindow(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestDeploymentScheduler_Run(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	now := time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	windows := []string{"02:00-04:00 UTC"}
+	due := &OTADeployment{DeploymentID: "deployment-due", Status: DeploymentStatusPending, MaintenanceWindows: windows}
+	future := &OTADeployment{DeploymentID: "deployment-future", Status: DeploymentStatusPending, StartAt: &later}
+	closing := &OTADeployment{DeploymentID: "deployment-closing", Status: DeploymentStatusActive, MaintenanceWindows: []string{"00:00-01:00 UTC"}}
+	held := &OTADeployment{DeploymentID: "deployment-held", Status: DeploymentStatusPaused, MaintenanceWindows: windows,
+		Annotations: map[string]string{AnnotationScheduleHold: "2026-03-01T04:00:00Z"}}
+	paused := &OTADeployment{DeploymentID: "deployment-paused", Status: DeploymentStatusPaused, MaintenanceWindows: windows}
+	unscheduled := &OTADeployment{DeploymentID: "deployment-staged", Status: DeploymentStatusPending}
+
+	mockRepo.On("ListDeployments", mock.Anything, "").Return([]*OTADeployment{due, future, closing, held, paused, unscheduled}, nil)
+	for _, deployment := range []*OTADeployment{due, closing, held} {
+		mockRepo.On("GetDeployment", mock.Anything, deployment.DeploymentID).Return(deployment, nil)
+		mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	}
+
+	scheduler := NewDeploymentScheduler(service, service.logger)
+	scheduler.now = func() time.Time { return now }
+
+	report, err := scheduler.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deployment-due"}, report.Started)
+	assert.Equal(t, []string{"deployment-closing"}, report.Paused)
+	assert.Equal(t, []string{"deployment-held"}, report.Resumed)
+
+	assert.Equal(t, DeploymentStatusActive, due.Status)
+	assert.Equal(t, DeploymentStatusPending, future.Status)
+	assert.Equal(t, DeploymentStatusPaused, closing.Status)
+	assert.Contains(t, closing.Annotations, AnnotationScheduleHold)
+	assert.Equal(t, DeploymentStatusActive, held.Status)
+	assert.NotContains(t, held.Annotations, AnnotationScheduleHold)
+	assert.Equal(t, DeploymentStatusPaused, paused.Status)
+	assert.Equal(t, DeploymentStatusPending, unscheduled.Status)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_DeployRelease_Scheduled(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return([]*device.Device{{DeviceID: "device-001"}}, nil)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+
+	startAt := time.Now().Add(24 * time.Hour)
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:           DeploymentStrategyImmediate,
+		StartAt:            &startAt,
+		MaintenanceWindows: []string{"02:00-04:00 UTC"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentStatusPending, deployment.Status)
+	assert.Equal(t, []string{"02:00-04:00 UTC"}, deployment.MaintenanceWindows)
+	mockRepo.AssertNotCalled(t, "UpdateDeployment", mock.Anything, mock.Anything)
+
+	past := time.Now().Add(-time.Hour)
+	for _, config := range []*DeploymentConfig{
+		{Strategy: DeploymentStrategyImmediate, MaintenanceWindows: []string{"nightly"}},
+		{Strategy: DeploymentStrategyImmediate, EndAt: &past},
+		{Strategy: DeploymentStrategyImmediate, StartAt: &startAt, EndAt: &startAt},
+	} {
+		_, err := service.DeployRelease(context.Background(), "release-001", config)
+		assert.Error(t, err)
+	}
+}
+
+// Devices are not offered updates of a deployment paused by its schedule
+func TestService_GetUpdateForDevice_PausedDeployment(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(&DeviceUpdate{
+		DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusPending,
+	}, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{
+		DeploymentID: "deployment-001", Status: DeploymentStatusPaused, MaintenanceWindows: []string{"02:00-04:00 UTC"},
+		Annotations: map[string]string{AnnotationScheduleHold: "2026-03-02T04:00:00Z"},
+	}, nil)
+
+	update, err := service.GetUpdateForDevice(context.Background(), "device-001")
+	assert.Nil(t, update)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no pending update")
+}
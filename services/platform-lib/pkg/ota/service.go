@@ -2,16 +2,21 @@ package ota
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/athena/platform-lib/internal/device"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/ids"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
+	"github.com/athena/platform-lib/pkg/notifications"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // Service represents the OTA service
@@ -21,27 +26,75 @@ type Service struct {
 	repository       Repository
 	deviceRepository device.Repository
 	signer           *Signer
+	signingKeys      SigningKeyStore
 	storageBackend   StorageBackend
+	publisher        notifications.Publisher
+	webhooks         *webhookDispatcher
+	usage            *metering.Recorder
+	reportLimits     *reportLimiter
+	ids              *ids.Generator
+	telemetry        TelemetrySource
+	deviceGroups     device.GroupStore
+	templatePromoter TemplatePromoter
+	deviceEvents     *device.EventRecorder
 }
 
 // StorageBackend defines the interface for binary storage
 type StorageBackend interface {
 	StoreBinary(ctx context.Context, releaseID string, data []byte) (string, error)
 	GetBinary(ctx context.Context, path string) ([]byte, error)
+	// OpenBinary opens a binary for streaming and returns its size
+	OpenBinary(ctx context.Context, path string) (io.ReadCloser, int64, error)
+	// GetBinaryRange reads length bytes from offset and returns them with
+	// the size of the whole binary
+	GetBinaryRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error)
 	GetBinaryURL(ctx context.Context, path string, expiry time.Duration) (string, error)
 	DeleteBinary(ctx context.Context, path string) error
 }
 
 // NewService creates a new OTA service instance
 func NewService(cfg *config.Config, logger *logger.Logger, repo Repository, deviceRepo device.Repository, signer *Signer, storage StorageBackend) (*Service, error) {
-	return &Service{
+	idGenerator, err := ids.NewGeneratorFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &Service{
 		config:           cfg,
 		logger:           logger,
 		repository:       repo,
 		deviceRepository: deviceRepo,
 		signer:           signer,
+		signingKeys:      NewMemorySigningKeyStore(),
 		storageBackend:   storage,
-	}, nil
+		publisher:        notifications.NewPublisherFromConfig(cfg),
+		webhooks:         newWebhookDispatcher(cfg, logger),
+		reportLimits:     newReportLimiter(updateReportSettings(cfg)),
+		ids:              idGenerator,
+	}
+
+	// Deployment health checks read the telemetry of updated devices
+	if telemetryURL := cfg.Services["telemetry-service"]; telemetryURL != "" {
+		service.telemetry = NewHTTPTelemetrySource(telemetryURL)
+	}
+
+	return service, nil
+}
+
+// SetDeviceEvents adds completed firmware updates to device timelines
+func (s *Service) SetDeviceEvents(events *device.EventRecorder) {
+	s.deviceEvents = events
+}
+
+// SetSigningKeyStore sets where rotated signing keys are saved. The signer
+// should be restored from the same store before the service starts.
+func (s *Service) SetSigningKeyStore(store SigningKeyStore) {
+	s.signingKeys = store
+}
+
+// SetUsageRecorder meters the firmware bytes devices download
+func (s *Service) SetUsageRecorder(recorder *metering.Recorder) {
+	s.usage = recorder
 }
 
 // CreateRelease creates a new firmware release with signing
@@ -56,14 +109,28 @@ func (s *Service) CreateRelease(ctx context.Context, req *CreateReleaseRequest)
 		return nil, fmt.Errorf("invalid release channel: %s", req.Channel)
 	}
 
-	// Generate release ID
-	releaseID := uuid.New().String()
+	if err := ValidateAnnotations(req.Annotations); err != nil {
+		return nil, err
+	}
+	if err := validateSlots(req.Slots); err != nil {
+		return nil, err
+	}
+
+	// Generate release ID and name
+	releaseID, err := s.ids.NewID(ctx, ids.KindRelease, s.repository.ReleaseExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate release ID: %w", err)
+	}
+	name, err := s.releaseName(ctx, req.TemplateID, req.Name)
+	if err != nil {
+		return nil, err
+	}
 
 	// Compute binary hash
 	binaryHash := ComputeHash(req.BinaryData)
 
 	// Sign the binary
-	signature, err := s.signer.SignBinary(req.BinaryData)
+	signature, keyID, err := s.signer.SignBinaryWithKeyID(req.BinaryData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign binary: %w", err)
 	}
@@ -76,24 +143,38 @@ func (s *Service) CreateRelease(ctx context.Context, req *CreateReleaseRequest)
 
 	// Create release object
 	release := &FirmwareRelease{
-		ReleaseID:    releaseID,
-		TemplateID:   req.TemplateID,
-		Version:      req.Version,
-		Channel:      req.Channel,
-		BinaryHash:   binaryHash,
-		BinaryPath:   binaryPath,
-		BinarySize:   int64(len(req.BinaryData)),
-		Signature:    signature,
-		ReleaseNotes: req.ReleaseNotes,
-		CreatedAt:    time.Now(),
-		CreatedBy:    req.CreatedBy,
-	}
+		ReleaseID:       releaseID,
+		Name:            name,
+		TemplateID:      req.TemplateID,
+		TemplateVersion: req.TemplateVersion,
+		Version:         req.Version,
+		Channel:         req.Channel,
+		BinaryHash:      binaryHash,
+		BinaryPath:      binaryPath,
+		BinarySize:      int64(len(req.BinaryData)),
+		Signature:       signature,
+		SigningKeyID:    keyID,
+		ReleaseNotes:    req.ReleaseNotes,
+		Annotations:     req.Annotations,
+		Slots:           req.Slots,
+		Environments:    []string{s.environments().Initial()},
+		CreatedAt:       time.Now(),
+		CreatedBy:       req.CreatedBy,
+	}
+
+	// Patches from earlier releases of the template, for delta updates
+	s.generateDeltas(ctx, release, req.BinaryData)
+
+	// Compressed copies of the binary, for devices that can decompress them
+	s.compressRelease(ctx, release, req.BinaryData)
 
 	// Store release metadata in repository
 	err = s.repository.CreateRelease(ctx, release)
 	if err != nil {
 		// Clean up binary if metadata storage fails
 		_ = s.storageBackend.DeleteBinary(ctx, binaryPath)
+		s.deleteDeltas(ctx, release)
+		s.deleteEncodings(ctx, release)
 		return nil, fmt.Errorf("failed to create release: %w", err)
 	}
 
@@ -125,6 +206,8 @@ func (s *Service) DeleteRelease(ctx context.Context, releaseID string) error {
 	if err != nil {
 		s.logger.Warn("Failed to delete binary from storage", "error", err)
 	}
+	s.deleteDeltas(ctx, release)
+	s.deleteEncodings(ctx, release)
 
 	// Delete release metadata
 	err = s.repository.DeleteRelease(ctx, releaseID)
@@ -157,8 +240,8 @@ func (s *Service) VerifyRelease(ctx context.Context, releaseID string) error {
 		return fmt.Errorf("binary hash mismatch: expected %s, got %s", release.BinaryHash, computedHash)
 	}
 
-	// Verify signature
-	err = s.signer.VerifySignature(binaryData, release.Signature)
+	// Verify signature against the key that made it
+	err = s.signer.VerifySignatureWithKeyID(binaryData, release.Signature, release.SigningKeyID)
 	if err != nil {
 		return fmt.Errorf("signature verification failed: %w", err)
 	}
@@ -176,19 +259,58 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 		v1.POST("/releases", service.createReleaseHandler)
 		v1.GET("/releases/:releaseId", service.getReleaseHandler)
 		v1.GET("/releases", service.listReleasesHandler)
+		v1.PATCH("/releases/:releaseId/annotations", service.annotateReleaseHandler)
 		v1.DELETE("/releases/:releaseId", service.deleteReleaseHandler)
 		v1.POST("/releases/:releaseId/verify", service.verifyReleaseHandler)
+		v1.POST("/releases/:releaseId/promote", service.promoteReleaseHandler)
+		v1.GET("/releases/:releaseId/bandwidth", service.getReleaseBandwidthHandler)
+		v1.GET("/releases/:releaseId/binary", service.downloadBinaryHandler)
+
+		// Retention of old releases
+		v1.GET("/retention/preview", service.previewRetentionHandler)
+
+		// Signing keys
+		v1.GET("/keys", service.listKeysHandler)
+		v1.POST("/keys/rotate", service.rotateKeyHandler)
 
 		// Deployment management
 		v1.POST("/deployments", service.createDeploymentHandler)
+		v1.GET("/deployments", service.listDeploymentsHandler)
 		v1.GET("/deployments/:deploymentId", service.getDeploymentHandler)
+		v1.GET("/deployments/:deploymentId/status", service.getDeploymentStatusHandler)
+		v1.GET("/deployments/:deploymentId/updates", service.listDeploymentUpdatesHandler)
 		v1.PUT("/deployments/:deploymentId/pause", service.pauseDeploymentHandler)
 		v1.PUT("/deployments/:deploymentId/resume", service.resumeDeploymentHandler)
+		v1.POST("/deployments/:deploymentId/approve", service.approveDeploymentHandler)
+		v1.POST("/deployments/:deploymentId/reject", service.rejectDeploymentHandler)
+		v1.POST("/deployments/:deploymentId/cancel", service.cancelDeploymentHandler)
+		v1.POST("/deployments/:deploymentId/retry-failed", service.retryFailedUpdatesHandler)
+		v1.POST("/deployments/:deploymentId/resolve-targets", service.resolveDeploymentTargetsHandler)
+		v1.GET("/deployments/:deploymentId/health", service.getDeploymentHealthHandler)
 		v1.POST("/deployments/:deploymentId/rollback", service.rollbackDeploymentHandler)
+		v1.PATCH("/deployments/:deploymentId/annotations", service.annotateDeploymentHandler)
 
 		// Device update endpoints
 		v1.GET("/updates/:deviceId", service.getUpdateForDeviceHandler)
+		v1.GET("/updates/:deviceId/delta", service.getDeltaUpdateHandler)
+		v1.POST("/updates/:deviceId/confirm-boot", service.confirmBootHandler)
 		v1.POST("/updates/status", service.reportUpdateStatusHandler)
+		v1.POST("/devices/:deviceId/updates/status", service.reportUpdateStatusHandler)
+		v1.POST("/devices/:deviceId/updates/downloaded", service.reportDownloadHandler)
+		v1.GET("/devices/:deviceId/downloads/:token", service.downloadHandler)
+		v1.GET("/devices/:deviceId/updates", service.listDeviceUpdateHistoryHandler)
+		v1.GET("/devices/:deviceId/children/updates", service.getChildUpdatesHandler)
+
+		// Crash and reset reports from devices
+		v1.POST("/devices/:deviceId/crashes", service.reportCrashHandler)
+		v1.GET("/devices/:deviceId/crashes", service.listCrashReportsHandler)
+
+		// Webhooks receiving deployment events, for CI/CD pipelines
+		v1.POST("/webhooks", service.createWebhookHandler)
+		v1.GET("/webhooks", service.listWebhooksHandler)
+		v1.GET("/webhooks/:webhookId", service.getWebhookHandler)
+		v1.PUT("/webhooks/:webhookId", service.updateWebhookHandler)
+		v1.DELETE("/webhooks/:webhookId", service.deleteWebhookHandler)
 	}
 }
 
@@ -210,11 +332,24 @@ func (s *Service) createReleaseHandler(c *gin.Context) {
 	}
 
 	// Get form fields
+	req.Name = c.PostForm("name")
 	req.TemplateID = c.PostForm("template_id")
 	req.Version = c.PostForm("version")
 	req.Channel = ReleaseChannel(c.PostForm("channel"))
 	req.ReleaseNotes = c.PostForm("release_notes")
 	req.CreatedBy = c.PostForm("created_by")
+	if annotations := c.PostForm("annotations"); annotations != "" {
+		if err := json.Unmarshal([]byte(annotations), &req.Annotations); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "annotations must be a JSON object of strings"})
+			return
+		}
+	}
+	if slots := c.PostForm("slots"); slots != "" {
+		if err := json.Unmarshal([]byte(slots), &req.Slots); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slots must be a JSON object"})
+			return
+		}
+	}
 
 	// Get binary file
 	file, _, err := c.Request.FormFile("binary")
@@ -237,7 +372,13 @@ func (s *Service) createReleaseHandler(c *gin.Context) {
 	release, err := s.CreateRelease(c.Request.Context(), &req)
 	if err != nil {
 		s.logger.Error("Failed to create release", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNameTaken) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidSlots) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -260,15 +401,65 @@ func (s *Service) listReleasesHandler(c *gin.Context) {
 	templateID := c.Query("template_id")
 	channel := ReleaseChannel(c.Query("channel"))
 
+	filter, err := ParseAnnotationFilter(c.QueryArray("annotation"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pipeline := s.environments()
+	environment := c.Query("environment")
+	if environment != "" {
+		if err := pipeline.Validate(environment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	releases, err := s.ListReleases(c.Request.Context(), templateID, channel)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if filter != nil || environment != "" {
+		matched := make([]*FirmwareRelease, 0, len(releases))
+		for _, release := range releases {
+			if filter != nil && !MatchesAnnotations(release.Annotations, filter) {
+				continue
+			}
+			if environment != "" && !pipeline.Includes(release.Environments, environment) {
+				continue
+			}
+			matched = append(matched, release)
+		}
+		releases = matched
+	}
+
 	c.JSON(http.StatusOK, gin.H{"releases": releases})
 }
 
+func (s *Service) annotateReleaseHandler(c *gin.Context) {
+	var req UpdateAnnotationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := s.GetRelease(c.Request.Context(), c.Param("releaseId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	release, err := s.AnnotateRelease(c.Request.Context(), c.Param("releaseId"), req.Annotations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, release)
+}
+
 func (s *Service) deleteReleaseHandler(c *gin.Context) {
 	releaseID := c.Param("releaseId")
 
@@ -308,7 +499,13 @@ func (s *Service) createDeploymentHandler(c *gin.Context) {
 	deployment, err := s.DeployRelease(c.Request.Context(), req.ReleaseID, req.Config)
 	if err != nil {
 		s.logger.Error("Failed to create deployment", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNameTaken) {
+			status = http.StatusConflict
+		} else if errors.Is(err, ErrInvalidTargets) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -340,6 +537,92 @@ func (s *Service) getDeploymentHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, deployment)
 }
 
+func (s *Service) getDeploymentStatusHandler(c *gin.Context) {
+	statusReport, err := s.GetDeploymentStatus(c.Request.Context(), c.Param("deploymentId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, statusReport)
+}
+
+func (s *Service) listDeploymentsHandler(c *gin.Context) {
+	filter, err := ParseAnnotationFilter(c.QueryArray("annotation"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var deployments []*OTADeployment
+	if releaseID := c.Query("release_id"); releaseID != "" {
+		deployments, err = s.repository.ListDeployments(c.Request.Context(), releaseID)
+	} else {
+		deployments, err = s.repository.GetActiveDeployments(c.Request.Context())
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matched := make([]*OTADeployment, 0, len(deployments))
+	for _, deployment := range deployments {
+		if MatchesAnnotations(deployment.Annotations, filter) {
+			matched = append(matched, deployment)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deployments": matched, "total": len(matched)})
+}
+
+func (s *Service) listDeploymentUpdatesHandler(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	query, err := ParseDeviceUpdateQuery(c.QueryArray("status"), c.Query("since"), c.Query("until"), c.Query("limit"), c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := s.GetDeployment(c.Request.Context(), deploymentID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := s.ListDeploymentUpdates(c.Request.Context(), deploymentID, query)
+	if err != nil {
+		if errors.Is(err, ErrInvalidDeviceUpdateQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Error("Failed to list deployment updates", "deployment_id", deploymentID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (s *Service) annotateDeploymentHandler(c *gin.Context) {
+	var req UpdateAnnotationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := s.GetDeployment(c.Request.Context(), c.Param("deploymentId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployment, err := s.AnnotateDeployment(c.Request.Context(), c.Param("deploymentId"), req.Annotations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deployment)
+}
+
 func (s *Service) pauseDeploymentHandler(c *gin.Context) {
 	deploymentID := c.Param("deploymentId")
 
@@ -364,11 +647,27 @@ func (s *Service) resumeDeploymentHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "deployment resumed successfully", "deployment_id": deploymentID})
 }
 
+func (s *Service) cancelDeploymentHandler(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	deployment, err := s.CancelDeployment(c.Request.Context(), deploymentID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deployment)
+}
+
 func (s *Service) rollbackDeploymentHandler(c *gin.Context) {
 	deploymentID := c.Param("deploymentId")
 
 	err := s.RollbackDeployment(c.Request.Context(), deploymentID)
 	if err != nil {
+		if errors.Is(err, ErrAlreadyRolledBack) || errors.Is(err, ErrRollbackDepthExceeded) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -379,7 +678,12 @@ func (s *Service) rollbackDeploymentHandler(c *gin.Context) {
 func (s *Service) getUpdateForDeviceHandler(c *gin.Context) {
 	deviceID := c.Param("deviceId")
 
-	update, err := s.GetUpdateForDevice(c.Request.Context(), deviceID)
+	if err := s.authenticateUpdateCheck(c.Request, deviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	update, err := s.GetUpdateForDevice(c.Request.Context(), deviceID, parseAcceptedEncodings(c.Query("accept_encoding"))...)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -388,6 +692,33 @@ func (s *Service) getUpdateForDeviceHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, update)
 }
 
+func (s *Service) listDeviceUpdateHistoryHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	updates, err := s.ListUpdatesForDevice(c.Request.Context(), deviceID, limit)
+	if err != nil {
+		s.logger.Error("Failed to list device updates", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": deviceID,
+		"updates":   updates,
+		"count":     len(updates),
+	})
+}
+
 func (s *Service) reportUpdateStatusHandler(c *gin.Context) {
 	var report UpdateStatusReport
 
@@ -396,12 +727,89 @@ func (s *Service) reportUpdateStatusHandler(c *gin.Context) {
 		return
 	}
 
+	// The device-scoped route takes the device from the path
+	if deviceID := c.Param("deviceId"); deviceID != "" {
+		if report.DeviceID != "" && report.DeviceID != deviceID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id does not match the reporting device"})
+			return
+		}
+		report.DeviceID = deviceID
+	}
+	if report.DeviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required"})
+		return
+	}
+
+	if err := s.authenticateDevice(c.Request, report.DeviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.reportLimits.Allow(report.DeviceID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": ErrReportRateLimited.Error()})
+		return
+	}
+
 	err := s.ReportUpdateStatus(c.Request.Context(), &report)
 	if err != nil {
-		s.logger.Error("Failed to report update status", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, ErrInvalidStatusReport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			s.logger.Error("Failed to report update status", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "update status reported successfully"})
 }
+
+func (s *Service) reportCrashHandler(c *gin.Context) {
+	var req CrashReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := s.RecordCrashReport(c.Request.Context(), c.Param("deviceId"), &req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCrashReport) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s.logger.Error("Failed to record crash report", "device_id", c.Param("deviceId"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+func (s *Service) listCrashReportsHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	limit := 20
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	reports, err := s.ListCrashReports(c.Request.Context(), deviceID, limit)
+	if err != nil {
+		s.logger.Error("Failed to list crash reports", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id":     deviceID,
+		"crash_reports": reports,
+		"count":         len(reports),
+	})
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/athena/platform-lib/internal/device"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/ids"
 	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -84,6 +86,40 @@ func TestService_CreateRelease(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+func TestService_CreateRelease_Names(t *testing.T) {
+	service, mockRepo, _, mockStorage := setupTestService()
+	generator, err := ids.NewGenerator(ids.SchemeULID, true)
+	require.NoError(t, err)
+	service.ids = generator
+
+	binaryData := []byte("test firmware binary data")
+	mockStorage.On("StoreBinary", mock.Anything, mock.AnythingOfType("string"), binaryData).Return("/binaries/release-123.bin", nil)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannel("")).Return([]*FirmwareRelease{
+		{ReleaseID: "release-001", Name: "first-light"},
+	}, nil)
+	mockRepo.On("CreateRelease", mock.Anything, mock.AnythingOfType("*ota.FirmwareRelease")).Return(nil)
+
+	release, err := service.CreateRelease(context.Background(), &CreateReleaseRequest{
+		TemplateID: "template-001",
+		Version:    "1.1.0",
+		Channel:    ReleaseChannelStable,
+		BinaryData: binaryData,
+	})
+	require.NoError(t, err)
+	assert.Len(t, release.ReleaseID, 26)
+	assert.Regexp(t, `^[a-z]+-[a-z]+$`, release.Name)
+
+	_, err = service.CreateRelease(context.Background(), &CreateReleaseRequest{
+		Name:       "first-light",
+		TemplateID: "template-001",
+		Version:    "1.2.0",
+		Channel:    ReleaseChannelStable,
+		BinaryData: binaryData,
+	})
+	assert.ErrorIs(t, err, ErrNameTaken)
+	mockRepo.AssertNumberOfCalls(t, "CreateRelease", 1)
+}
+
 func TestService_CreateRelease_InvalidChannel(t *testing.T) {
 	service, _, _, _ := setupTestService()
 
@@ -311,6 +347,7 @@ func TestService_GetUpdateForDeviceHandler(t *testing.T) {
 	release := createTestRelease("release-001")
 
 	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(deviceUpdate, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{DeploymentID: "deployment-001", Status: DeploymentStatusActive}, nil)
 	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
 	mockStorage.On("GetBinaryURL", mock.Anything, release.BinaryPath, mock.AnythingOfType("time.Duration")).Return("https://storage.example.com/firmware.bin", nil)
 
@@ -331,6 +368,38 @@ func TestService_GetUpdateForDeviceHandler(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+func TestService_ListDeviceUpdateHistoryHandler(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	updates := []*DeviceUpdate{
+		{DeviceID: "device-001", ReleaseID: "release-002", Status: UpdateStatusFailed, StartedAt: time.Now()},
+		{DeviceID: "device-001", ReleaseID: "release-001", Status: UpdateStatusCompleted, StartedAt: time.Now().Add(-time.Hour)},
+	}
+
+	mockRepo.On("ListUpdatesForDevice", mock.Anything, "device-001", 5).Return(updates, nil)
+
+	req, _ := http.NewRequest("GET", "/api/v1/ota/devices/device-001/updates?limit=5", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		DeviceID string          `json:"device_id"`
+		Updates  []*DeviceUpdate `json:"updates"`
+		Count    int             `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "device-001", response.DeviceID)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, UpdateStatusFailed, response.Updates[0].Status)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestService_ReportUpdateStatusHandler(t *testing.T) {
 	service, mockRepo, _, _ := setupTestService()
 
@@ -357,6 +426,7 @@ func TestService_ReportUpdateStatusHandler(t *testing.T) {
 	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
 	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(1, 0, 0, nil)
 	mockRepo.On("UpdateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
 
 	report := UpdateStatusReport{
 		DeviceID:  "device-001",
@@ -422,6 +492,11 @@ func (m *MockRepository) ListReleases(ctx context.Context, templateID string, ch
 	return args.Get(0).([]*FirmwareRelease), args.Error(1)
 }
 
+func (m *MockRepository) UpdateRelease(ctx context.Context, release *FirmwareRelease) error {
+	args := m.Called(ctx, release)
+	return args.Error(0)
+}
+
 func (m *MockRepository) DeleteRelease(ctx context.Context, releaseID string) error {
 	args := m.Called(ctx, releaseID)
 	return args.Error(0)
@@ -466,6 +541,14 @@ func (m *MockRepository) GetLatestUpdateForDevice(ctx context.Context, deviceID
 	return args.Get(0).(*DeviceUpdate), args.Error(1)
 }
 
+func (m *MockRepository) ListUpdatesForDevice(ctx context.Context, deviceID string, limit int) ([]*DeviceUpdate, error) {
+	args := m.Called(ctx, deviceID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*DeviceUpdate), args.Error(1)
+}
+
 func (m *MockRepository) UpdateDeviceUpdate(ctx context.Context, update *DeviceUpdate) error {
 	args := m.Called(ctx, update)
 	return args.Error(0)
@@ -479,6 +562,22 @@ func (m *MockRepository) ListDeviceUpdates(ctx context.Context, deploymentID str
 	return args.Get(0).([]*DeviceUpdate), args.Error(1)
 }
 
+func (m *MockRepository) QueryDeviceUpdates(ctx context.Context, deploymentID string, query *DeviceUpdateQuery) ([]*DeviceUpdate, string, error) {
+	args := m.Called(ctx, deploymentID, query)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]*DeviceUpdate), args.String(1), args.Error(2)
+}
+
+func (m *MockRepository) CountDeviceUpdatesByStatus(ctx context.Context, deploymentID string) (map[UpdateStatus]int, error) {
+	args := m.Called(ctx, deploymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[UpdateStatus]int), args.Error(1)
+}
+
 func (m *MockRepository) GetDeploymentStats(ctx context.Context, deploymentID string) (int, int, int, error) {
 	args := m.Called(ctx, deploymentID)
 	return args.Int(0), args.Int(1), args.Int(2), args.Error(3)
@@ -497,6 +596,32 @@ func (m *MockRepository) ReleaseExists(ctx context.Context, releaseID string) (b
 	return args.Bool(0), args.Error(1)
 }
 
+// ModifyDeployment goes through the GetDeployment and UpdateDeployment
+// expectations, so tests mock those instead
+func (m *MockRepository) ModifyDeployment(ctx context.Context, deploymentID string, modify func(*OTADeployment) error) (*OTADeployment, error) {
+	deployment, err := m.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(deployment); err != nil {
+		return nil, err
+	}
+	return deployment, m.UpdateDeployment(ctx, deployment)
+}
+
+// ModifyDeviceUpdate goes through the GetDeviceUpdate and
+// UpdateDeviceUpdate expectations, so tests mock those instead
+func (m *MockRepository) ModifyDeviceUpdate(ctx context.Context, deviceID, releaseID string, modify func(*DeviceUpdate) error) (*DeviceUpdate, error) {
+	update, err := m.GetDeviceUpdate(ctx, deviceID, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(update); err != nil {
+		return nil, err
+	}
+	return update, m.UpdateDeviceUpdate(ctx, update)
+}
+
 func (m *MockRepository) ListDeployments(ctx context.Context, releaseID string) ([]*OTADeployment, error) {
 	args := m.Called(ctx, releaseID)
 	if args.Get(0) == nil {
@@ -529,6 +654,58 @@ func (m *MockRepository) GetDevicesPendingUpdate(ctx context.Context, deployment
 	return args.Get(0).([]*DeviceUpdate), args.Error(1)
 }
 
+func (m *MockRepository) CreateCrashReport(ctx context.Context, report *CrashReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListCrashReportsForDevice(ctx context.Context, deviceID string, limit int) ([]*CrashReport, error) {
+	args := m.Called(ctx, deviceID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*CrashReport), args.Error(1)
+}
+
+func (m *MockRepository) ListCrashReportsForDeployment(ctx context.Context, deploymentID string) ([]*CrashReport, error) {
+	args := m.Called(ctx, deploymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*CrashReport), args.Error(1)
+}
+
+func (m *MockRepository) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	args := m.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	args := m.Called(ctx, webhookID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Webhook), args.Error(1)
+}
+
+func (m *MockRepository) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Webhook), args.Error(1)
+}
+
+func (m *MockRepository) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	args := m.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteWebhook(ctx context.Context, webhookID string) error {
+	args := m.Called(ctx, webhookID)
+	return args.Error(0)
+}
+
 type MockDeviceRepository struct {
 	mock.Mock
 }
@@ -652,6 +829,23 @@ func (m *MockStorageBackend) GetBinary(ctx context.Context, path string) ([]byte
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockStorageBackend) GetBinaryRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error) {
+	args := m.Called(ctx, path, offset, length)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]byte), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockStorageBackend) OpenBinary(ctx context.Context, path string) (io.ReadCloser, int64, error) {
+	args := m.Called(ctx, path)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(1)
+	}
+	data := args.Get(0).([]byte)
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), args.Error(1)
+}
+
 func (m *MockStorageBackend) GetBinaryURL(ctx context.Context, path string, expiry time.Duration) (string, error) {
 	args := m.Called(ctx, path, expiry)
 	return args.String(0), args.Error(1)
@@ -677,6 +871,7 @@ func TestService_GetUpdateForDevice_Authentication(t *testing.T) {
 	release := createTestRelease("release-001")
 
 	mockRepo.On("GetLatestUpdateForDevice", mock.Anything, "device-001").Return(deviceUpdate, nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(&OTADeployment{DeploymentID: "deployment-001", Status: DeploymentStatusActive}, nil)
 	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
 	mockStorage.On("GetBinaryURL", mock.Anything, release.BinaryPath, mock.AnythingOfType("time.Duration")).Return("https://storage.example.com/firmware.bin", nil)
 
@@ -829,7 +1024,7 @@ func TestService_ReportUpdateStatus_Failure(t *testing.T) {
 	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.MatchedBy(func(update *DeviceUpdate) bool {
 		return update.Status == UpdateStatusFailed && update.ErrorMessage == "Download failed" && update.CompletedAt != nil
 	})).Return(nil)
-	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil).Times(2)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil).Times(3)
 	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-001").Return(0, 1, 0, nil)
 	mockRepo.On("UpdateDeployment", mock.Anything, mock.MatchedBy(func(d *OTADeployment) bool {
 		return d.FailureCount == 1 && d.Status == DeploymentStatusFailed
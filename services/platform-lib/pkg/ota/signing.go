@@ -1,20 +1,62 @@
 package ota
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// KeyStatus is the role of a key the signer verifies with
+type KeyStatus string
+
+const (
+	// KeyStatusSigning marks the key new releases are signed with
+	KeyStatusSigning KeyStatus = "signing"
+	// KeyStatusRetired marks an earlier signing key, kept for verifying
+	// releases signed before a rotation
+	KeyStatusRetired KeyStatus = "retired"
 )
 
+// SigningKey describes a public key the signer verifies signatures with.
+// PublicKey is PEM encoded.
+type SigningKey struct {
+	KeyID       string     `json:"key_id"`
+	Status      KeyStatus  `json:"status"`
+	PublicKey   string     `json:"public_key"`
+	ActivatedAt time.Time  `json:"activated_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// retiredKey is an earlier signing key
+type retiredKey struct {
+	publicKey   *rsa.PublicKey
+	activatedAt time.Time
+	retiredAt   time.Time
+}
+
 // Signer handles firmware binary signing and verification
 type Signer struct {
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
+	mu          sync.RWMutex
+	privateKey  *rsa.PrivateKey
+	publicKey   *rsa.PublicKey
+	activatedAt time.Time
+	// retired holds earlier signing keys, newest first, so releases signed
+	// before a rotation still verify
+	retired []*retiredKey
 }
 
 // NewSigner creates a new Signer with the provided RSA keys
@@ -32,15 +74,210 @@ func NewSigner(privateKeyPEM, publicKeyPEM []byte) (*Signer, error) {
 	}
 
 	return &Signer{
-		privateKey: privateKey,
-		publicKey:  publicKey,
+		privateKey:  privateKey,
+		publicKey:   publicKey,
+		activatedAt: time.Now(),
 	}, nil
 }
 
+// KeyID identifies the current signing key by a fingerprint of its public
+// key
+func (s *Signer) KeyID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return keyID(s.publicKey)
+}
+
+// Rotate makes a new key pair the signing key. The previous public key is
+// kept for verifying releases signed with it.
+func (s *Signer) Rotate(privateKeyPEM, publicKeyPEM []byte) error {
+	return s.rotate(privateKeyPEM, publicKeyPEM, nil)
+}
+
+// RotateAndSave rotates the signing key like Rotate, saving the new set of
+// keys to store first. A key that cannot be saved is not rotated in, so the
+// signer never signs with a key lost on restart. A nil store saves nothing.
+func (s *Signer) RotateAndSave(ctx context.Context, privateKeyPEM, publicKeyPEM []byte, store SigningKeyStore) error {
+	if store == nil {
+		return s.Rotate(privateKeyPEM, publicKeyPEM)
+	}
+	return s.rotate(privateKeyPEM, publicKeyPEM, func(keys []*StoredSigningKey) error {
+		return store.SaveSigningKeys(ctx, keys)
+	})
+}
+
+func (s *Signer) rotate(privateKeyPEM, publicKeyPEM []byte, save func([]*StoredSigningKey) error) error {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse private key: %v", ErrInvalidSigningKey, err)
+	}
+	publicKey, err := parsePublicKey(publicKeyPEM)
+	if err != nil {
+		return fmt.Errorf("%w: failed to parse public key: %v", ErrInvalidSigningKey, err)
+	}
+	if !privateKey.PublicKey.Equal(publicKey) {
+		return fmt.Errorf("%w: public key does not match private key", ErrInvalidSigningKey)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	next := &Signer{privateKey: privateKey, publicKey: publicKey, activatedAt: now}
+	if s.publicKey != nil {
+		if s.publicKey.Equal(publicKey) {
+			return fmt.Errorf("%w: key %s is already the signing key", ErrInvalidSigningKey, keyID(publicKey))
+		}
+		next.retired = []*retiredKey{{publicKey: s.publicKey, activatedAt: s.activatedAt, retiredAt: now}}
+		for _, key := range s.retired {
+			// A key rotated back in is no longer retired
+			if !key.publicKey.Equal(publicKey) {
+				next.retired = append(next.retired, key)
+			}
+		}
+	}
+	if save != nil {
+		if err := save(next.storedKeys()); err != nil {
+			return fmt.Errorf("failed to save signing keys: %w", err)
+		}
+	}
+	s.privateKey = next.privateKey
+	s.publicKey = next.publicKey
+	s.activatedAt = next.activatedAt
+	s.retired = next.retired
+	return nil
+}
+
+// Restore merges the keys of a store with the configured signing key and
+// saves the result back. Rotations made through the API are kept across
+// restarts: when the configured key is one the store has retired, the
+// configuration is stale and the stored signing key signs. A configured key
+// the store does not know was rotated in by configuration and signs, and
+// the stored signing key is retired. Retired keys always keep verifying.
+func (s *Signer) Restore(ctx context.Context, store SigningKeyStore) error {
+	stored, err := store.ListSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configured := keyID(s.publicKey)
+	now := time.Now()
+	var signing *StoredSigningKey
+	var retired []*retiredKey
+	configuredRetired := false
+	for _, key := range stored {
+		if key.Status == KeyStatusSigning {
+			signing = key
+			continue
+		}
+		publicKey, err := parsePublicKey([]byte(key.PublicKey))
+		if err != nil {
+			return fmt.Errorf("stored signing key %s: %w", key.KeyID, err)
+		}
+		retiredAt := now
+		if key.RetiredAt != nil {
+			retiredAt = *key.RetiredAt
+		}
+		if keyID(publicKey) == configured {
+			configuredRetired = true
+		}
+		retired = append(retired, &retiredKey{publicKey: publicKey, activatedAt: key.ActivatedAt, retiredAt: retiredAt})
+	}
+
+	next := &Signer{privateKey: s.privateKey, publicKey: s.publicKey, activatedAt: s.activatedAt}
+	switch {
+	case signing == nil:
+	case signing.KeyID == configured:
+		next.activatedAt = signing.ActivatedAt
+	case configuredRetired:
+		if next.privateKey, err = parsePrivateKey([]byte(signing.PrivateKey)); err != nil {
+			return fmt.Errorf("stored signing key %s: %w", signing.KeyID, err)
+		}
+		next.publicKey = &next.privateKey.PublicKey
+		next.activatedAt = signing.ActivatedAt
+	default:
+		publicKey, err := parsePublicKey([]byte(signing.PublicKey))
+		if err != nil {
+			return fmt.Errorf("stored signing key %s: %w", signing.KeyID, err)
+		}
+		retired = append(retired, &retiredKey{publicKey: publicKey, activatedAt: signing.ActivatedAt, retiredAt: now})
+	}
+	for _, key := range retired {
+		if !key.publicKey.Equal(next.publicKey) {
+			next.retired = append(next.retired, key)
+		}
+	}
+	sort.SliceStable(next.retired, func(i, j int) bool {
+		return next.retired[i].retiredAt.After(next.retired[j].retiredAt)
+	})
+
+	if err := store.SaveSigningKeys(ctx, next.storedKeys()); err != nil {
+		return fmt.Errorf("failed to save signing keys: %w", err)
+	}
+	s.privateKey = next.privateKey
+	s.publicKey = next.publicKey
+	s.activatedAt = next.activatedAt
+	s.retired = next.retired
+	return nil
+}
+
+// Keys lists the signing key followed by the retired keys, newest first.
+// Every listed key verifies signatures; only the signing key signs.
+func (s *Signer) Keys() []*SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []*SigningKey{}
+	for _, key := range s.storedKeys() {
+		keys = append(keys, &key.SigningKey)
+	}
+	return keys
+}
+
+// storedKeys lists the keys as Keys does, the signing key with its private
+// key. Callers hold s.mu or own s.
+func (s *Signer) storedKeys() []*StoredSigningKey {
+	keys := []*StoredSigningKey{}
+	if s.publicKey != nil {
+		key := &StoredSigningKey{SigningKey: SigningKey{
+			KeyID:       keyID(s.publicKey),
+			Status:      KeyStatusSigning,
+			PublicKey:   encodePublicKey(s.publicKey),
+			ActivatedAt: s.activatedAt,
+		}}
+		if s.privateKey != nil {
+			key.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(s.privateKey)}))
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range s.retired {
+		retiredAt := key.retiredAt
+		keys = append(keys, &StoredSigningKey{SigningKey: SigningKey{
+			KeyID:       keyID(key.publicKey),
+			Status:      KeyStatusRetired,
+			PublicKey:   encodePublicKey(key.publicKey),
+			ActivatedAt: key.activatedAt,
+			RetiredAt:   &retiredAt,
+		}})
+	}
+	return keys
+}
+
 // SignBinary signs the firmware binary and returns the signature
 func (s *Signer) SignBinary(binaryData []byte) (string, error) {
+	signature, _, err := s.SignBinaryWithKeyID(binaryData)
+	return signature, err
+}
+
+// SignBinaryWithKeyID signs the firmware binary and returns the signature
+// with the ID of the key that made it, which a concurrent rotation cannot
+// separate
+func (s *Signer) SignBinaryWithKeyID(binaryData []byte) (string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if s.privateKey == nil {
-		return "", fmt.Errorf("private key not configured")
+		return "", "", fmt.Errorf("private key not configured")
 	}
 
 	// Compute SHA-256 hash of the binary
@@ -49,19 +286,47 @@ func (s *Signer) SignBinary(binaryData []byte) (string, error) {
 	// Sign the hash using RSA-PSS
 	signature, err := rsa.SignPSS(rand.Reader, s.privateKey, crypto.SHA256, hash[:], nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign binary: %w", err)
+		return "", "", fmt.Errorf("failed to sign binary: %w", err)
 	}
 
 	// Encode signature as base64
-	return base64.StdEncoding.EncodeToString(signature), nil
+	return base64.StdEncoding.EncodeToString(signature), keyID(s.publicKey), nil
 }
 
-// VerifySignature verifies the signature of a firmware binary
+// VerifySignature verifies the signature of a firmware binary against the
+// current signing key and the keys it replaced
 func (s *Signer) VerifySignature(binaryData []byte, signatureBase64 string) error {
+	return s.VerifySignatureWithKeyID(binaryData, signatureBase64, "")
+}
+
+// VerifySignatureWithKeyID verifies the signature of a firmware binary
+// against the key with the given ID, or against every key when the ID is
+// empty, as for releases signed before key IDs were recorded
+func (s *Signer) VerifySignatureWithKeyID(binaryData []byte, signatureBase64, id string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if s.publicKey == nil {
 		return fmt.Errorf("public key not configured")
 	}
 
+	keys := []*rsa.PublicKey{s.publicKey}
+	for _, key := range s.retired {
+		keys = append(keys, key.publicKey)
+	}
+	if id != "" {
+		var match *rsa.PublicKey
+		for _, key := range keys {
+			if keyID(key) == id {
+				match = key
+				break
+			}
+		}
+		if match == nil {
+			return fmt.Errorf("unknown signing key %s", id)
+		}
+		keys = []*rsa.PublicKey{match}
+	}
+
 	// Decode signature from base64
 	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
 	if err != nil {
@@ -72,12 +337,12 @@ func (s *Signer) VerifySignature(binaryData []byte, signatureBase64 string) erro
 	hash := sha256.Sum256(binaryData)
 
 	// Verify the signature using RSA-PSS
-	err = rsa.VerifyPSS(s.publicKey, crypto.SHA256, hash[:], signature, nil)
-	if err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
+	for _, key := range keys {
+		if err = rsa.VerifyPSS(key, crypto.SHA256, hash[:], signature, nil); err == nil {
+			return nil
+		}
 	}
-
-	return nil
+	return fmt.Errorf("signature verification failed: %w", err)
 }
 
 // ComputeHash computes the SHA-256 hash of the binary data
@@ -115,6 +380,82 @@ func GenerateKeyPair(bits int) (privateKeyPEM, publicKeyPEM []byte, err error) {
 	return privateKeyPEM, publicKeyPEM, nil
 }
 
+// NewSignerFromConfig creates the firmware signer from the ota section of
+// the configuration. Without a configured key pair one is generated,
+// except in production; releases signed with it only verify after a
+// restart if the signer is restored from a SigningKeyStore.
+func NewSignerFromConfig(cfg *config.Config, log *logger.Logger) (*Signer, error) {
+	privateKeyPEM, err := readSigningKey(cfg.OTA.SigningKey, cfg.OTA.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPEM, err := readSigningKey(cfg.OTA.SigningPublicKey, cfg.OTA.SigningPublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case privateKeyPEM == nil && publicKeyPEM == nil:
+		if cfg.Environment == "production" {
+			return nil, fmt.Errorf("a firmware signing key pair is required in production")
+		}
+		if privateKeyPEM, publicKeyPEM, err = GenerateKeyPair(2048); err != nil {
+			return nil, err
+		}
+		log.Warn("No firmware signing key configured, generated one for this run")
+	case privateKeyPEM == nil || publicKeyPEM == nil:
+		return nil, fmt.Errorf("the firmware signing key and public key must be configured together")
+	}
+
+	signer, err := NewSigner(privateKeyPEM, publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !signer.privateKey.PublicKey.Equal(signer.publicKey) {
+		return nil, fmt.Errorf("public key does not match private key")
+	}
+	return signer, nil
+}
+
+// readSigningKey returns a PEM key given inline or read from file, or nil
+// when neither is set
+func readSigningKey(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	return data, nil
+}
+
+// keyID fingerprints a public key as the first 16 hex digits of the SHA-256
+// of its PKIX encoding
+func keyID(publicKey *rsa.PublicKey) string {
+	if publicKey == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// encodePublicKey PEM encodes a public key
+func encodePublicKey(publicKey *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
 // parsePrivateKey parses a PEM-encoded RSA private key
 func parsePrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
 	block, _ := pem.Decode(privateKeyPEM)
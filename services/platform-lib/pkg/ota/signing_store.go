@@ -0,0 +1,164 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// StoredSigningKey is a signing key as kept in a SigningKeyStore. Only the
+// signing key keeps its PEM encoded private key; retired keys only verify.
+type StoredSigningKey struct {
+	SigningKey
+	PrivateKey string `json:"-"`
+}
+
+// SigningKeyStore persists the firmware signing key and the keys it
+// replaced, so rotations survive restarts
+type SigningKeyStore interface {
+	// SaveSigningKeys replaces the stored keys with keys
+	SaveSigningKeys(ctx context.Context, keys []*StoredSigningKey) error
+	// ListSigningKeys returns the keys in the order saved
+	ListSigningKeys(ctx context.Context) ([]*StoredSigningKey, error)
+}
+
+// MemorySigningKeyStore is an in-memory SigningKeyStore
+type MemorySigningKeyStore struct {
+	mu   sync.RWMutex
+	keys []StoredSigningKey
+}
+
+// NewMemorySigningKeyStore creates an empty in-memory signing key store
+func NewMemorySigningKeyStore() *MemorySigningKeyStore {
+	return &MemorySigningKeyStore{}
+}
+
+// SaveSigningKeys replaces the stored keys
+func (m *MemorySigningKeyStore) SaveSigningKeys(ctx context.Context, keys []*StoredSigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = make([]StoredSigningKey, len(keys))
+	for i, key := range keys {
+		m.keys[i] = *key
+	}
+	return nil
+}
+
+// ListSigningKeys returns copies of the stored keys
+func (m *MemorySigningKeyStore) ListSigningKeys(ctx context.Context) ([]*StoredSigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*StoredSigningKey, len(m.keys))
+	for i := range m.keys {
+		copied := m.keys[i]
+		keys[i] = &copied
+	}
+	return keys, nil
+}
+
+// SigningKeyEntity represents the Datastore entity for signing keys. The
+// keys share a parent, so a rotation replaces all of them in one
+// transaction.
+type SigningKeyEntity struct {
+	Status      string    `datastore:"status"`
+	PublicKey   string    `datastore:"public_key,noindex"`
+	PrivateKey  string    `datastore:"private_key,noindex"`
+	Position    int       `datastore:"position"`
+	ActivatedAt time.Time `datastore:"activated_at,noindex"`
+	RetiredAt   time.Time `datastore:"retired_at,noindex"`
+}
+
+// signingKeyring is the parent of the stored signing keys
+var signingKeyring = datastore.NameKey("SigningKeyring", "firmware", nil)
+
+// DatastoreSigningKeyStore implements SigningKeyStore using Google Cloud
+// Datastore. The signing key's private key is stored with it, so access to
+// the SigningKey kind must be limited to the OTA service.
+type DatastoreSigningKeyStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreSigningKeyStore creates a new Datastore signing key store
+func NewDatastoreSigningKeyStore(client *datastore.Client) *DatastoreSigningKeyStore {
+	return &DatastoreSigningKeyStore{client: client}
+}
+
+// SaveSigningKeys replaces the stored keys in one transaction
+func (s *DatastoreSigningKeyStore) SaveSigningKeys(ctx context.Context, keys []*StoredSigningKey) error {
+	entityKeys := make([]*datastore.Key, len(keys))
+	entities := make([]*SigningKeyEntity, len(keys))
+	keep := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		entityKeys[i] = datastore.NameKey("SigningKey", key.KeyID, signingKeyring)
+		entities[i] = &SigningKeyEntity{
+			Status:      string(key.Status),
+			PublicKey:   key.PublicKey,
+			PrivateKey:  key.PrivateKey,
+			Position:    i,
+			ActivatedAt: key.ActivatedAt,
+		}
+		if key.RetiredAt != nil {
+			entities[i].RetiredAt = *key.RetiredAt
+		}
+		keep[key.KeyID] = true
+	}
+
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		query := datastore.NewQuery("SigningKey").Ancestor(signingKeyring).KeysOnly().Transaction(tx)
+		existing, err := s.client.GetAll(ctx, query, nil)
+		if err != nil {
+			return err
+		}
+		var stale []*datastore.Key
+		for _, key := range existing {
+			if !keep[key.Name] {
+				stale = append(stale, key)
+			}
+		}
+		if len(stale) > 0 {
+			if err := tx.DeleteMulti(stale); err != nil {
+				return err
+			}
+		}
+		_, err = tx.PutMulti(entityKeys, entities)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store signing keys in Datastore: %w", err)
+	}
+	return nil
+}
+
+// ListSigningKeys returns the stored keys in the order saved
+func (s *DatastoreSigningKeyStore) ListSigningKeys(ctx context.Context) ([]*StoredSigningKey, error) {
+	query := datastore.NewQuery("SigningKey").Ancestor(signingKeyring).Order("position")
+
+	var entities []SigningKeyEntity
+	entityKeys, err := s.client.GetAll(ctx, query, &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query signing keys from Datastore: %w", err)
+	}
+
+	keys := make([]*StoredSigningKey, len(entities))
+	for i, entity := range entities {
+		keys[i] = &StoredSigningKey{
+			SigningKey: SigningKey{
+				KeyID:       entityKeys[i].Name,
+				Status:      KeyStatus(entity.Status),
+				PublicKey:   entity.PublicKey,
+				ActivatedAt: entity.ActivatedAt,
+			},
+			PrivateKey: entity.PrivateKey,
+		}
+		if !entity.RetiredAt.IsZero() {
+			retiredAt := entity.RetiredAt
+			keys[i].RetiredAt = &retiredAt
+		}
+	}
+	return keys, nil
+}
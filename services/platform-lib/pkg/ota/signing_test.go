@@ -1,8 +1,12 @@
 package ota
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -237,3 +241,35 @@ func TestSigner_SignBinary_EmptyData(t *testing.T) {
 	err = signer.VerifySignature(emptyData, signature)
 	assert.NoError(t, err)
 }
+
+// Test creating the signer from the ota configuration
+func TestNewSignerFromConfig(t *testing.T) {
+	log := logger.New("debug", "test")
+	privateKeyPEM, publicKeyPEM, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privateKeyFile := filepath.Join(dir, "signing.pem")
+	require.NoError(t, os.WriteFile(privateKeyFile, privateKeyPEM, 0600))
+
+	// The private key read from a file, the public key given inline
+	cfg := &config.Config{OTA: config.OTAConfig{SigningKeyFile: privateKeyFile, SigningPublicKey: string(publicKeyPEM)}}
+	signer, err := NewSignerFromConfig(cfg, log)
+	require.NoError(t, err)
+	signature, err := signer.SignBinary([]byte("firmware"))
+	require.NoError(t, err)
+	assert.NoError(t, signer.VerifySignature([]byte("firmware"), signature))
+
+	// Half a key pair, or a mismatched one, is rejected
+	_, err = NewSignerFromConfig(&config.Config{OTA: config.OTAConfig{SigningKeyFile: privateKeyFile}}, log)
+	assert.Error(t, err)
+	_, otherPublicKeyPEM, err := GenerateKeyPair(2048)
+	require.NoError(t, err)
+	cfg.OTA.SigningPublicKey = string(otherPublicKeyPEM)
+	_, err = NewSignerFromConfig(cfg, log)
+	assert.Error(t, err)
+
+	// Without keys production refuses to start
+	_, err = NewSignerFromConfig(&config.Config{Environment: "production"}, log)
+	assert.Error(t, err)
+}
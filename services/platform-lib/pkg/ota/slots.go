@@ -0,0 +1,170 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrInvalidSlots is returned for release slot metadata devices could
+	// not act on
+	ErrInvalidSlots = errors.New("invalid slot metadata")
+
+	// ErrInvalidBootConfirmation is returned for boot confirmations that do
+	// not show the device running the release
+	ErrInvalidBootConfirmation = errors.New("invalid boot confirmation")
+)
+
+// SlotInactive targets whichever A/B slot the device is not running from
+const SlotInactive = "inactive"
+
+// Boot flags of A/B releases
+const (
+	// BootFlagPendingVerify boots the new image pending verification; the
+	// bootloader falls back to the rollback slot unless the firmware marks
+	// it valid
+	BootFlagPendingVerify = "pending_verify"
+	// BootFlagEraseNVS erases the NVS partition before the first boot
+	BootFlagEraseNVS = "erase_nvs"
+)
+
+var bootFlags = map[string]bool{
+	BootFlagPendingVerify: true,
+	BootFlagEraseNVS:      true,
+}
+
+// slotNamePattern matches ESP-IDF app partition names such as ota_0 and
+// factory
+var slotNamePattern = regexp.MustCompile(`^(ota_[0-9]{1,2}|factory)$`)
+
+// SlotMetadata describes how a release is installed on devices with A/B
+// app partitions. TargetSlot is the partition to flash, or SlotInactive,
+// and RollbackSlot the one the bootloader falls back to when the new image
+// fails to boot.
+type SlotMetadata struct {
+	TargetSlot   string   `json:"target_slot"`
+	RollbackSlot string   `json:"rollback_slot,omitempty"`
+	BootFlags    []string `json:"boot_flags,omitempty"`
+}
+
+// validateSlots checks slot names and boot flags. Releases without slot
+// metadata are valid.
+func validateSlots(slots *SlotMetadata) error {
+	if slots == nil {
+		return nil
+	}
+	if slots.TargetSlot != SlotInactive && !slotNamePattern.MatchString(slots.TargetSlot) {
+		return fmt.Errorf("%w: unknown target slot %q", ErrInvalidSlots, slots.TargetSlot)
+	}
+	if slots.RollbackSlot != "" {
+		if !slotNamePattern.MatchString(slots.RollbackSlot) {
+			return fmt.Errorf("%w: unknown rollback slot %q", ErrInvalidSlots, slots.RollbackSlot)
+		}
+		if slots.RollbackSlot == slots.TargetSlot {
+			return fmt.Errorf("%w: rollback slot cannot be the target slot", ErrInvalidSlots)
+		}
+	}
+	for _, flag := range slots.BootFlags {
+		if !bootFlags[flag] {
+			return fmt.Errorf("%w: unknown boot flag %q", ErrInvalidSlots, flag)
+		}
+	}
+	return nil
+}
+
+// BootConfirmation is sent by a device once it has booted a release, as
+// opposed to having flashed it. Slot is the partition it booted from.
+type BootConfirmation struct {
+	ReleaseID string `json:"release_id" binding:"required"`
+	Slot      string `json:"slot,omitempty"`
+}
+
+// ConfirmBoot records that a device booted the release of its update.
+// Updates still installing are completed first; confirming again changes
+// nothing. A device that reports booting the release's rollback slot fell
+// back to its old firmware and should report the update failed instead.
+func (s *Service) ConfirmBoot(ctx context.Context, deviceID string, confirmation *BootConfirmation) (*DeviceUpdate, error) {
+	if confirmation.Slot != "" && !slotNamePattern.MatchString(confirmation.Slot) {
+		return nil, fmt.Errorf("%w: unknown slot %q", ErrInvalidBootConfirmation, confirmation.Slot)
+	}
+
+	update, err := s.repository.GetDeviceUpdate(ctx, deviceID, confirmation.ReleaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device update: %w", err)
+	}
+	if update.BootedAt != nil {
+		return update, nil
+	}
+	if update.Status != UpdateStatusInstalling && update.Status != UpdateStatusCompleted {
+		return nil, fmt.Errorf("%w: cannot confirm the boot of a %s update", ErrInvalidStatusTransition, update.Status)
+	}
+
+	release, err := s.repository.GetRelease(ctx, confirmation.ReleaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	if release.Slots != nil && confirmation.Slot != "" && confirmation.Slot == release.Slots.RollbackSlot {
+		return nil, fmt.Errorf("%w: device booted its rollback slot %s", ErrInvalidBootConfirmation, confirmation.Slot)
+	}
+
+	// Completing the update counts it towards its deployment
+	if update.Status == UpdateStatusInstalling {
+		if err := s.ReportUpdateStatus(ctx, &UpdateStatusReport{
+			DeviceID:  deviceID,
+			ReleaseID: confirmation.ReleaseID,
+			Status:    UpdateStatusCompleted,
+			Progress:  100,
+		}); err != nil {
+			return nil, err
+		}
+		if update, err = s.repository.GetDeviceUpdate(ctx, deviceID, confirmation.ReleaseID); err != nil {
+			return nil, fmt.Errorf("failed to get device update: %w", err)
+		}
+	}
+
+	now := time.Now()
+	update.BootedAt = &now
+	update.BootSlot = confirmation.Slot
+	if err := s.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+		return nil, fmt.Errorf("failed to update device update: %w", err)
+	}
+
+	s.logger.Info("Device confirmed boot", "device_id", deviceID, "release_id", confirmation.ReleaseID, "slot", confirmation.Slot)
+	return update, nil
+}
+
+func (s *Service) confirmBootHandler(c *gin.Context) {
+	var confirmation BootConfirmation
+	if err := c.ShouldBindJSON(&confirmation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deviceID := c.Param("deviceId")
+	if err := s.authenticateDevice(c.Request, deviceID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	update, err := s.ConfirmBoot(c.Request.Context(), deviceID, &confirmation)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidBootConfirmation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			s.logger.Error("Failed to confirm boot", "device_id", deviceID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, update)
+}
@@ -0,0 +1,116 @@
+package ota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSlots(t *testing.T) {
+	assert.NoError(t, validateSlots(nil))
+	assert.NoError(t, validateSlots(&SlotMetadata{TargetSlot: SlotInactive, BootFlags: []string{BootFlagPendingVerify}}))
+	assert.NoError(t, validateSlots(&SlotMetadata{TargetSlot: "ota_1", RollbackSlot: "ota_0"}))
+
+	assert.ErrorIs(t, validateSlots(&SlotMetadata{}), ErrInvalidSlots)
+	assert.ErrorIs(t, validateSlots(&SlotMetadata{TargetSlot: "app1"}), ErrInvalidSlots)
+	assert.ErrorIs(t, validateSlots(&SlotMetadata{TargetSlot: "ota_0", RollbackSlot: "ota_0"}), ErrInvalidSlots)
+	assert.ErrorIs(t, validateSlots(&SlotMetadata{TargetSlot: "ota_0", BootFlags: []string{"turbo"}}), ErrInvalidSlots)
+}
+
+func TestFirmwareRelease_SlotsSurviveStorage(t *testing.T) {
+	release := createTestRelease("release-001")
+	release.Slots = &SlotMetadata{TargetSlot: SlotInactive, RollbackSlot: "factory", BootFlags: []string{BootFlagPendingVerify}}
+
+	entity, err := release.ToEntity()
+	require.NoError(t, err)
+	stored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, release.Slots, stored.Slots)
+}
+
+func TestService_ConfirmBoot(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	release := createTestRelease("release-001")
+	release.Slots = &SlotMetadata{TargetSlot: "ota_1", RollbackSlot: "ota_0", BootFlags: []string{BootFlagPendingVerify}}
+
+	// The device reported the flash written before rebooting
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(&DeviceUpdate{
+		DeviceID:  "device-001",
+		ReleaseID: "release-001",
+		Status:    UpdateStatusCompleted,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.MatchedBy(func(u *DeviceUpdate) bool {
+		return u.BootedAt != nil && u.BootSlot == "ota_1"
+	})).Return(nil).Once()
+
+	update, err := service.ConfirmBoot(context.Background(), "device-001", &BootConfirmation{ReleaseID: "release-001", Slot: "ota_1"})
+	require.NoError(t, err)
+	assert.NotNil(t, update.BootedAt)
+
+	// Confirming again changes nothing
+	_, err = service.ConfirmBoot(context.Background(), "device-001", &BootConfirmation{ReleaseID: "release-001", Slot: "ota_1"})
+	require.NoError(t, err)
+
+	// Booting the rollback slot means the new image did not come up
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-002", "release-001").Return(&DeviceUpdate{
+		DeviceID:  "device-002",
+		ReleaseID: "release-001",
+		Status:    UpdateStatusCompleted,
+	}, nil)
+	_, err = service.ConfirmBoot(context.Background(), "device-002", &BootConfirmation{ReleaseID: "release-001", Slot: "ota_0"})
+	assert.ErrorIs(t, err, ErrInvalidBootConfirmation)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_ConfirmBoot_CompletesInstallingUpdate(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(&DeviceUpdate{
+		DeviceID:     "device-001",
+		ReleaseID:    "release-001",
+		DeploymentID: "deployment-001",
+		Status:       UpdateStatusInstalling,
+	}, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(nil, assert.AnError)
+
+	update, err := service.ConfirmBoot(context.Background(), "device-001", &BootConfirmation{ReleaseID: "release-001"})
+	require.NoError(t, err)
+	assert.Equal(t, UpdateStatusCompleted, update.Status)
+	assert.NotNil(t, update.BootedAt)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDeviceUpdate", 2)
+}
+
+func TestConfirmBootHandler(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(&DeviceUpdate{
+		DeviceID:  "device-001",
+		ReleaseID: "release-001",
+		Status:    UpdateStatusDownloading,
+	}, nil)
+
+	confirm := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ota/updates/device-001/confirm-boot", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, confirm(`{}`))
+	assert.Equal(t, http.StatusBadRequest, confirm(`{"release_id": "release-001", "slot": "app"}`))
+	// An update still downloading has not been flashed
+	assert.Equal(t, http.StatusConflict, confirm(`{"release_id": "release-001"}`))
+}
@@ -0,0 +1,203 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// lastActivity returns when the device last showed progress on an update
+func (u *DeviceUpdate) lastActivity() time.Time {
+	if u.ReportedAt != nil && u.ReportedAt.After(u.StartedAt) {
+		return *u.ReportedAt
+	}
+	return u.StartedAt
+}
+
+// stale reports whether a device has gone quiet on an update it started
+// downloading or installing
+func (u *DeviceUpdate) stale(now time.Time, timeout time.Duration) bool {
+	if u.Status != UpdateStatusDownloading && u.Status != UpdateStatusInstalling {
+		return false
+	}
+	return !u.lastActivity().Add(timeout).After(now)
+}
+
+// StaleUpdateReport lists the devices whose updates one reaper pass
+// requeued or timed out. Timed out updates may still be retried under
+// their deployment's retry policy.
+type StaleUpdateReport struct {
+	Requeued []string `json:"requeued"`
+	TimedOut []string `json:"timed_out"`
+}
+
+// StaleUpdateReaper times out device updates whose device stopped
+// reporting, so that devices going offline mid-update cannot keep their
+// deployments from completing
+type StaleUpdateReaper struct {
+	service  *Service
+	settings config.StaleUpdatesConfig
+	logger   *logger.Logger
+	now      func() time.Time
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewStaleUpdateReaper creates a reaper for the device updates of service,
+// with the service's stale_updates settings
+func NewStaleUpdateReaper(service *Service, logger *logger.Logger) *StaleUpdateReaper {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var settings config.StaleUpdatesConfig
+	if service.config != nil {
+		settings = service.config.StaleUpdates
+	}
+
+	return &StaleUpdateReaper{
+		service:  service,
+		settings: settings,
+		logger:   logger,
+		now:      time.Now,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start reaps stale updates every interval until Stop is called. It does
+// nothing if the timeout is zero.
+func (r *StaleUpdateReaper) Start(interval time.Duration) {
+	if r.settings.Timeout <= 0 {
+		r.logger.Info("Stale update reaper disabled")
+		return
+	}
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	r.wg.Add(1)
+	go r.reapLoop(interval)
+	r.logger.Info("Stale update reaper started", "interval", interval, "timeout", r.settings.Timeout, "max_requeues", r.settings.MaxRequeues)
+}
+
+// Stop stops the reaper
+func (r *StaleUpdateReaper) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	r.logger.Info("Stale update reaper stopped")
+}
+
+func (r *StaleUpdateReaper) reapLoop(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Run(r.ctx); err != nil {
+				r.logger.Error("Stale update pass failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run requeues or fails every stale update of the deployments still in
+// progress once
+func (r *StaleUpdateReaper) Run(ctx context.Context) (*StaleUpdateReport, error) {
+	if r.service.repository == nil {
+		return nil, fmt.Errorf("deployment repository not configured")
+	}
+	deployments, err := r.service.repository.ListDeployments(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	report := &StaleUpdateReport{Requeued: []string{}, TimedOut: []string{}}
+	now := r.now()
+	for _, deployment := range deployments {
+		switch deployment.Status {
+		case DeploymentStatusActive, DeploymentStatusPaused, DeploymentStatusCancelled:
+		default:
+			continue
+		}
+		if err := r.reapDeployment(ctx, deployment, now, report); err != nil {
+			r.logger.Error("Failed to reap stale updates", "deployment_id", deployment.DeploymentID, "error", err)
+		}
+	}
+
+	if len(report.Requeued) > 0 || len(report.TimedOut) > 0 {
+		r.logger.Info("Reaped stale device updates", "requeued", len(report.Requeued), "timed_out", len(report.TimedOut))
+	}
+	return report, nil
+}
+
+// reapDeployment handles the stale updates of one deployment. Updates of a
+// cancelled deployment are never requeued.
+func (r *StaleUpdateReaper) reapDeployment(ctx context.Context, deployment *OTADeployment, now time.Time, report *StaleUpdateReport) error {
+	var stale []*DeviceUpdate
+	for _, status := range []UpdateStatus{UpdateStatusDownloading, UpdateStatusInstalling} {
+		updates, err := r.service.repository.GetDeviceUpdatesByStatus(ctx, deployment.DeploymentID, status)
+		if err != nil {
+			return fmt.Errorf("failed to get %s device updates: %w", status, err)
+		}
+		for _, update := range updates {
+			if update.stale(now, r.settings.Timeout) {
+				stale = append(stale, update)
+			}
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	timedOut := 0
+	var failed []*DeviceUpdate
+	for _, update := range stale {
+		update.ErrorType = UpdateErrorTimeout
+		update.ErrorMessage = fmt.Sprintf("no status report for %s while %s", r.settings.Timeout, update.Status)
+
+		if deployment.Status != DeploymentStatusCancelled && update.attempts() <= r.settings.MaxRequeues {
+			update.restart(now)
+			if err := r.service.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+				return fmt.Errorf("failed to requeue update for device %s: %w", update.DeviceID, err)
+			}
+			r.logger.Warn("Requeued stale device update", "device_id", update.DeviceID, "deployment_id", update.DeploymentID, "attempt", update.Attempts)
+			report.Requeued = append(report.Requeued, update.DeviceID)
+			continue
+		}
+
+		// The deployment's retry policy may still give it another attempt
+		update.Status = UpdateStatusFailed
+		update.CompletedAt = &now
+		r.service.scheduleRetry(ctx, update)
+		if err := r.service.repository.UpdateDeviceUpdate(ctx, update); err != nil {
+			return fmt.Errorf("failed to time out update for device %s: %w", update.DeviceID, err)
+		}
+		r.logger.Warn("Timed out stale device update", "device_id", update.DeviceID, "deployment_id", update.DeploymentID, "status", update.Status)
+		report.TimedOut = append(report.TimedOut, update.DeviceID)
+		timedOut++
+		if update.Status == UpdateStatusFailed {
+			failed = append(failed, update)
+		}
+	}
+
+	// Requeued updates still count as pending, so only time outs change
+	// the deployment statistics
+	if timedOut == 0 {
+		return nil
+	}
+	if err := r.service.updateDeploymentStats(ctx, deployment.DeploymentID); err != nil {
+		return err
+	}
+	for _, update := range failed {
+		r.service.handleUpdateFailure(ctx, update)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceUpdate_Stale(t *testing.T) {
+	now := time.Now()
+	reported := now.Add(-10 * time.Minute)
+
+	assert.True(t, (&DeviceUpdate{Status: UpdateStatusDownloading, StartedAt: now.Add(-time.Hour)}).stale(now, time.Hour))
+	assert.False(t, (&DeviceUpdate{Status: UpdateStatusDownloading, StartedAt: now.Add(-time.Hour), ReportedAt: &reported}).stale(now, time.Hour))
+	assert.False(t, (&DeviceUpdate{Status: UpdateStatusPending, StartedAt: now.Add(-2 * time.Hour)}).stale(now, time.Hour))
+	assert.False(t, (&DeviceUpdate{Status: UpdateStatusFailed, StartedAt: now.Add(-2 * time.Hour)}).stale(now, time.Hour))
+}
+
+func TestStaleUpdateReaper_Run(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	service.config.StaleUpdates = config.StaleUpdatesConfig{Timeout: time.Hour, MaxRequeues: 1}
+	ctx := context.Background()
+
+	now := time.Now()
+	quiet := now.Add(-2 * time.Hour)
+	recent := now.Add(-10 * time.Minute)
+	deployment := &OTADeployment{DeploymentID: "deployment-1", ReleaseID: "release-1", Status: DeploymentStatusActive, FailureThreshold: 50}
+	finished := &OTADeployment{DeploymentID: "deployment-2", ReleaseID: "release-1", Status: DeploymentStatusCompleted}
+	offline := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusDownloading, Attempts: 1, Progress: 40, StartedAt: quiet}
+	requeued := &DeviceUpdate{DeviceID: "device-2", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusInstalling, Attempts: 2, StartedAt: quiet, ReportedAt: &quiet}
+	reporting := &DeviceUpdate{DeviceID: "device-3", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusDownloading, Attempts: 1, StartedAt: quiet, ReportedAt: &recent}
+
+	mockRepo.On("ListDeployments", mock.Anything, "").Return([]*OTADeployment{deployment, finished}, nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-1", UpdateStatusDownloading).Return([]*DeviceUpdate{offline, reporting}, nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-1", UpdateStatusInstalling).Return([]*DeviceUpdate{requeued}, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-1").Return(deployment, nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-1").Return(3, 1, 1, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+
+	reaper := NewStaleUpdateReaper(service, logger.New("info", "test"))
+	reaper.now = func() time.Time { return now }
+	report, err := reaper.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-1"}, report.Requeued)
+	assert.Equal(t, []string{"device-2"}, report.TimedOut)
+
+	// A first stale attempt is offered to the device again
+	assert.Equal(t, UpdateStatusPending, offline.Status)
+	assert.Equal(t, 2, offline.Attempts)
+	assert.Equal(t, 0, offline.Progress)
+	assert.Equal(t, UpdateErrorTimeout, offline.ErrorType)
+	assert.True(t, offline.due(now))
+
+	// Once requeues run out the update fails and the deployment counts it
+	assert.Equal(t, UpdateStatusFailed, requeued.Status)
+	assert.Equal(t, UpdateErrorTimeout, requeued.ErrorType)
+	assert.Contains(t, requeued.ErrorMessage, "installing")
+	require.NotNil(t, requeued.CompletedAt)
+	assert.Equal(t, 1, deployment.FailureCount)
+	mockRepo.AssertNumberOfCalls(t, "UpdateDeployment", 1)
+
+	assert.Equal(t, UpdateStatusDownloading, reporting.Status)
+	mockRepo.AssertNotCalled(t, "GetDeviceUpdatesByStatus", mock.Anything, "deployment-2", mock.Anything)
+}
+
+func TestStaleUpdateReaper_CancelledDeployment(t *testing.T) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	service.config.StaleUpdates = config.StaleUpdatesConfig{Timeout: time.Hour, MaxRequeues: 3}
+
+	quiet := time.Now().Add(-2 * time.Hour)
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-1",
+		ReleaseID:    "release-1",
+		Status:       DeploymentStatusCancelled,
+		RetryPolicy:  &RetryPolicy{MaxAttempts: 5},
+	}
+	update := &DeviceUpdate{DeviceID: "device-1", ReleaseID: "release-1", DeploymentID: "deployment-1", Status: UpdateStatusDownloading, Attempts: 1, StartedAt: quiet}
+
+	mockRepo.On("ListDeployments", mock.Anything, "").Return([]*OTADeployment{deployment}, nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-1", UpdateStatusDownloading).Return([]*DeviceUpdate{update}, nil)
+	mockRepo.On("GetDeviceUpdatesByStatus", mock.Anything, "deployment-1", UpdateStatusInstalling).Return([]*DeviceUpdate{}, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-1").Return(deployment, nil)
+	mockRepo.On("GetDeploymentStats", mock.Anything, "deployment-1").Return(0, 1, 0, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+
+	// Updates of a cancelled deployment are neither requeued nor retried,
+	// and the deployment stays cancelled
+	report, err := NewStaleUpdateReaper(service, logger.New("info", "test")).Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Requeued)
+	assert.Equal(t, []string{"device-1"}, report.TimedOut)
+	assert.Equal(t, UpdateStatusFailed, update.Status)
+	assert.Equal(t, DeploymentStatusCancelled, deployment.Status)
+}
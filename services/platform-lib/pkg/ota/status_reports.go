@@ -0,0 +1,182 @@
+package ota
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/middleware"
+)
+
+var (
+	// ErrInvalidStatusReport is returned for reports with an unknown
+	// status or a progress outside 0-100
+	ErrInvalidStatusReport = errors.New("invalid update status report")
+
+	// ErrInvalidStatusTransition is returned for reports that would move
+	// an update backwards, such as completed to downloading
+	ErrInvalidStatusTransition = errors.New("invalid update status transition")
+
+	// ErrDeviceUnauthorized is returned when a request does not carry a
+	// credential of the requesting device
+	ErrDeviceUnauthorized = errors.New("device credentials required")
+
+	// ErrReportRateLimited is returned when a device reports faster than
+	// update_reports allows
+	ErrReportRateLimited = errors.New("too many update status reports")
+)
+
+// updateTransitions lists the statuses each status may move to. A device
+// may skip ahead, since it can finish a step between reports, but never go
+// back. Completed, failed and cancelled are final.
+var updateTransitions = map[UpdateStatus][]UpdateStatus{
+	UpdateStatusPending:     {UpdateStatusDownloading, UpdateStatusInstalling, UpdateStatusCompleted, UpdateStatusFailed},
+	UpdateStatusDownloading: {UpdateStatusInstalling, UpdateStatusCompleted, UpdateStatusFailed},
+	UpdateStatusInstalling:  {UpdateStatusCompleted, UpdateStatusFailed},
+}
+
+// validateStatusReport checks a report's fields and that it follows from
+// the update's current status. Repeating the current status is allowed.
+func validateStatusReport(current UpdateStatus, report *UpdateStatusReport) error {
+	if !isUpdateStatus(report.Status) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusReport, report.Status)
+	}
+	if report.Progress < 0 || report.Progress > 100 {
+		return fmt.Errorf("%w: progress must be between 0 and 100", ErrInvalidStatusReport)
+	}
+	if report.Downloaded < 0 {
+		return fmt.Errorf("%w: downloaded bytes cannot be negative", ErrInvalidStatusReport)
+	}
+	if report.ErrorType != "" && !isUpdateErrorType(report.ErrorType) {
+		return fmt.Errorf("%w: unknown error type %q", ErrInvalidStatusReport, report.ErrorType)
+	}
+	if report.ErrorCode != "" {
+		errorType, ok := updateErrorCodeTypes[report.ErrorCode]
+		if !ok {
+			return fmt.Errorf("%w: unknown error code %q", ErrInvalidStatusReport, report.ErrorCode)
+		}
+		if report.ErrorType != "" && report.ErrorType != errorType {
+			return fmt.Errorf("%w: error code %s is a %s error, not %s", ErrInvalidStatusReport, report.ErrorCode, errorType, report.ErrorType)
+		}
+	}
+	if report.Status == current {
+		return nil
+	}
+	for _, next := range updateTransitions[current] {
+		if report.Status == next {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, current, report.Status)
+}
+
+// updateReportSettings returns the update report configuration with
+// defaults applied. Token checks stay off unless configured.
+func updateReportSettings(cfg *config.Config) config.UpdateReportsConfig {
+	var settings config.UpdateReportsConfig
+	if cfg != nil {
+		settings = cfg.UpdateReports
+	}
+	if settings.Burst <= 0 {
+		settings.Burst = 10
+	}
+	if settings.PerSecond <= 0 {
+		settings.PerSecond = 1
+	}
+	return settings
+}
+
+// authenticateDevice checks that r carries a credential of deviceID, or of
+// a gateway deviceID sits behind, when update_reports.require_device_token
+// or credentials.require_device_auth is set. A credential is a device token
+// as a bearer token or a device certificate as the TLS client certificate.
+// Failures are not told apart, so callers cannot probe for device IDs.
+func (s *Service) authenticateDevice(r *http.Request, deviceID string) error {
+	if !s.requireDeviceAuth() {
+		return nil
+	}
+	if s.deviceRepository == nil {
+		return ErrDeviceUnauthorized
+	}
+
+	ok, err := device.AuthenticateRequest(r.Context(), s.deviceRepository, deviceID, r)
+	if err != nil {
+		s.logger.Warn("Failed to look up reporting device", "device_id", deviceID, "error", err)
+	}
+	if !ok {
+		return ErrDeviceUnauthorized
+	}
+	return nil
+}
+
+// authenticateUpdateCheck authenticates an update check like
+// authenticateDevice, but only with credentials.require_device_auth, since
+// update_reports.require_device_token never covered update checks
+func (s *Service) authenticateUpdateCheck(r *http.Request, deviceID string) error {
+	if s.config == nil || !s.config.Credentials.RequireDeviceAuth {
+		return nil
+	}
+	return s.authenticateDevice(r, deviceID)
+}
+
+// requireDeviceAuth reports whether device requests must carry device
+// credentials
+func (s *Service) requireDeviceAuth() bool {
+	return updateReportSettings(s.config).RequireDeviceToken || (s.config != nil && s.config.Credentials.RequireDeviceAuth)
+}
+
+// maxReportBuckets bounds the devices a report limiter tracks. Without
+// device authentication, reports can name any device ID.
+const maxReportBuckets = 10000
+
+// reportLimiter keeps a token bucket per device. Once maxBuckets devices
+// are tracked, buckets that have refilled are dropped, as a new bucket is
+// the same; if none has, reports from untracked devices are refused until
+// one does.
+type reportLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*middleware.TokenBucket
+	burst      int
+	perSecond  int
+	maxBuckets int
+}
+
+func newReportLimiter(settings config.UpdateReportsConfig) *reportLimiter {
+	return &reportLimiter{
+		buckets:    make(map[string]*middleware.TokenBucket),
+		burst:      settings.Burst,
+		perSecond:  settings.PerSecond,
+		maxBuckets: maxReportBuckets,
+	}
+}
+
+// Allow takes a report from deviceID's bucket. A nil limiter allows all.
+func (l *reportLimiter) Allow(deviceID string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	bucket, ok := l.buckets[deviceID]
+	if !ok {
+		if len(l.buckets) >= l.maxBuckets {
+			for id, idle := range l.buckets {
+				if idle.GetAvailableTokens() >= l.burst {
+					delete(l.buckets, id)
+				}
+			}
+			if len(l.buckets) >= l.maxBuckets {
+				l.mu.Unlock()
+				return false
+			}
+		}
+		bucket = middleware.NewTokenBucket(l.burst, l.perSecond)
+		l.buckets[deviceID] = bucket
+	}
+	l.mu.Unlock()
+
+	return bucket.TakeToken()
+}
@@ -0,0 +1,172 @@
+package ota
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStatusReport(t *testing.T) {
+	tests := []struct {
+		current UpdateStatus
+		report  UpdateStatusReport
+		want    error
+	}{
+		{UpdateStatusPending, UpdateStatusReport{Status: UpdateStatusDownloading, Progress: 10}, nil},
+		{UpdateStatusPending, UpdateStatusReport{Status: UpdateStatusCompleted, Progress: 100}, nil},
+		{UpdateStatusDownloading, UpdateStatusReport{Status: UpdateStatusDownloading, Progress: 80}, nil},
+		{UpdateStatusCompleted, UpdateStatusReport{Status: UpdateStatusCompleted, Progress: 100}, nil},
+		{UpdateStatusCompleted, UpdateStatusReport{Status: UpdateStatusDownloading}, ErrInvalidStatusTransition},
+		{UpdateStatusFailed, UpdateStatusReport{Status: UpdateStatusInstalling}, ErrInvalidStatusTransition},
+		{UpdateStatusInstalling, UpdateStatusReport{Status: UpdateStatusPending}, ErrInvalidStatusTransition},
+		{UpdateStatusPending, UpdateStatusReport{Status: "rebooting"}, ErrInvalidStatusReport},
+		{UpdateStatusPending, UpdateStatusReport{Status: UpdateStatusDownloading, Progress: 140}, ErrInvalidStatusReport},
+		{UpdateStatusInstalling, UpdateStatusReport{Status: UpdateStatusFailed, ErrorCode: UpdateErrorCodeFlashWriteError}, nil},
+		{UpdateStatusInstalling, UpdateStatusReport{Status: UpdateStatusFailed, ErrorType: UpdateErrorVerification, ErrorCode: UpdateErrorCodeHashMismatch}, nil},
+		{UpdateStatusInstalling, UpdateStatusReport{Status: UpdateStatusFailed, ErrorCode: "bricked"}, ErrInvalidStatusReport},
+		{UpdateStatusInstalling, UpdateStatusReport{Status: UpdateStatusFailed, ErrorType: UpdateErrorDownload, ErrorCode: UpdateErrorCodeBootVerifyFailed}, ErrInvalidStatusReport},
+		{UpdateStatusDownloading, UpdateStatusReport{Status: UpdateStatusDownloading, Downloaded: 512}, nil},
+		{UpdateStatusDownloading, UpdateStatusReport{Status: UpdateStatusDownloading, Downloaded: -1}, ErrInvalidStatusReport},
+	}
+
+	for _, tt := range tests {
+		err := validateStatusReport(tt.current, &tt.report)
+		if tt.want == nil {
+			assert.NoError(t, err, "%s -> %s", tt.current, tt.report.Status)
+		} else {
+			assert.True(t, errors.Is(err, tt.want), "%s -> %s: %v", tt.current, tt.report.Status, err)
+		}
+	}
+}
+
+func TestService_ReportUpdateStatus_DeviceAuth(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupTestService()
+	service.config.UpdateReports = config.UpdateReportsConfig{RequireDeviceToken: true}
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	sum := sha256.Sum256([]byte("device-1-token"))
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(&device.Device{
+		DeviceID: "device-001",
+		Credentials: map[string]*device.Credential{
+			"mqtt": {Type: device.CredentialTypeToken, Fingerprint: hex.EncodeToString(sum[:]), ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}, nil)
+
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusCompleted}
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+
+	report := func(path, token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	body := `{"release_id": "release-001", "status": "failed"}`
+
+	assert.Equal(t, http.StatusUnauthorized, report("/api/v1/ota/devices/device-001/updates/status", "", body))
+	assert.Equal(t, http.StatusUnauthorized, report("/api/v1/ota/devices/device-001/updates/status", "device-2-token", body))
+	assert.Equal(t, http.StatusBadRequest, report("/api/v1/ota/devices/device-001/updates/status", "device-1-token",
+		`{"device_id": "device-002", "release_id": "release-001", "status": "failed"}`))
+
+	// Authenticated, but a completed update cannot fail afterwards
+	assert.Equal(t, http.StatusConflict, report("/api/v1/ota/devices/device-001/updates/status", "device-1-token", body))
+	assert.Equal(t, http.StatusConflict, report("/api/v1/ota/updates/status", "device-1-token",
+		`{"device_id": "device-001", "release_id": "release-001", "status": "downloading"}`))
+
+	// Repeating a final status is accepted and changes nothing
+	assert.Equal(t, http.StatusOK, report("/api/v1/ota/devices/device-001/updates/status", "device-1-token",
+		`{"release_id": "release-001", "status": "completed", "progress": 100}`))
+	mockRepo.AssertNotCalled(t, "UpdateDeviceUpdate", mock.Anything, mock.Anything)
+}
+
+func TestService_GetUpdateForDevice_DeviceCertificate(t *testing.T) {
+	service, _, mockDeviceRepo, _ := setupTestService()
+	service.config.Credentials = config.CredentialsConfig{RequireDeviceAuth: true}
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	authority, err := device.GenerateCertificateAuthority()
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certificate, err := authority.Sign("device-001", &key.PublicKey, time.Hour)
+	require.NoError(t, err)
+	sum := sha256.Sum256(certificate.Raw)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-001").Return(&device.Device{
+		DeviceID: "device-001",
+		Credentials: map[string]*device.Credential{
+			device.DeviceCertificateCredential: {Type: device.CredentialTypeCertificate, Fingerprint: hex.EncodeToString(sum[:]), ExpiresAt: certificate.NotAfter},
+		},
+	}, nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-002").Return(&device.Device{DeviceID: "device-002"}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ota/updates/device-001", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ota/updates/device-001", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}
+	assert.NoError(t, service.authenticateUpdateCheck(req, "device-001"))
+	assert.ErrorIs(t, service.authenticateUpdateCheck(req, "device-002"), ErrDeviceUnauthorized)
+}
+
+func TestService_ReportUpdateStatus_RateLimited(t *testing.T) {
+	service, mockRepo, _, _ := setupTestService()
+	service.reportLimits = newReportLimiter(config.UpdateReportsConfig{Burst: 2, PerSecond: 1})
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	update := &DeviceUpdate{DeviceID: "device-001", ReleaseID: "release-001", DeploymentID: "deployment-001", Status: UpdateStatusDownloading}
+	mockRepo.On("GetDeviceUpdate", mock.Anything, "device-001", "release-001").Return(update, nil)
+	mockRepo.On("UpdateDeviceUpdate", mock.Anything, update).Return(nil)
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ota/devices/device-001/updates/status",
+			bytes.NewBufferString(`{"release_id": "release-001", "status": "downloading", "progress": 50}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestReportLimiter_BoundsDevices(t *testing.T) {
+	limiter := newReportLimiter(config.UpdateReportsConfig{Burst: 2, PerSecond: 1})
+	limiter.maxBuckets = 2
+
+	assert.True(t, limiter.Allow("device-001"))
+	assert.True(t, limiter.Allow("device-002"))
+	// Both buckets are in use, so a third device is refused
+	assert.False(t, limiter.Allow("device-003"))
+	assert.Len(t, limiter.buckets, 2)
+
+	// A refilled bucket makes room
+	limiter.buckets["device-001"] = middleware.NewTokenBucket(2, 1)
+	assert.True(t, limiter.Allow("device-003"))
+	assert.Len(t, limiter.buckets, 2)
+}
@@ -2,7 +2,9 @@ package ota
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -58,6 +60,48 @@ func (s *LocalStorageBackend) GetBinary(ctx context.Context, path string) ([]byt
 	return data, nil
 }
 
+// OpenBinary opens a binary file on the local filesystem for streaming
+func (s *LocalStorageBackend) OpenBinary(ctx context.Context, path string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(filepath.Join(s.basePath, path))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open binary file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat binary file: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+// GetBinaryRange reads part of a binary file from the local filesystem.
+// Reads past the end of the file are cut short.
+func (s *LocalStorageBackend) GetBinaryRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error) {
+	file, err := os.Open(filepath.Join(s.basePath, path))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open binary file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to stat binary file: %w", err)
+	}
+	size := info.Size()
+	if offset < 0 || offset > size {
+		return nil, size, fmt.Errorf("offset %d is outside the %d byte binary", offset, size)
+	}
+	if length > size-offset {
+		length = size - offset
+	}
+
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, size, fmt.Errorf("failed to read binary file: %w", err)
+	}
+	return data, size, nil
+}
+
 // GetBinaryURL returns a URL for accessing the binary (for local storage, returns file path)
 func (s *LocalStorageBackend) GetBinaryURL(ctx context.Context, path string, expiry time.Duration) (string, error) {
 	fullPath := filepath.Join(s.basePath, path)
@@ -0,0 +1,260 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidTargets is returned for deployment groups and label selectors
+// that cannot be resolved
+var ErrInvalidTargets = errors.New("invalid deployment targets")
+
+// TargetResolution lists the devices a deployment gained and lost when its
+// groups and labels were resolved again, and how many of the gained ones
+// were offered the update right away
+type TargetResolution struct {
+	DeploymentID   string   `json:"deployment_id"`
+	Added          []string `json:"added"`
+	Removed        []string `json:"removed"`
+	UpdatesCreated int      `json:"updates_created"`
+}
+
+// changed reports whether the deployment's targets changed
+func (r *TargetResolution) changed() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0
+}
+
+// targeted reports whether a deployment configuration selects devices by
+// group or label
+func (c *DeploymentConfig) targeted() bool {
+	return len(c.TargetGroups) > 0 || len(c.TargetLabels) > 0
+}
+
+// SetDeviceGroups lets deployments target the device groups managed by the
+// device service, next to the groups in the configuration
+func (s *Service) SetDeviceGroups(groups device.GroupStore) {
+	s.deviceGroups = groups
+}
+
+// deviceGroup returns the device group called name, looking in the
+// configuration before the managed groups
+func (s *Service) deviceGroup(ctx context.Context, name string) (*config.DeviceGroupConfig, error) {
+	if s.config != nil {
+		for i := range s.config.DeviceGroups {
+			if s.config.DeviceGroups[i].Name == name {
+				return &s.config.DeviceGroups[i], nil
+			}
+		}
+	}
+	if s.deviceGroups != nil {
+		group, err := s.deviceGroups.GetGroup(ctx, name)
+		if err == nil {
+			return &config.DeviceGroupConfig{Name: group.Name, Devices: group.Devices, Labels: group.Labels}, nil
+		}
+		if !errors.Is(err, device.ErrGroupNotFound) {
+			return nil, fmt.Errorf("failed to get device group %s: %w", name, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown device group %q", ErrInvalidTargets, name)
+}
+
+// validateTargets checks the groups and label selector of a deployment
+// configuration. Rolling targets are resolved from groups and labels
+// alone, so they cannot be combined with a device list.
+func (s *Service) validateTargets(ctx context.Context, config *DeploymentConfig) error {
+	for _, name := range config.TargetGroups {
+		if _, err := s.deviceGroup(ctx, name); err != nil {
+			return err
+		}
+	}
+	for key := range config.TargetLabels {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: label selector keys cannot be empty", ErrInvalidTargets)
+		}
+	}
+	if config.RollingTargets {
+		if !config.targeted() {
+			return fmt.Errorf("%w: rolling targets need target groups or labels", ErrInvalidTargets)
+		}
+		if len(config.TargetDevices) > 0 {
+			return fmt.Errorf("%w: rolling targets cannot be combined with target devices", ErrInvalidTargets)
+		}
+	}
+	return nil
+}
+
+// resolveTargets lists the devices of a release's template that belong to
+// any of groups or match labels, in registry order
+func (s *Service) resolveTargets(ctx context.Context, release *FirmwareRelease, groups []string, labels map[string]string) ([]*device.Device, error) {
+	if s.deviceRepository == nil {
+		return nil, ErrDeviceRepositoryUnavailable
+	}
+	selected := make([]*config.DeviceGroupConfig, 0, len(groups))
+	for _, name := range groups {
+		group, err := s.deviceGroup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, group)
+	}
+
+	devices, err := s.deviceRepository.ListDevices(ctx, &device.DeviceFilters{TemplateID: release.TemplateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	// Devices enrolled manually only match groups that list them
+	var targets []*device.Device
+	for _, dev := range devices {
+		auto := dev.AutoEnrolled()
+		match := auto && len(labels) > 0 && dev.MatchesLabels(labels)
+		for _, group := range selected {
+			if match {
+				break
+			}
+			match = slices.Contains(group.Devices, dev.DeviceID) || (auto && len(group.Labels) > 0 && dev.MatchesLabels(group.Labels))
+		}
+		if match {
+			targets = append(targets, dev)
+		}
+	}
+	return targets, nil
+}
+
+// autoEnrolled returns the devices that join deployments without being
+// named in them
+func autoEnrolled(devices []*device.Device) []*device.Device {
+	enrolled := make([]*device.Device, 0, len(devices))
+	for _, dev := range devices {
+		if dev.AutoEnrolled() {
+			enrolled = append(enrolled, dev)
+		}
+	}
+	return enrolled
+}
+
+// mergeTargets appends the devices of extra not already in targets
+func mergeTargets(targets, extra []string) []string {
+	seen := make(map[string]bool, len(targets))
+	for _, deviceID := range targets {
+		seen[deviceID] = true
+	}
+	for _, deviceID := range extra {
+		if !seen[deviceID] {
+			seen[deviceID] = true
+			targets = append(targets, deviceID)
+		}
+	}
+	return targets
+}
+
+// ResolveDeploymentTargets resolves the groups and labels of a deployment
+// with rolling targets again. Devices that joined them are added to the
+// deployment and, while it rolls out, offered the update up to its rollout
+// percentage; devices that left them are dropped unless they were already
+// offered the update.
+func (s *Service) ResolveDeploymentTargets(ctx context.Context, deploymentID string) (*TargetResolution, error) {
+	deployment, err := s.repository.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	if !deployment.RollingTargets {
+		return nil, fmt.Errorf("%w: deployment %s does not have rolling targets", ErrInvalidTargets, deploymentID)
+	}
+	if !deployment.inProgress() {
+		return nil, fmt.Errorf("%w: deployment %s is %s", ErrInvalidTargets, deploymentID, deployment.Status)
+	}
+
+	release, err := s.repository.GetRelease(ctx, deployment.ReleaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	devices, err := s.resolveTargets(ctx, release, deployment.TargetGroups, deployment.TargetLabels)
+	if err != nil {
+		return nil, err
+	}
+	resolved, skipped := splitByCapacity(devices, release)
+	updates, err := s.repository.ListDeviceUpdates(ctx, deploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device updates: %w", err)
+	}
+	cohort := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		cohort[update.DeviceID] = true
+	}
+	matching := make(map[string]bool, len(resolved))
+	for _, deviceID := range resolved {
+		matching[deviceID] = true
+	}
+
+	resolution := &TargetResolution{DeploymentID: deploymentID, Added: []string{}, Removed: []string{}}
+	now := time.Now()
+	deployment, err = s.repository.ModifyDeployment(ctx, deploymentID, func(d *OTADeployment) error {
+		resolution.Added, resolution.Removed = []string{}, []string{}
+		kept := make([]string, 0, len(resolved))
+		for _, deviceID := range d.TargetDevices {
+			if matching[deviceID] || cohort[deviceID] {
+				kept = append(kept, deviceID)
+			} else {
+				resolution.Removed = append(resolution.Removed, deviceID)
+			}
+		}
+		targets := mergeTargets(kept, resolved)
+		resolution.Added = append(resolution.Added, targets[len(kept):]...)
+		if resolution.changed() {
+			d.TargetDevices = targets
+			d.UpdatedAt = now
+		}
+		d.SkippedDevices = mergeSkipped(d.SkippedDevices, skipped, targets)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update deployment targets: %w", err)
+	}
+	if !resolution.changed() {
+		return resolution, nil
+	}
+
+	if deployment.rollingOut() {
+		percentage := deployment.RolloutPercentage
+		if deployment.Strategy == DeploymentStrategyImmediate {
+			percentage = 100
+		}
+		additions := cohortAdditions(deployment, cohort, percentage)
+		s.addToCohort(ctx, deployment, additions, now)
+		resolution.UpdatesCreated = len(additions)
+	}
+
+	s.logger.Info("Resolved deployment targets", "deployment_id", deploymentID, "added", len(resolution.Added),
+		"removed", len(resolution.Removed), "updates_created", resolution.UpdatesCreated)
+
+	return resolution, nil
+}
+
+// resolveDeploymentTargetsHandler resolves the groups and labels of a
+// deployment with rolling targets now, rather than on the promoter's next
+// pass
+func (s *Service) resolveDeploymentTargetsHandler(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	resolution, err := s.ResolveDeploymentTargets(c.Request.Context(), deploymentID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidTargets) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resolution)
+}
@@ -0,0 +1,183 @@
+package ota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func targetingDevices() []*device.Device {
+	return []*device.Device{
+		{DeviceID: "device-001", Labels: map[string]string{"region": "eu", "hw_rev": "2"}},
+		{DeviceID: "device-002", Labels: map[string]string{"region": "eu", "hw_rev": "1"}},
+		{DeviceID: "device-003", Labels: map[string]string{"region": "us", "hw_rev": "2"}},
+		{DeviceID: "device-004"},
+	}
+}
+
+func TestService_DeployRelease_TargetGroupsAndLabels(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	service.config.DeviceGroups = []config.DeviceGroupConfig{
+		{Name: "pilot", Devices: []string{"device-004"}, Labels: map[string]string{"region": "us"}},
+	}
+	release := createTestRelease("release-001")
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("GetDeployment", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.MatchedBy(func(filters *device.DeviceFilters) bool {
+		return filters.TemplateID == release.TemplateID && filters.OTAChannel == ""
+	})).Return(targetingDevices(), nil)
+	mockDeviceRepo.On("GetDevice", mock.Anything, "device-009").Return(nil, assert.AnError)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:      DeploymentStrategyImmediate,
+		TargetDevices: []string{"device-009"},
+		TargetGroups:  []string{"pilot"},
+		TargetLabels:  map[string]string{"region": "eu", "hw_rev": "2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-009", "device-001", "device-003", "device-004"}, deployment.TargetDevices)
+	assert.Equal(t, []string{"pilot"}, deployment.TargetGroups)
+
+	for _, invalid := range []*DeploymentConfig{
+		{Strategy: DeploymentStrategyImmediate, TargetGroups: []string{"missing"}},
+		{Strategy: DeploymentStrategyImmediate, TargetLabels: map[string]string{" ": "eu"}},
+		{Strategy: DeploymentStrategyImmediate, RollingTargets: true},
+		{Strategy: DeploymentStrategyImmediate, RollingTargets: true, TargetGroups: []string{"pilot"}, TargetDevices: []string{"device-009"}},
+	} {
+		_, err := service.DeployRelease(context.Background(), "release-001", invalid)
+		assert.ErrorIs(t, err, ErrInvalidTargets)
+	}
+}
+
+func TestService_DeployRelease_ManagedDeviceGroup(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	groups := device.NewMemoryGroupStore()
+	require.NoError(t, groups.CreateGroup(context.Background(), &device.DeviceGroup{
+		Name: "lab", Devices: []string{"device-004"}, Labels: map[string]string{"hw_rev": "1"},
+	}))
+	service.SetDeviceGroups(groups)
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return(targetingDevices(), nil)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:     DeploymentStrategyImmediate,
+		TargetGroups: []string{"lab"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-002", "device-004"}, deployment.TargetDevices)
+
+	_, err = service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:     DeploymentStrategyImmediate,
+		TargetGroups: []string{"missing"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidTargets)
+}
+
+func TestService_DeployRelease_SkipsManuallyEnrolledDevices(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	service.config.DeviceGroups = []config.DeviceGroupConfig{
+		{Name: "pilot", Devices: []string{"device-003"}, Labels: map[string]string{"region": "eu"}},
+	}
+	devices := []*device.Device{
+		{DeviceID: "device-001", Labels: map[string]string{"region": "eu"}, OTAEnrollment: device.OTAEnrollmentAuto},
+		{DeviceID: "device-002", Labels: map[string]string{"region": "eu"}, OTAEnrollment: device.OTAEnrollmentManual},
+		{DeviceID: "device-003", OTAEnrollment: device.OTAEnrollmentManual},
+		{DeviceID: "device-004"},
+	}
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return(devices, nil)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.AnythingOfType("*ota.DeviceUpdate")).Return(nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+
+	// Channel-wide deployments leave manually enrolled devices out
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{Strategy: DeploymentStrategyImmediate})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-001", "device-004"}, deployment.TargetDevices)
+
+	// Groups that list a manually enrolled device name it
+	deployment, err = service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:     DeploymentStrategyImmediate,
+		TargetGroups: []string{"pilot"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-001", "device-003"}, deployment.TargetDevices)
+}
+
+func TestService_ResolveDeploymentTargets(t *testing.T) {
+	service, mockRepo, mockDeviceRepo, _ := setupDeploymentTestService()
+	ctx := context.Background()
+	release := createTestRelease("release-001")
+
+	deployment := &OTADeployment{
+		DeploymentID: "deployment-001", ReleaseID: "release-001", Strategy: DeploymentStrategyStaged,
+		Status: DeploymentStatusActive, RolloutPercentage: 70, RollingTargets: true,
+		TargetLabels:  map[string]string{"region": "eu"},
+		TargetDevices: []string{"device-001", "device-005", "device-006"},
+	}
+	cohort := []*DeviceUpdate{{DeviceID: "device-005", DeploymentID: "deployment-001", Status: UpdateStatusCompleted}}
+
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-001").Return(deployment, nil)
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(release, nil)
+	mockRepo.On("ListDeviceUpdates", mock.Anything, "deployment-001").Return(cohort, nil)
+	mockRepo.On("UpdateDeployment", mock.Anything, deployment).Return(nil)
+	mockDeviceRepo.On("ListDevices", mock.Anything, mock.AnythingOfType("*device.DeviceFilters")).Return(targetingDevices(), nil)
+	mockRepo.On("CreateDeviceUpdate", mock.Anything, mock.MatchedBy(func(update *DeviceUpdate) bool {
+		return update.DeviceID == "device-001" && update.DeploymentID == "deployment-001"
+	})).Return(nil).Once()
+
+	// device-005 left the selector after its update and is kept;
+	// device-006 left it before and is dropped
+	resolution, err := service.ResolveDeploymentTargets(ctx, "deployment-001")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"device-002"}, resolution.Added)
+	assert.Equal(t, []string{"device-006"}, resolution.Removed)
+	assert.Equal(t, []string{"device-001", "device-005", "device-002"}, deployment.TargetDevices)
+	assert.Equal(t, 1, resolution.UpdatesCreated)
+	mockRepo.AssertExpectations(t)
+
+	fixed := &OTADeployment{DeploymentID: "deployment-002", Status: DeploymentStatusActive, TargetDevices: []string{"device-001"}}
+	mockRepo.On("GetDeployment", mock.Anything, "deployment-002").Return(fixed, nil)
+	_, err = service.ResolveDeploymentTargets(ctx, "deployment-002")
+	assert.ErrorIs(t, err, ErrInvalidTargets)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/ota/deployments/deployment-002/resolve-targets", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOTADeployment_TargetsEntityRoundTrip(t *testing.T) {
+	deployment := &OTADeployment{
+		DeploymentID:   "deployment-001",
+		TargetDevices:  []string{"device-001"},
+		TargetGroups:   []string{"pilot"},
+		TargetLabels:   map[string]string{"region": "eu"},
+		RollingTargets: true,
+	}
+	entity, err := deployment.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, deployment.TargetGroups, restored.TargetGroups)
+	assert.Equal(t, deployment.TargetLabels, restored.TargetLabels)
+	assert.True(t, restored.RollingTargets)
+}
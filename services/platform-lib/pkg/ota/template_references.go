@@ -0,0 +1,37 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+// TemplateReferences finds the firmware releases built from a template
+// version, so the template service can refuse to delete versions still in
+// use. Releases that did not record their template version are not found.
+type TemplateReferences struct {
+	repository Repository
+}
+
+// NewTemplateReferences creates a template reference finder over repository
+func NewTemplateReferences(repository Repository) *TemplateReferences {
+	return &TemplateReferences{repository: repository}
+}
+
+// FindTemplateReferences lists the releases, on any channel, built from a
+// template version
+func (f *TemplateReferences) FindTemplateReferences(ctx context.Context, templateID, version string) ([]template.Reference, error) {
+	releases, err := f.repository.ListReleases(ctx, templateID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	var references []template.Reference
+	for _, release := range releases {
+		if release.TemplateVersion == version {
+			references = append(references, template.Reference{Kind: template.ReferenceRelease, ID: release.ReleaseID})
+		}
+	}
+	return references, nil
+}
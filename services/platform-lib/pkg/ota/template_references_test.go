@@ -0,0 +1,24 @@
+package ota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateReferences_FindTemplateReferences(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("ListReleases", mock.Anything, "template-001", ReleaseChannel("")).Return([]*FirmwareRelease{
+		{ReleaseID: "release-003", TemplateID: "template-001", TemplateVersion: "1.1.0", Channel: ReleaseChannelBeta},
+		{ReleaseID: "release-002", TemplateID: "template-001", TemplateVersion: "1.0.0", Channel: ReleaseChannelStable},
+		{ReleaseID: "release-001", TemplateID: "template-001"},
+	}, nil)
+
+	references, err := NewTemplateReferences(mockRepo).FindTemplateReferences(context.Background(), "template-001", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []template.Reference{{Kind: template.ReferenceRelease, ID: "release-002"}}, references)
+}
@@ -0,0 +1,460 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookEventType names an OTA event posted to webhooks
+type WebhookEventType string
+
+const (
+	WebhookDeploymentCreated   WebhookEventType = "deployment.created"
+	WebhookDeploymentCompleted WebhookEventType = "deployment.completed"
+	WebhookDeploymentFailed    WebhookEventType = "deployment.failed"
+	WebhookRollbackTriggered   WebhookEventType = "rollback.triggered"
+	WebhookDeviceUpdateFailed  WebhookEventType = "device.update.failed"
+)
+
+var webhookEventTypes = []WebhookEventType{
+	WebhookDeploymentCreated,
+	WebhookDeploymentCompleted,
+	WebhookDeploymentFailed,
+	WebhookRollbackTriggered,
+	WebhookDeviceUpdateFailed,
+}
+
+const (
+	// WebhookEventHeader carries the type of a delivered event
+	WebhookEventHeader = "X-Athena-Event"
+
+	// WebhookDeliveryHeader carries the event ID, which is the same on every
+	// attempt so receivers can drop repeated deliveries
+	WebhookDeliveryHeader = "X-Athena-Delivery"
+)
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrInvalidWebhook is returned for webhook requests that cannot be stored
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// WebhookRequest creates or replaces a webhook. Enabled defaults to true.
+// A secret is generated for new webhooks created without one; on update,
+// an empty secret keeps the current one.
+type WebhookRequest struct {
+	URL         string             `json:"url" binding:"required"`
+	Events      []WebhookEventType `json:"events,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enabled     *bool              `json:"enabled,omitempty"`
+	Secret      string             `json:"secret,omitempty"`
+	CreatedBy   string             `json:"created_by,omitempty"`
+}
+
+// WebhookEvent is the body posted to webhooks. Its signature, the
+// HMAC-SHA256 of the body with the webhook's secret, is sent in
+// X-Athena-Signature.
+type WebhookEvent struct {
+	ID        string                 `json:"id"`
+	Type      WebhookEventType       `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// receives reports whether a webhook is sent events of a type
+func (w *Webhook) receives(eventType WebhookEventType) bool {
+	if !w.Enabled {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns a copy of the webhook without its secret
+func (w *Webhook) redacted() *Webhook {
+	copied := *w
+	copied.Secret = ""
+	return &copied
+}
+
+// CreateWebhook validates and stores a new webhook. The returned webhook
+// holds its secret, which is not shown again.
+func (s *Service) CreateWebhook(ctx context.Context, req *WebhookRequest) (*Webhook, error) {
+	now := time.Now()
+	webhook := &Webhook{
+		WebhookID: uuid.New().String(),
+		Enabled:   true,
+		CreatedBy: req.CreatedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyWebhookRequest(webhook, req); err != nil {
+		return nil, err
+	}
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		webhook.Secret = secret
+	}
+
+	if err := s.repository.CreateWebhook(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.logger.Info("Created webhook", "webhook_id", webhook.WebhookID, "url", webhook.URL, "events", webhook.Events)
+
+	return webhook, nil
+}
+
+// GetWebhook returns a webhook without its secret
+func (s *Service) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	webhook, err := s.repository.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.redacted(), nil
+}
+
+// ListWebhooks returns all webhooks without their secrets
+func (s *Service) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	webhooks, err := s.repository.ListWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	redacted := make([]*Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		redacted = append(redacted, webhook.redacted())
+	}
+	return redacted, nil
+}
+
+// UpdateWebhook replaces a webhook's settings
+func (s *Service) UpdateWebhook(ctx context.Context, webhookID string, req *WebhookRequest) (*Webhook, error) {
+	webhook, err := s.repository.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookRequest(webhook, req); err != nil {
+		return nil, err
+	}
+	webhook.UpdatedAt = time.Now()
+
+	if err := s.repository.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	s.logger.Info("Updated webhook", "webhook_id", webhook.WebhookID, "url", webhook.URL, "events", webhook.Events, "enabled", webhook.Enabled)
+
+	return webhook.redacted(), nil
+}
+
+// DeleteWebhook removes a webhook; events already being delivered to it
+// are still sent
+func (s *Service) DeleteWebhook(ctx context.Context, webhookID string) error {
+	if _, err := s.repository.GetWebhook(ctx, webhookID); err != nil {
+		return err
+	}
+	if err := s.repository.DeleteWebhook(ctx, webhookID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	s.logger.Info("Deleted webhook", "webhook_id", webhookID)
+
+	return nil
+}
+
+// StopWebhooks abandons pending delivery retries and waits for deliveries
+// in progress
+func (s *Service) StopWebhooks() {
+	if s.webhooks != nil {
+		s.webhooks.stop()
+	}
+}
+
+// applyWebhookRequest validates a request and copies it onto webhook
+func applyWebhookRequest(webhook *Webhook, req *WebhookRequest) error {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	for _, eventType := range req.Events {
+		if !isWebhookEventType(eventType) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, eventType)
+		}
+	}
+
+	webhook.URL = req.URL
+	webhook.Events = req.Events
+	webhook.Description = req.Description
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	return nil
+}
+
+func isWebhookEventType(eventType WebhookEventType) bool {
+	for _, known := range webhookEventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+func newWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// emitWebhookEvent posts an event to the webhooks that receive it, in the
+// background. Services without a dispatcher, as in tests, send nothing.
+func (s *Service) emitWebhookEvent(eventType WebhookEventType, data map[string]interface{}) {
+	if s.webhooks == nil {
+		return
+	}
+	s.webhooks.dispatch(s.repository, &WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+}
+
+// webhookDispatcher delivers events to webhooks, retrying failed deliveries
+// with exponential backoff
+type webhookDispatcher struct {
+	client   *http.Client
+	settings config.WebhooksConfig
+	logger   *logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWebhookDispatcher(cfg *config.Config, logger *logger.Logger) *webhookDispatcher {
+	settings := cfg.Webhooks
+	if settings.Timeout <= 0 {
+		settings.Timeout = 10 * time.Second
+	}
+	if settings.MaxAttempts <= 0 {
+		settings.MaxAttempts = 1
+	}
+	if settings.InitialBackoff <= 0 {
+		settings.InitialBackoff = time.Second
+	}
+	if settings.MaxBackoff < settings.InitialBackoff {
+		settings.MaxBackoff = settings.InitialBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookDispatcher{
+		client:   &http.Client{Timeout: settings.Timeout},
+		settings: settings,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// dispatch looks up the webhooks that receive an event and delivers it to
+// each of them
+func (d *webhookDispatcher) dispatch(repo Repository, event *WebhookEvent) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), d.settings.Timeout)
+		webhooks, err := repo.ListWebhooks(ctx)
+		cancel()
+		if err != nil {
+			d.logger.Warn("Failed to list webhooks", "event", event.Type, "event_id", event.ID, "error", err)
+			return
+		}
+
+		body, err := json.Marshal(event)
+		if err != nil {
+			d.logger.Warn("Failed to encode webhook event", "event", event.Type, "event_id", event.ID, "error", err)
+			return
+		}
+
+		for _, webhook := range webhooks {
+			if !webhook.receives(event.Type) {
+				continue
+			}
+			d.wg.Add(1)
+			go func(webhook *Webhook) {
+				defer d.wg.Done()
+				d.deliver(webhook, event, body)
+			}(webhook)
+		}
+	}()
+}
+
+// deliver posts an event to a webhook until it is accepted, fails for good
+// or runs out of attempts
+func (d *webhookDispatcher) deliver(webhook *Webhook, event *WebhookEvent, body []byte) {
+	backoff := d.settings.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(webhook, event, body)
+		if err == nil {
+			d.logger.Debug("Delivered webhook event", "webhook_id", webhook.WebhookID, "event", event.Type, "event_id", event.ID, "attempts", attempt)
+			return
+		}
+		if !retry || attempt >= d.settings.MaxAttempts {
+			d.logger.Warn("Webhook delivery failed", "webhook_id", webhook.WebhookID, "event", event.Type, "event_id", event.ID, "attempts", attempt, "error", err)
+			return
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > d.settings.MaxBackoff {
+			backoff = d.settings.MaxBackoff
+		}
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying: network errors, timeouts, rate limiting and server errors are
+func (d *webhookDispatcher) post(webhook *Webhook, event *WebhookEvent, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event.Type))
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	req.Header.Set(notifications.SignatureHeader, notifications.Sign([]byte(webhook.Secret), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post event: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+}
+
+func (d *webhookDispatcher) stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (s *Service) createWebhookHandler(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := s.CreateWebhook(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (s *Service) listWebhooksHandler(c *gin.Context) {
+	webhooks, err := s.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	})
+}
+
+func (s *Service) getWebhookHandler(c *gin.Context) {
+	webhook, err := s.GetWebhook(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (s *Service) updateWebhookHandler(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook, err := s.UpdateWebhook(c.Request.Context(), c.Param("webhookId"), &req)
+	if err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (s *Service) deleteWebhookHandler(c *gin.Context) {
+	if err := s.DeleteWebhook(c.Request.Context(), c.Param("webhookId")); err != nil {
+		c.JSON(webhookErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted successfully", "webhook_id": c.Param("webhookId")})
+}
+
+// webhookErrorStatus maps webhook errors to HTTP status codes
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrWebhookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidWebhook):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,202 @@
+package ota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the events posted to it, failing the first
+// failures requests with failStatus
+type webhookReceiver struct {
+	mu         sync.Mutex
+	failures   int
+	failStatus int
+	attempts   int
+	requests   []*http.Request
+	bodies     [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		w.WriteHeader(r.failStatus)
+		return
+	}
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+}
+
+func setupWebhookTestService(t *testing.T) (*Service, *MockRepository) {
+	service, mockRepo, _, _ := setupDeploymentTestService()
+	service.webhooks = newWebhookDispatcher(&config.Config{Webhooks: config.WebhooksConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+	}}, service.logger)
+	t.Cleanup(service.StopWebhooks)
+	return service, mockRepo
+}
+
+func TestWebhooks_DeliverSignedEvents(t *testing.T) {
+	service, mockRepo := setupWebhookTestService(t)
+
+	receiver := &webhookReceiver{failures: 2, failStatus: http.StatusServiceUnavailable}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	mockRepo.On("ListWebhooks", mock.Anything).Return([]*Webhook{
+		{WebhookID: "webhook-001", URL: server.URL, Secret: "ci-secret", Enabled: true, Events: []WebhookEventType{WebhookDeviceUpdateFailed}},
+		{WebhookID: "webhook-002", URL: server.URL, Secret: "other", Enabled: true, Events: []WebhookEventType{WebhookDeploymentCreated}},
+		{WebhookID: "webhook-003", URL: server.URL, Secret: "other", Enabled: false},
+	}, nil)
+
+	service.emitWebhookEvent(WebhookDeviceUpdateFailed, map[string]interface{}{"device_id": "device-001"})
+	service.webhooks.wg.Wait()
+
+	// Delivered on the third attempt, to the subscribed webhook only
+	assert.Equal(t, 3, receiver.attempts)
+	require.Len(t, receiver.requests, 1)
+	req, body := receiver.requests[0], receiver.bodies[0]
+	assert.Equal(t, string(WebhookDeviceUpdateFailed), req.Header.Get(WebhookEventHeader))
+	assert.True(t, notifications.VerifySignature([]byte("ci-secret"), body, req.Header.Get(notifications.SignatureHeader)))
+
+	var event WebhookEvent
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, req.Header.Get(WebhookDeliveryHeader), event.ID)
+	assert.Equal(t, WebhookDeviceUpdateFailed, event.Type)
+	assert.Equal(t, "device-001", event.Data["device_id"])
+}
+
+func TestWebhooks_RetryLimits(t *testing.T) {
+	service, mockRepo := setupWebhookTestService(t)
+
+	unavailable := &webhookReceiver{failures: 10, failStatus: http.StatusBadGateway}
+	unavailableServer := httptest.NewServer(unavailable)
+	defer unavailableServer.Close()
+	rejecting := &webhookReceiver{failures: 10, failStatus: http.StatusUnauthorized}
+	rejectingServer := httptest.NewServer(rejecting)
+	defer rejectingServer.Close()
+
+	mockRepo.On("ListWebhooks", mock.Anything).Return([]*Webhook{
+		{WebhookID: "webhook-001", URL: unavailableServer.URL, Enabled: true},
+		{WebhookID: "webhook-002", URL: rejectingServer.URL, Enabled: true},
+	}, nil)
+
+	service.emitWebhookEvent(WebhookDeploymentFailed, map[string]interface{}{"deployment_id": "deployment-001"})
+	service.webhooks.wg.Wait()
+
+	// Server errors are retried up to max_attempts, client errors are not
+	assert.Equal(t, 3, unavailable.attempts)
+	assert.Equal(t, 1, rejecting.attempts)
+}
+
+func TestService_DeployRelease_EmitsWebhookEvent(t *testing.T) {
+	service, mockRepo := setupWebhookTestService(t)
+	service.config.Approval.Channels = []string{"stable"}
+
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	mockRepo.On("GetRelease", mock.Anything, "release-001").Return(createTestRelease("release-001"), nil)
+	mockRepo.On("CreateDeployment", mock.Anything, mock.AnythingOfType("*ota.OTADeployment")).Return(nil)
+	mockRepo.On("ListWebhooks", mock.Anything).Return([]*Webhook{{WebhookID: "webhook-001", URL: server.URL, Enabled: true}}, nil)
+	service.deviceRepository.(*MockDeviceRepository).On("GetDevice", mock.Anything, "device-001").Return(&device.Device{DeviceID: "device-001"}, nil)
+
+	deployment, err := service.DeployRelease(context.Background(), "release-001", &DeploymentConfig{
+		Strategy:      DeploymentStrategyImmediate,
+		TargetDevices: []string{"device-001"},
+	})
+	require.NoError(t, err)
+	service.webhooks.wg.Wait()
+
+	require.Len(t, receiver.bodies, 1)
+	var event WebhookEvent
+	require.NoError(t, json.Unmarshal(receiver.bodies[0], &event))
+	assert.Equal(t, WebhookDeploymentCreated, event.Type)
+	assert.Equal(t, deployment.DeploymentID, event.Data["deployment_id"])
+	assert.Equal(t, "release-001", event.Data["release_id"])
+	assert.Equal(t, string(DeploymentStatusPendingApproval), event.Data["status"])
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	service, mockRepo := setupWebhookTestService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload []byte
+		if body != nil {
+			var err error
+			payload, err = json.Marshal(body)
+			require.NoError(t, err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/api/v1/ota"+path, bytes.NewReader(payload)))
+		return w
+	}
+
+	var stored *Webhook
+	mockRepo.On("CreateWebhook", mock.Anything, mock.AnythingOfType("*ota.Webhook")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*Webhook)
+	}).Return(nil)
+
+	w := send(http.MethodPost, "/webhooks", WebhookRequest{URL: "ftp://ci.example.com/hook"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(http.MethodPost, "/webhooks", WebhookRequest{URL: "https://ci.example.com/hook", Events: []WebhookEventType{"deployment.exploded"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(http.MethodPost, "/webhooks", WebhookRequest{URL: "https://ci.example.com/hook", Events: []WebhookEventType{WebhookDeploymentCompleted}})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, created.Enabled)
+	assert.Len(t, created.Secret, 64)
+	assert.Equal(t, stored.Secret, created.Secret)
+
+	// The secret is only shown on creation
+	mockRepo.On("GetWebhook", mock.Anything, created.WebhookID).Return(stored, nil)
+	mockRepo.On("GetWebhook", mock.Anything, "missing").Return(nil, ErrWebhookNotFound)
+	mockRepo.On("ListWebhooks", mock.Anything).Return([]*Webhook{stored}, nil)
+
+	w = send(http.MethodGet, "/webhooks/"+created.WebhookID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret)
+	w = send(http.MethodGet, "/webhooks", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/webhooks/missing", nil).Code)
+
+	disabled := false
+	mockRepo.On("UpdateWebhook", mock.Anything, stored).Return(nil)
+	w = send(http.MethodPut, "/webhooks/"+created.WebhookID, WebhookRequest{URL: "https://ci.example.com/v2", Enabled: &disabled})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "https://ci.example.com/v2", stored.URL)
+	assert.False(t, stored.Enabled)
+	assert.Empty(t, stored.Events)
+	assert.Equal(t, created.Secret, stored.Secret)
+
+	mockRepo.On("DeleteWebhook", mock.Anything, created.WebhookID).Return(nil)
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "/webhooks/"+created.WebhookID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/webhooks/missing", nil).Code)
+	mockRepo.AssertCalled(t, "DeleteWebhook", mock.Anything, created.WebhookID)
+}
@@ -0,0 +1,21 @@
+package provisioning
+
+import (
+	"context"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+// AdminTasks returns the provisioning service's operational tasks
+func (s *Service) AdminTasks() []admin.Task {
+	return []admin.Task{
+		{
+			Name:        "gc",
+			Description: "Delete expired and unreadable firmware artifacts",
+			Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+				deleted, err := s.artifactManager.CleanupExpiredArtifacts(ctx)
+				return admin.Result{"artifacts_deleted": deleted}, err
+			},
+		},
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -13,6 +15,7 @@ import (
 type ArduinoCLI struct {
 	cliPath string
 	timeout time.Duration
+	env     []string // extra environment, e.g. separate data directories
 }
 
 // NewArduinoCLI creates a new Arduino CLI wrapper
@@ -26,6 +29,20 @@ func NewArduinoCLI(cliPath string) *ArduinoCLI {
 	}
 }
 
+// WithDirectories returns a wrapper whose commands keep cores, libraries
+// and downloads under dir, apart from those of the default installation
+func (a *ArduinoCLI) WithDirectories(dir string) *ArduinoCLI {
+	return &ArduinoCLI{
+		cliPath: a.cliPath,
+		timeout: a.timeout,
+		env: []string{
+			"ARDUINO_DIRECTORIES_DATA=" + filepath.Join(dir, "data"),
+			"ARDUINO_DIRECTORIES_DOWNLOADS=" + filepath.Join(dir, "downloads"),
+			"ARDUINO_DIRECTORIES_USER=" + filepath.Join(dir, "user"),
+		},
+	}
+}
+
 // Board represents an Arduino board
 type Board struct {
 	FQBN         string            `json:"fqbn"`
@@ -87,6 +104,9 @@ func (a *ArduinoCLI) ExecuteCommand(ctx context.Context, args ...string) ([]byte
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, a.cliPath, args...)
+	if len(a.env) > 0 {
+		cmd.Env = append(os.Environ(), a.env...)
+	}
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -126,6 +146,33 @@ func (a *ArduinoCLI) InstallCore(ctx context.Context, core string) error {
 	return err
 }
 
+// LatestCoreVersion returns the latest released version of a core
+// platform, e.g. "esp32:esp32"
+func (a *ArduinoCLI) LatestCoreVersion(ctx context.Context, core string) (string, error) {
+	output, err := a.ExecuteCommand(ctx, "core", "search", core, "--format", "json")
+	if err != nil {
+		return "", err
+	}
+
+	var searchResponse struct {
+		Platforms []struct {
+			ID            string `json:"id"`
+			LatestVersion string `json:"latest_version"`
+		} `json:"platforms"`
+	}
+
+	if err := json.Unmarshal(output, &searchResponse); err != nil {
+		return "", fmt.Errorf("failed to parse core search output: %w", err)
+	}
+
+	for _, platform := range searchResponse.Platforms {
+		if platform.ID == core {
+			return platform.LatestVersion, nil
+		}
+	}
+	return "", fmt.Errorf("core %s not found", core)
+}
+
 // ListBoards returns available boards
 func (a *ArduinoCLI) ListBoards(ctx context.Context) ([]Board, error) {
 	output, err := a.ExecuteCommand(ctx, "board", "listall", "--format", "json")
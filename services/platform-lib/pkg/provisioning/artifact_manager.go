@@ -190,8 +190,9 @@ func (am *ArtifactManager) DeleteArtifact(ctx context.Context, artifactID string
 	return nil
 }
 
-// CleanupExpiredArtifacts removes expired artifacts
-func (am *ArtifactManager) CleanupExpiredArtifacts(ctx context.Context) error {
+// CleanupExpiredArtifacts removes expired and unreadable artifacts and
+// returns how many it removed
+func (am *ArtifactManager) CleanupExpiredArtifacts(ctx context.Context) (int, error) {
 	now := time.Now()
 	var deletedCount int
 
@@ -206,13 +207,15 @@ func (am *ArtifactManager) CleanupExpiredArtifacts(ctx context.Context) error {
 				// Delete invalid artifacts
 				os.RemoveAll(filepath.Dir(path))
 				deletedCount++
-				return nil
+				// The rest of the artifact directory is gone
+				return filepath.SkipDir
 			}
 
 			// Delete expired artifacts
 			if now.After(artifact.ExpiresAt) {
 				os.RemoveAll(filepath.Dir(path))
 				deletedCount++
+				return filepath.SkipDir
 			}
 		}
 
@@ -220,10 +223,10 @@ func (am *ArtifactManager) CleanupExpiredArtifacts(ctx context.Context) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to cleanup artifacts: %w", err)
+		return deletedCount, fmt.Errorf("failed to cleanup artifacts: %w", err)
 	}
 
-	return nil
+	return deletedCount, nil
 }
 
 // GetArtifactBinary returns the binary data for an artifact
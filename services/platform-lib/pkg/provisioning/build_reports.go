@@ -0,0 +1,105 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+)
+
+const buildReportTimeout = 10 * time.Second
+
+// BuildReporter records the outcome of user compiles with the template
+// service, for the analytics of the template's author
+type BuildReporter struct {
+	baseURL string
+	client  *http.Client
+	logger  *logger.Logger
+}
+
+// NewBuildReporter creates a reporter for the template service at baseURL
+func NewBuildReporter(baseURL string, logger *logger.Logger) *BuildReporter {
+	return &BuildReporter{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: buildReportTimeout},
+		logger:  logger,
+	}
+}
+
+// buildRecord describes a compile that ran for the template service. Only
+// compiles of a known template version are recorded; secrets are never
+// part of the record.
+func buildRecord(request *CompilationRequest, result *CompilationResult) *athenatemplate.BuildRecord {
+	if request.TemplateID == "" || request.TemplateVersion == "" || result == nil {
+		return nil
+	}
+
+	record := &athenatemplate.BuildRecord{
+		TemplateVersion: request.TemplateVersion,
+		Board:           request.Board,
+		Source:          athenatemplate.BuildSourceCompile,
+		Parameters:      request.Parameters,
+		Success:         result.Success,
+	}
+	for _, library := range request.Libraries {
+		record.Libraries = append(record.Libraries, athenatemplate.LibraryDependency{Name: library.Name, Version: library.Version})
+	}
+	if !result.Success {
+		record.Error = "build failed"
+		if len(result.Errors) > 0 {
+			record.Error = result.Errors[0].Message
+		}
+	}
+	return record
+}
+
+// Report records a compile in the background. Compiles that did not run,
+// e.g. because the CLI is missing, say nothing about the template and are
+// not recorded.
+func (r *BuildReporter) Report(request *CompilationRequest, result *CompilationResult) {
+	record := buildRecord(request, result)
+	if record == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), buildReportTimeout)
+		defer cancel()
+		if err := r.post(ctx, request.TemplateID, record); err != nil {
+			r.logger.Warn("Failed to report build to template service", "template", request.TemplateID, "version", request.TemplateVersion, "error", err)
+		}
+	}()
+}
+
+func (r *BuildReporter) post(ctx context.Context, templateID string, record *athenatemplate.BuildRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode build record: %w", err)
+	}
+	endpoint := r.baseURL + "/api/v1/templates/" + url.PathEscape(templateID) + "/builds"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach template service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("template-service returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -4,21 +4,26 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
 )
 
 // Compiler handles Arduino firmware compilation
 type Compiler struct {
-	cli          *ArduinoCLI
-	workspaceDir string
-	cacheDir     string
-	enableCache  bool
+	cli                 *ArduinoCLI
+	workspaceDir        string
+	cacheDir            string
+	enableCache         bool
+	onboardingReportURL string
 }
 
 // NewCompiler creates a new compiler instance
@@ -31,14 +36,37 @@ func NewCompiler(cli *ArduinoCLI, workspaceDir, cacheDir string) *Compiler {
 	}
 }
 
+// SetOnboardingReportURL sets where onboarded firmware reports its network
+// when a request does not name one
+func (c *Compiler) SetOnboardingReportURL(reportURL string) {
+	c.onboardingReportURL = reportURL
+}
+
 // CompilationRequest represents a compilation request
 type CompilationRequest struct {
-	TemplateID   string                 `json:"template_id"`
-	TemplateCode string                 `json:"template_code"`
-	Parameters   map[string]interface{} `json:"parameters"`
-	Board        string                 `json:"board"` // FQBN
-	Libraries    []LibraryDependency    `json:"libraries"`
-	Secrets      map[string]string      `json:"secrets,omitempty"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version,omitempty"` // counts the compile in the template's analytics
+	TemplateCode    string                 `json:"template_code"`
+	Parameters      map[string]interface{} `json:"parameters"`
+	Board           string                 `json:"board"` // FQBN
+	Libraries       []LibraryDependency    `json:"libraries"`
+	Secrets         map[string]string      `json:"secrets,omitempty"`
+	Overrides       map[string]string      `json:"overrides,omitempty"` // override block name -> code
+	Snippets        string                 `json:"snippets,omitempty"`  // supplemental {{define}} snippet file
+
+	// Schema is the template's parameter schema, which types parameters as
+	// they are encoded into the sketch; without one they are encoded by value
+	Schema map[string]interface{} `json:"schema,omitempty"`
+
+	// Onboarding adds a captive portal; it may also be given as the
+	// "onboarding" parameter
+	Onboarding *athenatemplate.OnboardingOptions `json:"onboarding,omitempty"`
+
+	// LocaleMessages translates {{t}} text, from the template's locale pack
+	LocaleMessages athenatemplate.Messages `json:"locale_messages,omitempty"`
+
+	// Project is billed for the compile time; empty is the default project
+	Project string `json:"project,omitempty"`
 }
 
 // CompilationResult represents the result of compilation
@@ -129,7 +157,7 @@ func (c *Compiler) CompileTemplate(ctx context.Context, request *CompilationRequ
 	defer os.RemoveAll(projectDir)
 
 	// Render template with parameters
-	renderedCode, err := c.renderTemplate(request.TemplateCode, request.Parameters, request.Secrets)
+	renderedCode, err := c.renderTemplate(request)
 	if err != nil {
 		result.Errors = append(result.Errors, CompilationError{
 			File:    "template",
@@ -195,17 +223,41 @@ func (c *Compiler) createProjectDirectory(request *CompilationRequest) (string,
 }
 
 // renderTemplate renders the Arduino template with parameters and secrets
-func (c *Compiler) renderTemplate(templateCode string, parameters map[string]interface{}, secrets map[string]string) (string, error) {
-	// Create template with custom functions
+func (c *Compiler) renderTemplate(request *CompilationRequest) (string, error) {
+	parameters, onboarding, err := athenatemplate.ExtractOnboardingOptions(request.Parameters)
+	if err != nil {
+		return "", fmt.Errorf("invalid onboarding options: %w", err)
+	}
+	if request.Onboarding != nil {
+		onboarding = request.Onboarding
+	}
+	secrets := request.Secrets
+
+	// Parameters are interpolated into C++ source, so reject values that
+	// look like code and encode the rest by type
+	if findings := athenatemplate.ScanParameters(parameters); len(findings) > 0 {
+		reasons := make([]string, len(findings))
+		for i, finding := range findings {
+			reasons[i] = finding.String()
+		}
+		return "", fmt.Errorf("%w: %s", athenatemplate.ErrUnsafeParameter, strings.Join(reasons, "; "))
+	}
+	encoded, err := athenatemplate.EncodeParameters(request.Schema, parameters)
+	if err != nil {
+		return "", err
+	}
+
+	// Create template with custom functions. Parameters and secrets are
+	// escaped for a string literal, so quote only wraps them.
 	tmpl, err := template.New("arduino").Funcs(template.FuncMap{
 		"secret": func(key string) string {
 			if value, exists := secrets[key]; exists {
-				return value
+				return athenatemplate.EscapeString(value)
 			}
 			return ""
 		},
 		"param": func(key string) interface{} {
-			if value, exists := parameters[key]; exists {
+			if value, exists := encoded[key]; exists {
 				return value
 			}
 			return ""
@@ -217,6 +269,8 @@ func (c *Compiler) renderTemplate(templateCode string, parameters map[string]int
 			switch val := v.(type) {
 			case int:
 				return val
+			case int64:
+				return int(val)
 			case float64:
 				return int(val)
 			case string:
@@ -237,30 +291,39 @@ func (c *Compiler) renderTemplate(templateCode string, parameters map[string]int
 				return val == "true"
 			case int:
 				return val != 0
+			case int64:
+				return val != 0
 			case float64:
 				return val != 0
 			default:
 				return false
 			}
 		},
-	}).Parse(templateCode)
+		"t": request.LocaleMessages.Translate,
+	}).Parse(athenatemplate.NormalizeBlocks(request.TemplateCode))
 
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Combine parameters and secrets for template context
-	context := make(map[string]interface{})
-	for k, v := range parameters {
-		context[k] = v
+	// Fill override blocks from the request and snippet file
+	blocks := athenatemplate.BlockNames(request.TemplateCode)
+	if err := athenatemplate.ApplyBlockOverrides(tmpl, blocks, request.Overrides, request.Snippets); err != nil {
+		return "", err
 	}
-	// Don't expose secrets directly in context for security
 
+	// Secrets are only reached through the secret function
 	var rendered strings.Builder
-	if err := tmpl.Execute(&rendered, context); err != nil {
+	if err := tmpl.Execute(&rendered, encoded); err != nil {
 		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
 
+	if onboarding != nil {
+		opts := *onboarding
+		opts.ApplyDefaults(c.onboardingReportURL, parameters)
+		return athenatemplate.InjectOnboarding(rendered.String(), &opts, []string{request.Board})
+	}
+
 	return rendered.String(), nil
 }
 
@@ -389,6 +452,25 @@ func (c *Compiler) generateCacheKey(request *CompilationRequest) string {
 		hasher.Write([]byte(fmt.Sprintf("%s:%s", lib.Name, lib.Version)))
 	}
 
+	// Hash block overrides in a stable order
+	blockNames := make([]string, 0, len(request.Overrides))
+	for name := range request.Overrides {
+		blockNames = append(blockNames, name)
+	}
+	sort.Strings(blockNames)
+	for _, name := range blockNames {
+		hasher.Write([]byte(fmt.Sprintf("block:%s:%s", name, request.Overrides[name])))
+	}
+	hasher.Write([]byte(request.Snippets))
+	if request.Onboarding != nil {
+		onboarding, _ := json.Marshal(request.Onboarding)
+		hasher.Write(onboarding)
+	}
+	if len(request.LocaleMessages) > 0 {
+		messages, _ := json.Marshal(request.LocaleMessages)
+		hasher.Write(messages)
+	}
+
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
@@ -0,0 +1,53 @@
+package provisioning
+
+import (
+	"testing"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiler_RenderTemplateEncodesValues(t *testing.T) {
+	compiler := NewCompiler(nil, t.TempDir(), t.TempDir())
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"interval": map[string]interface{}{"type": "integer"},
+		},
+	}
+
+	rendered, err := compiler.renderTemplate(&CompilationRequest{
+		TemplateCode: `const char* ssid = "{{.ssid}}";
+const char* name = {{quote (param "ssid")}};
+const char* pass = "{{secret "wifi_password"}}";
+const long interval = {{int .interval}};`,
+		Parameters: map[string]interface{}{"ssid": `lab "5" \ net`, "interval": "2000"},
+		Schema:     schema,
+		Secrets:    map[string]string{"wifi_password": "p\"w\\d\n"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, rendered, `const char* ssid = "lab \"5\" \\ net";`)
+	assert.Contains(t, rendered, `const char* name = "lab \"5\" \\ net";`)
+	assert.Contains(t, rendered, `const char* pass = "p\"w\\d\012";`)
+	assert.Contains(t, rendered, `const long interval = 2000;`)
+}
+
+func TestCompiler_RenderTemplateRejectsInjection(t *testing.T) {
+	compiler := NewCompiler(nil, t.TempDir(), t.TempDir())
+
+	_, err := compiler.renderTemplate(&CompilationRequest{
+		TemplateCode: `const char* ssid = "{{.ssid}}";`,
+		Parameters:   map[string]interface{}{"ssid": `x"; system("reboot"); //`},
+	})
+	assert.ErrorIs(t, err, athenatemplate.ErrUnsafeParameter)
+	assert.Contains(t, err.Error(), "parameter ssid closes a string literal")
+
+	_, err = compiler.renderTemplate(&CompilationRequest{
+		TemplateCode: `const long interval = {{.interval}};`,
+		Parameters:   map[string]interface{}{"interval": "1); reboot(); (1"},
+		Schema: map[string]interface{}{
+			"properties": map[string]interface{}{"interval": map[string]interface{}{"type": "integer"}},
+		},
+	})
+	assert.ErrorIs(t, err, athenatemplate.ErrUnsafeParameter)
+}
@@ -0,0 +1,167 @@
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	athenatemplate "github.com/athena/platform-lib/pkg/template"
+	"github.com/gin-gonic/gin"
+)
+
+// RegressionBuilder compiles templates against newly released cores and
+// libraries for the template service's regression builds. They are
+// installed under a separate arduino-cli directory, so regular compiles
+// keep the versions the service was set up with.
+type RegressionBuilder struct {
+	cli      *ArduinoCLI
+	compiler *Compiler
+	mu       sync.Mutex // builds change the installed cores and libraries
+}
+
+// NewRegressionBuilder creates a regression builder working under dir
+func NewRegressionBuilder(cli *ArduinoCLI, dir string) *RegressionBuilder {
+	isolated := cli.WithDirectories(dir)
+	compiler := NewCompiler(isolated, filepath.Join(dir, "workspace"), filepath.Join(dir, "cache"))
+	// The same sketch is rebuilt with new toolchains, which the cache key
+	// does not cover
+	compiler.enableCache = false
+	return &RegressionBuilder{cli: isolated, compiler: compiler}
+}
+
+// LatestToolchain looks up the latest released version of each core and
+// library. Ones that cannot be found are left out.
+func (b *RegressionBuilder) LatestToolchain(ctx context.Context, request *athenatemplate.ToolchainRequest) (*athenatemplate.Toolchain, error) {
+	if err := b.cli.UpdateIndex(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update package index: %w", err)
+	}
+
+	toolchain := &athenatemplate.Toolchain{
+		Cores:     make(map[string]string, len(request.Cores)),
+		Libraries: make(map[string]string, len(request.Libraries)),
+	}
+	for _, core := range request.Cores {
+		if version, err := b.cli.LatestCoreVersion(ctx, core); err == nil {
+			toolchain.Cores[core] = version
+		}
+	}
+	for _, name := range request.Libraries {
+		libraries, err := b.cli.SearchLibrary(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, library := range libraries {
+			if library.Name == name {
+				toolchain.Libraries[name] = library.Version
+				break
+			}
+		}
+	}
+	return toolchain, nil
+}
+
+// Build installs the requested core and library versions and compiles the
+// rendered template. Compile errors are reported in the record.
+func (b *RegressionBuilder) Build(ctx context.Context, request *athenatemplate.RegressionBuildRequest) (*athenatemplate.BuildRecord, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	core := request.Core
+	if request.CoreVersion != "" {
+		core += "@" + request.CoreVersion
+	}
+	if err := b.cli.InstallCore(ctx, core); err != nil {
+		return nil, fmt.Errorf("failed to install core %s: %w", core, err)
+	}
+
+	libraries := make([]LibraryDependency, len(request.Libraries))
+	for i, library := range request.Libraries {
+		libraries[i] = LibraryDependency{Name: library.Name, Version: library.Version}
+		name := library.Name
+		if library.Version != "" {
+			name += "@" + library.Version
+		}
+		if err := b.cli.InstallLibrary(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to install library %s: %w", name, err)
+		}
+	}
+
+	result, err := b.compiler.CompileTemplate(ctx, &CompilationRequest{
+		TemplateID:   request.TemplateID,
+		TemplateCode: request.Code,
+		Board:        request.Board,
+		Libraries:    libraries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record := &athenatemplate.BuildRecord{
+		TemplateID:      request.TemplateID,
+		TemplateVersion: request.TemplateVersion,
+		Board:           request.Board,
+		CoreVersion:     request.CoreVersion,
+		Libraries:       request.Libraries,
+		Success:         result.Success,
+		BuiltAt:         time.Now().UTC(),
+	}
+	if !result.Success {
+		messages := make([]string, 0, len(result.Errors))
+		for _, compileErr := range result.Errors {
+			messages = append(messages, compileErr.Message)
+		}
+		record.Error = strings.Join(messages, "\n")
+		if record.Error == "" {
+			record.Error = "compilation failed"
+		}
+	}
+	return record, nil
+}
+
+func (s *Service) latestToolchain(c *gin.Context) {
+	var req athenatemplate.ToolchainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	toolchain, err := s.regression.LatestToolchain(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("Failed to look up toolchain releases", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to look up toolchain releases: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, toolchain)
+}
+
+func (s *Service) regressionBuild(c *gin.Context) {
+	var req athenatemplate.RegressionBuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	s.logger.Info("Starting regression build", "template", req.TemplateID, "board", req.Board, "core_version", req.CoreVersion)
+
+	record, err := s.regression.Build(c.Request.Context(), &req)
+	if err != nil {
+		s.logger.Error("Regression build failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Regression build failed: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/athena/platform-lib/pkg/config"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
 	"github.com/gin-gonic/gin"
 )
 
@@ -39,6 +40,9 @@ type Service struct {
 	compiler        *Compiler
 	artifactManager *ArtifactManager
 	flasher         *Flasher
+	regression      *RegressionBuilder
+	builds          *BuildReporter
+	usage           *metering.Recorder
 }
 
 // NewService creates a new provisioning service instance
@@ -54,10 +58,19 @@ func NewService(cfg *config.Config, logger *logger.Logger) (*Service, error) {
 	workspaceDir := "/tmp/athena/workspace"
 	cacheDir := "/tmp/athena/cache"
 	artifactDir := "/tmp/athena/artifacts"
+	regressionDir := "/tmp/athena/regression"
 
 	compiler := NewCompiler(cli, workspaceDir, cacheDir)
+	compiler.SetOnboardingReportURL(cfg.Onboarding.ReportURL)
 	artifactManager := NewArtifactManager(artifactDir)
 	flasher := NewFlasher(cli)
+	regression := NewRegressionBuilder(cli, regressionDir)
+
+	// User compiles count towards the analytics of their template
+	var builds *BuildReporter
+	if templateURL := cfg.Services["template-service"]; templateURL != "" {
+		builds = NewBuildReporter(templateURL, logger)
+	}
 
 	return &Service{
 		config:          cfg,
@@ -68,9 +81,16 @@ func NewService(cfg *config.Config, logger *logger.Logger) (*Service, error) {
 		compiler:        compiler,
 		artifactManager: artifactManager,
 		flasher:         flasher,
+		regression:      regression,
+		builds:          builds,
 	}, nil
 }
 
+// SetUsageRecorder meters compile time per project
+func (s *Service) SetUsageRecorder(recorder *metering.Recorder) {
+	s.usage = recorder
+}
+
 // RegisterRoutes registers HTTP routes for the provisioning service
 func RegisterRoutes(router *gin.Engine, service *Service) {
 	v1 := router.Group("/api/v1/provisioning")
@@ -97,6 +117,10 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 		v1.DELETE("/artifacts/:id", service.deleteArtifact)
 		v1.POST("/artifacts/search", service.searchArtifacts)
 
+		// Regression builds of templates against new toolchain releases
+		v1.POST("/toolchain/latest", service.latestToolchain)
+		v1.POST("/regression-builds", service.regressionBuild)
+
 		// Flashing endpoints
 		v1.POST("/flash", service.flashDevice)
 		v1.GET("/ports", service.getAvailablePorts)
@@ -403,6 +427,12 @@ func (s *Service) compileTemplate(c *gin.Context) {
 	s.logger.Info("Starting compilation", "template", req.TemplateID, "board", req.Board)
 
 	result, err := s.compiler.CompileTemplate(ctx, &req)
+	if result != nil && !result.CacheHit {
+		s.usage.Add(req.Project, metering.MeterCompileMinutes, result.Duration.Minutes())
+	}
+	if s.builds != nil {
+		s.builds.Report(&req, result)
+	}
 	if err != nil {
 		s.logger.Error("Compilation failed", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -96,7 +96,7 @@ func (rp *ReverseProxy) ProxyHandler(serviceName string) gin.HandlerFunc {
 		// Get service URL
 		serviceURL, err := rp.registry.GetServiceURL(serviceName, rp.loadBalancer)
 		if err != nil {
-			rp.logger.Errorf("Failed to get service URL for %s: %v", serviceName, err)
+			rp.logger.Error("Failed to get service URL", "service", serviceName, "error", err)
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"error": fmt.Sprintf("Service %s is unavailable", serviceName),
 			})
@@ -106,7 +106,7 @@ func (rp *ReverseProxy) ProxyHandler(serviceName string) gin.HandlerFunc {
 		// Create target URL
 		target, err := url.Parse(serviceURL)
 		if err != nil {
-			rp.logger.Errorf("Failed to parse service URL %s: %v", serviceURL, err)
+			rp.logger.Error("Failed to parse service URL", "url", serviceURL, "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Internal server error",
 			})
@@ -141,7 +141,7 @@ func (rp *ReverseProxy) ProxyHandler(serviceName string) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			rp.logger.Errorf("Proxy request failed for %s: %v", serviceName, err)
+			rp.logger.Error("Proxy request failed", "service", serviceName, "error", err)
 			if !c.Writer.Written() {
 				c.JSON(http.StatusServiceUnavailable, gin.H{
 					"error": fmt.Sprintf("Service %s is temporarily unavailable", serviceName),
@@ -153,7 +153,7 @@ func (rp *ReverseProxy) ProxyHandler(serviceName string) gin.HandlerFunc {
 
 // errorHandler handles proxy errors
 func (rp *ReverseProxy) errorHandler(rw http.ResponseWriter, r *http.Request, err error) {
-	rp.logger.Errorf("Proxy error: %v", err)
+	rp.logger.Error("Proxy error", "error", err)
 
 	if r.Context().Err() == context.DeadlineExceeded {
 		http.Error(rw, "Gateway timeout", http.StatusGatewayTimeout)
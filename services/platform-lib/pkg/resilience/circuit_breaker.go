@@ -125,12 +125,12 @@ func (cb *CircuitBreaker) onSuccess() {
 		cb.halfOpenReq++
 		if cb.halfOpenReq >= cb.halfOpenMaxReq {
 			cb.state = StateClosed
-			cb.logger.Infof("Circuit breaker %s closed after successful half-open requests", cb.name)
+			cb.logger.Info("Circuit breaker closed after successful half-open requests", "breaker", cb.name)
 		}
 	case StateOpen:
 		// Should not happen, but handle gracefully
 		cb.state = StateClosed
-		cb.logger.Infof("Circuit breaker %s closed after successful request", cb.name)
+		cb.logger.Info("Circuit breaker closed after successful request", "breaker", cb.name)
 	}
 }
 
@@ -143,11 +143,11 @@ func (cb *CircuitBreaker) onFailure() {
 	case StateClosed:
 		if cb.failures >= cb.maxFailures {
 			cb.state = StateOpen
-			cb.logger.Warnf("Circuit breaker %s opened after %d failures", cb.name, cb.failures)
+			cb.logger.Warn("Circuit breaker opened", "breaker", cb.name, "failures", cb.failures)
 		}
 	case StateHalfOpen:
 		cb.state = StateOpen
-		cb.logger.Warnf("Circuit breaker %s opened after failure in half-open state", cb.name)
+		cb.logger.Warn("Circuit breaker opened after failure in half-open state", "breaker", cb.name)
 	case StateOpen:
 		// Already open, just update failure count
 	}
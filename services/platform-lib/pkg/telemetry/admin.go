@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+// AdminTasks returns the telemetry service's operational tasks
+func (s *Service) AdminTasks() []admin.Task {
+	return []admin.Task{
+		{
+			Name: "gc",
+			Description: "Delete device logs past the retention period (log_older_than overrides it) " +
+				"and, when older_than is given, telemetry older than that",
+			Run: s.collectGarbage,
+		},
+	}
+}
+
+// collectGarbage deletes expired device logs and, on request, old
+// telemetry. Telemetry has no retention period of its own, so it is only
+// deleted when older_than is set.
+func (s *Service) collectGarbage(ctx context.Context, params admin.Params) (admin.Result, error) {
+	logsOlderThan, err := params.Duration("log_older_than", s.config.Telemetry.LogRetention)
+	if err != nil {
+		return nil, err
+	}
+	telemetryOlderThan, err := params.Duration("older_than", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := admin.Result{}
+	now := time.Now()
+	if logsOlderThan > 0 {
+		deleted, err := s.repository.DeleteOldDeviceLogs(ctx, now.Add(-logsOlderThan))
+		if err != nil {
+			return result, fmt.Errorf("failed to delete device logs: %w", err)
+		}
+		result["device_logs_deleted"] = deleted
+	}
+	if telemetryOlderThan > 0 {
+		deleted, err := s.repository.DeleteOldTelemetry(ctx, now.Add(-telemetryOlderThan))
+		if err != nil {
+			return result, fmt.Errorf("failed to delete telemetry: %w", err)
+		}
+		result["telemetry_deleted"] = deleted
+	}
+	return result, nil
+}
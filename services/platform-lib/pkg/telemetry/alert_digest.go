@@ -0,0 +1,256 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Digest limits. Alerts past maxDigestAlerts are counted but not listed.
+const (
+	minDigestInterval = time.Minute
+	maxDigestAlerts   = 500
+	// DigestDaily flushes once a day at midnight UTC
+	DigestDaily = "daily"
+)
+
+// DigestConfig batches a channel's non-critical alerts into periodic
+// summaries. Critical alerts are always sent immediately.
+type DigestConfig struct {
+	// Interval is a duration such as "30m" or "6h", or "daily". Digests
+	// flush on interval boundaries, e.g. on the hour and half hour for "30m".
+	Interval string `json:"interval" binding:"required"`
+}
+
+// Validate checks the digest interval
+func (d *DigestConfig) Validate() error {
+	_, err := d.interval()
+	return err
+}
+
+func (d *DigestConfig) interval() (time.Duration, error) {
+	if d.Interval == DigestDaily {
+		return 24 * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(d.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid digest interval %q: use a duration like 30m or %q", d.Interval, DigestDaily)
+	}
+	if interval < minDigestInterval {
+		return 0, fmt.Errorf("digest interval must be at least %s", minDigestInterval)
+	}
+	return interval, nil
+}
+
+// AlertDigest is a summary of the alerts a channel held back over a period
+type AlertDigest struct {
+	Channel     NotificationChannel `json:"channel"`
+	PeriodStart time.Time           `json:"period_start"`
+	PeriodEnd   time.Time           `json:"period_end"`
+	Count       int                 `json:"count"`
+	Omitted     int                 `json:"omitted,omitempty"`
+	BySeverity  map[string]int      `json:"by_severity"`
+	Alerts      []*Alert            `json:"alerts"`
+}
+
+// digestBuffer collects alerts for one channel until the next flush
+type digestBuffer struct {
+	since   time.Time
+	alerts  []*Alert
+	omitted int
+	counts  map[string]int
+}
+
+func (b *digestBuffer) digest(channel NotificationChannel, now time.Time) *AlertDigest {
+	return &AlertDigest{
+		Channel:     channel,
+		PeriodStart: b.since,
+		PeriodEnd:   now,
+		Count:       len(b.alerts) + b.omitted,
+		Omitted:     b.omitted,
+		BySeverity:  b.counts,
+		Alerts:      b.alerts,
+	}
+}
+
+// queueDigest holds an alert for the channel's next digest. The caller
+// holds an.mu.
+func (an *AlertNotifier) queueDigest(channel NotificationChannel, alert *Alert, now time.Time) {
+	buffer, ok := an.digests[channel]
+	if !ok {
+		buffer = &digestBuffer{since: now, counts: make(map[string]int)}
+		an.digests[channel] = buffer
+	}
+
+	buffer.counts[alert.Severity]++
+	if len(buffer.alerts) >= maxDigestAlerts {
+		buffer.omitted++
+		return
+	}
+	buffer.alerts = append(buffer.alerts, alert)
+}
+
+// FlushDigests sends every digest whose interval boundary has passed and
+// returns them. With force, all pending digests are sent.
+func (an *AlertNotifier) FlushDigests(now time.Time, force bool) []*AlertDigest {
+	an.mu.Lock()
+	var due []*AlertDigest
+	var configs []NotificationConfig
+	for _, config := range an.channels {
+		buffer, ok := an.digests[config.Channel]
+		if !ok || config.Digest == nil {
+			continue
+		}
+		interval, err := config.Digest.interval()
+		if err != nil {
+			continue
+		}
+		if !force && now.Before(buffer.since.Truncate(interval).Add(interval)) {
+			continue
+		}
+
+		delete(an.digests, config.Channel)
+		due = append(due, buffer.digest(config.Channel, now))
+		configs = append(configs, config)
+	}
+	an.mu.Unlock()
+
+	for i, digest := range due {
+		an.sendDigest(digest, configs[i])
+	}
+	return due
+}
+
+// sendDigest delivers a digest through its channel
+func (an *AlertNotifier) sendDigest(digest *AlertDigest, config NotificationConfig) {
+	switch config.Channel {
+	case ChannelWebhook:
+		alerts := make([]map[string]interface{}, len(digest.Alerts))
+		for i, alert := range digest.Alerts {
+			alerts[i] = alertPayload(alert)
+		}
+		payload := map[string]interface{}{
+			"type":         "alert_digest",
+			"period_start": digest.PeriodStart.Format(time.RFC3339),
+			"period_end":   digest.PeriodEnd.Format(time.RFC3339),
+			"count":        digest.Count,
+			"omitted":      digest.Omitted,
+			"by_severity":  digest.BySeverity,
+			"alerts":       alerts,
+		}
+		go an.postWebhook(config.Settings, payload, fmt.Sprintf("digest of %d alerts", digest.Count))
+	case ChannelEmail:
+		to, ok := config.Settings["to"].(string)
+		if !ok || to == "" {
+			an.logger.Error("Email recipient not configured")
+			return
+		}
+		an.logger.Info("Email digest would be sent", "to", to, "alerts", digest.Count, "severities", formatSeverityCounts(digest.BySeverity))
+		for _, alert := range digest.Alerts {
+			an.logger.Info("Digest alert", "severity", alert.Severity, "device_id", alert.DeviceID, "message", alert.Message)
+		}
+	case ChannelLog:
+		an.logger.Info("Alert digest", "alerts", digest.Count, "since", digest.PeriodStart.Format(time.RFC3339),
+			"severities", formatSeverityCounts(digest.BySeverity))
+	default:
+		an.logger.Warn("Unknown notification channel", "channel", config.Channel)
+	}
+}
+
+// formatSeverityCounts renders counts as "warning=3, info=1", most severe first
+func formatSeverityCounts(counts map[string]int) string {
+	rank := map[string]int{"critical": 0, "warning": 1, "info": 2}
+	severities := make([]string, 0, len(counts))
+	for severity := range counts {
+		severities = append(severities, severity)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		ri, ok := rank[severities[i]]
+		if !ok {
+			ri = len(rank)
+		}
+		rj, ok := rank[severities[j]]
+		if !ok {
+			rj = len(rank)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return severities[i] < severities[j]
+	})
+
+	parts := make([]string, len(severities))
+	for i, severity := range severities {
+		parts[i] = fmt.Sprintf("%s=%d", severity, counts[severity])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SetDigest enables digest mode for a channel, or disables it when digest
+// is nil. Alerts held back when digest mode is disabled are sent at once.
+func (an *AlertNotifier) SetDigest(channel NotificationChannel, digest *DigestConfig) error {
+	if digest != nil {
+		if err := digest.Validate(); err != nil {
+			return err
+		}
+	}
+
+	an.mu.Lock()
+	found := -1
+	for i, config := range an.channels {
+		if config.Channel == channel {
+			found = i
+			break
+		}
+	}
+	if found < 0 {
+		an.mu.Unlock()
+		return fmt.Errorf("notification channel %s not found", channel)
+	}
+	previous := an.channels[found]
+	an.channels[found].Digest = digest
+	buffer, pending := an.digests[channel]
+	if digest == nil {
+		delete(an.digests, channel)
+	}
+	an.mu.Unlock()
+
+	if digest == nil && pending && previous.Digest != nil {
+		an.sendDigest(buffer.digest(channel, an.now()), previous)
+	}
+
+	if digest != nil {
+		an.logger.Info("Digest mode enabled", "channel", channel, "interval", digest.Interval)
+	} else {
+		an.logger.Info("Digest mode disabled", "channel", channel)
+	}
+	return nil
+}
+
+// Start periodically flushes due digests
+func (an *AlertNotifier) Start(checkInterval time.Duration) {
+	an.wg.Add(1)
+	go func() {
+		defer an.wg.Done()
+
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-an.stop:
+				return
+			case <-ticker.C:
+				an.FlushDigests(an.now(), false)
+			}
+		}
+	}()
+}
+
+// Stop stops the digest loop and sends any pending digests
+func (an *AlertNotifier) Stop() {
+	an.stopOnce.Do(func() { close(an.stop) })
+	an.wg.Wait()
+	an.FlushDigests(an.now(), true)
+}
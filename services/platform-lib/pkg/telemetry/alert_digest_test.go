@@ -0,0 +1,206 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var digestNow = time.Date(2026, 3, 1, 10, 5, 0, 0, time.UTC)
+
+// webhookRecorder collects webhook request bodies
+func webhookRecorder(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	bodies := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		bodies <- body
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+func receiveWebhook(t *testing.T, bodies chan map[string]interface{}) map[string]interface{} {
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+		return nil
+	}
+}
+
+func newDigestNotifier(channels ...NotificationConfig) *AlertNotifier {
+	notifier := NewAlertNotifier(logger.New("info", "telemetry-service"), channels)
+	notifier.now = func() time.Time { return digestNow }
+	return notifier
+}
+
+func digestAlert(id, severity string) *Alert {
+	return &Alert{AlertID: id, DeviceID: "dev-1", MetricName: "temperature", Severity: severity, Message: id}
+}
+
+func TestDigestConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&DigestConfig{Interval: "30m"}).Validate())
+	assert.NoError(t, (&DigestConfig{Interval: DigestDaily}).Validate())
+	assert.Error(t, (&DigestConfig{Interval: "10s"}).Validate())
+	assert.Error(t, (&DigestConfig{Interval: "hourly"}).Validate())
+}
+
+func TestAlertNotifier_DigestHoldsNonCritical(t *testing.T) {
+	server, bodies := webhookRecorder(t)
+	notifier := newDigestNotifier(NotificationConfig{
+		Channel:  ChannelWebhook,
+		Enabled:  true,
+		Settings: map[string]interface{}{"url": server.URL},
+		Digest:   &DigestConfig{Interval: "30m"},
+	})
+
+	notifier.SendAlert(digestAlert("a1", "warning"))
+	notifier.SendAlert(digestAlert("a2", "info"))
+	notifier.SendAlert(digestAlert("a3", "warning"))
+
+	// Critical alerts page immediately
+	notifier.SendAlert(digestAlert("c1", "critical"))
+	body := receiveWebhook(t, bodies)
+	assert.Equal(t, "c1", body["alert_id"])
+
+	// Not due until the next half hour boundary
+	assert.Empty(t, notifier.FlushDigests(digestNow.Add(20*time.Minute), false))
+
+	digests := notifier.FlushDigests(digestNow.Add(25*time.Minute), false)
+	require.Len(t, digests, 1)
+	assert.Equal(t, 3, digests[0].Count)
+	assert.Equal(t, map[string]int{"warning": 2, "info": 1}, digests[0].BySeverity)
+	assert.True(t, digests[0].PeriodStart.Equal(digestNow))
+
+	body = receiveWebhook(t, bodies)
+	assert.Equal(t, "alert_digest", body["type"])
+	assert.Equal(t, 3.0, body["count"])
+	assert.Len(t, body["alerts"], 3)
+
+	// The buffer is empty after a flush
+	assert.Empty(t, notifier.FlushDigests(digestNow.Add(time.Hour), false))
+}
+
+func TestAlertNotifier_DigestDaily(t *testing.T) {
+	notifier := newDigestNotifier(NotificationConfig{
+		Channel: ChannelLog,
+		Enabled: true,
+		Digest:  &DigestConfig{Interval: DigestDaily},
+	})
+	notifier.SendAlert(digestAlert("a1", "info"))
+
+	midnight := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	assert.Empty(t, notifier.FlushDigests(midnight.Add(-time.Second), false))
+	assert.Len(t, notifier.FlushDigests(midnight, false), 1)
+}
+
+func TestAlertNotifier_DigestOmitsOverflow(t *testing.T) {
+	notifier := newDigestNotifier(NotificationConfig{
+		Channel: ChannelLog,
+		Enabled: true,
+		Digest:  &DigestConfig{Interval: "1h"},
+	})
+	for i := 0; i < maxDigestAlerts+5; i++ {
+		notifier.SendAlert(digestAlert(fmt.Sprintf("a%d", i), "info"))
+	}
+
+	digests := notifier.FlushDigests(digestNow, true)
+	require.Len(t, digests, 1)
+	assert.Equal(t, maxDigestAlerts+5, digests[0].Count)
+	assert.Equal(t, 5, digests[0].Omitted)
+	assert.Len(t, digests[0].Alerts, maxDigestAlerts)
+}
+
+func TestAlertNotifier_SetDigest(t *testing.T) {
+	server, bodies := webhookRecorder(t)
+	notifier := newDigestNotifier(NotificationConfig{
+		Channel:  ChannelWebhook,
+		Enabled:  true,
+		Settings: map[string]interface{}{"url": server.URL},
+	})
+
+	assert.Error(t, notifier.SetDigest(ChannelEmail, &DigestConfig{Interval: "30m"}))
+	assert.Error(t, notifier.SetDigest(ChannelWebhook, &DigestConfig{Interval: "1s"}))
+	require.NoError(t, notifier.SetDigest(ChannelWebhook, &DigestConfig{Interval: "30m"}))
+
+	notifier.SendAlert(digestAlert("a1", "warning"))
+	select {
+	case <-bodies:
+		t.Fatal("alert sent before digest")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Disabling digest mode sends what was held back
+	require.NoError(t, notifier.SetDigest(ChannelWebhook, nil))
+	body := receiveWebhook(t, bodies)
+	assert.Equal(t, "alert_digest", body["type"])
+
+	notifier.SendAlert(digestAlert("a2", "warning"))
+	body = receiveWebhook(t, bodies)
+	assert.Equal(t, "a2", body["alert_id"])
+}
+
+func TestAlertNotifier_StopFlushesDigests(t *testing.T) {
+	server, bodies := webhookRecorder(t)
+	notifier := newDigestNotifier(NotificationConfig{
+		Channel:  ChannelWebhook,
+		Enabled:  true,
+		Settings: map[string]interface{}{"url": server.URL},
+		Digest:   &DigestConfig{Interval: DigestDaily},
+	})
+	notifier.Start(time.Hour)
+	notifier.SendAlert(digestAlert("a1", "info"))
+
+	notifier.Stop()
+	body := receiveWebhook(t, bodies)
+	assert.Equal(t, 1.0, body["count"])
+}
+
+func TestService_NotificationDigestHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &MockRepository{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPut, "/api/v1/telemetry/notifications/channels/log/digest", `{"interval":"30m"}`, http.StatusOK},
+		{http.MethodPut, "/api/v1/telemetry/notifications/channels/log/digest", `{"interval":"5s"}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/telemetry/notifications/channels/email/digest", `{"interval":"daily"}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/telemetry/notifications/channels/log/digest", ``, http.StatusOK},
+		{http.MethodPost, "/api/v1/telemetry/notifications/channels", `{"channel":"email","enabled":true,"digest":{"interval":"never"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/telemetry/notifications/channels", `{"channel":"email","enabled":true,"digest":{"interval":"daily"}}`, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		assert.Equal(t, tt.code, w.Code, "%s %s %s: %s", tt.method, tt.path, tt.body, w.Body.String())
+	}
+
+	channels := service.alertNotifier.GetChannels()
+	require.Len(t, channels, 2)
+	assert.Nil(t, channels[0].Digest)
+	require.NotNil(t, channels[1].Digest)
+	assert.Equal(t, DigestDaily, channels[1].Digest.Interval)
+}
@@ -2,13 +2,15 @@ package telemetry
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
 )
 
 // NotificationChannel represents a notification delivery channel
@@ -25,14 +27,24 @@ type NotificationConfig struct {
 	Channel  NotificationChannel    `json:"channel"`
 	Enabled  bool                   `json:"enabled"`
 	Settings map[string]interface{} `json:"settings"`
+	// Digest batches non-critical alerts into periodic summaries
+	Digest *DigestConfig `json:"digest,omitempty"`
 }
 
 // AlertNotifier handles alert notifications through multiple channels
 type AlertNotifier struct {
-	logger   *logger.Logger
-	channels []NotificationConfig
-	mu       sync.RWMutex
-	client   *http.Client
+	logger    *logger.Logger
+	channels  []NotificationConfig
+	mu        sync.RWMutex
+	client    *http.Client
+	publisher notifications.Publisher
+	events    *device.EventRecorder
+	owners    *device.DeviceOwners
+	digests   map[NotificationChannel]*digestBuffer
+	now       func() time.Time
+	stop      chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
 }
 
 // NewAlertNotifier creates a new alert notifier
@@ -43,19 +55,35 @@ func NewAlertNotifier(logger *logger.Logger, channels []NotificationConfig) *Ale
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		digests: make(map[NotificationChannel]*digestBuffer),
+		now:     time.Now,
+		stop:    make(chan struct{}),
 	}
 }
 
-// SendAlert sends an alert through all configured channels
+// SendAlert sends an alert through all configured channels. Channels in
+// digest mode hold non-critical alerts for their next digest.
 func (an *AlertNotifier) SendAlert(alert *Alert) {
-	an.mu.RLock()
-	channels := an.channels
-	an.mu.RUnlock()
+	now := an.now()
+	var channels []NotificationConfig
 
-	for _, channel := range channels {
+	an.mu.Lock()
+	for _, channel := range an.channels {
 		if !channel.Enabled {
 			continue
 		}
+		if channel.Digest != nil && alert.Severity != "critical" {
+			an.queueDigest(channel.Channel, alert, now)
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	an.mu.Unlock()
+
+	an.publishAlert(alert)
+	an.recordAlert(alert)
+
+	for _, channel := range channels {
 
 		switch channel.Channel {
 		case ChannelWebhook:
@@ -65,20 +93,90 @@ func (an *AlertNotifier) SendAlert(alert *Alert) {
 		case ChannelLog:
 			an.sendLog(alert)
 		default:
-			an.logger.Warn(fmt.Sprintf("Unknown notification channel: %s", channel.Channel))
+			an.logger.Warn("Unknown notification channel", "channel", channel.Channel)
 		}
 	}
 }
 
-// sendWebhook sends alert via webhook
-func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface{}) {
-	webhookURL, ok := settings["url"].(string)
-	if !ok || webhookURL == "" {
-		an.logger.Error("Webhook URL not configured")
+// SetPublisher sets the publisher used to push fired alerts to notification subscribers
+func (an *AlertNotifier) SetPublisher(publisher notifications.Publisher) {
+	an.mu.Lock()
+	defer an.mu.Unlock()
+	an.publisher = publisher
+}
+
+// SetOwners sets how fired alerts are addressed to the owners of their
+// devices. Without it they only reach admins.
+func (an *AlertNotifier) SetOwners(owners *device.DeviceOwners) {
+	an.mu.Lock()
+	defer an.mu.Unlock()
+	an.owners = owners
+}
+
+// publishAlert pushes an alert to the owner of its device through the
+// platform notification hub
+func (an *AlertNotifier) publishAlert(alert *Alert) {
+	an.mu.RLock()
+	publisher := an.publisher
+	owners := an.owners
+	an.mu.RUnlock()
+	if publisher == nil {
 		return
 	}
 
-	payload := map[string]interface{}{
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	notifications.PublishAsync(publisher, an.logger, &notifications.Event{
+		Type:         notifications.EventAlertFired,
+		ResourceType: "device",
+		ResourceID:   alert.DeviceID,
+		Owner:        owners.OwnerOf(ctx, alert.DeviceID),
+		Message:      alert.Message,
+		Data: map[string]interface{}{
+			"alert_id":        alert.AlertID,
+			"metric_name":     alert.MetricName,
+			"current_value":   alert.CurrentValue,
+			"threshold_value": alert.ThresholdValue,
+			"severity":        alert.Severity,
+		},
+	})
+}
+
+// SetDeviceEvents sets where fired alerts are added to device timelines
+func (an *AlertNotifier) SetDeviceEvents(events *device.EventRecorder) {
+	an.mu.Lock()
+	defer an.mu.Unlock()
+	an.events = events
+}
+
+// recordAlert adds a fired alert to its device's timeline
+func (an *AlertNotifier) recordAlert(alert *Alert) {
+	an.mu.RLock()
+	events := an.events
+	an.mu.RUnlock()
+	if events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events.Record(ctx, alert.DeviceID, device.DeviceEventAlertFired, alert.Message, map[string]interface{}{
+		"alert_id":        alert.AlertID,
+		"metric_name":     alert.MetricName,
+		"current_value":   alert.CurrentValue,
+		"threshold_value": alert.ThresholdValue,
+		"severity":        alert.Severity,
+	})
+}
+
+// sendWebhook sends alert via webhook
+func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface{}) {
+	an.postWebhook(settings, alertPayload(alert), "alert "+alert.AlertID)
+}
+
+// alertPayload is the webhook representation of an alert
+func alertPayload(alert *Alert) map[string]interface{} {
+	return map[string]interface{}{
 		"alert_id":        alert.AlertID,
 		"device_id":       alert.DeviceID,
 		"metric_name":     alert.MetricName,
@@ -88,16 +186,25 @@ func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface
 		"threshold_value": alert.ThresholdValue,
 		"triggered_at":    alert.TriggeredAt.Format(time.RFC3339),
 	}
+}
+
+// postWebhook posts a JSON payload to the configured webhook URL
+func (an *AlertNotifier) postWebhook(settings map[string]interface{}, payload map[string]interface{}, description string) {
+	webhookURL, ok := settings["url"].(string)
+	if !ok || webhookURL == "" {
+		an.logger.Error("Webhook URL not configured")
+		return
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to marshal webhook payload: %v", err))
+		an.logger.Error("Failed to marshal webhook payload", "error", err)
 		return
 	}
 
 	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to create webhook request: %v", err))
+		an.logger.Error("Failed to create webhook request", "error", err)
 		return
 	}
 
@@ -114,15 +221,15 @@ func (an *AlertNotifier) sendWebhook(alert *Alert, settings map[string]interface
 
 	resp, err := an.client.Do(req)
 	if err != nil {
-		an.logger.Error(fmt.Sprintf("Failed to send webhook: %v", err))
+		an.logger.Error("Failed to send webhook", "error", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		an.logger.Info(fmt.Sprintf("Webhook notification sent for alert %s", alert.AlertID))
+		an.logger.Info("Webhook notification sent", "notification", description)
 	} else {
-		an.logger.Error(fmt.Sprintf("Webhook returned status %d for alert %s", resp.StatusCode, alert.AlertID))
+		an.logger.Error("Webhook returned error status", "status", resp.StatusCode, "notification", description)
 	}
 }
 
@@ -137,23 +244,22 @@ func (an *AlertNotifier) sendEmail(alert *Alert, settings map[string]interface{}
 		return
 	}
 
-	an.logger.Info(fmt.Sprintf("Email notification would be sent to %s for alert %s", to, alert.AlertID))
-	an.logger.Info(fmt.Sprintf("Subject: [%s] Alert for device %s", alert.Severity, alert.DeviceID))
-	an.logger.Info(fmt.Sprintf("Body: %s", alert.Message))
+	an.logger.Info("Email notification would be sent", "to", to, "alert_id", alert.AlertID,
+		"severity", alert.Severity, "device_id", alert.DeviceID, "message", alert.Message)
 }
 
 // sendLog logs the alert
 func (an *AlertNotifier) sendLog(alert *Alert) {
-	logMessage := fmt.Sprintf("[ALERT] Device: %s, Metric: %s, Severity: %s, Message: %s",
-		alert.DeviceID, alert.MetricName, alert.Severity, alert.Message)
+	fields := []interface{}{"alert_id", alert.AlertID, "device_id", alert.DeviceID, "metric", alert.MetricName,
+		"severity", alert.Severity, "message", alert.Message}
 
 	switch alert.Severity {
 	case "critical":
-		an.logger.Error(logMessage)
+		an.logger.Error("Alert", fields...)
 	case "warning":
-		an.logger.Warn(logMessage)
+		an.logger.Warn("Alert", fields...)
 	default:
-		an.logger.Info(logMessage)
+		an.logger.Info("Alert", fields...)
 	}
 }
 
@@ -163,7 +269,7 @@ func (an *AlertNotifier) AddChannel(config NotificationConfig) {
 	defer an.mu.Unlock()
 
 	an.channels = append(an.channels, config)
-	an.logger.Info(fmt.Sprintf("Added notification channel: %s", config.Channel))
+	an.logger.Info("Added notification channel", "channel", config.Channel)
 }
 
 // RemoveChannel removes a notification channel
@@ -174,7 +280,8 @@ func (an *AlertNotifier) RemoveChannel(channel NotificationChannel) {
 	for i, config := range an.channels {
 		if config.Channel == channel {
 			an.channels = append(an.channels[:i], an.channels[i+1:]...)
-			an.logger.Info(fmt.Sprintf("Removed notification channel: %s", channel))
+			delete(an.digests, channel)
+			an.logger.Info("Removed notification channel", "channel", channel)
 			break
 		}
 	}
@@ -188,7 +295,7 @@ func (an *AlertNotifier) UpdateChannel(channel NotificationChannel, settings map
 	for i, config := range an.channels {
 		if config.Channel == channel {
 			an.channels[i].Settings = settings
-			an.logger.Info(fmt.Sprintf("Updated notification channel: %s", channel))
+			an.logger.Info("Updated notification channel", "channel", channel)
 			break
 		}
 	}
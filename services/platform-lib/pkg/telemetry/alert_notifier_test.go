@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventChannel hands published notification events to a channel
+type eventChannel chan *notifications.Event
+
+func (p eventChannel) Publish(ctx context.Context, event *notifications.Event) error {
+	p <- event
+	return nil
+}
+
+func TestAlertNotifier_PublishesToDeviceOwner(t *testing.T) {
+	notifier := NewAlertNotifier(logger.New("info", "telemetry-service"), nil)
+	events := make(eventChannel, 4)
+	notifier.SetPublisher(events)
+
+	templates := template.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(context.Background(), &template.Template{
+		ID:      "sensor",
+		Version: "1.0.0",
+		Name:    "Sensor",
+		Owner:   "user-1",
+	}))
+	notifier.SetOwners(device.NewDeviceOwners(deviceDirectory{
+		{DeviceID: "dev-1", TemplateID: "sensor", TemplateVersion: "1.0.0"},
+	}, templates))
+
+	published := func() *notifications.Event {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("alert not published")
+			return nil
+		}
+	}
+
+	notifier.SendAlert(&Alert{AlertID: "a1", DeviceID: "dev-1", MetricName: "temperature", Severity: "critical"})
+	event := published()
+	assert.Equal(t, notifications.EventAlertFired, event.Type)
+	assert.Equal(t, "user-1", event.Owner)
+
+	// Alerts of unknown devices are left for admins
+	notifier.SendAlert(&Alert{AlertID: "a2", DeviceID: "dev-2", MetricName: "temperature", Severity: "critical"})
+	assert.Empty(t, published().Owner)
+}
@@ -0,0 +1,530 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+)
+
+// CloudProvider identifies a third-party IoT platform a bridge connects to
+type CloudProvider string
+
+const (
+	CloudProviderAWSIoT      CloudProvider = "aws_iot"
+	CloudProviderAzureIoTHub CloudProvider = "azure_iot_hub"
+)
+
+// BridgeDirection controls which way telemetry flows through a bridge
+type BridgeDirection string
+
+const (
+	// BridgeInbound ingests telemetry already flowing to the cloud platform
+	BridgeInbound BridgeDirection = "inbound"
+	// BridgeOutbound forwards telemetry ingested by Athena to the platform
+	BridgeOutbound BridgeDirection = "outbound"
+	BridgeBoth     BridgeDirection = "both"
+)
+
+const (
+	defaultBridgeQueueSize = 1000
+	bridgeRequestTimeout   = 10 * time.Second
+)
+
+var (
+	ErrCloudBridgeNotFound    = errors.New("cloud bridge not found")
+	ErrCloudBridgeUnsupported = errors.New("operation not supported by cloud bridge")
+	ErrDeviceStateUnavailable = errors.New("device state store not configured")
+)
+
+// CloudBridgeConfig configures a bridge to AWS IoT Core or Azure IoT Hub
+type CloudBridgeConfig struct {
+	Name      string          `json:"name"`
+	Provider  CloudProvider   `json:"provider"`
+	Direction BridgeDirection `json:"direction"`
+	// Endpoint is the AWS IoT data endpoint or the IoT Hub hostname
+	Endpoint string `json:"endpoint"`
+	// TopicTemplate maps AWS IoT topics to devices, e.g.
+	// "dt/athena/{device_id}/{kind}". Telemetry uses the "data" kind.
+	TopicTemplate string `json:"topic_template,omitempty"`
+	// AWS IoT Core X.509 credentials for the bridge's thing
+	ClientID string `json:"client_id,omitempty"`
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	CAFile   string `json:"ca_file,omitempty"`
+	// Azure IoT Hub shared access policy
+	PolicyName string `json:"policy_name,omitempty"`
+	PolicyKey  string `json:"-"`
+	// WebhookSecret authenticates Event Grid deliveries to Azure bridges
+	WebhookSecret string `json:"-"`
+	// SyncState mirrors device shadows and twins onto registered devices
+	SyncState bool `json:"sync_state"`
+	// QueueSize bounds outbound telemetry waiting to be forwarded
+	QueueSize int `json:"queue_size,omitempty"`
+}
+
+// Validate checks the bridge configuration
+func (c *CloudBridgeConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("cloud bridge name is required")
+	}
+	switch c.Direction {
+	case BridgeInbound, BridgeOutbound, BridgeBoth:
+	default:
+		return fmt.Errorf("cloud bridge %s: direction must be inbound, outbound or both", c.Name)
+	}
+	if c.Endpoint == "" {
+		return fmt.Errorf("cloud bridge %s: endpoint is required", c.Name)
+	}
+
+	switch c.Provider {
+	case CloudProviderAWSIoT:
+		if c.CertFile == "" || c.KeyFile == "" {
+			return fmt.Errorf("cloud bridge %s: cert_file and key_file are required for AWS IoT", c.Name)
+		}
+	case CloudProviderAzureIoTHub:
+		if c.PolicyName == "" || c.PolicyKey == "" {
+			return fmt.Errorf("cloud bridge %s: policy_name and policy_key are required for Azure IoT Hub", c.Name)
+		}
+	default:
+		return fmt.Errorf("cloud bridge %s: unknown provider %q", c.Name, c.Provider)
+	}
+	return nil
+}
+
+func (c *CloudBridgeConfig) inbound() bool {
+	return c.Direction == BridgeInbound || c.Direction == BridgeBoth
+}
+
+func (c *CloudBridgeConfig) outbound() bool {
+	return c.Direction == BridgeOutbound || c.Direction == BridgeBoth
+}
+
+// DeviceStateStore receives device state mirrored from shadows and twins
+type DeviceStateStore interface {
+	GetDevice(ctx context.Context, deviceID string) (*device.Device, error)
+	UpdateDevice(ctx context.Context, device *device.Device) error
+}
+
+// CloudDeviceState is a shadow or twin reduced to what the device service
+// tracks. Desired state maps to device parameters and reported state to the
+// device's reported properties.
+type CloudDeviceState struct {
+	DeviceID  string
+	Desired   map[string]interface{}
+	Reported  map[string]interface{}
+	Connected *bool
+	Timestamp time.Time
+}
+
+// CloudBridgeStatus reports a bridge's health and traffic counters
+type CloudBridgeStatus struct {
+	Name         string          `json:"name"`
+	Provider     CloudProvider   `json:"provider"`
+	Direction    BridgeDirection `json:"direction"`
+	SyncState    bool            `json:"sync_state"`
+	Connected    bool            `json:"connected"`
+	Received     int64           `json:"received"`
+	Forwarded    int64           `json:"forwarded"`
+	Dropped      int64           `json:"dropped"`
+	Failed       int64           `json:"failed"`
+	StateUpdates int64           `json:"state_updates"`
+}
+
+// cloudAdapter speaks one provider's protocol
+type cloudAdapter interface {
+	start(ctx context.Context) error
+	stop()
+	connected() bool
+	forward(ctx context.Context, data *TelemetryData) error
+	// requestState asks the platform for a device's shadow or twin
+	requestState(ctx context.Context, deviceID string) error
+}
+
+// CloudBridge moves telemetry and device state between Athena and a cloud
+// IoT platform
+type CloudBridge struct {
+	config  CloudBridgeConfig
+	adapter cloudAdapter
+	set     *cloudBridgeSet
+	logger  *logger.Logger
+	queue   chan *TelemetryData
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	stopped bool
+
+	received     atomic.Int64
+	forwarded    atomic.Int64
+	dropped      atomic.Int64
+	failed       atomic.Int64
+	stateUpdates atomic.Int64
+}
+
+// Status returns the bridge's health and traffic counters
+func (b *CloudBridge) Status() CloudBridgeStatus {
+	return CloudBridgeStatus{
+		Name:         b.config.Name,
+		Provider:     b.config.Provider,
+		Direction:    b.config.Direction,
+		SyncState:    b.config.SyncState,
+		Connected:    b.adapter.connected(),
+		Received:     b.received.Load(),
+		Forwarded:    b.forwarded.Load(),
+		Dropped:      b.dropped.Load(),
+		Failed:       b.failed.Load(),
+		StateUpdates: b.stateUpdates.Load(),
+	}
+}
+
+func (b *CloudBridge) start(ctx context.Context) error {
+	if b.config.outbound() {
+		b.wg.Add(1)
+		go b.forwardLoop(ctx)
+	}
+	return b.adapter.start(ctx)
+}
+
+func (b *CloudBridge) stop() {
+	b.mu.Lock()
+	stopped := b.stopped
+	b.stopped = true
+	b.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	if b.config.outbound() {
+		close(b.queue)
+		b.wg.Wait()
+	}
+	b.adapter.stop()
+}
+
+// enqueue hands telemetry to the forwarding loop without blocking ingestion
+func (b *CloudBridge) enqueue(data *TelemetryData) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.stopped {
+		return
+	}
+
+	select {
+	case b.queue <- data:
+	default:
+		if b.dropped.Add(1) == 1 {
+			b.logger.Warn("Cloud bridge queue full, dropping telemetry", "bridge", b.config.Name)
+		}
+	}
+}
+
+func (b *CloudBridge) forwardLoop(ctx context.Context) {
+	defer b.wg.Done()
+	for data := range b.queue {
+		forwardCtx, cancel := context.WithTimeout(ctx, bridgeRequestTimeout)
+		err := b.adapter.forward(forwardCtx, data)
+		cancel()
+		if err != nil {
+			b.failed.Add(1)
+			b.logger.Error("Cloud bridge failed to forward telemetry", "bridge", b.config.Name, "device_id", data.DeviceID, "error", err)
+			continue
+		}
+		b.forwarded.Add(1)
+	}
+}
+
+// ingest stores telemetry received from the cloud platform. It bypasses
+// outbound forwarding so telemetry never loops back to where it came from.
+func (b *CloudBridge) ingest(ctx context.Context, data *TelemetryData) error {
+	if err := b.set.repository.StoreTelemetry(ctx, data); err != nil {
+		return fmt.Errorf("failed to store telemetry: %w", err)
+	}
+	b.received.Add(1)
+	return nil
+}
+
+// applyState mirrors shadow or twin state onto a registered device
+func (b *CloudBridge) applyState(ctx context.Context, state *CloudDeviceState) error {
+	if !b.config.SyncState {
+		return nil
+	}
+	store := b.set.deviceStore()
+	if store == nil {
+		return ErrDeviceStateUnavailable
+	}
+
+	dev, err := store.GetDevice(ctx, state.DeviceID)
+	if err != nil {
+		return fmt.Errorf("device %s is not registered: %w", state.DeviceID, err)
+	}
+	applyCloudState(dev, state)
+	if err := store.UpdateDevice(ctx, dev); err != nil {
+		return fmt.Errorf("failed to update device %s: %w", state.DeviceID, err)
+	}
+	b.stateUpdates.Add(1)
+	return nil
+}
+
+// applyCloudState merges desired state into the device's parameters, where a
+// null value removes a parameter as in shadow and twin patches, and replaces
+// its reported properties
+func applyCloudState(dev *device.Device, state *CloudDeviceState) {
+	if len(state.Desired) > 0 && dev.Parameters == nil {
+		dev.Parameters = make(map[string]interface{})
+	}
+	for key, value := range state.Desired {
+		if value == nil {
+			delete(dev.Parameters, key)
+			continue
+		}
+		dev.Parameters[key] = value
+	}
+	if state.Reported != nil {
+		dev.Reported = state.Reported
+	}
+
+	if state.Connected != nil {
+		if *state.Connected {
+			dev.Status = device.DeviceStatusOnline
+		} else {
+			dev.Status = device.DeviceStatusOffline
+		}
+	}
+	if (state.Connected != nil || state.Reported != nil) && state.Timestamp.After(dev.LastSeen) {
+		dev.LastSeen = state.Timestamp
+	}
+	dev.UpdatedAt = time.Now()
+}
+
+// withoutMetadata drops the "$metadata" and "$version" entries twins embed
+// in their property documents
+func withoutMetadata(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+	cleaned := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		if !strings.HasPrefix(key, "$") {
+			cleaned[key] = value
+		}
+	}
+	return cleaned
+}
+
+// decodeCloudTelemetry converts a cloud message into telemetry. Payloads in
+// Athena's format keep their metrics and tags; flat payloads such as
+// {"temperature": 21.5, "site": "north"} use numbers as metrics and strings
+// as tags. A "timestamp" or "ts" field in RFC 3339 or epoch seconds or
+// milliseconds overrides the received time.
+func decodeCloudTelemetry(deviceID string, payload []byte, received time.Time) (*TelemetryData, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode telemetry: %w", err)
+	}
+
+	data := &TelemetryData{
+		DeviceID:  deviceID,
+		Timestamp: received,
+		Metrics:   make(map[string]interface{}),
+		Tags:      make(map[string]string),
+	}
+
+	if metrics, ok := doc["metrics"].(map[string]interface{}); ok {
+		data.Metrics = metrics
+		if tags, ok := doc["tags"].(map[string]interface{}); ok {
+			for key, value := range tags {
+				if tag, ok := value.(string); ok {
+					data.Tags[key] = tag
+				}
+			}
+		}
+	} else {
+		for key, value := range doc {
+			switch key {
+			case "device_id", "deviceId", "timestamp", "ts", "tags":
+				continue
+			}
+			switch v := value.(type) {
+			case float64:
+				data.Metrics[key] = v
+			case string:
+				data.Tags[key] = v
+			}
+		}
+	}
+
+	for key, value := range doc {
+		switch key {
+		case "device_id", "deviceId":
+			if id, ok := value.(string); ok && id != "" && id != deviceID {
+				return nil, fmt.Errorf("message from device %s names device %s", deviceID, id)
+			}
+		case "timestamp", "ts":
+			if ts, ok := parseCloudTimestamp(value); ok {
+				data.Timestamp = ts
+			}
+		}
+	}
+
+	if len(data.Metrics) == 0 {
+		return nil, fmt.Errorf("message from device %s has no metrics", deviceID)
+	}
+	return data, nil
+}
+
+func parseCloudTimestamp(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		return ts, err == nil
+	case float64:
+		if v <= 0 {
+			return time.Time{}, false
+		}
+		// Epoch milliseconds pass 1e12 in 2001, epoch seconds never will
+		if v >= 1e12 {
+			return time.UnixMilli(int64(v)).UTC(), true
+		}
+		return time.Unix(int64(v), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// cloudBridgeSet holds the service's bridges and what they share
+type cloudBridgeSet struct {
+	mu      sync.RWMutex
+	bridges []*CloudBridge
+	devices DeviceStateStore
+	// repository stores inbound telemetry without forwarding it
+	repository Repository
+}
+
+func (s *cloudBridgeSet) deviceStore() DeviceStateStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.devices
+}
+
+func (s *cloudBridgeSet) list() []*CloudBridge {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*CloudBridge(nil), s.bridges...)
+}
+
+func (s *cloudBridgeSet) get(name string) (*CloudBridge, error) {
+	for _, bridge := range s.list() {
+		if bridge.config.Name == name {
+			return bridge, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrCloudBridgeNotFound, name)
+}
+
+func (s *cloudBridgeSet) forward(data *TelemetryData) {
+	for _, bridge := range s.list() {
+		if bridge.config.outbound() {
+			bridge.enqueue(data)
+		}
+	}
+}
+
+// forwardingRepository hands stored telemetry to outbound cloud bridges
+type forwardingRepository struct {
+	Repository
+	bridges *cloudBridgeSet
+}
+
+// StoreTelemetry stores data and queues it for outbound bridges
+func (r *forwardingRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	if err := r.Repository.StoreTelemetry(ctx, data); err != nil {
+		return err
+	}
+	r.bridges.forward(data)
+	return nil
+}
+
+// StoreTelemetryBatch stores a batch and queues it for outbound bridges
+func (r *forwardingRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	if err := r.Repository.StoreTelemetryBatch(ctx, batch); err != nil {
+		return err
+	}
+	for _, data := range batch {
+		r.bridges.forward(data)
+	}
+	return nil
+}
+
+// AddCloudBridge configures a bridge. Bridges must be added before Start.
+func (s *Service) AddCloudBridge(config CloudBridgeConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if _, err := s.bridges.get(config.Name); err == nil {
+		return fmt.Errorf("cloud bridge %s already exists", config.Name)
+	}
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultBridgeQueueSize
+	}
+	bridge := &CloudBridge{
+		config: config,
+		set:    s.bridges,
+		logger: s.logger,
+		queue:  make(chan *TelemetryData, queueSize),
+	}
+
+	var err error
+	switch config.Provider {
+	case CloudProviderAWSIoT:
+		bridge.adapter, err = newAWSIoTAdapter(bridge)
+	case CloudProviderAzureIoTHub:
+		bridge.adapter, err = newAzureIoTHubAdapter(bridge)
+	}
+	if err != nil {
+		return fmt.Errorf("cloud bridge %s: %w", config.Name, err)
+	}
+
+	s.bridges.mu.Lock()
+	s.bridges.bridges = append(s.bridges.bridges, bridge)
+	s.bridges.mu.Unlock()
+	return nil
+}
+
+// SetDeviceStateStore sets where bridges mirror shadow and twin state
+func (s *Service) SetDeviceStateStore(store DeviceStateStore) {
+	s.bridges.mu.Lock()
+	s.bridges.devices = store
+	s.bridges.mu.Unlock()
+}
+
+// CloudBridges returns the status of every configured bridge
+func (s *Service) CloudBridges() []CloudBridgeStatus {
+	bridges := s.bridges.list()
+	statuses := make([]CloudBridgeStatus, len(bridges))
+	for i, bridge := range bridges {
+		statuses[i] = bridge.Status()
+	}
+	return statuses
+}
+
+// SyncDeviceState pulls a device's shadow or twin through a bridge
+func (s *Service) SyncDeviceState(ctx context.Context, bridgeName, deviceID string) error {
+	bridge, err := s.bridges.get(bridgeName)
+	if err != nil {
+		return err
+	}
+	if !bridge.config.SyncState {
+		return fmt.Errorf("%w: state sync is disabled for %s", ErrCloudBridgeUnsupported, bridgeName)
+	}
+	if s.bridges.deviceStore() == nil {
+		return ErrDeviceStateUnavailable
+	}
+	return bridge.adapter.requestState(ctx, deviceID)
+}
@@ -0,0 +1,247 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/topics"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// awsDefaultTopicTemplate follows AWS's dt/<application>/<thing> layout
+	awsDefaultTopicTemplate = "dt/athena/{device_id}/{kind}"
+	awsShadowDocuments      = "$aws/things/+/shadow/update/documents"
+	awsShadowGetAccepted    = "$aws/things/+/shadow/get/accepted"
+	awsConnectTimeout       = 30 * time.Second
+)
+
+// awsShadowDocument is the state part of a classic device shadow
+type awsShadowDocument struct {
+	State struct {
+		Desired  map[string]interface{} `json:"desired"`
+		Reported map[string]interface{} `json:"reported"`
+	} `json:"state"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// awsIoTAdapter bridges AWS IoT Core over MQTT with X.509 authentication.
+// Inbound bridges subscribe to device telemetry topics and to classic shadow
+// updates; outbound bridges publish telemetry to the same topics.
+type awsIoTAdapter struct {
+	bridge *CloudBridge
+	client mqtt.Client
+	topics *topics.Namespace
+}
+
+func newAWSIoTAdapter(bridge *CloudBridge) (*awsIoTAdapter, error) {
+	config := bridge.config
+
+	template := config.TopicTemplate
+	if template == "" {
+		template = awsDefaultTopicTemplate
+	}
+	namespace, err := topics.New(template, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := awsTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter := &awsIoTAdapter{bridge: bridge, topics: namespace}
+
+	broker := config.Endpoint
+	if !strings.Contains(broker, "://") {
+		broker = "ssl://" + broker
+	}
+	if !strings.Contains(strings.TrimPrefix(broker, "ssl://"), ":") {
+		broker += ":8883"
+	}
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = "athena-bridge-" + config.Name
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(broker)
+	opts.SetClientID(clientID)
+	opts.SetTLSConfig(tlsConfig)
+	opts.SetCleanSession(true)
+	opts.SetConnectTimeout(awsConnectTimeout)
+	opts.SetKeepAlive(60 * time.Second)
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(adapter.onConnect)
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		bridge.logger.Warn("Cloud bridge lost AWS IoT connection", "bridge", config.Name, "error", err)
+	})
+	adapter.client = mqtt.NewClient(opts)
+
+	return adapter, nil
+}
+
+// awsTLSConfig loads the bridge's client certificate and optional root CA
+func awsTLSConfig(config CloudBridgeConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS IoT certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AWS IoT CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func (a *awsIoTAdapter) start(ctx context.Context) error {
+	token := a.client.Connect()
+	if !token.WaitTimeout(awsConnectTimeout) {
+		return fmt.Errorf("timed out connecting to AWS IoT")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to AWS IoT: %w", err)
+	}
+	return nil
+}
+
+func (a *awsIoTAdapter) stop() {
+	a.client.Disconnect(250)
+}
+
+func (a *awsIoTAdapter) connected() bool {
+	return a.client.IsConnected()
+}
+
+// onConnect subscribes on every connect, since clean sessions drop
+// subscriptions when the connection is lost
+func (a *awsIoTAdapter) onConnect(client mqtt.Client) {
+	config := a.bridge.config
+	a.bridge.logger.Info("Cloud bridge connected to AWS IoT", "bridge", config.Name)
+	if !config.inbound() {
+		return
+	}
+
+	filters := map[string]byte{a.topics.Filter(topics.KindData): 1}
+	if config.SyncState {
+		filters[awsShadowDocuments] = 1
+		filters[awsShadowGetAccepted] = 1
+	}
+	token := client.SubscribeMultiple(filters, func(client mqtt.Client, msg mqtt.Message) {
+		if err := a.handleMessage(msg.Topic(), msg.Payload()); err != nil {
+			a.bridge.logger.Error("Cloud bridge failed to handle AWS IoT message", "bridge", config.Name, "error", err)
+		}
+	})
+	if token.WaitTimeout(awsConnectTimeout) && token.Error() != nil {
+		a.bridge.logger.Error("Cloud bridge failed to subscribe", "bridge", config.Name, "error", token.Error())
+	}
+}
+
+// handleMessage routes an AWS IoT message to telemetry or shadow handling
+func (a *awsIoTAdapter) handleMessage(topic string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bridgeRequestTimeout)
+	defer cancel()
+
+	if strings.HasPrefix(topic, "$aws/things/") {
+		return a.handleShadow(ctx, topic, payload)
+	}
+
+	deviceID, kind, ok := a.topics.Parse(topic)
+	if !ok || kind != topics.KindData {
+		return fmt.Errorf("unexpected topic %s", topic)
+	}
+	data, err := decodeCloudTelemetry(deviceID, payload, time.Now())
+	if err != nil {
+		return err
+	}
+	return a.bridge.ingest(ctx, data)
+}
+
+// handleShadow mirrors a shadow update document or get response
+func (a *awsIoTAdapter) handleShadow(ctx context.Context, topic string, payload []byte) error {
+	levels := strings.Split(topic, "/")
+	if len(levels) < 4 || levels[3] != "shadow" {
+		return fmt.Errorf("unexpected shadow topic %s", topic)
+	}
+	thing := levels[2]
+
+	var doc awsShadowDocument
+	if strings.HasSuffix(topic, "/update/documents") {
+		var update struct {
+			Current   awsShadowDocument `json:"current"`
+			Timestamp int64             `json:"timestamp"`
+		}
+		if err := json.Unmarshal(payload, &update); err != nil {
+			return fmt.Errorf("failed to decode shadow for %s: %w", thing, err)
+		}
+		doc = update.Current
+		doc.Timestamp = update.Timestamp
+	} else if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("failed to decode shadow for %s: %w", thing, err)
+	}
+
+	state := &CloudDeviceState{
+		DeviceID: thing,
+		Desired:  doc.State.Desired,
+		Reported: doc.State.Reported,
+	}
+	if doc.Timestamp > 0 {
+		state.Timestamp = time.Unix(doc.Timestamp, 0).UTC()
+	}
+	return a.bridge.applyState(ctx, state)
+}
+
+func (a *awsIoTAdapter) forward(ctx context.Context, data *TelemetryData) error {
+	topic, err := a.topics.Topic(data.DeviceID, topics.KindData)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry: %w", err)
+	}
+	return a.publish(ctx, topic, payload)
+}
+
+// requestState publishes a shadow get; the response arrives on get/accepted
+func (a *awsIoTAdapter) requestState(ctx context.Context, deviceID string) error {
+	if err := topics.ValidateLevel(deviceID); err != nil {
+		return fmt.Errorf("invalid device ID: %w", err)
+	}
+	return a.publish(ctx, fmt.Sprintf("$aws/things/%s/shadow/get", deviceID), []byte("{}"))
+}
+
+func (a *awsIoTAdapter) publish(ctx context.Context, topic string, payload []byte) error {
+	if !a.client.IsConnected() {
+		return fmt.Errorf("not connected to AWS IoT")
+	}
+	token := a.client.Publish(topic, 1, false, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("failed to publish to %s: %w", topic, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
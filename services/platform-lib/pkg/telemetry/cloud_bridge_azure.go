@@ -0,0 +1,290 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// IoT Hub REST API versions for device messages and twins
+const (
+	azureMessagesAPIVersion = "2020-03-13"
+	azureTwinAPIVersion     = "2021-04-12"
+	azureTokenLifetime      = time.Hour
+)
+
+// Event Grid event types delivered by IoT Hub
+const (
+	eventGridSubscriptionValidation = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	azureDeviceTelemetry            = "Microsoft.Devices.DeviceTelemetry"
+	azureDeviceConnected            = "Microsoft.Devices.DeviceConnected"
+	azureDeviceDisconnected         = "Microsoft.Devices.DeviceDisconnected"
+)
+
+// eventGridEvent is an event in the Event Grid schema
+type eventGridEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"eventType"`
+	EventTime time.Time       `json:"eventTime"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// azureTwin is the part of a device twin the device service tracks
+type azureTwin struct {
+	DeviceID         string    `json:"deviceId"`
+	ConnectionState  string    `json:"connectionState"`
+	LastActivityTime time.Time `json:"lastActivityTime"`
+	Properties       struct {
+		Desired  map[string]interface{} `json:"desired"`
+		Reported map[string]interface{} `json:"reported"`
+	} `json:"properties"`
+}
+
+// azureIoTHubAdapter bridges Azure IoT Hub. IoT Hub does not expose device
+// messages to services over MQTT, so inbound telemetry and connection events
+// arrive through an Event Grid subscription, twins are read from the service
+// API and outbound telemetry is sent through the device API on behalf of each
+// device. All requests are signed with the bridge's shared access policy.
+type azureIoTHubAdapter struct {
+	bridge  *CloudBridge
+	client  *http.Client
+	baseURL string
+	host    string
+	key     []byte
+	now     func() time.Time
+	healthy atomic.Bool
+}
+
+func newAzureIoTHubAdapter(bridge *CloudBridge) (*azureIoTHubAdapter, error) {
+	key, err := base64.StdEncoding.DecodeString(bridge.config.PolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("policy_key must be base64: %w", err)
+	}
+
+	baseURL := strings.TrimSuffix(bridge.config.Endpoint, "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IoT Hub endpoint: %w", err)
+	}
+
+	adapter := &azureIoTHubAdapter{
+		bridge:  bridge,
+		client:  &http.Client{Timeout: bridgeRequestTimeout},
+		baseURL: baseURL,
+		host:    strings.ToLower(parsed.Host),
+		key:     key,
+		now:     time.Now,
+	}
+	adapter.healthy.Store(true)
+	return adapter, nil
+}
+
+func (a *azureIoTHubAdapter) start(ctx context.Context) error {
+	return nil
+}
+
+func (a *azureIoTHubAdapter) stop() {}
+
+// connected reports whether the last IoT Hub request succeeded
+func (a *azureIoTHubAdapter) connected() bool {
+	return a.healthy.Load()
+}
+
+// sasToken signs a shared access signature for the hub
+func (a *azureIoTHubAdapter) sasToken() string {
+	resource := url.QueryEscape(a.host)
+	expiry := a.now().Add(azureTokenLifetime).Unix()
+
+	mac := hmac.New(sha256.New, a.key)
+	fmt.Fprintf(mac, "%s\n%d", resource, expiry)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%d&skn=%s",
+		resource, url.QueryEscape(signature), expiry, url.QueryEscape(a.bridge.config.PolicyName))
+}
+
+// do sends a signed request to the hub and returns the response body
+func (a *azureIoTHubAdapter) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", a.sasToken())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("iothub-contenttype", "application/json")
+		req.Header.Set("iothub-contentencoding", "utf-8")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		a.healthy.Store(false)
+		return nil, fmt.Errorf("IoT Hub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		a.healthy.Store(false)
+		return nil, fmt.Errorf("failed to read IoT Hub response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		a.healthy.Store(resp.StatusCode < 500)
+		return nil, fmt.Errorf("IoT Hub returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	a.healthy.Store(true)
+	return data, nil
+}
+
+// forward sends telemetry as a device-to-cloud message from its device
+func (a *azureIoTHubAdapter) forward(ctx context.Context, data *TelemetryData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry: %w", err)
+	}
+	path := fmt.Sprintf("/devices/%s/messages/events?api-version=%s", url.PathEscape(data.DeviceID), azureMessagesAPIVersion)
+	_, err = a.do(ctx, http.MethodPost, path, payload)
+	return err
+}
+
+// requestState reads a device twin and mirrors it onto the device
+func (a *azureIoTHubAdapter) requestState(ctx context.Context, deviceID string) error {
+	path := fmt.Sprintf("/twins/%s?api-version=%s", url.PathEscape(deviceID), azureTwinAPIVersion)
+	body, err := a.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	var twin azureTwin
+	if err := json.Unmarshal(body, &twin); err != nil {
+		return fmt.Errorf("failed to decode twin for %s: %w", deviceID, err)
+	}
+	connected := twin.ConnectionState == "Connected"
+	return a.bridge.applyState(ctx, &CloudDeviceState{
+		DeviceID:  deviceID,
+		Desired:   withoutMetadata(twin.Properties.Desired),
+		Reported:  withoutMetadata(twin.Properties.Reported),
+		Connected: &connected,
+		Timestamp: twin.LastActivityTime,
+	})
+}
+
+// handleEvents processes an Event Grid delivery. It answers subscription
+// validation with the validation code; other deliveries return nil. Events
+// that fail are logged and skipped so Event Grid does not redeliver the
+// ones that succeeded.
+func (a *azureIoTHubAdapter) handleEvents(ctx context.Context, body []byte) (map[string]string, error) {
+	var events []eventGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("invalid Event Grid delivery: %w", err)
+	}
+
+	for _, event := range events {
+		if event.EventType == eventGridSubscriptionValidation {
+			var validation struct {
+				ValidationCode string `json:"validationCode"`
+			}
+			if err := json.Unmarshal(event.Data, &validation); err != nil {
+				return nil, fmt.Errorf("invalid subscription validation: %w", err)
+			}
+			return map[string]string{"validationResponse": validation.ValidationCode}, nil
+		}
+
+		if err := a.handleEvent(ctx, &event); err != nil {
+			a.bridge.logger.Error("Cloud bridge failed to handle Azure event", "bridge", a.bridge.config.Name, "event_id", event.ID, "error", err)
+		}
+	}
+	return nil, nil
+}
+
+func (a *azureIoTHubAdapter) handleEvent(ctx context.Context, event *eventGridEvent) error {
+	switch event.EventType {
+	case azureDeviceTelemetry:
+		var message struct {
+			Properties       map[string]string `json:"properties"`
+			SystemProperties map[string]string `json:"systemProperties"`
+			Body             json.RawMessage   `json:"body"`
+		}
+		if err := json.Unmarshal(event.Data, &message); err != nil {
+			return fmt.Errorf("invalid telemetry event: %w", err)
+		}
+		deviceID := message.SystemProperties["iothub-connection-device-id"]
+		if deviceID == "" {
+			return fmt.Errorf("telemetry event has no device ID")
+		}
+
+		// Bodies that are not UTF-8 JSON are delivered base64 encoded
+		body := []byte(message.Body)
+		var encoded string
+		if json.Unmarshal(body, &encoded) == nil {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("invalid telemetry body: %w", err)
+			}
+			body = decoded
+		}
+
+		received := event.EventTime
+		if enqueued, err := time.Parse(time.RFC3339Nano, message.SystemProperties["iothub-enqueuedtime"]); err == nil {
+			received = enqueued
+		}
+		data, err := decodeCloudTelemetry(deviceID, body, received)
+		if err != nil {
+			return err
+		}
+		for key, value := range message.Properties {
+			if _, exists := data.Tags[key]; !exists {
+				data.Tags[key] = value
+			}
+		}
+		return a.bridge.ingest(ctx, data)
+
+	case azureDeviceConnected, azureDeviceDisconnected:
+		var info struct {
+			DeviceID string `json:"deviceId"`
+		}
+		if err := json.Unmarshal(event.Data, &info); err != nil || info.DeviceID == "" {
+			return fmt.Errorf("invalid connection event")
+		}
+		connected := event.EventType == azureDeviceConnected
+		if err := a.bridge.applyState(ctx, &CloudDeviceState{
+			DeviceID:  info.DeviceID,
+			Connected: &connected,
+			Timestamp: event.EventTime,
+		}); err != nil {
+			return err
+		}
+		// Twins are not delivered through Event Grid, so read the twin
+		// when a device connects
+		if connected && a.bridge.config.SyncState {
+			return a.requestState(ctx, info.DeviceID)
+		}
+	}
+	return nil
+}
+
+// HandleCloudEvents processes an Event Grid delivery for an Azure bridge
+func (s *Service) HandleCloudEvents(ctx context.Context, bridgeName string, body []byte) (map[string]string, error) {
+	bridge, err := s.bridges.get(bridgeName)
+	if err != nil {
+		return nil, err
+	}
+	adapter, ok := bridge.adapter.(*azureIoTHubAdapter)
+	if !ok || !bridge.config.inbound() {
+		return nil, fmt.Errorf("%w: %s does not receive Event Grid deliveries", ErrCloudBridgeUnsupported, bridgeName)
+	}
+	return adapter.handleEvents(ctx, body)
+}
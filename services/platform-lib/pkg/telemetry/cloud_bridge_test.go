@@ -0,0 +1,377 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deviceStateStore keeps devices in memory
+type deviceStateStore struct {
+	mu      sync.Mutex
+	devices map[string]*device.Device
+}
+
+func newDeviceStateStore(ids ...string) *deviceStateStore {
+	store := &deviceStateStore{devices: make(map[string]*device.Device)}
+	for _, id := range ids {
+		store.devices[id] = &device.Device{DeviceID: id, Status: device.DeviceStatusProvisioned, Parameters: map[string]interface{}{"interval": 30.0}}
+	}
+	return store
+}
+
+func (s *deviceStateStore) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.devices[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("device not found")
+	}
+	copied := *dev
+	return &copied, nil
+}
+
+func (s *deviceStateStore) UpdateDevice(ctx context.Context, dev *device.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[dev.DeviceID] = dev
+	return nil
+}
+
+func (s *deviceStateStore) get(deviceID string) *device.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.devices[deviceID]
+}
+
+// iotHubStub records requests and serves twins like IoT Hub's REST API
+type iotHubStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages map[string][]map[string]interface{}
+	auth     []string
+}
+
+func newIoTHubStub(t *testing.T) *iotHubStub {
+	stub := &iotHubStub{messages: make(map[string][]map[string]interface{})}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		stub.auth = append(stub.auth, r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages/events"):
+			deviceID := strings.Split(r.URL.Path, "/")[2]
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			stub.messages[deviceID] = append(stub.messages[deviceID], body)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/twins/dev-1":
+			_, _ = io.WriteString(w, `{
+				"deviceId": "dev-1",
+				"connectionState": "Connected",
+				"lastActivityTime": "2026-03-01T10:00:00Z",
+				"properties": {
+					"desired": {"interval": 60, "mode": "eco", "$version": 4, "$metadata": {}},
+					"reported": {"firmware": "1.4.0", "$version": 9}
+				}
+			}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *iotHubStub) messageCount(deviceID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages[deviceID])
+}
+
+func newBridgeService(t *testing.T) (*Service, *recordingRepository, *deviceStateStore) {
+	repository := &recordingRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	store := newDeviceStateStore("dev-1", "dev-2")
+	service.SetDeviceStateStore(store)
+	return service, repository, store
+}
+
+func azureBridgeConfig(endpoint string, direction BridgeDirection) CloudBridgeConfig {
+	return CloudBridgeConfig{
+		Name:          "azure",
+		Provider:      CloudProviderAzureIoTHub,
+		Direction:     direction,
+		Endpoint:      endpoint,
+		PolicyName:    "athena-bridge",
+		PolicyKey:     base64.StdEncoding.EncodeToString([]byte("secret-key")),
+		WebhookSecret: "hook-secret",
+		SyncState:     true,
+	}
+}
+
+func TestCloudBridgeConfig_Validate(t *testing.T) {
+	valid := azureBridgeConfig("hub.azure-devices.net", BridgeBoth)
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name   string
+		mutate func(c *CloudBridgeConfig)
+	}{
+		{"no name", func(c *CloudBridgeConfig) { c.Name = "" }},
+		{"bad direction", func(c *CloudBridgeConfig) { c.Direction = "sideways" }},
+		{"no endpoint", func(c *CloudBridgeConfig) { c.Endpoint = "" }},
+		{"unknown provider", func(c *CloudBridgeConfig) { c.Provider = "gcp_iot" }},
+		{"no policy key", func(c *CloudBridgeConfig) { c.PolicyKey = "" }},
+		{"aws without cert", func(c *CloudBridgeConfig) { c.Provider = CloudProviderAWSIoT }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.mutate(&config)
+			assert.Error(t, config.Validate())
+		})
+	}
+}
+
+func TestDecodeCloudTelemetry(t *testing.T) {
+	received := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// Flat payloads split numbers into metrics and strings into tags
+	data, err := decodeCloudTelemetry("dev-1", []byte(`{"temperature":21.5,"site":"north","ts":1772359200000,"nested":{"x":1}}`), received)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"temperature": 21.5}, data.Metrics)
+	assert.Equal(t, map[string]string{"site": "north"}, data.Tags)
+	assert.True(t, data.Timestamp.Equal(time.UnixMilli(1772359200000)))
+
+	// Athena's own format keeps its metrics and tags
+	data, err = decodeCloudTelemetry("dev-1", []byte(`{"device_id":"dev-1","timestamp":"2026-03-01T09:00:00Z","metrics":{"humidity":40},"tags":{"site":"south"}}`), received)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"humidity": 40.0}, data.Metrics)
+	assert.Equal(t, "south", data.Tags["site"])
+	assert.Equal(t, 9, data.Timestamp.Hour())
+
+	// Epoch seconds and missing timestamps
+	data, err = decodeCloudTelemetry("dev-1", []byte(`{"temperature":1,"timestamp":1772359200}`), received)
+	require.NoError(t, err)
+	assert.True(t, data.Timestamp.Equal(time.Unix(1772359200, 0)))
+	data, err = decodeCloudTelemetry("dev-1", []byte(`{"temperature":1}`), received)
+	require.NoError(t, err)
+	assert.True(t, data.Timestamp.Equal(received))
+
+	_, err = decodeCloudTelemetry("dev-1", []byte(`{"deviceId":"dev-2","temperature":1}`), received)
+	assert.Error(t, err, "a device may not report for another")
+	_, err = decodeCloudTelemetry("dev-1", []byte(`{"status":"ok"}`), received)
+	assert.Error(t, err)
+}
+
+func TestApplyCloudState(t *testing.T) {
+	lastSeen := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	dev := &device.Device{
+		DeviceID:   "dev-1",
+		Status:     device.DeviceStatusOffline,
+		LastSeen:   lastSeen,
+		Parameters: map[string]interface{}{"interval": 30.0, "mode": "normal", "pin": 4.0},
+	}
+
+	connected := true
+	applyCloudState(dev, &CloudDeviceState{
+		DeviceID:  "dev-1",
+		Desired:   map[string]interface{}{"interval": 60.0, "mode": nil},
+		Reported:  map[string]interface{}{"firmware": "1.4.0"},
+		Connected: &connected,
+		Timestamp: lastSeen.Add(time.Hour),
+	})
+
+	assert.Equal(t, map[string]interface{}{"interval": 60.0, "pin": 4.0}, dev.Parameters)
+	assert.Equal(t, map[string]interface{}{"firmware": "1.4.0"}, dev.Reported)
+	assert.Equal(t, device.DeviceStatusOnline, dev.Status)
+	assert.True(t, dev.LastSeen.Equal(lastSeen.Add(time.Hour)))
+
+	// Older state does not move last seen backwards
+	applyCloudState(dev, &CloudDeviceState{DeviceID: "dev-1", Reported: map[string]interface{}{}, Timestamp: lastSeen})
+	assert.True(t, dev.LastSeen.Equal(lastSeen.Add(time.Hour)))
+}
+
+func TestAzureBridge_ForwardsIngestedTelemetry(t *testing.T) {
+	hub := newIoTHubStub(t)
+	service, repository, _ := newBridgeService(t)
+	require.NoError(t, service.AddCloudBridge(azureBridgeConfig(hub.URL, BridgeOutbound)))
+	require.NoError(t, service.Start())
+
+	require.NoError(t, service.repository.StoreTelemetry(context.Background(), &TelemetryData{
+		DeviceID:  "dev-1",
+		Timestamp: time.Now(),
+		Metrics:   map[string]interface{}{"temperature": 21.5},
+	}))
+	service.Stop()
+
+	require.Len(t, repository.stored, 1)
+	require.Equal(t, 1, hub.messageCount("dev-1"))
+	assert.Equal(t, map[string]interface{}{"temperature": 21.5}, hub.messages["dev-1"][0]["metrics"])
+	assert.True(t, strings.HasPrefix(hub.auth[0], "SharedAccessSignature sr=127.0.0.1"))
+	assert.Contains(t, hub.auth[0], "&skn=athena-bridge")
+
+	status := service.CloudBridges()
+	require.Len(t, status, 1)
+	assert.Equal(t, int64(1), status[0].Forwarded)
+	assert.True(t, status[0].Connected)
+}
+
+func TestAzureBridge_SyncDeviceState(t *testing.T) {
+	hub := newIoTHubStub(t)
+	service, _, store := newBridgeService(t)
+	require.NoError(t, service.AddCloudBridge(azureBridgeConfig(hub.URL, BridgeInbound)))
+
+	require.NoError(t, service.SyncDeviceState(context.Background(), "azure", "dev-1"))
+	dev := store.get("dev-1")
+	assert.Equal(t, map[string]interface{}{"interval": 60.0, "mode": "eco"}, dev.Parameters)
+	assert.Equal(t, map[string]interface{}{"firmware": "1.4.0"}, dev.Reported)
+	assert.Equal(t, device.DeviceStatusOnline, dev.Status)
+
+	assert.ErrorIs(t, service.SyncDeviceState(context.Background(), "aws", "dev-1"), ErrCloudBridgeNotFound)
+	assert.Error(t, service.SyncDeviceState(context.Background(), "azure", "dev-3"))
+}
+
+func TestService_CloudEventsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := newIoTHubStub(t)
+	service, repository, store := newBridgeService(t)
+	require.NoError(t, service.AddCloudBridge(azureBridgeConfig(hub.URL, BridgeInbound)))
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	validation := `[{"id":"1","eventType":"Microsoft.EventGrid.SubscriptionValidationEvent","data":{"validationCode":"abc"}}]`
+	assert.Equal(t, http.StatusUnauthorized, post("/api/v1/telemetry/bridges/azure/events?code=wrong", validation).Code)
+	w := post("/api/v1/telemetry/bridges/azure/events?code=hook-secret", validation)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"validationResponse":"abc"}`, w.Body.String())
+
+	body := base64.StdEncoding.EncodeToString([]byte(`{"temperature":22.5}`))
+	events := fmt.Sprintf(`[
+		{"id":"2","eventType":"Microsoft.Devices.DeviceTelemetry","eventTime":"2026-03-01T10:00:01Z","data":{
+			"properties":{"site":"north"},
+			"systemProperties":{"iothub-connection-device-id":"dev-2","iothub-enqueuedtime":"2026-03-01T10:00:00Z"},
+			"body":%q}},
+		{"id":"3","eventType":"Microsoft.Devices.DeviceTelemetry","data":{
+			"systemProperties":{"iothub-connection-device-id":"dev-2"},
+			"body":{"status":"no metrics"}}},
+		{"id":"4","eventType":"Microsoft.Devices.DeviceConnected","eventTime":"2026-03-01T10:00:02Z","data":{"deviceId":"dev-1"}}
+	]`, body)
+	require.Equal(t, http.StatusOK, post("/api/v1/telemetry/bridges/azure/events?code=hook-secret", events).Code)
+
+	// The bad event is skipped without failing the delivery
+	require.Len(t, repository.stored, 1)
+	assert.Equal(t, "dev-2", repository.stored[0].DeviceID)
+	assert.Equal(t, 22.5, repository.stored[0].Metrics["temperature"])
+	assert.Equal(t, "north", repository.stored[0].Tags["site"])
+	assert.Equal(t, 0, repository.stored[0].Timestamp.Second())
+
+	// Connecting pulls the twin
+	assert.Equal(t, "eco", store.get("dev-1").Parameters["mode"])
+
+	assert.Equal(t, http.StatusNotFound, post("/api/v1/telemetry/bridges/other/events", validation).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/telemetry/bridges/azure/events?code=hook-secret", `{`).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/bridges", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"received":1`)
+	assert.NotContains(t, w.Body.String(), "hook-secret")
+}
+
+// writeTestCertificate writes a self-signed client certificate and key
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "athena-bridge"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "bridge.crt"), filepath.Join(dir, "bridge.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestAWSBridge_HandleMessages(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	service, repository, store := newBridgeService(t)
+
+	config := CloudBridgeConfig{
+		Name:      "aws",
+		Provider:  CloudProviderAWSIoT,
+		Direction: BridgeInbound,
+		Endpoint:  "example-ats.iot.us-east-1.amazonaws.com",
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+		SyncState: true,
+	}
+	require.NoError(t, service.AddCloudBridge(config))
+	bridge, err := service.bridges.get("aws")
+	require.NoError(t, err)
+	adapter := bridge.adapter.(*awsIoTAdapter)
+
+	require.NoError(t, adapter.handleMessage("dt/athena/dev-1/data", []byte(`{"temperature":19.5}`)))
+	require.Len(t, repository.stored, 1)
+	assert.Equal(t, "dev-1", repository.stored[0].DeviceID)
+	assert.Error(t, adapter.handleMessage("dt/other/dev-1/data", []byte(`{"temperature":19.5}`)))
+
+	update := `{"previous":{},"current":{"state":{"desired":{"interval":15},"reported":{"firmware":"2.0.0"}},"version":7},"timestamp":1772359200}`
+	require.NoError(t, adapter.handleMessage("$aws/things/dev-2/shadow/update/documents", []byte(update)))
+	dev := store.get("dev-2")
+	assert.Equal(t, 15.0, dev.Parameters["interval"])
+	assert.Equal(t, map[string]interface{}{"firmware": "2.0.0"}, dev.Reported)
+	assert.True(t, dev.LastSeen.Equal(time.Unix(1772359200, 0)))
+
+	get := `{"state":{"reported":{"firmware":"2.0.1"}},"version":8,"timestamp":1772359300}`
+	require.NoError(t, adapter.handleMessage("$aws/things/dev-2/shadow/get/accepted", []byte(get)))
+	assert.Equal(t, "2.0.1", store.get("dev-2").Reported["firmware"])
+
+	// Shadows of devices unknown to Athena are rejected
+	assert.Error(t, adapter.handleMessage("$aws/things/dev-9/shadow/update/documents", []byte(update)))
+
+	config.Name = "aws-bad-key"
+	config.KeyFile = certFile
+	assert.Error(t, service.AddCloudBridge(config))
+}
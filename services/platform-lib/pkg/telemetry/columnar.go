@@ -0,0 +1,326 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// StorageEncoding is how a metric's values are packed in a telemetry block
+type StorageEncoding string
+
+const (
+	// StorageFloat64 keeps full precision (8 bytes per value)
+	StorageFloat64 StorageEncoding = "float64"
+	// StorageInt16 and StorageInt32 store value*10^scale in 2 or 4 bytes
+	StorageInt16 StorageEncoding = "int16"
+	StorageInt32 StorageEncoding = "int32"
+	// StorageScaled stores value*10^scale as a variable-length integer
+	StorageScaled StorageEncoding = "scaled"
+	// StorageDelta stores the difference between consecutive scaled
+	// values, which is small for slowly changing metrics
+	StorageDelta StorageEncoding = "delta"
+)
+
+const (
+	// maxBlockPoints keeps a block well under the Datastore entity size limit
+	maxBlockPoints  = 1000
+	maxStorageScale = 9
+	// maxExactInteger is the largest integer a float64 holds exactly
+	maxExactInteger = 1 << 53
+)
+
+// StorageHint tells the repository how to store a high-frequency metric.
+// Numeric values of hinted metrics written through StoreTelemetryBatch are
+// packed into one TelemetryBlock per device, metric and batch instead of one
+// entity per point. Scale is the number of decimal places kept; encodings
+// other than float64 round values to it.
+type StorageHint struct {
+	Metric   string          `json:"metric" mapstructure:"metric"`
+	Encoding StorageEncoding `json:"encoding" mapstructure:"encoding"`
+	Scale    int             `json:"scale,omitempty" mapstructure:"scale"`
+}
+
+// Validate checks the hint's encoding and scale
+func (h StorageHint) Validate() error {
+	if h.Metric == "" {
+		return fmt.Errorf("storage hint requires a metric name")
+	}
+	switch h.Encoding {
+	case StorageFloat64, StorageInt16, StorageInt32, StorageScaled, StorageDelta:
+	default:
+		return fmt.Errorf("unsupported storage encoding %q for metric %s", h.Encoding, h.Metric)
+	}
+	if h.Scale < 0 || h.Scale > maxStorageScale {
+		return fmt.Errorf("storage scale for metric %s must be between 0 and %d", h.Metric, maxStorageScale)
+	}
+	return nil
+}
+
+// TelemetryBlockEntity is the Datastore entity for a column of values of one
+// metric. Timestamps and values are packed; tags are shared by all points.
+type TelemetryBlockEntity struct {
+	DeviceID   string    `datastore:"device_id"`
+	MetricName string    `datastore:"metric_name"`
+	StartTime  time.Time `datastore:"start_time"`
+	EndTime    time.Time `datastore:"end_time"`
+	Count      int       `datastore:"count,noindex"`
+	Encoding   string    `datastore:"encoding,noindex"`
+	Scale      int       `datastore:"scale,noindex"`
+	Timestamps []byte    `datastore:"timestamps,noindex"`
+	Values     []byte    `datastore:"values,noindex"`
+	TagsJSON   string    `datastore:"tags_json,noindex"`
+}
+
+// splitBatch converts a batch into per-point entities and blocks. Numeric
+// values of hinted metrics go into blocks grouped by device, metric and tags.
+func splitBatch(batch []*TelemetryData, hints map[string]StorageHint) ([]*TelemetryEntity, []*TelemetryBlockEntity, error) {
+	type columnKey struct {
+		deviceID, metric, tags string
+	}
+
+	var points []*TelemetryEntity
+	columns := make(map[columnKey][]*TelemetryEntity)
+	var order []columnKey
+
+	for _, data := range batch {
+		entities, err := data.ToEntities()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entity := range entities {
+			if _, ok := hints[entity.MetricName]; !ok || entity.MetricString != "" {
+				points = append(points, entity)
+				continue
+			}
+			key := columnKey{entity.DeviceID, entity.MetricName, entity.TagsJSON}
+			if _, ok := columns[key]; !ok {
+				order = append(order, key)
+			}
+			columns[key] = append(columns[key], entity)
+		}
+	}
+
+	var blocks []*TelemetryBlockEntity
+	for _, key := range order {
+		column := columns[key]
+		sort.SliceStable(column, func(i, j int) bool {
+			return column[i].Timestamp.Before(column[j].Timestamp)
+		})
+		for start := 0; start < len(column); start += maxBlockPoints {
+			end := start + maxBlockPoints
+			if end > len(column) {
+				end = len(column)
+			}
+			blocks = append(blocks, newTelemetryBlock(column[start:end], hints[key.metric]))
+		}
+	}
+
+	return points, blocks, nil
+}
+
+// newTelemetryBlock packs time-ordered points of one column. If a value does
+// not fit the hinted encoding the block falls back to scaled, then float64.
+func newTelemetryBlock(column []*TelemetryEntity, hint StorageHint) *TelemetryBlockEntity {
+	values := make([]float64, len(column))
+	timestamps := make([]time.Time, len(column))
+	for i, entity := range column {
+		values[i] = entity.MetricValue
+		timestamps[i] = entity.Timestamp
+	}
+
+	block := &TelemetryBlockEntity{
+		DeviceID:   column[0].DeviceID,
+		MetricName: column[0].MetricName,
+		StartTime:  timestamps[0],
+		EndTime:    timestamps[len(timestamps)-1],
+		Count:      len(column),
+		Scale:      hint.Scale,
+		Timestamps: encodeTimestamps(timestamps),
+		TagsJSON:   column[0].TagsJSON,
+	}
+
+	for _, encoding := range []StorageEncoding{hint.Encoding, StorageScaled, StorageFloat64} {
+		if packed, ok := encodeValues(values, encoding, hint.Scale); ok {
+			block.Encoding = string(encoding)
+			block.Values = packed
+			break
+		}
+	}
+	return block
+}
+
+// Points decodes the block's points that fall within timeRange
+func (b *TelemetryBlockEntity) Points(timeRange TimeRange) ([]*MetricPoint, error) {
+	timestamps, err := decodeTimestamps(b.Timestamps, b.Count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamps in telemetry block: %w", err)
+	}
+	values, err := decodeValues(b.Values, StorageEncoding(b.Encoding), b.Scale, b.Count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid values in telemetry block: %w", err)
+	}
+
+	var tags map[string]string
+	if b.TagsJSON != "" {
+		if err := json.Unmarshal([]byte(b.TagsJSON), &tags); err != nil {
+			return nil, err
+		}
+	}
+
+	points := make([]*MetricPoint, 0, b.Count)
+	for i, timestamp := range timestamps {
+		if timestamp.Before(timeRange.Start) || timestamp.After(timeRange.End) {
+			continue
+		}
+		points = append(points, &MetricPoint{
+			Timestamp:   timestamp,
+			MetricName:  b.MetricName,
+			MetricValue: values[i],
+			Tags:        tags,
+		})
+	}
+	return points, nil
+}
+
+// encodeTimestamps stores the first timestamp and then the gaps between
+// consecutive (sorted) timestamps as varints
+func encodeTimestamps(timestamps []time.Time) []byte {
+	buf := make([]byte, 0, len(timestamps)*4+binary.MaxVarintLen64)
+	previous := int64(0)
+	for i, timestamp := range timestamps {
+		nanos := timestamp.UnixNano()
+		if i == 0 {
+			buf = binary.AppendVarint(buf, nanos)
+		} else {
+			buf = binary.AppendUvarint(buf, uint64(nanos-previous))
+		}
+		previous = nanos
+	}
+	return buf
+}
+
+func decodeTimestamps(buf []byte, count int) ([]time.Time, error) {
+	timestamps := make([]time.Time, count)
+	var nanos int64
+	for i := range timestamps {
+		if i == 0 {
+			value, n := binary.Varint(buf)
+			if n <= 0 {
+				return nil, fmt.Errorf("truncated at point %d", i)
+			}
+			nanos, buf = value, buf[n:]
+		} else {
+			gap, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return nil, fmt.Errorf("truncated at point %d", i)
+			}
+			nanos, buf = nanos+int64(gap), buf[n:]
+		}
+		timestamps[i] = time.Unix(0, nanos).UTC()
+	}
+	return timestamps, nil
+}
+
+// encodeValues packs values; ok is false when a value does not fit the encoding
+func encodeValues(values []float64, encoding StorageEncoding, scale int) ([]byte, bool) {
+	if encoding == StorageFloat64 {
+		buf := make([]byte, 0, len(values)*8)
+		for _, value := range values {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(value))
+		}
+		return buf, true
+	}
+
+	factor := math.Pow10(scale)
+	scaled := make([]int64, len(values))
+	for i, value := range values {
+		s := math.Round(value * factor)
+		if math.IsNaN(s) || math.Abs(s) > maxExactInteger {
+			return nil, false
+		}
+		scaled[i] = int64(s)
+	}
+
+	var buf []byte
+	switch encoding {
+	case StorageInt16:
+		buf = make([]byte, 0, len(values)*2)
+		for _, s := range scaled {
+			if s < math.MinInt16 || s > math.MaxInt16 {
+				return nil, false
+			}
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(s)))
+		}
+	case StorageInt32:
+		buf = make([]byte, 0, len(values)*4)
+		for _, s := range scaled {
+			if s < math.MinInt32 || s > math.MaxInt32 {
+				return nil, false
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(s)))
+		}
+	case StorageScaled:
+		for _, s := range scaled {
+			buf = binary.AppendVarint(buf, s)
+		}
+	case StorageDelta:
+		previous := int64(0)
+		for _, s := range scaled {
+			buf = binary.AppendVarint(buf, s-previous)
+			previous = s
+		}
+	default:
+		return nil, false
+	}
+	return buf, true
+}
+
+func decodeValues(buf []byte, encoding StorageEncoding, scale, count int) ([]float64, error) {
+	values := make([]float64, count)
+	factor := math.Pow10(scale)
+
+	switch encoding {
+	case StorageFloat64:
+		if len(buf) != count*8 {
+			return nil, fmt.Errorf("expected %d bytes, got %d", count*8, len(buf))
+		}
+		for i := range values {
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[i*8:]))
+		}
+	case StorageInt16:
+		if len(buf) != count*2 {
+			return nil, fmt.Errorf("expected %d bytes, got %d", count*2, len(buf))
+		}
+		for i := range values {
+			values[i] = float64(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / factor
+		}
+	case StorageInt32:
+		if len(buf) != count*4 {
+			return nil, fmt.Errorf("expected %d bytes, got %d", count*4, len(buf))
+		}
+		for i := range values {
+			values[i] = float64(int32(binary.LittleEndian.Uint32(buf[i*4:]))) / factor
+		}
+	case StorageScaled, StorageDelta:
+		previous := int64(0)
+		for i := range values {
+			s, n := binary.Varint(buf)
+			if n <= 0 {
+				return nil, fmt.Errorf("truncated at point %d", i)
+			}
+			buf = buf[n:]
+			if encoding == StorageDelta {
+				s += previous
+				previous = s
+			}
+			values[i] = float64(s) / factor
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return values, nil
+}
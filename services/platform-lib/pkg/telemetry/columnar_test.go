@@ -0,0 +1,262 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var blockStart = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// sensorBatch returns one reading per second for each value
+func sensorBatch(deviceID, metric string, values ...interface{}) []*TelemetryData {
+	batch := make([]*TelemetryData, len(values))
+	for i, value := range values {
+		batch[i] = &TelemetryData{
+			DeviceID:  deviceID,
+			Timestamp: blockStart.Add(time.Duration(i) * time.Second),
+			Metrics:   map[string]interface{}{metric: value},
+		}
+	}
+	return batch
+}
+
+func TestStorageHint_Validate(t *testing.T) {
+	assert.NoError(t, StorageHint{Metric: "temperature", Encoding: StorageDelta, Scale: 2}.Validate())
+	assert.Error(t, StorageHint{Encoding: StorageInt16}.Validate())
+	assert.Error(t, StorageHint{Metric: "temperature", Encoding: "int8"}.Validate())
+	assert.Error(t, StorageHint{Metric: "temperature", Encoding: StorageScaled, Scale: 10}.Validate())
+	assert.Error(t, StorageHint{Metric: "temperature", Encoding: StorageScaled, Scale: -1}.Validate())
+}
+
+func TestEncodeValues_RoundTrip(t *testing.T) {
+	values := []float64{21.5, 21.52, 21.49, -3.25, 0}
+
+	for _, encoding := range []StorageEncoding{StorageFloat64, StorageInt16, StorageInt32, StorageScaled, StorageDelta} {
+		t.Run(string(encoding), func(t *testing.T) {
+			buf, ok := encodeValues(values, encoding, 2)
+			require.True(t, ok)
+
+			decoded, err := decodeValues(buf, encoding, 2, len(values))
+			require.NoError(t, err)
+			assert.InDeltaSlice(t, values, decoded, 1e-9)
+		})
+	}
+}
+
+func TestEncodeValues_Size(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = 20 + float64(i%5)*0.01
+	}
+
+	float64s, _ := encodeValues(values, StorageFloat64, 2)
+	int16s, _ := encodeValues(values, StorageInt16, 2)
+	deltas, _ := encodeValues(values, StorageDelta, 2)
+
+	assert.Len(t, float64s, 800)
+	assert.Len(t, int16s, 200)
+	assert.Less(t, len(deltas), len(int16s))
+}
+
+func TestEncodeValues_RoundsToScale(t *testing.T) {
+	buf, ok := encodeValues([]float64{1.23456}, StorageScaled, 2)
+	require.True(t, ok)
+
+	decoded, err := decodeValues(buf, StorageScaled, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1.23}, decoded)
+}
+
+func TestEncodeValues_Overflow(t *testing.T) {
+	_, ok := encodeValues([]float64{400}, StorageInt16, 2)
+	assert.False(t, ok)
+
+	_, ok = encodeValues([]float64{1e300}, StorageScaled, 0)
+	assert.False(t, ok)
+}
+
+func TestDecodeValues_Truncated(t *testing.T) {
+	_, err := decodeValues([]byte{1, 2, 3}, StorageInt16, 0, 2)
+	assert.Error(t, err)
+
+	_, err = decodeValues(nil, StorageDelta, 0, 1)
+	assert.Error(t, err)
+}
+
+func TestTimestamps_RoundTrip(t *testing.T) {
+	timestamps := []time.Time{
+		blockStart,
+		blockStart.Add(time.Millisecond),
+		blockStart.Add(time.Millisecond),
+		blockStart.Add(time.Hour),
+	}
+
+	decoded, err := decodeTimestamps(encodeTimestamps(timestamps), len(timestamps))
+	require.NoError(t, err)
+	for i := range timestamps {
+		assert.True(t, timestamps[i].Equal(decoded[i]))
+	}
+}
+
+func TestSplitBatch(t *testing.T) {
+	hints := map[string]StorageHint{
+		"temperature": {Metric: "temperature", Encoding: StorageDelta, Scale: 1},
+	}
+
+	batch := sensorBatch("dev-1", "temperature", 20.1, 20.2, 20.3)
+	batch = append(batch, sensorBatch("dev-1", "status", "ok")...)
+	batch = append(batch, sensorBatch("dev-2", "temperature", 18.0)...)
+	// String values of a hinted metric are kept as points
+	batch = append(batch, sensorBatch("dev-2", "temperature", "n/a")...)
+
+	points, blocks, err := splitBatch(batch, hints)
+	require.NoError(t, err)
+
+	assert.Len(t, points, 2)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "dev-1", blocks[0].DeviceID)
+	assert.Equal(t, 3, blocks[0].Count)
+	assert.Equal(t, string(StorageDelta), blocks[0].Encoding)
+	assert.True(t, blocks[0].StartTime.Equal(blockStart))
+	assert.True(t, blocks[0].EndTime.Equal(blockStart.Add(2*time.Second)))
+	assert.Equal(t, "dev-2", blocks[1].DeviceID)
+	assert.Equal(t, 1, blocks[1].Count)
+}
+
+func TestSplitBatch_GroupsByTags(t *testing.T) {
+	hints := map[string]StorageHint{"rssi": {Metric: "rssi", Encoding: StorageInt16}}
+
+	batch := sensorBatch("dev-1", "rssi", -60, -61)
+	batch[1].Tags = map[string]string{"antenna": "b"}
+
+	_, blocks, err := splitBatch(batch, hints)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 2)
+}
+
+func TestSplitBatch_SortsAndChunks(t *testing.T) {
+	hints := map[string]StorageHint{"rssi": {Metric: "rssi", Encoding: StorageInt16}}
+
+	values := make([]interface{}, maxBlockPoints+10)
+	for i := range values {
+		values[i] = -50 - i%20
+	}
+	batch := sensorBatch("dev-1", "rssi", values...)
+	batch[0], batch[len(batch)-1] = batch[len(batch)-1], batch[0]
+
+	_, blocks, err := splitBatch(batch, hints)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, maxBlockPoints, blocks[0].Count)
+	assert.Equal(t, 10, blocks[1].Count)
+	assert.True(t, blocks[0].StartTime.Equal(blockStart))
+	assert.True(t, blocks[0].EndTime.Before(blocks[1].StartTime))
+}
+
+func TestNewTelemetryBlock_FallsBack(t *testing.T) {
+	hints := map[string]StorageHint{"pressure": {Metric: "pressure", Encoding: StorageInt16, Scale: 2}}
+
+	_, blocks, err := splitBatch(sensorBatch("dev-1", "pressure", 1013.2, 1013.4), hints)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, string(StorageScaled), blocks[0].Encoding)
+
+	_, blocks, err = splitBatch(sensorBatch("dev-1", "pressure", 1e300), hints)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, string(StorageFloat64), blocks[0].Encoding)
+}
+
+func TestTelemetryBlock_Points(t *testing.T) {
+	hints := map[string]StorageHint{"temperature": {Metric: "temperature", Encoding: StorageDelta, Scale: 2}}
+
+	batch := sensorBatch("dev-1", "temperature", 20.25, 20.5, 20.75, 21.0)
+	for _, data := range batch {
+		data.Tags = map[string]string{"room": "lab"}
+	}
+
+	_, blocks, err := splitBatch(batch, hints)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+
+	points, err := blocks[0].Points(TimeRange{
+		Start: blockStart.Add(time.Second),
+		End:   blockStart.Add(2 * time.Second),
+	})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, 20.5, points[0].MetricValue)
+	assert.Equal(t, 20.75, points[1].MetricValue)
+	assert.Equal(t, "temperature", points[0].MetricName)
+	assert.Equal(t, "lab", points[0].Tags["room"])
+	assert.True(t, points[1].Timestamp.Equal(blockStart.Add(2*time.Second)))
+}
+
+func TestMergeBlockMetrics(t *testing.T) {
+	metrics := []*MetricPoint{
+		{Timestamp: blockStart, MetricName: "status"},
+		{Timestamp: blockStart.Add(2 * time.Second), MetricName: "status"},
+	}
+	blockMetrics := []*MetricPoint{
+		{Timestamp: blockStart.Add(time.Second), MetricName: "temperature"},
+	}
+
+	merged := mergeBlockMetrics(metrics, blockMetrics)
+	require.Len(t, merged, 3)
+	assert.Equal(t, "temperature", merged[1].MetricName)
+}
+
+func TestLatestMetrics(t *testing.T) {
+	metrics := []*MetricPoint{
+		{Timestamp: blockStart.Add(3 * time.Second), MetricName: "status"},
+		{Timestamp: blockStart, MetricName: "status"},
+	}
+	_, blocks, err := splitBatch(sensorBatch("dev-1", "rssi", -60, -61, -62), map[string]StorageHint{"rssi": {Metric: "rssi", Encoding: StorageInt16}})
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	blockMetrics, err := blocks[0].Points(TimeRange{Start: blocks[0].StartTime, End: blocks[0].EndTime})
+	require.NoError(t, err)
+
+	latest := latestMetrics(metrics, blockMetrics, 3)
+	require.Len(t, latest, 3)
+	assert.Equal(t, "status", latest[0].MetricName)
+	assert.Equal(t, -62.0, latest[1].MetricValue)
+	assert.Equal(t, -61.0, latest[2].MetricValue)
+}
+
+func TestService_IngestBatchHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &MockRepository{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"valid", `{"points":[{"metrics":{"rssi":-60}},{"metrics":{"rssi":-61},"timestamp":"2026-03-01T00:00:01Z"}]}`, http.StatusOK},
+		{"empty", `{"points":[]}`, http.StatusBadRequest},
+		{"null point", `{"points":[null]}`, http.StatusBadRequest},
+		{"other device", `{"points":[{"device_id":"dev-2","metrics":{"rssi":-60}}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/ingest/dev-1/batch", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+}
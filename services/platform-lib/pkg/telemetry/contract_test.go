@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/contract"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractStates fill the repository with the data a consumer's
+// interaction requires
+var contractStates = map[string]func(repo *logRepository){
+	"device dev-1 has telemetry": func(repo *logRepository) {
+		repo.metrics = []*MetricPoint{
+			{Timestamp: time.Now().Add(-time.Minute), MetricName: "temperature", MetricValue: 21.5, Tags: map[string]string{}},
+		}
+	},
+	"device dev-1 has logs": func(repo *logRepository) {
+		repo.logs = []*DeviceLogEntry{
+			{DeviceID: "dev-1", Timestamp: time.Now().Add(-time.Minute), Level: LogLevelError, Tag: "i2c", Message: "bus timeout"},
+		}
+	},
+}
+
+func TestProviderContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	contracts, err := contract.LoadDir("../../contracts", "telemetry-service")
+	require.NoError(t, err)
+	require.NotEmpty(t, contracts)
+
+	for _, c := range contracts {
+		for _, interaction := range c.Interactions {
+			t.Run(c.Consumer+"/"+interaction.Description, func(t *testing.T) {
+				setState, ok := contractStates[interaction.State]
+				require.True(t, ok, "unknown provider state %q", interaction.State)
+
+				repository := &logRepository{}
+				setState(repository)
+				service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+				require.NoError(t, err)
+				router := gin.New()
+				RegisterRoutes(router, service)
+
+				assert.NoError(t, interaction.Verify(router))
+			})
+		}
+	}
+}
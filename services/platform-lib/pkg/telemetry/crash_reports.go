@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CrashReportSink receives crash and reset reports devices publish over
+// MQTT. The OTA service stores them so they can be correlated with firmware
+// releases and updates.
+type CrashReportSink interface {
+	ReportCrash(ctx context.Context, deviceID string, report json.RawMessage) error
+}
+
+// HTTPCrashReportSink forwards crash reports to the OTA service API
+type HTTPCrashReportSink struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPCrashReportSink creates a sink posting to the OTA service at baseURL
+func NewHTTPCrashReportSink(baseURL string) *HTTPCrashReportSink {
+	return &HTTPCrashReportSink{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ReportCrash posts a crash report for a device
+func (s *HTTPCrashReportSink) ReportCrash(ctx context.Context, deviceID string, report json.RawMessage) error {
+	endpoint := s.baseURL + "/api/v1/ota/devices/" + url.PathEscape(deviceID) + "/crashes"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(report))
+	if err != nil {
+		return fmt.Errorf("failed to create crash report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to forward crash report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ota-service rejected crash report with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// SetCrashReportSink sets where crash reports received over MQTT are sent.
+// It must be called before Start.
+func (s *Service) SetCrashReportSink(sink CrashReportSink) {
+	s.crashReports = sink
+}
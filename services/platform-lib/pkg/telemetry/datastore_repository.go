@@ -2,7 +2,9 @@ package telemetry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"cloud.google.com/go/datastore"
@@ -12,6 +14,7 @@ import (
 // DatastoreRepository implements the Repository interface using Google Cloud Datastore
 type DatastoreRepository struct {
 	client *datastore.Client
+	hints  map[string]StorageHint
 }
 
 // NewDatastoreRepository creates a new Datastore-backed telemetry repository
@@ -21,65 +24,162 @@ func NewDatastoreRepository(client *datastore.Client) *DatastoreRepository {
 	}
 }
 
-// StoreTelemetry stores telemetry data in Datastore
+// SetStorageHints configures per-metric storage of stored telemetry
+func (r *DatastoreRepository) SetStorageHints(hints []StorageHint) error {
+	byMetric := make(map[string]StorageHint, len(hints))
+	for _, hint := range hints {
+		if err := hint.Validate(); err != nil {
+			return err
+		}
+		byMetric[hint.Metric] = hint
+	}
+	r.hints = byMetric
+	return nil
+}
+
+// StoreTelemetry stores telemetry data in Datastore. Metrics with a storage
+// hint are written as telemetry blocks, as in StoreTelemetryBatch.
 func (r *DatastoreRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
-	entities, err := data.ToEntities()
+	return r.StoreTelemetryBatch(ctx, []*TelemetryData{data})
+}
+
+// StoreTelemetryBatch stores multiple telemetry data points in a batch.
+// Metrics with a storage hint are written as packed telemetry blocks.
+func (r *DatastoreRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	entities, blocks, err := splitBatch(batch, r.hints)
 	if err != nil {
 		return fmt.Errorf("failed to convert telemetry data to entities: %w", err)
 	}
 
-	keys := make([]*datastore.Key, len(entities))
+	allKeys := make([]*datastore.Key, len(entities))
 	for i, entity := range entities {
-		// Key format: telemetry/{device_id}#{timestamp_nanos}#{metric_name}
+		// Key format: {device_id}#{timestamp_nanos}#{metric_name}
 		keyName := fmt.Sprintf("%s#%d#%s", entity.DeviceID, entity.Timestamp.UnixNano(), entity.MetricName)
-		keys[i] = datastore.NameKey("Telemetry", keyName, nil)
+		allKeys[i] = datastore.NameKey("Telemetry", keyName, nil)
 	}
 
-	if _, err := r.client.PutMulti(ctx, keys, entities); err != nil {
-		return fmt.Errorf("failed to store telemetry data: %w", err)
+	// Datastore has a limit of 500 entities per batch
+	batchSize := 500
+	for i := 0; i < len(allKeys); i += batchSize {
+		end := i + batchSize
+		if end > len(allKeys) {
+			end = len(allKeys)
+		}
+
+		if _, err := r.client.PutMulti(ctx, allKeys[i:end], entities[i:end]); err != nil {
+			return fmt.Errorf("failed to store telemetry batch: %w", err)
+		}
+	}
+
+	blockKeys := make([]*datastore.Key, len(blocks))
+	for i, block := range blocks {
+		// Key format: {device_id}#{metric_name}#{start_nanos}#{end_nanos}
+		keyName := fmt.Sprintf("%s#%s#%d#%d", block.DeviceID, block.MetricName, block.StartTime.UnixNano(), block.EndTime.UnixNano())
+		blockKeys[i] = datastore.NameKey("TelemetryBlock", keyName, nil)
+	}
+
+	for i := 0; i < len(blockKeys); i += batchSize {
+		end := i + batchSize
+		if end > len(blockKeys) {
+			end = len(blockKeys)
+		}
+
+		if _, err := r.client.PutMulti(ctx, blockKeys[i:end], blocks[i:end]); err != nil {
+			return fmt.Errorf("failed to store telemetry blocks: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// StoreTelemetryBatch stores multiple telemetry data points in a batch
-func (r *DatastoreRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
-	var allEntities []*TelemetryEntity
-	var allKeys []*datastore.Key
+// getBlockMetrics decodes the points of telemetry blocks overlapping the
+// time range. An empty metricName matches every metric.
+func (r *DatastoreRepository) getBlockMetrics(ctx context.Context, deviceID, metricName string, timeRange TimeRange) ([]*MetricPoint, error) {
+	if len(r.hints) == 0 {
+		return nil, nil
+	}
 
-	for _, data := range batch {
-		entities, err := data.ToEntities()
-		if err != nil {
-			return fmt.Errorf("failed to convert telemetry data to entities: %w", err)
-		}
+	query := datastore.NewQuery("TelemetryBlock").
+		Filter("device_id =", deviceID)
+	if metricName != "" {
+		query = query.Filter("metric_name =", metricName)
+	}
+	query = query.Filter("start_time <=", timeRange.End).Order("start_time")
 
-		for _, entity := range entities {
-			keyName := fmt.Sprintf("%s#%d#%s", entity.DeviceID, entity.Timestamp.UnixNano(), entity.MetricName)
-			key := datastore.NameKey("Telemetry", keyName, nil)
+	var blocks []*TelemetryBlockEntity
+	if _, err := r.client.GetAll(ctx, query, &blocks); err != nil {
+		return nil, fmt.Errorf("failed to query telemetry blocks: %w", err)
+	}
 
-			allKeys = append(allKeys, key)
-			allEntities = append(allEntities, entity)
+	var metrics []*MetricPoint
+	for _, block := range blocks {
+		if block.EndTime.Before(timeRange.Start) {
+			continue
+		}
+		points, err := block.Points(timeRange)
+		if err != nil {
+			return nil, err
 		}
+		metrics = append(metrics, points...)
 	}
+	return metrics, nil
+}
 
-	if len(allKeys) == 0 {
-		return nil
+// getLatestBlockMetrics decodes the points of the limit telemetry blocks
+// that end last. Every block ends with a point, so they hold the latest
+// limit block points.
+func (r *DatastoreRepository) getLatestBlockMetrics(ctx context.Context, deviceID string, limit int) ([]*MetricPoint, error) {
+	if len(r.hints) == 0 {
+		return nil, nil
 	}
 
-	// Datastore has a limit of 500 entities per batch
-	batchSize := 500
-	for i := 0; i < len(allKeys); i += batchSize {
-		end := i + batchSize
-		if end > len(allKeys) {
-			end = len(allKeys)
-		}
+	query := datastore.NewQuery("TelemetryBlock").
+		Filter("device_id =", deviceID).
+		Order("-end_time").
+		Limit(limit)
 
-		if _, err := r.client.PutMulti(ctx, allKeys[i:end], allEntities[i:end]); err != nil {
-			return fmt.Errorf("failed to store telemetry batch: %w", err)
+	var blocks []*TelemetryBlockEntity
+	if _, err := r.client.GetAll(ctx, query, &blocks); err != nil {
+		return nil, fmt.Errorf("failed to query latest telemetry blocks: %w", err)
+	}
+
+	var metrics []*MetricPoint
+	for _, block := range blocks {
+		points, err := block.Points(TimeRange{Start: block.StartTime, End: block.EndTime})
+		if err != nil {
+			return nil, err
 		}
+		metrics = append(metrics, points...)
 	}
+	return metrics, nil
+}
 
-	return nil
+// latestMetrics merges per-point and block metrics, newest first, keeping
+// the limit latest
+func latestMetrics(metrics, blockMetrics []*MetricPoint, limit int) []*MetricPoint {
+	if len(blockMetrics) == 0 {
+		return metrics
+	}
+	metrics = append(metrics, blockMetrics...)
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Timestamp.After(metrics[j].Timestamp)
+	})
+	if len(metrics) > limit {
+		metrics = metrics[:limit]
+	}
+	return metrics
+}
+
+// mergeBlockMetrics adds block points to per-point metrics in time order
+func mergeBlockMetrics(metrics, blockMetrics []*MetricPoint) []*MetricPoint {
+	if len(blockMetrics) == 0 {
+		return metrics
+	}
+	metrics = append(metrics, blockMetrics...)
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Timestamp.Before(metrics[j].Timestamp)
+	})
+	return metrics
 }
 
 // GetDeviceMetrics retrieves all metrics for a device within a time range
@@ -104,7 +204,12 @@ func (r *DatastoreRepository) GetDeviceMetrics(ctx context.Context, deviceID str
 		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	blockMetrics, err := r.getBlockMetrics(ctx, deviceID, "", timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeBlockMetrics(metrics, blockMetrics), nil
 }
 
 // GetDeviceMetricsByName retrieves specific metric for a device within a time range
@@ -130,7 +235,12 @@ func (r *DatastoreRepository) GetDeviceMetricsByName(ctx context.Context, device
 		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	blockMetrics, err := r.getBlockMetrics(ctx, deviceID, metricName, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeBlockMetrics(metrics, blockMetrics), nil
 }
 
 // GetLatestMetrics retrieves the latest N metrics for a device
@@ -154,7 +264,12 @@ func (r *DatastoreRepository) GetLatestMetrics(ctx context.Context, deviceID str
 		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	blockMetrics, err := r.getLatestBlockMetrics(ctx, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return latestMetrics(metrics, blockMetrics, limit), nil
 }
 
 // AggregateMetrics performs aggregation on metrics
@@ -165,19 +280,25 @@ func (r *DatastoreRepository) AggregateMetrics(ctx context.Context, query *Aggre
 		return nil, err
 	}
 
+	return aggregatePoints(metrics, query), nil
+}
+
+// aggregatePoints aggregates metric points into one result, or one result
+// per interval bucket when the query has an interval
+func aggregatePoints(metrics []*MetricPoint, query *AggregationQuery) []*AggregationResult {
 	if len(metrics) == 0 {
-		return []*AggregationResult{}, nil
+		return []*AggregationResult{}
 	}
 
 	// If no interval specified, aggregate all data into one result
 	if query.Interval == 0 {
-		value := r.aggregateValues(metrics, query.Aggregation)
+		value := aggregateValues(metrics, query.Aggregation)
 		return []*AggregationResult{
 			{
 				Timestamp: query.TimeRange.Start,
 				Value:     value,
 			},
-		}, nil
+		}
 	}
 
 	// Group by interval and aggregate
@@ -189,18 +310,18 @@ func (r *DatastoreRepository) AggregateMetrics(ctx context.Context, query *Aggre
 
 	results := make([]*AggregationResult, 0, len(buckets))
 	for timestamp, points := range buckets {
-		value := r.aggregateValues(points, query.Aggregation)
+		value := aggregateValues(points, query.Aggregation)
 		results = append(results, &AggregationResult{
 			Timestamp: timestamp,
 			Value:     value,
 		})
 	}
 
-	return results, nil
+	return results
 }
 
 // aggregateValues performs the actual aggregation calculation
-func (r *DatastoreRepository) aggregateValues(metrics []*MetricPoint, aggType AggregationType) float64 {
+func aggregateValues(metrics []*MetricPoint, aggType AggregationType) float64 {
 	if len(metrics) == 0 {
 		return 0
 	}
@@ -364,6 +485,98 @@ func (r *DatastoreRepository) DeleteThreshold(ctx context.Context, thresholdID s
 	return nil
 }
 
+// ListScopedThresholds lists the thresholds defined for a template or device
+func (r *DatastoreRepository) ListScopedThresholds(ctx context.Context, scope ThresholdScope, scopeID string) ([]*ScopedThreshold, error) {
+	query := datastore.NewQuery("Threshold")
+	switch scope {
+	case ThresholdScopeTemplate:
+		query = query.Filter("template_id =", scopeID)
+	case ThresholdScopeDevice:
+		query = query.Filter("device_id =", scopeID)
+	default:
+		return nil, fmt.Errorf("unknown threshold scope %q", scope)
+	}
+
+	var entities []*ThresholdEntity
+	if _, err := r.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to list thresholds: %w", err)
+	}
+
+	thresholds := make([]*ScopedThreshold, 0, len(entities))
+	for _, entity := range entities {
+		threshold := &ScopedThreshold{
+			ThresholdID: entity.ThresholdID,
+			Scope:       scope,
+			ScopeID:     scopeID,
+			AlertThreshold: AlertThreshold{
+				MetricName: entity.MetricName,
+				Operator:   entity.Operator,
+				Value:      entity.Value,
+				Duration:   time.Duration(entity.DurationNanos),
+				Severity:   entity.Severity,
+				Enabled:    entity.Enabled,
+			},
+			UpdatedAt: entity.UpdatedAt,
+		}
+		if entity.MetadataJSON != "" && entity.MetadataJSON != "{}" {
+			if err := json.Unmarshal([]byte(entity.MetadataJSON), &threshold.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to decode threshold metadata: %w", err)
+			}
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	return thresholds, nil
+}
+
+// SaveScopedThreshold creates or replaces a template or device threshold
+func (r *DatastoreRepository) SaveScopedThreshold(ctx context.Context, threshold *ScopedThreshold) error {
+	now := time.Now()
+	entity := &ThresholdEntity{CreatedAt: now}
+
+	if threshold.ThresholdID == "" {
+		threshold.ThresholdID = uuid.New().String()
+	} else if err := r.client.Get(ctx, datastore.NameKey("Threshold", threshold.ThresholdID, nil), entity); err != nil && err != datastore.ErrNoSuchEntity {
+		return fmt.Errorf("failed to get threshold: %w", err)
+	}
+
+	metadataJSON := "{}"
+	if threshold.Metadata != nil {
+		encoded, err := json.Marshal(threshold.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode threshold metadata: %w", err)
+		}
+		metadataJSON = string(encoded)
+	}
+
+	entity.ThresholdID = threshold.ThresholdID
+	entity.DeviceID, entity.TemplateID = "", ""
+	switch threshold.Scope {
+	case ThresholdScopeTemplate:
+		entity.TemplateID = threshold.ScopeID
+	case ThresholdScopeDevice:
+		entity.DeviceID = threshold.ScopeID
+	default:
+		return fmt.Errorf("unknown threshold scope %q", threshold.Scope)
+	}
+	entity.MetricName = threshold.MetricName
+	entity.Operator = threshold.Operator
+	entity.Value = threshold.Value
+	entity.DurationNanos = int64(threshold.Duration)
+	entity.Severity = threshold.Severity
+	entity.Enabled = threshold.Enabled
+	entity.MetadataJSON = metadataJSON
+	entity.UpdatedAt = now
+
+	key := datastore.NameKey("Threshold", threshold.ThresholdID, nil)
+	if _, err := r.client.Put(ctx, key, entity); err != nil {
+		return fmt.Errorf("failed to save threshold: %w", err)
+	}
+
+	threshold.UpdatedAt = now
+	return nil
+}
+
 // CreateAlert creates a new alert
 func (r *DatastoreRepository) CreateAlert(ctx context.Context, alert *Alert) error {
 	entity, err := alert.ToEntity()
@@ -461,6 +674,115 @@ func (r *DatastoreRepository) ResolveAlert(ctx context.Context, alertID string)
 	return nil
 }
 
+// StoreDeviceLogs stores device log lines in Datastore
+func (r *DatastoreRepository) StoreDeviceLogs(ctx context.Context, entries []*DeviceLogEntry) error {
+	keys := make([]*datastore.Key, len(entries))
+	entities := make([]*DeviceLogEntity, len(entries))
+	for i, entry := range entries {
+		// Lines from one batch may share a timestamp, so the index keeps keys unique
+		keyName := fmt.Sprintf("%s#%d#%d", entry.DeviceID, entry.Timestamp.UnixNano(), i)
+		keys[i] = datastore.NameKey("DeviceLog", keyName, nil)
+		entities[i] = &DeviceLogEntity{
+			DeviceID:  entry.DeviceID,
+			Timestamp: entry.Timestamp,
+			Level:     entry.Level,
+			Tag:       entry.Tag,
+			Message:   entry.Message,
+		}
+	}
+
+	batchSize := 500
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		if _, err := r.client.PutMulti(ctx, keys[i:end], entities[i:end]); err != nil {
+			return fmt.Errorf("failed to store device logs: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// QueryDeviceLogs retrieves device log lines. The level filter is applied
+// after the query so that it needs no composite index per level.
+func (r *DatastoreRepository) QueryDeviceLogs(ctx context.Context, query *DeviceLogQuery) ([]*DeviceLogEntry, error) {
+	q := datastore.NewQuery("DeviceLog").
+		Filter("device_id =", query.DeviceID)
+	if !query.Start.IsZero() {
+		q = q.Filter("timestamp >=", query.Start)
+	}
+	if !query.End.IsZero() {
+		q = q.Filter("timestamp <=", query.End)
+	}
+	if query.Tag != "" {
+		q = q.Filter("tag =", query.Tag)
+	}
+	q = q.Order("-timestamp")
+	if query.Limit > 0 && query.MinLevel == "" {
+		q = q.Limit(query.Limit)
+	}
+
+	var entities []DeviceLogEntity
+	if _, err := r.client.GetAll(ctx, q, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query device logs: %w", err)
+	}
+
+	var entries []*DeviceLogEntry
+	for _, entity := range entities {
+		if !LogLevelAtLeast(entity.Level, query.MinLevel) {
+			continue
+		}
+		entries = append(entries, &DeviceLogEntry{
+			DeviceID:  entity.DeviceID,
+			Timestamp: entity.Timestamp,
+			Level:     entity.Level,
+			Tag:       entity.Tag,
+			Message:   entity.Message,
+		})
+		if query.Limit > 0 && len(entries) == query.Limit {
+			break
+		}
+	}
+
+	// Newest lines were fetched first; return them in reading order
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// DeleteOldDeviceLogs deletes device log lines older than the specified time
+func (r *DatastoreRepository) DeleteOldDeviceLogs(ctx context.Context, before time.Time) (int64, error) {
+	query := datastore.NewQuery("DeviceLog").
+		Filter("timestamp <", before).
+		KeysOnly()
+
+	keys, err := r.client.GetAll(ctx, query, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query old device logs: %w", err)
+	}
+
+	batchSize := 500
+	deleted := int64(0)
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		if err := r.client.DeleteMulti(ctx, keys[i:end]); err != nil {
+			return deleted, fmt.Errorf("failed to delete device log batch: %w", err)
+		}
+		deleted += int64(end - i)
+	}
+
+	return deleted, nil
+}
+
 // DeleteOldTelemetry deletes telemetry data older than the specified time
 func (r *DatastoreRepository) DeleteOldTelemetry(ctx context.Context, before time.Time) (int64, error) {
 	query := datastore.NewQuery("Telemetry").
@@ -472,10 +794,6 @@ func (r *DatastoreRepository) DeleteOldTelemetry(ctx context.Context, before tim
 		return 0, fmt.Errorf("failed to query old telemetry: %w", err)
 	}
 
-	if len(keys) == 0 {
-		return 0, nil
-	}
-
 	// Delete in batches of 500
 	batchSize := 500
 	deleted := int64(0)
@@ -491,5 +809,34 @@ func (r *DatastoreRepository) DeleteOldTelemetry(ctx context.Context, before tim
 		deleted += int64(end - i)
 	}
 
-	return deleted, nil
+	err = r.deleteOldBlocks(ctx, before, &deleted)
+	return deleted, err
+}
+
+// deleteOldBlocks deletes telemetry blocks whose newest point is before the
+// cutoff, adding them to deleted
+func (r *DatastoreRepository) deleteOldBlocks(ctx context.Context, before time.Time, deleted *int64) error {
+	query := datastore.NewQuery("TelemetryBlock").
+		Filter("end_time <", before).
+		KeysOnly()
+
+	keys, err := r.client.GetAll(ctx, query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to query old telemetry blocks: %w", err)
+	}
+
+	batchSize := 500
+	for i := 0; i < len(keys); i += batchSize {
+		end := i + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		if err := r.client.DeleteMulti(ctx, keys[i:end]); err != nil {
+			return fmt.Errorf("failed to delete telemetry blocks: %w", err)
+		}
+		*deleted += int64(end - i)
+	}
+
+	return nil
 }
@@ -0,0 +1,526 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DerivedMetricMode controls when a derived metric is computed
+type DerivedMetricMode string
+
+const (
+	// DerivedModeIngest computes the metric when telemetry arrives and stores
+	// it alongside the raw metrics
+	DerivedModeIngest DerivedMetricMode = "ingest"
+	// DerivedModeQuery computes the metric from stored raw metrics whenever
+	// it is read
+	DerivedModeQuery DerivedMetricMode = "query"
+)
+
+var (
+	ErrDerivedMetricNotFound = errors.New("derived metric not found")
+	ErrDerivedMetricExists   = errors.New("derived metric already exists")
+
+	// errDerivedMetricStorage wraps failures to persist definitions, which
+	// are not the caller's fault
+	errDerivedMetricStorage = errors.New("failed to persist derived metric")
+)
+
+var derivedMetricNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// DerivedMetric is a metric computed from other metrics of the same device
+type DerivedMetric struct {
+	Name        string            `json:"name"`
+	Expression  string            `json:"expression"`
+	Mode        DerivedMetricMode `json:"mode"`
+	Unit        string            `json:"unit,omitempty"`
+	Description string            `json:"description,omitempty"`
+	// DeviceIDs limits the metric to some devices; empty applies to all
+	DeviceIDs []string  `json:"device_ids,omitempty"`
+	Inputs    []string  `json:"inputs"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	expression *Expression
+}
+
+// DerivedMetricRequest creates or replaces a derived metric
+type DerivedMetricRequest struct {
+	Name        string            `json:"name"`
+	Expression  string            `json:"expression" binding:"required"`
+	Mode        DerivedMetricMode `json:"mode"`
+	Unit        string            `json:"unit,omitempty"`
+	Description string            `json:"description,omitempty"`
+	DeviceIDs   []string          `json:"device_ids,omitempty"`
+}
+
+func (m *DerivedMetric) appliesTo(deviceID string) bool {
+	if len(m.DeviceIDs) == 0 {
+		return true
+	}
+	for _, id := range m.DeviceIDs {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// DerivedMetricRegistry holds derived metric definitions. With a store,
+// changes are persisted before they take effect.
+type DerivedMetricRegistry struct {
+	mu      sync.RWMutex
+	metrics map[string]*DerivedMetric
+	store   DerivedMetricStore
+}
+
+// NewDerivedMetricRegistry creates an empty registry
+func NewDerivedMetricRegistry() *DerivedMetricRegistry {
+	return &DerivedMetricRegistry{
+		metrics: make(map[string]*DerivedMetric),
+	}
+}
+
+// Create adds a derived metric
+func (r *DerivedMetricRegistry) Create(ctx context.Context, req *DerivedMetricRequest) (*DerivedMetric, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[req.Name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDerivedMetricExists, req.Name)
+	}
+
+	now := time.Now()
+	metric, err := r.build(req, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.save(ctx, metric); err != nil {
+		return nil, err
+	}
+	r.metrics[metric.Name] = metric
+
+	copied := *metric
+	return &copied, nil
+}
+
+// Update replaces the definition of a derived metric
+func (r *DerivedMetricRegistry) Update(ctx context.Context, req *DerivedMetricRequest) (*DerivedMetric, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.metrics[req.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDerivedMetricNotFound, req.Name)
+	}
+
+	metric, err := r.build(req, existing.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if metric.Mode == DerivedModeQuery {
+		for _, other := range r.metrics {
+			if other.Mode == DerivedModeIngest && containsInput(other.Inputs, metric.Name) {
+				return nil, fmt.Errorf("ingest-time metric %s uses %s, which cannot become query-time", other.Name, metric.Name)
+			}
+		}
+	}
+	metric.UpdatedAt = time.Now()
+	if err := r.save(ctx, metric); err != nil {
+		return nil, err
+	}
+	r.metrics[metric.Name] = metric
+
+	copied := *metric
+	return &copied, nil
+}
+
+// Get returns a derived metric by name
+func (r *DerivedMetricRegistry) Get(name string) (*DerivedMetric, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metric, ok := r.metrics[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDerivedMetricNotFound, name)
+	}
+	copied := *metric
+	return &copied, nil
+}
+
+// List returns all derived metrics sorted by name
+func (r *DerivedMetricRegistry) List() []*DerivedMetric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metrics := make([]*DerivedMetric, 0, len(r.metrics))
+	for _, metric := range r.metrics {
+		copied := *metric
+		metrics = append(metrics, &copied)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+	return metrics
+}
+
+// Delete removes a derived metric that no other derived metric depends on
+func (r *DerivedMetricRegistry) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrDerivedMetricNotFound, name)
+	}
+	for _, other := range r.metrics {
+		if other.Name != name && containsInput(other.Inputs, name) {
+			return fmt.Errorf("derived metric %s is used by %s", name, other.Name)
+		}
+	}
+	if r.store != nil {
+		if err := r.store.DeleteDerivedMetric(ctx, name); err != nil {
+			return fmt.Errorf("%w: %v", errDerivedMetricStorage, err)
+		}
+	}
+	delete(r.metrics, name)
+	return nil
+}
+
+// save persists a definition. The caller holds the write lock.
+func (r *DerivedMetricRegistry) save(ctx context.Context, metric *DerivedMetric) error {
+	if r.store == nil {
+		return nil
+	}
+	if err := r.store.SaveDerivedMetric(ctx, metric); err != nil {
+		return fmt.Errorf("%w: %v", errDerivedMetricStorage, err)
+	}
+	return nil
+}
+
+// load replaces the definitions with those in the registry's store
+func (r *DerivedMetricRegistry) load(ctx context.Context) error {
+	r.mu.RLock()
+	store := r.store
+	r.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+
+	stored, err := store.ListDerivedMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	metrics := make(map[string]*DerivedMetric, len(stored))
+	for _, metric := range stored {
+		expression, err := CompileExpression(metric.Expression)
+		if err != nil {
+			return fmt.Errorf("stored derived metric %s: %w", metric.Name, err)
+		}
+		metric.expression = expression
+		metric.Inputs = expression.Inputs()
+		metrics[metric.Name] = metric
+	}
+
+	r.mu.Lock()
+	r.metrics = metrics
+	r.mu.Unlock()
+	return nil
+}
+
+// build validates a request against the current definitions. The caller
+// holds the write lock.
+func (r *DerivedMetricRegistry) build(req *DerivedMetricRequest, createdAt time.Time) (*DerivedMetric, error) {
+	if !derivedMetricNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("invalid derived metric name %q", req.Name)
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = DerivedModeIngest
+	}
+	if mode != DerivedModeIngest && mode != DerivedModeQuery {
+		return nil, fmt.Errorf("invalid mode %q: must be %s or %s", mode, DerivedModeIngest, DerivedModeQuery)
+	}
+
+	expression, err := CompileExpression(req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(expression.Inputs()) == 0 {
+		return nil, fmt.Errorf("expression %q does not reference any metric", req.Expression)
+	}
+
+	for _, input := range expression.Inputs() {
+		dependency, ok := r.metrics[input]
+		if !ok || input == req.Name {
+			continue
+		}
+		// Query-time metrics are never stored, so they are not available
+		// when ingest-time metrics are computed
+		if mode == DerivedModeIngest && dependency.Mode == DerivedModeQuery {
+			return nil, fmt.Errorf("ingest-time metric %s cannot use query-time metric %s", req.Name, input)
+		}
+	}
+
+	metric := &DerivedMetric{
+		Name:        req.Name,
+		Expression:  expression.String(),
+		Mode:        mode,
+		Unit:        req.Unit,
+		Description: req.Description,
+		DeviceIDs:   req.DeviceIDs,
+		Inputs:      expression.Inputs(),
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		expression:  expression,
+	}
+
+	if r.dependsOn(metric, req.Name, map[string]bool{}) {
+		return nil, fmt.Errorf("derived metric %s depends on itself", req.Name)
+	}
+
+	return metric, nil
+}
+
+// dependsOn reports whether metric reads name directly or through other
+// derived metrics. Stored definitions are acyclic, so visited only prunes.
+func (r *DerivedMetricRegistry) dependsOn(metric *DerivedMetric, name string, visited map[string]bool) bool {
+	for _, input := range metric.Inputs {
+		if input == name {
+			return true
+		}
+		dependency, ok := r.metrics[input]
+		if !ok || visited[input] {
+			continue
+		}
+		visited[input] = true
+		if r.dependsOn(dependency, name, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// ordered returns the metrics in mode that apply to deviceID, each after
+// the derived metrics it reads
+func (r *DerivedMetricRegistry) ordered(mode DerivedMetricMode, deviceID string) []*DerivedMetric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*DerivedMetric
+	visited := make(map[string]bool)
+	var visit func(metric *DerivedMetric)
+	visit = func(metric *DerivedMetric) {
+		if visited[metric.Name] {
+			return
+		}
+		visited[metric.Name] = true
+		for _, input := range metric.Inputs {
+			if dependency, ok := r.metrics[input]; ok {
+				visit(dependency)
+			}
+		}
+		if metric.Mode == mode && metric.appliesTo(deviceID) {
+			result = append(result, metric)
+		}
+	}
+
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		visit(r.metrics[name])
+	}
+	return result
+}
+
+// queryMetric returns the query-time metric name for deviceID, if any
+func (r *DerivedMetricRegistry) queryMetric(deviceID, name string) *DerivedMetric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	metric, ok := r.metrics[name]
+	if !ok || metric.Mode != DerivedModeQuery || !metric.appliesTo(deviceID) {
+		return nil
+	}
+	return metric
+}
+
+// applyAtIngest adds ingest-time metrics to data when all their inputs are
+// present and numeric
+func (r *DerivedMetricRegistry) applyAtIngest(data *TelemetryData) {
+	metrics := r.ordered(DerivedModeIngest, data.DeviceID)
+	if len(metrics) == 0 {
+		return
+	}
+
+	values := make(map[string]float64, len(data.Metrics))
+	for name, value := range data.Metrics {
+		if v, ok := numericValue(value); ok {
+			values[name] = v
+		}
+	}
+
+	for _, metric := range metrics {
+		value, err := metric.expression.Evaluate(values)
+		if err != nil {
+			continue
+		}
+		if data.Metrics == nil {
+			data.Metrics = make(map[string]interface{})
+		}
+		data.Metrics[metric.Name] = value
+		values[metric.Name] = value
+	}
+}
+
+func containsInput(inputs []string, name string) bool {
+	for _, input := range inputs {
+		if input == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DerivedRepository wraps a Repository so derived metrics behave like raw
+// ones: ingest-time metrics are added before telemetry is stored and
+// query-time metrics are computed on read, which makes both available to
+// queries, aggregations, exports and alert thresholds. Query-time values are
+// computed for timestamps at which every input was reported.
+type DerivedRepository struct {
+	Repository
+	registry *DerivedMetricRegistry
+}
+
+// NewDerivedRepository wraps repository with the registry's definitions
+func NewDerivedRepository(repository Repository, registry *DerivedMetricRegistry) *DerivedRepository {
+	return &DerivedRepository{
+		Repository: repository,
+		registry:   registry,
+	}
+}
+
+// StoreTelemetry stores data with its ingest-time derived metrics
+func (r *DerivedRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	r.registry.applyAtIngest(data)
+	return r.Repository.StoreTelemetry(ctx, data)
+}
+
+// StoreTelemetryBatch stores a batch with its ingest-time derived metrics
+func (r *DerivedRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	for _, data := range batch {
+		r.registry.applyAtIngest(data)
+	}
+	return r.Repository.StoreTelemetryBatch(ctx, batch)
+}
+
+// GetDeviceMetrics returns stored metrics followed by query-time derived metrics
+func (r *DerivedRepository) GetDeviceMetrics(ctx context.Context, deviceID string, timeRange TimeRange) ([]*MetricPoint, error) {
+	metrics, err := r.Repository.GetDeviceMetrics(ctx, deviceID, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	derived := r.registry.ordered(DerivedModeQuery, deviceID)
+	if len(derived) == 0 {
+		return metrics, nil
+	}
+
+	samples := groupByTimestamp(metrics)
+	for _, metric := range derived {
+		metrics = append(metrics, evaluateSamples(metric, samples)...)
+	}
+	return metrics, nil
+}
+
+// GetDeviceMetricsByName computes query-time derived metrics from their inputs
+func (r *DerivedRepository) GetDeviceMetricsByName(ctx context.Context, deviceID string, metricName string, timeRange TimeRange) ([]*MetricPoint, error) {
+	metric := r.registry.queryMetric(deviceID, metricName)
+	if metric == nil {
+		return r.Repository.GetDeviceMetricsByName(ctx, deviceID, metricName, timeRange)
+	}
+
+	var inputs []*MetricPoint
+	for _, input := range metric.Inputs {
+		points, err := r.GetDeviceMetricsByName(ctx, deviceID, input, timeRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get input %s for %s: %w", input, metricName, err)
+		}
+		inputs = append(inputs, points...)
+	}
+
+	return evaluateSamples(metric, groupByTimestamp(inputs)), nil
+}
+
+// AggregateMetrics aggregates query-time derived metrics in memory
+func (r *DerivedRepository) AggregateMetrics(ctx context.Context, query *AggregationQuery) ([]*AggregationResult, error) {
+	if r.registry.queryMetric(query.DeviceID, query.MetricName) == nil {
+		return r.Repository.AggregateMetrics(ctx, query)
+	}
+
+	metrics, err := r.GetDeviceMetricsByName(ctx, query.DeviceID, query.MetricName, query.TimeRange)
+	if err != nil {
+		return nil, err
+	}
+	return aggregatePoints(metrics, query), nil
+}
+
+// timestampSamples holds the numeric values reported at one timestamp
+type timestampSamples struct {
+	timestamp time.Time
+	values    map[string]float64
+}
+
+func groupByTimestamp(metrics []*MetricPoint) []*timestampSamples {
+	byTime := make(map[int64]*timestampSamples)
+	for _, point := range metrics {
+		value, ok := numericValue(point.MetricValue)
+		if !ok {
+			continue
+		}
+		key := point.Timestamp.UnixNano()
+		group, ok := byTime[key]
+		if !ok {
+			group = &timestampSamples{timestamp: point.Timestamp, values: make(map[string]float64)}
+			byTime[key] = group
+		}
+		group.values[point.MetricName] = value
+	}
+
+	groups := make([]*timestampSamples, 0, len(byTime))
+	for _, group := range byTime {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].timestamp.Before(groups[j].timestamp)
+	})
+	return groups
+}
+
+// evaluateSamples computes metric at each timestamp where its inputs are
+// present and records the result so later metrics can read it
+func evaluateSamples(metric *DerivedMetric, samples []*timestampSamples) []*MetricPoint {
+	var points []*MetricPoint
+	for _, sample := range samples {
+		value, err := metric.expression.Evaluate(sample.values)
+		if err != nil {
+			continue
+		}
+		sample.values[metric.Name] = value
+		points = append(points, &MetricPoint{
+			Timestamp:   sample.timestamp,
+			MetricName:  metric.Name,
+			MetricValue: value,
+			Tags:        map[string]string{"derived": "true"},
+		})
+	}
+	return points
+}
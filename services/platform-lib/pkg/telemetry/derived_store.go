@@ -0,0 +1,136 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// derivedMetricRefreshInterval is how often definitions changed through
+// other replicas are reloaded
+const derivedMetricRefreshInterval = time.Minute
+
+// DerivedMetricStore persists derived metric definitions, so they survive
+// restarts and are shared between replicas
+type DerivedMetricStore interface {
+	SaveDerivedMetric(ctx context.Context, metric *DerivedMetric) error
+	ListDerivedMetrics(ctx context.Context) ([]*DerivedMetric, error)
+	DeleteDerivedMetric(ctx context.Context, name string) error
+}
+
+// DerivedMetricEntity represents a derived metric definition in Datastore.
+// Inputs are recomputed from the expression when it is loaded.
+type DerivedMetricEntity struct {
+	Name        string    `datastore:"name"`
+	Expression  string    `datastore:"expression,noindex"`
+	Mode        string    `datastore:"mode,noindex"`
+	Unit        string    `datastore:"unit,noindex"`
+	Description string    `datastore:"description,noindex"`
+	DeviceIDs   []string  `datastore:"device_ids,noindex"`
+	CreatedAt   time.Time `datastore:"created_at,noindex"`
+	UpdatedAt   time.Time `datastore:"updated_at,noindex"`
+}
+
+// ToEntity converts a DerivedMetric to a DerivedMetricEntity
+func (m *DerivedMetric) ToEntity() *DerivedMetricEntity {
+	return &DerivedMetricEntity{
+		Name:        m.Name,
+		Expression:  m.Expression,
+		Mode:        string(m.Mode),
+		Unit:        m.Unit,
+		Description: m.Description,
+		DeviceIDs:   m.DeviceIDs,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+}
+
+// FromEntity converts a DerivedMetricEntity to a DerivedMetric
+func (me *DerivedMetricEntity) FromEntity() *DerivedMetric {
+	return &DerivedMetric{
+		Name:        me.Name,
+		Expression:  me.Expression,
+		Mode:        DerivedMetricMode(me.Mode),
+		Unit:        me.Unit,
+		Description: me.Description,
+		DeviceIDs:   me.DeviceIDs,
+		CreatedAt:   me.CreatedAt,
+		UpdatedAt:   me.UpdatedAt,
+	}
+}
+
+// DatastoreDerivedMetricStore keeps derived metric definitions in
+// Datastore, keyed by name
+type DatastoreDerivedMetricStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreDerivedMetricStore creates a derived metric store on a
+// Datastore client
+func NewDatastoreDerivedMetricStore(client *datastore.Client) *DatastoreDerivedMetricStore {
+	return &DatastoreDerivedMetricStore{client: client}
+}
+
+func (s *DatastoreDerivedMetricStore) SaveDerivedMetric(ctx context.Context, metric *DerivedMetric) error {
+	if _, err := s.client.Put(ctx, datastore.NameKey("DerivedMetric", metric.Name, nil), metric.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save derived metric in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreDerivedMetricStore) ListDerivedMetrics(ctx context.Context) ([]*DerivedMetric, error) {
+	var entities []*DerivedMetricEntity
+	if _, err := s.client.GetAll(ctx, datastore.NewQuery("DerivedMetric"), &entities); err != nil {
+		return nil, fmt.Errorf("failed to list derived metrics from Datastore: %w", err)
+	}
+	metrics := make([]*DerivedMetric, len(entities))
+	for i, entity := range entities {
+		metrics[i] = entity.FromEntity()
+	}
+	return metrics, nil
+}
+
+func (s *DatastoreDerivedMetricStore) DeleteDerivedMetric(ctx context.Context, name string) error {
+	if err := s.client.Delete(ctx, datastore.NameKey("DerivedMetric", name, nil)); err != nil {
+		return fmt.Errorf("failed to delete derived metric from Datastore: %w", err)
+	}
+	return nil
+}
+
+// SetDerivedMetricStore persists derived metric definitions in store and
+// loads those already stored. Definitions changed through other replicas
+// are reloaded every derivedMetricRefreshInterval until the service stops.
+func (s *Service) SetDerivedMetricStore(ctx context.Context, store DerivedMetricStore) error {
+	s.derived.mu.Lock()
+	s.derived.store = store
+	s.derived.mu.Unlock()
+	if err := s.derived.load(ctx); err != nil {
+		return fmt.Errorf("failed to load derived metrics: %w", err)
+	}
+
+	go s.refreshDerivedMetrics()
+	return nil
+}
+
+// refreshDerivedMetrics reloads the stored definitions until the service
+// stops
+func (s *Service) refreshDerivedMetrics() {
+	ticker := time.NewTicker(derivedMetricRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		if err := s.derived.load(ctx); err != nil {
+			s.logger.Error("Failed to reload derived metrics", "error", err)
+		}
+		cancel()
+	}
+}
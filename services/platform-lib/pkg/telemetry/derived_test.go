@@ -0,0 +1,272 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepository stores telemetry so derived metrics can be read back by name
+type memoryRepository struct {
+	MockRepository
+	stored []*MetricPoint
+}
+
+func (m *memoryRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	for name, value := range data.Metrics {
+		m.stored = append(m.stored, &MetricPoint{Timestamp: data.Timestamp, MetricName: name, MetricValue: value})
+	}
+	return nil
+}
+
+func (m *memoryRepository) GetDeviceMetrics(ctx context.Context, deviceID string, timeRange TimeRange) ([]*MetricPoint, error) {
+	return m.stored, nil
+}
+
+func (m *memoryRepository) GetDeviceMetricsByName(ctx context.Context, deviceID string, metricName string, timeRange TimeRange) ([]*MetricPoint, error) {
+	var points []*MetricPoint
+	for _, point := range m.stored {
+		if point.MetricName == metricName {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+func TestDerivedMetricRegistry_Validation(t *testing.T) {
+	registry := NewDerivedMetricRegistry()
+
+	_, err := registry.Create(context.Background(), &DerivedMetricRequest{Name: "power", Expression: "volts * amps"})
+	require.NoError(t, err)
+
+	metric, err := registry.Get("power")
+	require.NoError(t, err)
+	assert.Equal(t, DerivedModeIngest, metric.Mode, "ingest is the default mode")
+	assert.Equal(t, []string{"amps", "volts"}, metric.Inputs)
+
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "power", Expression: "volts"})
+	assert.ErrorIs(t, err, ErrDerivedMetricExists)
+
+	for _, req := range []*DerivedMetricRequest{
+		{Name: "bad name", Expression: "a"},
+		{Name: "constant", Expression: "1 + 2"},
+		{Name: "loop", Expression: "loop + 1"},
+		{Name: "mode", Expression: "a", Mode: "sometimes"},
+	} {
+		_, err := registry.Create(context.Background(), req)
+		assert.Error(t, err, req.Name)
+	}
+
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "energy", Expression: "power * hours", Mode: DerivedModeQuery})
+	require.NoError(t, err)
+
+	_, err = registry.Update(context.Background(), &DerivedMetricRequest{Name: "power", Expression: "energy / hours"})
+	assert.Error(t, err, "cycles through other derived metrics are rejected")
+
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "cost", Expression: "energy * 0.3"})
+	assert.Error(t, err, "ingest-time metrics cannot read query-time ones")
+
+	assert.Error(t, registry.Delete(context.Background(), "power"), "power is used by energy")
+	require.NoError(t, registry.Delete(context.Background(), "energy"))
+	require.NoError(t, registry.Delete(context.Background(), "power"))
+	assert.ErrorIs(t, registry.Delete(context.Background(), "power"), ErrDerivedMetricNotFound)
+}
+
+func TestDerivedRepository_IngestAndQuery(t *testing.T) {
+	registry := NewDerivedMetricRegistry()
+	_, err := registry.Create(context.Background(), &DerivedMetricRequest{Name: "power", Expression: "volts * amps"})
+	require.NoError(t, err)
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "dew", Expression: "dew_point(temperature, humidity)", Mode: DerivedModeQuery})
+	require.NoError(t, err)
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "spread", Expression: "temperature - dew", Mode: DerivedModeQuery})
+	require.NoError(t, err)
+	_, err = registry.Create(context.Background(), &DerivedMetricRequest{Name: "other", Expression: "volts * 2", DeviceIDs: []string{"device-002"}})
+	require.NoError(t, err)
+
+	inner := &memoryRepository{}
+	repo := NewDerivedRepository(inner, registry)
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.StoreTelemetry(ctx, &TelemetryData{
+			DeviceID:  "device-001",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Metrics:   map[string]interface{}{"volts": 12.0, "amps": float64(i + 1), "temperature": 20, "humidity": 50.0},
+		}))
+	}
+	// A reading without amps gets no power value
+	require.NoError(t, repo.StoreTelemetry(ctx, &TelemetryData{
+		DeviceID:  "device-001",
+		Timestamp: start.Add(time.Hour),
+		Metrics:   map[string]interface{}{"volts": 12.0},
+	}))
+
+	power, err := inner.GetDeviceMetricsByName(ctx, "device-001", "power", TimeRange{})
+	require.NoError(t, err)
+	require.Len(t, power, 3, "ingest-time metrics are stored like raw metrics")
+	assert.Equal(t, 36.0, power[2].MetricValue)
+
+	other, err := inner.GetDeviceMetricsByName(ctx, "device-001", "other", TimeRange{})
+	require.NoError(t, err)
+	assert.Empty(t, other, "metrics scoped to other devices are not computed")
+
+	dew, err := repo.GetDeviceMetricsByName(ctx, "device-001", "dew", TimeRange{})
+	require.NoError(t, err)
+	require.Len(t, dew, 3)
+	assert.InDelta(t, 9.26, dew[0].MetricValue.(float64), 0.01)
+
+	spread, err := repo.GetDeviceMetricsByName(ctx, "device-001", "spread", TimeRange{})
+	require.NoError(t, err)
+	require.Len(t, spread, 3, "query-time metrics can build on each other")
+	assert.InDelta(t, 10.74, spread[0].MetricValue.(float64), 0.01)
+
+	all, err := repo.GetDeviceMetrics(ctx, "device-001", TimeRange{})
+	require.NoError(t, err)
+	names := map[string]int{}
+	for _, point := range all {
+		names[point.MetricName]++
+	}
+	assert.Equal(t, 3, names["dew"])
+	assert.Equal(t, 3, names["spread"])
+
+	results, err := repo.AggregateMetrics(ctx, &AggregationQuery{DeviceID: "device-001", MetricName: "dew", Aggregation: AggregationCount})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 3.0, results[0].Value)
+}
+
+func TestAlertMonitor_DerivedThreshold(t *testing.T) {
+	registry := NewDerivedMetricRegistry()
+	_, err := registry.Create(context.Background(), &DerivedMetricRequest{Name: "power", Expression: "volts * amps", Mode: DerivedModeQuery})
+	require.NoError(t, err)
+
+	now := time.Now()
+	inner := &memoryRepository{stored: []*MetricPoint{
+		{Timestamp: now, MetricName: "volts", MetricValue: 230.0},
+		{Timestamp: now, MetricName: "amps", MetricValue: 10.0},
+	}}
+	monitor := NewAlertMonitor(NewDerivedRepository(inner, registry), nil, logger.New("info", "telemetry-service"))
+
+	metrics, err := monitor.repository.GetDeviceMetricsByName(context.Background(), "device-001", "power", TimeRange{})
+	require.NoError(t, err)
+	violated, value := monitor.evaluateThreshold(metrics, &AlertThreshold{MetricName: "power", Operator: "gt", Value: 2000})
+	assert.True(t, violated)
+	assert.Equal(t, 2300.0, value)
+}
+
+func TestService_DerivedMetricRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &memoryRepository{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/telemetry/derived-metrics", `{"name": "power", "expression": "volts * amps", "unit": "W"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/telemetry/derived-metrics", `{"name": "power", "expression": "volts"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/telemetry/derived-metrics", `{"name": "x", "expression": "volts +"}`).Code)
+
+	w = do(http.MethodPost, "/api/v1/telemetry/ingest/device-001", `{"metrics": {"volts": 12, "amps": 2}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.MethodGet, "/api/v1/telemetry/metrics/device-001/power", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"metric_value":24`)
+
+	w = do(http.MethodPut, "/api/v1/telemetry/derived-metrics/power", `{"expression": "volts * amps / 1000", "unit": "kW"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"unit":"kW"`)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/v1/telemetry/derived-metrics/missing", `{"expression": "a"}`).Code)
+
+	w = do(http.MethodGet, "/api/v1/telemetry/derived-metrics", "")
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/telemetry/derived-metrics/power", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/telemetry/derived-metrics/power", "").Code)
+}
+
+// mapDerivedMetricStore keeps derived metric definitions in a map
+type mapDerivedMetricStore struct {
+	metrics map[string]DerivedMetricEntity
+	err     error
+}
+
+func (s *mapDerivedMetricStore) SaveDerivedMetric(ctx context.Context, metric *DerivedMetric) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.metrics[metric.Name] = *metric.ToEntity()
+	return nil
+}
+
+func (s *mapDerivedMetricStore) ListDerivedMetrics(ctx context.Context) ([]*DerivedMetric, error) {
+	var metrics []*DerivedMetric
+	for _, entity := range s.metrics {
+		metrics = append(metrics, entity.FromEntity())
+	}
+	return metrics, nil
+}
+
+func (s *mapDerivedMetricStore) DeleteDerivedMetric(ctx context.Context, name string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.metrics, name)
+	return nil
+}
+
+func TestService_DerivedMetricStore(t *testing.T) {
+	ctx := context.Background()
+	store := &mapDerivedMetricStore{metrics: make(map[string]DerivedMetricEntity)}
+
+	first, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &memoryRepository{})
+	require.NoError(t, err)
+	require.NoError(t, first.SetDerivedMetricStore(ctx, store))
+	_, err = first.derived.Create(ctx, &DerivedMetricRequest{Name: "power", Expression: "volts * amps", Unit: "W"})
+	require.NoError(t, err)
+	_, err = first.derived.Create(ctx, &DerivedMetricRequest{Name: "kw", Expression: "power / 1000", Mode: DerivedModeQuery})
+	require.NoError(t, err)
+
+	// Definitions survive into another replica, ready to compute
+	repository := &memoryRepository{}
+	second, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	require.NoError(t, second.SetDerivedMetricStore(ctx, store))
+	assert.Len(t, second.derived.List(), 2)
+	kw, err := second.derived.Get("kw")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"power"}, kw.Inputs)
+
+	data := &TelemetryData{DeviceID: "device-001", Metrics: map[string]interface{}{"volts": 12.0, "amps": 2.0}}
+	second.derived.applyAtIngest(data)
+	assert.Equal(t, 24.0, data.Metrics["power"])
+
+	// A definition that cannot be stored does not take effect
+	store.err = assert.AnError
+	_, err = first.derived.Create(ctx, &DerivedMetricRequest{Name: "amps_ma", Expression: "amps * 1000"})
+	assert.ErrorIs(t, err, errDerivedMetricStorage)
+	_, err = first.derived.Get("amps_ma")
+	assert.ErrorIs(t, err, ErrDerivedMetricNotFound)
+	assert.ErrorIs(t, first.derived.Delete(ctx, "kw"), errDerivedMetricStorage)
+	_, err = first.derived.Get("kw")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,397 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Device log levels, from least to most severe
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+const (
+	// maxLogBatch is the most log lines accepted in one request or message
+	maxLogBatch = 500
+
+	// maxLogMessageBytes caps a stored log line; longer lines are truncated
+	maxLogMessageBytes = 2048
+
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+
+	// logRetentionInterval is how often expired log lines are deleted
+	logRetentionInterval = time.Hour
+
+	// logFollowerBuffer is how many lines a slow follower may fall behind
+	// before lines are dropped for it
+	logFollowerBuffer = 256
+)
+
+// ErrInvalidDeviceLog is returned for log batches that cannot be stored
+var ErrInvalidDeviceLog = errors.New("invalid device log")
+
+var logLevelRanks = map[string]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+}
+
+// logLevelAliases maps the level names and letters printed by ESP-IDF and
+// Arduino logging macros to a log level
+var logLevelAliases = map[string]string{
+	"v":        LogLevelDebug,
+	"verbose":  LogLevelDebug,
+	"trace":    LogLevelDebug,
+	"d":        LogLevelDebug,
+	"i":        LogLevelInfo,
+	"w":        LogLevelWarn,
+	"warning":  LogLevelWarn,
+	"e":        LogLevelError,
+	"err":      LogLevelError,
+	"fatal":    LogLevelError,
+	"critical": LogLevelError,
+}
+
+// ParseLogLevel normalizes a log level such as "W" or "warning"
+func ParseLogLevel(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if _, ok := logLevelRanks[level]; ok {
+		return level, nil
+	}
+	if alias, ok := logLevelAliases[level]; ok {
+		return alias, nil
+	}
+	return "", fmt.Errorf("%w: unknown log level %q", ErrInvalidDeviceLog, level)
+}
+
+// LogLevelAtLeast reports whether level is at least as severe as min. An
+// empty min matches every level.
+func LogLevelAtLeast(level, min string) bool {
+	if min == "" {
+		return true
+	}
+	return logLevelRanks[level] >= logLevelRanks[min]
+}
+
+// ParseDeviceLogs decodes a log payload: either a batch {"logs": [...]} or
+// a single line {"level": ..., "tag": ..., "message": ...}. It returns the
+// device ID named in the payload, if any.
+func ParseDeviceLogs(payload []byte) (string, []*DeviceLogEntry, error) {
+	var batch struct {
+		DeviceLogEntry
+		Logs []*DeviceLogEntry `json:"logs"`
+	}
+	if err := json.Unmarshal(payload, &batch); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDeviceLog, err)
+	}
+
+	if batch.Logs == nil {
+		if batch.Message == "" {
+			return "", nil, fmt.Errorf("%w: payload has no logs", ErrInvalidDeviceLog)
+		}
+		entry := batch.DeviceLogEntry
+		return batch.DeviceID, []*DeviceLogEntry{&entry}, nil
+	}
+	return batch.DeviceID, batch.Logs, nil
+}
+
+// IngestDeviceLogs validates and stores log lines from a device, passes
+// them to anyone following the device's logs and evaluates log alert rules
+func (s *Service) IngestDeviceLogs(ctx context.Context, deviceID string, entries []*DeviceLogEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("%w: no log lines", ErrInvalidDeviceLog)
+	}
+	if len(entries) > maxLogBatch {
+		return fmt.Errorf("%w: %d lines exceed the batch limit of %d", ErrInvalidDeviceLog, len(entries), maxLogBatch)
+	}
+
+	now := time.Now()
+	for i, entry := range entries {
+		if entry == nil {
+			return fmt.Errorf("%w: line %d is null", ErrInvalidDeviceLog, i)
+		}
+		if entry.DeviceID != "" && entry.DeviceID != deviceID {
+			return fmt.Errorf("%w: line %d names device %s", ErrInvalidDeviceLog, i, entry.DeviceID)
+		}
+		if entry.Message == "" {
+			return fmt.Errorf("%w: line %d has no message", ErrInvalidDeviceLog, i)
+		}
+
+		level := LogLevelInfo
+		if entry.Level != "" {
+			parsed, err := ParseLogLevel(entry.Level)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i, err)
+			}
+			level = parsed
+		}
+
+		entry.DeviceID = deviceID
+		entry.Level = level
+		if len(entry.Message) > maxLogMessageBytes {
+			entry.Message = entry.Message[:maxLogMessageBytes]
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = now
+		}
+	}
+
+	if err := s.repository.StoreDeviceLogs(ctx, entries); err != nil {
+		return fmt.Errorf("failed to store device logs: %w", err)
+	}
+
+	s.logFollowers.publish(deviceID, entries)
+	s.evaluateLogAlerts(ctx, deviceID, entries)
+	return nil
+}
+
+// QueryDeviceLogs returns stored log lines for a device, oldest first
+func (s *Service) QueryDeviceLogs(ctx context.Context, query *DeviceLogQuery) ([]*DeviceLogEntry, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultLogLimit
+	}
+	if query.Limit > maxLogLimit {
+		query.Limit = maxLogLimit
+	}
+	return s.repository.QueryDeviceLogs(ctx, query)
+}
+
+// runLogRetention deletes log lines older than retention until the service stops
+func (s *Service) runLogRetention(retention time.Duration) {
+	ticker := time.NewTicker(logRetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(s.ctx, time.Minute)
+		deleted, err := s.repository.DeleteOldDeviceLogs(ctx, time.Now().Add(-retention))
+		cancel()
+		if err != nil {
+			s.logger.Error("Failed to delete expired device logs", "error", err)
+		} else if deleted > 0 {
+			s.logger.Info("Deleted expired device log lines", "deleted", deleted)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logFollowers fans newly ingested log lines out to live followers
+type logFollowers struct {
+	mu        sync.RWMutex
+	followers map[string]map[chan *DeviceLogEntry]struct{}
+}
+
+func newLogFollowers() *logFollowers {
+	return &logFollowers{followers: make(map[string]map[chan *DeviceLogEntry]struct{})}
+}
+
+// follow returns a channel receiving the device's new log lines and a
+// function that stops following
+func (f *logFollowers) follow(deviceID string) (<-chan *DeviceLogEntry, func()) {
+	ch := make(chan *DeviceLogEntry, logFollowerBuffer)
+
+	f.mu.Lock()
+	if f.followers[deviceID] == nil {
+		f.followers[deviceID] = make(map[chan *DeviceLogEntry]struct{})
+	}
+	f.followers[deviceID][ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.followers[deviceID], ch)
+		if len(f.followers[deviceID]) == 0 {
+			delete(f.followers, deviceID)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// publish sends lines to the device's followers without blocking ingest
+func (f *logFollowers) publish(deviceID string, entries []*DeviceLogEntry) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for ch := range f.followers[deviceID] {
+		for _, entry := range entries {
+			select {
+			case ch <- entry:
+			default:
+			}
+		}
+	}
+}
+
+func (s *Service) ingestDeviceLogsHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxLogBatch*(maxLogMessageBytes+256)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device log batch", "details": err.Error()})
+		return
+	}
+
+	payloadDeviceID, entries, err := ParseDeviceLogs(body)
+	if err == nil && payloadDeviceID != "" && payloadDeviceID != deviceID {
+		err = fmt.Errorf("%w: device_id does not match path", ErrInvalidDeviceLog)
+	}
+	if err == nil {
+		err = s.IngestDeviceLogs(c.Request.Context(), deviceID, entries)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidDeviceLog) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device log batch", "details": err.Error()})
+			return
+		}
+		s.logger.Error("Failed to ingest device logs", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store device logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device logs ingested successfully", "lines": len(entries)})
+}
+
+// getDeviceLogsHandler returns recent log lines as JSON, or follows the
+// device's logs over a WebSocket when the request is an upgrade
+func (s *Service) getDeviceLogsHandler(c *gin.Context) {
+	query := &DeviceLogQuery{DeviceID: c.Param("deviceId"), Tag: c.Query("tag")}
+
+	if since := c.Query("since"); since != "" {
+		start, err := parseLogTime(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since: use RFC 3339 or a duration such as 15m"})
+			return
+		}
+		query.Start = start
+	}
+	if until := c.Query("until"); until != "" {
+		end, err := parseLogTime(until)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until: use RFC 3339 or a duration such as 15m"})
+			return
+		}
+		query.End = end
+	}
+	if level := c.Query("level"); level != "" {
+		minLevel, err := ParseLogLevel(level)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query.MinLevel = minLevel
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		query.Limit = parsed
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		s.followDeviceLogs(c, query)
+		return
+	}
+
+	entries, err := s.QueryDeviceLogs(c.Request.Context(), query)
+	if err != nil {
+		s.logger.Error("Failed to query device logs", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve device logs"})
+		return
+	}
+	if entries == nil {
+		entries = []*DeviceLogEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id": query.DeviceID,
+		"logs":      entries,
+		"count":     len(entries),
+	})
+}
+
+// followDeviceLogs sends recent lines and then every new matching line over
+// a WebSocket until the client disconnects
+func (s *Service) followDeviceLogs(c *gin.Context, query *DeviceLogQuery) {
+	if s.streamManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Streaming not available"})
+		return
+	}
+
+	conn, err := s.streamManager.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.Error("Failed to upgrade device log connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Follow before reading the backlog so no line falls in between
+	lines, stop := s.logFollowers.follow(query.DeviceID)
+	defer stop()
+
+	backlog, err := s.QueryDeviceLogs(c.Request.Context(), query)
+	if err != nil {
+		s.logger.Error("Failed to query device logs", "error", err)
+	}
+	for _, entry := range backlog {
+		if err := conn.WriteJSON(entry); err != nil {
+			return
+		}
+	}
+
+	// The client sends nothing; reading detects when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.ctx.Done():
+			return
+		case entry := <-lines:
+			if !LogLevelAtLeast(entry.Level, query.MinLevel) || (query.Tag != "" && entry.Tag != query.Tag) {
+				continue
+			}
+			if err := conn.WriteJSON(entry); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseLogTime accepts an RFC 3339 time or a duration back from now
+func parseLogTime(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
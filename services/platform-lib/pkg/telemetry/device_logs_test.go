@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logRepository keeps stored device logs in memory
+type logRepository struct {
+	MockRepository
+	logs  []*DeviceLogEntry
+	query *DeviceLogQuery
+}
+
+func (r *logRepository) StoreDeviceLogs(ctx context.Context, entries []*DeviceLogEntry) error {
+	r.logs = append(r.logs, entries...)
+	return nil
+}
+
+func (r *logRepository) QueryDeviceLogs(ctx context.Context, query *DeviceLogQuery) ([]*DeviceLogEntry, error) {
+	r.query = query
+	var result []*DeviceLogEntry
+	for _, entry := range r.logs {
+		if entry.DeviceID == query.DeviceID && LogLevelAtLeast(entry.Level, query.MinLevel) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func newLogService(t *testing.T) (*Service, *logRepository) {
+	repository := &logRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	return service, repository
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for input, expected := range map[string]string{
+		"E":       LogLevelError,
+		"warning": LogLevelWarn,
+		" Info ":  LogLevelInfo,
+		"v":       LogLevelDebug,
+		"fatal":   LogLevelError,
+	} {
+		level, err := ParseLogLevel(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, level, input)
+	}
+
+	_, err := ParseLogLevel("loud")
+	assert.Error(t, err)
+
+	assert.True(t, LogLevelAtLeast(LogLevelError, LogLevelWarn))
+	assert.False(t, LogLevelAtLeast(LogLevelDebug, LogLevelInfo))
+	assert.True(t, LogLevelAtLeast(LogLevelDebug, ""))
+}
+
+func TestParseDeviceLogs(t *testing.T) {
+	deviceID, entries, err := ParseDeviceLogs([]byte(`{"device_id":"dev-1","logs":[{"level":"I","tag":"wifi","message":"connected"},{"level":"E","message":"sensor timeout"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "dev-1", deviceID)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "wifi", entries[0].Tag)
+
+	_, entries, err = ParseDeviceLogs([]byte(`{"level":"warn","message":"low battery"}`))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "a single line may be published without a batch")
+	assert.Equal(t, "low battery", entries[0].Message)
+
+	_, _, err = ParseDeviceLogs([]byte(`not json`))
+	assert.True(t, errors.Is(err, ErrInvalidDeviceLog))
+}
+
+func TestService_IngestDeviceLogs(t *testing.T) {
+	service, repository := newLogService(t)
+
+	updates, stop := service.logFollowers.follow("dev-1")
+	defer stop()
+
+	require.NoError(t, service.IngestDeviceLogs(context.Background(), "dev-1", []*DeviceLogEntry{
+		{Level: "W", Tag: "power", Message: "brownout imminent"},
+		{Message: strings.Repeat("x", maxLogMessageBytes+10)},
+	}))
+	require.Len(t, repository.logs, 2)
+	assert.Equal(t, LogLevelWarn, repository.logs[0].Level)
+	assert.Equal(t, LogLevelInfo, repository.logs[1].Level, "lines without a level default to info")
+	assert.Len(t, repository.logs[1].Message, maxLogMessageBytes)
+	assert.False(t, repository.logs[1].Timestamp.IsZero())
+
+	select {
+	case entry := <-updates:
+		assert.Equal(t, "brownout imminent", entry.Message)
+	case <-time.After(time.Second):
+		t.Fatal("follower did not receive the new line")
+	}
+
+	err := service.IngestDeviceLogs(context.Background(), "dev-1", []*DeviceLogEntry{{Level: "loud", Message: "?"}})
+	assert.True(t, errors.Is(err, ErrInvalidDeviceLog))
+	err = service.IngestDeviceLogs(context.Background(), "dev-1", nil)
+	assert.True(t, errors.Is(err, ErrInvalidDeviceLog))
+	assert.Len(t, repository.logs, 2)
+}
+
+func TestService_DeviceLogHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, repository := newLogService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := post("/api/v1/telemetry/devices/dev-1/logs", `{"logs":[{"level":"debug","message":"tick"},{"level":"error","tag":"i2c","message":"bus stuck"}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"lines":2`)
+
+	w = post("/api/v1/telemetry/devices/dev-1/logs", `{"device_id":"dev-2","logs":[{"message":"spoofed"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, repository.logs, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/devices/dev-1/logs?level=warn&since=15m&limit=5000", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"message":"bus stuck"`)
+	assert.Equal(t, maxLogLimit, repository.query.Limit)
+	assert.WithinDuration(t, time.Now().Add(-15*time.Minute), repository.query.Start, time.Minute)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/devices/dev-1/logs?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMQTTClient_HandleLogs(t *testing.T) {
+	client, err := NewMQTTClient(&MQTTConfig{BrokerURL: "tcp://localhost:1883"}, &MockRepository{}, logger.New("info", "telemetry-service"))
+	require.NoError(t, err)
+
+	var received []string
+	handler := func(ctx context.Context, deviceID string, entries []*DeviceLogEntry) error {
+		received = append(received, deviceID)
+		return nil
+	}
+
+	require.NoError(t, client.handleLogs("telemetry/dev-1/log", []byte(`{"logs":[{"level":"I","message":"boot"}]}`), handler))
+	assert.Error(t, client.handleLogs("telemetry/dev-1/log", []byte(`{"device_id":"dev-2","logs":[{"message":"spoofed"}]}`), handler))
+	assert.Equal(t, []string{"dev-1"}, received)
+}
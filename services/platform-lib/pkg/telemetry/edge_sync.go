@@ -0,0 +1,285 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSyncRecords bounds the records of a single sync request
+const maxSyncRecords = 5000
+
+// ErrInvalidSync is returned for sync requests that cannot be ingested
+var ErrInvalidSync = errors.New("invalid sync request")
+
+// SyncRecord is a reading buffered by an edge gateway. Seq numbers the
+// records of a buffer stream and never repeats within it. DeviceID names a
+// device behind the gateway the reading belongs to, and is empty for the
+// gateway's own readings.
+type SyncRecord struct {
+	Seq       uint64                 `json:"seq"`
+	DeviceID  string                 `json:"device_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	Tags      map[string]string      `json:"tags,omitempty"`
+}
+
+// SyncRequest uploads buffered records of a gateway. Stream identifies the
+// gateway's buffer and changes when the buffer is recreated, so sequence
+// numbers can start over.
+type SyncRequest struct {
+	Stream  string        `json:"stream" binding:"required"`
+	Records []*SyncRecord `json:"records" binding:"required,min=1"`
+}
+
+// SyncResponse acknowledges every record of the stream up to Acked.
+// Duplicates counts records that were stored by an earlier sync.
+type SyncResponse struct {
+	Acked      uint64 `json:"acked"`
+	Stored     int    `json:"stored"`
+	Duplicates int    `json:"duplicates"`
+}
+
+// SyncStateStore keeps the highest sequence number stored per gateway
+// buffer stream. It must survive restarts for duplicates to be detected
+// across them.
+type SyncStateStore interface {
+	GetSyncMark(ctx context.Context, deviceID, stream string) (uint64, error)
+	PutSyncMark(ctx context.Context, deviceID, stream string, seq uint64) error
+}
+
+// MemorySyncStateStore is an in-memory SyncStateStore
+type MemorySyncStateStore struct {
+	mu    sync.RWMutex
+	marks map[string]uint64
+}
+
+// NewMemorySyncStateStore creates an empty sync state store
+func NewMemorySyncStateStore() *MemorySyncStateStore {
+	return &MemorySyncStateStore{marks: make(map[string]uint64)}
+}
+
+func (s *MemorySyncStateStore) GetSyncMark(ctx context.Context, deviceID, stream string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.marks[deviceID+"/"+stream], nil
+}
+
+func (s *MemorySyncStateStore) PutSyncMark(ctx context.Context, deviceID, stream string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marks[deviceID+"/"+stream] = seq
+	return nil
+}
+
+// SyncMarkEntity is the Datastore entity of a gateway stream's sync mark.
+// Datastore has no unsigned integers, so Seq is stored as int64.
+type SyncMarkEntity struct {
+	DeviceID  string    `datastore:"device_id"`
+	Stream    string    `datastore:"stream"`
+	Seq       int64     `datastore:"seq,noindex"`
+	UpdatedAt time.Time `datastore:"updated_at"`
+}
+
+// DatastoreSyncStateStore keeps sync marks in Datastore, keyed by device
+// and stream
+type DatastoreSyncStateStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreSyncStateStore creates a Datastore-backed sync state store
+func NewDatastoreSyncStateStore(client *datastore.Client) *DatastoreSyncStateStore {
+	return &DatastoreSyncStateStore{client: client}
+}
+
+func syncMarkKey(deviceID, stream string) *datastore.Key {
+	return datastore.NameKey("EdgeSyncMark", deviceID+"/"+stream, nil)
+}
+
+func (s *DatastoreSyncStateStore) GetSyncMark(ctx context.Context, deviceID, stream string) (uint64, error) {
+	var entity SyncMarkEntity
+	switch err := s.client.Get(ctx, syncMarkKey(deviceID, stream), &entity); err {
+	case nil:
+		return uint64(entity.Seq), nil
+	case datastore.ErrNoSuchEntity:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("failed to get sync mark from Datastore: %w", err)
+	}
+}
+
+// PutSyncMark never lowers a mark, so replicas racing on the same stream
+// cannot let duplicates through
+func (s *DatastoreSyncStateStore) PutSyncMark(ctx context.Context, deviceID, stream string, seq uint64) error {
+	key := syncMarkKey(deviceID, stream)
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var entity SyncMarkEntity
+		switch err := tx.Get(key, &entity); err {
+		case nil:
+			if uint64(entity.Seq) >= seq {
+				return nil
+			}
+		case datastore.ErrNoSuchEntity:
+		default:
+			return err
+		}
+		_, err := tx.Put(key, &SyncMarkEntity{
+			DeviceID:  deviceID,
+			Stream:    stream,
+			Seq:       int64(seq),
+			UpdatedAt: time.Now(),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put sync mark in Datastore: %w", err)
+	}
+	return nil
+}
+
+// edgeSync serializes syncs per gateway, so a retry racing the request it
+// retries cannot store records twice
+type edgeSync struct {
+	mu    sync.Mutex
+	store SyncStateStore
+	locks map[string]*sync.Mutex
+}
+
+func newEdgeSync() *edgeSync {
+	return &edgeSync{store: NewMemorySyncStateStore(), locks: make(map[string]*sync.Mutex)}
+}
+
+func (e *edgeSync) lock(deviceID string) (SyncStateStore, func()) {
+	e.mu.Lock()
+	lock, ok := e.locks[deviceID]
+	if !ok {
+		lock = &sync.Mutex{}
+		e.locks[deviceID] = lock
+	}
+	store := e.store
+	e.mu.Unlock()
+
+	lock.Lock()
+	return store, lock.Unlock
+}
+
+// SetSyncStateStore replaces the store of gateway sync marks
+func (s *Service) SetSyncStateStore(store SyncStateStore) {
+	s.edgeSync.mu.Lock()
+	defer s.edgeSync.mu.Unlock()
+	s.edgeSync.store = store
+}
+
+// validate checks that records carry metrics and strictly increasing
+// sequence numbers
+func (r *SyncRequest) validate() error {
+	if len(r.Records) > maxSyncRecords {
+		return fmt.Errorf("%w: more than %d records", ErrInvalidSync, maxSyncRecords)
+	}
+	var previous uint64
+	for i, record := range r.Records {
+		switch {
+		case record == nil:
+			return fmt.Errorf("%w: record %d is null", ErrInvalidSync, i)
+		case record.Seq <= previous:
+			return fmt.Errorf("%w: record %d: seq must be positive and increasing", ErrInvalidSync, i)
+		case record.Seq > math.MaxInt64:
+			return fmt.Errorf("%w: record %d: seq exceeds %d", ErrInvalidSync, i, int64(math.MaxInt64))
+		case len(record.Metrics) == 0:
+			return fmt.Errorf("%w: record %d has no metrics", ErrInvalidSync, i)
+		}
+		previous = record.Seq
+	}
+	return nil
+}
+
+// SyncEdgeBuffer stores the records of a gateway buffer that were not
+// stored before. Records at or below the stream's mark are duplicates of
+// an earlier sync whose response the gateway did not receive.
+func (s *Service) SyncEdgeBuffer(ctx context.Context, deviceID string, request *SyncRequest) (*SyncResponse, error) {
+	if err := request.validate(); err != nil {
+		return nil, err
+	}
+
+	store, unlock := s.edgeSync.lock(deviceID)
+	defer unlock()
+
+	mark, err := store.GetSyncMark(ctx, deviceID, request.Stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync mark: %w", err)
+	}
+
+	response := &SyncResponse{Acked: mark}
+	var batch []*TelemetryData
+	for _, record := range request.Records {
+		if record.Seq <= mark {
+			response.Duplicates++
+			continue
+		}
+		batch = append(batch, &TelemetryData{
+			DeviceID:  record.DeviceID,
+			Timestamp: record.Timestamp,
+			Metrics:   record.Metrics,
+			Tags:      record.Tags,
+		})
+	}
+	if len(batch) == 0 {
+		return response, nil
+	}
+	if err := s.attributeToChildren(ctx, deviceID, batch); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSync, err)
+	}
+
+	if err := s.IngestTelemetryBatch(deviceID, batch); err != nil {
+		return nil, err
+	}
+	last := request.Records[len(request.Records)-1].Seq
+	if err := store.PutSyncMark(ctx, deviceID, request.Stream, last); err != nil {
+		// The records are stored but may be stored again on retry
+		return nil, fmt.Errorf("failed to save sync mark: %w", err)
+	}
+
+	response.Acked = last
+	response.Stored = len(batch)
+	return response, nil
+}
+
+// edgeSyncHandler accepts a bulk upload from an edge gateway, authorized
+// by the gateway's device token
+func (s *Service) edgeSyncHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	err := s.authenticateIngest(c.Request.Context(), deviceID, c.Request)
+	switch {
+	case errors.Is(err, ErrDeviceDirectoryUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request SyncRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync request", "details": err.Error()})
+		return
+	}
+
+	response, err := s.SyncEdgeBuffer(c.Request.Context(), deviceID, &request)
+	switch {
+	case errors.Is(err, ErrInvalidSync):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync request", "details": err.Error()})
+	case err != nil:
+		s.logger.Error("Failed to sync edge buffer", "device_id", deviceID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store telemetry data"})
+	default:
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,92 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func syncRecords(seqs ...uint64) []*SyncRecord {
+	records := make([]*SyncRecord, len(seqs))
+	for i, seq := range seqs {
+		records[i] = &SyncRecord{
+			Seq:       seq,
+			Timestamp: time.Date(2024, 3, 1, 10, 0, int(seq), 0, time.UTC),
+			Metrics:   map[string]interface{}{"temperature": 21.5},
+		}
+	}
+	return records
+}
+
+func TestService_SyncEdgeBuffer(t *testing.T) {
+	service, repository := newIngestService(t)
+	ctx := context.Background()
+	response, err := service.SyncEdgeBuffer(ctx, "accel-1", &SyncRequest{Stream: "a", Records: syncRecords(1, 2, 3)})
+	require.NoError(t, err)
+	assert.Equal(t, &SyncResponse{Acked: 3, Stored: 3}, response)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 2, 0, time.UTC), repository.points()[1].Timestamp)
+
+	// Records stored before are duplicates, even in a batch with new ones
+	response, err = service.SyncEdgeBuffer(ctx, "accel-1", &SyncRequest{Stream: "a", Records: syncRecords(2, 3, 5)})
+	require.NoError(t, err)
+	assert.Equal(t, &SyncResponse{Acked: 5, Stored: 1, Duplicates: 2}, response)
+	assert.Len(t, repository.points(), 4)
+
+	response, err = service.SyncEdgeBuffer(ctx, "accel-1", &SyncRequest{Stream: "a", Records: syncRecords(4)})
+	require.NoError(t, err)
+	assert.Equal(t, &SyncResponse{Acked: 5, Duplicates: 1}, response)
+
+	// A new stream starts over
+	response, err = service.SyncEdgeBuffer(ctx, "accel-1", &SyncRequest{Stream: "b", Records: syncRecords(1)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), response.Acked)
+	assert.Len(t, repository.points(), 5)
+
+	_, err = service.SyncEdgeBuffer(ctx, "accel-1", &SyncRequest{Stream: "a", Records: syncRecords(7, 6)})
+	assert.ErrorIs(t, err, ErrInvalidSync)
+}
+
+func TestService_EdgeSyncHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, repository := newIngestService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(token string, request *SyncRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/sync/accel-1", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("device-token", &SyncRequest{Stream: "a", Records: syncRecords(1, 2)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response SyncResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint64(2), response.Acked)
+	assert.Equal(t, "accel-1", repository.points()[0].DeviceID)
+
+	assert.Equal(t, http.StatusUnauthorized, send("wrong-token", &SyncRequest{Stream: "a", Records: syncRecords(3)}).Code)
+	assert.Equal(t, http.StatusBadRequest, send("device-token", &SyncRequest{Stream: "a"}).Code)
+
+	records := syncRecords(3)
+	records[0].Metrics = nil
+	assert.Equal(t, http.StatusBadRequest, send("device-token", &SyncRequest{Stream: "a", Records: records}).Code)
+
+	// Marks are stored as Datastore integers
+	records = syncRecords(3)
+	records[0].Seq = math.MaxInt64 + 1
+	assert.Equal(t, http.StatusBadRequest, send("device-token", &SyncRequest{Stream: "a", Records: records}).Code)
+}
@@ -1,11 +1,13 @@
 package telemetry
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 )
 
@@ -25,67 +27,187 @@ type ExportRequest struct {
 	Format     ExportFormat `json:"format"`
 }
 
+const (
+	// defaultExportWindow is the span of the first read of a raw export
+	defaultExportWindow = 6 * time.Hour
+
+	// minExportWindow and maxExportWindow bound the span of later reads
+	minExportWindow = time.Second
+	maxExportWindow = 100 * 365 * 24 * time.Hour
+
+	// exportPageRows is the number of points a read of a raw export aims
+	// for. Windows holding more are halved and those holding less than a
+	// quarter doubled.
+	exportPageRows = 10000
+
+	// exportBufferSize bounds the output buffered between flushes
+	exportBufferSize = 64 * 1024
+
+	// exportReadTimeout bounds each read of a raw export, which as a whole
+	// runs for as long as the client keeps reading
+	exportReadTimeout = 30 * time.Second
+)
+
 // Exporter handles telemetry data export
 type Exporter struct {
 	repository Repository
+	window     time.Duration
 }
 
 // NewExporter creates a new exporter
 func NewExporter(repository Repository) *Exporter {
 	return &Exporter{
 		repository: repository,
+		window:     defaultExportWindow,
 	}
 }
 
-// Export exports telemetry data in the specified format
-func (e *Exporter) Export(ctx context.Context, request *ExportRequest, writer io.Writer) error {
+// metricCursor reads the metrics of an export request one window of its
+// time range at a time, so that only one window is held in memory. The
+// window adapts to the density of the data, aiming for exportPageRows
+// points per read.
+type metricCursor struct {
+	repository Repository
+	request    *ExportRequest
+	next       time.Time
+	window     time.Duration
+	done       bool
+}
+
+// newMetricCursor creates a cursor over request, starting with window
+func newMetricCursor(repository Repository, request *ExportRequest, window time.Duration) *metricCursor {
+	return &metricCursor{
+		repository: repository,
+		request:    request,
+		next:       request.TimeRange.Start,
+		window:     window,
+	}
+}
+
+// Next reads the metrics of the next window in time order, returning io.EOF
+// once the time range is read
+func (c *metricCursor) Next(ctx context.Context) ([]*MetricPoint, error) {
+	if c.done {
+		return nil, io.EOF
+	}
+
+	// Windows are read up to just before the next one starts, since the
+	// repository includes both ends of a time range
+	timeRange := TimeRange{Start: c.next, End: c.request.TimeRange.End}
+	last := c.window >= timeRange.End.Sub(timeRange.Start)
+	if !last {
+		timeRange.End = c.next.Add(c.window - time.Nanosecond)
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, exportReadTimeout)
+	defer cancel()
+
 	var metrics []*MetricPoint
 	var err error
-
-	// Fetch data
-	if request.MetricName != "" {
-		metrics, err = e.repository.GetDeviceMetricsByName(ctx, request.DeviceID, request.MetricName, request.TimeRange)
+	if c.request.MetricName != "" {
+		metrics, err = c.repository.GetDeviceMetricsByName(readCtx, c.request.DeviceID, c.request.MetricName, timeRange)
 	} else {
-		metrics, err = e.repository.GetDeviceMetrics(ctx, request.DeviceID, request.TimeRange)
+		metrics, err = c.repository.GetDeviceMetrics(readCtx, c.request.DeviceID, timeRange)
 	}
-
 	if err != nil {
-		return fmt.Errorf("failed to fetch metrics: %w", err)
+		return nil, fmt.Errorf("failed to fetch metrics: %w", err)
+	}
+
+	c.done = last
+	c.next = timeRange.End.Add(time.Nanosecond)
+	switch {
+	case len(metrics) > exportPageRows && c.window > minExportWindow:
+		c.window /= 2
+	case len(metrics) < exportPageRows/4 && c.window < maxExportWindow:
+		c.window *= 2
+	}
+	return metrics, nil
+}
+
+// exportWriter buffers the output of an export and hands it to the client
+// after each read, flushing writers that stream, such as HTTP responses
+// sent with chunked transfer encoding
+type exportWriter struct {
+	*bufio.Writer
+	flusher http.Flusher
+}
+
+func newExportWriter(writer io.Writer) *exportWriter {
+	flusher, _ := writer.(http.Flusher)
+	return &exportWriter{Writer: bufio.NewWriterSize(writer, exportBufferSize), flusher: flusher}
+}
+
+// flush writes out the buffered output
+func (w *exportWriter) flush() error {
+	if err := w.Writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
 	}
+	return nil
+}
+
+// Export exports telemetry data in the specified format. The metrics are
+// streamed to writer as they are read, so ranges of any length can be
+// exported; a failure partway leaves the output truncated.
+func (e *Exporter) Export(ctx context.Context, request *ExportRequest, writer io.Writer) error {
+	cursor := newMetricCursor(e.repository, request, e.window)
+	output := newExportWriter(writer)
 
-	// Export based on format
 	switch request.Format {
 	case ExportFormatJSON:
-		return e.exportJSON(metrics, writer)
+		return e.exportJSON(ctx, cursor, output)
 	case ExportFormatCSV:
-		return e.exportCSV(metrics, writer)
+		return e.exportCSV(ctx, cursor, output)
 	default:
 		return fmt.Errorf("unsupported export format: %s", request.Format)
 	}
 }
 
-// exportJSON exports metrics as JSON
-func (e *Exporter) exportJSON(metrics []*MetricPoint, writer io.Writer) error {
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
+// exportJSON exports metrics as a JSON object, with the count after the
+// metrics since it is only known once they are written
+func (e *Exporter) exportJSON(ctx context.Context, cursor *metricCursor, output *exportWriter) error {
+	exportedAt, _ := json.Marshal(time.Now().Format(time.RFC3339))
+	fmt.Fprintf(output, "{\n  \"exported_at\": %s,\n  \"metrics\": [", exportedAt)
 
-	data := map[string]interface{}{
-		"exported_at": time.Now().Format(time.RFC3339),
-		"count":       len(metrics),
-		"metrics":     metrics,
-	}
+	count := 0
+	for {
+		metrics, err := cursor.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("failed to encode JSON: %w", err)
+		for _, metric := range metrics {
+			data, err := json.Marshal(metric)
+			if err != nil {
+				return fmt.Errorf("failed to encode JSON: %w", err)
+			}
+			if count > 0 {
+				output.WriteString(",")
+			}
+			output.WriteString("\n    ")
+			output.Write(data)
+			count++
+		}
+		if err := output.flush(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if count > 0 {
+		output.WriteString("\n  ")
+	}
+	fmt.Fprintf(output, "],\n  \"count\": %d\n}\n", count)
+	return output.flush()
 }
 
 // exportCSV exports metrics as CSV
-func (e *Exporter) exportCSV(metrics []*MetricPoint, writer io.Writer) error {
-	csvWriter := csv.NewWriter(writer)
-	defer csvWriter.Flush()
+func (e *Exporter) exportCSV(ctx context.Context, cursor *metricCursor, output *exportWriter) error {
+	csvWriter := csv.NewWriter(output)
 
 	// Write header
 	header := []string{"timestamp", "metric_name", "metric_value", "tags"}
@@ -93,23 +215,39 @@ func (e *Exporter) exportCSV(metrics []*MetricPoint, writer io.Writer) error {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
-	// Write data rows
-	for _, metric := range metrics {
-		tagsJSON, _ := json.Marshal(metric.Tags)
+	for {
+		metrics, err := cursor.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 
-		row := []string{
-			metric.Timestamp.Format(time.RFC3339),
-			metric.MetricName,
-			fmt.Sprintf("%v", metric.MetricValue),
-			string(tagsJSON),
+		// Write data rows
+		for _, metric := range metrics {
+			tagsJSON, _ := json.Marshal(metric.Tags)
+
+			row := []string{
+				metric.Timestamp.Format(time.RFC3339),
+				metric.MetricName,
+				fmt.Sprintf("%v", metric.MetricValue),
+				string(tagsJSON),
+			}
+
+			if err := csvWriter.Write(row); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
 		}
 
-		if err := csvWriter.Write(row); err != nil {
-			return fmt.Errorf("failed to write CSV row: %w", err)
+		csvWriter.Flush()
+		if err := output.flush(); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	csvWriter.Flush()
+	return output.flush()
 }
 
 // ExportAggregated exports aggregated metrics
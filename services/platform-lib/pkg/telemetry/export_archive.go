@@ -0,0 +1,235 @@
+package telemetry
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExportCompression is the compression applied to an archive export
+type ExportCompression string
+
+const (
+	ExportCompressionNone ExportCompression = ""
+	ExportCompressionGzip ExportCompression = "gzip"
+	ExportCompressionZip  ExportCompression = "zip"
+)
+
+// defaultExportFilename names each file of an archive export
+const defaultExportFilename = "{device}/{metric}/{date}"
+
+// ErrInvalidExport is returned for archive export requests that cannot be
+// served
+var ErrInvalidExport = errors.New("invalid export request")
+
+// filenamePlaceholder matches the placeholders of a filename template
+var filenamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ArchiveExportRequest exports aggregated metrics of several devices and
+// metrics in one download, one file per device and metric. Filename is a
+// template of the path of each file in the archive, without extension,
+// using the {device}, {metric}, {date} and {aggregation} placeholders.
+type ArchiveExportRequest struct {
+	DeviceIDs   []string          `json:"device_ids"`
+	MetricNames []string          `json:"metric_names"`
+	TimeRange   TimeRange         `json:"time_range"`
+	Aggregation AggregationType   `json:"aggregation"`
+	Interval    time.Duration     `json:"interval,omitempty"`
+	Format      ExportFormat      `json:"format"`
+	Compression ExportCompression `json:"compression,omitempty"`
+	Filename    string            `json:"filename,omitempty"`
+	// Async stores the export and returns a download link instead of
+	// streaming it
+	Async bool `json:"async,omitempty"`
+}
+
+// exportEntry is one file of an archive export
+type exportEntry struct {
+	name  string
+	query *AggregationQuery
+}
+
+// Validate checks an archive export request and fills in its defaults
+func (r *ArchiveExportRequest) Validate() error {
+	if len(r.DeviceIDs) == 0 {
+		return fmt.Errorf("%w: device_ids is required", ErrInvalidExport)
+	}
+	if len(r.MetricNames) == 0 {
+		return fmt.Errorf("%w: metric_names is required", ErrInvalidExport)
+	}
+	if r.Format != ExportFormatJSON && r.Format != ExportFormatCSV {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidExport, r.Format)
+	}
+	switch r.Compression {
+	case ExportCompressionNone, ExportCompressionGzip, ExportCompressionZip:
+	default:
+		return fmt.Errorf("%w: unsupported compression %q", ErrInvalidExport, r.Compression)
+	}
+	if r.Aggregation == "" {
+		r.Aggregation = AggregationAvg
+	}
+	if r.Filename == "" {
+		r.Filename = defaultExportFilename
+	}
+	for _, placeholder := range filenamePlaceholder.FindAllString(r.Filename, -1) {
+		switch placeholder {
+		case "{device}", "{metric}", "{date}", "{aggregation}":
+		default:
+			return fmt.Errorf("%w: unknown filename placeholder %s", ErrInvalidExport, placeholder)
+		}
+	}
+
+	if r.files() > 1 && r.Compression == ExportCompressionNone {
+		return fmt.Errorf("%w: exports of several files need gzip or zip compression", ErrInvalidExport)
+	}
+	_, err := r.entries()
+	return err
+}
+
+func (r *ArchiveExportRequest) files() int {
+	return len(r.DeviceIDs) * len(r.MetricNames)
+}
+
+// entries lists the files of the export in device, then metric, order
+func (r *ArchiveExportRequest) entries() ([]exportEntry, error) {
+	var entries []exportEntry
+	seen := make(map[string]bool)
+	for _, deviceID := range r.DeviceIDs {
+		for _, metricName := range r.MetricNames {
+			name := r.entryName(deviceID, metricName)
+			if seen[name] {
+				return nil, fmt.Errorf("%w: filename template gives %q to more than one file", ErrInvalidExport, name)
+			}
+			seen[name] = true
+			entries = append(entries, exportEntry{
+				name: name,
+				query: &AggregationQuery{
+					DeviceID:    deviceID,
+					MetricName:  metricName,
+					TimeRange:   r.TimeRange,
+					Aggregation: r.Aggregation,
+					Interval:    r.Interval,
+				},
+			})
+		}
+	}
+	return entries, nil
+}
+
+// entryName fills in the filename template. Values cannot add path
+// segments, so files stay inside the archive.
+func (r *ArchiveExportRequest) entryName(deviceID, metricName string) string {
+	values := map[string]string{
+		"{device}":      deviceID,
+		"{metric}":      metricName,
+		"{date}":        r.TimeRange.Start.UTC().Format("2006-01-02"),
+		"{aggregation}": string(r.Aggregation),
+	}
+	name := filenamePlaceholder.ReplaceAllStringFunc(r.Filename, func(placeholder string) string {
+		return strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(values[placeholder])
+	})
+	name = strings.TrimLeft(path.Clean("/"+name), "/")
+	return name + "." + string(r.Format)
+}
+
+// DownloadName is the filename an export is downloaded as
+func (r *ArchiveExportRequest) DownloadName() string {
+	switch {
+	case r.Compression == ExportCompressionZip:
+		return "telemetry_export.zip"
+	case r.Compression == ExportCompressionGzip && r.files() > 1:
+		return "telemetry_export.tar.gz"
+	}
+
+	entries, err := r.entries()
+	if err != nil || len(entries) == 0 {
+		return "telemetry_export." + string(r.Format)
+	}
+	name := path.Base(entries[0].name)
+	if r.Compression == ExportCompressionGzip {
+		name += ".gz"
+	}
+	return name
+}
+
+// ContentType is the media type of an export
+func (r *ArchiveExportRequest) ContentType() string {
+	switch {
+	case r.Compression == ExportCompressionZip:
+		return "application/zip"
+	case r.Compression == ExportCompressionGzip:
+		return "application/gzip"
+	case r.Format == ExportFormatCSV:
+		return "text/csv"
+	}
+	return "application/json"
+}
+
+// ExportArchive exports aggregated metrics for every device and metric of
+// the request. Several files are written as a zip archive or, with gzip, as
+// a gzipped tarball; a single file may also be plain or gzipped.
+func (e *Exporter) ExportArchive(ctx context.Context, request *ArchiveExportRequest, writer io.Writer) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	entries, err := request.entries()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case request.Compression == ExportCompressionZip:
+		archive := zip.NewWriter(writer)
+		for _, entry := range entries {
+			file, err := archive.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: time.Now()})
+			if err != nil {
+				return fmt.Errorf("failed to add %s to archive: %w", entry.name, err)
+			}
+			if err := e.ExportAggregated(ctx, entry.query, request.Format, file); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+
+	case request.Compression == ExportCompressionGzip && len(entries) > 1:
+		compressed := gzip.NewWriter(writer)
+		archive := tar.NewWriter(compressed)
+		for _, entry := range entries {
+			// Tar headers need the size up front, so each file is buffered
+			var buf bytes.Buffer
+			if err := e.ExportAggregated(ctx, entry.query, request.Format, &buf); err != nil {
+				return err
+			}
+			header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(buf.Len()), ModTime: time.Now()}
+			if err := archive.WriteHeader(header); err != nil {
+				return fmt.Errorf("failed to add %s to archive: %w", entry.name, err)
+			}
+			if _, err := archive.Write(buf.Bytes()); err != nil {
+				return fmt.Errorf("failed to add %s to archive: %w", entry.name, err)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		return compressed.Close()
+
+	case request.Compression == ExportCompressionGzip:
+		compressed := gzip.NewWriter(writer)
+		compressed.Name = entries[0].name
+		if err := e.ExportAggregated(ctx, entries[0].query, request.Format, compressed); err != nil {
+			return err
+		}
+		return compressed.Close()
+	}
+
+	return e.ExportAggregated(ctx, entries[0].query, request.Format, writer)
+}
@@ -0,0 +1,292 @@
+package telemetry
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newArchiveRequest() *ArchiveExportRequest {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return &ArchiveExportRequest{
+		DeviceIDs:   []string{"device-1", "device-2"},
+		MetricNames: []string{"temperature", "humidity"},
+		TimeRange:   TimeRange{Start: start, End: start.Add(24 * time.Hour)},
+		Interval:    time.Hour,
+		Format:      ExportFormatCSV,
+		Compression: ExportCompressionZip,
+	}
+}
+
+func newArchiveRepository() *MockRepository {
+	return &MockRepository{aggregationResult: []*AggregationResult{
+		{Timestamp: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Value: 21.5},
+	}}
+}
+
+func TestArchiveExportRequest_Validate(t *testing.T) {
+	request := newArchiveRequest()
+	require.NoError(t, request.Validate())
+	assert.Equal(t, AggregationAvg, request.Aggregation)
+	assert.Equal(t, defaultExportFilename, request.Filename)
+
+	request.Filename = "{device}/{sensor}"
+	assert.ErrorIs(t, request.Validate(), ErrInvalidExport)
+
+	// Every file needs its own name
+	request.Filename = "{device}"
+	assert.ErrorContains(t, request.Validate(), "more than one file")
+
+	// Several files need an archive
+	request = newArchiveRequest()
+	request.Compression = ExportCompressionNone
+	assert.ErrorIs(t, request.Validate(), ErrInvalidExport)
+
+	request.DeviceIDs = []string{"device-1"}
+	request.MetricNames = []string{"temperature"}
+	assert.NoError(t, request.Validate())
+
+	// Device IDs cannot escape the archive
+	request.Filename = "{device}/{metric}"
+	assert.Equal(t, "____etc/temperature.csv", request.entryName("../../etc", "temperature"))
+}
+
+func TestExporter_ExportArchive_Zip(t *testing.T) {
+	exporter := NewExporter(newArchiveRepository())
+	request := newArchiveRequest()
+	request.Filename = "{date}/{device}_{metric}_{aggregation}"
+
+	var buf bytes.Buffer
+	require.NoError(t, exporter.ExportArchive(context.Background(), request, &buf))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{
+		"2024-03-01/device-1_temperature_avg.csv",
+		"2024-03-01/device-1_humidity_avg.csv",
+		"2024-03-01/device-2_temperature_avg.csv",
+		"2024-03-01/device-2_humidity_avg.csv",
+	}, names)
+
+	file, err := archive.File[0].Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "21.5")
+	assert.Equal(t, "telemetry_export.zip", request.DownloadName())
+}
+
+func TestExporter_ExportArchive_Gzip(t *testing.T) {
+	exporter := NewExporter(newArchiveRepository())
+
+	// Several files are a gzipped tarball
+	request := newArchiveRequest()
+	request.Compression = ExportCompressionGzip
+	var buf bytes.Buffer
+	require.NoError(t, exporter.ExportArchive(context.Background(), request, &buf))
+
+	compressed, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	archive := tar.NewReader(compressed)
+	header, err := archive.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "device-1/temperature/2024-03-01.csv", header.Name)
+	assert.Equal(t, "telemetry_export.tar.gz", request.DownloadName())
+
+	// A single file is gzipped on its own
+	request.DeviceIDs = []string{"device-1"}
+	request.MetricNames = []string{"temperature"}
+	request.Format = ExportFormatJSON
+	buf.Reset()
+	require.NoError(t, exporter.ExportArchive(context.Background(), request, &buf))
+
+	compressed, err = gzip.NewReader(&buf)
+	require.NoError(t, err)
+	var exported map[string]interface{}
+	require.NoError(t, json.NewDecoder(compressed).Decode(&exported))
+	assert.Equal(t, "device-1", exported["device_id"])
+	assert.Equal(t, "2024-03-01.json.gz", request.DownloadName())
+}
+
+func TestService_AsyncExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{JWTSecret: "test-secret"}
+	service, err := NewService(cfg, logger.New("info", "telemetry-service"), newArchiveRepository())
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	request := newArchiveRequest()
+	request.Async = true
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/export/archive", bytes.NewReader(body)))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var job ExportJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+
+	require.Eventually(t, func() bool {
+		current, err := service.GetExport(context.Background(), job.ID)
+		return err == nil && current.Status == ExportJobCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/exports/"+job.ID, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	require.NotEmpty(t, job.DownloadURL)
+	require.NotNil(t, job.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *job.ExpiresAt, time.Minute)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, job.DownloadURL, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	assert.Len(t, archive.File, 4)
+
+	// Tampered links are refused
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.Replace(job.DownloadURL, "expires=", "expires=9", 1), nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestService_AsyncExportLimit(t *testing.T) {
+	cfg := &config.Config{Telemetry: config.TelemetryConfig{MaxConcurrentExports: 1}}
+	service, err := NewService(cfg, logger.New("info", "telemetry-service"), newArchiveRepository())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Hold the only slot as a running export would
+	service.exports.slots <- struct{}{}
+	_, err = service.StartExport(ctx, newArchiveRequest())
+	assert.ErrorIs(t, err, ErrTooManyExports)
+
+	<-service.exports.slots
+	job, err := service.StartExport(ctx, newArchiveRequest())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		current, err := service.GetExport(ctx, job.ID)
+		return err == nil && current.Status == ExportJobCompleted && len(service.exports.slots) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestService_InterruptedExport(t *testing.T) {
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), newArchiveRepository())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	jobs := NewMemoryExportJobStore()
+	require.NoError(t, jobs.SaveExportJob(ctx, &ExportJob{ID: "stale", Status: ExportJobPending, Request: *newArchiveRequest(), CreatedAt: time.Now().Add(-time.Hour)}))
+	service.SetExportJobStore(jobs)
+
+	job, err := service.GetExport(ctx, "stale")
+	require.NoError(t, err)
+	assert.Equal(t, ExportJobFailed, job.Status)
+	assert.Empty(t, job.DownloadURL)
+}
+
+func TestLocalExportStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalExportStore(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	size, err := store.PutExport(ctx, "job-1", strings.NewReader("archive"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), size)
+	r, err := store.OpenExport(ctx, "job-1")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "archive", string(data))
+
+	// A failed archive leaves nothing behind
+	_, err = store.PutExport(ctx, "job-2", io.MultiReader(strings.NewReader("part"), iotest.ErrReader(errors.New("export failed"))))
+	assert.ErrorContains(t, err, "export failed")
+	_, err = store.OpenExport(ctx, "job-2")
+	assert.ErrorIs(t, err, ErrExportNotFound)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = store.PutExport(ctx, "../escape", strings.NewReader("x"))
+	assert.Error(t, err)
+
+	require.NoError(t, store.DeleteExport(ctx, "job-1"))
+	require.NoError(t, store.DeleteExport(ctx, "job-1"))
+	_, err = store.OpenExport(ctx, "job-1")
+	assert.ErrorIs(t, err, ErrExportNotFound)
+}
+
+func TestExportJobEntity(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	completed := created.Add(time.Minute)
+	job := &ExportJob{ID: "job-1", Status: ExportJobCompleted, Request: *newArchiveRequest(), Size: 2048, CreatedAt: created, CompletedAt: &completed}
+
+	entity, err := job.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, job, restored)
+
+	job.Status, job.CompletedAt = ExportJobPending, nil
+	entity, err = job.ToEntity()
+	require.NoError(t, err)
+	assert.True(t, entity.CompletedAt.IsZero())
+	restored, err = entity.FromEntity()
+	require.NoError(t, err)
+	assert.Nil(t, restored.CompletedAt)
+}
+
+func TestService_ExportArchiveHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), newArchiveRepository())
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	request := newArchiveRequest()
+	request.Compression = ExportCompressionGzip
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/export/archive", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "telemetry_export.tar.gz")
+
+	request.Compression = "rar"
+	body, err = json.Marshal(request)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/export/archive", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
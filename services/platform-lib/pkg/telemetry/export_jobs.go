@@ -0,0 +1,398 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExportJobStatus is the progress of an asynchronous export
+type ExportJobStatus string
+
+const (
+	ExportJobPending   ExportJobStatus = "pending"
+	ExportJobCompleted ExportJobStatus = "completed"
+	ExportJobFailed    ExportJobStatus = "failed"
+)
+
+const (
+	// maxSyncExportFiles is the largest export streamed in the response;
+	// larger exports always run asynchronously
+	maxSyncExportFiles = 20
+
+	// exportJobTimeout bounds the runtime of an asynchronous export
+	exportJobTimeout = 10 * time.Minute
+
+	// exportRetention is how long finished exports are kept
+	exportRetention = 24 * time.Hour
+
+	// defaultMaxConcurrentExports bounds the asynchronous exports running
+	// at once when the configuration does not
+	defaultMaxConcurrentExports = 4
+)
+
+var (
+	ErrExportNotFound    = errors.New("export not found")
+	ErrExportNotReady    = errors.New("export not ready")
+	ErrExportLinkInvalid = errors.New("export link invalid or expired")
+	ErrTooManyExports    = errors.New("too many exports running")
+)
+
+// ExportStore keeps finished exports for download. In production it is
+// backed by object storage; LocalExportStore keeps them on disk.
+type ExportStore interface {
+	// PutExport stores the archive read from r and returns its size. If
+	// reading fails nothing is stored.
+	PutExport(ctx context.Context, key string, r io.Reader) (int64, error)
+	OpenExport(ctx context.Context, key string) (io.ReadCloser, error)
+	DeleteExport(ctx context.Context, key string) error
+}
+
+// MemoryExportStore is an in-memory ExportStore
+type MemoryExportStore struct {
+	mu      sync.RWMutex
+	exports map[string][]byte
+}
+
+// NewMemoryExportStore creates an empty export store
+func NewMemoryExportStore() *MemoryExportStore {
+	return &MemoryExportStore{exports: make(map[string][]byte)}
+}
+
+func (s *MemoryExportStore) PutExport(ctx context.Context, key string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exports[key] = data
+	return int64(len(data)), nil
+}
+
+func (s *MemoryExportStore) OpenExport(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.exports[key]
+	if !ok {
+		return nil, ErrExportNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryExportStore) DeleteExport(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.exports, key)
+	return nil
+}
+
+// ExportJob is an asynchronous archive export. Once completed it carries a
+// signed download link.
+type ExportJob struct {
+	ID          string               `json:"id"`
+	Status      ExportJobStatus      `json:"status"`
+	Request     ArchiveExportRequest `json:"request"`
+	Size        int64                `json:"size,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	DownloadURL string               `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time           `json:"expires_at,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+}
+
+// exportJobs runs asynchronous exports and signs their download links.
+// Slots bound the exports running at once.
+type exportJobs struct {
+	mu         sync.Mutex
+	jobs       ExportJobStore
+	store      ExportStore
+	slots      chan struct{}
+	signingKey []byte
+	linkExpiry time.Duration
+}
+
+// newExportJobs signs links with secret, or with a random key when no
+// secret is configured, which invalidates links on restart
+func newExportJobs(secret string, linkExpiry time.Duration, maxConcurrent int) *exportJobs {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	if linkExpiry <= 0 {
+		linkExpiry = time.Hour
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrentExports
+	}
+	return &exportJobs{
+		jobs:       NewMemoryExportJobStore(),
+		store:      NewMemoryExportStore(),
+		slots:      make(chan struct{}, maxConcurrent),
+		signingKey: key,
+		linkExpiry: linkExpiry,
+	}
+}
+
+// stores returns the job and export stores
+func (j *exportJobs) stores() (ExportJobStore, ExportStore) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jobs, j.store
+}
+
+func (j *exportJobs) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, j.signingKey)
+	fmt.Fprintf(mac, "%s\n%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// view copies a job, signing a fresh download link for completed exports
+func (j *exportJobs) view(job *ExportJob, now time.Time) *ExportJob {
+	copied := *job
+	if job.Status == ExportJobCompleted {
+		expiresAt := now.Add(j.linkExpiry).Truncate(time.Second)
+		copied.ExpiresAt = &expiresAt
+		copied.DownloadURL = fmt.Sprintf("/api/v1/telemetry/exports/%s/download?expires=%d&signature=%s",
+			job.ID, expiresAt.Unix(), j.signature(job.ID, expiresAt.Unix()))
+	}
+	return &copied
+}
+
+// prune deletes finished exports past their retention
+func (j *exportJobs) prune(ctx context.Context, now time.Time) error {
+	jobs, store := j.stores()
+	ids, err := jobs.ListExpiredExportJobs(ctx, now.Add(-exportRetention))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := store.DeleteExport(ctx, id); err != nil {
+			return err
+		}
+		if err := jobs.DeleteExportJob(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetExportStore replaces the store asynchronous exports are kept in
+func (s *Service) SetExportStore(store ExportStore) {
+	s.exports.mu.Lock()
+	defer s.exports.mu.Unlock()
+	s.exports.store = store
+}
+
+// SetExportJobStore replaces the store asynchronous export jobs are
+// recorded in
+func (s *Service) SetExportJobStore(store ExportJobStore) {
+	s.exports.mu.Lock()
+	defer s.exports.mu.Unlock()
+	s.exports.jobs = store
+}
+
+// StartExport runs an archive export in the background. Its progress is
+// reported by GetExport. ErrTooManyExports is returned while the maximum
+// number of exports are running.
+func (s *Service) StartExport(ctx context.Context, request *ArchiveExportRequest) (*ExportJob, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.exports.slots <- struct{}{}:
+	default:
+		return nil, ErrTooManyExports
+	}
+
+	now := time.Now()
+	if err := s.exports.prune(ctx, now); err != nil {
+		s.logger.Error("Failed to prune exports", "error", err)
+	}
+
+	job := &ExportJob{
+		ID:        uuid.New().String(),
+		Status:    ExportJobPending,
+		Request:   *request,
+		CreatedAt: now,
+	}
+	jobs, store := s.exports.stores()
+	if err := jobs.SaveExportJob(ctx, job); err != nil {
+		<-s.exports.slots
+		return nil, err
+	}
+
+	view := s.exports.view(job, now)
+	go s.runExport(job, jobs, store)
+	return view, nil
+}
+
+// runExport streams the archive into the export store as it is built
+func (s *Service) runExport(job *ExportJob, jobs ExportJobStore, store ExportStore) {
+	defer func() { <-s.exports.slots }()
+
+	ctx, cancel := context.WithTimeout(s.ctx, exportJobTimeout)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(s.exporter.ExportArchive(ctx, &job.Request, writer))
+	}()
+	size, err := store.PutExport(ctx, job.ID, reader)
+	// Stops the exporter if the store gave up first
+	reader.CloseWithError(err)
+
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	if err != nil {
+		s.logger.Error("Export failed", "export_id", job.ID, "error", err)
+		job.Status = ExportJobFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = ExportJobCompleted
+		job.Size = size
+	}
+	if err := jobs.SaveExportJob(s.ctx, job); err != nil {
+		s.logger.Error("Failed to record export", "export_id", job.ID, "error", err)
+	}
+}
+
+// getJob returns an export job. A job still pending past the export
+// timeout was interrupted by a restart and is reported as failed.
+func (s *Service) getJob(ctx context.Context, id string) (*ExportJob, error) {
+	jobs, _ := s.exports.stores()
+	job, err := jobs.GetExportJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == ExportJobPending && time.Since(job.CreatedAt) > exportJobTimeout {
+		job.Status = ExportJobFailed
+		job.Error = "export was interrupted"
+	}
+	return job, nil
+}
+
+// GetExport returns an asynchronous export
+func (s *Service) GetExport(ctx context.Context, id string) (*ExportJob, error) {
+	job, err := s.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.exports.view(job, time.Now()), nil
+}
+
+// DownloadExport opens a completed export given the expiry and signature
+// of its download link. The caller closes the returned reader.
+func (s *Service) DownloadExport(ctx context.Context, id string, expires int64, signature string) (io.ReadCloser, *ExportJob, error) {
+	if !hmac.Equal([]byte(signature), []byte(s.exports.signature(id, expires))) || time.Now().Unix() > expires {
+		return nil, nil, ErrExportLinkInvalid
+	}
+
+	job, err := s.getJob(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != ExportJobCompleted {
+		return nil, nil, ErrExportNotReady
+	}
+
+	_, store := s.exports.stores()
+	data, err := store.OpenExport(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read export: %w", err)
+	}
+	return data, job, nil
+}
+
+// exportArchiveHandler streams an archive export, or starts an
+// asynchronous one when requested or when the export is large
+func (s *Service) exportArchiveHandler(c *gin.Context) {
+	var request ArchiveExportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export request", "details": err.Error()})
+		return
+	}
+	if err := request.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export request", "details": err.Error()})
+		return
+	}
+
+	if request.Async || request.files() > maxSyncExportFiles {
+		job, err := s.StartExport(c.Request.Context(), &request)
+		switch {
+		case errors.Is(err, ErrTooManyExports):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case err != nil:
+			s.logger.Error("Failed to start export", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		default:
+			c.JSON(http.StatusAccepted, job)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	c.Header("Content-Type", request.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", request.DownloadName()))
+	if err := s.exporter.ExportArchive(ctx, &request, c.Writer); err != nil {
+		s.logger.Error("Failed to export archive", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+}
+
+func (s *Service) getExportHandler(c *gin.Context) {
+	job, err := s.GetExport(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, ErrExportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		s.logger.Error("Failed to get export", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get export"})
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func (s *Service) downloadExportHandler(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrExportLinkInvalid.Error()})
+		return
+	}
+
+	data, job, err := s.DownloadExport(c.Request.Context(), c.Param("id"), expires, c.Query("signature"))
+	switch {
+	case errors.Is(err, ErrExportLinkInvalid):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrExportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		s.logger.Error("Failed to download export", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download export"})
+	default:
+		defer data.Close()
+		c.DataFromReader(http.StatusOK, job.Size, job.Request.ContentType(), data, map[string]string{
+			"Content-Disposition": fmt.Sprintf("attachment; filename=%s", job.Request.DownloadName()),
+		})
+	}
+}
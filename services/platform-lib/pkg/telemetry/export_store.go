@@ -0,0 +1,258 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// LocalExportStore implements ExportStore on the local filesystem, keeping
+// exports under a base directory by their key
+type LocalExportStore struct {
+	basePath string
+}
+
+// NewLocalExportStore creates an export store under basePath, creating the
+// directory if needed
+func NewLocalExportStore(basePath string) (*LocalExportStore, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &LocalExportStore{basePath: basePath}, nil
+}
+
+// file returns where the export with key is kept, rejecting keys that
+// leave the base directory
+func (s *LocalExportStore) file(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid export key %q", key)
+	}
+	return filepath.Join(s.basePath, key), nil
+}
+
+// PutExport copies the archive to a temporary file and renames it into
+// place once complete, so downloads never see part of an export
+func (s *LocalExportStore) PutExport(ctx context.Context, key string, r io.Reader) (int64, error) {
+	file, err := s.file(key)
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(s.basePath, key+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	size, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	return size, nil
+}
+
+func (s *LocalExportStore) OpenExport(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *LocalExportStore) DeleteExport(ctx context.Context, key string) error {
+	file, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete export file: %w", err)
+	}
+	return nil
+}
+
+// ExportJobStore records asynchronous export jobs, so their status and
+// download links survive restarts and are shared between replicas
+type ExportJobStore interface {
+	SaveExportJob(ctx context.Context, job *ExportJob) error
+	GetExportJob(ctx context.Context, id string) (*ExportJob, error)
+	// ListExpiredExportJobs returns the IDs of jobs completed before a time
+	ListExpiredExportJobs(ctx context.Context, before time.Time) ([]string, error)
+	DeleteExportJob(ctx context.Context, id string) error
+}
+
+// MemoryExportJobStore is an in-memory ExportJobStore
+type MemoryExportJobStore struct {
+	mu   sync.RWMutex
+	jobs map[string]ExportJob
+}
+
+// NewMemoryExportJobStore creates an empty export job store
+func NewMemoryExportJobStore() *MemoryExportJobStore {
+	return &MemoryExportJobStore{jobs: make(map[string]ExportJob)}
+}
+
+func (s *MemoryExportJobStore) SaveExportJob(ctx context.Context, job *ExportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *MemoryExportJobStore) GetExportJob(ctx context.Context, id string) (*ExportJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrExportNotFound
+	}
+	return &job, nil
+}
+
+func (s *MemoryExportJobStore) ListExpiredExportJobs(ctx context.Context, before time.Time) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ids []string
+	for id, job := range s.jobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryExportJobStore) DeleteExportJob(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// ExportJobEntity represents an export job in Datastore. A zero
+// CompletedAt means the job is still running.
+type ExportJobEntity struct {
+	ID          string    `datastore:"id"`
+	Status      string    `datastore:"status,noindex"`
+	RequestJSON string    `datastore:"request_json,noindex"`
+	Size        int64     `datastore:"size,noindex"`
+	Error       string    `datastore:"error,noindex"`
+	CreatedAt   time.Time `datastore:"created_at,noindex"`
+	CompletedAt time.Time `datastore:"completed_at"`
+}
+
+// ToEntity converts an ExportJob to an ExportJobEntity
+func (j *ExportJob) ToEntity() (*ExportJobEntity, error) {
+	request, err := json.Marshal(j.Request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal export request: %w", err)
+	}
+	entity := &ExportJobEntity{
+		ID:          j.ID,
+		Status:      string(j.Status),
+		RequestJSON: string(request),
+		Size:        j.Size,
+		Error:       j.Error,
+		CreatedAt:   j.CreatedAt,
+	}
+	if j.CompletedAt != nil {
+		entity.CompletedAt = *j.CompletedAt
+	}
+	return entity, nil
+}
+
+// FromEntity converts an ExportJobEntity to an ExportJob
+func (je *ExportJobEntity) FromEntity() (*ExportJob, error) {
+	job := &ExportJob{
+		ID:        je.ID,
+		Status:    ExportJobStatus(je.Status),
+		Size:      je.Size,
+		Error:     je.Error,
+		CreatedAt: je.CreatedAt,
+	}
+	if err := json.Unmarshal([]byte(je.RequestJSON), &job.Request); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export request: %w", err)
+	}
+	if !je.CompletedAt.IsZero() {
+		completedAt := je.CompletedAt
+		job.CompletedAt = &completedAt
+	}
+	return job, nil
+}
+
+// DatastoreExportJobStore keeps export jobs in Datastore, keyed by job ID
+type DatastoreExportJobStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreExportJobStore creates an export job store on a Datastore
+// client
+func NewDatastoreExportJobStore(client *datastore.Client) *DatastoreExportJobStore {
+	return &DatastoreExportJobStore{client: client}
+}
+
+func (s *DatastoreExportJobStore) SaveExportJob(ctx context.Context, job *ExportJob) error {
+	entity, err := job.ToEntity()
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Put(ctx, datastore.NameKey("ExportJob", job.ID, nil), entity); err != nil {
+		return fmt.Errorf("failed to save export job in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreExportJobStore) GetExportJob(ctx context.Context, id string) (*ExportJob, error) {
+	var entity ExportJobEntity
+	switch err := s.client.Get(ctx, datastore.NameKey("ExportJob", id, nil), &entity); err {
+	case nil:
+	case datastore.ErrNoSuchEntity:
+		return nil, ErrExportNotFound
+	default:
+		return nil, fmt.Errorf("failed to get export job from Datastore: %w", err)
+	}
+	return entity.FromEntity()
+}
+
+// ListExpiredExportJobs skips running jobs, whose completion time is zero
+func (s *DatastoreExportJobStore) ListExpiredExportJobs(ctx context.Context, before time.Time) ([]string, error) {
+	query := datastore.NewQuery("ExportJob").
+		Filter("completed_at >", time.Time{}).
+		Filter("completed_at <", before).
+		KeysOnly()
+
+	keys, err := s.client.GetAll(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired export jobs from Datastore: %w", err)
+	}
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.Name
+	}
+	return ids, nil
+}
+
+func (s *DatastoreExportJobStore) DeleteExportJob(ctx context.Context, id string) error {
+	if err := s.client.Delete(ctx, datastore.NameKey("ExportJob", id, nil)); err != nil {
+		return fmt.Errorf("failed to delete export job from Datastore: %w", err)
+	}
+	return nil
+}
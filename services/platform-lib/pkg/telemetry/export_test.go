@@ -63,6 +63,14 @@ func (m *MockRepository) DeleteThreshold(ctx context.Context, thresholdID string
 	return nil
 }
 
+func (m *MockRepository) ListScopedThresholds(ctx context.Context, scope ThresholdScope, scopeID string) ([]*ScopedThreshold, error) {
+	return nil, nil
+}
+
+func (m *MockRepository) SaveScopedThreshold(ctx context.Context, threshold *ScopedThreshold) error {
+	return nil
+}
+
 func (m *MockRepository) CreateAlert(ctx context.Context, alert *Alert) error {
 	return nil
 }
@@ -83,10 +91,22 @@ func (m *MockRepository) ResolveAlert(ctx context.Context, alertID string) error
 	return nil
 }
 
+func (m *MockRepository) StoreDeviceLogs(ctx context.Context, entries []*DeviceLogEntry) error {
+	return nil
+}
+
+func (m *MockRepository) QueryDeviceLogs(ctx context.Context, query *DeviceLogQuery) ([]*DeviceLogEntry, error) {
+	return nil, nil
+}
+
 func (m *MockRepository) DeleteOldTelemetry(ctx context.Context, before time.Time) (int64, error) {
 	return 0, nil
 }
 
+func (m *MockRepository) DeleteOldDeviceLogs(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
 func TestExporter_ExportJSON(t *testing.T) {
 	now := time.Now()
 	mockRepo := &MockRepository{
@@ -348,3 +368,89 @@ func TestExporter_ExportCSVWithMultipleMetrics(t *testing.T) {
 	assert.Contains(t, lines[0], "metric_name")
 	assert.Contains(t, lines[0], "metric_value")
 }
+
+// rangeRepository returns the metrics within the time range of each read
+type rangeRepository struct {
+	MockRepository
+	reads []TimeRange
+}
+
+func (r *rangeRepository) GetDeviceMetrics(ctx context.Context, deviceID string, timeRange TimeRange) ([]*MetricPoint, error) {
+	r.reads = append(r.reads, timeRange)
+	var metrics []*MetricPoint
+	for _, metric := range r.metrics {
+		if !metric.Timestamp.Before(timeRange.Start) && !metric.Timestamp.After(timeRange.End) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+// flushRecorder counts the flushes of a streamed export
+type flushRecorder struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes++
+}
+
+func TestExporter_ExportStreamsWindows(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &rangeRepository{}
+	for i := 0; i < 48; i++ {
+		repo.metrics = append(repo.metrics, &MetricPoint{Timestamp: start.Add(time.Duration(i) * 30 * time.Minute), MetricName: "temperature", MetricValue: float64(i)})
+	}
+
+	exporter := NewExporter(repo)
+	exporter.window = time.Hour
+	request := &ExportRequest{
+		DeviceID:  "device-001",
+		TimeRange: TimeRange{Start: start, End: start.Add(24 * time.Hour)},
+		Format:    ExportFormatCSV,
+	}
+
+	var out flushRecorder
+	require.NoError(t, exporter.Export(context.Background(), request, &out))
+
+	// Every point is written once, in order, though read in several windows
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 49)
+	for i, record := range records[1:] {
+		assert.Equal(t, start.Add(time.Duration(i)*30*time.Minute).Format(time.RFC3339), record[0])
+	}
+	assert.Greater(t, len(repo.reads), 1)
+	assert.Equal(t, len(repo.reads)+1, out.flushes)
+	assert.Equal(t, request.TimeRange.End, repo.reads[len(repo.reads)-1].End)
+
+	// Sparse windows grow
+	assert.Equal(t, time.Hour, repo.reads[0].End.Sub(repo.reads[0].Start)+time.Nanosecond)
+	assert.Equal(t, 2*time.Hour, repo.reads[1].End.Sub(repo.reads[1].Start)+time.Nanosecond)
+
+	request.Format = ExportFormatJSON
+	out = flushRecorder{}
+	require.NoError(t, exporter.Export(context.Background(), request, &out))
+	var result struct {
+		Count   int            `json:"count"`
+		Metrics []*MetricPoint `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, 48, result.Count)
+	assert.Len(t, result.Metrics, 48)
+}
+
+func TestMetricCursor_ShrinksDenseWindows(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &rangeRepository{}
+	for i := 0; i < exportPageRows+1; i++ {
+		repo.metrics = append(repo.metrics, &MetricPoint{Timestamp: start.Add(time.Duration(i) * time.Millisecond), MetricName: "vibration"})
+	}
+
+	cursor := newMetricCursor(repo, &ExportRequest{TimeRange: TimeRange{Start: start, End: start.Add(time.Hour)}}, time.Minute)
+	metrics, err := cursor.Next(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics, exportPageRows+1)
+	assert.Equal(t, 30*time.Second, cursor.window)
+}
@@ -0,0 +1,316 @@
+package telemetry
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Expression is a compiled arithmetic expression over metric names, e.g.
+// "volts * amps" or "dew_point(temperature, humidity)". It supports numeric
+// literals, + - * /, parentheses and the functions in expressionFunctions.
+type Expression struct {
+	source string
+	root   ast.Expr
+	inputs []string
+	// payload allows the functions in payloadFunctions, which read raw bytes
+	payload bool
+}
+
+type expressionFunction struct {
+	// arity is the exact number of arguments, or -1 for one or more
+	arity int
+	call  func(args []float64) float64
+}
+
+var expressionFunctions = map[string]expressionFunction{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {-1, func(a []float64) float64 {
+		result := a[0]
+		for _, v := range a[1:] {
+			result = math.Min(result, v)
+		}
+		return result
+	}},
+	"max": {-1, func(a []float64) float64 {
+		result := a[0]
+		for _, v := range a[1:] {
+			result = math.Max(result, v)
+		}
+		return result
+	}},
+	"dew_point": {2, func(a []float64) float64 { return dewPoint(a[0], a[1]) }},
+}
+
+// payloadFunction reads a value from a raw payload; offsets are in bytes
+type payloadFunction struct {
+	arity int
+	call  func(payload []byte, args []float64) (float64, error)
+}
+
+// payloadFunctions are available to payload decoder expressions. Multi-byte
+// readers are big-endian, as most LoRaWAN devices send, with "le" variants.
+var payloadFunctions = map[string]payloadFunction{
+	"length": {0, func(p []byte, a []float64) (float64, error) { return float64(len(p)), nil }},
+	"u8":     {1, readUnsigned(1, false)},
+	"u16":    {1, readUnsigned(2, false)},
+	"u16le":  {1, readUnsigned(2, true)},
+	"u24":    {1, readUnsigned(3, false)},
+	"u24le":  {1, readUnsigned(3, true)},
+	"u32":    {1, readUnsigned(4, false)},
+	"u32le":  {1, readUnsigned(4, true)},
+	"s8":     {1, readSigned(1, false)},
+	"s16":    {1, readSigned(2, false)},
+	"s16le":  {1, readSigned(2, true)},
+	"s24":    {1, readSigned(3, false)},
+	"s24le":  {1, readSigned(3, true)},
+	"s32":    {1, readSigned(4, false)},
+	"s32le":  {1, readSigned(4, true)},
+	"f32": {1, func(p []byte, a []float64) (float64, error) {
+		bits, err := readBytes(p, a[0], 4, false)
+		return float64(math.Float32frombits(uint32(bits))), err
+	}},
+	"f32le": {1, func(p []byte, a []float64) (float64, error) {
+		bits, err := readBytes(p, a[0], 4, true)
+		return float64(math.Float32frombits(uint32(bits))), err
+	}},
+	// bits(offset, shift, width) extracts width bits of a byte, counting
+	// shift from the least significant bit
+	"bits": {3, func(p []byte, a []float64) (float64, error) {
+		value, err := readBytes(p, a[0], 1, false)
+		if err != nil {
+			return 0, err
+		}
+		shift, width := a[1], a[2]
+		if shift != math.Trunc(shift) || width != math.Trunc(width) || shift < 0 || width < 1 || shift+width > 8 {
+			return 0, fmt.Errorf("bits(%g, %g, %g) is outside a byte", a[0], shift, width)
+		}
+		return float64((value >> uint(shift)) & (1<<uint(width) - 1)), nil
+	}},
+}
+
+// readBytes reads size bytes at offset as an unsigned integer
+func readBytes(payload []byte, offset float64, size int, littleEndian bool) (uint64, error) {
+	if offset != math.Trunc(offset) || offset < 0 || int(offset)+size > len(payload) {
+		return 0, fmt.Errorf("cannot read %d byte(s) at offset %g of a %d byte payload", size, offset, len(payload))
+	}
+	var value uint64
+	for i := 0; i < size; i++ {
+		index := int(offset) + i
+		if littleEndian {
+			index = int(offset) + size - 1 - i
+		}
+		value = value<<8 | uint64(payload[index])
+	}
+	return value, nil
+}
+
+func readUnsigned(size int, littleEndian bool) func([]byte, []float64) (float64, error) {
+	return func(p []byte, a []float64) (float64, error) {
+		value, err := readBytes(p, a[0], size, littleEndian)
+		return float64(value), err
+	}
+}
+
+func readSigned(size int, littleEndian bool) func([]byte, []float64) (float64, error) {
+	return func(p []byte, a []float64) (float64, error) {
+		value, err := readBytes(p, a[0], size, littleEndian)
+		if err != nil {
+			return 0, err
+		}
+		// Sign-extend from the top bit of the value
+		shift := uint(64 - 8*size)
+		return float64(int64(value<<shift) >> shift), nil
+	}
+}
+
+// dewPoint uses the Magnus approximation for a temperature in °C and a
+// relative humidity in percent
+func dewPoint(temperature, humidity float64) float64 {
+	const a, b = 17.62, 243.12
+	gamma := math.Log(humidity/100) + a*temperature/(b+temperature)
+	return b * gamma / (a - gamma)
+}
+
+// CompileExpression parses and validates an expression
+func CompileExpression(source string) (*Expression, error) {
+	return compileExpression(source, false)
+}
+
+// CompilePayloadExpression parses an expression that may also read the raw
+// payload with the functions in payloadFunctions, e.g. "s16(0) / 100"
+func CompilePayloadExpression(source string) (*Expression, error) {
+	return compileExpression(source, true)
+}
+
+func compileExpression(source string, payload bool) (*Expression, error) {
+	root, err := parser.ParseExpr(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+
+	inputs := make(map[string]bool)
+	if err := checkExpression(root, inputs, payload); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+
+	expr := &Expression{source: source, root: root, payload: payload}
+	for input := range inputs {
+		expr.inputs = append(expr.inputs, input)
+	}
+	sort.Strings(expr.inputs)
+	return expr, nil
+}
+
+// String returns the expression source
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Inputs returns the metric names the expression reads, sorted
+func (e *Expression) Inputs() []string {
+	return e.inputs
+}
+
+// Evaluate computes the expression from metric values. Every input must be
+// present and the result must be finite.
+func (e *Expression) Evaluate(values map[string]float64) (float64, error) {
+	return e.EvaluatePayload(nil, values)
+}
+
+// EvaluatePayload computes a payload expression from the raw payload and
+// values for its inputs
+func (e *Expression) EvaluatePayload(payload []byte, values map[string]float64) (float64, error) {
+	if e.payload && payload == nil {
+		payload = []byte{}
+	}
+	result, err := evaluateExpression(e.root, values, payload)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("expression %q is not finite for the given values", e.source)
+	}
+	return result, nil
+}
+
+func checkExpression(node ast.Expr, inputs map[string]bool, payload bool) error {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.INT && n.Kind != token.FLOAT {
+			return fmt.Errorf("unsupported literal %s", n.Value)
+		}
+	case *ast.Ident:
+		inputs[n.Name] = true
+	case *ast.ParenExpr:
+		return checkExpression(n.X, inputs, payload)
+	case *ast.UnaryExpr:
+		if n.Op != token.ADD && n.Op != token.SUB {
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		return checkExpression(n.X, inputs, payload)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+		default:
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		if err := checkExpression(n.X, inputs, payload); err != nil {
+			return err
+		}
+		return checkExpression(n.Y, inputs, payload)
+	case *ast.CallExpr:
+		name, ok := n.Fun.(*ast.Ident)
+		if !ok || n.Ellipsis.IsValid() {
+			return fmt.Errorf("unsupported function call")
+		}
+		fn, ok := expressionFunctions[name.Name]
+		if reader, isReader := payloadFunctions[name.Name]; payload && isReader {
+			fn, ok = expressionFunction{arity: reader.arity}, true
+		}
+		if !ok {
+			return fmt.Errorf("unknown function %s", name.Name)
+		}
+		if fn.arity < 0 && len(n.Args) == 0 {
+			return fmt.Errorf("%s takes at least one argument", name.Name)
+		}
+		if fn.arity >= 0 && len(n.Args) != fn.arity {
+			return fmt.Errorf("%s takes %d argument(s), got %d", name.Name, fn.arity, len(n.Args))
+		}
+		for _, arg := range n.Args {
+			if err := checkExpression(arg, inputs, payload); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported syntax")
+	}
+	return nil
+}
+
+func evaluateExpression(node ast.Expr, values map[string]float64, payload []byte) (float64, error) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		return strconv.ParseFloat(n.Value, 64)
+	case *ast.Ident:
+		value, ok := values[n.Name]
+		if !ok {
+			return 0, fmt.Errorf("missing value for %s", n.Name)
+		}
+		return value, nil
+	case *ast.ParenExpr:
+		return evaluateExpression(n.X, values, payload)
+	case *ast.UnaryExpr:
+		x, err := evaluateExpression(n.X, values, payload)
+		if n.Op == token.SUB {
+			x = -x
+		}
+		return x, err
+	case *ast.BinaryExpr:
+		x, err := evaluateExpression(n.X, values, payload)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evaluateExpression(n.Y, values, payload)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		default:
+			return x / y, nil
+		}
+	case *ast.CallExpr:
+		args := make([]float64, len(n.Args))
+		for i, arg := range n.Args {
+			value, err := evaluateExpression(arg, values, payload)
+			if err != nil {
+				return 0, err
+			}
+			args[i] = value
+		}
+		name := n.Fun.(*ast.Ident).Name
+		if reader, ok := payloadFunctions[name]; ok && payload != nil {
+			return reader.call(payload, args)
+		}
+		return expressionFunctions[name].call(args), nil
+	}
+	return 0, fmt.Errorf("unsupported syntax")
+}
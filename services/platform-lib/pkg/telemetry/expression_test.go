@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileExpression(t *testing.T) {
+	tests := []struct {
+		source string
+		values map[string]float64
+		want   float64
+		inputs []string
+	}{
+		{"volts * amps", map[string]float64{"volts": 12, "amps": 1.5}, 18, []string{"amps", "volts"}},
+		{"(a + b) / 2 - -1", map[string]float64{"a": 3, "b": 5}, 5, []string{"a", "b"}},
+		{"max(a, b, 7) + min(a, 1)", map[string]float64{"a": 3, "b": 5}, 8, []string{"a", "b"}},
+		{"pow(x, 2) + sqrt(16) + abs(-1)", map[string]float64{"x": 3}, 14, []string{"x"}},
+		{"round(dew_point(temperature, humidity))", map[string]float64{"temperature": 25, "humidity": 60}, 17, []string{"humidity", "temperature"}},
+		{"level * 1e2", map[string]float64{"level": 0.25}, 25, []string{"level"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			expr, err := CompileExpression(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.inputs, expr.Inputs())
+
+			got, err := expr.Evaluate(tt.values)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestCompileExpression_Rejects(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"a +",
+		`a + "x"`,
+		"a % 2",
+		"a == b",
+		"device.battery",
+		"os.Exit(1)",
+		"unknown(a)",
+		"pow(a)",
+		"max()",
+		"a[0]",
+		"func() {}",
+	} {
+		_, err := CompileExpression(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestExpression_EvaluateErrors(t *testing.T) {
+	expr, err := CompileExpression("energy / hours")
+	require.NoError(t, err)
+
+	_, err = expr.Evaluate(map[string]float64{"energy": 10})
+	assert.Error(t, err, "missing inputs are reported")
+
+	_, err = expr.Evaluate(map[string]float64{"energy": 10, "hours": 0})
+	assert.Error(t, err, "division by zero is not a value")
+}
+
+func TestCompilePayloadExpression(t *testing.T) {
+	payload := []byte{0xFF, 0x38, 0x01, 0x02, 0x03, 0x04, 0xA5, 0x41, 0x20, 0x00, 0x00}
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"u8(0)", 255},
+		{"s8(0)", -1},
+		{"s16(0) / 100", -2},
+		{"u16(0)", 65336},
+		{"u16le(2)", 0x0201},
+		{"u24(2)", 0x010203},
+		{"s24le(4)", -5962749},
+		{"u32(2)", 0x01020304},
+		{"u32le(2)", 0x04030201},
+		{"s32(0)", -13106942},
+		{"f32(7)", 10},
+		{"bits(6, 0, 1) + bits(6, 4, 4)", 11},
+		{"length() + fport", 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			expr, err := CompilePayloadExpression(tt.source)
+			require.NoError(t, err)
+
+			got, err := expr.EvaluatePayload(payload, map[string]float64{"fport": 2})
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+
+	// Payload functions are only available to payload expressions
+	_, err := CompileExpression("u8(0)")
+	assert.Error(t, err)
+}
+
+func TestPayloadExpression_EvaluateErrors(t *testing.T) {
+	for _, source := range []string{"u16(1)", "u8(-1)", "u8(0.5)", "bits(0, 6, 3)"} {
+		expr, err := CompilePayloadExpression(source)
+		require.NoError(t, err, source)
+
+		_, err = expr.EvaluatePayload([]byte{0x01, 0x02}, nil)
+		assert.Error(t, err, source)
+	}
+
+	expr, err := CompilePayloadExpression("u8(0)")
+	require.NoError(t, err)
+	_, err = expr.Evaluate(nil)
+	assert.Error(t, err, "reading without a payload is out of range")
+
+	_, err = CompilePayloadExpression("length(1)")
+	assert.Error(t, err)
+}
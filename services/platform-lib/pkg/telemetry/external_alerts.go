@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAlertRequest is returned for external alerts that cannot be
+// raised
+var ErrInvalidAlertRequest = errors.New("invalid alert request")
+
+// AlertRequest raises an alert on a device on behalf of an external
+// system, such as a Grafana alert or an inbound webhook. Severity defaults
+// to warning.
+type AlertRequest struct {
+	DeviceID     string                 `json:"device_id" binding:"required"`
+	Message      string                 `json:"message" binding:"required"`
+	Severity     string                 `json:"severity,omitempty"`
+	MetricName   string                 `json:"metric_name,omitempty"`
+	CurrentValue float64                `json:"current_value,omitempty"`
+	Source       string                 `json:"source,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// RaiseAlert stores an external alert and notifies the alert channels
+func (s *Service) RaiseAlert(ctx context.Context, req *AlertRequest) (*Alert, error) {
+	severity := req.Severity
+	if severity == "" {
+		severity = "warning"
+	}
+	if !thresholdSeverities[severity] {
+		return nil, fmt.Errorf("%w: unknown severity %q", ErrInvalidAlertRequest, severity)
+	}
+	metricName := req.MetricName
+	if metricName == "" {
+		metricName = "external"
+	}
+
+	metadata := make(map[string]interface{}, len(req.Metadata)+1)
+	for key, value := range req.Metadata {
+		metadata[key] = value
+	}
+	metadata["source"] = "external"
+	if req.Source != "" {
+		metadata["source"] = req.Source
+	}
+
+	alert := &Alert{
+		AlertID:      uuid.New().String(),
+		DeviceID:     req.DeviceID,
+		MetricName:   metricName,
+		CurrentValue: req.CurrentValue,
+		Severity:     severity,
+		Message:      req.Message,
+		TriggeredAt:  time.Now(),
+		Status:       "active",
+		Metadata:     metadata,
+	}
+	if err := s.repository.CreateAlert(ctx, alert); err != nil {
+		return nil, fmt.Errorf("failed to store alert: %w", err)
+	}
+	if s.alertNotifier != nil {
+		s.alertNotifier.SendAlert(alert)
+	}
+	s.logger.Warn("External alert raised", "device_id", alert.DeviceID, "source", metadata["source"], "message", alert.Message)
+
+	return alert, nil
+}
+
+func (s *Service) createAlertHandler(c *gin.Context) {
+	var req AlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	alert, err := s.RaiseAlert(ctx, &req)
+	if errors.Is(err, ErrInvalidAlertRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to raise alert", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to raise alert"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, alert)
+}
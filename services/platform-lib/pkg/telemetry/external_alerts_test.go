@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_CreateAlert(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository := &alertRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/alerts", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`{"device_id":"dev-1","message":"Battery low","metric_name":"battery","current_value":12,"source":"ttn","metadata":{"f_port":1}}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Len(t, repository.alerts, 1)
+	alert := repository.alerts[0]
+	assert.Equal(t, "dev-1", alert.DeviceID)
+	assert.Equal(t, "warning", alert.Severity)
+	assert.Equal(t, "battery", alert.MetricName)
+	assert.Equal(t, float64(12), alert.CurrentValue)
+	assert.Equal(t, "active", alert.Status)
+	assert.Equal(t, "ttn", alert.Metadata["source"])
+	assert.Equal(t, float64(1), alert.Metadata["f_port"])
+
+	assert.Equal(t, http.StatusBadRequest, send(`{"device_id":"dev-1"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(`{"device_id":"dev-1","message":"x","severity":"page"}`).Code)
+	assert.Len(t, repository.alerts, 1)
+}
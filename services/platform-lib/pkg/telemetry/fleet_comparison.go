@@ -0,0 +1,521 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultComparisonWindow = 7 * 24 * time.Hour
+	maxComparisonMetrics    = 10
+	maxComparisonDevices    = 1000
+	// comparisonSignificance is the p-value below which a difference is
+	// hinted to be significant
+	comparisonSignificance = 0.05
+)
+
+// Significance hints of a fleet comparison
+const (
+	SignificanceSignificant      = "significant"
+	SignificanceNotSignificant   = "not_significant"
+	SignificanceInsufficientData = "insufficient_data"
+)
+
+var (
+	// ErrInvalidComparison is returned for a comparison request that cannot
+	// be answered
+	ErrInvalidComparison = errors.New("invalid fleet comparison")
+	// ErrFirmwareDirectoryUnavailable is returned when a group selects a
+	// firmware version but releases cannot be looked up
+	ErrFirmwareDirectoryUnavailable = errors.New("firmware directory not configured")
+)
+
+// FirmwareDirectory resolves firmware versions to the hashes of their
+// release binaries
+type FirmwareDirectory interface {
+	ReleaseHashes(ctx context.Context, templateID, version string) ([]string, error)
+}
+
+// HTTPFirmwareDirectory looks up releases on the OTA service
+type HTTPFirmwareDirectory struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPFirmwareDirectory creates a directory reading releases from the
+// OTA service at baseURL
+func NewHTTPFirmwareDirectory(baseURL string) *HTTPFirmwareDirectory {
+	return &HTTPFirmwareDirectory{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ReleaseHashes returns the binary hashes of the releases with version, on
+// any channel. An empty templateID matches releases of every template.
+func (d *HTTPFirmwareDirectory) ReleaseHashes(ctx context.Context, templateID, version string) ([]string, error) {
+	endpoint := d.baseURL + "/api/v1/ota/releases"
+	if templateID != "" {
+		endpoint += "?template_id=" + url.QueryEscape(templateID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release request: %w", err)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("ota-service listed releases with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var list struct {
+		Releases []struct {
+			Version    string `json:"version"`
+			BinaryHash string `json:"binary_hash"`
+		} `json:"releases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode releases: %w", err)
+	}
+
+	var hashes []string
+	for _, release := range list.Releases {
+		if release.Version == version && release.BinaryHash != "" {
+			hashes = append(hashes, release.BinaryHash)
+		}
+	}
+	return hashes, nil
+}
+
+// DeviceGroup selects the devices of one side of a fleet comparison: the
+// listed devices, or every device when none are listed, narrowed down by
+// each filter that is set. FirmwareVersion matches devices running the
+// binary of a release with that version, and Group the members of a device
+// group managed by the device service.
+type DeviceGroup struct {
+	Name            string            `json:"name,omitempty"`
+	DeviceIDs       []string          `json:"device_ids,omitempty"`
+	Group           string            `json:"group,omitempty"`
+	Selector        map[string]string `json:"selector,omitempty"`
+	TemplateID      string            `json:"template_id,omitempty"`
+	TemplateVersion string            `json:"template_version,omitempty"`
+	FirmwareHash    string            `json:"firmware_hash,omitempty"`
+	FirmwareVersion string            `json:"firmware_version,omitempty"`
+}
+
+// filtered reports whether the group needs device metadata
+func (g *DeviceGroup) filtered() bool {
+	return len(g.Selector) > 0 || g.Group != "" || g.TemplateID != "" || g.TemplateVersion != "" || g.FirmwareHash != "" || g.FirmwareVersion != ""
+}
+
+// FleetComparisonRequest compares two device groups on metrics over a
+// window. The window defaults to the last seven days.
+type FleetComparisonRequest struct {
+	A       DeviceGroup `json:"a"`
+	B       DeviceGroup `json:"b"`
+	Metrics []string    `json:"metrics"`
+	Start   time.Time   `json:"start"`
+	End     time.Time   `json:"end"`
+}
+
+// MetricSummary describes the readings of a metric in one group. Mean,
+// median and p95 are over all readings.
+type MetricSummary struct {
+	Devices int     `json:"devices"`
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	Median  float64 `json:"median"`
+	P95     float64 `json:"p95"`
+	StdDev  float64 `json:"stddev"`
+}
+
+// MetricDelta is how group B differs from group A. Percentages are
+// relative to A and left out when A is zero.
+type MetricDelta struct {
+	Mean          float64  `json:"mean"`
+	Median        float64  `json:"median"`
+	P95           float64  `json:"p95"`
+	MeanPercent   *float64 `json:"mean_percent,omitempty"`
+	MedianPercent *float64 `json:"median_percent,omitempty"`
+	P95Percent    *float64 `json:"p95_percent,omitempty"`
+}
+
+// SignificanceHint is a Welch's t-test of the per-device means of the two
+// groups. Readings of one device are correlated, so devices rather than
+// readings are the samples; it takes two devices with data per group.
+type SignificanceHint struct {
+	Hint   string  `json:"hint"`
+	T      float64 `json:"t,omitempty"`
+	DF     float64 `json:"df,omitempty"`
+	PValue float64 `json:"p_value,omitempty"`
+}
+
+// MetricComparison compares one metric between the groups
+type MetricComparison struct {
+	Metric       string           `json:"metric"`
+	A            MetricSummary    `json:"a"`
+	B            MetricSummary    `json:"b"`
+	Delta        *MetricDelta     `json:"delta,omitempty"`
+	Significance SignificanceHint `json:"significance"`
+}
+
+// ComparedGroup is a resolved device group
+type ComparedGroup struct {
+	Name    string   `json:"name"`
+	Devices []string `json:"devices"`
+}
+
+// FleetComparison is the answer to a FleetComparisonRequest
+type FleetComparison struct {
+	A       ComparedGroup       `json:"a"`
+	B       ComparedGroup       `json:"b"`
+	Start   time.Time           `json:"start"`
+	End     time.Time           `json:"end"`
+	Metrics []*MetricComparison `json:"metrics"`
+}
+
+// CompareFleets compares the metrics of two device groups, e.g. devices on
+// firmware 1.2.0 against devices on 1.3.0
+func (s *Service) CompareFleets(ctx context.Context, req *FleetComparisonRequest) (*FleetComparison, error) {
+	if len(req.Metrics) == 0 || len(req.Metrics) > maxComparisonMetrics {
+		return nil, fmt.Errorf("%w: between 1 and %d metrics are required", ErrInvalidComparison, maxComparisonMetrics)
+	}
+	end := req.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := req.Start
+	if start.IsZero() {
+		start = end.Add(-defaultComparisonWindow)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidComparison)
+	}
+
+	a, err := s.resolveComparisonGroup(ctx, &req.A, "a")
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.resolveComparisonGroup(ctx, &req.B, "b")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FleetComparison{A: *a, B: *b, Start: start, End: end}
+	timeRange := TimeRange{Start: start, End: end}
+	for _, metric := range req.Metrics {
+		readingsA, err := s.groupReadings(ctx, a.Devices, metric, timeRange)
+		if err != nil {
+			return nil, err
+		}
+		readingsB, err := s.groupReadings(ctx, b.Devices, metric, timeRange)
+		if err != nil {
+			return nil, err
+		}
+		result.Metrics = append(result.Metrics, compareMetric(metric, readingsA, readingsB))
+	}
+	return result, nil
+}
+
+// resolveComparisonGroup returns the sorted devices of a group
+func (s *Service) resolveComparisonGroup(ctx context.Context, group *DeviceGroup, defaultName string) (*ComparedGroup, error) {
+	name := group.Name
+	if name == "" {
+		name = defaultName
+	}
+	if len(group.DeviceIDs) == 0 && !group.filtered() {
+		return nil, fmt.Errorf("%w: group %s selects no devices", ErrInvalidComparison, name)
+	}
+
+	var devices []string
+	if !group.filtered() {
+		devices = append(devices, group.DeviceIDs...)
+	} else {
+		if s.devices == nil {
+			return nil, fmt.Errorf("%w: device groups need device metadata", ErrDeviceDirectoryUnavailable)
+		}
+
+		var hashes map[string]bool
+		if group.FirmwareVersion != "" {
+			if s.firmware == nil {
+				return nil, ErrFirmwareDirectoryUnavailable
+			}
+			found, err := s.firmware.ReleaseHashes(ctx, group.TemplateID, group.FirmwareVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve firmware %s: %w", group.FirmwareVersion, err)
+			}
+			hashes = make(map[string]bool, len(found))
+			for _, hash := range found {
+				hashes[hash] = true
+			}
+		}
+
+		listed := make(map[string]bool, len(group.DeviceIDs))
+		for _, deviceID := range group.DeviceIDs {
+			listed[deviceID] = true
+		}
+
+		candidates, err := s.devices.ListDevices(ctx, &device.DeviceFilters{TemplateID: group.TemplateID, TemplateVersion: group.TemplateVersion, Group: group.Group})
+		if errors.Is(err, device.ErrGroupNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidComparison, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices: %w", err)
+		}
+		for _, d := range candidates {
+			switch {
+			case len(listed) > 0 && !listed[d.DeviceID],
+				group.TemplateID != "" && d.TemplateID != group.TemplateID,
+				group.TemplateVersion != "" && d.TemplateVersion != group.TemplateVersion,
+				group.FirmwareHash != "" && d.FirmwareHash != group.FirmwareHash,
+				hashes != nil && !hashes[d.FirmwareHash],
+				!d.MatchesLabels(group.Selector):
+				continue
+			}
+			devices = append(devices, d.DeviceID)
+		}
+	}
+
+	if len(devices) > maxComparisonDevices {
+		return nil, fmt.Errorf("%w: group %s has %d devices, at most %d can be compared", ErrInvalidComparison, name, len(devices), maxComparisonDevices)
+	}
+	sort.Strings(devices)
+	if devices == nil {
+		devices = []string{}
+	}
+	return &ComparedGroup{Name: name, Devices: devices}, nil
+}
+
+// groupReadings returns the numeric readings of a metric by device
+func (s *Service) groupReadings(ctx context.Context, devices []string, metric string, timeRange TimeRange) (map[string][]float64, error) {
+	readings := make(map[string][]float64)
+	for _, deviceID := range devices {
+		points, err := s.repository.GetDeviceMetricsByName(ctx, deviceID, metric, timeRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s of device %s: %w", metric, deviceID, err)
+		}
+		for _, point := range points {
+			if value, ok := numericValue(point.MetricValue); ok {
+				readings[deviceID] = append(readings[deviceID], value)
+			}
+		}
+	}
+	return readings, nil
+}
+
+// compareMetric summarizes both groups' readings of a metric and how they
+// differ
+func compareMetric(metric string, a, b map[string][]float64) *MetricComparison {
+	comparison := &MetricComparison{
+		Metric: metric,
+		A:      summarizeReadings(a),
+		B:      summarizeReadings(b),
+	}
+	if comparison.A.Samples > 0 && comparison.B.Samples > 0 {
+		comparison.Delta = &MetricDelta{
+			Mean:          comparison.B.Mean - comparison.A.Mean,
+			Median:        comparison.B.Median - comparison.A.Median,
+			P95:           comparison.B.P95 - comparison.A.P95,
+			MeanPercent:   percentChange(comparison.A.Mean, comparison.B.Mean),
+			MedianPercent: percentChange(comparison.A.Median, comparison.B.Median),
+			P95Percent:    percentChange(comparison.A.P95, comparison.B.P95),
+		}
+	}
+	comparison.Significance = welchTest(deviceMeans(a), deviceMeans(b))
+	return comparison
+}
+
+func summarizeReadings(readings map[string][]float64) MetricSummary {
+	var values []float64
+	for _, deviceReadings := range readings {
+		values = append(values, deviceReadings...)
+	}
+	summary := MetricSummary{Devices: len(readings), Samples: len(values)}
+	if len(values) == 0 {
+		return summary
+	}
+	sort.Float64s(values)
+	mean, variance := meanVariance(values)
+	summary.Mean = mean
+	summary.Median = percentile(values, 50)
+	summary.P95 = percentile(values, 95)
+	summary.StdDev = math.Sqrt(variance)
+	return summary
+}
+
+func deviceMeans(readings map[string][]float64) []float64 {
+	means := make([]float64, 0, len(readings))
+	for _, deviceReadings := range readings {
+		mean, _ := meanVariance(deviceReadings)
+		means = append(means, mean)
+	}
+	return means
+}
+
+// meanVariance returns the mean and sample variance of values
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// percentile interpolates the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func percentChange(from, to float64) *float64 {
+	if from == 0 {
+		return nil
+	}
+	change := (to - from) / math.Abs(from) * 100
+	return &change
+}
+
+// welchTest tests whether two samples have different means without
+// assuming equal variances
+func welchTest(a, b []float64) SignificanceHint {
+	if len(a) < 2 || len(b) < 2 {
+		return SignificanceHint{Hint: SignificanceInsufficientData}
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	seA := varA / float64(len(a))
+	seB := varB / float64(len(b))
+	if seA+seB == 0 {
+		if meanA == meanB {
+			return SignificanceHint{Hint: SignificanceNotSignificant, PValue: 1}
+		}
+		return SignificanceHint{Hint: SignificanceSignificant}
+	}
+
+	t := (meanB - meanA) / math.Sqrt(seA+seB)
+	df := (seA + seB) * (seA + seB) / (seA*seA/float64(len(a)-1) + seB*seB/float64(len(b)-1))
+	p := studentTwoSided(t, df)
+	hint := SignificanceNotSignificant
+	if p < comparisonSignificance {
+		hint = SignificanceSignificant
+	}
+	return SignificanceHint{Hint: hint, T: t, DF: df, PValue: p}
+}
+
+// studentTwoSided is the two-sided p-value of t under Student's t
+// distribution with df degrees of freedom
+func studentTwoSided(t, df float64) float64 {
+	return regularizedBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// regularizedBeta is the regularized incomplete beta function I_x(a, b)
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly only below this point
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta
+// function with the modified Lentz method
+func betaFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		for _, numerator := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + numerator*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + numerator/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+func (s *Service) compareFleetsHandler(c *gin.Context) {
+	var req FleetComparisonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comparison request", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 60*time.Second)
+	defer cancel()
+
+	result, err := s.CompareFleets(ctx, &req)
+	switch {
+	case errors.Is(err, ErrInvalidComparison):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDeviceDirectoryUnavailable), errors.Is(err, ErrFirmwareDirectoryUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to compare fleets", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare fleets"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
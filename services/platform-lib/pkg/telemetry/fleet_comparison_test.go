@@ -0,0 +1,166 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fleetRepository returns readings by device and metric
+type fleetRepository struct {
+	MockRepository
+	readings map[string][]float64
+}
+
+func (r *fleetRepository) GetDeviceMetricsByName(ctx context.Context, deviceID string, metricName string, timeRange TimeRange) ([]*MetricPoint, error) {
+	var points []*MetricPoint
+	for _, value := range r.readings[deviceID+"/"+metricName] {
+		points = append(points, &MetricPoint{Timestamp: timeRange.Start, MetricName: metricName, MetricValue: value})
+	}
+	return points, nil
+}
+
+type firmwareReleases map[string][]string
+
+func (f firmwareReleases) ReleaseHashes(ctx context.Context, templateID, version string) ([]string, error) {
+	return f[version], nil
+}
+
+func newFleetComparisonService(t *testing.T) *Service {
+	repository := &fleetRepository{readings: map[string][]float64{
+		"old-1/power": {10, 11, 12},
+		"old-2/power": {11, 12, 13},
+		"old-3/power": {10, 12, 14},
+		"new-1/power": {7, 8, 9},
+		"new-2/power": {8, 8, 8},
+		"new-3/power": {7, 9, 8},
+		"old-1/rssi":  {-60},
+		"new-1/rssi":  {-61},
+	}}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	service.devices = deviceDirectory{
+		{DeviceID: "old-1", TemplateID: "meter", FirmwareHash: "hash-120"},
+		{DeviceID: "old-2", TemplateID: "meter", FirmwareHash: "hash-120"},
+		{DeviceID: "old-3", TemplateID: "meter", FirmwareHash: "hash-120"},
+		{DeviceID: "new-1", TemplateID: "meter", FirmwareHash: "hash-130"},
+		{DeviceID: "new-2", TemplateID: "meter", FirmwareHash: "hash-130"},
+		{DeviceID: "new-3", TemplateID: "meter", FirmwareHash: "hash-130"},
+		{DeviceID: "relay-1", TemplateID: "relay", FirmwareHash: "hash-130"},
+	}
+	service.firmware = firmwareReleases{"1.2.0": {"hash-120"}, "1.3.0": {"hash-130"}}
+	return service
+}
+
+func TestService_CompareFleets(t *testing.T) {
+	service := newFleetComparisonService(t)
+
+	result, err := service.CompareFleets(context.Background(), &FleetComparisonRequest{
+		A:       DeviceGroup{Name: "1.2.0", TemplateID: "meter", FirmwareVersion: "1.2.0"},
+		B:       DeviceGroup{Name: "1.3.0", TemplateID: "meter", FirmwareVersion: "1.3.0"},
+		Metrics: []string{"power", "rssi"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old-1", "old-2", "old-3"}, result.A.Devices)
+	assert.Equal(t, []string{"new-1", "new-2", "new-3"}, result.B.Devices)
+	assert.WithinDuration(t, result.End.Add(-defaultComparisonWindow), result.Start, time.Second)
+	require.Len(t, result.Metrics, 2)
+
+	power := result.Metrics[0]
+	assert.Equal(t, "power", power.Metric)
+	assert.Equal(t, 3, power.A.Devices)
+	assert.Equal(t, 9, power.A.Samples)
+	assert.InDelta(t, 11.667, power.A.Mean, 0.001)
+	assert.Equal(t, 12.0, power.A.Median)
+	assert.Equal(t, 8.0, power.B.Median)
+	require.NotNil(t, power.Delta)
+	assert.InDelta(t, -3.667, power.Delta.Mean, 0.001)
+	assert.InDelta(t, -31.43, *power.Delta.MeanPercent, 0.01)
+	assert.Equal(t, SignificanceSignificant, power.Significance.Hint)
+	assert.Less(t, power.Significance.PValue, 0.01)
+
+	// One device per group says nothing about the fleet
+	rssi := result.Metrics[1]
+	assert.Equal(t, SignificanceInsufficientData, rssi.Significance.Hint)
+	assert.InDelta(t, -1.0, rssi.Delta.Mean, 0.001)
+}
+
+func TestService_CompareFleets_Groups(t *testing.T) {
+	service := newFleetComparisonService(t)
+	ctx := context.Background()
+
+	// Listed devices are narrowed down by the filters
+	group, err := service.resolveComparisonGroup(ctx, &DeviceGroup{DeviceIDs: []string{"new-2", "relay-1", "old-1"}, FirmwareHash: "hash-130"}, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", group.Name)
+	assert.Equal(t, []string{"new-2", "relay-1"}, group.Devices)
+
+	_, err = service.CompareFleets(ctx, &FleetComparisonRequest{A: DeviceGroup{DeviceIDs: []string{"old-1"}}, B: DeviceGroup{}, Metrics: []string{"power"}})
+	assert.ErrorIs(t, err, ErrInvalidComparison)
+
+	_, err = service.CompareFleets(ctx, &FleetComparisonRequest{A: DeviceGroup{DeviceIDs: []string{"old-1"}}, B: DeviceGroup{DeviceIDs: []string{"new-1"}}})
+	assert.ErrorIs(t, err, ErrInvalidComparison)
+
+	service.firmware = nil
+	_, err = service.CompareFleets(ctx, &FleetComparisonRequest{
+		A:       DeviceGroup{DeviceIDs: []string{"old-1"}},
+		B:       DeviceGroup{FirmwareVersion: "1.3.0"},
+		Metrics: []string{"power"},
+	})
+	assert.ErrorIs(t, err, ErrFirmwareDirectoryUnavailable)
+}
+
+func TestWelchTest(t *testing.T) {
+	// Equal samples are not significantly different
+	hint := welchTest([]float64{1, 2, 3}, []float64{1, 2, 3})
+	assert.Equal(t, SignificanceNotSignificant, hint.Hint)
+	assert.InDelta(t, 1.0, hint.PValue, 1e-9)
+
+	// t = 2.0 with 10 degrees of freedom has a two-sided p-value of 0.0734
+	assert.InDelta(t, 0.0734, studentTwoSided(2.0, 10), 0.0001)
+	assert.InDelta(t, 0.0734, studentTwoSided(-2.0, 10), 0.0001)
+	assert.InDelta(t, 0.0044, studentTwoSided(3.5, 12), 0.0001)
+
+	assert.Equal(t, SignificanceInsufficientData, welchTest([]float64{1}, []float64{1, 2}).Hint)
+}
+
+func TestService_CompareFleetsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := newFleetComparisonService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	body, err := json.Marshal(FleetComparisonRequest{
+		A:       DeviceGroup{FirmwareHash: "hash-120"},
+		B:       DeviceGroup{FirmwareHash: "hash-130", TemplateID: "meter"},
+		Metrics: []string{"power"},
+	})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/compare", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result FleetComparison
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Metrics, 1)
+	assert.Equal(t, SignificanceSignificant, result.Metrics[0].Significance.Hint)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/compare", bytes.NewReader([]byte(`{"a":{"device_ids":["old-1"]},"b":{"device_ids":["new-1"]},"metrics":[]}`))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	service.devices = nil
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/compare", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,453 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ForecastMethod selects the model used to extrapolate a metric
+type ForecastMethod string
+
+const (
+	// ForecastMethodAuto uses Holt-Winters when at least two seasons of
+	// history are available and falls back to a linear trend otherwise
+	ForecastMethodAuto        ForecastMethod = "auto"
+	ForecastMethodLinear      ForecastMethod = "linear"
+	ForecastMethodHoltWinters ForecastMethod = "holt_winters"
+)
+
+const (
+	defaultForecastHorizon = 24 * time.Hour
+	defaultForecastHistory = 7 * 24 * time.Hour
+	defaultForecastSeason  = 24 * time.Hour
+	maxForecastHorizon     = 30 * 24 * time.Hour
+	maxForecastPoints      = 500
+	maxForecastBuckets     = 5000
+	minForecastSamples     = 3
+
+	// forecastConfidence is the coverage of the lower/upper band; z is the
+	// matching two-sided normal quantile
+	forecastConfidence = 0.95
+	forecastZ          = 1.96
+)
+
+var (
+	// ErrInvalidForecastRequest is returned for unsupported methods or out-of-range durations
+	ErrInvalidForecastRequest = errors.New("invalid forecast request")
+	// ErrInsufficientData is returned when there is too little numeric history to fit a model
+	ErrInsufficientData = errors.New("insufficient data for forecast")
+)
+
+// ForecastRequest describes a forecast for a single device metric
+type ForecastRequest struct {
+	DeviceID   string
+	MetricName string
+	Method     ForecastMethod
+	Horizon    time.Duration
+	History    time.Duration
+	// Step is the spacing of forecast points. Zero picks one from the
+	// horizon (linear) or the season (Holt-Winters).
+	Step   time.Duration
+	Season time.Duration
+	// Threshold, when set, is used to estimate when the metric crosses it
+	// (e.g. battery depletion or a tank running dry)
+	Threshold *float64
+}
+
+// ForecastPoint is a predicted value with its confidence band
+type ForecastPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Lower     float64   `json:"lower"`
+	Upper     float64   `json:"upper"`
+}
+
+// Forecast is the result of extrapolating a metric
+type Forecast struct {
+	DeviceID          string          `json:"device_id"`
+	MetricName        string          `json:"metric_name"`
+	Method            ForecastMethod  `json:"method"`
+	Horizon           string          `json:"horizon"`
+	Step              string          `json:"step"`
+	Confidence        float64         `json:"confidence"`
+	HistoryPoints     int             `json:"history_points"`
+	TrendPerHour      float64         `json:"trend_per_hour"`
+	Threshold         *float64        `json:"threshold,omitempty"`
+	ThresholdCrossing *time.Time      `json:"threshold_crossing,omitempty"`
+	GeneratedAt       time.Time       `json:"generated_at"`
+	Points            []ForecastPoint `json:"points"`
+}
+
+// Forecaster fits simple time-series models to stored telemetry
+type Forecaster struct {
+	repository Repository
+	now        func() time.Time
+}
+
+// NewForecaster creates a new forecaster
+func NewForecaster(repository Repository) *Forecaster {
+	return &Forecaster{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+type sample struct {
+	t time.Time
+	v float64
+}
+
+// Forecast predicts a metric's values over the requested horizon
+func (f *Forecaster) Forecast(ctx context.Context, req *ForecastRequest) (*Forecast, error) {
+	if err := normalizeForecastRequest(req); err != nil {
+		return nil, err
+	}
+
+	now := f.now()
+	metrics, err := f.repository.GetDeviceMetricsByName(ctx, req.DeviceID, req.MetricName, TimeRange{
+		Start: now.Add(-req.History),
+		End:   now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metrics: %w", err)
+	}
+
+	samples := numericSamples(metrics)
+	if len(samples) < minForecastSamples {
+		return nil, fmt.Errorf("%w: %d numeric samples, need at least %d", ErrInsufficientData, len(samples), minForecastSamples)
+	}
+
+	method := req.Method
+	if method == ForecastMethodAuto {
+		method = ForecastMethodLinear
+		if samples[len(samples)-1].t.Sub(samples[0].t) >= 2*req.Season {
+			method = ForecastMethodHoltWinters
+		}
+	}
+
+	forecast := &Forecast{
+		DeviceID:      req.DeviceID,
+		MetricName:    req.MetricName,
+		Method:        method,
+		Horizon:       req.Horizon.String(),
+		Confidence:    forecastConfidence,
+		HistoryPoints: len(samples),
+		Threshold:     req.Threshold,
+		GeneratedAt:   now,
+	}
+
+	step := forecastStep(req.Step, req.Horizon/48)
+	if method == ForecastMethodHoltWinters {
+		step = forecastStep(req.Step, req.Season/24)
+	}
+	if req.Horizon/step > maxForecastPoints {
+		return nil, fmt.Errorf("%w: step %s yields more than %d forecast points", ErrInvalidForecastRequest, step, maxForecastPoints)
+	}
+
+	switch method {
+	case ForecastMethodLinear:
+		forecast.Points, forecast.TrendPerHour = forecastLinear(samples, now, req.Horizon, step)
+	case ForecastMethodHoltWinters:
+		forecast.Points, forecast.TrendPerHour, err = forecastHoltWinters(samples, now, req.Horizon, step, req.Season)
+		if err != nil {
+			return nil, err
+		}
+	}
+	forecast.Step = step.String()
+
+	if req.Threshold != nil {
+		forecast.ThresholdCrossing = thresholdCrossing(samples[len(samples)-1].v, forecast.Points, *req.Threshold)
+	}
+
+	return forecast, nil
+}
+
+// normalizeForecastRequest applies defaults and validates limits
+func normalizeForecastRequest(req *ForecastRequest) error {
+	if req.Method == "" {
+		req.Method = ForecastMethodAuto
+	}
+	switch req.Method {
+	case ForecastMethodAuto, ForecastMethodLinear, ForecastMethodHoltWinters:
+	default:
+		return fmt.Errorf("%w: unsupported method %q", ErrInvalidForecastRequest, req.Method)
+	}
+
+	if req.Horizon == 0 {
+		req.Horizon = defaultForecastHorizon
+	}
+	if req.History == 0 {
+		req.History = defaultForecastHistory
+	}
+	if req.Season == 0 {
+		req.Season = defaultForecastSeason
+	}
+
+	if req.Horizon < 0 || req.Horizon > maxForecastHorizon {
+		return fmt.Errorf("%w: horizon must be between 0 and %s", ErrInvalidForecastRequest, maxForecastHorizon)
+	}
+	if req.History < 0 || req.Step < 0 || req.Season < 0 {
+		return fmt.Errorf("%w: history, step and season must be positive", ErrInvalidForecastRequest)
+	}
+	return nil
+}
+
+func forecastStep(requested, fallback time.Duration) time.Duration {
+	if requested > 0 {
+		return requested
+	}
+	if fallback < time.Minute {
+		return time.Minute
+	}
+	return fallback
+}
+
+// numericSamples extracts numeric values in time order, skipping non-numeric points
+func numericSamples(metrics []*MetricPoint) []sample {
+	samples := make([]sample, 0, len(metrics))
+	for _, metric := range metrics {
+		if value, ok := numericValue(metric.MetricValue); ok {
+			samples = append(samples, sample{t: metric.Timestamp, v: value})
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].t.Before(samples[j].t)
+	})
+	return samples
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// forecastLinear fits an ordinary least-squares line and returns prediction
+// intervals that widen with distance from the observed data
+func forecastLinear(samples []sample, now time.Time, horizon, step time.Duration) ([]ForecastPoint, float64) {
+	origin := samples[0].t
+	n := float64(len(samples))
+
+	var meanX, meanY float64
+	for _, s := range samples {
+		meanX += s.t.Sub(origin).Hours()
+		meanY += s.v
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for _, s := range samples {
+		dx := s.t.Sub(origin).Hours() - meanX
+		sxx += dx * dx
+		sxy += dx * (s.v - meanY)
+	}
+
+	slope := 0.0
+	if sxx > 0 {
+		slope = sxy / sxx
+	}
+	intercept := meanY - slope*meanX
+
+	var sse float64
+	for _, s := range samples {
+		residual := s.v - (intercept + slope*s.t.Sub(origin).Hours())
+		sse += residual * residual
+	}
+	sigma := math.Sqrt(sse / math.Max(n-2, 1))
+
+	points := make([]ForecastPoint, 0, int(horizon/step))
+	for at := now.Add(step); !at.After(now.Add(horizon)); at = at.Add(step) {
+		x := at.Sub(origin).Hours()
+		value := intercept + slope*x
+		spread := 1 + 1/n
+		if sxx > 0 {
+			spread += (x - meanX) * (x - meanX) / sxx
+		}
+		band := forecastZ * sigma * math.Sqrt(spread)
+		points = append(points, ForecastPoint{Timestamp: at, Value: value, Lower: value - band, Upper: value + band})
+	}
+	return points, slope
+}
+
+// holtWintersFit holds the final state of an additive Holt-Winters model
+type holtWintersFit struct {
+	alpha, beta, gamma float64
+	level, trend       float64
+	seasonal           []float64
+	// next is the index into seasonal for the first forecast step
+	next  int
+	sigma float64
+}
+
+// forecastHoltWinters resamples the history onto a regular grid, fits an
+// additive Holt-Winters model (smoothing parameters picked by grid search on
+// one-step-ahead error) and extrapolates it
+func forecastHoltWinters(samples []sample, now time.Time, horizon, step, season time.Duration) ([]ForecastPoint, float64, error) {
+	period := int(math.Round(float64(season) / float64(step)))
+	if period < 2 {
+		return nil, 0, fmt.Errorf("%w: season %s must span at least two steps of %s", ErrInvalidForecastRequest, season, step)
+	}
+
+	buckets := int(samples[len(samples)-1].t.Sub(samples[0].t)/step) + 1
+	if buckets > maxForecastBuckets {
+		return nil, 0, fmt.Errorf("%w: history spans %d steps of %s, limit is %d", ErrInvalidForecastRequest, buckets, step, maxForecastBuckets)
+	}
+
+	series := resample(samples, step)
+	if len(series) < 2*period {
+		return nil, 0, fmt.Errorf("%w: Holt-Winters needs two seasons (%s) of history", ErrInsufficientData, 2*season)
+	}
+
+	var best *holtWintersFit
+	grid := []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+	for _, alpha := range grid {
+		for _, beta := range grid {
+			for _, gamma := range grid {
+				fit := fitHoltWinters(series, period, alpha, beta, gamma)
+				if best == nil || fit.sigma < best.sigma {
+					best = fit
+				}
+			}
+		}
+	}
+
+	// The grid ends at the last observed bucket; forecast steps are counted
+	// from there so the season stays aligned
+	last := samples[0].t.Add(time.Duration(len(series)-1) * step)
+	points := make([]ForecastPoint, 0, int(horizon/step))
+	variance := 0.0
+	for h := 1; ; h++ {
+		at := last.Add(time.Duration(h) * step)
+		if at.After(now.Add(horizon)) {
+			break
+		}
+		if h > 1 {
+			c := best.alpha * (1 + float64(h-1)*best.beta)
+			if (h-1)%period == 0 {
+				c += best.gamma
+			}
+			variance += c * c
+		}
+		if !at.After(now) {
+			continue
+		}
+		value := best.level + float64(h)*best.trend + best.seasonal[(best.next+h-1)%period]
+		band := forecastZ * best.sigma * math.Sqrt(1+variance)
+		points = append(points, ForecastPoint{Timestamp: at, Value: value, Lower: value - band, Upper: value + band})
+	}
+
+	return points, best.trend * float64(time.Hour) / float64(step), nil
+}
+
+// fitHoltWinters runs additive Holt-Winters over series and records the
+// standard deviation of its one-step-ahead errors
+func fitHoltWinters(series []float64, period int, alpha, beta, gamma float64) *holtWintersFit {
+	// Initialise from every complete season: the trend is the average change
+	// between season means and each seasonal index is the average deviation
+	// from its (trend-adjusted) season mean. The level starts at the end of
+	// the first season, where the smoothing pass begins.
+	seasons := len(series) / period
+	means := make([]float64, seasons)
+	for k := range means {
+		for i := 0; i < period; i++ {
+			means[k] += series[k*period+i]
+		}
+		means[k] /= float64(period)
+	}
+	trend := (means[seasons-1] - means[0]) / float64((seasons-1)*period)
+
+	fit := &holtWintersFit{
+		alpha:    alpha,
+		beta:     beta,
+		gamma:    gamma,
+		level:    means[0] + trend*float64(period-1)/2,
+		trend:    trend,
+		seasonal: make([]float64, period),
+	}
+	for i := 0; i < period; i++ {
+		for k := 0; k < seasons; k++ {
+			fit.seasonal[i] += series[k*period+i] - (means[k] + trend*(float64(i)-float64(period-1)/2))
+		}
+		fit.seasonal[i] /= float64(seasons)
+	}
+
+	var sse float64
+	for i := period; i < len(series); i++ {
+		s := i % period
+		predicted := fit.level + fit.trend + fit.seasonal[s]
+		residual := series[i] - predicted
+		sse += residual * residual
+
+		previousLevel := fit.level
+		fit.level = alpha*(series[i]-fit.seasonal[s]) + (1-alpha)*(fit.level+fit.trend)
+		fit.trend = beta*(fit.level-previousLevel) + (1-beta)*fit.trend
+		fit.seasonal[s] = gamma*(series[i]-fit.level) + (1-gamma)*fit.seasonal[s]
+	}
+
+	fit.next = len(series) % period
+	fit.sigma = math.Sqrt(sse / float64(len(series)-period))
+	return fit
+}
+
+// resample averages samples into step-sized buckets starting at the first
+// sample and fills empty buckets by linear interpolation
+func resample(samples []sample, step time.Duration) []float64 {
+	origin := samples[0].t
+	count := int(samples[len(samples)-1].t.Sub(origin)/step) + 1
+
+	sums := make([]float64, count)
+	counts := make([]int, count)
+	for _, s := range samples {
+		i := int(s.t.Sub(origin) / step)
+		sums[i] += s.v
+		counts[i]++
+	}
+
+	series := make([]float64, count)
+	previous := -1
+	for i := range series {
+		if counts[i] == 0 {
+			continue
+		}
+		series[i] = sums[i] / float64(counts[i])
+		if previous >= 0 && i-previous > 1 {
+			for j := previous + 1; j < i; j++ {
+				fraction := float64(j-previous) / float64(i-previous)
+				series[j] = series[previous] + fraction*(series[i]-series[previous])
+			}
+		}
+		previous = i
+	}
+	return series
+}
+
+// thresholdCrossing returns when the predicted value first reaches threshold
+// coming from the side of the latest observation
+func thresholdCrossing(current float64, points []ForecastPoint, threshold float64) *time.Time {
+	below := current < threshold
+	for _, point := range points {
+		if (below && point.Value >= threshold) || (!below && point.Value <= threshold) {
+			at := point.Timestamp
+			return &at
+		}
+	}
+	return nil
+}
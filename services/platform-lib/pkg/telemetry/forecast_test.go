@@ -0,0 +1,169 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var forecastNow = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestForecaster(metrics []*MetricPoint) *Forecaster {
+	forecaster := NewForecaster(&MockRepository{metrics: metrics})
+	forecaster.now = func() time.Time { return forecastNow }
+	return forecaster
+}
+
+// hourlySeries returns one point per hour ending at forecastNow
+func hourlySeries(hours int, value func(h float64) float64) []*MetricPoint {
+	metrics := make([]*MetricPoint, 0, hours+1)
+	for i := hours; i >= 0; i-- {
+		at := forecastNow.Add(-time.Duration(i) * time.Hour)
+		metrics = append(metrics, &MetricPoint{
+			Timestamp:   at,
+			MetricName:  "battery",
+			MetricValue: value(float64(hours - i)),
+		})
+	}
+	return metrics
+}
+
+func TestForecaster_LinearTrend(t *testing.T) {
+	// Battery draining 1% per hour, currently at 52%
+	forecaster := newTestForecaster(hourlySeries(48, func(h float64) float64 { return 100 - h }))
+	threshold := 20.0
+
+	forecast, err := forecaster.Forecast(context.Background(), &ForecastRequest{
+		DeviceID:   "device-001",
+		MetricName: "battery",
+		Method:     ForecastMethodLinear,
+		Horizon:    48 * time.Hour,
+		Step:       time.Hour,
+		Threshold:  &threshold,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ForecastMethodLinear, forecast.Method)
+	assert.Equal(t, 49, forecast.HistoryPoints)
+	assert.InDelta(t, -1, forecast.TrendPerHour, 1e-9)
+	require.Len(t, forecast.Points, 48)
+	assert.Equal(t, forecastNow.Add(time.Hour), forecast.Points[0].Timestamp)
+	assert.InDelta(t, 51, forecast.Points[0].Value, 1e-9)
+	assert.InDelta(t, 4, forecast.Points[47].Value, 1e-9)
+	for _, point := range forecast.Points {
+		assert.LessOrEqual(t, point.Lower, point.Value)
+		assert.GreaterOrEqual(t, point.Upper, point.Value)
+	}
+
+	require.NotNil(t, forecast.ThresholdCrossing)
+	assert.Equal(t, forecastNow.Add(32*time.Hour), *forecast.ThresholdCrossing)
+}
+
+func TestForecaster_LinearBandsWidenWithNoise(t *testing.T) {
+	noisy := hourlySeries(48, func(h float64) float64 { return 50 + 3*math.Sin(h*1.7) })
+	forecaster := newTestForecaster(noisy)
+
+	forecast, err := forecaster.Forecast(context.Background(), &ForecastRequest{
+		MetricName: "tank_level",
+		Method:     ForecastMethodLinear,
+		Horizon:    24 * time.Hour,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, forecast.Points)
+	assert.Equal(t, "30m0s", forecast.Step, "the default step splits the horizon into 48 points")
+
+	first, last := forecast.Points[0], forecast.Points[len(forecast.Points)-1]
+	assert.Greater(t, first.Upper-first.Lower, 0.0)
+	assert.Greater(t, last.Upper-last.Lower, first.Upper-first.Lower, "bands widen further from the data")
+	assert.Nil(t, forecast.ThresholdCrossing)
+}
+
+func TestForecaster_HoltWintersFollowsSeason(t *testing.T) {
+	daily := func(h float64) float64 { return 20 + 0.05*h + 5*math.Sin(2*math.Pi*h/24) }
+	forecaster := newTestForecaster(hourlySeries(7*24, daily))
+
+	forecast, err := forecaster.Forecast(context.Background(), &ForecastRequest{
+		MetricName: "temperature",
+		Horizon:    24 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ForecastMethodHoltWinters, forecast.Method, "auto picks Holt-Winters with a week of hourly data")
+	assert.Equal(t, "1h0m0s", forecast.Step)
+	require.Len(t, forecast.Points, 24)
+	assert.InDelta(t, 0.05, forecast.TrendPerHour, 0.02)
+	for i, point := range forecast.Points {
+		assert.InDelta(t, daily(float64(7*24+i+1)), point.Value, 0.5, "point %d", i)
+	}
+}
+
+func TestForecaster_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := newTestForecaster(hourlySeries(1, func(h float64) float64 { return h })).Forecast(ctx, &ForecastRequest{MetricName: "battery"})
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	nonNumeric := []*MetricPoint{
+		{Timestamp: forecastNow, MetricValue: "on"},
+		{Timestamp: forecastNow, MetricValue: "off"},
+		{Timestamp: forecastNow, MetricValue: "on"},
+	}
+	_, err = newTestForecaster(nonNumeric).Forecast(ctx, &ForecastRequest{MetricName: "switch"})
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	series := hourlySeries(24, func(h float64) float64 { return h })
+	_, err = newTestForecaster(series).Forecast(ctx, &ForecastRequest{Method: "arima"})
+	assert.ErrorIs(t, err, ErrInvalidForecastRequest)
+
+	_, err = newTestForecaster(series).Forecast(ctx, &ForecastRequest{Horizon: 60 * 24 * time.Hour})
+	assert.ErrorIs(t, err, ErrInvalidForecastRequest)
+
+	_, err = newTestForecaster(series).Forecast(ctx, &ForecastRequest{Horizon: 24 * time.Hour, Step: time.Minute})
+	assert.ErrorIs(t, err, ErrInvalidForecastRequest)
+
+	_, err = newTestForecaster(series).Forecast(ctx, &ForecastRequest{Method: ForecastMethodHoltWinters})
+	assert.ErrorIs(t, err, ErrInsufficientData, "Holt-Winters needs two full seasons")
+}
+
+func TestService_ForecastHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &MockRepository{
+		metrics: hourlySeries(48, func(h float64) float64 { return 100 - h }),
+	})
+	require.NoError(t, err)
+	service.forecaster.now = func() time.Time { return forecastNow }
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/forecast/device-001/battery?horizon=48h&step=1h&threshold=20", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var forecast Forecast
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &forecast))
+	assert.Equal(t, "device-001", forecast.DeviceID)
+	assert.Equal(t, "battery", forecast.MetricName)
+	assert.Len(t, forecast.Points, 48)
+	require.NotNil(t, forecast.ThresholdCrossing)
+
+	for query, status := range map[string]int{
+		"?horizon=soon":   http.StatusBadRequest,
+		"?threshold=low":  http.StatusBadRequest,
+		"?method=prophet": http.StatusBadRequest,
+	} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/forecast/device-001/battery"+query, nil))
+		assert.Equal(t, status, w.Code, query)
+	}
+}
@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/device"
+)
+
+// GatewayTag is added to telemetry a gateway transported on behalf of a
+// device behind it. The point itself is attributed to that device.
+const GatewayTag = "gateway_id"
+
+// ErrNotChildDevice is returned for points a gateway sends on behalf of a
+// device that is not behind it
+var ErrNotChildDevice = errors.New("device is not behind the gateway")
+
+// attributeToChildren checks that every point a gateway sends is its own
+// or belongs to a device behind it, directly or through other gateways.
+// Points of other devices are tagged with the gateway that carried them.
+func (s *Service) attributeToChildren(ctx context.Context, gatewayID string, points []*TelemetryData) error {
+	verified := map[string]bool{gatewayID: true}
+	for _, data := range points {
+		if data.DeviceID == "" || data.DeviceID == gatewayID {
+			continue
+		}
+		if !verified[data.DeviceID] {
+			if err := s.checkBehindGateway(ctx, gatewayID, data.DeviceID); err != nil {
+				return err
+			}
+			verified[data.DeviceID] = true
+		}
+		if data.Tags == nil {
+			data.Tags = make(map[string]string, 1)
+		}
+		data.Tags[GatewayTag] = gatewayID
+	}
+	return nil
+}
+
+// checkBehindGateway walks up from deviceID looking for gatewayID
+func (s *Service) checkBehindGateway(ctx context.Context, gatewayID, deviceID string) error {
+	if s.devices == nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotChildDevice, deviceID, ErrDeviceDirectoryUnavailable)
+	}
+
+	id := deviceID
+	for depth := 0; depth < device.MaxHierarchyDepth; depth++ {
+		dev, err := s.devices.GetDevice(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrNotChildDevice, deviceID, err)
+		}
+		if dev.ParentID == gatewayID {
+			return nil
+		}
+		if dev.ParentID == "" {
+			break
+		}
+		id = dev.ParentID
+	}
+	return fmt.Errorf("%w: %s", ErrNotChildDevice, deviceID)
+}
@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_AttributeToChildren(t *testing.T) {
+	service, _ := newIngestService(t)
+	ctx := context.Background()
+
+	points := []*TelemetryData{
+		{Metrics: map[string]interface{}{"x": 1.0}},
+		{DeviceID: "probe-1", Metrics: map[string]interface{}{"x": 2.0}},
+		{DeviceID: "probe-1a", Metrics: map[string]interface{}{"x": 3.0}, Tags: map[string]string{"axis": "z"}},
+	}
+	require.NoError(t, service.attributeToChildren(ctx, "accel-1", points))
+	assert.Empty(t, points[0].Tags)
+	assert.Equal(t, map[string]string{GatewayTag: "accel-1"}, points[1].Tags)
+	assert.Equal(t, map[string]string{"axis": "z", GatewayTag: "accel-1"}, points[2].Tags)
+
+	// A child cannot carry its gateway, nor a gateway an unrelated device
+	assert.ErrorIs(t, service.attributeToChildren(ctx, "probe-1", []*TelemetryData{{DeviceID: "accel-1"}}), ErrNotChildDevice)
+	assert.ErrorIs(t, service.attributeToChildren(ctx, "accel-1", []*TelemetryData{{DeviceID: "other-1"}}), ErrNotChildDevice)
+	assert.ErrorIs(t, service.attributeToChildren(ctx, "accel-1", []*TelemetryData{{DeviceID: "missing"}}), ErrNotChildDevice)
+}
+
+func TestService_GatewayBatchIngest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, repository := newIngestService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(body string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/ingest/accel-1/batch", strings.NewReader(body)))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(`{"points":[{"metrics":{"x":1}},{"device_id":"probe-1","metrics":{"x":2}}]}`))
+	points := repository.points()
+	require.Len(t, points, 2)
+	assert.Equal(t, "accel-1", points[0].DeviceID)
+	assert.Equal(t, "probe-1", points[1].DeviceID)
+	assert.Equal(t, "accel-1", points[1].Tags[GatewayTag])
+
+	assert.Equal(t, http.StatusBadRequest, send(`{"points":[{"device_id":"other-1","metrics":{"x":1}}]}`))
+	assert.Len(t, repository.points(), 2)
+
+	// Records of an edge sync are attributed the same way
+	records := syncRecords(1, 2)
+	records[1].DeviceID = "probe-1a"
+	response, err := service.SyncEdgeBuffer(context.Background(), "accel-1", &SyncRequest{Stream: "a", Records: records})
+	require.NoError(t, err)
+	assert.Equal(t, 2, response.Stored)
+	assert.Equal(t, "probe-1a", repository.points()[3].DeviceID)
+
+	records = syncRecords(3)
+	records[0].DeviceID = "other-1"
+	_, err = service.SyncEdgeBuffer(context.Background(), "accel-1", &SyncRequest{Stream: "a", Records: records})
+	assert.ErrorIs(t, err, ErrInvalidSync)
+}
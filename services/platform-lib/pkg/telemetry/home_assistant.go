@@ -0,0 +1,392 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+const (
+	defaultHomeAssistantQueueSize = 1000
+	// homeAssistantRefresh is how long an announcement is trusted before
+	// the device and its template are looked up again, e.g. after an update
+	// to a new template version
+	homeAssistantRefresh    = time.Hour
+	homeAssistantTimeout    = 10 * time.Second
+	homeAssistantIdentifier = "athena"
+)
+
+var homeAssistantUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// MessagePublisher publishes MQTT messages. MQTTClient satisfies it.
+type MessagePublisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) error
+}
+
+// TemplateDirectory looks up the templates devices are built from.
+// template.Repository satisfies it.
+type TemplateDirectory interface {
+	GetTemplate(ctx context.Context, id, version string) (*template.Template, error)
+}
+
+// homeAssistantDevice is the device block of a discovery message
+type homeAssistantDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+	HWVersion    string   `json:"hw_version,omitempty"`
+}
+
+// homeAssistantEntity is the discovery message of one sensor
+type homeAssistantEntity struct {
+	Name              string              `json:"name"`
+	UniqueID          string              `json:"unique_id"`
+	StateTopic        string              `json:"state_topic"`
+	UnitOfMeasurement string              `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string              `json:"device_class,omitempty"`
+	StateClass        string              `json:"state_class,omitempty"`
+	Icon              string              `json:"icon,omitempty"`
+	Device            homeAssistantDevice `json:"device"`
+}
+
+// homeAssistantAnnouncement is what was announced for a device: the type
+// of each metric by name and the discovery topics used
+type homeAssistantAnnouncement struct {
+	metrics   map[string]string
+	topics    []string
+	checkedAt time.Time
+}
+
+// HomeAssistantDiscovery announces devices to Home Assistant over MQTT. The
+// first reading of a device publishes a retained discovery message for each
+// metric of its template's telemetry schema, and every reading is
+// republished to the metric state topics.
+type HomeAssistantDiscovery struct {
+	settings  config.HomeAssistantConfig
+	publisher MessagePublisher
+	devices   DeviceDirectory
+	templates TemplateDirectory
+	logger    *logger.Logger
+	now       func() time.Time
+
+	queue   chan *TelemetryData
+	wg      sync.WaitGroup
+	dropped atomic.Int64
+
+	mu        sync.Mutex
+	stopped   bool
+	announced map[string]*homeAssistantAnnouncement
+}
+
+// NewHomeAssistantDiscovery creates a discovery publisher. Its publisher,
+// device directory and template directory must be set before it starts.
+func NewHomeAssistantDiscovery(settings config.HomeAssistantConfig, logger *logger.Logger) *HomeAssistantDiscovery {
+	if settings.DiscoveryPrefix == "" {
+		settings.DiscoveryPrefix = "homeassistant"
+	}
+	if settings.StateTopicPrefix == "" {
+		settings.StateTopicPrefix = homeAssistantIdentifier
+	}
+	return &HomeAssistantDiscovery{
+		settings:  settings,
+		logger:    logger,
+		now:       time.Now,
+		queue:     make(chan *TelemetryData, defaultHomeAssistantQueueSize),
+		announced: make(map[string]*homeAssistantAnnouncement),
+	}
+}
+
+// SetTemplateDirectory gives Home Assistant discovery the templates devices
+// are built from. It has no effect unless discovery is enabled.
+func (s *Service) SetTemplateDirectory(templates TemplateDirectory) {
+	if s.homeAssistant != nil {
+		s.homeAssistant.templates = templates
+	}
+}
+
+// StatusTopic is where Home Assistant announces it came online
+func (d *HomeAssistantDiscovery) StatusTopic() string {
+	return d.settings.DiscoveryPrefix + "/status"
+}
+
+func (d *HomeAssistantDiscovery) start(ctx context.Context) {
+	d.wg.Add(1)
+	go d.publishLoop(ctx)
+}
+
+func (d *HomeAssistantDiscovery) stop() {
+	d.mu.Lock()
+	stopped := d.stopped
+	d.stopped = true
+	d.mu.Unlock()
+	if stopped {
+		return
+	}
+	close(d.queue)
+	d.wg.Wait()
+}
+
+// enqueue hands a reading to the publish loop without blocking ingestion
+func (d *HomeAssistantDiscovery) enqueue(data *TelemetryData) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+
+	select {
+	case d.queue <- data:
+	default:
+		if d.dropped.Add(1) == 1 {
+			d.logger.Warn("Home Assistant queue full, dropping telemetry")
+		}
+	}
+}
+
+func (d *HomeAssistantDiscovery) publishLoop(ctx context.Context) {
+	defer d.wg.Done()
+	for data := range d.queue {
+		publishCtx, cancel := context.WithTimeout(ctx, homeAssistantTimeout)
+		err := d.Publish(publishCtx, data)
+		cancel()
+		if err != nil {
+			d.logger.Error("Failed to publish telemetry to Home Assistant", "device_id", data.DeviceID, "error", err)
+		}
+	}
+}
+
+// handleStatus forgets every announcement when Home Assistant comes back
+// online, so devices are announced again with their next reading
+func (d *HomeAssistantDiscovery) handleStatus(topic string, payload []byte) error {
+	if string(payload) != "online" {
+		return nil
+	}
+	d.mu.Lock()
+	d.announced = make(map[string]*homeAssistantAnnouncement)
+	d.mu.Unlock()
+	d.logger.Info("Home Assistant came online, devices will be announced again")
+	return nil
+}
+
+// Publish announces a reading's device if needed and publishes the
+// reading's schema metrics to their state topics
+func (d *HomeAssistantDiscovery) Publish(ctx context.Context, data *TelemetryData) error {
+	announcement, err := d.announce(ctx, data.DeviceID)
+	if err != nil {
+		return err
+	}
+	for name, value := range data.Metrics {
+		metricType, ok := announcement.metrics[name]
+		if !ok {
+			continue
+		}
+		if err := d.publisher.Publish(d.stateTopic(data.DeviceID, name), 0, true, homeAssistantState(metricType, value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announce publishes the discovery messages of a device, unless they were
+// published recently. Discovery messages of metrics the device's template
+// no longer has are removed.
+func (d *HomeAssistantDiscovery) announce(ctx context.Context, deviceID string) (*homeAssistantAnnouncement, error) {
+	now := d.now()
+	d.mu.Lock()
+	previous := d.announced[deviceID]
+	d.mu.Unlock()
+	if previous != nil && now.Sub(previous.checkedAt) < homeAssistantRefresh {
+		return previous, nil
+	}
+
+	entities, err := d.entities(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	announcement := &homeAssistantAnnouncement{metrics: make(map[string]string), checkedAt: now}
+	published := make(map[string]bool, len(entities))
+	for _, entity := range entities {
+		if err := d.publisher.Publish(entity.topic, 1, true, entity.config); err != nil {
+			return nil, fmt.Errorf("failed to announce device %s: %w", deviceID, err)
+		}
+		announcement.metrics[entity.metric] = entity.metricType
+		announcement.topics = append(announcement.topics, entity.topic)
+		published[entity.topic] = true
+	}
+	if previous != nil {
+		for _, topic := range previous.topics {
+			if !published[topic] {
+				if err := d.publisher.Publish(topic, 1, true, ""); err != nil {
+					return nil, fmt.Errorf("failed to remove discovery message %s: %w", topic, err)
+				}
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.announced[deviceID] = announcement
+	d.mu.Unlock()
+	if len(entities) > 0 && (previous == nil || len(previous.topics) != len(announcement.topics)) {
+		d.logger.Info("Announced device to Home Assistant", "device_id", deviceID, "entities", len(entities))
+	}
+	return announcement, nil
+}
+
+// homeAssistantMetric is the discovery message of one metric of a device
+type homeAssistantMetric struct {
+	metric     string
+	metricType string
+	topic      string
+	config     homeAssistantEntity
+}
+
+// entities builds the discovery messages of a device from its template's
+// telemetry schema. Unknown devices and templates without a schema have
+// none.
+func (d *HomeAssistantDiscovery) entities(ctx context.Context, deviceID string) ([]homeAssistantMetric, error) {
+	if d.devices == nil {
+		return nil, ErrDeviceDirectoryUnavailable
+	}
+	if d.templates == nil {
+		return nil, fmt.Errorf("template directory not configured")
+	}
+
+	dev, err := d.devices.GetDevice(ctx, deviceID)
+	if err != nil || dev == nil || dev.TemplateID == "" {
+		return nil, nil
+	}
+	tmpl, err := d.templates.GetTemplate(ctx, dev.TemplateID, dev.TemplateVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template %s@%s: %w", dev.TemplateID, dev.TemplateVersion, err)
+	}
+
+	name := dev.Name
+	if name == "" {
+		name = dev.DeviceID
+	}
+	device := homeAssistantDevice{
+		Identifiers:  []string{homeAssistantIdentifier + "_" + dev.DeviceID},
+		Name:         name,
+		Manufacturer: "Athena",
+		Model:        tmpl.Name,
+		SWVersion:    tmpl.Version,
+		HWVersion:    dev.BoardType,
+	}
+
+	nodeID := homeAssistantUnsafe.ReplaceAllString(homeAssistantIdentifier+"_"+dev.DeviceID, "_")
+	var entities []homeAssistantMetric
+	for _, metric := range tmpl.Telemetry {
+		metricType := metric.Type
+		if metricType == "" {
+			metricType = template.MetricTypeNumber
+		}
+		component := "sensor"
+		if metricType == template.MetricTypeBoolean {
+			component = "binary_sensor"
+		}
+
+		entity := homeAssistantEntity{
+			Name:              metric.DisplayName,
+			UniqueID:          nodeID + "_" + homeAssistantUnsafe.ReplaceAllString(metric.Name, "_"),
+			StateTopic:        d.stateTopic(dev.DeviceID, metric.Name),
+			UnitOfMeasurement: metric.Unit,
+			DeviceClass:       metric.DeviceClass,
+			StateClass:        metric.StateClass,
+			Icon:              metric.Icon,
+			Device:            device,
+		}
+		if entity.Name == "" {
+			entity.Name = metric.Name
+		}
+		if entity.StateClass == "" && metricType == template.MetricTypeNumber {
+			entity.StateClass = "measurement"
+		}
+		if metricType != template.MetricTypeNumber {
+			entity.StateClass = ""
+		}
+
+		entities = append(entities, homeAssistantMetric{
+			metric:     metric.Name,
+			metricType: metricType,
+			topic:      fmt.Sprintf("%s/%s/%s/%s/config", d.settings.DiscoveryPrefix, component, nodeID, homeAssistantUnsafe.ReplaceAllString(metric.Name, "_")),
+			config:     entity,
+		})
+	}
+	return entities, nil
+}
+
+// stateTopic is where the readings of a device metric are published
+func (d *HomeAssistantDiscovery) stateTopic(deviceID, metric string) string {
+	return d.settings.StateTopicPrefix + "/" + deviceID + "/" + metric
+}
+
+// homeAssistantState formats a reading the way Home Assistant expects it
+// for the metric type; binary sensors take ON and OFF
+func homeAssistantState(metricType string, value interface{}) string {
+	if metricType == template.MetricTypeBoolean {
+		on := false
+		switch v := value.(type) {
+		case bool:
+			on = v
+		case string:
+			on, _ = strconv.ParseBool(v)
+		default:
+			if f, ok := numericValue(value); ok {
+				on = f != 0
+			}
+		}
+		if on {
+			return "ON"
+		}
+		return "OFF"
+	}
+	if f, ok := numericValue(value); ok && metricType == template.MetricTypeNumber {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// homeAssistantRepository hands stored telemetry to Home Assistant
+// discovery
+type homeAssistantRepository struct {
+	Repository
+	discovery *HomeAssistantDiscovery
+}
+
+// StoreTelemetry stores data and queues it for Home Assistant
+func (r *homeAssistantRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	if err := r.Repository.StoreTelemetry(ctx, data); err != nil {
+		return err
+	}
+	r.discovery.enqueue(data)
+	return nil
+}
+
+// StoreTelemetryBatch stores a batch and queues the latest reading of each
+// device for Home Assistant
+func (r *homeAssistantRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	if err := r.Repository.StoreTelemetryBatch(ctx, batch); err != nil {
+		return err
+	}
+	latest := make(map[string]*TelemetryData)
+	for _, data := range batch {
+		if current, ok := latest[data.DeviceID]; !ok || !data.Timestamp.Before(current.Timestamp) {
+			latest[data.DeviceID] = data
+		}
+	}
+	for _, data := range latest {
+		r.discovery.enqueue(data)
+	}
+	return nil
+}
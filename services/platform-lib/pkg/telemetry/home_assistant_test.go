@@ -0,0 +1,170 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher keeps the last message published to each topic
+type recordingPublisher struct {
+	mu       sync.Mutex
+	messages map[string]string
+	count    int
+}
+
+func (p *recordingPublisher) Publish(topic string, qos byte, retained bool, payload interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.messages == nil {
+		p.messages = make(map[string]string)
+	}
+	data, ok := payload.(string)
+	if !ok {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	p.messages[topic] = data
+	p.count++
+	return nil
+}
+
+func newHomeAssistantDiscovery(t *testing.T) (*HomeAssistantDiscovery, *recordingPublisher, *template.MemoryRepository) {
+	templates := template.NewMemoryRepository()
+	require.NoError(t, templates.CreateTemplate(context.Background(), &template.Template{
+		ID:      "greenhouse",
+		Name:    "Greenhouse Monitor",
+		Version: "1.2.0",
+		Telemetry: []template.TelemetryMetric{
+			{Name: "temperature", Unit: "°C", DeviceClass: "temperature"},
+			{Name: "door_open", DisplayName: "Door", Type: template.MetricTypeBoolean, DeviceClass: "door"},
+		},
+	}))
+
+	publisher := &recordingPublisher{}
+	discovery := NewHomeAssistantDiscovery(config.HomeAssistantConfig{}, logger.New("info", "telemetry-service"))
+	discovery.publisher = publisher
+	discovery.templates = templates
+	discovery.devices = deviceDirectory{
+		{DeviceID: "dev-1", Name: "North greenhouse", BoardType: "esp32:esp32:esp32", TemplateID: "greenhouse", TemplateVersion: "1.2.0"},
+		{DeviceID: "dev-2", TemplateID: "plain", TemplateVersion: "1.0.0"},
+	}
+	return discovery, publisher, templates
+}
+
+func TestHomeAssistantDiscovery_Publish(t *testing.T) {
+	discovery, publisher, _ := newHomeAssistantDiscovery(t)
+	ctx := context.Background()
+
+	err := discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-1", Metrics: map[string]interface{}{
+		"temperature": 21.5, "door_open": true, "rssi": -60.0,
+	}})
+	require.NoError(t, err)
+
+	var sensor map[string]interface{}
+	require.Contains(t, publisher.messages, "homeassistant/sensor/athena_dev-1/temperature/config")
+	require.NoError(t, json.Unmarshal([]byte(publisher.messages["homeassistant/sensor/athena_dev-1/temperature/config"]), &sensor))
+	assert.Equal(t, "temperature", sensor["name"])
+	assert.Equal(t, "athena_dev-1_temperature", sensor["unique_id"])
+	assert.Equal(t, "athena/dev-1/temperature", sensor["state_topic"])
+	assert.Equal(t, "°C", sensor["unit_of_measurement"])
+	assert.Equal(t, "measurement", sensor["state_class"])
+	deviceInfo := sensor["device"].(map[string]interface{})
+	assert.Equal(t, "North greenhouse", deviceInfo["name"])
+	assert.Equal(t, "Greenhouse Monitor", deviceInfo["model"])
+	assert.Equal(t, "1.2.0", deviceInfo["sw_version"])
+
+	var binarySensor map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(publisher.messages["homeassistant/binary_sensor/athena_dev-1/door_open/config"]), &binarySensor))
+	assert.Equal(t, "Door", binarySensor["name"])
+	assert.NotContains(t, binarySensor, "state_class")
+
+	// Readings of schema metrics go to their state topics; others are not
+	// announced
+	assert.Equal(t, "21.5", publisher.messages["athena/dev-1/temperature"])
+	assert.Equal(t, "ON", publisher.messages["athena/dev-1/door_open"])
+	assert.NotContains(t, publisher.messages, "athena/dev-1/rssi")
+
+	// Later readings only update state
+	published := publisher.count
+	require.NoError(t, discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-1", Metrics: map[string]interface{}{"temperature": 22.0}}))
+	assert.Equal(t, published+1, publisher.count)
+	assert.Equal(t, "22", publisher.messages["athena/dev-1/temperature"])
+
+	// Home Assistant coming back online has devices announced again
+	require.NoError(t, discovery.handleStatus("homeassistant/status", []byte("online")))
+	require.NoError(t, discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-1", Metrics: map[string]interface{}{"temperature": 22.5}}))
+	assert.Equal(t, published+4, publisher.count)
+}
+
+func TestHomeAssistantDiscovery_RemovesDroppedMetrics(t *testing.T) {
+	discovery, publisher, templates := newHomeAssistantDiscovery(t)
+	ctx := context.Background()
+	now := time.Now()
+	discovery.now = func() time.Time { return now }
+
+	require.NoError(t, discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-1", Metrics: map[string]interface{}{"temperature": 20.0}}))
+
+	tmpl, err := templates.GetTemplate(ctx, "greenhouse", "1.2.0")
+	require.NoError(t, err)
+	tmpl.Telemetry = tmpl.Telemetry[:1]
+	require.NoError(t, templates.UpdateTemplate(ctx, tmpl))
+
+	// The template change is picked up once the announcement is refreshed
+	now = now.Add(homeAssistantRefresh)
+	require.NoError(t, discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-1", Metrics: map[string]interface{}{"temperature": 20.0}}))
+	assert.Equal(t, "", publisher.messages["homeassistant/binary_sensor/athena_dev-1/door_open/config"])
+	assert.NotEqual(t, "", publisher.messages["homeassistant/sensor/athena_dev-1/temperature/config"])
+}
+
+func TestHomeAssistantDiscovery_DevicesWithoutSchema(t *testing.T) {
+	discovery, publisher, _ := newHomeAssistantDiscovery(t)
+	ctx := context.Background()
+
+	// Unknown devices have nothing to announce
+	require.NoError(t, discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-9", Metrics: map[string]interface{}{"temperature": 20.0}}))
+	assert.Empty(t, publisher.messages)
+
+	// A missing template is an error, so the device is looked up again
+	err := discovery.Publish(ctx, &TelemetryData{DeviceID: "dev-2", Metrics: map[string]interface{}{"temperature": 20.0}})
+	assert.Error(t, err)
+	assert.Empty(t, publisher.messages)
+}
+
+func TestHomeAssistantState(t *testing.T) {
+	assert.Equal(t, "ON", homeAssistantState(template.MetricTypeBoolean, true))
+	assert.Equal(t, "OFF", homeAssistantState(template.MetricTypeBoolean, 0.0))
+	assert.Equal(t, "ON", homeAssistantState(template.MetricTypeBoolean, "true"))
+	assert.Equal(t, "1013.25", homeAssistantState(template.MetricTypeNumber, 1013.25))
+	assert.Equal(t, "42", homeAssistantState(template.MetricTypeNumber, 42))
+	assert.Equal(t, "idle", homeAssistantState(template.MetricTypeString, "idle"))
+}
+
+func TestService_HomeAssistantDiscovery(t *testing.T) {
+	cfg := &config.Config{Telemetry: config.TelemetryConfig{HomeAssistant: config.HomeAssistantConfig{Enabled: true}}}
+	service, err := NewService(cfg, logger.New("info", "telemetry-service"), &MockRepository{})
+	require.NoError(t, err)
+	assert.Nil(t, service.homeAssistant)
+
+	// Stored readings are queued for Home Assistant
+	discovery, _, _ := newHomeAssistantDiscovery(t)
+	repository := &homeAssistantRepository{Repository: &MockRepository{}, discovery: discovery}
+	now := time.Now()
+	require.NoError(t, repository.StoreTelemetryBatch(context.Background(), []*TelemetryData{
+		{DeviceID: "dev-1", Timestamp: now, Metrics: map[string]interface{}{"temperature": 21.0}},
+		{DeviceID: "dev-1", Timestamp: now.Add(-time.Minute), Metrics: map[string]interface{}{"temperature": 19.0}},
+	}))
+	require.Len(t, discovery.queue, 1)
+	assert.Equal(t, 21.0, (<-discovery.queue).Metrics["temperature"])
+}
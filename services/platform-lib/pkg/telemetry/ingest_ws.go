@@ -0,0 +1,363 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/cbor"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsIngestMaxFrame bounds the size of a single ingest frame
+	wsIngestMaxFrame = 1 << 20
+
+	// wsIngestBatchSize is the number of buffered points that triggers a
+	// write to the repository
+	wsIngestBatchSize = 500
+
+	// wsIngestFlushInterval bounds how long points wait for a batch to fill
+	wsIngestFlushInterval = 250 * time.Millisecond
+
+	// wsIngestPongWait is how long a silent connection is kept open
+	wsIngestPongWait = 60 * time.Second
+
+	// wsIngestPingInterval must be shorter than wsIngestPongWait
+	wsIngestPingInterval = wsIngestPongWait * 9 / 10
+
+	wsIngestWriteWait = 10 * time.Second
+)
+
+// ErrDeviceUnauthorized is returned when a device presents no valid token
+var ErrDeviceUnauthorized = errors.New("device unauthorized")
+
+// errInvalidIngestFrame is reported back to the device for frames that
+// cannot be ingested
+var errInvalidIngestFrame = errors.New("invalid ingest frame")
+
+var ingestUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 1024,
+	// Devices are not browsers, and authenticate with their own token
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// authenticateIngest checks that a device presents one of its tokens,
+// either as a bearer token or, for clients that cannot set headers on a
+// WebSocket handshake, in the token query parameter. Failures are not told
+// apart, so callers cannot probe for device IDs.
+func (s *Service) authenticateIngest(ctx context.Context, deviceID string, r *http.Request) error {
+	if s.devices == nil {
+		return ErrDeviceDirectoryUnavailable
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return ErrDeviceUnauthorized
+	}
+	dev, err := s.devices.GetDevice(ctx, deviceID)
+	if err != nil {
+		s.logger.Warn("Failed to look up ingesting device", "device_id", deviceID, "error", err)
+		return ErrDeviceUnauthorized
+	}
+	if !dev.VerifyToken(token, time.Now()) {
+		return ErrDeviceUnauthorized
+	}
+	return nil
+}
+
+// ingestWebSocketHandler accepts a stream of telemetry from one device.
+//
+// Each binary message is a CBOR map with a sequence number and its points:
+//
+//	{"seq": 42, "points": [{"ts": 1700000000000, "m": {"accel_x": 0.12}, "tags": {"axis": "x"}}]}
+//
+// A frame with a single point may carry "ts", "m" and "tags" at the top
+// level instead. Timestamps are Unix milliseconds and default to the time
+// of the write. Sequence numbers start at 1 and increase per connection.
+//
+// Points are buffered and written to the repository in batches. After each
+// write the server sends {"ack": seq}, acknowledging every frame up to seq.
+// Frames that cannot be ingested are answered with {"error": ..., "seq": seq}
+// and count as handled. When a write fails the server answers
+// {"error": ..., "seq": seq} for the last frame of the batch and accepts the
+// unacknowledged frames again, so devices retransmit everything after their
+// last ack. Delivery is at least once across reconnects.
+func (s *Service) ingestWebSocketHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	err := s.authenticateIngest(c.Request.Context(), deviceID, c.Request)
+	switch {
+	case errors.Is(err, ErrDeviceDirectoryUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := ingestUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.Error("Failed to upgrade ingest connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	s.logger.Info("Ingest stream opened", "device_id", deviceID)
+	session := &ingestSession{service: s, deviceID: deviceID, conn: conn}
+	session.run()
+	s.logger.Info("Ingest stream closed", "device_id", deviceID)
+}
+
+// ingestMessage is a message read from an ingest connection
+type ingestMessage struct {
+	messageType int
+	data        []byte
+}
+
+// ingestSession is the state of one ingest connection. Everything but
+// reading runs on the goroutine of run, which owns all writes.
+type ingestSession struct {
+	service  *Service
+	deviceID string
+	conn     *websocket.Conn
+
+	pending []*TelemetryData
+	// received is the highest sequence number handled, acked the highest
+	// acknowledged
+	received uint64
+	acked    uint64
+}
+
+func (is *ingestSession) run() {
+	is.conn.SetReadLimit(wsIngestMaxFrame)
+	_ = is.conn.SetReadDeadline(time.Now().Add(wsIngestPongWait))
+	is.conn.SetPongHandler(func(string) error {
+		return is.conn.SetReadDeadline(time.Now().Add(wsIngestPongWait))
+	})
+
+	messages := make(chan ingestMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(messages)
+		for {
+			messageType, data, err := is.conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					is.service.logger.Warn("Ingest stream failed", "device_id", is.deviceID, "error", err)
+				}
+				return
+			}
+			_ = is.conn.SetReadDeadline(time.Now().Add(wsIngestPongWait))
+			select {
+			case messages <- ingestMessage{messageType: messageType, data: data}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	flushes := time.NewTicker(wsIngestFlushInterval)
+	defer flushes.Stop()
+	pings := time.NewTicker(wsIngestPingInterval)
+	defer pings.Stop()
+
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				is.flush()
+				return
+			}
+			if err := is.handle(message); err != nil {
+				return
+			}
+			if len(is.pending) >= wsIngestBatchSize {
+				if err := is.flush(); err != nil {
+					return
+				}
+			}
+		case <-flushes.C:
+			if err := is.flush(); err != nil {
+				return
+			}
+		case <-pings.C:
+			if err := is.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsIngestWriteWait)); err != nil {
+				return
+			}
+		case <-is.service.ctx.Done():
+			is.flush()
+			_ = is.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(wsIngestWriteWait))
+			return
+		}
+	}
+}
+
+// handle buffers the points of a frame. Only failures to write to the
+// connection are returned.
+func (is *ingestSession) handle(message ingestMessage) error {
+	if message.messageType != websocket.BinaryMessage {
+		return is.send(map[string]interface{}{"error": "frames must be binary CBOR"})
+	}
+
+	seq, points, err := parseIngestFrame(message.data)
+	switch {
+	case seq == 0:
+		return is.send(map[string]interface{}{"error": err.Error()})
+	case seq <= is.acked:
+		// The ack was lost, so the device retransmits
+		return is.send(map[string]interface{}{"ack": is.acked})
+	case seq <= is.received:
+		// Already buffered, and acknowledged with the next write
+		return nil
+	}
+
+	is.received = seq
+	if err != nil {
+		return is.send(map[string]interface{}{"error": err.Error(), "seq": seq})
+	}
+	is.pending = append(is.pending, points...)
+	return nil
+}
+
+// flush writes the buffered points and acknowledges the frames handled
+// since the last ack. Only failures to write to the connection are
+// returned.
+func (is *ingestSession) flush() error {
+	if len(is.pending) > 0 {
+		batch := is.pending
+		is.pending = nil
+		if err := is.service.IngestTelemetryBatch(is.deviceID, batch); err != nil {
+			is.service.logger.Error("Failed to ingest telemetry stream", "device_id", is.deviceID, "error", err)
+			seq := is.received
+			is.received = is.acked
+			return is.send(map[string]interface{}{"error": "failed to store telemetry data", "seq": seq})
+		}
+	}
+
+	if is.received > is.acked {
+		is.acked = is.received
+		return is.send(map[string]interface{}{"ack": is.acked})
+	}
+	return nil
+}
+
+func (is *ingestSession) send(message map[string]interface{}) error {
+	data, err := cbor.Encode(message)
+	if err != nil {
+		return err
+	}
+	_ = is.conn.SetWriteDeadline(time.Now().Add(wsIngestWriteWait))
+	return is.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// parseIngestFrame decodes an ingest frame. The sequence number is returned
+// whenever it can be read, even if the points are invalid.
+func parseIngestFrame(data []byte) (uint64, []*TelemetryData, error) {
+	decoded, err := cbor.Decode(data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", errInvalidIngestFrame, err)
+	}
+	frame, ok := decoded.(map[string]interface{})
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: frame is not a map", errInvalidIngestFrame)
+	}
+	seq, ok := frame["seq"].(uint64)
+	if !ok || seq == 0 {
+		return 0, nil, fmt.Errorf("%w: seq must be a positive integer", errInvalidIngestFrame)
+	}
+
+	var entries []interface{}
+	if raw, ok := frame["points"]; ok {
+		entries, ok = raw.([]interface{})
+		if !ok || len(entries) == 0 {
+			return seq, nil, fmt.Errorf("%w: points must be a non-empty array", errInvalidIngestFrame)
+		}
+	} else {
+		entries = []interface{}{frame}
+	}
+
+	points := make([]*TelemetryData, 0, len(entries))
+	for i, entry := range entries {
+		point, err := parseIngestPoint(entry)
+		if err != nil {
+			return seq, nil, fmt.Errorf("%w: point %d: %v", errInvalidIngestFrame, i, err)
+		}
+		points = append(points, point)
+	}
+	return seq, points, nil
+}
+
+func parseIngestPoint(entry interface{}) (*TelemetryData, error) {
+	fields, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a map")
+	}
+
+	point := &TelemetryData{Metrics: make(map[string]interface{})}
+	if raw, ok := fields["ts"]; ok {
+		millis, ok := cborNumber(raw)
+		if !ok || !(millis >= 0 && millis < math.MaxInt64) {
+			return nil, fmt.Errorf("ts must be Unix milliseconds")
+		}
+		point.Timestamp = time.UnixMilli(int64(millis))
+	}
+
+	metrics, ok := fields["m"].(map[string]interface{})
+	if !ok || len(metrics) == 0 {
+		return nil, fmt.Errorf("m must be a non-empty map of metrics")
+	}
+	for name, value := range metrics {
+		// Numbers are stored as they would be after JSON ingest
+		if number, ok := cborNumber(value); ok {
+			value = number
+		}
+		switch value.(type) {
+		case float64, bool, string:
+			point.Metrics[name] = value
+		default:
+			return nil, fmt.Errorf("metric %s has unsupported type %T", name, value)
+		}
+	}
+
+	if raw, ok := fields["tags"]; ok {
+		tags, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tags must be a map")
+		}
+		point.Tags = make(map[string]string, len(tags))
+		for name, value := range tags {
+			text, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("tag %s is not text", name)
+			}
+			point.Tags[name] = text
+		}
+	}
+	return point, nil
+}
+
+// cborNumber converts a decoded CBOR integer or float to float64
+func cborNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
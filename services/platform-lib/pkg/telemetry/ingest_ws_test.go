@@ -0,0 +1,252 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/cbor"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRepository records batch writes and fails them on request
+type batchRepository struct {
+	MockRepository
+	mu      sync.Mutex
+	batches [][]*TelemetryData
+	fail    bool
+}
+
+func (r *batchRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		r.fail = false
+		return errors.New("datastore unavailable")
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func (r *batchRepository) points() []*TelemetryData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var points []*TelemetryData
+	for _, batch := range r.batches {
+		points = append(points, batch...)
+	}
+	return points
+}
+
+// newIngestService knows device accel-1 with token device-token, probe-1
+// behind it, probe-1a behind probe-1 and the unrelated other-1
+func newIngestService(t *testing.T) (*Service, *batchRepository) {
+	repository := &batchRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte("device-token"))
+	service.SetDeviceDirectory(deviceDirectory{{
+		DeviceID: "accel-1",
+		Credentials: map[string]*device.Credential{
+			"mqtt": {Type: device.CredentialTypeToken, Fingerprint: hex.EncodeToString(sum[:]), ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}, {
+		DeviceID: "probe-1",
+		ParentID: "accel-1",
+	}, {
+		DeviceID: "probe-1a",
+		ParentID: "probe-1",
+	}, {
+		DeviceID: "other-1",
+	}})
+	return service, repository
+}
+
+func newIngestServer(t *testing.T) (*httptest.Server, *batchRepository) {
+	gin.SetMode(gin.TestMode)
+	service, repository := newIngestService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, repository
+}
+
+func dialIngest(t *testing.T, server *httptest.Server, deviceID, token string) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/telemetry/ingest/" + deviceID + "/ws"
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+func sendFrame(t *testing.T, conn *websocket.Conn, frame map[string]interface{}) {
+	data, err := cbor.Encode(frame)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+}
+
+func readReply(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	messageType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, messageType)
+	decoded, err := cbor.Decode(data)
+	require.NoError(t, err)
+	return decoded.(map[string]interface{})
+}
+
+func samples(start int64, n int) []interface{} {
+	points := make([]interface{}, n)
+	for i := range points {
+		points[i] = map[string]interface{}{
+			"ts": start + int64(i)*10,
+			"m":  map[string]interface{}{"accel_x": 0.5 * float64(i), "count": i},
+		}
+	}
+	return points
+}
+
+func TestIngestWebSocket_Auth(t *testing.T) {
+	server, _ := newIngestServer(t)
+
+	_, resp, err := dialIngest(t, server, "accel-1", "")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, resp, err = dialIngest(t, server, "accel-1", "wrong-token")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, resp, err = dialIngest(t, server, "accel-2", "device-token")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The token may be passed in the query by clients that cannot set headers
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/telemetry/ingest/accel-1/ws?token=device-token"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestIngestWebSocket_BatchesAndAcks(t *testing.T) {
+	server, repository := newIngestServer(t)
+	conn, _, err := dialIngest(t, server, "accel-1", "device-token")
+	require.NoError(t, err)
+
+	sendFrame(t, conn, map[string]interface{}{"seq": 1, "points": samples(1700000000000, 3)})
+	sendFrame(t, conn, map[string]interface{}{"seq": 2, "ts": int64(1700000000030), "m": map[string]interface{}{"accel_x": 1.5}, "tags": map[string]interface{}{"axis": "x"}})
+
+	// Frames arriving within the flush interval are written together and
+	// acknowledged cumulatively
+	reply := readReply(t, conn)
+	if reply["ack"] == uint64(1) {
+		reply = readReply(t, conn)
+	}
+	assert.Equal(t, map[string]interface{}{"ack": uint64(2)}, reply)
+	points := repository.points()
+	require.Len(t, points, 4)
+	assert.Equal(t, "accel-1", points[0].DeviceID)
+	assert.Equal(t, time.UnixMilli(1700000000010), points[1].Timestamp)
+	assert.Equal(t, 0.5, points[1].Metrics["accel_x"])
+	assert.Equal(t, float64(1), points[1].Metrics["count"])
+	assert.Equal(t, "x", points[3].Tags["axis"])
+
+	// A retransmitted frame is acknowledged again but not stored twice
+	sendFrame(t, conn, map[string]interface{}{"seq": 2, "ts": int64(1700000000030), "m": map[string]interface{}{"accel_x": 1.5}})
+	assert.Equal(t, map[string]interface{}{"ack": uint64(2)}, readReply(t, conn))
+	assert.Len(t, repository.points(), 4)
+
+	// A full batch is written without waiting for the flush interval
+	sendFrame(t, conn, map[string]interface{}{"seq": 3, "points": samples(1700000001000, wsIngestBatchSize)})
+	assert.Equal(t, map[string]interface{}{"ack": uint64(3)}, readReply(t, conn))
+	assert.Len(t, repository.points(), 4+wsIngestBatchSize)
+}
+
+func TestIngestWebSocket_InvalidFrames(t *testing.T) {
+	server, repository := newIngestServer(t)
+	conn, _, err := dialIngest(t, server, "accel-1", "device-token")
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"seq":1}`)))
+	assert.Contains(t, readReply(t, conn)["error"], "binary")
+
+	sendFrame(t, conn, map[string]interface{}{"points": samples(0, 1)})
+	assert.Contains(t, readReply(t, conn)["error"], "seq")
+
+	sendFrame(t, conn, map[string]interface{}{"seq": 1, "m": map[string]interface{}{"accel_x": []interface{}{1}}})
+	reply := readReply(t, conn)
+	assert.Equal(t, uint64(1), reply["seq"])
+	assert.Contains(t, reply["error"], "unsupported type")
+
+	// Rejected frames count as handled, so later acks cover them
+	sendFrame(t, conn, map[string]interface{}{"seq": 2, "points": samples(1700000000000, 1)})
+	assert.Equal(t, map[string]interface{}{"ack": uint64(2)}, readReply(t, conn))
+	assert.Len(t, repository.points(), 1)
+}
+
+func TestIngestWebSocket_StorageFailure(t *testing.T) {
+	server, repository := newIngestServer(t)
+	repository.fail = true
+	conn, _, err := dialIngest(t, server, "accel-1", "device-token")
+	require.NoError(t, err)
+
+	sendFrame(t, conn, map[string]interface{}{"seq": 1, "points": samples(1700000000000, 2)})
+	reply := readReply(t, conn)
+	assert.Equal(t, uint64(1), reply["seq"])
+	assert.Contains(t, reply["error"], "store")
+	assert.Empty(t, repository.points())
+
+	// The unacknowledged frame is accepted again when retransmitted
+	sendFrame(t, conn, map[string]interface{}{"seq": 1, "points": samples(1700000000000, 2)})
+	assert.Equal(t, map[string]interface{}{"ack": uint64(1)}, readReply(t, conn))
+	assert.Len(t, repository.points(), 2)
+}
+
+func TestParseIngestFrame(t *testing.T) {
+	data, err := cbor.Encode(map[string]interface{}{"seq": 7, "points": []interface{}{
+		map[string]interface{}{"m": map[string]interface{}{"on": true, "mode": "fast"}},
+	}})
+	require.NoError(t, err)
+	seq, points, err := parseIngestFrame(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), seq)
+	require.Len(t, points, 1)
+	assert.True(t, points[0].Timestamp.IsZero())
+	assert.Equal(t, map[string]interface{}{"on": true, "mode": "fast"}, points[0].Metrics)
+
+	for _, frame := range []map[string]interface{}{
+		{"seq": 1, "points": []interface{}{}},
+		{"seq": 1, "ts": -5, "m": map[string]interface{}{"x": 1}},
+		{"seq": 1, "m": map[string]interface{}{}},
+		{"seq": 1, "m": map[string]interface{}{"x": 1}, "tags": map[string]interface{}{"axis": 1}},
+	} {
+		data, err := cbor.Encode(frame)
+		require.NoError(t, err)
+		seq, _, err := parseIngestFrame(data)
+		assert.Equal(t, uint64(1), seq)
+		assert.ErrorIs(t, err, errInvalidIngestFrame)
+	}
+
+	_, _, err = parseIngestFrame([]byte{0xff})
+	assert.ErrorIs(t, err, errInvalidIngestFrame)
+}
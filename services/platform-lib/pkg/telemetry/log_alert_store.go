@@ -0,0 +1,144 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// logAlertRuleRefreshInterval is how often rules changed through other
+// replicas are reloaded
+const logAlertRuleRefreshInterval = time.Minute
+
+// LogAlertRuleStore persists log alert rules, so they survive restarts and
+// are shared between replicas
+type LogAlertRuleStore interface {
+	SaveLogAlertRule(ctx context.Context, rule *LogAlertRule) error
+	ListLogAlertRules(ctx context.Context) ([]*LogAlertRule, error)
+	DeleteLogAlertRule(ctx context.Context, name string) error
+}
+
+// LogAlertRuleEntity represents a log alert rule in Datastore
+type LogAlertRuleEntity struct {
+	Name        string    `datastore:"name"`
+	Pattern     string    `datastore:"pattern,noindex"`
+	MinLevel    string    `datastore:"min_level,noindex"`
+	Tag         string    `datastore:"tag,noindex"`
+	Count       int       `datastore:"count,noindex"`
+	Window      string    `datastore:"window,noindex"`
+	Severity    string    `datastore:"severity,noindex"`
+	DeviceIDs   []string  `datastore:"device_ids,noindex"`
+	Description string    `datastore:"description,noindex"`
+	CreatedAt   time.Time `datastore:"created_at,noindex"`
+	UpdatedAt   time.Time `datastore:"updated_at,noindex"`
+}
+
+// ToEntity converts a LogAlertRule to a LogAlertRuleEntity
+func (r *LogAlertRule) ToEntity() *LogAlertRuleEntity {
+	return &LogAlertRuleEntity{
+		Name:        r.Name,
+		Pattern:     r.Pattern,
+		MinLevel:    r.MinLevel,
+		Tag:         r.Tag,
+		Count:       r.Count,
+		Window:      r.Window,
+		Severity:    r.Severity,
+		DeviceIDs:   r.DeviceIDs,
+		Description: r.Description,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// FromEntity converts a LogAlertRuleEntity to a LogAlertRule. The rule is
+// compiled when the registry loads it.
+func (re *LogAlertRuleEntity) FromEntity() *LogAlertRule {
+	return &LogAlertRule{
+		Name:        re.Name,
+		Pattern:     re.Pattern,
+		MinLevel:    re.MinLevel,
+		Tag:         re.Tag,
+		Count:       re.Count,
+		Window:      re.Window,
+		Severity:    re.Severity,
+		DeviceIDs:   re.DeviceIDs,
+		Description: re.Description,
+		CreatedAt:   re.CreatedAt,
+		UpdatedAt:   re.UpdatedAt,
+	}
+}
+
+// DatastoreLogAlertRuleStore keeps log alert rules in Datastore, keyed by
+// name
+type DatastoreLogAlertRuleStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreLogAlertRuleStore creates a log alert rule store on a
+// Datastore client
+func NewDatastoreLogAlertRuleStore(client *datastore.Client) *DatastoreLogAlertRuleStore {
+	return &DatastoreLogAlertRuleStore{client: client}
+}
+
+func (s *DatastoreLogAlertRuleStore) SaveLogAlertRule(ctx context.Context, rule *LogAlertRule) error {
+	if _, err := s.client.Put(ctx, datastore.NameKey("LogAlertRule", rule.Name, nil), rule.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save log alert rule in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastoreLogAlertRuleStore) ListLogAlertRules(ctx context.Context) ([]*LogAlertRule, error) {
+	var entities []*LogAlertRuleEntity
+	if _, err := s.client.GetAll(ctx, datastore.NewQuery("LogAlertRule"), &entities); err != nil {
+		return nil, fmt.Errorf("failed to list log alert rules from Datastore: %w", err)
+	}
+	rules := make([]*LogAlertRule, len(entities))
+	for i, entity := range entities {
+		rules[i] = entity.FromEntity()
+	}
+	return rules, nil
+}
+
+func (s *DatastoreLogAlertRuleStore) DeleteLogAlertRule(ctx context.Context, name string) error {
+	if err := s.client.Delete(ctx, datastore.NameKey("LogAlertRule", name, nil)); err != nil {
+		return fmt.Errorf("failed to delete log alert rule from Datastore: %w", err)
+	}
+	return nil
+}
+
+// SetLogAlertRuleStore persists log alert rules in store and loads those
+// already stored. Rules changed through other replicas are reloaded every
+// logAlertRuleRefreshInterval until the service stops.
+func (s *Service) SetLogAlertRuleStore(ctx context.Context, store LogAlertRuleStore) error {
+	s.logAlerts.mu.Lock()
+	s.logAlerts.store = store
+	s.logAlerts.mu.Unlock()
+	if err := s.logAlerts.load(ctx); err != nil {
+		return fmt.Errorf("failed to load log alert rules: %w", err)
+	}
+
+	go s.refreshLogAlertRules()
+	return nil
+}
+
+// refreshLogAlertRules reloads the stored rules until the service stops
+func (s *Service) refreshLogAlertRules() {
+	ticker := time.NewTicker(logAlertRuleRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		if err := s.logAlerts.load(ctx); err != nil {
+			s.logger.Error("Failed to reload log alert rules", "error", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,525 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLogAlertRuleNotFound = errors.New("log alert rule not found")
+	ErrLogAlertRuleExists   = errors.New("log alert rule already exists")
+
+	// errLogAlertRuleStorage wraps failures to persist rules, which are not
+	// the caller's fault
+	errLogAlertRuleStorage = errors.New("failed to persist log alert rule")
+)
+
+var logAlertRuleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// LogAlertRule raises an alert when a device logs more than Count lines
+// matching the rule within Window. Pattern, MinLevel and Tag filter the
+// lines that count; with Count 0 every matching line alerts. A rule alerts
+// at most once per Window for each device.
+type LogAlertRule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern,omitempty"`
+	MinLevel string `json:"min_level,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Count    int    `json:"count"`
+	Window   string `json:"window"`
+	Severity string `json:"severity"`
+	// DeviceIDs limits the rule to some devices; empty applies to all
+	DeviceIDs   []string  `json:"device_ids,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	pattern *regexp.Regexp
+	window  time.Duration
+}
+
+// LogAlertRuleRequest creates or replaces a log alert rule
+type LogAlertRuleRequest struct {
+	Name        string   `json:"name"`
+	Pattern     string   `json:"pattern,omitempty"`
+	MinLevel    string   `json:"min_level,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	Count       int      `json:"count"`
+	Window      string   `json:"window,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	DeviceIDs   []string `json:"device_ids,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+func (r *LogAlertRule) appliesTo(deviceID string) bool {
+	if len(r.DeviceIDs) == 0 {
+		return true
+	}
+	for _, id := range r.DeviceIDs {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LogAlertRule) matches(entry *DeviceLogEntry) bool {
+	if !LogLevelAtLeast(entry.Level, r.MinLevel) {
+		return false
+	}
+	if r.Tag != "" && entry.Tag != r.Tag {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(entry.Message)
+}
+
+// logAlertState tracks recent matches of a rule on one device
+type logAlertState struct {
+	matches   []time.Time
+	lastAlert time.Time
+}
+
+// LogAlertRuleRegistry holds log alert rules and their per-device state.
+// With a store, rule changes are persisted before they take effect.
+//
+// Match counts and the once-per-window limit are kept by each replica for
+// the lines it ingests, and are lost on restart. A device whose lines are
+// spread over several replicas needs up to Count lines on each before one
+// of them alerts, and may alert once per replica in a window. Route a
+// device's log ingest to one replica where that matters.
+type LogAlertRuleRegistry struct {
+	mu    sync.Mutex
+	rules map[string]*LogAlertRule
+	state map[string]map[string]*logAlertState // rule -> device -> state
+	store LogAlertRuleStore
+}
+
+// NewLogAlertRuleRegistry creates an empty registry
+func NewLogAlertRuleRegistry() *LogAlertRuleRegistry {
+	return &LogAlertRuleRegistry{
+		rules: make(map[string]*LogAlertRule),
+		state: make(map[string]map[string]*logAlertState),
+	}
+}
+
+// Create adds a log alert rule
+func (r *LogAlertRuleRegistry) Create(ctx context.Context, req *LogAlertRuleRequest) (*LogAlertRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[req.Name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrLogAlertRuleExists, req.Name)
+	}
+
+	rule, err := buildLogAlertRule(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.save(ctx, rule); err != nil {
+		return nil, err
+	}
+	r.rules[rule.Name] = rule
+
+	copied := *rule
+	return &copied, nil
+}
+
+// Update replaces a log alert rule and clears its match history
+func (r *LogAlertRuleRegistry) Update(ctx context.Context, req *LogAlertRuleRequest) (*LogAlertRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.rules[req.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLogAlertRuleNotFound, req.Name)
+	}
+
+	rule, err := buildLogAlertRule(req, existing.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = time.Now()
+	if err := r.save(ctx, rule); err != nil {
+		return nil, err
+	}
+	r.rules[rule.Name] = rule
+	delete(r.state, rule.Name)
+
+	copied := *rule
+	return &copied, nil
+}
+
+// Get returns a log alert rule by name
+func (r *LogAlertRuleRegistry) Get(name string) (*LogAlertRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule, ok := r.rules[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLogAlertRuleNotFound, name)
+	}
+	copied := *rule
+	return &copied, nil
+}
+
+// List returns all log alert rules sorted by name
+func (r *LogAlertRuleRegistry) List() []*LogAlertRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rules := make([]*LogAlertRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
+
+// Delete removes a log alert rule
+func (r *LogAlertRuleRegistry) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrLogAlertRuleNotFound, name)
+	}
+	if r.store != nil {
+		if err := r.store.DeleteLogAlertRule(ctx, name); err != nil {
+			return fmt.Errorf("%w: %v", errLogAlertRuleStorage, err)
+		}
+	}
+	delete(r.rules, name)
+	delete(r.state, name)
+	return nil
+}
+
+// save persists a rule. The caller holds the lock.
+func (r *LogAlertRuleRegistry) save(ctx context.Context, rule *LogAlertRule) error {
+	if r.store == nil {
+		return nil
+	}
+	if err := r.store.SaveLogAlertRule(ctx, rule); err != nil {
+		return fmt.Errorf("%w: %v", errLogAlertRuleStorage, err)
+	}
+	return nil
+}
+
+// load replaces the rules with those in the registry's store. Match
+// history is kept for rules that did not change.
+func (r *LogAlertRuleRegistry) load(ctx context.Context) error {
+	r.mu.Lock()
+	store := r.store
+	r.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+
+	stored, err := store.ListLogAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+	rules := make(map[string]*LogAlertRule, len(stored))
+	for _, saved := range stored {
+		rule, err := buildLogAlertRule(saved.request(), saved.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("stored log alert rule %s: %w", saved.Name, err)
+		}
+		rule.UpdatedAt = saved.UpdatedAt
+		rules[rule.Name] = rule
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range r.state {
+		current, ok := r.rules[name]
+		if rule := rules[name]; rule == nil || !ok || !rule.UpdatedAt.Equal(current.UpdatedAt) {
+			delete(r.state, name)
+		}
+	}
+	r.rules = rules
+	return nil
+}
+
+// request returns the request that defines the rule
+func (r *LogAlertRule) request() *LogAlertRuleRequest {
+	return &LogAlertRuleRequest{
+		Name:        r.Name,
+		Pattern:     r.Pattern,
+		MinLevel:    r.MinLevel,
+		Tag:         r.Tag,
+		Count:       r.Count,
+		Window:      r.Window,
+		Severity:    r.Severity,
+		DeviceIDs:   r.DeviceIDs,
+		Description: r.Description,
+	}
+}
+
+func buildLogAlertRule(req *LogAlertRuleRequest, createdAt time.Time) (*LogAlertRule, error) {
+	if !logAlertRuleNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("invalid rule name %q: use up to 64 letters, digits, '.', '_' or '-'", req.Name)
+	}
+	if req.Pattern == "" && req.MinLevel == "" && req.Tag == "" {
+		return nil, errors.New("a rule needs a pattern, min_level or tag")
+	}
+	if req.Count < 0 {
+		return nil, errors.New("count must not be negative")
+	}
+
+	rule := &LogAlertRule{
+		Name:        req.Name,
+		Pattern:     req.Pattern,
+		Tag:         req.Tag,
+		Count:       req.Count,
+		Window:      req.Window,
+		Severity:    req.Severity,
+		DeviceIDs:   req.DeviceIDs,
+		Description: req.Description,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+
+	if req.Pattern != "" {
+		pattern, err := regexp.Compile(req.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		rule.pattern = pattern
+	}
+	if req.MinLevel != "" {
+		level, err := ParseLogLevel(req.MinLevel)
+		if err != nil {
+			return nil, err
+		}
+		rule.MinLevel = level
+	}
+
+	if rule.Window == "" {
+		rule.Window = "5m"
+	}
+	window, err := time.ParseDuration(rule.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid window %q: use a positive duration such as 5m", req.Window)
+	}
+	rule.window = window
+
+	if rule.Severity == "" {
+		rule.Severity = "warning"
+	}
+	if !thresholdSeverities[rule.Severity] {
+		return nil, fmt.Errorf("unsupported severity %q", rule.Severity)
+	}
+
+	return rule, nil
+}
+
+// Evaluate counts the device's new log lines against every rule and returns
+// an alert for each rule whose condition is now met
+func (r *LogAlertRuleRegistry) Evaluate(deviceID string, entries []*DeviceLogEntry, now time.Time) []*Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var alerts []*Alert
+	for _, rule := range r.rules {
+		if !rule.appliesTo(deviceID) {
+			continue
+		}
+
+		var matched []*DeviceLogEntry
+		for _, entry := range entries {
+			if rule.matches(entry) {
+				matched = append(matched, entry)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if r.state[rule.Name] == nil {
+			r.state[rule.Name] = make(map[string]*logAlertState)
+		}
+		state := r.state[rule.Name][deviceID]
+		if state == nil {
+			state = &logAlertState{}
+			r.state[rule.Name][deviceID] = state
+		}
+
+		for _, entry := range matched {
+			state.matches = append(state.matches, entry.Timestamp)
+		}
+		sort.Slice(state.matches, func(i, j int) bool {
+			return state.matches[i].Before(state.matches[j])
+		})
+
+		// Only matches within one window of the newest line count
+		cutoff := state.matches[len(state.matches)-1].Add(-rule.window)
+		first := sort.Search(len(state.matches), func(i int) bool {
+			return state.matches[i].After(cutoff)
+		})
+		state.matches = state.matches[first:]
+		if len(state.matches) > rule.Count+1 {
+			state.matches = state.matches[len(state.matches)-rule.Count-1:]
+		}
+
+		count := len(state.matches)
+		if count <= rule.Count || now.Sub(state.lastAlert) < rule.window {
+			continue
+		}
+		state.lastAlert = now
+		state.matches = nil
+
+		alerts = append(alerts, logAlert(rule, deviceID, count, matched[len(matched)-1], now))
+	}
+	return alerts
+}
+
+func logAlert(rule *LogAlertRule, deviceID string, count int, last *DeviceLogEntry, now time.Time) *Alert {
+	var lines strings.Builder
+	if rule.MinLevel != "" {
+		lines.WriteString(strings.ToUpper(rule.MinLevel) + " ")
+	}
+	lines.WriteString("log lines")
+	if rule.Pattern != "" {
+		lines.WriteString(fmt.Sprintf(" matching %q", rule.Pattern))
+	}
+	if rule.Tag != "" {
+		lines.WriteString(fmt.Sprintf(" tagged %s", rule.Tag))
+	}
+
+	message := fmt.Sprintf("Device %s logged %s: %s", deviceID, lines.String(), last.Message)
+	if rule.Count > 0 {
+		message = fmt.Sprintf("Device %s logged more than %d %s in %s; last: %s", deviceID, rule.Count, lines.String(), rule.Window, last.Message)
+	}
+
+	return &Alert{
+		AlertID:        uuid.New().String(),
+		DeviceID:       deviceID,
+		ThresholdID:    rule.Name,
+		MetricName:     "logs",
+		CurrentValue:   float64(count),
+		ThresholdValue: float64(rule.Count),
+		Severity:       rule.Severity,
+		Message:        message,
+		TriggeredAt:    now,
+		Status:         "active",
+		Metadata: map[string]interface{}{
+			"source":    "log_rule",
+			"rule":      rule.Name,
+			"window":    rule.Window,
+			"last_line": last.Message,
+			"last_tag":  last.Tag,
+		},
+	}
+}
+
+// evaluateLogAlerts raises alerts for log rules triggered by newly ingested
+// lines. Failing to record an alert does not fail ingest.
+func (s *Service) evaluateLogAlerts(ctx context.Context, deviceID string, entries []*DeviceLogEntry) {
+	for _, alert := range s.logAlerts.Evaluate(deviceID, entries, time.Now()) {
+		if err := s.repository.CreateAlert(ctx, alert); err != nil {
+			s.logger.Error("Failed to store log alert", "threshold_id", alert.ThresholdID, "device_id", deviceID, "error", err)
+		}
+		if s.alertNotifier != nil {
+			s.alertNotifier.SendAlert(alert)
+		}
+		s.logger.Warn("Log alert triggered", "device_id", deviceID, "message", alert.Message)
+	}
+}
+
+func (s *Service) listLogAlertRulesHandler(c *gin.Context) {
+	rules := s.logAlerts.List()
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+func (s *Service) createLogAlertRuleHandler(c *gin.Context) {
+	var req LogAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log alert rule", "details": err.Error()})
+		return
+	}
+
+	rule, err := s.logAlerts.Create(c.Request.Context(), &req)
+	if errors.Is(err, ErrLogAlertRuleExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errLogAlertRuleStorage) {
+		s.logger.Error("Failed to store log alert rule", "rule", req.Name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store log alert rule"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Log alert rule defined", "rule", rule.Name)
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (s *Service) getLogAlertRuleHandler(c *gin.Context) {
+	rule, err := s.logAlerts.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Service) updateLogAlertRuleHandler(c *gin.Context) {
+	var req LogAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log alert rule", "details": err.Error()})
+		return
+	}
+	req.Name = c.Param("name")
+
+	rule, err := s.logAlerts.Update(c.Request.Context(), &req)
+	if errors.Is(err, ErrLogAlertRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errLogAlertRuleStorage) {
+		s.logger.Error("Failed to store log alert rule", "rule", req.Name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store log alert rule"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Service) deleteLogAlertRuleHandler(c *gin.Context) {
+	err := s.logAlerts.Delete(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, errLogAlertRuleStorage) {
+		s.logger.Error("Failed to delete log alert rule", "rule", c.Param("name"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete log alert rule"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Log alert rule deleted successfully"})
+}
@@ -0,0 +1,206 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func errorLines(start time.Time, n int, step time.Duration) []*DeviceLogEntry {
+	entries := make([]*DeviceLogEntry, n)
+	for i := range entries {
+		entries[i] = &DeviceLogEntry{DeviceID: "dev-1", Timestamp: start.Add(time.Duration(i) * step), Level: LogLevelError, Tag: "i2c", Message: "bus timeout"}
+	}
+	return entries
+}
+
+func TestLogAlertRuleRegistry_Validation(t *testing.T) {
+	registry := NewLogAlertRuleRegistry()
+
+	rule, err := registry.Create(context.Background(), &LogAlertRuleRequest{Name: "errors", MinLevel: "E", Count: 10})
+	require.NoError(t, err)
+	assert.Equal(t, LogLevelError, rule.MinLevel)
+	assert.Equal(t, "5m", rule.Window)
+	assert.Equal(t, "warning", rule.Severity)
+
+	_, err = registry.Create(context.Background(), &LogAlertRuleRequest{Name: "errors", MinLevel: "error"})
+	assert.ErrorIs(t, err, ErrLogAlertRuleExists)
+
+	for _, req := range []*LogAlertRuleRequest{
+		{Name: "bad name!", Pattern: "x"},
+		{Name: "no-filter"},
+		{Name: "regex", Pattern: "("},
+		{Name: "level", MinLevel: "loud"},
+		{Name: "window", Pattern: "x", Window: "-1m"},
+		{Name: "severity", Pattern: "x", Severity: "page"},
+		{Name: "count", Pattern: "x", Count: -1},
+	} {
+		_, err := registry.Create(context.Background(), req)
+		assert.Error(t, err, req.Name)
+	}
+	assert.Len(t, registry.List(), 1)
+
+	_, err = registry.Update(context.Background(), &LogAlertRuleRequest{Name: "missing", Pattern: "x"})
+	assert.ErrorIs(t, err, ErrLogAlertRuleNotFound)
+	assert.NoError(t, registry.Delete(context.Background(), "errors"))
+	assert.ErrorIs(t, registry.Delete(context.Background(), "errors"), ErrLogAlertRuleNotFound)
+}
+
+func TestLogAlertRuleRegistry_EvaluateRate(t *testing.T) {
+	registry := NewLogAlertRuleRegistry()
+	_, err := registry.Create(context.Background(), &LogAlertRuleRequest{Name: "i2c-errors", MinLevel: "error", Count: 10, Window: "5m", Severity: "critical"})
+	require.NoError(t, err)
+
+	now := time.Now()
+	start := now.Add(-10 * time.Minute)
+
+	// Eleven errors spread over ten minutes never exceed ten in five minutes
+	assert.Empty(t, registry.Evaluate("dev-1", errorLines(start, 11, time.Minute), now))
+
+	// Eleven errors within a minute do
+	alerts := registry.Evaluate("dev-1", errorLines(now.Add(-time.Minute), 11, time.Second), now)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "dev-1", alerts[0].DeviceID)
+	assert.Equal(t, "i2c-errors", alerts[0].ThresholdID)
+	assert.Equal(t, "critical", alerts[0].Severity)
+	assert.Equal(t, float64(11), alerts[0].CurrentValue)
+	assert.Contains(t, alerts[0].Message, "more than 10 ERROR log lines in 5m")
+	assert.Equal(t, "log_rule", alerts[0].Metadata["source"])
+
+	// The rule stays quiet for a window after alerting
+	assert.Empty(t, registry.Evaluate("dev-1", errorLines(now, 20, time.Millisecond), now.Add(time.Minute)))
+	assert.Len(t, registry.Evaluate("dev-1", errorLines(now.Add(6*time.Minute), 11, time.Millisecond), now.Add(6*time.Minute)), 1)
+}
+
+func TestLogAlertRuleRegistry_EvaluateFilters(t *testing.T) {
+	registry := NewLogAlertRuleRegistry()
+	_, err := registry.Create(context.Background(), &LogAlertRuleRequest{Name: "oom", Pattern: `(?i)out of memory`, Tag: "heap", DeviceIDs: []string{"dev-1"}})
+	require.NoError(t, err)
+
+	now := time.Now()
+	line := func(deviceID, tag, message string) []*DeviceLogEntry {
+		return []*DeviceLogEntry{{DeviceID: deviceID, Timestamp: now, Level: LogLevelInfo, Tag: tag, Message: message}}
+	}
+
+	assert.Empty(t, registry.Evaluate("dev-1", line("dev-1", "heap", "free 2048 bytes"), now))
+	assert.Empty(t, registry.Evaluate("dev-1", line("dev-1", "wifi", "Out of memory"), now))
+	assert.Empty(t, registry.Evaluate("dev-2", line("dev-2", "heap", "Out of memory"), now))
+
+	alerts := registry.Evaluate("dev-1", line("dev-1", "heap", "Out of memory allocating 4096"), now)
+	require.Len(t, alerts, 1, "a count of zero alerts on the first matching line")
+	assert.Contains(t, alerts[0].Message, "Out of memory allocating 4096")
+}
+
+// alertRepository records alerts and device logs in memory
+type alertRepository struct {
+	logRepository
+	alerts []*Alert
+}
+
+func (r *alertRepository) CreateAlert(ctx context.Context, alert *Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestService_LogAlertRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repository := &alertRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := send(http.MethodPost, "/api/v1/telemetry/log-alert-rules", `{"name":"panics","pattern":"Guru Meditation","severity":"critical"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, send(http.MethodPost, "/api/v1/telemetry/log-alert-rules", `{"name":"panics","pattern":"x"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/telemetry/log-alert-rules", `{"name":"bad","pattern":"("}`).Code)
+
+	w = send(http.MethodPut, "/api/v1/telemetry/log-alert-rules/panics", `{"pattern":"Guru Meditation Error","severity":"critical"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"pattern":"Guru Meditation Error"`)
+
+	w = send(http.MethodPost, "/api/v1/telemetry/devices/dev-1/logs", `{"logs":[{"level":"E","message":"Guru Meditation Error: Core 0 panic'ed"}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, repository.alerts, 1)
+	assert.Equal(t, "panics", repository.alerts[0].ThresholdID)
+
+	w = send(http.MethodGet, "/api/v1/telemetry/log-alert-rules", "")
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "/api/v1/telemetry/log-alert-rules/panics", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/api/v1/telemetry/log-alert-rules/panics", "").Code)
+}
+
+// mapLogAlertRuleStore keeps log alert rules in a map
+type mapLogAlertRuleStore struct {
+	rules map[string]LogAlertRuleEntity
+	err   error
+}
+
+func (s *mapLogAlertRuleStore) SaveLogAlertRule(ctx context.Context, rule *LogAlertRule) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.rules[rule.Name] = *rule.ToEntity()
+	return nil
+}
+
+func (s *mapLogAlertRuleStore) ListLogAlertRules(ctx context.Context) ([]*LogAlertRule, error) {
+	var rules []*LogAlertRule
+	for _, entity := range s.rules {
+		rules = append(rules, entity.FromEntity())
+	}
+	return rules, nil
+}
+
+func (s *mapLogAlertRuleStore) DeleteLogAlertRule(ctx context.Context, name string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.rules, name)
+	return nil
+}
+
+func TestService_LogAlertRuleStore(t *testing.T) {
+	ctx := context.Background()
+	store := &mapLogAlertRuleStore{rules: make(map[string]LogAlertRuleEntity)}
+
+	first, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &MockRepository{})
+	require.NoError(t, err)
+	require.NoError(t, first.SetLogAlertRuleStore(ctx, store))
+	_, err = first.logAlerts.Create(ctx, &LogAlertRuleRequest{Name: "i2c-errors", MinLevel: "error", Count: 10, Window: "5m"})
+	require.NoError(t, err)
+
+	// Rules survive into another replica, ready to evaluate
+	second, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &MockRepository{})
+	require.NoError(t, err)
+	require.NoError(t, second.SetLogAlertRuleStore(ctx, store))
+	now := time.Now()
+	assert.Empty(t, second.logAlerts.Evaluate("dev-1", errorLines(now.Add(-time.Minute), 6, time.Second), now))
+
+	// Reloading an unchanged rule keeps its match history
+	require.NoError(t, second.logAlerts.load(ctx))
+	assert.Len(t, second.logAlerts.Evaluate("dev-1", errorLines(now.Add(-30*time.Second), 5, time.Second), now), 1)
+
+	// A rule that cannot be stored does not take effect
+	store.err = assert.AnError
+	_, err = first.logAlerts.Create(ctx, &LogAlertRuleRequest{Name: "oom", Pattern: "out of memory"})
+	assert.ErrorIs(t, err, errLogAlertRuleStorage)
+	_, err = first.logAlerts.Get("oom")
+	assert.ErrorIs(t, err, ErrLogAlertRuleNotFound)
+	assert.ErrorIs(t, first.logAlerts.Delete(ctx, "i2c-errors"), errLogAlertRuleStorage)
+}
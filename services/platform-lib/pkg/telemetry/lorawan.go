@@ -0,0 +1,486 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoRaWANNetwork is a LoRaWAN network server that delivers uplinks
+type LoRaWANNetwork string
+
+const (
+	// LoRaWANNetworkTTN is The Things Network / The Things Stack v3
+	LoRaWANNetworkTTN LoRaWANNetwork = "ttn"
+	// LoRaWANNetworkChirpStack is ChirpStack v4's HTTP integration
+	LoRaWANNetworkChirpStack LoRaWANNetwork = "chirpstack"
+)
+
+var (
+	ErrPayloadDecoderNotFound = errors.New("payload decoder not found")
+	// ErrLoRaWANNetworkUnsupported is returned for unknown network servers
+	ErrLoRaWANNetworkUnsupported = errors.New("unsupported LoRaWAN network")
+	// ErrInvalidUplink is returned for malformed webhook bodies
+	ErrInvalidUplink = errors.New("invalid uplink")
+	// ErrUplinkDecode is returned when an uplink cannot be turned into metrics
+	ErrUplinkDecode = errors.New("failed to decode uplink")
+	// ErrUplinkDevice is returned when an uplink's device is not registered
+	ErrUplinkDevice = errors.New("unknown uplink device")
+
+	// errPayloadDecoderStorage wraps failures to persist decoders, which are
+	// not the caller's fault
+	errPayloadDecoderStorage = errors.New("failed to persist payload decoder")
+)
+
+// chirpStackDeviceTag is the ChirpStack device tag that names the Athena
+// device when it differs from the ChirpStack device name
+const chirpStackDeviceTag = "athena_device_id"
+
+// PayloadDecoder turns the raw bytes of a template's uplinks into metrics.
+// Each field is a payload expression such as "s16(0) / 100". Network
+// servers run JavaScript payload formatters themselves; UseNetworkDecoded
+// keeps the values they decoded, and templates without a decoder rely on
+// them entirely.
+type PayloadDecoder struct {
+	TemplateID string            `json:"template_id"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	// Ports limits the fields to uplinks on these FPorts; empty decodes all
+	Ports             []int     `json:"ports,omitempty"`
+	UseNetworkDecoded bool      `json:"use_network_decoded"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	fields map[string]*Expression
+}
+
+// PayloadDecoderRequest creates or replaces a template's payload decoder
+type PayloadDecoderRequest struct {
+	Fields            map[string]string `json:"fields,omitempty"`
+	Ports             []int             `json:"ports,omitempty"`
+	UseNetworkDecoded bool              `json:"use_network_decoded"`
+}
+
+// decodesPort reports whether the decoder's fields apply to an FPort
+func (d *PayloadDecoder) decodesPort(port int) bool {
+	if len(d.Ports) == 0 {
+		return true
+	}
+	for _, p := range d.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode evaluates the decoder's fields for an uplink. The fport input is
+// available to every field.
+func (d *PayloadDecoder) Decode(payload []byte, port int) (map[string]interface{}, error) {
+	metrics := make(map[string]interface{}, len(d.fields))
+	if !d.decodesPort(port) {
+		return metrics, nil
+	}
+	values := map[string]float64{"fport": float64(port)}
+	for name, expr := range d.fields {
+		value, err := expr.EvaluatePayload(payload, values)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %s: %v", ErrUplinkDecode, name, err)
+		}
+		metrics[name] = value
+	}
+	return metrics, nil
+}
+
+// PayloadDecoderRegistry holds payload decoders by template ID. With a
+// store, changes are persisted before they take effect.
+type PayloadDecoderRegistry struct {
+	mu       sync.RWMutex
+	decoders map[string]*PayloadDecoder
+	store    PayloadDecoderStore
+}
+
+// NewPayloadDecoderRegistry creates an empty registry
+func NewPayloadDecoderRegistry() *PayloadDecoderRegistry {
+	return &PayloadDecoderRegistry{
+		decoders: make(map[string]*PayloadDecoder),
+	}
+}
+
+// Put creates or replaces the decoder for a template
+func (r *PayloadDecoderRegistry) Put(ctx context.Context, templateID string, req *PayloadDecoderRequest) (*PayloadDecoder, error) {
+	decoder, err := buildPayloadDecoder(templateID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	decoder.CreatedAt, decoder.UpdatedAt = now, now
+	if existing, ok := r.decoders[templateID]; ok {
+		decoder.CreatedAt = existing.CreatedAt
+	}
+	if r.store != nil {
+		if err := r.store.SavePayloadDecoder(ctx, decoder); err != nil {
+			return nil, fmt.Errorf("%w: %v", errPayloadDecoderStorage, err)
+		}
+	}
+	r.decoders[templateID] = decoder
+
+	copied := *decoder
+	return &copied, nil
+}
+
+// buildPayloadDecoder validates a request and compiles its fields
+func buildPayloadDecoder(templateID string, req *PayloadDecoderRequest) (*PayloadDecoder, error) {
+	if templateID == "" {
+		return nil, fmt.Errorf("template ID is required")
+	}
+	if len(req.Fields) == 0 && !req.UseNetworkDecoded {
+		return nil, fmt.Errorf("a decoder needs fields or use_network_decoded")
+	}
+	for _, port := range req.Ports {
+		if port < 1 || port > 223 {
+			return nil, fmt.Errorf("FPort %d is not an application port (1-223)", port)
+		}
+	}
+
+	decoder := &PayloadDecoder{
+		TemplateID:        templateID,
+		Fields:            req.Fields,
+		Ports:             req.Ports,
+		UseNetworkDecoded: req.UseNetworkDecoded,
+		fields:            make(map[string]*Expression, len(req.Fields)),
+	}
+	for name, source := range req.Fields {
+		if !derivedMetricNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid field name %q", name)
+		}
+		expr, err := CompilePayloadExpression(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, input := range expr.Inputs() {
+			if input != "fport" {
+				return nil, fmt.Errorf("field %s reads unknown input %s", name, input)
+			}
+		}
+		decoder.fields[name] = expr
+	}
+	return decoder, nil
+}
+
+// Get returns the decoder for a template
+func (r *PayloadDecoderRegistry) Get(templateID string) (*PayloadDecoder, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	decoder, ok := r.decoders[templateID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPayloadDecoderNotFound, templateID)
+	}
+	copied := *decoder
+	return &copied, nil
+}
+
+// List returns all decoders sorted by template ID
+func (r *PayloadDecoderRegistry) List() []*PayloadDecoder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	decoders := make([]*PayloadDecoder, 0, len(r.decoders))
+	for _, decoder := range r.decoders {
+		copied := *decoder
+		decoders = append(decoders, &copied)
+	}
+	sort.Slice(decoders, func(i, j int) bool {
+		return decoders[i].TemplateID < decoders[j].TemplateID
+	})
+	return decoders
+}
+
+// Delete removes a template's decoder
+func (r *PayloadDecoderRegistry) Delete(ctx context.Context, templateID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.decoders[templateID]; !ok {
+		return fmt.Errorf("%w: %s", ErrPayloadDecoderNotFound, templateID)
+	}
+	if r.store != nil {
+		if err := r.store.DeletePayloadDecoder(ctx, templateID); err != nil {
+			return fmt.Errorf("%w: %v", errPayloadDecoderStorage, err)
+		}
+	}
+	delete(r.decoders, templateID)
+	return nil
+}
+
+// load replaces the decoders with those in the registry's store
+func (r *PayloadDecoderRegistry) load(ctx context.Context) error {
+	r.mu.RLock()
+	store := r.store
+	r.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+
+	stored, err := store.ListPayloadDecoders(ctx)
+	if err != nil {
+		return err
+	}
+	decoders := make(map[string]*PayloadDecoder, len(stored))
+	for _, s := range stored {
+		decoder, err := buildPayloadDecoder(s.TemplateID, &PayloadDecoderRequest{
+			Fields:            s.Fields,
+			Ports:             s.Ports,
+			UseNetworkDecoded: s.UseNetworkDecoded,
+		})
+		if err != nil {
+			return fmt.Errorf("stored payload decoder %s: %w", s.TemplateID, err)
+		}
+		decoder.CreatedAt, decoder.UpdatedAt = s.CreatedAt, s.UpdatedAt
+		decoders[decoder.TemplateID] = decoder
+	}
+
+	r.mu.Lock()
+	r.decoders = decoders
+	r.mu.Unlock()
+	return nil
+}
+
+// lorawanGateway is the reception of an uplink by one gateway
+type lorawanGateway struct {
+	ID   string
+	RSSI float64
+	SNR  float64
+}
+
+// lorawanUplink is an uplink in a network-independent form
+type lorawanUplink struct {
+	DeviceID   string
+	DevEUI     string
+	Port       int
+	FrameCount uint32
+	Payload    []byte
+	// Decoded is what the network server's payload formatter produced
+	Decoded    json.RawMessage
+	ReceivedAt time.Time
+	Gateways   []lorawanGateway
+}
+
+// ttnUplink is the part of a The Things Stack uplink message Athena uses
+type ttnUplink struct {
+	EndDeviceIDs struct {
+		DeviceID string `json:"device_id"`
+		DevEUI   string `json:"dev_eui"`
+	} `json:"end_device_ids"`
+	ReceivedAt    time.Time `json:"received_at"`
+	UplinkMessage *struct {
+		FPort          int             `json:"f_port"`
+		FCnt           uint32          `json:"f_cnt"`
+		FRMPayload     []byte          `json:"frm_payload"`
+		DecodedPayload json.RawMessage `json:"decoded_payload"`
+		RxMetadata     []struct {
+			GatewayIDs struct {
+				GatewayID string `json:"gateway_id"`
+			} `json:"gateway_ids"`
+			RSSI float64 `json:"rssi"`
+			SNR  float64 `json:"snr"`
+		} `json:"rx_metadata"`
+	} `json:"uplink_message"`
+}
+
+// chirpStackUplink is the part of a ChirpStack v4 "up" event Athena uses
+type chirpStackUplink struct {
+	Time       time.Time `json:"time"`
+	DeviceInfo struct {
+		DeviceName string            `json:"deviceName"`
+		DevEUI     string            `json:"devEui"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"deviceInfo"`
+	FPort  int             `json:"fPort"`
+	FCnt   uint32          `json:"fCnt"`
+	Data   []byte          `json:"data"`
+	Object json.RawMessage `json:"object"`
+	RxInfo []struct {
+		GatewayID string  `json:"gatewayId"`
+		RSSI      float64 `json:"rssi"`
+		SNR       float64 `json:"snr"`
+	} `json:"rxInfo"`
+}
+
+// parseLoRaWANUplink reads a network server's uplink webhook body. It
+// returns nil for messages that carry no application payload, such as join
+// notifications or MAC-only uplinks on FPort 0.
+func parseLoRaWANUplink(network LoRaWANNetwork, body []byte) (*lorawanUplink, error) {
+	var uplink *lorawanUplink
+
+	switch network {
+	case LoRaWANNetworkTTN:
+		var msg ttnUplink
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return nil, fmt.Errorf("%w: TTN message: %v", ErrInvalidUplink, err)
+		}
+		if msg.UplinkMessage == nil {
+			return nil, nil
+		}
+		uplink = &lorawanUplink{
+			DeviceID:   msg.EndDeviceIDs.DeviceID,
+			DevEUI:     msg.EndDeviceIDs.DevEUI,
+			Port:       msg.UplinkMessage.FPort,
+			FrameCount: msg.UplinkMessage.FCnt,
+			Payload:    msg.UplinkMessage.FRMPayload,
+			Decoded:    msg.UplinkMessage.DecodedPayload,
+			ReceivedAt: msg.ReceivedAt,
+		}
+		for _, rx := range msg.UplinkMessage.RxMetadata {
+			uplink.Gateways = append(uplink.Gateways, lorawanGateway{ID: rx.GatewayIDs.GatewayID, RSSI: rx.RSSI, SNR: rx.SNR})
+		}
+
+	case LoRaWANNetworkChirpStack:
+		var msg chirpStackUplink
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return nil, fmt.Errorf("%w: ChirpStack event: %v", ErrInvalidUplink, err)
+		}
+		deviceID := msg.DeviceInfo.Tags[chirpStackDeviceTag]
+		if deviceID == "" {
+			deviceID = msg.DeviceInfo.DeviceName
+		}
+		uplink = &lorawanUplink{
+			DeviceID:   deviceID,
+			DevEUI:     msg.DeviceInfo.DevEUI,
+			Port:       msg.FPort,
+			FrameCount: msg.FCnt,
+			Payload:    msg.Data,
+			Decoded:    msg.Object,
+			ReceivedAt: msg.Time,
+		}
+		for _, rx := range msg.RxInfo {
+			uplink.Gateways = append(uplink.Gateways, lorawanGateway{ID: rx.GatewayID, RSSI: rx.RSSI, SNR: rx.SNR})
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrLoRaWANNetworkUnsupported, network)
+	}
+
+	if uplink.Port == 0 {
+		return nil, nil
+	}
+	if uplink.DeviceID == "" {
+		return nil, fmt.Errorf("%w: no device ID", ErrInvalidUplink)
+	}
+	if uplink.ReceivedAt.IsZero() {
+		uplink.ReceivedAt = time.Now()
+	}
+	return uplink, nil
+}
+
+// SetPayloadDecoder creates or replaces the payload decoder for a template
+func (s *Service) SetPayloadDecoder(ctx context.Context, templateID string, req *PayloadDecoderRequest) (*PayloadDecoder, error) {
+	return s.decoders.Put(ctx, templateID, req)
+}
+
+// IngestLoRaWANUplink decodes a network server's uplink webhook and ingests
+// it as telemetry for the device it names. Uplinks without an application
+// payload return nil telemetry. When a device directory is configured the
+// device must be registered, and its template selects the payload decoder.
+func (s *Service) IngestLoRaWANUplink(network LoRaWANNetwork, body []byte) (*TelemetryData, error) {
+	uplink, err := parseLoRaWANUplink(network, body)
+	if err != nil || uplink == nil {
+		return nil, err
+	}
+
+	var decoder *PayloadDecoder
+	if s.devices != nil {
+		ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+		dev, err := s.devices.GetDevice(ctx, uplink.DeviceID)
+		cancel()
+		if err != nil || dev == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUplinkDevice, uplink.DeviceID)
+		}
+		if dev.TemplateID != "" {
+			decoder, _ = s.decoders.Get(dev.TemplateID)
+		}
+	}
+
+	data, err := decodeLoRaWANUplink(uplink, decoder)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.IngestTelemetry(uplink.DeviceID, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeLoRaWANUplink builds telemetry from an uplink. Decoder fields are
+// evaluated first; values from the network server's formatter are used when
+// there is no decoder or it asks for them, without replacing decoded fields.
+// Radio metadata from the strongest gateway is recorded alongside.
+func decodeLoRaWANUplink(uplink *lorawanUplink, decoder *PayloadDecoder) (*TelemetryData, error) {
+	data := &TelemetryData{
+		DeviceID:  uplink.DeviceID,
+		Timestamp: uplink.ReceivedAt,
+		Metrics:   make(map[string]interface{}),
+		Tags:      make(map[string]string),
+	}
+
+	if decoder != nil {
+		metrics, err := decoder.Decode(uplink.Payload, uplink.Port)
+		if err != nil {
+			return nil, err
+		}
+		data.Metrics = metrics
+	}
+
+	if (decoder == nil || decoder.UseNetworkDecoded) && len(uplink.Decoded) > 0 && string(uplink.Decoded) != "null" {
+		decoded, err := decodeCloudTelemetry(uplink.DeviceID, uplink.Decoded, uplink.ReceivedAt)
+		if err != nil && len(data.Metrics) == 0 {
+			return nil, fmt.Errorf("%w: %v", ErrUplinkDecode, err)
+		}
+		if err == nil {
+			for name, value := range decoded.Metrics {
+				if _, exists := data.Metrics[name]; !exists {
+					data.Metrics[name] = value
+				}
+			}
+			for key, value := range decoded.Tags {
+				data.Tags[key] = value
+			}
+		}
+	}
+
+	if len(data.Metrics) == 0 {
+		if decoder == nil {
+			return nil, fmt.Errorf("%w: no payload decoder for %s and the network server sent no decoded payload", ErrUplinkDecode, uplink.DeviceID)
+		}
+		return nil, fmt.Errorf("%w: decoder for template %s produced no metrics on FPort %d", ErrUplinkDecode, decoder.TemplateID, uplink.Port)
+	}
+
+	data.Tags["lorawan_fport"] = strconv.Itoa(uplink.Port)
+	// The frame counter shows lost uplinks as gaps
+	data.Metrics["lorawan_fcnt"] = float64(uplink.FrameCount)
+	if uplink.DevEUI != "" {
+		data.Tags["lorawan_dev_eui"] = uplink.DevEUI
+	}
+	if len(uplink.Gateways) > 0 {
+		best := uplink.Gateways[0]
+		for _, gateway := range uplink.Gateways[1:] {
+			if gateway.RSSI > best.RSSI {
+				best = gateway
+			}
+		}
+		data.Tags["lorawan_gateway"] = best.ID
+		data.Metrics["lorawan_rssi"] = best.RSSI
+		data.Metrics["lorawan_snr"] = best.SNR
+		data.Metrics["lorawan_gateways"] = float64(len(uplink.Gateways))
+	}
+	return data, nil
+}
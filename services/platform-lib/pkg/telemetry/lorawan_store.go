@@ -0,0 +1,143 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// payloadDecoderRefreshInterval is how often decoders changed through other
+// replicas are reloaded
+const payloadDecoderRefreshInterval = time.Minute
+
+// PayloadDecoderStore persists payload decoders, so decoders set through
+// the API survive restarts and are shared between replicas
+type PayloadDecoderStore interface {
+	SavePayloadDecoder(ctx context.Context, decoder *PayloadDecoder) error
+	ListPayloadDecoders(ctx context.Context) ([]*PayloadDecoder, error)
+	DeletePayloadDecoder(ctx context.Context, templateID string) error
+}
+
+// PayloadDecoderEntity represents a payload decoder in Datastore. Fields
+// are recompiled when it is loaded.
+type PayloadDecoderEntity struct {
+	TemplateID        string    `datastore:"template_id"`
+	FieldNames        []string  `datastore:"field_names,noindex"`
+	FieldExpressions  []string  `datastore:"field_expressions,noindex"`
+	Ports             []int     `datastore:"ports,noindex"`
+	UseNetworkDecoded bool      `datastore:"use_network_decoded,noindex"`
+	CreatedAt         time.Time `datastore:"created_at,noindex"`
+	UpdatedAt         time.Time `datastore:"updated_at,noindex"`
+}
+
+// ToEntity converts a PayloadDecoder to a PayloadDecoderEntity
+func (d *PayloadDecoder) ToEntity() *PayloadDecoderEntity {
+	entity := &PayloadDecoderEntity{
+		TemplateID:        d.TemplateID,
+		Ports:             d.Ports,
+		UseNetworkDecoded: d.UseNetworkDecoded,
+		CreatedAt:         d.CreatedAt,
+		UpdatedAt:         d.UpdatedAt,
+	}
+	for name, expression := range d.Fields {
+		entity.FieldNames = append(entity.FieldNames, name)
+		entity.FieldExpressions = append(entity.FieldExpressions, expression)
+	}
+	return entity
+}
+
+// FromEntity converts a PayloadDecoderEntity to a PayloadDecoder
+func (de *PayloadDecoderEntity) FromEntity() *PayloadDecoder {
+	decoder := &PayloadDecoder{
+		TemplateID:        de.TemplateID,
+		Ports:             de.Ports,
+		UseNetworkDecoded: de.UseNetworkDecoded,
+		CreatedAt:         de.CreatedAt,
+		UpdatedAt:         de.UpdatedAt,
+	}
+	if len(de.FieldNames) > 0 {
+		decoder.Fields = make(map[string]string, len(de.FieldNames))
+		for i, name := range de.FieldNames {
+			if i < len(de.FieldExpressions) {
+				decoder.Fields[name] = de.FieldExpressions[i]
+			}
+		}
+	}
+	return decoder
+}
+
+// DatastorePayloadDecoderStore keeps payload decoders in Datastore, keyed
+// by template ID
+type DatastorePayloadDecoderStore struct {
+	client *datastore.Client
+}
+
+// NewDatastorePayloadDecoderStore creates a payload decoder store on a
+// Datastore client
+func NewDatastorePayloadDecoderStore(client *datastore.Client) *DatastorePayloadDecoderStore {
+	return &DatastorePayloadDecoderStore{client: client}
+}
+
+func (s *DatastorePayloadDecoderStore) SavePayloadDecoder(ctx context.Context, decoder *PayloadDecoder) error {
+	if _, err := s.client.Put(ctx, datastore.NameKey("PayloadDecoder", decoder.TemplateID, nil), decoder.ToEntity()); err != nil {
+		return fmt.Errorf("failed to save payload decoder in Datastore: %w", err)
+	}
+	return nil
+}
+
+func (s *DatastorePayloadDecoderStore) ListPayloadDecoders(ctx context.Context) ([]*PayloadDecoder, error) {
+	var entities []*PayloadDecoderEntity
+	if _, err := s.client.GetAll(ctx, datastore.NewQuery("PayloadDecoder"), &entities); err != nil {
+		return nil, fmt.Errorf("failed to list payload decoders from Datastore: %w", err)
+	}
+	decoders := make([]*PayloadDecoder, len(entities))
+	for i, entity := range entities {
+		decoders[i] = entity.FromEntity()
+	}
+	return decoders, nil
+}
+
+func (s *DatastorePayloadDecoderStore) DeletePayloadDecoder(ctx context.Context, templateID string) error {
+	if err := s.client.Delete(ctx, datastore.NameKey("PayloadDecoder", templateID, nil)); err != nil {
+		return fmt.Errorf("failed to delete payload decoder from Datastore: %w", err)
+	}
+	return nil
+}
+
+// SetPayloadDecoderStore persists payload decoders in store and loads those
+// already stored. Decoders changed through other replicas are reloaded
+// every payloadDecoderRefreshInterval until the service stops.
+func (s *Service) SetPayloadDecoderStore(ctx context.Context, store PayloadDecoderStore) error {
+	s.decoders.mu.Lock()
+	s.decoders.store = store
+	s.decoders.mu.Unlock()
+	if err := s.decoders.load(ctx); err != nil {
+		return fmt.Errorf("failed to load payload decoders: %w", err)
+	}
+
+	go s.refreshPayloadDecoders()
+	return nil
+}
+
+// refreshPayloadDecoders reloads the stored decoders until the service
+// stops
+func (s *Service) refreshPayloadDecoders() {
+	ticker := time.NewTicker(payloadDecoderRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		if err := s.decoders.load(ctx); err != nil {
+			s.logger.Error("Failed to reload payload decoders", "error", err)
+		}
+		cancel()
+	}
+}
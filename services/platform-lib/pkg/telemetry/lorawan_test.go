@@ -0,0 +1,318 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// soilProbePayload is temperature -1.25 °C, moisture 41.2 % and battery 3.3 V
+var soilProbePayload = []byte{0xFF, 0x83, 0x01, 0x9C, 0xA5}
+
+func ttnUplinkBody(deviceID string, port int, payload []byte, decoded string) string {
+	return fmt.Sprintf(`{
+		"end_device_ids": {"device_id": %q, "dev_eui": "70B3D57ED0000001", "application_ids": {"application_id": "athena"}},
+		"received_at": "2026-03-01T10:00:00.5Z",
+		"uplink_message": {
+			"f_port": %d, "f_cnt": 42, "frm_payload": %q,
+			"decoded_payload": %s,
+			"rx_metadata": [
+				{"gateway_ids": {"gateway_id": "gw-roof"}, "rssi": -97, "snr": 3.5},
+				{"gateway_ids": {"gateway_id": "gw-barn"}, "rssi": -81, "snr": 8.25}
+			]
+		}
+	}`, deviceID, port, base64.StdEncoding.EncodeToString(payload), decoded)
+}
+
+func chirpStackUplinkBody(deviceName string, tags string, payload []byte, decoded string) string {
+	return fmt.Sprintf(`{
+		"deduplicationId": "3ac7e3c4-4401-4b8d-9386-a5c902f9202d",
+		"time": "2026-03-01T10:00:00Z",
+		"deviceInfo": {"tenantId": "t", "applicationId": "a", "deviceName": %q, "devEui": "0101010101010101", "tags": %s},
+		"devAddr": "00189440", "fCnt": 7, "fPort": 1,
+		"data": %q,
+		"object": %s,
+		"rxInfo": [{"gatewayId": "0016c001f153a14c", "rssi": -57, "snr": 10}]
+	}`, deviceName, tags, base64.StdEncoding.EncodeToString(payload), decoded)
+}
+
+func newLoRaWANService(t *testing.T, secret string) (*Service, *recordingRepository) {
+	repository := &recordingRepository{}
+	cfg := &config.Config{}
+	cfg.Telemetry.LoRaWAN.WebhookSecret = secret
+	service, err := NewService(cfg, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	service.SetDeviceDirectory(deviceDirectory{
+		{DeviceID: "probe-1", TemplateID: "soil-probe-lora"},
+		{DeviceID: "probe-2", TemplateID: "soil-probe-lora"},
+		{DeviceID: "tracker-1", TemplateID: "gps-tracker"},
+	})
+
+	_, err = service.SetPayloadDecoder(context.Background(), "soil-probe-lora", &PayloadDecoderRequest{
+		Ports: []int{1},
+		Fields: map[string]string{
+			"temperature": "s16(0) / 100",
+			"moisture":    "u16(2) / 10",
+			"battery":     "u8(4) / 50",
+		},
+	})
+	require.NoError(t, err)
+	return service, repository
+}
+
+func TestPayloadDecoderRegistry_Put(t *testing.T) {
+	ctx := context.Background()
+	registry := NewPayloadDecoderRegistry()
+
+	for name, req := range map[string]*PayloadDecoderRequest{
+		"empty":          {},
+		"bad expression": {Fields: map[string]string{"temperature": "s16(0) /"}},
+		"unknown input":  {Fields: map[string]string{"temperature": "s16(0) * scale"}},
+		"bad field name": {Fields: map[string]string{"temp-c": "s16(0)"}},
+		"bad port":       {UseNetworkDecoded: true, Ports: []int{224}},
+	} {
+		_, err := registry.Put(ctx, "probe", req)
+		assert.Error(t, err, name)
+	}
+
+	created, err := registry.Put(ctx, "probe", &PayloadDecoderRequest{Fields: map[string]string{"level": "u8(0) * fport"}})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	updated, err := registry.Put(ctx, "probe", &PayloadDecoderRequest{UseNetworkDecoded: true})
+	require.NoError(t, err)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+	assert.Len(t, registry.List(), 1)
+
+	require.NoError(t, registry.Delete(ctx, "probe"))
+	assert.ErrorIs(t, registry.Delete(ctx, "probe"), ErrPayloadDecoderNotFound)
+}
+
+type mapPayloadDecoderStore struct {
+	decoders map[string]PayloadDecoderEntity
+	err      error
+}
+
+func (s *mapPayloadDecoderStore) SavePayloadDecoder(ctx context.Context, decoder *PayloadDecoder) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.decoders[decoder.TemplateID] = *decoder.ToEntity()
+	return nil
+}
+
+func (s *mapPayloadDecoderStore) ListPayloadDecoders(ctx context.Context) ([]*PayloadDecoder, error) {
+	var decoders []*PayloadDecoder
+	for _, entity := range s.decoders {
+		decoders = append(decoders, entity.FromEntity())
+	}
+	return decoders, nil
+}
+
+func (s *mapPayloadDecoderStore) DeletePayloadDecoder(ctx context.Context, templateID string) error {
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.decoders, templateID)
+	return nil
+}
+
+func TestService_PayloadDecoderStore(t *testing.T) {
+	ctx := context.Background()
+	store := &mapPayloadDecoderStore{decoders: make(map[string]PayloadDecoderEntity)}
+
+	first, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &recordingRepository{})
+	require.NoError(t, err)
+	require.NoError(t, first.SetPayloadDecoderStore(ctx, store))
+	_, err = first.SetPayloadDecoder(ctx, "soil-probe-lora", &PayloadDecoderRequest{
+		Fields: map[string]string{"temperature": "s16(0) / 100", "moisture": "u16(2) / 10"},
+		Ports:  []int{1},
+	})
+	require.NoError(t, err)
+
+	// Decoders survive into another replica, ready to decode
+	second, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &recordingRepository{})
+	require.NoError(t, err)
+	require.NoError(t, second.SetPayloadDecoderStore(ctx, store))
+	decoder, err := second.decoders.Get("soil-probe-lora")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, decoder.Ports)
+	metrics, err := decoder.Decode(soilProbePayload, 1)
+	require.NoError(t, err)
+	assert.InDelta(t, -1.25, metrics["temperature"], 1e-9)
+	assert.InDelta(t, 41.2, metrics["moisture"], 1e-9)
+
+	// A decoder that cannot be stored does not take effect
+	store.err = assert.AnError
+	_, err = first.SetPayloadDecoder(ctx, "gps-tracker", &PayloadDecoderRequest{UseNetworkDecoded: true})
+	assert.ErrorIs(t, err, errPayloadDecoderStorage)
+	_, err = first.decoders.Get("gps-tracker")
+	assert.ErrorIs(t, err, ErrPayloadDecoderNotFound)
+	assert.ErrorIs(t, first.decoders.Delete(ctx, "soil-probe-lora"), errPayloadDecoderStorage)
+	_, err = first.decoders.Get("soil-probe-lora")
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, first)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/telemetry/lorawan/decoders/gps-tracker", strings.NewReader(`{"use_network_decoded": true}`)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/telemetry/lorawan/decoders/soil-probe-lora", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestParseLoRaWANUplink(t *testing.T) {
+	uplink, err := parseLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 1, soilProbePayload, "null")))
+	require.NoError(t, err)
+	assert.Equal(t, "probe-1", uplink.DeviceID)
+	assert.Equal(t, "70B3D57ED0000001", uplink.DevEUI)
+	assert.Equal(t, 1, uplink.Port)
+	assert.Equal(t, uint32(42), uplink.FrameCount)
+	assert.Equal(t, soilProbePayload, uplink.Payload)
+	assert.Len(t, uplink.Gateways, 2)
+	assert.Equal(t, 500*time.Millisecond, time.Duration(uplink.ReceivedAt.Nanosecond()))
+
+	// ChirpStack devices can name their Athena device with a tag
+	uplink, err = parseLoRaWANUplink(LoRaWANNetworkChirpStack, []byte(chirpStackUplinkBody("field-7", `{"athena_device_id": "probe-2"}`, soilProbePayload, "null")))
+	require.NoError(t, err)
+	assert.Equal(t, "probe-2", uplink.DeviceID)
+	assert.Equal(t, "0101010101010101", uplink.DevEUI)
+	assert.Equal(t, soilProbePayload, uplink.Payload)
+	uplink, err = parseLoRaWANUplink(LoRaWANNetworkChirpStack, []byte(chirpStackUplinkBody("field-7", `{}`, soilProbePayload, "null")))
+	require.NoError(t, err)
+	assert.Equal(t, "field-7", uplink.DeviceID)
+
+	// Join notifications and MAC-only uplinks carry no telemetry
+	uplink, err = parseLoRaWANUplink(LoRaWANNetworkTTN, []byte(`{"end_device_ids": {"device_id": "probe-1"}, "join_accept": {}}`))
+	require.NoError(t, err)
+	assert.Nil(t, uplink)
+	uplink, err = parseLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 0, nil, "null")))
+	require.NoError(t, err)
+	assert.Nil(t, uplink)
+
+	_, err = parseLoRaWANUplink(LoRaWANNetworkTTN, []byte(`{`))
+	assert.ErrorIs(t, err, ErrInvalidUplink)
+	_, err = parseLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("", 1, soilProbePayload, "null")))
+	assert.ErrorIs(t, err, ErrInvalidUplink)
+	_, err = parseLoRaWANUplink("helium", []byte(`{}`))
+	assert.ErrorIs(t, err, ErrLoRaWANNetworkUnsupported)
+}
+
+func TestService_IngestLoRaWANUplink(t *testing.T) {
+	service, repository := newLoRaWANService(t, "")
+
+	data, err := service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 1, soilProbePayload, `{"temperature": 99, "status": "ok"}`)))
+	require.NoError(t, err)
+	require.Len(t, repository.stored, 1)
+	assert.Equal(t, "probe-1", data.DeviceID)
+	assert.InDelta(t, -1.25, data.Metrics["temperature"], 1e-9, "the template decoder wins without use_network_decoded")
+	assert.InDelta(t, 41.2, data.Metrics["moisture"], 1e-9)
+	assert.InDelta(t, 3.3, data.Metrics["battery"], 1e-9)
+	assert.NotContains(t, data.Tags, "status")
+	assert.Equal(t, -81.0, data.Metrics["lorawan_rssi"], "radio metadata comes from the strongest gateway")
+	assert.Equal(t, 8.25, data.Metrics["lorawan_snr"])
+	assert.Equal(t, 42.0, data.Metrics["lorawan_fcnt"])
+	assert.Equal(t, "gw-barn", data.Tags["lorawan_gateway"])
+	assert.Equal(t, "1", data.Tags["lorawan_fport"])
+	assert.Equal(t, "70B3D57ED0000001", data.Tags["lorawan_dev_eui"])
+	assert.True(t, data.Timestamp.Equal(time.Date(2026, 3, 1, 10, 0, 0, 5e8, time.UTC)))
+
+	// Truncated payloads fail rather than storing partial readings
+	_, err = service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 1, soilProbePayload[:3], "null")))
+	assert.ErrorIs(t, err, ErrUplinkDecode)
+
+	// Ports the decoder does not cover need the network server's values
+	_, err = service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 2, []byte{0x01}, "null")))
+	assert.ErrorIs(t, err, ErrUplinkDecode)
+
+	// Templates without a decoder use the network server's payload formatter
+	data, err = service.IngestLoRaWANUplink(LoRaWANNetworkChirpStack, []byte(chirpStackUplinkBody("tracker-1", `{}`, []byte{0x01}, `{"latitude": 52.1, "longitude": 5.2, "fix": "3d"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, 52.1, data.Metrics["latitude"])
+	assert.Equal(t, "3d", data.Tags["fix"])
+	_, err = service.IngestLoRaWANUplink(LoRaWANNetworkChirpStack, []byte(chirpStackUplinkBody("tracker-1", `{}`, []byte{0x01}, "null")))
+	assert.ErrorIs(t, err, ErrUplinkDecode)
+
+	// With use_network_decoded both are kept, decoded fields first
+	_, err = service.SetPayloadDecoder(context.Background(), "soil-probe-lora", &PayloadDecoderRequest{
+		Fields:            map[string]string{"temperature": "s16(0) / 100"},
+		UseNetworkDecoded: true,
+	})
+	require.NoError(t, err)
+	data, err = service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-1", 1, soilProbePayload, `{"temperature": 99, "salinity": 0.4}`)))
+	require.NoError(t, err)
+	assert.InDelta(t, -1.25, data.Metrics["temperature"], 1e-9)
+	assert.Equal(t, 0.4, data.Metrics["salinity"])
+
+	_, err = service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("stranger", 1, soilProbePayload, `{"temperature": 1}`)))
+	assert.ErrorIs(t, err, ErrUplinkDevice)
+}
+
+func TestService_LoRaWANHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, repository := newLoRaWANService(t, "lora-secret")
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	uplink := ttnUplinkBody("probe-1", 1, soilProbePayload, "null")
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/api/v1/telemetry/lorawan/ttn/uplink", uplink).Code)
+	w := request(http.MethodPost, "/api/v1/telemetry/lorawan/ttn/uplink?code=lora-secret", uplink)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"moisture":41.2`)
+	require.Len(t, repository.stored, 1)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/api/v1/telemetry/lorawan/chirpstack/uplink?code=lora-secret&event=join", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/v1/telemetry/lorawan/helium/uplink?code=lora-secret", uplink).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/api/v1/telemetry/lorawan/ttn/uplink?code=lora-secret", `{`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, request(http.MethodPost, "/api/v1/telemetry/lorawan/ttn/uplink?code=lora-secret",
+		ttnUplinkBody("probe-1", 1, []byte{0x01}, "null")).Code)
+	assert.Len(t, repository.stored, 1)
+
+	// Decoder management
+	w = request(http.MethodPut, "/api/v1/telemetry/lorawan/decoders/gps-tracker", `{"fields": {"latitude": "s32(0) / 1e7", "longitude": "s32(4) / 1e7"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPut, "/api/v1/telemetry/lorawan/decoders/gps-tracker", `{"fields": {"latitude": "s32("}}`).Code)
+
+	sample := base64.StdEncoding.EncodeToString([]byte{0x1F, 0x0D, 0xD4, 0x40, 0x03, 0x19, 0x75, 0x00})
+	w = request(http.MethodPost, "/api/v1/telemetry/lorawan/decoders/gps-tracker/test", fmt.Sprintf(`{"payload": %q, "f_port": 1}`, sample))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"latitude":52.1`)
+	assert.Equal(t, http.StatusUnprocessableEntity, request(http.MethodPost, "/api/v1/telemetry/lorawan/decoders/gps-tracker/test", `{"payload": "AQ=="}`).Code)
+
+	w = request(http.MethodGet, "/api/v1/telemetry/lorawan/decoders", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":2`)
+	assert.Equal(t, http.StatusOK, request(http.MethodDelete, "/api/v1/telemetry/lorawan/decoders/gps-tracker", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/api/v1/telemetry/lorawan/decoders/gps-tracker", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/v1/telemetry/lorawan/decoders/gps-tracker/test", `{"payload": "AQ=="}`).Code)
+}
+
+func TestService_IngestLoRaWANUplink_WithoutDirectory(t *testing.T) {
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &recordingRepository{})
+	require.NoError(t, err)
+
+	// Without device metadata any device is accepted and the network
+	// server's decoded values are used
+	data, err := service.IngestLoRaWANUplink(LoRaWANNetworkTTN, []byte(ttnUplinkBody("probe-9", 1, soilProbePayload, `{"temperature": 4.5}`)))
+	require.NoError(t, err)
+	assert.Equal(t, 4.5, data.Metrics["temperature"])
+}
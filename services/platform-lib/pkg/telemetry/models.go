@@ -23,6 +23,35 @@ type TelemetryEntity struct {
 	TagsJSON     string    `datastore:"tags_json,noindex"`
 }
 
+// DeviceLogEntry is a log line published by device firmware
+type DeviceLogEntry struct {
+	DeviceID  string    `json:"device_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Tag       string    `json:"tag,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// DeviceLogEntity represents the Datastore entity for device log lines
+type DeviceLogEntity struct {
+	DeviceID  string    `datastore:"device_id"`
+	Timestamp time.Time `datastore:"timestamp"`
+	Level     string    `datastore:"level"`
+	Tag       string    `datastore:"tag"`
+	Message   string    `datastore:"message,noindex"`
+}
+
+// DeviceLogQuery selects device log lines. MinLevel and Tag are optional;
+// Limit keeps the newest matching lines.
+type DeviceLogQuery struct {
+	DeviceID string
+	Start    time.Time
+	End      time.Time
+	MinLevel string
+	Tag      string
+	Limit    int
+}
+
 // MetricPoint represents a single metric data point
 type MetricPoint struct {
 	Timestamp   time.Time         `json:"timestamp"`
@@ -86,6 +115,7 @@ type AlertEntity struct {
 type ThresholdEntity struct {
 	ThresholdID   string    `datastore:"threshold_id"`
 	DeviceID      string    `datastore:"device_id"`
+	TemplateID    string    `datastore:"template_id"`
 	MetricName    string    `datastore:"metric_name"`
 	Operator      string    `datastore:"operator"`
 	Value         float64   `datastore:"value"`
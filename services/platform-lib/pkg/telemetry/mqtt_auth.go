@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+)
+
+// mqttAuthRequest is the JSON body the HTTP backend of mosquitto-go-auth
+// posts for its user, superuser and ACL checks. Acc is the access asked
+// for in ACL checks: 1 read, 2 write, 3 read and write, 4 subscribe.
+type mqttAuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	ClientID string `json:"clientid"`
+	Topic    string `json:"topic"`
+	Acc      int    `json:"acc"`
+}
+
+// mqttUserHandler authenticates a broker client. The platform services
+// connect as mqtt.username with mqtt.password; devices connect with their
+// device ID as username and one of their tokens as password. The broker
+// only reads the status: 200 allows the connection, anything else refuses
+// it.
+func (s *Service) mqttUserHandler(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if s.isMQTTServiceUser(req.Username) {
+		if subtle.ConstantTimeCompare([]byte(req.Password), []byte(s.config.MQTT.Password)) != 1 {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if s.devices == nil {
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	dev, err := s.devices.GetDevice(c.Request.Context(), req.Username)
+	if err != nil || !dev.VerifyToken(req.Password, time.Now()) {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// mqttSuperuserHandler lets the platform services read every topic
+func (s *Service) mqttSuperuserHandler(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !s.isMQTTServiceUser(req.Username) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// mqttACLCheckHandler confines authenticated devices to the topics of the
+// namespace that belong to them, or to a device behind them when they are
+// a gateway
+func (s *Service) mqttACLCheckHandler(c *gin.Context) {
+	var req mqttAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	deviceID, _, ok := s.topics.Parse(req.Topic)
+	if !ok || !s.mqttTopicOwnedBy(c.Request.Context(), deviceID, req.Username) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *Service) isMQTTServiceUser(username string) bool {
+	return s.config.MQTT.Username != "" && username == s.config.MQTT.Username
+}
+
+// mqttTopicOwnedBy reports whether the topics of deviceID may be used by
+// username: the device itself or a gateway it sits behind
+func (s *Service) mqttTopicOwnedBy(ctx context.Context, deviceID, username string) bool {
+	for depth := 0; depth <= device.MaxHierarchyDepth; depth++ {
+		if deviceID == username {
+			return true
+		}
+		if s.devices == nil {
+			return false
+		}
+		dev, err := s.devices.GetDevice(ctx, deviceID)
+		if err != nil || dev.ParentID == "" {
+			return false
+		}
+		deviceID = dev.ParentID
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_MQTTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, _ := newIngestService(t)
+	service.config.MQTT.Username = "telemetry-service"
+	service.config.MQTT.Password = "service-secret"
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	check := func(path string, req mqttAuthRequest) int {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/telemetry/mqtt/auth/"+path, bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// Devices log in with one of their tokens, services with the broker
+	// credentials of the platform
+	assert.Equal(t, http.StatusOK, check("user", mqttAuthRequest{Username: "accel-1", Password: "device-token"}))
+	assert.Equal(t, http.StatusUnauthorized, check("user", mqttAuthRequest{Username: "accel-1", Password: "wrong"}))
+	assert.Equal(t, http.StatusUnauthorized, check("user", mqttAuthRequest{Username: "other-1", Password: "device-token"}))
+	assert.Equal(t, http.StatusUnauthorized, check("user", mqttAuthRequest{Username: "unknown", Password: "device-token"}))
+	assert.Equal(t, http.StatusOK, check("user", mqttAuthRequest{Username: "telemetry-service", Password: "service-secret"}))
+	assert.Equal(t, http.StatusUnauthorized, check("user", mqttAuthRequest{Username: "telemetry-service", Password: "device-token"}))
+
+	assert.Equal(t, http.StatusOK, check("superuser", mqttAuthRequest{Username: "telemetry-service"}))
+	assert.Equal(t, http.StatusForbidden, check("superuser", mqttAuthRequest{Username: "accel-1"}))
+
+	// Devices only use their own topics and those of devices behind them
+	assert.Equal(t, http.StatusOK, check("acl", mqttAuthRequest{Username: "accel-1", Topic: "telemetry/accel-1/data", Acc: 2}))
+	assert.Equal(t, http.StatusOK, check("acl", mqttAuthRequest{Username: "accel-1", Topic: "telemetry/probe-1a/data", Acc: 2}))
+	assert.Equal(t, http.StatusForbidden, check("acl", mqttAuthRequest{Username: "accel-1", Topic: "telemetry/other-1/data", Acc: 2}))
+	assert.Equal(t, http.StatusForbidden, check("acl", mqttAuthRequest{Username: "probe-1", Topic: "telemetry/accel-1/config", Acc: 4}))
+	assert.Equal(t, http.StatusForbidden, check("acl", mqttAuthRequest{Username: "accel-1", Topic: "telemetry/+/data", Acc: 4}))
+	assert.Equal(t, http.StatusForbidden, check("acl", mqttAuthRequest{Username: "accel-1", Topic: "other/accel-1/data", Acc: 2}))
+}
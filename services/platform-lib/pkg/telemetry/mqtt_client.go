@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/topics"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -16,7 +17,9 @@ type MQTTClient struct {
 	client     mqtt.Client
 	logger     *logger.Logger
 	repository Repository
+	topics     *topics.Namespace
 	handlers   map[string]MessageHandler
+	intercept  MessageInterceptor
 	mu         sync.RWMutex
 	ctx        context.Context
 	cancel     context.CancelFunc
@@ -25,6 +28,13 @@ type MQTTClient struct {
 // MessageHandler is a function that processes MQTT messages
 type MessageHandler func(topic string, payload []byte) error
 
+// MessageInterceptor sees every MQTT message before it is handled or
+// published and may delay or drop it, e.g. to inject faults in testing
+type MessageInterceptor interface {
+	// InterceptMessage returns false if the message must be dropped
+	InterceptMessage(topic string) bool
+}
+
 // MQTTConfig holds MQTT connection configuration
 type MQTTConfig struct {
 	BrokerURL      string
@@ -35,15 +45,26 @@ type MQTTConfig struct {
 	CleanSession   bool
 	ConnectTimeout time.Duration
 	KeepAlive      time.Duration
+	// Topics is the device topic namespace. Nil uses the default layout.
+	Topics *topics.Namespace
 }
 
 // NewMQTTClient creates a new MQTT client for telemetry ingestion
 func NewMQTTClient(config *MQTTConfig, repository Repository, log *logger.Logger) (*MQTTClient, error) {
+	namespace := config.Topics
+	if namespace == nil {
+		var err error
+		if namespace, err = topics.New("", ""); err != nil {
+			return nil, err
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &MQTTClient{
 		logger:     log,
 		repository: repository,
+		topics:     namespace,
 		handlers:   make(map[string]MessageHandler),
 		ctx:        ctx,
 		cancel:     cancel,
@@ -84,21 +105,22 @@ func (c *MQTTClient) Disconnect() {
 	c.logger.Info("Disconnected from MQTT broker")
 }
 
-// Subscribe subscribes to a topic with a handler
+// Subscribe subscribes to a topic filter with a handler. The handler
+// receives every message matching the filter, including wildcards.
 func (c *MQTTClient) Subscribe(topic string, qos byte, handler MessageHandler) error {
 	c.mu.Lock()
 	c.handlers[topic] = handler
 	c.mu.Unlock()
 
 	token := c.client.Subscribe(topic, qos, func(client mqtt.Client, msg mqtt.Message) {
-		c.handleMessage(msg.Topic(), msg.Payload())
+		c.handleMessage(topic, msg.Topic(), msg.Payload())
 	})
 
 	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
 	}
 
-	c.logger.Info(fmt.Sprintf("Subscribed to topic: %s", topic))
+	c.logger.Info("Subscribed to topic", "topic", topic)
 	return nil
 }
 
@@ -113,29 +135,48 @@ func (c *MQTTClient) Unsubscribe(topic string) error {
 		return fmt.Errorf("failed to unsubscribe from topic %s: %w", topic, token.Error())
 	}
 
-	c.logger.Info(fmt.Sprintf("Unsubscribed from topic: %s", topic))
+	c.logger.Info("Unsubscribed from topic", "topic", topic)
 	return nil
 }
 
-// handleMessage processes incoming MQTT messages
-func (c *MQTTClient) handleMessage(topic string, payload []byte) {
+// SetInterceptor installs an interceptor for incoming and outgoing messages
+func (c *MQTTClient) SetInterceptor(intercept MessageInterceptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.intercept = intercept
+}
+
+// intercepted reports whether the interceptor dropped a message
+func (c *MQTTClient) intercepted(topic string) bool {
+	c.mu.RLock()
+	intercept := c.intercept
+	c.mu.RUnlock()
+	return intercept != nil && !intercept.InterceptMessage(topic)
+}
+
+// handleMessage processes incoming MQTT messages received on a subscription
+func (c *MQTTClient) handleMessage(filter, topic string, payload []byte) {
+	if c.intercepted(topic) {
+		return
+	}
+
 	c.mu.RLock()
-	handler, exists := c.handlers[topic]
+	handler, exists := c.handlers[filter]
 	c.mu.RUnlock()
 
 	if !exists {
-		c.logger.Warn(fmt.Sprintf("No handler for topic: %s", topic))
+		c.logger.Warn("No handler for topic", "topic", topic)
 		return
 	}
 
 	if err := handler(topic, payload); err != nil {
-		c.logger.Error(fmt.Sprintf("Error handling message from topic %s: %v", topic, err))
+		c.logger.Error("Error handling message", "topic", topic, "error", err)
 	}
 }
 
 // onConnectionLost is called when connection to broker is lost
 func (c *MQTTClient) onConnectionLost(client mqtt.Client, err error) {
-	c.logger.Warn(fmt.Sprintf("MQTT connection lost: %v", err))
+	c.logger.Warn("MQTT connection lost", "error", err)
 }
 
 // onConnect is called when connection to broker is established
@@ -143,59 +184,148 @@ func (c *MQTTClient) onConnect(client mqtt.Client) {
 	c.logger.Info("MQTT connection established")
 }
 
+// Topics returns the device topic namespace
+func (c *MQTTClient) Topics() *topics.Namespace {
+	return c.topics
+}
+
+// deviceFromTopic returns the device a topic belongs to. A device may only
+// report for itself, so a payload naming another device is rejected.
+func (c *MQTTClient) deviceFromTopic(topic, payloadDeviceID string) (string, error) {
+	deviceID, _, ok := c.topics.Parse(topic)
+	if !ok {
+		return "", fmt.Errorf("topic %s is outside namespace %s", topic, c.topics.Template())
+	}
+	if payloadDeviceID != "" && payloadDeviceID != deviceID {
+		return "", fmt.Errorf("device %s may not publish for device %s", deviceID, payloadDeviceID)
+	}
+	return deviceID, nil
+}
+
 // SubscribeToDeviceTelemetry subscribes to telemetry topics for all devices
 func (c *MQTTClient) SubscribeToDeviceTelemetry() error {
-	// Subscribe to wildcard topic for all device telemetry
-	// Topic pattern: telemetry/{device_id}/data
-	topic := "telemetry/+/data"
-
-	handler := func(topic string, payload []byte) error {
-		var data TelemetryData
-		if err := json.Unmarshal(payload, &data); err != nil {
-			return fmt.Errorf("failed to unmarshal telemetry data: %w", err)
-		}
+	return c.Subscribe(c.topics.Filter(topics.KindData), 1, c.handleTelemetry)
+}
 
-		// Set timestamp if not provided
-		if data.Timestamp.IsZero() {
-			data.Timestamp = time.Now()
-		}
+// handleTelemetry stores a telemetry message published by a device
+func (c *MQTTClient) handleTelemetry(topic string, payload []byte) error {
+	var data TelemetryData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal telemetry data: %w", err)
+	}
 
-		// Store telemetry data
-		ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
-		defer cancel()
+	deviceID, err := c.deviceFromTopic(topic, data.DeviceID)
+	if err != nil {
+		return err
+	}
+	data.DeviceID = deviceID
 
-		if err := c.repository.StoreTelemetry(ctx, &data); err != nil {
-			return fmt.Errorf("failed to store telemetry data: %w", err)
-		}
+	// Set timestamp if not provided
+	if data.Timestamp.IsZero() {
+		data.Timestamp = time.Now()
+	}
 
-		c.logger.Debug(fmt.Sprintf("Stored telemetry for device %s", data.DeviceID))
-		return nil
+	// Store telemetry data
+	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	defer cancel()
+
+	if err := c.repository.StoreTelemetry(ctx, &data); err != nil {
+		return fmt.Errorf("failed to store telemetry data: %w", err)
 	}
 
-	return c.Subscribe(topic, 1, handler)
+	c.logger.Debug("Stored telemetry", "device_id", data.DeviceID)
+	return nil
 }
 
 // SubscribeToDeviceHeartbeats subscribes to device heartbeat messages
 func (c *MQTTClient) SubscribeToDeviceHeartbeats() error {
-	// Topic pattern: telemetry/{device_id}/heartbeat
-	topic := "telemetry/+/heartbeat"
-
-	handler := func(topic string, payload []byte) error {
-		var heartbeat struct {
-			DeviceID  string    `json:"device_id"`
-			Timestamp time.Time `json:"timestamp"`
-			Status    string    `json:"status"`
-		}
+	return c.Subscribe(c.topics.Filter(topics.KindHeartbeat), 1, c.handleHeartbeat)
+}
 
-		if err := json.Unmarshal(payload, &heartbeat); err != nil {
-			return fmt.Errorf("failed to unmarshal heartbeat: %w", err)
-		}
+// handleHeartbeat records a heartbeat published by a device
+func (c *MQTTClient) handleHeartbeat(topic string, payload []byte) error {
+	var heartbeat struct {
+		DeviceID  string    `json:"device_id"`
+		Timestamp time.Time `json:"timestamp"`
+		Status    string    `json:"status"`
+	}
 
-		c.logger.Debug(fmt.Sprintf("Received heartbeat from device %s", heartbeat.DeviceID))
-		return nil
+	if err := json.Unmarshal(payload, &heartbeat); err != nil {
+		return fmt.Errorf("failed to unmarshal heartbeat: %w", err)
+	}
+
+	deviceID, err := c.deviceFromTopic(topic, heartbeat.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug("Received heartbeat", "device_id", deviceID)
+	return nil
+}
+
+// SubscribeToDeviceCrashes subscribes to device crash and reset reports
+// and hands each one to sink
+func (c *MQTTClient) SubscribeToDeviceCrashes(sink CrashReportSink) error {
+	return c.Subscribe(c.topics.Filter(topics.KindCrash), 1, func(topic string, payload []byte) error {
+		return c.handleCrash(topic, payload, sink)
+	})
+}
+
+// handleCrash passes a crash report published by a device on to sink
+func (c *MQTTClient) handleCrash(topic string, payload []byte, sink CrashReportSink) error {
+	var report struct {
+		DeviceID string `json:"device_id"`
+	}
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return fmt.Errorf("failed to unmarshal crash report: %w", err)
+	}
+
+	deviceID, err := c.deviceFromTopic(topic, report.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	defer cancel()
+
+	if err := sink.ReportCrash(ctx, deviceID, payload); err != nil {
+		return fmt.Errorf("failed to report crash for device %s: %w", deviceID, err)
 	}
 
-	return c.Subscribe(topic, 1, handler)
+	c.logger.Warn("Device reported a crash", "device_id", deviceID)
+	return nil
+}
+
+// DeviceLogHandler stores log lines a device published
+type DeviceLogHandler func(ctx context.Context, deviceID string, entries []*DeviceLogEntry) error
+
+// SubscribeToDeviceLogs subscribes to device log lines and hands each
+// message's lines to handler
+func (c *MQTTClient) SubscribeToDeviceLogs(handler DeviceLogHandler) error {
+	return c.Subscribe(c.topics.Filter(topics.KindLog), 1, func(topic string, payload []byte) error {
+		return c.handleLogs(topic, payload, handler)
+	})
+}
+
+// handleLogs passes log lines published by a device on to handler
+func (c *MQTTClient) handleLogs(topic string, payload []byte, handler DeviceLogHandler) error {
+	payloadDeviceID, entries, err := ParseDeviceLogs(payload)
+	if err != nil {
+		return err
+	}
+
+	deviceID, err := c.deviceFromTopic(topic, payloadDeviceID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	defer cancel()
+
+	if err := handler(ctx, deviceID, entries); err != nil {
+		return fmt.Errorf("failed to store logs for device %s: %w", deviceID, err)
+	}
+	return nil
 }
 
 // Publish publishes a message to a topic
@@ -215,6 +345,11 @@ func (c *MQTTClient) Publish(topic string, qos byte, retained bool, payload inte
 		}
 	}
 
+	// A dropped message is lost in transit, so the publisher is not told
+	if c.intercepted(topic) {
+		return nil
+	}
+
 	token := c.client.Publish(topic, qos, retained, data)
 	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to publish to topic %s: %w", topic, token.Error())
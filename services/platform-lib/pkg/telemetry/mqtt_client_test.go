@@ -0,0 +1,135 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/topics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRepository keeps stored telemetry in memory
+type recordingRepository struct {
+	MockRepository
+	stored []*TelemetryData
+}
+
+func (r *recordingRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	r.stored = append(r.stored, data)
+	return nil
+}
+
+func TestMQTTClient_HandleTelemetry_DeviceIsolation(t *testing.T) {
+	namespace, err := topics.New("{tenant}/devices/{device_id}/{kind}", "acme")
+	require.NoError(t, err)
+
+	repository := &recordingRepository{}
+	client, err := NewMQTTClient(&MQTTConfig{BrokerURL: "tcp://localhost:1883", Topics: namespace}, repository, logger.New("info", "telemetry-service"))
+	require.NoError(t, err)
+	assert.Equal(t, "acme/devices/+/data", client.Topics().Filter(topics.KindData))
+
+	// The device ID comes from the topic when the payload omits it
+	require.NoError(t, client.handleTelemetry("acme/devices/dev-1/data", []byte(`{"metrics":{"temperature":21.5}}`)))
+	require.Len(t, repository.stored, 1)
+	assert.Equal(t, "dev-1", repository.stored[0].DeviceID)
+
+	// A device may not report for another device or tenant
+	assert.Error(t, client.handleTelemetry("acme/devices/dev-1/data", []byte(`{"device_id":"dev-2","metrics":{"temperature":21.5}}`)))
+	assert.Error(t, client.handleTelemetry("globex/devices/dev-1/data", []byte(`{"metrics":{"temperature":21.5}}`)))
+	assert.Error(t, client.handleHeartbeat("acme/devices/dev-1/heartbeat", []byte(`{"device_id":"dev-2","status":"online"}`)))
+	assert.Len(t, repository.stored, 1)
+}
+
+func TestMQTTClient_HandleMessage_Wildcard(t *testing.T) {
+	client, err := NewMQTTClient(&MQTTConfig{BrokerURL: "tcp://localhost:1883"}, &MockRepository{}, logger.New("info", "telemetry-service"))
+	require.NoError(t, err)
+
+	var received string
+	client.handlers["telemetry/+/data"] = func(topic string, payload []byte) error {
+		received = topic
+		return nil
+	}
+
+	client.handleMessage("telemetry/+/data", "telemetry/dev-1/data", nil)
+	assert.Equal(t, "telemetry/dev-1/data", received)
+}
+
+// dropInterceptor drops messages on one topic
+type dropInterceptor struct {
+	topic string
+}
+
+func (d *dropInterceptor) InterceptMessage(topic string) bool {
+	return topic != d.topic
+}
+
+func TestMQTTClient_Interceptor(t *testing.T) {
+	client, err := NewMQTTClient(&MQTTConfig{BrokerURL: "tcp://localhost:1883"}, &MockRepository{}, logger.New("info", "telemetry-service"))
+	require.NoError(t, err)
+	client.SetInterceptor(&dropInterceptor{topic: "telemetry/dev-1/data"})
+
+	var received []string
+	client.handlers["telemetry/+/data"] = func(topic string, payload []byte) error {
+		received = append(received, topic)
+		return nil
+	}
+
+	client.handleMessage("telemetry/+/data", "telemetry/dev-1/data", nil)
+	client.handleMessage("telemetry/+/data", "telemetry/dev-2/data", nil)
+	assert.Equal(t, []string{"telemetry/dev-2/data"}, received)
+
+	// A dropped publish never reaches the broker, so it succeeds offline
+	assert.NoError(t, client.Publish("telemetry/dev-1/data", 1, false, map[string]string{"status": "ok"}))
+}
+
+// recordingCrashSink keeps forwarded crash reports in memory
+type recordingCrashSink struct {
+	devices []string
+}
+
+func (s *recordingCrashSink) ReportCrash(ctx context.Context, deviceID string, report json.RawMessage) error {
+	s.devices = append(s.devices, deviceID)
+	return nil
+}
+
+func TestMQTTClient_HandleCrash(t *testing.T) {
+	client, err := NewMQTTClient(&MQTTConfig{BrokerURL: "tcp://localhost:1883"}, &MockRepository{}, logger.New("info", "telemetry-service"))
+	require.NoError(t, err)
+	assert.Equal(t, "telemetry/+/crash", client.Topics().Filter(topics.KindCrash))
+
+	sink := &recordingCrashSink{}
+	require.NoError(t, client.handleCrash("telemetry/dev-1/crash", []byte(`{"reason":"ESP_RST_BROWNOUT"}`), sink))
+	assert.Error(t, client.handleCrash("telemetry/dev-1/crash", []byte(`{"device_id":"dev-2","reason":"panic"}`), sink))
+	assert.Error(t, client.handleCrash("telemetry/dev-1/crash", []byte(`not json`), sink))
+	assert.Equal(t, []string{"dev-1"}, sink.devices)
+}
+
+func TestHTTPCrashReportSink(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(data)
+		if strings.Contains(body, "reject") {
+			http.Error(w, "invalid crash report", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPCrashReportSink(server.URL + "/")
+	require.NoError(t, sink.ReportCrash(context.Background(), "dev-1", json.RawMessage(`{"reason":"panic"}`)))
+	assert.Equal(t, "/api/v1/ota/devices/dev-1/crashes", path)
+	assert.Equal(t, `{"reason":"panic"}`, body)
+
+	err := sink.ReportCrash(context.Background(), "dev-1", json.RawMessage(`{"reason":"reject"}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 400")
+}
@@ -0,0 +1,202 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/template"
+)
+
+const (
+	// BatteryMetric is the metric devices report their battery level in, as
+	// a percentage
+	BatteryMetric = "battery"
+
+	defaultBatteryTarget       = 30 * 24 * time.Hour
+	recommendationHistory      = 7 * 24 * time.Hour
+	minRecommendationDevices   = 3
+	minRecommendationSpan      = 6 * time.Hour
+	recommendationIntervalStep = 1000 // round recommended intervals to whole seconds
+)
+
+// ParameterRecommender recommends template parameters from fleet telemetry.
+// For interval parameters (names ending in _interval, in milliseconds) it
+// looks at the battery drain of devices running the template and suggests
+// the interval that would make a full battery last the target time,
+// assuming drain grows with how often devices publish.
+type ParameterRecommender struct {
+	devices    DeviceDirectory
+	repository Repository
+	target     time.Duration
+	now        func() time.Time
+}
+
+// NewParameterRecommender creates a recommender that aims for a month of
+// battery life
+func NewParameterRecommender(devices DeviceDirectory, repository Repository) *ParameterRecommender {
+	return &ParameterRecommender{
+		devices:    devices,
+		repository: repository,
+		target:     defaultBatteryTarget,
+		now:        time.Now,
+	}
+}
+
+// SetBatteryTarget sets how long a full battery should last
+func (r *ParameterRecommender) SetBatteryTarget(target time.Duration) {
+	if target > 0 {
+		r.target = target
+	}
+}
+
+// deviceDrain is a device's battery drain at the interval it runs with
+type deviceDrain struct {
+	interval    float64 // milliseconds
+	drainPerDay float64 // percent
+}
+
+// RecommendParameters recommends interval parameters for a template. A
+// recommendation needs battery history from at least three devices running
+// any version of the template.
+func (r *ParameterRecommender) RecommendParameters(ctx context.Context, tmpl *template.Template) ([]template.ParameterRecommendation, error) {
+	properties, _ := tmpl.Schema["properties"].(map[string]interface{})
+	var names []string
+	for name, property := range properties {
+		if strings.HasSuffix(name, "_interval") && isNumericProperty(property) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+
+	devices, err := r.devices.ListDevices(ctx, &device.DeviceFilters{TemplateID: tmpl.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	now := r.now()
+	drains := make(map[string]float64, len(devices))
+	for _, d := range devices {
+		metrics, err := r.repository.GetDeviceMetricsByName(ctx, d.DeviceID, BatteryMetric, TimeRange{Start: now.Add(-recommendationHistory), End: now})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get battery history for %s: %w", d.DeviceID, err)
+		}
+		if drain, ok := batteryDrainPerDay(numericSamples(metrics)); ok {
+			drains[d.DeviceID] = drain
+		}
+	}
+
+	var recommendations []template.ParameterRecommendation
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		var observed []deviceDrain
+		for _, d := range devices {
+			drain, ok := drains[d.DeviceID]
+			if !ok {
+				continue
+			}
+			interval, ok := numericValue(d.Parameters[name])
+			if !ok {
+				if interval, ok = numericValue(tmpl.Parameters[name]); !ok {
+					interval, ok = numericValue(property["default"])
+				}
+			}
+			if ok && interval > 0 {
+				observed = append(observed, deviceDrain{interval: interval, drainPerDay: drain})
+			}
+		}
+		if len(observed) < minRecommendationDevices {
+			continue
+		}
+		recommendations = append(recommendations, r.recommendInterval(name, property, observed))
+	}
+	return recommendations, nil
+}
+
+// recommendInterval scales each device's interval to the one that would
+// meet the battery target and takes the median
+func (r *ParameterRecommender) recommendInterval(name string, property map[string]interface{}, observed []deviceDrain) template.ParameterRecommendation {
+	targetDays := r.target.Hours() / 24
+	intervals := make([]float64, len(observed))
+	currentIntervals := make([]float64, len(observed))
+	drains := make([]float64, len(observed))
+	for i, o := range observed {
+		lifeDays := 100 / o.drainPerDay
+		intervals[i] = o.interval * targetDays / lifeDays
+		currentIntervals[i] = o.interval
+		drains[i] = o.drainPerDay
+	}
+
+	value := math.Round(median(intervals)/recommendationIntervalStep) * recommendationIntervalStep
+	value = math.Max(value, recommendationIntervalStep)
+	if minimum, ok := numericValue(property["minimum"]); ok {
+		value = math.Max(value, minimum)
+	}
+	if maximum, ok := numericValue(property["maximum"]); ok {
+		value = math.Min(value, maximum)
+	}
+
+	return template.ParameterRecommendation{
+		Parameter: name,
+		Value:     int64(value),
+		Reason: fmt.Sprintf("%d devices on this template lose a median %.1f%% battery a day publishing every %.0f ms; every %.0f ms should make a full battery last about %.0f days",
+			len(observed), median(drains), median(currentIntervals), value, targetDays),
+		Devices: len(observed),
+	}
+}
+
+// batteryDrainPerDay fits a line to battery history and returns the drain in
+// percent per day. Charging or flat batteries and short histories give no
+// drain.
+func batteryDrainPerDay(samples []sample) (float64, bool) {
+	if len(samples) < minForecastSamples || samples[len(samples)-1].t.Sub(samples[0].t) < minRecommendationSpan {
+		return 0, false
+	}
+
+	origin := samples[0].t
+	n := float64(len(samples))
+	var meanX, meanY float64
+	for _, s := range samples {
+		meanX += s.t.Sub(origin).Hours()
+		meanY += s.v
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for _, s := range samples {
+		dx := s.t.Sub(origin).Hours() - meanX
+		sxx += dx * dx
+		sxy += dx * (s.v - meanY)
+	}
+	if sxx == 0 || sxy >= 0 {
+		return 0, false
+	}
+	return -sxy / sxx * 24, true
+}
+
+func isNumericProperty(property interface{}) bool {
+	p, ok := property.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	kind, _ := p["type"].(string)
+	return kind == "integer" || kind == "number"
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batteryRepository serves battery history per device
+type batteryRepository struct {
+	MockRepository
+	history map[string][]*MetricPoint
+}
+
+func (r *batteryRepository) GetDeviceMetricsByName(ctx context.Context, deviceID string, metricName string, timeRange TimeRange) ([]*MetricPoint, error) {
+	return r.history[deviceID], nil
+}
+
+// batteryHistory reports a battery losing drainPerDay percent every 12 hours
+// for three days
+func batteryHistory(now time.Time, drainPerDay float64) []*MetricPoint {
+	var points []*MetricPoint
+	for hours := 72; hours >= 0; hours -= 12 {
+		points = append(points, &MetricPoint{
+			Timestamp:   now.Add(-time.Duration(hours) * time.Hour),
+			MetricName:  BatteryMetric,
+			MetricValue: 90 - drainPerDay*float64(72-hours)/24,
+		})
+	}
+	return points
+}
+
+func TestParameterRecommender_PublishInterval(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	charging := batteryHistory(now, -5)
+	repository := &batteryRepository{history: map[string][]*MetricPoint{
+		"dev-1": batteryHistory(now, 10), // 10 days at 5000 ms
+		"dev-2": batteryHistory(now, 5),  // 20 days at the template default
+		"dev-3": batteryHistory(now, 4),  // 25 days at 10000 ms
+		"dev-4": charging,
+		"dev-5": batteryHistory(now, 10)[:2],
+	}}
+	devices := deviceDirectory{
+		{DeviceID: "dev-1", TemplateID: "weather", Parameters: map[string]interface{}{"publish_interval": 5000.0}},
+		{DeviceID: "dev-2", TemplateID: "weather"},
+		{DeviceID: "dev-3", TemplateID: "weather", Parameters: map[string]interface{}{"publish_interval": 10000.0}},
+		{DeviceID: "dev-4", TemplateID: "weather", Parameters: map[string]interface{}{"publish_interval": 1000.0}},
+		{DeviceID: "dev-5", TemplateID: "weather", Parameters: map[string]interface{}{"publish_interval": 1000.0}},
+	}
+	recommender := NewParameterRecommender(devices, repository)
+	recommender.now = func() time.Time { return now }
+
+	tmpl := &template.Template{
+		ID:      "weather",
+		Version: "1.0.0",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"publish_interval": map[string]interface{}{"type": "integer", "minimum": 1000.0},
+				"wifi_ssid":        map[string]interface{}{"type": "string"},
+			},
+		},
+		Parameters: map[string]interface{}{"publish_interval": 5000.0},
+	}
+
+	recommendations, err := recommender.RecommendParameters(context.Background(), tmpl)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 1)
+	// Each device scaled to 30 days: 15000, 7500 and 12000 ms
+	assert.Equal(t, "publish_interval", recommendations[0].Parameter)
+	assert.Equal(t, int64(12000), recommendations[0].Value)
+	assert.Equal(t, 3, recommendations[0].Devices)
+	assert.Equal(t, "3 devices on this template lose a median 5.0% battery a day publishing every 5000 ms; every 12000 ms should make a full battery last about 30 days", recommendations[0].Reason)
+
+	tmpl.Schema["properties"].(map[string]interface{})["publish_interval"].(map[string]interface{})["maximum"] = 10000.0
+	recommendations, err = recommender.RecommendParameters(context.Background(), tmpl)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), recommendations[0].Value, "recommendations stay within the schema")
+
+	// Too few devices with battery history
+	recommender.devices = devices[2:]
+	recommendations, err = recommender.RecommendParameters(context.Background(), tmpl)
+	require.NoError(t, err)
+	assert.Empty(t, recommendations)
+}
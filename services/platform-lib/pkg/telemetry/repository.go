@@ -26,6 +26,11 @@ type Repository interface {
 	UpdateThreshold(ctx context.Context, thresholdID string, threshold *AlertThreshold) error
 	DeleteThreshold(ctx context.Context, thresholdID string) error
 
+	// Template and device scoped thresholds. SaveScopedThreshold creates the
+	// threshold when ThresholdID is empty and sets it.
+	ListScopedThresholds(ctx context.Context, scope ThresholdScope, scopeID string) ([]*ScopedThreshold, error)
+	SaveScopedThreshold(ctx context.Context, threshold *ScopedThreshold) error
+
 	// Alert management
 	CreateAlert(ctx context.Context, alert *Alert) error
 	GetAlert(ctx context.Context, alertID string) (*Alert, error)
@@ -33,6 +38,11 @@ type Repository interface {
 	AcknowledgeAlert(ctx context.Context, alertID string) error
 	ResolveAlert(ctx context.Context, alertID string) error
 
+	// Device logs. QueryDeviceLogs returns lines oldest first.
+	StoreDeviceLogs(ctx context.Context, entries []*DeviceLogEntry) error
+	QueryDeviceLogs(ctx context.Context, query *DeviceLogQuery) ([]*DeviceLogEntry, error)
+
 	// Cleanup operations
 	DeleteOldTelemetry(ctx context.Context, before time.Time) (int64, error)
+	DeleteOldDeviceLogs(ctx context.Context, before time.Time) (int64, error)
 }
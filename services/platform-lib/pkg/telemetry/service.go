@@ -2,12 +2,19 @@ package telemetry
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/athena/platform-lib/pkg/topics"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,18 +24,68 @@ type Service struct {
 	logger        *logger.Logger
 	repository    Repository
 	mqttClient    *MQTTClient
+	topics        *topics.Namespace
+	bridges       *cloudBridgeSet
 	streamManager *StreamManager
 	exporter      *Exporter
+	exports       *exportJobs
+	edgeSync      *edgeSync
+	forecaster    *Forecaster
+	derived       *DerivedMetricRegistry
+	decoders      *PayloadDecoderRegistry
+	devices       DeviceDirectory
+	crashReports  CrashReportSink
+	usage         *meteredRepository
+	logFollowers  *logFollowers
+	logAlerts     *LogAlertRuleRegistry
 	alertMonitor  *AlertMonitor
 	alertNotifier *AlertNotifier
+	clocks        *ClockSkewTracker
+	homeAssistant *HomeAssistantDiscovery
+	firmware      FirmwareDirectory
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
 
 // NewService creates a new telemetry service instance
 func NewService(cfg *config.Config, logger *logger.Logger, repository Repository) (*Service, error) {
+	namespace, err := topics.New(cfg.MQTT.TopicTemplate, cfg.MQTT.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT topic configuration: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Every point written, whichever path it arrives by, is metered
+	usage := &meteredRepository{Repository: repository}
+	repository = usage
+
+	// Derived metrics are computed by wrapping the repository, so every
+	// reader and writer below sees them like raw metrics
+	derived := NewDerivedMetricRegistry()
+	repository = NewDerivedRepository(repository, derived)
+
+	// Outbound cloud bridges see everything the service stores; inbound
+	// bridges write underneath them so telemetry is not sent back
+	bridges := &cloudBridgeSet{repository: repository}
+	repository = &forwardingRepository{Repository: repository, bridges: bridges}
+
+	// Home Assistant sees everything the service stores, like the bridges
+	var homeAssistant *HomeAssistantDiscovery
+	if cfg.Telemetry.HomeAssistant.Enabled {
+		if cfg.MQTT.Enabled {
+			homeAssistant = NewHomeAssistantDiscovery(cfg.Telemetry.HomeAssistant, logger.Component("home-assistant"))
+			repository = &homeAssistantRepository{Repository: repository, discovery: homeAssistant}
+		} else {
+			logger.Warn("Home Assistant discovery needs MQTT and is disabled")
+		}
+	}
+
+	// Timestamps from drifting device clocks are corrected before anything
+	// else sees them
+	clocks := NewClockSkewTracker(cfg.Telemetry.ClockSkewTolerance)
+	repository = &clockCorrectingRepository{Repository: repository, clocks: clocks}
+
 	// Initialize alert notifier with default log channel
 	notificationChannels := []NotificationConfig{
 		{
@@ -37,6 +94,7 @@ func NewService(cfg *config.Config, logger *logger.Logger, repository Repository
 		},
 	}
 	alertNotifier := NewAlertNotifier(logger, notificationChannels)
+	alertNotifier.SetPublisher(notifications.NewPublisherFromConfig(cfg))
 
 	// Initialize alert monitor
 	alertMonitor := NewAlertMonitor(repository, alertNotifier, logger)
@@ -47,12 +105,29 @@ func NewService(cfg *config.Config, logger *logger.Logger, repository Repository
 		repository:    repository,
 		streamManager: NewStreamManager(logger, repository),
 		exporter:      NewExporter(repository),
+		exports:       newExportJobs(cfg.JWTSecret, cfg.Telemetry.ExportLinkExpiry, cfg.Telemetry.MaxConcurrentExports),
+		edgeSync:      newEdgeSync(),
+		forecaster:    NewForecaster(repository),
+		derived:       derived,
+		decoders:      NewPayloadDecoderRegistry(),
 		alertMonitor:  alertMonitor,
 		alertNotifier: alertNotifier,
+		clocks:        clocks,
+		homeAssistant: homeAssistant,
+		topics:        namespace,
+		bridges:       bridges,
+		logFollowers:  newLogFollowers(),
+		logAlerts:     NewLogAlertRuleRegistry(),
+		usage:         usage,
 		ctx:           ctx,
 		cancel:        cancel,
 	}
 
+	// Fleet comparisons resolve firmware versions from OTA releases
+	if otaURL := cfg.Services["ota-service"]; otaURL != "" {
+		service.firmware = NewHTTPFirmwareDirectory(otaURL)
+	}
+
 	// Initialize MQTT client if configured
 	if cfg.MQTT.Enabled {
 		mqttConfig := &MQTTConfig{
@@ -64,6 +139,7 @@ func NewService(cfg *config.Config, logger *logger.Logger, repository Repository
 			CleanSession:   true,
 			ConnectTimeout: 10 * time.Second,
 			KeepAlive:      60 * time.Second,
+			Topics:         namespace,
 		}
 
 		mqttClient, err := NewMQTTClient(mqttConfig, repository, logger)
@@ -72,11 +148,42 @@ func NewService(cfg *config.Config, logger *logger.Logger, repository Repository
 		}
 
 		service.mqttClient = mqttClient
+		if homeAssistant != nil {
+			homeAssistant.publisher = mqttClient
+		}
+
+		// Crash reports are stored by the OTA service alongside update history
+		if otaURL := cfg.Services["ota-service"]; otaURL != "" {
+			service.crashReports = NewHTTPCrashReportSink(otaURL)
+		}
 	}
 
 	return service, nil
 }
 
+// SetDeviceEvents adds fired alerts to device timelines
+func (s *Service) SetDeviceEvents(events *device.EventRecorder) {
+	if s.alertNotifier != nil {
+		s.alertNotifier.SetDeviceEvents(events)
+	}
+}
+
+// SetDeviceOwners sets how fired alerts are addressed to the owners of
+// their devices
+func (s *Service) SetDeviceOwners(owners *device.DeviceOwners) {
+	if s.alertNotifier != nil {
+		s.alertNotifier.SetOwners(owners)
+	}
+}
+
+// SetMessageInterceptor lets an interceptor delay or drop the MQTT messages
+// the service receives and publishes. It has no effect without MQTT.
+func (s *Service) SetMessageInterceptor(intercept MessageInterceptor) {
+	if s.mqttClient != nil {
+		s.mqttClient.SetInterceptor(intercept)
+	}
+}
+
 // Start starts the telemetry service
 func (s *Service) Start() error {
 	if s.mqttClient != nil {
@@ -93,6 +200,23 @@ func (s *Service) Start() error {
 			return fmt.Errorf("failed to subscribe to device heartbeats: %w", err)
 		}
 
+		if s.crashReports != nil {
+			if err := s.mqttClient.SubscribeToDeviceCrashes(s.crashReports); err != nil {
+				return fmt.Errorf("failed to subscribe to device crash reports: %w", err)
+			}
+		}
+
+		if err := s.mqttClient.SubscribeToDeviceLogs(s.IngestDeviceLogs); err != nil {
+			return fmt.Errorf("failed to subscribe to device logs: %w", err)
+		}
+
+		if s.homeAssistant != nil {
+			if err := s.mqttClient.Subscribe(s.homeAssistant.StatusTopic(), 1, s.homeAssistant.handleStatus); err != nil {
+				return fmt.Errorf("failed to subscribe to Home Assistant status: %w", err)
+			}
+			s.homeAssistant.start(s.ctx)
+		}
+
 		s.logger.Info("Telemetry service started with MQTT support")
 	} else {
 		s.logger.Info("Telemetry service started (HTTP only)")
@@ -104,15 +228,48 @@ func (s *Service) Start() error {
 		s.logger.Info("Alert monitoring started")
 	}
 
+	if retention := s.config.Telemetry.LogRetention; retention > 0 {
+		go s.runLogRetention(retention)
+	}
+
+	// Flush notification digests as their intervals elapse
+	if s.alertNotifier != nil {
+		s.alertNotifier.Start(time.Minute)
+	}
+
+	// Streams are closed once their principal loses access
+	if s.streamManager != nil && s.streamManager.currentAuthorizer() != nil {
+		if interval := s.config.Telemetry.StreamReauthorizeInterval; interval > 0 {
+			s.streamManager.startReauthorizing(s.ctx, interval)
+		}
+	}
+
+	for _, bridge := range s.bridges.list() {
+		if err := bridge.start(s.ctx); err != nil {
+			return fmt.Errorf("failed to start cloud bridge %s: %w", bridge.config.Name, err)
+		}
+		s.logger.Info("Cloud bridge started", "bridge", bridge.config.Name, "provider", bridge.config.Provider, "direction", bridge.config.Direction)
+	}
+
 	return nil
 }
 
 // Stop stops the telemetry service
 func (s *Service) Stop() {
+	// Bridges drain queued telemetry before the service context is cancelled
+	for _, bridge := range s.bridges.list() {
+		bridge.stop()
+	}
+	if s.homeAssistant != nil {
+		s.homeAssistant.stop()
+	}
 	s.cancel()
 	if s.alertMonitor != nil {
 		s.alertMonitor.Stop()
 	}
+	if s.alertNotifier != nil {
+		s.alertNotifier.Stop()
+	}
 	if s.mqttClient != nil {
 		s.mqttClient.Disconnect()
 	}
@@ -148,6 +305,42 @@ func (s *Service) IngestTelemetry(deviceID string, data *TelemetryData) error {
 	return nil
 }
 
+// BatchIngestRequest carries many readings from one device, e.g. a buffered
+// upload from a high-frequency sensor. A gateway may include readings of
+// devices behind it by setting their device_id.
+type BatchIngestRequest struct {
+	Points []*TelemetryData `json:"points" binding:"required,min=1"`
+}
+
+// IngestTelemetryBatch ingests many telemetry readings for a device in one
+// write. Metrics with a storage hint are packed into telemetry blocks.
+func (s *Service) IngestTelemetryBatch(deviceID string, batch []*TelemetryData) error {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	for _, data := range batch {
+		if data.DeviceID == "" {
+			data.DeviceID = deviceID
+		}
+		if data.Timestamp.IsZero() {
+			data.Timestamp = now
+		}
+	}
+
+	if err := s.repository.StoreTelemetryBatch(ctx, batch); err != nil {
+		return err
+	}
+
+	if s.streamManager != nil {
+		for _, data := range batch {
+			s.streamManager.BroadcastTelemetry(data.DeviceID, data)
+		}
+	}
+
+	return nil
+}
+
 // GetDeviceMetrics retrieves metrics for a device
 func (s *Service) GetDeviceMetrics(deviceID string, timeRange TimeRange) ([]*MetricPoint, error) {
 	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
@@ -178,31 +371,96 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 	v1 := router.Group("/api/v1/telemetry")
 	{
 		v1.GET("/health", service.healthCheck)
+		v1.GET("/mqtt/acl", service.mqttACLHandler)
+
+		// Broker authentication against the device registry, called by
+		// the HTTP backend of mosquitto-go-auth
+		v1.POST("/mqtt/auth/user", service.mqttUserHandler)
+		v1.POST("/mqtt/auth/superuser", service.mqttSuperuserHandler)
+		v1.POST("/mqtt/auth/acl", service.mqttACLCheckHandler)
+
+		// Time synchronization for devices without a real time clock
+		v1.GET("/time", service.timeHandler)
+		v1.GET("/clock-skew", service.listClockSkewHandler)
+		v1.GET("/clock-skew/:deviceId", service.getClockSkewHandler)
 		v1.POST("/ingest/:deviceId", service.ingestTelemetryHandler)
+		v1.POST("/ingest/:deviceId/batch", service.ingestTelemetryBatchHandler)
+		v1.GET("/ingest/:deviceId/ws", service.ingestWebSocketHandler)
+		v1.POST("/sync/:deviceId", service.edgeSyncHandler)
 		v1.GET("/metrics/:deviceId", service.getMetricsHandler)
 		v1.GET("/metrics/:deviceId/:metricName", service.getMetricByNameHandler)
 		v1.POST("/aggregate", service.aggregateMetricsHandler)
+		v1.GET("/forecast/:deviceId/:metric", service.forecastHandler)
+		v1.POST("/compare", service.compareFleetsHandler)
+		v1.PUT("/thresholds/bulk", service.bulkThresholdsHandler)
+		v1.GET("/thresholds/templates/:templateId", service.listTemplateThresholdsHandler)
+		v1.GET("/thresholds/:deviceId/effective", service.effectiveThresholdsHandler)
 		v1.POST("/thresholds/:deviceId", service.createThresholdHandler)
 		v1.GET("/thresholds/:deviceId", service.listThresholdsHandler)
+		v1.GET("/thresholds/:deviceId/:thresholdId", service.getThresholdHandler)
+		v1.PUT("/thresholds/:deviceId/:thresholdId", service.updateThresholdHandler)
+		v1.DELETE("/thresholds/:deviceId/:thresholdId", service.deleteThresholdHandler)
+		v1.POST("/alerts", service.createAlertHandler)
 		v1.GET("/alerts/:deviceId", service.listAlertsHandler)
 		v1.POST("/alerts/:alertId/acknowledge", service.acknowledgeAlertHandler)
 		v1.POST("/alerts/:alertId/resolve", service.resolveAlertHandler)
 
 		// Streaming endpoints
 		v1.GET("/stream/:deviceId", service.streamDeviceDataHandler)
+		v1.GET("/stream/groups/:group", service.streamGroupDataHandler)
+
+		// Device logs, kept apart from numeric telemetry
+		v1.POST("/devices/:deviceId/logs", service.ingestDeviceLogsHandler)
+		v1.GET("/devices/:deviceId/logs", service.getDeviceLogsHandler)
+		v1.GET("/log-alert-rules", service.listLogAlertRulesHandler)
+		v1.POST("/log-alert-rules", service.createLogAlertRuleHandler)
+		v1.GET("/log-alert-rules/:name", service.getLogAlertRuleHandler)
+		v1.PUT("/log-alert-rules/:name", service.updateLogAlertRuleHandler)
+		v1.DELETE("/log-alert-rules/:name", service.deleteLogAlertRuleHandler)
+
+		// Derived metric definitions
+		v1.GET("/derived-metrics", service.listDerivedMetricsHandler)
+		v1.POST("/derived-metrics", service.createDerivedMetricHandler)
+		v1.GET("/derived-metrics/:name", service.getDerivedMetricHandler)
+		v1.PUT("/derived-metrics/:name", service.updateDerivedMetricHandler)
+		v1.DELETE("/derived-metrics/:name", service.deleteDerivedMetricHandler)
 
 		// Export endpoints
 		v1.POST("/export", service.exportDataHandler)
 		v1.POST("/export/aggregated", service.exportAggregatedHandler)
+		v1.POST("/export/archive", service.exportArchiveHandler)
+		v1.GET("/exports/:id", service.getExportHandler)
+		v1.GET("/exports/:id/download", service.downloadExportHandler)
 
 		// Notification channel management
 		v1.GET("/notifications/channels", service.listNotificationChannelsHandler)
 		v1.POST("/notifications/channels", service.addNotificationChannelHandler)
 		v1.PUT("/notifications/channels/:channel", service.updateNotificationChannelHandler)
 		v1.DELETE("/notifications/channels/:channel", service.deleteNotificationChannelHandler)
+		v1.PUT("/notifications/channels/:channel/digest", service.setNotificationDigestHandler)
+		v1.DELETE("/notifications/channels/:channel/digest", service.clearNotificationDigestHandler)
+
+		// Cloud bridges to AWS IoT Core and Azure IoT Hub
+		v1.GET("/bridges", service.listCloudBridgesHandler)
+		v1.POST("/bridges/:name/events", service.cloudEventsHandler)
+		v1.POST("/bridges/:name/devices/:deviceId/sync", service.syncDeviceStateHandler)
+
+		// LoRaWAN uplinks from The Things Network and ChirpStack
+		v1.POST("/lorawan/:network/uplink", service.lorawanUplinkHandler)
+		v1.GET("/lorawan/decoders", service.listPayloadDecodersHandler)
+		v1.GET("/lorawan/decoders/:templateId", service.getPayloadDecoderHandler)
+		v1.PUT("/lorawan/decoders/:templateId", service.setPayloadDecoderHandler)
+		v1.DELETE("/lorawan/decoders/:templateId", service.deletePayloadDecoderHandler)
+		v1.POST("/lorawan/decoders/:templateId/test", service.testPayloadDecoderHandler)
 	}
 }
 
+// mqttACLHandler renders a Mosquitto ACL that confines each device to its
+// own topics in the configured namespace
+func (s *Service) mqttACLHandler(c *gin.Context) {
+	c.String(http.StatusOK, s.topics.MosquittoACL(s.config.MQTT.Username))
+}
+
 func (s *Service) healthCheck(c *gin.Context) {
 	status := "healthy"
 	mqttStatus := "disabled"
@@ -233,7 +491,7 @@ func (s *Service) ingestTelemetryHandler(c *gin.Context) {
 	}
 
 	if err := s.IngestTelemetry(deviceID, &data); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to ingest telemetry: %v", err))
+		s.logger.Error("Failed to ingest telemetry", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store telemetry data"})
 		return
 	}
@@ -241,6 +499,35 @@ func (s *Service) ingestTelemetryHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Telemetry data ingested successfully"})
 }
 
+func (s *Service) ingestTelemetryBatchHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	var req BatchIngestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telemetry batch", "details": err.Error()})
+		return
+	}
+
+	for _, data := range req.Points {
+		if data == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telemetry batch", "details": "null point"})
+			return
+		}
+	}
+	if err := s.attributeToChildren(c.Request.Context(), deviceID, req.Points); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid telemetry batch", "details": err.Error()})
+		return
+	}
+
+	if err := s.IngestTelemetryBatch(deviceID, req.Points); err != nil {
+		s.logger.Error("Failed to ingest telemetry batch", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store telemetry data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Telemetry batch ingested successfully", "points": len(req.Points)})
+}
+
 func (s *Service) getMetricsHandler(c *gin.Context) {
 	deviceID := c.Param("deviceId")
 
@@ -263,7 +550,7 @@ func (s *Service) getMetricsHandler(c *gin.Context) {
 	timeRange := TimeRange{Start: start, End: end}
 	metrics, err := s.GetDeviceMetrics(deviceID, timeRange)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to get metrics: %v", err))
+		s.logger.Error("Failed to get metrics", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve metrics"})
 		return
 	}
@@ -300,7 +587,7 @@ func (s *Service) getMetricByNameHandler(c *gin.Context) {
 	timeRange := TimeRange{Start: start, End: end}
 	metrics, err := s.repository.GetDeviceMetricsByName(ctx, deviceID, metricName, timeRange)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to get metric: %v", err))
+		s.logger.Error("Failed to get metric", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve metric"})
 		return
 	}
@@ -327,7 +614,7 @@ func (s *Service) createThresholdHandler(c *gin.Context) {
 
 	thresholdID, err := s.repository.CreateThreshold(ctx, deviceID, &threshold)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to create threshold: %v", err))
+		s.logger.Error("Failed to create threshold", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create threshold"})
 		return
 	}
@@ -346,7 +633,7 @@ func (s *Service) listThresholdsHandler(c *gin.Context) {
 
 	thresholds, err := s.repository.ListThresholds(ctx, deviceID)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to list thresholds: %v", err))
+		s.logger.Error("Failed to list thresholds", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve thresholds"})
 		return
 	}
@@ -358,6 +645,135 @@ func (s *Service) listThresholdsHandler(c *gin.Context) {
 	})
 }
 
+func (s *Service) getThresholdHandler(c *gin.Context) {
+	thresholdID := c.Param("thresholdId")
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	threshold, err := s.repository.GetThreshold(ctx, thresholdID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threshold not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, threshold)
+}
+
+func (s *Service) updateThresholdHandler(c *gin.Context) {
+	thresholdID := c.Param("thresholdId")
+
+	var threshold AlertThreshold
+	if err := c.ShouldBindJSON(&threshold); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold data", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.repository.GetThreshold(ctx, thresholdID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threshold not found"})
+		return
+	}
+
+	if err := s.repository.UpdateThreshold(ctx, thresholdID, &threshold); err != nil {
+		s.logger.Error("Failed to update threshold", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update threshold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threshold_id": thresholdID,
+		"message":      "Threshold updated successfully",
+	})
+}
+
+func (s *Service) deleteThresholdHandler(c *gin.Context) {
+	thresholdID := c.Param("thresholdId")
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.repository.GetThreshold(ctx, thresholdID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Threshold not found"})
+		return
+	}
+
+	if err := s.repository.DeleteThreshold(ctx, thresholdID); err != nil {
+		s.logger.Error("Failed to delete threshold", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete threshold"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Threshold deleted successfully"})
+}
+
+func (s *Service) bulkThresholdsHandler(c *gin.Context) {
+	var req BulkThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold data", "details": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := s.BulkSetThresholds(ctx, &req)
+	switch {
+	case errors.Is(err, ErrInvalidThresholdRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDeviceDirectoryUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to apply bulk thresholds", "error", err)
+		response := gin.H{"error": "Failed to apply thresholds"}
+		if result != nil {
+			response["created"] = result.Created
+			response["updated"] = result.Updated
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	s.logger.Info("Applied thresholds", "thresholds", len(req.Thresholds), "targets", result.Targets,
+		"created", result.Created, "updated", result.Updated)
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Service) listTemplateThresholdsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	thresholds, err := s.ListTemplateThresholds(ctx, c.Param("templateId"))
+	if err != nil {
+		s.logger.Error("Failed to list template thresholds", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve thresholds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thresholds": thresholds,
+		"count":      len(thresholds),
+	})
+}
+
+func (s *Service) effectiveThresholdsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+
+	effective, err := s.ResolveThresholds(ctx, c.Param("deviceId"), c.Query("template_id"))
+	if err != nil {
+		s.logger.Error("Failed to resolve thresholds", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve thresholds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, effective)
+}
+
 func (s *Service) listAlertsHandler(c *gin.Context) {
 	deviceID := c.Param("deviceId")
 	status := c.DefaultQuery("status", "")
@@ -367,7 +783,7 @@ func (s *Service) listAlertsHandler(c *gin.Context) {
 
 	alerts, err := s.repository.ListAlerts(ctx, deviceID, status)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to list alerts: %v", err))
+		s.logger.Error("Failed to list alerts", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
 		return
 	}
@@ -386,7 +802,7 @@ func (s *Service) acknowledgeAlertHandler(c *gin.Context) {
 	defer cancel()
 
 	if err := s.repository.AcknowledgeAlert(ctx, alertID); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to acknowledge alert: %v", err))
+		s.logger.Error("Failed to acknowledge alert", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
 		return
 	}
@@ -401,7 +817,7 @@ func (s *Service) resolveAlertHandler(c *gin.Context) {
 	defer cancel()
 
 	if err := s.repository.ResolveAlert(ctx, alertID); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to resolve alert: %v", err))
+		s.logger.Error("Failed to resolve alert", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve alert"})
 		return
 	}
@@ -421,7 +837,7 @@ func (s *Service) aggregateMetricsHandler(c *gin.Context) {
 
 	results, err := s.repository.AggregateMetrics(ctx, &query)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to aggregate metrics: %v", err))
+		s.logger.Error("Failed to aggregate metrics", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate metrics"})
 		return
 	}
@@ -435,6 +851,61 @@ func (s *Service) aggregateMetricsHandler(c *gin.Context) {
 	})
 }
 
+func (s *Service) forecastHandler(c *gin.Context) {
+	req := &ForecastRequest{
+		DeviceID:   c.Param("deviceId"),
+		MetricName: c.Param("metric"),
+		Method:     ForecastMethod(c.Query("method")),
+	}
+
+	durations := map[string]*time.Duration{
+		"horizon": &req.Horizon,
+		"history": &req.History,
+		"step":    &req.Step,
+		"season":  &req.Season,
+	}
+	for param, target := range durations {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s duration", param)})
+			return
+		}
+		*target = duration
+	}
+
+	if value := c.Query("threshold"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold"})
+			return
+		}
+		req.Threshold = &threshold
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+
+	forecast, err := s.forecaster.Forecast(ctx, req)
+	switch {
+	case errors.Is(err, ErrInvalidForecastRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInsufficientData):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to forecast metric", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to forecast metric"})
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
+
 func (s *Service) streamDeviceDataHandler(c *gin.Context) {
 	if s.streamManager == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Streaming not available"})
@@ -444,6 +915,15 @@ func (s *Service) streamDeviceDataHandler(c *gin.Context) {
 	s.streamManager.HandleWebSocket(c)
 }
 
+func (s *Service) streamGroupDataHandler(c *gin.Context) {
+	if s.streamManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Streaming not available"})
+		return
+	}
+
+	s.streamManager.HandleGroupWebSocket(c)
+}
+
 func (s *Service) exportDataHandler(c *gin.Context) {
 	var request ExportRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -451,8 +931,12 @@ func (s *Service) exportDataHandler(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	// The export is streamed for as long as the client keeps reading, so it
+	// ends with the request or the service rather than after a timeout
+	ctx, cancel := context.WithCancel(c.Request.Context())
 	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
 
 	// Set content type based on format
 	switch request.Format {
@@ -467,8 +951,14 @@ func (s *Service) exportDataHandler(c *gin.Context) {
 		return
 	}
 
+	// Without a Content-Length the response is sent with chunked transfer
+	// encoding, flushed after every read of the repository
 	if err := s.exporter.Export(ctx, &request, c.Writer); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to export data: %v", err))
+		s.logger.Error("Failed to export data", "device_id", request.DeviceID, "error", err)
+		if c.Writer.Written() {
+			// The status is sent; the truncated body tells the client
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
 		return
 	}
@@ -502,7 +992,7 @@ func (s *Service) exportAggregatedHandler(c *gin.Context) {
 	}
 
 	if err := s.exporter.ExportAggregated(ctx, &request.Query, request.Format, c.Writer); err != nil {
-		s.logger.Error(fmt.Sprintf("Failed to export aggregated data: %v", err))
+		s.logger.Error("Failed to export aggregated data", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export aggregated data"})
 		return
 	}
@@ -532,6 +1022,12 @@ func (s *Service) addNotificationChannelHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification channel config", "details": err.Error()})
 		return
 	}
+	if config.Digest != nil {
+		if err := config.Digest.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification channel config", "details": err.Error()})
+			return
+		}
+	}
 
 	s.alertNotifier.AddChannel(config)
 	c.JSON(http.StatusCreated, gin.H{"message": "Notification channel added successfully"})
@@ -565,3 +1061,326 @@ func (s *Service) deleteNotificationChannelHandler(c *gin.Context) {
 	s.alertNotifier.RemoveChannel(channel)
 	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted successfully"})
 }
+
+func (s *Service) setNotificationDigestHandler(c *gin.Context) {
+	if s.alertNotifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Alert notifications not available"})
+		return
+	}
+
+	var digest DigestConfig
+	if err := c.ShouldBindJSON(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest config", "details": err.Error()})
+		return
+	}
+	if err := digest.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest config", "details": err.Error()})
+		return
+	}
+
+	if err := s.alertNotifier.SetDigest(NotificationChannel(c.Param("channel")), &digest); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Digest mode enabled", "digest": digest})
+}
+
+func (s *Service) clearNotificationDigestHandler(c *gin.Context) {
+	if s.alertNotifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Alert notifications not available"})
+		return
+	}
+
+	if err := s.alertNotifier.SetDigest(NotificationChannel(c.Param("channel")), nil); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Digest mode disabled"})
+}
+
+func (s *Service) listDerivedMetricsHandler(c *gin.Context) {
+	metrics := s.derived.List()
+	c.JSON(http.StatusOK, gin.H{
+		"derived_metrics": metrics,
+		"count":           len(metrics),
+	})
+}
+
+func (s *Service) createDerivedMetricHandler(c *gin.Context) {
+	var req DerivedMetricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid derived metric", "details": err.Error()})
+		return
+	}
+
+	metric, err := s.derived.Create(c.Request.Context(), &req)
+	if errors.Is(err, ErrDerivedMetricExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errDerivedMetricStorage) {
+		s.logger.Error("Failed to store derived metric", "metric", req.Name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store derived metric"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Derived metric defined", "metric", metric.Name, "expression", metric.Expression, "mode", metric.Mode)
+	c.JSON(http.StatusCreated, metric)
+}
+
+func (s *Service) getDerivedMetricHandler(c *gin.Context) {
+	metric, err := s.derived.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metric)
+}
+
+func (s *Service) updateDerivedMetricHandler(c *gin.Context) {
+	var req DerivedMetricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid derived metric", "details": err.Error()})
+		return
+	}
+	req.Name = c.Param("name")
+
+	metric, err := s.derived.Update(c.Request.Context(), &req)
+	if errors.Is(err, ErrDerivedMetricNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errDerivedMetricStorage) {
+		s.logger.Error("Failed to store derived metric", "metric", req.Name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store derived metric"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metric)
+}
+
+func (s *Service) deleteDerivedMetricHandler(c *gin.Context) {
+	err := s.derived.Delete(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, ErrDerivedMetricNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errDerivedMetricStorage) {
+		s.logger.Error("Failed to delete derived metric", "metric", c.Param("name"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete derived metric"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Derived metric deleted successfully"})
+}
+
+func (s *Service) listCloudBridgesHandler(c *gin.Context) {
+	bridges := s.CloudBridges()
+	c.JSON(http.StatusOK, gin.H{
+		"bridges": bridges,
+		"count":   len(bridges),
+	})
+}
+
+// cloudEventsHandler receives Event Grid deliveries for Azure bridges. Event
+// Grid cannot present platform credentials, so the subscription URL carries
+// the bridge's webhook secret as the code query parameter.
+func (s *Service) cloudEventsHandler(c *gin.Context) {
+	bridge, err := s.bridges.get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if secret := bridge.config.WebhookSecret; secret != "" &&
+		subtle.ConstantTimeCompare([]byte(c.Query("code")), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook code"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	response, err := s.HandleCloudEvents(ctx, bridge.config.Name, body)
+	switch {
+	case errors.Is(err, ErrCloudBridgeUnsupported):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if response != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (s *Service) syncDeviceStateHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+	defer cancel()
+
+	err := s.SyncDeviceState(ctx, c.Param("name"), c.Param("deviceId"))
+	switch {
+	case errors.Is(err, ErrCloudBridgeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrCloudBridgeUnsupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrDeviceStateUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to sync device state", "device_id", c.Param("deviceId"), "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sync device state", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Device state sync requested"})
+}
+
+// lorawanUplinkHandler receives uplink webhooks from a LoRaWAN network
+// server. Like Event Grid, the network servers are configured with a fixed
+// URL, so it carries the webhook secret as the code query parameter.
+func (s *Service) lorawanUplinkHandler(c *gin.Context) {
+	if secret := s.config.Telemetry.LoRaWAN.WebhookSecret; secret != "" &&
+		subtle.ConstantTimeCompare([]byte(c.Query("code")), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook code"})
+		return
+	}
+	// ChirpStack posts every event type to the same URL
+	if event := c.Query("event"); event != "" && event != "up" {
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	data, err := s.IngestLoRaWANUplink(LoRaWANNetwork(c.Param("network")), body)
+	switch {
+	case errors.Is(err, ErrLoRaWANNetworkUnsupported), errors.Is(err, ErrUplinkDevice):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrUplinkDecode):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidUplink):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to ingest LoRaWAN uplink", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ingest uplink"})
+		return
+	}
+
+	if data == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Uplink has no application payload"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Uplink ingested", "device_id": data.DeviceID, "metrics": data.Metrics})
+}
+
+func (s *Service) listPayloadDecodersHandler(c *gin.Context) {
+	decoders := s.decoders.List()
+	c.JSON(http.StatusOK, gin.H{
+		"decoders": decoders,
+		"count":    len(decoders),
+	})
+}
+
+func (s *Service) getPayloadDecoderHandler(c *gin.Context) {
+	decoder, err := s.decoders.Get(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, decoder)
+}
+
+func (s *Service) setPayloadDecoderHandler(c *gin.Context) {
+	var req PayloadDecoderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload decoder", "details": err.Error()})
+		return
+	}
+
+	decoder, err := s.SetPayloadDecoder(c.Request.Context(), c.Param("templateId"), &req)
+	if errors.Is(err, errPayloadDecoderStorage) {
+		s.logger.Error("Failed to store payload decoder", "template_id", c.Param("templateId"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store payload decoder"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Payload decoder set", "template_id", decoder.TemplateID, "fields", len(decoder.Fields))
+	c.JSON(http.StatusOK, decoder)
+}
+
+func (s *Service) deletePayloadDecoderHandler(c *gin.Context) {
+	if err := s.decoders.Delete(c.Request.Context(), c.Param("templateId")); err != nil {
+		if errors.Is(err, errPayloadDecoderStorage) {
+			s.logger.Error("Failed to delete stored payload decoder", "template_id", c.Param("templateId"), "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete payload decoder"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payload decoder deleted successfully"})
+}
+
+// testPayloadDecoderHandler decodes a sample payload without storing it, so
+// decoders can be checked against captured uplinks
+func (s *Service) testPayloadDecoderHandler(c *gin.Context) {
+	var req struct {
+		Payload []byte `json:"payload" binding:"required"`
+		FPort   int    `json:"f_port"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test payload", "details": err.Error()})
+		return
+	}
+
+	decoder, err := s.decoders.Get(c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	metrics, err := decoder.Decode(req.Payload, req.FPort)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+}
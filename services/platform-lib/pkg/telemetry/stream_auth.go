@@ -0,0 +1,166 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrStreamForbidden is returned when a principal may not read the device
+// or group it subscribes to
+var ErrStreamForbidden = errors.New("stream access forbidden")
+
+// StreamPrincipal is the authenticated caller of a telemetry stream, as set
+// on the request context by the authentication middleware
+type StreamPrincipal struct {
+	UserID      string
+	Roles       []string
+	Permissions []string
+}
+
+// streamPrincipalFromContext returns the principal of a request, or nil if
+// the request is not authenticated
+func streamPrincipalFromContext(c *gin.Context) *StreamPrincipal {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return nil
+	}
+	return &StreamPrincipal{
+		UserID:      userID,
+		Roles:       c.GetStringSlice("roles"),
+		Permissions: c.GetStringSlice("permissions"),
+	}
+}
+
+// StreamAuthorizer decides which devices and groups a principal may stream
+type StreamAuthorizer interface {
+	// AuthorizeDevice returns nil if principal may read the telemetry of
+	// deviceID, and ErrStreamForbidden otherwise
+	AuthorizeDevice(ctx context.Context, principal *StreamPrincipal, deviceID string) error
+	// AuthorizeGroup returns the current members of group if principal may
+	// read it, and ErrStreamForbidden otherwise
+	AuthorizeGroup(ctx context.Context, principal *StreamPrincipal, group string) ([]string, error)
+}
+
+// GroupDirectory looks up device groups. device.GroupStore satisfies it.
+type GroupDirectory interface {
+	GetGroup(ctx context.Context, name string) (*device.DeviceGroup, error)
+}
+
+// streamReadRoles may read the telemetry of every device
+var streamReadRoles = []string{"admin", "operator", "viewer"}
+
+// GroupStreamAuthorizer grants streams from a principal's roles and
+// permissions. The admin, operator and viewer roles and the read permission
+// cover every device. A "group:<name>" permission covers the group and its
+// members, and a "device:<id>" permission a single device.
+type GroupStreamAuthorizer struct {
+	devices DeviceDirectory
+	groups  GroupDirectory
+}
+
+// NewGroupStreamAuthorizer creates an authorizer resolving group members
+// through devices and groups
+func NewGroupStreamAuthorizer(devices DeviceDirectory, groups GroupDirectory) *GroupStreamAuthorizer {
+	return &GroupStreamAuthorizer{devices: devices, groups: groups}
+}
+
+// AuthorizeDevice checks the principal's grants for a device, looking up
+// the groups it was granted until one contains the device
+func (a *GroupStreamAuthorizer) AuthorizeDevice(ctx context.Context, principal *StreamPrincipal, deviceID string) error {
+	if principal == nil {
+		return ErrStreamForbidden
+	}
+	if readsAll(principal) || slices.Contains(principal.Permissions, "device:"+deviceID) {
+		return nil
+	}
+
+	groups := grantedGroups(principal)
+	if len(groups) == 0 {
+		return ErrStreamForbidden
+	}
+	if a.devices == nil {
+		return ErrDeviceDirectoryUnavailable
+	}
+	d, err := a.devices.GetDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to look up device %s: %w", deviceID, err)
+	}
+	for _, name := range groups {
+		group, err := a.groups.GetGroup(ctx, name)
+		if errors.Is(err, device.ErrGroupNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if group.Contains(d) {
+			return nil
+		}
+	}
+	return ErrStreamForbidden
+}
+
+// AuthorizeGroup checks the principal's grants for a group and resolves its
+// members: the devices listed in the group and those matching its labels
+func (a *GroupStreamAuthorizer) AuthorizeGroup(ctx context.Context, principal *StreamPrincipal, name string) ([]string, error) {
+	if principal == nil || !(readsAll(principal) || slices.Contains(principal.Permissions, "group:"+name)) {
+		return nil, ErrStreamForbidden
+	}
+	group, err := a.groups.GetGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	members := slices.Clone(group.Devices)
+	if len(group.Labels) > 0 {
+		if a.devices == nil {
+			return nil, ErrDeviceDirectoryUnavailable
+		}
+		labelled, err := a.devices.ListDevices(ctx, &device.DeviceFilters{Labels: group.Labels})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices of group %s: %w", name, err)
+		}
+		for _, d := range labelled {
+			if !slices.Contains(members, d.DeviceID) {
+				members = append(members, d.DeviceID)
+			}
+		}
+	}
+	return members, nil
+}
+
+// SetStreamAuthorizer requires WebSocket streams to be authorized for the
+// principal of the request. The principal is taken from the user_id, roles
+// and permissions set by the authentication middleware.
+func (s *Service) SetStreamAuthorizer(authorizer StreamAuthorizer) {
+	if s.streamManager != nil {
+		s.streamManager.SetAuthorizer(authorizer)
+	}
+}
+
+// readsAll reports whether a principal may read every device
+func readsAll(principal *StreamPrincipal) bool {
+	for _, role := range principal.Roles {
+		if slices.Contains(streamReadRoles, role) {
+			return true
+		}
+	}
+	return slices.Contains(principal.Permissions, "read")
+}
+
+// grantedGroups returns the groups named by a principal's permissions
+func grantedGroups(principal *StreamPrincipal) []string {
+	var groups []string
+	for _, permission := range principal.Permissions {
+		if name, ok := strings.CutPrefix(permission, "group:"); ok && name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
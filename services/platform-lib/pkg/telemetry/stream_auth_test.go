@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStreamServer serves authorized streams. Requests authenticate as the
+// X-User header with the comma separated X-Roles and X-Permissions.
+func newStreamServer(t *testing.T) (*httptest.Server, *StreamManager, *device.MemoryGroupStore) {
+	gin.SetMode(gin.TestMode)
+	groups := device.NewMemoryGroupStore()
+	require.NoError(t, groups.CreateGroup(context.Background(), &device.DeviceGroup{Name: "lab", Devices: []string{"dev-1"}}))
+
+	manager := NewStreamManager(logger.New("info", "telemetry-service"), &memoryRepository{})
+	manager.SetAuthorizer(NewGroupStreamAuthorizer(deviceDirectory{{DeviceID: "dev-1"}, {DeviceID: "dev-3"}}, groups))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("user_id", user)
+			c.Set("roles", strings.Split(c.GetHeader("X-Roles"), ","))
+			c.Set("permissions", strings.Split(c.GetHeader("X-Permissions"), ","))
+		}
+	})
+	router.GET("/stream/:deviceId", manager.HandleWebSocket)
+	router.GET("/stream/groups/:group", manager.HandleGroupWebSocket)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, manager, groups
+}
+
+func dialStream(t *testing.T, server *httptest.Server, path, user, roles, permissions string) (*websocket.Conn, int) {
+	header := http.Header{}
+	if user != "" {
+		header.Set("X-User", user)
+		header.Set("X-Roles", roles)
+		header.Set("X-Permissions", permissions)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, header)
+	if err != nil {
+		require.NotNil(t, resp, err)
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp.StatusCode
+}
+
+func readTelemetry(t *testing.T, conn *websocket.Conn) *TelemetryData {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var data TelemetryData
+	require.NoError(t, json.Unmarshal(message, &data))
+	return &data
+}
+
+// requireRevoked expects the server to close conn because access was revoked
+func requireRevoked(t *testing.T, conn *websocket.Conn) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "unexpected error %v", err)
+}
+
+func TestStreamManager_Authorization(t *testing.T) {
+	server, manager, _ := newStreamServer(t)
+
+	_, status := dialStream(t, server, "/stream/dev-1", "", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	_, status = dialStream(t, server, "/stream/dev-3", "alice", "", "group:lab")
+	assert.Equal(t, http.StatusForbidden, status)
+	_, status = dialStream(t, server, "/stream/groups/other", "alice", "", "group:lab")
+	assert.Equal(t, http.StatusForbidden, status)
+	_, status = dialStream(t, server, "/stream/groups/missing", "bob", "viewer", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	deviceStream, status := dialStream(t, server, "/stream/dev-1", "alice", "", "group:lab")
+	require.Equal(t, http.StatusSwitchingProtocols, status)
+	groupStream, status := dialStream(t, server, "/stream/groups/lab", "alice", "", "group:lab")
+	require.Equal(t, http.StatusSwitchingProtocols, status)
+	otherStream, status := dialStream(t, server, "/stream/dev-3", "carol", "", "device:dev-3")
+	require.Equal(t, http.StatusSwitchingProtocols, status)
+	require.Eventually(t, func() bool { return len(manager.streams()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// Group streams receive the telemetry of their members only
+	manager.BroadcastTelemetry("dev-3", &TelemetryData{DeviceID: "dev-3"})
+	manager.BroadcastTelemetry("dev-1", &TelemetryData{DeviceID: "dev-1"})
+	assert.Equal(t, "dev-1", readTelemetry(t, deviceStream).DeviceID)
+	assert.Equal(t, "dev-1", readTelemetry(t, groupStream).DeviceID)
+	assert.Equal(t, "dev-3", readTelemetry(t, otherStream).DeviceID)
+}
+
+func TestStreamManager_Revocation(t *testing.T) {
+	server, manager, groups := newStreamServer(t)
+	ctx := context.Background()
+
+	deviceStream, _ := dialStream(t, server, "/stream/dev-1", "alice", "", "group:lab")
+	groupStream, _ := dialStream(t, server, "/stream/groups/lab", "alice", "", "group:lab")
+	otherStream, _ := dialStream(t, server, "/stream/dev-3", "carol", "", "device:dev-3")
+	require.Eventually(t, func() bool { return len(manager.streams()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, manager.Reauthorize(ctx))
+
+	// Removing the device from the group revokes its stream and the group
+	// stream stops receiving it
+	_, err := groups.ModifyGroup(ctx, "lab", func(g *device.DeviceGroup) error {
+		g.Devices = []string{"dev-3"}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, manager.Reauthorize(ctx))
+	requireRevoked(t, deviceStream)
+	require.Eventually(t, func() bool { return manager.GetActiveConnections("dev-1") == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	manager.BroadcastTelemetry("dev-1", &TelemetryData{DeviceID: "dev-1"})
+	manager.BroadcastTelemetry("dev-3", &TelemetryData{DeviceID: "dev-3"})
+	assert.Equal(t, "dev-3", readTelemetry(t, groupStream).DeviceID)
+	assert.Equal(t, "dev-3", readTelemetry(t, otherStream).DeviceID)
+
+	require.NoError(t, groups.DeleteGroup(ctx, "lab"))
+	assert.Equal(t, 1, manager.Reauthorize(ctx))
+	requireRevoked(t, groupStream)
+
+	assert.Equal(t, 1, manager.RevokePrincipal("carol"))
+	requireRevoked(t, otherStream)
+	require.Eventually(t, func() bool { return len(manager.streams()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -3,29 +3,65 @@ package telemetry
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-// StreamManager manages WebSocket connections for real-time telemetry streaming
+// StreamManager manages WebSocket connections for real-time telemetry
+// streaming. Clients stream a single device or a device group. With an
+// authorizer set, every connection is authorized for its principal when it
+// opens and again periodically, and closed once access is revoked.
 type StreamManager struct {
-	clients    map[string]map[*websocket.Conn]bool // deviceID -> connections
+	clients    map[string]map[*websocket.Conn]*streamClient // deviceID -> connections
+	groups     map[string]map[*websocket.Conn]*streamClient // group -> connections
 	mu         sync.RWMutex
 	logger     *logger.Logger
 	repository Repository
 	upgrader   websocket.Upgrader
+	authorizer StreamAuthorizer
+}
+
+// streamClient is a connection and what it streams. Group streams carry
+// the group's members as of their last authorization.
+type streamClient struct {
+	conn      *websocket.Conn
+	principal *StreamPrincipal
+	deviceID  string
+	group     string
+	members   map[string]bool
+	writeMu   sync.Mutex
+}
+
+// write sends a message, serializing writers of the connection
+func (sc *streamClient) write(message []byte) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	return sc.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// revoke closes the connection with a policy violation, ending its read
+// loop
+func (sc *streamClient) revoke(reason string) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	_ = sc.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+	sc.conn.Close()
 }
 
 // NewStreamManager creates a new stream manager
 func NewStreamManager(logger *logger.Logger, repository Repository) *StreamManager {
 	return &StreamManager{
-		clients:    make(map[string]map[*websocket.Conn]bool),
+		clients:    make(map[string]map[*websocket.Conn]*streamClient),
+		groups:     make(map[string]map[*websocket.Conn]*streamClient),
 		logger:     logger,
 		repository: repository,
 		upgrader: websocket.Upgrader{
@@ -39,96 +75,306 @@ func NewStreamManager(logger *logger.Logger, repository Repository) *StreamManag
 	}
 }
 
+// SetAuthorizer requires streams to be authorized for their principal.
+// Without an authorizer streams are open to every caller.
+func (sm *StreamManager) SetAuthorizer(authorizer StreamAuthorizer) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.authorizer = authorizer
+}
+
 // HandleWebSocket handles WebSocket connections for device telemetry streaming
 func (sm *StreamManager) HandleWebSocket(c *gin.Context) {
 	deviceID := c.Param("deviceId")
+	client := &streamClient{principal: streamPrincipalFromContext(c), deviceID: deviceID}
 
-	conn, err := sm.upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to upgrade WebSocket connection: %v", err))
+	if authorizer := sm.currentAuthorizer(); authorizer != nil {
+		if !sm.requirePrincipal(c, client.principal) {
+			return
+		}
+		if err := authorizer.AuthorizeDevice(c.Request.Context(), client.principal, deviceID); err != nil {
+			sm.respondAuthorizationError(c, err)
+			return
+		}
+	}
+
+	if !sm.accept(c, client) {
 		return
 	}
-	defer conn.Close()
+	defer client.conn.Close()
 
 	// Register client
-	sm.registerClient(deviceID, conn)
-	defer sm.unregisterClient(deviceID, conn)
+	sm.registerClient(client)
+	defer sm.unregisterClient(client)
 
-	sm.logger.Info(fmt.Sprintf("WebSocket client connected for device %s", deviceID))
+	sm.logger.Info("WebSocket client connected", "device_id", deviceID)
 
 	// Send initial historical data
-	if err := sm.sendHistoricalData(conn, deviceID); err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to send historical data: %v", err))
+	if err := sm.sendHistoricalData(client, deviceID); err != nil {
+		sm.logger.Error("Failed to send historical data", "error", err)
+	}
+
+	sm.readUntilClosed(client.conn)
+	sm.logger.Info("WebSocket client disconnected", "device_id", deviceID)
+}
+
+// HandleGroupWebSocket streams the live telemetry of every member of a
+// device group
+func (sm *StreamManager) HandleGroupWebSocket(c *gin.Context) {
+	group := c.Param("group")
+	client := &streamClient{principal: streamPrincipalFromContext(c), group: group}
+
+	authorizer := sm.currentAuthorizer()
+	if authorizer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Group streaming not available"})
+		return
+	}
+	if !sm.requirePrincipal(c, client.principal) {
+		return
+	}
+	members, err := authorizer.AuthorizeGroup(c.Request.Context(), client.principal, group)
+	if err != nil {
+		sm.respondAuthorizationError(c, err)
+		return
+	}
+	client.members = memberSet(members)
+
+	if !sm.accept(c, client) {
+		return
+	}
+	defer client.conn.Close()
+
+	sm.registerClient(client)
+	defer sm.unregisterClient(client)
+
+	sm.logger.Info("WebSocket client connected", "group", group, "members", len(members))
+	sm.readUntilClosed(client.conn)
+	sm.logger.Info("WebSocket client disconnected", "group", group)
+}
+
+func (sm *StreamManager) currentAuthorizer() StreamAuthorizer {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.authorizer
+}
+
+// requirePrincipal rejects unauthenticated requests
+func (sm *StreamManager) requirePrincipal(c *gin.Context, principal *StreamPrincipal) bool {
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return false
+	}
+	return true
+}
+
+func (sm *StreamManager) respondAuthorizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrStreamForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to stream this telemetry"})
+	case errors.Is(err, device.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		sm.logger.Error("Failed to authorize telemetry stream", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize telemetry stream"})
+	}
+}
+
+// accept upgrades the request to a WebSocket connection of client
+func (sm *StreamManager) accept(c *gin.Context, client *streamClient) bool {
+	conn, err := sm.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		sm.logger.Error("Failed to upgrade WebSocket connection", "error", err)
+		return false
 	}
+	client.conn = conn
+	return true
+}
 
-	// Keep connection alive and handle incoming messages
+// readUntilClosed keeps the connection alive until the client disconnects
+// or the connection is closed
+func (sm *StreamManager) readUntilClosed(conn *websocket.Conn) {
 	for {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				sm.logger.Error(fmt.Sprintf("WebSocket error: %v", err))
+				sm.logger.Error("WebSocket error", "error", err)
 			}
-			break
+			return
 		}
 	}
-
-	sm.logger.Info(fmt.Sprintf("WebSocket client disconnected for device %s", deviceID))
 }
 
-// registerClient registers a WebSocket connection for a device
-func (sm *StreamManager) registerClient(deviceID string, conn *websocket.Conn) {
+// registerClient registers a WebSocket connection for a device or group
+func (sm *StreamManager) registerClient(client *streamClient) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.clients[deviceID] == nil {
-		sm.clients[deviceID] = make(map[*websocket.Conn]bool)
+	index, key := sm.index(client)
+	if index[key] == nil {
+		index[key] = make(map[*websocket.Conn]*streamClient)
 	}
-	sm.clients[deviceID][conn] = true
+	index[key][client.conn] = client
 }
 
 // unregisterClient unregisters a WebSocket connection
-func (sm *StreamManager) unregisterClient(deviceID string, conn *websocket.Conn) {
+func (sm *StreamManager) unregisterClient(client *streamClient) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if clients, exists := sm.clients[deviceID]; exists {
-		delete(clients, conn)
+	index, key := sm.index(client)
+	if clients, exists := index[key]; exists {
+		delete(clients, client.conn)
 		if len(clients) == 0 {
-			delete(sm.clients, deviceID)
+			delete(index, key)
 		}
 	}
 }
 
-// BroadcastTelemetry broadcasts telemetry data to all connected clients for a device
+// index returns the connection map and key of a client. Callers hold mu.
+func (sm *StreamManager) index(client *streamClient) (map[string]map[*websocket.Conn]*streamClient, string) {
+	if client.group != "" {
+		return sm.groups, client.group
+	}
+	return sm.clients, client.deviceID
+}
+
+// BroadcastTelemetry broadcasts telemetry data to all connected clients for
+// a device, including group streams the device is a member of
 func (sm *StreamManager) BroadcastTelemetry(deviceID string, data *TelemetryData) {
 	sm.mu.RLock()
-	clients, exists := sm.clients[deviceID]
+	var targets []*streamClient
+	for _, client := range sm.clients[deviceID] {
+		targets = append(targets, client)
+	}
+	for _, clients := range sm.groups {
+		for _, client := range clients {
+			if client.members[deviceID] {
+				targets = append(targets, client)
+			}
+		}
+	}
 	sm.mu.RUnlock()
 
-	if !exists || len(clients) == 0 {
+	if len(targets) == 0 {
 		return
 	}
 
 	message, err := json.Marshal(data)
 	if err != nil {
-		sm.logger.Error(fmt.Sprintf("Failed to marshal telemetry data: %v", err))
+		sm.logger.Error("Failed to marshal telemetry data", "error", err)
 		return
 	}
 
+	// Failed connections are closed; their read loop unregisters them
+	for _, client := range targets {
+		if err := client.write(message); err != nil {
+			sm.logger.Error("Failed to send message to WebSocket client", "error", err)
+			client.conn.Close()
+		}
+	}
+}
+
+// Reauthorize checks every open stream against the authorizer again,
+// refreshing the members of group streams and closing streams whose
+// principal lost access. Streams are kept when the check itself fails, so
+// a directory outage does not disconnect everyone. It returns the number
+// of streams closed.
+func (sm *StreamManager) Reauthorize(ctx context.Context) int {
+	authorizer := sm.currentAuthorizer()
+	if authorizer == nil {
+		return 0
+	}
+
+	revoked := 0
+	for _, client := range sm.streams() {
+		var err error
+		if client.group != "" {
+			var members []string
+			if members, err = authorizer.AuthorizeGroup(ctx, client.principal, client.group); err == nil {
+				sm.mu.Lock()
+				client.members = memberSet(members)
+				sm.mu.Unlock()
+			}
+		} else {
+			err = authorizer.AuthorizeDevice(ctx, client.principal, client.deviceID)
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrStreamForbidden), errors.Is(err, device.ErrGroupNotFound):
+			sm.logger.Info("Closing telemetry stream after access was revoked",
+				"user_id", principalID(client.principal), "device_id", client.deviceID, "group", client.group)
+			client.revoke("permission revoked")
+			revoked++
+		default:
+			sm.logger.Warn("Failed to reauthorize telemetry stream", "error", err)
+		}
+	}
+	return revoked
+}
+
+// RevokePrincipal closes every stream of a user at once, for example when
+// the user's sessions are revoked. It returns the number of streams closed.
+func (sm *StreamManager) RevokePrincipal(userID string) int {
+	revoked := 0
+	for _, client := range sm.streams() {
+		if client.principal != nil && client.principal.UserID == userID {
+			client.revoke("permission revoked")
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// startReauthorizing reauthorizes streams every interval until ctx is done
+func (sm *StreamManager) startReauthorizing(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sm.Reauthorize(ctx)
+			}
+		}
+	}()
+}
+
+// streams returns every open stream
+func (sm *StreamManager) streams() []*streamClient {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	for conn := range clients {
-		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			sm.logger.Error(fmt.Sprintf("Failed to send message to WebSocket client: %v", err))
-			conn.Close()
-			delete(clients, conn)
+	var streams []*streamClient
+	for _, index := range []map[string]map[*websocket.Conn]*streamClient{sm.clients, sm.groups} {
+		for _, clients := range index {
+			for _, client := range clients {
+				streams = append(streams, client)
+			}
 		}
 	}
+	return streams
+}
+
+func memberSet(members []string) map[string]bool {
+	set := make(map[string]bool, len(members))
+	for _, id := range members {
+		set[id] = true
+	}
+	return set
+}
+
+func principalID(principal *StreamPrincipal) string {
+	if principal == nil {
+		return ""
+	}
+	return principal.UserID
 }
 
 // sendHistoricalData sends recent historical data to a newly connected client
-func (sm *StreamManager) sendHistoricalData(conn *websocket.Conn, deviceID string) error {
+func (sm *StreamManager) sendHistoricalData(client *streamClient, deviceID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -150,7 +396,7 @@ func (sm *StreamManager) sendHistoricalData(conn *websocket.Conn, deviceID strin
 			continue
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		if err := client.write(message); err != nil {
 			return fmt.Errorf("failed to send historical data: %w", err)
 		}
 	}
@@ -174,10 +420,12 @@ func (sm *StreamManager) CloseAllConnections() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	for deviceID, clients := range sm.clients {
-		for conn := range clients {
-			conn.Close()
+	for _, index := range []map[string]map[*websocket.Conn]*streamClient{sm.clients, sm.groups} {
+		for key, clients := range index {
+			for conn := range clients {
+				conn.Close()
+			}
+			delete(index, key)
 		}
-		delete(sm.clients, deviceID)
 	}
 }
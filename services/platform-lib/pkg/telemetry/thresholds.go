@@ -0,0 +1,275 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+)
+
+// ThresholdScope is the level a threshold is defined at. Template thresholds
+// apply to every device built from the template; a device threshold for the
+// same metric and operator overrides the inherited one.
+type ThresholdScope string
+
+const (
+	ThresholdScopeTemplate ThresholdScope = "template"
+	ThresholdScopeDevice   ThresholdScope = "device"
+)
+
+var (
+	// ErrInvalidThresholdRequest is returned for malformed bulk requests
+	ErrInvalidThresholdRequest = errors.New("invalid threshold request")
+	// ErrDeviceDirectoryUnavailable is returned when a request needs device
+	// metadata and no device directory is configured
+	ErrDeviceDirectoryUnavailable = errors.New("device directory not configured")
+)
+
+var thresholdOperators = map[string]bool{"gt": true, "lt": true, "eq": true, "gte": true, "lte": true}
+var thresholdSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+// DeviceDirectory looks up device metadata for template inheritance and
+// label selectors. device.Repository satisfies it.
+type DeviceDirectory interface {
+	GetDevice(ctx context.Context, deviceID string) (*device.Device, error)
+	ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error)
+}
+
+// ScopedThreshold is a stored threshold together with where it is defined
+type ScopedThreshold struct {
+	ThresholdID string         `json:"threshold_id"`
+	Scope       ThresholdScope `json:"scope"`
+	ScopeID     string         `json:"scope_id"`
+	AlertThreshold
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// thresholdKey identifies the threshold a device override replaces
+func (t *ScopedThreshold) thresholdKey() string {
+	return t.MetricName + "#" + t.Operator
+}
+
+// BulkThresholdRequest creates or updates thresholds for a template or for
+// a group of devices given by IDs and/or a label selector. Existing
+// thresholds with the same metric and operator are updated in place.
+type BulkThresholdRequest struct {
+	TemplateID string            `json:"template_id,omitempty"`
+	DeviceIDs  []string          `json:"device_ids,omitempty"`
+	Selector   map[string]string `json:"selector,omitempty"`
+	Thresholds []*AlertThreshold `json:"thresholds" binding:"required,min=1"`
+}
+
+// BulkThresholdResult summarises a bulk threshold write
+type BulkThresholdResult struct {
+	Targets    int                `json:"targets"`
+	Created    int                `json:"created"`
+	Updated    int                `json:"updated"`
+	Thresholds []*ScopedThreshold `json:"thresholds"`
+}
+
+// EffectiveThreshold is a threshold that applies to a device. Scope and
+// ScopeID say where the value comes from; Overrides is the template
+// threshold a device threshold replaces.
+type EffectiveThreshold struct {
+	*ScopedThreshold
+	Overrides *ScopedThreshold `json:"overrides,omitempty"`
+}
+
+// EffectiveThresholds is the resolved threshold set for a device
+type EffectiveThresholds struct {
+	DeviceID   string                `json:"device_id"`
+	TemplateID string                `json:"template_id,omitempty"`
+	Thresholds []*EffectiveThreshold `json:"thresholds"`
+}
+
+// SetDeviceDirectory enables template lookup and label selectors for
+// threshold inheritance, and device lookup for Home Assistant discovery
+func (s *Service) SetDeviceDirectory(devices DeviceDirectory) {
+	s.devices = devices
+	if s.homeAssistant != nil {
+		s.homeAssistant.devices = devices
+	}
+}
+
+func validateThreshold(threshold *AlertThreshold) error {
+	if threshold == nil || threshold.MetricName == "" {
+		return fmt.Errorf("%w: metric_name is required", ErrInvalidThresholdRequest)
+	}
+	if !thresholdOperators[threshold.Operator] {
+		return fmt.Errorf("%w: unsupported operator %q for %s", ErrInvalidThresholdRequest, threshold.Operator, threshold.MetricName)
+	}
+	if threshold.Severity != "" && !thresholdSeverities[threshold.Severity] {
+		return fmt.Errorf("%w: unsupported severity %q for %s", ErrInvalidThresholdRequest, threshold.Severity, threshold.MetricName)
+	}
+	if threshold.Duration < 0 {
+		return fmt.Errorf("%w: duration for %s must not be negative", ErrInvalidThresholdRequest, threshold.MetricName)
+	}
+	return nil
+}
+
+// BulkSetThresholds applies thresholds to a template or to every device in
+// a group
+func (s *Service) BulkSetThresholds(ctx context.Context, req *BulkThresholdRequest) (*BulkThresholdResult, error) {
+	grouped := len(req.DeviceIDs) > 0 || len(req.Selector) > 0
+	if req.TemplateID != "" && grouped {
+		return nil, fmt.Errorf("%w: template_id cannot be combined with device_ids or selector", ErrInvalidThresholdRequest)
+	}
+	if req.TemplateID == "" && !grouped {
+		return nil, fmt.Errorf("%w: template_id, device_ids or selector is required", ErrInvalidThresholdRequest)
+	}
+
+	seen := make(map[string]bool, len(req.Thresholds))
+	for _, threshold := range req.Thresholds {
+		if err := validateThreshold(threshold); err != nil {
+			return nil, err
+		}
+		key := threshold.MetricName + "#" + threshold.Operator
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate threshold for %s %s", ErrInvalidThresholdRequest, threshold.MetricName, threshold.Operator)
+		}
+		seen[key] = true
+	}
+
+	scope, targets := ThresholdScopeTemplate, []string{req.TemplateID}
+	if grouped {
+		deviceIDs, err := s.resolveDeviceGroup(ctx, req.DeviceIDs, req.Selector)
+		if err != nil {
+			return nil, err
+		}
+		scope, targets = ThresholdScopeDevice, deviceIDs
+	}
+
+	result := &BulkThresholdResult{Targets: len(targets)}
+	for _, scopeID := range targets {
+		existing, err := s.repository.ListScopedThresholds(ctx, scope, scopeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list thresholds for %s %s: %w", scope, scopeID, err)
+		}
+		byKey := make(map[string]*ScopedThreshold, len(existing))
+		for _, threshold := range existing {
+			byKey[threshold.thresholdKey()] = threshold
+		}
+
+		for _, threshold := range req.Thresholds {
+			scoped := &ScopedThreshold{Scope: scope, ScopeID: scopeID, AlertThreshold: *threshold}
+			if current, ok := byKey[scoped.thresholdKey()]; ok {
+				scoped.ThresholdID = current.ThresholdID
+				result.Updated++
+			} else {
+				result.Created++
+			}
+			if err := s.repository.SaveScopedThreshold(ctx, scoped); err != nil {
+				return result, fmt.Errorf("failed to save threshold for %s %s: %w", scope, scopeID, err)
+			}
+			result.Thresholds = append(result.Thresholds, scoped)
+		}
+	}
+
+	return result, nil
+}
+
+// resolveDeviceGroup returns the sorted union of explicit device IDs and the
+// devices whose labels match selector
+func (s *Service) resolveDeviceGroup(ctx context.Context, deviceIDs []string, selector map[string]string) ([]string, error) {
+	members := make(map[string]bool, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		if deviceID == "" {
+			return nil, fmt.Errorf("%w: empty device ID", ErrInvalidThresholdRequest)
+		}
+		members[deviceID] = true
+	}
+
+	if len(selector) > 0 {
+		if s.devices == nil {
+			return nil, fmt.Errorf("%w: label selectors need device metadata", ErrDeviceDirectoryUnavailable)
+		}
+		devices, err := s.devices.ListDevices(ctx, &device.DeviceFilters{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list devices: %w", err)
+		}
+		for _, d := range devices {
+			if d.MatchesLabels(selector) {
+				members[d.DeviceID] = true
+			}
+		}
+	}
+
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: no devices match the selector", ErrInvalidThresholdRequest)
+	}
+
+	resolved := make([]string, 0, len(members))
+	for deviceID := range members {
+		resolved = append(resolved, deviceID)
+	}
+	sort.Strings(resolved)
+	return resolved, nil
+}
+
+// ListTemplateThresholds returns the thresholds defined for a template
+func (s *Service) ListTemplateThresholds(ctx context.Context, templateID string) ([]*ScopedThreshold, error) {
+	return s.repository.ListScopedThresholds(ctx, ThresholdScopeTemplate, templateID)
+}
+
+// ResolveThresholds returns the thresholds in effect for a device: its
+// template's thresholds with device thresholds overriding them. templateID
+// may be empty, in which case it is looked up in the device directory.
+func (s *Service) ResolveThresholds(ctx context.Context, deviceID, templateID string) (*EffectiveThresholds, error) {
+	if templateID == "" && s.devices != nil {
+		d, err := s.devices.GetDevice(ctx, deviceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get device: %w", err)
+		}
+		templateID = d.TemplateID
+	}
+
+	var inherited []*ScopedThreshold
+	if templateID != "" {
+		var err error
+		inherited, err = s.repository.ListScopedThresholds(ctx, ThresholdScopeTemplate, templateID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list template thresholds: %w", err)
+		}
+	}
+	own, err := s.repository.ListScopedThresholds(ctx, ThresholdScopeDevice, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device thresholds: %w", err)
+	}
+
+	return &EffectiveThresholds{
+		DeviceID:   deviceID,
+		TemplateID: templateID,
+		Thresholds: mergeThresholds(inherited, own),
+	}, nil
+}
+
+// mergeThresholds overlays device thresholds on inherited ones by metric and
+// operator, sorted by metric then operator
+func mergeThresholds(inherited, own []*ScopedThreshold) []*EffectiveThreshold {
+	byKey := make(map[string]*EffectiveThreshold, len(inherited)+len(own))
+	for _, threshold := range inherited {
+		byKey[threshold.thresholdKey()] = &EffectiveThreshold{ScopedThreshold: threshold}
+	}
+	for _, threshold := range own {
+		effective := &EffectiveThreshold{ScopedThreshold: threshold}
+		if current, ok := byKey[threshold.thresholdKey()]; ok {
+			effective.Overrides = current.ScopedThreshold
+		}
+		byKey[threshold.thresholdKey()] = effective
+	}
+
+	merged := make([]*EffectiveThreshold, 0, len(byKey))
+	for _, threshold := range byKey {
+		merged = append(merged, threshold)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].MetricName != merged[j].MetricName {
+			return merged[i].MetricName < merged[j].MetricName
+		}
+		return merged[i].Operator < merged[j].Operator
+	})
+	return merged
+}
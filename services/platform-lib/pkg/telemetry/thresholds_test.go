@@ -0,0 +1,241 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thresholdRepository keeps scoped thresholds in memory
+type thresholdRepository struct {
+	MockRepository
+	thresholds []*ScopedThreshold
+}
+
+func (r *thresholdRepository) ListScopedThresholds(ctx context.Context, scope ThresholdScope, scopeID string) ([]*ScopedThreshold, error) {
+	var matched []*ScopedThreshold
+	for _, threshold := range r.thresholds {
+		if threshold.Scope == scope && threshold.ScopeID == scopeID {
+			copied := *threshold
+			matched = append(matched, &copied)
+		}
+	}
+	return matched, nil
+}
+
+func (r *thresholdRepository) SaveScopedThreshold(ctx context.Context, threshold *ScopedThreshold) error {
+	copied := *threshold
+	if copied.ThresholdID == "" {
+		copied.ThresholdID = fmt.Sprintf("threshold-%d", len(r.thresholds)+1)
+		threshold.ThresholdID = copied.ThresholdID
+		r.thresholds = append(r.thresholds, &copied)
+		return nil
+	}
+	for i, current := range r.thresholds {
+		if current.ThresholdID == copied.ThresholdID {
+			r.thresholds[i] = &copied
+			return nil
+		}
+	}
+	return fmt.Errorf("threshold not found")
+}
+
+// deviceDirectory serves a fixed device list
+type deviceDirectory []*device.Device
+
+func (d deviceDirectory) GetDevice(ctx context.Context, deviceID string) (*device.Device, error) {
+	for _, dev := range d {
+		if dev.DeviceID == deviceID {
+			return dev, nil
+		}
+	}
+	return nil, fmt.Errorf("device not found")
+}
+
+func (d deviceDirectory) ListDevices(ctx context.Context, filters *device.DeviceFilters) ([]*device.Device, error) {
+	return d, nil
+}
+
+func newThresholdService(t *testing.T) (*Service, *thresholdRepository) {
+	repository := &thresholdRepository{}
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+	service.SetDeviceDirectory(deviceDirectory{
+		{DeviceID: "dev-1", TemplateID: "greenhouse", Labels: map[string]string{"site": "north"}},
+		{DeviceID: "dev-2", TemplateID: "greenhouse", Labels: map[string]string{"site": "north"}},
+		{DeviceID: "dev-3", TemplateID: "greenhouse", Labels: map[string]string{"site": "south"}},
+	})
+	return service, repository
+}
+
+func TestService_BulkSetThresholds_Template(t *testing.T) {
+	service, repository := newThresholdService(t)
+	ctx := context.Background()
+
+	result, err := service.BulkSetThresholds(ctx, &BulkThresholdRequest{
+		TemplateID: "greenhouse",
+		Thresholds: []*AlertThreshold{
+			{MetricName: "temperature", Operator: "gt", Value: 35, Severity: "warning", Enabled: true},
+			{MetricName: "temperature", Operator: "lt", Value: 5, Severity: "critical", Enabled: true},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Targets)
+	assert.Equal(t, 2, result.Created)
+	assert.Len(t, repository.thresholds, 2)
+
+	// Re-applying updates by metric and operator instead of duplicating
+	result, err = service.BulkSetThresholds(ctx, &BulkThresholdRequest{
+		TemplateID: "greenhouse",
+		Thresholds: []*AlertThreshold{{MetricName: "temperature", Operator: "gt", Value: 38, Enabled: true}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Created)
+	assert.Equal(t, 1, result.Updated)
+	require.Len(t, repository.thresholds, 2)
+	assert.Equal(t, 38.0, repository.thresholds[0].Value)
+}
+
+func TestService_BulkSetThresholds_DeviceGroup(t *testing.T) {
+	service, repository := newThresholdService(t)
+
+	result, err := service.BulkSetThresholds(context.Background(), &BulkThresholdRequest{
+		DeviceIDs:  []string{"dev-3"},
+		Selector:   map[string]string{"site": "north"},
+		Thresholds: []*AlertThreshold{{MetricName: "humidity", Operator: "gte", Value: 90, Enabled: true}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Targets)
+	assert.Equal(t, 3, result.Created)
+
+	scopeIDs := make([]string, 0, len(repository.thresholds))
+	for _, threshold := range repository.thresholds {
+		assert.Equal(t, ThresholdScopeDevice, threshold.Scope)
+		scopeIDs = append(scopeIDs, threshold.ScopeID)
+	}
+	assert.Equal(t, []string{"dev-1", "dev-2", "dev-3"}, scopeIDs)
+}
+
+func TestService_BulkSetThresholds_Invalid(t *testing.T) {
+	service, _ := newThresholdService(t)
+	valid := []*AlertThreshold{{MetricName: "temperature", Operator: "gt", Value: 35}}
+
+	tests := []struct {
+		name string
+		req  *BulkThresholdRequest
+	}{
+		{"no target", &BulkThresholdRequest{Thresholds: valid}},
+		{"template and devices", &BulkThresholdRequest{TemplateID: "greenhouse", DeviceIDs: []string{"dev-1"}, Thresholds: valid}},
+		{"no matching devices", &BulkThresholdRequest{Selector: map[string]string{"site": "east"}, Thresholds: valid}},
+		{"bad operator", &BulkThresholdRequest{TemplateID: "greenhouse", Thresholds: []*AlertThreshold{{MetricName: "temperature", Operator: "above"}}}},
+		{"bad severity", &BulkThresholdRequest{TemplateID: "greenhouse", Thresholds: []*AlertThreshold{{MetricName: "temperature", Operator: "gt", Severity: "urgent"}}}},
+		{"duplicate", &BulkThresholdRequest{TemplateID: "greenhouse", Thresholds: append(valid, valid[0])}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.BulkSetThresholds(context.Background(), tt.req)
+			assert.ErrorIs(t, err, ErrInvalidThresholdRequest)
+		})
+	}
+}
+
+func TestService_BulkSetThresholds_SelectorNeedsDirectory(t *testing.T) {
+	service, err := NewService(&config.Config{}, logger.New("info", "telemetry-service"), &thresholdRepository{})
+	require.NoError(t, err)
+
+	_, err = service.BulkSetThresholds(context.Background(), &BulkThresholdRequest{
+		Selector:   map[string]string{"site": "north"},
+		Thresholds: []*AlertThreshold{{MetricName: "temperature", Operator: "gt", Value: 35}},
+	})
+	assert.ErrorIs(t, err, ErrDeviceDirectoryUnavailable)
+}
+
+func TestService_ResolveThresholds(t *testing.T) {
+	service, _ := newThresholdService(t)
+	ctx := context.Background()
+
+	_, err := service.BulkSetThresholds(ctx, &BulkThresholdRequest{
+		TemplateID: "greenhouse",
+		Thresholds: []*AlertThreshold{
+			{MetricName: "temperature", Operator: "gt", Value: 35, Enabled: true},
+			{MetricName: "humidity", Operator: "gt", Value: 80, Enabled: true},
+		},
+	})
+	require.NoError(t, err)
+	_, err = service.BulkSetThresholds(ctx, &BulkThresholdRequest{
+		DeviceIDs: []string{"dev-1"},
+		Thresholds: []*AlertThreshold{
+			{MetricName: "temperature", Operator: "gt", Value: 40, Enabled: true},
+			{MetricName: "battery", Operator: "lt", Value: 20, Enabled: true},
+		},
+	})
+	require.NoError(t, err)
+
+	effective, err := service.ResolveThresholds(ctx, "dev-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "greenhouse", effective.TemplateID)
+	require.Len(t, effective.Thresholds, 3)
+
+	battery, humidity, temperature := effective.Thresholds[0], effective.Thresholds[1], effective.Thresholds[2]
+	assert.Equal(t, ThresholdScopeDevice, battery.Scope)
+	assert.Nil(t, battery.Overrides)
+	assert.Equal(t, ThresholdScopeTemplate, humidity.Scope)
+	assert.Equal(t, "greenhouse", humidity.ScopeID)
+	assert.Equal(t, ThresholdScopeDevice, temperature.Scope)
+	assert.Equal(t, 40.0, temperature.Value)
+	require.NotNil(t, temperature.Overrides)
+	assert.Equal(t, 35.0, temperature.Overrides.Value)
+
+	// Devices without overrides inherit the template unchanged
+	effective, err = service.ResolveThresholds(ctx, "dev-2", "")
+	require.NoError(t, err)
+	require.Len(t, effective.Thresholds, 2)
+	for _, threshold := range effective.Thresholds {
+		assert.Equal(t, ThresholdScopeTemplate, threshold.Scope)
+	}
+}
+
+func TestService_ThresholdHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, _ := newThresholdService(t)
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	body := `{"template_id":"greenhouse","thresholds":[{"metric_name":"temperature","operator":"gt","value":35,"enabled":true}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/telemetry/thresholds/bulk", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/telemetry/thresholds/bulk", strings.NewReader(`{"thresholds":[{"metric_name":"temperature","operator":"gt"}]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/thresholds/templates/greenhouse", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/thresholds/dev-1/effective", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var effective EffectiveThresholds
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &effective))
+	require.Len(t, effective.Thresholds, 1)
+	assert.Equal(t, ThresholdScopeTemplate, effective.Thresholds[0].Scope)
+	assert.Equal(t, "temperature", effective.Thresholds[0].MetricName)
+	assert.Equal(t, 35.0, effective.Thresholds[0].Value)
+}
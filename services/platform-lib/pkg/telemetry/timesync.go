@@ -0,0 +1,288 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// clockSkewSamples is how many recent skew samples are kept per device
+	clockSkewSamples = 16
+
+	// minClockSkewSamples is how many agreeing samples are needed before
+	// a device's timestamps are corrected, so a single backfilled reading
+	// is not mistaken for a drifting clock
+	minClockSkewSamples = 3
+
+	// clockJumpThreshold is how far a sample may stray from the estimate
+	// before the device clock is considered reset, e.g. by a reboot
+	clockJumpThreshold = time.Minute
+
+	// deviceTimestampTag records the timestamp a device reported when the
+	// service corrected it
+	deviceTimestampTag = "device_timestamp"
+)
+
+// clockFloor is the earliest plausible device time. Devices without a real
+// time clock count from the epoch, or from their build date, after boot.
+var clockFloor = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ClockSkew is the estimated offset of a device clock from the server clock
+type ClockSkew struct {
+	DeviceID string `json:"device_id"`
+	// SkewMs is the device clock minus the server clock. Network delay
+	// makes it slightly negative for a device with an accurate clock.
+	SkewMs  int64 `json:"skew_ms"`
+	Samples int   `json:"samples"`
+	// Resets counts the times the device clock jumped
+	Resets int `json:"resets"`
+	// ClockUnset reports that the latest timestamp predates any plausible
+	// time, i.e. the device clock was never set
+	ClockUnset bool `json:"clock_unset"`
+	// Corrected reports that the device's timestamps are being corrected
+	Corrected    bool      `json:"corrected"`
+	LastSampleAt time.Time `json:"last_sample_at"`
+}
+
+// ClockSkewTracker estimates the clock skew of each device from the
+// timestamps it reports and the time they are received, and corrects
+// timestamps from clocks that are off by more than the tolerance.
+type ClockSkewTracker struct {
+	tolerance time.Duration
+
+	mu      sync.Mutex
+	devices map[string]*deviceClock
+}
+
+type deviceClock struct {
+	samples      []time.Duration
+	resets       int
+	unset        bool
+	lastSampleAt time.Time
+}
+
+// NewClockSkewTracker creates a tracker. A tolerance of zero disables
+// correction; skew is still tracked.
+func NewClockSkewTracker(tolerance time.Duration) *ClockSkewTracker {
+	return &ClockSkewTracker{tolerance: tolerance, devices: make(map[string]*deviceClock)}
+}
+
+// Observe records a timestamp reported by a device at receivedAt
+func (t *ClockSkewTracker) Observe(deviceID string, deviceTime, receivedAt time.Time) {
+	if deviceTime.IsZero() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clock, ok := t.devices[deviceID]
+	if !ok {
+		clock = &deviceClock{}
+		t.devices[deviceID] = clock
+	}
+
+	sample := deviceTime.Sub(receivedAt)
+	if len(clock.samples) >= minClockSkewSamples && absDuration(sample-clock.estimate()) > clockJumpThreshold {
+		clock.samples = nil
+		clock.resets++
+	}
+	clock.samples = append(clock.samples, sample)
+	if len(clock.samples) > clockSkewSamples {
+		clock.samples = clock.samples[1:]
+	}
+	clock.unset = deviceTime.Before(clockFloor)
+	clock.lastSampleAt = receivedAt
+}
+
+// Correct returns the server time of a timestamp reported by a device, and
+// whether it differs from the reported one. Timestamps from an unset clock
+// fall back to receivedAt until enough samples are collected.
+func (t *ClockSkewTracker) Correct(deviceID string, deviceTime, receivedAt time.Time) (time.Time, bool) {
+	if t.tolerance <= 0 || deviceTime.IsZero() {
+		return deviceTime, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if clock, ok := t.devices[deviceID]; ok && clock.correcting(t.tolerance) {
+		return deviceTime.Add(-clock.estimate()), true
+	}
+	if deviceTime.Before(clockFloor) {
+		return receivedAt, true
+	}
+	return deviceTime, false
+}
+
+// Skew returns the skew estimate of a device
+func (t *ClockSkewTracker) Skew(deviceID string) (*ClockSkew, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clock, ok := t.devices[deviceID]
+	if !ok {
+		return nil, false
+	}
+	return t.skew(deviceID, clock), true
+}
+
+// Report returns the skew estimate of every device, largest skew first
+func (t *ClockSkewTracker) Report() []*ClockSkew {
+	t.mu.Lock()
+	report := make([]*ClockSkew, 0, len(t.devices))
+	for deviceID, clock := range t.devices {
+		report = append(report, t.skew(deviceID, clock))
+	}
+	t.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := absInt64(report[i].SkewMs), absInt64(report[j].SkewMs)
+		if a != b {
+			return a > b
+		}
+		return report[i].DeviceID < report[j].DeviceID
+	})
+	return report
+}
+
+func (t *ClockSkewTracker) skew(deviceID string, clock *deviceClock) *ClockSkew {
+	return &ClockSkew{
+		DeviceID:     deviceID,
+		SkewMs:       clock.estimate().Milliseconds(),
+		Samples:      len(clock.samples),
+		Resets:       clock.resets,
+		ClockUnset:   clock.unset,
+		Corrected:    t.tolerance > 0 && (clock.correcting(t.tolerance) || clock.unset),
+		LastSampleAt: clock.lastSampleAt,
+	}
+}
+
+// estimate is the median of the samples, which is robust to the odd
+// delayed message
+func (c *deviceClock) estimate() time.Duration {
+	if len(c.samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(c.samples))
+	copy(sorted, c.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+func (c *deviceClock) correcting(tolerance time.Duration) bool {
+	return len(c.samples) >= minClockSkewSamples && absDuration(c.estimate()) > tolerance
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// clockCorrectingRepository observes the clock skew of devices as their
+// telemetry is stored and corrects the timestamps of skewed clocks. The
+// reported timestamp is kept in a tag.
+type clockCorrectingRepository struct {
+	Repository
+	clocks *ClockSkewTracker
+}
+
+// StoreTelemetry stores data with its timestamp corrected
+func (r *clockCorrectingRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	receivedAt := time.Now()
+	r.clocks.Observe(data.DeviceID, data.Timestamp, receivedAt)
+	r.correct(data, receivedAt)
+	return r.Repository.StoreTelemetry(ctx, data)
+}
+
+// StoreTelemetryBatch stores a batch with its timestamps corrected. A
+// batch may be a buffered upload, so only the latest reading of each device
+// is a skew sample.
+func (r *clockCorrectingRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	receivedAt := time.Now()
+	latest := make(map[string]time.Time)
+	for _, data := range batch {
+		if data.Timestamp.After(latest[data.DeviceID]) {
+			latest[data.DeviceID] = data.Timestamp
+		}
+	}
+	for deviceID, timestamp := range latest {
+		r.clocks.Observe(deviceID, timestamp, receivedAt)
+	}
+	for _, data := range batch {
+		r.correct(data, receivedAt)
+	}
+	return r.Repository.StoreTelemetryBatch(ctx, batch)
+}
+
+func (r *clockCorrectingRepository) correct(data *TelemetryData, receivedAt time.Time) {
+	corrected, ok := r.clocks.Correct(data.DeviceID, data.Timestamp, receivedAt)
+	if !ok {
+		return
+	}
+	if data.Tags == nil {
+		data.Tags = make(map[string]string)
+	}
+	data.Tags[deviceTimestampTag] = data.Timestamp.Format(time.RFC3339Nano)
+	data.Timestamp = corrected
+}
+
+// timeHandler serves the server time for devices to set their clocks. A
+// device sends its own time as t0 (Unix milliseconds) and, noting the
+// response arrival time t3, estimates its offset as
+// ((receive - t0) + (transmit - t3)) / 2, as in NTP.
+func (s *Service) timeHandler(c *gin.Context) {
+	receive := time.Now()
+
+	response := gin.H{
+		"receive_ms": receive.UnixMilli(),
+	}
+	if t0 := c.Query("t0"); t0 != "" {
+		originate, err := strconv.ParseInt(t0, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid t0", "details": "t0 must be Unix milliseconds"})
+			return
+		}
+		response["originate_ms"] = originate
+	}
+
+	transmit := time.Now()
+	response["transmit_ms"] = transmit.UnixMilli()
+	response["server_time"] = transmit.UTC().Format(time.RFC3339Nano)
+	c.JSON(http.StatusOK, response)
+}
+
+func (s *Service) listClockSkewHandler(c *gin.Context) {
+	report := s.clocks.Report()
+	c.JSON(http.StatusOK, gin.H{
+		"devices":      report,
+		"count":        len(report),
+		"tolerance_ms": s.clocks.tolerance.Milliseconds(),
+	})
+}
+
+func (s *Service) getClockSkewHandler(c *gin.Context) {
+	deviceID := c.Param("deviceId")
+
+	skew, ok := s.clocks.Skew(deviceID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No timestamps received from device"})
+		return
+	}
+	c.JSON(http.StatusOK, skew)
+}
@@ -0,0 +1,169 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewTracker(t *testing.T) {
+	tracker := NewClockSkewTracker(2 * time.Second)
+	now := time.Now().Round(0)
+
+	// A single reading an hour behind may be a backfill
+	tracker.Observe("device-1", now.Add(-time.Hour), now)
+	corrected, ok := tracker.Correct("device-1", now.Add(-time.Hour), now)
+	assert.False(t, ok)
+	assert.Equal(t, now.Add(-time.Hour), corrected)
+
+	// Once it is consistent, the clock is skewed
+	tracker.Observe("device-1", now.Add(-time.Hour+time.Second), now.Add(time.Second))
+	tracker.Observe("device-1", now.Add(-time.Hour+2*time.Second), now.Add(2*time.Second))
+	corrected, ok = tracker.Correct("device-1", now.Add(-time.Hour+3*time.Second), now.Add(3*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(3*time.Second), corrected)
+
+	skew, ok := tracker.Skew("device-1")
+	require.True(t, ok)
+	assert.Equal(t, (-time.Hour).Milliseconds(), skew.SkewMs)
+	assert.Equal(t, 3, skew.Samples)
+	assert.True(t, skew.Corrected)
+
+	// A clock jump starts a new estimate
+	tracker.Observe("device-1", now.Add(10*time.Second), now.Add(10*time.Second))
+	skew, _ = tracker.Skew("device-1")
+	assert.Equal(t, 1, skew.Resets)
+	assert.Equal(t, 1, skew.Samples)
+	assert.Zero(t, skew.SkewMs)
+	assert.False(t, skew.Corrected)
+}
+
+func TestClockSkewTracker_UnsetClock(t *testing.T) {
+	tracker := NewClockSkewTracker(2 * time.Second)
+	now := time.Now().Round(0)
+	boot := time.Unix(0, 0)
+
+	// Until there are enough samples, readings take the receive time
+	tracker.Observe("device-1", boot.Add(time.Minute), now)
+	corrected, ok := tracker.Correct("device-1", boot.Add(time.Minute), now)
+	assert.True(t, ok)
+	assert.Equal(t, now, corrected)
+
+	tracker.Observe("device-1", boot.Add(2*time.Minute), now.Add(time.Minute))
+	tracker.Observe("device-1", boot.Add(3*time.Minute), now.Add(2*time.Minute))
+
+	// Buffered readings keep their spacing
+	corrected, ok = tracker.Correct("device-1", boot.Add(90*time.Second), now.Add(2*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(30*time.Second), corrected)
+
+	report := tracker.Report()
+	require.Len(t, report, 1)
+	assert.True(t, report[0].ClockUnset)
+}
+
+func TestClockSkewTracker_Disabled(t *testing.T) {
+	tracker := NewClockSkewTracker(0)
+	now := time.Now().Round(0)
+	for i := 0; i < minClockSkewSamples; i++ {
+		tracker.Observe("device-1", time.Unix(int64(i), 0), now)
+	}
+
+	_, ok := tracker.Correct("device-1", time.Unix(5, 0), now)
+	assert.False(t, ok)
+
+	skew, ok := tracker.Skew("device-1")
+	require.True(t, ok)
+	assert.False(t, skew.Corrected)
+}
+
+func TestService_ClockCorrection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Telemetry: config.TelemetryConfig{ClockSkewTolerance: 2 * time.Second}}
+	repository := &memoryRepository{}
+	service, err := NewService(cfg, logger.New("info", "telemetry-service"), repository)
+	require.NoError(t, err)
+
+	// A device counting from the epoch since it booted a minute ago
+	boot := time.Now().Add(-time.Minute)
+	for i := 0; i < minClockSkewSamples; i++ {
+		uptime := time.Since(boot)
+		require.NoError(t, service.IngestTelemetry("device-1", &TelemetryData{
+			Timestamp: time.Unix(0, 0).Add(uptime),
+			Metrics:   map[string]interface{}{"temperature": 21.5},
+		}))
+	}
+
+	require.Len(t, repository.stored, minClockSkewSamples)
+	for _, point := range repository.stored {
+		assert.WithinDuration(t, time.Now(), point.Timestamp, time.Second)
+	}
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/clock-skew/device-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var skew ClockSkew
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &skew))
+	assert.True(t, skew.ClockUnset)
+	assert.True(t, skew.Corrected)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/clock-skew/device-2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestClockCorrectingRepository_Batch(t *testing.T) {
+	repository := &clockCorrectingRepository{Repository: &MockRepository{}, clocks: NewClockSkewTracker(2 * time.Second)}
+	now := time.Now().Round(0)
+	for i := 0; i < minClockSkewSamples; i++ {
+		repository.clocks.Observe("device-1", now.Add(time.Hour), now)
+	}
+
+	batch := []*TelemetryData{
+		{DeviceID: "device-1", Timestamp: now.Add(time.Hour - time.Minute)},
+		{DeviceID: "device-1", Timestamp: now.Add(time.Hour)},
+	}
+	require.NoError(t, repository.StoreTelemetryBatch(context.Background(), batch))
+
+	assert.WithinDuration(t, now.Add(-time.Minute), batch[0].Timestamp, time.Second)
+	assert.WithinDuration(t, now, batch[1].Timestamp, time.Second)
+	assert.NotEmpty(t, batch[0].Tags[deviceTimestampTag])
+}
+
+func TestService_TimeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, _ := newLogService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/time?t0=1000", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(1000), body["originate_ms"])
+	assert.InDelta(t, float64(time.Now().UnixMilli()), body["transmit_ms"], 1000)
+	assert.GreaterOrEqual(t, body["transmit_ms"], body["receive_ms"])
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/telemetry/time?t0=soon", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/athena/platform-lib/pkg/metering"
+)
+
+// meteredRepository records the metric values stored for usage metering
+type meteredRepository struct {
+	Repository
+	recorder *metering.Recorder
+}
+
+// StoreTelemetry stores data and meters its points
+func (r *meteredRepository) StoreTelemetry(ctx context.Context, data *TelemetryData) error {
+	if err := r.Repository.StoreTelemetry(ctx, data); err != nil {
+		return err
+	}
+	r.recorder.AddForDevice(data.DeviceID, metering.MeterTelemetryPoints, float64(len(data.Metrics)))
+	return nil
+}
+
+// StoreTelemetryBatch stores a batch and meters its points
+func (r *meteredRepository) StoreTelemetryBatch(ctx context.Context, batch []*TelemetryData) error {
+	if err := r.Repository.StoreTelemetryBatch(ctx, batch); err != nil {
+		return err
+	}
+	for _, data := range batch {
+		r.recorder.AddForDevice(data.DeviceID, metering.MeterTelemetryPoints, float64(len(data.Metrics)))
+	}
+	return nil
+}
+
+// SetUsageRecorder meters stored telemetry points. It must be called
+// before Start.
+func (s *Service) SetUsageRecorder(recorder *metering.Recorder) {
+	s.usage.recorder = recorder
+}
@@ -0,0 +1,30 @@
+package template
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/athena/platform-lib/pkg/admin"
+)
+
+// AdminTasks returns the template service's operational tasks
+func AdminTasks(repo Repository) []admin.Task {
+	return []admin.Task{
+		{
+			Name:        "reindex",
+			Description: "Rewrite every template version so Datastore indexes match the current schema",
+			Run: func(ctx context.Context, params admin.Params) (admin.Result, error) {
+				templates, err := repo.ListTemplates(ctx, &TemplateFilters{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to list templates: %w", err)
+				}
+				for i, t := range templates {
+					if err := repo.UpdateTemplate(ctx, t); err != nil {
+						return admin.Result{"reindexed": i}, fmt.Errorf("failed to rewrite template %s %s: %w", t.ID, t.Version, err)
+					}
+				}
+				return admin.Result{"reindexed": len(templates)}, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,418 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 365
+	maxTopErrors         = 10
+	maxParameterValues   = 10
+	maxErrorLength       = 200
+
+	// minParameterValueUses is how often a parameter value must be used to
+	// be shown; rarer values, such as a user's own network name, are only
+	// counted as other values
+	minParameterValueUses = 3
+)
+
+// compilerErrorPrefix matches the file position compilers put before an
+// error, so the same error in different sketches is counted once
+var compilerErrorPrefix = regexp.MustCompile(`^\S+:\d+(:\d+)?:\s*(fatal error|error)?:?\s*`)
+
+// DeviceUsage is a device built from a template
+type DeviceUsage struct {
+	DeviceID   string                 `json:"device_id"`
+	Version    string                 `json:"version"`
+	Board      string                 `json:"board"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// UsageFinder finds the devices built from a template. The device package
+// provides one over the device registry.
+type UsageFinder interface {
+	FindTemplateUsage(ctx context.Context, templateID string) ([]DeviceUsage, error)
+}
+
+// SetUsageFinders sets where template analytics look for the devices
+// built from a template
+func (s *Service) SetUsageFinders(finders ...UsageFinder) {
+	s.usageFinders = finders
+}
+
+// TemplateAnalytics shows a template's author how the template is used:
+// which versions devices run, how builds fail and which parameter values
+// are chosen. Builds are those of the last days; devices are counted as
+// they are now.
+type TemplateAnalytics struct {
+	TemplateID   string             `json:"template_id"`
+	Since        time.Time          `json:"since"`
+	Devices      int                `json:"devices"`
+	Builds       int                `json:"builds"`
+	FailedBuilds int                `json:"failed_builds"`
+	FailureRate  float64            `json:"failure_rate"`
+	Versions     []*VersionAdoption `json:"versions"`
+	Boards       []*BoardBuildStats `json:"boards"`
+	TopErrors    []*BuildErrorCount `json:"top_errors"`
+	Parameters   []*ParameterUsage  `json:"parameters"`
+}
+
+// VersionAdoption is how many devices run a template version and how its
+// builds went. Versions are listed newest first.
+type VersionAdoption struct {
+	Version      string  `json:"version"`
+	Devices      int     `json:"devices"`
+	Share        float64 `json:"share"` // of the template's devices
+	Builds       int     `json:"builds"`
+	FailedBuilds int     `json:"failed_builds"`
+}
+
+// BoardBuildStats is how builds for a board went
+type BoardBuildStats struct {
+	Board        string  `json:"board"`
+	Devices      int     `json:"devices"`
+	Builds       int     `json:"builds"`
+	FailedBuilds int     `json:"failed_builds"`
+	FailureRate  float64 `json:"failure_rate"`
+}
+
+// BuildErrorCount is a build error and how often it occurred
+type BuildErrorCount struct {
+	Message  string    `json:"message"`
+	Count    int       `json:"count"`
+	Boards   []string  `json:"boards"`
+	Versions []string  `json:"versions"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ParameterUsage lists the most used values of a parameter, on devices and
+// in user compiles. Other counts the uses of values not listed.
+type ParameterUsage struct {
+	Parameter string                 `json:"parameter"`
+	Uses      int                    `json:"uses"`
+	Values    []*ParameterValueCount `json:"values"`
+	Other     int                    `json:"other"`
+}
+
+// ParameterValueCount is how often a parameter value is used. Default is
+// set for the default value of the latest template version.
+type ParameterValueCount struct {
+	Value    interface{} `json:"value"`
+	Devices  int         `json:"devices"`
+	Compiles int         `json:"compiles"`
+	Default  bool        `json:"default,omitempty"`
+}
+
+// GetTemplateAnalytics computes the analytics of a template from the
+// devices built from it and its builds of the last days
+func (s *Service) GetTemplateAnalytics(ctx context.Context, templateID string, days int) (*TemplateAnalytics, error) {
+	versions, err := s.repo.GetTemplateVersions(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, templateID)
+	}
+	if sorted, err := s.versionManager.SortVersions(versions); err == nil {
+		versions = sorted
+	}
+	slices.Reverse(versions)
+	latest, err := s.repo.GetTemplate(ctx, templateID, versions[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template version %s: %w", versions[0], err)
+	}
+
+	var devices []DeviceUsage
+	for _, finder := range s.usageFinders {
+		found, err := finder.FindTemplateUsage(ctx, templateID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find devices: %w", err)
+		}
+		devices = append(devices, found...)
+	}
+
+	if days <= 0 {
+		days = defaultAnalyticsDays
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	records, err := s.builds.ListBuildRecords(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+	var builds []*BuildRecord
+	for _, record := range records {
+		if !record.BuiltAt.Before(since) {
+			builds = append(builds, record)
+		}
+	}
+
+	analytics := &TemplateAnalytics{
+		TemplateID: templateID,
+		Since:      since,
+		Devices:    len(devices),
+		Builds:     len(builds),
+		Versions:   versionAdoption(versions, devices, builds),
+		Boards:     boardBuildStats(devices, builds),
+		TopErrors:  topBuildErrors(builds),
+		Parameters: parameterUsage(latest.Parameters, devices, builds),
+	}
+	for _, record := range builds {
+		if !record.Success {
+			analytics.FailedBuilds++
+		}
+	}
+	analytics.FailureRate = ratio(analytics.FailedBuilds, analytics.Builds)
+	return analytics, nil
+}
+
+// versionAdoption lists the template's versions, newest first, followed by
+// deleted versions devices still run
+func versionAdoption(versions []string, devices []DeviceUsage, builds []*BuildRecord) []*VersionAdoption {
+	byVersion := make(map[string]*VersionAdoption)
+	adoption := make([]*VersionAdoption, 0, len(versions))
+	version := func(name string) *VersionAdoption {
+		if entry, ok := byVersion[name]; ok {
+			return entry
+		}
+		entry := &VersionAdoption{Version: name}
+		byVersion[name] = entry
+		adoption = append(adoption, entry)
+		return entry
+	}
+	for _, name := range versions {
+		version(name)
+	}
+	for _, d := range devices {
+		version(d.Version).Devices++
+	}
+	for _, record := range builds {
+		entry := version(record.TemplateVersion)
+		entry.Builds++
+		if !record.Success {
+			entry.FailedBuilds++
+		}
+	}
+	for _, entry := range adoption {
+		entry.Share = ratio(entry.Devices, len(devices))
+	}
+	return adoption
+}
+
+// boardBuildStats lists boards by failed builds, then by name
+func boardBuildStats(devices []DeviceUsage, builds []*BuildRecord) []*BoardBuildStats {
+	byBoard := make(map[string]*BoardBuildStats)
+	board := func(name string) *BoardBuildStats {
+		if entry, ok := byBoard[name]; ok {
+			return entry
+		}
+		entry := &BoardBuildStats{Board: name}
+		byBoard[name] = entry
+		return entry
+	}
+	for _, d := range devices {
+		if d.Board != "" {
+			board(d.Board).Devices++
+		}
+	}
+	for _, record := range builds {
+		entry := board(record.Board)
+		entry.Builds++
+		if !record.Success {
+			entry.FailedBuilds++
+		}
+	}
+
+	stats := make([]*BoardBuildStats, 0, len(byBoard))
+	for _, entry := range byBoard {
+		entry.FailureRate = ratio(entry.FailedBuilds, entry.Builds)
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].FailedBuilds != stats[j].FailedBuilds {
+			return stats[i].FailedBuilds > stats[j].FailedBuilds
+		}
+		return stats[i].Board < stats[j].Board
+	})
+	return stats
+}
+
+// topBuildErrors counts the errors of failed builds, most frequent first
+func topBuildErrors(builds []*BuildRecord) []*BuildErrorCount {
+	byMessage := make(map[string]*BuildErrorCount)
+	for _, record := range builds {
+		if record.Success {
+			continue
+		}
+		message := normalizeBuildError(record.Error)
+		entry, ok := byMessage[message]
+		if !ok {
+			entry = &BuildErrorCount{Message: message, Boards: []string{}, Versions: []string{}}
+			byMessage[message] = entry
+		}
+		entry.Count++
+		if !slices.Contains(entry.Boards, record.Board) {
+			entry.Boards = append(entry.Boards, record.Board)
+		}
+		if !slices.Contains(entry.Versions, record.TemplateVersion) {
+			entry.Versions = append(entry.Versions, record.TemplateVersion)
+		}
+		if record.BuiltAt.After(entry.LastSeen) {
+			entry.LastSeen = record.BuiltAt
+		}
+	}
+
+	top := make([]*BuildErrorCount, 0, len(byMessage))
+	for _, entry := range byMessage {
+		slices.Sort(entry.Boards)
+		top = append(top, entry)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Message < top[j].Message
+	})
+	if len(top) > maxTopErrors {
+		top = top[:maxTopErrors]
+	}
+	return top
+}
+
+// normalizeBuildError strips the file position from a compiler error and
+// shortens it
+func normalizeBuildError(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	message = compilerErrorPrefix.ReplaceAllString(message, "")
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	if message == "" {
+		message = "build failed"
+	}
+	return message
+}
+
+// parameterUsage counts the values of each parameter on devices and in
+// user compiles. Parameters are listed by name, values by uses.
+func parameterUsage(defaults map[string]interface{}, devices []DeviceUsage, builds []*BuildRecord) []*ParameterUsage {
+	type valueCount struct {
+		*ParameterValueCount
+		key string
+	}
+	counts := make(map[string]map[string]*valueCount)
+	count := func(parameter string, value interface{}) *ParameterValueCount {
+		key := parameterValueKey(value)
+		values, ok := counts[parameter]
+		if !ok {
+			values = make(map[string]*valueCount)
+			counts[parameter] = values
+		}
+		entry, ok := values[key]
+		if !ok {
+			entry = &valueCount{ParameterValueCount: &ParameterValueCount{Value: value}, key: key}
+			if defaultValue, ok := defaults[parameter]; ok {
+				entry.Default = parameterValueKey(defaultValue) == key
+			}
+			values[key] = entry
+		}
+		return entry.ParameterValueCount
+	}
+	for _, d := range devices {
+		for parameter, value := range d.Parameters {
+			count(parameter, value).Devices++
+		}
+	}
+	for _, record := range builds {
+		if record.Source != BuildSourceCompile {
+			continue
+		}
+		for parameter, value := range record.Parameters {
+			count(parameter, value).Compiles++
+		}
+	}
+
+	usage := make([]*ParameterUsage, 0, len(counts))
+	for parameter, values := range counts {
+		sorted := make([]*valueCount, 0, len(values))
+		for _, entry := range values {
+			sorted = append(sorted, entry)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			a := sorted[i].Devices + sorted[i].Compiles
+			b := sorted[j].Devices + sorted[j].Compiles
+			if a != b {
+				return a > b
+			}
+			return sorted[i].key < sorted[j].key
+		})
+
+		entry := &ParameterUsage{Parameter: parameter, Values: []*ParameterValueCount{}}
+		for _, value := range sorted {
+			uses := value.Devices + value.Compiles
+			entry.Uses += uses
+			if len(entry.Values) < maxParameterValues && (uses >= minParameterValueUses || value.Default) {
+				entry.Values = append(entry.Values, value.ParameterValueCount)
+			} else {
+				entry.Other += uses
+			}
+		}
+		usage = append(usage, entry)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Parameter < usage[j].Parameter })
+	return usage
+}
+
+// parameterValueKey identifies a parameter value, so that e.g. 5 from a
+// device and 5.0 from a compile are the same value
+func parameterValueKey(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func ratio(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func (s *Service) getTemplateAnalytics(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+
+	days := defaultAnalyticsDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxAnalyticsDays {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxAnalyticsDays)})
+			return
+		}
+		days = parsed
+	}
+
+	analytics, err := s.GetTemplateAnalytics(ctx, templateID, days)
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+	case err != nil:
+		s.logger.Error("Failed to compute template analytics", "id", templateID, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to compute template analytics", "details": err.Error()})
+	default:
+		c.JSON(200, analytics)
+	}
+}
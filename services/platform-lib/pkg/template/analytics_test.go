@@ -0,0 +1,151 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticUsage is a UsageFinder with fixed devices per template
+type staticUsage map[string][]DeviceUsage
+
+func (f staticUsage) FindTemplateUsage(ctx context.Context, templateID string) ([]DeviceUsage, error) {
+	return f[templateID], nil
+}
+
+func setupAnalyticsService(t *testing.T) *Service {
+	templates := createCompatibilityTemplates()
+	templates[1].Parameters = map[string]interface{}{"interval": 60}
+	service := setupCompositionService(t, templates...)
+
+	devices := []DeviceUsage{
+		{DeviceID: "d1", Version: "1.1.0", Board: "arduino:avr:uno", Parameters: map[string]interface{}{"interval": 60, "ssid": "home"}},
+		{DeviceID: "d2", Version: "1.1.0", Board: "esp32:esp32:esp32dev", Parameters: map[string]interface{}{"interval": 30, "ssid": "office"}},
+		{DeviceID: "d3", Version: "1.1.0", Board: "arduino:avr:uno", Parameters: map[string]interface{}{"interval": 30}},
+		{DeviceID: "d4", Version: "1.0.0", Board: "arduino:avr:uno", Parameters: map[string]interface{}{"interval": 30}},
+	}
+	service.SetUsageFinders(staticUsage{"weather": devices})
+	return service
+}
+
+func TestService_GetTemplateAnalytics(t *testing.T) {
+	service := setupAnalyticsService(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	builds := []*BuildRecord{
+		{TemplateVersion: "1.1.0", Board: "esp32:esp32:esp32dev", Source: BuildSourceCompile, Error: "/tmp/sketch/sketch.ino:12:5: error: 'ledcSetup' was not declared in this scope", BuiltAt: now.Add(-time.Hour)},
+		{TemplateVersion: "1.1.0", Board: "esp32:esp32:esp32dev", Source: BuildSourceCompile, Error: "/tmp/other/sketch.ino:40:3: error: 'ledcSetup' was not declared in this scope", BuiltAt: now,
+			Parameters: map[string]interface{}{"interval": 30.0}},
+		{TemplateVersion: "1.0.0", Board: "arduino:avr:nano", Source: BuildSourceCompile, Error: "sketch too big", BuiltAt: now},
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Source: BuildSourceCompile, Success: true, BuiltAt: now,
+			Parameters: map[string]interface{}{"interval": 60}},
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Source: BuildSourceRegression, Success: true, BuiltAt: now},
+		// Outside the window
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Source: BuildSourceCompile, Error: "old failure", BuiltAt: now.AddDate(0, 0, -60)},
+	}
+	for _, build := range builds {
+		require.NoError(t, service.RecordBuild(ctx, "weather", build))
+	}
+
+	analytics, err := service.GetTemplateAnalytics(ctx, "weather", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, analytics.Devices)
+	assert.Equal(t, 5, analytics.Builds)
+	assert.Equal(t, 3, analytics.FailedBuilds)
+	assert.InDelta(t, 0.6, analytics.FailureRate, 1e-9)
+
+	require.Len(t, analytics.Versions, 2)
+	assert.Equal(t, "1.1.0", analytics.Versions[0].Version)
+	assert.Equal(t, 3, analytics.Versions[0].Devices)
+	assert.InDelta(t, 0.75, analytics.Versions[0].Share, 1e-9)
+	assert.Equal(t, 4, analytics.Versions[0].Builds)
+	assert.Equal(t, 1, analytics.Versions[1].FailedBuilds)
+
+	// Boards are listed by failures
+	require.Len(t, analytics.Boards, 3)
+	assert.Equal(t, "esp32:esp32:esp32dev", analytics.Boards[0].Board)
+	assert.Equal(t, 2, analytics.Boards[0].FailedBuilds)
+	assert.Equal(t, 1, analytics.Boards[0].Devices)
+	assert.Equal(t, "arduino:avr:nano", analytics.Boards[1].Board)
+	assert.Equal(t, "arduino:avr:uno", analytics.Boards[2].Board)
+	assert.Equal(t, 3, analytics.Boards[2].Devices)
+
+	// The same error in different sketches is counted once
+	require.Len(t, analytics.TopErrors, 2)
+	assert.Equal(t, "'ledcSetup' was not declared in this scope", analytics.TopErrors[0].Message)
+	assert.Equal(t, 2, analytics.TopErrors[0].Count)
+	assert.Equal(t, []string{"esp32:esp32:esp32dev"}, analytics.TopErrors[0].Boards)
+	assert.Equal(t, "sketch too big", analytics.TopErrors[1].Message)
+
+	// Rare values are only counted as other values, except the default
+	require.Len(t, analytics.Parameters, 2)
+	interval := analytics.Parameters[0]
+	assert.Equal(t, "interval", interval.Parameter)
+	assert.Equal(t, 6, interval.Uses)
+	require.Len(t, interval.Values, 2)
+	assert.Equal(t, 3, interval.Values[0].Devices)
+	assert.Equal(t, 1, interval.Values[0].Compiles)
+	assert.False(t, interval.Values[0].Default)
+	assert.True(t, interval.Values[1].Default)
+	assert.Zero(t, interval.Other)
+
+	ssid := analytics.Parameters[1]
+	assert.Equal(t, "ssid", ssid.Parameter)
+	assert.Empty(t, ssid.Values)
+	assert.Equal(t, 2, ssid.Other)
+
+	_, err = service.GetTemplateAnalytics(ctx, "missing", 30)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestService_CompileBuildsStayOutOfCompatibility(t *testing.T) {
+	service := setupCompositionService(t, createCompatibilityTemplates()...)
+	ctx := context.Background()
+
+	require.NoError(t, service.RecordBuild(ctx, "weather", &BuildRecord{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Success: true}))
+	require.NoError(t, service.RecordBuild(ctx, "weather", &BuildRecord{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Source: BuildSourceCompile, Error: "user sketch error"}))
+
+	matrix, err := service.GetCompatibilityMatrix(ctx, "weather")
+	require.NoError(t, err)
+	assert.Equal(t, CompatibilityVerified, matrix.Versions[0].Boards[0].Status)
+
+	// User compiles do not push regression builds out of the store
+	for i := 0; i < maxBuildRecords+5; i++ {
+		require.NoError(t, service.RecordBuild(ctx, "weather", &BuildRecord{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", Source: BuildSourceCompile, Success: true}))
+	}
+	matrix, err = service.GetCompatibilityMatrix(ctx, "weather")
+	require.NoError(t, err)
+	assert.Equal(t, 1, matrix.Versions[0].Boards[0].SuccessfulBuilds)
+}
+
+func TestService_GetTemplateAnalyticsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupAnalyticsService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/templates/weather/analytics?days=7")
+	require.Equal(t, http.StatusOK, w.Code)
+	var analytics TemplateAnalytics
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+	assert.Equal(t, "weather", analytics.TemplateID)
+	assert.Equal(t, 4, analytics.Devices)
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/templates/weather/analytics?days=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/templates/weather/analytics?days=1000").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/templates/missing/analytics").Code)
+}
@@ -0,0 +1,154 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// BlockOverridesParameter is the render parameter holding block overrides as
+// a map of block name to template code
+const BlockOverridesParameter = "blocks"
+
+// snippetTemplateName names the parsed supplemental snippet file
+const snippetTemplateName = "_snippets"
+
+var (
+	// blockActionPattern matches {{block "name"}} written without a pipeline
+	blockActionPattern = regexp.MustCompile(`\{\{(-?\s*)block\s+("[^"]+")(\s*-?)\}\}`)
+	blockNamePattern   = regexp.MustCompile(`\{\{-?\s*block\s+"([^"]+)"`)
+	defineNamePattern  = regexp.MustCompile(`\{\{-?\s*define\s+"([^"]+)"`)
+)
+
+// BlockOverrides maps override block names to replacement template code
+type BlockOverrides map[string]string
+
+// NormalizeBlocks rewrites the shorthand {{block "name"}} into the standard
+// {{block "name" .}} so blocks see the same parameters as the template
+func NormalizeBlocks(code string) string {
+	return blockActionPattern.ReplaceAllString(code, `{{${1}block $2 .$3}}`)
+}
+
+// BlockNames returns the override blocks declared in template code
+func BlockNames(code string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range blockNamePattern.FindAllStringSubmatch(code, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// declaredBlocks returns the sorted override blocks declared across a main
+// template and its includes
+func declaredBlocks(code string, includes map[string]string) []string {
+	seen := make(map[string]bool)
+	for _, name := range BlockNames(code) {
+		seen[name] = true
+	}
+	for _, content := range includes {
+		for _, name := range BlockNames(content) {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExtractBlockOverrides removes block overrides from render parameters so
+// they are not validated against the template schema
+func ExtractBlockOverrides(parameters map[string]interface{}) (map[string]interface{}, BlockOverrides, error) {
+	raw, exists := parameters[BlockOverridesParameter]
+	if !exists {
+		return parameters, nil, nil
+	}
+
+	overrides := make(BlockOverrides)
+	switch blocks := raw.(type) {
+	case map[string]interface{}:
+		for name, value := range blocks {
+			code, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("override for block '%s' must be a string", name)
+			}
+			overrides[name] = code
+		}
+	case map[string]string:
+		for name, code := range blocks {
+			overrides[name] = code
+		}
+	default:
+		return nil, nil, fmt.Errorf("parameter '%s' must map block names to code", BlockOverridesParameter)
+	}
+
+	remaining := make(map[string]interface{}, len(parameters)-1)
+	for name, value := range parameters {
+		if name != BlockOverridesParameter {
+			remaining[name] = value
+		}
+	}
+
+	return remaining, overrides, nil
+}
+
+// ApplyBlockOverrides fills the given declared override blocks using a
+// supplemental snippet file of {{define "name"}}...{{end}} sections and
+// explicit overrides, which take precedence over the snippet file. Overriding
+// a block the template does not declare is an error.
+func ApplyBlockOverrides(tmpl *template.Template, blocks []string, overrides BlockOverrides, snippets string) error {
+	declared := make(map[string]bool)
+	for _, name := range blocks {
+		declared[name] = true
+	}
+
+	if strings.TrimSpace(snippets) != "" {
+		for _, match := range defineNamePattern.FindAllStringSubmatch(snippets, -1) {
+			if !declared[match[1]] {
+				return unknownBlockError(match[1], declared)
+			}
+		}
+		if _, err := tmpl.New(snippetTemplateName).Parse(NormalizeBlocks(snippets)); err != nil {
+			return fmt.Errorf("failed to parse snippet file: %w", err)
+		}
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !declared[name] {
+			return unknownBlockError(name, declared)
+		}
+		if _, err := tmpl.New(name).Parse(overrides[name]); err != nil {
+			return fmt.Errorf("failed to parse override for block %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func unknownBlockError(name string, declared map[string]bool) error {
+	available := make([]string, 0, len(declared))
+	for block := range declared {
+		available = append(available, block)
+	}
+	sort.Strings(available)
+
+	if len(available) == 0 {
+		return fmt.Errorf("unknown override block '%s': template declares no blocks", name)
+	}
+	return fmt.Errorf("unknown override block '%s' (available: %s)", name, strings.Join(available, ", "))
+}
@@ -0,0 +1,99 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const blockSketch = `void loop() {
+  read();
+{{block "loop_extra"}}{{end}}
+  delay({{.delay_ms}});
+}
+{{- block "footer" -}}
+// default footer
+{{- end}}`
+
+func TestNormalizeBlocks(t *testing.T) {
+	assert.Equal(t, `{{block "loop_extra" .}}{{end}}`, NormalizeBlocks(`{{block "loop_extra"}}{{end}}`))
+	assert.Equal(t, `{{- block "x" . -}}`, NormalizeBlocks(`{{- block "x" -}}`))
+	assert.Equal(t, `{{block "x" .cfg}}`, NormalizeBlocks(`{{block "x" .cfg}}`), "explicit pipelines are kept")
+	assert.Equal(t, []string{"loop_extra", "footer"}, BlockNames(blockSketch))
+}
+
+func TestExtractBlockOverrides(t *testing.T) {
+	params, overrides, err := ExtractBlockOverrides(map[string]interface{}{
+		"delay_ms": 100,
+		"blocks":   map[string]interface{}{"loop_extra": "  blink();"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"delay_ms": 100}, params)
+	assert.Equal(t, BlockOverrides{"loop_extra": "  blink();"}, overrides)
+
+	_, _, err = ExtractBlockOverrides(map[string]interface{}{"blocks": "nope"})
+	assert.Error(t, err)
+}
+
+func TestRenderWithOverrides(t *testing.T) {
+	renderer := NewTemplateRenderer()
+	params := map[string]interface{}{"delay_ms": 500, "led": 13}
+
+	code, err := renderer.RenderWithOverrides(blockSketch, nil, params, nil, "")
+	require.NoError(t, err)
+	assert.Contains(t, code, "  read();\n\n  delay(500);")
+	assert.Contains(t, code, "// default footer")
+
+	snippets := `{{define "loop_extra"}}  digitalWrite({{.led}}, HIGH);{{end}}
+{{define "footer"}}// custom footer{{end}}`
+	code, err = renderer.RenderWithOverrides(blockSketch, nil, params, BlockOverrides{"footer": "// param footer"}, snippets)
+	require.NoError(t, err)
+	assert.Contains(t, code, "  digitalWrite(13, HIGH);")
+	assert.Contains(t, code, "// param footer", "explicit overrides win over the snippet file")
+	assert.NotContains(t, code, "default footer")
+}
+
+func TestRenderWithOverrides_UnknownBlock(t *testing.T) {
+	renderer := NewTemplateRenderer()
+
+	_, err := renderer.RenderWithOverrides(blockSketch, nil, nil, BlockOverrides{"setup_extra": "x"}, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "available: footer, loop_extra")
+
+	_, err = renderer.RenderWithOverrides(blockSketch, nil, nil, nil, `{{define "setup_extra"}}x{{end}}`)
+	assert.Error(t, err)
+}
+
+func TestService_RenderTemplateWithOverrides(t *testing.T) {
+	tmpl := &Template{
+		ID:              "blink",
+		Name:            "Blink",
+		Version:         "1.0.0",
+		Category:        "automation",
+		BoardsSupported: []string{"arduino:avr:uno"},
+		Schema: map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"delay_ms": map[string]interface{}{"type": "integer"},
+			},
+		},
+		Assets: []Asset{{
+			Type:     "code",
+			Path:     "main.ino",
+			Metadata: map[string]interface{}{"content": blockSketch},
+		}},
+	}
+	service := setupCompositionService(t, tmpl)
+
+	rendered, err := service.RenderTemplateWithOverrides(context.Background(), "blink", "1.0.0", map[string]interface{}{
+		"delay_ms": 250,
+		"blocks":   map[string]interface{}{"loop_extra": "  Serial.println(\"tick\");"},
+	}, "")
+	require.NoError(t, err)
+	assert.Contains(t, rendered.RenderedCode, "  Serial.println(\"tick\");")
+	assert.NotContains(t, rendered.Parameters, "blocks")
+	assert.Equal(t, []string{"footer", "loop_extra"}, rendered.Blocks)
+}
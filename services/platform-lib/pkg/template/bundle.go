@@ -0,0 +1,399 @@
+package template
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Provenance statuses of imported templates
+const (
+	// ProvenanceVerified bundles were signed by a registered publisher
+	ProvenanceVerified = "verified"
+	// ProvenanceUnverified bundles were signed by a publisher the platform
+	// has no key for
+	ProvenanceUnverified = "unverified"
+	// ProvenanceUnsigned bundles carried no signature
+	ProvenanceUnsigned = "unsigned"
+)
+
+// BundleAlgorithm is the signature scheme for template bundles, the same
+// RSA-PSS over SHA-256 used for firmware releases
+const BundleAlgorithm = "rsa-pss-sha256"
+
+var (
+	ErrInvalidBundle          = errors.New("invalid template bundle")
+	ErrInvalidBundleSignature = errors.New("template bundle signature verification failed")
+	ErrUnverifiedBundle       = errors.New("template bundle is not signed by a registered publisher")
+	ErrPublisherNotFound      = errors.New("publisher not found")
+	ErrPublisherExists        = errors.New("publisher already exists")
+)
+
+// TemplateBundle is a template packaged for distribution, optionally signed
+// by its publisher
+type TemplateBundle struct {
+	Template  *Template        `json:"template"`
+	Signature *BundleSignature `json:"signature,omitempty"`
+}
+
+// BundleSignature is a publisher's signature over a bundle's template
+type BundleSignature struct {
+	PublisherID string `json:"publisher_id"`
+	Algorithm   string `json:"algorithm"`
+	Signature   string `json:"signature"` // base64
+}
+
+// Provenance records where an imported template came from. Templates
+// created on the platform have none.
+type Provenance struct {
+	Status         string    `json:"status"`
+	PublisherID    string    `json:"publisher_id,omitempty"`
+	PublisherName  string    `json:"publisher_name,omitempty"` // set for registered publishers
+	KeyFingerprint string    `json:"key_fingerprint,omitempty"`
+	Digest         string    `json:"digest"`
+	ImportedAt     time.Time `json:"imported_at"`
+}
+
+// Verified reports whether the template was signed by a registered
+// publisher
+func (p *Provenance) Verified() bool {
+	return p != nil && p.Status == ProvenanceVerified
+}
+
+// Publisher is a registered source of community templates
+type Publisher struct {
+	ID             string    `json:"id" binding:"required"`
+	Name           string    `json:"name" binding:"required"`
+	PublicKey      string    `json:"public_key" binding:"required"` // PEM
+	KeyFingerprint string    `json:"key_fingerprint"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PublisherStore persists registered publishers
+type PublisherStore interface {
+	CreatePublisher(ctx context.Context, publisher *Publisher) error
+	GetPublisher(ctx context.Context, id string) (*Publisher, error)
+	ListPublishers(ctx context.Context) ([]*Publisher, error)
+}
+
+// MemoryPublisherStore keeps publishers in memory
+type MemoryPublisherStore struct {
+	mu         sync.RWMutex
+	publishers map[string]*Publisher
+}
+
+// NewMemoryPublisherStore creates an empty in-memory publisher store
+func NewMemoryPublisherStore() *MemoryPublisherStore {
+	return &MemoryPublisherStore{publishers: make(map[string]*Publisher)}
+}
+
+func (m *MemoryPublisherStore) CreatePublisher(ctx context.Context, publisher *Publisher) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.publishers[publisher.ID]; exists {
+		return ErrPublisherExists
+	}
+	stored := *publisher
+	m.publishers[publisher.ID] = &stored
+	return nil
+}
+
+func (m *MemoryPublisherStore) GetPublisher(ctx context.Context, id string) (*Publisher, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	publisher, ok := m.publishers[id]
+	if !ok {
+		return nil, ErrPublisherNotFound
+	}
+	copied := *publisher
+	return &copied, nil
+}
+
+// ListPublishers returns publishers ordered by ID
+func (m *MemoryPublisherStore) ListPublishers(ctx context.Context) ([]*Publisher, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	publishers := make([]*Publisher, 0, len(m.publishers))
+	for _, publisher := range m.publishers {
+		copied := *publisher
+		publishers = append(publishers, &copied)
+	}
+	sort.Slice(publishers, func(i, j int) bool { return publishers[i].ID < publishers[j].ID })
+	return publishers, nil
+}
+
+// SetPublisherStore sets where registered publishers are kept
+func (s *Service) SetPublisherStore(store PublisherStore) {
+	s.publishers = store
+}
+
+// BundleDigest returns the SHA-256 digest a bundle signature covers: the
+// template's JSON without timestamps, provenance or environments, which the
+// platform sets
+func BundleDigest(tmpl *Template) ([]byte, error) {
+	signed := *tmpl
+	signed.CreatedAt = time.Time{}
+	signed.UpdatedAt = time.Time{}
+	signed.Provenance = nil
+	signed.Environments = nil
+
+	data, err := json.Marshal(&signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// SignBundle packages a template signed with the publisher's PEM-encoded
+// RSA private key
+func SignBundle(tmpl *Template, publisherID string, privateKeyPEM []byte) (*TemplateBundle, error) {
+	if publisherID == "" {
+		return nil, fmt.Errorf("%w: publisher ID is required", ErrInvalidBundle)
+	}
+	key, err := parsePublisherPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := BundleDigest(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign bundle: %w", err)
+	}
+	return &TemplateBundle{
+		Template: tmpl,
+		Signature: &BundleSignature{
+			PublisherID: publisherID,
+			Algorithm:   BundleAlgorithm,
+			Signature:   base64.StdEncoding.EncodeToString(signature),
+		},
+	}, nil
+}
+
+// VerifyBundle checks a bundle's signature against a PEM-encoded RSA public
+// key
+func VerifyBundle(bundle *TemplateBundle, publicKeyPEM string) error {
+	if bundle.Template == nil || bundle.Signature == nil {
+		return fmt.Errorf("%w: bundle is not signed", ErrInvalidBundleSignature)
+	}
+	if bundle.Signature.Algorithm != BundleAlgorithm {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidBundleSignature, bundle.Signature.Algorithm)
+	}
+	key, err := parsePublisherKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(bundle.Signature.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundleSignature, err)
+	}
+	digest, err := BundleDigest(bundle.Template)
+	if err != nil {
+		return err
+	}
+	if err := rsa.VerifyPSS(key, crypto.SHA256, digest, signature, nil); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundleSignature, err)
+	}
+	return nil
+}
+
+// KeyFingerprint identifies a PEM-encoded public key by the SHA-256 of its
+// DER encoding
+func KeyFingerprint(publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", fmt.Errorf("failed to decode PEM block")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func parsePublisherKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+func parsePublisherPrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// RegisterPublisher registers a publisher whose signed bundles are trusted
+func (s *Service) RegisterPublisher(ctx context.Context, publisher *Publisher) (*Publisher, error) {
+	if _, err := parsePublisherKey(publisher.PublicKey); err != nil {
+		return nil, fmt.Errorf("invalid publisher key: %w", err)
+	}
+	fingerprint, err := KeyFingerprint(publisher.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher key: %w", err)
+	}
+
+	registered := *publisher
+	registered.KeyFingerprint = fingerprint
+	registered.CreatedAt = time.Now().UTC()
+	if err := s.publishers.CreatePublisher(ctx, &registered); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Registered template publisher", "publisher_id", registered.ID, "fingerprint", fingerprint)
+	return &registered, nil
+}
+
+// ImportBundle verifies a template bundle and creates its template,
+// recording the publisher. A bad signature always rejects the bundle;
+// unsigned bundles and bundles from unknown publishers are imported and
+// flagged unless the configuration requires signed bundles.
+func (s *Service) ImportBundle(ctx context.Context, bundle *TemplateBundle) (*Template, error) {
+	if bundle.Template == nil {
+		return nil, fmt.Errorf("%w: template is required", ErrInvalidBundle)
+	}
+	tmpl := bundle.Template
+
+	digest, err := BundleDigest(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	provenance := &Provenance{
+		Status:     ProvenanceUnsigned,
+		Digest:     "sha256:" + hex.EncodeToString(digest),
+		ImportedAt: time.Now().UTC(),
+	}
+
+	if bundle.Signature != nil {
+		provenance.PublisherID = bundle.Signature.PublisherID
+		publisher, err := s.publishers.GetPublisher(ctx, bundle.Signature.PublisherID)
+		switch {
+		case errors.Is(err, ErrPublisherNotFound):
+			provenance.Status = ProvenanceUnverified
+		case err != nil:
+			return nil, fmt.Errorf("failed to look up publisher: %w", err)
+		default:
+			if err := VerifyBundle(bundle, publisher.PublicKey); err != nil {
+				s.logger.Warn("Rejected template bundle", "id", tmpl.ID, "version", tmpl.Version, "publisher_id", publisher.ID, "error", err)
+				return nil, err
+			}
+			provenance.Status = ProvenanceVerified
+			provenance.PublisherName = publisher.Name
+			provenance.KeyFingerprint = publisher.KeyFingerprint
+		}
+	}
+
+	if !provenance.Verified() && s.config.Templates.RequireSignedBundles {
+		return nil, fmt.Errorf("%w: bundle is %s", ErrUnverifiedBundle, provenance.Status)
+	}
+
+	tmpl.Provenance = provenance
+	if err := s.storeTemplate(ctx, tmpl); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Imported template bundle", "id", tmpl.ID, "version", tmpl.Version,
+		"status", provenance.Status, "publisher_id", provenance.PublisherID)
+	return tmpl, nil
+}
+
+func (s *Service) importBundle(c *gin.Context) {
+	var bundle TemplateBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	tmpl, err := s.ImportBundle(c.Request.Context(), &bundle)
+	if err != nil {
+		s.logger.Error("Failed to import template bundle", "error", err)
+		status := 422
+		if errors.Is(err, ErrInvalidBundle) {
+			status = 400
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, tmpl)
+}
+
+func (s *Service) createPublisher(c *gin.Context) {
+	var publisher Publisher
+	if err := c.ShouldBindJSON(&publisher); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	registered, err := s.RegisterPublisher(c.Request.Context(), &publisher)
+	if err != nil {
+		status := 400
+		if errors.Is(err, ErrPublisherExists) {
+			status = 409
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, registered)
+}
+
+func (s *Service) listPublishers(c *gin.Context) {
+	publishers, err := s.publishers.ListPublishers(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to list publishers"})
+		return
+	}
+	c.JSON(200, gin.H{"publishers": publishers, "count": len(publishers)})
+}
+
+func (s *Service) getPublisher(c *gin.Context) {
+	publisher, err := s.publishers.GetPublisher(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := 500
+		if errors.Is(err, ErrPublisherNotFound) {
+			status = 404
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, publisher)
+}
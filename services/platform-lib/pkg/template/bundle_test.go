@@ -0,0 +1,267 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generatePublisherKey returns a PEM-encoded RSA key pair
+func generatePublisherKey(t *testing.T) (privateKeyPEM []byte, publicKeyPEM string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func TestSignAndVerifyBundle(t *testing.T) {
+	privateKey, publicKey := generatePublisherKey(t)
+	_, otherKey := generatePublisherKey(t)
+
+	bundle, err := SignBundle(createTestTemplate(), "acme", privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", bundle.Signature.PublisherID)
+	assert.Equal(t, BundleAlgorithm, bundle.Signature.Algorithm)
+	require.NoError(t, VerifyBundle(bundle, publicKey))
+
+	// Timestamps are set by the platform and not covered by the signature
+	bundle.Template.CreatedAt = bundle.Template.CreatedAt.AddDate(1, 0, 0)
+	assert.NoError(t, VerifyBundle(bundle, publicKey))
+
+	assert.ErrorIs(t, VerifyBundle(bundle, otherKey), ErrInvalidBundleSignature)
+
+	bundle.Template.Libraries[0].URL = "https://example.com/patched-dht.zip"
+	assert.ErrorIs(t, VerifyBundle(bundle, publicKey), ErrInvalidBundleSignature)
+
+	_, err = SignBundle(createTestTemplate(), "", privateKey)
+	assert.ErrorIs(t, err, ErrInvalidBundle)
+}
+
+func TestService_ImportBundle(t *testing.T) {
+	privateKey, publicKey := generatePublisherKey(t)
+	ctx := context.Background()
+	service := setupCompositionService(t)
+
+	publisher, err := service.RegisterPublisher(ctx, &Publisher{ID: "acme", Name: "Acme Sensors", PublicKey: publicKey})
+	require.NoError(t, err)
+	assert.Contains(t, publisher.KeyFingerprint, "SHA256:")
+	_, err = service.RegisterPublisher(ctx, &Publisher{ID: "acme", Name: "Acme Sensors", PublicKey: publicKey})
+	assert.ErrorIs(t, err, ErrPublisherExists)
+	_, err = service.RegisterPublisher(ctx, &Publisher{ID: "bad", Name: "Bad", PublicKey: "not a key"})
+	assert.Error(t, err)
+
+	// Signed by a registered publisher
+	bundle, err := SignBundle(createTestTemplate(), "acme", privateKey)
+	require.NoError(t, err)
+	imported, err := service.ImportBundle(ctx, bundle)
+	require.NoError(t, err)
+	require.NotNil(t, imported.Provenance)
+	assert.Equal(t, ProvenanceVerified, imported.Provenance.Status)
+	assert.Equal(t, "Acme Sensors", imported.Provenance.PublisherName)
+	assert.Equal(t, publisher.KeyFingerprint, imported.Provenance.KeyFingerprint)
+
+	stored, err := service.GetTemplate(ctx, "test-template-1", "1.0.0")
+	require.NoError(t, err)
+	assert.True(t, stored.Provenance.Verified())
+
+	// Tampered after signing
+	tampered := createTestTemplate()
+	tampered.Version = "1.1.0"
+	bundle, err = SignBundle(tampered, "acme", privateKey)
+	require.NoError(t, err)
+	bundle.Template.Description = "Now with extra features"
+	_, err = service.ImportBundle(ctx, bundle)
+	assert.ErrorIs(t, err, ErrInvalidBundleSignature)
+
+	// Signed by a publisher the platform does not know
+	otherKey, _ := generatePublisherKey(t)
+	unknown := createTestTemplate()
+	unknown.Version = "1.2.0"
+	bundle, err = SignBundle(unknown, "someone-else", otherKey)
+	require.NoError(t, err)
+	imported, err = service.ImportBundle(ctx, bundle)
+	require.NoError(t, err)
+	assert.Equal(t, ProvenanceUnverified, imported.Provenance.Status)
+	assert.Equal(t, "someone-else", imported.Provenance.PublisherID)
+
+	// Unsigned
+	unsigned := createTestTemplate()
+	unsigned.Version = "1.3.0"
+	imported, err = service.ImportBundle(ctx, &TemplateBundle{Template: unsigned})
+	require.NoError(t, err)
+	assert.Equal(t, ProvenanceUnsigned, imported.Provenance.Status)
+	assert.False(t, imported.Provenance.Verified())
+
+	_, err = service.ImportBundle(ctx, &TemplateBundle{})
+	assert.ErrorIs(t, err, ErrInvalidBundle)
+}
+
+func TestService_ImportBundle_RequireSigned(t *testing.T) {
+	privateKey, publicKey := generatePublisherKey(t)
+	ctx := context.Background()
+	service, err := NewService(&config.Config{
+		ServiceName: "test-template-service",
+		Templates:   config.TemplatesConfig{RequireSignedBundles: true},
+	}, logger.New("debug", "test"), NewMemoryRepository())
+	require.NoError(t, err)
+
+	_, err = service.ImportBundle(ctx, &TemplateBundle{Template: createTestTemplate()})
+	assert.ErrorIs(t, err, ErrUnverifiedBundle)
+
+	bundle, err := SignBundle(createTestTemplate(), "acme", privateKey)
+	require.NoError(t, err)
+	_, err = service.ImportBundle(ctx, bundle)
+	assert.ErrorIs(t, err, ErrUnverifiedBundle)
+
+	_, err = service.RegisterPublisher(ctx, &Publisher{ID: "acme", Name: "Acme Sensors", PublicKey: publicKey})
+	require.NoError(t, err)
+	_, err = service.ImportBundle(ctx, bundle)
+	assert.NoError(t, err)
+
+	// Templates cannot be created around the bundle check
+	unsigned := createTestTemplate()
+	unsigned.Version = "2.0.0"
+	assert.ErrorIs(t, service.CreateTemplate(ctx, unsigned), ErrUnverifiedBundle)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, service)
+	body, err := json.Marshal(unsigned)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/templates", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestService_ProvenanceNotSpoofable(t *testing.T) {
+	privateKey, publicKey := generatePublisherKey(t)
+	ctx := context.Background()
+	service := setupCompositionService(t)
+	spoofed := &Provenance{Status: ProvenanceVerified, PublisherID: "acme", PublisherName: "Acme Sensors"}
+
+	tmpl := createTestTemplate()
+	tmpl.Provenance = spoofed
+	require.NoError(t, service.CreateTemplate(ctx, tmpl))
+	stored, err := service.GetTemplate(ctx, "test-template-1", "1.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Provenance)
+
+	// Updates keep what the import recorded
+	_, err = service.RegisterPublisher(ctx, &Publisher{ID: "acme", Name: "Acme Sensors", PublicKey: publicKey})
+	require.NoError(t, err)
+	signed := createTestTemplate()
+	signed.Version = "1.1.0"
+	bundle, err := SignBundle(signed, "acme", privateKey)
+	require.NoError(t, err)
+	imported, err := service.ImportBundle(ctx, bundle)
+	require.NoError(t, err)
+
+	update := createTestTemplate()
+	update.Version = "1.1.0"
+	update.Provenance = &Provenance{Status: ProvenanceVerified, PublisherID: "someone-else"}
+	require.NoError(t, service.UpdateTemplate(ctx, update))
+	stored, err = service.GetTemplate(ctx, "test-template-1", "1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, imported.Provenance, stored.Provenance)
+
+	update.Version = "1.0.0"
+	update.Provenance = spoofed
+	require.NoError(t, service.UpdateTemplate(ctx, update))
+	stored, err = service.GetTemplate(ctx, "test-template-1", "1.0.0")
+	require.NoError(t, err)
+	assert.Nil(t, stored.Provenance)
+}
+
+func TestImportBundleHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	privateKey, publicKey := generatePublisherKey(t)
+	service := setupCompositionService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/v1/publishers", map[string]string{"id": "acme", "name": "Acme Sensors", "public_key": publicKey})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/publishers", map[string]string{"id": "acme", "name": "Acme", "public_key": publicKey}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/publishers/acme", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/publishers/nobody", nil).Code)
+
+	// The signature survives the JSON round trip to the service
+	bundle, err := SignBundle(createTestTemplate(), "acme", privateKey)
+	require.NoError(t, err)
+	w = do(http.MethodPost, "/api/v1/templates/import", bundle)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var imported Template
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, ProvenanceVerified, imported.Provenance.Status)
+
+	w = do(http.MethodGet, "/api/v1/templates/test-template-1?version=1.0.0", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"verified"`)
+
+	forged := createTestTemplate()
+	forged.Version = "1.0.1"
+	bundle, err = SignBundle(forged, "acme", privateKey)
+	require.NoError(t, err)
+	bundle.Template.Parameters["sensorPin"] = 3
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/api/v1/templates/import", bundle).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/templates/import", map[string]string{}).Code)
+}
+
+func TestTemplateEntity_Provenance(t *testing.T) {
+	tmpl := createTestTemplate()
+	tmpl.Provenance = &Provenance{Status: ProvenanceUnverified, PublisherID: "acme", Digest: "sha256:abc"}
+
+	entity, err := tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.Provenance, restored.Provenance)
+
+	tmpl.Provenance = nil
+	entity, err = tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err = entity.FromEntity()
+	require.NoError(t, err)
+	assert.Nil(t, restored.Provenance)
+}
+
+func TestPublisherEntity(t *testing.T) {
+	_, publicKey := generatePublisherKey(t)
+	publisher := &Publisher{
+		ID:             "acme",
+		Name:           "Acme Sensors",
+		PublicKey:      publicKey,
+		KeyFingerprint: "SHA256:abc",
+		CreatedAt:      time.Now().UTC().Truncate(time.Second),
+	}
+	assert.Equal(t, publisher, publisher.ToEntity().FromEntity("acme"))
+}
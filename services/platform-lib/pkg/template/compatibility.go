@@ -0,0 +1,345 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBuildRecords bounds the builds kept per template, separately for
+// user compiles and other builds; older ones are dropped first
+const maxBuildRecords = 1000
+
+// ErrInvalidBuildRecord is returned for build reports that cannot be
+// recorded
+var ErrInvalidBuildRecord = errors.New("invalid build record")
+
+// Board compatibility statuses, from the builds recorded for a board
+const (
+	// CompatibilityVerified means the latest build for the board succeeded
+	CompatibilityVerified = "verified"
+	// CompatibilityFailing means the latest build for the board failed
+	CompatibilityFailing = "failing"
+	// CompatibilityUntested means the board is declared but never built
+	CompatibilityUntested = "untested"
+)
+
+// Sources of build records. Builds without a source come from CI.
+const (
+	// BuildSourceRegression is a build of the regression runner
+	BuildSourceRegression = "regression"
+	// BuildSourceCompile is a user's compile on the provisioning service.
+	// It depends on the user's parameters, so it counts towards template
+	// analytics but not board compatibility.
+	BuildSourceCompile = "compile"
+)
+
+// BuildRecord is the outcome of compiling a template version for a board,
+// reported by the provisioning service or CI
+type BuildRecord struct {
+	TemplateID      string                 `json:"template_id"`
+	TemplateVersion string                 `json:"template_version" binding:"required"`
+	Board           string                 `json:"board" binding:"required"` // fully qualified board name
+	CoreVersion     string                 `json:"core_version,omitempty"`
+	Libraries       []LibraryDependency    `json:"libraries,omitempty"` // library versions the build resolved
+	Source          string                 `json:"source,omitempty"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"` // of user compiles
+	Success         bool                   `json:"success"`
+	Error           string                 `json:"error,omitempty"`
+	BuiltAt         time.Time              `json:"built_at"`
+}
+
+// BuildRecordStore keeps the build history of templates
+type BuildRecordStore interface {
+	AddBuildRecord(ctx context.Context, record *BuildRecord) error
+	ListBuildRecords(ctx context.Context, templateID string) ([]*BuildRecord, error)
+}
+
+// MemoryBuildRecordStore keeps the most recent builds of each template in
+// memory
+type MemoryBuildRecordStore struct {
+	mu      sync.RWMutex
+	records map[string][]*BuildRecord
+}
+
+// NewMemoryBuildRecordStore creates an empty build record store
+func NewMemoryBuildRecordStore() *MemoryBuildRecordStore {
+	return &MemoryBuildRecordStore{records: make(map[string][]*BuildRecord)}
+}
+
+// AddBuildRecord stores a build, dropping the oldest of its kind beyond
+// maxBuildRecords, so user compiles do not push out CI and regression
+// builds
+func (m *MemoryBuildRecordStore) AddBuildRecord(ctx context.Context, record *BuildRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	compile := record.Source == BuildSourceCompile
+	records := append(m.records[record.TemplateID], record)
+	kept := 0
+	for _, r := range records {
+		if (r.Source == BuildSourceCompile) == compile {
+			kept++
+		}
+	}
+	if kept > maxBuildRecords {
+		oldest := slices.IndexFunc(records, func(r *BuildRecord) bool { return (r.Source == BuildSourceCompile) == compile })
+		records = slices.Delete(records, oldest, oldest+1)
+	}
+	m.records[record.TemplateID] = records
+	return nil
+}
+
+// ListBuildRecords returns the builds of a template in the order recorded
+func (m *MemoryBuildRecordStore) ListBuildRecords(ctx context.Context, templateID string) ([]*BuildRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.records[templateID]), nil
+}
+
+// SetBuildRecordStore replaces the store build history is kept in
+func (s *Service) SetBuildRecordStore(store BuildRecordStore) {
+	s.builds = store
+}
+
+// CompatibilityMatrix lists, for every version of a template, the boards
+// it runs on and what they need
+type CompatibilityMatrix struct {
+	TemplateID string                  `json:"template_id"`
+	Boards     []string                `json:"boards"` // every board of any version, for table columns
+	Versions   []*VersionCompatibility `json:"versions"`
+}
+
+// VersionCompatibility is a row of the compatibility matrix
+type VersionCompatibility struct {
+	Version   string                `json:"version"`
+	Libraries []LibraryDependency   `json:"libraries"`
+	Boards    []*BoardCompatibility `json:"boards"`
+}
+
+// BoardCompatibility describes a template version on one board. Boards
+// that are not declared appear when a build for them succeeded.
+type BoardCompatibility struct {
+	Board    string `json:"board"`
+	Core     string `json:"core"`
+	Declared bool   `json:"declared"`
+	Status   string `json:"status"`
+	// MinCoreVersion is the declared minimum core version, or else the
+	// oldest core version a build succeeded with
+	MinCoreVersion   string              `json:"min_core_version,omitempty"`
+	MinCoreSource    string              `json:"min_core_source,omitempty"` // "declared" or "builds"
+	Libraries        []LibraryDependency `json:"libraries,omitempty"`       // as resolved by the last successful build
+	SuccessfulBuilds int                 `json:"successful_builds"`
+	FailedBuilds     int                 `json:"failed_builds"`
+	LastBuildAt      *time.Time          `json:"last_build_at,omitempty"`
+	LastSuccessfulAt *time.Time          `json:"last_successful_at,omitempty"`
+	LastBuildError   string              `json:"last_build_error,omitempty"`
+}
+
+// boardCore returns the core of a fully qualified board name, e.g.
+// "esp32:esp32" for "esp32:esp32:esp32dev". Short names are their own core.
+func boardCore(board string) string {
+	parts := strings.SplitN(board, ":", 3)
+	if len(parts) < 2 {
+		return board
+	}
+	return parts[0] + ":" + parts[1]
+}
+
+// RecordBuild adds the outcome of a build of a template version
+func (s *Service) RecordBuild(ctx context.Context, templateID string, record *BuildRecord) error {
+	if strings.TrimSpace(record.Board) == "" {
+		return fmt.Errorf("%w: board is required", ErrInvalidBuildRecord)
+	}
+	if !record.Success && record.Error == "" {
+		return fmt.Errorf("%w: failed builds need an error", ErrInvalidBuildRecord)
+	}
+	exists, err := s.repo.TemplateExists(ctx, templateID, record.TemplateVersion)
+	if err != nil {
+		return fmt.Errorf("failed to look up template: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s version %s", ErrTemplateNotFound, templateID, record.TemplateVersion)
+	}
+
+	record.TemplateID = templateID
+	if record.BuiltAt.IsZero() {
+		record.BuiltAt = time.Now().UTC()
+	}
+	if err := s.builds.AddBuildRecord(ctx, record); err != nil {
+		return fmt.Errorf("failed to record build: %w", err)
+	}
+	return nil
+}
+
+// GetCompatibilityMatrix computes the compatibility matrix of a template
+// from the metadata of its versions and the builds recorded for them.
+// Versions are listed newest first.
+func (s *Service) GetCompatibilityMatrix(ctx context.Context, templateID string) (*CompatibilityMatrix, error) {
+	versions, err := s.repo.GetTemplateVersions(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, templateID)
+	}
+	if sorted, err := s.versionManager.SortVersions(versions); err == nil {
+		versions = sorted
+	}
+	slices.Reverse(versions)
+
+	records, err := s.builds.ListBuildRecords(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+	byVersion := make(map[string][]*BuildRecord)
+	for _, record := range records {
+		byVersion[record.TemplateVersion] = append(byVersion[record.TemplateVersion], record)
+	}
+
+	matrix := &CompatibilityMatrix{TemplateID: templateID, Boards: []string{}, Versions: []*VersionCompatibility{}}
+	seenBoards := make(map[string]bool)
+	for _, version := range versions {
+		tmpl, err := s.repo.GetTemplate(ctx, templateID, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get template version %s: %w", version, err)
+		}
+		row := s.versionCompatibility(tmpl, byVersion[version])
+		for _, board := range row.Boards {
+			if !seenBoards[board.Board] {
+				seenBoards[board.Board] = true
+				matrix.Boards = append(matrix.Boards, board.Board)
+			}
+		}
+		matrix.Versions = append(matrix.Versions, row)
+	}
+	slices.Sort(matrix.Boards)
+	return matrix, nil
+}
+
+// versionCompatibility builds the matrix row of a template version.
+// Records are in the order they were recorded.
+func (s *Service) versionCompatibility(tmpl *Template, records []*BuildRecord) *VersionCompatibility {
+	libraries := tmpl.Libraries
+	if libraries == nil {
+		libraries = []LibraryDependency{}
+	}
+	row := &VersionCompatibility{Version: tmpl.Version, Libraries: libraries, Boards: []*BoardCompatibility{}}
+
+	boards := make(map[string]*BoardCompatibility)
+	board := func(name string) *BoardCompatibility {
+		if entry, ok := boards[name]; ok {
+			return entry
+		}
+		entry := &BoardCompatibility{Board: name, Core: boardCore(name), Status: CompatibilityUntested}
+		if declared, ok := tmpl.CoreVersions[entry.Core]; ok {
+			entry.MinCoreVersion = declared
+			entry.MinCoreSource = "declared"
+		}
+		boards[name] = entry
+		return entry
+	}
+	for _, name := range tmpl.BoardsSupported {
+		board(name).Declared = true
+	}
+
+	for _, record := range records {
+		if record.Source == BuildSourceCompile {
+			continue
+		}
+		entry, known := boards[record.Board]
+		if !known {
+			// Undeclared boards are listed once a build for them succeeded
+			if !record.Success {
+				continue
+			}
+			entry = board(record.Board)
+		}
+		builtAt := record.BuiltAt
+		entry.LastBuildAt = &builtAt
+		if !record.Success {
+			entry.FailedBuilds++
+			entry.LastBuildError = record.Error
+			continue
+		}
+		entry.SuccessfulBuilds++
+		entry.LastSuccessfulAt = &builtAt
+		entry.LastBuildError = ""
+		entry.Libraries = record.Libraries
+		if record.CoreVersion != "" && entry.MinCoreSource != "declared" {
+			if entry.MinCoreVersion == "" || s.olderVersion(record.CoreVersion, entry.MinCoreVersion) {
+				entry.MinCoreVersion = record.CoreVersion
+				entry.MinCoreSource = "builds"
+			}
+		}
+	}
+
+	for _, entry := range boards {
+		switch {
+		case entry.LastBuildAt == nil:
+			entry.Status = CompatibilityUntested
+		case entry.LastBuildError == "":
+			entry.Status = CompatibilityVerified
+		default:
+			entry.Status = CompatibilityFailing
+		}
+		row.Boards = append(row.Boards, entry)
+	}
+	slices.SortFunc(row.Boards, func(a, b *BoardCompatibility) int { return strings.Compare(a.Board, b.Board) })
+	return row
+}
+
+// olderVersion reports whether version a is older than b. Versions that
+// are not semantic versions compare as strings.
+func (s *Service) olderVersion(a, b string) bool {
+	comparison, err := s.versionManager.CompareVersions(a, b)
+	if err != nil {
+		return a < b
+	}
+	return comparison < 0
+}
+
+func (s *Service) getCompatibility(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+
+	matrix, err := s.GetCompatibilityMatrix(ctx, templateID)
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+	case err != nil:
+		s.logger.Error("Failed to compute compatibility matrix", "id", templateID, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to compute compatibility matrix", "details": err.Error()})
+	default:
+		c.JSON(200, matrix)
+	}
+}
+
+func (s *Service) recordBuild(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+
+	var record BuildRecord
+	if err := c.ShouldBindJSON(&record); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid build record", "details": err.Error()})
+		return
+	}
+
+	err := s.RecordBuild(ctx, templateID, &record)
+	switch {
+	case errors.Is(err, ErrInvalidBuildRecord):
+		c.JSON(400, gin.H{"error": "Invalid build record", "details": err.Error()})
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+	case err != nil:
+		s.logger.Error("Failed to record build", "id", templateID, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to record build", "details": err.Error()})
+	default:
+		c.JSON(201, record)
+	}
+}
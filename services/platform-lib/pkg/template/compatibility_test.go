@@ -0,0 +1,146 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createCompatibilityTemplates() []*Template {
+	return []*Template{
+		{
+			ID:              "weather",
+			Name:            "Weather Station",
+			Version:         "1.0.0",
+			Category:        "sensing",
+			BoardsSupported: []string{"arduino:avr:uno"},
+			Libraries:       []LibraryDependency{{Name: "DHT sensor library", Version: "1.4.4"}},
+		},
+		{
+			ID:              "weather",
+			Name:            "Weather Station",
+			Version:         "1.1.0",
+			Category:        "sensing",
+			BoardsSupported: []string{"arduino:avr:uno", "esp32:esp32:esp32dev"},
+			Libraries:       []LibraryDependency{{Name: "DHT sensor library", Version: "1.4.6"}},
+			CoreVersions:    map[string]string{"esp32:esp32": "2.0.11"},
+		},
+	}
+}
+
+func TestService_GetCompatibilityMatrix(t *testing.T) {
+	service := setupCompositionService(t, createCompatibilityTemplates()...)
+	ctx := context.Background()
+	built := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	builds := []*BuildRecord{
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", CoreVersion: "1.8.6", Success: true, BuiltAt: built},
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", CoreVersion: "1.8.5", Success: true, BuiltAt: built.Add(time.Hour),
+			Libraries: []LibraryDependency{{Name: "DHT sensor library", Version: "1.4.6"}}},
+		{TemplateVersion: "1.1.0", Board: "esp32:esp32:esp32dev", CoreVersion: "3.0.0", Success: false, Error: "'ledcSetup' was not declared", BuiltAt: built},
+		{TemplateVersion: "1.1.0", Board: "arduino:avr:nano", CoreVersion: "1.8.6", Success: false, Error: "sketch too big", BuiltAt: built},
+		{TemplateVersion: "1.0.0", Board: "arduino:samd:mkr1000", CoreVersion: "1.8.13", Success: true, BuiltAt: built},
+	}
+	for _, build := range builds {
+		require.NoError(t, service.RecordBuild(ctx, "weather", build))
+	}
+
+	matrix, err := service.GetCompatibilityMatrix(ctx, "weather")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arduino:avr:uno", "arduino:samd:mkr1000", "esp32:esp32:esp32dev"}, matrix.Boards)
+	require.Len(t, matrix.Versions, 2)
+
+	latest := matrix.Versions[0]
+	assert.Equal(t, "1.1.0", latest.Version)
+	assert.Equal(t, "1.4.6", latest.Libraries[0].Version)
+	require.Len(t, latest.Boards, 2)
+
+	uno := latest.Boards[0]
+	assert.Equal(t, "arduino:avr", uno.Core)
+	assert.Equal(t, CompatibilityVerified, uno.Status)
+	assert.Equal(t, "1.8.5", uno.MinCoreVersion)
+	assert.Equal(t, "builds", uno.MinCoreSource)
+	assert.Equal(t, 2, uno.SuccessfulBuilds)
+	assert.Len(t, uno.Libraries, 1)
+
+	esp32 := latest.Boards[1]
+	assert.Equal(t, CompatibilityFailing, esp32.Status)
+	assert.Equal(t, "2.0.11", esp32.MinCoreVersion)
+	assert.Equal(t, "declared", esp32.MinCoreSource)
+	assert.Contains(t, esp32.LastBuildError, "ledcSetup")
+
+	// A board that built but is not declared is still listed
+	older := matrix.Versions[1]
+	require.Len(t, older.Boards, 2)
+	assert.Equal(t, "arduino:avr:uno", older.Boards[0].Board)
+	assert.Equal(t, CompatibilityUntested, older.Boards[0].Status)
+	assert.False(t, older.Boards[1].Declared)
+	assert.Equal(t, CompatibilityVerified, older.Boards[1].Status)
+
+	// A later failure turns a verified board into a failing one
+	require.NoError(t, service.RecordBuild(ctx, "weather", &BuildRecord{TemplateVersion: "1.1.0", Board: "arduino:avr:uno", CoreVersion: "1.8.7", Error: "missing header"}))
+	matrix, err = service.GetCompatibilityMatrix(ctx, "weather")
+	require.NoError(t, err)
+	assert.Equal(t, CompatibilityFailing, matrix.Versions[0].Boards[0].Status)
+	assert.Equal(t, "1.8.5", matrix.Versions[0].Boards[0].MinCoreVersion)
+
+	_, err = service.GetCompatibilityMatrix(ctx, "missing")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestService_RecordBuildHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t, createCompatibilityTemplates()...)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	post := func(path, body string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, post("/api/v1/templates/weather/builds", `{"template_version": "1.0.0", "board": "arduino:avr:uno", "core_version": "1.8.6", "success": true}`))
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/templates/weather/builds", `{"template_version": "1.0.0", "board": "arduino:avr:uno", "success": false}`))
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/templates/weather/builds", `{"board": "arduino:avr:uno", "success": true}`))
+	assert.Equal(t, http.StatusNotFound, post("/api/v1/templates/weather/builds", `{"template_version": "9.0.0", "board": "arduino:avr:uno", "success": true}`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/weather/compatibility", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var matrix CompatibilityMatrix
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &matrix))
+	assert.Equal(t, CompatibilityVerified, matrix.Versions[1].Boards[0].Status)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/missing/compatibility", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBuildRecordEntity(t *testing.T) {
+	record := &BuildRecord{
+		TemplateID:      "test-template-1",
+		TemplateVersion: "1.0.0",
+		Board:           "arduino:avr:uno",
+		CoreVersion:     "1.8.6",
+		Libraries:       []LibraryDependency{{Name: "DHT sensor library", Version: "1.4.4"}},
+		Source:          BuildSourceCompile,
+		Parameters:      map[string]interface{}{"sensorPin": float64(2)},
+		Error:           "sketch too big",
+		BuiltAt:         time.Now().UTC().Truncate(time.Second),
+	}
+	entity, err := record.ToEntity()
+	require.NoError(t, err)
+	assert.True(t, entity.Compile)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, record, restored)
+}
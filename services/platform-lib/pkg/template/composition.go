@@ -0,0 +1,468 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrCompositionCycle is returned when templates include each other in a loop
+	ErrCompositionCycle = errors.New("template composition cycle detected")
+
+	// ErrCompositionConflict is returned when a sub-template is included
+	// more than once with different parameter bindings or at different
+	// versions, or when two sub-templates declare the same parameter. A
+	// sub-template's code is merged once and parameters are not namespaced,
+	// so only one binding could take effect.
+	ErrCompositionConflict = errors.New("conflicting template includes")
+)
+
+// Code sections that sub-templates contribute to the composed sketch. Code
+// assets declare their section in metadata ("section": "setup").
+var codeSections = []string{"includes", "globals", "setup", "loop"}
+
+// composedSketch renders a sketch purely from the merged sections when the
+// composing template does not provide its own main.ino
+const composedSketch = `{{template "includes" .}}
+{{template "globals" .}}
+void setup() {
+{{template "setup" .}}
+}
+
+void loop() {
+{{template "loop" .}}
+}
+`
+
+// TemplateLoader loads a template by ID and version ("latest" allowed)
+type TemplateLoader func(ctx context.Context, id, version string) (*Template, error)
+
+// ComposedTemplate is a template flattened together with its sub-templates
+type ComposedTemplate struct {
+	Template *Template `json:"template"`
+	// Order lists "id@version" for every template in the composition,
+	// sub-templates before the templates that include them
+	Order []string `json:"order"`
+	// Sections holds the merged code for each section, in Order
+	Sections map[string]string `json:"sections"`
+	// Components holds each constituent template with its bound parameters,
+	// used to merge wiring diagrams
+	Components []*Template `json:"-"`
+	Warnings   []string    `json:"warnings,omitempty"`
+}
+
+// CompositionResolver resolves template includes into a single template
+type CompositionResolver struct {
+	load TemplateLoader
+}
+
+// NewCompositionResolver creates a resolver that loads sub-templates with load
+func NewCompositionResolver(load TemplateLoader) *CompositionResolver {
+	return &CompositionResolver{load: load}
+}
+
+// compositionState tracks a single resolution walk
+type compositionState struct {
+	composed *ComposedTemplate
+	sections map[string][]string
+	visiting []string
+	resolved map[string]*resolvedInclude // template ID -> first inclusion
+	shared   map[string]bool             // parameters the root declares
+	declared map[string]string           // parameter -> sub-template declaring it
+}
+
+// resolvedInclude is the version and bindings a template was merged with
+type resolvedInclude struct {
+	version string
+	bound   map[string]interface{}
+}
+
+// Resolve flattens a template and its includes. Sub-template parameters,
+// schema properties, libraries, assets and code sections are merged, with the
+// root template taking precedence on conflicts. A sub-template included
+// more than once (e.g. two sensors sharing wifi) is merged only once, and
+// must be included at the same version and bound to the same parameters
+// each time. Parameters share one namespace: two sub-templates may only
+// declare the same parameter (e.g. pin) when the root declares it too,
+// binding both to its value.
+func (cr *CompositionResolver) Resolve(ctx context.Context, root *Template) (*ComposedTemplate, error) {
+	flat := *root
+	flat.Schema = copySchema(root.Schema)
+	flat.Parameters = copyParameters(root.Parameters)
+	flat.Libraries = append([]LibraryDependency(nil), root.Libraries...)
+	flat.Assets = nil
+	flat.Includes = nil
+
+	state := &compositionState{
+		composed: &ComposedTemplate{
+			Template: &flat,
+			Sections: make(map[string]string),
+		},
+		sections: make(map[string][]string),
+		resolved: make(map[string]*resolvedInclude),
+		shared:   make(map[string]bool),
+		declared: make(map[string]string),
+	}
+	for _, name := range declaredParameters(root) {
+		state.shared[name] = true
+	}
+
+	if err := cr.resolve(ctx, state, root, nil); err != nil {
+		return nil, err
+	}
+
+	for _, section := range codeSections {
+		state.composed.Sections[section] = strings.Join(state.sections[section], "\n")
+	}
+
+	return state.composed, nil
+}
+
+func (cr *CompositionResolver) resolve(ctx context.Context, state *compositionState, tmpl *Template, bound map[string]interface{}) error {
+	key := tmpl.ID + "@" + tmpl.Version
+	if previous, ok := state.resolved[tmpl.ID]; ok {
+		if previous.version != tmpl.Version {
+			return fmt.Errorf("%w: %s is included at versions %s and %s", ErrCompositionConflict, tmpl.ID, previous.version, tmpl.Version)
+		}
+		if !sameBindings(previous.bound, bound) {
+			return fmt.Errorf("%w: %s is included with parameters %v and %v", ErrCompositionConflict, key, previous.bound, bound)
+		}
+		return nil
+	}
+
+	state.visiting = append(state.visiting, tmpl.ID)
+	defer func() { state.visiting = state.visiting[:len(state.visiting)-1] }()
+
+	for _, include := range tmpl.Includes {
+		// Check before loading so cycles through an unsaved template are caught
+		if err := state.checkCycle(include.ID); err != nil {
+			return err
+		}
+
+		version := include.Version
+		if version == "" {
+			version = "latest"
+		}
+
+		sub, err := cr.load(ctx, include.ID, version)
+		if err != nil {
+			return fmt.Errorf("failed to load included template %s@%s: %w", include.ID, version, err)
+		}
+		if sub == nil {
+			return fmt.Errorf("included template %s@%s not found", include.ID, version)
+		}
+
+		if err := cr.resolve(ctx, state, sub, include.Parameters); err != nil {
+			return err
+		}
+	}
+
+	isRoot := len(state.visiting) == 1
+	if !isRoot {
+		if err := state.declare(tmpl); err != nil {
+			return err
+		}
+	}
+	state.resolved[tmpl.ID] = &resolvedInclude{version: tmpl.Version, bound: bound}
+	state.composed.Order = append(state.composed.Order, key)
+	cr.merge(state, tmpl, bound, isRoot)
+	return nil
+}
+
+// declare records the parameters a sub-template declares, failing when
+// another sub-template already declared one the root does not
+func (state *compositionState) declare(tmpl *Template) error {
+	names := declaredParameters(tmpl)
+	for _, name := range names {
+		if state.shared[name] {
+			continue
+		}
+		if owner, ok := state.declared[name]; ok && owner != tmpl.ID {
+			return fmt.Errorf("%w: parameter %q is declared by both %s and %s; rename it or declare it in the including template to share it",
+				ErrCompositionConflict, name, owner, tmpl.ID)
+		}
+	}
+	for _, name := range names {
+		if !state.shared[name] {
+			state.declared[name] = tmpl.ID
+		}
+	}
+	return nil
+}
+
+// declaredParameters returns the names of a template's parameters and
+// schema properties, sorted
+func declaredParameters(tmpl *Template) []string {
+	seen := make(map[string]bool)
+	for name := range tmpl.Parameters {
+		seen[name] = true
+	}
+	if props, ok := tmpl.Schema["properties"].(map[string]interface{}); ok {
+		for name := range props {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameBindings reports whether two includes bind the same parameters
+func sameBindings(a, b map[string]interface{}) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// checkCycle fails if id is already being resolved higher up the include chain
+func (state *compositionState) checkCycle(id string) error {
+	for i, visiting := range state.visiting {
+		if visiting == id {
+			path := append(append([]string{}, state.visiting[i:]...), id)
+			return fmt.Errorf("%w: %s", ErrCompositionCycle, strings.Join(path, " -> "))
+		}
+	}
+	return nil
+}
+
+// merge folds a resolved template into the composition. The root template is
+// already the base of the flattened template and only contributes code.
+func (cr *CompositionResolver) merge(state *compositionState, tmpl *Template, bound map[string]interface{}, isRoot bool) {
+	composed := state.composed
+	flat := composed.Template
+
+	for _, asset := range tmpl.Assets {
+		if section, ok := asset.Metadata["section"].(string); ok && asset.Type == "code" {
+			if content, ok := asset.Metadata["content"].(string); ok {
+				state.sections[section] = append(state.sections[section], content)
+			}
+			continue
+		}
+		if isRoot || !(asset.Type == "code" && strings.Contains(asset.Path, "main.ino")) {
+			flat.Assets = append(flat.Assets, asset)
+		}
+	}
+
+	parameters := copyParameters(tmpl.Parameters)
+	for name, value := range bound {
+		parameters[name] = value
+	}
+
+	component := *tmpl
+	component.Parameters = parameters
+	composed.Components = append(composed.Components, &component)
+
+	if isRoot {
+		return
+	}
+
+	for name, value := range parameters {
+		if _, exists := flat.Parameters[name]; !exists {
+			flat.Parameters[name] = value
+		}
+	}
+
+	cr.mergeSchema(composed, tmpl)
+	cr.mergeLibraries(composed, tmpl)
+}
+
+// mergeSchema adds a sub-template's schema properties and required fields.
+// Properties the root declares keep the root's definition.
+func (cr *CompositionResolver) mergeSchema(composed *ComposedTemplate, tmpl *Template) {
+	flat := composed.Template
+	subProps, ok := tmpl.Schema["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if flat.Schema == nil {
+		flat.Schema = map[string]interface{}{"type": "object"}
+	}
+	props, ok := flat.Schema["properties"].(map[string]interface{})
+	if !ok {
+		props = make(map[string]interface{})
+		flat.Schema["properties"] = props
+	}
+
+	names := make([]string, 0, len(subProps))
+	for name := range subProps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if existing, exists := props[name]; exists {
+			if schemaType(existing) != schemaType(subProps[name]) {
+				composed.Warnings = append(composed.Warnings, fmt.Sprintf(
+					"parameter '%s' from template '%s' conflicts with an existing definition and was ignored", name, tmpl.ID))
+			}
+			continue
+		}
+		props[name] = subProps[name]
+	}
+
+	var required []interface{}
+	for _, name := range requiredFields(flat.Schema) {
+		required = append(required, name)
+	}
+	for _, name := range requiredFields(tmpl.Schema) {
+		if !containsValue(required, name) {
+			required = append(required, name)
+		}
+	}
+	if len(required) > 0 {
+		flat.Schema["required"] = required
+	}
+}
+
+// mergeLibraries adds a sub-template's libraries, keeping the first version
+// seen for a library and warning on mismatches
+func (cr *CompositionResolver) mergeLibraries(composed *ComposedTemplate, tmpl *Template) {
+	flat := composed.Template
+	for _, lib := range tmpl.Libraries {
+		found := false
+		for _, existing := range flat.Libraries {
+			if strings.EqualFold(existing.Name, lib.Name) {
+				found = true
+				if existing.Version != lib.Version && lib.Version != "" {
+					composed.Warnings = append(composed.Warnings, fmt.Sprintf(
+						"library '%s' version %s from template '%s' conflicts with version %s",
+						lib.Name, lib.Version, tmpl.ID, existing.Version))
+				}
+				break
+			}
+		}
+		if !found {
+			flat.Libraries = append(flat.Libraries, lib)
+		}
+	}
+}
+
+// CodeTemplate returns the code to render for the composition along with the
+// named section templates it may reference
+func (ct *ComposedTemplate) CodeTemplate() (string, map[string]string) {
+	for _, asset := range ct.Template.Assets {
+		if asset.Type == "code" && strings.Contains(asset.Path, "main.ino") {
+			if content, ok := asset.Metadata["content"].(string); ok {
+				return content, ct.Sections
+			}
+		}
+	}
+
+	for _, section := range codeSections {
+		if ct.Sections[section] != "" {
+			return composedSketch, ct.Sections
+		}
+	}
+
+	return "", ct.Sections
+}
+
+// MergeWiringDiagrams combines the diagrams of the composed templates,
+// de-duplicating shared components such as the board
+func (wdg *WiringDiagramGenerator) MergeWiringDiagrams(root *Template, diagrams []*WiringDiagram) *WiringDiagram {
+	var components []Component
+	var connections []Connection
+	seenComponents := make(map[string]bool)
+	seenConnections := make(map[Connection]bool)
+
+	for _, diagram := range diagrams {
+		for _, component := range diagram.Components {
+			if !seenComponents[component.ID] {
+				seenComponents[component.ID] = true
+				components = append(components, component)
+			}
+		}
+		for _, connection := range diagram.Connections {
+			if !seenConnections[connection] {
+				seenConnections[connection] = true
+				connections = append(connections, connection)
+			}
+		}
+	}
+
+	return &WiringDiagram{
+		MermaidSyntax: wdg.generateMermaidSyntax(components, connections),
+		Components:    components,
+		Connections:   connections,
+		Metadata: map[string]interface{}{
+			"template_id":      root.ID,
+			"template_version": root.Version,
+			"generated_from":   "composition",
+		},
+	}
+}
+
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		copied[key] = value
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		copiedProps := make(map[string]interface{}, len(props))
+		for name, prop := range props {
+			copiedProps[name] = prop
+		}
+		copied["properties"] = copiedProps
+	}
+	if _, ok := schema["required"]; ok {
+		var required []interface{}
+		for _, name := range requiredFields(schema) {
+			required = append(required, name)
+		}
+		copied["required"] = required
+	}
+	return copied
+}
+
+func copyParameters(parameters map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		copied[name] = value
+	}
+	return copied
+}
+
+func schemaType(prop interface{}) string {
+	if propMap, ok := prop.(map[string]interface{}); ok {
+		if propType, ok := propMap["type"].(string); ok {
+			return propType
+		}
+	}
+	return ""
+}
+
+func requiredFields(schema map[string]interface{}) []string {
+	var fields []string
+	switch required := schema["required"].(type) {
+	case []interface{}:
+		for _, field := range required {
+			if name, ok := field.(string); ok {
+				fields = append(fields, name)
+			}
+		}
+	case []string:
+		fields = append(fields, required...)
+	}
+	return fields
+}
+
+func containsValue(values []interface{}, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupCompositionService creates a service backed by an in-memory repository
+// seeded with the given templates
+func setupCompositionService(t *testing.T, templates ...*Template) *Service {
+	repo := NewMemoryRepository()
+	for _, tmpl := range templates {
+		require.NoError(t, repo.CreateTemplate(context.Background(), tmpl))
+	}
+
+	service, err := NewService(&config.Config{ServiceName: "test-template-service"}, logger.New("debug", "test"), repo)
+	require.NoError(t, err)
+	return service
+}
+
+func codeSection(section, content string) Asset {
+	return Asset{
+		Type:     "code",
+		Path:     "sections/" + section + ".ino",
+		Metadata: map[string]interface{}{"section": section, "content": content},
+	}
+}
+
+func createDHT22Template() *Template {
+	return &Template{
+		ID:              "dht22-sensor",
+		Name:            "DHT22 Sensor",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"dht_pin": map[string]interface{}{"type": "integer"},
+			},
+			"required": []interface{}{"dht_pin"},
+		},
+		Parameters: map[string]interface{}{"dht_pin": 2},
+		Libraries:  []LibraryDependency{{Name: "DHT sensor library", Version: "1.4.4"}},
+		Assets: []Asset{
+			codeSection("includes", "#include <DHT.h>"),
+			codeSection("globals", "DHT dht({{.dht_pin}}, DHT22);"),
+			codeSection("setup", "  dht.begin();"),
+			codeSection("loop", "  float t = dht.readTemperature();"),
+		},
+	}
+}
+
+func createWifiMQTTTemplate() *Template {
+	return &Template{
+		ID:              "wifi-mqtt",
+		Name:            "WiFi MQTT",
+		Version:         "1.0.0",
+		Category:        "communication",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"mqtt_broker": map[string]interface{}{"type": "string"},
+			},
+		},
+		Parameters: map[string]interface{}{"mqtt_broker": "broker.local"},
+		Libraries:  []LibraryDependency{{Name: "PubSubClient", Version: "2.8"}},
+		Assets: []Asset{
+			codeSection("includes", "#include <PubSubClient.h>"),
+			codeSection("setup", "  client.setServer(\"{{.mqtt_broker}}\", 1883);"),
+			codeSection("loop", "  client.loop();"),
+		},
+	}
+}
+
+func createWeatherStationTemplate() *Template {
+	return &Template{
+		ID:              "weather-station",
+		Name:            "Weather Station",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Parameters:      map[string]interface{}{"delay_ms": 5000},
+		Libraries:       []LibraryDependency{{Name: "PubSubClient", Version: "2.7"}},
+		Includes: []TemplateInclude{
+			{ID: "dht22-sensor", Version: "1.0.0", Parameters: map[string]interface{}{"dht_pin": 4}},
+			{ID: "wifi-mqtt"},
+		},
+	}
+}
+
+func TestCompositionResolver_MergesSubTemplates(t *testing.T) {
+	service := setupCompositionService(t, createDHT22Template(), createWifiMQTTTemplate())
+
+	composed, err := service.ResolveComposition(context.Background(), createWeatherStationTemplate())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dht22-sensor@1.0.0", "wifi-mqtt@1.0.0", "weather-station@1.0.0"}, composed.Order)
+
+	flat := composed.Template
+	assert.Equal(t, 4, flat.Parameters["dht_pin"], "bound include parameters override sub-template defaults")
+	assert.Equal(t, "broker.local", flat.Parameters["mqtt_broker"])
+	assert.Equal(t, 5000, flat.Parameters["delay_ms"])
+	assert.Empty(t, flat.Includes)
+
+	props := flat.Schema["properties"].(map[string]interface{})
+	assert.Contains(t, props, "dht_pin")
+	assert.Contains(t, props, "mqtt_broker")
+	assert.Equal(t, []interface{}{"dht_pin"}, flat.Schema["required"])
+
+	require.Len(t, flat.Libraries, 2)
+	assert.Equal(t, "2.7", flat.Libraries[0].Version, "including template's library version wins")
+	assert.Equal(t, "DHT sensor library", flat.Libraries[1].Name)
+	assert.Len(t, composed.Warnings, 1)
+
+	assert.Equal(t, "#include <DHT.h>\n#include <PubSubClient.h>", composed.Sections["includes"])
+	assert.Equal(t, "  float t = dht.readTemperature();\n  client.loop();", composed.Sections["loop"])
+	assert.Len(t, composed.Components, 3)
+}
+
+func TestCompositionResolver_DetectsCycles(t *testing.T) {
+	a := &Template{ID: "a", Version: "1.0.0", Includes: []TemplateInclude{{ID: "b", Version: "1.0.0"}}}
+	b := &Template{ID: "b", Version: "1.0.0", Includes: []TemplateInclude{{ID: "c", Version: "1.0.0"}}}
+	c := &Template{ID: "c", Version: "1.0.0", Includes: []TemplateInclude{{ID: "a", Version: "1.0.0"}}}
+	service := setupCompositionService(t, a, b, c)
+
+	_, err := service.ResolveComposition(context.Background(), a)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrCompositionCycle))
+	assert.Contains(t, err.Error(), "a -> b -> c -> a")
+
+	self := &Template{ID: "self", Version: "1.0.0", Includes: []TemplateInclude{{ID: "self", Version: "1.0.0"}}}
+	_, err = service.ResolveComposition(context.Background(), self)
+	assert.True(t, errors.Is(err, ErrCompositionCycle))
+}
+
+func TestCompositionResolver_SharedIncludeMergedOnce(t *testing.T) {
+	sensorA := &Template{ID: "sensor-a", Version: "1.0.0", Includes: []TemplateInclude{{ID: "wifi-mqtt", Version: "1.0.0"}}}
+	sensorB := &Template{ID: "sensor-b", Version: "1.0.0", Includes: []TemplateInclude{{ID: "wifi-mqtt", Version: "1.0.0"}}}
+	service := setupCompositionService(t, createWifiMQTTTemplate(), sensorA, sensorB)
+
+	root := &Template{ID: "root", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "sensor-a", Version: "1.0.0"},
+		{ID: "sensor-b", Version: "1.0.0"},
+	}}
+
+	composed, err := service.ResolveComposition(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wifi-mqtt@1.0.0", "sensor-a@1.0.0", "sensor-b@1.0.0", "root@1.0.0"}, composed.Order)
+	assert.Equal(t, "  client.loop();", composed.Sections["loop"])
+}
+
+func TestCompositionResolver_ConflictingIncludes(t *testing.T) {
+	sensorA := &Template{ID: "sensor-a", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "dht22-sensor", Version: "1.0.0", Parameters: map[string]interface{}{"dht_pin": 4}},
+	}}
+	sensorB := &Template{ID: "sensor-b", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "dht22-sensor", Version: "1.0.0", Parameters: map[string]interface{}{"dht_pin": 5}},
+	}}
+	service := setupCompositionService(t, createDHT22Template(), sensorA, sensorB)
+
+	root := &Template{ID: "root", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "sensor-a", Version: "1.0.0"},
+		{ID: "sensor-b", Version: "1.0.0"},
+	}}
+	_, err := service.ResolveComposition(context.Background(), root)
+	assert.ErrorIs(t, err, ErrCompositionConflict)
+	assert.Contains(t, err.Error(), "dht22-sensor@1.0.0")
+
+	// The same bindings twice are merged once
+	sensorB.Includes[0].Parameters["dht_pin"] = 4
+	composed, err := service.ResolveComposition(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dht22-sensor@1.0.0", "sensor-a@1.0.0", "sensor-b@1.0.0", "root@1.0.0"}, composed.Order)
+	assert.Equal(t, 4, composed.Template.Parameters["dht_pin"])
+}
+
+func TestCompositionResolver_ConflictingParameters(t *testing.T) {
+	relay := &Template{
+		ID:         "relay",
+		Version:    "1.0.0",
+		Schema:     map[string]interface{}{"properties": map[string]interface{}{"pin": map[string]interface{}{"type": "integer"}}},
+		Parameters: map[string]interface{}{"pin": 5},
+	}
+	led := &Template{
+		ID:         "led",
+		Version:    "1.0.0",
+		Schema:     map[string]interface{}{"properties": map[string]interface{}{"pin": map[string]interface{}{"type": "integer"}}},
+		Parameters: map[string]interface{}{"pin": 2},
+	}
+	service := setupCompositionService(t, relay, led)
+
+	root := &Template{ID: "root", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "relay", Version: "1.0.0", Parameters: map[string]interface{}{"pin": 26}},
+		{ID: "led", Version: "1.0.0", Parameters: map[string]interface{}{"pin": 13}},
+	}}
+	_, err := service.ResolveComposition(context.Background(), root)
+	assert.ErrorIs(t, err, ErrCompositionConflict)
+	assert.Contains(t, err.Error(), `parameter "pin" is declared by both relay and led`)
+
+	// Declared by the root, the parameter is shared on purpose
+	root.Parameters = map[string]interface{}{"pin": 26}
+	composed, err := service.ResolveComposition(context.Background(), root)
+	require.NoError(t, err)
+	assert.Equal(t, 26, composed.Template.Parameters["pin"])
+}
+
+func TestCompositionResolver_IncludeAtTwoVersions(t *testing.T) {
+	newer := createDHT22Template()
+	newer.Version = "1.1.0"
+	sensorA := &Template{ID: "sensor-a", Version: "1.0.0", Includes: []TemplateInclude{{ID: "dht22-sensor", Version: "1.0.0"}}}
+	sensorB := &Template{ID: "sensor-b", Version: "1.0.0", Includes: []TemplateInclude{{ID: "dht22-sensor", Version: "1.1.0"}}}
+	service := setupCompositionService(t, createDHT22Template(), newer, sensorA, sensorB)
+
+	root := &Template{ID: "root", Version: "1.0.0", Includes: []TemplateInclude{
+		{ID: "sensor-a", Version: "1.0.0"},
+		{ID: "sensor-b", Version: "1.0.0"},
+	}}
+	_, err := service.ResolveComposition(context.Background(), root)
+	assert.ErrorIs(t, err, ErrCompositionConflict)
+	assert.Contains(t, err.Error(), "dht22-sensor is included at versions 1.0.0 and 1.1.0")
+}
+
+func TestCompositionResolver_MissingInclude(t *testing.T) {
+	service := setupCompositionService(t)
+
+	_, err := service.ResolveComposition(context.Background(), createWeatherStationTemplate())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dht22-sensor")
+}
+
+func TestService_RenderComposedTemplate(t *testing.T) {
+	service := setupCompositionService(t, createDHT22Template(), createWifiMQTTTemplate(), createWeatherStationTemplate())
+
+	rendered, err := service.RenderTemplate(context.Background(), "weather-station", "1.0.0", map[string]interface{}{
+		"mqtt_broker": "mqtt.example.com",
+	})
+	require.NoError(t, err)
+
+	code := rendered.RenderedCode
+	assert.Contains(t, code, "#include <DHT.h>")
+	assert.Contains(t, code, "DHT dht(4, DHT22);")
+	assert.Contains(t, code, "client.setServer(\"mqtt.example.com\", 1883);")
+	assert.Contains(t, code, "void loop() {\n  float t = dht.readTemperature();\n  client.loop();")
+	assert.Len(t, rendered.Template.Libraries, 2)
+}
+
+func TestService_CreateTemplate_RejectsCompositionCycle(t *testing.T) {
+	looping := createWifiMQTTTemplate()
+	looping.Includes = []TemplateInclude{{ID: "weather-station"}}
+	service := setupCompositionService(t, createDHT22Template(), looping)
+
+	err := service.CreateTemplate(context.Background(), createWeatherStationTemplate())
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrCompositionCycle))
+}
+
+func TestService_GenerateComposedWiringDiagram(t *testing.T) {
+	service := setupCompositionService(t, createDHT22Template(), createWifiMQTTTemplate())
+
+	diagram, err := service.GenerateWiringDiagram(context.Background(), createWeatherStationTemplate(), nil)
+	require.NoError(t, err)
+
+	boards := 0
+	for _, component := range diagram.Components {
+		if component.Type == "microcontroller" {
+			boards++
+		}
+	}
+	assert.Equal(t, 1, boards, "shared board component is merged")
+	assert.Equal(t, "composition", diagram.Metadata["generated_from"])
+	assert.NotEmpty(t, diagram.MermaidSyntax)
+}
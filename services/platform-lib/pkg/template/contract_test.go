@@ -0,0 +1,54 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/contract"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractStates seed the templates a consumer's interaction requires
+var contractStates = map[string]func() []*Template{
+	"template temp-sensor exists": func() []*Template {
+		return []*Template{{
+			ID:              "temp-sensor",
+			Name:            "Temperature Sensor",
+			Version:         "1.0.0",
+			Category:        "sensing",
+			Description:     "Reads a DS18B20 and publishes readings",
+			BoardsSupported: []string{"esp32:esp32:esp32"},
+			Parameters:      map[string]interface{}{"interval_ms": 5000},
+			Assets: []Asset{{
+				Type:     "code",
+				Path:     "main.ino",
+				Metadata: map[string]interface{}{"content": "void loop() { delay({{.interval_ms}}); }"},
+			}},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}}
+	},
+}
+
+func TestProviderContracts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	contracts, err := contract.LoadDir("../../contracts", "template-service")
+	require.NoError(t, err)
+	require.NotEmpty(t, contracts)
+
+	for _, c := range contracts {
+		for _, interaction := range c.Interactions {
+			t.Run(c.Consumer+"/"+interaction.Description, func(t *testing.T) {
+				templates, ok := contractStates[interaction.State]
+				require.True(t, ok, "unknown provider state %q", interaction.State)
+
+				router := gin.New()
+				RegisterRoutes(router, setupCompositionService(t, templates()...))
+
+				assert.NoError(t, interaction.Verify(router))
+			})
+		}
+	}
+}
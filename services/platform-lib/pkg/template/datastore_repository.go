@@ -202,6 +202,9 @@ func (r *DatastoreRepository) ListTemplates(ctx context.Context, filters *Templa
 			// For simplicity, we'll filter the first supported board
 			query = query.Filter("boards_supported =", filters.SupportedBoards[0])
 		}
+		if filters.Environment != "" {
+			query = query.Filter("environments =", filters.Environment)
+		}
 		if filters.Limit > 0 {
 			query = query.Limit(filters.Limit)
 		}
@@ -419,6 +422,9 @@ func (r *DatastoreRepository) GetTemplateCount(ctx context.Context, filters *Tem
 		if len(filters.SupportedBoards) > 0 {
 			query = query.Filter("boards_supported =", filters.SupportedBoards[0])
 		}
+		if filters.Environment != "" {
+			query = query.Filter("environments =", filters.Environment)
+		}
 	}
 
 	// Count only
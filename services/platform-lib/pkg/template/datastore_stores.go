@@ -0,0 +1,393 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// PublisherEntity represents a registered publisher in Datastore, keyed by
+// publisher ID
+type PublisherEntity struct {
+	Name           string    `datastore:"name"`
+	PublicKey      string    `datastore:"public_key,noindex"`
+	KeyFingerprint string    `datastore:"key_fingerprint"`
+	CreatedAt      time.Time `datastore:"created_at"`
+}
+
+// ToEntity converts a Publisher to a PublisherEntity
+func (p *Publisher) ToEntity() *PublisherEntity {
+	return &PublisherEntity{
+		Name:           p.Name,
+		PublicKey:      p.PublicKey,
+		KeyFingerprint: p.KeyFingerprint,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+// FromEntity converts a PublisherEntity to a Publisher
+func (e *PublisherEntity) FromEntity(id string) *Publisher {
+	return &Publisher{
+		ID:             id,
+		Name:           e.Name,
+		PublicKey:      e.PublicKey,
+		KeyFingerprint: e.KeyFingerprint,
+		CreatedAt:      e.CreatedAt,
+	}
+}
+
+// DatastorePublisherStore implements PublisherStore using Google Cloud Datastore
+type DatastorePublisherStore struct {
+	client *datastore.Client
+}
+
+// NewDatastorePublisherStore creates a new Datastore publisher store
+func NewDatastorePublisherStore(client *datastore.Client) *DatastorePublisherStore {
+	return &DatastorePublisherStore{client: client}
+}
+
+// CreatePublisher stores a publisher, failing if its ID is already registered
+func (s *DatastorePublisherStore) CreatePublisher(ctx context.Context, publisher *Publisher) error {
+	key := datastore.NameKey("TemplatePublisher", publisher.ID, nil)
+	_, err := s.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var existing PublisherEntity
+		switch err := tx.Get(key, &existing); err {
+		case nil:
+			return ErrPublisherExists
+		case datastore.ErrNoSuchEntity:
+		default:
+			return fmt.Errorf("failed to retrieve publisher from Datastore: %w", err)
+		}
+		_, err := tx.Put(key, publisher.ToEntity())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store publisher in Datastore: %w", err)
+	}
+	return nil
+}
+
+// GetPublisher retrieves a publisher by ID from Datastore
+func (s *DatastorePublisherStore) GetPublisher(ctx context.Context, id string) (*Publisher, error) {
+	key := datastore.NameKey("TemplatePublisher", id, nil)
+	var entity PublisherEntity
+	if err := s.client.Get(ctx, key, &entity); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, ErrPublisherNotFound
+		}
+		return nil, fmt.Errorf("failed to get publisher from Datastore: %w", err)
+	}
+	return entity.FromEntity(id), nil
+}
+
+// ListPublishers returns publishers ordered by ID
+func (s *DatastorePublisherStore) ListPublishers(ctx context.Context) ([]*Publisher, error) {
+	query := datastore.NewQuery("TemplatePublisher").Order("__key__")
+	var entities []PublisherEntity
+	keys, err := s.client.GetAll(ctx, query, &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query publishers from Datastore: %w", err)
+	}
+
+	publishers := make([]*Publisher, len(entities))
+	for i := range entities {
+		publishers[i] = entities[i].FromEntity(keys[i].Name)
+	}
+	return publishers, nil
+}
+
+// DriverEntity represents a driver snippet in Datastore, keyed by snippet
+// ID. Deleted marks a built-in snippet removed from the library.
+type DriverEntity struct {
+	Component     string    `datastore:"component"`
+	Kind          string    `datastore:"kind"`
+	Description   string    `datastore:"description,noindex"`
+	Parameter     string    `datastore:"parameter,noindex"`
+	Unless        string    `datastore:"unless,noindex"`
+	LibrariesJSON string    `datastore:"libraries_json,noindex"`
+	Includes      string    `datastore:"includes,noindex"`
+	Globals       string    `datastore:"globals,noindex"`
+	Init          string    `datastore:"init,noindex"`
+	Read          string    `datastore:"read,noindex"`
+	Functions     string    `datastore:"functions,noindex"`
+	Deleted       bool      `datastore:"deleted,noindex"`
+	UpdatedAt     time.Time `datastore:"updated_at"`
+}
+
+// ToEntity converts a DriverSnippet to a DriverEntity
+func (d *DriverSnippet) ToEntity() (*DriverEntity, error) {
+	librariesJSON, err := json.Marshal(d.Libraries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal libraries: %w", err)
+	}
+	return &DriverEntity{
+		Component:     d.Component,
+		Kind:          d.Kind,
+		Description:   d.Description,
+		Parameter:     d.Parameter,
+		Unless:        d.Unless,
+		LibrariesJSON: string(librariesJSON),
+		Includes:      d.Includes,
+		Globals:       d.Globals,
+		Init:          d.Init,
+		Read:          d.Read,
+		Functions:     d.Functions,
+		UpdatedAt:     d.UpdatedAt,
+	}, nil
+}
+
+// FromEntity converts a DriverEntity to a DriverSnippet
+func (e *DriverEntity) FromEntity(id string) (*DriverSnippet, error) {
+	driver := &DriverSnippet{
+		ID:          id,
+		Component:   e.Component,
+		Kind:        e.Kind,
+		Description: e.Description,
+		Parameter:   e.Parameter,
+		Unless:      e.Unless,
+		Includes:    e.Includes,
+		Globals:     e.Globals,
+		Init:        e.Init,
+		Read:        e.Read,
+		Functions:   e.Functions,
+		UpdatedAt:   e.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(e.LibrariesJSON), &driver.Libraries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal libraries: %w", err)
+	}
+	return driver, nil
+}
+
+// DatastoreDriverStore implements DriverStore using Google Cloud Datastore.
+// The built-in snippets are part of the library without being stored;
+// saving one stores the replacement and deleting one stores a tombstone, so
+// snippets added to later releases appear without migrating the store.
+type DatastoreDriverStore struct {
+	client   *datastore.Client
+	builtins map[string]*DriverSnippet
+}
+
+// NewDatastoreDriverStore creates a new Datastore driver store
+func NewDatastoreDriverStore(client *datastore.Client) *DatastoreDriverStore {
+	store := &DatastoreDriverStore{
+		client:   client,
+		builtins: make(map[string]*DriverSnippet),
+	}
+	for _, driver := range BuiltinDrivers() {
+		store.builtins[driver.ID] = driver
+	}
+	return store
+}
+
+// ListDrivers returns snippets ordered by ID
+func (s *DatastoreDriverStore) ListDrivers(ctx context.Context) ([]*DriverSnippet, error) {
+	var entities []DriverEntity
+	keys, err := s.client.GetAll(ctx, datastore.NewQuery("TemplateDriver"), &entities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query driver snippets from Datastore: %w", err)
+	}
+
+	library := make(map[string]*DriverSnippet, len(s.builtins)+len(entities))
+	for id, driver := range s.builtins {
+		copied := *driver
+		library[id] = &copied
+	}
+	for i, entity := range entities {
+		id := keys[i].Name
+		if entity.Deleted {
+			delete(library, id)
+			continue
+		}
+		driver, err := entity.FromEntity(id)
+		if err != nil {
+			return nil, err
+		}
+		library[id] = driver
+	}
+
+	drivers := make([]*DriverSnippet, 0, len(library))
+	for _, driver := range library {
+		drivers = append(drivers, driver)
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].ID < drivers[j].ID })
+	return drivers, nil
+}
+
+// GetDriver retrieves a snippet by ID
+func (s *DatastoreDriverStore) GetDriver(ctx context.Context, id string) (*DriverSnippet, error) {
+	var entity DriverEntity
+	switch err := s.client.Get(ctx, datastore.NameKey("TemplateDriver", id, nil), &entity); err {
+	case nil:
+		if entity.Deleted {
+			return nil, fmt.Errorf("%w: %s", ErrDriverNotFound, id)
+		}
+		return entity.FromEntity(id)
+	case datastore.ErrNoSuchEntity:
+		if builtin, ok := s.builtins[id]; ok {
+			copied := *builtin
+			return &copied, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotFound, id)
+	default:
+		return nil, fmt.Errorf("failed to get driver snippet from Datastore: %w", err)
+	}
+}
+
+// SaveDriver creates a snippet or replaces the one with the same ID
+func (s *DatastoreDriverStore) SaveDriver(ctx context.Context, driver *DriverSnippet) error {
+	entity, err := driver.ToEntity()
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Put(ctx, datastore.NameKey("TemplateDriver", driver.ID, nil), entity); err != nil {
+		return fmt.Errorf("failed to store driver snippet in Datastore: %w", err)
+	}
+	return nil
+}
+
+// DeleteDriver removes a snippet from the library
+func (s *DatastoreDriverStore) DeleteDriver(ctx context.Context, id string) error {
+	if _, err := s.GetDriver(ctx, id); err != nil {
+		return err
+	}
+	key := datastore.NameKey("TemplateDriver", id, nil)
+	var err error
+	if _, builtin := s.builtins[id]; builtin {
+		_, err = s.client.Put(ctx, key, &DriverEntity{Deleted: true, UpdatedAt: time.Now()})
+	} else {
+		err = s.client.Delete(ctx, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete driver snippet from Datastore: %w", err)
+	}
+	return nil
+}
+
+// BuildRecordEntity represents a template build in Datastore
+type BuildRecordEntity struct {
+	TemplateID      string    `datastore:"template_id"`
+	TemplateVersion string    `datastore:"template_version"`
+	Board           string    `datastore:"board"`
+	CoreVersion     string    `datastore:"core_version,noindex"`
+	LibrariesJSON   string    `datastore:"libraries_json,noindex"`
+	Source          string    `datastore:"source,noindex"`
+	Compile         bool      `datastore:"compile"` // user compiles are trimmed separately
+	ParametersJSON  string    `datastore:"parameters_json,noindex"`
+	Success         bool      `datastore:"success,noindex"`
+	Error           string    `datastore:"error,noindex"`
+	BuiltAt         time.Time `datastore:"built_at,noindex"`
+	RecordedAt      time.Time `datastore:"recorded_at"`
+}
+
+// ToEntity converts a BuildRecord to a BuildRecordEntity
+func (r *BuildRecord) ToEntity() (*BuildRecordEntity, error) {
+	librariesJSON, err := json.Marshal(r.Libraries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal libraries: %w", err)
+	}
+	parametersJSON, err := json.Marshal(r.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal parameters: %w", err)
+	}
+	return &BuildRecordEntity{
+		TemplateID:      r.TemplateID,
+		TemplateVersion: r.TemplateVersion,
+		Board:           r.Board,
+		CoreVersion:     r.CoreVersion,
+		LibrariesJSON:   string(librariesJSON),
+		Source:          r.Source,
+		Compile:         r.Source == BuildSourceCompile,
+		ParametersJSON:  string(parametersJSON),
+		Success:         r.Success,
+		Error:           r.Error,
+		BuiltAt:         r.BuiltAt,
+	}, nil
+}
+
+// FromEntity converts a BuildRecordEntity to a BuildRecord
+func (e *BuildRecordEntity) FromEntity() (*BuildRecord, error) {
+	record := &BuildRecord{
+		TemplateID:      e.TemplateID,
+		TemplateVersion: e.TemplateVersion,
+		Board:           e.Board,
+		CoreVersion:     e.CoreVersion,
+		Source:          e.Source,
+		Success:         e.Success,
+		Error:           e.Error,
+		BuiltAt:         e.BuiltAt,
+	}
+	if err := json.Unmarshal([]byte(e.LibrariesJSON), &record.Libraries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal libraries: %w", err)
+	}
+	if err := json.Unmarshal([]byte(e.ParametersJSON), &record.Parameters); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
+	}
+	return record, nil
+}
+
+// DatastoreBuildRecordStore implements BuildRecordStore using Google Cloud
+// Datastore
+type DatastoreBuildRecordStore struct {
+	client *datastore.Client
+}
+
+// NewDatastoreBuildRecordStore creates a new Datastore build record store
+func NewDatastoreBuildRecordStore(client *datastore.Client) *DatastoreBuildRecordStore {
+	return &DatastoreBuildRecordStore{client: client}
+}
+
+// AddBuildRecord stores a build, then drops the oldest of its kind beyond
+// maxBuildRecords, so user compiles do not push out CI and regression
+// builds
+func (s *DatastoreBuildRecordStore) AddBuildRecord(ctx context.Context, record *BuildRecord) error {
+	entity, err := record.ToEntity()
+	if err != nil {
+		return err
+	}
+	entity.RecordedAt = time.Now()
+	if _, err := s.client.Put(ctx, datastore.IncompleteKey("TemplateBuild", nil), entity); err != nil {
+		return fmt.Errorf("failed to store build record in Datastore: %w", err)
+	}
+
+	query := datastore.NewQuery("TemplateBuild").
+		Filter("template_id =", record.TemplateID).
+		Filter("compile =", entity.Compile).
+		Order("-recorded_at").
+		Offset(maxBuildRecords).
+		KeysOnly()
+	keys, err := s.client.GetAll(ctx, query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to query old build records from Datastore: %w", err)
+	}
+	if len(keys) > 0 {
+		if err := s.client.DeleteMulti(ctx, keys); err != nil {
+			return fmt.Errorf("failed to delete old build records from Datastore: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListBuildRecords returns the builds of a template in the order recorded
+func (s *DatastoreBuildRecordStore) ListBuildRecords(ctx context.Context, templateID string) ([]*BuildRecord, error) {
+	query := datastore.NewQuery("TemplateBuild").
+		Filter("template_id =", templateID).
+		Order("recorded_at")
+	var entities []BuildRecordEntity
+	if _, err := s.client.GetAll(ctx, query, &entities); err != nil {
+		return nil, fmt.Errorf("failed to query build records from Datastore: %w", err)
+	}
+
+	records := make([]*BuildRecord, len(entities))
+	for i := range entities {
+		record, err := entities[i].FromEntity()
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
+	}
+	return records, nil
+}
@@ -0,0 +1,584 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrDriverNotFound is returned for driver snippets not in the library
+	ErrDriverNotFound = errors.New("driver snippet not found")
+
+	// ErrInvalidDriver is returned for driver snippets that cannot be
+	// composed into sketches
+	ErrInvalidDriver = errors.New("invalid driver snippet")
+)
+
+// Kinds of parts driver snippets drive
+const (
+	DriverKindSensor        = "sensor"
+	DriverKindActuator      = "actuator"
+	DriverKindDisplay       = "display"
+	DriverKindCommunication = "communication"
+)
+
+var driverKinds = map[string]bool{
+	DriverKindSensor:        true,
+	DriverKindActuator:      true,
+	DriverKindDisplay:       true,
+	DriverKindCommunication: true,
+}
+
+var (
+	driverIDPattern        = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+	driverParameterPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// DriverSnippet is the code a part contributes to generated sketches. Each
+// section is template code rendered with the sketch's parameters. A snippet
+// with Parameter set is only generated when that parameter is set, for
+// example to the pin the part is wired to, and one with Unless set only
+// when that parameter is not.
+type DriverSnippet struct {
+	ID          string              `json:"id"`
+	Component   string              `json:"component"` // part model, e.g. DHT22
+	Kind        string              `json:"kind"`
+	Description string              `json:"description,omitempty"`
+	Parameter   string              `json:"parameter,omitempty"`
+	Unless      string              `json:"unless,omitempty"`
+	Libraries   []LibraryDependency `json:"libraries,omitempty"`
+	Includes    string              `json:"includes,omitempty"`  // #include lines
+	Globals     string              `json:"globals,omitempty"`   // constants and driver objects
+	Init        string              `json:"init,omitempty"`      // setup() code
+	Read        string              `json:"read,omitempty"`      // loop() code reading or driving the part
+	Functions   string              `json:"functions,omitempty"` // helpers defined after loop()
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// sections returns the snippet's code by sketch section
+func (d *DriverSnippet) sections() map[string]string {
+	return map[string]string{
+		"includes":  d.Includes,
+		"globals":   d.Globals,
+		"init":      d.Init,
+		"read":      d.Read,
+		"functions": d.Functions,
+	}
+}
+
+// active reports whether the snippet is generated for parameters
+func (d *DriverSnippet) active(parameters map[string]interface{}) bool {
+	if d.Parameter != "" && !isSet(parameters[d.Parameter]) {
+		return false
+	}
+	return d.Unless == "" || !isSet(parameters[d.Unless])
+}
+
+// guard wraps code so it is only rendered when the snippet is active
+func (d *DriverSnippet) guard(code string) string {
+	if d.Unless != "" {
+		code = "{{if ." + d.Unless + "}}{{else}}" + code + "{{end}}"
+	}
+	if d.Parameter != "" {
+		code = "{{if ." + d.Parameter + "}}" + code + "{{end}}"
+	}
+	return code
+}
+
+// isSet reports whether a parameter value counts as set in template
+// conditions
+func isSet(value interface{}) bool {
+	truth, _ := template.IsTrue(value)
+	return truth
+}
+
+// validateDriver checks a snippet's fields and that each of its sections
+// parses with funcs
+func validateDriver(driver *DriverSnippet, funcs template.FuncMap) error {
+	if !driverIDPattern.MatchString(driver.ID) {
+		return fmt.Errorf("%w: ID %q must be lowercase letters, digits and dashes", ErrInvalidDriver, driver.ID)
+	}
+	if strings.TrimSpace(driver.Component) == "" {
+		return fmt.Errorf("%w: component is required", ErrInvalidDriver)
+	}
+	if !driverKinds[driver.Kind] {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidDriver, driver.Kind)
+	}
+	for _, name := range []string{driver.Parameter, driver.Unless} {
+		if name != "" && !driverParameterPattern.MatchString(name) {
+			return fmt.Errorf("%w: %q is not a parameter name", ErrInvalidDriver, name)
+		}
+	}
+	if strings.TrimSpace(driver.Init+driver.Read) == "" {
+		return fmt.Errorf("%w: init or read code is required", ErrInvalidDriver)
+	}
+	for section, code := range driver.sections() {
+		if _, err := template.New(section).Funcs(funcs).Parse(code); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidDriver, section, err)
+		}
+	}
+	return nil
+}
+
+// DriverStore persists the driver snippet library
+type DriverStore interface {
+	ListDrivers(ctx context.Context) ([]*DriverSnippet, error)
+	GetDriver(ctx context.Context, id string) (*DriverSnippet, error)
+	// SaveDriver creates a snippet or replaces the one with the same ID
+	SaveDriver(ctx context.Context, driver *DriverSnippet) error
+	DeleteDriver(ctx context.Context, id string) error
+}
+
+// MemoryDriverStore keeps driver snippets in memory
+type MemoryDriverStore struct {
+	mu      sync.RWMutex
+	drivers map[string]*DriverSnippet
+}
+
+// NewMemoryDriverStore creates a store holding the built-in driver snippets
+func NewMemoryDriverStore() *MemoryDriverStore {
+	store := &MemoryDriverStore{drivers: make(map[string]*DriverSnippet)}
+	for _, driver := range BuiltinDrivers() {
+		store.drivers[driver.ID] = driver
+	}
+	return store
+}
+
+// ListDrivers returns snippets ordered by ID
+func (m *MemoryDriverStore) ListDrivers(ctx context.Context) ([]*DriverSnippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	drivers := make([]*DriverSnippet, 0, len(m.drivers))
+	for _, driver := range m.drivers {
+		copied := *driver
+		drivers = append(drivers, &copied)
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].ID < drivers[j].ID })
+	return drivers, nil
+}
+
+func (m *MemoryDriverStore) GetDriver(ctx context.Context, id string) (*DriverSnippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	driver, ok := m.drivers[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotFound, id)
+	}
+	copied := *driver
+	return &copied, nil
+}
+
+func (m *MemoryDriverStore) SaveDriver(ctx context.Context, driver *DriverSnippet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *driver
+	m.drivers[driver.ID] = &stored
+	return nil
+}
+
+func (m *MemoryDriverStore) DeleteDriver(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.drivers[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrDriverNotFound, id)
+	}
+	delete(m.drivers, id)
+	return nil
+}
+
+// SetDriverStore sets where the driver snippet library is kept
+func (s *Service) SetDriverStore(store DriverStore) {
+	s.drivers = store
+}
+
+// sketchProfile is the sketch generated for templates of a category without
+// code of their own: the drivers it is composed from, in order, and how it
+// announces itself and paces its loop
+type sketchProfile struct {
+	kind      string // named in the header comment
+	name      string // device name when the "name" parameter is not set
+	ready     string // printed once setup() is done
+	drivers   []string
+	loopDelay string // template for the delay ending loop(), if any
+}
+
+var sketchProfiles = map[string]sketchProfile{
+	"sensing": {
+		kind: "sensor", name: "Sensor", ready: "initialized",
+		drivers:   []string{"dht22", "analog-sensor"},
+		loopDelay: "{{.delay_ms | default 2000}}",
+	},
+	"automation": {
+		kind: "automation", name: "Automation", ready: "system ready",
+		drivers: []string{"led-blink", "servo-sweep", "relay"},
+	},
+	"display": {
+		kind: "display", name: "Display", ready: "initialized",
+		drivers:   []string{"lcd1602"},
+		loopDelay: "{{.update_interval | default 1000}}",
+	},
+	"communication": {
+		kind: "communication", name: "Communication", ready: "system ready",
+		drivers:   []string{"wifi-mqtt"},
+		loopDelay: "{{.publish_interval | default 5000}}",
+	},
+}
+
+// basicSketch is the profile of templates in other categories
+var basicSketch = sketchProfile{
+	name: "Arduino", ready: "sketch started",
+	drivers: []string{"led-blink", "serial-heartbeat"},
+}
+
+// sketchDrivers returns the drivers a template's generated sketch is
+// composed from: those it names, or those of its category
+func (s *Service) sketchDrivers(ctx context.Context, tmpl *Template) (sketchProfile, []*DriverSnippet, error) {
+	profile, ok := sketchProfiles[strings.ToLower(tmpl.Category)]
+	if !ok {
+		profile = basicSketch
+	}
+	ids := profile.drivers
+	if len(tmpl.Drivers) > 0 {
+		ids = tmpl.Drivers
+	}
+
+	drivers := make([]*DriverSnippet, 0, len(ids))
+	for _, id := range ids {
+		driver, err := s.drivers.GetDriver(ctx, id)
+		if err != nil {
+			return profile, nil, err
+		}
+		drivers = append(drivers, driver)
+	}
+	return profile, drivers, nil
+}
+
+// validateDrivers checks that the driver snippets a template names are in
+// the library
+func (s *Service) validateDrivers(ctx context.Context, tmpl *Template) error {
+	for _, id := range tmpl.Drivers {
+		if _, err := s.drivers.GetDriver(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateSketch returns the code template of the sketch generated for a
+// template without code of its own
+func (s *Service) generateSketch(ctx context.Context, tmpl *Template) (string, []*DriverSnippet, error) {
+	profile, drivers, err := s.sketchDrivers(ctx, tmpl)
+	if err != nil {
+		return "", nil, err
+	}
+	return composeSketch(profile, drivers), drivers, nil
+}
+
+// composeSketch joins the sections of drivers into a sketch, each guarded
+// so it is only rendered when its driver is active
+func composeSketch(profile sketchProfile, drivers []*DriverSnippet) string {
+	section := func(name string, indentation int) string {
+		var parts []string
+		for _, driver := range drivers {
+			if code := strings.TrimSpace(driver.sections()[name]); code != "" {
+				parts = append(parts, driver.guard(indent(indentation, code)))
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+
+	header := "Generated Arduino code"
+	if profile.kind != "" {
+		header += " for " + profile.kind + " template"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "{{comment %q}}\n", header)
+	if code := section("includes", 0); code != "" {
+		b.WriteString(code + "\n")
+	}
+	if code := section("globals", 0); code != "" {
+		b.WriteString("\n" + code + "\n")
+	}
+	b.WriteString("\nvoid setup() {\n  Serial.begin(9600);\n")
+	if code := section("init", 2); code != "" {
+		b.WriteString(code + "\n")
+	}
+	fmt.Fprintf(&b, "\n  Serial.println(\"{{.name | default %q}} %s\");\n}\n", profile.name, profile.ready)
+	b.WriteString("\nvoid loop() {\n")
+	if code := section("read", 2); code != "" {
+		b.WriteString(code + "\n")
+	}
+	if profile.loopDelay != "" {
+		fmt.Fprintf(&b, "\n  delay(%s);\n", profile.loopDelay)
+	}
+	b.WriteString("}\n")
+	if code := section("functions", 0); code != "" {
+		b.WriteString("\n" + code + "\n")
+	}
+	return b.String()
+}
+
+// driverLibraries returns the libraries of the drivers active for
+// parameters that libraries does not list yet
+func driverLibraries(libraries []LibraryDependency, drivers []*DriverSnippet, parameters map[string]interface{}) []LibraryDependency {
+	for _, driver := range drivers {
+		if !driver.active(parameters) {
+			continue
+		}
+		for _, lib := range driver.Libraries {
+			found := false
+			for _, existing := range libraries {
+				if strings.EqualFold(existing.Name, lib.Name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				libraries = append(libraries, lib)
+			}
+		}
+	}
+	return libraries
+}
+
+// BuiltinDrivers returns the driver snippets the library starts with
+func BuiltinDrivers() []*DriverSnippet {
+	return []*DriverSnippet{
+		{
+			ID:          "dht22",
+			Component:   "DHT22",
+			Kind:        DriverKindSensor,
+			Description: "Temperature and humidity, blinking the LED on led_pin above temp_threshold",
+			Parameter:   "dht_pin",
+			Libraries:   []LibraryDependency{{Name: "DHT sensor library"}},
+			Includes:    "#include <DHT.h>",
+			Globals:     "{{defineConst \"DHT_PIN\" .dht_pin}}\nDHT dht({{.dht_pin}}, DHT22);",
+			Init:        "dht.begin();\n{{if .led_pin}}pinMode({{.led_pin}}, OUTPUT);{{end}}",
+			Read: `float temperature = dht.readTemperature();
+float humidity = dht.readHumidity();
+
+if (!isnan(temperature) && !isnan(humidity)) {
+  Serial.print("Temperature: ");
+  Serial.print(temperature);
+  Serial.print("°C, Humidity: ");
+  Serial.print(humidity);
+  Serial.println("%");
+  {{if .led_pin}}
+  // Blink LED based on temperature
+  if (temperature > {{.temp_threshold | default 25}}) {
+    digitalWrite({{.led_pin}}, HIGH);
+    delay(100);
+    digitalWrite({{.led_pin}}, LOW);
+  }
+  {{end}}
+}`,
+		},
+		{
+			ID:          "analog-sensor",
+			Component:   "Analog sensor",
+			Kind:        DriverKindSensor,
+			Description: "Raw readings of an analog sensor on sensor_pin, unless a DHT22 is wired",
+			Unless:      "dht_pin",
+			Globals:     "{{if .sensor_pin}}{{defineConst \"SENSOR_PIN\" .sensor_pin}}{{end}}",
+			Read: `int sensorValue = analogRead({{.sensor_pin | default "A0"}});
+Serial.print("Sensor reading: ");
+Serial.println(sensorValue);`,
+		},
+		{
+			ID:          "led-blink",
+			Component:   "LED",
+			Kind:        DriverKindActuator,
+			Description: "Blinks an LED on led_pin for on_time and off_time milliseconds",
+			Parameter:   "led_pin",
+			Globals:     "{{defineConst \"LED_PIN\" .led_pin}}",
+			Init:        "pinMode({{.led_pin}}, OUTPUT);",
+			Read: `// LED control
+digitalWrite({{.led_pin}}, HIGH);
+delay({{.on_time | default (.delay_on | default 1000)}});
+digitalWrite({{.led_pin}}, LOW);
+delay({{.off_time | default (.delay_off | default 1000)}});`,
+		},
+		{
+			ID:          "servo-sweep",
+			Component:   "SG90",
+			Kind:        DriverKindActuator,
+			Description: "Sweeps a hobby servo on servo_pin back and forth",
+			Parameter:   "servo_pin",
+			Libraries:   []LibraryDependency{{Name: "Servo"}},
+			Includes:    "#include <Servo.h>",
+			Globals:     "{{defineConst \"SERVO_PIN\" .servo_pin}}\nServo myServo;",
+			Init:        "myServo.attach({{.servo_pin}});",
+			Read: `// Servo control
+for (int pos = 0; pos <= 180; pos += 1) {
+  myServo.write(pos);
+  delay(15);
+}
+for (int pos = 180; pos >= 0; pos -= 1) {
+  myServo.write(pos);
+  delay(15);
+}`,
+		},
+		{
+			ID:          "relay",
+			Component:   "Relay module",
+			Kind:        DriverKindActuator,
+			Description: "Switches a relay on relay_pin for relay_on_time and relay_off_time milliseconds",
+			Parameter:   "relay_pin",
+			Globals:     "{{defineConst \"RELAY_PIN\" .relay_pin}}",
+			Init:        "pinMode({{.relay_pin}}, OUTPUT);",
+			Read: `// Relay control
+digitalWrite({{.relay_pin}}, HIGH);
+delay({{.relay_on_time | default 2000}});
+digitalWrite({{.relay_pin}}, LOW);
+delay({{.relay_off_time | default 2000}});`,
+		},
+		{
+			ID:          "lcd1602",
+			Component:   "LCD1602",
+			Kind:        DriverKindDisplay,
+			Description: "16x2 character LCD in 4-bit mode showing display_message and the uptime",
+			Parameter:   "lcd_rs",
+			Libraries:   []LibraryDependency{{Name: "LiquidCrystal"}},
+			Includes:    "#include <LiquidCrystal.h>",
+			Globals: `{{defineConst "LCD_RS" .lcd_rs}}
+{{if .lcd_enable}}{{defineConst "LCD_ENABLE" .lcd_enable}}{{end}}
+LiquidCrystal lcd({{.lcd_rs}}, {{.lcd_enable}}, {{.lcd_d4}}, {{.lcd_d5}}, {{.lcd_d6}}, {{.lcd_d7}});`,
+			Init: `lcd.begin(16, 2);
+lcd.print("{{.display_message | default "Hello, World!"}}");`,
+			Read: `lcd.setCursor(0, 1);
+lcd.print("Time: ");
+lcd.print(millis() / 1000);
+lcd.print("s");`,
+		},
+		{
+			ID:          "wifi-mqtt",
+			Component:   "WiFi",
+			Kind:        DriverKindCommunication,
+			Description: "Joins wifi_ssid and publishes uptime to the device's MQTT telemetry topic on mqtt_server",
+			Parameter:   "wifi_ssid",
+			Libraries:   []LibraryDependency{{Name: "PubSubClient"}},
+			Includes:    "#include <WiFi.h>\n#include <PubSubClient.h>",
+			Globals: `{{defineConst "WIFI_SSID" .wifi_ssid}}
+{{if .mqtt_server}}{{defineConst "MQTT_SERVER" .mqtt_server}}{{end}}
+WiFiClient espClient;
+PubSubClient client(espClient);`,
+			Init: `WiFi.begin("{{.wifi_ssid}}", "{{.wifi_password}}");
+while (WiFi.status() != WL_CONNECTED) {
+  delay(1000);
+  Serial.println("Connecting to WiFi...");
+}
+Serial.println("WiFi connected");
+{{if .mqtt_server}}
+client.setServer("{{.mqtt_server}}", {{.mqtt_port | default 1883}});
+{{end}}`,
+			Read: `if (!client.connected()) {
+  reconnect();
+}
+client.loop();
+
+// Publish sensor data to this device's telemetry topic
+String payload = "{\"device_id\":\"{{.device_id | default "arduino"}}\",\"metrics\":{\"uptime_ms\":" + String(millis()) + "}}";
+client.publish("{{mqttTopic (.device_id | default "arduino") "data"}}", payload.c_str());`,
+			Functions: `void reconnect() {
+  while (!client.connected()) {
+    Serial.print("Attempting MQTT connection...");
+    // The broker only lets a device publish under its own topics, so the
+    // device ID doubles as the MQTT username
+    if (client.connect("{{.device_id | default "arduino"}}", "{{.device_id | default "arduino"}}", {{if .mqtt_password}}"{{.mqtt_password}}"{{else}}NULL{{end}})) {
+      Serial.println("connected");
+    } else {
+      Serial.print("failed, rc=");
+      Serial.print(client.state());
+      Serial.println(" try again in 5 seconds");
+      delay(5000);
+    }
+  }
+}`,
+		},
+		{
+			ID:          "serial-heartbeat",
+			Component:   "Serial",
+			Kind:        DriverKindCommunication,
+			Description: "Prints a heartbeat every loop_delay milliseconds when no LED is wired",
+			Unless:      "led_pin",
+			Read: `Serial.println("Running...");
+delay({{.loop_delay | default 1000}});`,
+		},
+	}
+}
+
+// HTTP handlers
+
+func (s *Service) listDrivers(c *gin.Context) {
+	drivers, err := s.drivers.ListDrivers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"drivers": drivers})
+}
+
+func (s *Service) getDriver(c *gin.Context) {
+	driver, err := s.drivers.GetDriver(c.Request.Context(), c.Param("driverId"))
+	if err != nil {
+		s.respondDriverError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, driver)
+}
+
+// putDriver adds a snippet to the library or replaces the one with its ID
+func (s *Service) putDriver(c *gin.Context) {
+	var driver DriverSnippet
+	if err := c.ShouldBindJSON(&driver); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	driver.ID = c.Param("driverId")
+	driver.UpdatedAt = time.Now()
+
+	if err := validateDriver(&driver, s.renderer.funcMap); err != nil {
+		s.respondDriverError(c, err)
+		return
+	}
+	if err := s.drivers.SaveDriver(c.Request.Context(), &driver); err != nil {
+		s.respondDriverError(c, err)
+		return
+	}
+
+	s.logger.Info("Saved driver snippet", "driver_id", driver.ID, "component", driver.Component)
+	c.JSON(http.StatusOK, &driver)
+}
+
+func (s *Service) deleteDriver(c *gin.Context) {
+	driverID := c.Param("driverId")
+	if err := s.drivers.DeleteDriver(c.Request.Context(), driverID); err != nil {
+		s.respondDriverError(c, err)
+		return
+	}
+
+	s.logger.Info("Deleted driver snippet", "driver_id", driverID)
+	c.Status(http.StatusNoContent)
+}
+
+func (s *Service) respondDriverError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrDriverNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidDriver):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
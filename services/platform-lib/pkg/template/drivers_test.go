@@ -0,0 +1,164 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinDrivers_Valid(t *testing.T) {
+	funcs := NewTemplateRenderer().funcMap
+	for _, driver := range BuiltinDrivers() {
+		assert.NoError(t, validateDriver(driver, funcs), driver.ID)
+	}
+
+	// Every category's sketch is composed from drivers in the library
+	store := NewMemoryDriverStore()
+	profiles := []sketchProfile{basicSketch}
+	for _, profile := range sketchProfiles {
+		profiles = append(profiles, profile)
+	}
+	for _, profile := range profiles {
+		for _, id := range profile.drivers {
+			_, err := store.GetDriver(context.Background(), id)
+			assert.NoError(t, err, id)
+		}
+	}
+}
+
+func TestValidateDriver(t *testing.T) {
+	funcs := NewTemplateRenderer().funcMap
+	valid := DriverSnippet{ID: "bh1750", Component: "BH1750", Kind: DriverKindSensor, Parameter: "light_sda", Read: "float lux = lightMeter.readLightLevel();"}
+	require.NoError(t, validateDriver(&valid, funcs))
+
+	tests := []struct {
+		name   string
+		modify func(*DriverSnippet)
+	}{
+		{"bad ID", func(d *DriverSnippet) { d.ID = "BH 1750" }},
+		{"no component", func(d *DriverSnippet) { d.Component = " " }},
+		{"unknown kind", func(d *DriverSnippet) { d.Kind = "motor" }},
+		{"bad parameter", func(d *DriverSnippet) { d.Parameter = "light-sda" }},
+		{"no code", func(d *DriverSnippet) { d.Read = "" }},
+		{"unparsable section", func(d *DriverSnippet) { d.Globals = "{{if .light_sda}}" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := valid
+			tt.modify(&driver)
+			assert.ErrorIs(t, validateDriver(&driver, funcs), ErrInvalidDriver)
+		})
+	}
+}
+
+func TestService_RenderTemplate_GeneratedSketch(t *testing.T) {
+	sensing := &Template{
+		ID:              "climate",
+		Name:            "Climate",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+	}
+	named := &Template{
+		ID:              "gate",
+		Name:            "Gate",
+		Version:         "1.0.0",
+		Category:        "custom",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Drivers:         []string{"servo-sweep", "relay"},
+	}
+	service := setupCompositionService(t, sensing, named)
+	ctx := context.Background()
+
+	rendered, err := service.RenderTemplate(ctx, "climate", "1.0.0", map[string]interface{}{"dht_pin": 4, "led_pin": 2})
+	require.NoError(t, err)
+	code := rendered.RenderedCode
+	assert.Contains(t, code, "#include <DHT.h>")
+	assert.Contains(t, code, "DHT dht(4, DHT22);")
+	assert.Contains(t, code, "dht.begin();")
+	assert.Contains(t, code, "pinMode(2, OUTPUT);")
+	assert.Contains(t, code, "delay(2000);")
+	assert.NotContains(t, code, "analogRead")
+	assert.Less(t, strings.Index(code, "#include <DHT.h>"), strings.Index(code, "DHT dht("))
+	assert.Contains(t, rendered.Template.Libraries, LibraryDependency{Name: "DHT sensor library"})
+
+	// Without a DHT22 the analog sensor is read instead
+	rendered, err = service.RenderTemplate(ctx, "climate", "1.0.0", map[string]interface{}{"sensor_pin": "A3"})
+	require.NoError(t, err)
+	assert.Contains(t, rendered.RenderedCode, "analogRead(A3)")
+	assert.NotContains(t, rendered.RenderedCode, "DHT")
+	assert.Empty(t, rendered.Template.Libraries)
+
+	// Templates naming drivers get those instead of their category's
+	rendered, err = service.RenderTemplate(ctx, "gate", "1.0.0", map[string]interface{}{"servo_pin": 9, "relay_pin": 7})
+	require.NoError(t, err)
+	assert.Contains(t, rendered.RenderedCode, "myServo.attach(9);")
+	assert.Contains(t, rendered.RenderedCode, "digitalWrite(7, HIGH);")
+	assert.Contains(t, rendered.RenderedCode, `Serial.println("Arduino sketch started");`)
+}
+
+func TestService_CreateTemplate_UnknownDriver(t *testing.T) {
+	service := setupCompositionService(t)
+
+	tmpl := createDHT22Template()
+	tmpl.Drivers = []string{"dht22", "bme680"}
+	assert.ErrorIs(t, service.CreateTemplate(context.Background(), tmpl), ErrDriverNotFound)
+}
+
+func TestService_DriverRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/drivers"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("PUT", "/bh1750", `{"component":"BH1750","kind":"sensor","parameter":"light_sda","includes":"#include <BH1750.h>","read":"float lux = lightMeter.readLightLevel();"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = send("GET", "/bh1750", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var driver DriverSnippet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &driver))
+	assert.Equal(t, "bh1750", driver.ID)
+	assert.Equal(t, "#include <BH1750.h>", driver.Includes)
+
+	w = send("PUT", "/bh1750", `{"component":"BH1750","kind":"sensor","read":"{{if .light_sda}}"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("GET", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Drivers []*DriverSnippet `json:"drivers"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Drivers, len(BuiltinDrivers())+1)
+
+	w = send("DELETE", "/bh1750", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = send("GET", "/bh1750", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDriverEntity(t *testing.T) {
+	for _, driver := range BuiltinDrivers() {
+		entity, err := driver.ToEntity()
+		require.NoError(t, err)
+		restored, err := entity.FromEntity(driver.ID)
+		require.NoError(t, err)
+		assert.Equal(t, driver, restored)
+	}
+}
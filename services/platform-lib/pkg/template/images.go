@@ -0,0 +1,554 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Preview image roles. A template version has at most one image per role.
+const (
+	ImageRoleCover       = "cover"
+	ImageRoleWiringPhoto = "wiring_photo"
+)
+
+// imageAssetType is the asset type preview images are recorded under
+const imageAssetType = "image"
+
+const (
+	// maxImageBytes bounds an uploaded image
+	maxImageBytes = 5 << 20
+
+	// maxImagePixels bounds the decoded size of an uploaded image, so a
+	// small file cannot expand into a huge bitmap
+	maxImagePixels = 40_000_000
+
+	// Thumbnails fit within these bounds, keeping the aspect ratio
+	thumbnailWidth  = 320
+	thumbnailHeight = 240
+)
+
+var (
+	// ErrImageNotFound is returned for a role with no uploaded image
+	ErrImageNotFound = errors.New("image not found")
+
+	// ErrInvalidImage is returned for uploads that are not a usable PNG,
+	// JPEG or GIF image
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+var imageRoles = map[string]bool{
+	ImageRoleCover:       true,
+	ImageRoleWiringPhoto: true,
+}
+
+// TemplateImage is a preview image of a template version as listed in
+// catalogs. URLs are relative to the API root.
+type TemplateImage struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// ImageStore stores the bytes of preview images and their thumbnails
+type ImageStore interface {
+	PutImage(ctx context.Context, path string, data []byte) error
+	GetImage(ctx context.Context, path string) ([]byte, error)
+	DeleteImage(ctx context.Context, path string) error
+}
+
+// MemoryImageStore is an in-memory ImageStore
+type MemoryImageStore struct {
+	mu     sync.RWMutex
+	images map[string][]byte
+}
+
+// NewMemoryImageStore creates an empty image store
+func NewMemoryImageStore() *MemoryImageStore {
+	return &MemoryImageStore{images: make(map[string][]byte)}
+}
+
+func (s *MemoryImageStore) PutImage(ctx context.Context, path string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[path] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemoryImageStore) GetImage(ctx context.Context, path string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.images[path]
+	if !ok {
+		return nil, ErrImageNotFound
+	}
+	return data, nil
+}
+
+func (s *MemoryImageStore) DeleteImage(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.images, path)
+	return nil
+}
+
+// LocalImageStore implements ImageStore on the local filesystem, keeping
+// images under a base directory by their path
+type LocalImageStore struct {
+	basePath string
+}
+
+// NewLocalImageStore creates an image store under basePath, creating the
+// directory if needed
+func NewLocalImageStore(basePath string) (*LocalImageStore, error) {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	return &LocalImageStore{basePath: basePath}, nil
+}
+
+// file returns where the image at path is kept, rejecting paths that
+// leave the base directory
+func (s *LocalImageStore) file(path string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("invalid image path %q", path)
+	}
+	return filepath.Join(s.basePath, filepath.FromSlash(path)), nil
+}
+
+// PutImage writes an image, replacing any at the same path. The bytes are
+// written to a temporary file first so readers never see part of an image.
+func (s *LocalImageStore) PutImage(ctx context.Context, path string, data []byte) error {
+	file, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalImageStore) GetImage(ctx context.Context, path string) ([]byte, error) {
+	file, err := s.file(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image file: %w", err)
+	}
+	return data, nil
+}
+
+func (s *LocalImageStore) DeleteImage(ctx context.Context, path string) error {
+	file, err := s.file(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete image file: %w", err)
+	}
+	return nil
+}
+
+// SetImageStore replaces the store preview images are kept in
+func (s *Service) SetImageStore(store ImageStore) {
+	s.images = store
+}
+
+// UploadImage stores a preview image for a template version, replacing any
+// image with the same role, and generates its thumbnail
+func (s *Service) UploadImage(ctx context.Context, templateID, version, role string, data []byte) (*TemplateImage, error) {
+	if !imageRoles[role] {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidImage, role)
+	}
+
+	tmpl, err := s.imageTemplate(ctx, templateID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := encodeThumbnail(img, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate thumbnail: %w", err)
+	}
+
+	path := imagePrefix(tmpl) + role + "." + format
+	thumbnailPath := imagePrefix(tmpl) + role + "_thumb." + thumbnailFormat(format)
+	if err := s.images.PutImage(ctx, path, data); err != nil {
+		return nil, fmt.Errorf("failed to store image: %w", err)
+	}
+	if err := s.images.PutImage(ctx, thumbnailPath, thumbnail); err != nil {
+		return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	// Replace the previous image of the role
+	if previous := findImageAsset(tmpl.Assets, role); previous != nil {
+		if err := s.repo.DeleteAsset(ctx, tmpl.ID, tmpl.Version, imageAssetType, previous.Path); err != nil {
+			return nil, fmt.Errorf("failed to replace image: %w", err)
+		}
+		s.deleteImageFiles(ctx, tmpl, previous, path, thumbnailPath)
+	}
+
+	bounds := img.Bounds()
+	asset := &Asset{
+		Type: imageAssetType,
+		Path: path,
+		Metadata: map[string]interface{}{
+			"role":                   role,
+			"content_type":           "image/" + format,
+			"width":                  bounds.Dx(),
+			"height":                 bounds.Dy(),
+			"thumbnail_path":         thumbnailPath,
+			"thumbnail_content_type": "image/" + thumbnailFormat(format),
+		},
+	}
+	if err := s.repo.CreateAsset(ctx, tmpl.ID, tmpl.Version, asset); err != nil {
+		return nil, fmt.Errorf("failed to record image: %w", err)
+	}
+
+	return templateImage(tmpl.ID, tmpl.Version, *asset), nil
+}
+
+// GetImage returns a preview image, or its thumbnail, and its content type
+func (s *Service) GetImage(ctx context.Context, templateID, version, role string, thumbnail bool) ([]byte, string, error) {
+	tmpl, err := s.imageTemplate(ctx, templateID, version)
+	if err != nil {
+		return nil, "", err
+	}
+
+	asset := findImageAsset(tmpl.Assets, role)
+	if asset == nil {
+		return nil, "", ErrImageNotFound
+	}
+
+	path, contentType := asset.Path, metadataString(asset.Metadata, "content_type")
+	if thumbnail {
+		path, contentType = metadataString(asset.Metadata, "thumbnail_path"), metadataString(asset.Metadata, "thumbnail_content_type")
+	}
+	data, err := s.images.GetImage(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// DeleteImage removes a preview image and its thumbnail
+func (s *Service) DeleteImage(ctx context.Context, templateID, version, role string) error {
+	tmpl, err := s.imageTemplate(ctx, templateID, version)
+	if err != nil {
+		return err
+	}
+
+	asset := findImageAsset(tmpl.Assets, role)
+	if asset == nil {
+		return ErrImageNotFound
+	}
+	if err := s.repo.DeleteAsset(ctx, tmpl.ID, tmpl.Version, imageAssetType, asset.Path); err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	s.deleteImageFiles(ctx, tmpl, asset)
+	return nil
+}
+
+// imageTemplate resolves the template version images belong to
+func (s *Service) imageTemplate(ctx context.Context, templateID, version string) (*Template, error) {
+	tmpl, err := s.GetTemplate(ctx, templateID, version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s version %s", ErrTemplateNotFound, templateID, version)
+	}
+	return tmpl, nil
+}
+
+// imagePrefix is where the images of a template version are stored
+func imagePrefix(tmpl *Template) string {
+	return fmt.Sprintf("templates/%s/%s/", tmpl.ID, tmpl.Version)
+}
+
+// deleteImageFiles removes the stored bytes of an image asset, keeping the
+// given paths, which a replacement image was just written to. Forks share
+// the images of their source template, so only the template's own files
+// are removed.
+func (s *Service) deleteImageFiles(ctx context.Context, tmpl *Template, asset *Asset, keep ...string) {
+	for _, path := range []string{asset.Path, metadataString(asset.Metadata, "thumbnail_path")} {
+		if !strings.HasPrefix(path, imagePrefix(tmpl)) || slices.Contains(keep, path) {
+			continue
+		}
+		if err := s.images.DeleteImage(ctx, path); err != nil {
+			s.logger.Warn("Failed to delete image", "path", path, "error", err)
+		}
+	}
+}
+
+// setTemplateImages fills in the preview images of a template from its
+// assets
+func setTemplateImages(tmpl *Template) {
+	tmpl.Images = nil
+	for _, asset := range tmpl.Assets {
+		role := metadataString(asset.Metadata, "role")
+		if asset.Type != imageAssetType || !imageRoles[role] {
+			continue
+		}
+		if tmpl.Images == nil {
+			tmpl.Images = make(map[string]*TemplateImage)
+		}
+		tmpl.Images[role] = templateImage(tmpl.ID, tmpl.Version, asset)
+	}
+}
+
+func templateImage(templateID, version string, asset Asset) *TemplateImage {
+	role := metadataString(asset.Metadata, "role")
+	imageURL := fmt.Sprintf("/api/v1/templates/%s/images/%s?version=%s", url.PathEscape(templateID), role, url.QueryEscape(version))
+	return &TemplateImage{
+		URL:          imageURL,
+		ThumbnailURL: imageURL + "&size=thumbnail",
+		ContentType:  metadataString(asset.Metadata, "content_type"),
+		Width:        metadataInt(asset.Metadata, "width"),
+		Height:       metadataInt(asset.Metadata, "height"),
+	}
+}
+
+func findImageAsset(assets []Asset, role string) *Asset {
+	for i := range assets {
+		if assets[i].Type == imageAssetType && metadataString(assets[i].Metadata, "role") == role {
+			return &assets[i]
+		}
+	}
+	return nil
+}
+
+// decodeImage decodes a PNG, JPEG or GIF image after checking its size
+func decodeImage(data []byte) (image.Image, string, error) {
+	if len(data) > maxImageBytes {
+		return nil, "", fmt.Errorf("%w: larger than %d bytes", ErrInvalidImage, maxImageBytes)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, "", fmt.Errorf("%w: %dx%d pixels is too large", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return img, format, nil
+}
+
+// thumbnailFormat keeps PNG thumbnails for PNG images, whose transparency
+// JPEG would lose
+func thumbnailFormat(format string) string {
+	if format == "png" {
+		return "png"
+	}
+	return "jpeg"
+}
+
+// encodeThumbnail scales an image down to fit the thumbnail bounds
+func encodeThumbnail(img image.Image, format string) ([]byte, error) {
+	thumb := scaleDown(img, thumbnailWidth, thumbnailHeight)
+
+	var buf bytes.Buffer
+	var err error
+	if thumbnailFormat(format) == "png" {
+		err = png.Encode(&buf, thumb)
+	} else {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown fits an image within maxWidth x maxHeight by averaging the
+// source pixels under each thumbnail pixel. Smaller images are not
+// enlarged.
+func scaleDown(img image.Image, maxWidth, maxHeight int) *image.RGBA {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := sw, sh
+	if dw > maxWidth {
+		dw, dh = maxWidth, max(1, sh*maxWidth/sw)
+	}
+	if dh > maxHeight {
+		dw, dh = max(1, dw*maxHeight/dh), maxHeight
+	}
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				d[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	value, _ := metadata[key].(string)
+	return value
+}
+
+// metadataInt reads a number from asset metadata, which is float64 once it
+// has been stored as JSON
+func metadataInt(metadata map[string]interface{}, key string) int {
+	switch value := metadata[key].(type) {
+	case int:
+		return value
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+func (s *Service) uploadImage(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	role := c.Param("role")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Image file is required", "details": err.Error()})
+		return
+	}
+	if file.Size > maxImageBytes {
+		c.JSON(413, gin.H{"error": "Image too large", "details": fmt.Sprintf("images are limited to %d bytes", maxImageBytes)})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Failed to read image", "details": err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImageBytes+1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Failed to read image", "details": err.Error()})
+		return
+	}
+
+	img, err := s.UploadImage(ctx, templateID, version, role, data)
+	switch {
+	case errors.Is(err, ErrInvalidImage):
+		c.JSON(400, gin.H{"error": "Invalid image", "details": err.Error()})
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+	case err != nil:
+		s.logger.Error("Failed to upload image", "id", templateID, "version", version, "role", role, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to upload image", "details": err.Error()})
+	default:
+		c.JSON(200, img)
+	}
+}
+
+func (s *Service) getImage(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	role := c.Param("role")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	data, contentType, err := s.GetImage(ctx, templateID, version, role, c.Query("size") == "thumbnail")
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Image not found", "details": err.Error()})
+		return
+	}
+
+	// Images of a pinned version only change when replaced
+	if c.Query("version") != "" {
+		c.Header("Cache-Control", "public, max-age=300")
+	}
+	c.Data(200, contentType, data)
+}
+
+func (s *Service) deleteImage(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	role := c.Param("role")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	err := s.DeleteImage(ctx, templateID, version, role)
+	switch {
+	case errors.Is(err, ErrTemplateNotFound), errors.Is(err, ErrImageNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case err != nil:
+		s.logger.Error("Failed to delete image", "id", templateID, "version", version, "role", role, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to delete image", "details": err.Error()})
+	default:
+		c.Status(204)
+	}
+}
@@ -0,0 +1,173 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, img))
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error {
+	return png.Encode(buf, img)
+}
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(buf, img, nil)
+}
+
+func createImageTemplate() *Template {
+	return &Template{
+		ID:              "blink",
+		Name:            "Blink",
+		Version:         "1.0.0",
+		Category:        "automation",
+		BoardsSupported: []string{"arduino:avr:uno"},
+	}
+}
+
+func TestService_UploadImage(t *testing.T) {
+	service := setupCompositionService(t, createImageTemplate())
+	ctx := context.Background()
+
+	img, err := service.UploadImage(ctx, "blink", "latest", ImageRoleCover, encodeTestImage(t, 800, 400, encodePNG))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, 800, img.Width)
+	assert.Equal(t, 400, img.Height)
+	assert.Equal(t, "/api/v1/templates/blink/images/cover?version=1.0.0", img.URL)
+
+	// The thumbnail keeps the aspect ratio within 320x240
+	data, contentType, err := service.GetImage(ctx, "blink", "1.0.0", ImageRoleCover, true)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 160, cfg.Height)
+
+	// Replacing the image keeps one asset per role
+	_, err = service.UploadImage(ctx, "blink", "1.0.0", ImageRoleCover, encodeTestImage(t, 100, 100, encodeJPEG))
+	require.NoError(t, err)
+	tmpl, err := service.GetTemplate(ctx, "blink", "1.0.0")
+	require.NoError(t, err)
+	require.Len(t, tmpl.Assets, 1)
+	assert.Equal(t, "templates/blink/1.0.0/cover.jpeg", tmpl.Assets[0].Path)
+
+	_, err = service.images.GetImage(ctx, "templates/blink/1.0.0/cover.png")
+	assert.ErrorIs(t, err, ErrImageNotFound)
+
+	// Small images are not enlarged
+	data, contentType, err = service.GetImage(ctx, "blink", "1.0.0", ImageRoleCover, true)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+	cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfg.Width)
+
+	_, err = service.UploadImage(ctx, "blink", "1.0.0", "banner", encodeTestImage(t, 10, 10, encodePNG))
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	_, err = service.UploadImage(ctx, "blink", "1.0.0", ImageRoleCover, []byte("not an image"))
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	_, err = service.UploadImage(ctx, "missing", "1.0.0", ImageRoleCover, encodeTestImage(t, 10, 10, encodePNG))
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+	require.NoError(t, service.DeleteImage(ctx, "blink", "1.0.0", ImageRoleCover))
+	assert.ErrorIs(t, service.DeleteImage(ctx, "blink", "1.0.0", ImageRoleCover), ErrImageNotFound)
+}
+
+func TestService_ImageEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t, createImageTemplate())
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("image", "wiring.png")
+	require.NoError(t, err)
+	_, err = part.Write(encodeTestImage(t, 640, 480, encodePNG))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/templates/blink/images/wiring_photo", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Listings carry the image URLs
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Templates []Template `json:"templates"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Templates, 1)
+	photo := list.Templates[0].Images[ImageRoleWiringPhoto]
+	require.NotNil(t, photo)
+	assert.Equal(t, 640, photo.Width)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, photo.ThumbnailURL, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+	cfg, _, err := image.DecodeConfig(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 240, cfg.Height)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/blink/images/cover", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/templates/blink/images/wiring_photo", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestLocalImageStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	path := "templates/weather/1.0.0/cover.png"
+	require.NoError(t, store.PutImage(ctx, path, []byte("first")))
+	require.NoError(t, store.PutImage(ctx, path, []byte("second")))
+	data, err := store.GetImage(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	require.NoError(t, store.DeleteImage(ctx, path))
+	_, err = store.GetImage(ctx, path)
+	assert.ErrorIs(t, err, ErrImageNotFound)
+	assert.NoError(t, store.DeleteImage(ctx, path))
+
+	assert.Error(t, store.PutImage(ctx, "../outside.png", []byte("x")))
+	_, err = store.GetImage(ctx, "/etc/passwd")
+	assert.Error(t, err)
+}
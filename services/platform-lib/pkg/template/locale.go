@@ -0,0 +1,171 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// LocaleParameter is the render parameter naming the locale to render
+// comments and instructions in. Like block overrides it is removed before
+// schema validation.
+const LocaleParameter = "locale"
+
+// LocaleAssetType is the asset type of a locale pack. A pack's messages are
+// held in its "messages" metadata, or as a JSON object in "content", and
+// its locale is taken from the "locale" metadata or the file name, e.g.
+// locales/de.json.
+const LocaleAssetType = "locale"
+
+// Messages maps message keys to localized text
+type Messages map[string]string
+
+// Translate returns the message for key, or fallback when the key has no
+// translation. Arguments are formatted into the message with fmt verbs.
+func (m Messages) Translate(key, fallback string, args ...interface{}) string {
+	text, ok := m[key]
+	if !ok || text == "" {
+		text = fallback
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// ExtractLocale removes the locale from render parameters
+func ExtractLocale(parameters map[string]interface{}) (map[string]interface{}, string, error) {
+	raw, exists := parameters[LocaleParameter]
+	if !exists {
+		return parameters, "", nil
+	}
+
+	locale, ok := raw.(string)
+	if !ok {
+		return nil, "", fmt.Errorf("parameter '%s' must be a locale such as \"de\" or \"pt-BR\"", LocaleParameter)
+	}
+
+	remaining := make(map[string]interface{}, len(parameters)-1)
+	for name, value := range parameters {
+		if name != LocaleParameter {
+			remaining[name] = value
+		}
+	}
+
+	return remaining, NormalizeLocale(locale), nil
+}
+
+// NormalizeLocale canonicalizes a locale tag so "pt_br" and "pt-BR" match
+func NormalizeLocale(locale string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	for i, part := range parts {
+		if i == 0 {
+			parts[i] = strings.ToLower(part)
+		} else if len(part) == 2 {
+			parts[i] = strings.ToUpper(part)
+		} else if len(part) == 4 {
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		} else {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// LocalePacks collects the locale packs among a template's assets. When a
+// composed template carries several packs for one locale they are merged,
+// with earlier assets taking precedence.
+func LocalePacks(assets []Asset) (map[string]Messages, error) {
+	packs := make(map[string]Messages)
+	for _, asset := range assets {
+		if asset.Type != LocaleAssetType {
+			continue
+		}
+
+		locale, _ := asset.Metadata["locale"].(string)
+		if locale == "" {
+			locale = strings.TrimSuffix(path.Base(asset.Path), path.Ext(asset.Path))
+		}
+		locale = NormalizeLocale(locale)
+		if locale == "" {
+			return nil, fmt.Errorf("locale pack %s has no locale", asset.Path)
+		}
+
+		messages, err := localePackMessages(asset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid locale pack %s: %w", asset.Path, err)
+		}
+
+		if packs[locale] == nil {
+			packs[locale] = make(Messages)
+		}
+		for key, text := range messages {
+			if _, exists := packs[locale][key]; !exists {
+				packs[locale][key] = text
+			}
+		}
+	}
+	return packs, nil
+}
+
+func localePackMessages(asset Asset) (Messages, error) {
+	messages := make(Messages)
+	switch raw := asset.Metadata["messages"].(type) {
+	case map[string]interface{}:
+		for key, value := range raw {
+			text, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("message '%s' must be a string", key)
+			}
+			messages[key] = text
+		}
+		return messages, nil
+	case map[string]string:
+		for key, text := range raw {
+			messages[key] = text
+		}
+		return messages, nil
+	case nil:
+	default:
+		return nil, fmt.Errorf("messages must map keys to text")
+	}
+
+	content, _ := asset.Metadata["content"].(string)
+	if content == "" {
+		return nil, fmt.Errorf("pack has no messages")
+	}
+	if err := json.Unmarshal([]byte(content), &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ResolveLocale picks the pack for a requested locale, falling back from a
+// regional variant to its language ("pt-BR" to "pt"). It returns the locale
+// used, or "" when no pack matches and the template's own text applies.
+func ResolveLocale(packs map[string]Messages, requested string) (string, Messages) {
+	requested = NormalizeLocale(requested)
+	for locale := requested; locale != ""; {
+		if messages, ok := packs[locale]; ok {
+			return locale, messages
+		}
+		cut := strings.LastIndex(locale, "-")
+		if cut < 0 {
+			break
+		}
+		locale = locale[:cut]
+	}
+	return "", nil
+}
+
+// Locales lists the locales available in a set of packs
+func Locales(packs map[string]Messages) []string {
+	locales := make([]string, 0, len(packs))
+	for locale := range packs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
@@ -0,0 +1,114 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const localizedSketch = `// {{t "header" "Reads the sensor every %d ms" .interval}}
+void loop() {
+  // {{t "read" "Read the sensor"}}
+  delay({{.interval}});
+}`
+
+func TestExtractLocale(t *testing.T) {
+	params, locale, err := ExtractLocale(map[string]interface{}{"interval": 500, "locale": "pt_br"})
+	require.NoError(t, err)
+	assert.Equal(t, "pt-BR", locale)
+	assert.Equal(t, map[string]interface{}{"interval": 500}, params)
+
+	_, _, err = ExtractLocale(map[string]interface{}{"locale": 7})
+	assert.Error(t, err)
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	assert.Equal(t, "de", NormalizeLocale("DE"))
+	assert.Equal(t, "pt-BR", NormalizeLocale("pt_br"))
+	assert.Equal(t, "zh-Hant-TW", NormalizeLocale("zh-hant-tw"))
+	assert.Equal(t, "", NormalizeLocale(" "))
+}
+
+func TestLocalePacks(t *testing.T) {
+	packs, err := LocalePacks([]Asset{
+		{Type: "code", Path: "main.ino"},
+		{Type: LocaleAssetType, Path: "locales/de.json", Metadata: map[string]interface{}{
+			"messages": map[string]interface{}{"read": "Sensor lesen"},
+		}},
+		{Type: LocaleAssetType, Path: "locales/de.json", Metadata: map[string]interface{}{
+			"content": `{"read": "Ignored", "header": "Liest den Sensor alle %d ms"}`,
+		}},
+		{Type: LocaleAssetType, Path: "i18n/brazil.json", Metadata: map[string]interface{}{
+			"locale":  "pt_BR",
+			"content": `{"read": "Ler o sensor"}`,
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de", "pt-BR"}, Locales(packs))
+	assert.Equal(t, Messages{"read": "Sensor lesen", "header": "Liest den Sensor alle %d ms"}, packs["de"], "earlier packs take precedence")
+
+	_, err = LocalePacks([]Asset{{Type: LocaleAssetType, Path: "locales/fr.json", Metadata: map[string]interface{}{"content": "not json"}}})
+	assert.Error(t, err)
+}
+
+func TestResolveLocale(t *testing.T) {
+	packs := map[string]Messages{"de": {"read": "Sensor lesen"}, "pt-BR": {"read": "Ler o sensor"}}
+
+	locale, messages := ResolveLocale(packs, "de-AT")
+	assert.Equal(t, "de", locale)
+	assert.Equal(t, "Sensor lesen", messages["read"])
+
+	locale, _ = ResolveLocale(packs, "pt_br")
+	assert.Equal(t, "pt-BR", locale)
+
+	locale, messages = ResolveLocale(packs, "fr")
+	assert.Equal(t, "", locale)
+	assert.Nil(t, messages)
+}
+
+func TestRenderLocalized(t *testing.T) {
+	renderer := NewTemplateRenderer()
+	params := map[string]interface{}{"interval": 500}
+
+	code, err := renderer.RenderWithOverrides(localizedSketch, nil, params, nil, "")
+	require.NoError(t, err)
+	assert.Contains(t, code, "// Reads the sensor every 500 ms")
+	assert.Contains(t, code, "// Read the sensor")
+
+	code, err = renderer.RenderLocalized(localizedSketch, nil, params, nil, "", Messages{"header": "Liest den Sensor alle %d ms"})
+	require.NoError(t, err)
+	assert.Contains(t, code, "// Liest den Sensor alle 500 ms")
+	assert.Contains(t, code, "// Read the sensor", "untranslated keys keep the default text")
+}
+
+func TestService_RenderTemplate_Locale(t *testing.T) {
+	service, mockRepo := setupTestService()
+
+	tmpl := &Template{
+		ID:      "localized",
+		Version: "1.0.0",
+		Schema:  map[string]interface{}{"type": "object"},
+		Assets: []Asset{
+			{Type: "code", Path: "main.ino", Metadata: map[string]interface{}{"content": localizedSketch}},
+			{Type: LocaleAssetType, Path: "locales/de.json", Metadata: map[string]interface{}{
+				"messages": map[string]interface{}{"read": "Sensor lesen"},
+			}},
+		},
+	}
+	mockRepo.On("GetTemplate", mock.Anything, "localized", "1.0.0").Return(tmpl, nil)
+
+	rendered, err := service.RenderTemplate(context.Background(), "localized", "1.0.0", map[string]interface{}{"interval": 100, "locale": "de-CH"})
+	require.NoError(t, err)
+	assert.Equal(t, "de", rendered.Locale)
+	assert.Equal(t, []string{"de"}, rendered.Locales)
+	assert.Contains(t, rendered.RenderedCode, "// Sensor lesen")
+	assert.NotContains(t, rendered.Parameters, "locale")
+
+	rendered, err = service.RenderTemplate(context.Background(), "localized", "1.0.0", map[string]interface{}{"interval": 100, "locale": "fr"})
+	require.NoError(t, err)
+	assert.Equal(t, "", rendered.Locale)
+	assert.Contains(t, rendered.RenderedCode, "// Read the sensor")
+}
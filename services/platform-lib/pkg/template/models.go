@@ -7,18 +7,28 @@ import (
 
 // Template represents an Arduino project template
 type Template struct {
-	ID              string                 `json:"id"`
-	Name            string                 `json:"name"`
-	Version         string                 `json:"version"`
-	Category        string                 `json:"category"`
-	Description     string                 `json:"description"`
-	BoardsSupported []string               `json:"boards_supported"`
-	Schema          map[string]interface{} `json:"schema"`
-	Parameters      map[string]interface{} `json:"parameters"`
-	Libraries       []LibraryDependency    `json:"libraries"`
-	Assets          []Asset                `json:"assets"`
-	CreatedAt       time.Time              `json:"created_at"`
-	UpdatedAt       time.Time              `json:"updated_at"`
+	ID              string                    `json:"id"`
+	Name            string                    `json:"name"`
+	Version         string                    `json:"version"`
+	Category        string                    `json:"category"`
+	Description     string                    `json:"description"`
+	BoardsSupported []string                  `json:"boards_supported"`
+	Schema          map[string]interface{}    `json:"schema"`
+	Parameters      map[string]interface{}    `json:"parameters"`
+	Libraries       []LibraryDependency       `json:"libraries"`
+	CoreVersions    map[string]string         `json:"core_versions,omitempty"` // minimum board core version by core, e.g. "esp32:esp32"
+	Assets          []Asset                   `json:"assets"`
+	Includes        []TemplateInclude         `json:"includes,omitempty"`
+	Drivers         []string                  `json:"drivers,omitempty"`     // driver snippets generated sketches use instead of the category's
+	Telemetry       []TelemetryMetric         `json:"telemetry,omitempty"`   // metrics devices built from the template report
+	OTA             *OTADefaults              `json:"ota,omitempty"`         // OTA settings of devices registered from the template
+	ForkedFrom      string                    `json:"forked_from,omitempty"` // "id@version" of the source template
+	Owner           string                    `json:"owner,omitempty"`       // user notified about the template, e.g. of broken builds
+	Provenance      *Provenance               `json:"provenance,omitempty"`  // set for templates imported from bundles
+	Images          map[string]*TemplateImage `json:"images,omitempty"`      // preview images by role, derived from image assets
+	Environments    []string                  `json:"environments,omitempty"`
+	CreatedAt       time.Time                 `json:"created_at"`
+	UpdatedAt       time.Time                 `json:"updated_at"`
 }
 
 // LibraryDependency represents an Arduino library dependency
@@ -28,9 +38,48 @@ type LibraryDependency struct {
 	URL     string `json:"url,omitempty"`
 }
 
+// TemplateInclude references a sub-template composed into a template. Parameters
+// bind values for the sub-template's parameters (e.g. the pin a sensor uses).
+type TemplateInclude struct {
+	ID         string                 `json:"id"`
+	Version    string                 `json:"version,omitempty"` // defaults to "latest"
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Metric value types of a template's telemetry schema
+const (
+	MetricTypeNumber  = "number"
+	MetricTypeBoolean = "boolean"
+	MetricTypeString  = "string"
+)
+
+// TelemetryMetric describes a metric devices built from a template report.
+// Type is number (the default), boolean or string. DeviceClass and
+// StateClass use the Home Assistant vocabulary, e.g. "temperature" and
+// "measurement".
+type TelemetryMetric struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	DeviceClass string `json:"device_class,omitempty"`
+	StateClass  string `json:"state_class,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+}
+
+// OTADefaults are the OTA settings devices registered from a template
+// start with; each device can change them afterwards. Channel is stable,
+// beta or alpha. Enrollment is auto, joining the deployments of the
+// device's template and channel, or manual, only joining deployments that
+// name the device.
+type OTADefaults struct {
+	Channel    string `json:"channel,omitempty"`
+	Enrollment string `json:"enrollment,omitempty"`
+}
+
 // Asset represents a template asset (wiring diagram, documentation, etc.)
 type Asset struct {
-	Type     string                 `json:"type"` // 'wiring_diagram', 'documentation', 'image'
+	Type     string                 `json:"type"` // 'wiring_diagram', 'documentation', 'image', 'locale'
 	Path     string                 `json:"path"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -46,6 +95,15 @@ type TemplateEntity struct {
 	ParametersJSON  string    `datastore:"parameters_json,noindex"`
 	BoardsSupported []string  `datastore:"boards_supported"`
 	LibrariesJSON   string    `datastore:"libraries_json,noindex"`
+	CoresJSON       string    `datastore:"core_versions_json,noindex"`
+	IncludesJSON    string    `datastore:"includes_json,noindex"`
+	Drivers         []string  `datastore:"drivers,noindex"`
+	TelemetryJSON   string    `datastore:"telemetry_json,noindex"`
+	OTAJSON         string    `datastore:"ota_json,noindex"`
+	ForkedFrom      string    `datastore:"forked_from"`
+	Owner           string    `datastore:"owner"`
+	ProvenanceJSON  string    `datastore:"provenance_json,noindex"`
+	Environments    []string  `datastore:"environments"`
 	CreatedAt       time.Time `datastore:"created_at"`
 	UpdatedAt       time.Time `datastore:"updated_at"`
 }
@@ -65,6 +123,7 @@ type TemplateFilters struct {
 	Category        string   `json:"category,omitempty"`
 	BoardType       string   `json:"board_type,omitempty"`
 	SupportedBoards []string `json:"supported_boards,omitempty"`
+	Environment     string   `json:"environment,omitempty"`
 	Limit           int      `json:"limit,omitempty"`
 	Offset          int      `json:"offset,omitempty"`
 }
@@ -82,6 +141,9 @@ type RenderedTemplate struct {
 	Parameters   map[string]interface{} `json:"parameters"`
 	RenderedCode string                 `json:"rendered_code"`
 	Assets       []Asset                `json:"assets"`
+	Blocks       []string               `json:"blocks,omitempty"`  // override blocks declared by the code
+	Locale       string                 `json:"locale,omitempty"`  // locale pack the code was rendered with
+	Locales      []string               `json:"locales,omitempty"` // locale packs the template provides
 }
 
 // WiringDiagram represents a generated wiring diagram
@@ -136,6 +198,43 @@ func (t *Template) ToEntity() (*TemplateEntity, error) {
 		return nil, err
 	}
 
+	includesJSON, err := json.Marshal(t.Includes)
+	if err != nil {
+		return nil, err
+	}
+
+	var telemetryJSON []byte
+	if len(t.Telemetry) > 0 {
+		telemetryJSON, err = json.Marshal(t.Telemetry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var otaJSON []byte
+	if t.OTA != nil {
+		otaJSON, err = json.Marshal(t.OTA)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var coresJSON []byte
+	if len(t.CoreVersions) > 0 {
+		coresJSON, err = json.Marshal(t.CoreVersions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var provenanceJSON []byte
+	if t.Provenance != nil {
+		provenanceJSON, err = json.Marshal(t.Provenance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &TemplateEntity{
 		ID:              t.ID,
 		Name:            t.Name,
@@ -146,6 +245,15 @@ func (t *Template) ToEntity() (*TemplateEntity, error) {
 		ParametersJSON:  string(parametersJSON),
 		BoardsSupported: t.BoardsSupported,
 		LibrariesJSON:   string(librariesJSON),
+		CoresJSON:       string(coresJSON),
+		IncludesJSON:    string(includesJSON),
+		Drivers:         t.Drivers,
+		TelemetryJSON:   string(telemetryJSON),
+		OTAJSON:         string(otaJSON),
+		ForkedFrom:      t.ForkedFrom,
+		Owner:           t.Owner,
+		ProvenanceJSON:  string(provenanceJSON),
+		Environments:    t.Environments,
 		CreatedAt:       t.CreatedAt,
 		UpdatedAt:       t.UpdatedAt,
 	}, nil
@@ -174,6 +282,41 @@ func (te *TemplateEntity) FromEntity() (*Template, error) {
 		}
 	}
 
+	var includes []TemplateInclude
+	if te.IncludesJSON != "" {
+		if err := json.Unmarshal([]byte(te.IncludesJSON), &includes); err != nil {
+			return nil, err
+		}
+	}
+
+	var telemetry []TelemetryMetric
+	if te.TelemetryJSON != "" {
+		if err := json.Unmarshal([]byte(te.TelemetryJSON), &telemetry); err != nil {
+			return nil, err
+		}
+	}
+
+	var ota *OTADefaults
+	if te.OTAJSON != "" {
+		if err := json.Unmarshal([]byte(te.OTAJSON), &ota); err != nil {
+			return nil, err
+		}
+	}
+
+	var coreVersions map[string]string
+	if te.CoresJSON != "" {
+		if err := json.Unmarshal([]byte(te.CoresJSON), &coreVersions); err != nil {
+			return nil, err
+		}
+	}
+
+	var provenance *Provenance
+	if te.ProvenanceJSON != "" {
+		if err := json.Unmarshal([]byte(te.ProvenanceJSON), &provenance); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Template{
 		ID:              te.ID,
 		Name:            te.Name,
@@ -184,7 +327,16 @@ func (te *TemplateEntity) FromEntity() (*Template, error) {
 		Schema:          schema,
 		Parameters:      parameters,
 		Libraries:       libraries,
+		CoreVersions:    coreVersions,
 		Assets:          []Asset{}, // Assets are loaded separately
+		Includes:        includes,
+		Drivers:         te.Drivers,
+		Telemetry:       telemetry,
+		OTA:             ota,
+		ForkedFrom:      te.ForkedFrom,
+		Owner:           te.Owner,
+		Provenance:      provenance,
+		Environments:    te.Environments,
 		CreatedAt:       te.CreatedAt,
 		UpdatedAt:       te.UpdatedAt,
 	}, nil
@@ -124,6 +124,50 @@ func TestTemplateEntity_FromEntity(t *testing.T) {
 	assert.Empty(t, template.Assets)
 }
 
+func TestTemplateEntity_Telemetry(t *testing.T) {
+	tmpl := createTestTemplate()
+	tmpl.Telemetry = []TelemetryMetric{{Name: "temperature", Unit: "°C", DeviceClass: "temperature"}}
+
+	entity, err := tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.Telemetry, restored.Telemetry)
+}
+
+func TestTemplateEntity_OTADefaults(t *testing.T) {
+	tmpl := createTestTemplate()
+	tmpl.OTA = &OTADefaults{Channel: "beta", Enrollment: "manual"}
+
+	entity, err := tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.OTA, restored.OTA)
+}
+
+func TestTemplateEntity_Drivers(t *testing.T) {
+	tmpl := createTestTemplate()
+	tmpl.Drivers = []string{"dht22", "led-blink"}
+
+	entity, err := tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.Drivers, restored.Drivers)
+}
+
+func TestTemplateEntity_Environments(t *testing.T) {
+	tmpl := createTestTemplate()
+	tmpl.Environments = []string{"dev", "staging"}
+
+	entity, err := tmpl.ToEntity()
+	require.NoError(t, err)
+	restored, err := entity.FromEntity()
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.Environments, restored.Environments)
+}
+
 func TestTemplateEntity_FromEntity_EmptyJSON(t *testing.T) {
 	entity := &TemplateEntity{
 		ID:              "test",
@@ -0,0 +1,636 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// OnboardingParameter is the render parameter holding onboarding options.
+// Like block overrides it is removed before schema validation.
+const OnboardingParameter = "onboarding"
+
+// OnboardingCaptivePortal serves a Wi-Fi setup page from a temporary access
+// point until the device joins the network chosen there
+const OnboardingCaptivePortal = "captive_portal"
+
+// Onboarding defaults
+const (
+	defaultOnboardingBrand   = "ATHENA"
+	defaultOnboardingColor   = "#1f6feb"
+	defaultOnboardingTimeout = 300
+)
+
+var (
+	// ErrOnboardingUnsupported is returned when onboarding cannot be added to
+	// a sketch, e.g. for boards without Wi-Fi
+	ErrOnboardingUnsupported = errors.New("captive portal onboarding is not supported")
+
+	onboardingColorPattern    = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	onboardingDeviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	sketchSetupPattern        = regexp.MustCompile(`\bvoid\s+setup\s*\(\s*(?:void)?\s*\)\s*\{`)
+	sketchLoopPattern         = regexp.MustCompile(`\bvoid\s+loop\s*\(\s*(?:void)?\s*\)\s*\{`)
+	sketchWiFiBeginPattern    = regexp.MustCompile(`\bWiFi\.begin\s*\(`)
+)
+
+// OnboardingOptions configures the self-onboarding flow injected into
+// generated firmware. PortalSSID may contain {chip_id} (the last six hex
+// digits of the MAC) and {device_id}; ReportURL may contain {device_id}.
+type OnboardingOptions struct {
+	Mode           string `json:"mode,omitempty"`
+	PortalSSID     string `json:"portal_ssid,omitempty"`
+	PortalPassword string `json:"portal_password,omitempty"`
+	BrandName      string `json:"brand_name,omitempty"`
+	BrandColor     string `json:"brand_color,omitempty"`
+	LogoURL        string `json:"logo_url,omitempty"`
+	// PortalTimeout is how many seconds the portal runs before retrying a
+	// previously stored network
+	PortalTimeout int `json:"portal_timeout,omitempty"`
+	// ResetPin re-opens the portal when held for five seconds
+	ResetPin *int `json:"reset_pin,omitempty"`
+	// DeviceID defaults to the device_id or deviceId render parameter, then
+	// to an ID derived from the MAC address at runtime
+	DeviceID string `json:"device_id,omitempty"`
+	// ReportURL receives the joined network on first connect; it defaults to
+	// the onboarding.report_url configuration
+	ReportURL string `json:"report_url,omitempty"`
+	// DeviceToken authenticates the report as the device. It defaults to
+	// the device_token or mqtt_password render parameter, as the device's
+	// token is also its MQTT password.
+	DeviceToken string `json:"device_token,omitempty"`
+}
+
+// ExtractOnboardingOptions removes onboarding options from render
+// parameters. The options may be an object or just the mode name.
+func ExtractOnboardingOptions(parameters map[string]interface{}) (map[string]interface{}, *OnboardingOptions, error) {
+	raw, exists := parameters[OnboardingParameter]
+	if !exists {
+		return parameters, nil, nil
+	}
+
+	var opts OnboardingOptions
+	switch value := raw.(type) {
+	case nil:
+	case string:
+		opts.Mode = value
+	case map[string]interface{}, map[string]string:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&opts); err != nil {
+			return nil, nil, fmt.Errorf("'%s' must be an onboarding options object: %w", OnboardingParameter, err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("'%s' must be an object or a mode name", OnboardingParameter)
+	}
+
+	remaining := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		if name != OnboardingParameter {
+			remaining[name] = value
+		}
+	}
+	if raw == nil {
+		return remaining, nil, nil
+	}
+	return remaining, &opts, nil
+}
+
+// ApplyDefaults fills unset options, taking the device ID from the render
+// parameters and the report URL from configuration
+func (o *OnboardingOptions) ApplyDefaults(reportURL string, parameters map[string]interface{}) {
+	if o.Mode == "" {
+		o.Mode = OnboardingCaptivePortal
+	}
+	if o.BrandName == "" {
+		o.BrandName = defaultOnboardingBrand
+	}
+	if o.PortalSSID == "" {
+		o.PortalSSID = o.BrandName + "-Setup-{chip_id}"
+	}
+	if o.BrandColor == "" {
+		o.BrandColor = defaultOnboardingColor
+	}
+	if o.PortalTimeout == 0 {
+		o.PortalTimeout = defaultOnboardingTimeout
+	}
+	if o.DeviceID == "" {
+		for _, name := range []string{"device_id", "deviceId"} {
+			if id, ok := parameters[name].(string); ok && id != "" {
+				o.DeviceID = id
+				break
+			}
+		}
+	}
+	if o.ReportURL == "" {
+		o.ReportURL = reportURL
+	}
+	if o.DeviceToken == "" {
+		for _, name := range []string{"device_token", "deviceToken", "mqtt_password", "mqttPassword"} {
+			if token, ok := parameters[name].(string); ok && token != "" {
+				o.DeviceToken = token
+				break
+			}
+		}
+	}
+}
+
+// Validate checks the options can be embedded in firmware safely
+func (o *OnboardingOptions) Validate() error {
+	if o.Mode != OnboardingCaptivePortal {
+		return fmt.Errorf("unknown onboarding mode '%s'", o.Mode)
+	}
+
+	ssid := strings.NewReplacer("{chip_id}", "XXXXXX", "{device_id}", "").Replace(o.PortalSSID)
+	if ssid == "" || len(ssid) > 32 {
+		return fmt.Errorf("portal_ssid must be 1-32 bytes")
+	}
+	if o.PortalPassword != "" && (len(o.PortalPassword) < 8 || len(o.PortalPassword) > 63) {
+		return fmt.Errorf("portal_password must be empty or 8-63 characters")
+	}
+	if len(o.BrandName) > 64 {
+		return fmt.Errorf("brand_name must be at most 64 characters")
+	}
+	for _, value := range []string{o.PortalSSID, o.PortalPassword, o.BrandName, o.DeviceToken} {
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("onboarding options must not contain control characters")
+		}
+	}
+	if !onboardingColorPattern.MatchString(o.BrandColor) {
+		return fmt.Errorf("brand_color must be a hex color like #1f6feb")
+	}
+	if o.LogoURL != "" {
+		if err := validateOnboardingURL(o.LogoURL); err != nil {
+			return fmt.Errorf("invalid logo_url: %w", err)
+		}
+	}
+	if o.PortalTimeout < 30 || o.PortalTimeout > 3600 {
+		return fmt.Errorf("portal_timeout must be 30-3600 seconds")
+	}
+	if o.ResetPin != nil && (*o.ResetPin < 0 || *o.ResetPin > 39) {
+		return fmt.Errorf("reset_pin must be a GPIO between 0 and 39")
+	}
+	if o.DeviceID != "" && (len(o.DeviceID) > 64 || !onboardingDeviceIDPattern.MatchString(o.DeviceID)) {
+		return fmt.Errorf("device_id may only contain letters, digits, '.', '_' and '-' (at most 64)")
+	}
+	if o.ReportURL == "" {
+		return fmt.Errorf("a report URL is required; set report_url or the onboarding.report_url configuration")
+	}
+	if err := validateOnboardingURL(strings.ReplaceAll(o.ReportURL, "{device_id}", "device")); err != nil {
+		return fmt.Errorf("invalid report_url: %w", err)
+	}
+	if o.DeviceToken == "" {
+		return fmt.Errorf("a device token is required to authenticate the report; set device_token or the device_token parameter")
+	}
+	return nil
+}
+
+func validateOnboardingURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("must be an absolute http or https URL")
+	}
+	if strings.ContainsAny(raw, "\"'<> \\") {
+		return fmt.Errorf("must not contain quotes, angle brackets, spaces or backslashes")
+	}
+	return nil
+}
+
+// InjectOnboarding adds the onboarding flow to a rendered sketch: the portal
+// code is prepended, setup and loop call into it, and the sketch's own
+// WiFi.begin calls are redirected to the onboarded network. Boards are the
+// FQBNs the sketch targets; captive portal onboarding needs an ESP32.
+func InjectOnboarding(sketch string, opts *OnboardingOptions, boards []string) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", fmt.Errorf("invalid onboarding options: %w", err)
+	}
+
+	esp32 := false
+	for _, board := range boards {
+		if strings.HasPrefix(board, "esp32:") {
+			esp32 = true
+			break
+		}
+	}
+	if !esp32 {
+		return "", fmt.Errorf("%w: it requires an ESP32 board", ErrOnboardingUnsupported)
+	}
+	if strings.Contains(sketch, "ATHENA_PROV_SERVICE_UUID") {
+		return "", fmt.Errorf("%w: the sketch already uses BLE provisioning", ErrOnboardingUnsupported)
+	}
+
+	setup := sketchSetupPattern.FindStringIndex(sketch)
+	loop := sketchLoopPattern.FindStringIndex(sketch)
+	if setup == nil || loop == nil {
+		return "", fmt.Errorf("%w: the sketch has no setup() and loop()", ErrOnboardingUnsupported)
+	}
+
+	var portal bytes.Buffer
+	if err := onboardingFirmware.Execute(&portal, onboardingView(opts)); err != nil {
+		return "", fmt.Errorf("failed to render onboarding code: %w", err)
+	}
+
+	// Insert from the end so earlier offsets stay valid
+	type insertion struct {
+		at   int
+		code string
+	}
+	insertions := []insertion{
+		{setup[1], "\n  athenaOnboardingBegin();"},
+		{loop[1], "\n  athenaOnboardingLoop();"},
+	}
+	if insertions[0].at > insertions[1].at {
+		insertions[0], insertions[1] = insertions[1], insertions[0]
+	}
+	body := sketch
+	for i := len(insertions) - 1; i >= 0; i-- {
+		body = body[:insertions[i].at] + insertions[i].code + body[insertions[i].at:]
+	}
+	body = sketchWiFiBeginPattern.ReplaceAllString(body, "athenaOnboardingWiFiBegin(")
+
+	return portal.String() + "\n" + body, nil
+}
+
+// onboardingView prepares options for the firmware template
+func onboardingView(o *OnboardingOptions) map[string]interface{} {
+	resetPin := -1
+	if o.ResetPin != nil {
+		resetPin = *o.ResetPin
+	}
+	return map[string]interface{}{
+		"BrandName":      o.BrandName,
+		"BrandColor":     o.BrandColor,
+		"LogoURL":        o.LogoURL,
+		"PortalSSID":     o.PortalSSID,
+		"PortalPassword": o.PortalPassword,
+		"PortalTimeout":  o.PortalTimeout,
+		"ResetPin":       resetPin,
+		"DeviceID":       o.DeviceID,
+		"ReportURL":      o.ReportURL,
+		"DeviceToken":    o.DeviceToken,
+	}
+}
+
+// cString quotes a value as a C string literal
+func cString(value string) string {
+	return `"` + cEscape(value) + `"`
+}
+
+// cEscape escapes a value for use inside a C string literal. Octal escapes
+// are used for other bytes because hex escapes would swallow following hex
+// digits.
+func cEscape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '?':
+			// Avoid trigraphs
+			b.WriteString(`\?`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var onboardingFirmware = template.Must(template.New("onboarding").Funcs(template.FuncMap{
+	"cstring": cString,
+}).Parse(onboardingFirmwareSource))
+
+// onboardingFirmwareSource is the ESP32 captive portal. Values reach C++
+// through cstring and HTML through html; the color is validated.
+const onboardingFirmwareSource = `// ---- ATHENA captive portal onboarding ----
+#include <WiFi.h>
+#include <WiFiClientSecure.h>
+#include <WebServer.h>
+#include <DNSServer.h>
+#include <Preferences.h>
+#include <HTTPClient.h>
+
+#define ATHENA_ONBOARDING_PORTAL_TIMEOUT ({{.PortalTimeout}} * 1000UL)
+#define ATHENA_ONBOARDING_CONNECT_TIMEOUT 20000UL
+#define ATHENA_ONBOARDING_REPORT_INTERVAL 60000UL
+#define ATHENA_ONBOARDING_RESET_PIN {{.ResetPin}}
+#define ATHENA_ONBOARDING_RESET_HOLD_MS 5000
+
+const char* athenaOnboardingBrand = {{cstring .BrandName}};
+const char* athenaOnboardingPortalSSIDPattern = {{cstring .PortalSSID}};
+const char* athenaOnboardingPortalPassword = {{cstring .PortalPassword}};
+const char* athenaOnboardingReportURL = {{cstring .ReportURL}};
+const char* athenaOnboardingToken = {{cstring .DeviceToken}};
+String athenaOnboardingDeviceId = {{cstring .DeviceID}};
+String athenaOnboardingSSID;
+String athenaOnboardingPassphrase;
+String athenaOnboardingPortalSSID;
+String athenaOnboardingNetworks;
+bool athenaOnboardingReported = true;
+unsigned long athenaOnboardingLastReport = 0;
+unsigned long athenaOnboardingResetPressedAt = 0;
+Preferences athenaOnboardingPrefs;
+
+const char athenaOnboardingPage[] PROGMEM = R"ATHENA(<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1">
+<title>{{html .BrandName}} setup</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Roboto,sans-serif;margin:0;background:#f4f5f7;color:#222}
+header{background:{{.BrandColor}};color:#fff;padding:16px;text-align:center}
+header img{max-height:48px;display:block;margin:0 auto 8px}
+h1{font-size:20px;margin:0}
+main{max-width:420px;margin:16px auto;padding:0 16px}
+label{display:block;margin:12px 0 4px;font-weight:600}
+select,input{width:100%;padding:10px;box-sizing:border-box;border:1px solid #ccc;border-radius:6px;font-size:16px}
+button{width:100%;margin-top:16px;padding:12px;border:0;border-radius:6px;background:{{.BrandColor}};color:#fff;font-size:16px}
+.msg{padding:10px;border-radius:6px;background:#fff3cd}
+</style></head><body>
+<header>{{if .LogoURL}}<img src="{{html .LogoURL}}" alt="">{{end}}<h1>{{html .BrandName}} setup</h1></header>
+<main>
+%MESSAGE%
+<form method="POST" action="/save">
+<label for="ssid">Wi-Fi network</label>
+<select id="ssid" name="ssid">%NETWORKS%</select>
+<label for="other">Or enter a network name</label>
+<input id="other" name="other" maxlength="32" autocomplete="off">
+<label for="passphrase">Password</label>
+<input id="passphrase" name="passphrase" type="password" maxlength="63">
+<button type="submit">Connect</button>
+</form>
+<p><a href="/?scan=1">Scan again</a></p>
+</main></body></html>)ATHENA";
+
+String athenaOnboardingEscape(const String& value) {
+  String escaped = value;
+  escaped.replace("&", "&amp;");
+  escaped.replace("<", "&lt;");
+  escaped.replace(">", "&gt;");
+  escaped.replace("\"", "&quot;");
+  escaped.replace("'", "&#39;");
+  return escaped;
+}
+
+String athenaOnboardingJSON(const String& value) {
+  String escaped;
+  for (unsigned int i = 0; i < value.length(); i++) {
+    char c = value[i];
+    if (c == '"' || c == '\\') {
+      escaped += '\\';
+      escaped += c;
+    } else if ((unsigned char)c < 0x20) {
+      char code[7];
+      snprintf(code, sizeof(code), "\\u%04x", c);
+      escaped += code;
+    } else {
+      escaped += c;
+    }
+  }
+  return escaped;
+}
+
+void athenaOnboardingScan() {
+  int count = WiFi.scanNetworks();
+  athenaOnboardingNetworks = "";
+  for (int i = 0; i < count; i++) {
+    String ssid = WiFi.SSID(i);
+    if (ssid.length() == 0) {
+      continue;
+    }
+    String escaped = athenaOnboardingEscape(ssid);
+    athenaOnboardingNetworks += "<option value=\"" + escaped + "\">" + escaped +
+                                " (" + String(WiFi.RSSI(i)) + " dBm)</option>";
+  }
+  WiFi.scanDelete();
+}
+
+bool athenaOnboardingConnect(const String& ssid, const String& passphrase) {
+  Serial.print("Connecting to Wi-Fi ");
+  Serial.println(ssid);
+  WiFi.begin(ssid.c_str(), passphrase.c_str());
+  unsigned long start = millis();
+  while (WiFi.status() != WL_CONNECTED && millis() - start < ATHENA_ONBOARDING_CONNECT_TIMEOUT) {
+    delay(250);
+  }
+  return WiFi.status() == WL_CONNECTED;
+}
+
+// Sketch calls to WiFi.begin are routed here so they use the onboarded network
+template <typename... Args>
+wl_status_t athenaOnboardingWiFiBegin(Args...) {
+  if (WiFi.status() == WL_CONNECTED) {
+    return WL_CONNECTED;
+  }
+  return WiFi.begin(athenaOnboardingSSID.c_str(), athenaOnboardingPassphrase.c_str());
+}
+
+void athenaOnboardingRunPortal() {
+  String chipId = WiFi.macAddress();
+  chipId.replace(":", "");
+  athenaOnboardingPortalSSID = athenaOnboardingPortalSSIDPattern;
+  athenaOnboardingPortalSSID.replace("{chip_id}", chipId.substring(6));
+  athenaOnboardingPortalSSID.replace("{device_id}", athenaOnboardingDeviceId);
+  if (athenaOnboardingPortalSSID.length() > 32) {
+    athenaOnboardingPortalSSID = athenaOnboardingPortalSSID.substring(0, 32);
+  }
+
+  WiFi.mode(WIFI_AP_STA);
+  WiFi.softAP(athenaOnboardingPortalSSID.c_str(),
+              strlen(athenaOnboardingPortalPassword) > 0 ? athenaOnboardingPortalPassword : NULL);
+  Serial.print("Onboarding portal started on ");
+  Serial.println(athenaOnboardingPortalSSID);
+
+  DNSServer dns;
+  dns.start(53, "*", WiFi.softAPIP());
+  WebServer server(80);
+  String message;
+  bool done = false;
+  athenaOnboardingScan();
+
+  server.on("/", HTTP_GET, [&]() {
+    if (server.hasArg("scan")) {
+      athenaOnboardingScan();
+    }
+    String page = FPSTR(athenaOnboardingPage);
+    page.replace("%NETWORKS%", athenaOnboardingNetworks);
+    page.replace("%MESSAGE%", message.length() > 0 ? "<p class=\"msg\">" + message + "</p>" : "");
+    server.send(200, "text/html", page);
+  });
+
+  server.on("/save", HTTP_POST, [&]() {
+    String ssid = server.arg("other");
+    ssid.trim();
+    if (ssid.length() == 0) {
+      ssid = server.arg("ssid");
+    }
+    String passphrase = server.arg("passphrase");
+    if (ssid.length() == 0 || ssid.length() > 32 ||
+        (passphrase.length() > 0 && (passphrase.length() < 8 || passphrase.length() > 63))) {
+      message = "Choose a network and enter a password of 8 to 63 characters.";
+      server.sendHeader("Location", "/");
+      server.send(303);
+      return;
+    }
+
+    if (!athenaOnboardingConnect(ssid, passphrase)) {
+      WiFi.disconnect();
+      message = "Could not connect to " + athenaOnboardingEscape(ssid) + ". Check the password and try again.";
+      server.sendHeader("Location", "/");
+      server.send(303);
+      return;
+    }
+
+    athenaOnboardingSSID = ssid;
+    athenaOnboardingPassphrase = passphrase;
+    athenaOnboardingReported = false;
+    athenaOnboardingPrefs.putString("ssid", ssid);
+    athenaOnboardingPrefs.putString("passphrase", passphrase);
+    athenaOnboardingPrefs.putBool("reported", false);
+    server.send(200, "text/html",
+                "<!DOCTYPE html><html><head><meta name=\"viewport\" content=\"width=device-width,initial-scale=1\"></head>"
+                "<body style=\"font-family:sans-serif;text-align:center;padding:24px\"><h2>Connected</h2><p>" +
+                athenaOnboardingEscape(athenaOnboardingBrand) + " is now online. You can close this page.</p></body></html>");
+    done = true;
+  });
+
+  // Captive portal detection requests land on the setup page
+  server.onNotFound([&]() {
+    server.sendHeader("Location", "http://" + WiFi.softAPIP().toString() + "/");
+    server.send(302);
+  });
+  server.begin();
+
+  unsigned long start = millis();
+  while (!done) {
+    dns.processNextRequest();
+    server.handleClient();
+    delay(2);
+
+    // A stored network that was down at boot may have come back
+    if (millis() - start > ATHENA_ONBOARDING_PORTAL_TIMEOUT) {
+      if (athenaOnboardingSSID.length() > 0 &&
+          athenaOnboardingConnect(athenaOnboardingSSID, athenaOnboardingPassphrase)) {
+        break;
+      }
+      start = millis();
+    }
+  }
+
+  // Let the confirmation page reach the browser
+  unsigned long finished = millis();
+  while (millis() - finished < 2000) {
+    server.handleClient();
+    delay(2);
+  }
+  server.stop();
+  dns.stop();
+  WiFi.softAPdisconnect(true);
+  WiFi.mode(WIFI_STA);
+  Serial.println("Onboarding portal closed");
+}
+
+// athenaOnboardingReport tells the platform which network the device joined,
+// once, on the first connection after onboarding. The passphrase is never sent.
+void athenaOnboardingReport() {
+  if (athenaOnboardingReported || WiFi.status() != WL_CONNECTED) {
+    return;
+  }
+  if (athenaOnboardingLastReport != 0 && millis() - athenaOnboardingLastReport < ATHENA_ONBOARDING_REPORT_INTERVAL) {
+    return;
+  }
+  athenaOnboardingLastReport = millis();
+
+  String url = athenaOnboardingReportURL;
+  url.replace("{device_id}", athenaOnboardingDeviceId);
+  String body = "{\"method\":\"captive_portal\",\"ssid\":\"" + athenaOnboardingJSON(WiFi.SSID()) +
+                "\",\"bssid\":\"" + WiFi.BSSIDstr() +
+                "\",\"ip\":\"" + WiFi.localIP().toString() +
+                "\",\"rssi\":" + String(WiFi.RSSI()) +
+                ",\"mac\":\"" + WiFi.macAddress() +
+                "\",\"portal_ssid\":\"" + athenaOnboardingJSON(athenaOnboardingPortalSSID) + "\"}";
+
+  HTTPClient http;
+  WiFiClient plainClient;
+  WiFiClientSecure secureClient;
+  if (url.startsWith("https://")) {
+    // Devices have no CA bundle by default, so the server is not verified
+    secureClient.setInsecure();
+    http.begin(secureClient, url);
+  } else {
+    http.begin(plainClient, url);
+  }
+  http.addHeader("Content-Type", "application/json");
+  http.addHeader("Authorization", String("Bearer ") + athenaOnboardingToken);
+  int status = http.POST(body);
+  http.end();
+
+  if (status >= 200 && status < 300) {
+    athenaOnboardingReported = true;
+    athenaOnboardingPrefs.putBool("reported", true);
+    Serial.println("Onboarding reported to platform");
+  } else {
+    Serial.print("Onboarding report failed: ");
+    Serial.println(status);
+  }
+}
+
+void athenaOnboardingBegin() {
+  Serial.begin(115200);
+  athenaOnboardingPrefs.begin("athena-onb", false);
+  WiFi.mode(WIFI_STA);
+  if (athenaOnboardingDeviceId.length() == 0) {
+    athenaOnboardingDeviceId = "esp32-" + WiFi.macAddress();
+    athenaOnboardingDeviceId.replace(":", "");
+    athenaOnboardingDeviceId.toLowerCase();
+  }
+
+#if ATHENA_ONBOARDING_RESET_PIN >= 0
+  pinMode(ATHENA_ONBOARDING_RESET_PIN, INPUT_PULLUP);
+  if (digitalRead(ATHENA_ONBOARDING_RESET_PIN) == LOW) {
+    Serial.println("Reset button held, forgetting Wi-Fi network");
+    athenaOnboardingPrefs.clear();
+  }
+#endif
+
+  athenaOnboardingSSID = athenaOnboardingPrefs.getString("ssid", "");
+  athenaOnboardingPassphrase = athenaOnboardingPrefs.getString("passphrase", "");
+  athenaOnboardingReported = athenaOnboardingPrefs.getBool("reported", true);
+  if (athenaOnboardingSSID.length() == 0 || !athenaOnboardingConnect(athenaOnboardingSSID, athenaOnboardingPassphrase)) {
+    athenaOnboardingRunPortal();
+  }
+  athenaOnboardingReport();
+}
+
+void athenaOnboardingLoop() {
+#if ATHENA_ONBOARDING_RESET_PIN >= 0
+  if (digitalRead(ATHENA_ONBOARDING_RESET_PIN) == LOW) {
+    if (athenaOnboardingResetPressedAt == 0) {
+      athenaOnboardingResetPressedAt = millis();
+    } else if (millis() - athenaOnboardingResetPressedAt > ATHENA_ONBOARDING_RESET_HOLD_MS) {
+      Serial.println("Forgetting Wi-Fi network and restarting");
+      athenaOnboardingPrefs.clear();
+      delay(100);
+      ESP.restart();
+    }
+  } else {
+    athenaOnboardingResetPressedAt = 0;
+  }
+#endif
+  athenaOnboardingReport();
+}
+// ---- end onboarding ----
+`
@@ -0,0 +1,141 @@
+package template
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const onboardingSketch = `#include <WiFi.h>
+
+void setup() {
+  Serial.begin(115200);
+  WiFi.begin("{{.ssid}}", "{{.password}}");
+}
+
+void loop() {
+  delay(1000);
+}
+`
+
+func TestExtractOnboardingOptions(t *testing.T) {
+	params, opts, err := ExtractOnboardingOptions(map[string]interface{}{
+		"delay_ms":   100,
+		"onboarding": map[string]interface{}{"brand_name": "Acme", "reset_pin": 0},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"delay_ms": 100}, params)
+	require.NotNil(t, opts)
+	assert.Equal(t, "Acme", opts.BrandName)
+	require.NotNil(t, opts.ResetPin)
+	assert.Equal(t, 0, *opts.ResetPin)
+
+	_, opts, err = ExtractOnboardingOptions(map[string]interface{}{"onboarding": "captive_portal"})
+	require.NoError(t, err)
+	assert.Equal(t, OnboardingCaptivePortal, opts.Mode)
+
+	params, opts, err = ExtractOnboardingOptions(map[string]interface{}{"delay_ms": 100})
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+	assert.Equal(t, map[string]interface{}{"delay_ms": 100}, params)
+
+	_, _, err = ExtractOnboardingOptions(map[string]interface{}{"onboarding": map[string]interface{}{"ssid": "x"}})
+	assert.Error(t, err, "unknown fields are rejected")
+	_, _, err = ExtractOnboardingOptions(map[string]interface{}{"onboarding": 3})
+	assert.Error(t, err)
+}
+
+func TestOnboardingOptions_ApplyDefaults(t *testing.T) {
+	opts := &OnboardingOptions{BrandName: "Acme"}
+	opts.ApplyDefaults("https://athena.example.com/api/v1/devices/{device_id}/onboarding", map[string]interface{}{"deviceId": "greenhouse-1", "device_token": "device-token"})
+
+	assert.Equal(t, OnboardingCaptivePortal, opts.Mode)
+	assert.Equal(t, "Acme-Setup-{chip_id}", opts.PortalSSID)
+	assert.Equal(t, defaultOnboardingColor, opts.BrandColor)
+	assert.Equal(t, defaultOnboardingTimeout, opts.PortalTimeout)
+	assert.Equal(t, "greenhouse-1", opts.DeviceID)
+	assert.Equal(t, "https://athena.example.com/api/v1/devices/{device_id}/onboarding", opts.ReportURL)
+	assert.Equal(t, "device-token", opts.DeviceToken)
+	assert.NoError(t, opts.Validate())
+}
+
+func TestOnboardingOptions_Validate(t *testing.T) {
+	pin := 40
+	tests := []struct {
+		name   string
+		modify func(*OnboardingOptions)
+	}{
+		{"unknown mode", func(o *OnboardingOptions) { o.Mode = "smartconfig" }},
+		{"long ssid", func(o *OnboardingOptions) { o.PortalSSID = strings.Repeat("x", 33) }},
+		{"short password", func(o *OnboardingOptions) { o.PortalPassword = "secret" }},
+		{"control characters", func(o *OnboardingOptions) { o.BrandName = "Acme\n" }},
+		{"bad color", func(o *OnboardingOptions) { o.BrandColor = "red;}" }},
+		{"script logo", func(o *OnboardingOptions) { o.LogoURL = "javascript:alert(1)" }},
+		{"quoted logo", func(o *OnboardingOptions) { o.LogoURL = `https://example.com/"onload="x` }},
+		{"short timeout", func(o *OnboardingOptions) { o.PortalTimeout = 5 }},
+		{"bad reset pin", func(o *OnboardingOptions) { o.ResetPin = &pin }},
+		{"bad device id", func(o *OnboardingOptions) { o.DeviceID = "a b" }},
+		{"missing report url", func(o *OnboardingOptions) { o.ReportURL = "" }},
+		{"missing device token", func(o *OnboardingOptions) { o.DeviceToken = "" }},
+		{"control characters in token", func(o *OnboardingOptions) { o.DeviceToken = "tok\nen" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &OnboardingOptions{}
+			opts.ApplyDefaults("http://localhost:8000/api/v1/devices/{device_id}/onboarding", map[string]interface{}{"mqtt_password": "device-token"})
+			require.NoError(t, opts.Validate())
+
+			tt.modify(opts)
+			assert.Error(t, opts.Validate())
+		})
+	}
+}
+
+func TestInjectOnboarding(t *testing.T) {
+	renderer := NewTemplateRenderer()
+	sketch, err := renderer.RenderArduinoCode(onboardingSketch, map[string]interface{}{"ssid": "Lab", "password": "labpass1"})
+	require.NoError(t, err)
+
+	opts := &OnboardingOptions{BrandName: `Acme "Labs"`, LogoURL: "https://example.com/logo.png"}
+	opts.ApplyDefaults("http://localhost:8000/api/v1/devices/{device_id}/onboarding", map[string]interface{}{"mqtt_password": "device-token"})
+
+	code, err := InjectOnboarding(sketch, opts, []string{"esp32:esp32:esp32"})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(code, "// ---- ATHENA captive portal onboarding ----"))
+	assert.Contains(t, code, "void setup() {\n  athenaOnboardingBegin();\n  Serial.begin(115200);")
+	assert.Contains(t, code, "void loop() {\n  athenaOnboardingLoop();\n  delay(1000);")
+	assert.Contains(t, code, `athenaOnboardingWiFiBegin("Lab", "labpass1");`)
+	assert.NotContains(t, code, `WiFi.begin("Lab"`)
+	assert.Contains(t, code, `const char* athenaOnboardingBrand = "Acme \"Labs\"";`)
+	assert.Contains(t, code, `<h1>Acme &#34;Labs&#34; setup</h1>`)
+	assert.Contains(t, code, `<img src="https://example.com/logo.png" alt="">`)
+	assert.Contains(t, code, `const char* athenaOnboardingPortalSSIDPattern = "Acme \"Labs\"-Setup-{chip_id}";`)
+	assert.Contains(t, code, "#define ATHENA_ONBOARDING_RESET_PIN -1")
+	assert.Contains(t, code, `const char* athenaOnboardingToken = "device-token";`)
+	assert.Contains(t, code, `http.addHeader("Authorization", String("Bearer ") + athenaOnboardingToken);`)
+}
+
+func TestInjectOnboarding_Unsupported(t *testing.T) {
+	opts := &OnboardingOptions{}
+	opts.ApplyDefaults("http://localhost:8000/api/v1/devices/{device_id}/onboarding", map[string]interface{}{"mqtt_password": "device-token"})
+
+	_, err := InjectOnboarding(onboardingSketch, opts, []string{"arduino:avr:uno"})
+	assert.True(t, errors.Is(err, ErrOnboardingUnsupported))
+
+	_, err = InjectOnboarding("int x = 1;", opts, []string{"esp32:esp32:esp32"})
+	assert.True(t, errors.Is(err, ErrOnboardingUnsupported))
+
+	_, err = InjectOnboarding(onboardingSketch+"\n#define ATHENA_PROV_SERVICE_UUID \"x\"\n", opts, []string{"esp32:esp32:esp32"})
+	assert.True(t, errors.Is(err, ErrOnboardingUnsupported))
+}
+
+func TestCString(t *testing.T) {
+	assert.Equal(t, `"plain"`, cString("plain"))
+	assert.Equal(t, `"a\"b\\c"`, cString(`a"b\c`))
+	assert.Equal(t, `"what\?\?"`, cString("what??"))
+	assert.Equal(t, `"caf\303\2511"`, cString("café1"))
+}
@@ -0,0 +1,101 @@
+package template
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// PreviewRequest describes a render preview, optionally diffed against a
+// previous render kept by the client
+type PreviewRequest struct {
+	Version      string                 `json:"version,omitempty"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Snippets     string                 `json:"snippets,omitempty"`
+	PreviousCode string                 `json:"previous_code,omitempty"`
+}
+
+// PreviewResult holds the fully rendered sketch and its diff against the
+// previous render
+type PreviewResult struct {
+	TemplateID   string                 `json:"template_id"`
+	Version      string                 `json:"version"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	RenderedCode string                 `json:"rendered_code"`
+	Blocks       []string               `json:"blocks,omitempty"`
+	Locale       string                 `json:"locale,omitempty"`
+	Diff         string                 `json:"diff,omitempty"`
+	Changed      bool                   `json:"changed"`
+}
+
+// PreviewRender renders a template without compiling it and, when previous
+// code is supplied, returns a unified diff from the previous render
+func (s *Service) PreviewRender(ctx context.Context, id string, req *PreviewRequest) (*PreviewResult, error) {
+	version := req.Version
+	if version == "" {
+		version = "latest"
+	}
+
+	tmpl, err := s.GetTemplate(ctx, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	rendered, err := s.RenderTemplateWithOverrides(ctx, tmpl.ID, tmpl.Version, req.Parameters, req.Snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PreviewResult{
+		TemplateID:   tmpl.ID,
+		Version:      tmpl.Version,
+		Parameters:   rendered.Parameters,
+		RenderedCode: rendered.RenderedCode,
+		Blocks:       rendered.Blocks,
+		Locale:       rendered.Locale,
+		Changed:      req.PreviousCode != rendered.RenderedCode,
+	}
+
+	if req.PreviousCode != "" && result.Changed {
+		diff, err := UnifiedDiff(req.PreviousCode, rendered.RenderedCode, "previous/"+tmpl.ID+".ino", "rendered/"+tmpl.ID+".ino")
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff rendered code: %w", err)
+		}
+		result.Diff = diff
+	}
+
+	return result, nil
+}
+
+// UnifiedDiff returns a unified diff between two renders with three lines of context
+func UnifiedDiff(previous, current, fromFile, toFile string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(previous),
+		B:        difflib.SplitLines(current),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+func (s *Service) previewTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+
+	var req PreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	result, err := s.PreviewRender(ctx, templateID, &req)
+	if err != nil {
+		s.logger.Error("Failed to preview template", "id", templateID, "error", err)
+		c.JSON(422, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, result)
+}
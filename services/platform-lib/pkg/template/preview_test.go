@@ -0,0 +1,56 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_PreviewRender(t *testing.T) {
+	tmpl := &Template{
+		ID:              "blink",
+		Name:            "Blink",
+		Version:         "1.0.0",
+		Category:        "automation",
+		BoardsSupported: []string{"arduino:avr:uno"},
+		Assets: []Asset{{
+			Type:     "code",
+			Path:     "main.ino",
+			Metadata: map[string]interface{}{"content": "void setup() {\n  pinMode({{.led_pin}}, OUTPUT);\n}\n\nvoid loop() {\n  delay({{.delay_ms}});\n}\n"},
+		}},
+	}
+	service := setupCompositionService(t, tmpl)
+	ctx := context.Background()
+
+	first, err := service.PreviewRender(ctx, "blink", &PreviewRequest{
+		Parameters: map[string]interface{}{"led_pin": 13, "delay_ms": 1000},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", first.Version)
+	assert.Contains(t, first.RenderedCode, "delay(1000);")
+	assert.Empty(t, first.Diff, "no diff without a previous render")
+	assert.True(t, first.Changed)
+
+	second, err := service.PreviewRender(ctx, "blink", &PreviewRequest{
+		Version:      "1.0.0",
+		Parameters:   map[string]interface{}{"led_pin": 13, "delay_ms": 250},
+		PreviousCode: first.RenderedCode,
+	})
+	require.NoError(t, err)
+	assert.True(t, second.Changed)
+	assert.Contains(t, second.Diff, "--- previous/blink.ino")
+	assert.Contains(t, second.Diff, "+++ rendered/blink.ino")
+	assert.Contains(t, second.Diff, "-  delay(1000);")
+	assert.Contains(t, second.Diff, "+  delay(250);")
+	assert.NotContains(t, second.Diff, "-  pinMode(13, OUTPUT);")
+
+	same, err := service.PreviewRender(ctx, "blink", &PreviewRequest{
+		Parameters:   map[string]interface{}{"led_pin": 13, "delay_ms": 250},
+		PreviousCode: second.RenderedCode,
+	})
+	require.NoError(t, err)
+	assert.False(t, same.Changed)
+	assert.Empty(t, same.Diff)
+}
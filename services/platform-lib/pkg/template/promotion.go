@@ -0,0 +1,152 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/athena/platform-lib/pkg/environments"
+	"github.com/gin-gonic/gin"
+)
+
+// TemplatePromotionRequest promotes a template version from one environment
+// to the next
+type TemplatePromotionRequest struct {
+	Version string `json:"version" binding:"required"`
+	environments.PromotionRequest
+}
+
+// Promoter promotes template versions through the environments of a
+// pipeline. A version is promoted with the versions it includes, so a
+// template is never in an environment its sub-templates are not in.
+type Promoter struct {
+	repo           Repository
+	pipeline       *environments.Pipeline
+	versionManager *VersionManager
+}
+
+// NewPromoter creates a promoter of the templates in repo
+func NewPromoter(repo Repository, pipeline *environments.Pipeline) *Promoter {
+	return &Promoter{
+		repo:           repo,
+		pipeline:       pipeline,
+		versionManager: NewVersionManager(),
+	}
+}
+
+// PromoteTemplate promotes version of template id, and the versions it
+// includes, from one environment to the next. Nothing is promoted unless
+// every version is in from. Versions are returned dependencies first.
+func (p *Promoter) PromoteTemplate(ctx context.Context, id, version, from, to string) ([]environments.Resource, error) {
+	if err := p.pipeline.CheckPromotion(from, to); err != nil {
+		return nil, err
+	}
+
+	var plan []*Template
+	if err := p.collect(ctx, id, version, make(map[string]bool), &plan); err != nil {
+		return nil, err
+	}
+	for _, tmpl := range plan {
+		if !p.pipeline.Includes(tmpl.Environments, from) {
+			return nil, fmt.Errorf("%w: template %s@%s is not in %s", environments.ErrInvalidPromotion, tmpl.ID, tmpl.Version, from)
+		}
+	}
+
+	resources := make([]environments.Resource, 0, len(plan))
+	for _, tmpl := range plan {
+		resource := environments.Resource{Kind: environments.KindTemplate, ID: tmpl.ID, Version: tmpl.Version}
+		if !p.pipeline.Includes(tmpl.Environments, to) {
+			// Repositories may hand out the stored template, so it is
+			// copied rather than changed in place
+			promoted := *tmpl
+			promoted.Environments = p.pipeline.Add(tmpl.Environments, to)
+			if err := p.repo.UpdateTemplate(ctx, &promoted); err != nil {
+				return nil, fmt.Errorf("failed to promote template %s@%s: %w", tmpl.ID, tmpl.Version, err)
+			}
+			resource.Promoted = true
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// collect appends version of template id to plan after the versions it
+// includes, skipping versions already seen
+func (p *Promoter) collect(ctx context.Context, id, version string, seen map[string]bool, plan *[]*Template) error {
+	if version == "" || version == "latest" {
+		versions, err := p.repo.GetTemplateVersions(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get template versions: %w", err)
+		}
+		if len(versions) == 0 {
+			return fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+		}
+		if version, err = p.versionManager.GetLatestVersion(versions); err != nil {
+			return fmt.Errorf("failed to determine latest version: %w", err)
+		}
+	}
+
+	key := id + "@" + version
+	if seen[key] {
+		return nil
+	}
+	seen[key] = true
+
+	exists, err := p.repo.TemplateExists(ctx, id, version)
+	if err != nil {
+		return fmt.Errorf("failed to look up template: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s version %s", ErrTemplateNotFound, id, version)
+	}
+	tmpl, err := p.repo.GetTemplate(ctx, id, version)
+	if err != nil {
+		return fmt.Errorf("failed to get template %s@%s: %w", id, version, err)
+	}
+
+	for _, include := range tmpl.Includes {
+		if err := p.collect(ctx, include.ID, include.Version, seen, plan); err != nil {
+			return err
+		}
+	}
+	*plan = append(*plan, tmpl)
+	return nil
+}
+
+// PromoteTemplate promotes a template version, with the versions it
+// includes, from one environment to the next
+func (s *Service) PromoteTemplate(ctx context.Context, id, version, from, to string) (*environments.Promotion, error) {
+	resources, err := NewPromoter(s.repo, s.environments).PromoteTemplate(ctx, id, version, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Promoted template", "id", id, "version", version, "from", from, "to", to, "resources", len(resources))
+	return &environments.Promotion{From: from, To: to, Resources: resources}, nil
+}
+
+func (s *Service) promoteTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+
+	var req TemplatePromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	promotion, err := s.PromoteTemplate(c.Request.Context(), templateID, req.Version, req.From, req.To)
+	switch {
+	case errors.Is(err, environments.ErrUnknownEnvironment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, environments.ErrInvalidPromotion):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		s.logger.Error("Failed to promote template", "id", templateID, "version", req.Version, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote template", "details": err.Error()})
+	default:
+		c.JSON(http.StatusOK, promotion)
+	}
+}
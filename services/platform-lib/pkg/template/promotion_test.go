@@ -0,0 +1,89 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/environments"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_PromoteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sensor := createDHT22Template()
+	newerSensor := createDHT22Template()
+	newerSensor.Version = "1.1.0"
+	station := &Template{
+		ID:              "weather-station",
+		Name:            "Weather Station",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Includes:        []TemplateInclude{{ID: "dht22-sensor"}, {ID: "wifi-mqtt", Version: "1.0.0"}},
+	}
+	service := setupCompositionService(t, sensor, newerSensor, createWifiMQTTTemplate(), station)
+	ctx := context.Background()
+
+	router := gin.New()
+	RegisterRoutes(router, service)
+	promote := func(id, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/templates/"+id+"/promote", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Includes are promoted first, the latest version where none is pinned
+	w := promote("weather-station", `{"version":"1.0.0","from":"dev","to":"staging"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var promotion environments.Promotion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &promotion))
+	assert.Equal(t, []environments.Resource{
+		{Kind: environments.KindTemplate, ID: "dht22-sensor", Version: "1.1.0", Promoted: true},
+		{Kind: environments.KindTemplate, ID: "wifi-mqtt", Version: "1.0.0", Promoted: true},
+		{Kind: environments.KindTemplate, ID: "weather-station", Version: "1.0.0", Promoted: true},
+	}, promotion.Resources)
+
+	staged, err := service.ListTemplates(ctx, &TemplateFilters{Environment: "staging"})
+	require.NoError(t, err)
+	assert.Len(t, staged, 3)
+	promoted, err := service.GetTemplate(ctx, "dht22-sensor", "1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "staging"}, promoted.Environments)
+	assert.Empty(t, sensor.Environments)
+
+	// Promoting again leaves the versions as they are
+	resources, err := NewPromoter(service.repo, service.environments).PromoteTemplate(ctx, "weather-station", "1.0.0", "dev", "staging")
+	require.NoError(t, err)
+	for _, resource := range resources {
+		assert.False(t, resource.Promoted, resource.ID)
+	}
+
+	assert.Equal(t, http.StatusConflict, promote("weather-station", `{"version":"1.0.0","from":"dev","to":"prod"}`).Code)
+	assert.Equal(t, http.StatusConflict, promote("dht22-sensor", `{"version":"1.0.0","from":"staging","to":"prod"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, promote("weather-station", `{"version":"1.0.0","from":"dev","to":"qa"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, promote("weather-station", `{"from":"dev","to":"staging"}`).Code)
+	assert.Equal(t, http.StatusNotFound, promote("missing", `{"version":"1.0.0","from":"dev","to":"staging"}`).Code)
+
+	w = promote("weather-station", `{"version":"1.0.0","from":"staging","to":"prod"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	released, err := service.ListTemplates(ctx, &TemplateFilters{Environment: "prod"})
+	require.NoError(t, err)
+	assert.Len(t, released, 3)
+}
+
+func TestService_CreateTemplate_InitialEnvironment(t *testing.T) {
+	service := setupCompositionService(t)
+
+	tmpl := createDHT22Template()
+	tmpl.Environments = []string{"prod"}
+	require.NoError(t, service.CreateTemplate(context.Background(), tmpl))
+	assert.Equal(t, []string{"dev"}, tmpl.Environments)
+}
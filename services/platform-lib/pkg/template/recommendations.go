@@ -0,0 +1,94 @@
+package template
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParameterRecommendation is a suggested value for a template parameter,
+// drawn from how devices running the template behave in the field
+type ParameterRecommendation struct {
+	Parameter string      `json:"parameter"`
+	Value     interface{} `json:"value"`
+	Reason    string      `json:"reason"`
+	Devices   int         `json:"devices"` // devices the recommendation is based on
+}
+
+// ParameterRecommender suggests parameter values for a template. The
+// telemetry package provides one based on fleet battery drain.
+type ParameterRecommender interface {
+	RecommendParameters(ctx context.Context, tmpl *Template) ([]ParameterRecommendation, error)
+}
+
+// ParameterSchema is a template version's parameter schema with its default
+// values and any recommendations, keyed by parameter
+type ParameterSchema struct {
+	TemplateID      string                             `json:"template_id"`
+	Version         string                             `json:"version"`
+	Schema          map[string]interface{}             `json:"schema"`
+	Defaults        map[string]interface{}             `json:"defaults,omitempty"`
+	Recommendations map[string]ParameterRecommendation `json:"recommendations,omitempty"`
+}
+
+// SetParameterRecommenders sets where parameter schemas get recommended
+// values from
+func (s *Service) SetParameterRecommenders(recommenders ...ParameterRecommender) {
+	s.recommenders = recommenders
+}
+
+// GetParameterSchema returns a template version's parameter schema with
+// recommendations for parameters it declares. Recommendations are advice:
+// a recommender that fails is logged and skipped.
+func (s *Service) GetParameterSchema(ctx context.Context, id, version string) (*ParameterSchema, error) {
+	tmpl, err := s.GetTemplate(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &ParameterSchema{
+		TemplateID: tmpl.ID,
+		Version:    tmpl.Version,
+		Schema:     tmpl.Schema,
+		Defaults:   tmpl.Parameters,
+	}
+
+	properties, _ := tmpl.Schema["properties"].(map[string]interface{})
+	for _, recommender := range s.recommenders {
+		recommendations, err := recommender.RecommendParameters(ctx, tmpl)
+		if err != nil {
+			s.logger.Warn("Failed to recommend template parameters", "id", tmpl.ID, "version", tmpl.Version, "error", err)
+			continue
+		}
+		for _, recommendation := range recommendations {
+			if _, ok := properties[recommendation.Parameter]; !ok {
+				continue
+			}
+			if schema.Recommendations == nil {
+				schema.Recommendations = make(map[string]ParameterRecommendation)
+			}
+			schema.Recommendations[recommendation.Parameter] = recommendation
+		}
+	}
+
+	return schema, nil
+}
+
+func (s *Service) getParameterSchema(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest"
+	}
+
+	schema, err := s.GetParameterSchema(ctx, templateID, version)
+	if err != nil {
+		s.logger.Error("Failed to get parameter schema", "id", templateID, "version", version, "error", err)
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(200, schema)
+}
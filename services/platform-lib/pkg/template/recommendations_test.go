@@ -0,0 +1,54 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRecommender returns fixed recommendations, or an error
+type staticRecommender struct {
+	recommendations []ParameterRecommendation
+	err             error
+}
+
+func (r staticRecommender) RecommendParameters(ctx context.Context, tmpl *Template) ([]ParameterRecommendation, error) {
+	return r.recommendations, r.err
+}
+
+func TestService_GetParameterSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t, createDHT22Template())
+	service.SetParameterRecommenders(
+		staticRecommender{err: errors.New("telemetry unavailable")},
+		staticRecommender{recommendations: []ParameterRecommendation{
+			{Parameter: "dht_pin", Value: 4, Reason: "most devices use pin 4", Devices: 12},
+			{Parameter: "publish_interval", Value: 30000, Reason: "not in this schema", Devices: 12},
+		}},
+	)
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/dht22-sensor/parameters", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var schema ParameterSchema
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	assert.Equal(t, "1.0.0", schema.Version)
+	assert.Equal(t, float64(2), schema.Defaults["dht_pin"])
+	require.Len(t, schema.Recommendations, 1, "only parameters in the schema are recommended")
+	assert.Equal(t, float64(4), schema.Recommendations["dht_pin"].Value)
+	assert.Equal(t, 12, schema.Recommendations["dht_pin"].Devices)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/missing/parameters", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
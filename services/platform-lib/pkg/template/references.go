@@ -0,0 +1,165 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of records that refer to a template version
+const (
+	ReferenceTemplate = "template"
+	ReferenceDevice   = "device"
+	ReferenceRelease  = "release"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTemplateInUse    = errors.New("template version is in use")
+)
+
+// Reference is a record that uses a template version
+type Reference struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// ReferenceFinder finds records outside the template service that use a
+// template version. The device and OTA packages provide finders for
+// devices and firmware releases.
+type ReferenceFinder interface {
+	FindTemplateReferences(ctx context.Context, templateID, version string) ([]Reference, error)
+}
+
+// DeletionReport lists what uses a template version. When a delete is
+// refused it says why; when a delete is forced it lists the records left
+// referring to a version that no longer exists.
+type DeletionReport struct {
+	TemplateID string      `json:"template_id"`
+	Version    string      `json:"version"`
+	Deleted    bool        `json:"deleted"`
+	Forced     bool        `json:"forced,omitempty"`
+	References []Reference `json:"references"`
+}
+
+// Counts returns the number of references of each kind
+func (r *DeletionReport) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, ref := range r.References {
+		counts[ref.Kind]++
+	}
+	return counts
+}
+
+// SetReferenceFinders sets where deletes look for devices, releases and
+// other records that use a template version
+func (s *Service) SetReferenceFinders(finders ...ReferenceFinder) {
+	s.referenceFinders = finders
+}
+
+// FindReferences lists the templates, devices and releases that use a
+// template version. Includes of "latest" follow whichever version is
+// newest and are not references to any one version.
+func (s *Service) FindReferences(ctx context.Context, id, version string) ([]Reference, error) {
+	templates, err := s.repo.ListTemplates(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	references := []Reference{}
+	for _, tmpl := range templates {
+		for _, include := range tmpl.Includes {
+			if include.ID == id && include.Version == version {
+				references = append(references, Reference{Kind: ReferenceTemplate, ID: tmpl.ID + "@" + tmpl.Version})
+				break
+			}
+		}
+	}
+
+	for _, finder := range s.referenceFinders {
+		found, err := finder.FindTemplateReferences(ctx, id, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find template references: %w", err)
+		}
+		references = append(references, found...)
+	}
+
+	sort.SliceStable(references, func(i, j int) bool {
+		if references[i].Kind != references[j].Kind {
+			return references[i].Kind < references[j].Kind
+		}
+		return references[i].ID < references[j].ID
+	})
+	return references, nil
+}
+
+// DeleteTemplateVersion deletes a template version. While templates,
+// devices or releases use it the delete is refused with ErrTemplateInUse,
+// unless force is set; the report lists them either way.
+func (s *Service) DeleteTemplateVersion(ctx context.Context, id, version string, force bool) (*DeletionReport, error) {
+	exists, err := s.repo.TemplateExists(ctx, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check template existence: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s version %s", ErrTemplateNotFound, id, version)
+	}
+
+	references, err := s.FindReferences(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeletionReport{TemplateID: id, Version: version, References: references}
+	if len(references) > 0 {
+		if !force {
+			return report, fmt.Errorf("%w: %s version %s has %d references", ErrTemplateInUse, id, version, len(references))
+		}
+		report.Forced = true
+		s.logger.Warn("Force deleting template version in use", "id", id, "version", version, "references", report.Counts())
+	}
+
+	s.logger.Info("Deleting template", "id", id, "version", version)
+	if err := s.repo.DeleteTemplate(ctx, id, version); err != nil {
+		return report, err
+	}
+	report.Deleted = true
+	return report, nil
+}
+
+func (s *Service) deleteTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		c.JSON(400, gin.H{"error": "version is required"})
+		return
+	}
+	force := false
+	if value := c.Query("force"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid force value: " + value})
+			return
+		}
+		force = parsed
+	}
+
+	report, err := s.DeleteTemplateVersion(ctx, templateID, version, force)
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+	case errors.Is(err, ErrTemplateInUse):
+		c.JSON(409, gin.H{"error": err.Error(), "report": report})
+	case err != nil:
+		s.logger.Error("Failed to delete template", "id", templateID, "version", version, "error", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.JSON(200, report)
+	}
+}
@@ -0,0 +1,102 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticReferences is a ReferenceFinder with fixed results per version
+type staticReferences map[string][]Reference
+
+func (f staticReferences) FindTemplateReferences(ctx context.Context, templateID, version string) ([]Reference, error) {
+	return f[templateID+"@"+version], nil
+}
+
+func TestService_DeleteTemplateVersion(t *testing.T) {
+	ctx := context.Background()
+	dht := createDHT22Template()
+	dhtNext := createDHT22Template()
+	dhtNext.Version = "1.1.0"
+	station := &Template{
+		ID:       "weather-station",
+		Name:     "Weather Station",
+		Version:  "1.0.0",
+		Category: "sensing",
+		Includes: []TemplateInclude{{ID: "dht22-sensor", Version: "1.0.0"}},
+	}
+	service := setupCompositionService(t, dht, dhtNext, station)
+	service.SetReferenceFinders(staticReferences{
+		"dht22-sensor@1.0.0": {{Kind: ReferenceDevice, ID: "device-002"}, {Kind: ReferenceDevice, ID: "device-001"}},
+	})
+
+	report, err := service.DeleteTemplateVersion(ctx, "dht22-sensor", "1.0.0", false)
+	assert.ErrorIs(t, err, ErrTemplateInUse)
+	require.NotNil(t, report)
+	assert.False(t, report.Deleted)
+	assert.Equal(t, []Reference{
+		{Kind: ReferenceDevice, ID: "device-001"},
+		{Kind: ReferenceDevice, ID: "device-002"},
+		{Kind: ReferenceTemplate, ID: "weather-station@1.0.0"},
+	}, report.References)
+	assert.Equal(t, map[string]int{ReferenceDevice: 2, ReferenceTemplate: 1}, report.Counts())
+
+	exists, err := service.repo.TemplateExists(ctx, "dht22-sensor", "1.0.0")
+	require.NoError(t, err)
+	assert.True(t, exists, "a version in use is kept")
+
+	// Unused versions go without force
+	report, err = service.DeleteTemplateVersion(ctx, "dht22-sensor", "1.1.0", false)
+	require.NoError(t, err)
+	assert.True(t, report.Deleted)
+	assert.Empty(t, report.References)
+
+	report, err = service.DeleteTemplateVersion(ctx, "dht22-sensor", "1.0.0", true)
+	require.NoError(t, err)
+	assert.True(t, report.Deleted)
+	assert.True(t, report.Forced)
+	assert.Len(t, report.References, 3)
+
+	_, err = service.DeleteTemplateVersion(ctx, "dht22-sensor", "1.0.0", true)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestService_DeleteTemplateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t, createDHT22Template())
+	service.SetReferenceFinders(staticReferences{
+		"dht22-sensor@1.0.0": {{Kind: ReferenceRelease, ID: "release-001"}},
+	})
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	send := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, target, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send("/api/v1/templates/dht22-sensor").Code)
+	assert.Equal(t, http.StatusNotFound, send("/api/v1/templates/dht22-sensor?version=9.9.9").Code)
+
+	w := send("/api/v1/templates/dht22-sensor?version=1.0.0")
+	require.Equal(t, http.StatusConflict, w.Code)
+	var refused struct {
+		Report DeletionReport `json:"report"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refused))
+	assert.Equal(t, []Reference{{Kind: ReferenceRelease, ID: "release-001"}}, refused.Report.References)
+
+	w = send("/api/v1/templates/dht22-sensor?version=1.0.0&force=true")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report DeletionReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(t, report.Deleted)
+	assert.True(t, report.Forced)
+}
@@ -0,0 +1,377 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/notifications"
+)
+
+// Toolchain holds the latest released versions of board cores, by core
+// (e.g. "esp32:esp32"), and of libraries, by library name
+type Toolchain struct {
+	Cores     map[string]string `json:"cores"`
+	Libraries map[string]string `json:"libraries"`
+}
+
+// ToolchainRequest names the cores and libraries to look up releases for
+type ToolchainRequest struct {
+	Cores     []string `json:"cores"`
+	Libraries []string `json:"libraries"`
+}
+
+// RegressionBuildRequest asks for a rendered template version to be
+// compiled for a board with specific core and library versions
+type RegressionBuildRequest struct {
+	TemplateID      string              `json:"template_id" binding:"required"`
+	TemplateVersion string              `json:"template_version" binding:"required"`
+	Board           string              `json:"board" binding:"required"`
+	Core            string              `json:"core"`
+	CoreVersion     string              `json:"core_version,omitempty"`
+	Libraries       []LibraryDependency `json:"libraries,omitempty"`
+	Code            string              `json:"code" binding:"required"`
+}
+
+// RegressionBuilder compiles templates against released toolchains. Build
+// reports compile failures in the returned record; an error means the
+// build could not be attempted.
+type RegressionBuilder interface {
+	LatestToolchain(ctx context.Context, request *ToolchainRequest) (*Toolchain, error)
+	Build(ctx context.Context, request *RegressionBuildRequest) (*BuildRecord, error)
+}
+
+// HTTPRegressionBuilder runs regression builds on the provisioning service
+type HTTPRegressionBuilder struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPRegressionBuilder creates a builder using the provisioning service
+// at baseURL
+func NewHTTPRegressionBuilder(baseURL string) *HTTPRegressionBuilder {
+	return &HTTPRegressionBuilder{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		// Installing a new core and compiling can take minutes
+		client: &http.Client{Timeout: 15 * time.Minute},
+	}
+}
+
+// LatestToolchain looks up the latest released core and library versions
+func (b *HTTPRegressionBuilder) LatestToolchain(ctx context.Context, request *ToolchainRequest) (*Toolchain, error) {
+	var toolchain Toolchain
+	if err := b.post(ctx, "/api/v1/provisioning/toolchain/latest", request, &toolchain); err != nil {
+		return nil, err
+	}
+	return &toolchain, nil
+}
+
+// Build compiles a template version with the requested toolchain
+func (b *HTTPRegressionBuilder) Build(ctx context.Context, request *RegressionBuildRequest) (*BuildRecord, error) {
+	var record BuildRecord
+	if err := b.post(ctx, "/api/v1/provisioning/regression-builds", request, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (b *HTTPRegressionBuilder) post(ctx context.Context, path string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach provisioning service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provisioning-service returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// RegressionReport summarizes a regression run
+type RegressionReport struct {
+	StartedAt time.Time      `json:"started_at"`
+	Templates int            `json:"templates"`
+	Builds    int            `json:"builds"`
+	Failed    int            `json:"failed"`
+	Skipped   int            `json:"skipped"` // boards already built with the latest toolchain
+	Broken    []*BuildRecord `json:"broken"`  // failed builds of boards whose previous build passed
+}
+
+// RegressionRunner periodically rebuilds the latest version of every
+// template against the newest released board cores and libraries. Each
+// build is recorded, so it shows in the compatibility matrix. When a board
+// whose previous build passed fails to build, the template owner is
+// notified with a template.build_broken event, before users run into it.
+type RegressionRunner struct {
+	service   *Service
+	builder   RegressionBuilder
+	logger    *logger.Logger
+	interval  time.Duration
+	publisher notifications.Publisher
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewRegressionRunner creates a regression runner using the
+// templates.regression_interval setting
+func NewRegressionRunner(service *Service, builder RegressionBuilder, cfg *config.Config) *RegressionRunner {
+	interval := 24 * time.Hour
+	if cfg != nil && cfg.Templates.RegressionInterval > 0 {
+		interval = cfg.Templates.RegressionInterval
+	}
+	return &RegressionRunner{
+		service:  service,
+		builder:  builder,
+		logger:   service.logger,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// SetPublisher sets the publisher used to notify owners of broken builds
+func (r *RegressionRunner) SetPublisher(publisher notifications.Publisher) {
+	r.publisher = publisher
+}
+
+// Start runs regression builds in the background until Stop is called
+func (r *RegressionRunner) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+			report, err := r.Run(ctx)
+			if err != nil {
+				r.logger.Error("Template regression run failed", "error", err)
+				continue
+			}
+			r.logger.Info("Template regression run finished", "templates", report.Templates,
+				"builds", report.Builds, "failed", report.Failed, "broken", len(report.Broken))
+		}
+	}()
+}
+
+// Stop stops background regression runs
+func (r *RegressionRunner) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// Run rebuilds the latest version of every template on each declared board
+// whose latest toolchain it was not built with yet
+func (r *RegressionRunner) Run(ctx context.Context) (*RegressionReport, error) {
+	report := &RegressionReport{StartedAt: time.Now().UTC(), Broken: []*BuildRecord{}}
+
+	templates, err := r.latestTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Templates = len(templates)
+	if len(templates) == 0 {
+		return report, nil
+	}
+
+	toolchain, err := r.builder.LatestToolchain(ctx, toolchainRequest(templates))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up toolchain releases: %w", err)
+	}
+
+	for _, tmpl := range templates {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := r.runTemplate(ctx, tmpl, toolchain, report); err != nil {
+			// One template failing to render does not stop the others
+			r.logger.Error("Regression build failed", "id", tmpl.ID, "version", tmpl.Version, "error", err)
+		}
+	}
+	return report, nil
+}
+
+// latestTemplates returns the latest version of every template
+func (r *RegressionRunner) latestTemplates(ctx context.Context) ([]*Template, error) {
+	all, err := r.service.repo.ListTemplates(ctx, &TemplateFilters{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	latest := make(map[string]*Template)
+	for _, tmpl := range all {
+		if current, ok := latest[tmpl.ID]; !ok || r.service.olderVersion(current.Version, tmpl.Version) {
+			latest[tmpl.ID] = tmpl
+		}
+	}
+	templates := make([]*Template, 0, len(latest))
+	for _, tmpl := range latest {
+		templates = append(templates, tmpl)
+	}
+	slices.SortFunc(templates, func(a, b *Template) int { return strings.Compare(a.ID, b.ID) })
+	return templates, nil
+}
+
+// toolchainRequest collects the cores and libraries templates depend on
+func toolchainRequest(templates []*Template) *ToolchainRequest {
+	request := &ToolchainRequest{Cores: []string{}, Libraries: []string{}}
+	for _, tmpl := range templates {
+		for _, board := range tmpl.BoardsSupported {
+			if core := boardCore(board); !slices.Contains(request.Cores, core) {
+				request.Cores = append(request.Cores, core)
+			}
+		}
+		for _, library := range tmpl.Libraries {
+			if !slices.Contains(request.Libraries, library.Name) {
+				request.Libraries = append(request.Libraries, library.Name)
+			}
+		}
+	}
+	slices.Sort(request.Cores)
+	slices.Sort(request.Libraries)
+	return request
+}
+
+// runTemplate builds a template on each of its boards. Libraries without a
+// known release keep the version the template declares.
+func (r *RegressionRunner) runTemplate(ctx context.Context, tmpl *Template, toolchain *Toolchain, report *RegressionReport) error {
+	libraries := make([]LibraryDependency, len(tmpl.Libraries))
+	for i, library := range tmpl.Libraries {
+		libraries[i] = library
+		if latest := toolchain.Libraries[library.Name]; latest != "" {
+			libraries[i].Version = latest
+		}
+	}
+
+	records, err := r.service.builds.ListBuildRecords(ctx, tmpl.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list builds: %w", err)
+	}
+
+	var code string
+	for _, board := range tmpl.BoardsSupported {
+		core := boardCore(board)
+		coreVersion := toolchain.Cores[core]
+
+		previous := lastBuild(records, tmpl.Version, board)
+		if previous != nil && previous.CoreVersion == coreVersion && sameLibraries(previous.Libraries, libraries) {
+			report.Skipped++
+			continue
+		}
+
+		if code == "" {
+			rendered, err := r.service.RenderTemplate(ctx, tmpl.ID, tmpl.Version, nil)
+			if err != nil {
+				return fmt.Errorf("failed to render template: %w", err)
+			}
+			code = rendered.RenderedCode
+		}
+
+		record, err := r.builder.Build(ctx, &RegressionBuildRequest{
+			TemplateID:      tmpl.ID,
+			TemplateVersion: tmpl.Version,
+			Board:           board,
+			Core:            core,
+			CoreVersion:     coreVersion,
+			Libraries:       libraries,
+			Code:            code,
+		})
+		if err != nil {
+			r.logger.Error("Regression build could not run", "id", tmpl.ID, "board", board, "error", err)
+			continue
+		}
+		record.TemplateVersion, record.Board = tmpl.Version, board
+		record.Source = BuildSourceRegression
+		if !record.Success && record.Error == "" {
+			record.Error = "build failed"
+		}
+		if err := r.service.RecordBuild(ctx, tmpl.ID, record); err != nil {
+			return err
+		}
+
+		report.Builds++
+		if record.Success {
+			continue
+		}
+		report.Failed++
+		if previous != nil && previous.Success {
+			report.Broken = append(report.Broken, record)
+			r.notifyBroken(tmpl, record, previous)
+		}
+	}
+	return nil
+}
+
+// lastBuild returns the most recent build of a template version for a
+// board, leaving out user compiles
+func lastBuild(records []*BuildRecord, version, board string) *BuildRecord {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].TemplateVersion == version && records[i].Board == board && records[i].Source != BuildSourceCompile {
+			return records[i]
+		}
+	}
+	return nil
+}
+
+// sameLibraries reports whether two builds used the same library versions
+func sameLibraries(a, b []LibraryDependency) bool {
+	versions := func(libraries []LibraryDependency) []string {
+		list := make([]string, len(libraries))
+		for i, library := range libraries {
+			list[i] = library.Name + "@" + library.Version
+		}
+		slices.Sort(list)
+		return list
+	}
+	return slices.Equal(versions(a), versions(b))
+}
+
+func (r *RegressionRunner) notifyBroken(tmpl *Template, record, previous *BuildRecord) {
+	notifications.PublishAsync(r.publisher, r.logger, &notifications.Event{
+		Type:         notifications.EventTemplateBuildBroken,
+		ResourceType: "template",
+		ResourceID:   tmpl.ID,
+		Owner:        tmpl.Owner,
+		Message: fmt.Sprintf("Template %s %s no longer builds for %s with core %s",
+			tmpl.ID, tmpl.Version, record.Board, record.CoreVersion),
+		Data: map[string]interface{}{
+			"version":            tmpl.Version,
+			"board":              record.Board,
+			"core_version":       record.CoreVersion,
+			"libraries":          record.Libraries,
+			"error":              record.Error,
+			"passed_core":        previous.CoreVersion,
+			"passed_libraries":   previous.Libraries,
+			"last_successful_at": previous.BuiltAt,
+		},
+	})
+}
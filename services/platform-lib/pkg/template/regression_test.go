@@ -0,0 +1,151 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// channelPublisher hands published events to a channel
+type channelPublisher chan *notifications.Event
+
+func (p channelPublisher) Publish(ctx context.Context, event *notifications.Event) error {
+	p <- event
+	return nil
+}
+
+// fakeRegressionBuilder fails builds on cores listed in broken
+type fakeRegressionBuilder struct {
+	toolchain *Toolchain
+	broken    map[string]bool // "core@version"
+	requests  []*RegressionBuildRequest
+}
+
+func (b *fakeRegressionBuilder) LatestToolchain(ctx context.Context, request *ToolchainRequest) (*Toolchain, error) {
+	return b.toolchain, nil
+}
+
+func (b *fakeRegressionBuilder) Build(ctx context.Context, request *RegressionBuildRequest) (*BuildRecord, error) {
+	b.requests = append(b.requests, request)
+	record := &BuildRecord{
+		TemplateVersion: request.TemplateVersion,
+		Board:           request.Board,
+		CoreVersion:     request.CoreVersion,
+		Libraries:       request.Libraries,
+		Success:         true,
+	}
+	if b.broken[request.Core+"@"+request.CoreVersion] {
+		record.Success = false
+		record.Error = "'ledcSetup' was not declared in this scope"
+	}
+	return record, nil
+}
+
+func createRegressionTemplates() []*Template {
+	templates := createCompatibilityTemplates()
+	for _, tmpl := range templates {
+		tmpl.Owner = "user-1"
+	}
+	return templates
+}
+
+func TestRegressionRunner_Run(t *testing.T) {
+	service := setupCompositionService(t, createRegressionTemplates()...)
+	ctx := context.Background()
+	builder := &fakeRegressionBuilder{
+		toolchain: &Toolchain{
+			Cores:     map[string]string{"arduino:avr": "1.8.6", "esp32:esp32": "2.0.17"},
+			Libraries: map[string]string{"DHT sensor library": "1.4.6"},
+		},
+		broken: map[string]bool{},
+	}
+	events := make(channelPublisher, 4)
+	runner := NewRegressionRunner(service, builder, nil)
+	runner.SetPublisher(events)
+
+	// Only the latest version is built, on each declared board
+	report, err := runner.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Templates)
+	assert.Equal(t, 2, report.Builds)
+	assert.Equal(t, 0, report.Failed)
+	require.Len(t, builder.requests, 2)
+	assert.Equal(t, "1.1.0", builder.requests[0].TemplateVersion)
+	assert.Equal(t, "esp32:esp32", builder.requests[1].Core)
+	assert.NotEmpty(t, builder.requests[0].Code)
+
+	// Nothing new was released, so nothing is rebuilt
+	report, err = runner.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Builds)
+	assert.Equal(t, 2, report.Skipped)
+
+	// A new esp32 core breaks the template
+	builder.toolchain.Cores["esp32:esp32"] = "3.0.0"
+	builder.broken["esp32:esp32@3.0.0"] = true
+	report, err = runner.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Builds)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Broken, 1)
+	assert.Equal(t, "esp32:esp32:esp32dev", report.Broken[0].Board)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, notifications.EventTemplateBuildBroken, event.Type)
+		assert.Equal(t, "weather", event.ResourceID)
+		assert.Equal(t, "user-1", event.Owner)
+		assert.Equal(t, "3.0.0", event.Data["core_version"])
+		assert.Equal(t, "2.0.17", event.Data["passed_core"])
+	case <-time.After(time.Second):
+		t.Fatal("expected a template.build_broken notification")
+	}
+
+	matrix, err := service.GetCompatibilityMatrix(ctx, "weather")
+	require.NoError(t, err)
+	assert.Equal(t, CompatibilityVerified, matrix.Versions[0].Boards[0].Status)
+	assert.Equal(t, CompatibilityFailing, matrix.Versions[0].Boards[1].Status)
+
+	// A new library release rebuilds every board; the still failing board
+	// is not reported as newly broken
+	builder.toolchain.Libraries["DHT sensor library"] = "1.4.7"
+	report, err = runner.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Builds)
+	assert.Equal(t, 1, report.Failed)
+	assert.Empty(t, report.Broken)
+	assert.Equal(t, "1.4.7", builder.requests[len(builder.requests)-1].Libraries[0].Version)
+}
+
+func TestHTTPRegressionBuilder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/provisioning/toolchain/latest":
+			var request ToolchainRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, []string{"esp32:esp32"}, request.Cores)
+			json.NewEncoder(w).Encode(Toolchain{Cores: map[string]string{"esp32:esp32": "3.0.0"}})
+		case "/api/v1/provisioning/regression-builds":
+			http.Error(w, "arduino-cli not available", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	builder := NewHTTPRegressionBuilder(server.URL + "/")
+	toolchain, err := builder.LatestToolchain(context.Background(), &ToolchainRequest{Cores: []string{"esp32:esp32"}})
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.0", toolchain.Cores["esp32:esp32"])
+
+	_, err = builder.Build(context.Background(), &RegressionBuildRequest{TemplateID: "weather", Board: "esp32:esp32:esp32dev"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 500")
+}
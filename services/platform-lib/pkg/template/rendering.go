@@ -6,59 +6,76 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+
+	"github.com/athena/platform-lib/pkg/topics"
 )
 
 // TemplateRenderer handles Arduino code template rendering
 type TemplateRenderer struct {
 	funcMap template.FuncMap
+	topics  *topics.Namespace
 }
 
 // NewTemplateRenderer creates a new template renderer with custom functions
 func NewTemplateRenderer() *TemplateRenderer {
-	return &TemplateRenderer{
-		funcMap: template.FuncMap{
-			"upper":       strings.ToUpper,
-			"lower":       strings.ToLower,
-			"title":       strings.Title,
-			"replace":     strings.ReplaceAll,
-			"contains":    strings.Contains,
-			"hasPrefix":   strings.HasPrefix,
-			"hasSuffix":   strings.HasSuffix,
-			"join":        strings.Join,
-			"split":       strings.Split,
-			"trim":        strings.TrimSpace,
-			"add":         add,
-			"sub":         sub,
-			"mul":         mul,
-			"div":         div,
-			"mod":         mod,
-			"eq":          eq,
-			"ne":          ne,
-			"lt":          lt,
-			"le":          le,
-			"gt":          gt,
-			"ge":          ge,
-			"and":         and,
-			"or":          or,
-			"not":         not,
-			"default":     defaultValue,
-			"pinType":     getPinType,
-			"analogPin":   isAnalogPin,
-			"digitalPin":  isDigitalPin,
-			"pwmPin":      isPWMPin,
-			"formatPin":   formatPin,
-			"generateID":  generateID,
-			"indent":      indent,
-			"comment":     comment,
-			"defineConst": defineConstant,
-		},
+	namespace, _ := topics.New("", "")
+	tr := &TemplateRenderer{topics: namespace}
+	tr.funcMap = template.FuncMap{
+		"upper":       strings.ToUpper,
+		"lower":       strings.ToLower,
+		"title":       strings.Title,
+		"replace":     strings.ReplaceAll,
+		"contains":    strings.Contains,
+		"hasPrefix":   strings.HasPrefix,
+		"hasSuffix":   strings.HasSuffix,
+		"join":        strings.Join,
+		"split":       strings.Split,
+		"trim":        strings.TrimSpace,
+		"add":         add,
+		"sub":         sub,
+		"mul":         mul,
+		"div":         div,
+		"mod":         mod,
+		"eq":          eq,
+		"ne":          ne,
+		"lt":          lt,
+		"le":          le,
+		"gt":          gt,
+		"ge":          ge,
+		"and":         and,
+		"or":          or,
+		"not":         not,
+		"default":     defaultValue,
+		"pinType":     getPinType,
+		"analogPin":   isAnalogPin,
+		"digitalPin":  isDigitalPin,
+		"pwmPin":      isPWMPin,
+		"formatPin":   formatPin,
+		"generateID":  generateID,
+		"indent":      indent,
+		"comment":     comment,
+		"defineConst": defineConstant,
+		"mqttTopic":   tr.mqttTopic,
+		"t":           Messages(nil).Translate,
 	}
+	return tr
+}
+
+// SetTopicNamespace sets the MQTT topic namespace used by mqttTopic
+func (tr *TemplateRenderer) SetTopicNamespace(namespace *topics.Namespace) {
+	tr.topics = namespace
+}
+
+// mqttTopic renders the topic a device publishes kind messages on, so
+// generated firmware matches the platform's topic namespace
+func (tr *TemplateRenderer) mqttTopic(deviceID interface{}, kind string) (string, error) {
+	return tr.topics.Topic(fmt.Sprintf("%v", deviceID), kind)
 }
 
 // RenderArduinoCode renders Arduino code from a template with parameters
 func (tr *TemplateRenderer) RenderArduinoCode(templateCode string, parameters map[string]interface{}) (string, error) {
 	// Create a new template with custom functions
-	tmpl, err := template.New("arduino").Funcs(tr.funcMap).Parse(templateCode)
+	tmpl, err := template.New("arduino").Funcs(tr.funcMap).Parse(NormalizeBlocks(templateCode))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -75,23 +92,42 @@ func (tr *TemplateRenderer) RenderArduinoCode(templateCode string, parameters ma
 
 // RenderTemplateWithIncludes renders a template with support for includes and partials
 func (tr *TemplateRenderer) RenderTemplateWithIncludes(mainTemplate string, includes map[string]string, parameters map[string]interface{}) (string, error) {
+	return tr.RenderWithOverrides(mainTemplate, includes, parameters, nil, "")
+}
+
+// RenderWithOverrides renders a template with includes, filling its override
+// blocks from explicit overrides and a supplemental snippet file
+func (tr *TemplateRenderer) RenderWithOverrides(mainTemplate string, includes map[string]string, parameters map[string]interface{}, overrides BlockOverrides, snippets string) (string, error) {
+	return tr.RenderLocalized(mainTemplate, includes, parameters, overrides, snippets, nil)
+}
+
+// RenderLocalized renders like RenderWithOverrides, translating text marked
+// with {{t "key" "default text"}} using the given locale messages
+func (tr *TemplateRenderer) RenderLocalized(mainTemplate string, includes map[string]string, parameters map[string]interface{}, overrides BlockOverrides, snippets string, messages Messages) (string, error) {
 	// Create the main template
-	tmpl := template.New("main").Funcs(tr.funcMap)
+	tmpl := template.New("main").Funcs(tr.funcMap).Funcs(template.FuncMap{
+		"t": messages.Translate,
+	})
 
 	// Parse all includes first
 	for name, content := range includes {
-		_, err := tmpl.New(name).Parse(content)
+		_, err := tmpl.New(name).Parse(NormalizeBlocks(content))
 		if err != nil {
 			return "", fmt.Errorf("failed to parse include template %s: %w", name, err)
 		}
 	}
 
 	// Parse the main template
-	_, err := tmpl.Parse(mainTemplate)
+	_, err := tmpl.Parse(NormalizeBlocks(mainTemplate))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse main template: %w", err)
 	}
 
+	// Overrides are parsed last so they replace the block defaults
+	if err := ApplyBlockOverrides(tmpl, declaredBlocks(mainTemplate, includes), overrides, snippets); err != nil {
+		return "", err
+	}
+
 	// Render the template
 	var buf bytes.Buffer
 	err = tmpl.Execute(&buf, parameters)
@@ -0,0 +1,29 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/athena/platform-lib/pkg/topics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderArduinoCode_MQTTTopic(t *testing.T) {
+	renderer := NewTemplateRenderer()
+	sketch := `const char* topic = "{{mqttTopic .deviceId "data"}}";`
+
+	code, err := renderer.RenderArduinoCode(sketch, map[string]interface{}{"deviceId": "dev-1"})
+	require.NoError(t, err)
+	assert.Equal(t, `const char* topic = "telemetry/dev-1/data";`, code)
+
+	namespace, err := topics.New("{tenant}/devices/{device_id}/{kind}", "acme")
+	require.NoError(t, err)
+	renderer.SetTopicNamespace(namespace)
+
+	code, err = renderer.RenderArduinoCode(sketch, map[string]interface{}{"deviceId": "dev-1"})
+	require.NoError(t, err)
+	assert.Equal(t, `const char* topic = "acme/devices/dev-1/data";`, code)
+
+	_, err = renderer.RenderArduinoCode(sketch, map[string]interface{}{"deviceId": "dev/+"})
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -331,6 +332,11 @@ func (r *MemoryRepository) matchesFilters(template *Template, filters *TemplateF
 		return false
 	}
 
+	// Filter by environment
+	if filters.Environment != "" && !slices.Contains(template.Environments, filters.Environment) {
+		return false
+	}
+
 	// Filter by board type
 	if filters.BoardType != "" {
 		boardSupported := false
@@ -0,0 +1,239 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxParameterLength bounds string parameter values; nothing a sketch is
+// configured with comes close
+const maxParameterLength = 4096
+
+// ErrUnsafeParameter is returned when a parameter value cannot be encoded
+// safely into generated source
+var ErrUnsafeParameter = errors.New("unsafe parameter value")
+
+// ParameterFinding describes a parameter value that looks like an attempt to
+// inject code into the rendered sketch
+type ParameterFinding struct {
+	Parameter string `json:"parameter"`
+	Reason    string `json:"reason"`
+}
+
+func (f ParameterFinding) String() string {
+	return fmt.Sprintf("parameter %s %s", f.Parameter, f.Reason)
+}
+
+var suspiciousPatterns = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`(^|[\s;{}])#\s*(include|define|undef|pragma|if|ifdef|ifndef|elif|else|endif|error|line)\b`), "contains a preprocessor directive"},
+	{regexp.MustCompile(`/\*|\*/`), "contains a comment delimiter"},
+	{regexp.MustCompile(`"\s*[;,)}]`), "closes a string literal"},
+	{regexp.MustCompile(`\?\?[=/'()!<>-]`), "contains a trigraph"},
+}
+
+// ScanParameters reports string parameter values, including those nested
+// in arrays and objects, that look like source code rather than data.
+// Findings are ordered by parameter name.
+func ScanParameters(parameters map[string]interface{}) []ParameterFinding {
+	var findings []ParameterFinding
+	for _, name := range sortedKeys(parameters) {
+		findings = append(findings, scanValue(name, parameters[name])...)
+	}
+	return findings
+}
+
+func scanValue(path string, value interface{}) []ParameterFinding {
+	switch v := value.(type) {
+	case string:
+		return scanString(path, v)
+	case []string:
+		var findings []ParameterFinding
+		for i, item := range v {
+			findings = append(findings, scanString(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return findings
+	case []interface{}:
+		var findings []ParameterFinding
+		for i, item := range v {
+			findings = append(findings, scanValue(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return findings
+	case map[string]interface{}:
+		var findings []ParameterFinding
+		for _, key := range sortedKeys(v) {
+			findings = append(findings, scanValue(path+"."+key, v[key])...)
+		}
+		return findings
+	default:
+		return nil
+	}
+}
+
+func scanString(path, value string) []ParameterFinding {
+	if len(value) > maxParameterLength {
+		return []ParameterFinding{{Parameter: path, Reason: fmt.Sprintf("is longer than %d characters", maxParameterLength)}}
+	}
+	// Line breaks end comments and preprocessor lines, so no value may
+	// contain them; tabs are harmless
+	for _, r := range value {
+		if (r < 0x20 && r != '\t') || r == 0x7f {
+			return []ParameterFinding{{Parameter: path, Reason: "contains control characters"}}
+		}
+	}
+	for _, suspicious := range suspiciousPatterns {
+		if suspicious.pattern.MatchString(value) {
+			return []ParameterFinding{{Parameter: path, Reason: suspicious.reason}}
+		}
+	}
+	return nil
+}
+
+// EncodeParameters returns a copy of parameters that is safe to interpolate
+// into C++ source. Types come from the template's JSON Schema: integer and
+// number parameters must hold numbers and are rendered in C syntax,
+// booleans must be true or false, and strings are escaped for use inside a
+// string literal, so templates keep writing "{{.ssid}}". Values without a
+// schema type are encoded by their Go type.
+func EncodeParameters(schema map[string]interface{}, parameters map[string]interface{}) (map[string]interface{}, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	encoded := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		property, _ := properties[name].(map[string]interface{})
+		v, err := encodeValue(name, property, value)
+		if err != nil {
+			return nil, err
+		}
+		encoded[name] = v
+	}
+	return encoded, nil
+}
+
+// EscapeString escapes a value for use inside a C++ string literal, as
+// EncodeParameters does for string parameters
+func EscapeString(value string) string {
+	return cEscape(value)
+}
+
+func encodeValue(path string, schema map[string]interface{}, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	schemaType, _ := schema["type"].(string)
+	switch schemaType {
+	case "integer":
+		n, ok := toNumber(value)
+		if !ok || n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+			return nil, fmt.Errorf("%w: %s must be an integer", ErrUnsafeParameter, path)
+		}
+		return int64(n), nil
+	case "number":
+		n, ok := toNumber(value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s must be a number", ErrUnsafeParameter, path)
+		}
+		return n, nil
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if v == "true" || v == "false" {
+				return v == "true", nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s must be true or false", ErrUnsafeParameter, path)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return cEscape(v), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, nil
+	case float32, float64, json.Number:
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s must be a finite number", ErrUnsafeParameter, path)
+		}
+		return n, nil
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = cEscape(item)
+		}
+		return items, nil
+	case []interface{}:
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded, err := encodeValue(fmt.Sprintf("%s[%d]", path, i), itemSchema, item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = encoded
+		}
+		return items, nil
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		fields := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			property, _ := properties[key].(map[string]interface{})
+			encoded, err := encodeValue(path+"."+key, property, field)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = encoded
+		}
+		return fields, nil
+	default:
+		return cEscape(fmt.Sprint(v)), nil
+	}
+}
+
+// toNumber converts a numeric value, or a string holding one, to a finite
+// float64
+func toNumber(value interface{}) (float64, bool) {
+	var n float64
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		n = f
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		n = f
+	default:
+		f, err := toFloat64(v)
+		if err != nil {
+			return 0, false
+		}
+		n = f
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
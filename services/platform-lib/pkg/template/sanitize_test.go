@@ -0,0 +1,157 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const credentialsSketch = `#define DHTPIN {{.dhtPin}}
+const char* ssid = "{{.ssid}}";
+const char* password = "{{.wifi_password}}";
+const float calibration = {{.calibration}};
+const bool verbose = {{.verbose}};
+{{range .sensors}}// sensor "{{.name}}" on pin {{.pin}}
+{{end}}`
+
+var credentialsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"dhtPin":        map[string]interface{}{"type": "integer"},
+		"ssid":          map[string]interface{}{"type": "string"},
+		"wifi_password": map[string]interface{}{"type": "string"},
+		"calibration":   map[string]interface{}{"type": "number"},
+		"verbose":       map[string]interface{}{"type": "boolean"},
+		"sensors": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"pin":  map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	},
+}
+
+func TestEncodeParameters(t *testing.T) {
+	var parameters map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"dhtPin": 1000000,
+		"ssid": "Café \"Corner\"",
+		"wifi_password": "p@ss\\word??",
+		"calibration": "0.25",
+		"verbose": "true",
+		"sensors": [{"name": "attic", "pin": 4.0}]
+	}`), &parameters))
+
+	encoded, err := EncodeParameters(credentialsSchema, parameters)
+	require.NoError(t, err)
+	rendered, err := NewTemplateRenderer().RenderArduinoCode(credentialsSketch, encoded)
+	require.NoError(t, err)
+
+	assert.Equal(t, `#define DHTPIN 1000000
+const char* ssid = "Caf\303\251 \"Corner\"";
+const char* password = "p@ss\\word\?\?";
+const float calibration = 0.25;
+const bool verbose = true;
+// sensor "attic" on pin 4
+`, rendered)
+	// The caller's parameters are left as they were
+	assert.Equal(t, "p@ss\\word??", parameters["wifi_password"])
+}
+
+func TestEncodeParameters_NeutralisesLiteralBreakout(t *testing.T) {
+	encoded, err := EncodeParameters(credentialsSchema, map[string]interface{}{
+		"dhtPin":        2,
+		"ssid":          "home",
+		"wifi_password": "x\"; system(\"reboot\"); //",
+		"calibration":   1,
+		"verbose":       false,
+	})
+	require.NoError(t, err)
+	rendered, err := NewTemplateRenderer().RenderArduinoCode(credentialsSketch, encoded)
+	require.NoError(t, err)
+	assert.Contains(t, rendered, `const char* password = "x\"; system(\"reboot\"); //";`)
+}
+
+func TestEncodeParameters_RejectsMistypedValues(t *testing.T) {
+	for name, parameters := range map[string]map[string]interface{}{
+		"code in integer":    {"dhtPin": "2); digitalWrite(13, HIGH"},
+		"fractional integer": {"dhtPin": 2.5},
+		"code in number":     {"calibration": "1; while(1)"},
+		"code in boolean":    {"verbose": "true; reboot()"},
+		"nested integer":     {"sensors": []interface{}{map[string]interface{}{"pin": "A0)"}}},
+	} {
+		_, err := EncodeParameters(credentialsSchema, parameters)
+		assert.ErrorIs(t, err, ErrUnsafeParameter, name)
+	}
+}
+
+func TestScanParameters(t *testing.T) {
+	assert.Empty(t, ScanParameters(map[string]interface{}{
+		"ssid":       "Café \"Corner\"",
+		"password":   "tab\tand #hash; {braces}",
+		"webhookUrl": "https://hooks.example.com/a?b=c",
+		"sensors":    []interface{}{map[string]interface{}{"name": "attic", "pin": 4}},
+		"interval":   5000,
+	}))
+
+	findings := ScanParameters(map[string]interface{}{
+		"wifi_password": "x\";\n#include <evil.h>\n//",
+		"location":      "hall */ evil(); /*",
+		"ssid":          `home"); reboot(); ("`,
+		"device":        "#define LED 13",
+		"sensors":       []interface{}{map[string]interface{}{"name": "a??/"}},
+	})
+	assert.Equal(t, []ParameterFinding{
+		{Parameter: "device", Reason: "contains a preprocessor directive"},
+		{Parameter: "location", Reason: "contains a comment delimiter"},
+		{Parameter: "sensors[0].name", Reason: "contains a trigraph"},
+		{Parameter: "ssid", Reason: "closes a string literal"},
+		{Parameter: "wifi_password", Reason: "contains control characters"},
+	}, findings)
+}
+
+func TestService_RenderTemplate_RejectsInjectedParameters(t *testing.T) {
+	tmpl := &Template{
+		ID:              "wifi",
+		Name:            "WiFi",
+		Version:         "1.0.0",
+		Category:        "connectivity",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Schema:          credentialsSchema,
+		Assets: []Asset{{
+			Type:     "code",
+			Path:     "main.ino",
+			Metadata: map[string]interface{}{"content": credentialsSketch},
+		}},
+	}
+	service := setupCompositionService(t, tmpl)
+	ctx := context.Background()
+
+	_, err := service.RenderTemplate(ctx, "wifi", "1.0.0", map[string]interface{}{
+		"dhtPin":        2,
+		"ssid":          "home",
+		"wifi_password": "secret\";\nvoid hijack() {}\nconst char* x = \"",
+		"calibration":   1.5,
+		"verbose":       true,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parameter wifi_password contains control characters")
+
+	rendered, err := service.RenderTemplate(ctx, "wifi", "1.0.0", map[string]interface{}{
+		"dhtPin":        2,
+		"ssid":          "home",
+		"wifi_password": `se"cret`,
+		"calibration":   1.5,
+		"verbose":       true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, rendered.RenderedCode, `const char* password = "se\"cret";`)
+	assert.Equal(t, `se"cret`, rendered.Parameters["wifi_password"])
+}
@@ -2,11 +2,15 @@ package template
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/environments"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/topics"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,11 +23,22 @@ type Service struct {
 	versionManager *VersionManager
 	renderer       *TemplateRenderer
 	wiringGen      *WiringDiagramGenerator
+	composer       *CompositionResolver
+	publishers     PublisherStore
+	images         ImageStore
+	diagrams       *diagramCache
+	builds         BuildRecordStore
+	drivers        DriverStore
+	environments   *environments.Pipeline
+
+	referenceFinders []ReferenceFinder
+	recommenders     []ParameterRecommender
+	usageFinders     []UsageFinder
 }
 
 // NewService creates a new template service instance
 func NewService(cfg *config.Config, logger *logger.Logger, repo Repository) (*Service, error) {
-	return &Service{
+	service := &Service{
 		config:         cfg,
 		logger:         logger,
 		repo:           repo,
@@ -31,7 +46,22 @@ func NewService(cfg *config.Config, logger *logger.Logger, repo Repository) (*Se
 		versionManager: NewVersionManager(),
 		renderer:       NewTemplateRenderer(),
 		wiringGen:      NewWiringDiagramGenerator(),
-	}, nil
+		publishers:     NewMemoryPublisherStore(),
+		images:         NewMemoryImageStore(),
+		diagrams:       newDiagramCache(maxRenderedDiagrams),
+		builds:         NewMemoryBuildRecordStore(),
+		drivers:        NewMemoryDriverStore(),
+		environments:   environments.NewPipelineFromConfig(cfg),
+	}
+	service.composer = NewCompositionResolver(service.GetTemplate)
+
+	namespace, err := topics.New(cfg.MQTT.TopicTemplate, cfg.MQTT.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT topic configuration: %w", err)
+	}
+	service.renderer.SetTopicNamespace(namespace)
+
+	return service, nil
 }
 
 // RegisterRoutes registers HTTP routes for the template service
@@ -40,7 +70,31 @@ func RegisterRoutes(router *gin.Engine, service *Service) {
 	{
 		v1.GET("/health", service.healthCheck)
 		v1.GET("/templates", service.listTemplates)
+		v1.POST("/templates", service.createTemplate)
+		v1.POST("/templates/import", service.importBundle)
 		v1.GET("/templates/:id", service.getTemplate)
+		v1.GET("/templates/:id/composition", service.getTemplateComposition)
+		v1.GET("/templates/:id/parameters", service.getParameterSchema)
+		v1.GET("/templates/:id/compatibility", service.getCompatibility)
+		v1.POST("/templates/:id/builds", service.recordBuild)
+		v1.GET("/templates/:id/analytics", service.getTemplateAnalytics)
+		v1.GET("/templates/:id/wiring.svg", service.getWiringSVG)
+		v1.GET("/templates/:id/wiring.png", service.getWiringPNG)
+		v1.POST("/templates/:id/preview", service.previewTemplate)
+		v1.GET("/templates/:id/source", service.getTemplateSource)
+		v1.POST("/templates/:id/fork", service.forkTemplate)
+		v1.POST("/templates/:id/promote", service.promoteTemplate)
+		v1.PUT("/templates/:id/images/:role", service.uploadImage)
+		v1.GET("/templates/:id/images/:role", service.getImage)
+		v1.DELETE("/templates/:id/images/:role", service.deleteImage)
+		v1.DELETE("/templates/:id", service.deleteTemplate)
+		v1.GET("/publishers", service.listPublishers)
+		v1.POST("/publishers", service.createPublisher)
+		v1.GET("/publishers/:id", service.getPublisher)
+		v1.GET("/drivers", service.listDrivers)
+		v1.GET("/drivers/:driverId", service.getDriver)
+		v1.PUT("/drivers/:driverId", service.putDriver)
+		v1.DELETE("/drivers/:driverId", service.deleteDriver)
 	}
 }
 
@@ -74,8 +128,19 @@ func (s *Service) GetTemplate(ctx context.Context, id string, version string) (*
 	return s.repo.GetTemplate(ctx, id, version)
 }
 
-// CreateTemplate creates a new template
+// CreateTemplate creates a new template on the platform. Provenance is
+// only recorded by ImportBundle, so any sent with the template is dropped,
+// and with signed bundles required templates can only be imported.
 func (s *Service) CreateTemplate(ctx context.Context, template *Template) error {
+	template.Provenance = nil
+	if s.config.Templates.RequireSignedBundles {
+		return fmt.Errorf("%w: templates must be imported as signed bundles", ErrUnverifiedBundle)
+	}
+	return s.storeTemplate(ctx, template)
+}
+
+// storeTemplate validates and stores a new template version
+func (s *Service) storeTemplate(ctx context.Context, template *Template) error {
 	s.logger.Info("Creating template", "id", template.ID, "version", template.Version)
 
 	// Validate template before creation
@@ -87,6 +152,14 @@ func (s *Service) CreateTemplate(ctx context.Context, template *Template) error
 		return fmt.Errorf("template validation failed: %v", result.Errors)
 	}
 
+	// Validate includes resolve without cycles
+	if err := s.validateComposition(ctx, template); err != nil {
+		return err
+	}
+	if err := s.validateDrivers(ctx, template); err != nil {
+		return err
+	}
+
 	// Validate version format
 	_, _, _, err = s.versionManager.ParseVersion(template.Version)
 	if err != nil {
@@ -125,6 +198,9 @@ func (s *Service) CreateTemplate(ctx context.Context, template *Template) error
 		}
 	}
 
+	// New versions start in the first environment and are promoted from there
+	template.Environments = []string{s.environments.Initial()}
+
 	return s.repo.CreateTemplate(ctx, template)
 }
 
@@ -141,13 +217,27 @@ func (s *Service) UpdateTemplate(ctx context.Context, template *Template) error
 		return fmt.Errorf("template validation failed: %v", result.Errors)
 	}
 
+	if err := s.validateComposition(ctx, template); err != nil {
+		return err
+	}
+	if err := s.validateDrivers(ctx, template); err != nil {
+		return err
+	}
+
+	// Provenance is kept from the stored version, never taken from the caller
+	template.Provenance = nil
+	if existing, err := s.repo.GetTemplate(ctx, template.ID, template.Version); err == nil {
+		template.Provenance = existing.Provenance
+	}
+
 	return s.repo.UpdateTemplate(ctx, template)
 }
 
-// DeleteTemplate deletes a template by ID and version
+// DeleteTemplate deletes a template by ID and version. Versions in use are
+// not deleted; see DeleteTemplateVersion.
 func (s *Service) DeleteTemplate(ctx context.Context, id string, version string) error {
-	s.logger.Info("Deleting template", "id", id, "version", version)
-	return s.repo.DeleteTemplate(ctx, id, version)
+	_, err := s.DeleteTemplateVersion(ctx, id, version, false)
+	return err
 }
 
 // ValidateTemplate validates a complete template structure
@@ -156,10 +246,21 @@ func (s *Service) ValidateTemplate(ctx context.Context, template *Template) (*Va
 	return s.validator.ValidateTemplate(template)
 }
 
-// ValidateParameters validates template parameters against the template's schema
+// ValidateParameters validates template parameters against the template's
+// schema and rejects values that look like injected code
 func (s *Service) ValidateParameters(ctx context.Context, template *Template, parameters map[string]interface{}) (*ValidationResult, error) {
 	s.logger.Info("Validating parameters", "template_id", template.ID, "version", template.Version)
-	return s.validator.ValidateParameters(template.Schema, parameters)
+	result, err := s.validator.ValidateParameters(template.Schema, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, finding := range ScanParameters(parameters) {
+		s.logger.Warn("Rejected suspicious template parameter", "template_id", template.ID, "parameter", finding.Parameter, "reason", finding.Reason)
+		result.Valid = false
+		result.Errors = append(result.Errors, finding.String())
+	}
+	return result, nil
 }
 
 // ValidateBoardCapabilities validates that template parameters are compatible with board capabilities
@@ -170,14 +271,48 @@ func (s *Service) ValidateBoardCapabilities(ctx context.Context, template *Templ
 
 // RenderTemplate renders a template with the given parameters
 func (s *Service) RenderTemplate(ctx context.Context, id string, version string, parameters map[string]interface{}) (*RenderedTemplate, error) {
+	return s.RenderTemplateWithOverrides(ctx, id, version, parameters, "")
+}
+
+// RenderTemplateWithOverrides renders a template, filling its override blocks
+// from the "blocks" parameter and a supplemental snippet file. An
+// "onboarding" parameter adds captive portal onboarding to the sketch, and a
+// "locale" parameter selects the locale pack for comments and instructions.
+func (s *Service) RenderTemplateWithOverrides(ctx context.Context, id string, version string, parameters map[string]interface{}, snippets string) (*RenderedTemplate, error) {
 	s.logger.Info("Rendering template", "id", id, "version", version)
 
+	parameters, overrides, err := ExtractBlockOverrides(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block overrides: %w", err)
+	}
+	parameters, onboarding, err := ExtractOnboardingOptions(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid onboarding options: %w", err)
+	}
+	parameters, requestedLocale, err := ExtractLocale(parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the template
 	tmpl, err := s.repo.GetTemplate(ctx, id, version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get template: %w", err)
 	}
 
+	// Flatten composed templates into a single template
+	var codeTemplate string
+	var includes map[string]string
+	if len(tmpl.Includes) > 0 {
+		composed, err := s.ResolveComposition(ctx, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = composed.Template
+		parameters = mergeParameters(tmpl.Parameters, parameters)
+		codeTemplate, includes = composed.CodeTemplate()
+	}
+
 	// Validate parameters
 	paramResult, err := s.ValidateParameters(ctx, tmpl, parameters)
 	if err != nil {
@@ -188,32 +323,68 @@ func (s *Service) RenderTemplate(ctx context.Context, id string, version string,
 	}
 
 	// Find the main Arduino code template
-	var codeTemplate string
-	for _, asset := range tmpl.Assets {
-		if asset.Type == "code" && strings.Contains(asset.Path, "main.ino") {
-			// Use the template content from asset metadata if available
-			if content, ok := asset.Metadata["content"].(string); ok {
-				codeTemplate = content
-				break
+	if codeTemplate == "" {
+		for _, asset := range tmpl.Assets {
+			if asset.Type == "code" && strings.Contains(asset.Path, "main.ino") {
+				// Use the template content from asset metadata if available
+				if content, ok := asset.Metadata["content"].(string); ok {
+					codeTemplate = content
+					break
+				}
 			}
 		}
 	}
 
+	// Templates without code get a sketch composed from driver snippets
 	if codeTemplate == "" {
-		codeTemplate = s.getDefaultArduinoTemplate(tmpl)
+		var drivers []*DriverSnippet
+		codeTemplate, drivers, err = s.generateSketch(ctx, tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate sketch: %w", err)
+		}
+		generated := *tmpl
+		generated.Libraries = driverLibraries(slices.Clone(tmpl.Libraries), drivers, parameters)
+		tmpl = &generated
+	}
+
+	// Pick the locale pack, falling back to the template's own text
+	packs, err := LocalePacks(tmpl.Assets)
+	if err != nil {
+		return nil, err
+	}
+	locale, messages := ResolveLocale(packs, requestedLocale)
+	if requestedLocale != "" && locale == "" {
+		s.logger.Info("No locale pack for template, using default text", "id", id, "locale", requestedLocale)
+	}
+
+	// Parameters are interpolated into C++ source, so encode them by type
+	encoded, err := EncodeParameters(tmpl.Schema, parameters)
+	if err != nil {
+		return nil, fmt.Errorf("parameter validation failed: %w", err)
 	}
 
 	// Render the Arduino code
-	renderedCode, err := s.renderer.RenderArduinoCode(codeTemplate, parameters)
+	renderedCode, err := s.renderer.RenderLocalized(codeTemplate, includes, encoded, overrides, snippets, messages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render Arduino code: %w", err)
 	}
 
+	if onboarding != nil {
+		onboarding.ApplyDefaults(s.config.Onboarding.ReportURL, parameters)
+		renderedCode, err = InjectOnboarding(renderedCode, onboarding, tmpl.BoardsSupported)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	rendered := &RenderedTemplate{
 		Template:     tmpl,
 		Parameters:   parameters,
 		RenderedCode: renderedCode,
 		Assets:       tmpl.Assets,
+		Blocks:       declaredBlocks(codeTemplate, includes),
+		Locale:       locale,
+		Locales:      Locales(packs),
 	}
 
 	return rendered, nil
@@ -232,6 +403,25 @@ func (s *Service) GenerateWiringDiagram(ctx context.Context, template *Template,
 		return nil, fmt.Errorf("parameter validation failed: %v", paramResult.Errors)
 	}
 
+	// Composed templates merge the diagrams of every sub-template
+	if len(template.Includes) > 0 {
+		composed, err := s.ResolveComposition(ctx, template)
+		if err != nil {
+			return nil, err
+		}
+
+		var diagrams []*WiringDiagram
+		for _, component := range composed.Components {
+			diagram, err := s.wiringGen.GenerateWiringDiagram(component, mergeParameters(component.Parameters, parameters))
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate wiring diagram for %s: %w", component.ID, err)
+			}
+			diagrams = append(diagrams, diagram)
+		}
+
+		return s.wiringGen.MergeWiringDiagrams(template, diagrams), nil
+	}
+
 	// Generate the wiring diagram
 	diagram, err := s.wiringGen.GenerateWiringDiagram(template, parameters)
 	if err != nil {
@@ -241,6 +431,38 @@ func (s *Service) GenerateWiringDiagram(ctx context.Context, template *Template,
 	return diagram, nil
 }
 
+// ResolveComposition flattens a template with its included sub-templates
+func (s *Service) ResolveComposition(ctx context.Context, template *Template) (*ComposedTemplate, error) {
+	s.logger.Info("Resolving template composition", "id", template.ID, "version", template.Version)
+
+	composed, err := s.composer.Resolve(ctx, template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve template composition: %w", err)
+	}
+
+	for _, warning := range composed.Warnings {
+		s.logger.Warn("Template composition warning", "id", template.ID, "warning", warning)
+	}
+
+	return composed, nil
+}
+
+// validateComposition checks that a template's includes exist and do not form a cycle
+func (s *Service) validateComposition(ctx context.Context, template *Template) error {
+	if len(template.Includes) == 0 {
+		return nil
+	}
+
+	for _, include := range template.Includes {
+		if include.ID == "" {
+			return fmt.Errorf("template validation failed: included template ID is required")
+		}
+	}
+
+	_, err := s.ResolveComposition(ctx, template)
+	return err
+}
+
 // SearchTemplates searches templates by query string
 func (s *Service) SearchTemplates(ctx context.Context, query string, filters *TemplateFilters) ([]*Template, error) {
 	s.logger.Info("Searching templates", "query", query, "filters", filters)
@@ -291,10 +513,11 @@ func (s *Service) listTemplates(c *gin.Context) {
 
 	// Parse query parameters for filters
 	filters := &TemplateFilters{
-		Category:  c.Query("category"),
-		BoardType: c.Query("board_type"),
-		Limit:     10, // Default limit
-		Offset:    0,  // Default offset
+		Category:    c.Query("category"),
+		BoardType:   c.Query("board_type"),
+		Environment: c.Query("environment"),
+		Limit:       10, // Default limit
+		Offset:      0,  // Default offset
 	}
 
 	// Parse limit and offset if provided
@@ -323,6 +546,10 @@ func (s *Service) listTemplates(c *gin.Context) {
 		return
 	}
 
+	for _, tmpl := range templates {
+		setTemplateImages(tmpl)
+	}
+
 	c.JSON(200, gin.H{
 		"templates": templates,
 		"total":     count,
@@ -345,9 +572,71 @@ func (s *Service) getTemplate(c *gin.Context) {
 		return
 	}
 
+	setTemplateImages(template)
 	c.JSON(200, template)
 }
 
+func (s *Service) createTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var template Template
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if err := s.CreateTemplate(ctx, &template); err != nil {
+		s.logger.Error("Failed to create template", "id", template.ID, "version", template.Version, "error", err)
+		status := 422
+		if errors.Is(err, ErrUnverifiedBundle) {
+			status = 403
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, template)
+}
+
+func (s *Service) getTemplateComposition(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	template, err := s.GetTemplate(ctx, templateID, version)
+	if err != nil {
+		s.logger.Error("Failed to get template", "id", templateID, "version", version, "error", err)
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+
+	composed, err := s.ResolveComposition(ctx, template)
+	if err != nil {
+		s.logger.Error("Failed to resolve template composition", "id", templateID, "version", version, "error", err)
+		status := 500
+		if errors.Is(err, ErrCompositionCycle) || errors.Is(err, ErrCompositionConflict) {
+			status = 422
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, composed)
+}
+
+// mergeParameters overlays user-supplied parameters on template defaults
+func mergeParameters(defaults, parameters map[string]interface{}) map[string]interface{} {
+	merged := copyParameters(defaults)
+	for name, value := range parameters {
+		merged[name] = value
+	}
+	return merged
+}
+
 // Helper function to parse integer parameters
 func parseIntParam(s string) (int, error) {
 	// Simple integer parsing - in production you'd use strconv.Atoi
@@ -696,238 +985,3 @@ type SearchResult struct {
 	ResultCount int64       `json:"result_count"`
 	Query       string      `json:"query"`
 }
-
-// getDefaultArduinoTemplate returns a default Arduino template based on the template category
-func (s *Service) getDefaultArduinoTemplate(template *Template) string {
-	switch strings.ToLower(template.Category) {
-	case "sensing":
-		return s.getSensorTemplate()
-	case "automation":
-		return s.getAutomationTemplate()
-	case "display":
-		return s.getDisplayTemplate()
-	case "communication":
-		return s.getCommunicationTemplate()
-	default:
-		return s.getBasicTemplate()
-	}
-}
-
-// getSensorTemplate returns a basic sensor template
-func (s *Service) getSensorTemplate() string {
-	return `{{comment "Generated Arduino code for sensor template"}}
-{{defineConst "SENSOR_PIN" .sensor_pin}}
-{{if .dht_pin}}{{defineConst "DHT_PIN" .dht_pin}}{{end}}
-
-{{if .dht_pin}}#include <DHT.h>
-DHT dht({{.dht_pin}}, DHT22);{{end}}
-
-void setup() {
-  Serial.begin(9600);
-  {{if .dht_pin}}dht.begin();{{end}}
-  {{if .led_pin}}pinMode({{.led_pin}}, OUTPUT);{{end}}
-  
-  Serial.println("{{.name | default "Sensor"}} initialized");
-}
-
-void loop() {
-  {{if .dht_pin}}
-  float temperature = dht.readTemperature();
-  float humidity = dht.readHumidity();
-  
-  if (!isnan(temperature) && !isnan(humidity)) {
-    Serial.print("Temperature: ");
-    Serial.print(temperature);
-    Serial.print("°C, Humidity: ");
-    Serial.print(humidity);
-    Serial.println("%");
-    
-    {{if .led_pin}}
-    // Blink LED based on temperature
-    if (temperature > {{.temp_threshold | default 25}}) {
-      digitalWrite({{.led_pin}}, HIGH);
-      delay(100);
-      digitalWrite({{.led_pin}}, LOW);
-    }
-    {{end}}
-  }
-  {{else}}
-  int sensorValue = analogRead({{.sensor_pin | default "A0"}});
-  Serial.print("Sensor reading: ");
-  Serial.println(sensorValue);
-  {{end}}
-  
-  delay({{.delay_ms | default 2000}});
-}`
-}
-
-// getAutomationTemplate returns a basic automation template
-func (s *Service) getAutomationTemplate() string {
-	return `{{comment "Generated Arduino code for automation template"}}
-{{if .led_pin}}{{defineConst "LED_PIN" .led_pin}}{{end}}
-{{if .servo_pin}}{{defineConst "SERVO_PIN" .servo_pin}}{{end}}
-{{if .relay_pin}}{{defineConst "RELAY_PIN" .relay_pin}}{{end}}
-
-{{if .servo_pin}}#include <Servo.h>
-Servo myServo;{{end}}
-
-void setup() {
-  Serial.begin(9600);
-  
-  {{if .led_pin}}pinMode({{.led_pin}}, OUTPUT);{{end}}
-  {{if .relay_pin}}pinMode({{.relay_pin}}, OUTPUT);{{end}}
-  {{if .servo_pin}}myServo.attach({{.servo_pin}});{{end}}
-  
-  Serial.println("{{.name | default "Automation"}} system ready");
-}
-
-void loop() {
-  {{if .led_pin}}
-  // LED control
-  digitalWrite({{.led_pin}}, HIGH);
-  delay({{.on_time | default 1000}});
-  digitalWrite({{.led_pin}}, LOW);
-  delay({{.off_time | default 1000}});
-  {{end}}
-  
-  {{if .servo_pin}}
-  // Servo control
-  for (int pos = 0; pos <= 180; pos += 1) {
-    myServo.write(pos);
-    delay(15);
-  }
-  for (int pos = 180; pos >= 0; pos -= 1) {
-    myServo.write(pos);
-    delay(15);
-  }
-  {{end}}
-  
-  {{if .relay_pin}}
-  // Relay control
-  digitalWrite({{.relay_pin}}, HIGH);
-  delay({{.relay_on_time | default 2000}});
-  digitalWrite({{.relay_pin}}, LOW);
-  delay({{.relay_off_time | default 2000}});
-  {{end}}
-}`
-}
-
-// getDisplayTemplate returns a basic display template
-func (s *Service) getDisplayTemplate() string {
-	return `{{comment "Generated Arduino code for display template"}}
-{{if .lcd_rs}}{{defineConst "LCD_RS" .lcd_rs}}{{end}}
-{{if .lcd_enable}}{{defineConst "LCD_ENABLE" .lcd_enable}}{{end}}
-
-{{if .lcd_rs}}#include <LiquidCrystal.h>
-LiquidCrystal lcd({{.lcd_rs}}, {{.lcd_enable}}, {{.lcd_d4}}, {{.lcd_d5}}, {{.lcd_d6}}, {{.lcd_d7}});{{end}}
-
-void setup() {
-  Serial.begin(9600);
-  
-  {{if .lcd_rs}}
-  lcd.begin(16, 2);
-  lcd.print("{{.display_message | default "Hello, World!"}}");
-  {{end}}
-  
-  Serial.println("{{.name | default "Display"}} initialized");
-}
-
-void loop() {
-  {{if .lcd_rs}}
-  lcd.setCursor(0, 1);
-  lcd.print("Time: ");
-  lcd.print(millis() / 1000);
-  lcd.print("s");
-  {{end}}
-  
-  delay({{.update_interval | default 1000}});
-}`
-}
-
-// getCommunicationTemplate returns a basic communication template
-func (s *Service) getCommunicationTemplate() string {
-	return `{{comment "Generated Arduino code for communication template"}}
-{{if .wifi_ssid}}{{defineConst "WIFI_SSID" .wifi_ssid}}{{end}}
-{{if .mqtt_server}}{{defineConst "MQTT_SERVER" .mqtt_server}}{{end}}
-
-{{if .wifi_ssid}}#include <WiFi.h>
-#include <PubSubClient.h>
-
-WiFiClient espClient;
-PubSubClient client(espClient);{{end}}
-
-void setup() {
-  Serial.begin(9600);
-  
-  {{if .wifi_ssid}}
-  WiFi.begin("{{.wifi_ssid}}", "{{.wifi_password}}");
-  while (WiFi.status() != WL_CONNECTED) {
-    delay(1000);
-    Serial.println("Connecting to WiFi...");
-  }
-  Serial.println("WiFi connected");
-  
-  {{if .mqtt_server}}
-  client.setServer("{{.mqtt_server}}", {{.mqtt_port | default 1883}});
-  {{end}}
-  {{end}}
-  
-  Serial.println("{{.name | default "Communication"}} system ready");
-}
-
-void loop() {
-  {{if .wifi_ssid}}
-  if (!client.connected()) {
-    reconnect();
-  }
-  client.loop();
-  
-  // Publish sensor data
-  String payload = "{{.device_id | default "arduino"}}: " + String(millis());
-  client.publish("{{.mqtt_topic | default "sensors/data"}}", payload.c_str());
-  {{end}}
-  
-  delay({{.publish_interval | default 5000}});
-}
-
-{{if .mqtt_server}}
-void reconnect() {
-  while (!client.connected()) {
-    Serial.print("Attempting MQTT connection...");
-    if (client.connect("{{.device_id | default "ArduinoClient"}}")) {
-      Serial.println("connected");
-    } else {
-      Serial.print("failed, rc=");
-      Serial.print(client.state());
-      Serial.println(" try again in 5 seconds");
-      delay(5000);
-    }
-  }
-}
-{{end}}`
-}
-
-// getBasicTemplate returns a basic Arduino template
-func (s *Service) getBasicTemplate() string {
-	return `{{comment "Generated Arduino code"}}
-{{if .led_pin}}{{defineConst "LED_PIN" .led_pin}}{{end}}
-
-void setup() {
-  Serial.begin(9600);
-  {{if .led_pin}}pinMode({{.led_pin}}, OUTPUT);{{end}}
-  
-  Serial.println("{{.name | default "Arduino"}} sketch started");
-}
-
-void loop() {
-  {{if .led_pin}}
-  digitalWrite({{.led_pin}}, HIGH);
-  delay({{.delay_on | default 1000}});
-  digitalWrite({{.led_pin}}, LOW);
-  delay({{.delay_off | default 1000}});
-  {{else}}
-  Serial.println("Running...");
-  delay({{.loop_delay | default 1000}});
-  {{end}}
-}`
-}
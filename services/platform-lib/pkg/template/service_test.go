@@ -246,6 +246,7 @@ func TestService_UpdateTemplate(t *testing.T) {
 
 	// First, create the template so it exists for update
 	mockRepo.On("GetTemplateVersions", ctx, template.ID).Return([]string{}, nil)
+	mockRepo.On("GetTemplate", ctx, template.ID, template.Version).Return(createTestTemplate(), nil)
 	mockRepo.On("UpdateTemplate", ctx, template).Return(nil)
 
 	err := service.UpdateTemplate(ctx, template)
@@ -261,6 +262,8 @@ func TestService_DeleteTemplate(t *testing.T) {
 	templateID := "test-template-1"
 	version := "1.0.0"
 
+	mockRepo.On("TemplateExists", ctx, templateID, version).Return(true, nil)
+	mockRepo.On("ListTemplates", ctx, (*TemplateFilters)(nil)).Return([]*Template{}, nil)
 	mockRepo.On("DeleteTemplate", ctx, templateID, version).Return(nil)
 
 	err := service.DeleteTemplate(ctx, templateID, version)
@@ -0,0 +1,211 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SourceFile is an editable code file of a template
+type SourceFile struct {
+	Path    string `json:"path"`
+	Section string `json:"section,omitempty"` // composition section, empty for main.ino
+	Content string `json:"content"`
+}
+
+// TemplateSource is the editable form of a template opened in an editor
+type TemplateSource struct {
+	TemplateID string                 `json:"template_id"`
+	Version    string                 `json:"version"`
+	Name       string                 `json:"name"`
+	Files      []SourceFile           `json:"files"`
+	Schema     map[string]interface{} `json:"schema,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Libraries  []LibraryDependency    `json:"libraries,omitempty"`
+	Blocks     []string               `json:"blocks,omitempty"`
+	// Generated is set when the template has no code asset and Files holds
+	// the category default sketch
+	Generated bool `json:"generated"`
+}
+
+// ForkRequest creates a new template from local edits to an existing one
+type ForkRequest struct {
+	ID          string                 `json:"id" binding:"required"`
+	Name        string                 `json:"name,omitempty"`
+	Version     string                 `json:"version,omitempty"` // defaults to 1.0.0
+	Description string                 `json:"description,omitempty"`
+	Files       []SourceFile           `json:"files" binding:"required"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Owner       string                 `json:"owner,omitempty"`
+}
+
+// GetTemplateSource returns the code files of a template for editing
+func (s *Service) GetTemplateSource(ctx context.Context, id string, version string) (*TemplateSource, error) {
+	tmpl, err := s.GetTemplate(ctx, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	source := &TemplateSource{
+		TemplateID: tmpl.ID,
+		Version:    tmpl.Version,
+		Name:       tmpl.Name,
+		Schema:     tmpl.Schema,
+		Parameters: tmpl.Parameters,
+		Libraries:  tmpl.Libraries,
+	}
+
+	for _, asset := range tmpl.Assets {
+		if asset.Type != "code" {
+			continue
+		}
+		content, ok := asset.Metadata["content"].(string)
+		if !ok {
+			continue
+		}
+		section, _ := asset.Metadata["section"].(string)
+		source.Files = append(source.Files, SourceFile{
+			Path:    asset.Path,
+			Section: section,
+			Content: content,
+		})
+	}
+
+	if len(source.Files) == 0 {
+		code, _, err := s.generateSketch(ctx, tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate sketch: %w", err)
+		}
+		source.Generated = true
+		source.Files = []SourceFile{{
+			Path:    "main.ino",
+			Content: code,
+		}}
+	}
+
+	for _, file := range source.Files {
+		source.Blocks = append(source.Blocks, BlockNames(file.Content)...)
+	}
+
+	return source, nil
+}
+
+// ForkTemplate creates a new template from an existing one with its code
+// replaced by the supplied files. Non-code assets, schema, libraries and
+// includes are carried over from the source template.
+func (s *Service) ForkTemplate(ctx context.Context, sourceID string, sourceVersion string, req *ForkRequest) (*Template, error) {
+	s.logger.Info("Forking template", "source_id", sourceID, "source_version", sourceVersion, "id", req.ID)
+
+	if req.ID == "" {
+		return nil, fmt.Errorf("fork ID is required")
+	}
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("at least one source file is required")
+	}
+
+	source, err := s.GetTemplate(ctx, sourceID, sourceVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source template: %w", err)
+	}
+
+	fork := &Template{
+		ID:              req.ID,
+		Name:            req.Name,
+		Version:         req.Version,
+		Category:        source.Category,
+		Description:     req.Description,
+		BoardsSupported: append([]string(nil), source.BoardsSupported...),
+		Schema:          copySchema(source.Schema),
+		Parameters:      copyParameters(source.Parameters),
+		Libraries:       append([]LibraryDependency(nil), source.Libraries...),
+		CoreVersions:    maps.Clone(source.CoreVersions),
+		Includes:        append([]TemplateInclude(nil), source.Includes...),
+		ForkedFrom:      source.ID + "@" + source.Version,
+		Owner:           req.Owner,
+	}
+	if fork.Name == "" {
+		fork.Name = source.Name + " (fork)"
+	}
+	if fork.Version == "" {
+		fork.Version = "1.0.0"
+	}
+	if fork.Description == "" {
+		fork.Description = source.Description
+	}
+	for name, value := range req.Parameters {
+		fork.Parameters[name] = value
+	}
+
+	for _, asset := range source.Assets {
+		if asset.Type != "code" {
+			fork.Assets = append(fork.Assets, asset)
+		}
+	}
+	for _, file := range req.Files {
+		if strings.TrimSpace(file.Path) == "" {
+			return nil, fmt.Errorf("source file path is required")
+		}
+		metadata := map[string]interface{}{"content": file.Content}
+		if file.Section != "" {
+			metadata["section"] = file.Section
+		}
+		fork.Assets = append(fork.Assets, Asset{
+			Type:     "code",
+			Path:     file.Path,
+			Metadata: metadata,
+		})
+	}
+
+	if err := s.CreateTemplate(ctx, fork); err != nil {
+		return nil, err
+	}
+
+	return fork, nil
+}
+
+func (s *Service) getTemplateSource(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	source, err := s.GetTemplateSource(ctx, templateID, version)
+	if err != nil {
+		s.logger.Error("Failed to get template source", "id", templateID, "version", version, "error", err)
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(200, source)
+}
+
+func (s *Service) forkTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	var req ForkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	fork, err := s.ForkTemplate(ctx, templateID, version, &req)
+	if err != nil {
+		s.logger.Error("Failed to fork template", "id", templateID, "version", version, "error", err)
+		c.JSON(422, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, fork)
+}
@@ -0,0 +1,87 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_GetTemplateSource(t *testing.T) {
+	withCode := &Template{
+		ID:              "blink",
+		Name:            "Blink",
+		Version:         "1.0.0",
+		Category:        "automation",
+		BoardsSupported: []string{"arduino:avr:uno"},
+		Assets: []Asset{{
+			Type:     "code",
+			Path:     "main.ino",
+			Metadata: map[string]interface{}{"content": blockSketch},
+		}},
+	}
+	withoutCode := &Template{
+		ID:              "plain",
+		Name:            "Plain",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		BoardsSupported: []string{"arduino:avr:uno"},
+	}
+	service := setupCompositionService(t, withCode, withoutCode)
+	ctx := context.Background()
+
+	source, err := service.GetTemplateSource(ctx, "blink", "latest")
+	require.NoError(t, err)
+	assert.False(t, source.Generated)
+	require.Len(t, source.Files, 1)
+	assert.Equal(t, "main.ino", source.Files[0].Path)
+	assert.Equal(t, blockSketch, source.Files[0].Content)
+	assert.Equal(t, []string{"loop_extra", "footer"}, source.Blocks)
+
+	source, err = service.GetTemplateSource(ctx, "plain", "1.0.0")
+	require.NoError(t, err)
+	assert.True(t, source.Generated)
+	require.Len(t, source.Files, 1)
+	assert.NotEmpty(t, source.Files[0].Content)
+}
+
+func TestService_ForkTemplate(t *testing.T) {
+	tmpl := &Template{
+		ID:              "blink",
+		Name:            "Blink",
+		Version:         "1.0.0",
+		Category:        "automation",
+		Description:     "Blinks an LED",
+		BoardsSupported: []string{"arduino:avr:uno"},
+		Parameters:      map[string]interface{}{"delay_ms": 1000},
+		Assets: []Asset{
+			{Type: "code", Path: "main.ino", Metadata: map[string]interface{}{"content": "void loop() {}"}},
+			{Type: "image", Path: "wiring.png"},
+		},
+	}
+	service := setupCompositionService(t, tmpl)
+	ctx := context.Background()
+
+	fork, err := service.ForkTemplate(ctx, "blink", "latest", &ForkRequest{
+		ID:         "blink-fast",
+		Files:      []SourceFile{{Path: "main.ino", Content: "void loop() { delay({{.delay_ms}}); }"}},
+		Parameters: map[string]interface{}{"delay_ms": 100},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Blink (fork)", fork.Name)
+	assert.Equal(t, "1.0.0", fork.Version)
+	assert.Equal(t, "blink@1.0.0", fork.ForkedFrom)
+	assert.Equal(t, 100, fork.Parameters["delay_ms"])
+	assert.Equal(t, 1000, tmpl.Parameters["delay_ms"], "source parameters are not modified")
+
+	stored, err := service.GetTemplate(ctx, "blink-fast", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "blink@1.0.0", stored.ForkedFrom)
+	require.Len(t, stored.Assets, 2)
+	assert.Equal(t, "image", stored.Assets[0].Type)
+	assert.Equal(t, "void loop() { delay({{.delay_ms}}); }", stored.Assets[1].Metadata["content"])
+
+	_, err = service.ForkTemplate(ctx, "blink", "latest", &ForkRequest{ID: "empty"})
+	assert.Error(t, err)
+}
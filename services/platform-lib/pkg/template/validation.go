@@ -133,6 +133,38 @@ func (v *JSONSchemaValidator) ValidateTemplate(template *Template) (*ValidationR
 		}
 	}
 
+	// Validate the telemetry schema
+	metricTypes := map[string]bool{"": true, MetricTypeNumber: true, MetricTypeBoolean: true, MetricTypeString: true}
+	metricNames := make(map[string]bool, len(template.Telemetry))
+	for i, metric := range template.Telemetry {
+		if metric.Name == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("telemetry metric %d: name is required", i))
+		} else if metricNames[metric.Name] {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("telemetry metric '%s' is defined twice", metric.Name))
+		}
+		metricNames[metric.Name] = true
+		if !metricTypes[metric.Type] {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("telemetry metric '%s': invalid type '%s'", metric.Name, metric.Type))
+		}
+	}
+
+	// Validate the OTA defaults of registered devices
+	if template.OTA != nil {
+		channels := map[string]bool{"": true, "stable": true, "beta": true, "alpha": true}
+		if !channels[template.OTA.Channel] {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("ota: invalid channel '%s'", template.OTA.Channel))
+		}
+		enrollments := map[string]bool{"": true, "auto": true, "manual": true}
+		if !enrollments[template.OTA.Enrollment] {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("ota: invalid enrollment '%s'", template.OTA.Enrollment))
+		}
+	}
+
 	// Validate parameters against schema if both exist
 	if template.Schema != nil && template.Parameters != nil {
 		paramResult, err := v.ValidateParameters(template.Schema, template.Parameters)
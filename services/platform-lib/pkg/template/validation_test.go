@@ -42,6 +42,33 @@ func TestService_ValidateTemplate(t *testing.T) {
 		assert.NotEmpty(t, result.Errors)
 	})
 
+	t.Run("Invalid template - telemetry schema", func(t *testing.T) {
+		template := createTestTemplate()
+		template.Telemetry = []TelemetryMetric{
+			{Name: "temperature", Unit: "°C"},
+			{Name: "temperature"},
+			{Name: "door", Type: "enum"},
+			{Unit: "%"},
+		}
+
+		result, err := service.ValidateTemplate(ctx, template)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Len(t, result.Errors, 3)
+	})
+
+	t.Run("Invalid template - OTA defaults", func(t *testing.T) {
+		template := createTestTemplate()
+		template.OTA = &OTADefaults{Channel: "nightly", Enrollment: "sometimes"}
+
+		result, err := service.ValidateTemplate(ctx, template)
+
+		assert.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Len(t, result.Errors, 2)
+	})
+
 	t.Run("Invalid template - malformed schema", func(t *testing.T) {
 		template := createTestTemplate()
 		template.Schema = map[string]interface{}{
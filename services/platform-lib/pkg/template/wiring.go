@@ -578,17 +578,8 @@ func (wdg *WiringDiagramGenerator) generateMermaidStyling(components []Component
 	builder.WriteString("\n    %% Styling\n")
 
 	for _, component := range components {
-		switch component.Type {
-		case "microcontroller":
-			builder.WriteString(fmt.Sprintf("    classDef %s fill:#e1f5fe,stroke:#01579b,stroke-width:2px\n", component.ID))
-		case "sensor":
-			builder.WriteString(fmt.Sprintf("    classDef %s fill:#f3e5f5,stroke:#4a148c,stroke-width:2px\n", component.ID))
-		case "actuator":
-			builder.WriteString(fmt.Sprintf("    classDef %s fill:#e8f5e8,stroke:#1b5e20,stroke-width:2px\n", component.ID))
-		case "display":
-			builder.WriteString(fmt.Sprintf("    classDef %s fill:#fff3e0,stroke:#e65100,stroke-width:2px\n", component.ID))
-		case "communication":
-			builder.WriteString(fmt.Sprintf("    classDef %s fill:#fce4ec,stroke:#880e4f,stroke-width:2px\n", component.ID))
+		if style, ok := componentStyles[component.Type]; ok {
+			builder.WriteString(fmt.Sprintf("    classDef %s fill:%s,stroke:%s,stroke-width:2px\n", component.ID, style.Fill, style.Stroke))
 		}
 		builder.WriteString(fmt.Sprintf("    class %s %s\n", component.ID, component.ID))
 	}
@@ -0,0 +1,233 @@
+package template
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PNG diagrams are drawn with a built-in 3x5 pixel font, scaled up, so
+// rendering needs no font files. Text is drawn in upper case.
+const (
+	glyphScale   = 2
+	glyphAdvance = 4 * glyphScale
+	glyphHeight  = 5 * glyphScale
+)
+
+// glyphs maps characters to their 3x5 bitmaps, top row first
+var glyphs = map[rune][5]string{
+	'A': {".#.", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {".##", "#..", "#..", "#..", ".##"},
+	'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'F': {"###", "#..", "##.", "#..", "#.."},
+	'G': {".##", "#..", "#.#", "#.#", ".##"},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", ".#."},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {".#.", "#.#", "#.#", "#.#", ".#."},
+	'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'Q': {".#.", "#.#", "#.#", "##.", ".##"},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {".##", "#..", ".#.", "..#", "##."},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#.#", "#.#", "###", "###", "#.#"},
+	'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"##.", "..#", ".#.", "#..", "###"},
+	'3': {"##.", "..#", ".#.", "..#", "##."},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "##.", "..#", "##."},
+	'6': {".##", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "##."},
+	' ': {"...", "...", "...", "...", "..."},
+	'-': {"...", "...", "###", "...", "..."},
+	'_': {"...", "...", "...", "...", "###"},
+	'.': {"...", "...", "...", "...", ".#."},
+	',': {"...", "...", "...", ".#.", "#.."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'(': {".#.", "#..", "#..", "#..", ".#."},
+	')': {".#.", "..#", "..#", "..#", ".#."},
+	'+': {"...", ".#.", "###", ".#.", "..."},
+	'>': {"#..", ".#.", "..#", ".#.", "#.."},
+	'→': {"#..", ".#.", "..#", ".#.", "#.."},
+	'?': {"##.", "..#", ".#.", "...", ".#."},
+}
+
+// renderPNG draws a laid out diagram as a PNG image
+func renderPNG(layout *diagramLayout) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(layout.Width)), int(math.Ceil(layout.Height))))
+	fillRect(img, img.Bounds(), color.White)
+
+	for _, wire := range layout.Wires {
+		wireColor := parseHexColor(wire.Color)
+		head := arrowHead(wire)
+		drawLine(img, wire.X1, wire.Y1, (head[1][0]+head[2][0])/2, (head[1][1]+head[2][1])/2, wireColor)
+		fillPolygon(img, head[:], wireColor)
+	}
+
+	for _, node := range layout.Nodes {
+		bounds := image.Rect(int(node.X-node.W/2)-1, int(node.Y-node.H/2)-1, int(node.X+node.W/2)+2, int(node.Y+node.H/2)+2)
+		fillShape(img, bounds, parseHexColor(node.style.Stroke), func(x, y float64) bool { return nodeContains(node, x, y, 0) })
+		fillShape(img, bounds, parseHexColor(node.style.Fill), func(x, y float64) bool { return nodeContains(node, x, y, 2) })
+		if node.Type == "display" {
+			for _, x := range []float64{node.X - node.W/2 + 8, node.X + node.W/2 - 8} {
+				drawLine(img, x, node.Y-node.H/2, x, node.Y+node.H/2, parseHexColor(node.style.Stroke))
+			}
+		}
+		drawText(img, node.Name, node.X, node.Y, color.Black)
+	}
+
+	for _, label := range layout.Labels {
+		longest := 0
+		for _, line := range label.Lines {
+			longest = max(longest, len([]rune(line)))
+		}
+		lineHeight := float64(glyphHeight + 4)
+		width, height := float64(longest*glyphAdvance+8), float64(len(label.Lines))*lineHeight+4
+		top := label.Y - height/2
+		fillRect(img, image.Rect(int(label.X-width/2), int(top), int(label.X+width/2), int(top+height)), color.White)
+		for i, line := range label.Lines {
+			drawText(img, line, label.X, top+2+lineHeight/2+float64(i)*lineHeight, color.Black)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeContains reports whether a point lies inside the shape of a node
+// shrunk by inset, which draws the outline as the difference of the two
+func nodeContains(node *diagramNode, x, y, inset float64) bool {
+	halfW, halfH := node.W/2-inset, node.H/2-inset
+	dx, dy := math.Abs(x-node.X), math.Abs(y-node.Y)
+	if halfW <= 0 || halfH <= 0 {
+		return false
+	}
+
+	switch node.Type {
+	case "actuator":
+		return dx/halfW+dy/halfH <= 1
+	case "communication":
+		return (dx*dx)/(halfW*halfW)+(dy*dy)/(halfH*halfH) <= 1
+	case "sensor":
+		radius := math.Min(12, halfH)
+		if dx <= halfW-radius || dy <= halfH-radius {
+			return dx <= halfW && dy <= halfH
+		}
+		return math.Hypot(dx-(halfW-radius), dy-(halfH-radius)) <= radius
+	case "power":
+		if dy > halfH {
+			return false
+		}
+		// The sides lean right by half the height, see nodeOutline
+		skew := node.H / 2
+		shift := -skew * (y - node.Y) / node.H
+		return math.Abs(x-node.X-shift) <= halfW-skew/2
+	}
+	return dx <= halfW && dy <= halfH
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
+
+// fillShape paints the pixels of bounds whose centres lie inside a shape
+func fillShape(img *image.RGBA, bounds image.Rectangle, c color.Color, contains func(x, y float64) bool) {
+	bounds = bounds.Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if contains(float64(x)+0.5, float64(y)+0.5) {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// fillPolygon paints a convex polygon
+func fillPolygon(img *image.RGBA, points [][2]float64, c color.Color) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = math.Min(minX, p[0]), math.Min(minY, p[1])
+		maxX, maxY = math.Max(maxX, p[0]), math.Max(maxY, p[1])
+	}
+	bounds := image.Rect(int(minX), int(minY), int(maxX)+1, int(maxY)+1)
+	fillShape(img, bounds, c, func(x, y float64) bool {
+		// Inside when on the same side of every edge
+		sign := 0.0
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			cross := (b[0]-a[0])*(y-a[1]) - (b[1]-a[1])*(x-a[0])
+			if cross != 0 {
+				if sign != 0 && (cross > 0) != (sign > 0) {
+					return false
+				}
+				sign = cross
+			}
+		}
+		return true
+	})
+}
+
+// drawLine paints a 2 pixel wide line
+func drawLine(img *image.RGBA, x1, y1, x2, y2 float64, c color.Color) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x, y := int(x1+(x2-x1)*t), int(y1+(y2-y1)*t)
+		fillRect(img, image.Rect(x, y, x+2, y+2), c)
+	}
+}
+
+// drawText paints text centred on (cx, cy). Characters without a glyph
+// are drawn as question marks.
+func drawText(img *image.RGBA, text string, cx, cy float64, c color.Color) {
+	runes := []rune(strings.ToUpper(text))
+	x0 := int(cx) - (len(runes)*glyphAdvance-glyphScale)/2
+	y0 := int(cy) - glyphHeight/2
+	for i, r := range runes {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		for row, bits := range glyph {
+			for col, bit := range bits {
+				if bit != '#' {
+					continue
+				}
+				x, y := x0+i*glyphAdvance+col*glyphScale, y0+row*glyphScale
+				fillRect(img, image.Rect(x, y, x+glyphScale, y+glyphScale), c)
+			}
+		}
+	}
+}
+
+// parseHexColor parses a #rrggbb colour, falling back to black
+func parseHexColor(hex string) color.RGBA {
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(hex) != 7 {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}
+}
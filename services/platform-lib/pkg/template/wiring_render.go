@@ -0,0 +1,559 @@
+package template
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiagramFormat is the image format a wiring diagram is rendered to
+type DiagramFormat string
+
+// Rendered wiring diagram formats
+const (
+	DiagramFormatSVG DiagramFormat = "svg"
+	DiagramFormatPNG DiagramFormat = "png"
+)
+
+// ErrInvalidDiagramParameters is returned for parameters the wiring diagram
+// cannot be generated from
+var ErrInvalidDiagramParameters = errors.New("invalid diagram parameters")
+
+// maxRenderedDiagrams bounds the rendered diagram cache
+const maxRenderedDiagrams = 256
+
+// Diagram layout, in pixels. It follows Mermaid's top-down graph layout:
+// each component sits one row below the components wired to it.
+const (
+	diagramMargin    = 24.0
+	diagramRowGap    = 110.0
+	diagramColumnGap = 40.0
+	diagramCharWidth = 8.0
+	diagramNodeWidth = 120.0
+	diagramWireGap   = 6.0
+	diagramArrowSize = 10.0
+)
+
+// RenderedDiagram is a wiring diagram rendered to an image
+type RenderedDiagram struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
+
+type componentStyle struct {
+	Fill   string
+	Stroke string
+}
+
+// componentStyles colour components by type, in Mermaid and rendered
+// diagrams alike
+var componentStyles = map[string]componentStyle{
+	"microcontroller": {Fill: "#e1f5fe", Stroke: "#01579b"},
+	"sensor":          {Fill: "#f3e5f5", Stroke: "#4a148c"},
+	"actuator":        {Fill: "#e8f5e8", Stroke: "#1b5e20"},
+	"display":         {Fill: "#fff3e0", Stroke: "#e65100"},
+	"communication":   {Fill: "#fce4ec", Stroke: "#880e4f"},
+}
+
+// defaultComponentStyle is Mermaid's default node style
+var defaultComponentStyle = componentStyle{Fill: "#ececff", Stroke: "#9370db"}
+
+var wireColors = map[string]string{
+	"red":    "#d32f2f",
+	"black":  "#212121",
+	"yellow": "#f9a825",
+	"blue":   "#1976d2",
+	"green":  "#388e3c",
+	"gray":   "#757575",
+}
+
+func componentStyleFor(componentType string) componentStyle {
+	if style, ok := componentStyles[componentType]; ok {
+		return style
+	}
+	return defaultComponentStyle
+}
+
+func wireColor(name string) string {
+	if color, ok := wireColors[name]; ok {
+		return color
+	}
+	return wireColors["gray"]
+}
+
+// diagramNode is a placed component. X and Y are its centre.
+type diagramNode struct {
+	ID    string
+	Name  string
+	Type  string
+	X, Y  float64
+	W, H  float64
+	style componentStyle
+}
+
+// diagramWire is a placed connection, drawn from (X1, Y1) to the arrow tip
+// at (X2, Y2)
+type diagramWire struct {
+	X1, Y1, X2, Y2 float64
+	Color          string
+}
+
+// diagramLabel lists the pins of the wires between two components
+type diagramLabel struct {
+	X, Y  float64
+	Lines []string
+}
+
+type diagramLayout struct {
+	Width, Height float64
+	Nodes         []*diagramNode
+	Wires         []diagramWire
+	Labels        []diagramLabel
+}
+
+// layoutWiringDiagram places the components of a diagram in rows and routes
+// a wire for every connection
+func layoutWiringDiagram(diagram *WiringDiagram) *diagramLayout {
+	layout := &diagramLayout{}
+	nodes := make(map[string]*diagramNode)
+	pinNames := make(map[string]string)
+	addNode := func(id, name, componentType string) {
+		if _, exists := nodes[id]; exists {
+			return
+		}
+		node := &diagramNode{ID: id, Name: name, Type: componentType, style: componentStyleFor(componentType)}
+		node.W, node.H = nodeSize(node)
+		nodes[id] = node
+		layout.Nodes = append(layout.Nodes, node)
+	}
+	for _, component := range diagram.Components {
+		addNode(component.ID, component.Name, component.Type)
+		for _, pin := range component.Pins {
+			pinNames[component.ID+"#"+pin.Number] = pin.Name
+		}
+	}
+	// Mermaid draws connections to undeclared components as plain nodes
+	for _, connection := range diagram.Connections {
+		addNode(connection.FromComponent, connection.FromComponent, "")
+		addNode(connection.ToComponent, connection.ToComponent, "")
+	}
+
+	// Rank components by their longest wiring path from a root. The
+	// iterations are bounded so that cycles terminate.
+	rank := make(map[string]int)
+	for i := 0; i < len(layout.Nodes); i++ {
+		changed := false
+		for _, connection := range diagram.Connections {
+			from, to := connection.FromComponent, connection.ToComponent
+			if from != to && rank[to] < rank[from]+1 {
+				rank[to] = rank[from] + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var rows [][]*diagramNode
+	for _, node := range layout.Nodes {
+		for len(rows) <= rank[node.ID] {
+			rows = append(rows, nil)
+		}
+		rows[rank[node.ID]] = append(rows[rank[node.ID]], node)
+	}
+
+	rowWidths := make([]float64, len(rows))
+	for i, row := range rows {
+		for j, node := range row {
+			if j > 0 {
+				rowWidths[i] += diagramColumnGap
+			}
+			rowWidths[i] += node.W
+		}
+		layout.Width = math.Max(layout.Width, rowWidths[i])
+	}
+	layout.Width += 2 * diagramMargin
+
+	y := diagramMargin
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		rowHeight := 0.0
+		for _, node := range row {
+			rowHeight = math.Max(rowHeight, node.H)
+		}
+		x := (layout.Width - rowWidths[i]) / 2
+		for _, node := range row {
+			node.X, node.Y = x+node.W/2, y+rowHeight/2
+			x += node.W + diagramColumnGap
+		}
+		y += rowHeight + diagramRowGap
+	}
+	layout.Height = y - diagramRowGap + diagramMargin
+
+	// Parallel wires between the same components are drawn side by side
+	// and share one label
+	type pair struct{ from, to string }
+	var pairs []pair
+	grouped := make(map[pair][]Connection)
+	for _, connection := range diagram.Connections {
+		p := pair{connection.FromComponent, connection.ToComponent}
+		if p.from == p.to {
+			continue
+		}
+		if _, exists := grouped[p]; !exists {
+			pairs = append(pairs, p)
+		}
+		grouped[p] = append(grouped[p], connection)
+	}
+
+	for _, p := range pairs {
+		from, to := nodes[p.from], nodes[p.to]
+		dx, dy := to.X-from.X, to.Y-from.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		ux, uy := dx/length, dy/length
+		px, py := -uy, ux
+
+		startX, startY := clipToNode(from, ux, uy)
+		endX, endY := clipToNode(to, -ux, -uy)
+
+		var lines []string
+		connections := grouped[p]
+		for i, connection := range connections {
+			offset := (float64(i) - float64(len(connections)-1)/2) * diagramWireGap
+			layout.Wires = append(layout.Wires, diagramWire{
+				X1:    startX + px*offset,
+				Y1:    startY + py*offset,
+				X2:    endX + px*offset,
+				Y2:    endY + py*offset,
+				Color: wireColor(connection.WireColor),
+			})
+			lines = append(lines, wireLabel(connection, pinNames))
+		}
+
+		// Labels sit nearer the child, where fanned-out wires are apart
+		layout.Labels = append(layout.Labels, diagramLabel{
+			X:     startX + (endX-startX)*0.6,
+			Y:     startY + (endY-startY)*0.6,
+			Lines: lines,
+		})
+	}
+
+	return layout
+}
+
+// nodeSize fits a node around its name. Diamonds and circles need more
+// room than boxes for the same text.
+func nodeSize(node *diagramNode) (float64, float64) {
+	width := math.Max(diagramNodeWidth, float64(len(node.Name))*diagramCharWidth+32)
+	switch node.Type {
+	case "actuator":
+		return width * 1.5, 72
+	case "communication":
+		return width * 1.3, 60
+	default:
+		return width, 44
+	}
+}
+
+// clipToNode returns where a ray from the centre of a node in direction
+// (ux, uy) leaves its bounding box
+func clipToNode(node *diagramNode, ux, uy float64) (float64, float64) {
+	t := math.Inf(1)
+	if ux != 0 {
+		t = math.Min(t, node.W/2/math.Abs(ux))
+	}
+	if uy != 0 {
+		t = math.Min(t, node.H/2/math.Abs(uy))
+	}
+	return node.X + ux*t, node.Y + uy*t
+}
+
+// wireLabel names the pins a wire joins, falling back to the wire colour
+// as Mermaid labels it
+func wireLabel(connection Connection, pinNames map[string]string) string {
+	toPin := connection.ToPin
+	if name, ok := pinNames[connection.ToComponent+"#"+toPin]; ok && name != "" {
+		toPin = name
+	}
+	switch {
+	case connection.FromPin != "" && toPin != "":
+		return connection.FromPin + " → " + toPin
+	case connection.FromPin != "":
+		return connection.FromPin
+	case connection.WireColor != "":
+		return connection.WireColor + " wire"
+	}
+	return ""
+}
+
+// arrowHead returns the corners of the arrow head at the end of a wire
+func arrowHead(wire diagramWire) [3][2]float64 {
+	dx, dy := wire.X2-wire.X1, wire.Y2-wire.Y1
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return [3][2]float64{{wire.X2, wire.Y2}, {wire.X2, wire.Y2}, {wire.X2, wire.Y2}}
+	}
+	ux, uy := dx/length, dy/length
+	baseX, baseY := wire.X2-ux*diagramArrowSize, wire.Y2-uy*diagramArrowSize
+	half := diagramArrowSize / 2
+	return [3][2]float64{
+		{wire.X2, wire.Y2},
+		{baseX - uy*half, baseY + ux*half},
+		{baseX + uy*half, baseY - ux*half},
+	}
+}
+
+// nodeOutline returns the polygon of a diamond or parallelogram node, or
+// nil for the other shapes
+func nodeOutline(node *diagramNode) [][2]float64 {
+	left, right := node.X-node.W/2, node.X+node.W/2
+	top, bottom := node.Y-node.H/2, node.Y+node.H/2
+	switch node.Type {
+	case "actuator":
+		return [][2]float64{{node.X, top}, {right, node.Y}, {node.X, bottom}, {left, node.Y}}
+	case "power":
+		skew := node.H / 2
+		return [][2]float64{{left + skew, top}, {right, top}, {right - skew, bottom}, {left, bottom}}
+	}
+	return nil
+}
+
+// RenderDiagramSVG draws a wiring diagram that was not generated from a
+// stored template, such as one edited by the user, as an SVG document
+func RenderDiagramSVG(diagram *WiringDiagram) []byte {
+	return renderSVG(layoutWiringDiagram(diagram))
+}
+
+// renderSVG draws a laid out diagram as an SVG document
+func renderSVG(layout *diagramLayout) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="14">`+"\n",
+		layout.Width, layout.Height, layout.Width, layout.Height)
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+
+	for _, wire := range layout.Wires {
+		head := arrowHead(wire)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n",
+			wire.X1, wire.Y1, (head[1][0]+head[2][0])/2, (head[1][1]+head[2][1])/2, wire.Color)
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s"/>`+"\n", svgPoints(head[:]), wire.Color)
+	}
+
+	for _, node := range layout.Nodes {
+		attrs := fmt.Sprintf(`fill="%s" stroke="%s" stroke-width="2"`, node.style.Fill, node.style.Stroke)
+		left, top := node.X-node.W/2, node.Y-node.H/2
+		switch {
+		case nodeOutline(node) != nil:
+			fmt.Fprintf(&b, `<polygon points="%s" %s/>`+"\n", svgPoints(nodeOutline(node)), attrs)
+		case node.Type == "communication":
+			fmt.Fprintf(&b, `<ellipse cx="%.1f" cy="%.1f" rx="%.1f" ry="%.1f" %s/>`+"\n", node.X, node.Y, node.W/2, node.H/2, attrs)
+		case node.Type == "sensor":
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="12" %s/>`+"\n", left, top, node.W, node.H, attrs)
+		default:
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" %s/>`+"\n", left, top, node.W, node.H, attrs)
+		}
+		if node.Type == "display" {
+			for _, x := range []float64{left + 8, left + node.W - 8} {
+				fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n", x, top, x, top+node.H, node.style.Stroke)
+			}
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n", node.X, node.Y, svgEscape(node.Name))
+	}
+
+	for _, label := range layout.Labels {
+		width, height := labelSize(label)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#ffffff" fill-opacity="0.85"/>`+"\n",
+			label.X-width/2, label.Y-height/2, width, height)
+		for i, line := range label.Lines {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+				label.X, label.Y-height/2+7+float64(i)*labelLineHeight, svgEscape(line))
+		}
+	}
+
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+const labelLineHeight = 14.0
+
+func labelSize(label diagramLabel) (float64, float64) {
+	longest := 0
+	for _, line := range label.Lines {
+		longest = max(longest, len([]rune(line)))
+	}
+	return float64(longest)*6.5 + 8, float64(len(label.Lines)) * labelLineHeight
+}
+
+func svgPoints(points [][2]float64) string {
+	formatted := make([]string, len(points))
+	for i, point := range points {
+		formatted[i] = fmt.Sprintf("%.1f,%.1f", point[0], point[1])
+	}
+	return strings.Join(formatted, " ")
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// diagramCache keeps recently rendered diagrams, evicting the oldest
+type diagramCache struct {
+	mu      sync.Mutex
+	entries map[string]*RenderedDiagram
+	order   []string
+	max     int
+}
+
+func newDiagramCache(max int) *diagramCache {
+	return &diagramCache{entries: make(map[string]*RenderedDiagram), max: max}
+}
+
+func (c *diagramCache) get(key string) (*RenderedDiagram, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rendered, ok := c.entries[key]
+	return rendered, ok
+}
+
+func (c *diagramCache) put(key string, rendered *RenderedDiagram) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; exists {
+		return
+	}
+	if len(c.order) >= c.max {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = rendered
+	c.order = append(c.order, key)
+}
+
+// diagramCacheKey identifies a rendering of a template version with a set
+// of parameters. Parameters are keyed by their JSON encoding, whose object
+// keys are sorted, and updates to the version change the key.
+func diagramCacheKey(tmpl *Template, parameters map[string]interface{}, format DiagramFormat) (string, error) {
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDiagramParameters, err)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		tmpl.ID, tmpl.Version, tmpl.UpdatedAt.UTC().Format(time.RFC3339Nano), string(format), string(encoded),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// RenderWiringDiagram renders the wiring diagram of a template version to
+// an SVG or PNG image. Parameters overlay the template defaults. Renders
+// are cached by template version and parameters.
+func (s *Service) RenderWiringDiagram(ctx context.Context, id, version string, parameters map[string]interface{}, format DiagramFormat) (*RenderedDiagram, error) {
+	if format != DiagramFormatSVG && format != DiagramFormatPNG {
+		return nil, fmt.Errorf("unsupported diagram format %q", format)
+	}
+
+	tmpl, err := s.GetTemplate(ctx, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s version %s", ErrTemplateNotFound, id, version)
+	}
+	parameters = mergeParameters(tmpl.Parameters, parameters)
+
+	key, err := diagramCacheKey(tmpl, parameters, format)
+	if err != nil {
+		return nil, err
+	}
+	if rendered, ok := s.diagrams.get(key); ok {
+		return rendered, nil
+	}
+
+	diagram, err := s.GenerateWiringDiagram(ctx, tmpl, parameters)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiagramParameters, err)
+	}
+
+	layout := layoutWiringDiagram(diagram)
+	rendered := &RenderedDiagram{ETag: `"` + key + `"`}
+	if format == DiagramFormatPNG {
+		rendered.ContentType = "image/png"
+		rendered.Data, err = renderPNG(layout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render diagram: %w", err)
+		}
+	} else {
+		rendered.ContentType = "image/svg+xml"
+		rendered.Data = renderSVG(layout)
+	}
+
+	s.diagrams.put(key, rendered)
+	return rendered, nil
+}
+
+func (s *Service) getWiringSVG(c *gin.Context) {
+	s.renderWiring(c, DiagramFormatSVG)
+}
+
+func (s *Service) getWiringPNG(c *gin.Context) {
+	s.renderWiring(c, DiagramFormatPNG)
+}
+
+// renderWiring serves a rendered wiring diagram. Parameters are passed as
+// a JSON object in the parameters query parameter.
+func (s *Service) renderWiring(c *gin.Context, format DiagramFormat) {
+	ctx := c.Request.Context()
+	templateID := c.Param("id")
+	version := c.Query("version")
+
+	if version == "" {
+		version = "latest" // Default to latest version
+	}
+
+	var parameters map[string]interface{}
+	if raw := c.Query("parameters"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &parameters); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid parameters", "details": err.Error()})
+			return
+		}
+	}
+
+	rendered, err := s.RenderWiringDiagram(ctx, templateID, version, parameters, format)
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	case errors.Is(err, ErrInvalidDiagramParameters):
+		c.JSON(422, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Error("Failed to render wiring diagram", "id", templateID, "version", version, "error", err)
+		c.JSON(500, gin.H{"error": "Failed to render wiring diagram", "details": err.Error()})
+		return
+	}
+
+	c.Header("ETag", rendered.ETag)
+	// Renders of a pinned version never change
+	if c.Query("version") != "" && c.Query("version") != "latest" {
+		c.Header("Cache-Control", "public, max-age=86400")
+	}
+	if c.GetHeader("If-None-Match") == rendered.ETag {
+		c.Status(304)
+		return
+	}
+	c.Data(200, rendered.ContentType, rendered.Data)
+}
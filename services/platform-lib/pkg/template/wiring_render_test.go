@@ -0,0 +1,114 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutWiringDiagram(t *testing.T) {
+	diagram, err := NewWiringDiagramGenerator().GenerateWiringDiagram(createDHT22Template(), map[string]interface{}{"dht_pin": 4})
+	require.NoError(t, err)
+
+	layout := layoutWiringDiagram(diagram)
+	require.Len(t, layout.Nodes, 2)
+
+	// The board sits above the components wired to it
+	board, sensor := layout.Nodes[0], layout.Nodes[1]
+	assert.Equal(t, "arduino", board.ID)
+	assert.Equal(t, "dht22", sensor.ID)
+	assert.Less(t, board.Y, sensor.Y)
+
+	// Signal, power and ground wires to the sensor share a label
+	var sensorLabel []string
+	for _, label := range layout.Labels {
+		if len(label.Lines) > 1 {
+			sensorLabel = label.Lines
+		}
+	}
+	assert.Contains(t, sensorLabel, "5V → VCC")
+	assert.Contains(t, sensorLabel, "GND → GND")
+	assert.Len(t, layout.Wires, len(diagram.Connections))
+
+	// Components only named by a connection are drawn as plain nodes
+	diagram.Connections = append(diagram.Connections, Connection{FromComponent: "dht22", ToComponent: "buzzer"})
+	layout = layoutWiringDiagram(diagram)
+	require.Len(t, layout.Nodes, 3)
+	assert.Equal(t, "buzzer", layout.Nodes[2].Name)
+	assert.Less(t, layout.Nodes[1].Y, layout.Nodes[2].Y)
+}
+
+func TestService_RenderWiringDiagram(t *testing.T) {
+	service := setupCompositionService(t, createDHT22Template())
+	ctx := context.Background()
+
+	svg, err := service.RenderWiringDiagram(ctx, "dht22-sensor", "latest", nil, DiagramFormatSVG)
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", svg.ContentType)
+	assert.NoError(t, xml.Unmarshal(svg.Data, new(struct{})))
+	assert.Contains(t, string(svg.Data), "DHT22 Sensor")
+
+	// Renders are cached by version and parameters
+	again, err := service.RenderWiringDiagram(ctx, "dht22-sensor", "1.0.0", map[string]interface{}{"dht_pin": 2}, DiagramFormatSVG)
+	require.NoError(t, err)
+	assert.Same(t, svg, again)
+
+	other, err := service.RenderWiringDiagram(ctx, "dht22-sensor", "1.0.0", map[string]interface{}{"dht_pin": 5}, DiagramFormatSVG)
+	require.NoError(t, err)
+	assert.NotEqual(t, svg.ETag, other.ETag)
+
+	rendered, err := service.RenderWiringDiagram(ctx, "dht22-sensor", "1.0.0", nil, DiagramFormatPNG)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(rendered.Data))
+	require.NoError(t, err)
+	assert.Greater(t, img.Bounds().Dx(), 100)
+
+	_, err = service.RenderWiringDiagram(ctx, "missing", "1.0.0", nil, DiagramFormatSVG)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	_, err = service.RenderWiringDiagram(ctx, "dht22-sensor", "1.0.0", map[string]interface{}{"dht_pin": "two"}, DiagramFormatSVG)
+	assert.ErrorIs(t, err, ErrInvalidDiagramParameters)
+}
+
+func TestService_WiringDiagramEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := setupCompositionService(t, createDHT22Template())
+	router := gin.New()
+	RegisterRoutes(router, service)
+
+	target := "/api/v1/templates/dht22-sensor/wiring.svg?version=1.0.0&parameters=" + url.QueryEscape(`{"dht_pin":4}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/dht22-sensor/wiring.png", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/dht22-sensor/wiring.svg?parameters=nope", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates/missing/wiring.svg", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
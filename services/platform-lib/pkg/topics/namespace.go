@@ -0,0 +1,186 @@
+package topics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTemplate is the topic layout used when none is configured
+const DefaultTemplate = "telemetry/{device_id}/{kind}"
+
+// Topic kinds published by devices
+const (
+	KindData      = "data"
+	KindHeartbeat = "heartbeat"
+	KindCrash     = "crash"
+	KindLog       = "log"
+)
+
+// KindCommand is the topic kind devices subscribe to for the commands sent
+// to them
+const KindCommand = "commands"
+
+// Template placeholders. Each must fill a whole topic level.
+const (
+	placeholderTenant = "{tenant}"
+	placeholderDevice = "{device_id}"
+	placeholderKind   = "{kind}"
+)
+
+// Namespace renders and parses device MQTT topics from a template such as
+// "{tenant}/devices/{device_id}/{kind}". Each tenant (project) gets its own
+// topic prefix, and a device only ever publishes under its own ID.
+type Namespace struct {
+	template string
+	tenant   string
+	levels   []string
+}
+
+// New creates a namespace from a topic template and tenant. An empty
+// template uses DefaultTemplate. The template must contain {device_id} and
+// {kind}; a tenant requires {tenant} and vice versa.
+func New(template, tenant string) (*Namespace, error) {
+	if template == "" {
+		template = DefaultTemplate
+	}
+
+	levels := strings.Split(template, "/")
+	counts := make(map[string]int)
+	for _, level := range levels {
+		switch level {
+		case placeholderTenant, placeholderDevice, placeholderKind:
+			counts[level]++
+			continue
+		}
+		if err := ValidateLevel(level); err != nil {
+			return nil, fmt.Errorf("invalid topic template %q: %w", template, err)
+		}
+		if strings.ContainsAny(level, "{}") {
+			return nil, fmt.Errorf("invalid topic template %q: placeholders must fill a whole level", template)
+		}
+	}
+
+	for _, placeholder := range []string{placeholderDevice, placeholderKind} {
+		if counts[placeholder] != 1 {
+			return nil, fmt.Errorf("invalid topic template %q: %s must appear exactly once", template, placeholder)
+		}
+	}
+	switch {
+	case counts[placeholderTenant] > 1:
+		return nil, fmt.Errorf("invalid topic template %q: %s must appear at most once", template, placeholderTenant)
+	case counts[placeholderTenant] == 1 && tenant == "":
+		return nil, fmt.Errorf("topic template %q requires a tenant", template)
+	case counts[placeholderTenant] == 0 && tenant != "":
+		return nil, fmt.Errorf("topic template %q has no %s level for tenant %q", template, placeholderTenant, tenant)
+	}
+	if tenant != "" {
+		if err := ValidateLevel(tenant); err != nil {
+			return nil, fmt.Errorf("invalid tenant: %w", err)
+		}
+	}
+
+	return &Namespace{template: template, tenant: tenant, levels: levels}, nil
+}
+
+// ValidateLevel checks that s can be used as a single topic level
+func ValidateLevel(s string) error {
+	if s == "" {
+		return fmt.Errorf("empty topic level")
+	}
+	if strings.ContainsAny(s, "/+#\x00") {
+		return fmt.Errorf("topic level %q must not contain '/', '+', '#' or NUL", s)
+	}
+	return nil
+}
+
+// Template returns the topic template
+func (n *Namespace) Template() string {
+	return n.template
+}
+
+// Tenant returns the namespace's tenant, if any
+func (n *Namespace) Tenant() string {
+	return n.tenant
+}
+
+// Topic returns the topic a device publishes kind messages on
+func (n *Namespace) Topic(deviceID, kind string) (string, error) {
+	if err := ValidateLevel(deviceID); err != nil {
+		return "", fmt.Errorf("invalid device ID: %w", err)
+	}
+	if err := ValidateLevel(kind); err != nil {
+		return "", fmt.Errorf("invalid topic kind: %w", err)
+	}
+	return n.render(deviceID, kind), nil
+}
+
+// Filter returns the subscription filter matching kind messages from every
+// device in the namespace. Use "+" as kind to match every kind.
+func (n *Namespace) Filter(kind string) string {
+	return n.render("+", kind)
+}
+
+// Parse extracts the device ID and kind from a topic in the namespace
+func (n *Namespace) Parse(topic string) (deviceID, kind string, ok bool) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(n.levels) {
+		return "", "", false
+	}
+
+	for i, level := range n.levels {
+		switch level {
+		case placeholderTenant:
+			if levels[i] != n.tenant {
+				return "", "", false
+			}
+		case placeholderDevice:
+			deviceID = levels[i]
+		case placeholderKind:
+			kind = levels[i]
+		default:
+			if levels[i] != level {
+				return "", "", false
+			}
+		}
+	}
+
+	if ValidateLevel(deviceID) != nil || ValidateLevel(kind) != nil {
+		return "", "", false
+	}
+	return deviceID, kind, true
+}
+
+// MosquittoACL renders a Mosquitto ACL for the namespace. Devices connect
+// with their device ID as username and may only publish and subscribe under
+// their own topics; serviceUser may read every device topic.
+func (n *Namespace) MosquittoACL(serviceUser string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated for topic template %s", n.template)
+	if n.tenant != "" {
+		fmt.Fprintf(&b, " (tenant %s)", n.tenant)
+	}
+	b.WriteString("\n\n# Devices authenticate with their device ID as username\n")
+	fmt.Fprintf(&b, "pattern readwrite %s\n", n.render("%u", "+"))
+	if serviceUser != "" {
+		fmt.Fprintf(&b, "\nuser %s\n", serviceUser)
+		fmt.Fprintf(&b, "topic read %s\n", n.Filter("+"))
+	}
+	return b.String()
+}
+
+func (n *Namespace) render(deviceID, kind string) string {
+	levels := make([]string, len(n.levels))
+	for i, level := range n.levels {
+		switch level {
+		case placeholderTenant:
+			levels[i] = n.tenant
+		case placeholderDevice:
+			levels[i] = deviceID
+		case placeholderKind:
+			levels[i] = kind
+		default:
+			levels[i] = level
+		}
+	}
+	return strings.Join(levels, "/")
+}
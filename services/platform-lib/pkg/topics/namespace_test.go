@@ -0,0 +1,85 @@
+package topics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Default(t *testing.T) {
+	ns, err := New("", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTemplate, ns.Template())
+
+	topic, err := ns.Topic("dev-1", KindData)
+	require.NoError(t, err)
+	assert.Equal(t, "telemetry/dev-1/data", topic)
+	assert.Equal(t, "telemetry/+/heartbeat", ns.Filter(KindHeartbeat))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		tenant   string
+	}{
+		{"missing device", "telemetry/{kind}", ""},
+		{"missing kind", "telemetry/{device_id}", ""},
+		{"repeated device", "{device_id}/{device_id}/{kind}", ""},
+		{"partial level", "dev-{device_id}/{kind}", ""},
+		{"wildcard", "telemetry/#/{device_id}/{kind}", ""},
+		{"empty level", "telemetry//{device_id}/{kind}", ""},
+		{"tenant without placeholder", "", "acme"},
+		{"placeholder without tenant", "{tenant}/{device_id}/{kind}", ""},
+		{"wildcard tenant", "{tenant}/{device_id}/{kind}", "acme/+"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.template, tt.tenant)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNamespace_TenantRoundTrip(t *testing.T) {
+	ns, err := New("{tenant}/devices/{device_id}/{kind}", "acme")
+	require.NoError(t, err)
+
+	topic, err := ns.Topic("dev-1", KindHeartbeat)
+	require.NoError(t, err)
+	assert.Equal(t, "acme/devices/dev-1/heartbeat", topic)
+	assert.Equal(t, "acme/devices/+/data", ns.Filter(KindData))
+
+	deviceID, kind, ok := ns.Parse(topic)
+	require.True(t, ok)
+	assert.Equal(t, "dev-1", deviceID)
+	assert.Equal(t, KindHeartbeat, kind)
+
+	// Other tenants and layouts are outside the namespace
+	for _, topic := range []string{"globex/devices/dev-1/data", "acme/devices/dev-1", "acme/things/dev-1/data"} {
+		_, _, ok := ns.Parse(topic)
+		assert.False(t, ok, topic)
+	}
+}
+
+func TestNamespace_TopicRejectsWildcards(t *testing.T) {
+	ns, err := New("", "")
+	require.NoError(t, err)
+
+	_, err = ns.Topic("+", KindData)
+	assert.Error(t, err)
+	_, err = ns.Topic("dev-1", "data/#")
+	assert.Error(t, err)
+}
+
+func TestNamespace_MosquittoACL(t *testing.T) {
+	ns, err := New("{tenant}/devices/{device_id}/{kind}", "acme")
+	require.NoError(t, err)
+
+	acl := ns.MosquittoACL("telemetry-service")
+	assert.Contains(t, acl, "pattern readwrite acme/devices/%u/+\n")
+	assert.Contains(t, acl, "user telemetry-service\ntopic read acme/devices/+/+\n")
+	assert.NotContains(t, ns.MosquittoACL(""), "user ")
+}
@@ -108,7 +108,7 @@ func (tm *TracingManager) initializeTracing() error {
 	// Create tracer
 	tm.tracer = tm.provider.Tracer(tm.config.ServiceName)
 
-	tm.logger.Infof("Tracing initialized with provider: %s", tm.config.Provider)
+	tm.logger.Info("Tracing initialized", "provider", tm.config.Provider)
 
 	return nil
 }
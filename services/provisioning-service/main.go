@@ -9,8 +9,11 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
 	"github.com/athena/platform-lib/pkg/provisioning"
 	"github.com/gin-gonic/gin"
 )
@@ -23,18 +26,29 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize service
 	service, err := provisioning.NewService(cfg, logger)
 	if err != nil {
-		logger.Fatalf("Failed to initialize provisioning service: %v", err)
+		logger.Fatal("Failed to initialize provisioning service", "error", err)
 	}
 
+	// Compile time is metered per project
+	usage := metering.NewRecorderFromConfig(cfg, logger, nil)
+	service.SetUsageRecorder(usage)
+	usage.Start()
+
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
 
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
+	// Operational tasks, run by administrators through the API gateway
+	tasks := admin.Setup(router, cfg, logger, service.AdminTasks()...)
+
 	// Register routes
 	provisioning.RegisterRoutes(router, service)
 
@@ -45,9 +59,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("Provisioning service starting on %s", cfg.HTTPPort)
+		logger.Info("Provisioning service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
@@ -58,12 +72,18 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Cancel running admin tasks
+	tasks.Stop()
+
+	// Report buffered usage
+	usage.Stop()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal("Server forced to shutdown", "error", err)
 	}
 
 	logger.Info("Server exited")
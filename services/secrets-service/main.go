@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
 	"github.com/athena/platform-lib/pkg/logger"
 	"github.com/athena/platform-lib/pkg/secrets"
@@ -24,7 +25,7 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize Datastore client
 	ctx := context.Background()
@@ -58,6 +59,9 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
 	// Add CORS middleware for development
 	if cfg.Environment == "development" {
 		router.Use(func(c *gin.Context) {
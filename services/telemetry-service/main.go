@@ -10,9 +10,18 @@ import (
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/cache"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/debugcapture"
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metering"
+	"github.com/athena/platform-lib/pkg/metrics"
+	"github.com/athena/platform-lib/pkg/middleware"
 	"github.com/athena/platform-lib/pkg/telemetry"
+	"github.com/athena/platform-lib/pkg/template"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,13 +33,13 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize Datastore client
 	ctx := context.Background()
 	datastoreClient, err := datastore.NewClient(ctx, cfg.DatastoreProject)
 	if err != nil {
-		logger.Fatalf("Failed to create Datastore client: %v", err)
+		logger.Fatal("Failed to create Datastore client", "error", err)
 	}
 	defer datastoreClient.Close()
 
@@ -39,23 +48,141 @@ func main() {
 		os.Setenv("DATASTORE_EMULATOR_HOST", cfg.DatastoreHost)
 	}
 
+	// Datastore calls are timed and counted per repository method
+	serviceMetrics := metrics.NewMetrics(cfg.ServiceName, *logger)
+
 	// Initialize repository
 	repository := telemetry.NewDatastoreRepository(datastoreClient)
 
+	hints := make([]telemetry.StorageHint, 0, len(cfg.Telemetry.StorageHints))
+	for _, hint := range cfg.Telemetry.StorageHints {
+		hints = append(hints, telemetry.StorageHint{
+			Metric:   hint.Metric,
+			Encoding: telemetry.StorageEncoding(hint.Encoding),
+			Scale:    hint.Scale,
+		})
+	}
+	if err := repository.SetStorageHints(hints); err != nil {
+		logger.Fatal("Invalid telemetry storage hints", "error", err)
+	}
+
 	// Initialize service
-	service, err := telemetry.NewService(cfg, logger, repository)
+	service, err := telemetry.NewService(cfg, logger, metrics.NewTelemetryRepository(repository, serviceMetrics))
 	if err != nil {
-		logger.Fatalf("Failed to initialize telemetry service: %v", err)
+		logger.Fatal("Failed to initialize telemetry service", "error", err)
+	}
+
+	// Device metadata drives template threshold inheritance, and cloud
+	// bridges mirror shadows and twins onto registered devices. Lookups are
+	// read through the device cache.
+	devices := cache.NewDeviceRepository(
+		metrics.NewDeviceRepository(device.NewDatastoreRepository(datastoreClient), serviceMetrics),
+		cache.NewStoreFromConfig(cfg, logger), cfg.Cache.DeviceTTL)
+	service.SetDeviceDirectory(devices)
+	service.SetDeviceStateStore(devices)
+
+	// Gateway sync marks survive restarts, so replayed buffers are not
+	// stored twice
+	service.SetSyncStateStore(telemetry.NewDatastoreSyncStateStore(datastoreClient))
+
+	// Asynchronous exports are written under telemetry.export_path and
+	// their jobs recorded in Datastore, so download links survive restarts
+	exports, err := telemetry.NewLocalExportStore(cfg.Telemetry.ExportPath)
+	if err != nil {
+		logger.Fatal("Failed to initialize export storage", "error", err)
+	}
+	service.SetExportStore(exports)
+	service.SetExportJobStore(telemetry.NewDatastoreExportJobStore(datastoreClient))
+
+	// Derived metric definitions, log alert rules and payload decoders are
+	// kept in Datastore and shared between replicas. Log alert match counts
+	// stay per replica.
+	if err := service.SetDerivedMetricStore(context.Background(), telemetry.NewDatastoreDerivedMetricStore(datastoreClient)); err != nil {
+		logger.Fatal("Failed to initialize derived metrics", "error", err)
+	}
+	if err := service.SetLogAlertRuleStore(context.Background(), telemetry.NewDatastoreLogAlertRuleStore(datastoreClient)); err != nil {
+		logger.Fatal("Failed to initialize log alert rules", "error", err)
+	}
+	if err := service.SetPayloadDecoderStore(context.Background(), telemetry.NewDatastorePayloadDecoderStore(datastoreClient)); err != nil {
+		logger.Fatal("Failed to initialize payload decoders", "error", err)
+	}
+
+	// Streams only deliver the devices and groups the caller can read
+	if cfg.Telemetry.StreamAuthorization {
+		service.SetStreamAuthorizer(telemetry.NewGroupStreamAuthorizer(devices, device.NewDatastoreGroupStore(datastoreClient)))
+	}
+
+	// Fired alerts are added to the device timelines kept by the device
+	// service
+	service.SetDeviceEvents(device.NewEventRecorder(device.NewDatastoreEventStore(datastoreClient), cfg.ServiceName, logger))
+
+	// Home Assistant discovery announces the metrics of each device's
+	// template telemetry schema, and fired alerts are sent to the owners of
+	// the devices' templates
+	templates := metrics.NewTemplateRepository(template.NewDatastoreRepository(datastoreClient), serviceMetrics)
+	service.SetTemplateDirectory(templates)
+	service.SetDeviceOwners(device.NewDeviceOwners(devices, templates))
+
+	// Stored telemetry points are metered per device project
+	usage := metering.NewRecorderFromConfig(cfg, logger, devices)
+	service.SetUsageRecorder(usage)
+	usage.Start()
+
+	for _, bridge := range cfg.Telemetry.CloudBridges {
+		if err := service.AddCloudBridge(telemetry.CloudBridgeConfig{
+			Name:          bridge.Name,
+			Provider:      telemetry.CloudProvider(bridge.Provider),
+			Direction:     telemetry.BridgeDirection(bridge.Direction),
+			Endpoint:      bridge.Endpoint,
+			TopicTemplate: bridge.TopicTemplate,
+			ClientID:      bridge.ClientID,
+			CertFile:      bridge.CertFile,
+			KeyFile:       bridge.KeyFile,
+			CAFile:        bridge.CAFile,
+			PolicyName:    bridge.PolicyName,
+			PolicyKey:     bridge.PolicyKey,
+			WebhookSecret: bridge.WebhookSecret,
+			SyncState:     bridge.SyncState,
+		}); err != nil {
+			logger.Fatal("Invalid cloud bridge configuration", "error", err)
+		}
+	}
+
+	for _, decoder := range cfg.Telemetry.LoRaWAN.Decoders {
+		if _, err := service.SetPayloadDecoder(context.Background(), decoder.TemplateID, &telemetry.PayloadDecoderRequest{
+			Fields:            decoder.Fields,
+			Ports:             decoder.Ports,
+			UseNetworkDecoded: decoder.UseNetworkDecoded,
+		}); err != nil {
+			logger.Fatal("Invalid payload decoder", "template_id", decoder.TemplateID, "error", err)
+		}
 	}
 
 	// Start the service (MQTT connections, etc.)
 	if err := service.Start(); err != nil {
-		logger.Fatalf("Failed to start telemetry service: %v", err)
+		logger.Fatal("Failed to start telemetry service", "error", err)
 	}
 
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
+	if cfg.Telemetry.StreamAuthorization {
+		router.Use(middleware.NewJWTAuth(cfg.JWTSecret, "athena-platform").OptionalAuth())
+	}
+
+	// Per-device request and response capture, started from the device service
+	debugcapture.Setup(router, cfg, logger.Component("debugcapture"))
+
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	if injector := chaos.Setup(router, cfg, logger); injector != nil {
+		service.SetMessageInterceptor(injector)
+	}
+
+	// Operational tasks, run by administrators through the API gateway
+	tasks := admin.Setup(router, cfg, logger, service.AdminTasks()...)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
 
 	// Register routes
 	telemetry.RegisterRoutes(router, service)
@@ -67,9 +194,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("Telemetry service starting on %s", cfg.HTTPPort)
+		logger.Info("Telemetry service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
@@ -80,15 +207,19 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Cancel running admin tasks
+	tasks.Stop()
+
 	// Stop the service
 	service.Stop()
+	usage.Stop()
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Fatal("Server forced to shutdown", "error", err)
 	}
 
 	logger.Info("Server exited")
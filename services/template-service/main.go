@@ -9,9 +9,16 @@ import (
 	"time"
 
 	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/admin"
+	"github.com/athena/platform-lib/pkg/chaos"
 	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
 	"github.com/athena/platform-lib/pkg/errors"
 	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/metrics"
+	"github.com/athena/platform-lib/pkg/notifications"
+	"github.com/athena/platform-lib/pkg/ota"
+	"github.com/athena/platform-lib/pkg/telemetry"
 	"github.com/athena/platform-lib/pkg/template"
 	"github.com/gin-gonic/gin"
 )
@@ -24,7 +31,7 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel, cfg.ServiceName)
+	logger := logger.FromConfig(cfg)
 
 	// Initialize Datastore client
 	ctx := context.Background()
@@ -34,17 +41,71 @@ func main() {
 	}
 	defer datastoreClient.Close()
 
+	// Datastore calls are timed and counted per repository method
+	serviceMetrics := metrics.NewMetrics(cfg.ServiceName, *logger)
+
 	// Initialize service with Datastore repository
-	repo := template.NewDatastoreRepository(datastoreClient)
+	repo := metrics.NewTemplateRepository(template.NewDatastoreRepository(datastoreClient), serviceMetrics)
 	service, err := template.NewService(cfg, logger, repo)
 	if err != nil {
 		errors.HandleServiceError("Failed to initialize template service", err)
 	}
 
+	// Versions used by devices or firmware releases are not deleted unless
+	// forced. Both live in the same Datastore project.
+	devices := metrics.NewDeviceRepository(device.NewDatastoreRepository(datastoreClient), serviceMetrics)
+	deviceReferences := device.NewTemplateReferences(devices)
+	service.SetReferenceFinders(
+		deviceReferences,
+		ota.NewTemplateReferences(metrics.NewOTARepository(ota.NewDatastoreRepository(datastoreClient), serviceMetrics)),
+	)
+
+	// Publishers whose signed bundles are trusted are kept in Datastore
+	service.SetPublisherStore(template.NewDatastorePublisherStore(datastoreClient))
+
+	// The driver snippet library is the built-in snippets plus the changes
+	// made through the API, which are kept in Datastore
+	service.SetDriverStore(template.NewDatastoreDriverStore(datastoreClient))
+
+	// Build history behind the compatibility matrix and analytics
+	service.SetBuildRecordStore(template.NewDatastoreBuildRecordStore(datastoreClient))
+
+	// Preview images are kept under templates.image_path
+	images, err := template.NewLocalImageStore(cfg.Templates.ImagePath)
+	if err != nil {
+		errors.HandleServiceError("Failed to initialize template image storage", err)
+	}
+	service.SetImageStore(images)
+
+	// Template analytics count the devices built from each template
+	service.SetUsageFinders(deviceReferences)
+
+	// Parameter schemas recommend values from the fleet's telemetry
+	service.SetParameterRecommenders(telemetry.NewParameterRecommender(devices,
+		metrics.NewTelemetryRepository(telemetry.NewDatastoreRepository(datastoreClient), serviceMetrics)))
+
+	// Published templates are rebuilt against new board cores and library
+	// releases on the provisioning service, and owners told what broke
+	var regression *template.RegressionRunner
+	if provisioningURL := cfg.Services["provisioning-service"]; cfg.Templates.RegressionBuilds && provisioningURL != "" {
+		regression = template.NewRegressionRunner(service, template.NewHTTPRegressionBuilder(provisioningURL), cfg)
+		regression.SetPublisher(notifications.NewPublisherFromConfig(cfg))
+		regression.Start(ctx)
+	}
+
 	// Setup HTTP server
 	router := gin.New()
 	router.Use(gin.Recovery())
 
+	// Fault injection for resilience testing (chaos.enabled, never in production)
+	chaos.Setup(router, cfg, logger)
+
+	// Operational tasks, run by administrators through the API gateway
+	tasks := admin.Setup(router, cfg, logger, template.AdminTasks(repo)...)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(serviceMetrics.Handler()))
+
 	// Register routes
 	template.RegisterRoutes(router, service)
 
@@ -55,7 +116,7 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		logger.Infof("Template service starting on %s", cfg.HTTPPort)
+		logger.Info("Template service starting", "port", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			errors.HandleNetworkError("Failed to start server", err)
 		}
@@ -68,6 +129,12 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
+	// Cancel running admin tasks
+	tasks.Stop()
+	if regression != nil {
+		regression.Stop()
+	}
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -0,0 +1,145 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/athena/e2e/harness"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/ota"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var env *harness.Environment
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	var err error
+	env, err = harness.Start(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start e2e environment: %v\n", err)
+		if env != nil {
+			env.Close()
+		}
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	if err := env.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to tear down e2e environment: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// TestTemplateToOTAFlow follows a device from template authoring through
+// registration and telemetry to a completed firmware update
+func TestTemplateToOTAFlow(t *testing.T) {
+	ctx := context.Background()
+	suffix := time.Now().Format("150405")
+	templateID := "e2e-temperature-" + suffix
+	deviceID := "e2e-device-" + suffix
+
+	gateway, err := env.Login("admin", "admin123")
+	require.NoError(t, err)
+
+	// Unauthenticated requests are rejected before reaching a service
+	status, err := env.Gateway().Do(http.MethodGet, "/api/v1/templates", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// Author and render a template through the gateway
+	var created template.Template
+	status, err = gateway.Do(http.MethodPost, "/api/v1/templates", harness.SampleTemplate(templateID), &created)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, status)
+
+	var preview template.PreviewResult
+	status, err = gateway.Do(http.MethodPost, "/api/v1/templates/"+templateID+"/preview", &template.PreviewRequest{
+		Parameters: map[string]interface{}{"interval_ms": 1000},
+	}, &preview)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, preview.RenderedCode, "delay(1000);")
+
+	// Provisioning registers the flashed device
+	registered, err := env.RegisterDevice(&device.DeviceRegistrationRequest{
+		DeviceID:        deviceID,
+		BoardType:       "esp32:esp32:esp32",
+		TemplateID:      templateID,
+		TemplateVersion: created.Version,
+		Parameters:      preview.Parameters,
+		FirmwareHash:    harness.FirmwareHash(preview.RenderedCode),
+		OTAChannel:      string(ota.ReleaseChannelStable),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, deviceID, registered.DeviceID)
+
+	var fetched device.Device
+	status, err = gateway.Do(http.MethodGet, "/api/v1/devices/"+deviceID, nil, &fetched)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, templateID, fetched.TemplateID)
+
+	// Telemetry published over MQTT is readable through the gateway
+	require.NoError(t, env.PublishTelemetry(deviceID, map[string]interface{}{"temperature": 21.5}))
+	require.Eventually(t, func() bool {
+		var metrics struct {
+			Count int `json:"count"`
+		}
+		status, err := gateway.Do(http.MethodGet, "/api/v1/telemetry/metrics/"+deviceID, nil, &metrics)
+		return err == nil && status == http.StatusOK && metrics.Count > 0
+	}, 30*time.Second, 500*time.Millisecond, "telemetry never reached the store")
+
+	// Ship a firmware update to the device
+	release, err := env.CreateRelease(ctx, templateID, "1.0.1", ota.ReleaseChannelStable, []byte("e2e firmware "+suffix))
+	require.NoError(t, err)
+
+	var deployment ota.OTADeployment
+	status, err = gateway.Do(http.MethodPost, "/api/v1/ota/deployments", map[string]interface{}{
+		"release_id": release.ReleaseID,
+		"config": ota.DeploymentConfig{
+			Strategy:      ota.DeploymentStrategyImmediate,
+			TargetDevices: []string{deviceID},
+		},
+	}, &deployment)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, ota.DeploymentStatusActive, deployment.Status)
+
+	// The device polls for its update and reports the install directly
+	otaService := env.Service("ota-service")
+	var update ota.FirmwareUpdate
+	status, err = otaService.Do(http.MethodGet, "/api/v1/ota/updates/"+deviceID, nil, &update)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, release.ReleaseID, update.ReleaseID)
+	assert.Equal(t, release.BinaryHash, update.BinaryHash)
+
+	status, err = otaService.Do(http.MethodPost, "/api/v1/ota/updates/status", &ota.UpdateStatusReport{
+		DeviceID:  deviceID,
+		ReleaseID: release.ReleaseID,
+		Status:    ota.UpdateStatusCompleted,
+		Progress:  100,
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+
+	var history struct {
+		Updates []*ota.DeviceUpdate `json:"updates"`
+	}
+	status, err = gateway.Do(http.MethodGet, "/api/v1/ota/devices/"+deviceID+"/updates", nil, &history)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, history.Updates, 1)
+	assert.Equal(t, ota.UpdateStatusCompleted, history.Updates[0].Status)
+	assert.NotNil(t, history.Updates[0].CompletedAt)
+}
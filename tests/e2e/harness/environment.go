@@ -0,0 +1,249 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"cloud.google.com/go/datastore"
+	"github.com/athena/platform-lib/pkg/config"
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/gateway"
+	"github.com/athena/platform-lib/pkg/logger"
+	"github.com/athena/platform-lib/pkg/ota"
+	"github.com/athena/platform-lib/pkg/provisioning"
+	"github.com/athena/platform-lib/pkg/telemetry"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/athena/platform-lib/pkg/topics"
+	"github.com/gin-gonic/gin"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Services are the platform services an environment runs, in start order.
+// The NLP service needs an LLM provider and is not part of the topology.
+var Services = []string{
+	"template-service",
+	"provisioning-service",
+	"device-service",
+	"telemetry-service",
+	"ota-service",
+	"api-gateway",
+}
+
+// JWTSecret signs gateway tokens in the environment
+const JWTSecret = "athena-e2e-secret"
+
+// Environment is a running platform: infrastructure containers plus every
+// service listening on a local port
+type Environment struct {
+	// DatastoreHost and BrokerURL address the emulator and MQTT broker
+	DatastoreHost string
+	BrokerURL     string
+	Topics        *topics.Namespace
+
+	servers    map[string]*httptest.Server
+	containers []testcontainers.Container
+	datastore  *datastore.Client
+	closers    []func()
+}
+
+// Start boots the infrastructure containers and all services. Call Close
+// when done, including when Start returns an error.
+func Start(ctx context.Context) (*Environment, error) {
+	gin.SetMode(gin.TestMode)
+	env := &Environment{servers: make(map[string]*httptest.Server)}
+
+	addresses, containers, err := startInfrastructure(ctx, Topology())
+	env.containers = containers
+	if err != nil {
+		return env, err
+	}
+	env.DatastoreHost = addresses["datastore-emulator"]
+	env.BrokerURL = "tcp://" + addresses["mosquitto"]
+
+	// The Datastore client picks the emulator up from the environment
+	if err := os.Setenv("DATASTORE_EMULATOR_HOST", env.DatastoreHost); err != nil {
+		return env, err
+	}
+	env.datastore, err = datastore.NewClient(ctx, DatastoreProject)
+	if err != nil {
+		return env, fmt.Errorf("failed to create Datastore client: %w", err)
+	}
+
+	// Reserve every port first so each service is configured with the
+	// addresses of the others
+	listeners := make(map[string]net.Listener, len(Services))
+	urls := make(map[string]string, len(Services))
+	for _, name := range Services {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return env, fmt.Errorf("failed to reserve a port for %s: %w", name, err)
+		}
+		listeners[name] = listener
+		urls[name] = "http://" + listener.Addr().String()
+	}
+
+	for _, name := range Services {
+		cfg := env.config(name, urls)
+		handler, err := env.buildService(cfg)
+		if err != nil {
+			listeners[name].Close()
+			return env, fmt.Errorf("failed to start %s: %w", name, err)
+		}
+
+		server := &httptest.Server{Listener: listeners[name], Config: &http.Server{Handler: handler}}
+		server.Start()
+		env.servers[name] = server
+	}
+
+	return env, nil
+}
+
+// config returns a service's configuration pointing at the environment
+func (e *Environment) config(name string, urls map[string]string) *config.Config {
+	cfg := config.Default(name)
+	cfg.Environment = "test"
+	cfg.LogLevel = envOr("ATHENA_E2E_LOG_LEVEL", "warn")
+	cfg.DatastoreProject = DatastoreProject
+	cfg.DatastoreHost = e.DatastoreHost
+	cfg.MQTT.BrokerURL = e.BrokerURL
+	cfg.JWTSecret = JWTSecret
+	cfg.Onboarding.ReportURL = urls["api-gateway"] + "/api/v1/devices/{device_id}/onboarding"
+
+	cfg.Services = make(map[string]string, len(urls))
+	for service, url := range urls {
+		cfg.Services[service] = url
+	}
+	return cfg
+}
+
+// buildService wires a service the way its main package does and returns
+// its HTTP handler
+func (e *Environment) buildService(cfg *config.Config) (http.Handler, error) {
+	log := logger.New(cfg.LogLevel, cfg.ServiceName)
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	// The gateway's service registry probes /health on every instance it
+	// proxies to; the gateway registers its own
+	if cfg.ServiceName != "api-gateway" {
+		router.GET("/health", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "healthy", "service": cfg.ServiceName})
+		})
+	}
+
+	switch cfg.ServiceName {
+	case "template-service":
+		service, err := template.NewService(cfg, log, template.NewDatastoreRepository(e.datastore))
+		if err != nil {
+			return nil, err
+		}
+		template.RegisterRoutes(router, service)
+
+	case "provisioning-service":
+		service, err := provisioning.NewService(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		provisioning.RegisterRoutes(router, service)
+
+	case "device-service":
+		service, err := device.NewService(cfg, log, device.NewDatastoreRepository(e.datastore))
+		if err != nil {
+			return nil, err
+		}
+		device.RegisterRoutes(router, service)
+
+	case "telemetry-service":
+		service, err := telemetry.NewService(cfg, log, telemetry.NewDatastoreRepository(e.datastore))
+		if err != nil {
+			return nil, err
+		}
+		devices := device.NewDatastoreRepository(e.datastore)
+		service.SetDeviceDirectory(devices)
+		service.SetDeviceStateStore(devices)
+		if err := service.Start(); err != nil {
+			return nil, err
+		}
+		e.closers = append(e.closers, service.Stop)
+		telemetry.RegisterRoutes(router, service)
+
+		e.Topics, err = topics.New(cfg.MQTT.TopicTemplate, cfg.MQTT.Tenant)
+		if err != nil {
+			return nil, err
+		}
+
+	case "ota-service":
+		privateKey, publicKey, err := ota.GenerateKeyPair(2048)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ota.NewSigner(privateKey, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		storageDir, err := os.MkdirTemp("", "athena-e2e-ota-")
+		if err != nil {
+			return nil, err
+		}
+		e.closers = append(e.closers, func() { os.RemoveAll(storageDir) })
+		storage, err := ota.NewLocalStorageBackend(storageDir)
+		if err != nil {
+			return nil, err
+		}
+		service, err := ota.NewService(cfg, log, ota.NewDatastoreRepository(e.datastore), device.NewDatastoreRepository(e.datastore), signer, storage)
+		if err != nil {
+			return nil, err
+		}
+		ota.RegisterRoutes(router, service)
+
+	case "api-gateway":
+		gw, err := gateway.NewGateway(cfg, log)
+		if err != nil {
+			return nil, err
+		}
+		e.closers = append(e.closers, func() { gw.Shutdown() })
+		gateway.RegisterRoutes(router, gw)
+
+	default:
+		return nil, fmt.Errorf("unknown service %s", cfg.ServiceName)
+	}
+
+	return router, nil
+}
+
+// URL returns the base URL of a service, e.g. URL("api-gateway")
+func (e *Environment) URL(service string) string {
+	server, ok := e.servers[service]
+	if !ok {
+		panic("harness: service not running: " + service)
+	}
+	return server.URL
+}
+
+// Datastore returns a client for the emulator, for tests that inspect or
+// seed stored entities directly
+func (e *Environment) Datastore() *datastore.Client {
+	return e.datastore
+}
+
+// Close stops the services and terminates the containers
+func (e *Environment) Close() error {
+	for _, server := range e.servers {
+		server.Close()
+	}
+	for i := len(e.closers) - 1; i >= 0; i-- {
+		e.closers[i]()
+	}
+
+	var errs []error
+	if e.datastore != nil {
+		errs = append(errs, e.datastore.Close())
+	}
+	errs = append(errs, terminate(e.containers))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,229 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/athena/platform-lib/pkg/device"
+	"github.com/athena/platform-lib/pkg/ota"
+	"github.com/athena/platform-lib/pkg/template"
+	"github.com/athena/platform-lib/pkg/topics"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Client sends JSON requests to one service, optionally with a bearer token
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// Gateway returns an unauthenticated client for the API gateway
+func (e *Environment) Gateway() *Client {
+	return &Client{BaseURL: e.URL("api-gateway"), HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Service returns a client that talks to a service directly, bypassing the
+// gateway the way devices and internal callers do
+func (e *Environment) Service(name string) *Client {
+	return &Client{BaseURL: e.URL(name), HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Login authenticates against the gateway and returns a client carrying
+// the issued token
+func (e *Environment) Login(username, password string) (*Client, error) {
+	client := e.Gateway()
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	status, err := client.Do(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"username": username,
+		"password": password,
+	}, &login)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || login.Token == "" {
+		return nil, fmt.Errorf("login as %s failed with status %d", username, status)
+	}
+
+	client.Token = login.Token
+	return client, nil
+}
+
+// Do sends body as JSON and decodes a JSON response into out when out is
+// non-nil. Non-2xx responses are not errors; the status is returned for the
+// test to assert on.
+func (c *Client) Do(method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req, out)
+}
+
+func (c *Client) send(req *http.Request, out interface{}) (int, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if out != nil && resp.StatusCode < 300 && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %s %s response: %w", req.Method, req.URL.Path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// SampleTemplate returns a minimal ESP32 template whose sketch reads a
+// temperature sensor
+func SampleTemplate(id string) *template.Template {
+	return &template.Template{
+		ID:              id,
+		Name:            "E2E Temperature Sensor",
+		Version:         "1.0.0",
+		Category:        "sensing",
+		Description:     "Publishes a temperature reading every interval",
+		BoardsSupported: []string{"esp32:esp32:esp32"},
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"interval_ms": map[string]interface{}{"type": "integer", "minimum": 100},
+			},
+		},
+		Parameters: map[string]interface{}{"interval_ms": 5000},
+		Assets: []template.Asset{
+			{
+				Type: "code",
+				Path: "main.ino",
+				Metadata: map[string]interface{}{
+					"content": "void setup() {\n  Serial.begin(115200);\n}\n\nvoid loop() {\n  Serial.println(temperatureRead());\n  delay({{.interval_ms}});\n}\n",
+				},
+			},
+		},
+	}
+}
+
+// FirmwareHash returns the hash devices report for rendered code
+func FirmwareHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// RegisterDevice registers a device with the device service the way
+// provisioning does after flashing
+func (e *Environment) RegisterDevice(req *device.DeviceRegistrationRequest) (*device.Device, error) {
+	var registered device.Device
+	status, err := e.Service("device-service").Do(http.MethodPost, "/api/v1/devices", req, &registered)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusCreated {
+		return nil, fmt.Errorf("device registration failed with status %d", status)
+	}
+	return &registered, nil
+}
+
+// PublishTelemetry publishes one reading over MQTT as the device would
+func (e *Environment) PublishTelemetry(deviceID string, metrics map[string]interface{}) error {
+	topic, err := e.Topics.Topic(deviceID, topics.KindData)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]interface{}{"metrics": metrics})
+	if err != nil {
+		return err
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(e.BrokerURL).
+		SetClientID("e2e-" + deviceID).
+		SetConnectTimeout(10 * time.Second)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %v", token.Error())
+	}
+	defer client.Disconnect(250)
+
+	token := client.Publish(topic, 1, false, payload)
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		return fmt.Errorf("failed to publish telemetry: %v", token.Error())
+	}
+	return nil
+}
+
+// CreateRelease uploads a firmware binary to the OTA service. Uploads are
+// multipart, so they go to the service directly rather than through the
+// gateway's JSON-validated route.
+func (e *Environment) CreateRelease(ctx context.Context, templateID, version string, channel ota.ReleaseChannel, binary []byte) (*ota.FirmwareRelease, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for field, value := range map[string]string{
+		"template_id": templateID,
+		"version":     version,
+		"channel":     string(channel),
+		"created_by":  "e2e",
+	} {
+		if err := writer.WriteField(field, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := writer.CreateFormFile("binary", "firmware.bin")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(binary); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	client := e.Service("ota-service")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BaseURL+"/api/v1/ota/releases", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var release ota.FirmwareRelease
+	status, err := client.send(req, &release)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusCreated {
+		return nil, fmt.Errorf("release upload failed with status %d", status)
+	}
+	return &release, nil
+}
@@ -0,0 +1,124 @@
+// Package harness boots the ATHENA platform for end-to-end tests: the
+// infrastructure from docker-compose.yml runs in containers, and the
+// services run in-process against it so tests can exercise complete flows
+// through the API gateway.
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// DatastoreProject is the project the services use in the emulator
+	DatastoreProject = "athena-e2e"
+
+	defaultDatastoreImage = "gcr.io/google.com/cloudsdktool/google-cloud-cli:emulators"
+	defaultMosquittoImage = "eclipse-mosquitto:2"
+
+	startupTimeout = 3 * time.Minute
+)
+
+// Infrastructure is a container the services depend on, mirroring an entry
+// in docker-compose.yml
+type Infrastructure struct {
+	Name    string
+	Image   string
+	Port    nat.Port
+	Cmd     []string
+	Waiting wait.Strategy
+}
+
+// Topology lists the infrastructure containers an environment starts.
+// Images can be overridden with ATHENA_E2E_DATASTORE_IMAGE and
+// ATHENA_E2E_MOSQUITTO_IMAGE, e.g. to use a registry mirror.
+func Topology() []Infrastructure {
+	return []Infrastructure{
+		{
+			Name:  "datastore-emulator",
+			Image: envOr("ATHENA_E2E_DATASTORE_IMAGE", defaultDatastoreImage),
+			Port:  "8081/tcp",
+			Cmd: []string{
+				"gcloud", "beta", "emulators", "datastore", "start",
+				"--host-port=0.0.0.0:8081",
+				"--project=" + DatastoreProject,
+				"--no-store-on-disk",
+				"--consistency=1.0",
+			},
+			Waiting: wait.ForHTTP("/").WithPort("8081/tcp").WithStartupTimeout(startupTimeout),
+		},
+		{
+			Name:    "mosquitto",
+			Image:   envOr("ATHENA_E2E_MOSQUITTO_IMAGE", defaultMosquittoImage),
+			Port:    "1883/tcp",
+			Cmd:     []string{"mosquitto", "-c", "/mosquitto-no-auth.conf"},
+			Waiting: wait.ForListeningPort("1883/tcp").WithStartupTimeout(startupTimeout),
+		},
+	}
+}
+
+// startInfrastructure starts the topology and returns the host address of
+// each container's port by name. Containers that started are returned even
+// on error so the caller can terminate them.
+func startInfrastructure(ctx context.Context, topology []Infrastructure) (map[string]string, []testcontainers.Container, error) {
+	addresses := make(map[string]string, len(topology))
+	var containers []testcontainers.Container
+
+	for _, infra := range topology {
+		container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+			ContainerRequest: testcontainers.ContainerRequest{
+				Image:        infra.Image,
+				Cmd:          infra.Cmd,
+				ExposedPorts: []string{string(infra.Port)},
+				WaitingFor:   infra.Waiting,
+			},
+			Started: true,
+		})
+		if container != nil {
+			containers = append(containers, container)
+		}
+		if err != nil {
+			return nil, containers, fmt.Errorf("failed to start %s: %w", infra.Name, err)
+		}
+
+		host, err := container.Host(ctx)
+		if err != nil {
+			return nil, containers, fmt.Errorf("failed to get %s host: %w", infra.Name, err)
+		}
+		port, err := container.MappedPort(ctx, infra.Port)
+		if err != nil {
+			return nil, containers, fmt.Errorf("failed to get %s port: %w", infra.Name, err)
+		}
+		addresses[infra.Name] = fmt.Sprintf("%s:%s", host, port.Port())
+	}
+
+	return addresses, containers, nil
+}
+
+// terminate stops containers, newest first
+func terminate(containers []testcontainers.Container) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var errs []error
+	for i := len(containers) - 1; i >= 0; i-- {
+		if err := containers[i].Terminate(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -1,8 +1,17 @@
 import type { NextConfig } from "next";
 
+// NEXT_OUTPUT=export produces a static bundle that the API gateway embeds
+// (see `make dashboard-embed`); NEXT_BASE_PATH must match dashboard.base_path.
+const staticExport = process.env.NEXT_OUTPUT === "export";
+
 const nextConfig: NextConfig = {
   /* config options here */
   reactCompiler: true,
+  ...(staticExport && {
+    output: "export",
+    basePath: process.env.NEXT_BASE_PATH || undefined,
+    images: { unoptimized: true },
+  }),
 };
 
 export default nextConfig;
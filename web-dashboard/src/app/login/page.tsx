@@ -2,8 +2,8 @@
 
 import { useState, useEffect, useCallback } from "react";
 import { useRouter } from "next/navigation";
-import { login } from "@/lib/auth/client";
-import type { LoginResponse } from "@/lib/auth/types";
+import { login, ssoLoginUrl, ssoProviders, ssoSession } from "@/lib/auth/client";
+import type { LoginResponse, SsoProvider } from "@/lib/auth/types";
 
 export default function LoginPage() {
   const router = useRouter();
@@ -12,6 +12,44 @@ export default function LoginPage() {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const [isDemoMode, setIsDemoMode] = useState(false);
+  const [providers, setProviders] = useState<SsoProvider[]>([]);
+
+  const storeSession = useCallback((res: LoginResponse, demoMode: boolean) => {
+    if (typeof window !== "undefined") {
+      window.localStorage.setItem("athena_access_token", res.accessToken);
+      window.localStorage.setItem("athena_refresh_token", res.refreshToken);
+      window.localStorage.setItem("athena_user", JSON.stringify(res.user));
+      window.localStorage.setItem("athena_demo_mode", demoMode.toString());
+    }
+  }, []);
+
+  // List SSO providers and finish an SSO login when the gateway redirects back
+  useEffect(() => {
+    ssoProviders()
+      .then(setProviders)
+      .catch(() => setProviders([]));
+
+    if (new URLSearchParams(window.location.search).get("sso") !== "1") return;
+
+    ssoSession()
+      .then((session) => {
+        // The refresh token stays in the HttpOnly session cookie
+        storeSession(
+          {
+            accessToken: session.token,
+            refreshToken: "",
+            user: {
+              id: session.user.id,
+              email: session.user.username,
+              roles: session.user.roles,
+            },
+          },
+          false
+        );
+        router.push("/dashboard");
+      })
+      .catch((err) => setError(err instanceof Error ? err.message : "SSO login failed"));
+  }, [router, storeSession]);
 
   const handleSubmit = useCallback(async (e: React.FormEvent) => {
     e.preventDefault();
@@ -44,20 +82,14 @@ export default function LoginPage() {
         res = await login({ email, password });
       }
       
-      if (typeof window !== "undefined") {
-        window.localStorage.setItem("athena_access_token", res.accessToken);
-        window.localStorage.setItem("athena_refresh_token", res.refreshToken);
-        window.localStorage.setItem("athena_user", JSON.stringify(res.user));
-        window.localStorage.setItem("athena_demo_mode", isDemoMode.toString());
-      }
-      
+      storeSession(res, isDemoMode);
       router.push("/dashboard");
     } catch (err) {
       setError(err instanceof Error ? err.message : "Login failed");
     } finally {
       setLoading(false);
     }
-  }, [email, password, router, isDemoMode]);
+  }, [email, password, router, isDemoMode, storeSession]);
 
   // Auto-login on component mount for demo mode
   useEffect(() => {
@@ -135,6 +167,21 @@ export default function LoginPage() {
             {loading ? "Signing in..." : (isDemoMode ? "Sign in (Demo)" : "Sign in")}
           </button>
         </form>
+
+        {!isDemoMode && providers.length > 0 && (
+          <div className="mt-6 space-y-2">
+            <p className="text-center text-xs text-zinc-500">or continue with</p>
+            {providers.map((provider) => (
+              <a
+                key={provider.name}
+                href={ssoLoginUrl(provider, `${window.location.pathname}?sso=1`)}
+                className="flex w-full items-center justify-center rounded-md border border-zinc-300 px-3 py-2 text-sm font-medium text-zinc-700 transition-colors hover:bg-zinc-50"
+              >
+                Sign in with {provider.name}
+              </a>
+            ))}
+          </div>
+        )}
         
         {isDemoMode && (
           <div className="mt-6 p-3 bg-blue-50 border border-blue-200 rounded-md">
@@ -6,6 +6,9 @@ import type {
   LogoutRequest,
   MeResponse,
   ApiError,
+  SsoProvider,
+  SsoProvidersResponse,
+  SessionResponse,
 } from "./types";
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_BASE_URL ?? "http://localhost:8080";
@@ -62,3 +65,29 @@ export async function me(accessToken: string): Promise<MeResponse> {
   });
   return handleResponse<MeResponse>(res);
 }
+
+export async function ssoProviders(): Promise<SsoProvider[]> {
+  const res = await fetch(`${API_BASE_URL}/api/v1/auth/sso/providers`);
+  const body = await handleResponse<SsoProvidersResponse>(res);
+  return body.providers;
+}
+
+export function ssoLoginUrl(provider: SsoProvider, returnTo: string): string {
+  return `${API_BASE_URL}${provider.login_url}?return_to=${encodeURIComponent(returnTo)}`;
+}
+
+// Exchanges the HttpOnly SSO session cookie for a fresh access token
+export async function ssoSession(): Promise<SessionResponse> {
+  const res = await fetch(`${API_BASE_URL}/api/v1/auth/session`, {
+    method: "POST",
+    credentials: "include",
+  });
+  return handleResponse<SessionResponse>(res);
+}
+
+export async function endSsoSession(): Promise<void> {
+  await fetch(`${API_BASE_URL}/api/v1/auth/session`, {
+    method: "DELETE",
+    credentials: "include",
+  });
+}
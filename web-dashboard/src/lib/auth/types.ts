@@ -36,3 +36,23 @@ export interface ApiError {
   error: string;
   code: string;
 }
+
+export interface SsoProvider {
+  name: string;
+  type: "google" | "github" | "oidc";
+  login_url: string;
+}
+
+export interface SsoProvidersResponse {
+  providers: SsoProvider[];
+}
+
+export interface SessionResponse {
+  token: string;
+  expires_at: string;
+  user: {
+    id: string;
+    username: string;
+    roles: UserRole[];
+  };
+}